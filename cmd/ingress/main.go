@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -47,6 +48,7 @@ func main() {
 	leaderElectionNS := flag.String("leader-election-namespace", "default", "Namespace for leader election resources")
 	enableGatewayAPI := flag.Bool("enable-gateway-api", true, "Watch Gateway API resources")
 	enableIngress := flag.Bool("enable-ingress", true, "Watch Ingress v1 resources")
+	enableRoutePolicy := flag.Bool("enable-route-policy", false, "Watch RoutePolicy resources for HTTPRoute ExtensionRef filters (requires the CRD)")
 	flag.Parse()
 
 	if *showVersion {
@@ -112,6 +114,7 @@ func main() {
 		WatchWithoutClass:       *watchWithoutClass,
 		EnableIngress:           *enableIngress,
 		EnableGatewayAPI:        *enableGatewayAPI,
+		EnableRoutePolicy:       *enableRoutePolicy,
 		DebounceDelay:           *debounceDelay,
 		MetricsAddr:             fmt.Sprintf(":%d", *metricsPort),
 		BaseConfig:              baseCfg,
@@ -119,15 +122,17 @@ func main() {
 		DefaultHTTPPort:         *httpPort,
 		DefaultHTTPSPort:        *httpsPort,
 		LeaderElectionNamespace: *leaderElectionNS,
-		ReloadFn: func(cfg *gw.Config) {
+		ReloadFn: func(cfg *gw.Config) (string, error) {
 			result := server.Reload(cfg)
-			if result.Success {
-				logging.Info("Config reloaded successfully",
-					zap.Strings("changes", result.Changes),
-				)
-			} else {
+			if !result.Success {
 				logging.Error("Config reload failed", zap.String("error", result.Error))
+				return "", errors.New(result.Error)
 			}
+			logging.Info("Config reloaded successfully",
+				zap.Strings("changes", result.Changes),
+				zap.String("config_hash", result.ConfigHash),
+			)
+			return result.ConfigHash, nil
 		},
 	}
 
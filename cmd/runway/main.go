@@ -23,10 +23,17 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay-file" {
+		os.Exit(replayFile(os.Args[2:]))
+	}
+
 	// Parse command line flags
 	configPath := flag.String("config", "configs/runway.yaml", "Path to configuration file")
+	configDir := flag.String("config-dir", "", "Directory of YAML files whose routes, upstreams and tenants are merged into the configuration")
 	showVersion := flag.Bool("version", false, "Show version information")
 	validateOnly := flag.Bool("validate", false, "Validate configuration and exit")
+	render := flag.Bool("render", false, "Print the effective configuration with merges applied and exit")
+	provenance := flag.Bool("provenance", false, "With -render, annotate inherited values with their source")
 	flag.Parse()
 
 	if *showVersion {
@@ -35,17 +42,39 @@ func main() {
 	}
 
 	// Load configuration
-	cfg, err := gw.LoadConfig(*configPath)
+	cfg, err := gw.LoadConfigDir(*configPath, *configDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
 		os.Exit(1)
 	}
 
 	if *validateOnly {
+		for _, w := range cfg.DeprecationWarnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+		for _, w := range cfg.ResponsePipelineWarnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+		for _, w := range cfg.CacheKeyWarnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+		for _, w := range cfg.EgressWarnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
 		fmt.Println("Configuration is valid")
 		os.Exit(0)
 	}
 
+	if *render {
+		out, err := gw.RenderConfig(cfg, *provenance)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to render configuration: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(out)
+		os.Exit(0)
+	}
+
 	// Initialize structured logger
 	logger, logCloser, err := logging.New(logging.Config{
 		Level:      cfg.Logging.Level,
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/wudi/runway/internal/mirror"
+)
+
+// replayFile implements "runway replay-file": it sends the requests recorded
+// by a mirror sink to a target, and prints a summary.
+func replayFile(args []string) int {
+	fs := flag.NewFlagSet("replay-file", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: runway replay-file -target URL [flags] FILE...\n\n")
+		fs.PrintDefaults()
+	}
+	target := fs.String("target", "", "Base URL to send the recorded requests to")
+	rate := fs.Float64("rate", 10, "Requests per second (0 = as fast as -concurrency allows)")
+	concurrency := fs.Int("concurrency", 16, "Maximum requests in flight")
+	timeout := fs.Duration("timeout", 10*time.Second, "Per-request timeout")
+	fs.Parse(args)
+
+	if *target == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := mirror.Replay(ctx, fs.Args(), mirror.ReplayOptions{
+		Target:      *target,
+		Rate:        *rate,
+		Concurrency: *concurrency,
+		Client:      &http.Client{Timeout: *timeout},
+	})
+	out, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(out))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Replay failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/wudi/runway/internal/artifact"
+)
+
+// WithArtifactClient sets the HTTP client remote artifacts are fetched
+// with, e.g. one trusting a private registry's CA.
+func WithArtifactClient(c *http.Client) LoaderOption {
+	return func(l *Loader) { l.artifactClient = c }
+}
+
+// CacheDirOrDefault returns the directory artifacts are cached in.
+func (c ArtifactsConfig) CacheDirOrDefault() string {
+	if c.CacheDir != "" {
+		return c.CacheDir
+	}
+	return filepath.Join(os.TempDir(), "runway-artifacts")
+}
+
+// ArtifactStore returns a store for the artifacts c configures.
+func (c ArtifactsConfig) ArtifactStore(client *http.Client) *artifact.Store {
+	creds := make(map[string]artifact.Credentials, len(c.Credentials))
+	for host, cr := range c.Credentials {
+		creds[host] = artifact.Credentials{Username: cr.Username, Password: cr.Password}
+	}
+	return artifact.NewStore(artifact.Options{
+		Dir:         c.CacheDirOrDefault(),
+		MaxSize:     c.MaxSize,
+		Timeout:     c.Timeout,
+		Credentials: creds,
+		Client:      client,
+	})
+}
+
+// resolveArtifacts fetches the oci:// and https:// references of
+// descriptor, OpenAPI spec and WASM plugin path fields into the artifact
+// cache, verifies their digests and rewrites the fields to the cached
+// copies. Fields referencing the same digest share one fetch.
+func (l *Loader) resolveArtifacts(cfg *Config) error {
+	if cfg.Artifacts.Timeout < 0 || cfg.Artifacts.MaxSize < 0 {
+		return fmt.Errorf("artifacts: timeout and max_size must be >= 0")
+	}
+	var store *artifact.Store
+	fetched := make(map[string]string) // digest -> cached path
+	cfg.ArtifactRefs = nil
+	resolve := func(route, field string, path *string) error {
+		if !artifact.IsRemote(*path) {
+			return nil
+		}
+		scope := field
+		if route != "" {
+			scope = "route " + route + ": " + field
+		}
+		ref, err := artifact.ParseRef(*path)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", scope, *path, err)
+		}
+		local, ok := fetched[ref.Digest]
+		if !ok {
+			if store == nil {
+				store = cfg.Artifacts.ArtifactStore(l.artifactClient)
+			}
+			if local, err = store.Fetch(context.Background(), ref); err != nil {
+				return fmt.Errorf("%s %s: %w", scope, *path, err)
+			}
+			fetched[ref.Digest] = local
+		}
+		cfg.ArtifactRefs = append(cfg.ArtifactRefs, ArtifactRef{
+			Reference: *path,
+			Digest:    ref.Digest,
+			Path:      local,
+			Route:     route,
+			Field:     field,
+		})
+		*path = local
+		return nil
+	}
+
+	for i := range cfg.OpenAPI.Specs {
+		spec := &cfg.OpenAPI.Specs[i]
+		if err := resolve("", "openapi.specs["+spec.ID+"].file", &spec.File); err != nil {
+			return err
+		}
+	}
+	for i := range cfg.Routes {
+		route := &cfg.Routes[i]
+		for j := range route.Protocol.REST.DescriptorFiles {
+			if err := resolve(route.ID, fmt.Sprintf("protocol.rest.descriptor_files[%d]", j), &route.Protocol.REST.DescriptorFiles[j]); err != nil {
+				return err
+			}
+		}
+		if err := resolve(route.ID, "openapi.spec_file", &route.OpenAPI.SpecFile); err != nil {
+			return err
+		}
+		for j := range route.WasmPlugins {
+			if err := resolve(route.ID, fmt.Sprintf("wasm_plugins[%d].path", j), &route.WasmPlugins[j].Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestResolveArtifacts(t *testing.T) {
+	spec := []byte("openapi: 3.0.0\ninfo: {title: items, version: '1'}\npaths: {}\n")
+	sum := sha256.Sum256(spec)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	var fetches atomic.Int64
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(spec)
+	}))
+	defer srv.Close()
+
+	cacheDir := t.TempDir()
+	load := func(ref string) (*Config, error) {
+		route := func(id string) string {
+			return "  - id: " + id + "\n    path: /" + id + "\n    backends:\n      - url: http://localhost:9000\n    openapi:\n      spec_file: " + ref + "\n"
+		}
+		yaml := "listeners:\n  - id: http\n    address: \":8080\"\n    protocol: http\n" +
+			"artifacts:\n  cache_dir: " + cacheDir + "\n" +
+			"routes:\n" + route("items") + route("orders")
+		return NewLoader(WithArtifactClient(srv.Client())).Parse([]byte(yaml))
+	}
+
+	ref := srv.URL + "/items.yaml@" + digest
+	cfg, err := load(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := cfg.Artifacts.ArtifactStore(nil).Path(digest)
+	for _, rc := range cfg.Routes {
+		if rc.OpenAPI.SpecFile != path {
+			t.Errorf("route %s: spec_file = %q, want the cached copy %q", rc.ID, rc.OpenAPI.SpecFile, path)
+		}
+	}
+	if got, _ := os.ReadFile(path); string(got) != string(spec) {
+		t.Errorf("cached content = %q", got)
+	}
+	if len(cfg.ArtifactRefs) != 2 || cfg.ArtifactRefs[1] != (ArtifactRef{Reference: ref, Digest: digest, Path: path, Route: "orders", Field: "openapi.spec_file"}) {
+		t.Errorf("unexpected refs %+v", cfg.ArtifactRefs)
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("expected routes with the same digest to share one fetch, got %d", fetches.Load())
+	}
+
+	// Loading again reuses the cache.
+	if _, err := load(ref); err != nil || fetches.Load() != 1 {
+		t.Errorf("expected a cache hit, got %v after %d fetches", err, fetches.Load())
+	}
+
+	// Content that does not match the pinned digest fails the load.
+	wrong := srv.URL + "/items.yaml@sha256:" + strings.Repeat("0", 64)
+	if _, err := load(wrong); err == nil || !strings.Contains(err.Error(), "route items: openapi.spec_file "+wrong+": digest mismatch") {
+		t.Errorf("expected a digest mismatch naming the reference, got %v", err)
+	}
+	if _, err := load(srv.URL + "/items.yaml"); err == nil || !strings.Contains(err.Error(), "missing digest") {
+		t.Errorf("expected a missing digest error, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// highCardinalityHeaders are request headers that usually differ per user,
+// so keying a cache on them stores a variant per user.
+var highCardinalityHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// CacheKeyWarning describes a route whose cache key includes a
+// high-cardinality header without allow_high_cardinality.
+type CacheKeyWarning struct {
+	Route   string `json:"route"`
+	Header  string `json:"header"`
+	Message string `json:"message"`
+}
+
+// String formats the warning for CLI and log output.
+func (w CacheKeyWarning) String() string {
+	return fmt.Sprintf("route %s: cache.key_headers: %s: %s", w.Route, w.Header, w.Message)
+}
+
+// cacheKeyWarnings lists the high-cardinality key_headers of every caching
+// route that does not set allow_high_cardinality.
+func cacheKeyWarnings(cfg *Config) []CacheKeyWarning {
+	var warnings []CacheKeyWarning
+	for _, rc := range cfg.Routes {
+		if !rc.Cache.Enabled || rc.Cache.AllowHighCardinality {
+			continue
+		}
+		for _, h := range rc.Cache.KeyHeaders {
+			for _, hc := range highCardinalityHeaders {
+				if http.CanonicalHeaderKey(h) != hc {
+					continue
+				}
+				msg := "stores a variant per user and can evict the rest of the store; set max_variants_per_path or max_entries and allow_high_cardinality: true if intended"
+				if rc.Cache.MaxEntries > 0 || rc.Cache.MaxVariantsPerPath > 0 {
+					msg = "stores a variant per user; set allow_high_cardinality: true if intended"
+				}
+				warnings = append(warnings, CacheKeyWarning{Route: rc.ID, Header: hc, Message: msg})
+			}
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCacheKeyWarnings(t *testing.T) {
+	tests := []struct {
+		name  string
+		cache string
+		want  []string // headers warned about
+	}{
+		{
+			name:  "no key headers",
+			cache: "      enabled: true\n",
+		},
+		{
+			name: "authorization and cookie",
+			cache: `      enabled: true
+      key_headers: [authorization, Accept-Language, Cookie]
+`,
+			want: []string{"Authorization", "Cookie"},
+		},
+		{
+			name: "allowed",
+			cache: `      enabled: true
+      key_headers: [Authorization]
+      allow_high_cardinality: true
+`,
+		},
+		{
+			name: "cache disabled",
+			cache: `      key_headers: [Authorization]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yaml := `
+routes:
+  - id: users
+    path: /users
+    backends:
+      - url: http://localhost:8080
+    cache:
+` + tt.cache
+			cfg, err := NewLoader().Parse([]byte(yaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, w := range cfg.CacheKeyWarnings {
+				if w.Route != "users" || w.Message == "" {
+					t.Errorf("unexpected warning %+v", w)
+				}
+				got = append(got, w.Header)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("warned headers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheBudgetValidation(t *testing.T) {
+	for _, field := range []string{"max_entries", "max_bytes", "max_variants_per_path"} {
+		yaml := `
+routes:
+  - id: users
+    path: /users
+    backends:
+      - url: http://localhost:8080
+    cache:
+      enabled: true
+      ` + field + `: -1
+`
+		_, err := NewLoader().Parse([]byte(yaml))
+		want := "route users: cache." + field + " must be >= 0"
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
@@ -18,12 +18,45 @@ const (
 
 // UpstreamConfig defines a named backend pool that can be referenced by multiple routes.
 type UpstreamConfig struct {
-	Backends       []BackendConfig      `yaml:"backends"`
-	Service        ServiceConfig        `yaml:"service"`
-	LoadBalancer   string               `yaml:"load_balancer"`
-	ConsistentHash ConsistentHashConfig `yaml:"consistent_hash"`
-	HealthCheck    *HealthCheckConfig   `yaml:"health_check"`
-	Transport      TransportConfig      `yaml:"transport"`
+	Backends        []BackendConfig            `yaml:"backends"`
+	Service         ServiceConfig              `yaml:"service"`
+	DNSDiscovery    UpstreamDNSDiscoveryConfig `yaml:"dns_discovery"` // backends from SRV records, by priority group
+	LoadBalancer    string                     `yaml:"load_balancer"`
+	ConsistentHash  ConsistentHashConfig       `yaml:"consistent_hash"`
+	EWMA            EWMAConfig                 `yaml:"ewma"`
+	HealthCheck     *HealthCheckConfig         `yaml:"health_check"`
+	Transport       TransportConfig            `yaml:"transport"`
+	TransportCanary TransportCanaryConfig      `yaml:"transport_canary"`
+
+	MaxUpstreamStreams int `yaml:"max_upstream_streams"` // concurrent SSE/WebSocket streams per backend, across all routes using the upstream (0 = unlimited)
+}
+
+// UpstreamDNSDiscoveryConfig populates an upstream's backends from SRV
+// records. Only the lowest priority group with a healthy member receives
+// traffic; the next group takes over when all of its members are unhealthy.
+type UpstreamDNSDiscoveryConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Name          string        `yaml:"name"`           // SRV service name, or the full record name when domain is empty
+	Domain        string        `yaml:"domain"`         // looks up _<name>._<protocol>.<domain>
+	Protocol      string        `yaml:"protocol"`       // SRV protocol: "tcp" (default) or "udp"
+	Nameserver    string        `yaml:"nameserver"`     // optional custom DNS server "host:port"
+	PollInterval  time.Duration `yaml:"poll_interval"`  // default 30s
+	Scheme        string        `yaml:"scheme"`         // backend URL scheme: "http" (default) or "https"
+	FailbackDelay time.Duration `yaml:"failback_delay"` // how long a preferred group must stay healthy before traffic returns to it (default 30s)
+}
+
+// TransportCanaryConfig sends a share of an upstream's requests through a
+// second transport built from a candidate config, falling back to the
+// primary transport automatically when the candidate misbehaves.
+type TransportCanaryConfig struct {
+	Enabled                 bool            `yaml:"enabled"`
+	Transport               TransportConfig `yaml:"transport"`                  // candidate settings, merged over the upstream's transport
+	Percentage              int             `yaml:"percentage"`                 // 0-100 share of requests, picked by request ID hash
+	Routes                  []string        `yaml:"routes"`                     // routes whose requests always use the candidate
+	Window                  time.Duration   `yaml:"window"`                     // evaluation window (default 1m)
+	MinRequests             int             `yaml:"min_requests"`               // candidate requests per window before evaluating (default 20)
+	MaxErrorRate            float64         `yaml:"max_error_rate"`             // 0.0-1.0 transport error rate that triggers fallback (default 0.05)
+	MaxHandshakeFailureRate float64         `yaml:"max_handshake_failure_rate"` // 0.0-1.0 dial/TLS/QUIC handshake failure rate that triggers fallback (default 0.02)
 }
 
 // Config represents the complete runway configuration
@@ -48,6 +81,7 @@ type Config struct {
 	Webhooks       WebhooksConfig       `yaml:"webhooks"`        // Event webhook notifications
 	HealthCheck    HealthCheckConfig    `yaml:"health_check"`    // Global health check settings
 	ErrorPages     ErrorPagesConfig     `yaml:"error_pages"`     // Global custom error pages
+	Errors         ErrorsConfig         `yaml:"errors"`          // Format of gateway-generated error responses
 	Nonce          NonceConfig          `yaml:"nonce"`           // Global nonce replay prevention
 	CSRF           CSRFConfig           `yaml:"csrf"`            // Global CSRF protection
 	Geo            GeoConfig            `yaml:"geo"`             // Global geo filtering
@@ -56,10 +90,14 @@ type Config struct {
 	Transport              TransportConfig              `yaml:"transport"`                // Global upstream transport settings
 	RequestDecompression   RequestDecompressionConfig   `yaml:"request_decompression"`    // Global request decompression
 	ResponseLimit          ResponseLimitConfig          `yaml:"response_limit"`           // Global response size limit
+	ResponseBuffering      ResponseBufferingConfig      `yaml:"response_buffering"`       // Global response buffering limits and spill directory
 	SecurityHeaders        SecurityHeadersConfig        `yaml:"security_headers"`         // Global security response headers
 	Maintenance            MaintenanceConfig            `yaml:"maintenance"`              // Global maintenance mode
+	SyntheticMonitoring    SyntheticMonitoringConfig    `yaml:"synthetic_monitoring"`     // Global synthetic monitor probe handling
+	ClientAborts           ClientAbortsConfig           `yaml:"client_aborts"`            // Accounting of requests abandoned by the client
 	Shutdown               ShutdownConfig               `yaml:"shutdown"`                 // Graceful shutdown settings
 	TrustedProxies         TrustedProxiesConfig         `yaml:"trusted_proxies"`          // Trusted proxy IP extraction
+	IPv6AggregationPrefix  int                          `yaml:"ipv6_aggregation_prefix"`  // Prefix length IPv6 clients are keyed by in rate limits, quotas and reputation (default 56)
 	BotDetection           BotDetectionConfig           `yaml:"bot_detection"`            // Global bot detection
 	AICrawlControl         AICrawlConfig                `yaml:"ai_crawl_control"`         // Global AI crawler control
 	ClientMTLS             ClientMTLSConfig             `yaml:"client_mtls"`              // Global per-route client mTLS verification
@@ -67,15 +105,29 @@ type Config struct {
 	AllowedHosts           AllowedHostsConfig           `yaml:"allowed_hosts"`            // Host header validation
 	TokenRevocation        TokenRevocationConfig        `yaml:"token_revocation"`         // JWT token revocation / blocklist
 	ServiceRateLimit       ServiceRateLimitConfig       `yaml:"service_rate_limit"`        // Global service-level rate limit
+	RateLimitState         RateLimitStateConfig         `yaml:"rate_limit_state"`          // Persist local rate limit buckets across reloads and restarts
 	SpikeArrest            SpikeArrestConfig            `yaml:"spike_arrest"`              // Global spike arrest defaults
 	DebugEndpoint          DebugEndpointConfig          `yaml:"debug_endpoint"`            // Debug endpoint for request inspection
+	TestMode               TestModeConfig               `yaml:"test_mode"`                 // Frozen clock and seeded randomness for integration tests
 	CDNCacheHeaders        CDNCacheConfig               `yaml:"cdn_cache_headers"`         // Global CDN cache header injection
 	EdgeCacheRules         EdgeCacheRulesConfig         `yaml:"edge_cache_rules"`          // Global conditional edge cache rules
 	RetryBudgets           map[string]BudgetConfig      `yaml:"retry_budgets"`             // Named shared retry budget pools
+	ExtAuthServices        map[string]ExtAuthConfig     `yaml:"ext_auth_services"`         // Named shared ext auth services (ext_auth.ref)
+	OPAPolicies            map[string]OPAConfig         `yaml:"opa_policies"`              // Named shared OPA policies (opa.ref)
+	BackendAuthProviders   map[string]BackendAuthConfig `yaml:"backend_auth_providers"`    // Named shared backend auth providers (backend_auth.ref)
+	TokenExchangers        map[string]TokenExchangeConfig `yaml:"token_exchangers"`        // Named shared token exchangers (token_exchange.ref)
 	InboundSigning         InboundSigningConfig         `yaml:"inbound_signing"`           // Global inbound request signature verification
 	SSRFProtection         SSRFProtectionConfig         `yaml:"ssrf_protection"`           // SSRF protection for outbound connections
+	EgressPolicy           EgressPolicyConfig           `yaml:"egress_policy"`             // Allowlist of outbound destinations
+	XMLLimits              XMLLimitsConfig              `yaml:"xml_limits"`                // Hardened XML parsing limits
+	RouteMetadata          RouteMetadataConfig          `yaml:"route_metadata"`            // Which route metadata keys reach logs and metrics
+	StorageEncryption      StorageEncryptionConfig      `yaml:"storage_encryption"`        // Keys for encrypting cache, idempotency and dedup entries in Redis
 	IPBlocklist            IPBlocklistConfig            `yaml:"ip_blocklist"`              // Dynamic IP blocklist
+	Reputation             ReputationConfig             `yaml:"reputation"`                // Client IP reputation scoring with automatic temporary blocks
+	PeerFailover           PeerFailoverConfig           `yaml:"peer_failover"`             // Peer gateways that take over routes with no healthy backends
 	LoadShedding           LoadSheddingConfig           `yaml:"load_shedding"`             // System-level load shedding
+	Warmup                 WarmupConfig                 `yaml:"warmup"`                    // Gradual traffic warm-up after start or large reloads
+	FeatureFlags           FeatureFlagsConfig           `yaml:"feature_flags"`             // Runtime overrides watched from Consul KV or etcd
 	AuditLog               AuditLogConfig               `yaml:"audit_log"`                 // Global audit logging defaults
 	Wasm                   WasmConfig                   `yaml:"wasm"`                      // WASM plugin runtime settings
 	Tenants                TenantsConfig                `yaml:"tenants"`                   // Multi-tenancy configuration
@@ -84,8 +136,52 @@ type Config struct {
 	ConsumerGroups         ConsumerGroupsConfig         `yaml:"consumer_groups"`           // Consumer group definitions
 	Baggage                BaggageConfig                `yaml:"baggage"`                   // Global baggage propagation defaults
 	Secrets                SecretsConfig                `yaml:"secrets"`                   // Secret provider settings
+	Artifacts              ArtifactsConfig              `yaml:"artifacts"`                 // Fetching of oci:// and https:// artifact references
 	Extensions             map[string]yaml.RawMessage   `yaml:"extensions,omitempty"`      // Plugin extension config (raw YAML, decoded by plugins)
 	Cluster                ClusterConfig                `yaml:"cluster"`                   // CP/DP cluster mode
+	StrictDeprecations     bool                         `yaml:"strict_deprecations"`       // Fail loading when deprecated fields are used
+	ResponsePipelineConflicts string                    `yaml:"response_pipeline_conflicts"` // Conflicting response body stages: "warn" (default), "error", "off"
+	Includes               []string                     `yaml:"includes,omitempty"`        // Files (globs) whose routes, upstreams and tenants are merged in
+
+	// DeprecationWarnings lists the deprecated fields the loader translated.
+	// Populated by Loader.Parse; never read from YAML.
+	DeprecationWarnings []DeprecationWarning `yaml:"-"`
+
+	// ResponsePipelineWarnings lists conflicting response body stages.
+	// Populated by Loader.Parse; never read from YAML.
+	ResponsePipelineWarnings []ResponsePipelineWarning `yaml:"-"`
+
+	// CacheKeyWarnings lists cache key_headers likely to store a variant
+	// per user. Populated by Loader.Parse; never read from YAML.
+	CacheKeyWarnings []CacheKeyWarning `yaml:"-"`
+
+	// EgressWarnings lists static destinations outside the egress policy's
+	// allowlist in warn mode. Populated by Loader.Parse; never read from YAML.
+	EgressWarnings []EgressWarning `yaml:"-"`
+
+	// SecretRefs maps secret values resolved from ${scheme:ref} references
+	// to their reference, so the config can be persisted without them.
+	// Populated by Loader.Parse; never read from YAML.
+	SecretRefs map[string]string `yaml:"-"`
+
+	// SecretRegistry is the registry the loader resolved secret references
+	// with, kept for components that re-resolve them at runtime. Populated
+	// by Loader.Parse; never read from YAML.
+	SecretRegistry *SecretRegistry `yaml:"-"`
+
+	// ArtifactRefs lists the remote artifacts fetched for path fields, which
+	// the loader rewrote to the cached copies. Populated by Loader.Parse;
+	// never read from YAML.
+	ArtifactRefs []ArtifactRef `yaml:"-"`
+
+	// RouteSources maps route IDs to the file that defined them when the
+	// config includes other files. Populated by Loader.Parse; never read
+	// from YAML.
+	RouteSources map[string]string `yaml:"-"`
+
+	// ConfigDir is the directory whose files were included with
+	// WithConfigDir, re-read on reload. Never read from YAML.
+	ConfigDir string `yaml:"-"`
 }
 
 // SecretsConfig defines secret provider settings.
@@ -98,6 +194,31 @@ type FileSecretsConfig struct {
 	AllowedPrefixes []string `yaml:"allowed_prefixes"` // optional path restriction for ${file:...} references
 }
 
+// ArtifactsConfig configures fetching the oci:// and https:// references
+// accepted in place of descriptor, OpenAPI spec and WASM plugin paths.
+type ArtifactsConfig struct {
+	CacheDir    string                         `yaml:"cache_dir"`   // content-addressed cache (default <tmp>/runway-artifacts)
+	Timeout     time.Duration                  `yaml:"timeout"`     // per artifact (default 30s)
+	MaxSize     int64                          `yaml:"max_size"`    // bytes per artifact (default 64MB)
+	Credentials map[string]ArtifactCredentials `yaml:"credentials"` // by registry or HTTPS host
+}
+
+// ArtifactCredentials authenticate artifact pulls. Registries that answer
+// with a bearer challenge receive them at their token service.
+type ArtifactCredentials struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password" redact:"true"`
+}
+
+// ArtifactRef is a remote artifact the loader fetched for a path field.
+type ArtifactRef struct {
+	Reference string `json:"reference"`       // as configured, e.g. oci://registry/repo:tag@sha256:<hex>
+	Digest    string `json:"digest"`          // sha256:<hex>
+	Path      string `json:"path"`            // cached copy the field now holds
+	Route     string `json:"route,omitempty"` // empty for top-level fields
+	Field     string `json:"field"`           // e.g. openapi.spec_file
+}
+
 // ListenerConfig defines a listener configuration
 type ListenerConfig struct {
 	ID       string             `yaml:"id"`
@@ -117,6 +238,19 @@ type HTTPListenerConfig struct {
 	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
 	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
 	EnableHTTP3       bool          `yaml:"enable_http3"` // serve HTTP/3 over QUIC on same port
+
+	HTTP2 StreamHardeningConfig `yaml:"http2"` // limits for HTTP/2 connections (TLS only)
+	HTTP3 StreamHardeningConfig `yaml:"http3"` // limits for HTTP/3 connections
+}
+
+// StreamHardeningConfig limits what a single multiplexed HTTP/2 or HTTP/3
+// connection may do. Changes apply to connections accepted after a reload.
+type StreamHardeningConfig struct {
+	MaxConcurrentStreams int           `yaml:"max_concurrent_streams"` // per connection; default 250 (HTTP/2), 100 (HTTP/3)
+	MaxHeaderListSize    int           `yaml:"max_header_list_size"`   // decoded header bytes incl. 32 per field; default max_header_bytes
+	MaxResets            int           `yaml:"max_resets"`             // client stream resets per window before the connection is closed; default 500
+	MaxRequests          int           `yaml:"max_requests"`           // requests per window before the connection is closed; default 20000
+	Window               time.Duration `yaml:"window"`                 // accounting window for max_resets and max_requests; default 10s
 }
 
 // TCPListenerConfig defines TCP-specific listener settings
@@ -176,6 +310,7 @@ type RegistryConfig struct {
 	Kubernetes KubernetesConfig `yaml:"kubernetes"`
 	Memory     MemoryConfig     `yaml:"memory"`
 	DNSSRV     DNSSRVConfig     `yaml:"dns"`
+	LocalZone  string           `yaml:"local_zone"` // this gateway's zone, for services with locality_aware
 }
 
 // DNSSRVConfig defines DNS SRV service discovery settings.
@@ -224,6 +359,14 @@ type TLSConfig struct {
 	ClientAuth   string          `yaml:"client_auth"`   // Feature 11: mTLS - none, request, require, verify
 	ClientCAFile string          `yaml:"client_ca_file"` // Feature 11: mTLS
 	ACME         ACMEConfig      `yaml:"acme"`           // Automatic certificate provisioning via ACME/Let's Encrypt
+	Reload       TLSReloadConfig `yaml:"reload"`         // Reload cert/key files when they change on disk (listeners)
+}
+
+// TLSReloadConfig polls a listener's certificate files and serves renewed
+// certificates without a config reload, e.g. after cert-manager renews them.
+type TLSReloadConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"` // how often file mtimes are checked (default 30s)
 }
 
 // ACMEConfig defines ACME (Let's Encrypt) automatic certificate provisioning settings.
@@ -287,8 +430,11 @@ type AuthenticationConfig struct {
 // TokenExchangeConfig defines OAuth2/OIDC token exchange (RFC 8693) settings.
 type TokenExchangeConfig struct {
 	Enabled          bool              `yaml:"enabled"`
+	Ref              string            `yaml:"ref"`                // reference to named exchanger in Config.TokenExchangers
 	ValidationMode   string            `yaml:"validation_mode"`    // "jwt" (local JWKS) or "introspection"
 	JWKSURL          string            `yaml:"jwks_url"`           // for jwt mode
+	JWKSURLs         []string          `yaml:"jwks_urls"`          // for jwt mode: JWKS mirrors tried in order (instead of jwks_url)
+	JWKSFetchTimeout time.Duration     `yaml:"jwks_fetch_timeout"` // per JWKS fetch (default 10s)
 	TrustedIssuers   []string          `yaml:"trusted_issuers"`    // for jwt mode
 	IntrospectionURL string            `yaml:"introspection_url"`  // for introspection mode
 	ClientID         string            `yaml:"client_id"`          // for introspection mode
@@ -316,11 +462,26 @@ type APIKeyConfig struct {
 
 // APIKeyManagementConfig defines settings for managed API key generation/rotation/revocation.
 type APIKeyManagementConfig struct {
-	Enabled          bool                  `yaml:"enabled"`
-	KeyLength        int                   `yaml:"key_length"`         // bytes (default 32)
-	KeyPrefix        string                `yaml:"key_prefix"`         // e.g., "gw_"
-	Store            string                `yaml:"store"`              // "memory" (default) or "redis"
-	DefaultRateLimit *KeyRateLimitConfig   `yaml:"default_rate_limit"` // default per-key rate limit
+	Enabled          bool                    `yaml:"enabled"`
+	KeyLength        int                     `yaml:"key_length"`         // bytes (default 32)
+	KeyPrefix        string                  `yaml:"key_prefix"`         // e.g., "gw_"
+	Store            string                  `yaml:"store"`              // "memory" (default) or "redis"
+	DefaultRateLimit *KeyRateLimitConfig     `yaml:"default_rate_limit"` // default per-key rate limit
+	SelfService      APIKeySelfServiceConfig `yaml:"self_service"`       // client-facing key lifecycle endpoints
+}
+
+// APIKeySelfServiceConfig defines gateway-served endpoints that let
+// JWT-authenticated users create, list, rotate and revoke their own keys.
+type APIKeySelfServiceConfig struct {
+	Enabled         bool                `yaml:"enabled"`
+	PathPrefix      string              `yaml:"path_prefix"`       // default "/keys"
+	Listeners       []string            `yaml:"listeners"`         // IDs of the HTTP listeners serving the endpoints (default all)
+	OwnerClaim      string              `yaml:"owner_claim"`       // JWT claim identifying the owner (default: token client ID)
+	MaxKeys         int                 `yaml:"max_keys"`          // max active keys per owner (default 10)
+	MaxTTL          time.Duration       `yaml:"max_ttl"`           // cap on key lifetime (0 = no cap)
+	GracePeriod     time.Duration       `yaml:"grace_period"`      // old key validity after rotation (default 1h)
+	CreateRateLimit *KeyRateLimitConfig `yaml:"create_rate_limit"` // per-owner limit on create/rotate (default 10/hour)
+	ListRateLimit   *KeyRateLimitConfig `yaml:"list_rate_limit"`   // per-owner limit on listing keys (default 60/minute, burst 10)
 }
 
 // KeyRateLimitConfig defines per-key rate limit settings.
@@ -348,9 +509,14 @@ type JWTConfig struct {
 	Audience            []string      `yaml:"audience"`
 	Algorithm           string        `yaml:"algorithm"`             // HS256, RS256
 	JWKSURL             string        `yaml:"jwks_url"`              // JWKS endpoint for dynamic key fetching
+	JWKSURLs            []string      `yaml:"jwks_urls"`             // JWKS mirrors tried in order (instead of jwks_url)
 	JWKSRefreshInterval time.Duration `yaml:"jwks_refresh_interval"` // default 1h
+	JWKSFetchTimeout    time.Duration `yaml:"jwks_fetch_timeout"`    // per JWKS fetch (default 10s)
 }
 
+// JWKSEndpoints returns the configured JWKS URLs, in failover order.
+func (c JWTConfig) JWKSEndpoints() []string { return jwksEndpoints(c.JWKSURL, c.JWKSURLs) }
+
 // OAuthConfig defines OAuth 2.0 / OIDC settings (Feature 7)
 type OAuthConfig struct {
 	Enabled              bool          `yaml:"enabled"`
@@ -358,13 +524,29 @@ type OAuthConfig struct {
 	ClientID             string        `yaml:"client_id"`
 	ClientSecret         string        `yaml:"client_secret" redact:"true"`
 	JWKSURL              string        `yaml:"jwks_url"`
+	JWKSURLs             []string      `yaml:"jwks_urls"`
 	JWKSRefreshInterval  time.Duration `yaml:"jwks_refresh_interval"`
+	JWKSFetchTimeout     time.Duration `yaml:"jwks_fetch_timeout"`
 	Issuer               string        `yaml:"issuer"`
 	Audience             string        `yaml:"audience"`
 	Scopes               []string      `yaml:"scopes"`
 	CacheTTL             time.Duration `yaml:"cache_ttl"`
 }
 
+// JWKSEndpoints returns the configured JWKS URLs, in failover order.
+func (c OAuthConfig) JWKSEndpoints() []string { return jwksEndpoints(c.JWKSURL, c.JWKSURLs) }
+
+// JWKSEndpoints returns the configured JWKS URLs, in failover order.
+func (c TokenExchangeConfig) JWKSEndpoints() []string { return jwksEndpoints(c.JWKSURL, c.JWKSURLs) }
+
+// jwksEndpoints returns the single jwks_url as a list, or jwks_urls.
+func jwksEndpoints(single string, list []string) []string {
+	if single != "" {
+		return []string{single}
+	}
+	return list
+}
+
 // BasicAuthConfig defines HTTP Basic Authentication settings
 type BasicAuthConfig struct {
 	Enabled bool            `yaml:"enabled"`
@@ -452,6 +634,36 @@ type SAMLConfig struct {
 	AttributeMapping        SAMLAttributeMapping `yaml:"attribute_mapping"`
 }
 
+// OIDCConfig defines a per-route OpenID Connect relying party: browsers
+// without a session are sent through the authorization code flow with PKCE
+// and the resulting identity is kept in a session cookie.
+type OIDCConfig struct {
+	Enabled             bool              `yaml:"enabled"`
+	Issuer              string            `yaml:"issuer"` // discovery via <issuer>/.well-known/openid-configuration
+	ClientID            string            `yaml:"client_id"`
+	ClientSecret        string            `yaml:"client_secret" redact:"true"` // supports ${ENV_VAR}; empty for public clients
+	Scopes              []string          `yaml:"scopes"`                      // default [openid, profile, email]
+	CallbackPath        string            `yaml:"callback_path"`               // default "/oauth2/callback"
+	LogoutPath          string            `yaml:"logout_path"`                 // default "/oauth2/logout"
+	PostLogoutRedirect  string            `yaml:"post_logout_redirect"`        // default "/"
+	ClientIDClaim       string            `yaml:"client_id_claim"`             // default "sub"
+	BaseURL             string            `yaml:"base_url"`                    // external origin the callback and post-logout URLs are built on, e.g. https://app.example.com
+	AuthorizationParams map[string]string `yaml:"authorization_params"`        // extra authorization request parameters
+	Session             OIDCSessionConfig `yaml:"session"`
+}
+
+// OIDCSessionConfig defines how an OIDC login session is kept.
+type OIDCSessionConfig struct {
+	Store      string        `yaml:"store"`                // "cookie" (default) or "redis"
+	CookieName string        `yaml:"cookie_name"`          // default "runway_oidc"
+	Secret     string        `yaml:"secret" redact:"true"` // encrypts session and login state (>= 32 bytes); supports ${ENV_VAR}
+	MaxAge     time.Duration `yaml:"max_age"`              // absolute session lifetime, default 8h
+	Refresh    *bool         `yaml:"refresh"`              // renew expired tokens with the refresh token (default true)
+	Domain     string        `yaml:"domain"`
+	Secure     *bool         `yaml:"secure"`    // default true
+	SameSite   string        `yaml:"same_site"` // "lax" (default), "strict", "none"
+}
+
 // RouteConfig defines a single route
 type RouteConfig struct {
 	ID             string               `yaml:"id"`
@@ -459,6 +671,7 @@ type RouteConfig struct {
 	PathPrefix     bool                 `yaml:"path_prefix"`
 	Methods        []string             `yaml:"methods"`
 	Match          MatchConfig          `yaml:"match"`
+	Listeners      []string             `yaml:"listeners"` // IDs of the HTTP listeners serving the route (default all)
 	Backends       []BackendConfig      `yaml:"backends"`
 	Service        ServiceConfig        `yaml:"service"`
 	Upstream       string               `yaml:"upstream"` // reference to named upstream in Config.Upstreams
@@ -486,8 +699,9 @@ type RouteConfig struct {
 	TrafficShaping TrafficShapingConfig `yaml:"traffic_shaping"` // Per-route traffic shaping
 	Sticky         StickyConfig         `yaml:"sticky"`          // Sticky sessions for traffic split
 	WAF            WAFConfig            `yaml:"waf"`             // Per-route WAF settings
-	LoadBalancer   string               `yaml:"load_balancer"`   // "round_robin"|"least_conn"|"consistent_hash"|"least_response_time"
+	LoadBalancer   string               `yaml:"load_balancer"`   // "round_robin"|"least_conn"|"consistent_hash"|"least_response_time"|"ewma"
 	ConsistentHash ConsistentHashConfig `yaml:"consistent_hash"` // Config for consistent_hash LB
+	EWMA           EWMAConfig           `yaml:"ewma"`            // Config for ewma LB
 	GraphQL            GraphQLConfig            `yaml:"graphql"`              // GraphQL query analysis and protection
 	GraphQLFederation  GraphQLFederationConfig  `yaml:"graphql_federation"`   // GraphQL federation / schema stitching
 	Coalesce           CoalesceConfig           `yaml:"coalesce"`             // Request coalescing (singleflight)
@@ -497,6 +711,7 @@ type RouteConfig struct {
 	AccessLog      AccessLogConfig      `yaml:"access_log"`      // Per-route access log overrides
 	OpenAPI        OpenAPIRouteConfig   `yaml:"openapi"`         // OpenAPI spec-based validation
 	ErrorPages     ErrorPagesConfig     `yaml:"error_pages"`     // Per-route custom error pages
+	Errors         ErrorsConfig         `yaml:"errors"`          // Per-route error format and catalog
 	Nonce             NonceConfig             `yaml:"nonce"`              // Per-route nonce replay prevention
 	CSRF              CSRFConfig              `yaml:"csrf"`               // Per-route CSRF protection
 	Idempotency       IdempotencyConfig       `yaml:"idempotency"`        // Per-route idempotency key support
@@ -505,8 +720,14 @@ type RouteConfig struct {
 	BackendSigning       BackendSigningConfig       `yaml:"backend_signing"`       // Per-route backend request signing
 	RequestDecompression RequestDecompressionConfig `yaml:"request_decompression"` // Per-route request decompression
 	ResponseLimit        ResponseLimitConfig        `yaml:"response_limit"`        // Per-route response size limit
+	ResponseBuffering    ResponseBufferingConfig    `yaml:"response_buffering"`    // Per-route adaptive response buffering
 	SecurityHeaders      SecurityHeadersConfig      `yaml:"security_headers"`      // Per-route security response headers
 	Maintenance          MaintenanceConfig          `yaml:"maintenance"`           // Per-route maintenance mode
+	SyntheticMonitoring  SyntheticMonitoringConfig  `yaml:"synthetic_monitoring"`  // Per-route synthetic monitor probe handling
+	DegradedMode         DegradedModeConfig         `yaml:"degraded_mode"`         // Per-route degraded mode on upstream failure
+	PeerFailover         RoutePeerFailoverConfig    `yaml:"peer_failover"`         // Forward to peer gateways when no backend is healthy
+	BreakGlass           BreakGlassConfig           `yaml:"break_glass"`           // Emergency bypass profile applied via the admin API
+	MultipartFields      MultipartFieldsConfig      `yaml:"multipart_fields"`      // Form fields captured from multipart uploads without buffering
 	Rewrite              RewriteConfig              `yaml:"rewrite"`               // URL rewriting (prefix, regex, host override)
 	BotDetection         BotDetectionConfig         `yaml:"bot_detection"`         // Per-route bot detection
 	AICrawlControl       AICrawlConfig              `yaml:"ai_crawl_control"`      // Per-route AI crawler control
@@ -515,16 +736,21 @@ type RouteConfig struct {
 	MockResponse         MockResponseConfig         `yaml:"mock_response"`         // Per-route mock responses
 	ClaimsPropagation    ClaimsPropagationConfig    `yaml:"claims_propagation"`    // JWT claims propagation to backend headers
 	BackendAuth          BackendAuthConfig          `yaml:"backend_auth"`          // OAuth2 client_credentials for backend calls
+	BackendHeadersSecret BackendHeadersSecretConfig `yaml:"backend_headers_secret"` // Secret-backed headers injected toward the backend
 	StatusMapping        StatusMappingConfig        `yaml:"status_mapping"`        // Remap backend response status codes
 	Static               StaticConfig               `yaml:"static"`                // Serve static files (replaces proxy)
 	Passthrough          bool                       `yaml:"passthrough"`           // Skip body-processing middleware
 	Echo                 bool                       `yaml:"echo"`                  // Echo handler (no backend needed)
 	SpikeArrest          SpikeArrestConfig          `yaml:"spike_arrest"`          // Per-route spike arrest
+	ConcurrencyLimit     ConcurrencyLimitConfig     `yaml:"concurrency_limit"`     // Per-client max in-flight requests
 	ContentReplacer      ContentReplacerConfig      `yaml:"content_replacer"`      // Per-route response content replacement
 	FollowRedirects      FollowRedirectsConfig      `yaml:"follow_redirects"`      // Follow backend 3xx redirects
+	ForwardInformational bool                       `yaml:"forward_informational"` // Forward backend 1xx responses (e.g. 103 Early Hints)
+	ExpectContinueThreshold int64                `yaml:"expect_continue_threshold"` // Send Expect: 100-continue to the backend for bodies of at least this many bytes
 	BodyGenerator        BodyGeneratorConfig         `yaml:"body_generator"`        // Generate request body from template
 	Sequential           SequentialConfig            `yaml:"sequential"`            // Chain multiple backend calls
 	Quota                QuotaConfig                 `yaml:"quota"`                 // Per-client usage quota enforcement
+	BandwidthQuota       BandwidthQuotaConfig        `yaml:"bandwidth_quota"`       // Per-client request+response byte quota
 	Aggregate            AggregateConfig             `yaml:"aggregate"`             // Parallel multi-backend response aggregation
 	ResponseBodyGenerator ResponseBodyGeneratorConfig `yaml:"response_body_generator"` // Rewrite response body with Go template
 	ParamForwarding      ParamForwardingConfig       `yaml:"param_forwarding"`      // Zero-trust parameter forwarding
@@ -533,6 +759,7 @@ type RouteConfig struct {
 	EdgeCacheRules       EdgeCacheRulesConfig        `yaml:"edge_cache_rules"`      // Per-route conditional edge cache rules
 	BackendEncoding      BackendEncodingConfig       `yaml:"backend_encoding"`      // Decode XML/YAML backend responses to JSON
 	SSE                  SSEConfig                   `yaml:"sse"`                   // Server-Sent Events proxy
+	StreamLimits         StreamLimitsConfig          `yaml:"stream_limits"`         // Cap concurrent SSE/WebSocket streams per backend
 	InboundSigning       InboundSigningConfig        `yaml:"inbound_signing"`       // Per-route inbound request signature verification
 	PIIRedaction         PIIRedactionConfig          `yaml:"pii_redaction"`         // Per-route PII redaction
 	FieldEncryption      FieldEncryptionConfig       `yaml:"field_encryption"`      // Per-route field-level encryption
@@ -540,27 +767,33 @@ type RouteConfig struct {
 	ABTest               ABTestConfig                `yaml:"ab_test"`               // A/B testing with metric collection
 	FastCGI              FastCGIConfig               `yaml:"fastcgi"`               // FastCGI proxy (replaces proxy)
 	RequestDedup         RequestDedupConfig          `yaml:"request_dedup"`         // Per-route request deduplication
+	ContentDedup         ContentDedupConfig          `yaml:"content_dedup"`         // Per-route dedup of identical mutation requests
 	IPBlocklist          IPBlocklistConfig           `yaml:"ip_blocklist"`          // Per-route dynamic IP blocklist
 	Baggage              BaggageConfig               `yaml:"baggage"`               // Per-route baggage propagation
 	Backpressure         BackpressureConfig          `yaml:"backpressure"`          // Per-route backend backpressure detection
 	AuditLog             AuditLogConfig              `yaml:"audit_log"`             // Per-route audit logging
+	DecisionLog          DecisionLogConfig           `yaml:"decision_log"`          // Per-request policy decision records
 	Modifiers            []ModifierConfig            `yaml:"modifiers"`             // Martian-style request/response modifiers
 	FieldReplacer        FieldReplacerConfig         `yaml:"field_replacer"`        // Field-level content replacement
+	ResponseFieldPolicy  ResponseFieldPolicyConfig   `yaml:"response_field_policy"` // Identity-based JSON response field filtering
 	JMESPath             JMESPathConfig              `yaml:"jmespath"`              // JMESPath query on response body
+	ResponsePipelineOrder []string                   `yaml:"response_pipeline_order"` // Execution order of reorderable response body stages
 	BackendResponse      BackendResponseConfig       `yaml:"backend_response"`      // Backend response handling (is_collection, etc.)
-	OutputEncoding       string                      `yaml:"output_encoding"`       // Override Accept-header content negotiation (json, xml, yaml, json-collection, string)
+	OutputEncoding       string                      `yaml:"output_encoding"`       // Override Accept-header content negotiation (json, xml, yaml, cbor, json-collection, string)
 	ErrorHandling        ErrorHandlingConfig         `yaml:"error_handling"`        // Structured error detail modes
 	Lua                  LuaConfig                   `yaml:"lua"`                   // Lua scripting engine
 	WasmPlugins          []WasmPluginConfig          `yaml:"wasm_plugins"`          // WASM plugin chain
 	Lambda               LambdaConfig                `yaml:"lambda"`                // AWS Lambda backend
 	AMQP                 AMQPConfig                  `yaml:"amqp"`                  // AMQP/RabbitMQ backend
 	PubSub               PubSubConfig                `yaml:"pubsub"`                // Pub/Sub backend (Go CDK)
+	Kafka                KafkaConfig                 `yaml:"kafka"`                 // Kafka producer backend
 	Tenant               RouteTenantConfig              `yaml:"tenant"`                 // Per-route tenant restrictions
 	TenantBackends       map[string][]BackendConfig    `yaml:"tenant_backends,omitempty"` // Per-tenant dedicated backends
 	CompletionHeader     bool                          `yaml:"completion_header"`      // Add X-Runway-Completed header
 	SessionAffinity      SessionAffinityConfig          `yaml:"session_affinity"`       // Cookie-based backend pinning
 	TrafficReplay        TrafficReplayConfig            `yaml:"traffic_replay"`         // Record and replay traffic
 	TokenExchange        TokenExchangeConfig            `yaml:"token_exchange"`         // OAuth2/OIDC token exchange (RFC 8693)
+	OIDC                 OIDCConfig                     `yaml:"oidc"`                   // OpenID Connect login for browser routes
 	Deprecation          DeprecationConfig              `yaml:"deprecation"`            // API deprecation lifecycle (RFC 8594)
 	SLO                  SLOConfig                      `yaml:"slo"`                    // SLI/SLO enforcement with error budget
 	ETag                 ETagConfig                     `yaml:"etag"`                   // Per-route ETag generation and conditional requests
@@ -570,6 +803,9 @@ type RouteConfig struct {
 	RequestCost          RequestCostConfig              `yaml:"request_cost"`           // Per-route request cost tracking
 	Connect              ConnectConfig                  `yaml:"connect"`                // HTTP CONNECT tunneling
 	AI                   AIConfig                       `yaml:"ai"`                     // AI runway (LLM proxy)
+	Metrics              RequestMetricsConfig           `yaml:"metrics"`                // Per-route request metric instruments
+	Metadata              map[string]string `yaml:"metadata,omitempty"`      // Arbitrary key/values (team, tier, ...) for variables, rules, logs, metrics and plugins
+	ExposeMetadataHeaders []string          `yaml:"expose_metadata_headers"` // Metadata keys sent as X-Route-<Key> response headers
 	Extensions           map[string]yaml.RawMessage     `yaml:"extensions,omitempty"`   // Plugin extension config (raw YAML, decoded by plugins)
 }
 
@@ -614,6 +850,12 @@ type ConsistentHashConfig struct {
 	Replicas   int    `yaml:"replicas"`    // virtual nodes per backend, default 150
 }
 
+// EWMAConfig defines peak-EWMA load balancer settings.
+type EWMAConfig struct {
+	DecayTime     time.Duration `yaml:"decay_time"`      // time constant of the latency average, default 10s
+	NoDataPenalty time.Duration `yaml:"no_data_penalty"` // latency assumed for backends without recent samples, default 500ms
+}
+
 // RetryConfig defines retry policy settings
 type RetryConfig struct {
 	MaxRetries        int           `yaml:"max_retries"`
@@ -630,9 +872,11 @@ type RetryConfig struct {
 
 // BudgetConfig defines retry budget settings to prevent retry storms.
 type BudgetConfig struct {
-	Ratio      float64       `yaml:"ratio"`       // max ratio of retries to total requests (0.0-1.0)
-	MinRetries int           `yaml:"min_retries"` // always allow at least N retries/sec
-	Window     time.Duration `yaml:"window"`      // sliding window (default 10s)
+	Ratio        float64       `yaml:"ratio"`         // max ratio of retries to total requests (0.0-1.0)
+	MinRetries   int           `yaml:"min_retries"`   // always allow at least N retries/sec
+	Window       time.Duration `yaml:"window"`        // sliding window (default 10s)
+	Mode         string        `yaml:"mode"`          // "local" (default) or "distributed" (Redis-backed, retry_budgets pools only)
+	SyncInterval time.Duration `yaml:"sync_interval"` // distributed: how long cluster counts are cached locally (default 1s)
 }
 
 // HedgingConfig defines request hedging settings.
@@ -678,8 +922,47 @@ type CacheConfig struct {
 	Bucket               string        `yaml:"bucket"`                 // named shared cache bucket (routes with same bucket share a store)
 	StaleWhileRevalidate time.Duration `yaml:"stale_while_revalidate"` // serve stale while refreshing in background
 	StaleIfError         time.Duration `yaml:"stale_if_error"`         // serve stale on backend 5xx errors
+	ServeStaleOnTimeout  bool          `yaml:"serve_stale_on_timeout"` // serve a stale_if_error entry when the backend exceeds soft_timeout
+	SoftTimeout          time.Duration `yaml:"soft_timeout"`           // backend wait before serving stale; the request finishes in the background
 	TagHeaders           []string      `yaml:"tag_headers"`            // response headers to extract cache tags from (values split on space/comma)
 	Tags                 []string      `yaml:"tags"`                   // static tags applied to all entries on this route
+
+	StatusTTLs               map[string]time.Duration `yaml:"status_ttls"`                // cacheable statuses ("404") or classes ("4xx") with their TTL (0 = ttl)
+	WriteThroughInvalidation bool                     `yaml:"write_through_invalidation"` // successful POST/PUT/PATCH/DELETE purges the GET entry for the same path
+	Encrypt                  bool                     `yaml:"encrypt"`                    // seal entries with storage_encryption keys (distributed mode only)
+	MaxVaries                int                      `yaml:"max_varies"`                 // request headers a response may Vary on and still be cached (default 4)
+
+	MaxEntries           int   `yaml:"max_entries"`            // entries the route may hold in its store, evicting its own LRU beyond (0 = store limit only)
+	MaxBytes             int64 `yaml:"max_bytes"`              // body bytes the route may hold in its store (0 = unlimited)
+	MaxVariantsPerPath   int   `yaml:"max_variants_per_path"`  // entries per URL told apart by key_headers, Vary or tenant; more are not stored (0 = unlimited)
+	AllowHighCardinality bool  `yaml:"allow_high_cardinality"` // Authorization, Cookie or Proxy-Authorization in key_headers without a warning
+
+	KeyNormalization CacheKeyNormalizationConfig `yaml:"key_normalization"` // canonical path and query in cache and coalesce keys
+	Prime            CachePrimeConfig            `yaml:"prime"`             // warm the cache from a manifest at startup
+}
+
+// CachePrimeConfig fills a route's cache at startup, and after reloads that
+// leave it empty, by requesting the paths listed in a manifest through the
+// route's own pipeline.
+type CachePrimeConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	Manifest     string        `yaml:"manifest"`      // manifest file path or http(s) URL
+	Concurrency  int           `yaml:"concurrency"`   // parallel priming requests (default 4)
+	Rate         int           `yaml:"rate"`          // priming requests per second (0 = unlimited)
+	Budget       time.Duration `yaml:"budget"`        // total time priming may take (default 5m)
+	Timeout      time.Duration `yaml:"timeout"`       // per request and manifest fetch (default 10s)
+	ReadyPercent int           `yaml:"ready_percent"` // readiness waits until this share of the manifest is done (0 = don't wait)
+}
+
+// CacheKeyNormalizationConfig canonicalizes the request path and query that
+// go into the route's cache and coalesce keys. The request forwarded to the
+// backend is not changed.
+type CacheKeyNormalizationConfig struct {
+	SortQuery          bool     `yaml:"sort_query"`           // order query params by name
+	IgnoreQueryParams  []string `yaml:"ignore_query_params"`  // params left out of the key (globs, e.g. "utm_*")
+	IncludeQueryParams []string `yaml:"include_query_params"` // only these params are kept (globs); exclusive with ignore_query_params
+	LowercasePath      bool     `yaml:"lowercase_path"`       // ASCII letters only
+	StripTrailingSlash bool     `yaml:"strip_trailing_slash"` // "/a/" and "/a" share a key
 }
 
 // WebSocketConfig defines WebSocket proxy settings
@@ -691,6 +974,21 @@ type WebSocketConfig struct {
 	WriteTimeout    time.Duration `yaml:"write_timeout"`
 	PingInterval    time.Duration `yaml:"ping_interval"`
 	PongTimeout     time.Duration `yaml:"pong_timeout"`
+	MaxMessageSize  int64         `yaml:"max_message_size"` // max message size in bytes, enforced in both directions (0 = unlimited)
+	Subprotocols    []string      `yaml:"subprotocols"`     // subprotocols the gateway negotiates; upgrades offering none are rejected (empty = pass through)
+	MaxConnections  int           `yaml:"max_connections"`  // concurrent connections on the route; further upgrades get 503 (0 = unlimited)
+
+	FirstMessageValidation WebSocketMessageValidationConfig `yaml:"first_message_validation"`
+}
+
+// WebSocketMessageValidationConfig validates the first client messages of a
+// WebSocket connection against a JSON schema.
+type WebSocketMessageValidationConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Schema     string `yaml:"schema"`      // inline JSON schema
+	SchemaFile string `yaml:"schema_file"` // path to JSON schema file
+	Messages   int    `yaml:"messages"`    // number of client messages to validate (default 1)
+	Action     string `yaml:"action"`      // "close" (close with 1008, default) or "log"
 }
 
 // SSEConfig defines Server-Sent Events proxy settings.
@@ -716,6 +1014,14 @@ type SSEFanoutConfig struct {
 	FilterParam      string        `yaml:"filter_param"`       // query param name (default "event_type")
 }
 
+// StreamLimitsConfig caps the SSE and WebSocket streams a route keeps open
+// to each of its backends.
+type StreamLimitsConfig struct {
+	MaxUpstreamStreams int           `yaml:"max_upstream_streams"` // concurrent streams per backend (0 = unlimited)
+	OnLimit            string        `yaml:"on_limit"`             // "reject" (503, default) or "fanout" (SSE: serve from a shared fan-out hub)
+	RetryAfter         time.Duration `yaml:"retry_after"`          // Retry-After on rejections (default 5s)
+}
+
 // IPFilterConfig defines IP allow/deny list settings (Feature 2)
 type IPFilterConfig struct {
 	Enabled bool     `yaml:"enabled"`
@@ -760,6 +1066,18 @@ type ResponseLimitConfig struct {
 	Action  string `yaml:"action"`   // "reject" (default: 502 if known, discard if streaming), "truncate", "log_only"
 }
 
+// ResponseBufferingConfig bounds how body-transforming middleware buffers
+// responses. Bodies up to MemoryThreshold stay in memory; larger ones spill
+// to a temp file.
+type ResponseBufferingConfig struct {
+	Enabled           bool   `yaml:"enabled"`
+	MemoryThreshold   int64  `yaml:"memory_threshold"`     // bytes kept in memory before spilling (default 1MiB)
+	MaxSize           int64  `yaml:"max_size"`             // hard max body size; larger bodies get a 502 (default 256MiB)
+	MaxDiskPerRequest int64  `yaml:"max_disk_per_request"` // spill bytes per buffered body (default max_size)
+	SpillDir          string `yaml:"spill_dir"`            // global only: directory for spill files (default os.TempDir())
+	DiskBudget        int64  `yaml:"disk_budget"`          // global only: spill bytes across all requests (default 1GiB)
+}
+
 // SecurityHeadersConfig defines automatic security response headers.
 type SecurityHeadersConfig struct {
 	Enabled                    bool   `yaml:"enabled"`
@@ -776,6 +1094,27 @@ type SecurityHeadersConfig struct {
 	CustomHeaders              map[string]string `yaml:"custom_headers"`    // arbitrary extra headers
 }
 
+// SyntheticMonitoringConfig identifies external synthetic monitor probes.
+// Identified probes bypass rate limiting, spike arrest and quota, and are
+// counted in separate synthetic metrics instead of the main metrics and
+// access logs.
+type SyntheticMonitoringConfig struct {
+	Enabled           bool          `yaml:"enabled"`
+	Header            string        `yaml:"header"`               // signed probe header (default "X-Synthetic-Probe")
+	Secret            string        `yaml:"secret" redact:"true"` // HMAC-SHA256 key for the probe header
+	MaxSkew           time.Duration `yaml:"max_skew"`             // accepted probe timestamp skew (default 5m)
+	SourceCIDRs       []string      `yaml:"source_cidrs"`         // networks whose traffic is treated as probes
+	RespondFromHealth bool          `yaml:"respond_from_health"`  // answer from last known backend health instead of proxying
+}
+
+// ClientAbortsConfig controls how requests abandoned by the client are
+// accounted. Aborts are always recorded with status 499 in access logs and
+// metrics; by default they are left out of circuit breaker failure counts,
+// SLO error budgets and canary, blue-green and A/B error rates.
+type ClientAbortsConfig struct {
+	CountAsErrors bool `yaml:"count_as_errors"` // count aborts as failures in breakers, SLOs and traffic analysis
+}
+
 // MaintenanceConfig defines maintenance mode settings.
 type MaintenanceConfig struct {
 	Enabled     bool              `yaml:"enabled"`
@@ -788,6 +1127,91 @@ type MaintenanceConfig struct {
 	Headers     map[string]string `yaml:"headers"`        // extra response headers
 }
 
+// DegradedModeConfig defines per-route degraded mode. When the upstream error
+// budget is exhausted the route stops forwarding traffic and serves stale cache
+// entries, a static fallback, or the normal error page until the backend passes
+// a probation of canary requests.
+type DegradedModeConfig struct {
+	Enabled             bool                    `yaml:"enabled"`
+	ErrorRateThreshold  float64                 `yaml:"error_rate_threshold"` // 0.0-1.0, 5xx ratio over window (0 = disabled)
+	MinRequests         int                     `yaml:"min_requests"`         // min requests in window before rate applies (default 20)
+	Window              time.Duration           `yaml:"window"`               // error rate window (default 60s)
+	ConsecutiveFailures int                     `yaml:"consecutive_failures"` // consecutive 5xx responses that trip (0 = disabled)
+	Fallback            DegradedFallbackConfig  `yaml:"fallback"`
+	Probation           DegradedProbationConfig `yaml:"probation"`
+}
+
+// DegradedFallbackConfig is the static response served in degraded mode when
+// no stale cache entry exists. Leaving status_code and body empty falls through
+// to the normal error page.
+type DegradedFallbackConfig struct {
+	StatusCode  int               `yaml:"status_code"`  // default 503
+	Body        string            `yaml:"body"`         // response body
+	ContentType string            `yaml:"content_type"` // default "text/html; charset=utf-8"
+	RetryAfter  string            `yaml:"retry_after"`  // Retry-After header value
+	Headers     map[string]string `yaml:"headers"`      // extra response headers
+}
+
+// DegradedProbationConfig controls how a degraded route returns to normal.
+type DegradedProbationConfig struct {
+	SuccessThreshold int           `yaml:"success_threshold"` // consecutive successful canaries to exit (default 5)
+	Interval         time.Duration `yaml:"interval"`          // min time between canary requests (default 5s)
+}
+
+// PeerFailoverConfig defines the peer gateways, typically in other regions,
+// that routes with peer_failover forward to when none of their own backends
+// are healthy. Forwarded requests carry a hop header signed with the shared
+// secret; a peer never forwards a request it received from another peer.
+type PeerFailoverConfig struct {
+	GatewayID string        `yaml:"gateway_id"`           // this gateway's name in X-Gateway-Forwarded-By (default hostname)
+	Secret    string        `yaml:"secret" redact:"true"` // shared HMAC key signing the hop header; supports ${ENV_VAR}
+	Timeout   time.Duration `yaml:"timeout"`              // default per-peer timeout (default 5s)
+	Peers     []PeerConfig  `yaml:"peers"`
+}
+
+// PeerConfig defines one peer gateway.
+type PeerConfig struct {
+	Name           string               `yaml:"name"`
+	URL            string               `yaml:"url"`             // peer base URL; the request URI is appended
+	Timeout        time.Duration        `yaml:"timeout"`         // time limit for a forwarded request (default peer_failover.timeout)
+	TLS            PeerTLSConfig        `yaml:"tls"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"` // failure_threshold, max_requests and timeout; always on
+}
+
+// PeerTLSConfig configures TLS, and optionally mTLS, for connections to a peer.
+type PeerTLSConfig struct {
+	CAFile     string `yaml:"ca_file"`
+	CertFile   string `yaml:"cert_file"` // client certificate for mTLS
+	KeyFile    string `yaml:"key_file"`  // client key for mTLS
+	ServerName string `yaml:"server_name"`
+}
+
+// RoutePeerFailoverConfig enables peer failover for a route.
+type RoutePeerFailoverConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Peers   []string `yaml:"peers"` // peer names to try in order (default all, in config order)
+}
+
+// BreakGlassConfig defines the features an emergency break-glass activation
+// switches off on a route, through POST /admin/routes/{id}/break-glass.
+type BreakGlassConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Bypass  []string      `yaml:"bypass"`  // middleware names to disable, e.g. auth, waf, rate_limit
+	MaxTTL  time.Duration `yaml:"max_ttl"` // longest allowed activation (default 60m)
+}
+
+// MultipartFieldsConfig defines small multipart/form-data fields read from
+// the start of an upload for tenant resolution, validation and the
+// $form_<name> variables. The body is scanned as it streams and is never
+// buffered beyond max_scan_bytes.
+type MultipartFieldsConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	Fields       []string `yaml:"fields"`         // form field names to capture
+	MaxScanBytes int64    `yaml:"max_scan_bytes"` // body bytes read before giving up (default 1MB)
+	MaxFieldSize int64    `yaml:"max_field_size"` // largest captured value (default 4KB)
+	OnLimit      string   `yaml:"on_limit"`       // "continue" (default) or "reject" when max_scan_bytes is hit first
+}
+
 // TrustedProxiesConfig defines trusted proxy settings for real client IP extraction.
 type TrustedProxiesConfig struct {
 	CIDRs   []string `yaml:"cidrs"`    // trusted proxy CIDRs (e.g. "10.0.0.0/8", "127.0.0.1/32")
@@ -867,6 +1291,7 @@ type StreamingConfig struct {
 // OPAConfig defines Open Policy Agent (OPA) policy engine settings.
 type OPAConfig struct {
 	Enabled     bool          `yaml:"enabled"`
+	Ref         string        `yaml:"ref"` // reference to named policy in Config.OPAPolicies; timeout and fail_open override it
 	URL         string        `yaml:"url"`
 	PolicyPath  string        `yaml:"policy_path"`
 	Timeout     time.Duration `yaml:"timeout"`
@@ -874,6 +1299,9 @@ type OPAConfig struct {
 	IncludeBody bool          `yaml:"include_body"`
 	CacheTTL    time.Duration `yaml:"cache_ttl"`
 	Headers     []string      `yaml:"headers"`
+
+	ShadowAsync      bool   `yaml:"shadow_async"`       // evaluate off the request path: shadow_policy_path, or policy_path without enforcing it
+	ShadowPolicyPath string `yaml:"shadow_policy_path"` // candidate policy compared with policy_path (requires shadow_async)
 }
 
 // MockResponseConfig defines static mock responses.
@@ -916,6 +1344,7 @@ type TokenRevocationConfig struct {
 // BackendAuthConfig defines OAuth2 client_credentials token injection for backend calls.
 type BackendAuthConfig struct {
 	Enabled      bool              `yaml:"enabled"`
+	Ref          string            `yaml:"ref"`           // reference to named provider in Config.BackendAuthProviders
 	Type         string            `yaml:"type"`          // "oauth2_client_credentials"
 	TokenURL     string            `yaml:"token_url"`
 	ClientID     string            `yaml:"client_id"`
@@ -925,6 +1354,25 @@ type BackendAuthConfig struct {
 	Timeout      time.Duration     `yaml:"timeout"` // default 10s
 }
 
+// BackendHeadersSecretConfig defines secret-backed headers injected into
+// backend requests, with an optional primary/secondary pair per header for
+// rotation.
+type BackendHeadersSecretConfig struct {
+	Enabled         bool                 `yaml:"enabled"`
+	Headers         []SecretHeaderConfig `yaml:"headers"`
+	RefreshInterval time.Duration        `yaml:"refresh_interval"` // re-resolve secret references; default 1m
+}
+
+// SecretHeaderConfig defines one secret header. Primary and Secondary keep
+// their ${scheme:ref} form after loading so the values can be re-resolved
+// without a config reload.
+type SecretHeaderConfig struct {
+	Name             string `yaml:"name"`
+	Primary          string `yaml:"primary" redact:"true" secret:"deferred"`
+	Secondary        string `yaml:"secondary" redact:"true" secret:"deferred"`
+	SecondaryPercent int    `yaml:"secondary_percent"` // share of requests sent the secondary value, 0-100
+}
+
 // StatusMappingConfig defines per-route backend response status code remapping.
 type StatusMappingConfig struct {
 	Enabled  bool        `yaml:"enabled"`
@@ -976,13 +1424,25 @@ type SunsetResponse struct {
 type ConsumerGroupsConfig struct {
 	Enabled bool                       `yaml:"enabled"`
 	Groups  map[string]ConsumerGroup   `yaml:"groups"`
+	Clients map[string]string          `yaml:"clients"` // client_id -> group, checked before claims and roles
+	Claim   string                     `yaml:"claim"`   // identity claim naming the group (default "consumer_group")
+}
+
+// GroupClaim returns the identity claim naming a consumer's group.
+func (c ConsumerGroupsConfig) GroupClaim() string {
+	if c.Claim == "" {
+		return "consumer_group"
+	}
+	return c.Claim
 }
 
-// ConsumerGroup defines a single consumer group with resource policies and metadata.
+// ConsumerGroup defines a single consumer group with resource policies and
+// metadata. Non-zero limits replace the route's own for the group's
+// consumers.
 type ConsumerGroup struct {
-	RateLimit int               `yaml:"rate_limit"`
-	Quota     int64             `yaml:"quota"`
-	Priority  int               `yaml:"priority"`
+	RateLimit int               `yaml:"rate_limit"` // requests per second per consumer
+	Quota     int64             `yaml:"quota"`      // requests per route quota period
+	Priority  int               `yaml:"priority"`   // priority admission level (1 highest, 10 lowest)
 	Metadata  map[string]string `yaml:"metadata"`
 }
 
@@ -1070,8 +1530,40 @@ type RewriteConfig struct {
 
 // MetricsConfig defines Prometheus metrics settings (Feature 5)
 type MetricsConfig struct {
-	Enabled bool   `yaml:"enabled"`
-	Path    string `yaml:"path"` // default "/metrics"
+	Enabled         bool                 `yaml:"enabled"`
+	Path            string               `yaml:"path"`              // default "/metrics"
+	PluginMaxSeries int                  `yaml:"plugin_max_series"` // per-plugin cap on Lua/WASM metric series; default 100
+	Requests        RequestMetricsConfig `yaml:"requests"`          // default request instruments for routes without their own
+	Buckets         []float64            `yaml:"buckets"`           // request duration histogram bounds in seconds; default 5ms..10s
+	SummaryWindow   time.Duration        `yaml:"summary_window"`    // sliding window of summary quantiles; default 1m
+}
+
+// Request metric modes and status labels.
+const (
+	MetricsModeHistogram = "histogram" // counter by status plus duration histogram (default)
+	MetricsModeSummary   = "summary"   // counter by status plus p50/p95/p99 duration summary
+	MetricsModeCounters  = "counters"  // counter by status only
+
+	MetricsStatusCode  = "code"  // status label carries the status code, e.g. 404 (default)
+	MetricsStatusClass = "class" // status label carries the status class, e.g. 4xx
+)
+
+// RequestMetricsConfig selects the instruments recorded for a route's
+// requests. Empty fields inherit admin.metrics.requests.
+type RequestMetricsConfig struct {
+	Mode   string `yaml:"mode"`   // histogram (default), summary, or counters
+	Status string `yaml:"status"` // code (default) or class
+}
+
+// Merge returns c with empty fields taken from defaults.
+func (c RequestMetricsConfig) Merge(defaults RequestMetricsConfig) RequestMetricsConfig {
+	if c.Mode == "" {
+		c.Mode = defaults.Mode
+	}
+	if c.Status == "" {
+		c.Status = defaults.Status
+	}
+	return c
 }
 
 // TrafficSplitConfig defines canary/weighted traffic split settings (Feature 6)
@@ -1081,6 +1573,7 @@ type TrafficSplitConfig struct {
 	Backends     []BackendConfig   `yaml:"backends"`
 	Upstream     string            `yaml:"upstream"`      // reference to named upstream (alternative to inline backends)
 	MatchHeaders map[string]string `yaml:"match_headers"` // header-based override
+	MatchBaggage map[string]string `yaml:"match_baggage"` // baggage-member-based override
 }
 
 // ValidationConfig defines request validation settings (Feature 8)
@@ -1090,9 +1583,37 @@ type ValidationConfig struct {
 	Schema             string `yaml:"schema"`               // inline JSON schema
 	ResponseSchemaFile string `yaml:"response_schema_file"` // path to response JSON schema file
 	ResponseSchema     string `yaml:"response_schema"`      // inline response JSON schema
+	XSDFile            string `yaml:"xsd_file"`             // path to request XML schema (XSD) file
+	XSD                string `yaml:"xsd"`                  // inline request XML schema
+	ResponseXSDFile    string `yaml:"response_xsd_file"`    // path to response XML schema file
+	ResponseXSD        string `yaml:"response_xsd"`         // inline response XML schema
 	LogOnly            bool   `yaml:"log_only"`             // log instead of reject
 }
 
+// XMLLimitsConfig bounds the resources any XML document parsed by the
+// gateway may use (XSD validation, backend encoding, SAML, WAF XML checks).
+// Zero fields take their defaults.
+type XMLLimitsConfig struct {
+	MaxBytes           int64 `yaml:"max_bytes"`            // document size (default 10MiB)
+	MaxDepth           int   `yaml:"max_depth"`            // element nesting depth (default 100)
+	MaxAttributes      int   `yaml:"max_attributes"`       // attributes per element (default 100)
+	MaxEntities        int   `yaml:"max_entities"`         // internal entity declarations (default 20)
+	MaxEntityExpansion int64 `yaml:"max_entity_expansion"` // bytes produced by entity expansion (default 64KiB)
+}
+
+// Limits on route metadata enforced at load time.
+const (
+	MaxRouteMetadataKeys        = 32
+	MaxRouteMetadataValueLength = 256
+)
+
+// RouteMetadataConfig selects the route metadata keys copied into logs and
+// metrics. Keys not listed stay available to variables, rules and plugins.
+type RouteMetadataConfig struct {
+	LogKeys      []string `yaml:"log_keys"`      // keys added to access and audit log entries
+	MetricLabels []string `yaml:"metric_labels"` // keys exported as runway_route_info labels
+}
+
 // TracingConfig defines distributed tracing settings (Feature 9)
 type TracingConfig struct {
 	Enabled     bool              `yaml:"enabled"`
@@ -1106,12 +1627,42 @@ type TracingConfig struct {
 
 // MirrorConfig defines traffic mirroring settings (Feature 10)
 type MirrorConfig struct {
-	Enabled    bool                   `yaml:"enabled"`
-	Backends   []BackendConfig        `yaml:"backends"`
-	Upstream   string                 `yaml:"upstream"`   // reference to named upstream (alternative to inline backends)
-	Percentage int                    `yaml:"percentage"` // 0-100
-	Conditions MirrorConditionsConfig `yaml:"conditions"`
-	Compare    MirrorCompareConfig    `yaml:"compare"`
+	Enabled     bool                   `yaml:"enabled"`
+	Backends    []BackendConfig        `yaml:"backends"`
+	Upstream    string                 `yaml:"upstream"`     // reference to named upstream (alternative to inline backends)
+	ShadowRoute string                 `yaml:"shadow_route"` // ID of a route whose pipeline serves the copies (alternative to backends)
+	Percentage  int                    `yaml:"percentage"`   // 0-100
+	Conditions  MirrorConditionsConfig `yaml:"conditions"`
+	Compare     MirrorCompareConfig    `yaml:"compare"`
+	Sink        MirrorSinkConfig       `yaml:"sink"` // record copies to files for offline replay (alternative to backends)
+
+	CopyRequestPolicy `yaml:",inline"` // header and query policy of the copies
+}
+
+// CopyRequestPolicy shapes the requests a route copies to secondary
+// targets: mirror backends, shadow routes and sinks, and aggregate
+// backends. It applies to the copy only; the primary request is untouched.
+type CopyRequestPolicy struct {
+	StripHeaders       []string          `yaml:"strip_headers"`        // removed from the copy
+	SetHeaders         map[string]string `yaml:"set_headers"`          // Go template values, set after strip_headers
+	ForwardQueryParams QueryParamFilter  `yaml:"forward_query_params"` // client query parameters the copy carries
+}
+
+// IsActive reports whether the policy changes the copy.
+func (c CopyRequestPolicy) IsActive() bool {
+	return len(c.StripHeaders) > 0 || len(c.SetHeaders) > 0 || c.ForwardQueryParams.IsActive()
+}
+
+// QueryParamFilter selects query parameters by name. Allow keeps only the
+// listed parameters; Deny drops the listed ones. At most one may be set.
+type QueryParamFilter struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// IsActive reports whether the filter lists any parameter.
+func (c QueryParamFilter) IsActive() bool {
+	return len(c.Allow) > 0 || len(c.Deny) > 0
 }
 
 // MirrorConditionsConfig defines conditions for when to mirror requests.
@@ -1132,6 +1683,23 @@ type MirrorCompareConfig struct {
 	IgnoreJSONFields []string `yaml:"ignore_json_fields"`
 }
 
+// MirrorSinkConfig records mirrored requests, sanitized, to rotating local
+// files that can be replayed later instead of sending them to a live backend.
+type MirrorSinkConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Directory     string        `yaml:"directory"`      // where record files are written
+	MaxFileSize   int64         `yaml:"max_file_size"`  // record bytes per file before rotating (default 64MB)
+	MaxFileAge    time.Duration `yaml:"max_file_age"`   // rotate an open file after this long (default 1h)
+	MaxFiles      int           `yaml:"max_files"`      // completed files kept per route, oldest deleted (0 = all)
+	Compress      *bool         `yaml:"compress"`       // gzip the files (default true)
+	MaxBodySize   int           `yaml:"max_body_size"`  // request body bytes recorded (default 64KB)
+	RedactHeaders []string      `yaml:"redact_headers"` // added to Authorization, Proxy-Authorization, Cookie, X-API-Key
+	RedactFields  []string      `yaml:"redact_fields"`  // JSON body fields (any depth), form fields and query parameters
+	BufferSize    int           `yaml:"buffer_size"`    // records queued before dropping (default 1000)
+	BatchSize     int           `yaml:"batch_size"`     // records per write (default 100)
+	FlushInterval time.Duration `yaml:"flush_interval"` // default 1s
+}
+
 // GRPCConfig defines gRPC proxying settings (Feature 12)
 type GRPCConfig struct {
 	Enabled             bool                   `yaml:"enabled"`
@@ -1166,14 +1734,34 @@ type GRPCHealthCheckConfig struct {
 
 // ProtocolConfig defines protocol translation settings per route.
 type ProtocolConfig struct {
-	Type     string                   `yaml:"type"` // "http_to_grpc", "http_to_thrift", "grpc_to_rest", "grpc_web", "grpc_json"
-	GRPC     GRPCTranslateConfig      `yaml:"grpc"`
-	Thrift   ThriftTranslateConfig    `yaml:"thrift"`
-	REST     RESTTranslateConfig      `yaml:"rest"`
-	GraphQL  GraphQLProtocolConfig    `yaml:"graphql"`
-	SOAP     SOAPProtocolConfig       `yaml:"soap"`
-	GRPCWeb  GRPCWebTranslateConfig   `yaml:"grpc_web"`
-	GRPCJson GRPCJSONTranslateConfig  `yaml:"grpc_json"`
+	Type     string                  `yaml:"type"` // "http_to_grpc", "http_to_thrift", "grpc_to_rest", "grpc_web", "grpc_json"
+	GRPC     GRPCTranslateConfig     `yaml:"grpc"`
+	Thrift   ThriftTranslateConfig   `yaml:"thrift"`
+	REST     RESTTranslateConfig     `yaml:"rest"`
+	GraphQL  GraphQLProtocolConfig   `yaml:"graphql"`
+	SOAP     SOAPProtocolConfig      `yaml:"soap"`
+	GRPCWeb  GRPCWebTranslateConfig  `yaml:"grpc_web"`
+	GRPCJson GRPCJSONTranslateConfig `yaml:"grpc_json"`
+	Fallback HandlerFallbackConfig   `yaml:"fallback"` // proxy to plain HTTP backends when translation fails
+}
+
+// HandlerFallbackConfig proxies the original request to plain HTTP backends
+// when a translator, lambda or amqp handler fails in one of the listed ways
+// before any response bytes were sent.
+type HandlerFallbackConfig struct {
+	Enabled     bool                  `yaml:"enabled"`
+	Triggers    FallbackTriggerConfig `yaml:"triggers"`
+	Backends    []BackendConfig       `yaml:"backends"`
+	Upstream    string                `yaml:"upstream"`      // reference to named upstream (alternative to inline backends)
+	MaxRate     float64               `yaml:"max_rate"`      // fallbacks per second (0 = unlimited)
+	MaxBodySize int64                 `yaml:"max_body_size"` // largest request body kept for replay (default 1MiB)
+}
+
+// FallbackTriggerConfig lists the handler failures that trigger a fallback.
+type FallbackTriggerConfig struct {
+	GRPCCodes        []string `yaml:"grpc_codes"`        // e.g. UNIMPLEMENTED, NOT_FOUND
+	Statuses         []int    `yaml:"statuses"`          // HTTP statuses written by the handler
+	ConnectionErrors bool     `yaml:"connection_errors"` // backend unreachable (gRPC UNAVAILABLE, 502, 503)
 }
 
 // RESTTranslateConfig defines gRPC-to-REST translation settings.
@@ -1194,12 +1782,21 @@ type GRPCToRESTMapping struct {
 
 // GRPCTranslateConfig defines HTTP-to-gRPC translation settings.
 type GRPCTranslateConfig struct {
-	Service            string              `yaml:"service"`              // optional: fully-qualified service name
-	Method             string              `yaml:"method"`               // optional: fixed gRPC method name (requires service)
-	Timeout            time.Duration       `yaml:"timeout"`              // per-call timeout (default 30s)
-	DescriptorCacheTTL time.Duration       `yaml:"descriptor_cache_ttl"` // default 5m
-	TLS                ProtocolTLSConfig   `yaml:"tls"`
-	Mappings           []GRPCMethodMapping `yaml:"mappings"` // REST-to-gRPC method mappings
+	Service            string                 `yaml:"service"`              // optional: fully-qualified service name
+	Method             string                 `yaml:"method"`               // optional: fixed gRPC method name (requires service)
+	Timeout            time.Duration          `yaml:"timeout"`              // per-call timeout (default 30s)
+	DescriptorCacheTTL time.Duration          `yaml:"descriptor_cache_ttl"` // default 5m
+	TLS                ProtocolTLSConfig      `yaml:"tls"`
+	Mappings           []GRPCMethodMapping    `yaml:"mappings"`      // REST-to-gRPC method mappings
+	ClientStream       GRPCClientStreamConfig `yaml:"client_stream"` // NDJSON uploads to client-streaming methods
+}
+
+// GRPCClientStreamConfig bounds NDJSON request bodies sent to client-streaming
+// methods, where each body line is one stream message.
+type GRPCClientStreamConfig struct {
+	MaxLineSize   int    `yaml:"max_line_size"`   // max bytes per line (default 1MB)
+	MaxMessages   int    `yaml:"max_messages"`    // max messages per request (0 = unlimited)
+	OnInvalidLine string `yaml:"on_invalid_line"` // "reject" (default) or "skip"
 }
 
 // GRPCMethodMapping defines a REST-to-gRPC method mapping.
@@ -1232,6 +1829,16 @@ type ThriftTranslateConfig struct {
 	Methods     map[string]ThriftMethodDef  `yaml:"methods"`  // inline method definitions (alternative to idl_file)
 	Structs     map[string][]ThriftFieldDef `yaml:"structs"`  // inline struct definitions
 	Enums       map[string]map[string]int   `yaml:"enums"`    // inline enum definitions
+
+	Services          []ThriftServiceConfig `yaml:"services"`            // multiplexed services on one backend (alternative to service)
+	IDLReloadInterval time.Duration         `yaml:"idl_reload_interval"` // poll interval for idl_file changes (default 10s)
+}
+
+// ThriftServiceConfig defines one multiplexed Thrift service routed by its mappings.
+type ThriftServiceConfig struct {
+	Name            string                `yaml:"name"`             // service name in the IDL (required)
+	MultiplexedName string                `yaml:"multiplexed_name"` // name registered with TMultiplexedProcessor (default name)
+	Mappings        []ThriftMethodMapping `yaml:"mappings"`         // REST-to-Thrift method mappings (required)
 }
 
 // ThriftMethodDef defines an inline Thrift method schema.
@@ -1269,6 +1876,12 @@ type WAFConfig struct {
 	InlineRules  []string `yaml:"inline_rules"`   // inline SecLang rules
 	SQLInjection bool     `yaml:"sql_injection"`  // enable built-in SQLi rules
 	XSS          bool     `yaml:"xss"`            // enable built-in XSS rules
+
+	XMLProtection bool `yaml:"xml_protection"` // reject XML bodies that break xml_limits (entity expansion, XXE, depth)
+
+	ShadowRuleFiles []string      `yaml:"shadow_rule_files"` // candidate rule files evaluated in detect-only mode
+	ShadowAsync     bool          `yaml:"shadow_async"`      // evaluate off the request path: shadow_rule_files, or the rules themselves without enforcing them
+	ReloadInterval  time.Duration `yaml:"reload_interval"`   // poll interval for rule file changes (default 10s)
 }
 
 // GraphQLConfig defines GraphQL query analysis and protection settings.
@@ -1281,6 +1894,30 @@ type GraphQLConfig struct {
 	PersistedQueries PersistedQueriesConfig    `yaml:"persisted_queries"` // Automatic Persisted Queries (APQ)
 	Subscriptions    GraphQLSubscriptionConfig `yaml:"subscriptions"`     // GraphQL subscription (WebSocket) settings
 	Batching         GraphQLBatchingConfig     `yaml:"batching"`          // Query batching settings
+	Allowlist        GraphQLAllowlistConfig    `yaml:"allowlist"`         // Persisted query allowlist (sha256 of query text)
+	CostRateLimit    GraphQLCostRateLimit      `yaml:"complexity_rate_limit"`
+}
+
+// GraphQLAllowlistConfig restricts execution to queries whose SHA-256 appears in the list.
+type GraphQLAllowlistConfig struct {
+	Enabled bool                    `yaml:"enabled"`
+	File    string                  `yaml:"file"`    // YAML file containing a list of entries (re-read on config reload)
+	Queries []GraphQLAllowlistEntry `yaml:"queries"` // inline entries, merged with file entries
+}
+
+// GraphQLAllowlistEntry is a single allowlisted query.
+type GraphQLAllowlistEntry struct {
+	Hash               string `yaml:"hash"`                // hex SHA-256 of the query text
+	Name               string `yaml:"name"`                // optional label for stats
+	AllowIntrospection bool   `yaml:"allow_introspection"` // exempt this query from the introspection block
+}
+
+// GraphQLCostRateLimit charges each request its computed complexity against a per-client token bucket.
+type GraphQLCostRateLimit struct {
+	Enabled bool          `yaml:"enabled"`
+	Budget  int           `yaml:"budget"` // complexity points per period (also the burst)
+	Period  time.Duration `yaml:"period"` // default 1m
+	Key     string        `yaml:"key"`    // "ip", "client_id", "header:<name>", "cookie:<name>", "jwt_claim:<name>"
 }
 
 // GraphQLBatchingConfig defines GraphQL query batching settings.
@@ -1323,6 +1960,10 @@ type CoalesceConfig struct {
 	Timeout    time.Duration `yaml:"timeout"`      // max wait for coalesced requests (default 30s)
 	KeyHeaders []string      `yaml:"key_headers"`  // headers included in coalesce key
 	Methods    []string      `yaml:"methods"`      // eligible methods (default GET+HEAD)
+
+	// KeyNormalization is the route's cache.key_normalization, so identical
+	// requests coalesce exactly when they would share a cache entry.
+	KeyNormalization CacheKeyNormalizationConfig `yaml:"-"`
 }
 
 // CanaryConfig defines canary deployment settings.
@@ -1354,6 +1995,7 @@ type CanaryAnalysisConfig struct {
 // ExtAuthConfig configures external authentication for a route.
 type ExtAuthConfig struct {
 	Enabled         bool             `yaml:"enabled"`
+	Ref             string           `yaml:"ref"`               // reference to named service in Config.ExtAuthServices; timeout and fail_open override it
 	URL             string           `yaml:"url"`               // http:// or grpc:// URL
 	Timeout         time.Duration    `yaml:"timeout"`            // default 5s
 	FailOpen        bool             `yaml:"fail_open"`          // allow on error (default false = fail closed)
@@ -1395,11 +2037,24 @@ type VersionBackendConfig struct {
 type AccessLogConfig struct {
 	Enabled          *bool                `yaml:"enabled"`           // nil=inherit global, false=disable
 	Format           string               `yaml:"format"`            // override global format
+	Fields           []string             `yaml:"fields"`            // override global fields of "json" access logs
 	HeadersInclude   []string             `yaml:"headers_include"`   // headers to log
 	HeadersExclude   []string             `yaml:"headers_exclude"`   // headers to exclude
 	SensitiveHeaders []string             `yaml:"sensitive_headers"` // headers to mask
 	Body             AccessLogBodyConfig  `yaml:"body"`
 	Conditions       AccessLogConditions  `yaml:"conditions"`
+	Level            string               `yaml:"level"`    // minimum severity to log: "info" (default), "warn" (4xx+), "error" (5xx)
+	Sampling         AccessLogSampling    `yaml:"sampling"` // adaptive sampling under log bursts
+}
+
+// AccessLogSampling defines adaptive sampling of a route's request logs.
+// Above the threshold rate, info-level logs are kept at Ratio; warnings and
+// errors are always kept.
+type AccessLogSampling struct {
+	Enabled   bool    `yaml:"enabled"`
+	Threshold float64 `yaml:"threshold"` // logs per second before sampling engages (required)
+	Burst     int     `yaml:"burst"`     // token bucket size (default threshold)
+	Ratio     float64 `yaml:"ratio"`     // fraction of info-level logs kept while sampling (default 0.1)
 }
 
 // AccessLogBodyConfig defines body capture settings for access logging.
@@ -1440,6 +2095,7 @@ type OpenAPISpecConfig struct {
 	RoutePrefix     string                   `yaml:"route_prefix"`
 	StripPrefix     bool                     `yaml:"strip_prefix"`
 	Validation      OpenAPIValidationOptions `yaml:"validation"`
+	SchemaDrift     SchemaDriftConfig        `yaml:"schema_drift"` // applied to every generated route
 }
 
 // OpenAPIValidationOptions defines validation settings for an OpenAPI spec.
@@ -1457,6 +2113,17 @@ type OpenAPIRouteConfig struct {
 	ValidateRequest  *bool  `yaml:"validate_request"`  // default true
 	ValidateResponse bool   `yaml:"validate_response"` // default false
 	LogOnly          bool   `yaml:"log_only"`          // default false
+	SchemaDrift      SchemaDriftConfig `yaml:"schema_drift"` // observe-only response schema drift detection
+}
+
+// SchemaDriftConfig samples responses and records where they drift from the
+// operation's response schema, without affecting the response.
+type SchemaDriftConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	SampleRate     float64       `yaml:"sample_rate"`     // percent of responses inspected, 0-100 (default 1)
+	MaxFindings    int           `yaml:"max_findings"`    // distinct findings kept per route (default 100)
+	MaxBodySize    int64         `yaml:"max_body_size"`   // larger responses are not inspected (default 1MB)
+	DigestInterval time.Duration `yaml:"digest_interval"` // emit a schema_drift.digest webhook this often (0 = no digest)
 }
 
 // BodyTransformConfig defines request/response body transformation settings (Feature 13)
@@ -1494,10 +2161,15 @@ type MatchConfig struct {
 	Cookies          []CookieMatchConfig  `yaml:"cookies"`
 	Body             []BodyMatchConfig    `yaml:"body"`
 	MaxMatchBodySize int64                `yaml:"max_match_body_size"`
+	// Expression combines the header, query, cookie and body matchers that
+	// have an id, e.g. "beta_header || beta_cookie". Matchers without an id
+	// must all match, as without an expression.
+	Expression string `yaml:"expression"`
 }
 
 // HeaderMatchConfig defines a single header match criterion
 type HeaderMatchConfig struct {
+	ID      string `yaml:"id"` // name used in match.expression
 	Name    string `yaml:"name"`
 	Value   string `yaml:"value"`
 	Present *bool  `yaml:"present"`
@@ -1506,6 +2178,7 @@ type HeaderMatchConfig struct {
 
 // QueryMatchConfig defines a single query parameter match criterion
 type QueryMatchConfig struct {
+	ID      string `yaml:"id"` // name used in match.expression
 	Name    string `yaml:"name"`
 	Value   string `yaml:"value"`
 	Present *bool  `yaml:"present"`
@@ -1514,6 +2187,7 @@ type QueryMatchConfig struct {
 
 // CookieMatchConfig defines a single cookie match criterion
 type CookieMatchConfig struct {
+	ID      string `yaml:"id"` // name used in match.expression
 	Name    string `yaml:"name"`
 	Value   string `yaml:"value"`
 	Present *bool  `yaml:"present"`
@@ -1522,6 +2196,7 @@ type CookieMatchConfig struct {
 
 // BodyMatchConfig defines a single request body field match criterion using gjson paths.
 type BodyMatchConfig struct {
+	ID      string `yaml:"id"`      // name used in match.expression
 	Name    string `yaml:"name"`    // gjson path (required)
 	Value   string `yaml:"value"`   // exact match
 	Present *bool  `yaml:"present"` // field existence check
@@ -1537,14 +2212,61 @@ type BackendConfig struct {
 
 // ServiceConfig defines service discovery settings for a route
 type ServiceConfig struct {
-	Name string   `yaml:"name"`
-	Tags []string `yaml:"tags"`
+	Name          string            `yaml:"name"`
+	Tags          []string          `yaml:"tags"`
+	Meta          map[string]string `yaml:"meta"`           // required instance metadata (all must match)
+	LocalityAware bool              `yaml:"locality_aware"` // prefer instances in registry.local_zone
+	ZoneMetaKey   string            `yaml:"zone_meta_key"`  // metadata key holding an instance's zone (default "zone")
+}
+
+// ZoneKey returns the metadata key holding an instance's zone.
+func (s ServiceConfig) ZoneKey() string {
+	if s.ZoneMetaKey == "" {
+		return "zone"
+	}
+	return s.ZoneMetaKey
 }
 
 // RouteAuthConfig defines authentication for a route
 type RouteAuthConfig struct {
-	Required bool     `yaml:"required"`
-	Methods  []string `yaml:"methods"` // jwt, api_key, oauth
+	Required     bool                   `yaml:"required"`
+	Methods      []string               `yaml:"methods"`      // jwt, api_key, oauth
+	Requirements AuthRequirementsConfig `yaml:"requirements"` // scopes/roles/claims the identity must have
+}
+
+// AuthRequirementsConfig declares what an authenticated identity needs to
+// access a route. Every non-empty condition must hold. A per-method entry
+// replaces the route-wide conditions for requests with that method.
+type AuthRequirementsConfig struct {
+	AuthRequirement `yaml:",inline"`
+	Methods         map[string]AuthRequirement `yaml:"methods"`        // per-HTTP-method override, e.g. GET vs POST
+	VerboseErrors   *bool                      `yaml:"verbose_errors"` // name the missing requirement in 403s (default true)
+}
+
+// AuthRequirement is a set of authorization conditions on an identity.
+type AuthRequirement struct {
+	ScopesAny []string               `yaml:"scopes_any"` // at least one of these scopes
+	ScopesAll []string               `yaml:"scopes_all"` // every one of these scopes
+	RolesAny  []string               `yaml:"roles_any"`  // at least one of these roles
+	Claims    map[string]interface{} `yaml:"claims"`     // claim -> expected value or list of accepted values
+}
+
+// IsZero reports whether the requirement has no conditions.
+func (r AuthRequirement) IsZero() bool {
+	return len(r.ScopesAny) == 0 && len(r.ScopesAll) == 0 && len(r.RolesAny) == 0 && len(r.Claims) == 0
+}
+
+// IsActive reports whether any requirement is configured.
+func (c AuthRequirementsConfig) IsActive() bool {
+	if !c.AuthRequirement.IsZero() {
+		return true
+	}
+	for _, m := range c.Methods {
+		if !m.IsZero() {
+			return true
+		}
+	}
+	return false
 }
 
 // RateLimitConfig defines rate limiting settings
@@ -1555,11 +2277,28 @@ type RateLimitConfig struct {
 	Burst       int                     `yaml:"burst"`
 	PerIP       bool                    `yaml:"per_ip"`
 	Key         string                  `yaml:"key"`          // Custom key extraction: "ip", "client_id", "header:<name>", "cookie:<name>", "jwt_claim:<name>"
-	Mode        string                  `yaml:"mode"`         // "local" (default) or "distributed"
+	Mode        string                  `yaml:"mode"`         // "local" (default), "distributed", or "observe" (local, never rejects)
 	Algorithm   string                  `yaml:"algorithm"`    // "token_bucket" (default) or "sliding_window"
 	Tiers       map[string]TierConfig   `yaml:"tiers"`        // per-tier rate limits
 	TierKey     string                  `yaml:"tier_key"`     // "header:<name>" or "jwt_claim:<name>"
 	DefaultTier string                  `yaml:"default_tier"` // fallback tier name
+
+	// Cost-based limiting: each request debits N tokens instead of 1.
+	CostSource       string         `yaml:"cost_source"`       // "fixed", "request_cost", "graphql_complexity", or "openapi_weight"
+	Cost             int            `yaml:"cost"`              // tokens per request for "fixed" (default 1)
+	MaxCost          int            `yaml:"max_cost"`          // per-request cost cap (default burst)
+	OperationWeights map[string]int `yaml:"operation_weights"` // operationId -> cost for "openapi_weight"
+
+	// Quota headers written on every response, not just 429s.
+	Headers       *bool `yaml:"headers"`        // RateLimit-Limit, -Remaining and -Reset (default true)
+	LegacyHeaders bool  `yaml:"legacy_headers"` // also X-RateLimit-Limit, -Remaining and -Reset
+
+	IPv6AggregationPrefix int `yaml:"ipv6_aggregation_prefix"` // IPv6 client IP keys cover a network of this length (default: global ipv6_aggregation_prefix)
+}
+
+// HeadersEnabled reports whether quota headers are written.
+func (c RateLimitConfig) HeadersEnabled() bool {
+	return c.Headers == nil || *c.Headers
 }
 
 // TierConfig defines rate limits for a single tier.
@@ -1612,12 +2351,24 @@ type HeaderTransform struct {
 
 // LoggingConfig defines logging settings
 type LoggingConfig struct {
-	Format   string            `yaml:"format"`
+	Format   string            `yaml:"format"` // access log variable format, or "json" for one JSON object per request
+	Fields   []string          `yaml:"fields"` // fields of "json" access logs (default AccessLogFields)
 	Level    string            `yaml:"level"`
 	Output   string            `yaml:"output"`
 	Rotation LogRotationConfig `yaml:"rotation"`
 }
 
+// AccessLogFormatJSON is the access log format that writes one JSON object
+// per request with a selected list of fields.
+const AccessLogFormatJSON = "json"
+
+// AccessLogFields are the fields of "json" access logs, in output order.
+var AccessLogFields = []string{
+	"time", "method", "path", "status", "duration_ms", "route_id", "listener_id",
+	"client_ip", "request_id", "upstream_addr", "bytes_sent", "user_agent", "tenant",
+	"jwt_sub",
+}
+
 // LogRotationConfig defines log file rotation settings (powered by lumberjack).
 type LogRotationConfig struct {
 	MaxSize    int  `yaml:"max_size"`    // max megabytes before rotation (default 100)
@@ -1637,6 +2388,79 @@ type AdminConfig struct {
 	Catalog    CatalogConfig    `yaml:"catalog"`     // Developer portal / API catalog
 	GRPCHealth GRPCHealthConfig `yaml:"grpc_health"` // gRPC health check server
 	UI         AdminUIConfig    `yaml:"ui"`          // Admin UI SPA
+
+	DependencyHealth DependencyHealthConfig `yaml:"dependency_health"` // Background checks of external dependencies
+	BackendTLSScan   BackendTLSScanConfig   `yaml:"backend_tls_scan"`  // Background scan of backend TLS certificates
+	UpstreamSwap     UpstreamSwapConfig     `yaml:"upstream_swap"`     // Admin API swaps of upstream backend sets
+	ConfigDrift      ConfigDriftConfig      `yaml:"config_drift"`      // Config hash comparison between replicas via Redis
+	ConfigSnapshots  ConfigSnapshotsConfig  `yaml:"config_snapshots"`  // Snapshots of replaced configs for rollback
+}
+
+// ConfigSnapshotsConfig defines persisting the running config whenever a
+// reload replaces it, so it can be restored through the admin API.
+type ConfigSnapshotsConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Store     string        `yaml:"store"`     // "file" (default) or "redis"
+	Dir       string        `yaml:"dir"`       // snapshot directory (required for the file store)
+	RedisKey  string        `yaml:"redis_key"` // Redis hash key (default "runway:config_snapshots")
+	Retention int           `yaml:"retention"` // snapshots kept (default 10)
+	Interval  time.Duration `yaml:"interval"`  // scheduled snapshots of the running config; 0 disables
+	Secrets   string        `yaml:"secrets"`   // "reference" (default) or "mask"
+}
+
+// ConfigDriftConfig defines publishing the config hash to Redis and
+// comparing it with the hashes of peer replicas.
+type ConfigDriftConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	Interval    time.Duration `yaml:"interval"`     // publish and compare period (default 30s)
+	GracePeriod time.Duration `yaml:"grace_period"` // how long hashes may differ before drift is reported (default 2m)
+	Key         string        `yaml:"key"`          // Redis hash key (default "runway:config_hash")
+	InstanceID  string        `yaml:"instance_id"`  // this replica's ID (default hostname)
+}
+
+// UpstreamSwapConfig defines defaults for swapping an upstream's backends
+// through POST /admin/upstreams/{name}/swap.
+type UpstreamSwapConfig struct {
+	RollbackWindow time.Duration        `yaml:"rollback_window"` // how long the previous backends can be restored (default 15m)
+	Verify         UpstreamVerifyConfig `yaml:"verify"`          // default verification requests
+}
+
+// UpstreamVerifyConfig defines the verification requests sent to each
+// candidate backend before an upstream swap.
+type UpstreamVerifyConfig struct {
+	Method         string        `yaml:"method"`          // default GET
+	Path           string        `yaml:"path"`            // default "/health"
+	ExpectedStatus int           `yaml:"expected_status"` // default 200
+	Count          int           `yaml:"count"`           // requests per candidate backend (default 3)
+	Timeout        time.Duration `yaml:"timeout"`         // per request (default 5s)
+}
+
+// BackendTLSScanConfig defines the background scan of https backend
+// certificates for upcoming expiry.
+type BackendTLSScanConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Interval        time.Duration `yaml:"interval"`         // time between scans (default 24h)
+	Timeout         time.Duration `yaml:"timeout"`          // per-backend connect and handshake timeout (default 10s)
+	ExpiryThreshold time.Duration `yaml:"expiry_threshold"` // warn when a certificate expires within this window (default 720h)
+	Rate            float64       `yaml:"rate"`             // backend connections per second (default 1)
+}
+
+// DependencyHealthConfig defines background health checks of the gateway's
+// external dependencies (Redis, registry, geo database, OPA, ext auth, webhooks).
+type DependencyHealthConfig struct {
+	Enabled  bool                             `yaml:"enabled"`
+	Interval time.Duration                    `yaml:"interval"` // default probe interval (default 15s)
+	Timeout  time.Duration                    `yaml:"timeout"`  // default probe timeout (default 3s)
+	Checks   map[string]DependencyCheckConfig `yaml:"checks"`   // per-dependency overrides keyed by dependency name or kind
+}
+
+// DependencyCheckConfig overrides the probe settings of one dependency.
+type DependencyCheckConfig struct {
+	Disabled         bool          `yaml:"disabled"`
+	Interval         time.Duration `yaml:"interval"`
+	Timeout          time.Duration `yaml:"timeout"`
+	Critical         *bool         `yaml:"critical"`          // default true, false for webhooks
+	AffectsReadiness bool          `yaml:"affects_readiness"` // a failing critical dependency marks the instance not ready
 }
 
 // AdminUIConfig defines admin UI settings.
@@ -1761,9 +2585,41 @@ type PriorityLevelConfig struct {
 
 // FaultInjectionConfig defines fault injection settings for chaos testing.
 type FaultInjectionConfig struct {
-	Enabled bool             `yaml:"enabled"`
-	Delay   FaultDelayConfig `yaml:"delay"`
-	Abort   FaultAbortConfig `yaml:"abort"`
+	Enabled bool              `yaml:"enabled"`
+	Delay   FaultDelayConfig  `yaml:"delay"`
+	Abort   FaultAbortConfig  `yaml:"abort"`
+	Target  FaultTargetConfig `yaml:"target"` // restrict faults to matching requests (default: all)
+	Ramp    FaultRampConfig   `yaml:"ramp"`   // ramp fault intensity up over time
+}
+
+// FaultTargetConfig restricts fault injection to matching requests. Every
+// configured selector must match; a selector matches when the request's
+// value is one of its values.
+type FaultTargetConfig struct {
+	Tenants        []string           `yaml:"tenants"`         // resolved tenant IDs
+	ConsumerGroups []string           `yaml:"consumer_groups"` // resolved consumer group names
+	Match          []FaultTargetMatch `yaml:"match"`
+}
+
+// IsZero reports whether no selector is configured.
+func (c FaultTargetConfig) IsZero() bool {
+	return len(c.Tenants) == 0 && len(c.ConsumerGroups) == 0 && len(c.Match) == 0
+}
+
+// FaultTargetMatch matches a request value extracted with the rate_limit.key
+// syntax ("header:<name>", "jwt_claim:<name>", "cookie:<name>", ...).
+type FaultTargetMatch struct {
+	Key    string   `yaml:"key"`
+	Values []string `yaml:"values"`
+}
+
+// FaultRampConfig linearly ramps the delay and abort percentages from
+// StartPercentage to EndPercentage of their configured values over
+// Duration, then holds at EndPercentage.
+type FaultRampConfig struct {
+	StartPercentage float64       `yaml:"start_percentage"` // 0-100
+	EndPercentage   float64       `yaml:"end_percentage"`   // 0-100
+	Duration        time.Duration `yaml:"duration"`
 }
 
 // FaultDelayConfig defines delay injection settings.
@@ -1780,12 +2636,13 @@ type FaultAbortConfig struct {
 
 // WebhooksConfig defines event webhook notification settings.
 type WebhooksConfig struct {
-	Enabled   bool              `yaml:"enabled"`
-	Endpoints []WebhookEndpoint `yaml:"endpoints"`
-	Retry     WebhookRetryConfig `yaml:"retry"`
-	Timeout   time.Duration     `yaml:"timeout"`
-	Workers   int               `yaml:"workers"`
-	QueueSize int               `yaml:"queue_size"`
+	Enabled    bool               `yaml:"enabled"`
+	Endpoints  []WebhookEndpoint  `yaml:"endpoints"`
+	Retry      WebhookRetryConfig `yaml:"retry"`
+	Timeout    time.Duration      `yaml:"timeout"`
+	Workers    int                `yaml:"workers"`
+	QueueSize  int                `yaml:"queue_size"`
+	SkipShadow *bool              `yaml:"skip_shadow"` // drop events caused by shadowed requests (default true)
 }
 
 // WebhookEndpoint defines a single webhook receiver.
@@ -1807,14 +2664,27 @@ type WebhookRetryConfig struct {
 
 // HealthCheckConfig defines backend health check settings.
 type HealthCheckConfig struct {
-	Path           string        `yaml:"path"`             // default "/health"
-	Method         string        `yaml:"method"`           // default "GET"
-	Interval       time.Duration `yaml:"interval"`         // default 10s
-	Timeout        time.Duration `yaml:"timeout"`          // default 5s
-	HealthyAfter   int           `yaml:"healthy_after"`    // default 2
-	UnhealthyAfter int           `yaml:"unhealthy_after"`  // default 3
-	ExpectedStatus []string      `yaml:"expected_status"`  // e.g. ["200", "2xx", "200-299"]; default 200-399
-}
+	Path           string            `yaml:"path"`            // default "/health"
+	Method         string            `yaml:"method"`          // default "GET", or "POST" with a body
+	Interval       time.Duration     `yaml:"interval"`        // default 10s
+	Timeout        time.Duration     `yaml:"timeout"`         // default 5s
+	HealthyAfter   int               `yaml:"healthy_after"`   // default 2
+	UnhealthyAfter int               `yaml:"unhealthy_after"` // default 3
+	ExpectedStatus []string          `yaml:"expected_status"` // e.g. ["200", "2xx", "200-299"]; default 200-399
+	Type           string            `yaml:"type"`            // "http" (default), "tcp", "grpc"
+	Body           string            `yaml:"body"`            // http: request body sent with the probe
+	Headers        map[string]string `yaml:"headers"`         // http: extra request headers
+	Send           string            `yaml:"send"`            // tcp: written once connected
+	Expect         string            `yaml:"expect"`          // tcp: banner the reply must contain
+	Service        string            `yaml:"service"`         // grpc: grpc.health.v1 service name (empty = overall)
+}
+
+// Health check probe types.
+const (
+	HealthCheckHTTP = "http"
+	HealthCheckTCP  = "tcp"
+	HealthCheckGRPC = "grpc"
+)
 
 // ErrorPagesConfig defines custom error page settings.
 type ErrorPagesConfig struct {
@@ -1837,6 +2707,44 @@ type ErrorPageEntry struct {
 	XMLFile  string `yaml:"xml_file"`
 }
 
+// Error response formats.
+const (
+	ErrorFormatLegacy      = "legacy"       // RunwayError JSON, or error_pages when configured (default)
+	ErrorFormatProblemJSON = "problem_json" // RFC 9457 application/problem+json
+)
+
+// ErrorsConfig selects the format of error responses the gateway generates
+// itself. Route settings override the global ones.
+type ErrorsConfig struct {
+	Format  string                       `yaml:"format"`  // "legacy" (default) | "problem_json"
+	Catalog map[string]ErrorCatalogEntry `yaml:"catalog"` // reason code → problem type and title
+}
+
+// ErrorCatalogEntry documents one error reason code.
+type ErrorCatalogEntry struct {
+	Type  string `yaml:"type"`  // problem type URI
+	Title string `yaml:"title"` // default: the HTTP status text
+}
+
+// Merge returns c with an empty format taken from defaults and the catalog
+// of defaults extended by c's entries.
+func (c ErrorsConfig) Merge(defaults ErrorsConfig) ErrorsConfig {
+	if c.Format == "" {
+		c.Format = defaults.Format
+	}
+	if len(defaults.Catalog) > 0 {
+		catalog := make(map[string]ErrorCatalogEntry, len(defaults.Catalog)+len(c.Catalog))
+		for k, v := range defaults.Catalog {
+			catalog[k] = v
+		}
+		for k, v := range c.Catalog {
+			catalog[k] = v
+		}
+		c.Catalog = catalog
+	}
+	return c
+}
+
 // NonceConfig defines replay prevention nonce settings.
 type NonceConfig struct {
 	Enabled         bool          `yaml:"enabled"`
@@ -1870,6 +2778,19 @@ type CSRFConfig struct {
 	ExemptPaths           []string      `yaml:"exempt_paths"`            // glob patterns
 }
 
+// StorageEncryptionConfig holds the AES-256-GCM keys that seal cache,
+// idempotency and dedup entries written to Redis by routes that set
+// encrypt: true. Entries carry the ID of the key that sealed them; while
+// previous_key_grace lasts, entries sealed with the previous key are still
+// read and rewritten under the current key.
+type StorageEncryptionConfig struct {
+	KeyID             string        `yaml:"key_id"`                            // ID written in front of every sealed entry
+	KeyBase64         string        `yaml:"key_base64" redact:"true"`          // base64-encoded 32-byte key (use a secret reference)
+	PreviousKeyID     string        `yaml:"previous_key_id"`                   // ID of the key being rotated out
+	PreviousKeyBase64 string        `yaml:"previous_key_base64" redact:"true"` // base64-encoded 32-byte previous key
+	PreviousKeyGrace  time.Duration `yaml:"previous_key_grace"`                // how long the previous key stays readable after it is loaded (default 24h)
+}
+
 // IdempotencyConfig defines idempotency key support for mutation requests.
 type IdempotencyConfig struct {
 	Enabled      bool          `yaml:"enabled"`
@@ -1881,6 +2802,7 @@ type IdempotencyConfig struct {
 	Mode         string        `yaml:"mode"`           // "local" (default) or "distributed"
 	MaxKeyLength int           `yaml:"max_key_length"` // default 256
 	MaxBodySize  int64         `yaml:"max_body_size"`  // max response body to store, default 1MB
+	Encrypt      bool          `yaml:"encrypt"`        // seal stored responses with storage_encryption keys (distributed mode only)
 }
 
 // OutlierDetectionConfig defines passive per-backend outlier detection settings.
@@ -1997,8 +2919,28 @@ type HealthGate struct {
 
 // ABTestConfig defines A/B testing metric collection settings.
 type ABTestConfig struct {
-	Enabled        bool   `yaml:"enabled"`
-	ExperimentName string `yaml:"experiment_name"`
+	Enabled        bool                   `yaml:"enabled"`
+	ExperimentName string                 `yaml:"experiment_name"`
+	Assignment     ABTestAssignmentConfig `yaml:"assignment"`
+	Exposure       ABTestExposureConfig   `yaml:"exposure"`
+}
+
+// ABTestAssignmentConfig pins each client to a variant and exposes the
+// assignment to the client and backend.
+type ABTestAssignmentConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Key        string        `yaml:"key"`         // "ip" (default), "client_id", "header:<name>", "cookie:<name>", "jwt_claim:<name>"
+	Header     string        `yaml:"header"`      // default "X-Experiment-Variant"
+	CookieName string        `yaml:"cookie_name"` // default "ab_<experiment_name>"
+	Store      string        `yaml:"store"`       // "local" (default) or "redis"
+	MaxEntries int           `yaml:"max_entries"` // local store size, default 100000
+	TTL        time.Duration `yaml:"ttl"`         // assignment and cookie lifetime, default 720h
+}
+
+// ABTestExposureConfig emits sampled exposure events to the webhook dispatcher.
+type ABTestExposureConfig struct {
+	Enabled    bool    `yaml:"enabled"`
+	SampleRate float64 `yaml:"sample_rate"` // 0.0-1.0, default 1.0
 }
 
 // TransportConfig defines upstream HTTP transport (connection pool) settings.
@@ -2018,6 +2960,7 @@ type TransportConfig struct {
 	KeyFile               string        `yaml:"key_file"`
 	ForceHTTP2            *bool         `yaml:"force_http2"`
 	EnableHTTP3           *bool         `yaml:"enable_http3"` // connect via QUIC to upstream
+	IPFamily              string        `yaml:"ip_family"`    // "auto" (default), "ipv4", "ipv6", "prefer_ipv4", "prefer_ipv6"
 }
 
 // ServiceRateLimitConfig defines global runway-wide throughput cap.
@@ -2028,6 +2971,18 @@ type ServiceRateLimitConfig struct {
 	Burst   int           `yaml:"burst"`  // burst capacity (default = rate)
 }
 
+// RateLimitStateConfig defines saving local token bucket rate limiter state,
+// so reloads and restarts don't hand every client a fresh burst.
+type RateLimitStateConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Store           string        `yaml:"store"`              // "file" (default) or "redis"
+	File            string        `yaml:"file"`               // state file (required for the file store)
+	RedisKey        string        `yaml:"redis_key"`          // Redis key (default "runway:ratelimit_state:<hostname>")
+	SaveInterval    time.Duration `yaml:"save_interval"`      // periodic save when buckets changed (default 30s)
+	ActiveWithin    time.Duration `yaml:"active_within"`      // save buckets used within this window (default 10m)
+	MaxKeysPerRoute int           `yaml:"max_keys_per_route"` // buckets saved and restored per route (default 10000)
+}
+
 // SpikeArrestConfig defines continuous rate enforcement with immediate rejection.
 type SpikeArrestConfig struct {
 	Enabled bool          `yaml:"enabled"`
@@ -2035,6 +2990,21 @@ type SpikeArrestConfig struct {
 	Period  time.Duration `yaml:"period"` // default 1s
 	Burst   int           `yaml:"burst"`  // requests before arrest (default = rate)
 	PerIP   bool          `yaml:"per_ip"`
+	Mode    string        `yaml:"mode"` // "enforce" (default) or "observe" (never rejects)
+
+	IPv6AggregationPrefix int `yaml:"ipv6_aggregation_prefix"` // per_ip: IPv6 clients share a limiter per network of this length (default: global ipv6_aggregation_prefix)
+}
+
+// ConcurrencyLimitConfig caps the in-flight requests of each client on a route.
+type ConcurrencyLimitConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	MaxInFlight int           `yaml:"max_in_flight"` // max concurrent requests per key
+	Key         string        `yaml:"key"`           // same syntax as rate_limit.key (default: client_id, else IP)
+	MaxWait     time.Duration `yaml:"max_wait"`      // how long excess requests wait for a permit (0 = reject immediately)
+	QueueSize   int           `yaml:"queue_size"`    // max waiting requests per key when max_wait > 0 (default 10)
+	MaxKeys     int           `yaml:"max_keys"`      // tracked keys before idle ones are evicted (default 10000)
+	Mode        string        `yaml:"mode"`          // "local" (default) or "distributed"
+	LeaseTTL    time.Duration `yaml:"lease_ttl"`     // distributed: counter expiry after the key's last request (default 5m)
 }
 
 // ContentReplacerConfig defines response content replacement rules.
@@ -2056,6 +3026,19 @@ type DebugEndpointConfig struct {
 	Path    string `yaml:"path"` // default "/__debug"
 }
 
+// TestModeEnv must be "true" for test_mode to be accepted.
+const TestModeEnv = "RUNWAY_ALLOW_TEST_MODE"
+
+// TestModeConfig makes the gateway deterministic for integration test
+// suites: TTL-based components read a clock that stands still until it is
+// advanced through the admin API, and sampling decisions draw from a
+// seeded source. It is read at startup.
+type TestModeConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Seed      int64  `yaml:"seed"`       // random seed (default 1)
+	StartTime string `yaml:"start_time"` // RFC 3339 time the clock is frozen at (default: startup time)
+}
+
 // FollowRedirectsConfig enables following backend 3xx redirects.
 type FollowRedirectsConfig struct {
 	Enabled      bool `yaml:"enabled"`
@@ -2072,28 +3055,56 @@ type BodyGeneratorConfig struct {
 
 // SequentialConfig enables chaining multiple backend calls.
 type SequentialConfig struct {
-	Enabled bool             `yaml:"enabled"`
-	Steps   []SequentialStep `yaml:"steps"`
+	Enabled                  bool             `yaml:"enabled"`
+	Steps                    []SequentialStep `yaml:"steps"`
+	MaxPropagatedHeaders     int              `yaml:"max_propagated_headers"`      // cap on headers propagated from steps (default 32)
+	MaxPropagatedHeaderBytes int              `yaml:"max_propagated_header_bytes"` // cap on their total name+value size (default 8192)
 }
 
 // SequentialStep defines a single step in a sequential proxy chain.
 type SequentialStep struct {
-	URL          string            `yaml:"url"`            // Go template
-	Method       string            `yaml:"method"`         // default: GET
-	Headers      map[string]string `yaml:"headers"`        // Go template values
-	BodyTemplate string            `yaml:"body_template"`  // Go template for request body
-	Timeout      time.Duration     `yaml:"timeout"`        // per-step timeout (default 5s)
-	Variables    map[string]string `yaml:"variables"`      // custom static variables available in templates as .Variables
-	Encoding     string            `yaml:"encoding"`       // response encoding: "no-op" stores full metadata, "string" wraps as content
+	URL              string                 `yaml:"url"`               // Go template
+	Method           string                 `yaml:"method"`            // default: GET
+	Headers          map[string]string      `yaml:"headers"`           // Go template values
+	BodyTemplate     string                 `yaml:"body_template"`     // Go template for request body
+	Timeout          time.Duration          `yaml:"timeout"`           // per-step timeout (default 5s)
+	Variables        map[string]string      `yaml:"variables"`         // custom static variables available in templates as .Variables
+	Encoding         string                 `yaml:"encoding"`          // response encoding: "no-op" stores full metadata, "string" wraps as content
+	PropagateHeaders PropagateHeadersConfig `yaml:"propagate_headers"` // step response headers copied to the client response
+}
+
+// PropagateHeadersConfig selects sub-response headers of a sequential step or
+// aggregate backend to copy to the final client response.
+type PropagateHeadersConfig struct {
+	Headers        []string          `yaml:"headers"`          // exact names, or prefixes ending in "*"
+	Rename         map[string]string `yaml:"rename"`           // sub-response name -> client name
+	Conflict       string            `yaml:"conflict"`         // "first" (default), "last", "join" when an earlier source set the header
+	AllowSetCookie bool              `yaml:"allow_set_cookie"` // Set-Cookie is only propagated when true
 }
 
 // QuotaConfig defines per-client usage quota enforcement.
 type QuotaConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Limit      int64  `yaml:"limit"`       // max requests per period
+	Period     string `yaml:"period"`      // "hourly", "daily", "monthly", "yearly"
+	Key        string `yaml:"key"`         // "ip", "client_id", "header:<name>", "jwt_claim:<name>"
+	Redis      bool   `yaml:"redis"`       // use Redis for distributed tracking
+	SkipShadow *bool  `yaml:"skip_shadow"` // don't count shadowed requests (default true)
+
+	IPv6AggregationPrefix int `yaml:"ipv6_aggregation_prefix"` // IPv6 client IP keys cover a network of this length (default: global ipv6_aggregation_prefix)
+}
+
+// BandwidthQuotaConfig limits the request and response body bytes a client
+// may transfer per billing period.
+type BandwidthQuotaConfig struct {
 	Enabled bool   `yaml:"enabled"`
-	Limit   int64  `yaml:"limit"`   // max requests per period
-	Period  string `yaml:"period"`  // "hourly", "daily", "monthly", "yearly"
-	Key     string `yaml:"key"`     // "ip", "client_id", "header:<name>", "jwt_claim:<name>"
-	Redis   bool   `yaml:"redis"`   // use Redis for distributed tracking
+	Limit   int64  `yaml:"limit"`  // max request+response body bytes per period
+	Period  string `yaml:"period"` // "hourly", "daily", "monthly", "yearly"
+	Key     string `yaml:"key"`    // same syntax as quota.key
+	Redis   bool   `yaml:"redis"`  // use Redis for distributed counting
+	Mode    string `yaml:"mode"`   // "reject" (default) or "log_only"
+
+	IPv6AggregationPrefix int `yaml:"ipv6_aggregation_prefix"` // IPv6 client IP keys cover a network of this length (default: global ipv6_aggregation_prefix)
 }
 
 // RequestCostConfig defines request cost tracking settings.
@@ -2115,7 +3126,7 @@ type CostBudget struct {
 // TenantsConfig defines multi-tenancy settings.
 type TenantsConfig struct {
 	Enabled       bool                          `yaml:"enabled"`
-	Key           string                        `yaml:"key"`            // "header:<name>", "jwt_claim:<name>", "client_id"
+	Key           string                        `yaml:"key"`            // "header:<name>", "jwt_claim:<name>", "form:<name>", "client_id"
 	DefaultTenant string                        `yaml:"default_tenant"` // fallback tenant ID (empty = reject unknown)
 	Tiers         map[string]TenantTierConfig   `yaml:"tiers,omitempty"`
 	Tenants       map[string]TenantConfig       `yaml:"tenants"`
@@ -2126,6 +3137,7 @@ type TenantsConfig struct {
 type TenantTierConfig struct {
 	RateLimit       *TenantRateLimitConfig `yaml:"rate_limit,omitempty"`
 	Quota           *TenantQuotaConfig     `yaml:"quota,omitempty"`
+	BandwidthQuota  *TenantBandwidthConfig `yaml:"bandwidth_quota,omitempty"`
 	MaxBodySize     int64                  `yaml:"max_body_size,omitempty"`
 	Priority        int                    `yaml:"priority,omitempty"`
 	Timeout         time.Duration          `yaml:"timeout,omitempty"`
@@ -2137,6 +3149,7 @@ type TenantTierConfig struct {
 type TenantConfig struct {
 	RateLimit       *TenantRateLimitConfig `yaml:"rate_limit,omitempty"`
 	Quota           *TenantQuotaConfig     `yaml:"quota,omitempty"`
+	BandwidthQuota  *TenantBandwidthConfig `yaml:"bandwidth_quota,omitempty"`  // per-tenant byte quota
 	Routes          []string               `yaml:"routes,omitempty"`           // allowed route IDs (empty = all)
 	MaxBodySize     int64                  `yaml:"max_body_size,omitempty"`
 	Priority        int                    `yaml:"priority,omitempty"`
@@ -2159,6 +3172,14 @@ type TenantQuotaConfig struct {
 	Period string `yaml:"period"` // "hourly", "daily", "monthly", "yearly"
 }
 
+// TenantBandwidthConfig defines a per-tenant request+response byte quota.
+type TenantBandwidthConfig struct {
+	Limit  int64  `yaml:"limit"`
+	Period string `yaml:"period"` // "hourly", "daily", "monthly", "yearly"
+	Redis  bool   `yaml:"redis"`  // use Redis for distributed counting
+	Mode   string `yaml:"mode"`   // "reject" (default) or "log_only"
+}
+
 // RouteTenantConfig defines per-route tenant restrictions.
 type RouteTenantConfig struct {
 	Required bool     `yaml:"required"` // reject if no tenant resolved
@@ -2167,25 +3188,31 @@ type RouteTenantConfig struct {
 
 // AggregateConfig enables parallel multi-backend calls with JSON response merging.
 type AggregateConfig struct {
-	Enabled           bool               `yaml:"enabled"`
-	Timeout           time.Duration      `yaml:"timeout"`            // default 5s
-	FailStrategy      string             `yaml:"fail_strategy"`      // "abort" (default) or "partial"
-	Backends          []AggregateBackend `yaml:"backends"`
-	ResponseTransform BodyTransformConfig `yaml:"response_transform"` // post-merge body transform (flatmap, allow/deny, etc.)
+	Enabled                  bool                `yaml:"enabled"`
+	Timeout                  time.Duration       `yaml:"timeout"`       // default 5s
+	FailStrategy             string              `yaml:"fail_strategy"` // "abort" (default) or "partial"
+	Backends                 []AggregateBackend  `yaml:"backends"`
+	ResponseTransform        BodyTransformConfig `yaml:"response_transform"`          // post-merge body transform (flatmap, allow/deny, etc.)
+	MaxPropagatedHeaders     int                 `yaml:"max_propagated_headers"`      // cap on headers propagated from backends (default 32)
+	MaxPropagatedHeaderBytes int                 `yaml:"max_propagated_header_bytes"` // cap on their total name+value size (default 8192)
 }
 
 // AggregateBackend defines one backend in an aggregate call.
 type AggregateBackend struct {
-	Name      string            `yaml:"name"`      // unique name (required)
-	URL       string            `yaml:"url"`       // Go template
-	Method    string            `yaml:"method"`    // default GET
-	Headers   map[string]string `yaml:"headers"`   // Go template values
-	Group     string            `yaml:"group"`     // wrap response under this JSON key
-	Required  bool              `yaml:"required"`  // abort if fails (relevant for partial)
-	Timeout   time.Duration     `yaml:"timeout"`   // per-backend override
-	Variables map[string]string `yaml:"variables"` // custom static variables available in templates as .Variables
-	Encoding  string            `yaml:"encoding"`  // backend response encoding (xml, yaml, etc.) — decoded to JSON before merge
-	Transform BodyTransformConfig `yaml:"transform"` // per-backend response transform (allow/deny/rename/set/remove fields)
+	Name             string                 `yaml:"name"`              // unique name (required)
+	URL              string                 `yaml:"url"`               // Go template
+	Method           string                 `yaml:"method"`            // default GET
+	Headers          map[string]string      `yaml:"headers"`           // Go template values
+	Group            string                 `yaml:"group"`             // wrap response under this JSON key
+	Required         bool                   `yaml:"required"`          // abort if fails (relevant for partial)
+	Timeout          time.Duration          `yaml:"timeout"`           // per-backend override
+	Variables        map[string]string      `yaml:"variables"`         // custom static variables available in templates as .Variables
+	Encoding         string                 `yaml:"encoding"`          // backend response encoding (xml, yaml, etc.) — decoded to JSON before merge
+	Transform        BodyTransformConfig    `yaml:"transform"`         // per-backend response transform (allow/deny/rename/set/remove fields)
+	PropagateHeaders PropagateHeadersConfig `yaml:"propagate_headers"` // backend response headers copied to the client response
+	BackendAuth      BackendAuthConfig      `yaml:"backend_auth"`      // ref to a shared backend_auth_providers entry
+
+	CopyRequestPolicy `yaml:",inline"` // applied after the headers templates; forward_query_params appends client query parameters to the URL
 }
 
 // ResponseBodyGeneratorConfig defines a Go template that rewrites the entire response body.
@@ -2207,7 +3234,7 @@ type ParamForwardingConfig struct {
 // ContentNegotiationConfig defines content negotiation settings.
 type ContentNegotiationConfig struct {
 	Enabled   bool     `yaml:"enabled"`
-	Supported []string `yaml:"supported"` // "json", "xml", "yaml"
+	Supported []string `yaml:"supported"` // "json", "xml", "yaml", "cbor"
 	Default   string   `yaml:"default"`   // default "json"
 }
 
@@ -2251,7 +3278,17 @@ type EdgeCacheMatch struct {
 
 // BackendEncodingConfig defines backend response format decoding to JSON.
 type BackendEncodingConfig struct {
-	Encoding string `yaml:"encoding"` // "xml", "yaml", "safejson", "rss", "string", "fast-json" — backend response format to decode to JSON
+	Encoding    string           `yaml:"encoding"`      // "xml", "yaml", "cbor", "avro", "safejson", "rss", "string", "fast-json" — backend response format to decode to JSON
+	MaxBodySize int64            `yaml:"max_body_size"` // cbor/avro: max backend body to decode in bytes (default 10MB)
+	Avro        AvroSchemaConfig `yaml:"avro"`          // schema source for encoding "avro"
+}
+
+// AvroSchemaConfig defines where Avro writer schemas for backend responses come from.
+type AvroSchemaConfig struct {
+	SchemaFile  string        `yaml:"schema_file"`  // JSON schema file; bodies are plain Avro binary
+	RegistryURL string        `yaml:"registry_url"` // Confluent-style schema registry; bodies carry the 5-byte wire format header
+	Timeout     time.Duration `yaml:"timeout"`      // registry request timeout (default 5s)
+	CacheSize   int           `yaml:"cache_size"`   // max registry schemas cached by ID (default 1000)
 }
 
 // SSRFProtectionConfig defines SSRF protection for outbound proxy connections.
@@ -2261,6 +3298,16 @@ type SSRFProtectionConfig struct {
 	BlockLinkLocal *bool    `yaml:"block_link_local"`   // default true
 }
 
+// EgressPolicyConfig restricts outbound connections to approved destinations.
+// Static config URLs are checked at load time; discovered backends and
+// template-resolved hosts are checked before dialing.
+type EgressPolicyConfig struct {
+	Enabled        bool     `yaml:"enabled"`
+	Mode           string   `yaml:"mode"`            // "enforce" (default) or "warn"
+	AllowedDomains []string `yaml:"allowed_domains"` // suffix match on hostnames ("example.com" allows api.example.com)
+	AllowedCIDRs   []string `yaml:"allowed_cidrs"`   // IP literals and resolved addresses
+}
+
 // RequestDedupConfig defines per-route request deduplication settings.
 type RequestDedupConfig struct {
 	Enabled        bool          `yaml:"enabled"`
@@ -2269,6 +3316,21 @@ type RequestDedupConfig struct {
 	IncludeBody    *bool         `yaml:"include_body"`     // default true
 	MaxBodySize    int64         `yaml:"max_body_size"`    // default 1MB
 	Mode           string        `yaml:"mode"`             // "local" or "distributed"
+	Encrypt        bool          `yaml:"encrypt"`          // seal stored responses with storage_encryption keys (distributed mode only)
+}
+
+// ContentDedupConfig defines per-route deduplication of identical mutation
+// requests (e.g. double-submitted webhooks) without a client idempotency key.
+type ContentDedupConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	TTL            time.Duration `yaml:"ttl"`             // how long a completed response is remembered (default 10s)
+	Methods        []string      `yaml:"methods"`         // default ["POST","PUT","PATCH","DELETE"]
+	IncludeHeaders []string      `yaml:"include_headers"` // header values added to the fingerprint
+	MaxBodySize    int64         `yaml:"max_body_size"`   // larger bodies are not deduplicated (default 1MB)
+	OnDuplicate    string        `yaml:"on_duplicate"`    // "replay" (default) or "reject" (409)
+	WaitTimeout    time.Duration `yaml:"wait_timeout"`    // max wait for an in-flight original in replay mode (default 10s)
+	Mode           string        `yaml:"mode"`            // "local" (default) or "distributed"
+	Encrypt        bool          `yaml:"encrypt"`         // seal stored responses with storage_encryption keys (distributed mode only)
 }
 
 // IPBlocklistConfig defines dynamic IP blocklist settings.
@@ -2286,12 +3348,33 @@ type IPBlocklistFeed struct {
 	Format          string        `yaml:"format"`           // "text" or "json"
 }
 
+// ReputationConfig defines client IP reputation scoring. Signals observed on
+// requests add weighted points to the client IP's score, which decays over
+// time; an IP whose score reaches the threshold is temporarily blocked through
+// the global IP blocklist.
+type ReputationConfig struct {
+	Enabled        bool               `yaml:"enabled"`
+	Weights        map[string]float64 `yaml:"weights"`         // points per signal: waf, auth_failure, rate_limit, bot (defaults 25, 5, 2, 20)
+	Threshold      float64            `yaml:"threshold"`       // score that triggers a block (default 100)
+	HalfLife       time.Duration      `yaml:"half_life"`       // time for a score to decay by half (default 10m)
+	BlockDurations []time.Duration    `yaml:"block_durations"` // escalating block durations, last one repeats (default 5m, 30m, 2h, 24h)
+	ExemptCIDRs    []string           `yaml:"exempt_cidrs"`    // IPs/CIDRs never scored or blocked
+	MaxEntries     int                `yaml:"max_entries"`     // max IPs tracked per instance (default 100000)
+	HistorySize    int                `yaml:"history_size"`    // signals kept per IP for the admin API (default 20)
+	Store          string             `yaml:"store"`           // "memory" (default) or "redis" to share scores across instances
+
+	IPv6AggregationPrefix int `yaml:"ipv6_aggregation_prefix"` // IPv6 clients are scored and blocked per network of this length (default: global ipv6_aggregation_prefix)
+}
+
 // BaggageConfig defines baggage propagation settings for a route.
 type BaggageConfig struct {
 	Enabled        bool            `yaml:"enabled"`
 	PropagateTrace bool            `yaml:"propagate_trace"` // inject traceparent/tracestate to backends
 	W3CBaggage     bool            `yaml:"w3c_baggage"`     // emit W3C baggage header from tag values
 	Tags           []BaggageTagDef `yaml:"tags"`
+	MaxMembers     int             `yaml:"max_members"`     // max baggage members forwarded (default 64)
+	MaxBytes       int             `yaml:"max_bytes"`       // max serialized baggage size in bytes (default 8192)
+	ConflictPolicy string          `yaml:"conflict_policy"` // "local" (default) or "inbound": which value wins when an inbound member has a tag's key
 }
 
 // BaggageTagDef defines a single baggage tag to extract and propagate.
@@ -2300,6 +3383,7 @@ type BaggageTagDef struct {
 	Source     string `yaml:"source"`      // extraction source: header:<name>, jwt_claim:<name>, query:<name>, cookie:<name>, static:<value>
 	Header     string `yaml:"header"`      // backend header name to propagate as
 	BaggageKey string `yaml:"baggage_key"` // W3C baggage key; defaults to Name
+	Priority   int    `yaml:"priority"`    // truncation priority; higher survives longer, inbound members are 0
 }
 
 // BackpressureConfig defines backend backpressure detection settings.
@@ -2321,6 +3405,28 @@ type LoadSheddingConfig struct {
 	RetryAfter       int           `yaml:"retry_after"`       // Retry-After header value in seconds, default 5
 }
 
+// WarmupConfig defines gradual warm-up settings for a freshly started instance.
+type WarmupConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	InitialWeight   int           `yaml:"initial_weight"`   // readiness weight at the start of warm-up (0-100, default 0)
+	Duration        time.Duration `yaml:"duration"`         // time to ramp the weight to 100 (default 60s)
+	SelfThrottle    bool          `yaml:"self_throttle"`    // reject the not-yet-warm fraction of requests with 503
+	RetryAfter      int           `yaml:"retry_after"`      // Retry-After header value in seconds for throttled requests, default 1
+	ReloadThreshold float64       `yaml:"reload_threshold"` // restart warm-up when a reload changes more than this fraction of routes (0-1, default 0.5)
+}
+
+// FeatureFlagsConfig defines a Consul KV or etcd prefix watched for runtime
+// overrides (per-route feature kill-switches, maintenance mode, log level).
+type FeatureFlagsConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	Backend          string        `yaml:"backend"`            // "consul" or "etcd"
+	Prefix           string        `yaml:"prefix"`             // key prefix to watch (default "runway/flags/")
+	Consul           ConsulConfig  `yaml:"consul"`             // defaults to registry.consul when address is empty
+	Etcd             EtcdConfig    `yaml:"etcd"`               // defaults to registry.etcd when endpoints are empty
+	RetryInterval    time.Duration `yaml:"retry_interval"`     // initial reconnect backoff (default 1s)
+	MaxRetryInterval time.Duration `yaml:"max_retry_interval"` // reconnect backoff cap (default 30s)
+}
+
 // AuditLogConfig defines audit logging settings (global + per-route merge).
 type AuditLogConfig struct {
 	Enabled       bool              `yaml:"enabled"`
@@ -2334,6 +3440,22 @@ type AuditLogConfig struct {
 	FlushInterval time.Duration     `yaml:"flush_interval"`  // default 5s
 	Methods       []string          `yaml:"methods"`         // filter (empty=all)
 	StatusCodes   []int             `yaml:"status_codes"`    // filter (empty=all)
+	SkipShadow    *bool             `yaml:"skip_shadow"`     // don't log shadowed requests (default true)
+}
+
+// DecisionLogConfig records the policy decisions made for each request on
+// a route (authentication, request rules, WAF, rate limits, quotas, geo and
+// tenant resolution, the backend) and emits them as one versioned record,
+// keyed by request ID, when the request ends.
+type DecisionLogConfig struct {
+	Enabled        bool              `yaml:"enabled"`
+	Sink           string            `yaml:"sink"`                   // "file" (default) or "audit_webhook"
+	File           string            `yaml:"file"`                   // record file, JSON lines (sink file)
+	Rotation       LogRotationConfig `yaml:"rotation"`               // record file rotation
+	MaxEntries     int               `yaml:"max_entries"`            // decisions kept per request (default 64)
+	MaxValueLength int               `yaml:"max_value_length"`       // bytes kept per attribute value (default 256)
+	HashKey        string            `yaml:"hash_key" redact:"true"` // HMAC key for hashed values (plain SHA-256 when empty)
+	BufferSize     int               `yaml:"buffer_size"`            // records queued before dropping (default 1000)
 }
 
 // DefaultConfig returns a configuration with sensible defaults
@@ -2360,8 +3482,8 @@ type ModifierConfig struct {
 
 // ConditionConfig defines a condition for conditional modifier execution.
 type ConditionConfig struct {
-	Type  string `yaml:"type"`  // "header", "cookie", "query", "path_regex"
-	Name  string `yaml:"name"`  // header/cookie/query param name
+	Type  string `yaml:"type"`  // "header", "cookie", "query", "path_regex", "body"
+	Name  string `yaml:"name"`  // header/cookie/query param name, or JSON body field path
 	Value string `yaml:"value"` // optional regex pattern to match
 }
 
@@ -2379,6 +3501,21 @@ type FieldReplacerOperation struct {
 	Replace string `yaml:"replace"` // replacement string (regexp/literal)
 }
 
+// ResponseFieldPolicyConfig defines identity-based filtering of JSON response fields.
+type ResponseFieldPolicyConfig struct {
+	Enabled     bool                      `yaml:"enabled"`
+	MaxBodySize int64                     `yaml:"max_body_size"` // larger bodies skip filtering (default 1MB)
+	Rules       []ResponseFieldPolicyRule `yaml:"rules"`
+}
+
+// ResponseFieldPolicyRule maps an identity predicate to allowed or denied response fields.
+type ResponseFieldPolicyRule struct {
+	ID          string   `yaml:"id"`           // optional; defaults to "rule-<index>"
+	When        string   `yaml:"when"`         // rules-engine expression; empty matches every request
+	AllowFields []string `yaml:"allow_fields"` // gjson-style paths to keep (all others removed)
+	DenyFields  []string `yaml:"deny_fields"`  // gjson-style paths to remove
+}
+
 // JMESPathConfig defines JMESPath query filtering on response bodies.
 type JMESPathConfig struct {
 	Enabled         bool   `yaml:"enabled"`
@@ -2400,8 +3537,21 @@ type ErrorHandlingConfig struct {
 // LuaConfig defines Lua scripting for a route.
 type LuaConfig struct {
 	Enabled        bool   `yaml:"enabled"`
+	Name           string `yaml:"name"`            // plugin name for script metrics; default "lua"
 	RequestScript  string `yaml:"request_script"`  // Lua code for request phase
 	ResponseScript string `yaml:"response_script"` // Lua code for response phase
+
+	ShadowAsync         bool   `yaml:"shadow_async"`          // evaluate off the request path: shadow_request_script, or request_script without enforcing it
+	ShadowRequestScript string `yaml:"shadow_request_script"` // candidate request script compared with request_script (requires shadow_async)
+}
+
+// MetricsName returns the plugin name the route's script metrics are
+// reported under.
+func (c LuaConfig) MetricsName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return "lua"
 }
 
 // WasmConfig defines global WASM plugin runtime settings.
@@ -2421,20 +3571,31 @@ type WasmPluginConfig struct {
 	PoolSize int               `yaml:"pool_size"` // pre-instantiated module pool size
 }
 
+// MetricsName returns the plugin name the plugin's metrics are reported
+// under.
+func (c WasmPluginConfig) MetricsName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return "wasm"
+}
+
 // LambdaConfig defines AWS Lambda backend settings.
 type LambdaConfig struct {
-	Enabled      bool   `yaml:"enabled"`
-	FunctionName string `yaml:"function_name"`
-	Region       string `yaml:"region"`
-	MaxRetries   int    `yaml:"max_retries"` // default 2
+	Enabled      bool                  `yaml:"enabled"`
+	FunctionName string                `yaml:"function_name"`
+	Region       string                `yaml:"region"`
+	MaxRetries   int                   `yaml:"max_retries"` // default 2
+	Fallback     HandlerFallbackConfig `yaml:"fallback"`
 }
 
 // AMQPConfig defines AMQP/RabbitMQ backend settings.
 type AMQPConfig struct {
-	Enabled  bool             `yaml:"enabled"`
-	URL      string           `yaml:"url"`
-	Consumer AMQPConsumerConfig `yaml:"consumer"`
-	Producer AMQPProducerConfig `yaml:"producer"`
+	Enabled  bool                  `yaml:"enabled"`
+	URL      string                `yaml:"url"`
+	Consumer AMQPConsumerConfig    `yaml:"consumer"`
+	Producer AMQPProducerConfig    `yaml:"producer"`
+	Fallback HandlerFallbackConfig `yaml:"fallback"`
 }
 
 // AMQPConsumerConfig defines AMQP consumer settings.
@@ -2456,6 +3617,38 @@ type PubSubConfig struct {
 	PublishURL      string `yaml:"publish_url"`       // Go CDK publish URL
 }
 
+// KafkaConfig defines Kafka producer backend settings. POST bodies are
+// produced to Topic and the route answers 202 with the partition and offset.
+type KafkaConfig struct {
+	Enabled  bool            `yaml:"enabled"`
+	Brokers  []string        `yaml:"brokers"`   // bootstrap host:port addresses
+	Topic    string          `yaml:"topic"`
+	Key      string          `yaml:"key"`       // partition key Go template; empty spreads messages round-robin
+	Acks     string          `yaml:"acks"`      // none, leader or all (default all)
+	Headers  []string        `yaml:"headers"`   // request headers copied to Kafka record headers
+	Timeout  time.Duration   `yaml:"timeout"`   // produce timeout (default 10s)
+	ClientID string          `yaml:"client_id"` // default "runway"
+	TLS      KafkaTLSConfig  `yaml:"tls"`
+	SASL     KafkaSASLConfig `yaml:"sasl"`
+}
+
+// KafkaTLSConfig configures TLS, and optionally mTLS, for broker connections.
+type KafkaTLSConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	CAFile     string `yaml:"ca_file"`
+	CertFile   string `yaml:"cert_file"` // client certificate for mTLS
+	KeyFile    string `yaml:"key_file"`  // client key for mTLS
+	ServerName string `yaml:"server_name"`
+	SkipVerify bool   `yaml:"skip_verify"`
+}
+
+// KafkaSASLConfig configures SASL authentication to the brokers.
+type KafkaSASLConfig struct {
+	Mechanism string `yaml:"mechanism"` // PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512; empty disables SASL
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password" redact:"true"` // supports ${ENV_VAR}
+}
+
 // AggregateResponseTransformConfig allows post-merge body transforms on aggregated responses.
 type AggregateResponseTransformConfig struct {
 	BodyTransformConfig `yaml:",inline"`
@@ -2481,8 +3674,8 @@ type SOAPProtocolConfig struct {
 // Unlike http_to_grpc, this passes protobuf bytes through unchanged,
 // only transforming the framing layer (gRPC-Web wire format to native gRPC).
 type GRPCWebTranslateConfig struct {
-	Timeout        time.Duration     `yaml:"timeout" json:"timeout"`                 // per-call timeout (default 30s)
-	MaxMessageSize int               `yaml:"max_message_size" json:"max_message_size"` // max message size in bytes (default 4MB)
+	Timeout        time.Duration     `yaml:"timeout" json:"timeout"`                 // idle timeout between backend messages (default 30s)
+	MaxMessageSize int               `yaml:"max_message_size" json:"max_message_size"` // max size of each message in bytes (default 4MB)
 	TextMode       bool              `yaml:"text_mode" json:"text_mode"`              // accept grpc-web-text base64 encoding (default true)
 	TLS            ProtocolTLSConfig `yaml:"tls" json:"tls"`
 }
@@ -2512,8 +3705,10 @@ func (c CoalesceConfig) IsEnabled() bool               { return c.Enabled }
 func (c VersioningConfig) IsEnabled() bool             { return c.Enabled }
 func (c ProxyRateLimitConfig) IsEnabled() bool         { return c.Enabled }
 func (c ClaimsPropagationConfig) IsEnabled() bool      { return c.Enabled }
-func (c TokenExchangeConfig) IsEnabled() bool          { return c.Enabled }
-func (c BackendAuthConfig) IsEnabled() bool            { return c.Enabled }
+func (c TokenExchangeConfig) IsEnabled() bool          { return c.Enabled || c.Ref != "" }
+func (c WebSocketConfig) IsEnabled() bool              { return c.Enabled }
+func (c BackendAuthConfig) IsEnabled() bool            { return c.Enabled || c.Ref != "" }
+func (c BackendHeadersSecretConfig) IsEnabled() bool   { return c.Enabled }
 func (c FastCGIConfig) IsEnabled() bool                { return c.Enabled }
 func (c AIConfig) IsEnabled() bool                     { return c.Enabled }
 func (c BodyGeneratorConfig) IsEnabled() bool          { return c.Enabled }
@@ -2523,9 +3718,10 @@ func (c PIIRedactionConfig) IsEnabled() bool           { return c.Enabled }
 func (c FieldEncryptionConfig) IsEnabled() bool        { return c.Enabled }
 func (c JMESPathConfig) IsEnabled() bool               { return c.Enabled }
 func (c FieldReplacerConfig) IsEnabled() bool          { return c.Enabled }
+func (c ResponseFieldPolicyConfig) IsEnabled() bool    { return c.Enabled }
 func (c LuaConfig) IsEnabled() bool                    { return c.Enabled }
 func (c TrafficReplayConfig) IsEnabled() bool          { return c.Enabled }
-func (c OPAConfig) IsEnabled() bool                    { return c.Enabled }
+func (c OPAConfig) IsEnabled() bool                    { return c.Enabled || c.Ref != "" }
 func (c ResponseSigningConfig) IsEnabled() bool        { return c.Enabled }
 func (c RequestCostConfig) IsEnabled() bool            { return c.Enabled }
 func (c GraphQLSubscriptionConfig) IsEnabled() bool    { return c.Enabled }
@@ -2535,8 +3731,10 @@ func (c ETagConfig) IsEnabled() bool                   { return c.Enabled }
 func (c StreamingConfig) IsEnabled() bool              { return c.Enabled }
 func (c SSEConfig) IsEnabled() bool                    { return c.Enabled }
 func (c RequestDedupConfig) IsEnabled() bool           { return c.Enabled }
-func (c ExtAuthConfig) IsEnabled() bool                { return c.Enabled }
+func (c ContentDedupConfig) IsEnabled() bool           { return c.Enabled }
+func (c ExtAuthConfig) IsEnabled() bool                { return c.Enabled || c.Ref != "" }
 func (c QuotaConfig) IsEnabled() bool                  { return c.Enabled }
+func (c BandwidthQuotaConfig) IsEnabled() bool         { return c.Enabled }
 func (c MirrorConfig) IsEnabled() bool                 { return c.Enabled }
 func (c ThrottleConfig) IsEnabled() bool               { return c.Enabled }
 func (c BandwidthConfig) IsEnabled() bool              { return c.Enabled }
@@ -2544,11 +3742,16 @@ func (c FaultInjectionConfig) IsEnabled() bool         { return c.Enabled }
 func (c RequestQueueConfig) IsEnabled() bool           { return c.Enabled }
 func (c RequestDecompressionConfig) IsEnabled() bool   { return c.Enabled }
 func (c ResponseLimitConfig) IsEnabled() bool          { return c.Enabled }
+func (c ResponseBufferingConfig) IsEnabled() bool      { return c.Enabled }
 func (c SecurityHeadersConfig) IsEnabled() bool        { return c.Enabled }
 func (c MaintenanceConfig) IsEnabled() bool            { return c.Enabled }
+func (c SyntheticMonitoringConfig) IsEnabled() bool    { return c.Enabled }
+func (c DegradedModeConfig) IsEnabled() bool           { return c.Enabled }
+func (c RoutePeerFailoverConfig) IsEnabled() bool      { return c.Enabled }
 func (c BotDetectionConfig) IsEnabled() bool           { return c.Enabled }
 func (c AICrawlConfig) IsEnabled() bool                { return c.Enabled }
 func (c SpikeArrestConfig) IsEnabled() bool            { return c.Enabled }
+func (c ConcurrencyLimitConfig) IsEnabled() bool       { return c.Enabled }
 func (c ClientMTLSConfig) IsEnabled() bool             { return c.Enabled }
 func (c BackendSigningConfig) IsEnabled() bool         { return c.Enabled }
 func (c InboundSigningConfig) IsEnabled() bool         { return c.Enabled }
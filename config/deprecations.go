@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// deprecationDocURL is the documentation section listing every deprecated
+// field and its replacement.
+const deprecationDocURL = "https://github.com/wudi/runway/blob/main/docs/reference/configuration-reference.md#deprecated-fields"
+
+// DeprecationWarning describes a deprecated field found in a loaded config.
+type DeprecationWarning struct {
+	Field       string `json:"field"`       // path of the deprecated field, e.g. routes[0].timeout
+	Replacement string `json:"replacement"` // path of the field that replaces it
+	Message     string `json:"message"`     // what the loader did with the value
+	DocURL      string `json:"doc_url"`
+}
+
+// String formats the warning for CLI and log output.
+func (w DeprecationWarning) String() string {
+	return fmt.Sprintf("%s is deprecated, use %s: %s (see %s)", w.Field, w.Replacement, w.Message, w.DocURL)
+}
+
+// routeDeprecation is a deprecated per-route field and the function that
+// translates it to its replacement.
+type routeDeprecation struct {
+	field       string // YAML key relative to the route
+	replacement string // YAML key relative to the route
+	// migrate moves the deprecated value onto its replacement and clears it.
+	// It returns false if the field was unset, and otherwise a message
+	// describing the translation or why the value was ignored.
+	migrate func(rc *RouteConfig) (string, bool)
+}
+
+// routeDeprecations lists the deprecated route fields. Entries run in order,
+// so a migration may read fields a later entry clears (retries reads timeout
+// to keep the legacy per-try timeout).
+var routeDeprecations = []routeDeprecation{
+	{
+		field:       "retries",
+		replacement: "retry_policy.max_retries",
+		migrate: func(rc *RouteConfig) (string, bool) {
+			if rc.Retries == 0 {
+				return "", false
+			}
+			old := rc.Retries
+			rc.Retries = 0
+			if rc.RetryPolicy.MaxRetries > 0 {
+				return fmt.Sprintf("value %d ignored, retry_policy.max_retries=%d takes precedence", old, rc.RetryPolicy.MaxRetries), true
+			}
+			rc.RetryPolicy.MaxRetries = old
+			// Legacy retries used the route timeout as the per-try timeout.
+			if rc.RetryPolicy.PerTryTimeout == 0 && rc.Timeout > 0 {
+				rc.RetryPolicy.PerTryTimeout = rc.Timeout
+				return fmt.Sprintf("translated to retry_policy.max_retries=%d with per_try_timeout=%s", old, rc.Timeout), true
+			}
+			return fmt.Sprintf("translated to retry_policy.max_retries=%d", old), true
+		},
+	},
+	{
+		field:       "timeout",
+		replacement: "timeout_policy.request",
+		migrate: func(rc *RouteConfig) (string, bool) {
+			if rc.Timeout == 0 {
+				return "", false
+			}
+			old := rc.Timeout
+			rc.Timeout = 0
+			if rc.TimeoutPolicy.Request > 0 {
+				return fmt.Sprintf("value %s ignored, timeout_policy.request=%s takes precedence", old, rc.TimeoutPolicy.Request), true
+			}
+			rc.TimeoutPolicy.Request = old
+			return fmt.Sprintf("translated to timeout_policy.request=%s", old), true
+		},
+	},
+}
+
+// migrateDeprecations translates every deprecated field in cfg to its
+// replacement and returns one warning per field found. The new field always
+// wins when both are set.
+func migrateDeprecations(cfg *Config) []DeprecationWarning {
+	var warnings []DeprecationWarning
+	for i := range cfg.Routes {
+		rc := &cfg.Routes[i]
+		for _, d := range routeDeprecations {
+			msg, used := d.migrate(rc)
+			if !used {
+				continue
+			}
+			prefix := fmt.Sprintf("routes[%d]", i)
+			if rc.ID != "" {
+				prefix = fmt.Sprintf("routes[%s]", rc.ID)
+			}
+			warnings = append(warnings, DeprecationWarning{
+				Field:       prefix + "." + d.field,
+				Replacement: prefix + "." + d.replacement,
+				Message:     msg,
+				DocURL:      deprecationDocURL,
+			})
+		}
+	}
+	return warnings
+}
+
+// deprecationError reports the deprecated fields in strict mode.
+func deprecationError(warnings []DeprecationWarning) error {
+	fields := make([]string, len(warnings))
+	for i, w := range warnings {
+		fields[i] = w.Field + " (use " + w.Replacement + ")"
+	}
+	return fmt.Errorf("strict_deprecations: deprecated fields in use: %s", strings.Join(fields, ", "))
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func deprecationConfig(routeFields string) string {
+	return `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: "api"
+    path: "/api"
+    backends:
+      - url: "http://localhost:9000"
+` + routeFields
+}
+
+func TestParseMigratesDeprecatedFields(t *testing.T) {
+	tests := []struct {
+		name          string
+		fields        string
+		wantRetries   int
+		wantPerTry    time.Duration
+		wantRequest   time.Duration
+		wantWarnings  []string // deprecated field paths, in order
+		wantMsgSubstr []string
+	}{
+		{
+			name:        "no deprecated fields",
+			fields:      "    timeout_policy:\n      request: 5s\n",
+			wantRequest: 5 * time.Second,
+		},
+		{
+			name:          "timeout translated",
+			fields:        "    timeout: 5s\n",
+			wantRequest:   5 * time.Second,
+			wantWarnings:  []string{"routes[api].timeout"},
+			wantMsgSubstr: []string{"translated to timeout_policy.request=5s"},
+		},
+		{
+			name:          "timeout_policy.request wins over timeout",
+			fields:        "    timeout: 5s\n    timeout_policy:\n      request: 2s\n",
+			wantRequest:   2 * time.Second,
+			wantWarnings:  []string{"routes[api].timeout"},
+			wantMsgSubstr: []string{"value 5s ignored"},
+		},
+		{
+			name:          "retries translated",
+			fields:        "    retries: 3\n",
+			wantRetries:   3,
+			wantWarnings:  []string{"routes[api].retries"},
+			wantMsgSubstr: []string{"translated to retry_policy.max_retries=3"},
+		},
+		{
+			name:          "retry_policy.max_retries wins over retries",
+			fields:        "    retries: 3\n    retry_policy:\n      max_retries: 1\n",
+			wantRetries:   1,
+			wantWarnings:  []string{"routes[api].retries"},
+			wantMsgSubstr: []string{"value 3 ignored"},
+		},
+		{
+			name:          "retries with timeout keeps legacy per-try timeout",
+			fields:        "    retries: 2\n    timeout: 4s\n",
+			wantRetries:   2,
+			wantPerTry:    4 * time.Second,
+			wantRequest:   4 * time.Second,
+			wantWarnings:  []string{"routes[api].retries", "routes[api].timeout"},
+			wantMsgSubstr: []string{"per_try_timeout=4s", "translated to timeout_policy.request=4s"},
+		},
+		{
+			name:         "explicit per_try_timeout is kept",
+			fields:       "    retries: 2\n    timeout: 4s\n    retry_policy:\n      per_try_timeout: 1s\n",
+			wantRetries:  2,
+			wantPerTry:   time.Second,
+			wantRequest:  4 * time.Second,
+			wantWarnings: []string{"routes[api].retries", "routes[api].timeout"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := NewLoader().Parse([]byte(deprecationConfig(tt.fields)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			rc := cfg.Routes[0]
+			if rc.Timeout != 0 || rc.Retries != 0 {
+				t.Errorf("deprecated fields not cleared: timeout=%s retries=%d", rc.Timeout, rc.Retries)
+			}
+			if rc.RetryPolicy.MaxRetries != tt.wantRetries {
+				t.Errorf("max_retries = %d, want %d", rc.RetryPolicy.MaxRetries, tt.wantRetries)
+			}
+			if rc.RetryPolicy.PerTryTimeout != tt.wantPerTry {
+				t.Errorf("per_try_timeout = %s, want %s", rc.RetryPolicy.PerTryTimeout, tt.wantPerTry)
+			}
+			if rc.TimeoutPolicy.Request != tt.wantRequest {
+				t.Errorf("timeout_policy.request = %s, want %s", rc.TimeoutPolicy.Request, tt.wantRequest)
+			}
+			if len(cfg.DeprecationWarnings) != len(tt.wantWarnings) {
+				t.Fatalf("warnings = %v, want fields %v", cfg.DeprecationWarnings, tt.wantWarnings)
+			}
+			for i, w := range cfg.DeprecationWarnings {
+				if w.Field != tt.wantWarnings[i] {
+					t.Errorf("warning %d field = %q, want %q", i, w.Field, tt.wantWarnings[i])
+				}
+				if w.Replacement == "" || w.DocURL == "" {
+					t.Errorf("warning %d missing replacement or doc link: %+v", i, w)
+				}
+				if i < len(tt.wantMsgSubstr) && !strings.Contains(w.Message, tt.wantMsgSubstr[i]) {
+					t.Errorf("warning %d message = %q, want substring %q", i, w.Message, tt.wantMsgSubstr[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseStrictDeprecations(t *testing.T) {
+	_, err := NewLoader().Parse([]byte("strict_deprecations: true\n" + deprecationConfig("    timeout: 5s\n")))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "routes[api].timeout (use routes[api].timeout_policy.request)") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := NewLoader().Parse([]byte("strict_deprecations: true\n" + deprecationConfig("    timeout_policy:\n      request: 5s\n"))); err != nil {
+		t.Errorf("unexpected error without deprecated fields: %v", err)
+	}
+}
+
+func TestParseMigratedRetriesAreValidated(t *testing.T) {
+	// Translated retries are subject to retry_policy rules, such as the
+	// mutual exclusion with hedging.
+	_, err := NewLoader().Parse([]byte(deprecationConfig("    retries: 2\n    retry_policy:\n      hedging:\n        enabled: true\n")))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
@@ -0,0 +1,17 @@
+package config
+
+import "fmt"
+
+// EgressWarning describes a static outbound destination outside the
+// egress policy's allowlist, collected instead of failing the load when the
+// policy is in warn mode.
+type EgressWarning struct {
+	Field   string `json:"field"`
+	Host    string `json:"host"`
+	Message string `json:"message"`
+}
+
+// String formats the warning for CLI and log output.
+func (w EgressWarning) String() string {
+	return fmt.Sprintf("egress_policy: %s: destination %q %s", w.Field, w.Host, w.Message)
+}
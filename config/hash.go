@@ -0,0 +1,30 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Hash returns a stable SHA-256 hash of cfg's effective configuration.
+// The config is canonicalized by re-encoding the parsed struct, so key
+// order in the source YAML and map iteration order do not matter. Secrets
+// (fields tagged `redact:"true"`, in-memory certificates) and per-replica
+// identity (cluster node ID, config drift instance ID) are excluded, so
+// replicas running the same config report the same hash.
+func Hash(cfg *Config) (string, error) {
+	cp, err := RedactConfig(cfg)
+	if err != nil {
+		return "", fmt.Errorf("hash: %w", err)
+	}
+	cp.Cluster.DataPlane.NodeID = ""
+	cp.Admin.ConfigDrift.InstanceID = ""
+	data, err := yaml.Marshal(cp)
+	if err != nil {
+		return "", fmt.Errorf("hash: marshal failed: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import "testing"
+
+func TestHash_IgnoresKeyOrder(t *testing.T) {
+	a := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    headers:
+      request:
+        set:
+          X-A: "1"
+          X-B: "2"
+`
+	b := `
+routes:
+  - path: /test
+    id: test
+    headers:
+      request:
+        set:
+          X-B: "2"
+          X-A: "1"
+    backends:
+      - url: http://localhost:9000
+listeners:
+  - protocol: "http"
+    id: "http"
+    address: ":8080"
+`
+	cfgA, err := NewLoader().Parse([]byte(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgB, err := NewLoader().Parse([]byte(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ha, err := Hash(cfgA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb, err := Hash(cfgB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ha != hb {
+		t.Errorf("hashes differ for reordered config: %s vs %s", ha, hb)
+	}
+
+	cfgB.Routes[0].Path = "/other"
+	hc, err := Hash(cfgB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hc == ha {
+		t.Error("expected a different hash after changing a route")
+	}
+}
+
+func TestHash_ExcludesSecretsAndIdentity(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Redis.Password = "old-pass"
+	base, err := Hash(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Redis.Password = "redis-pass"
+	cfg.Cluster.DataPlane.NodeID = "node-1"
+	cfg.Admin.ConfigDrift.InstanceID = "runway-1"
+	got, err := Hash(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != base {
+		t.Error("secrets or replica identity changed the hash")
+	}
+	if cfg.Redis.Password != "redis-pass" || cfg.Cluster.DataPlane.NodeID != "node-1" {
+		t.Error("Hash modified the input config")
+	}
+}
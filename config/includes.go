@@ -0,0 +1,193 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// WithConfigDir adds every .yaml and .yml file in dir to the files the
+// loaded config includes, as if listed under includes:.
+func WithConfigDir(dir string) LoaderOption {
+	return func(l *Loader) { l.configDir = dir }
+}
+
+// includeFile is the content allowed in an included file.
+type includeFile struct {
+	Routes    []RouteConfig             `yaml:"routes"`
+	Upstreams map[string]UpstreamConfig `yaml:"upstreams"`
+	Tenants   map[string]TenantConfig   `yaml:"tenants"`
+}
+
+// includeKeys are the top-level keys of includeFile.
+var includeKeys = []string{"routes", "upstreams", "tenants"}
+
+// includePaths returns the files cfg includes in lexical order. Patterns
+// are globs relative to the directory of the main file, base.
+func (l *Loader) includePaths(cfg *Config, base string) ([]string, error) {
+	dir := filepath.Dir(base)
+	if base == "" {
+		dir = "."
+	}
+	patterns := make([]string, 0, len(cfg.Includes)+2)
+	for _, p := range cfg.Includes {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		patterns = append(patterns, p)
+	}
+	if l.configDir != "" {
+		patterns = append(patterns, filepath.Join(l.configDir, "*.yaml"), filepath.Join(l.configDir, "*.yml"))
+		if _, err := os.Stat(l.configDir); err != nil {
+			return nil, fmt.Errorf("config dir: %w", err)
+		}
+	}
+
+	var paths []string
+	for _, p := range patterns {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("include %q: %w", p, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(p, "*?[") {
+			return nil, fmt.Errorf("include %q: file not found", p)
+		}
+		paths = append(paths, matches...)
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+	// A config dir may hold the main file itself.
+	if base != "" {
+		paths = slices.DeleteFunc(paths, func(p string) bool { return sameFile(p, base) })
+	}
+	return paths, nil
+}
+
+// sameFile reports whether paths a and b name the same file.
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
+
+// mergeIncludes appends the routes, upstreams and tenants of the files cfg
+// includes to cfg, in lexical filename order. base is the path of the main
+// file ("" when parsing bytes). A route ID, upstream or tenant defined in
+// two files is an error naming both. cfg.RouteSources records the file
+// each route came from.
+func (l *Loader) mergeIncludes(cfg *Config, base string) error {
+	paths, err := l.includePaths(cfg, base)
+	if err != nil {
+		return err
+	}
+	cfg.Includes = nil
+	if len(paths) == 0 {
+		return nil
+	}
+
+	mainFile := base
+	if mainFile == "" {
+		mainFile = "main config"
+	}
+	routes := make(map[string]string, len(cfg.Routes))
+	upstreams := make(map[string]string, len(cfg.Upstreams))
+	tenants := make(map[string]string, len(cfg.Tenants.Tenants))
+	for _, r := range cfg.Routes {
+		if _, ok := routes[r.ID]; !ok {
+			routes[r.ID] = mainFile
+		}
+	}
+	for name := range cfg.Upstreams {
+		upstreams[name] = mainFile
+	}
+	for id := range cfg.Tenants.Tenants {
+		tenants[id] = mainFile
+	}
+
+	for _, path := range paths {
+		inc, err := l.readInclude(path)
+		if err != nil {
+			return err
+		}
+		for _, r := range inc.Routes {
+			if prev, ok := routes[r.ID]; ok {
+				return fmt.Errorf("duplicate route id %q in %s and %s", r.ID, prev, path)
+			}
+			routes[r.ID] = path
+			cfg.Routes = append(cfg.Routes, r)
+		}
+		for _, name := range sortedKeys(inc.Upstreams) {
+			if prev, ok := upstreams[name]; ok {
+				return fmt.Errorf("duplicate upstream %q in %s and %s", name, prev, path)
+			}
+			upstreams[name] = path
+			if cfg.Upstreams == nil {
+				cfg.Upstreams = make(map[string]UpstreamConfig)
+			}
+			cfg.Upstreams[name] = inc.Upstreams[name]
+		}
+		for _, id := range sortedKeys(inc.Tenants) {
+			if prev, ok := tenants[id]; ok {
+				return fmt.Errorf("duplicate tenant %q in %s and %s", id, prev, path)
+			}
+			tenants[id] = path
+			if cfg.Tenants.Tenants == nil {
+				cfg.Tenants.Tenants = make(map[string]TenantConfig)
+			}
+			cfg.Tenants.Tenants[id] = inc.Tenants[id]
+		}
+	}
+	if base == "" {
+		for id, file := range routes {
+			if file == mainFile {
+				delete(routes, id)
+			}
+		}
+	}
+	cfg.RouteSources = routes
+	return nil
+}
+
+// readInclude reads and env-expands one included file. Only the keys of
+// includeFile are allowed.
+func (l *Loader) readInclude(path string) (*includeFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read included file: %w", err)
+	}
+	expanded := []byte(l.expandEnvVars(string(data)))
+
+	var keys map[string]any
+	if err := yaml.Unmarshal(expanded, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML in %s: %w", path, err)
+	}
+	for _, k := range sortedKeys(keys) {
+		if !slices.Contains(includeKeys, k) {
+			return nil, fmt.Errorf("%s: %q is not allowed in an included file (only %s)", path, k, strings.Join(includeKeys, ", "))
+		}
+	}
+	var inc includeFile
+	if err := yaml.Unmarshal(expanded, &inc); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML in %s: %w", path, err)
+	}
+	return &inc, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const includesMain = `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+includes:
+  - routes/*.yaml
+routes:
+  - id: health
+    path: /health
+    echo: true
+`
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoaderIncludes(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("INCLUDE_TEST_BACKEND", "http://localhost:9001")
+	writeFiles(t, dir, map[string]string{
+		"runway.yaml": includesMain,
+		"routes/b-orders.yaml": `
+routes:
+  - id: orders
+    path: /orders
+    upstream: orders
+upstreams:
+  orders:
+    backends:
+      - url: http://localhost:9002
+`,
+		"routes/a-users.yaml": `
+routes:
+  - id: users
+    path: /users
+    backends:
+      - url: ${INCLUDE_TEST_BACKEND}
+`,
+		"routes/notes.txt": "not yaml",
+	})
+
+	main := filepath.Join(dir, "runway.yaml")
+	cfg, err := NewLoader().Load(main)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var ids []string
+	for _, r := range cfg.Routes {
+		ids = append(ids, r.ID)
+	}
+	if strings.Join(ids, ",") != "health,users,orders" {
+		t.Errorf("routes = %v, want main file first, then includes in filename order", ids)
+	}
+	if got := cfg.Routes[1].Backends[0].URL; got != "http://localhost:9001" {
+		t.Errorf("expected env expansion in included files, got %q", got)
+	}
+	if _, ok := cfg.Upstreams["orders"]; !ok {
+		t.Error("expected the included upstream")
+	}
+	if cfg.Includes != nil {
+		t.Errorf("expected includes cleared after merging, got %v", cfg.Includes)
+	}
+
+	want := map[string]string{
+		"health": main,
+		"users":  filepath.Join(dir, "routes/a-users.yaml"),
+		"orders": filepath.Join(dir, "routes/b-orders.yaml"),
+	}
+	for id, file := range want {
+		if cfg.RouteSources[id] != file {
+			t.Errorf("source of %s = %q, want %q", id, cfg.RouteSources[id], file)
+		}
+	}
+}
+
+func TestLoaderConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"runway.yaml": `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+`,
+		"conf.d/10-users.yml": `
+routes:
+  - id: users
+    path: /users
+    echo: true
+`,
+		"conf.d/20-tenants.yaml": `
+tenants:
+  acme:
+    routes: [users]
+`,
+	})
+
+	confDir := filepath.Join(dir, "conf.d")
+	cfg, err := NewLoader(WithConfigDir(confDir)).Load(filepath.Join(dir, "runway.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Routes) != 1 || cfg.Routes[0].ID != "users" {
+		t.Errorf("expected the users route from the config dir, got %+v", cfg.Routes)
+	}
+	if _, ok := cfg.Tenants.Tenants["acme"]; !ok {
+		t.Error("expected the included tenant")
+	}
+	if cfg.ConfigDir != confDir {
+		t.Errorf("ConfigDir = %q, want %q", cfg.ConfigDir, confDir)
+	}
+}
+
+func TestLoaderIncludesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		errMsg string
+	}{
+		{
+			name: "duplicate route across files",
+			files: map[string]string{
+				"routes/a.yaml": "routes:\n  - id: users\n    path: /a\n    echo: true\n",
+				"routes/b.yaml": "routes:\n  - id: users\n    path: /b\n    echo: true\n",
+			},
+			errMsg: `duplicate route id "users" in %s/routes/a.yaml and %s/routes/b.yaml`,
+		},
+		{
+			name: "duplicate of a main file route",
+			files: map[string]string{
+				"routes/a.yaml": "routes:\n  - id: health\n    path: /a\n    echo: true\n",
+			},
+			errMsg: `duplicate route id "health" in %s/runway.yaml and %s/routes/a.yaml`,
+		},
+		{
+			name: "duplicate upstream",
+			files: map[string]string{
+				"routes/a.yaml": "upstreams:\n  pool:\n    backends:\n      - url: http://a\n",
+				"routes/b.yaml": "upstreams:\n  pool:\n    backends:\n      - url: http://b\n",
+			},
+			errMsg: `duplicate upstream "pool" in %s/routes/a.yaml and %s/routes/b.yaml`,
+		},
+		{
+			name: "disallowed key",
+			files: map[string]string{
+				"routes/a.yaml": "listeners: []\n",
+			},
+			errMsg: `"listeners" is not allowed in an included file`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.files["runway.yaml"] = includesMain
+			writeFiles(t, dir, tt.files)
+
+			_, err := NewLoader().Load(filepath.Join(dir, "runway.yaml"))
+			want := strings.ReplaceAll(tt.errMsg, "%s", dir)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected error containing %q, got %q", want, err.Error())
+			}
+		})
+	}
+
+	_, err := NewLoader().Parse([]byte(includesMain + "  - id: extra\n    path: /extra\n    echo: true\n" + "\n"))
+	if err != nil {
+		// routes/*.yaml matches nothing relative to the working directory
+		t.Errorf("unexpected error for a glob matching nothing: %v", err)
+	}
+	_, err = NewLoader().Parse([]byte("includes: [missing.yaml]\nlisteners:\n  - id: http\n    address: \":8080\"\n    protocol: http\n"))
+	if err == nil || !strings.Contains(err.Error(), `include "missing.yaml": file not found`) {
+		t.Errorf("expected a missing include to fail, got %v", err)
+	}
+}
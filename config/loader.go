@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
+	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/goccy/go-yaml"
 	"github.com/getkin/kin-openapi/openapi3"
@@ -30,8 +33,10 @@ func WithSecretRegistry(r *SecretRegistry) LoaderOption {
 
 // Loader handles configuration loading and parsing
 type Loader struct {
-	envPattern *regexp.Regexp
-	registry   *SecretRegistry
+	envPattern     *regexp.Regexp
+	registry       *SecretRegistry
+	configDir      string
+	artifactClient *http.Client
 }
 
 // NewLoader creates a new configuration loader.
@@ -50,18 +55,25 @@ func NewLoader(opts ...LoaderOption) *Loader {
 	return l
 }
 
-// Load reads and parses a configuration file
+// Load reads and parses a configuration file. Includes are resolved
+// relative to the file's directory.
 func (l *Loader) Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	return l.Parse(data)
+	return l.parse(data, path)
 }
 
-// Parse parses configuration from YAML bytes
+// Parse parses configuration from YAML bytes. Includes are resolved
+// relative to the working directory.
 func (l *Loader) Parse(data []byte) (*Config, error) {
+	return l.parse(data, "")
+}
+
+// parse parses configuration from YAML bytes read from path.
+func (l *Loader) parse(data []byte, path string) (*Config, error) {
 	// Phase 1: Bare ${VAR} expansion. Does NOT match ${scheme:ref} (colon not in char class).
 	expanded := l.expandEnvVars(string(data))
 
@@ -71,24 +83,75 @@ func (l *Loader) Parse(data []byte) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
 
+	// Phase 2b: Merge routes, upstreams and tenants from included files
+	if len(cfg.Includes) > 0 || l.configDir != "" {
+		if err := l.mergeIncludes(cfg, path); err != nil {
+			return nil, fmt.Errorf("config includes: %w", err)
+		}
+	}
+	cfg.ConfigDir = l.configDir
+
 	// Phase 3: Resolve secret references in struct fields
 	if err := l.resolveSecrets(cfg); err != nil {
 		return nil, fmt.Errorf("secret resolution failed: %w", err)
 	}
 
+	// Phase 3b: Fetch remote artifacts; spec expansion reads the cached copies
+	if err := l.resolveArtifacts(cfg); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: artifacts: %w", err)
+	}
+
 	// Phase 4: Expand OpenAPI spec routes before validation
 	if err := expandOpenAPIRoutes(cfg); err != nil {
 		return nil, fmt.Errorf("openapi route expansion: %w", err)
 	}
 
-	// Phase 5: Validate configuration
+	// Phase 5: Translate deprecated fields to their replacements
+	cfg.DeprecationWarnings = migrateDeprecations(cfg)
+	if cfg.StrictDeprecations && len(cfg.DeprecationWarnings) > 0 {
+		return nil, deprecationError(cfg.DeprecationWarnings)
+	}
+
+	// Phase 5b: Apply the global IPv6 aggregation prefix to client IP keys
+	applyIPv6Aggregation(cfg)
+
+	// Phase 6: Validate configuration
 	if err := l.validate(cfg); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
 
+	// Phase 7: Collect conflicting response body stages
+	cfg.ResponsePipelineWarnings = responsePipelineWarnings(cfg)
+
+	// Phase 7b: Collect high-cardinality cache keys
+	cfg.CacheKeyWarnings = cacheKeyWarnings(cfg)
+
 	return cfg, nil
 }
 
+// applyIPv6Aggregation copies the top-level ipv6_aggregation_prefix into the
+// client-keyed features that leave their own unset.
+func applyIPv6Aggregation(cfg *Config) {
+	bits := cfg.IPv6AggregationPrefix
+	if bits == 0 {
+		return
+	}
+	setDefault := func(v *int) {
+		if *v == 0 {
+			*v = bits
+		}
+	}
+	setDefault(&cfg.SpikeArrest.IPv6AggregationPrefix)
+	setDefault(&cfg.Reputation.IPv6AggregationPrefix)
+	for i := range cfg.Routes {
+		rc := &cfg.Routes[i]
+		setDefault(&rc.RateLimit.IPv6AggregationPrefix)
+		setDefault(&rc.SpikeArrest.IPv6AggregationPrefix)
+		setDefault(&rc.Quota.IPv6AggregationPrefix)
+		setDefault(&rc.BandwidthQuota.IPv6AggregationPrefix)
+	}
+}
+
 // resolveSecrets creates a per-parse registry with env and file providers,
 // then walks the config resolving ${scheme:ref} strings in place.
 func (l *Loader) resolveSecrets(cfg *Config) error {
@@ -97,7 +160,23 @@ func (l *Loader) resolveSecrets(cfg *Config) error {
 	reg.Register(&FileProvider{AllowedPrefixes: cfg.Secrets.File.AllowedPrefixes})
 	// TODO: pass shared registry to reload paths when stateful providers (Vault, AWS SM) are added
 	ctx := context.Background()
-	return resolveSecretRefs(cfg, reg, ctx)
+	refs := make(map[string]string) // field path -> reference
+	walkStructStrings(reflect.ValueOf(cfg), "", func(field reflect.Value, path string, _ reflect.StructTag) {
+		if secretRefPattern.MatchString(field.String()) {
+			refs[path] = field.String()
+		}
+	})
+	if err := resolveSecretRefs(cfg, reg, ctx); err != nil {
+		return err
+	}
+	cfg.SecretRegistry = reg
+	cfg.SecretRefs = make(map[string]string, len(refs))
+	walkStructStrings(reflect.ValueOf(cfg), "", func(field reflect.Value, path string, _ reflect.StructTag) {
+		if ref, ok := refs[path]; ok && field.String() != ref {
+			cfg.SecretRefs[field.String()] = ref
+		}
+	})
+	return nil
 }
 
 // expandEnvVars replaces ${VAR_NAME} with environment variable values
@@ -184,18 +263,56 @@ func (l *Loader) validate(cfg *Config) error {
 				}
 			}
 		}
+		if listener.TLS.Reload.Interval < 0 {
+			return fmt.Errorf("listener %s: tls.reload.interval must be >= 0", listener.ID)
+		}
+		if listener.TLS.Reload.Enabled && listener.TLS.ACME.Enabled {
+			return fmt.Errorf("listener %s: tls.reload does not apply to acme certificates, which renew themselves", listener.ID)
+		}
 		if listener.HTTP.EnableHTTP3 && !listener.TLS.Enabled {
 			return fmt.Errorf("listener %s: enable_http3 requires tls.enabled", listener.ID)
 		}
+		for j, sh := range []StreamHardeningConfig{listener.HTTP.HTTP2, listener.HTTP.HTTP3} {
+			if sh.MaxConcurrentStreams < 0 || sh.MaxHeaderListSize < 0 || sh.MaxResets < 0 || sh.MaxRequests < 0 || sh.Window < 0 {
+				return fmt.Errorf("listener %s: http.http%d limits must be >= 0", listener.ID, j+2)
+			}
+		}
 	}
 
 	// === Global simple configs ===
 	if cfg.ServiceRateLimit.Enabled && cfg.ServiceRateLimit.Rate <= 0 {
 		return fmt.Errorf("service_rate_limit: rate must be > 0 when enabled")
 	}
+	if rs := cfg.RateLimitState; rs.Enabled {
+		switch rs.Store {
+		case "", "file":
+			if rs.File == "" {
+				return fmt.Errorf("rate_limit_state: file is required for the file store")
+			}
+		case "redis":
+			if cfg.Redis.Address == "" {
+				return fmt.Errorf("rate_limit_state: store \"redis\" requires redis.address to be configured")
+			}
+		default:
+			return fmt.Errorf("rate_limit_state: store must be \"file\" or \"redis\"")
+		}
+		if rs.SaveInterval < 0 || rs.ActiveWithin < 0 || rs.MaxKeysPerRoute < 0 {
+			return fmt.Errorf("rate_limit_state: save_interval, active_within and max_keys_per_route must be >= 0")
+		}
+	}
 	if cfg.DebugEndpoint.Enabled && cfg.DebugEndpoint.Path != "" && !strings.HasPrefix(cfg.DebugEndpoint.Path, "/") {
 		return fmt.Errorf("debug_endpoint: path must start with /")
 	}
+	if tm := cfg.TestMode; tm.Enabled {
+		if os.Getenv(TestModeEnv) != "true" {
+			return fmt.Errorf("test_mode: refused unless %s=true is set in the environment; never enable it in production", TestModeEnv)
+		}
+		if tm.StartTime != "" {
+			if _, err := time.Parse(time.RFC3339, tm.StartTime); err != nil {
+				return fmt.Errorf("test_mode: start_time must be an RFC 3339 time: %w", err)
+			}
+		}
+	}
 	if cfg.Logging.Rotation.MaxSize < 0 {
 		return fmt.Errorf("logging.rotation.max_size must be >= 0")
 	}
@@ -205,6 +322,14 @@ func (l *Loader) validate(cfg *Config) error {
 	if cfg.Logging.Rotation.MaxAge < 0 {
 		return fmt.Errorf("logging.rotation.max_age must be >= 0")
 	}
+	if len(cfg.Logging.Fields) > 0 {
+		if cfg.Logging.Format != AccessLogFormatJSON {
+			return fmt.Errorf("logging.fields requires logging.format json")
+		}
+		if err := validateAccessLogFields(cfg.Logging.Fields); err != nil {
+			return fmt.Errorf("logging.fields: %w", err)
+		}
+	}
 
 	// === Load shedding ===
 	if cfg.LoadShedding.Enabled {
@@ -219,6 +344,105 @@ func (l *Loader) validate(cfg *Config) error {
 		}
 	}
 
+	// === Warm-up ===
+	if cfg.Warmup.Enabled {
+		if cfg.Warmup.InitialWeight < 0 || cfg.Warmup.InitialWeight > 100 {
+			return fmt.Errorf("warmup: initial_weight must be between 0 and 100")
+		}
+		if cfg.Warmup.Duration < 0 {
+			return fmt.Errorf("warmup: duration must be >= 0")
+		}
+		if cfg.Warmup.RetryAfter < 0 {
+			return fmt.Errorf("warmup: retry_after must be >= 0")
+		}
+		if cfg.Warmup.ReloadThreshold < 0 || cfg.Warmup.ReloadThreshold > 1.0 {
+			return fmt.Errorf("warmup: reload_threshold must be between 0.0 and 1.0")
+		}
+	}
+
+	// === Dependency health ===
+	if dh := cfg.Admin.DependencyHealth; dh.Enabled {
+		if dh.Interval < 0 || dh.Timeout < 0 {
+			return fmt.Errorf("admin.dependency_health: interval and timeout must be >= 0")
+		}
+		for name, cc := range dh.Checks {
+			if cc.Interval < 0 || cc.Timeout < 0 {
+				return fmt.Errorf("admin.dependency_health.checks.%s: interval and timeout must be >= 0", name)
+			}
+		}
+	}
+
+	// === Backend TLS scan ===
+	if ts := cfg.Admin.BackendTLSScan; ts.Enabled {
+		if ts.Interval < 0 || ts.Timeout < 0 || ts.ExpiryThreshold < 0 {
+			return fmt.Errorf("admin.backend_tls_scan: interval, timeout and expiry_threshold must be >= 0")
+		}
+		if ts.Rate < 0 {
+			return fmt.Errorf("admin.backend_tls_scan: rate must be >= 0")
+		}
+	}
+
+	if cfg.Admin.Metrics.PluginMaxSeries < 0 {
+		return fmt.Errorf("admin.metrics: plugin_max_series must be >= 0")
+	}
+	if err := validateMetricsConfig(cfg.Admin.Metrics); err != nil {
+		return err
+	}
+
+	// === Config drift ===
+	if cd := cfg.Admin.ConfigDrift; cd.Enabled {
+		if cfg.Redis.Address == "" {
+			return fmt.Errorf("admin.config_drift: requires redis.address to be configured")
+		}
+		if cd.Interval < 0 || cd.GracePeriod < 0 {
+			return fmt.Errorf("admin.config_drift: interval and grace_period must be >= 0")
+		}
+	}
+
+	// === Config snapshots ===
+	if cs := cfg.Admin.ConfigSnapshots; cs.Enabled {
+		switch cs.Store {
+		case "", "file":
+			if cs.Dir == "" {
+				return fmt.Errorf("admin.config_snapshots: dir is required for the file store")
+			}
+		case "redis":
+			if cfg.Redis.Address == "" {
+				return fmt.Errorf("admin.config_snapshots: store \"redis\" requires redis.address to be configured")
+			}
+		default:
+			return fmt.Errorf("admin.config_snapshots: store must be \"file\" or \"redis\"")
+		}
+		if cs.Secrets != "" && cs.Secrets != SecretsReference && cs.Secrets != SecretsMask {
+			return fmt.Errorf("admin.config_snapshots: secrets must be \"reference\" or \"mask\"")
+		}
+		if cs.Retention < 0 || cs.Interval < 0 {
+			return fmt.Errorf("admin.config_snapshots: retention and interval must be >= 0")
+		}
+	}
+
+	// === Upstream swap ===
+	swap := cfg.Admin.UpstreamSwap
+	if swap.RollbackWindow < 0 || swap.Verify.Timeout < 0 || swap.Verify.Count < 0 {
+		return fmt.Errorf("admin.upstream_swap: rollback_window, verify.timeout and verify.count must be >= 0")
+	}
+	if swap.Verify.ExpectedStatus != 0 && (swap.Verify.ExpectedStatus < 100 || swap.Verify.ExpectedStatus > 599) {
+		return fmt.Errorf("admin.upstream_swap: verify.expected_status must be between 100 and 599")
+	}
+
+	// === Feature flags ===
+	if cfg.FeatureFlags.Enabled {
+		if cfg.FeatureFlags.Backend != "consul" && cfg.FeatureFlags.Backend != "etcd" {
+			return fmt.Errorf("feature_flags: backend must be \"consul\" or \"etcd\"")
+		}
+		if cfg.FeatureFlags.RetryInterval < 0 || cfg.FeatureFlags.MaxRetryInterval < 0 {
+			return fmt.Errorf("feature_flags: retry_interval and max_retry_interval must be >= 0")
+		}
+		if cfg.FeatureFlags.MaxRetryInterval > 0 && cfg.FeatureFlags.RetryInterval > cfg.FeatureFlags.MaxRetryInterval {
+			return fmt.Errorf("feature_flags: retry_interval must not exceed max_retry_interval")
+		}
+	}
+
 	// === Global audit log ===
 	if cfg.AuditLog.Enabled {
 		if cfg.AuditLog.WebhookURL == "" {
@@ -237,6 +461,53 @@ func (l *Loader) validate(cfg *Config) error {
 		if pool.MinRetries < 0 {
 			return fmt.Errorf("retry_budgets[%s]: min_retries must be >= 0", name)
 		}
+		switch pool.Mode {
+		case "", "local":
+			// valid
+		case "distributed":
+			if cfg.Redis.Address == "" {
+				return fmt.Errorf("retry_budgets[%s]: distributed mode requires redis configuration", name)
+			}
+		default:
+			return fmt.Errorf("retry_budgets[%s]: mode must be \"local\" or \"distributed\", got %q", name, pool.Mode)
+		}
+		if pool.SyncInterval < 0 {
+			return fmt.Errorf("retry_budgets[%s]: sync_interval must be >= 0", name)
+		}
+	}
+
+	// === Shared auth definitions ===
+	for name, def := range cfg.ExtAuthServices {
+		if def.Ref != "" {
+			return fmt.Errorf("ext_auth_services[%s]: ref is not allowed in a shared definition", name)
+		}
+		if err := validateExtAuthConfig(fmt.Sprintf("ext_auth_services[%s]", name), def); err != nil {
+			return err
+		}
+	}
+	for name, def := range cfg.OPAPolicies {
+		if def.Ref != "" {
+			return fmt.Errorf("opa_policies[%s]: ref is not allowed in a shared definition", name)
+		}
+		if err := validateOPAConfig(fmt.Sprintf("opa_policies[%s]", name), def); err != nil {
+			return err
+		}
+	}
+	for name, def := range cfg.BackendAuthProviders {
+		if def.Ref != "" {
+			return fmt.Errorf("backend_auth_providers[%s]: ref is not allowed in a shared definition", name)
+		}
+		if err := validateBackendAuthConfig(fmt.Sprintf("backend_auth_providers[%s]", name), def); err != nil {
+			return err
+		}
+	}
+	for name, def := range cfg.TokenExchangers {
+		if def.Ref != "" {
+			return fmt.Errorf("token_exchangers[%s]: ref is not allowed in a shared definition", name)
+		}
+		if err := validateTokenExchangeDef(fmt.Sprintf("token_exchangers[%s]", name), def); err != nil {
+			return err
+		}
 	}
 
 	// === API Key Management ===
@@ -266,11 +537,54 @@ func (l *Loader) validate(cfg *Config) error {
 				return fmt.Errorf("authentication.api_key.management.default_rate_limit.burst must be > 0")
 			}
 		}
+		if ss := mgmt.SelfService; ss.Enabled {
+			if !cfg.Authentication.JWT.Enabled {
+				return fmt.Errorf("authentication.api_key.management.self_service requires authentication.jwt.enabled")
+			}
+			if ss.PathPrefix != "" && (!strings.HasPrefix(ss.PathPrefix, "/") || ss.PathPrefix == "/") {
+				return fmt.Errorf("authentication.api_key.management.self_service.path_prefix must start with / and not be the root path")
+			}
+			if err := validateSelfServiceScope(ss, cfg); err != nil {
+				return err
+			}
+			if ss.MaxKeys < 0 {
+				return fmt.Errorf("authentication.api_key.management.self_service.max_keys must be >= 0")
+			}
+			if ss.MaxTTL < 0 {
+				return fmt.Errorf("authentication.api_key.management.self_service.max_ttl must be >= 0")
+			}
+			if ss.GracePeriod < 0 {
+				return fmt.Errorf("authentication.api_key.management.self_service.grace_period must be >= 0")
+			}
+			if ss.CreateRateLimit != nil {
+				if ss.CreateRateLimit.Rate <= 0 {
+					return fmt.Errorf("authentication.api_key.management.self_service.create_rate_limit.rate must be > 0")
+				}
+				if ss.CreateRateLimit.Period <= 0 {
+					return fmt.Errorf("authentication.api_key.management.self_service.create_rate_limit.period must be > 0")
+				}
+				if ss.CreateRateLimit.Burst <= 0 {
+					return fmt.Errorf("authentication.api_key.management.self_service.create_rate_limit.burst must be > 0")
+				}
+			}
+			if ss.ListRateLimit != nil {
+				if ss.ListRateLimit.Rate <= 0 {
+					return fmt.Errorf("authentication.api_key.management.self_service.list_rate_limit.rate must be > 0")
+				}
+				if ss.ListRateLimit.Period <= 0 {
+					return fmt.Errorf("authentication.api_key.management.self_service.list_rate_limit.period must be > 0")
+				}
+				if ss.ListRateLimit.Burst <= 0 {
+					return fmt.Errorf("authentication.api_key.management.self_service.list_rate_limit.burst must be > 0")
+				}
+			}
+		}
 	}
 
 	// === Routes (single loop via validateRoute) ===
 	// DP mode: routes may be empty (they come from the control plane)
 	routeIDs := make(map[string]bool)
+	oidcPaths := make(map[string]string) // OIDC callback/logout path -> route ID
 	for i, route := range cfg.Routes {
 		if route.ID == "" {
 			return fmt.Errorf("route %d: id is required", i)
@@ -285,6 +599,21 @@ func (l *Loader) validate(cfg *Config) error {
 		if err := l.validateRoute(route, cfg); err != nil {
 			return err
 		}
+		if route.OIDC.Enabled {
+			callback, logout := route.OIDC.CallbackPath, route.OIDC.LogoutPath
+			if callback == "" {
+				callback = "/oauth2/callback"
+			}
+			if logout == "" {
+				logout = "/oauth2/logout"
+			}
+			for _, p := range []string{callback, logout} {
+				if other, ok := oidcPaths[p]; ok {
+					return fmt.Errorf("route %s: oidc path %s is already used by route %s", route.ID, p, other)
+				}
+				oidcPaths[p] = route.ID
+			}
+		}
 	}
 
 	// === Tenants ===
@@ -310,6 +639,14 @@ func (l *Loader) validate(cfg *Config) error {
 				return fmt.Errorf("consumer_groups.groups[%s]: priority must be 0-10", name)
 			}
 		}
+		for clientID, name := range cfg.ConsumerGroups.Clients {
+			if clientID == "" {
+				return fmt.Errorf("consumer_groups.clients: client ID must be non-empty")
+			}
+			if _, ok := cfg.ConsumerGroups.Groups[name]; !ok {
+				return fmt.Errorf("consumer_groups.clients[%s]: unknown group %q", clientID, name)
+			}
+		}
 	}
 
 	for _, route := range cfg.Routes {
@@ -374,10 +711,20 @@ func (l *Loader) validate(cfg *Config) error {
 
 	// === JWT ===
 	if cfg.Authentication.JWT.Enabled {
-		if cfg.Authentication.JWT.Secret == "" && cfg.Authentication.JWT.PublicKey == "" && cfg.Authentication.JWT.JWKSURL == "" {
+		jwtCfg := cfg.Authentication.JWT
+		if err := validateJWKSEndpoints("authentication.jwt", jwtCfg.JWKSURL, jwtCfg.JWKSURLs, jwtCfg.JWKSFetchTimeout); err != nil {
+			return err
+		}
+		if jwtCfg.Secret == "" && jwtCfg.PublicKey == "" && len(jwtCfg.JWKSEndpoints()) == 0 {
 			return fmt.Errorf("JWT authentication enabled but no secret, public key, or JWKS URL provided")
 		}
 	}
+	if cfg.Authentication.OAuth.Enabled {
+		oauthCfg := cfg.Authentication.OAuth
+		if err := validateJWKSEndpoints("authentication.oauth", oauthCfg.JWKSURL, oauthCfg.JWKSURLs, oauthCfg.JWKSFetchTimeout); err != nil {
+			return err
+		}
+	}
 
 	// === Basic Auth ===
 	if cfg.Authentication.Basic.Enabled {
@@ -520,12 +867,24 @@ func (l *Loader) validate(cfg *Config) error {
 			return fmt.Errorf("global WAF mode must be 'block' or 'detect'")
 		}
 	}
+	if err := validateXMLLimits(cfg.XMLLimits); err != nil {
+		return err
+	}
+	if err := validateRouteMetadataConfig(cfg.RouteMetadata); err != nil {
+		return err
+	}
+	if err := validateStorageEncryption(cfg); err != nil {
+		return err
+	}
 	if err := l.validateHealthCheck("global", cfg.HealthCheck); err != nil {
 		return err
 	}
 	if err := l.validateErrorPages("global", cfg.ErrorPages); err != nil {
 		return err
 	}
+	if err := validateErrorsConfig("global", cfg.Errors); err != nil {
+		return err
+	}
 	if err := l.validateNonceConfig("global", cfg.Nonce, cfg.Redis.Address); err != nil {
 		return err
 	}
@@ -559,18 +918,27 @@ func (l *Loader) validate(cfg *Config) error {
 	if err := l.validateResponseLimitConfig("global", cfg.ResponseLimit); err != nil {
 		return err
 	}
+	if err := l.validateResponseBufferingConfig("global", cfg.ResponseBuffering); err != nil {
+		return err
+	}
 	if err := l.validateSecurityHeadersConfig("global", cfg.SecurityHeaders); err != nil {
 		return err
 	}
 	if err := l.validateMaintenanceConfig("global", cfg.Maintenance); err != nil {
 		return err
 	}
+	if err := l.validateSyntheticMonitoringConfig("global", cfg.SyntheticMonitoring); err != nil {
+		return err
+	}
 	if err := l.validateShutdownConfig(cfg.Shutdown); err != nil {
 		return err
 	}
 	if err := l.validateTrustedProxiesConfig(cfg.TrustedProxies); err != nil {
 		return err
 	}
+	if err := validateIPv6AggregationPrefix("ipv6_aggregation_prefix", cfg.IPv6AggregationPrefix); err != nil {
+		return err
+	}
 	if err := l.validateTransportConfig("global", cfg.Transport); err != nil {
 		return err
 	}
@@ -589,6 +957,12 @@ func (l *Loader) validate(cfg *Config) error {
 			return err
 		}
 	}
+	if err := validateSpikeArrestMode(cfg.SpikeArrest.Mode); err != nil {
+		return fmt.Errorf("global: %w", err)
+	}
+	if err := validateIPv6AggregationPrefix("spike_arrest.ipv6_aggregation_prefix", cfg.SpikeArrest.IPv6AggregationPrefix); err != nil {
+		return fmt.Errorf("global: %w", err)
+	}
 	if err := l.validateClientMTLSConfig("global", cfg.ClientMTLS); err != nil {
 		return err
 	}
@@ -627,11 +1001,33 @@ func (l *Loader) validate(cfg *Config) error {
 		return err
 	}
 
+	// === Response pipeline conflicts (global) ===
+	switch cfg.ResponsePipelineConflicts {
+	case "", "warn", "error", "off":
+	default:
+		return fmt.Errorf("response_pipeline_conflicts must be \"warn\", \"error\" or \"off\", got %q", cfg.ResponsePipelineConflicts)
+	}
+
+	// === Egress policy (global) ===
+	if err := l.validateEgressPolicy(cfg); err != nil {
+		return err
+	}
+
 	// === IP blocklist (global) ===
 	if err := l.validateIPBlocklistConfig("global", cfg.IPBlocklist); err != nil {
 		return err
 	}
 
+	// === Client IP reputation ===
+	if err := l.validateReputationConfig(cfg); err != nil {
+		return err
+	}
+
+	// === Peer failover ===
+	if err := l.validatePeerFailoverConfig(cfg.PeerFailover); err != nil {
+		return err
+	}
+
 	// === Webhooks ===
 	if err := l.validateWebhooks(cfg.Webhooks); err != nil {
 		return err
@@ -658,6 +1054,100 @@ func (l *Loader) validate(cfg *Config) error {
 	return nil
 }
 
+// reputationSignals are the signal names accepted in reputation.weights.
+var reputationSignals = map[string]bool{"waf": true, "auth_failure": true, "rate_limit": true, "bot": true}
+
+// validateReputationConfig validates client IP reputation scoring.
+func (l *Loader) validateReputationConfig(cfg *Config) error {
+	rc := cfg.Reputation
+	if !rc.Enabled {
+		return nil
+	}
+	for name, w := range rc.Weights {
+		if !reputationSignals[name] {
+			return fmt.Errorf("reputation.weights: unknown signal %q (must be waf, auth_failure, rate_limit or bot)", name)
+		}
+		if w < 0 {
+			return fmt.Errorf("reputation.weights.%s must be >= 0", name)
+		}
+	}
+	if rc.Threshold < 0 {
+		return fmt.Errorf("reputation.threshold must be >= 0")
+	}
+	if rc.HalfLife < 0 {
+		return fmt.Errorf("reputation.half_life must be >= 0")
+	}
+	for i, d := range rc.BlockDurations {
+		if d <= 0 {
+			return fmt.Errorf("reputation.block_durations[%d] must be > 0", i)
+		}
+	}
+	for i, entry := range rc.ExemptCIDRs {
+		if ip := net.ParseIP(entry); ip == nil {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return fmt.Errorf("reputation.exempt_cidrs[%d]: %q is not a valid IP or CIDR", i, entry)
+			}
+		}
+	}
+	if err := validateIPv6AggregationPrefix("reputation.ipv6_aggregation_prefix", rc.IPv6AggregationPrefix); err != nil {
+		return err
+	}
+	if rc.MaxEntries < 0 {
+		return fmt.Errorf("reputation.max_entries must be >= 0")
+	}
+	if rc.HistorySize < 0 {
+		return fmt.Errorf("reputation.history_size must be >= 0")
+	}
+	switch rc.Store {
+	case "", "memory":
+		// valid
+	case "redis":
+		if cfg.Redis.Address == "" {
+			return fmt.Errorf("reputation.store \"redis\" requires redis.address")
+		}
+	default:
+		return fmt.Errorf("reputation.store must be \"memory\" or \"redis\", got %q", rc.Store)
+	}
+	return nil
+}
+
+// validatePeerFailoverConfig validates the peer gateways routes fail over to.
+func (l *Loader) validatePeerFailoverConfig(pc PeerFailoverConfig) error {
+	if len(pc.Peers) == 0 {
+		return nil
+	}
+	if pc.Secret == "" {
+		return fmt.Errorf("peer_failover.secret is required when peers are configured")
+	}
+	if pc.Timeout < 0 {
+		return fmt.Errorf("peer_failover.timeout must be >= 0")
+	}
+	seen := make(map[string]bool, len(pc.Peers))
+	for i, p := range pc.Peers {
+		if p.Name == "" {
+			return fmt.Errorf("peer_failover.peers[%d]: name is required", i)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("peer_failover.peers[%d]: duplicate name %q", i, p.Name)
+		}
+		seen[p.Name] = true
+		if !strings.HasPrefix(p.URL, "http://") && !strings.HasPrefix(p.URL, "https://") {
+			return fmt.Errorf("peer_failover.peers[%s]: url must start with http:// or https://", p.Name)
+		}
+		if p.Timeout < 0 {
+			return fmt.Errorf("peer_failover.peers[%s]: timeout must be >= 0", p.Name)
+		}
+		if (p.TLS.CertFile == "") != (p.TLS.KeyFile == "") {
+			return fmt.Errorf("peer_failover.peers[%s]: tls.cert_file and tls.key_file must be set together", p.Name)
+		}
+		cb := p.CircuitBreaker
+		if cb.FailureThreshold < 0 || cb.MaxRequests < 0 || cb.Timeout < 0 {
+			return fmt.Errorf("peer_failover.peers[%s]: circuit_breaker values must be >= 0", p.Name)
+		}
+	}
+	return nil
+}
+
 // validateCluster validates cluster mode configuration.
 func (l *Loader) validateCluster(cfg *Config) error {
 	role := cfg.Cluster.Role
@@ -709,17 +1199,27 @@ func (l *Loader) validateCluster(cfg *Config) error {
 func (l *Loader) validateUpstreams(cfg *Config) error {
 	validLBs := map[string]bool{
 		"": true, "round_robin": true, "least_conn": true,
-		"consistent_hash": true, "least_response_time": true,
+		"consistent_hash": true, "least_response_time": true, "ewma": true,
 	}
 	for name, us := range cfg.Upstreams {
-		if len(us.Backends) == 0 && us.Service.Name == "" {
+		if dd := us.DNSDiscovery; dd.Enabled {
+			if err := l.validateUpstreamDNSDiscovery(name, us); err != nil {
+				return err
+			}
+		} else if len(us.Backends) == 0 && us.Service.Name == "" {
 			return fmt.Errorf("upstream %s: must have either backends or service name", name)
 		}
 		if len(us.Backends) > 0 && us.Service.Name != "" {
 			return fmt.Errorf("upstream %s: backends and service are mutually exclusive", name)
 		}
+		if err := validateServiceDiscovery("upstream "+name, us.Service, cfg); err != nil {
+			return err
+		}
 		if !validLBs[us.LoadBalancer] {
-			return fmt.Errorf("upstream %s: load_balancer must be round_robin, least_conn, consistent_hash, or least_response_time", name)
+			return fmt.Errorf("upstream %s: load_balancer must be round_robin, least_conn, consistent_hash, least_response_time, or ewma", name)
+		}
+		if us.EWMA.DecayTime < 0 || us.EWMA.NoDataPenalty < 0 {
+			return fmt.Errorf("upstream %s: ewma.decay_time and ewma.no_data_penalty must be >= 0", name)
 		}
 		if us.LoadBalancer == "consistent_hash" {
 			validKeys := map[string]bool{"header": true, "cookie": true, "path": true, "ip": true}
@@ -742,10 +1242,89 @@ func (l *Loader) validateUpstreams(cfg *Config) error {
 				}
 			}
 		}
+		if err := l.validateTransportCanary(cfg, name, us.TransportCanary); err != nil {
+			return err
+		}
+		if us.MaxUpstreamStreams < 0 {
+			return fmt.Errorf("upstream %s: max_upstream_streams must be >= 0", name)
+		}
 	}
 	return nil
 }
 
+// validateUpstreamDNSDiscovery validates an upstream's dns_discovery block.
+func (l *Loader) validateUpstreamDNSDiscovery(name string, us UpstreamConfig) error {
+	dd := us.DNSDiscovery
+	if dd.Name == "" {
+		return fmt.Errorf("upstream %s: dns_discovery.name is required", name)
+	}
+	if len(us.Backends) > 0 || us.Service.Name != "" {
+		return fmt.Errorf("upstream %s: dns_discovery is mutually exclusive with backends and service", name)
+	}
+	if dd.Protocol != "" && dd.Protocol != "tcp" && dd.Protocol != "udp" {
+		return fmt.Errorf("upstream %s: dns_discovery.protocol must be \"tcp\" or \"udp\"", name)
+	}
+	if dd.Scheme != "" && dd.Scheme != "http" && dd.Scheme != "https" {
+		return fmt.Errorf("upstream %s: dns_discovery.scheme must be \"http\" or \"https\"", name)
+	}
+	if dd.Nameserver != "" {
+		if _, _, err := net.SplitHostPort(dd.Nameserver); err != nil {
+			return fmt.Errorf("upstream %s: dns_discovery.nameserver must be host:port", name)
+		}
+	}
+	if dd.PollInterval < 0 || dd.FailbackDelay < 0 {
+		return fmt.Errorf("upstream %s: dns_discovery.poll_interval and failback_delay must be >= 0", name)
+	}
+	return nil
+}
+
+// validateTransportCanary validates an upstream's transport_canary block.
+func (l *Loader) validateTransportCanary(cfg *Config, name string, tc TransportCanaryConfig) error {
+	if !tc.Enabled {
+		return nil
+	}
+	scope := fmt.Sprintf("upstream %s: transport_canary", name)
+	if tc.Percentage < 0 || tc.Percentage > 100 {
+		return fmt.Errorf("%s.percentage must be between 0 and 100", scope)
+	}
+	if tc.Percentage == 0 && len(tc.Routes) == 0 {
+		return fmt.Errorf("%s requires percentage > 0 or routes", scope)
+	}
+	if tc.Transport == (TransportConfig{}) {
+		return fmt.Errorf("%s.transport must change at least one setting", scope)
+	}
+	if err := l.validateTransportConfig(scope, tc.Transport); err != nil {
+		return err
+	}
+	if tc.Window < 0 || tc.MinRequests < 0 {
+		return fmt.Errorf("%s: window and min_requests must be >= 0", scope)
+	}
+	if tc.MaxErrorRate < 0 || tc.MaxErrorRate > 1 || tc.MaxHandshakeFailureRate < 0 || tc.MaxHandshakeFailureRate > 1 {
+		return fmt.Errorf("%s: max_error_rate and max_handshake_failure_rate must be between 0.0 and 1.0", scope)
+	}
+	for _, id := range tc.Routes {
+		found := false
+		for _, r := range cfg.Routes {
+			if r.ID == id {
+				found = r.Upstream == name
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s.routes: %q is not a route using this upstream", scope, id)
+		}
+	}
+	return nil
+}
+
+// webhookEventPrefixes lists the event families an endpoint may subscribe to.
+var webhookEventPrefixes = []string{
+	"backend.", "circuit_breaker.", "canary.", "config.", "outlier.",
+	"dependency.", "api_key.", "degraded_mode.", "ab_test.",
+	"reputation.", "upstream.", "break_glass.", "transport_canary.",
+	"schema_drift.", "listener.", "route.",
+}
+
 // validateWebhooks validates webhook configuration.
 func (l *Loader) validateWebhooks(cfg WebhooksConfig) error {
 	if !cfg.Enabled {
@@ -755,10 +1334,6 @@ func (l *Loader) validateWebhooks(cfg WebhooksConfig) error {
 		return fmt.Errorf("webhooks: enabled requires at least one endpoint")
 	}
 	webhookIDs := make(map[string]bool)
-	validEventPrefixes := map[string]bool{
-		"backend.": true, "circuit_breaker.": true, "canary.": true,
-		"config.": true, "outlier.": true,
-	}
 	for i, ep := range cfg.Endpoints {
 		if ep.ID == "" {
 			return fmt.Errorf("webhooks: endpoint %d: id is required", i)
@@ -781,14 +1356,14 @@ func (l *Loader) validateWebhooks(cfg WebhooksConfig) error {
 				continue
 			}
 			valid := false
-			for prefix := range validEventPrefixes {
+			for _, prefix := range webhookEventPrefixes {
 				if strings.HasPrefix(evt, prefix) {
 					valid = true
 					break
 				}
 			}
 			if !valid {
-				return fmt.Errorf("webhooks: endpoint %s: invalid event pattern %q (must start with backend., circuit_breaker., canary., config., or be *)", ep.ID, evt)
+				return fmt.Errorf("webhooks: endpoint %s: invalid event pattern %q (must start with one of %s, or be *)", ep.ID, evt, strings.Join(webhookEventPrefixes, ", "))
 			}
 		}
 	}
@@ -906,6 +1481,7 @@ func generateRoutesFromSpec(specCfg OpenAPISpecConfig) ([]RouteConfig, error) {
 					ValidateRequest:  valReqPtr,
 					ValidateResponse: specCfg.Validation.Response,
 					LogOnly:          specCfg.Validation.LogOnly,
+					SchemaDrift:      specCfg.SchemaDrift,
 				},
 			}
 
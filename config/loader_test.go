@@ -588,6 +588,60 @@ listeners:
 			wantErr: true,
 			errMsg:  "challenge_type must be",
 		},
+		{
+			name: "certificate reload",
+			yaml: `
+listeners:
+  - id: "https"
+    address: ":443"
+    protocol: "http"
+    tls:
+      enabled: true
+      cert_file: "/etc/tls/tls.crt"
+      key_file: "/etc/tls/tls.key"
+      reload:
+        enabled: true
+        interval: 10s
+`,
+			wantErr: false,
+		},
+		{
+			name: "certificate reload negative interval",
+			yaml: `
+listeners:
+  - id: "https"
+    address: ":443"
+    protocol: "http"
+    tls:
+      enabled: true
+      cert_file: "/etc/tls/tls.crt"
+      key_file: "/etc/tls/tls.key"
+      reload:
+        enabled: true
+        interval: -1s
+`,
+			wantErr: true,
+			errMsg:  "tls.reload.interval must be >= 0",
+		},
+		{
+			name: "certificate reload with ACME",
+			yaml: `
+listeners:
+  - id: "https-acme"
+    address: ":443"
+    protocol: "http"
+    tls:
+      enabled: true
+      reload:
+        enabled: true
+      acme:
+        enabled: true
+        domains:
+          - "example.com"
+`,
+			wantErr: true,
+			errMsg:  "tls.reload does not apply to acme certificates",
+		},
 	}
 
 	for _, tt := range tests {
@@ -880,6 +934,26 @@ routes:
       grpc:
         tls:
           enabled: true
+`,
+			wantErr: true,
+		},
+		{
+			name: "protocol client_stream invalid on_invalid_line",
+			yaml: `
+listeners:
+  - id: "http-main"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: grpc-route
+    path: /api/grpc
+    backends:
+      - url: grpc://localhost:50051
+    protocol:
+      type: http_to_grpc
+      grpc:
+        client_stream:
+          on_invalid_line: ignore
 `,
 			wantErr: true,
 		},
@@ -1625,6 +1699,172 @@ routes:
 `,
 			wantErr: false,
 		},
+		{
+			name: "valid multiplexed services",
+			yaml: `
+listeners:
+  - id: "http-main"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: thrift-route
+    path: /thrift
+    path_prefix: true
+    backends:
+      - url: http://localhost:9090
+    protocol:
+      type: http_to_thrift
+      thrift:
+        idl_file: /etc/idl/platform.thrift
+        idl_reload_interval: 30s
+        services:
+          - name: UserService
+            mappings:
+              - http_method: GET
+                http_path: /users/:id
+                thrift_method: GetUser
+          - name: OrderService
+            multiplexed_name: orders
+            mappings:
+              - http_method: GET
+                http_path: /orders/:id
+                thrift_method: GetOrder
+`,
+			wantErr: false,
+		},
+		{
+			name: "services and service mutually exclusive",
+			yaml: `
+listeners:
+  - id: "http-main"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: thrift-route
+    path: /thrift
+    path_prefix: true
+    backends:
+      - url: http://localhost:9090
+    protocol:
+      type: http_to_thrift
+      thrift:
+        idl_file: /etc/idl/platform.thrift
+        service: UserService
+        services:
+          - name: OrderService
+            mappings:
+              - http_method: GET
+                http_path: /orders/:id
+                thrift_method: GetOrder
+`,
+			wantErr: true,
+		},
+		{
+			name: "services require idl_file",
+			yaml: `
+listeners:
+  - id: "http-main"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: thrift-route
+    path: /thrift
+    path_prefix: true
+    backends:
+      - url: http://localhost:9090
+    protocol:
+      type: http_to_thrift
+      thrift:
+        services:
+          - name: UserService
+            mappings:
+              - http_method: GET
+                http_path: /users/:id
+                thrift_method: GetUser
+        methods:
+          GetUser:
+            args:
+              - id: 1
+                name: id
+                type: string
+`,
+			wantErr: true,
+		},
+		{
+			name: "service without mappings",
+			yaml: `
+listeners:
+  - id: "http-main"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: thrift-route
+    path: /thrift
+    path_prefix: true
+    backends:
+      - url: http://localhost:9090
+    protocol:
+      type: http_to_thrift
+      thrift:
+        idl_file: /etc/idl/platform.thrift
+        services:
+          - name: UserService
+`,
+			wantErr: true,
+		},
+		{
+			name: "duplicate mapping across services",
+			yaml: `
+listeners:
+  - id: "http-main"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: thrift-route
+    path: /thrift
+    path_prefix: true
+    backends:
+      - url: http://localhost:9090
+    protocol:
+      type: http_to_thrift
+      thrift:
+        idl_file: /etc/idl/platform.thrift
+        services:
+          - name: UserService
+            mappings:
+              - http_method: GET
+                http_path: /items/:id
+                thrift_method: GetUser
+          - name: OrderService
+            mappings:
+              - http_method: GET
+                http_path: /items/:id
+                thrift_method: GetOrder
+`,
+			wantErr: true,
+		},
+		{
+			name: "negative idl_reload_interval",
+			yaml: `
+listeners:
+  - id: "http-main"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: thrift-route
+    path: /thrift
+    path_prefix: true
+    backends:
+      - url: http://localhost:9090
+    protocol:
+      type: http_to_thrift
+      thrift:
+        idl_file: /etc/idl/service.thrift
+        service: UserService
+        idl_reload_interval: -1s
+`,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -1845,6 +2085,64 @@ routes:
     load_balancer: least_response_time
     backends:
       - url: http://localhost:9000
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid ewma",
+			yaml: `
+listeners:
+  - id: "http-main"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    load_balancer: ewma
+    ewma:
+      decay_time: 5s
+      no_data_penalty: 200ms
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: false,
+		},
+		{
+			name: "ewma negative decay_time",
+			yaml: `
+listeners:
+  - id: "http-main"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    load_balancer: ewma
+    ewma:
+      decay_time: -1s
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: true,
+		},
+		{
+			name: "valid upstream ewma",
+			yaml: `
+listeners:
+  - id: "http-main"
+    address: ":8080"
+    protocol: "http"
+upstreams:
+  api:
+    load_balancer: ewma
+    ewma:
+      no_data_penalty: 1s
+    backends:
+      - url: http://localhost:9000
+routes:
+  - id: test
+    path: /test
+    upstream: api
 `,
 			wantErr: false,
 		},
@@ -2116,46 +2414,169 @@ routes:
 `,
 			wantErr: false,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			loader := NewLoader()
-			_, err := loader.Parse([]byte(tt.yaml))
-			if tt.wantErr && err == nil {
-				t.Error("expected error, got nil")
-			}
-			if !tt.wantErr && err != nil {
-				t.Errorf("unexpected error: %v", err)
-			}
-		})
-	}
-}
-
-func TestLoaderValidateTimeoutPolicy(t *testing.T) {
-	base := func(tp string) string {
-		return `
+		{
+			name: "valid status_ttls",
+			yaml: `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
 routes:
   - id: test
-    path: /api
+    path: /test
     backends:
       - url: http://localhost:9000
-    timeout_policy:
-` + tp
-	}
-
-	tests := []struct {
-		name    string
-		yaml    string
-		wantErr bool
-	}{
-		{
-			name:    "valid full config",
-			yaml:    base("      request: 30s\n      backend: 5s\n      header_timeout: 3s\n      idle: 60s"),
+    cache:
+      enabled: true
+      write_through_invalidation: true
+      status_ttls:
+        "200": 5m
+        "301": 1h
+        "404": 30s
+        "410":
+        "5xx": 5s
+`,
+			wantErr: false,
+		},
+		{
+			name: "invalid status_ttls key",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    cache:
+      enabled: true
+      status_ttls:
+        "600": 5m
+`,
+			wantErr: true,
+		},
+		{
+			name: "negative status_ttls value",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    cache:
+      enabled: true
+      status_ttls:
+        "4xx": -1s
+`,
+			wantErr: true,
+		},
+		{
+			name: "valid key_normalization",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    cache:
+      enabled: true
+      key_normalization:
+        sort_query: true
+        ignore_query_params: ["utm_*", "fbclid"]
+        lowercase_path: true
+        strip_trailing_slash: true
+`,
+			wantErr: false,
+		},
+		{
+			name: "key_normalization ignore and include together",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    cache:
+      enabled: true
+      key_normalization:
+        ignore_query_params: ["utm_*"]
+        include_query_params: ["id"]
+`,
+			wantErr: true,
+		},
+		{
+			name: "key_normalization invalid glob",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    cache:
+      enabled: true
+      key_normalization:
+        ignore_query_params: ["utm_["]
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateTimeoutPolicy(t *testing.T) {
+	base := func(tp string) string {
+		return `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /api
+    backends:
+      - url: http://localhost:9000
+    timeout_policy:
+` + tp
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{
+			name:    "valid full config",
+			yaml:    base("      request: 30s\n      backend: 5s\n      header_timeout: 3s\n      idle: 60s"),
 			wantErr: false,
 		},
 		{
@@ -2267,6 +2688,130 @@ webhooks:
       events:
         - "canary.*"
         - "config.*"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid degraded mode events",
+			yaml: base + `
+webhooks:
+  enabled: true
+  endpoints:
+    - id: degraded
+      url: https://hooks.example.com/degraded
+      events:
+        - "degraded_mode.entered"
+        - "degraded_mode.exited"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid ab test exposure event",
+			yaml: base + `
+webhooks:
+  enabled: true
+  endpoints:
+    - id: ab-tests
+      url: https://hooks.example.com/ab-tests
+      events:
+        - "ab_test.exposure"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid reputation block event",
+			yaml: base + `
+webhooks:
+  enabled: true
+  endpoints:
+    - id: reputation
+      url: https://hooks.example.com/reputation
+      events:
+        - "reputation.blocked"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid upstream swap events",
+			yaml: base + `
+webhooks:
+  enabled: true
+  endpoints:
+    - id: upstream-swaps
+      url: https://hooks.example.com/upstream-swaps
+      events:
+        - "upstream.swapped"
+        - "upstream.swap_failed"
+        - "upstream.rolled_back"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid break glass events",
+			yaml: base + `
+webhooks:
+  enabled: true
+  endpoints:
+    - id: break-glass
+      url: https://hooks.example.com/break-glass
+      events:
+        - "break_glass.activated"
+        - "break_glass.reverted"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid transport canary events",
+			yaml: base + `
+webhooks:
+  enabled: true
+  endpoints:
+    - id: transport-canary
+      url: https://hooks.example.com/transport-canary
+      events:
+        - "transport_canary.rolled_back"
+        - "transport_canary.promoted"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid schema drift digest event",
+			yaml: base + `
+webhooks:
+  enabled: true
+  endpoints:
+    - id: schema-drift
+      url: https://hooks.example.com/schema-drift
+      events:
+        - "schema_drift.digest"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid listener cert reload event",
+			yaml: base + `
+webhooks:
+  enabled: true
+  endpoints:
+    - id: listeners
+      url: https://hooks.example.com/listeners
+      events:
+        - "listener.cert_reload_failed"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid route pause events",
+			yaml: base + `
+webhooks:
+  enabled: true
+  endpoints:
+    - id: route-pauses
+      url: https://hooks.example.com/route-pauses
+      events:
+        - "route.paused"
+        - "route.resumed"
+        - "route.pause_expired"
 `,
 			wantErr: false,
 		},
@@ -2483,52 +3028,135 @@ health_check:
 `,
 			wantErr: false,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			loader := NewLoader()
-			_, err := loader.Parse([]byte(tt.yaml))
-			if tt.wantErr && err == nil {
-				t.Error("expected error, got nil")
-			}
-			if !tt.wantErr && err != nil {
-				t.Errorf("unexpected error: %v", err)
-			}
-		})
-	}
-}
-
-func TestLoaderValidateUpstreams(t *testing.T) {
-	base := `
+		{
+			name: "valid http probe with body and headers",
+			yaml: base + `
+health_check:
+  path: "/ready"
+  method: "POST"
+  body: '{"deep":true}'
+  headers:
+    Content-Type: "application/json"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid tcp probe with banner",
+			yaml: `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
-`
-
-	tests := []struct {
-		name    string
-		yaml    string
-		wantErr bool
-	}{
+routes:
+  - id: "test"
+    path: "/test"
+    backends:
+      - url: "http://localhost:6379"
+        health_check:
+          type: "tcp"
+          send: "PING\r\n"
+          expect: "+PONG"
+`,
+			wantErr: false,
+		},
 		{
-			name: "valid upstream with backends",
+			name: "valid grpc probe",
 			yaml: base + `
-upstreams:
-  api-pool:
-    backends:
-      - url: http://localhost:9000
-      - url: http://localhost:9001
-routes:
-  - id: test
-    path: /test
-    upstream: api-pool
+health_check:
+  type: "grpc"
+  service: "orders.v1.Orders"
 `,
 			wantErr: false,
 		},
 		{
-			name: "valid upstream with service",
+			name: "unknown probe type",
+			yaml: base + `
+health_check:
+  type: "udp"
+`,
+			wantErr: true,
+		},
+		{
+			name: "tcp probe with http path",
+			yaml: base + `
+health_check:
+  type: "tcp"
+  path: "/health"
+`,
+			wantErr: true,
+		},
+		{
+			name: "expect without tcp",
+			yaml: base + `
+health_check:
+  expect: "OK"
+`,
+			wantErr: true,
+		},
+		{
+			name: "service without grpc",
+			yaml: base + `
+health_check:
+  type: "tcp"
+  service: "orders"
+`,
+			wantErr: true,
+		},
+		{
+			name: "body with GET",
+			yaml: base + `
+health_check:
+  method: "GET"
+  body: "{}"
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateUpstreams(t *testing.T) {
+	base := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+`
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{
+			name: "valid upstream with backends",
+			yaml: base + `
+upstreams:
+  api-pool:
+    backends:
+      - url: http://localhost:9000
+      - url: http://localhost:9001
+routes:
+  - id: test
+    path: /test
+    upstream: api-pool
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid upstream with service",
 			yaml: base + `
 upstreams:
   svc-pool:
@@ -3008,7 +3636,7 @@ transport:
 	}
 }
 
-func TestLoaderValidateRequestDecompression(t *testing.T) {
+func TestLoaderValidateIPFamily(t *testing.T) {
 	tests := []struct {
 		name    string
 		yaml    string
@@ -3016,7 +3644,7 @@ func TestLoaderValidateRequestDecompression(t *testing.T) {
 		errMsg  string
 	}{
 		{
-			name: "valid global decompression",
+			name: "global prefer_ipv4 passes",
 			yaml: `
 listeners:
   - id: "http"
@@ -3027,52 +3655,33 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-request_decompression:
-  enabled: true
-  algorithms: ["gzip", "br"]
+transport:
+  ip_family: prefer_ipv4
 `,
 			wantErr: false,
 		},
 		{
-			name: "invalid algorithm rejected",
+			name: "per-upstream ipv6 passes",
 			yaml: `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
-routes:
-  - id: test
-    path: /test
+upstreams:
+  api:
     backends:
       - url: http://localhost:9000
-request_decompression:
-  enabled: true
-  algorithms: ["gzip", "lz4"]
-`,
-			wantErr: true,
-			errMsg:  "unsupported algorithm",
-		},
-		{
-			name: "negative max size rejected",
-			yaml: `
-listeners:
-  - id: "http"
-    address: ":8080"
-    protocol: "http"
+    transport:
+      ip_family: ipv6
 routes:
   - id: test
     path: /test
-    backends:
-      - url: http://localhost:9000
-request_decompression:
-  enabled: true
-  max_decompressed_size: -1
+    upstream: api
 `,
-			wantErr: true,
-			errMsg:  "max_decompressed_size must be >= 0",
+			wantErr: false,
 		},
 		{
-			name: "per-route decompression valid",
+			name: "unknown family rejected",
 			yaml: `
 listeners:
   - id: "http"
@@ -3083,11 +3692,11 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    request_decompression:
-      enabled: true
-      algorithms: ["zstd", "deflate"]
+transport:
+  ip_family: dual
 `,
-			wantErr: false,
+			wantErr: true,
+			errMsg:  "transport.ip_family must be",
 		},
 	}
 
@@ -3108,7 +3717,7 @@ routes:
 	}
 }
 
-func TestLoaderValidateSecurityHeaders(t *testing.T) {
+func TestLoaderValidateTransportCanary(t *testing.T) {
 	tests := []struct {
 		name    string
 		yaml    string
@@ -3116,185 +3725,196 @@ func TestLoaderValidateSecurityHeaders(t *testing.T) {
 		errMsg  string
 	}{
 		{
-			name: "valid global security headers",
+			name: "percentage with http3 passes",
 			yaml: `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
+upstreams:
+  api:
+    backends:
+      - url: http://localhost:9000
+    transport_canary:
+      enabled: true
+      percentage: 10
+      transport:
+        enable_http3: true
 routes:
   - id: test
     path: /test
+    upstream: api
+  - id: other
+    path: /other
     backends:
-      - url: http://localhost:9000
-security_headers:
-  enabled: true
-  strict_transport_security: "max-age=31536000"
-  x_frame_options: "DENY"
+      - url: http://localhost:9001
 `,
 			wantErr: false,
 		},
 		{
-			name: "valid per-route security headers",
+			name: "routes only passes",
 			yaml: `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
+upstreams:
+  api:
+    backends:
+      - url: http://localhost:9000
+    transport_canary:
+      enabled: true
+      routes: [test]
+      transport:
+        max_conns_per_host: 64
 routes:
   - id: test
     path: /test
+    upstream: api
+  - id: other
+    path: /other
     backends:
-      - url: http://localhost:9000
-    security_headers:
-      enabled: true
-      referrer_policy: "no-referrer"
-      custom_headers:
-        X-Custom: "value"
+      - url: http://localhost:9001
 `,
 			wantErr: false,
 		},
 		{
-			name: "empty custom header name rejected",
+			name: "no percentage or routes rejected",
 			yaml: `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
+upstreams:
+  api:
+    backends:
+      - url: http://localhost:9000
+    transport_canary:
+      enabled: true
+      transport:
+        force_http2: true
 routes:
   - id: test
     path: /test
+    upstream: api
+  - id: other
+    path: /other
     backends:
-      - url: http://localhost:9000
-security_headers:
-  enabled: true
-  custom_headers:
-    "": "bad"
+      - url: http://localhost:9001
 `,
 			wantErr: true,
-			errMsg:  "header name must not be empty",
+			errMsg:  "requires percentage > 0 or routes",
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			loader := NewLoader()
-			_, err := loader.Parse([]byte(tt.yaml))
-			if tt.wantErr {
-				if err == nil {
-					t.Error("expected error, got nil")
-				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
-					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
-				}
-			} else if err != nil {
-				t.Errorf("unexpected error: %v", err)
-			}
-		})
-	}
-}
-
-func TestLoaderValidateMaintenance(t *testing.T) {
-	tests := []struct {
-		name    string
-		yaml    string
-		wantErr bool
-		errMsg  string
-	}{
 		{
-			name: "valid global maintenance",
+			name: "percentage out of range rejected",
 			yaml: `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
-routes:
-  - id: test
-    path: /test
+upstreams:
+  api:
     backends:
       - url: http://localhost:9000
-maintenance:
-  enabled: true
-  status_code: 503
-  retry_after: "3600"
-  exclude_paths: ["/health"]
-  exclude_ips: ["10.0.0.0/8"]
-`,
-			wantErr: false,
-		},
-		{
-			name: "invalid status code",
-			yaml: `
-listeners:
-  - id: "http"
-    address: ":8080"
-    protocol: "http"
+    transport_canary:
+      enabled: true
+      percentage: 150
+      transport:
+        force_http2: true
 routes:
   - id: test
     path: /test
+    upstream: api
+  - id: other
+    path: /other
     backends:
-      - url: http://localhost:9000
-maintenance:
-  enabled: true
-  status_code: 999
+      - url: http://localhost:9001
 `,
 			wantErr: true,
-			errMsg:  "valid HTTP status",
+			errMsg:  "percentage must be between 0 and 100",
 		},
 		{
-			name: "invalid CIDR",
+			name: "empty transport rejected",
 			yaml: `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
+upstreams:
+  api:
+    backends:
+      - url: http://localhost:9000
+    transport_canary:
+      enabled: true
+      percentage: 10
 routes:
   - id: test
     path: /test
+    upstream: api
+  - id: other
+    path: /other
     backends:
-      - url: http://localhost:9000
-maintenance:
-  enabled: true
-  exclude_ips: ["not-a-cidr/33"]
+      - url: http://localhost:9001
 `,
 			wantErr: true,
-			errMsg:  "invalid CIDR",
+			errMsg:  "transport must change at least one setting",
 		},
 		{
-			name: "invalid IP",
+			name: "route on another upstream rejected",
 			yaml: `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
+upstreams:
+  api:
+    backends:
+      - url: http://localhost:9000
+    transport_canary:
+      enabled: true
+      routes: [other]
+      transport:
+        force_http2: true
 routes:
   - id: test
     path: /test
+    upstream: api
+  - id: other
+    path: /other
     backends:
-      - url: http://localhost:9000
-maintenance:
-  enabled: true
-  exclude_ips: ["not-an-ip"]
+      - url: http://localhost:9001
 `,
 			wantErr: true,
-			errMsg:  "invalid IP",
+			errMsg:  "\"other\" is not a route using this upstream",
 		},
 		{
-			name: "valid per-route maintenance",
+			name: "error rate out of range rejected",
 			yaml: `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
+upstreams:
+  api:
+    backends:
+      - url: http://localhost:9000
+    transport_canary:
+      enabled: true
+      percentage: 10
+      max_error_rate: 1.5
+      transport:
+        force_http2: true
 routes:
   - id: test
     path: /test
+    upstream: api
+  - id: other
+    path: /other
     backends:
-      - url: http://localhost:9000
-    maintenance:
-      enabled: true
-      body: "Under maintenance"
+      - url: http://localhost:9001
 `,
-			wantErr: false,
+			wantErr: true,
+			errMsg:  "max_error_rate and max_handshake_failure_rate must be between 0.0 and 1.0",
 		},
 	}
 
@@ -3315,7 +3935,7 @@ routes:
 	}
 }
 
-func TestLoaderValidateShutdown(t *testing.T) {
+func TestLoaderValidateDegradedMode(t *testing.T) {
 	tests := []struct {
 		name    string
 		yaml    string
@@ -3323,7 +3943,7 @@ func TestLoaderValidateShutdown(t *testing.T) {
 		errMsg  string
 	}{
 		{
-			name: "valid shutdown config",
+			name: "consecutive failures passes",
 			yaml: `
 listeners:
   - id: "http"
@@ -3334,14 +3954,17 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-shutdown:
-  timeout: 60s
-  drain_delay: 10s
+    degraded_mode:
+      enabled: true
+      consecutive_failures: 5
+      fallback:
+        status_code: 503
+        body: "<h1>Back soon</h1>"
 `,
 			wantErr: false,
 		},
 		{
-			name: "negative timeout",
+			name: "no trigger rejected",
 			yaml: `
 listeners:
   - id: "http"
@@ -3352,14 +3975,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-shutdown:
-  timeout: -1s
+    degraded_mode:
+      enabled: true
+      window: 30s
 `,
 			wantErr: true,
-			errMsg:  "shutdown.timeout must be >= 0",
+			errMsg:  "requires error_rate_threshold or consecutive_failures",
 		},
 		{
-			name: "negative drain_delay",
+			name: "error rate above one rejected",
 			yaml: `
 listeners:
   - id: "http"
@@ -3370,14 +3994,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-shutdown:
-  drain_delay: -5s
+    degraded_mode:
+      enabled: true
+      error_rate_threshold: 1.5
 `,
 			wantErr: true,
-			errMsg:  "shutdown.drain_delay must be >= 0",
+			errMsg:  "error_rate_threshold must be between",
 		},
 		{
-			name: "drain_delay >= timeout",
+			name: "invalid fallback status rejected",
 			yaml: `
 listeners:
   - id: "http"
@@ -3388,28 +4013,14 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-shutdown:
-  timeout: 10s
-  drain_delay: 10s
+    degraded_mode:
+      enabled: true
+      error_rate_threshold: 0.5
+      fallback:
+        status_code: 700
 `,
 			wantErr: true,
-			errMsg:  "shutdown.drain_delay",
-		},
-		{
-			name: "zero values valid (defaults apply)",
-			yaml: `
-listeners:
-  - id: "http"
-    address: ":8080"
-    protocol: "http"
-routes:
-  - id: test
-    path: /test
-    backends:
-      - url: http://localhost:9000
-shutdown: {}
-`,
-			wantErr: false,
+			errMsg:  "fallback.status_code",
 		},
 	}
 
@@ -3430,7 +4041,7 @@ shutdown: {}
 	}
 }
 
-func TestLoaderValidateTrustedProxies(t *testing.T) {
+func TestLoaderValidateRequestDecompression(t *testing.T) {
 	tests := []struct {
 		name    string
 		yaml    string
@@ -3438,7 +4049,7 @@ func TestLoaderValidateTrustedProxies(t *testing.T) {
 		errMsg  string
 	}{
 		{
-			name: "valid CIDRs",
+			name: "valid global decompression",
 			yaml: `
 listeners:
   - id: "http"
@@ -3449,17 +4060,14 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-trusted_proxies:
-  cidrs:
-    - "10.0.0.0/8"
-    - "172.16.0.0/12"
-    - "192.168.0.0/16"
-  max_hops: 2
+request_decompression:
+  enabled: true
+  algorithms: ["gzip", "br"]
 `,
 			wantErr: false,
 		},
 		{
-			name: "valid bare IP",
+			name: "invalid algorithm rejected",
 			yaml: `
 listeners:
   - id: "http"
@@ -3470,14 +4078,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-trusted_proxies:
-  cidrs:
-    - "127.0.0.1"
+request_decompression:
+  enabled: true
+  algorithms: ["gzip", "lz4"]
 `,
-			wantErr: false,
+			wantErr: true,
+			errMsg:  "unsupported algorithm",
 		},
 		{
-			name: "invalid CIDR",
+			name: "negative max size rejected",
 			yaml: `
 listeners:
   - id: "http"
@@ -3488,15 +4097,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-trusted_proxies:
-  cidrs:
-    - "not-a-cidr"
+request_decompression:
+  enabled: true
+  max_decompressed_size: -1
 `,
 			wantErr: true,
-			errMsg:  "trusted_proxies.cidrs",
+			errMsg:  "max_decompressed_size must be >= 0",
 		},
 		{
-			name: "negative max_hops",
+			name: "per-route decompression valid",
 			yaml: `
 listeners:
   - id: "http"
@@ -3507,13 +4116,11 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-trusted_proxies:
-  cidrs:
-    - "10.0.0.0/8"
-  max_hops: -1
+    request_decompression:
+      enabled: true
+      algorithms: ["zstd", "deflate"]
 `,
-			wantErr: true,
-			errMsg:  "max_hops must be >= 0",
+			wantErr: false,
 		},
 	}
 
@@ -3534,7 +4141,7 @@ trusted_proxies:
 	}
 }
 
-func TestLoaderValidateResponseLimit(t *testing.T) {
+func TestLoaderValidateSecurityHeaders(t *testing.T) {
 	tests := []struct {
 		name    string
 		yaml    string
@@ -3542,7 +4149,7 @@ func TestLoaderValidateResponseLimit(t *testing.T) {
 		errMsg  string
 	}{
 		{
-			name: "valid global response limit",
+			name: "valid global security headers",
 			yaml: `
 listeners:
   - id: "http"
@@ -3553,15 +4160,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-response_limit:
+security_headers:
   enabled: true
-  max_size: 1048576
-  action: reject
+  strict_transport_security: "max-age=31536000"
+  x_frame_options: "DENY"
 `,
 			wantErr: false,
 		},
 		{
-			name: "valid per-route truncate",
+			name: "valid per-route security headers",
 			yaml: `
 listeners:
   - id: "http"
@@ -3572,15 +4179,16 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    response_limit:
+    security_headers:
       enabled: true
-      max_size: 5242880
-      action: truncate
+      referrer_policy: "no-referrer"
+      custom_headers:
+        X-Custom: "value"
 `,
 			wantErr: false,
 		},
 		{
-			name: "negative max_size rejected",
+			name: "empty custom header name rejected",
 			yaml: `
 listeners:
   - id: "http"
@@ -3591,15 +4199,63 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-response_limit:
+security_headers:
   enabled: true
-  max_size: -1
+  custom_headers:
+    "": "bad"
 `,
 			wantErr: true,
-			errMsg:  "response_limit.max_size must be >= 0",
+			errMsg:  "header name must not be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateMaintenance(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid global maintenance",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+maintenance:
+  enabled: true
+  status_code: 503
+  retry_after: "3600"
+  exclude_paths: ["/health"]
+  exclude_ips: ["10.0.0.0/8"]
+`,
+			wantErr: false,
 		},
 		{
-			name: "zero max_size when enabled rejected",
+			name: "invalid status code",
 			yaml: `
 listeners:
   - id: "http"
@@ -3610,15 +4266,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-response_limit:
+maintenance:
   enabled: true
-  max_size: 0
+  status_code: 999
 `,
 			wantErr: true,
-			errMsg:  "response_limit.max_size must be set when enabled",
+			errMsg:  "valid HTTP status",
 		},
 		{
-			name: "invalid action rejected",
+			name: "invalid CIDR",
 			yaml: `
 listeners:
   - id: "http"
@@ -3629,16 +4285,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-response_limit:
+maintenance:
   enabled: true
-  max_size: 1048576
-  action: drop
+  exclude_ips: ["not-a-cidr/33"]
 `,
 			wantErr: true,
-			errMsg:  "response_limit.action must be one of",
+			errMsg:  "invalid CIDR",
 		},
 		{
-			name: "disabled config skips validation",
+			name: "invalid IP",
 			yaml: `
 listeners:
   - id: "http"
@@ -3649,9 +4304,28 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-response_limit:
-  enabled: false
-  max_size: -999
+maintenance:
+  enabled: true
+  exclude_ips: ["not-an-ip"]
+`,
+			wantErr: true,
+			errMsg:  "invalid IP",
+		},
+		{
+			name: "valid per-route maintenance",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    maintenance:
+      enabled: true
+      body: "Under maintenance"
 `,
 			wantErr: false,
 		},
@@ -3674,7 +4348,7 @@ response_limit:
 	}
 }
 
-func TestLoaderValidateCookieMatch(t *testing.T) {
+func TestLoaderValidateShutdown(t *testing.T) {
 	tests := []struct {
 		name    string
 		yaml    string
@@ -3682,7 +4356,7 @@ func TestLoaderValidateCookieMatch(t *testing.T) {
 		errMsg  string
 	}{
 		{
-			name: "valid cookie exact match",
+			name: "valid shutdown config",
 			yaml: `
 listeners:
   - id: "http"
@@ -3693,15 +4367,14 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      cookies:
-        - name: beta
-          value: "true"
+shutdown:
+  timeout: 60s
+  drain_delay: 10s
 `,
 			wantErr: false,
 		},
 		{
-			name: "valid cookie regex",
+			name: "negative timeout",
 			yaml: `
 listeners:
   - id: "http"
@@ -3712,15 +4385,14 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      cookies:
-        - name: variant
-          regex: "^(a|b)$"
+shutdown:
+  timeout: -1s
 `,
-			wantErr: false,
+			wantErr: true,
+			errMsg:  "shutdown.timeout must be >= 0",
 		},
 		{
-			name: "cookie missing name",
+			name: "negative drain_delay",
 			yaml: `
 listeners:
   - id: "http"
@@ -3731,15 +4403,14 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      cookies:
-        - value: "true"
+shutdown:
+  drain_delay: -5s
 `,
 			wantErr: true,
-			errMsg:  "match cookie 0: name is required",
+			errMsg:  "shutdown.drain_delay must be >= 0",
 		},
 		{
-			name: "cookie invalid regex",
+			name: "drain_delay >= timeout",
 			yaml: `
 listeners:
   - id: "http"
@@ -3750,16 +4421,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      cookies:
-        - name: variant
-          regex: "[invalid"
+shutdown:
+  timeout: 10s
+  drain_delay: 10s
 `,
 			wantErr: true,
-			errMsg:  "invalid regex",
+			errMsg:  "shutdown.drain_delay",
 		},
 		{
-			name: "cookie must set exactly one criterion",
+			name: "zero values valid (defaults apply)",
 			yaml: `
 listeners:
   - id: "http"
@@ -3770,14 +4440,9 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      cookies:
-        - name: beta
-          value: "true"
-          regex: "^true$"
+shutdown: {}
 `,
-			wantErr: true,
-			errMsg:  "must set exactly one of",
+			wantErr: false,
 		},
 	}
 
@@ -3798,7 +4463,7 @@ routes:
 	}
 }
 
-func TestLoaderValidateBodyMatch(t *testing.T) {
+func TestLoaderValidateWarmup(t *testing.T) {
 	tests := []struct {
 		name    string
 		yaml    string
@@ -3806,7 +4471,7 @@ func TestLoaderValidateBodyMatch(t *testing.T) {
 		errMsg  string
 	}{
 		{
-			name: "valid body exact match",
+			name: "valid warmup config",
 			yaml: `
 listeners:
   - id: "http"
@@ -3817,15 +4482,17 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      body:
-        - name: "action"
-          value: "create"
+warmup:
+  enabled: true
+  initial_weight: 10
+  duration: 2m
+  self_throttle: true
+  reload_threshold: 0.3
 `,
 			wantErr: false,
 		},
 		{
-			name: "valid body present check",
+			name: "initial_weight above 100",
 			yaml: `
 listeners:
   - id: "http"
@@ -3836,15 +4503,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      body:
-        - name: "metadata"
-          present: true
+warmup:
+  enabled: true
+  initial_weight: 101
 `,
-			wantErr: false,
+			wantErr: true,
+			errMsg:  "warmup: initial_weight must be between 0 and 100",
 		},
 		{
-			name: "valid body regex",
+			name: "negative duration",
 			yaml: `
 listeners:
   - id: "http"
@@ -3855,15 +4522,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      body:
-        - name: "user.role"
-          regex: "^admin.*$"
+warmup:
+  enabled: true
+  duration: -1s
 `,
-			wantErr: false,
+			wantErr: true,
+			errMsg:  "warmup: duration must be >= 0",
 		},
 		{
-			name: "body missing name",
+			name: "reload_threshold above 1",
 			yaml: `
 listeners:
   - id: "http"
@@ -3874,15 +4541,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      body:
-        - value: "create"
+warmup:
+  enabled: true
+  reload_threshold: 1.5
 `,
 			wantErr: true,
-			errMsg:  "match body 0: name is required",
+			errMsg:  "warmup: reload_threshold must be between 0.0 and 1.0",
 		},
 		{
-			name: "body no criterion set",
+			name: "disabled skips validation",
 			yaml: `
 listeners:
   - id: "http"
@@ -3893,15 +4560,39 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      body:
-        - name: "action"
+warmup:
+  initial_weight: 500
 `,
-			wantErr: true,
-			errMsg:  "must set exactly one of",
+			wantErr: false,
 		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateFeatureFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
 		{
-			name: "body two criteria set",
+			name: "valid consul feature flags",
 			yaml: `
 listeners:
   - id: "http"
@@ -3912,17 +4603,17 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      body:
-        - name: "action"
-          value: "create"
-          regex: "^create$"
+feature_flags:
+  enabled: true
+  backend: consul
+  prefix: runway/flags/
+  consul:
+    address: "127.0.0.1:8500"
 `,
-			wantErr: true,
-			errMsg:  "must set exactly one of",
+			wantErr: false,
 		},
 		{
-			name: "body invalid regex",
+			name: "consul address from registry",
 			yaml: `
 listeners:
   - id: "http"
@@ -3933,16 +4624,18 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      body:
-        - name: "action"
-          regex: "[invalid"
+registry:
+  type: consul
+  consul:
+    address: "127.0.0.1:8500"
+feature_flags:
+  enabled: true
+  backend: consul
 `,
-			wantErr: true,
-			errMsg:  "invalid regex",
+			wantErr: false,
 		},
 		{
-			name: "negative max_match_body_size",
+			name: "missing backend",
 			yaml: `
 listeners:
   - id: "http"
@@ -3953,33 +4646,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    match:
-      max_match_body_size: -1
+feature_flags:
+  enabled: true
 `,
 			wantErr: true,
-			errMsg:  "max_match_body_size must be >= 0",
+			errMsg:  "feature_flags: backend must be",
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			loader := NewLoader()
-			_, err := loader.Parse([]byte(tt.yaml))
-			if tt.wantErr {
-				if err == nil {
-					t.Error("expected error, got nil")
-				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
-					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
-				}
-			} else if err != nil {
-				t.Errorf("unexpected error: %v", err)
-			}
-		})
-	}
-}
-
-func TestLoaderValidateTransportCertFiles(t *testing.T) {
-	base := `
+		{
+			name: "unknown backend",
+			yaml: `
 listeners:
   - id: "http"
     address: ":8080"
@@ -3989,77 +4664,35 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-`
-
-	dir := t.TempDir()
-	certFile := dir + "/client.crt"
-	keyFile := dir + "/client.key"
-	os.WriteFile(certFile, []byte("fake-cert"), 0600)
-	os.WriteFile(keyFile, []byte("fake-key"), 0600)
-
-	tests := []struct {
-		name    string
-		yaml    string
-		wantErr bool
-	}{
-		{
-			name: "valid both cert and key",
-			yaml: base + fmt.Sprintf(`
-transport:
-  cert_file: %s
-  key_file: %s
-`, certFile, keyFile),
-			wantErr: false,
-		},
-		{
-			name: "cert without key",
-			yaml: base + fmt.Sprintf(`
-transport:
-  cert_file: %s
-`, certFile),
-			wantErr: true,
-		},
-		{
-			name: "key without cert",
-			yaml: base + fmt.Sprintf(`
-transport:
-  key_file: %s
-`, keyFile),
-			wantErr: true,
-		},
-		{
-			name: "cert file does not exist",
-			yaml: base + `
-transport:
-  cert_file: /nonexistent/client.crt
-  key_file: /nonexistent/client.key
+feature_flags:
+  enabled: true
+  backend: zookeeper
 `,
 			wantErr: true,
+			errMsg:  "feature_flags: backend must be",
 		},
 		{
-			name: "upstream cert without key",
-			yaml: base + fmt.Sprintf(`
-upstreams:
-  api:
+			name: "retry_interval above max",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
     backends:
-      - url: http://localhost:9001
-    transport:
-      cert_file: %s
-`, certFile),
+      - url: http://localhost:9000
+feature_flags:
+  enabled: true
+  backend: consul
+  consul:
+    address: "127.0.0.1:8500"
+  retry_interval: 1m
+  max_retry_interval: 10s
+`,
 			wantErr: true,
-		},
-		{
-			name: "valid upstream cert and key",
-			yaml: base + fmt.Sprintf(`
-upstreams:
-  api:
-    backends:
-      - url: http://localhost:9001
-    transport:
-      cert_file: %s
-      key_file: %s
-`, certFile, keyFile),
-			wantErr: false,
+			errMsg:  "feature_flags: retry_interval must not exceed max_retry_interval",
 		},
 	}
 
@@ -4067,24 +4700,28 @@ upstreams:
 		t.Run(tt.name, func(t *testing.T) {
 			loader := NewLoader()
 			_, err := loader.Parse([]byte(tt.yaml))
-			if tt.wantErr && err == nil {
-				t.Error("expected error, got nil")
-			}
-			if !tt.wantErr && err != nil {
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
 		})
 	}
 }
 
-func TestLoaderValidateRewrite(t *testing.T) {
+func TestLoaderValidateDependencyHealth(t *testing.T) {
 	tests := []struct {
 		name    string
 		yaml    string
 		wantErr bool
+		errMsg  string
 	}{
 		{
-			name: "valid prefix rewrite",
+			name: "valid dependency health",
 			yaml: `
 listeners:
   - id: "http"
@@ -4092,17 +4729,26 @@ listeners:
     protocol: "http"
 routes:
   - id: test
-    path: /api/v1
-    path_prefix: true
-    rewrite:
-      prefix: /v2
+    path: /test
     backends:
       - url: http://localhost:9000
+admin:
+  enabled: true
+  dependency_health:
+    enabled: true
+    interval: 10s
+    timeout: 2s
+    checks:
+      redis:
+        critical: true
+        affects_readiness: true
+      webhook:
+        disabled: true
 `,
 			wantErr: false,
 		},
 		{
-			name: "valid regex rewrite",
+			name: "negative interval",
 			yaml: `
 listeners:
   - id: "http"
@@ -4110,17 +4756,20 @@ listeners:
     protocol: "http"
 routes:
   - id: test
-    path: /api
-    rewrite:
-      regex: "^/api/(\\d+)$"
-      replacement: "/v2/$1"
+    path: /test
     backends:
       - url: http://localhost:9000
+admin:
+  enabled: true
+  dependency_health:
+    enabled: true
+    interval: -1s
 `,
-			wantErr: false,
+			wantErr: true,
+			errMsg:  "admin.dependency_health: interval and timeout must be >= 0",
 		},
 		{
-			name: "valid host-only rewrite",
+			name: "negative per-check timeout",
 			yaml: `
 listeners:
   - id: "http"
@@ -4128,16 +4777,22 @@ listeners:
     protocol: "http"
 routes:
   - id: test
-    path: /api
-    rewrite:
-      host: backend.internal
+    path: /test
     backends:
       - url: http://localhost:9000
+admin:
+  enabled: true
+  dependency_health:
+    enabled: true
+    checks:
+      registry:
+        timeout: -1s
 `,
-			wantErr: false,
+			wantErr: true,
+			errMsg:  "admin.dependency_health.checks.registry: interval and timeout must be >= 0",
 		},
 		{
-			name: "valid prefix with host rewrite",
+			name: "dependency webhook events accepted",
 			yaml: `
 listeners:
   - id: "http"
@@ -4145,166 +4800,338 @@ listeners:
     protocol: "http"
 routes:
   - id: test
-    path: /api/v1
-    path_prefix: true
-    rewrite:
-      prefix: /v2
-      host: backend.internal
+    path: /test
     backends:
       - url: http://localhost:9000
+webhooks:
+  enabled: true
+  endpoints:
+    - id: ops
+      url: https://hooks.example.com/runway
+      events: ["dependency.unhealthy", "dependency.healthy"]
 `,
 			wantErr: false,
 		},
-		{
-			name: "prefix and regex mutually exclusive",
-			yaml: `
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateReputation(t *testing.T) {
+	base := `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
 routes:
   - id: test
-    path: /api
-    path_prefix: true
-    rewrite:
-      prefix: /v2
-      regex: "^/api$"
-      replacement: "/v2"
+    path: /test
     backends:
       - url: http://localhost:9000
-`,
-			wantErr: true,
-		},
-		{
-			name: "prefix requires path_prefix",
-			yaml: `
+reputation:
+`
+	tests := []struct {
+		name   string
+		rep    string
+		errMsg string
+	}{
+		{name: "valid", rep: "  enabled: true\n  weights:\n    waf: 50\n    bot: 10\n  threshold: 80\n  half_life: 5m\n  block_durations: [1m, 1h]\n  exempt_cidrs: [\"10.0.0.0/8\", \"192.168.1.1\"]\n"},
+		{name: "unknown signal", rep: "  enabled: true\n  weights:\n    sqli: 10\n", errMsg: `reputation.weights: unknown signal "sqli"`},
+		{name: "negative weight", rep: "  enabled: true\n  weights:\n    waf: -1\n", errMsg: "reputation.weights.waf must be >= 0"},
+		{name: "zero block duration", rep: "  enabled: true\n  block_durations: [1m, 0s]\n", errMsg: "reputation.block_durations[1] must be > 0"},
+		{name: "invalid exempt", rep: "  enabled: true\n  exempt_cidrs: [\"nope\"]\n", errMsg: `reputation.exempt_cidrs[0]: "nope" is not a valid IP or CIDR`},
+		{name: "redis without address", rep: "  enabled: true\n  store: redis\n", errMsg: "requires redis.address"},
+		{name: "unknown store", rep: "  enabled: true\n  store: etcd\n", errMsg: "reputation.store must be"},
+		{name: "disabled skips validation", rep: "  enabled: false\n  store: etcd\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(base + tt.rep))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateBackendTLSScan(t *testing.T) {
+	base := `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
 routes:
   - id: test
-    path: /api/v1
-    rewrite:
-      prefix: /v2
+    path: /test
     backends:
-      - url: http://localhost:9000
-`,
-			wantErr: true,
-		},
-		{
-			name: "prefix and strip_prefix mutually exclusive",
-			yaml: `
+      - url: https://localhost:9443
+admin:
+  enabled: true
+  backend_tls_scan:
+`
+	tests := []struct {
+		name   string
+		scan   string
+		errMsg string
+	}{
+		{name: "valid", scan: "    enabled: true\n    interval: 12h\n    timeout: 5s\n    expiry_threshold: 336h\n    rate: 0.5\n"},
+		{name: "negative threshold", scan: "    enabled: true\n    expiry_threshold: -1h\n", errMsg: "admin.backend_tls_scan: interval, timeout and expiry_threshold must be >= 0"},
+		{name: "negative rate", scan: "    enabled: true\n    rate: -1\n", errMsg: "admin.backend_tls_scan: rate must be >= 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(base + tt.scan))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateConfigDrift(t *testing.T) {
+	base := `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
 routes:
   - id: test
-    path: /api/v1
-    path_prefix: true
-    strip_prefix: true
-    rewrite:
-      prefix: /v2
+    path: /test
     backends:
       - url: http://localhost:9000
-`,
-			wantErr: true,
-		},
-		{
-			name: "regex without replacement",
-			yaml: `
+admin:
+  enabled: true
+  config_drift:
+    enabled: true
+`
+	tests := []struct {
+		name   string
+		extra  string
+		errMsg string
+	}{
+		{name: "valid", extra: "    interval: 10s\n    grace_period: 1m\nredis:\n  address: localhost:6379\n"},
+		{name: "no redis", extra: "", errMsg: "admin.config_drift: requires redis.address"},
+		{name: "negative grace", extra: "    grace_period: -1s\nredis:\n  address: localhost:6379\n", errMsg: "admin.config_drift: interval and grace_period must be >= 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(base + tt.extra))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateUpstreamSwap(t *testing.T) {
+	base := `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
 routes:
   - id: test
-    path: /api
-    rewrite:
-      regex: "^/api$"
+    path: /test
     backends:
       - url: http://localhost:9000
-`,
-			wantErr: true,
-		},
-		{
-			name: "replacement without regex",
-			yaml: `
+admin:
+  enabled: true
+  upstream_swap:
+`
+	tests := []struct {
+		name   string
+		swap   string
+		errMsg string
+	}{
+		{name: "valid", swap: "    rollback_window: 30m\n    verify:\n      path: /ready\n      expected_status: 204\n      count: 5\n      timeout: 2s\n"},
+		{name: "negative window", swap: "    rollback_window: -1m\n", errMsg: "admin.upstream_swap: rollback_window, verify.timeout and verify.count must be >= 0"},
+		{name: "negative count", swap: "    verify:\n      count: -1\n", errMsg: "admin.upstream_swap: rollback_window, verify.timeout and verify.count must be >= 0"},
+		{name: "bad status", swap: "    verify:\n      expected_status: 700\n", errMsg: "admin.upstream_swap: verify.expected_status must be between 100 and 599"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(base + tt.swap))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateShadowAsync(t *testing.T) {
+	base := `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
+opa_policies:
+  authz:
+    url: http://opa:8181
+    policy_path: authz/allow
 routes:
   - id: test
-    path: /api
-    rewrite:
-      replacement: "/v2"
+    path: /test
     backends:
       - url: http://localhost:9000
-`,
-			wantErr: true,
-		},
-		{
-			name: "invalid regex",
-			yaml: `
+`
+	tests := []struct {
+		name   string
+		route  string
+		errMsg string
+	}{
+		{name: "valid", route: "    waf:\n      enabled: true\n      shadow_async: true\n    opa:\n      enabled: true\n      url: http://opa:8181\n      policy_path: authz/allow\n      shadow_async: true\n      shadow_policy_path: authz/candidate\n    lua:\n      enabled: true\n      shadow_async: true\n      shadow_request_script: return\n"},
+		{name: "opa shadow policy without async", route: "    opa:\n      enabled: true\n      url: http://opa:8181\n      policy_path: authz/allow\n      shadow_policy_path: authz/candidate\n", errMsg: "route test: opa.shadow_policy_path requires shadow_async"},
+		{name: "opa ref with async", route: "    opa:\n      enabled: true\n      ref: authz\n      shadow_async: true\n", errMsg: "opa with ref only allows timeout and fail_open overrides"},
+		{name: "lua shadow script without async", route: "    lua:\n      enabled: true\n      shadow_request_script: return\n", errMsg: "route test: lua.shadow_request_script requires shadow_async"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(base + tt.route))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidatePeerFailover(t *testing.T) {
+	base := `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
 routes:
   - id: test
-    path: /api
-    rewrite:
-      regex: "[invalid"
-      replacement: "/v2"
+    path: /test
     backends:
       - url: http://localhost:9000
-`,
-			wantErr: true,
-		},
-		{
-			name: "empty rewrite is valid no-op",
-			yaml: `
+`
+	routeOn := "    peer_failover:\n      enabled: true\n"
+	peers := "peer_failover:\n  secret: s3cret\n  peers:\n    - name: eu\n      url: https://eu.example.com\n"
+	tests := []struct {
+		name   string
+		yaml   string
+		errMsg string
+	}{
+		{name: "valid", yaml: base + routeOn + "      peers: [eu]\n" + peers},
+		{name: "no peers", yaml: base + routeOn, errMsg: "route test: peer_failover requires peer_failover.peers in the global config"},
+		{name: "unknown peer", yaml: base + routeOn + "      peers: [us]\n" + peers, errMsg: `route test: peer_failover peer "us" not found in peer_failover.peers`},
+		{name: "missing secret", yaml: base + "peer_failover:\n  peers:\n    - name: eu\n      url: https://eu.example.com\n", errMsg: "peer_failover.secret is required when peers are configured"},
+		{name: "duplicate name", yaml: base + peers + "    - name: eu\n      url: https://eu2.example.com\n", errMsg: `peer_failover.peers[1]: duplicate name "eu"`},
+		{name: "bad url", yaml: base + "peer_failover:\n  secret: s3cret\n  peers:\n    - name: eu\n      url: eu.example.com\n", errMsg: "peer_failover.peers[eu]: url must start with http:// or https://"},
+		{name: "cert without key", yaml: base + peers + "      tls:\n        cert_file: /etc/peer.crt\n", errMsg: "peer_failover.peers[eu]: tls.cert_file and tls.key_file must be set together"},
+		{name: "negative breaker", yaml: base + peers + "      circuit_breaker:\n        failure_threshold: -1\n", errMsg: "peer_failover.peers[eu]: circuit_breaker values must be >= 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateBreakGlass(t *testing.T) {
+	base := `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
 routes:
   - id: test
-    path: /api
-    rewrite: {}
+    path: /test
     backends:
       - url: http://localhost:9000
-`,
-			wantErr: false,
-		},
+    break_glass:
+      enabled: true
+`
+	tests := []struct {
+		name   string
+		yaml   string
+		errMsg string
+	}{
+		{name: "valid", yaml: base + "      bypass: [auth, waf]\n      max_ttl: 30m\n"},
+		{name: "no bypass", yaml: base, errMsg: "route test: break_glass.bypass must list at least one feature"},
+		{name: "empty name", yaml: base + "      bypass: [auth, \"\"]\n", errMsg: "route test: break_glass.bypass[1] must not be empty"},
+		{name: "duplicate", yaml: base + "      bypass: [waf, waf]\n", errMsg: `route test: break_glass.bypass lists "waf" twice`},
+		{name: "negative ttl", yaml: base + "      bypass: [waf]\n      max_ttl: -1m\n", errMsg: "route test: break_glass.max_ttl must be >= 0"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			loader := NewLoader()
-			_, err := loader.Parse([]byte(tt.yaml))
-			if tt.wantErr && err == nil {
-				t.Error("expected error, got nil")
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
 			}
-			if !tt.wantErr && err != nil {
-				t.Errorf("unexpected error: %v", err)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
 			}
 		})
 	}
 }
 
-func TestLoaderValidateRateLimitKey(t *testing.T) {
-	tests := []struct {
-		name    string
-		yaml    string
-		wantErr bool
-		errMsg  string
-	}{
-		{
-			name: "valid header key",
-			yaml: `
+func TestLoaderValidateMultipartFields(t *testing.T) {
+	base := `
 listeners:
   - id: "http"
     address: ":8080"
@@ -4314,37 +5141,90 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
+    multipart_fields:
       enabled: true
-      rate: 100
-      period: 1m
-      key: "header:X-Tenant-ID"
-`,
-			wantErr: false,
-		},
-		{
-			name: "valid cookie key",
-			yaml: `
+`
+	tests := []struct {
+		name   string
+		yaml   string
+		errMsg string
+	}{
+		{name: "valid", yaml: base + "      fields: [tenant_id, content_sha256]\n      max_scan_bytes: 65536\n      on_limit: reject\n"},
+		{name: "no fields", yaml: base, errMsg: "route test: multipart_fields.fields must list at least one field"},
+		{name: "duplicate", yaml: base + "      fields: [a, a]\n", errMsg: `route test: multipart_fields.fields lists "a" twice`},
+		{name: "negative scan", yaml: base + "      fields: [a]\n      max_scan_bytes: -1\n", errMsg: "route test: multipart_fields.max_scan_bytes and max_field_size must be >= 0"},
+		{name: "bad on_limit", yaml: base + "      fields: [a]\n      on_limit: drop\n", errMsg: "route test: multipart_fields.on_limit must be 'continue' or 'reject'"},
+		{name: "passthrough", yaml: base + "      fields: [a]\n    passthrough: true\n", errMsg: "route test: multipart_fields is incompatible with passthrough"},
+		{name: "tenant form key", yaml: base + "      fields: [tenant_id]\ntenants:\n  enabled: true\n  key: form:tenant_id\n  tenants:\n    acme: {}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateAuthRequirements(t *testing.T) {
+	base := `
 listeners:
   - id: "http"
     address: ":8080"
     protocol: "http"
+authentication:
+  api_key:
+    enabled: true
+    header: X-API-Key
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      rate: 100
-      period: 1m
-      key: "cookie:session"
-`,
-			wantErr: false,
-		},
-		{
-			name: "valid jwt_claim key",
-			yaml: `
+    auth:
+      required: true
+      methods: [api_key]
+      requirements:
+`
+	tests := []struct {
+		name   string
+		yaml   string
+		errMsg string
+	}{
+		{name: "valid", yaml: base + "        scopes_any: [orders:read]\n        claims:\n          tier: [gold, platinum]\n          email_verified: true\n        methods:\n          DELETE:\n            roles_any: [admin]\n        verbose_errors: false\n"},
+		{name: "not required", yaml: strings.Replace(base, "required: true", "required: false", 1) + "        roles_any: [admin]\n", errMsg: "route test: auth.requirements requires auth.required"},
+		{name: "empty role", yaml: base + "        roles_any: [admin, \"\"]\n", errMsg: "route test: auth.requirements.roles_any must not contain empty values"},
+		{name: "bad method", yaml: base + "        methods:\n          FETCH:\n            roles_any: [admin]\n", errMsg: `route test: auth.requirements.methods: invalid method "FETCH"`},
+		{name: "empty claim list", yaml: base + "        claims:\n          tier: []\n", errMsg: "route test: auth.requirements.claims.tier must list at least one value"},
+		{name: "nested claim value", yaml: base + "        claims:\n          tier: {a: b}\n", errMsg: "route test: auth.requirements.claims.tier values must be strings, numbers or booleans"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateServeStaleOnTimeout(t *testing.T) {
+	base := `
 listeners:
   - id: "http"
     address: ":8080"
@@ -4354,36 +5234,48 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
+    cache:
       enabled: true
-      rate: 100
-      period: 1m
-      key: "jwt_claim:sub"
-`,
-			wantErr: false,
-		},
-		{
-			name: "valid ip key",
-			yaml: `
-listeners:
-  - id: "http"
-    address: ":8080"
-    protocol: "http"
-routes:
-  - id: test
-    path: /test
-    backends:
-      - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      rate: 100
-      period: 1m
-      key: "ip"
-`,
-			wantErr: false,
-		},
+      serve_stale_on_timeout: true
+`
+	tests := []struct {
+		name   string
+		yaml   string
+		errMsg string
+	}{
+		{name: "valid", yaml: base + "      soft_timeout: 200ms\n      stale_if_error: 1m\n    timeout_policy:\n      request: 5s\n"},
+		{name: "missing soft timeout", yaml: base + "      stale_if_error: 1m\n", errMsg: "route test: cache.serve_stale_on_timeout requires cache.soft_timeout"},
+		{name: "missing stale_if_error", yaml: base + "      soft_timeout: 200ms\n", errMsg: "route test: cache.serve_stale_on_timeout requires cache.stale_if_error"},
+		{name: "negative soft timeout", yaml: base + "      soft_timeout: -1s\n", errMsg: "route test: cache.soft_timeout must be >= 0"},
+		{name: "negative max varies", yaml: base + "      soft_timeout: 200ms\n      stale_if_error: 1m\n      max_varies: -1\n", errMsg: "route test: cache.max_varies must be >= 0"},
+		{name: "soft timeout not below request timeout", yaml: base + "      soft_timeout: 5s\n      stale_if_error: 1m\n    timeout_policy:\n      request: 5s\n", errMsg: "route test: cache.soft_timeout must be less than timeout_policy.request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
 		{
-			name: "valid client_id key",
+			name: "valid CIDRs",
 			yaml: `
 listeners:
   - id: "http"
@@ -4394,16 +5286,17 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      rate: 100
-      period: 1m
-      key: "client_id"
+trusted_proxies:
+  cidrs:
+    - "10.0.0.0/8"
+    - "172.16.0.0/12"
+    - "192.168.0.0/16"
+  max_hops: 2
 `,
 			wantErr: false,
 		},
 		{
-			name: "key and per_ip mutually exclusive",
+			name: "valid bare IP",
 			yaml: `
 listeners:
   - id: "http"
@@ -4414,18 +5307,14 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      rate: 100
-      period: 1m
-      key: "header:X-Tenant-ID"
-      per_ip: true
+trusted_proxies:
+  cidrs:
+    - "127.0.0.1"
 `,
-			wantErr: true,
-			errMsg:  "mutually exclusive",
+			wantErr: false,
 		},
 		{
-			name: "invalid key prefix",
+			name: "invalid CIDR",
 			yaml: `
 listeners:
   - id: "http"
@@ -4436,17 +5325,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      rate: 100
-      period: 1m
-      key: "query:user_id"
+trusted_proxies:
+  cidrs:
+    - "not-a-cidr"
 `,
 			wantErr: true,
-			errMsg:  "invalid rate_limit.key",
+			errMsg:  "trusted_proxies.cidrs",
 		},
 		{
-			name: "header key with empty name",
+			name: "negative max_hops",
 			yaml: `
 listeners:
   - id: "http"
@@ -4457,17 +5344,42 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      rate: 100
-      period: 1m
-      key: "header:"
+trusted_proxies:
+  cidrs:
+    - "10.0.0.0/8"
+  max_hops: -1
 `,
 			wantErr: true,
-			errMsg:  "non-empty header name",
+			errMsg:  "max_hops must be >= 0",
 		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateResponseLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
 		{
-			name: "cookie key with empty name",
+			name: "valid global response limit",
 			yaml: `
 listeners:
   - id: "http"
@@ -4478,17 +5390,15 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      rate: 100
-      period: 1m
-      key: "cookie:"
+response_limit:
+  enabled: true
+  max_size: 1048576
+  action: reject
 `,
-			wantErr: true,
-			errMsg:  "non-empty cookie name",
+			wantErr: false,
 		},
 		{
-			name: "jwt_claim key with empty name",
+			name: "valid per-route truncate",
 			yaml: `
 listeners:
   - id: "http"
@@ -4499,113 +5409,86 @@ routes:
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
+    response_limit:
       enabled: true
-      rate: 100
-      period: 1m
-      key: "jwt_claim:"
+      max_size: 5242880
+      action: truncate
 `,
-			wantErr: true,
-			errMsg:  "non-empty claim name",
+			wantErr: false,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			loader := NewLoader()
-			_, err := loader.Parse([]byte(tt.yaml))
-			if tt.wantErr && err == nil {
-				t.Error("expected error, got nil")
-			}
-			if !tt.wantErr && err != nil {
-				t.Errorf("unexpected error: %v", err)
-			}
-			if tt.wantErr && err != nil && tt.errMsg != "" {
-				if !strings.Contains(err.Error(), tt.errMsg) {
-					t.Errorf("error %q should contain %q", err.Error(), tt.errMsg)
-				}
-			}
-		})
-	}
-}
-
-func TestLoaderValidateBotDetection(t *testing.T) {
-	tests := []struct {
-		name    string
-		yaml    string
-		wantErr bool
-		errMsg  string
-	}{
 		{
-			name: "valid bot detection",
+			name: "negative max_size rejected",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    bot_detection:
-      enabled: true
-      deny: ["(?i)googlebot"]
+response_limit:
+  enabled: true
+  max_size: -1
 `,
-			wantErr: false,
+			wantErr: true,
+			errMsg:  "response_limit.max_size must be >= 0",
 		},
 		{
-			name: "invalid regex in deny",
+			name: "zero max_size when enabled rejected",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    bot_detection:
-      enabled: true
-      deny: ["[invalid"]
+response_limit:
+  enabled: true
+  max_size: 0
 `,
 			wantErr: true,
-			errMsg:  "invalid regex",
+			errMsg:  "response_limit.max_size must be set when enabled",
 		},
 		{
-			name: "empty deny list",
+			name: "invalid action rejected",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    bot_detection:
-      enabled: true
+response_limit:
+  enabled: true
+  max_size: 1048576
+  action: drop
 `,
 			wantErr: true,
-			errMsg:  "requires at least one pattern",
+			errMsg:  "response_limit.action must be one of",
 		},
 		{
-			name: "global bot detection",
+			name: "disabled config skips validation",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
-bot_detection:
-  enabled: true
-  deny: ["bot"]
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
+response_limit:
+  enabled: false
+  max_size: -999
 `,
 			wantErr: false,
 		},
@@ -4615,20 +5498,20 @@ routes:
 		t.Run(tt.name, func(t *testing.T) {
 			loader := NewLoader()
 			_, err := loader.Parse([]byte(tt.yaml))
-			if tt.wantErr && err == nil {
-				t.Error("expected error, got nil")
-			}
-			if !tt.wantErr && err != nil {
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
-			if tt.wantErr && err != nil && tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
-				t.Errorf("error %q should contain %q", err.Error(), tt.errMsg)
-			}
 		})
 	}
 }
 
-func TestLoaderValidateProxyRateLimit(t *testing.T) {
+func TestLoaderValidateResponseBuffering(t *testing.T) {
 	tests := []struct {
 		name    string
 		yaml    string
@@ -4636,144 +5519,121 @@ func TestLoaderValidateProxyRateLimit(t *testing.T) {
 		errMsg  string
 	}{
 		{
-			name: "valid proxy rate limit",
+			name: "valid global and per-route",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    proxy_rate_limit:
+    response_buffering:
       enabled: true
-      rate: 100
-      period: 1s
+      max_size: 536870912
+response_buffering:
+  spill_dir: /tmp
+  disk_budget: 2147483648
+  memory_threshold: 1048576
 `,
 			wantErr: false,
 		},
 		{
-			name: "invalid rate zero",
+			name: "threshold above max_size rejected",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    proxy_rate_limit:
+    response_buffering:
       enabled: true
-      rate: 0
+      memory_threshold: 2048
+      max_size: 1024
 `,
 			wantErr: true,
-			errMsg:  "proxy_rate_limit.rate must be > 0",
+			errMsg:  "response_buffering.memory_threshold must not exceed max_size",
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			loader := NewLoader()
-			_, err := loader.Parse([]byte(tt.yaml))
-			if tt.wantErr && err == nil {
-				t.Error("expected error, got nil")
-			}
-			if !tt.wantErr && err != nil {
-				t.Errorf("unexpected error: %v", err)
-			}
-			if tt.wantErr && err != nil && tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
-				t.Errorf("error %q should contain %q", err.Error(), tt.errMsg)
-			}
-		})
-	}
-}
-
-func TestLoaderValidateMockResponse(t *testing.T) {
-	tests := []struct {
-		name    string
-		yaml    string
-		wantErr bool
-		errMsg  string
-	}{
 		{
-			name: "valid mock response",
+			name: "negative disk_budget rejected",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    mock_response:
-      enabled: true
-      status_code: 200
-      body: '{"test": true}'
+response_buffering:
+  disk_budget: -1
 `,
-			wantErr: false,
+			wantErr: true,
+			errMsg:  "response_buffering.disk_budget must be >= 0",
 		},
 		{
-			name: "invalid status code",
+			name: "missing spill_dir rejected",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    mock_response:
-      enabled: true
-      status_code: 999
+response_buffering:
+  spill_dir: /nonexistent/runway-spill
 `,
 			wantErr: true,
-			errMsg:  "mock_response.status_code must be 100-599",
+			errMsg:  "must be an existing directory",
 		},
 		{
-			name: "echo and mock mutually exclusive",
+			name: "passthrough conflict rejected",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
-    echo: true
-    mock_response:
+    backends:
+      - url: http://localhost:9000
+    passthrough: true
+    response_buffering:
       enabled: true
 `,
 			wantErr: true,
-			errMsg:  "echo is mutually exclusive with mock_response",
+			errMsg:  "response_buffering",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			loader := NewLoader()
-			_, err := loader.Parse([]byte(tt.yaml))
-			if tt.wantErr && err == nil {
-				t.Error("expected error, got nil")
-			}
-			if !tt.wantErr && err != nil {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
-			if tt.wantErr && err != nil && tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
-				t.Errorf("error %q should contain %q", err.Error(), tt.errMsg)
-			}
 		})
 	}
 }
 
-func TestLoaderValidateTieredRateLimits(t *testing.T) {
+func TestLoaderValidateHandlerFallback(t *testing.T) {
 	tests := []struct {
 		name    string
 		yaml    string
@@ -4781,166 +5641,3221 @@ func TestLoaderValidateTieredRateLimits(t *testing.T) {
 		errMsg  string
 	}{
 		{
-			name: "valid tiered rate limits",
+			name: "valid protocol fallback",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      tier_key: "header:X-Tier"
-      default_tier: free
-      tiers:
-        free:
-          rate: 10
-          period: 1s
-        premium:
-          rate: 100
-          period: 1s
+    protocol:
+      type: http_to_grpc
+      fallback:
+        enabled: true
+        triggers:
+          grpc_codes: [UNIMPLEMENTED]
+          connection_errors: true
+        backends:
+          - url: http://legacy:8080
+        max_rate: 50
 `,
 			wantErr: false,
 		},
 		{
-			name: "tiers and rate mutually exclusive",
+			name: "missing target rejected",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      rate: 10
-      tiers:
-        free:
-          rate: 10
-          period: 1s
-      default_tier: free
-      tier_key: "header:X-Tier"
+    protocol:
+      type: http_to_grpc
+      fallback:
+        enabled: true
+        triggers:
+          statuses: [501]
 `,
 			wantErr: true,
-			errMsg:  "tiers and rate_limit.rate are mutually exclusive",
+			errMsg:  "protocol.fallback: requires backends or upstream",
 		},
 		{
-			name: "default tier missing from tiers",
+			name: "no triggers rejected",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      tier_key: "header:X-Tier"
-      default_tier: nonexistent
-      tiers:
-        free:
-          rate: 10
-          period: 1s
+    protocol:
+      type: http_to_grpc
+      fallback:
+        enabled: true
+        backends:
+          - url: http://legacy:8080
 `,
 			wantErr: true,
-			errMsg:  "must exist in tiers",
+			errMsg:  "requires at least one trigger",
 		},
 		{
-			name: "tier_key missing",
+			name: "unknown grpc code rejected",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      default_tier: free
-      tiers:
-        free:
-          rate: 10
-          period: 1s
+    protocol:
+      type: http_to_grpc
+      fallback:
+        enabled: true
+        triggers:
+          grpc_codes: [NOT_IMPLEMENTED]
+        backends:
+          - url: http://legacy:8080
 `,
 			wantErr: true,
-			errMsg:  "tier_key is required",
+			errMsg:  "unknown gRPC error code",
 		},
 		{
-			name: "invalid tier_key prefix",
+			name: "unknown upstream rejected",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      tier_key: "cookie:tier"
-      default_tier: free
-      tiers:
-        free:
-          rate: 10
-          period: 1s
+    protocol:
+      type: http_to_grpc
+      fallback:
+        enabled: true
+        triggers:
+          statuses: [501]
+        upstream: legacy
 `,
 			wantErr: true,
-			errMsg:  "tier_key must be",
+			errMsg:  "references unknown upstream",
 		},
 		{
-			name: "tier with zero rate",
+			name: "fallback without lambda rejected",
 			yaml: `
 listeners:
-  - id: http
+  - id: "http"
     address: ":8080"
-    protocol: http
+    protocol: "http"
 routes:
   - id: test
     path: /test
     backends:
       - url: http://localhost:9000
-    rate_limit:
-      enabled: true
-      tier_key: "header:X-Tier"
-      default_tier: free
-      tiers:
-        free:
-          rate: 0
-          period: 1s
+    lambda:
+      fallback:
+        enabled: true
+        triggers:
+          statuses: [502]
+        backends:
+          - url: http://legacy:8080
 `,
 			wantErr: true,
-			errMsg:  "rate must be > 0",
+			errMsg:  "lambda.fallback requires lambda to be configured",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			loader := NewLoader()
-			_, err := loader.Parse([]byte(tt.yaml))
-			if tt.wantErr && err == nil {
-				t.Error("expected error, got nil")
-			}
-			if !tt.wantErr && err != nil {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
-			if tt.wantErr && err != nil && tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
-				t.Errorf("error %q should contain %q", err.Error(), tt.errMsg)
-			}
 		})
 	}
 }
+
+func TestLoaderValidateCookieMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid cookie exact match",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      cookies:
+        - name: beta
+          value: "true"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid cookie regex",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      cookies:
+        - name: variant
+          regex: "^(a|b)$"
+`,
+			wantErr: false,
+		},
+		{
+			name: "cookie missing name",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      cookies:
+        - value: "true"
+`,
+			wantErr: true,
+			errMsg:  "match cookie 0: name is required",
+		},
+		{
+			name: "cookie invalid regex",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      cookies:
+        - name: variant
+          regex: "[invalid"
+`,
+			wantErr: true,
+			errMsg:  "invalid regex",
+		},
+		{
+			name: "cookie must set exactly one criterion",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      cookies:
+        - name: beta
+          value: "true"
+          regex: "^true$"
+`,
+			wantErr: true,
+			errMsg:  "must set exactly one of",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateBodyMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid body exact match",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      body:
+        - name: "action"
+          value: "create"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid body present check",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      body:
+        - name: "metadata"
+          present: true
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid body regex",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      body:
+        - name: "user.role"
+          regex: "^admin.*$"
+`,
+			wantErr: false,
+		},
+		{
+			name: "body missing name",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      body:
+        - value: "create"
+`,
+			wantErr: true,
+			errMsg:  "match body 0: name is required",
+		},
+		{
+			name: "body no criterion set",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      body:
+        - name: "action"
+`,
+			wantErr: true,
+			errMsg:  "must set exactly one of",
+		},
+		{
+			name: "body two criteria set",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      body:
+        - name: "action"
+          value: "create"
+          regex: "^create$"
+`,
+			wantErr: true,
+			errMsg:  "must set exactly one of",
+		},
+		{
+			name: "body invalid regex",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      body:
+        - name: "action"
+          regex: "[invalid"
+`,
+			wantErr: true,
+			errMsg:  "invalid regex",
+		},
+		{
+			name: "negative max_match_body_size",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+      max_match_body_size: -1
+`,
+			wantErr: true,
+			errMsg:  "max_match_body_size must be >= 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateTransportCertFiles(t *testing.T) {
+	base := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+`
+
+	dir := t.TempDir()
+	certFile := dir + "/client.crt"
+	keyFile := dir + "/client.key"
+	os.WriteFile(certFile, []byte("fake-cert"), 0600)
+	os.WriteFile(keyFile, []byte("fake-key"), 0600)
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{
+			name: "valid both cert and key",
+			yaml: base + fmt.Sprintf(`
+transport:
+  cert_file: %s
+  key_file: %s
+`, certFile, keyFile),
+			wantErr: false,
+		},
+		{
+			name: "cert without key",
+			yaml: base + fmt.Sprintf(`
+transport:
+  cert_file: %s
+`, certFile),
+			wantErr: true,
+		},
+		{
+			name: "key without cert",
+			yaml: base + fmt.Sprintf(`
+transport:
+  key_file: %s
+`, keyFile),
+			wantErr: true,
+		},
+		{
+			name: "cert file does not exist",
+			yaml: base + `
+transport:
+  cert_file: /nonexistent/client.crt
+  key_file: /nonexistent/client.key
+`,
+			wantErr: true,
+		},
+		{
+			name: "upstream cert without key",
+			yaml: base + fmt.Sprintf(`
+upstreams:
+  api:
+    backends:
+      - url: http://localhost:9001
+    transport:
+      cert_file: %s
+`, certFile),
+			wantErr: true,
+		},
+		{
+			name: "valid upstream cert and key",
+			yaml: base + fmt.Sprintf(`
+upstreams:
+  api:
+    backends:
+      - url: http://localhost:9001
+    transport:
+      cert_file: %s
+      key_file: %s
+`, certFile, keyFile),
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateRewrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{
+			name: "valid prefix rewrite",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /api/v1
+    path_prefix: true
+    rewrite:
+      prefix: /v2
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid regex rewrite",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /api
+    rewrite:
+      regex: "^/api/(\\d+)$"
+      replacement: "/v2/$1"
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid host-only rewrite",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /api
+    rewrite:
+      host: backend.internal
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid prefix with host rewrite",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /api/v1
+    path_prefix: true
+    rewrite:
+      prefix: /v2
+      host: backend.internal
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: false,
+		},
+		{
+			name: "prefix and regex mutually exclusive",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /api
+    path_prefix: true
+    rewrite:
+      prefix: /v2
+      regex: "^/api$"
+      replacement: "/v2"
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: true,
+		},
+		{
+			name: "prefix requires path_prefix",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /api/v1
+    rewrite:
+      prefix: /v2
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: true,
+		},
+		{
+			name: "prefix and strip_prefix mutually exclusive",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /api/v1
+    path_prefix: true
+    strip_prefix: true
+    rewrite:
+      prefix: /v2
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: true,
+		},
+		{
+			name: "regex without replacement",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /api
+    rewrite:
+      regex: "^/api$"
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: true,
+		},
+		{
+			name: "replacement without regex",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /api
+    rewrite:
+      replacement: "/v2"
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: true,
+		},
+		{
+			name: "invalid regex",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /api
+    rewrite:
+      regex: "[invalid"
+      replacement: "/v2"
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: true,
+		},
+		{
+			name: "empty rewrite is valid no-op",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /api
+    rewrite: {}
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateRateLimitKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid header key",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "header:X-Tenant-ID"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid cookie key",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "cookie:session"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid jwt_claim key",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "jwt_claim:sub"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid ip key",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "ip"
+`,
+			wantErr: false,
+		},
+		{
+			name: "valid client_id key",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "client_id"
+`,
+			wantErr: false,
+		},
+		{
+			name: "key and per_ip mutually exclusive",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "header:X-Tenant-ID"
+      per_ip: true
+`,
+			wantErr: true,
+			errMsg:  "mutually exclusive",
+		},
+		{
+			name: "invalid key prefix",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "query:user_id"
+`,
+			wantErr: true,
+			errMsg:  "invalid rate_limit.key",
+		},
+		{
+			name: "header key with empty name",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "header:"
+`,
+			wantErr: true,
+			errMsg:  "non-empty header name",
+		},
+		{
+			name: "cookie key with empty name",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "cookie:"
+`,
+			wantErr: true,
+			errMsg:  "non-empty cookie name",
+		},
+		{
+			name: "jwt_claim key with empty name",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "jwt_claim:"
+`,
+			wantErr: true,
+			errMsg:  "non-empty claim name",
+		},
+		{
+			name: "valid baggage key",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    baggage:
+      enabled: true
+      tags:
+        - name: tenant
+          source: "jwt_claim:tenant"
+          header: X-Tenant
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "baggage:tenant"
+`,
+			wantErr: false,
+		},
+		{
+			name: "baggage key without a baggage tag",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "baggage:tenant"
+`,
+			wantErr: true,
+			errMsg:  "inbound baggage members are not trusted",
+		},
+		{
+			name: "baggage key with empty name",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "baggage:"
+`,
+			wantErr: true,
+			errMsg:  "non-empty baggage key",
+		},
+		{
+			name: "valid body key",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "body:user.id"
+`,
+			wantErr: false,
+		},
+		{
+			name: "body key with empty path",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+      key: "body:"
+`,
+			wantErr: true,
+			errMsg:  "non-empty body field path",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr && err != nil && tt.errMsg != "" {
+				if !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("error %q should contain %q", err.Error(), tt.errMsg)
+				}
+			}
+		})
+	}
+}
+
+func TestLoaderValidateBotDetection(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid bot detection",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    bot_detection:
+      enabled: true
+      deny: ["(?i)googlebot"]
+`,
+			wantErr: false,
+		},
+		{
+			name: "invalid regex in deny",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    bot_detection:
+      enabled: true
+      deny: ["[invalid"]
+`,
+			wantErr: true,
+			errMsg:  "invalid regex",
+		},
+		{
+			name: "empty deny list",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    bot_detection:
+      enabled: true
+`,
+			wantErr: true,
+			errMsg:  "requires at least one pattern",
+		},
+		{
+			name: "global bot detection",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+bot_detection:
+  enabled: true
+  deny: ["bot"]
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+`,
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr && err != nil && tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("error %q should contain %q", err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateProxyRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid proxy rate limit",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    proxy_rate_limit:
+      enabled: true
+      rate: 100
+      period: 1s
+`,
+			wantErr: false,
+		},
+		{
+			name: "invalid rate zero",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    proxy_rate_limit:
+      enabled: true
+      rate: 0
+`,
+			wantErr: true,
+			errMsg:  "proxy_rate_limit.rate must be > 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr && err != nil && tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("error %q should contain %q", err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateMockResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid mock response",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    mock_response:
+      enabled: true
+      status_code: 200
+      body: '{"test": true}'
+`,
+			wantErr: false,
+		},
+		{
+			name: "invalid status code",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    mock_response:
+      enabled: true
+      status_code: 999
+`,
+			wantErr: true,
+			errMsg:  "mock_response.status_code must be 100-599",
+		},
+		{
+			name: "echo and mock mutually exclusive",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    echo: true
+    mock_response:
+      enabled: true
+`,
+			wantErr: true,
+			errMsg:  "echo is mutually exclusive with mock_response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr && err != nil && tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("error %q should contain %q", err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateTieredRateLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid tiered rate limits",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      tier_key: "header:X-Tier"
+      default_tier: free
+      tiers:
+        free:
+          rate: 10
+          period: 1s
+        premium:
+          rate: 100
+          period: 1s
+`,
+			wantErr: false,
+		},
+		{
+			name: "tiers and rate mutually exclusive",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 10
+      tiers:
+        free:
+          rate: 10
+          period: 1s
+      default_tier: free
+      tier_key: "header:X-Tier"
+`,
+			wantErr: true,
+			errMsg:  "tiers and rate_limit.rate are mutually exclusive",
+		},
+		{
+			name: "default tier missing from tiers",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      tier_key: "header:X-Tier"
+      default_tier: nonexistent
+      tiers:
+        free:
+          rate: 10
+          period: 1s
+`,
+			wantErr: true,
+			errMsg:  "must exist in tiers",
+		},
+		{
+			name: "tier_key missing",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      default_tier: free
+      tiers:
+        free:
+          rate: 10
+          period: 1s
+`,
+			wantErr: true,
+			errMsg:  "tier_key is required",
+		},
+		{
+			name: "invalid tier_key prefix",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      tier_key: "cookie:tier"
+      default_tier: free
+      tiers:
+        free:
+          rate: 10
+          period: 1s
+`,
+			wantErr: true,
+			errMsg:  "tier_key must be",
+		},
+		{
+			name: "tier with zero rate",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      tier_key: "header:X-Tier"
+      default_tier: free
+      tiers:
+        free:
+          rate: 0
+          period: 1s
+`,
+			wantErr: true,
+			errMsg:  "rate must be > 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr && err != nil && tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("error %q should contain %q", err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateAPIKeySelfService(t *testing.T) {
+	base := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid self service",
+			yaml: base + `
+authentication:
+  jwt:
+    enabled: true
+    secret: test-secret
+  api_key:
+    enabled: true
+    management:
+      enabled: true
+      self_service:
+        enabled: true
+        path_prefix: /account/keys
+        owner_claim: tenant_user
+        max_keys: 5
+        max_ttl: 720h
+        grace_period: 30m
+        create_rate_limit:
+          rate: 5
+          period: 1h
+          burst: 2
+webhooks:
+  enabled: true
+  endpoints:
+    - id: audit
+      url: https://hooks.example.com/keys
+      events: ["api_key.*"]
+`,
+		},
+		{
+			name: "requires jwt",
+			yaml: base + `
+authentication:
+  api_key:
+    enabled: true
+    management:
+      enabled: true
+      self_service:
+        enabled: true
+`,
+			wantErr: true,
+			errMsg:  "self_service requires authentication.jwt.enabled",
+		},
+		{
+			name: "root path prefix",
+			yaml: base + `
+authentication:
+  jwt:
+    enabled: true
+    secret: test-secret
+  api_key:
+    enabled: true
+    management:
+      enabled: true
+      self_service:
+        enabled: true
+        path_prefix: /
+`,
+			wantErr: true,
+			errMsg:  "self_service.path_prefix must start with /",
+		},
+		{
+			name: "negative max_keys",
+			yaml: base + `
+authentication:
+  jwt:
+    enabled: true
+    secret: test-secret
+  api_key:
+    enabled: true
+    management:
+      enabled: true
+      self_service:
+        enabled: true
+        max_keys: -1
+`,
+			wantErr: true,
+			errMsg:  "self_service.max_keys must be >= 0",
+		},
+		{
+			name: "invalid create rate limit",
+			yaml: base + `
+authentication:
+  jwt:
+    enabled: true
+    secret: test-secret
+  api_key:
+    enabled: true
+    management:
+      enabled: true
+      self_service:
+        enabled: true
+        create_rate_limit:
+          rate: 5
+          burst: 1
+`,
+			wantErr: true,
+			errMsg:  "self_service.create_rate_limit.period must be > 0",
+		},
+		{
+			name: "invalid list rate limit",
+			yaml: base + `
+authentication:
+  jwt:
+    enabled: true
+    secret: test-secret
+  api_key:
+    enabled: true
+    management:
+      enabled: true
+      self_service:
+        enabled: true
+        list_rate_limit:
+          rate: 60
+          period: 1m
+`,
+			wantErr: true,
+			errMsg:  "self_service.list_rate_limit.burst must be > 0",
+		},
+		{
+			name: "shadows a route",
+			yaml: base + `
+authentication:
+  jwt:
+    enabled: true
+    secret: test-secret
+  api_key:
+    enabled: true
+    management:
+      enabled: true
+      self_service:
+        enabled: true
+        path_prefix: /test
+`,
+			wantErr: true,
+			errMsg:  `path_prefix "/test" shadows route test`,
+		},
+		{
+			name: "unknown listener",
+			yaml: base + `
+authentication:
+  jwt:
+    enabled: true
+    secret: test-secret
+  api_key:
+    enabled: true
+    management:
+      enabled: true
+      self_service:
+        enabled: true
+        listeners: [admin]
+`,
+			wantErr: true,
+			errMsg:  `self_service.listeners: unknown listener "admin"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateRetryBudgetMode(t *testing.T) {
+	base := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid distributed pool",
+			yaml: base + `
+redis:
+  address: localhost:6379
+retry_budgets:
+  shared:
+    ratio: 0.1
+    mode: distributed
+    sync_interval: 500ms
+`,
+		},
+		{
+			name: "distributed requires redis",
+			yaml: base + `
+retry_budgets:
+  shared:
+    ratio: 0.1
+    mode: distributed
+`,
+			wantErr: true,
+			errMsg:  "distributed mode requires redis configuration",
+		},
+		{
+			name: "unknown mode",
+			yaml: base + `
+retry_budgets:
+  shared:
+    ratio: 0.1
+    mode: global
+`,
+			wantErr: true,
+			errMsg:  "mode must be \"local\" or \"distributed\"",
+		},
+		{
+			name: "negative sync interval",
+			yaml: base + `
+retry_budgets:
+  shared:
+    ratio: 0.1
+    sync_interval: -1s
+`,
+			wantErr: true,
+			errMsg:  "sync_interval must be >= 0",
+		},
+		{
+			name: "inline budget cannot be distributed",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+redis:
+  address: localhost:6379
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    retry_policy:
+      max_retries: 2
+      budget:
+        ratio: 0.1
+        mode: distributed
+`,
+			wantErr: true,
+			errMsg:  "only supported for retry_budgets pools",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateSharedAuthDefinitions(t *testing.T) {
+	base := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+ext_auth_services:
+  main-authz:
+    url: http://authz:9000/check
+opa_policies:
+  authz:
+    url: http://opa:8181
+    policy_path: authz/allow
+backend_auth_providers:
+  billing:
+    type: oauth2_client_credentials
+    token_url: https://auth.example.com/token
+    client_id: gw
+    client_secret: s3cret
+token_exchangers:
+  internal:
+    validation_mode: introspection
+    introspection_url: https://idp.example.com/introspect
+    client_id: gw
+    client_secret: s3cret
+    issuer: https://gw.example.com
+    signing_algorithm: HS256
+    signing_secret: c2VjcmV0
+    token_lifetime: 15m
+`
+	route := func(extra string) string {
+		return base + `
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+` + extra
+	}
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid refs with overrides",
+			yaml: route(`
+    ext_auth:
+      ref: main-authz
+      timeout: 2s
+      fail_open: true
+    opa:
+      ref: authz
+    backend_auth:
+      ref: billing
+`),
+		},
+		{
+			name: "valid token_exchange ref",
+			yaml: route(`
+    auth:
+      required: true
+    token_exchange:
+      ref: internal
+`),
+		},
+		{
+			name:    "dangling token_exchange ref",
+			yaml:    route("    auth:\n      required: true\n    token_exchange:\n      ref: other\n"),
+			wantErr: true,
+			errMsg:  `token_exchange ref "other" not found in token_exchangers`,
+		},
+		{
+			name:    "token_exchange ref with other fields",
+			yaml:    route("    auth:\n      required: true\n    token_exchange:\n      ref: internal\n      issuer: https://other.example.com\n"),
+			wantErr: true,
+			errMsg:  "token_exchange with ref cannot set other fields",
+		},
+		{
+			name:    "token_exchange ref requires auth",
+			yaml:    route("    token_exchange:\n      ref: internal\n"),
+			wantErr: true,
+			errMsg:  "token_exchange requires auth.required to be true",
+		},
+		{
+			name:    "dangling ext_auth ref",
+			yaml:    route("    ext_auth:\n      ref: other\n"),
+			wantErr: true,
+			errMsg:  `ext_auth ref "other" not found in ext_auth_services`,
+		},
+		{
+			name:    "dangling opa ref",
+			yaml:    route("    opa:\n      ref: other\n"),
+			wantErr: true,
+			errMsg:  `opa ref "other" not found in opa_policies`,
+		},
+		{
+			name:    "dangling backend_auth ref",
+			yaml:    route("    backend_auth:\n      ref: other\n"),
+			wantErr: true,
+			errMsg:  `backend_auth ref "other" not found in backend_auth_providers`,
+		},
+		{
+			name:    "ref with non-overridable field",
+			yaml:    route("    ext_auth:\n      ref: main-authz\n      url: http://other:9000\n"),
+			wantErr: true,
+			errMsg:  "ext_auth with ref only allows timeout and fail_open overrides",
+		},
+		{
+			name:    "backend_auth ref with other fields",
+			yaml:    route("    backend_auth:\n      ref: billing\n      client_id: other\n"),
+			wantErr: true,
+			errMsg:  "backend_auth with ref cannot set other fields",
+		},
+		{
+			name: "invalid shared definition",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+ext_auth_services:
+  broken:
+    url: ftp://authz
+`,
+			wantErr: true,
+			errMsg:  "ext_auth_services[broken].url must start with http://, https://, or grpc://",
+		},
+		{
+			name: "shared definition cannot ref",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+opa_policies:
+  nested:
+    ref: authz
+`,
+			wantErr: true,
+			errMsg:  "opa_policies[nested]: ref is not allowed in a shared definition",
+		},
+		{
+			name: "invalid shared token exchanger",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+token_exchangers:
+  broken:
+    validation_mode: jwt
+    jwks_url: https://idp.example.com/jwks.json
+`,
+			wantErr: true,
+			errMsg:  "token_exchangers[broken].trusted_issuers is required for jwt validation mode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			_, err := loader.Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateRateLimitState(t *testing.T) {
+	base := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    rate_limit:
+      enabled: true
+      rate: 100
+      period: 1m
+`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid file store",
+			yaml: base + `
+rate_limit_state:
+  enabled: true
+  file: /var/lib/runway/ratelimit.json
+  save_interval: 15s
+  max_keys_per_route: 500
+`,
+		},
+		{
+			name: "file store requires file",
+			yaml: base + `
+rate_limit_state:
+  enabled: true
+`,
+			wantErr: true,
+			errMsg:  "file is required",
+		},
+		{
+			name: "redis store requires redis",
+			yaml: base + `
+rate_limit_state:
+  enabled: true
+  store: redis
+`,
+			wantErr: true,
+			errMsg:  "requires redis.address",
+		},
+		{
+			name: "unknown store rejected",
+			yaml: base + `
+rate_limit_state:
+  enabled: true
+  store: etcd
+`,
+			wantErr: true,
+			errMsg:  "store must be",
+		},
+		{
+			name: "negative max keys rejected",
+			yaml: base + `
+rate_limit_state:
+  enabled: true
+  file: /tmp/rl.json
+  max_keys_per_route: -1
+`,
+			wantErr: true,
+			errMsg:  "must be >= 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateUpstreamDNSDiscovery(t *testing.T) {
+	base := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    upstream: api
+`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid srv discovery",
+			yaml: base + `
+upstreams:
+  api:
+    dns_discovery:
+      enabled: true
+      name: api
+      domain: service.consul
+      nameserver: "10.0.0.53:53"
+      poll_interval: 10s
+      failback_delay: 1m
+`,
+		},
+		{
+			name: "valid full record name",
+			yaml: base + `
+upstreams:
+  api:
+    dns_discovery:
+      enabled: true
+      name: _api._tcp.example.com
+      scheme: https
+`,
+		},
+		{
+			name: "name required",
+			yaml: base + `
+upstreams:
+  api:
+    dns_discovery:
+      enabled: true
+`,
+			wantErr: true,
+			errMsg:  "dns_discovery.name is required",
+		},
+		{
+			name: "backends rejected",
+			yaml: base + `
+upstreams:
+  api:
+    backends:
+      - url: http://localhost:9000
+    dns_discovery:
+      enabled: true
+      name: api
+      domain: example.com
+`,
+			wantErr: true,
+			errMsg:  "mutually exclusive",
+		},
+		{
+			name: "invalid protocol",
+			yaml: base + `
+upstreams:
+  api:
+    dns_discovery:
+      enabled: true
+      name: api
+      domain: example.com
+      protocol: sctp
+`,
+			wantErr: true,
+			errMsg:  "protocol must be",
+		},
+		{
+			name: "nameserver without port",
+			yaml: base + `
+upstreams:
+  api:
+    dns_discovery:
+      enabled: true
+      name: api
+      domain: example.com
+      nameserver: 10.0.0.53
+`,
+			wantErr: true,
+			errMsg:  "nameserver must be host:port",
+		},
+		{
+			name: "traffic split reference rejected",
+			yaml: `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    traffic_split:
+      - name: a
+        weight: 50
+        upstream: api
+      - name: b
+        weight: 50
+        backends:
+          - url: http://localhost:9001
+upstreams:
+  api:
+    dns_discovery:
+      enabled: true
+      name: api
+      domain: example.com
+`,
+			wantErr: true,
+			errMsg:  "can only be referenced by route upstream",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateTestMode(t *testing.T) {
+	base := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+`
+	tests := []struct {
+		name    string
+		env     string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "allowed by env",
+			env:  "true",
+			yaml: base + `
+test_mode:
+  enabled: true
+  seed: 42
+  start_time: "2030-01-01T00:00:00Z"
+`,
+		},
+		{
+			name: "refused without env",
+			yaml: base + `
+test_mode:
+  enabled: true
+`,
+			wantErr: true,
+			errMsg:  TestModeEnv,
+		},
+		{
+			name: "invalid start time",
+			env:  "true",
+			yaml: base + `
+test_mode:
+  enabled: true
+  start_time: tomorrow
+`,
+			wantErr: true,
+			errMsg:  "start_time must be an RFC 3339 time",
+		},
+		{
+			name: "disabled needs no env",
+			yaml: base + `
+test_mode:
+  enabled: false
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(TestModeEnv, tt.env)
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateMirrorShadowRoute(t *testing.T) {
+	base := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: orders-v2
+    path: /v2/orders
+    backends:
+      - url: http://localhost:9001
+  - id: orders
+    path: /orders
+    backends:
+      - url: http://localhost:9000
+    mirror:
+      enabled: true
+`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid shadow route",
+			yaml: base + `      shadow_route: orders-v2
+      compare:
+        enabled: true
+`,
+		},
+		{
+			name: "unknown route",
+			yaml: base + `      shadow_route: orders-v3
+`,
+			wantErr: true,
+			errMsg:  `shadow_route references unknown route "orders-v3"`,
+		},
+		{
+			name: "self reference",
+			yaml: base + `      shadow_route: orders
+`,
+			wantErr: true,
+			errMsg:  "shadow_route cannot reference the route itself",
+		},
+		{
+			name: "backends rejected",
+			yaml: base + `      shadow_route: orders-v2
+      backends:
+        - url: http://localhost:9002
+`,
+			wantErr: true,
+			errMsg:  "shadow_route is mutually exclusive with backends and upstream",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateMirrorSink(t *testing.T) {
+	base := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: orders
+    path: /orders
+    backends:
+      - url: http://localhost:9000
+    mirror:
+      enabled: true
+      percentage: 1
+`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid sink",
+			yaml: base + `      sink:
+        enabled: true
+        directory: /var/lib/runway/mirror
+        max_file_size: 104857600
+        redact_headers: [X-Session]
+        redact_fields: [password]
+`,
+		},
+		{
+			name: "directory required",
+			yaml: base + `      sink:
+        enabled: true
+`,
+			wantErr: true,
+			errMsg:  "mirror sink.directory is required",
+		},
+		{
+			name: "backends rejected",
+			yaml: base + `      backends:
+        - url: http://localhost:9002
+      sink:
+        enabled: true
+        directory: /tmp/mirror
+`,
+			wantErr: true,
+			errMsg:  "sink is mutually exclusive with backends, upstream and shadow_route",
+		},
+		{
+			name: "compare rejected",
+			yaml: base + `      compare:
+        enabled: true
+      sink:
+        enabled: true
+        directory: /tmp/mirror
+`,
+			wantErr: true,
+			errMsg:  "sink cannot be combined with compare",
+		},
+		{
+			name: "negative size",
+			yaml: base + `      sink:
+        enabled: true
+        directory: /tmp/mirror
+        max_file_size: -1
+`,
+			wantErr: true,
+			errMsg:  "must be >= 0",
+		},
+		{
+			name: "empty redact field",
+			yaml: base + `      sink:
+        enabled: true
+        directory: /tmp/mirror
+        redact_fields: [""]
+`,
+			wantErr: true,
+			errMsg:  "sink.redact_fields[0] must be non-empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateEgressPolicy(t *testing.T) {
+	policy := `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+egress_policy:
+  enabled: true
+  allowed_domains: [internal.example.com]
+  allowed_cidrs: [10.0.0.0/8]
+`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "allowed domain and CIDR",
+			yaml: policy + `routes:
+  - id: users
+    path: /users
+    backends:
+      - url: http://users.internal.example.com:8080
+      - url: http://10.0.0.5:8080
+`,
+		},
+		{
+			name: "backend outside allowlist",
+			yaml: policy + `routes:
+  - id: users
+    path: /users
+    backends:
+      - url: http://10.0.0.5:8080
+      - url: http://users.example.org
+`,
+			wantErr: true,
+			errMsg:  `egress_policy: routes[users].backends[1].url: destination "users.example.org" is not in the allowlist`,
+		},
+		{
+			name: "upstream backend outside allowlist",
+			yaml: policy + `upstreams:
+  users:
+    backends:
+      - url: http://192.168.1.10:8080
+routes:
+  - id: users
+    path: /users
+    upstream: users
+`,
+			wantErr: true,
+			errMsg:  "upstreams[users].backends[0].url",
+		},
+		{
+			name: "webhook endpoint outside allowlist",
+			yaml: policy + `routes:
+  - id: users
+    path: /users
+    backends:
+      - url: http://10.0.0.5:8080
+webhooks:
+  enabled: true
+  endpoints:
+    - id: alerts
+      url: https://hooks.example.com/gateway
+      events: ["backend.unhealthy"]
+`,
+			wantErr: true,
+			errMsg:  "webhooks.endpoints[0].url",
+		},
+		{
+			name: "static aggregate host outside allowlist",
+			yaml: policy + `routes:
+  - id: profile
+    path: /profile
+    aggregate:
+      enabled: true
+      backends:
+        - name: user
+          url: "http://users.internal.example.com/users/{{.Path.id}}"
+        - name: orders
+          url: "https://orders.example.org/orders?user={{.Path.id}}"
+`,
+			wantErr: true,
+			errMsg:  "routes[profile].aggregate.backends[1].url",
+		},
+		{
+			name: "templated aggregate host checked at dial time",
+			yaml: policy + `routes:
+  - id: profile
+    path: /profile
+    aggregate:
+      enabled: true
+      backends:
+        - name: user
+          url: "http://users.internal.example.com/users/{{.Path.id}}"
+        - name: orders
+          url: "http://{{.Query.region}}.orders.example.org/orders"
+`,
+		},
+		{
+			name: "AI provider default base URL",
+			yaml: policy + `routes:
+  - id: chat
+    path: /chat
+    ai:
+      enabled: true
+      provider: openai
+      api_key: sk-test
+      model: gpt-4o
+`,
+			wantErr: true,
+			errMsg:  `routes[chat].ai.base_url: destination "api.openai.com"`,
+		},
+		{
+			name: "warn mode loads",
+			yaml: policy + `  mode: warn
+routes:
+  - id: users
+    path: /users
+    backends:
+      - url: http://users.example.org
+`,
+		},
+		{
+			name: "hostname with a CIDR-only allowlist",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+egress_policy:
+  enabled: true
+  allowed_cidrs: [10.0.0.0/8]
+routes:
+  - id: users
+    path: /users
+    backends:
+      - url: http://users.internal:8080
+`,
+			wantErr: true,
+			errMsg:  `routes[users].backends[0].url: destination "users.internal" is a hostname but allowed_domains is empty`,
+		},
+		{
+			name: "invalid mode",
+			yaml: policy + `  mode: audit
+routes:
+  - id: users
+    path: /users
+    backends:
+      - url: http://10.0.0.5:8080
+`,
+			wantErr: true,
+			errMsg:  `egress_policy.mode must be "enforce" or "warn"`,
+		},
+		{
+			name: "invalid CIDR",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+egress_policy:
+  enabled: true
+  allowed_cidrs: [10.0.0.0/33]
+routes:
+  - id: users
+    path: /users
+    backends:
+      - url: http://10.0.0.5:8080
+`,
+			wantErr: true,
+			errMsg:  "egress_policy.allowed_cidrs[0]: invalid CIDR",
+		},
+		{
+			name: "empty allowlist",
+			yaml: `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+egress_policy:
+  enabled: true
+routes:
+  - id: users
+    path: /users
+    backends:
+      - url: http://10.0.0.5:8080
+`,
+			wantErr: true,
+			errMsg:  "at least one of allowed_domains or allowed_cidrs is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderEgressPolicyWarnMode(t *testing.T) {
+	cfg, err := NewLoader().Parse([]byte(`
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+egress_policy:
+  enabled: true
+  mode: warn
+  allowed_domains: [internal.example.com]
+routes:
+  - id: users
+    path: /users
+    backends:
+      - url: http://users.internal.example.com
+      - url: http://users.example.org
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []EgressWarning{{Field: "routes[users].backends[1].url", Host: "users.example.org", Message: "is not in the allowlist"}}
+	if fmt.Sprint(cfg.EgressWarnings) != fmt.Sprint(want) {
+		t.Errorf("warnings = %+v, want %+v", cfg.EgressWarnings, want)
+	}
+}
+
+func TestLoaderValidateOIDC(t *testing.T) {
+	route := func(oidc, auth string) string {
+		return `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: dashboard
+    path: /dashboard
+    path_prefix: true
+    backends:
+      - url: http://10.0.0.5:8080
+` + auth + oidc
+	}
+	const auth = `    auth:
+      required: true
+      methods: [oidc]
+`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid",
+			yaml: route(`    oidc:
+      enabled: true
+      issuer: https://idp.example.com
+      client_id: dashboard
+      session:
+        secret: 0123456789abcdef0123456789abcdef
+`, auth),
+		},
+		{
+			name:    "oidc method without oidc",
+			yaml:    route("", auth),
+			wantErr: true,
+			errMsg:  `auth method "oidc" requires oidc.enabled`,
+		},
+		{
+			name: "oidc without oidc method",
+			yaml: route(`    oidc:
+      enabled: true
+      issuer: https://idp.example.com
+      client_id: dashboard
+      session:
+        secret: 0123456789abcdef0123456789abcdef
+`, `    auth:
+      required: true
+      methods: [jwt]
+`),
+			wantErr: true,
+			errMsg:  `oidc requires auth.required and "oidc" in auth.methods`,
+		},
+		{
+			name: "missing issuer",
+			yaml: route(`    oidc:
+      enabled: true
+      client_id: dashboard
+      session:
+        secret: 0123456789abcdef0123456789abcdef
+`, auth),
+			wantErr: true,
+			errMsg:  "oidc.issuer is required",
+		},
+		{
+			name: "short secret",
+			yaml: route(`    oidc:
+      enabled: true
+      issuer: https://idp.example.com
+      client_id: dashboard
+      session:
+        secret: short
+`, auth),
+			wantErr: true,
+			errMsg:  "oidc.session.secret must be at least 32 bytes",
+		},
+		{
+			name: "scopes without openid",
+			yaml: route(`    oidc:
+      enabled: true
+      issuer: https://idp.example.com
+      client_id: dashboard
+      scopes: [profile]
+      session:
+        secret: 0123456789abcdef0123456789abcdef
+`, auth),
+			wantErr: true,
+			errMsg:  `oidc.scopes must include "openid"`,
+		},
+		{
+			name: "redis store without redis",
+			yaml: route(`    oidc:
+      enabled: true
+      issuer: https://idp.example.com
+      client_id: dashboard
+      session:
+        store: redis
+        secret: 0123456789abcdef0123456789abcdef
+`, auth),
+			wantErr: true,
+			errMsg:  `oidc.session.store "redis" requires redis.address`,
+		},
+		{
+			name: "relative callback path",
+			yaml: route(`    oidc:
+      enabled: true
+      issuer: https://idp.example.com
+      client_id: dashboard
+      callback_path: callback
+      session:
+        secret: 0123456789abcdef0123456789abcdef
+`, auth),
+			wantErr: true,
+			errMsg:  "oidc.callback_path must be a path starting with /",
+		},
+		{
+			name: "base_url with a path",
+			yaml: route(`    oidc:
+      enabled: true
+      issuer: https://idp.example.com
+      client_id: dashboard
+      base_url: https://app.example.com/dash
+      session:
+        secret: 0123456789abcdef0123456789abcdef
+`, auth),
+			wantErr: true,
+			errMsg:  "oidc.base_url must be an http(s) origin",
+		},
+		{
+			name: "same_site none without secure",
+			yaml: route(`    oidc:
+      enabled: true
+      issuer: https://idp.example.com
+      client_id: dashboard
+      session:
+        secret: 0123456789abcdef0123456789abcdef
+        same_site: none
+        secure: false
+`, auth),
+			wantErr: true,
+			errMsg:  `oidc.session.same_site "none" requires secure cookies`,
+		},
+		{
+			name: "callback path shared by two routes",
+			yaml: route(`    oidc:
+      enabled: true
+      issuer: https://idp.example.com
+      client_id: dashboard
+      session:
+        secret: 0123456789abcdef0123456789abcdef
+  - id: grafana
+    path: /grafana
+    backends:
+      - url: http://10.0.0.6:8080
+    auth:
+      required: true
+      methods: [oidc]
+    oidc:
+      enabled: true
+      issuer: https://idp.example.com
+      client_id: grafana
+      logout_path: /grafana/logout
+      session:
+        secret: 0123456789abcdef0123456789abcdef
+`, auth),
+			wantErr: true,
+			errMsg:  "route grafana: oidc path /oauth2/callback is already used by route dashboard",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateRequestMetrics(t *testing.T) {
+	base := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+`
+	route := func(metrics string) string {
+		return `
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+` + metrics
+	}
+	tests := []struct {
+		name   string
+		yaml   string
+		errMsg string
+	}{
+		{
+			name: "global and route modes",
+			yaml: base + `
+admin:
+  metrics:
+    requests:
+      mode: summary
+      status: class
+    buckets: [0.05, 0.5, 5]
+    summary_window: 30s
+` + route(`    metrics:
+      mode: counters
+`),
+		},
+		{
+			name: "unknown route mode",
+			yaml: base + route(`    metrics:
+      mode: exponential
+`),
+			errMsg: "route test: metrics: mode must be histogram, summary or counters",
+		},
+		{
+			name: "unknown global status",
+			yaml: base + `
+admin:
+  metrics:
+    requests:
+      status: family
+` + route(""),
+			errMsg: "admin.metrics.requests: status must be code or class",
+		},
+		{
+			name: "unsorted buckets",
+			yaml: base + `
+admin:
+  metrics:
+    buckets: [1, 0.5]
+` + route(""),
+			errMsg: "bounds must be strictly increasing",
+		},
+		{
+			name: "non-positive bucket",
+			yaml: base + `
+admin:
+  metrics:
+    buckets: [0, 1]
+` + route(""),
+			errMsg: "bounds must be > 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestRequestMetricsConfigMerge(t *testing.T) {
+	defaults := RequestMetricsConfig{Mode: MetricsModeSummary, Status: MetricsStatusClass}
+	got := RequestMetricsConfig{Mode: MetricsModeHistogram}.Merge(defaults)
+	if got.Mode != MetricsModeHistogram || got.Status != MetricsStatusClass {
+		t.Errorf("unexpected merge result %+v", got)
+	}
+}
+
+func TestLoaderValidateMatchExpression(t *testing.T) {
+	route := func(match string) string {
+		return `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: http://localhost:9000
+    match:
+` + match
+	}
+	matchers := `      headers:
+        - id: header_beta
+          name: X-Beta
+          present: true
+        - name: X-Tenant
+          value: acme
+      cookies:
+        - id: cookie_beta
+          name: beta
+          value: "1"
+`
+	tests := []struct {
+		name   string
+		yaml   string
+		errMsg string
+	}{
+		{
+			name: "valid expression",
+			yaml: route(matchers + `      expression: "header_beta || cookie_beta"
+`),
+		},
+		{
+			name: "ids without expression",
+			yaml: route(matchers),
+		},
+		{
+			name: "undefined matcher",
+			yaml: route(matchers + `      expression: "header_beta || query_beta || cookie_beta"
+`),
+			errMsg: `route test: match.expression references undefined matcher "query_beta"`,
+		},
+		{
+			name: "unused matcher",
+			yaml: route(matchers + `      expression: "header_beta"
+`),
+			errMsg: `route test: matcher "cookie_beta" is not used by match.expression`,
+		},
+		{
+			name: "always false",
+			yaml: route(matchers + `      expression: "(header_beta || cookie_beta) && !header_beta && !cookie_beta"
+`),
+			errMsg: "can never match",
+		},
+		{
+			name: "syntax error",
+			yaml: route(matchers + `      expression: "header_beta | cookie_beta"
+`),
+			errMsg: "route test: match.expression: invalid expression at offset 12",
+		},
+		{
+			name: "duplicate id",
+			yaml: route(matchers + `      query:
+        - id: cookie_beta
+          name: beta
+          value: "1"
+      expression: "header_beta || cookie_beta"
+`),
+			errMsg: `route test: match cookie id "cookie_beta" is used more than once`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderIPv6AggregationPrefix(t *testing.T) {
+	yaml := `
+ipv6_aggregation_prefix: 48
+spike_arrest:
+  ipv6_aggregation_prefix: 64
+routes:
+  - id: api
+    path: /api
+    backends:
+      - url: http://localhost:8080
+    rate_limit:
+      enabled: true
+      rate: 10
+      period: 1s
+      per_ip: true
+    quota:
+      enabled: true
+      limit: 100
+      period: daily
+      key: ip
+      ipv6_aggregation_prefix: 128
+`
+	cfg, err := NewLoader().Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	route := cfg.Routes[0]
+	if got := route.RateLimit.IPv6AggregationPrefix; got != 48 {
+		t.Errorf("rate_limit prefix = %d, want global 48", got)
+	}
+	if got := route.Quota.IPv6AggregationPrefix; got != 128 {
+		t.Errorf("quota prefix = %d, want route 128", got)
+	}
+	if got := cfg.SpikeArrest.IPv6AggregationPrefix; got != 64 {
+		t.Errorf("spike_arrest prefix = %d, want 64", got)
+	}
+	if got := cfg.Reputation.IPv6AggregationPrefix; got != 48 {
+		t.Errorf("reputation prefix = %d, want global 48", got)
+	}
+
+	tests := []struct {
+		name   string
+		yaml   string
+		errMsg string
+	}{
+		{
+			name:   "global out of range",
+			yaml:   "ipv6_aggregation_prefix: 129\n",
+			errMsg: "ipv6_aggregation_prefix must be between 0 and 128",
+		},
+		{
+			name: "route out of range",
+			yaml: `
+routes:
+  - id: api
+    path: /api
+    backends:
+      - url: http://localhost:8080
+    rate_limit:
+      ipv6_aggregation_prefix: -1
+`,
+			errMsg: "route api: rate_limit.ipv6_aggregation_prefix must be between 0 and 128",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderConsumerGroupClients(t *testing.T) {
+	yaml := `
+consumer_groups:
+  enabled: true
+  claim: plan
+  groups:
+    gold:
+      rate_limit: 100
+      priority: 1
+  clients:
+    partner-a: gold
+`
+	cfg, err := NewLoader().Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := cfg.ConsumerGroups.Clients["partner-a"]; got != "gold" {
+		t.Errorf("clients[partner-a] = %q, want gold", got)
+	}
+	if got := cfg.ConsumerGroups.GroupClaim(); got != "plan" {
+		t.Errorf("GroupClaim() = %q, want plan", got)
+	}
+	if got := (ConsumerGroupsConfig{}).GroupClaim(); got != "consumer_group" {
+		t.Errorf("default GroupClaim() = %q, want consumer_group", got)
+	}
+
+	_, err = NewLoader().Parse([]byte(`
+consumer_groups:
+  enabled: true
+  groups:
+    gold:
+      rate_limit: 100
+  clients:
+    partner-a: platinum
+`))
+	want := `consumer_groups.clients[partner-a]: unknown group "platinum"`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expected error containing %q, got %v", want, err)
+	}
+}
@@ -13,18 +13,26 @@ const RedactedValue = "[REDACTED]"
 // RedactConfig returns a deep copy of cfg with all string fields tagged
 // `redact:"true"` replaced by RedactedValue. The original cfg is not mutated.
 func RedactConfig(cfg *Config) (*Config, error) {
-	// Deep copy via YAML round-trip. Fields tagged yaml:"-" (e.g.,
-	// TLSCertPair.CertData/KeyData) are intentionally dropped — they
-	// contain raw cert bytes that should not appear in admin output.
+	cp, err := copyConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("redact: %w", err)
+	}
+	redactFields(reflect.ValueOf(cp).Elem())
+	return cp, nil
+}
+
+// copyConfig deep copies cfg via a YAML round-trip. Fields tagged yaml:"-"
+// (e.g., TLSCertPair.CertData/KeyData) are intentionally dropped — they
+// contain raw cert bytes that should not appear in admin output.
+func copyConfig(cfg *Config) (*Config, error) {
 	data, err := yaml.Marshal(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("redact: marshal failed: %w", err)
+		return nil, fmt.Errorf("marshal failed: %w", err)
 	}
 	var cp Config
 	if err := yaml.Unmarshal(data, &cp); err != nil {
-		return nil, fmt.Errorf("redact: unmarshal failed: %w", err)
+		return nil, fmt.Errorf("unmarshal failed: %w", err)
 	}
-	redactFields(reflect.ValueOf(&cp).Elem())
 	return &cp, nil
 }
 
@@ -107,6 +107,9 @@ func TestRedactConfig_PerRouteSecrets(t *testing.T) {
 			FieldEncryption: FieldEncryptionConfig{
 				KeyBase64: "enc-key",
 			},
+			BackendHeadersSecret: BackendHeadersSecretConfig{
+				Headers: []SecretHeaderConfig{{Name: "X-Api-Key", Primary: "bhs-primary", Secondary: "bhs-secondary"}},
+			},
 		},
 	}
 
@@ -127,6 +130,8 @@ func TestRedactConfig_PerRouteSecrets(t *testing.T) {
 		{"AI.APIKey", r.AI.APIKey},
 		{"ResponseSigning.Secret", r.ResponseSigning.Secret},
 		{"FieldEncryption.KeyBase64", r.FieldEncryption.KeyBase64},
+		{"BackendHeadersSecret.Headers[0].Primary", r.BackendHeadersSecret.Headers[0].Primary},
+		{"BackendHeadersSecret.Headers[0].Secondary", r.BackendHeadersSecret.Headers[0].Secondary},
 	}
 
 	for _, c := range checks {
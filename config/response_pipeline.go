@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ResponsePipelineStages lists the body-modifying response stages that
+// response_pipeline_order can reorder, in their default execution order.
+// Response middleware runs innermost first, so this is the reverse of their
+// positions in the route's middleware chain.
+var ResponsePipelineStages = []string{
+	"content_negotiation",
+	"response_body_generator",
+	"field_replacer",
+	"pii_redaction",
+	"content_replacer",
+	"jmespath",
+}
+
+// ResponsePipelineWarning describes a combination of response body stages
+// on a route that is likely a mistake.
+type ResponsePipelineWarning struct {
+	Route   string   `json:"route"`
+	Stages  []string `json:"stages"` // the conflicting stages, in execution order
+	Message string   `json:"message"`
+}
+
+// String formats the warning for CLI and log output.
+func (w ResponsePipelineWarning) String() string {
+	return fmt.Sprintf("route %s: %s: %s", w.Route, strings.Join(w.Stages, " -> "), w.Message)
+}
+
+// ResponsePipelineEnabled reports whether a reorderable response stage is
+// active on the route.
+func ResponsePipelineEnabled(rc RouteConfig, stage string) bool {
+	if rc.Passthrough {
+		return false
+	}
+	switch stage {
+	case "content_negotiation":
+		return rc.ContentNegotiation.Enabled || rc.OutputEncoding != ""
+	case "response_body_generator":
+		return rc.ResponseBodyGenerator.Enabled
+	case "field_replacer":
+		return rc.FieldReplacer.Enabled
+	case "pii_redaction":
+		scope := rc.PIIRedaction.Scope
+		return rc.PIIRedaction.Enabled && (scope == "" || scope == "response" || scope == "both")
+	case "content_replacer":
+		return rc.ContentReplacer.Enabled && len(rc.ContentReplacer.Replacements) > 0
+	case "jmespath":
+		return rc.JMESPath.Enabled
+	}
+	return false
+}
+
+// ResponsePipelineOrder returns every reorderable stage in the order it
+// runs on the route. Stages named in response_pipeline_order run in the
+// listed order, taking the positions those stages have in the default
+// order; the others keep their default positions.
+func ResponsePipelineOrder(rc RouteConfig) []string {
+	order := slices.Clone(ResponsePipelineStages)
+	if len(rc.ResponsePipelineOrder) == 0 {
+		return order
+	}
+	next := 0
+	for i, stage := range order {
+		if slices.Contains(rc.ResponsePipelineOrder, stage) {
+			order[i] = rc.ResponsePipelineOrder[next]
+			next++
+		}
+	}
+	return order
+}
+
+// activeResponseStages returns the stages enabled on the route in the order
+// they run.
+func activeResponseStages(rc RouteConfig) []string {
+	var active []string
+	for _, stage := range ResponsePipelineOrder(rc) {
+		if ResponsePipelineEnabled(rc, stage) {
+			active = append(active, stage)
+		}
+	}
+	return active
+}
+
+// contentNegotiationReencodes reports whether content negotiation can
+// return something other than JSON.
+func contentNegotiationReencodes(rc RouteConfig) bool {
+	switch rc.OutputEncoding {
+	case "":
+	case "json", "json-collection":
+		return false
+	default:
+		return true
+	}
+	if !rc.ContentNegotiation.Enabled {
+		return false
+	}
+	for _, f := range rc.ContentNegotiation.Supported {
+		if f != "json" {
+			return true
+		}
+	}
+	return false
+}
+
+// ResponsePipelineConflicts returns the likely mistakes among the response
+// body stages active on the route, given the order they run in.
+func ResponsePipelineConflicts(rc RouteConfig) []ResponsePipelineWarning {
+	active := activeResponseStages(rc)
+	pos := make(map[string]int, len(active))
+	for i, stage := range active {
+		pos[stage] = i
+	}
+	before := func(a, b string) bool {
+		pa, okA := pos[a]
+		pb, okB := pos[b]
+		return okA && okB && pa < pb
+	}
+
+	var warnings []ResponsePipelineWarning
+	add := func(a, b, msg string) {
+		warnings = append(warnings, ResponsePipelineWarning{Route: rc.ID, Stages: []string{a, b}, Message: msg})
+	}
+
+	if contentNegotiationReencodes(rc) {
+		for _, stage := range []string{"jmespath", "field_replacer"} {
+			if before("content_negotiation", stage) {
+				add("content_negotiation", stage, stage+" only rewrites JSON and is skipped when content negotiation returns another format")
+			}
+		}
+		if before("content_negotiation", "pii_redaction") && (rc.OutputEncoding == "cbor" || slices.Contains(rc.ContentNegotiation.Supported, "cbor")) {
+			add("content_negotiation", "pii_redaction", "pii_redaction patterns do not match CBOR-encoded bodies")
+		}
+	}
+	if before("jmespath", "field_replacer") {
+		add("jmespath", "field_replacer", "field_replacer paths are evaluated against the jmespath result, not the backend body")
+	}
+	if tmpl := rc.ResponseBodyGenerator.Template; !strings.Contains(tmpl, ".Body") && !strings.Contains(tmpl, ".Parsed") {
+		for _, stage := range active {
+			if stage != "content_negotiation" && before(stage, "response_body_generator") {
+				add(stage, "response_body_generator", "the response_body_generator template does not use .Body or .Parsed, so the output of "+stage+" is discarded")
+			}
+		}
+	}
+	return warnings
+}
+
+// validateResponsePipeline validates response_pipeline_order against the
+// ordering rules every route must keep, and fails on conflicts when
+// response_pipeline_conflicts is "error".
+func (l *Loader) validateResponsePipeline(route RouteConfig, cfg *Config) error {
+	seen := make(map[string]bool, len(route.ResponsePipelineOrder))
+	for _, stage := range route.ResponsePipelineOrder {
+		if !slices.Contains(ResponsePipelineStages, stage) {
+			return fmt.Errorf("route %s: response_pipeline_order: unknown stage %q (must be one of %s)", route.ID, stage, strings.Join(ResponsePipelineStages, ", "))
+		}
+		if seen[stage] {
+			return fmt.Errorf("route %s: response_pipeline_order: duplicate stage %q", route.ID, stage)
+		}
+		seen[stage] = true
+		if !ResponsePipelineEnabled(route, stage) {
+			return fmt.Errorf("route %s: response_pipeline_order: stage %q is not enabled on the route", route.ID, stage)
+		}
+	}
+
+	if len(route.ResponsePipelineOrder) > 0 {
+		active := activeResponseStages(route)
+		// Redaction must see the generated body, or a template could
+		// reintroduce what it removed.
+		if gen, pii := slices.Index(active, "response_body_generator"), slices.Index(active, "pii_redaction"); gen >= 0 && pii >= 0 && pii < gen {
+			return fmt.Errorf("route %s: response_pipeline_order: pii_redaction must run after response_body_generator", route.ID)
+		}
+		// Stages on either side of content negotiation would see different
+		// encodings, so it runs first (re-encoding the backend body) or last.
+		if cn := slices.Index(active, "content_negotiation"); cn > 0 && cn < len(active)-1 {
+			return fmt.Errorf("route %s: response_pipeline_order: content_negotiation must run first or last", route.ID)
+		}
+	}
+
+	if cfg.ResponsePipelineConflicts == "error" {
+		if conflicts := ResponsePipelineConflicts(route); len(conflicts) > 0 {
+			return fmt.Errorf("%s (response_pipeline_conflicts is \"error\")", conflicts[0])
+		}
+	}
+	return nil
+}
+
+// responsePipelineWarnings collects the conflicts of every route unless
+// response_pipeline_conflicts is "off".
+func responsePipelineWarnings(cfg *Config) []ResponsePipelineWarning {
+	if cfg.ResponsePipelineConflicts == "off" {
+		return nil
+	}
+	var warnings []ResponsePipelineWarning
+	for _, rc := range cfg.Routes {
+		warnings = append(warnings, ResponsePipelineConflicts(rc)...)
+	}
+	return warnings
+}
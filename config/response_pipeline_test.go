@@ -0,0 +1,219 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const responsePipelineBase = `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+routes:
+  - id: users
+    path: /users
+    backends:
+      - url: http://localhost:9000
+    jmespath:
+      enabled: true
+      expression: "items"
+    field_replacer:
+      enabled: true
+      operations:
+        - field: "name"
+          type: upper
+    pii_redaction:
+      enabled: true
+      built_ins: [email]
+    response_body_generator:
+      enabled: true
+      template: '{"data": {{.Body}}}'
+`
+
+func TestResponsePipelineOrder(t *testing.T) {
+	// The documented default order; buildRouteHandler's slot order is
+	// checked against it in internal/runway.
+	want := []string{"content_negotiation", "response_body_generator", "field_replacer", "pii_redaction", "content_replacer", "jmespath"}
+	if got := ResponsePipelineOrder(RouteConfig{}); !slices.Equal(got, want) {
+		t.Fatalf("default order = %v, want %v", got, want)
+	}
+
+	// Listed stages swap among their own positions; the others stay.
+	rc := RouteConfig{ResponsePipelineOrder: []string{"jmespath", "field_replacer"}}
+	want = []string{"content_negotiation", "response_body_generator", "jmespath", "pii_redaction", "content_replacer", "field_replacer"}
+	if got := ResponsePipelineOrder(rc); !slices.Equal(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+}
+
+func TestLoaderValidateResponsePipeline(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "default order",
+			yaml: responsePipelineBase,
+		},
+		{
+			name: "valid override",
+			yaml: responsePipelineBase + `    response_pipeline_order: [jmespath, field_replacer]
+`,
+		},
+		{
+			name: "unknown stage",
+			yaml: responsePipelineBase + `    response_pipeline_order: [jmespath, compression]
+`,
+			wantErr: true,
+			errMsg:  `unknown stage "compression"`,
+		},
+		{
+			name: "duplicate stage",
+			yaml: responsePipelineBase + `    response_pipeline_order: [jmespath, jmespath]
+`,
+			wantErr: true,
+			errMsg:  `duplicate stage "jmespath"`,
+		},
+		{
+			name: "stage not enabled",
+			yaml: responsePipelineBase + `    response_pipeline_order: [content_replacer, jmespath]
+`,
+			wantErr: true,
+			errMsg:  `stage "content_replacer" is not enabled`,
+		},
+		{
+			name: "redaction before generator",
+			yaml: responsePipelineBase + `    response_pipeline_order: [pii_redaction, response_body_generator]
+`,
+			wantErr: true,
+			errMsg:  "pii_redaction must run after response_body_generator",
+		},
+		{
+			name: "content negotiation in the middle",
+			yaml: responsePipelineBase + `    content_negotiation:
+      enabled: true
+      supported: [json, xml]
+    response_pipeline_order: [field_replacer, content_negotiation]
+`,
+			wantErr: true,
+			errMsg:  "content_negotiation must run first or last",
+		},
+		{
+			name: "content negotiation last",
+			yaml: responsePipelineBase + `    content_negotiation:
+      enabled: true
+      supported: [json, xml]
+    response_pipeline_order: [response_body_generator, field_replacer, pii_redaction, jmespath, content_negotiation]
+`,
+		},
+		{
+			name: "conflict as error",
+			yaml: "response_pipeline_conflicts: error\n" + responsePipelineBase + `    response_pipeline_order: [jmespath, field_replacer]
+`,
+			wantErr: true,
+			errMsg:  "field_replacer paths are evaluated against the jmespath result",
+		},
+		{
+			name:    "invalid severity",
+			yaml:    "response_pipeline_conflicts: fatal\n" + responsePipelineBase,
+			wantErr: true,
+			errMsg:  `response_pipeline_conflicts must be "warn", "error" or "off"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader().Parse([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				} else if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %q", tt.errMsg, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestResponsePipelineWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		severity string
+		route    string
+		want     [][]string // conflicting stages
+	}{
+		{
+			name: "no conflicts",
+		},
+		{
+			name:  "jmespath before field_replacer",
+			route: "    response_pipeline_order: [jmespath, field_replacer]\n",
+			want:  [][]string{{"jmespath", "field_replacer"}},
+		},
+		{
+			name: "content negotiation re-encodes before JSON stages",
+			route: `    content_negotiation:
+      enabled: true
+      supported: [json, cbor]
+`,
+			want: [][]string{
+				{"content_negotiation", "jmespath"},
+				{"content_negotiation", "field_replacer"},
+				{"content_negotiation", "pii_redaction"},
+			},
+		},
+		{
+			name:  "json-only output encoding",
+			route: "    output_encoding: json-collection\n",
+		},
+		{
+			name:     "off",
+			severity: "response_pipeline_conflicts: off\n",
+			route:    "    response_pipeline_order: [jmespath, field_replacer]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := NewLoader().Parse([]byte(tt.severity + responsePipelineBase + tt.route))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got [][]string
+			for _, w := range cfg.ResponsePipelineWarnings {
+				if w.Route != "users" || w.Message == "" {
+					t.Errorf("unexpected warning %+v", w)
+				}
+				got = append(got, w.Stages)
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal[[]string]) {
+				t.Errorf("warnings = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponsePipelineDiscardedByGenerator(t *testing.T) {
+	rc := RouteConfig{
+		ID:                    "users",
+		JMESPath:              JMESPathConfig{Enabled: true, Expression: "items"},
+		ResponseBodyGenerator: ResponseBodyGeneratorConfig{Enabled: true, Template: `{"status": "ok"}`},
+		ResponsePipelineOrder: []string{"jmespath", "response_body_generator"},
+	}
+	w := ResponsePipelineConflicts(rc)
+	if len(w) != 1 || !slices.Equal(w[0].Stages, []string{"jmespath", "response_body_generator"}) {
+		t.Fatalf("expected jmespath discarded by the generator, got %v", w)
+	}
+
+	// A template that renders the body keeps the earlier stage's output.
+	rc.ResponseBodyGenerator.Template = `{"data": {{json .Parsed}}}`
+	if w := ResponsePipelineConflicts(rc); len(w) != 0 {
+		t.Fatalf("expected no conflicts, got %v", w)
+	}
+}
@@ -66,14 +66,35 @@ func (r *SecretRegistry) Close() error {
 	return nil
 }
 
+// NewDefaultSecretRegistry returns a registry with the built-in env and file
+// providers, for components that re-resolve secret references at runtime.
+func NewDefaultSecretRegistry(cfg SecretsConfig) *SecretRegistry {
+	reg := NewSecretRegistry()
+	reg.Register(&EnvProvider{})
+	reg.Register(&FileProvider{AllowedPrefixes: cfg.File.AllowedPrefixes})
+	return reg
+}
+
+// ResolveValue resolves value if it is a ${scheme:ref} secret reference and
+// returns it unchanged otherwise.
+func (r *SecretRegistry) ResolveValue(ctx context.Context, value string) (string, error) {
+	m := secretRefPattern.FindStringSubmatch(value)
+	if m == nil {
+		return value, nil
+	}
+	return r.Resolve(ctx, m[1], m[2])
+}
+
 // secretRefPattern matches a full-string secret reference: ${scheme:reference}
 // scheme must start with lowercase letter followed by lowercase letters/digits.
 var secretRefPattern = regexp.MustCompile(`^\$\{([a-z][a-z0-9]*):(.+)\}$`)
 
 // resolveSecretRefs walks a config struct resolving ${scheme:ref} strings in place.
+// Fields tagged `secret:"deferred"` are resolved to fail fast on bad references
+// but keep the reference, so their consumers can re-resolve it at runtime.
 func resolveSecretRefs(cfg any, registry *SecretRegistry, ctx context.Context) error {
 	var resolveErr error
-	walkStructStrings(reflect.ValueOf(cfg), "", func(field reflect.Value, path string, _ reflect.StructTag) {
+	walkStructStrings(reflect.ValueOf(cfg), "", func(field reflect.Value, path string, tag reflect.StructTag) {
 		if resolveErr != nil {
 			return
 		}
@@ -91,6 +112,9 @@ func resolveSecretRefs(cfg any, registry *SecretRegistry, ctx context.Context) e
 			resolveErr = fmt.Errorf("secret resolution failed for %s (${%s:%s}): %w", path, scheme, ref, err)
 			return
 		}
+		if tag.Get("secret") == "deferred" {
+			return
+		}
 		field.SetString(resolved)
 	})
 	return resolveErr
@@ -2,8 +2,10 @@ package config
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/goccy/go-yaml"
@@ -351,3 +353,99 @@ routes:
 		t.Fatal("expected error for missing env var in strict ref")
 	}
 }
+
+func TestParseKeepsDeferredSecretRefs(t *testing.T) {
+	t.Setenv("BHS_PRIMARY", "primary-value")
+
+	yamlData := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: "test"
+    path: "/test"
+    backends:
+      - url: "http://localhost:9001"
+    backend_headers_secret:
+      enabled: true
+      headers:
+        - name: X-Api-Key
+          primary: "${env:BHS_PRIMARY}"
+`
+	loader := NewLoader()
+	cfg, err := loader.Parse([]byte(yamlData))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := cfg.Routes[0].BackendHeadersSecret.Headers[0].Primary; got != "${env:BHS_PRIMARY}" {
+		t.Errorf("deferred ref should be kept, got %q", got)
+	}
+
+	// Deferred refs are still checked at load time.
+	_, err = loader.Parse([]byte(strings.Replace(yamlData, "BHS_PRIMARY", "DEFINITELY_UNSET_BHS", 1)))
+	if err == nil {
+		t.Fatal("expected error for unresolvable deferred ref")
+	}
+}
+
+// mapProvider resolves references from a fixed map.
+type mapProvider map[string]string
+
+func (mapProvider) Scheme() string { return "vault" }
+
+func (p mapProvider) Resolve(_ context.Context, ref string) (string, error) {
+	if v, ok := p[ref]; ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("%s not found", ref)
+}
+
+func TestParseKeepsLoaderRegistry(t *testing.T) {
+	vault := mapProvider{"partner/key": "v1"}
+	reg := NewSecretRegistry()
+	reg.Register(vault)
+
+	yamlData := `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: "test"
+    path: "/test"
+    backends:
+      - url: "http://localhost:9001"
+    backend_headers_secret:
+      enabled: true
+      headers:
+        - name: X-Api-Key
+          primary: "${vault:partner/key}"
+`
+	cfg, err := NewLoader(WithSecretRegistry(reg)).Parse([]byte(yamlData))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if cfg.SecretRegistry == nil {
+		t.Fatal("expected the loader registry on the config")
+	}
+	vault["partner/key"] = "v2"
+	got, err := cfg.SecretRegistry.ResolveValue(context.Background(), cfg.Routes[0].BackendHeadersSecret.Headers[0].Primary)
+	if err != nil || got != "v2" {
+		t.Fatalf("got %q, %v; want v2 from the loader's provider", got, err)
+	}
+}
+
+func TestSecretRegistry_ResolveValue(t *testing.T) {
+	t.Setenv("RESOLVE_VALUE_SECRET", "v1")
+
+	reg := NewDefaultSecretRegistry(SecretsConfig{})
+	got, err := reg.ResolveValue(context.Background(), "${env:RESOLVE_VALUE_SECRET}")
+	if err != nil || got != "v1" {
+		t.Fatalf("got %q, %v; want v1", got, err)
+	}
+	got, err = reg.ResolveValue(context.Background(), "literal")
+	if err != nil || got != "literal" {
+		t.Fatalf("literal should pass through, got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Secret handling modes for ProtectSecrets.
+const (
+	// SecretsReference writes secrets resolved from ${scheme:ref} as their
+	// reference and masks the others.
+	SecretsReference = "reference"
+	// SecretsMask masks every secret.
+	SecretsMask = "mask"
+)
+
+// MaskedSecret is a secret field written as RedactedValue by
+// ProtectSecrets. It must be re-resolved before the config is loaded.
+type MaskedSecret struct {
+	// Path is the field path, with routes keyed by ID, e.g.
+	// "Routes[api].BackendAuth.ClientSecret".
+	Path string `json:"path"`
+	// Reference is the ${scheme:ref} the value was resolved from, if any.
+	Reference string `json:"reference,omitempty"`
+}
+
+// CloneConfig returns a deep copy of cfg that keeps its SecretRefs.
+func CloneConfig(cfg *Config) (*Config, error) {
+	cp, err := copyConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("clone: %w", err)
+	}
+	cp.SecretRefs = maps.Clone(cfg.SecretRefs)
+	cp.RouteSources = maps.Clone(cfg.RouteSources)
+	cp.ArtifactRefs = slices.Clone(cfg.ArtifactRefs)
+	cp.ConfigDir = cfg.ConfigDir
+	cp.SecretRegistry = cfg.SecretRegistry
+	return cp, nil
+}
+
+// ProtectSecrets removes plaintext secrets from cfg in place so it can be
+// persisted. Values resolved from a ${scheme:ref} reference are written as
+// the reference in SecretsReference mode; they and every other non-empty
+// field tagged `redact:"true"` are otherwise set to RedactedValue and
+// returned, so RestoreSecrets can fill them in again.
+func ProtectSecrets(cfg *Config, mode string) []MaskedSecret {
+	var masked []MaskedSecret
+	walkConfigStrings(cfg, func(field reflect.Value, path string, tag reflect.StructTag) {
+		val := field.String()
+		if val == "" {
+			return
+		}
+		ref, isRef := cfg.SecretRefs[val]
+		if !isRef && secretRefPattern.MatchString(val) {
+			// Deferred fields keep their reference.
+			ref, isRef = val, true
+		}
+		switch {
+		case isRef && mode != SecretsMask:
+			field.SetString(ref)
+		case isRef || tag.Get("redact") == "true":
+			field.SetString(RedactedValue)
+			masked = append(masked, MaskedSecret{Path: path, Reference: ref})
+		}
+	})
+	return masked
+}
+
+// RestoreSecrets fills the masked fields of cfg, a config written by
+// ProtectSecrets. A field with a reference gets the reference back, which
+// the loader resolves when the config is parsed; any other field takes the
+// value of the same field in current. It fails when a masked field has
+// neither.
+func RestoreSecrets(cfg, current *Config, masked []MaskedSecret) error {
+	if len(masked) == 0 {
+		return nil
+	}
+	want := make(map[string]MaskedSecret, len(masked))
+	for _, m := range masked {
+		want[m.Path] = m
+	}
+	have := make(map[string]string)
+	walkConfigStrings(current, func(field reflect.Value, path string, _ reflect.StructTag) {
+		if _, ok := want[path]; ok {
+			have[path] = field.String()
+		}
+	})
+
+	var missing []string
+	walkConfigStrings(cfg, func(field reflect.Value, path string, _ reflect.StructTag) {
+		m, ok := want[path]
+		if !ok || field.String() != RedactedValue {
+			return
+		}
+		switch v := have[path]; {
+		case m.Reference != "":
+			field.SetString(m.Reference)
+		case v != "" && v != RedactedValue:
+			field.SetString(v)
+		default:
+			missing = append(missing, path)
+		}
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("no reference or current value for masked secrets: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// walkConfigStrings is walkStructStrings over cfg with routes keyed by ID
+// instead of index, so paths stay stable when routes are added or removed.
+func walkConfigStrings(cfg *Config, fn func(field reflect.Value, path string, tag reflect.StructTag)) {
+	walkStructStrings(reflect.ValueOf(cfg).Elem(), "", func(field reflect.Value, path string, tag reflect.StructTag) {
+		if !strings.HasPrefix(path, "Routes[") {
+			fn(field, path, tag)
+		}
+	})
+	for i := range cfg.Routes {
+		key := cfg.Routes[i].ID
+		if key == "" {
+			key = strconv.Itoa(i)
+		}
+		walkStructStrings(reflect.ValueOf(&cfg.Routes[i]).Elem(), "Routes["+key+"]", fn)
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const snapshotSecretsYAML = `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+csrf:
+  secret: inline-secret
+routes:
+  - id: api
+    path: /api
+    backends:
+      - url: http://localhost:9000
+    backend_auth:
+      client_secret: ${env:SNAPSHOT_CLIENT_SECRET}
+`
+
+func loadSnapshotConfig(t *testing.T) *Config {
+	t.Helper()
+	t.Setenv("SNAPSHOT_CLIENT_SECRET", "from-env")
+	cfg, err := NewLoader().Parse([]byte(snapshotSecretsYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.SecretRefs["from-env"]; got != "${env:SNAPSHOT_CLIENT_SECRET}" {
+		t.Fatalf("expected the loader to record the reference, got %q", got)
+	}
+	return cfg
+}
+
+func TestProtectSecrets_Reference(t *testing.T) {
+	cfg := loadSnapshotConfig(t)
+	cp, err := CloneConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	masked := ProtectSecrets(cp, SecretsReference)
+
+	if cp.Routes[0].BackendAuth.ClientSecret != "${env:SNAPSHOT_CLIENT_SECRET}" {
+		t.Errorf("expected the reference, got %q", cp.Routes[0].BackendAuth.ClientSecret)
+	}
+	if cp.CSRF.Secret != RedactedValue {
+		t.Errorf("expected inline secret masked, got %q", cp.CSRF.Secret)
+	}
+	if len(masked) != 1 || masked[0].Path != "CSRF.Secret" || masked[0].Reference != "" {
+		t.Errorf("expected only CSRF.Secret masked, got %+v", masked)
+	}
+	if cfg.CSRF.Secret != "inline-secret" {
+		t.Error("original config was mutated")
+	}
+}
+
+func TestProtectSecrets_Mask(t *testing.T) {
+	cfg := loadSnapshotConfig(t)
+	cp, _ := CloneConfig(cfg)
+	masked := ProtectSecrets(cp, SecretsMask)
+
+	if cp.Routes[0].BackendAuth.ClientSecret != RedactedValue {
+		t.Errorf("expected referenced secret masked, got %q", cp.Routes[0].BackendAuth.ClientSecret)
+	}
+	want := map[string]string{
+		"CSRF.Secret":                          "",
+		"Routes[api].BackendAuth.ClientSecret": "${env:SNAPSHOT_CLIENT_SECRET}",
+	}
+	if len(masked) != len(want) {
+		t.Fatalf("expected %d masked fields, got %+v", len(want), masked)
+	}
+	for _, m := range masked {
+		if ref, ok := want[m.Path]; !ok || ref != m.Reference {
+			t.Errorf("unexpected masked field %+v", m)
+		}
+	}
+}
+
+func TestRestoreSecrets(t *testing.T) {
+	cfg := loadSnapshotConfig(t)
+	cp, _ := CloneConfig(cfg)
+	masked := ProtectSecrets(cp, SecretsMask)
+
+	// The running config lost the route at index 0; paths are keyed by ID.
+	current, _ := CloneConfig(cfg)
+	current.Routes = append([]RouteConfig{{ID: "other"}}, current.Routes...)
+	if err := RestoreSecrets(cp, current, masked); err != nil {
+		t.Fatal(err)
+	}
+	if cp.CSRF.Secret != "inline-secret" {
+		t.Errorf("expected inline secret from the running config, got %q", cp.CSRF.Secret)
+	}
+	if cp.Routes[0].BackendAuth.ClientSecret != "${env:SNAPSHOT_CLIENT_SECRET}" {
+		t.Errorf("expected the reference for re-resolution, got %q", cp.Routes[0].BackendAuth.ClientSecret)
+	}
+
+	cp, _ = CloneConfig(cfg)
+	masked = ProtectSecrets(cp, SecretsMask)
+	current.CSRF.Secret = ""
+	err := RestoreSecrets(cp, current, masked)
+	if err == nil || !strings.Contains(err.Error(), "CSRF.Secret") {
+		t.Errorf("expected an error naming CSRF.Secret, got %v", err)
+	}
+}
@@ -2,17 +2,25 @@ package config
 
 import (
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net"
+	"net/textproto"
 	"net/url"
 	"os"
 	"path"
+	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"text/template"
 	"time"
+
+	"github.com/wudi/runway/internal/matchexpr"
+	"github.com/wudi/runway/internal/tmplutil"
 )
 
 // validateRoute validates a single route configuration by running all
@@ -25,10 +33,13 @@ func (l *Loader) validateRoute(route RouteConfig, cfg *Config) error {
 		l.validateMockAndStaticFiles,
 		l.validateFastCGI,
 		l.validateBackendAuthAndStatusMapping,
+		l.validateBackendHeadersSecret,
+		l.validateSharedAuthRefs,
 		l.validateSequentialProxy,
 		l.validateAggregateProxy,
 		l.validateSmallRouteFeatures,
 		l.validateRateLimiting,
+		l.validateConcurrencyLimit,
 		l.validateTrafficControls,
 		l.validateMirrorAndCORS,
 		l.validateResilienceFeatures,
@@ -42,8 +53,16 @@ func (l *Loader) validateRoute(route RouteConfig, cfg *Config) error {
 		l.validateDeprecation,
 		l.validateSLO,
 		l.validateTenantBackends,
+		l.validateHandlerFallbacks,
+		l.validateKafka,
+		l.validateDecisionLog,
+		l.validateCachePrime,
 		l.validateBatchBFeatures,
 		l.validateAI,
+		l.validateRouteMetadata,
+		l.validateRouteMetrics,
+		l.validateRouteListeners,
+		l.validateResponsePipeline,
 	}
 	for _, v := range validators {
 		if err := v(route, cfg); err != nil {
@@ -55,9 +74,9 @@ func (l *Loader) validateRoute(route RouteConfig, cfg *Config) error {
 
 // --- Route validator helpers ---
 
-func (l *Loader) validateRouteBasics(route RouteConfig, _ *Config) error {
+func (l *Loader) validateRouteBasics(route RouteConfig, cfg *Config) error {
 	routeID := route.ID
-	if len(route.Backends) == 0 && route.Service.Name == "" && !route.Versioning.Enabled && route.Upstream == "" && !route.Echo && !route.Static.Enabled && !route.Sequential.Enabled && !route.Aggregate.Enabled && !route.FastCGI.Enabled && !route.GraphQLFederation.Enabled && !route.AI.Enabled {
+	if len(route.Backends) == 0 && route.Service.Name == "" && !route.Versioning.Enabled && route.Upstream == "" && !route.Echo && !route.Static.Enabled && !route.Sequential.Enabled && !route.Aggregate.Enabled && !route.FastCGI.Enabled && !route.GraphQLFederation.Enabled && !route.AI.Enabled && !route.Kafka.Enabled {
 		return fmt.Errorf("route %s: must have either backends, service name, or upstream", routeID)
 	}
 	if route.Upstream != "" {
@@ -68,9 +87,31 @@ func (l *Loader) validateRouteBasics(route RouteConfig, _ *Config) error {
 			return fmt.Errorf("route %s: upstream and service are mutually exclusive", routeID)
 		}
 	}
+	if err := validateServiceDiscovery("route "+routeID, route.Service, cfg); err != nil {
+		return err
+	}
 	return l.validateMatchConfig(routeID, route.Match)
 }
 
+// validateServiceDiscovery validates a service block's metadata filter and
+// locality settings.
+func validateServiceDiscovery(scope string, svc ServiceConfig, cfg *Config) error {
+	for k := range svc.Meta {
+		if k == "" {
+			return fmt.Errorf("%s: service.meta keys must not be empty", scope)
+		}
+	}
+	if svc.LocalityAware {
+		if svc.Name == "" {
+			return fmt.Errorf("%s: service.locality_aware requires service.name", scope)
+		}
+		if cfg == nil || cfg.Registry.LocalZone == "" {
+			return fmt.Errorf("%s: service.locality_aware requires registry.local_zone", scope)
+		}
+	}
+	return nil
+}
+
 func (l *Loader) validateEchoExclusions(route RouteConfig, _ *Config) error {
 	if !route.Echo {
 		return nil
@@ -139,6 +180,7 @@ func (l *Loader) validatePassthroughExclusions(route RouteConfig, _ *Config) err
 		{route.OpenAPI.SpecFile != "" || route.OpenAPI.SpecID != "", "openapi"},
 		{route.RequestDecompression.Enabled, "request_decompression"},
 		{route.ResponseLimit.Enabled, "response_limit"},
+		{route.ResponseBuffering.Enabled, "response_buffering"},
 		{route.ContentReplacer.Enabled, "content_replacer"},
 		{route.BodyGenerator.Enabled, "body_generator"},
 		{route.Sequential.Enabled, "sequential"},
@@ -147,7 +189,9 @@ func (l *Loader) validatePassthroughExclusions(route RouteConfig, _ *Config) err
 		{route.ContentNegotiation.Enabled, "content_negotiation"},
 		{route.BackendEncoding.Encoding != "", "backend_encoding"},
 		{route.PIIRedaction.Enabled, "pii_redaction"},
+		{route.ResponseFieldPolicy.Enabled, "response_field_policy"},
 		{route.FieldEncryption.Enabled, "field_encryption"},
+		{route.ContentDedup.Enabled, "content_dedup"},
 		{route.FastCGI.Enabled, "fastcgi"},
 	}
 	for _, c := range checks {
@@ -230,18 +274,9 @@ func (l *Loader) validateFastCGI(route RouteConfig, _ *Config) error {
 
 func (l *Loader) validateBackendAuthAndStatusMapping(route RouteConfig, _ *Config) error {
 	routeID := route.ID
-	if route.BackendAuth.Enabled {
-		if route.BackendAuth.Type != "oauth2_client_credentials" {
-			return fmt.Errorf("route %s: backend_auth.type must be 'oauth2_client_credentials'", routeID)
-		}
-		if route.BackendAuth.TokenURL == "" {
-			return fmt.Errorf("route %s: backend_auth.token_url is required", routeID)
-		}
-		if route.BackendAuth.ClientID == "" {
-			return fmt.Errorf("route %s: backend_auth.client_id is required", routeID)
-		}
-		if route.BackendAuth.ClientSecret == "" {
-			return fmt.Errorf("route %s: backend_auth.client_secret is required", routeID)
+	if route.BackendAuth.Enabled && route.BackendAuth.Ref == "" {
+		if err := validateBackendAuthConfig(fmt.Sprintf("route %s: backend_auth", routeID), route.BackendAuth); err != nil {
+			return err
 		}
 	}
 	if route.StatusMapping.Enabled {
@@ -257,6 +292,197 @@ func (l *Loader) validateBackendAuthAndStatusMapping(route RouteConfig, _ *Confi
 	return nil
 }
 
+// validCacheStatusKey reports whether s is a status code such as "404"
+// or a status class such as "4xx".
+func validCacheStatusKey(s string) bool {
+	if len(s) != 3 || s[0] < '1' || s[0] > '5' {
+		return false
+	}
+	if s[1:] == "xx" {
+		return true
+	}
+	return s[1] >= '0' && s[1] <= '9' && s[2] >= '0' && s[2] <= '9'
+}
+
+// validABTestKey reports whether key is a supported A/B assignment
+// identifier. An empty key defaults to the client IP.
+func validABTestKey(key string) bool {
+	switch key {
+	case "", "ip", "client_id":
+		return true
+	}
+	for _, prefix := range []string{"header:", "cookie:", "jwt_claim:"} {
+		if strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateBandwidthQuota checks the fields shared by route and tenant
+// bandwidth quotas.
+func validateBandwidthQuota(prefix string, limit int64, period, mode string) error {
+	if limit <= 0 {
+		return fmt.Errorf("%s.limit must be > 0", prefix)
+	}
+	switch period {
+	case "hourly", "daily", "monthly", "yearly":
+	default:
+		return fmt.Errorf("%s.period must be hourly, daily, monthly, or yearly", prefix)
+	}
+	switch mode {
+	case "", "reject", "log_only":
+	default:
+		return fmt.Errorf("%s.mode must be reject or log_only", prefix)
+	}
+	return nil
+}
+
+// validateBackendAuthConfig checks an inline or shared backend_auth
+// definition. prefix names the config path in error messages.
+func validateBackendAuthConfig(prefix string, c BackendAuthConfig) error {
+	if c.Type != "oauth2_client_credentials" {
+		return fmt.Errorf("%s.type must be 'oauth2_client_credentials'", prefix)
+	}
+	if c.TokenURL == "" {
+		return fmt.Errorf("%s.token_url is required", prefix)
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("%s.client_id is required", prefix)
+	}
+	if c.ClientSecret == "" {
+		return fmt.Errorf("%s.client_secret is required", prefix)
+	}
+	return nil
+}
+
+// validateExtAuthConfig checks an inline or shared ext_auth definition.
+func validateExtAuthConfig(prefix string, c ExtAuthConfig) error {
+	if c.URL == "" {
+		return fmt.Errorf("%s.url is required when enabled", prefix)
+	}
+	if !strings.HasPrefix(c.URL, "http://") &&
+		!strings.HasPrefix(c.URL, "https://") &&
+		!strings.HasPrefix(c.URL, "grpc://") {
+		return fmt.Errorf("%s.url must start with http://, https://, or grpc://", prefix)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("%s.timeout must be >= 0", prefix)
+	}
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("%s.cache_ttl must be >= 0", prefix)
+	}
+	if c.TLS.Enabled && strings.HasPrefix(c.URL, "http://") {
+		return fmt.Errorf("%s.tls cannot be used with http:// URL", prefix)
+	}
+	return nil
+}
+
+// validateOPAConfig checks an inline or shared opa definition.
+func validateOPAConfig(prefix string, c OPAConfig) error {
+	if c.URL == "" {
+		return fmt.Errorf("%s.url is required", prefix)
+	}
+	if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+		return fmt.Errorf("%s.url must start with http:// or https://", prefix)
+	}
+	if c.PolicyPath == "" {
+		return fmt.Errorf("%s.policy_path is required", prefix)
+	}
+	if c.ShadowPolicyPath != "" && !c.ShadowAsync {
+		return fmt.Errorf("%s.shadow_policy_path requires shadow_async", prefix)
+	}
+	return nil
+}
+
+// validateSharedAuthRefs checks that ext_auth, opa, backend_auth and
+// token_exchange refs name an existing shared definition and only set the
+// fields a route may override.
+func (l *Loader) validateSharedAuthRefs(route RouteConfig, cfg *Config) error {
+	routeID := route.ID
+	if ref := route.ExtAuth.Ref; ref != "" {
+		if _, ok := cfg.ExtAuthServices[ref]; !ok {
+			return fmt.Errorf("route %s: ext_auth ref %q not found in ext_auth_services", routeID, ref)
+		}
+		ea := route.ExtAuth
+		if ea.URL != "" || len(ea.HeadersToSend) > 0 || len(ea.HeadersToInject) > 0 || ea.CacheTTL != 0 || ea.TLS.Enabled {
+			return fmt.Errorf("route %s: ext_auth with ref only allows timeout and fail_open overrides", routeID)
+		}
+		if ea.Timeout < 0 {
+			return fmt.Errorf("route %s: ext_auth.timeout must be >= 0", routeID)
+		}
+	}
+	if ref := route.OPA.Ref; ref != "" {
+		if _, ok := cfg.OPAPolicies[ref]; !ok {
+			return fmt.Errorf("route %s: opa ref %q not found in opa_policies", routeID, ref)
+		}
+		o := route.OPA
+		if o.URL != "" || o.PolicyPath != "" || o.IncludeBody || o.CacheTTL != 0 || len(o.Headers) > 0 ||
+			o.ShadowAsync || o.ShadowPolicyPath != "" {
+			return fmt.Errorf("route %s: opa with ref only allows timeout and fail_open overrides", routeID)
+		}
+		if o.Timeout < 0 {
+			return fmt.Errorf("route %s: opa.timeout must be >= 0", routeID)
+		}
+	}
+	if ref := route.BackendAuth.Ref; ref != "" {
+		if _, ok := cfg.BackendAuthProviders[ref]; !ok {
+			return fmt.Errorf("route %s: backend_auth ref %q not found in backend_auth_providers", routeID, ref)
+		}
+		ba := route.BackendAuth
+		if ba.Type != "" || ba.TokenURL != "" || ba.ClientID != "" || ba.ClientSecret != "" ||
+			len(ba.Scopes) > 0 || len(ba.ExtraParams) > 0 || ba.Timeout != 0 {
+			return fmt.Errorf("route %s: backend_auth with ref cannot set other fields", routeID)
+		}
+	}
+	if ref := route.TokenExchange.Ref; ref != "" {
+		if _, ok := cfg.TokenExchangers[ref]; !ok {
+			return fmt.Errorf("route %s: token_exchange ref %q not found in token_exchangers", routeID, ref)
+		}
+		te := route.TokenExchange
+		te.Enabled, te.Ref = false, ""
+		if !reflect.DeepEqual(te, TokenExchangeConfig{}) {
+			return fmt.Errorf("route %s: token_exchange with ref cannot set other fields", routeID)
+		}
+	}
+	return nil
+}
+
+func (l *Loader) validateBackendHeadersSecret(route RouteConfig, _ *Config) error {
+	bhs := route.BackendHeadersSecret
+	if !bhs.Enabled {
+		return nil
+	}
+	routeID := route.ID
+	if len(bhs.Headers) == 0 {
+		return fmt.Errorf("route %s: backend_headers_secret requires at least one header", routeID)
+	}
+	if bhs.RefreshInterval < 0 {
+		return fmt.Errorf("route %s: backend_headers_secret.refresh_interval must be >= 0", routeID)
+	}
+	seen := make(map[string]bool, len(bhs.Headers))
+	for i, h := range bhs.Headers {
+		if h.Name == "" {
+			return fmt.Errorf("route %s: backend_headers_secret.headers[%d].name is required", routeID, i)
+		}
+		name := strings.ToLower(h.Name)
+		if seen[name] {
+			return fmt.Errorf("route %s: backend_headers_secret.headers[%d]: duplicate header %q", routeID, i, h.Name)
+		}
+		seen[name] = true
+		if h.Primary == "" {
+			return fmt.Errorf("route %s: backend_headers_secret.headers[%d].primary is required", routeID, i)
+		}
+		if h.SecondaryPercent < 0 || h.SecondaryPercent > 100 {
+			return fmt.Errorf("route %s: backend_headers_secret.headers[%d].secondary_percent must be between 0 and 100", routeID, i)
+		}
+		if h.SecondaryPercent > 0 && h.Secondary == "" {
+			return fmt.Errorf("route %s: backend_headers_secret.headers[%d].secondary_percent requires secondary", routeID, i)
+		}
+	}
+	return nil
+}
+
 func (l *Loader) validateSequentialProxy(route RouteConfig, _ *Config) error {
 	if !route.Sequential.Enabled {
 		return nil
@@ -269,6 +495,12 @@ func (l *Loader) validateSequentialProxy(route RouteConfig, _ *Config) error {
 		if step.URL == "" {
 			return fmt.Errorf("route %s: sequential step %d requires a URL", routeID, j)
 		}
+		if err := validatePropagateHeaders(fmt.Sprintf("route %s: sequential step %d", routeID, j), step.PropagateHeaders); err != nil {
+			return err
+		}
+	}
+	if route.Sequential.MaxPropagatedHeaders < 0 || route.Sequential.MaxPropagatedHeaderBytes < 0 {
+		return fmt.Errorf("route %s: sequential max_propagated_headers and max_propagated_header_bytes must be >= 0", routeID)
 	}
 	if route.Echo {
 		return fmt.Errorf("route %s: sequential is mutually exclusive with echo", routeID)
@@ -282,7 +514,64 @@ func (l *Loader) validateSequentialProxy(route RouteConfig, _ *Config) error {
 	return nil
 }
 
-func (l *Loader) validateAggregateProxy(route RouteConfig, _ *Config) error {
+// hopByHopHeaders are never propagated from sub-responses.
+var hopByHopHeaders = map[string]bool{
+	"Connection": true, "Proxy-Connection": true, "Keep-Alive": true, "Proxy-Authenticate": true,
+	"Proxy-Authorization": true, "Te": true, "Trailer": true, "Transfer-Encoding": true, "Upgrade": true,
+	"Content-Length": true,
+}
+
+// validatePropagateHeaders checks a sequential step's or aggregate backend's
+// propagate_headers block.
+func validatePropagateHeaders(scope string, cfg PropagateHeadersConfig) error {
+	if len(cfg.Headers) == 0 {
+		if len(cfg.Rename) > 0 {
+			return fmt.Errorf("%s: propagate_headers.rename requires headers", scope)
+		}
+		return nil
+	}
+	var exact []string
+	var prefixes []string
+	for _, h := range cfg.Headers {
+		name, isPrefix := strings.CutSuffix(h, "*")
+		if name == "" || strings.Contains(name, "*") {
+			return fmt.Errorf("%s: propagate_headers.headers: invalid entry %q", scope, h)
+		}
+		canon := textproto.CanonicalMIMEHeaderKey(name)
+		if isPrefix {
+			prefixes = append(prefixes, canon)
+			continue
+		}
+		if hopByHopHeaders[canon] {
+			return fmt.Errorf("%s: propagate_headers.headers: %s cannot be propagated", scope, canon)
+		}
+		if canon == "Set-Cookie" && !cfg.AllowSetCookie {
+			return fmt.Errorf("%s: propagate_headers.headers: Set-Cookie requires allow_set_cookie", scope)
+		}
+		exact = append(exact, canon)
+	}
+	for from, to := range cfg.Rename {
+		canon := textproto.CanonicalMIMEHeaderKey(from)
+		covered := slices.Contains(exact, canon)
+		for _, p := range prefixes {
+			covered = covered || strings.HasPrefix(canon, p)
+		}
+		if !covered {
+			return fmt.Errorf("%s: propagate_headers.rename: %s is not listed in headers", scope, from)
+		}
+		if to == "" || hopByHopHeaders[textproto.CanonicalMIMEHeaderKey(to)] {
+			return fmt.Errorf("%s: propagate_headers.rename: invalid target %q for %s", scope, to, from)
+		}
+	}
+	switch cfg.Conflict {
+	case "", "first", "last", "join":
+	default:
+		return fmt.Errorf("%s: propagate_headers.conflict must be 'first', 'last' or 'join'", scope)
+	}
+	return nil
+}
+
+func (l *Loader) validateAggregateProxy(route RouteConfig, cfg *Config) error {
 	if !route.Aggregate.Enabled {
 		return nil
 	}
@@ -302,6 +591,28 @@ func (l *Loader) validateAggregateProxy(route RouteConfig, _ *Config) error {
 		if ab.URL == "" {
 			return fmt.Errorf("route %s: aggregate backend %s requires a URL", routeID, ab.Name)
 		}
+		scope := fmt.Sprintf("route %s: aggregate backend %s", routeID, ab.Name)
+		if err := validatePropagateHeaders(scope, ab.PropagateHeaders); err != nil {
+			return err
+		}
+		if err := validateCopyRequestPolicy(scope, ab.CopyRequestPolicy); err != nil {
+			return err
+		}
+		if ba := ab.BackendAuth; ba.Enabled || ba.Ref != "" {
+			if ba.Ref == "" {
+				return fmt.Errorf("%s: backend_auth requires ref to a backend_auth_providers entry", scope)
+			}
+			if _, ok := cfg.BackendAuthProviders[ba.Ref]; !ok {
+				return fmt.Errorf("%s: backend_auth ref %q not found in backend_auth_providers", scope, ba.Ref)
+			}
+			if ba.Type != "" || ba.TokenURL != "" || ba.ClientID != "" || ba.ClientSecret != "" ||
+				len(ba.Scopes) > 0 || len(ba.ExtraParams) > 0 || ba.Timeout != 0 {
+				return fmt.Errorf("%s: backend_auth with ref cannot set other fields", scope)
+			}
+		}
+	}
+	if route.Aggregate.MaxPropagatedHeaders < 0 || route.Aggregate.MaxPropagatedHeaderBytes < 0 {
+		return fmt.Errorf("route %s: aggregate max_propagated_headers and max_propagated_header_bytes must be >= 0", routeID)
 	}
 	fs := route.Aggregate.FailStrategy
 	if fs != "" && fs != "abort" && fs != "partial" {
@@ -322,6 +633,60 @@ func (l *Loader) validateAggregateProxy(route RouteConfig, _ *Config) error {
 	return nil
 }
 
+// validateCopyRequestPolicy checks the strip_headers, set_headers and
+// forward_query_params of a mirror or aggregate backend.
+func validateCopyRequestPolicy(scope string, p CopyRequestPolicy) error {
+	validName := func(name string) bool {
+		return name != "" && !strings.ContainsAny(name, " \t\r\n:")
+	}
+	for _, name := range p.StripHeaders {
+		if !validName(name) {
+			return fmt.Errorf("%s: strip_headers: invalid header name %q", scope, name)
+		}
+	}
+	funcs := tmplutil.FuncMap()
+	funcs["secret"] = func(string) (string, error) { return "", nil }
+	for _, name := range slices.Sorted(maps.Keys(p.SetHeaders)) {
+		if !validName(name) {
+			return fmt.Errorf("%s: set_headers: invalid header name %q", scope, name)
+		}
+		if textproto.CanonicalMIMEHeaderKey(name) == "Host" {
+			return fmt.Errorf("%s: set_headers cannot set Host", scope)
+		}
+		if _, err := template.New(name).Funcs(funcs).Parse(p.SetHeaders[name]); err != nil {
+			return fmt.Errorf("%s: set_headers %s: invalid template: %w", scope, name, err)
+		}
+	}
+	q := p.ForwardQueryParams
+	if len(q.Allow) > 0 && len(q.Deny) > 0 {
+		return fmt.Errorf("%s: forward_query_params allow and deny are mutually exclusive", scope)
+	}
+	for _, name := range append(slices.Clone(q.Allow), q.Deny...) {
+		if name == "" {
+			return fmt.Errorf("%s: forward_query_params entries must be non-empty", scope)
+		}
+	}
+	return nil
+}
+
+// validateIPv6AggregationPrefix checks the ipv6_aggregation_prefix of
+// field; 0 selects the default.
+func validateIPv6AggregationPrefix(field string, bits int) error {
+	if bits < 0 || bits > 128 {
+		return fmt.Errorf("%s must be between 0 and 128", field)
+	}
+	return nil
+}
+
+// validateSpikeArrestMode checks spike_arrest.mode.
+func validateSpikeArrestMode(mode string) error {
+	switch mode {
+	case "", "enforce", "observe":
+		return nil
+	}
+	return fmt.Errorf("spike_arrest.mode must be \"enforce\" or \"observe\"")
+}
+
 func (l *Loader) validateSmallRouteFeatures(route RouteConfig, _ *Config) error {
 	routeID := route.ID
 
@@ -329,6 +694,12 @@ func (l *Loader) validateSmallRouteFeatures(route RouteConfig, _ *Config) error
 	if route.SpikeArrest.Enabled && route.SpikeArrest.Rate <= 0 {
 		return fmt.Errorf("route %s: spike_arrest rate must be > 0 when enabled", routeID)
 	}
+	if err := validateSpikeArrestMode(route.SpikeArrest.Mode); err != nil {
+		return fmt.Errorf("route %s: %w", routeID, err)
+	}
+	if err := validateIPv6AggregationPrefix("spike_arrest.ipv6_aggregation_prefix", route.SpikeArrest.IPv6AggregationPrefix); err != nil {
+		return fmt.Errorf("route %s: %w", routeID, err)
+	}
 
 	// Content replacer
 	if route.ContentReplacer.Enabled {
@@ -347,6 +718,10 @@ func (l *Loader) validateSmallRouteFeatures(route RouteConfig, _ *Config) error
 		return fmt.Errorf("route %s: follow_redirects max_redirects must be >= 0", routeID)
 	}
 
+	if route.ExpectContinueThreshold < 0 {
+		return fmt.Errorf("route %s: expect_continue_threshold must be >= 0", routeID)
+	}
+
 	// Body generator
 	if route.BodyGenerator.Enabled {
 		if route.BodyGenerator.Template == "" {
@@ -401,16 +776,38 @@ func (l *Loader) validateSmallRouteFeatures(route RouteConfig, _ *Config) error
 		}
 	}
 
+	// Stream limits
+	if sl := route.StreamLimits; sl != (StreamLimitsConfig{}) {
+		if sl.MaxUpstreamStreams < 0 {
+			return fmt.Errorf("route %s: stream_limits.max_upstream_streams must be >= 0", routeID)
+		}
+		if sl.RetryAfter < 0 {
+			return fmt.Errorf("route %s: stream_limits.retry_after must be >= 0", routeID)
+		}
+		switch sl.OnLimit {
+		case "", "reject":
+		case "fanout":
+			if !route.SSE.Enabled {
+				return fmt.Errorf("route %s: stream_limits.on_limit fanout requires sse", routeID)
+			}
+		default:
+			return fmt.Errorf("route %s: stream_limits.on_limit must be reject or fanout", routeID)
+		}
+		if !route.SSE.Enabled && !route.WebSocket.Enabled {
+			return fmt.Errorf("route %s: stream_limits requires sse or websocket", routeID)
+		}
+	}
+
 	// Content negotiation
 	if route.ContentNegotiation.Enabled {
-		validFormats := map[string]bool{"json": true, "xml": true, "yaml": true}
+		validFormats := map[string]bool{"json": true, "xml": true, "yaml": true, "cbor": true}
 		for _, f := range route.ContentNegotiation.Supported {
 			if !validFormats[f] {
-				return fmt.Errorf("route %s: content_negotiation supported format %q must be json, xml, or yaml", routeID, f)
+				return fmt.Errorf("route %s: content_negotiation supported format %q must be json, xml, yaml, or cbor", routeID, f)
 			}
 		}
 		if route.ContentNegotiation.Default != "" && !validFormats[route.ContentNegotiation.Default] {
-			return fmt.Errorf("route %s: content_negotiation default %q must be json, xml, or yaml", routeID, route.ContentNegotiation.Default)
+			return fmt.Errorf("route %s: content_negotiation default %q must be json, xml, yaml, or cbor", routeID, route.ContentNegotiation.Default)
 		}
 	}
 
@@ -474,10 +871,38 @@ func (l *Loader) validateSmallRouteFeatures(route RouteConfig, _ *Config) error
 		}
 	}
 
+	// Cache budgets
+	if route.Cache.MaxEntries < 0 {
+		return fmt.Errorf("route %s: cache.max_entries must be >= 0", routeID)
+	}
+	if route.Cache.MaxBytes < 0 {
+		return fmt.Errorf("route %s: cache.max_bytes must be >= 0", routeID)
+	}
+	if route.Cache.MaxVariantsPerPath < 0 {
+		return fmt.Errorf("route %s: cache.max_variants_per_path must be >= 0", routeID)
+	}
+
 	// Backend encoding
 	if route.BackendEncoding.Encoding != "" {
-		if route.BackendEncoding.Encoding != "xml" && route.BackendEncoding.Encoding != "yaml" {
-			return fmt.Errorf("route %s: backend_encoding encoding must be 'xml' or 'yaml', got %q", routeID, route.BackendEncoding.Encoding)
+		be := route.BackendEncoding
+		switch be.Encoding {
+		case "xml", "yaml", "cbor", "avro":
+		default:
+			return fmt.Errorf("route %s: backend_encoding encoding must be 'xml', 'yaml', 'cbor' or 'avro', got %q", routeID, be.Encoding)
+		}
+		if be.MaxBodySize < 0 {
+			return fmt.Errorf("route %s: backend_encoding.max_body_size must be >= 0", routeID)
+		}
+		if be.Encoding == "avro" {
+			if (be.Avro.SchemaFile == "") == (be.Avro.RegistryURL == "") {
+				return fmt.Errorf("route %s: backend_encoding avro requires exactly one of avro.schema_file or avro.registry_url", routeID)
+			}
+			if be.Avro.RegistryURL != "" && !strings.HasPrefix(be.Avro.RegistryURL, "http://") && !strings.HasPrefix(be.Avro.RegistryURL, "https://") {
+				return fmt.Errorf("route %s: backend_encoding avro.registry_url must start with http:// or https://", routeID)
+			}
+			if be.Avro.Timeout < 0 || be.Avro.CacheSize < 0 {
+				return fmt.Errorf("route %s: backend_encoding avro.timeout and avro.cache_size must be >= 0", routeID)
+			}
 		}
 	}
 
@@ -495,6 +920,23 @@ func (l *Loader) validateSmallRouteFeatures(route RouteConfig, _ *Config) error
 		}
 	}
 
+	if err := validateIPv6AggregationPrefix("quota.ipv6_aggregation_prefix", route.Quota.IPv6AggregationPrefix); err != nil {
+		return fmt.Errorf("route %s: %w", routeID, err)
+	}
+
+	// Bandwidth quota
+	if bq := route.BandwidthQuota; bq.Enabled {
+		if err := validateBandwidthQuota(fmt.Sprintf("route %s: bandwidth_quota", routeID), bq.Limit, bq.Period, bq.Mode); err != nil {
+			return err
+		}
+		if bq.Key == "" {
+			return fmt.Errorf("route %s: bandwidth_quota.key is required", routeID)
+		}
+	}
+	if err := validateIPv6AggregationPrefix("bandwidth_quota.ipv6_aggregation_prefix", route.BandwidthQuota.IPv6AggregationPrefix); err != nil {
+		return fmt.Errorf("route %s: %w", routeID, err)
+	}
+
 	// Proxy rate limit
 	if route.ProxyRateLimit.Enabled {
 		if route.ProxyRateLimit.Rate <= 0 {
@@ -520,9 +962,332 @@ func (l *Loader) validateSmallRouteFeatures(route RouteConfig, _ *Config) error
 	return nil
 }
 
+// validateXMLLimits checks the global xml_limits block.
+func validateXMLLimits(c XMLLimitsConfig) error {
+	if c.MaxBytes < 0 || c.MaxDepth < 0 || c.MaxAttributes < 0 || c.MaxEntities < 0 || c.MaxEntityExpansion < 0 {
+		return fmt.Errorf("xml_limits: limits must be >= 0")
+	}
+	return nil
+}
+
+// metadataKeyPattern restricts route metadata keys to names usable as
+// Prometheus labels, rules fields and $route.metadata.<key> variables.
+var metadataKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func (l *Loader) validateRouteMetadata(route RouteConfig, _ *Config) error {
+	routeID := route.ID
+	if len(route.Metadata) > MaxRouteMetadataKeys {
+		return fmt.Errorf("route %s: metadata has %d keys (max %d)", routeID, len(route.Metadata), MaxRouteMetadataKeys)
+	}
+	for k, v := range route.Metadata {
+		if !metadataKeyPattern.MatchString(k) {
+			return fmt.Errorf("route %s: metadata key %q must match %s", routeID, k, metadataKeyPattern)
+		}
+		if len(v) > MaxRouteMetadataValueLength {
+			return fmt.Errorf("route %s: metadata.%s exceeds %d bytes", routeID, k, MaxRouteMetadataValueLength)
+		}
+	}
+	for _, k := range route.ExposeMetadataHeaders {
+		if _, ok := route.Metadata[k]; !ok {
+			return fmt.Errorf("route %s: expose_metadata_headers key %q is not in metadata", routeID, k)
+		}
+	}
+	return nil
+}
+
+func (l *Loader) validateRouteMetrics(route RouteConfig, _ *Config) error {
+	return validateRequestMetrics("route "+route.ID+": metrics", route.Metrics)
+}
+
+func (l *Loader) validateRouteListeners(route RouteConfig, cfg *Config) error {
+	seen := make(map[string]bool, len(route.Listeners))
+	for _, id := range route.Listeners {
+		if seen[id] {
+			return fmt.Errorf("route %s: listeners: duplicate listener %q", route.ID, id)
+		}
+		seen[id] = true
+		i := slices.IndexFunc(cfg.Listeners, func(lc ListenerConfig) bool { return lc.ID == id })
+		if i < 0 {
+			return fmt.Errorf("route %s: listeners: unknown listener %q", route.ID, id)
+		}
+		if cfg.Listeners[i].Protocol != ProtocolHTTP {
+			return fmt.Errorf("route %s: listeners: listener %q is not an http listener", route.ID, id)
+		}
+	}
+	return nil
+}
+
+// validateSelfServiceScope checks the listeners of the self-service API key
+// endpoints and rejects routes they would shadow: routes on a shared listener
+// whose path is the endpoints' prefix or lies under it.
+func validateSelfServiceScope(ss APIKeySelfServiceConfig, cfg *Config) error {
+	const field = "authentication.api_key.management.self_service"
+	seen := make(map[string]bool, len(ss.Listeners))
+	for _, id := range ss.Listeners {
+		if seen[id] {
+			return fmt.Errorf("%s.listeners: duplicate listener %q", field, id)
+		}
+		seen[id] = true
+		i := slices.IndexFunc(cfg.Listeners, func(lc ListenerConfig) bool { return lc.ID == id })
+		if i < 0 {
+			return fmt.Errorf("%s.listeners: unknown listener %q", field, id)
+		}
+		if cfg.Listeners[i].Protocol != ProtocolHTTP {
+			return fmt.Errorf("%s.listeners: listener %q is not an http listener", field, id)
+		}
+	}
+	prefix := strings.TrimRight(ss.PathPrefix, "/")
+	if prefix == "" {
+		prefix = "/keys"
+	}
+	for _, route := range cfg.Routes {
+		if route.Path != prefix && !strings.HasPrefix(route.Path, prefix+"/") {
+			continue
+		}
+		shared := len(ss.Listeners) == 0 || len(route.Listeners) == 0 ||
+			slices.ContainsFunc(route.Listeners, func(id string) bool { return seen[id] })
+		if shared {
+			return fmt.Errorf("%s: path_prefix %q shadows route %s (path %s); change the prefix or set listeners", field, prefix, route.ID, route.Path)
+		}
+	}
+	return nil
+}
+
+// validateRequestMetrics checks a request metrics mode and status label.
+func validateRequestMetrics(field string, c RequestMetricsConfig) error {
+	switch c.Mode {
+	case "", MetricsModeHistogram, MetricsModeSummary, MetricsModeCounters:
+	default:
+		return fmt.Errorf("%s: mode must be histogram, summary or counters, got %q", field, c.Mode)
+	}
+	switch c.Status {
+	case "", MetricsStatusCode, MetricsStatusClass:
+	default:
+		return fmt.Errorf("%s: status must be code or class, got %q", field, c.Status)
+	}
+	return nil
+}
+
+// validateMetricsConfig checks the admin.metrics request instrument settings.
+func validateMetricsConfig(c MetricsConfig) error {
+	if err := validateRequestMetrics("admin.metrics.requests", c.Requests); err != nil {
+		return err
+	}
+	for i, b := range c.Buckets {
+		if b <= 0 {
+			return fmt.Errorf("admin.metrics.buckets: bounds must be > 0")
+		}
+		if i > 0 && b <= c.Buckets[i-1] {
+			return fmt.Errorf("admin.metrics.buckets: bounds must be strictly increasing")
+		}
+	}
+	if c.SummaryWindow < 0 {
+		return fmt.Errorf("admin.metrics.summary_window must be >= 0")
+	}
+	return nil
+}
+
+// validateRouteMetadataConfig checks the global route_metadata allowlists.
+func validateRouteMetadataConfig(c RouteMetadataConfig) error {
+	for _, list := range []struct {
+		field string
+		keys  []string
+	}{{"log_keys", c.LogKeys}, {"metric_labels", c.MetricLabels}} {
+		field, keys := list.field, list.keys
+		seen := make(map[string]bool, len(keys))
+		for _, k := range keys {
+			if !metadataKeyPattern.MatchString(k) {
+				return fmt.Errorf("route_metadata.%s: invalid key %q", field, k)
+			}
+			if seen[k] {
+				return fmt.Errorf("route_metadata.%s: duplicate key %q", field, k)
+			}
+			seen[k] = true
+		}
+	}
+	if slices.Contains(c.MetricLabels, "route") {
+		return fmt.Errorf("route_metadata.metric_labels: \"route\" is reserved for the route ID")
+	}
+	return nil
+}
+
+// validateStorageEncryption checks the storage_encryption keys and that
+// every route asking for encrypt: true has a key and a distributed store.
+func validateStorageEncryption(cfg *Config) error {
+	se := cfg.StorageEncryption
+	if se.KeyBase64 == "" {
+		if se.KeyID != "" || se.PreviousKeyID != "" || se.PreviousKeyBase64 != "" {
+			return fmt.Errorf("storage_encryption.key_base64 is required")
+		}
+	} else {
+		if err := validateStorageKey("key", se.KeyID, se.KeyBase64); err != nil {
+			return err
+		}
+		if se.PreviousKeyBase64 != "" || se.PreviousKeyID != "" {
+			if err := validateStorageKey("previous_key", se.PreviousKeyID, se.PreviousKeyBase64); err != nil {
+				return err
+			}
+			if se.PreviousKeyID == se.KeyID {
+				return fmt.Errorf("storage_encryption.previous_key_id must differ from key_id")
+			}
+		}
+		if se.PreviousKeyGrace < 0 {
+			return fmt.Errorf("storage_encryption.previous_key_grace must be >= 0")
+		}
+	}
+
+	check := func(scope, field string, encrypt bool, mode string) error {
+		if !encrypt {
+			return nil
+		}
+		if mode != "distributed" {
+			return fmt.Errorf("%s: %s.encrypt requires mode \"distributed\"", scope, field)
+		}
+		if se.KeyBase64 == "" {
+			return fmt.Errorf("%s: %s.encrypt requires storage_encryption.key_base64", scope, field)
+		}
+		return nil
+	}
+	global := cfg.Idempotency
+	if global.Enabled {
+		if err := check("global", "idempotency", global.Encrypt, global.Mode); err != nil {
+			return err
+		}
+	}
+	bucketEncrypt := make(map[string]bool)
+	for _, route := range cfg.Routes {
+		scope := "route " + route.ID
+		if route.Cache.Enabled {
+			if err := check(scope, "cache", route.Cache.Encrypt, route.Cache.Mode); err != nil {
+				return err
+			}
+			if b := route.Cache.Bucket; b != "" {
+				if enc, seen := bucketEncrypt[b]; seen && enc != route.Cache.Encrypt {
+					return fmt.Errorf("%s: routes sharing cache bucket %q must agree on cache.encrypt", scope, b)
+				}
+				bucketEncrypt[b] = route.Cache.Encrypt
+			}
+		}
+		if idem := route.Idempotency; idem.Enabled {
+			mode := idem.Mode
+			if mode == "" {
+				mode = global.Mode
+			}
+			if err := check(scope, "idempotency", idem.Encrypt || global.Encrypt, mode); err != nil {
+				return err
+			}
+		}
+		if route.RequestDedup.Enabled {
+			if err := check(scope, "request_dedup", route.RequestDedup.Encrypt, route.RequestDedup.Mode); err != nil {
+				return err
+			}
+		}
+		if route.ContentDedup.Enabled {
+			if err := check(scope, "content_dedup", route.ContentDedup.Encrypt, route.ContentDedup.Mode); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateStorageKey checks one storage_encryption key ID and its key.
+func validateStorageKey(field, id, keyBase64 string) error {
+	if id == "" || len(id) > 255 {
+		return fmt.Errorf("storage_encryption.%s_id must be 1-255 bytes", field)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(keyBase64)
+	if err != nil {
+		return fmt.Errorf("storage_encryption.%s_base64 must be valid base64: %v", field, err)
+	}
+	if len(decoded) != 32 {
+		return fmt.Errorf("storage_encryption.%s_base64 must decode to exactly 32 bytes (got %d)", field, len(decoded))
+	}
+	return nil
+}
+
+// validateClientKey checks a per-client key extractor as used by rate_limit.key.
+func validateClientKey(routeID, field, key string) error {
+	if err := checkClientKey(field, key); err != nil {
+		return fmt.Errorf("route %s: %w", routeID, err)
+	}
+	return nil
+}
+
+// checkClientKey checks the key extractor syntax of rate_limit.key.
+func checkClientKey(field, key string) error {
+	switch {
+	case key == "ip", key == "client_id":
+		// valid
+	case strings.HasPrefix(key, "header:"):
+		if key[len("header:"):] == "" {
+			return fmt.Errorf("%s \"header:\" requires a non-empty header name", field)
+		}
+	case strings.HasPrefix(key, "cookie:"):
+		if key[len("cookie:"):] == "" {
+			return fmt.Errorf("%s \"cookie:\" requires a non-empty cookie name", field)
+		}
+	case strings.HasPrefix(key, "jwt_claim:"):
+		if key[len("jwt_claim:"):] == "" {
+			return fmt.Errorf("%s \"jwt_claim:\" requires a non-empty claim name", field)
+		}
+	case strings.HasPrefix(key, "baggage:"):
+		if key[len("baggage:"):] == "" {
+			return fmt.Errorf("%s \"baggage:\" requires a non-empty baggage key", field)
+		}
+	case strings.HasPrefix(key, "body:"):
+		if key[len("body:"):] == "" {
+			return fmt.Errorf("%s \"body:\" requires a non-empty body field path", field)
+		}
+	default:
+		return fmt.Errorf("invalid %s %q (must be \"ip\", \"client_id\", \"header:<name>\", \"cookie:<name>\", \"jwt_claim:<name>\", \"baggage:<key>\", or \"body:<path>\")", field, key)
+	}
+	return nil
+}
+
+func (l *Loader) validateConcurrencyLimit(route RouteConfig, cfg *Config) error {
+	cl := route.ConcurrencyLimit
+	if !cl.Enabled {
+		return nil
+	}
+	routeID := route.ID
+	if cl.MaxInFlight <= 0 {
+		return fmt.Errorf("route %s: concurrency_limit.max_in_flight must be > 0", routeID)
+	}
+	if cl.Key != "" {
+		if err := validateClientKey(routeID, "concurrency_limit.key", cl.Key); err != nil {
+			return err
+		}
+		if strings.HasPrefix(cl.Key, "baggage:") {
+			return fmt.Errorf("route %s: concurrency_limit.key \"baggage:\" is not supported; concurrency limiting runs before the baggage middleware", routeID)
+		}
+	}
+	if cl.MaxWait < 0 || cl.QueueSize < 0 || cl.MaxKeys < 0 || cl.LeaseTTL < 0 {
+		return fmt.Errorf("route %s: concurrency_limit max_wait, queue_size, max_keys and lease_ttl must be >= 0", routeID)
+	}
+	switch cl.Mode {
+	case "", "local":
+	case "distributed":
+		if cfg.Redis.Address == "" {
+			return fmt.Errorf("route %s: distributed concurrency_limit requires redis.address to be configured", routeID)
+		}
+		if cl.MaxWait > 0 {
+			return fmt.Errorf("route %s: concurrency_limit.max_wait is not supported in distributed mode", routeID)
+		}
+	default:
+		return fmt.Errorf("route %s: concurrency_limit.mode must be \"local\" or \"distributed\"", routeID)
+	}
+	return nil
+}
+
 func (l *Loader) validateRateLimiting(route RouteConfig, cfg *Config) error {
 	routeID := route.ID
 
+	switch route.RateLimit.Mode {
+	case "", "local", "distributed", "observe":
+	default:
+		return fmt.Errorf("route %s: rate_limit.mode must be \"local\", \"distributed\" or \"observe\"", routeID)
+	}
 	if route.RateLimit.Mode == "distributed" && cfg.Redis.Address == "" {
 		return fmt.Errorf("route %s: distributed rate limiting requires redis.address to be configured", routeID)
 	}
@@ -538,25 +1303,15 @@ func (l *Loader) validateRateLimiting(route RouteConfig, cfg *Config) error {
 	if route.RateLimit.Key != "" && route.RateLimit.PerIP {
 		return fmt.Errorf("route %s: rate_limit.key and rate_limit.per_ip are mutually exclusive", routeID)
 	}
+	if err := validateIPv6AggregationPrefix("rate_limit.ipv6_aggregation_prefix", route.RateLimit.IPv6AggregationPrefix); err != nil {
+		return fmt.Errorf("route %s: %w", routeID, err)
+	}
 	if route.RateLimit.Key != "" {
-		key := route.RateLimit.Key
-		switch {
-		case key == "ip", key == "client_id":
-			// valid
-		case strings.HasPrefix(key, "header:"):
-			if key[len("header:"):] == "" {
-				return fmt.Errorf("route %s: rate_limit.key \"header:\" requires a non-empty header name", routeID)
-			}
-		case strings.HasPrefix(key, "cookie:"):
-			if key[len("cookie:"):] == "" {
-				return fmt.Errorf("route %s: rate_limit.key \"cookie:\" requires a non-empty cookie name", routeID)
-			}
-		case strings.HasPrefix(key, "jwt_claim:"):
-			if key[len("jwt_claim:"):] == "" {
-				return fmt.Errorf("route %s: rate_limit.key \"jwt_claim:\" requires a non-empty claim name", routeID)
-			}
-		default:
-			return fmt.Errorf("route %s: invalid rate_limit.key %q (must be \"ip\", \"client_id\", \"header:<name>\", \"cookie:<name>\", or \"jwt_claim:<name>\")", routeID, key)
+		if err := validateClientKey(routeID, "rate_limit.key", route.RateLimit.Key); err != nil {
+			return err
+		}
+		if key, ok := strings.CutPrefix(route.RateLimit.Key, "baggage:"); ok && !baggageTagKeys(route, cfg)[key] {
+			return fmt.Errorf("route %s: rate_limit.key %q must name a tag of the route's baggage config; inbound baggage members are not trusted", routeID, route.RateLimit.Key)
 		}
 	}
 
@@ -584,6 +1339,46 @@ func (l *Loader) validateRateLimiting(route RouteConfig, cfg *Config) error {
 		}
 	}
 
+	// Cost-based rate limits
+	switch route.RateLimit.CostSource {
+	case "":
+		if route.RateLimit.Cost != 0 || route.RateLimit.MaxCost != 0 || len(route.RateLimit.OperationWeights) > 0 {
+			return fmt.Errorf("route %s: rate_limit.cost, max_cost and operation_weights require rate_limit.cost_source", routeID)
+		}
+	case "fixed", "request_cost":
+		// valid
+	case "graphql_complexity":
+		if !route.GraphQL.Enabled {
+			return fmt.Errorf("route %s: rate_limit.cost_source \"graphql_complexity\" requires graphql.enabled", routeID)
+		}
+	case "openapi_weight":
+		if route.OpenAPI.SpecFile == "" && route.OpenAPI.SpecID == "" {
+			return fmt.Errorf("route %s: rate_limit.cost_source \"openapi_weight\" requires openapi.spec_file or openapi.spec_id", routeID)
+		}
+	default:
+		return fmt.Errorf("route %s: invalid rate_limit.cost_source %q (must be \"fixed\", \"request_cost\", \"graphql_complexity\", or \"openapi_weight\")", routeID, route.RateLimit.CostSource)
+	}
+	if route.RateLimit.Cost < 0 {
+		return fmt.Errorf("route %s: rate_limit.cost must be >= 0", routeID)
+	}
+	if route.RateLimit.Cost > 0 && route.RateLimit.CostSource != "fixed" {
+		return fmt.Errorf("route %s: rate_limit.cost only applies to cost_source \"fixed\"", routeID)
+	}
+	if route.RateLimit.MaxCost < 0 {
+		return fmt.Errorf("route %s: rate_limit.max_cost must be >= 0", routeID)
+	}
+	if len(route.RateLimit.OperationWeights) > 0 && route.RateLimit.CostSource != "openapi_weight" {
+		return fmt.Errorf("route %s: rate_limit.operation_weights only applies to cost_source \"openapi_weight\"", routeID)
+	}
+	for op, weight := range route.RateLimit.OperationWeights {
+		if weight <= 0 {
+			return fmt.Errorf("route %s: rate_limit.operation_weights[%s] must be > 0", routeID, op)
+		}
+	}
+	if route.RateLimit.LegacyHeaders && !route.RateLimit.HeadersEnabled() {
+		return fmt.Errorf("route %s: rate_limit.legacy_headers requires rate_limit.headers", routeID)
+	}
+
 	return nil
 }
 
@@ -674,10 +1469,56 @@ func (l *Loader) validateTrafficControls(route RouteConfig, cfg *Config) error {
 	return nil
 }
 
-func (l *Loader) validateMirrorAndCORS(route RouteConfig, _ *Config) error {
+// validateMirrorSink validates a mirror that records to files.
+func validateMirrorSink(routeID string, m MirrorConfig) error {
+	sink := m.Sink
+	if len(m.Backends) > 0 || m.Upstream != "" || m.ShadowRoute != "" {
+		return fmt.Errorf("route %s: mirror: sink is mutually exclusive with backends, upstream and shadow_route", routeID)
+	}
+	if m.Compare.Enabled {
+		return fmt.Errorf("route %s: mirror: sink cannot be combined with compare", routeID)
+	}
+	if sink.Directory == "" {
+		return fmt.Errorf("route %s: mirror sink.directory is required", routeID)
+	}
+	if sink.MaxFileSize < 0 || sink.MaxFileAge < 0 || sink.MaxFiles < 0 || sink.MaxBodySize < 0 ||
+		sink.BufferSize < 0 || sink.BatchSize < 0 || sink.FlushInterval < 0 {
+		return fmt.Errorf("route %s: mirror sink sizes, counts and durations must be >= 0", routeID)
+	}
+	for i, h := range sink.RedactHeaders {
+		if h == "" {
+			return fmt.Errorf("route %s: mirror sink.redact_headers[%d] must be non-empty", routeID, i)
+		}
+	}
+	for i, f := range sink.RedactFields {
+		if f == "" {
+			return fmt.Errorf("route %s: mirror sink.redact_fields[%d] must be non-empty", routeID, i)
+		}
+	}
+	return nil
+}
+
+func (l *Loader) validateMirrorAndCORS(route RouteConfig, cfg *Config) error {
 	routeID := route.ID
 
 	// Mirror
+	if route.Mirror.Enabled && route.Mirror.ShadowRoute != "" {
+		shadow := route.Mirror.ShadowRoute
+		if len(route.Mirror.Backends) > 0 || route.Mirror.Upstream != "" {
+			return fmt.Errorf("route %s: mirror: shadow_route is mutually exclusive with backends and upstream", routeID)
+		}
+		if shadow == routeID {
+			return fmt.Errorf("route %s: mirror: shadow_route cannot reference the route itself", routeID)
+		}
+		if !slices.ContainsFunc(cfg.Routes, func(r RouteConfig) bool { return r.ID == shadow }) {
+			return fmt.Errorf("route %s: mirror: shadow_route references unknown route %q", routeID, shadow)
+		}
+	}
+	if route.Mirror.Enabled && route.Mirror.Sink.Enabled {
+		if err := validateMirrorSink(routeID, route.Mirror); err != nil {
+			return err
+		}
+	}
 	if route.Mirror.Enabled && route.Mirror.Conditions.PathRegex != "" {
 		if _, err := regexp.Compile(route.Mirror.Conditions.PathRegex); err != nil {
 			return fmt.Errorf("route %s: mirror conditions path_regex is invalid: %w", routeID, err)
@@ -702,6 +1543,11 @@ func (l *Loader) validateMirrorAndCORS(route RouteConfig, _ *Config) error {
 			return fmt.Errorf("route %s: mirror compare.ignore_json_fields[%d] must be non-empty", routeID, i)
 		}
 	}
+	if route.Mirror.Enabled {
+		if err := validateCopyRequestPolicy(fmt.Sprintf("route %s: mirror", routeID), route.Mirror.CopyRequestPolicy); err != nil {
+			return err
+		}
+	}
 
 	// CORS regex
 	for _, pattern := range route.CORS.AllowOriginPatterns {
@@ -737,6 +1583,9 @@ func (l *Loader) validateResilienceFeatures(route RouteConfig, cfg *Config) erro
 		if route.RetryPolicy.Budget.Window < 0 {
 			return fmt.Errorf("route %s: retry_policy budget window must be > 0", routeID)
 		}
+		if route.RetryPolicy.Budget.Mode == "distributed" {
+			return fmt.Errorf("route %s: retry_policy budget mode distributed is only supported for retry_budgets pools", routeID)
+		}
 	}
 	if route.RetryPolicy.BudgetPool != "" {
 		if route.RetryPolicy.Budget.Ratio > 0 {
@@ -788,6 +1637,23 @@ func (l *Loader) validateResilienceFeatures(route RouteConfig, cfg *Config) erro
 		if route.Cache.Mode == "distributed" && cfg.Redis.Address == "" {
 			return fmt.Errorf("route %s: distributed cache requires redis.address to be configured", routeID)
 		}
+		for status, ttl := range route.Cache.StatusTTLs {
+			if !validCacheStatusKey(status) {
+				return fmt.Errorf("route %s: cache status_ttls key %q must be a status code (100-599) or class (1xx-5xx)", routeID, status)
+			}
+			if ttl < 0 {
+				return fmt.Errorf("route %s: cache status_ttls[%s] must be >= 0", routeID, status)
+			}
+		}
+		kn := route.Cache.KeyNormalization
+		if len(kn.IgnoreQueryParams) > 0 && len(kn.IncludeQueryParams) > 0 {
+			return fmt.Errorf("route %s: cache key_normalization ignore_query_params and include_query_params are mutually exclusive", routeID)
+		}
+		for _, p := range append(slices.Clone(kn.IgnoreQueryParams), kn.IncludeQueryParams...) {
+			if _, err := path.Match(p, ""); p == "" || err != nil {
+				return fmt.Errorf("route %s: cache key_normalization query param pattern %q is invalid", routeID, p)
+			}
+		}
 	}
 
 	// Coalesce
@@ -896,6 +1762,31 @@ func (l *Loader) validateResilienceFeatures(route RouteConfig, cfg *Config) erro
 		if route.ABTest.ExperimentName == "" {
 			return fmt.Errorf("route %s: ab_test.experiment_name is required when enabled", routeID)
 		}
+		if as := route.ABTest.Assignment; as.Enabled {
+			if !validABTestKey(as.Key) {
+				return fmt.Errorf("route %s: invalid ab_test.assignment.key %q (must be \"ip\", \"client_id\", \"header:<name>\", \"cookie:<name>\", or \"jwt_claim:<name>\")", routeID, as.Key)
+			}
+			if as.Store != "" && as.Store != "local" && as.Store != "redis" {
+				return fmt.Errorf("route %s: ab_test.assignment.store must be \"local\" or \"redis\"", routeID)
+			}
+			if as.Store == "redis" && cfg.Redis.Address == "" {
+				return fmt.Errorf("route %s: ab_test.assignment.store \"redis\" requires redis.address to be configured", routeID)
+			}
+			if as.MaxEntries < 0 {
+				return fmt.Errorf("route %s: ab_test.assignment.max_entries must be >= 0", routeID)
+			}
+			if as.TTL < 0 {
+				return fmt.Errorf("route %s: ab_test.assignment.ttl must be >= 0", routeID)
+			}
+		}
+		if ex := route.ABTest.Exposure; ex.Enabled {
+			if !route.ABTest.Assignment.Enabled {
+				return fmt.Errorf("route %s: ab_test.exposure requires ab_test.assignment to be enabled", routeID)
+			}
+			if ex.SampleRate < 0 || ex.SampleRate > 1.0 {
+				return fmt.Errorf("route %s: ab_test.exposure.sample_rate must be 0.0-1.0", routeID)
+			}
+		}
 	}
 
 	return nil
@@ -909,6 +1800,14 @@ func (l *Loader) validateNetworkFeatures(route RouteConfig, _ *Config) error {
 		if route.WAF.Mode != "" && route.WAF.Mode != "block" && route.WAF.Mode != "detect" {
 			return fmt.Errorf("route %s: WAF mode must be 'block' or 'detect'", routeID)
 		}
+		if route.WAF.ReloadInterval < 0 {
+			return fmt.Errorf("route %s: WAF reload_interval must be >= 0", routeID)
+		}
+		for i, f := range route.WAF.ShadowRuleFiles {
+			if strings.TrimSpace(f) == "" {
+				return fmt.Errorf("route %s: WAF shadow_rule_files[%d] must not be empty", routeID, i)
+			}
+		}
 	}
 
 	// GraphQL
@@ -932,6 +1831,35 @@ func (l *Loader) validateNetworkFeatures(route RouteConfig, _ *Config) error {
 			return fmt.Errorf("route %s: graphql persisted_queries.max_size must be >= 0", routeID)
 		}
 	}
+	if route.GraphQL.Allowlist.Enabled {
+		if !route.GraphQL.Enabled {
+			return fmt.Errorf("route %s: graphql.allowlist.enabled requires graphql.enabled", routeID)
+		}
+		if route.GraphQL.Allowlist.File == "" && len(route.GraphQL.Allowlist.Queries) == 0 {
+			return fmt.Errorf("route %s: graphql.allowlist requires file or queries", routeID)
+		}
+		if f := route.GraphQL.Allowlist.File; f != "" {
+			if _, err := os.Stat(f); err != nil {
+				return fmt.Errorf("route %s: graphql.allowlist.file %q: %w", routeID, f, err)
+			}
+		}
+		for i, q := range route.GraphQL.Allowlist.Queries {
+			if !isSHA256Hex(q.Hash) {
+				return fmt.Errorf("route %s: graphql.allowlist.queries[%d].hash must be a hex SHA-256 digest", routeID, i)
+			}
+		}
+	}
+	if route.GraphQL.CostRateLimit.Enabled {
+		if !route.GraphQL.Enabled {
+			return fmt.Errorf("route %s: graphql.complexity_rate_limit.enabled requires graphql.enabled", routeID)
+		}
+		if route.GraphQL.CostRateLimit.Budget <= 0 {
+			return fmt.Errorf("route %s: graphql.complexity_rate_limit.budget must be > 0", routeID)
+		}
+		if route.GraphQL.CostRateLimit.Period < 0 {
+			return fmt.Errorf("route %s: graphql.complexity_rate_limit.period must be >= 0", routeID)
+		}
+	}
 	if route.GraphQL.PersistedQueries.Enabled && !route.GraphQL.Enabled {
 		return fmt.Errorf("route %s: graphql.persisted_queries.enabled requires graphql.enabled", routeID)
 	}
@@ -957,6 +1885,35 @@ func (l *Loader) validateNetworkFeatures(route RouteConfig, _ *Config) error {
 		if route.WebSocket.WriteBufferSize != 0 && route.WebSocket.WriteBufferSize < 1 {
 			return fmt.Errorf("route %s: websocket write_buffer_size must be > 0", routeID)
 		}
+		if route.WebSocket.MaxMessageSize < 0 {
+			return fmt.Errorf("route %s: websocket max_message_size must be >= 0", routeID)
+		}
+		if route.WebSocket.MaxConnections < 0 {
+			return fmt.Errorf("route %s: websocket max_connections must be >= 0", routeID)
+		}
+		seen := make(map[string]bool, len(route.WebSocket.Subprotocols))
+		for _, sp := range route.WebSocket.Subprotocols {
+			if sp == "" || strings.ContainsAny(sp, ", \t") {
+				return fmt.Errorf("route %s: websocket subprotocol %q must be a non-empty token", routeID, sp)
+			}
+			if seen[sp] {
+				return fmt.Errorf("route %s: websocket subprotocol %q is listed twice", routeID, sp)
+			}
+			seen[sp] = true
+		}
+		if fmv := route.WebSocket.FirstMessageValidation; fmv.Enabled {
+			if (fmv.Schema == "") == (fmv.SchemaFile == "") {
+				return fmt.Errorf("route %s: websocket first_message_validation requires exactly one of schema or schema_file", routeID)
+			}
+			if fmv.Messages < 0 {
+				return fmt.Errorf("route %s: websocket first_message_validation messages must be >= 0", routeID)
+			}
+			switch fmv.Action {
+			case "", "close", "log":
+			default:
+				return fmt.Errorf("route %s: websocket first_message_validation action must be close or log", routeID)
+			}
+		}
 	}
 
 	// Load balancer
@@ -966,9 +1923,13 @@ func (l *Loader) validateNetworkFeatures(route RouteConfig, _ *Config) error {
 			"least_conn":          true,
 			"consistent_hash":     true,
 			"least_response_time": true,
+			"ewma":                true,
 		}
 		if !validLBs[route.LoadBalancer] {
-			return fmt.Errorf("route %s: load_balancer must be round_robin, least_conn, consistent_hash, or least_response_time", routeID)
+			return fmt.Errorf("route %s: load_balancer must be round_robin, least_conn, consistent_hash, least_response_time, or ewma", routeID)
+		}
+		if route.EWMA.DecayTime < 0 || route.EWMA.NoDataPenalty < 0 {
+			return fmt.Errorf("route %s: ewma.decay_time and ewma.no_data_penalty must be >= 0", routeID)
 		}
 		if route.LoadBalancer == "consistent_hash" {
 			validKeys := map[string]bool{"header": true, "cookie": true, "path": true, "ip": true}
@@ -1013,6 +1974,16 @@ func (l *Loader) validateNetworkFeatures(route RouteConfig, _ *Config) error {
 					return fmt.Errorf("route %s: protocol grpc tls enabled but ca_file not provided", routeID)
 				}
 			}
+			cs := route.Protocol.GRPC.ClientStream
+			if cs.MaxLineSize < 0 {
+				return fmt.Errorf("route %s: protocol grpc client_stream.max_line_size must be >= 0", routeID)
+			}
+			if cs.MaxMessages < 0 {
+				return fmt.Errorf("route %s: protocol grpc client_stream.max_messages must be >= 0", routeID)
+			}
+			if p := cs.OnInvalidLine; p != "" && p != "reject" && p != "skip" {
+				return fmt.Errorf("route %s: protocol grpc client_stream.on_invalid_line must be 'reject' or 'skip', got %q", routeID, p)
+			}
 			if err := l.validateGRPCMappings(routeID, route.Protocol.GRPC); err != nil {
 				return err
 			}
@@ -1025,7 +1996,7 @@ func (l *Loader) validateNetworkFeatures(route RouteConfig, _ *Config) error {
 			if !hasIDL && !hasMethods {
 				return fmt.Errorf("route %s: thrift.idl_file or thrift.methods is required for http_to_thrift", routeID)
 			}
-			if route.Protocol.Thrift.Service == "" {
+			if route.Protocol.Thrift.Service == "" && len(route.Protocol.Thrift.Services) == 0 {
 				return fmt.Errorf("route %s: thrift.service is required for http_to_thrift", routeID)
 			}
 			if p := route.Protocol.Thrift.Protocol; p != "" && p != "binary" && p != "compact" {
@@ -1039,9 +2010,15 @@ func (l *Loader) validateNetworkFeatures(route RouteConfig, _ *Config) error {
 					return fmt.Errorf("route %s: thrift tls enabled but ca_file not provided", routeID)
 				}
 			}
+			if route.Protocol.Thrift.IDLReloadInterval < 0 {
+				return fmt.Errorf("route %s: thrift.idl_reload_interval must be >= 0", routeID)
+			}
 			if err := l.validateThriftMappings(routeID, route.Protocol.Thrift); err != nil {
 				return err
 			}
+			if err := l.validateThriftServices(routeID, route.Protocol.Thrift); err != nil {
+				return err
+			}
 			if hasMethods {
 				if err := l.validateThriftInlineSchema(routeID, route.Protocol.Thrift); err != nil {
 					return err
@@ -1072,23 +2049,9 @@ func (l *Loader) validateNetworkFeatures(route RouteConfig, _ *Config) error {
 	}
 
 	// External auth
-	if route.ExtAuth.Enabled {
-		if route.ExtAuth.URL == "" {
-			return fmt.Errorf("route %s: ext_auth.url is required when enabled", routeID)
-		}
-		if !strings.HasPrefix(route.ExtAuth.URL, "http://") &&
-			!strings.HasPrefix(route.ExtAuth.URL, "https://") &&
-			!strings.HasPrefix(route.ExtAuth.URL, "grpc://") {
-			return fmt.Errorf("route %s: ext_auth.url must start with http://, https://, or grpc://", routeID)
-		}
-		if route.ExtAuth.Timeout < 0 {
-			return fmt.Errorf("route %s: ext_auth.timeout must be >= 0", routeID)
-		}
-		if route.ExtAuth.CacheTTL < 0 {
-			return fmt.Errorf("route %s: ext_auth.cache_ttl must be >= 0", routeID)
-		}
-		if route.ExtAuth.TLS.Enabled && strings.HasPrefix(route.ExtAuth.URL, "http://") {
-			return fmt.Errorf("route %s: ext_auth.tls cannot be used with http:// URL", routeID)
+	if route.ExtAuth.Enabled && route.ExtAuth.Ref == "" {
+		if err := validateExtAuthConfig(fmt.Sprintf("route %s: ext_auth", routeID), route.ExtAuth); err != nil {
+			return err
 		}
 	}
 
@@ -1114,31 +2077,44 @@ func (l *Loader) validateTransformsAndValidation(route RouteConfig, cfg *Config)
 	}
 
 	// Access log
-	if err := l.validateAccessLog(routeID, route.AccessLog); err != nil {
+	if err := l.validateAccessLog(routeID, route.AccessLog, cfg.Logging.Format); err != nil {
 		return err
 	}
 
 	// OpenAPI
-	if route.OpenAPI.SpecFile != "" && route.OpenAPI.SpecID != "" {
-		return fmt.Errorf("route %s: openapi spec_file and spec_id are mutually exclusive", routeID)
-	}
 	if route.OpenAPI.SpecID != "" {
-		found := false
-		for _, s := range cfg.OpenAPI.Specs {
-			if s.ID == route.OpenAPI.SpecID {
-				found = true
+		var spec *OpenAPISpecConfig
+		for i := range cfg.OpenAPI.Specs {
+			if cfg.OpenAPI.Specs[i].ID == route.OpenAPI.SpecID {
+				spec = &cfg.OpenAPI.Specs[i]
 				break
 			}
 		}
-		if !found {
+		if spec == nil {
 			return fmt.Errorf("route %s: openapi.spec_id %q not found in openapi.specs", routeID, route.OpenAPI.SpecID)
 		}
+		// Routes generated from a spec carry both, pointing at the same file.
+		if route.OpenAPI.SpecFile != "" && route.OpenAPI.SpecFile != spec.File {
+			return fmt.Errorf("route %s: openapi spec_file and spec_id are mutually exclusive", routeID)
+		}
+	}
+	if err := validateSchemaDrift(route.OpenAPI.SchemaDrift); err != nil {
+		return fmt.Errorf("route %s: %w", routeID, err)
+	}
+	if route.OpenAPI.SchemaDrift.Enabled && route.OpenAPI.SpecFile == "" && route.OpenAPI.SpecID == "" {
+		return fmt.Errorf("route %s: openapi.schema_drift requires spec_file or spec_id", routeID)
 	}
 
 	// Response validation
 	if route.Validation.ResponseSchema != "" && route.Validation.ResponseSchemaFile != "" {
 		return fmt.Errorf("route %s: validation response_schema and response_schema_file are mutually exclusive", routeID)
 	}
+	if route.Validation.XSD != "" && route.Validation.XSDFile != "" {
+		return fmt.Errorf("route %s: validation xsd and xsd_file are mutually exclusive", routeID)
+	}
+	if route.Validation.ResponseXSD != "" && route.Validation.ResponseXSDFile != "" {
+		return fmt.Errorf("route %s: validation response_xsd and response_xsd_file are mutually exclusive", routeID)
+	}
 
 	// Rewrite
 	if err := l.validateRewriteConfig(routeID, route.Rewrite, route.PathPrefix, route.StripPrefix); err != nil {
@@ -1220,8 +2196,10 @@ func (l *Loader) validateHealthCheckRefs(route RouteConfig, cfg *Config) error {
 	}
 	for _, split := range route.TrafficSplit {
 		if split.Upstream != "" {
-			if _, ok := cfg.Upstreams[split.Upstream]; !ok {
+			if us, ok := cfg.Upstreams[split.Upstream]; !ok {
 				return fmt.Errorf("route %s: traffic_split %s: references unknown upstream %q", routeID, split.Name, split.Upstream)
+			} else if us.DNSDiscovery.Enabled {
+				return fmt.Errorf("route %s: traffic_split %s: upstream %q with dns_discovery can only be referenced by route upstream", routeID, split.Name, split.Upstream)
 			}
 			if len(split.Backends) > 0 {
 				return fmt.Errorf("route %s: traffic_split %s: upstream and backends are mutually exclusive", routeID, split.Name)
@@ -1231,8 +2209,10 @@ func (l *Loader) validateHealthCheckRefs(route RouteConfig, cfg *Config) error {
 	if route.Versioning.Enabled {
 		for ver, vcfg := range route.Versioning.Versions {
 			if vcfg.Upstream != "" {
-				if _, ok := cfg.Upstreams[vcfg.Upstream]; !ok {
+				if us, ok := cfg.Upstreams[vcfg.Upstream]; !ok {
 					return fmt.Errorf("route %s: versioning.versions[%s]: references unknown upstream %q", routeID, ver, vcfg.Upstream)
+				} else if us.DNSDiscovery.Enabled {
+					return fmt.Errorf("route %s: versioning.versions[%s]: upstream %q with dns_discovery can only be referenced by route upstream", routeID, ver, vcfg.Upstream)
 				}
 				if len(vcfg.Backends) > 0 {
 					return fmt.Errorf("route %s: versioning.versions[%s]: upstream and backends are mutually exclusive", routeID, ver)
@@ -1241,8 +2221,10 @@ func (l *Loader) validateHealthCheckRefs(route RouteConfig, cfg *Config) error {
 		}
 	}
 	if route.Mirror.Enabled && route.Mirror.Upstream != "" {
-		if _, ok := cfg.Upstreams[route.Mirror.Upstream]; !ok {
+		if us, ok := cfg.Upstreams[route.Mirror.Upstream]; !ok {
 			return fmt.Errorf("route %s: mirror: references unknown upstream %q", routeID, route.Mirror.Upstream)
+		} else if us.DNSDiscovery.Enabled {
+			return fmt.Errorf("route %s: mirror: upstream %q with dns_discovery can only be referenced by route upstream", routeID, route.Mirror.Upstream)
 		}
 		if len(route.Mirror.Backends) > 0 {
 			return fmt.Errorf("route %s: mirror: upstream and backends are mutually exclusive", routeID)
@@ -1296,6 +2278,9 @@ func (l *Loader) validateDelegatedSecurity(route RouteConfig, cfg *Config) error
 	if err := l.validateErrorPages(scope, route.ErrorPages); err != nil {
 		return err
 	}
+	if err := validateErrorsConfig(scope, route.Errors); err != nil {
+		return err
+	}
 	if err := l.validateNonceConfig(scope, route.Nonce, cfg.Redis.Address); err != nil {
 		return err
 	}
@@ -1327,64 +2312,174 @@ func (l *Loader) validateDelegatedSecurity(route RouteConfig, cfg *Config) error
 	if err := l.validateRequestDedupConfig(scope, route.RequestDedup, cfg.Redis.Address); err != nil {
 		return err
 	}
+	if err := l.validateContentDedupConfig(scope, route.ContentDedup, cfg.Redis.Address); err != nil {
+		return err
+	}
 	if err := l.validateIPBlocklistConfig(scope, route.IPBlocklist); err != nil {
 		return err
 	}
-	if err := l.validateClientMTLSConfig(scope, route.ClientMTLS); err != nil {
-		return err
+	if err := l.validateClientMTLSConfig(scope, route.ClientMTLS); err != nil {
+		return err
+	}
+	if err := l.validateTokenExchangeConfig(scope, route); err != nil {
+		return err
+	}
+	if err := l.validateOIDCConfig(scope, route, cfg.Redis.Address); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateOIDCConfig validates a route's OIDC relying party config.
+func (l *Loader) validateOIDCConfig(scope string, route RouteConfig, redisAddr string) error {
+	cfg := route.OIDC
+	if !cfg.Enabled {
+		if slices.Contains(route.Auth.Methods, "oidc") {
+			return fmt.Errorf("%s: auth method \"oidc\" requires oidc.enabled", scope)
+		}
+		return nil
+	}
+	if !route.Auth.Required || !slices.Contains(route.Auth.Methods, "oidc") {
+		return fmt.Errorf("%s: oidc requires auth.required and \"oidc\" in auth.methods", scope)
+	}
+	if cfg.Issuer == "" {
+		return fmt.Errorf("%s: oidc.issuer is required", scope)
+	}
+	if u, err := url.Parse(cfg.Issuer); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("%s: oidc.issuer must be an http(s) URL", scope)
+	}
+	if cfg.ClientID == "" {
+		return fmt.Errorf("%s: oidc.client_id is required", scope)
+	}
+	if cfg.BaseURL != "" {
+		if u, err := url.Parse(cfg.BaseURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" ||
+			strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("%s: oidc.base_url must be an http(s) origin such as https://app.example.com", scope)
+		}
+	}
+	if cfg.Scopes != nil && !slices.Contains(cfg.Scopes, "openid") {
+		return fmt.Errorf("%s: oidc.scopes must include \"openid\"", scope)
+	}
+	for _, p := range []struct{ name, path string }{
+		{"callback_path", cfg.CallbackPath},
+		{"logout_path", cfg.LogoutPath},
+		{"post_logout_redirect", cfg.PostLogoutRedirect},
+	} {
+		if p.path != "" && (!strings.HasPrefix(p.path, "/") || strings.HasPrefix(p.path, "//")) {
+			return fmt.Errorf("%s: oidc.%s must be a path starting with /", scope, p.name)
+		}
+	}
+	if cfg.CallbackPath != "" && cfg.CallbackPath == cfg.LogoutPath {
+		return fmt.Errorf("%s: oidc.callback_path and logout_path must differ", scope)
+	}
+	sess := cfg.Session
+	if len(sess.Secret) < 32 {
+		return fmt.Errorf("%s: oidc.session.secret must be at least 32 bytes", scope)
+	}
+	if sess.MaxAge < 0 {
+		return fmt.Errorf("%s: oidc.session.max_age must be >= 0", scope)
+	}
+	switch sess.Store {
+	case "", "cookie":
+	case "redis":
+		if redisAddr == "" {
+			return fmt.Errorf("%s: oidc.session.store \"redis\" requires redis.address to be configured", scope)
+		}
+	default:
+		return fmt.Errorf("%s: oidc.session.store must be \"cookie\" or \"redis\"", scope)
+	}
+	switch strings.ToLower(sess.SameSite) {
+	case "", "lax", "strict":
+	case "none":
+		if sess.Secure != nil && !*sess.Secure {
+			return fmt.Errorf("%s: oidc.session.same_site \"none\" requires secure cookies", scope)
+		}
+	default:
+		return fmt.Errorf("%s: oidc.session.same_site must be \"lax\", \"strict\" or \"none\"", scope)
+	}
+	return nil
+}
+
+// validateJWKSEndpoints checks the jwks_url, jwks_urls and
+// jwks_fetch_timeout fields of scope.
+func validateJWKSEndpoints(scope, single string, list []string, timeout time.Duration) error {
+	if single != "" && len(list) > 0 {
+		return fmt.Errorf("%s: jwks_url and jwks_urls are mutually exclusive", scope)
+	}
+	seen := make(map[string]bool, len(list))
+	for i, u := range list {
+		if u == "" {
+			return fmt.Errorf("%s: jwks_urls[%d] is empty", scope, i)
+		}
+		if seen[u] {
+			return fmt.Errorf("%s: jwks_urls: duplicate URL %q", scope, u)
+		}
+		seen[u] = true
 	}
-	if err := l.validateTokenExchangeConfig(scope, route); err != nil {
-		return err
+	if timeout < 0 {
+		return fmt.Errorf("%s: jwks_fetch_timeout must be >= 0", scope)
 	}
 	return nil
 }
 
 func (l *Loader) validateTokenExchangeConfig(scope string, route RouteConfig) error {
 	cfg := route.TokenExchange
-	if !cfg.Enabled {
+	if !cfg.IsEnabled() {
 		return nil
 	}
 	if !route.Auth.Required {
 		return fmt.Errorf("%s: token_exchange requires auth.required to be true", scope)
 	}
+	if cfg.Ref != "" {
+		return nil // checked by validateSharedAuthRefs
+	}
+	return validateTokenExchangeDef(scope+": token_exchange", cfg)
+}
+
+// validateTokenExchangeDef checks an inline or shared token_exchange
+// definition. prefix names the config path in error messages.
+func validateTokenExchangeDef(prefix string, cfg TokenExchangeConfig) error {
+	if err := validateJWKSEndpoints(prefix, cfg.JWKSURL, cfg.JWKSURLs, cfg.JWKSFetchTimeout); err != nil {
+		return err
+	}
 	switch cfg.ValidationMode {
 	case "jwt":
-		if cfg.JWKSURL == "" {
-			return fmt.Errorf("%s: token_exchange.jwks_url is required for jwt validation mode", scope)
+		if len(cfg.JWKSEndpoints()) == 0 {
+			return fmt.Errorf("%s.jwks_url is required for jwt validation mode", prefix)
 		}
 		if len(cfg.TrustedIssuers) == 0 {
-			return fmt.Errorf("%s: token_exchange.trusted_issuers is required for jwt validation mode", scope)
+			return fmt.Errorf("%s.trusted_issuers is required for jwt validation mode", prefix)
 		}
 	case "introspection":
 		if cfg.IntrospectionURL == "" {
-			return fmt.Errorf("%s: token_exchange.introspection_url is required for introspection mode", scope)
+			return fmt.Errorf("%s.introspection_url is required for introspection mode", prefix)
 		}
 		if cfg.ClientID == "" {
-			return fmt.Errorf("%s: token_exchange.client_id is required for introspection mode", scope)
+			return fmt.Errorf("%s.client_id is required for introspection mode", prefix)
 		}
 		if cfg.ClientSecret == "" {
-			return fmt.Errorf("%s: token_exchange.client_secret is required for introspection mode", scope)
+			return fmt.Errorf("%s.client_secret is required for introspection mode", prefix)
 		}
 	default:
-		return fmt.Errorf("%s: token_exchange.validation_mode must be \"jwt\" or \"introspection\"", scope)
+		return fmt.Errorf("%s.validation_mode must be \"jwt\" or \"introspection\"", prefix)
 	}
 	if cfg.Issuer == "" {
-		return fmt.Errorf("%s: token_exchange.issuer is required", scope)
+		return fmt.Errorf("%s.issuer is required", prefix)
 	}
 	switch cfg.SigningAlgorithm {
 	case "RS256", "RS512":
 		if cfg.SigningKey == "" && cfg.SigningKeyFile == "" {
-			return fmt.Errorf("%s: token_exchange.signing_key or signing_key_file required for %s", scope, cfg.SigningAlgorithm)
+			return fmt.Errorf("%s.signing_key or signing_key_file required for %s", prefix, cfg.SigningAlgorithm)
 		}
 	case "HS256", "HS512":
 		if cfg.SigningSecret == "" {
-			return fmt.Errorf("%s: token_exchange.signing_secret required for %s", scope, cfg.SigningAlgorithm)
+			return fmt.Errorf("%s.signing_secret required for %s", prefix, cfg.SigningAlgorithm)
 		}
 	default:
-		return fmt.Errorf("%s: token_exchange.signing_algorithm must be RS256, RS512, HS256, or HS512", scope)
+		return fmt.Errorf("%s.signing_algorithm must be RS256, RS512, HS256, or HS512", prefix)
 	}
 	if cfg.TokenLifetime <= 0 {
-		return fmt.Errorf("%s: token_exchange.token_lifetime must be > 0", scope)
+		return fmt.Errorf("%s.token_lifetime must be > 0", prefix)
 	}
 	return nil
 }
@@ -1400,18 +2495,48 @@ func (l *Loader) validateDelegatedMiddleware(route RouteConfig, cfg *Config) err
 	if err := l.validateResponseLimitConfig(scope, route.ResponseLimit); err != nil {
 		return err
 	}
+	if err := l.validateResponseBufferingConfig(scope, route.ResponseBuffering); err != nil {
+		return err
+	}
 	if err := l.validateSecurityHeadersConfig(scope, route.SecurityHeaders); err != nil {
 		return err
 	}
 	if err := l.validateMaintenanceConfig(scope, route.Maintenance); err != nil {
 		return err
 	}
+	if route.SyntheticMonitoring.Enabled {
+		merged := MergeNonZero(cfg.SyntheticMonitoring, route.SyntheticMonitoring)
+		if err := l.validateSyntheticMonitoringConfig(scope, merged); err != nil {
+			return err
+		}
+	}
+	if err := l.validateDegradedModeConfig(scope, route.DegradedMode); err != nil {
+		return err
+	}
+	if err := l.validateRoutePeerFailover(scope, route.PeerFailover, cfg.PeerFailover); err != nil {
+		return err
+	}
+	if err := l.validateBreakGlassConfig(scope, route.BreakGlass); err != nil {
+		return err
+	}
+	if err := l.validateMultipartFieldsConfig(scope, route.MultipartFields); err != nil {
+		return err
+	}
+	if err := l.validateAuthRequirements(scope, route.Auth); err != nil {
+		return err
+	}
+	if route.MultipartFields.Enabled && route.Passthrough {
+		return fmt.Errorf("%s: multipart_fields is incompatible with passthrough", scope)
+	}
 	if err := l.validatePIIRedactionConfig(scope, route.PIIRedaction); err != nil {
 		return err
 	}
 	if route.PIIRedaction.Enabled && route.Passthrough {
 		return fmt.Errorf("%s: pii_redaction is mutually exclusive with passthrough", scope)
 	}
+	if err := l.validateResponseFieldPolicyConfig(scope, route.ResponseFieldPolicy); err != nil {
+		return err
+	}
 	if err := l.validateFieldEncryptionConfig(scope, route.FieldEncryption); err != nil {
 		return err
 	}
@@ -1610,6 +2735,27 @@ func (l *Loader) validateAICrawlConfig(scope string, cfg AICrawlConfig) error {
 	return nil
 }
 
+// baggageTagKeys returns the baggage keys of the tags in the route's
+// effective baggage config: the members the gateway sets itself.
+func baggageTagKeys(route RouteConfig, cfg *Config) map[string]bool {
+	var tags []BaggageTagDef
+	switch {
+	case route.Baggage.Enabled && len(route.Baggage.Tags) > 0:
+		tags = route.Baggage.Tags
+	case route.Baggage.Enabled || cfg.Baggage.Enabled:
+		tags = cfg.Baggage.Tags
+	}
+	keys := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if tag.BaggageKey != "" {
+			keys[tag.BaggageKey] = true
+		} else {
+			keys[tag.Name] = true
+		}
+	}
+	return keys
+}
+
 // validateBaggageConfig validates baggage propagation config.
 func (l *Loader) validateBaggageConfig(scope string, cfg BaggageConfig, globalCfg *Config) error {
 	// propagate_trace requires tracing to be enabled
@@ -1624,6 +2770,17 @@ func (l *Loader) validateBaggageConfig(scope string, cfg BaggageConfig, globalCf
 	if cfg.W3CBaggage && len(cfg.Tags) == 0 {
 		return fmt.Errorf("%s: baggage.w3c_baggage requires at least one tag", scope)
 	}
+	if cfg.MaxMembers < 0 {
+		return fmt.Errorf("%s: baggage.max_members must be >= 0", scope)
+	}
+	if cfg.MaxBytes < 0 {
+		return fmt.Errorf("%s: baggage.max_bytes must be >= 0", scope)
+	}
+	switch cfg.ConflictPolicy {
+	case "", "local", "inbound":
+	default:
+		return fmt.Errorf("%s: baggage.conflict_policy must be \"local\" or \"inbound\"", scope)
+	}
 	validPrefixes := []string{"header:", "jwt_claim:", "query:", "cookie:", "static:"}
 	seenW3CKeys := map[string]bool{}
 	for i, tag := range cfg.Tags {
@@ -1667,7 +2824,20 @@ func (l *Loader) validateBaggageConfig(scope string, cfg BaggageConfig, globalCf
 }
 
 // validateAccessLog validates access log config for a given route.
-func (l *Loader) validateAccessLog(routeID string, cfg AccessLogConfig) error {
+// globalFormat is the format the route inherits when it sets none.
+func (l *Loader) validateAccessLog(routeID string, cfg AccessLogConfig, globalFormat string) error {
+	if len(cfg.Fields) > 0 {
+		format := cfg.Format
+		if format == "" {
+			format = globalFormat
+		}
+		if format != AccessLogFormatJSON {
+			return fmt.Errorf("route %s: access_log fields requires format json", routeID)
+		}
+		if err := validateAccessLogFields(cfg.Fields); err != nil {
+			return fmt.Errorf("route %s: access_log fields: %w", routeID, err)
+		}
+	}
 	if len(cfg.HeadersInclude) > 0 && len(cfg.HeadersExclude) > 0 {
 		return fmt.Errorf("route %s: access_log headers_include and headers_exclude are mutually exclusive", routeID)
 	}
@@ -1687,6 +2857,37 @@ func (l *Loader) validateAccessLog(routeID string, cfg AccessLogConfig) error {
 	if cfg.Body.Enabled && cfg.Body.MaxSize < 0 {
 		return fmt.Errorf("route %s: access_log body.max_size must be >= 0", routeID)
 	}
+	switch cfg.Level {
+	case "", "info", "warn", "error":
+	default:
+		return fmt.Errorf("route %s: access_log level must be info, warn or error", routeID)
+	}
+	if cfg.Sampling.Enabled {
+		if cfg.Sampling.Threshold <= 0 {
+			return fmt.Errorf("route %s: access_log sampling.threshold must be > 0", routeID)
+		}
+		if cfg.Sampling.Burst < 0 {
+			return fmt.Errorf("route %s: access_log sampling.burst must be >= 0", routeID)
+		}
+		if cfg.Sampling.Ratio < 0 || cfg.Sampling.Ratio > 1.0 {
+			return fmt.Errorf("route %s: access_log sampling.ratio must be between 0.0 and 1.0", routeID)
+		}
+	}
+	return nil
+}
+
+// validateAccessLogFields rejects names that are not in AccessLogFields.
+func validateAccessLogFields(fields []string) error {
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if !slices.Contains(AccessLogFields, f) {
+			return fmt.Errorf("unknown field %q (valid: %s)", f, strings.Join(AccessLogFields, ", "))
+		}
+		if seen[f] {
+			return fmt.Errorf("duplicate field %q", f)
+		}
+		seen[f] = true
+	}
 	return nil
 }
 
@@ -1717,6 +2918,27 @@ func parseStatusRange(s string) ([2]int, error) {
 
 // validateHealthCheck validates a health check configuration.
 func (l *Loader) validateHealthCheck(scope string, cfg HealthCheckConfig) error {
+	switch cfg.Type {
+	case "", HealthCheckHTTP:
+		if cfg.Send != "" || cfg.Expect != "" {
+			return fmt.Errorf("%s: health_check.send and expect require type \"tcp\"", scope)
+		}
+	case HealthCheckTCP, HealthCheckGRPC:
+		if cfg.Path != "" || cfg.Method != "" || cfg.Body != "" || len(cfg.Headers) > 0 || len(cfg.ExpectedStatus) > 0 {
+			return fmt.Errorf("%s: health_check.path, method, body, headers and expected_status only apply to type \"http\"", scope)
+		}
+		if cfg.Type == HealthCheckGRPC && (cfg.Send != "" || cfg.Expect != "") {
+			return fmt.Errorf("%s: health_check.send and expect require type \"tcp\"", scope)
+		}
+	default:
+		return fmt.Errorf("%s: health_check.type must be http, tcp, or grpc", scope)
+	}
+	if cfg.Service != "" && cfg.Type != HealthCheckGRPC {
+		return fmt.Errorf("%s: health_check.service requires type \"grpc\"", scope)
+	}
+	if (cfg.Method == "GET" || cfg.Method == "HEAD") && cfg.Body != "" {
+		return fmt.Errorf("%s: health_check.body cannot be sent with method %s", scope, cfg.Method)
+	}
 	validMethods := map[string]bool{"GET": true, "HEAD": true, "OPTIONS": true, "POST": true}
 	if cfg.Method != "" && !validMethods[cfg.Method] {
 		return fmt.Errorf("%s: health_check.method must be GET, HEAD, OPTIONS, or POST", scope)
@@ -1790,6 +3012,29 @@ func (l *Loader) validateBodyTransform(routeID, phase string, cfg BodyTransformC
 }
 
 // validateTrafficShaping validates traffic shaping config for a given scope.
+// validateFaultTarget checks the selectors of a fault_injection target.
+func validateFaultTarget(t FaultTargetConfig, scope string) error {
+	for i, id := range t.Tenants {
+		if id == "" {
+			return fmt.Errorf("%s: fault_injection target.tenants[%d] is empty", scope, i)
+		}
+	}
+	for i, g := range t.ConsumerGroups {
+		if g == "" {
+			return fmt.Errorf("%s: fault_injection target.consumer_groups[%d] is empty", scope, i)
+		}
+	}
+	for i, m := range t.Match {
+		if err := checkClientKey(fmt.Sprintf("fault_injection target.match[%d].key", i), m.Key); err != nil {
+			return fmt.Errorf("%s: %w", scope, err)
+		}
+		if len(m.Values) == 0 {
+			return fmt.Errorf("%s: fault_injection target.match[%d].values is required", scope, i)
+		}
+	}
+	return nil
+}
+
 func (l *Loader) validateTrafficShaping(cfg TrafficShapingConfig, scope string) error {
 	if cfg.Throttle.Enabled {
 		if cfg.Throttle.Rate <= 0 {
@@ -1833,6 +3078,19 @@ func (l *Loader) validateTrafficShaping(cfg TrafficShapingConfig, scope string)
 		if cfg.FaultInjection.Abort.Percentage > 0 && (cfg.FaultInjection.Abort.StatusCode < 100 || cfg.FaultInjection.Abort.StatusCode > 599) {
 			return fmt.Errorf("%s: fault_injection abort status_code must be between 100 and 599", scope)
 		}
+		if err := validateFaultTarget(cfg.FaultInjection.Target, scope); err != nil {
+			return err
+		}
+		ramp := cfg.FaultInjection.Ramp
+		if ramp.StartPercentage < 0 || ramp.StartPercentage > 100 || ramp.EndPercentage < 0 || ramp.EndPercentage > 100 {
+			return fmt.Errorf("%s: fault_injection ramp percentages must be between 0 and 100", scope)
+		}
+		if ramp.Duration < 0 {
+			return fmt.Errorf("%s: fault_injection ramp duration must be >= 0", scope)
+		}
+		if ramp.Duration == 0 && (ramp.StartPercentage > 0 || ramp.EndPercentage > 0) {
+			return fmt.Errorf("%s: fault_injection ramp duration must be > 0 when percentages are set", scope)
+		}
 	}
 	if cfg.AdaptiveConcurrency.Enabled {
 		if cfg.AdaptiveConcurrency.MinConcurrency < 0 {
@@ -2229,32 +3487,33 @@ func (l *Loader) validateThriftMappings(routeID string, cfg ThriftTranslateConfi
 		return fmt.Errorf("route %s: cannot use both thrift.method and thrift.mappings", routeID)
 	}
 
-	if len(cfg.Mappings) == 0 {
-		return nil
-	}
+	return validateThriftMappingList(routeID, "thrift mapping", cfg.Mappings, make(map[string]bool))
+}
 
+// validateThriftMappingList validates a list of mappings. seen is shared
+// across lists so duplicates between multiplexed services are rejected.
+func validateThriftMappingList(routeID, label string, mappings []ThriftMethodMapping, seen map[string]bool) error {
 	validMethods := map[string]bool{
 		"GET": true, "POST": true, "PUT": true, "DELETE": true, "PATCH": true,
 	}
 
-	seen := make(map[string]bool)
-	for i, m := range cfg.Mappings {
+	for i, m := range mappings {
 		if m.HTTPMethod == "" {
-			return fmt.Errorf("route %s: thrift mapping %d: http_method is required", routeID, i)
+			return fmt.Errorf("route %s: %s %d: http_method is required", routeID, label, i)
 		}
 		if !validMethods[m.HTTPMethod] {
-			return fmt.Errorf("route %s: thrift mapping %d: invalid http_method: %s", routeID, i, m.HTTPMethod)
+			return fmt.Errorf("route %s: %s %d: invalid http_method: %s", routeID, label, i, m.HTTPMethod)
 		}
 		if m.HTTPPath == "" {
-			return fmt.Errorf("route %s: thrift mapping %d: http_path is required", routeID, i)
+			return fmt.Errorf("route %s: %s %d: http_path is required", routeID, label, i)
 		}
 		if m.ThriftMethod == "" {
-			return fmt.Errorf("route %s: thrift mapping %d: thrift_method is required", routeID, i)
+			return fmt.Errorf("route %s: %s %d: thrift_method is required", routeID, label, i)
 		}
 
 		key := m.HTTPMethod + " " + m.HTTPPath
 		if seen[key] {
-			return fmt.Errorf("route %s: thrift mapping %d: duplicate mapping for %s", routeID, i, key)
+			return fmt.Errorf("route %s: %s %d: duplicate mapping for %s", routeID, label, i, key)
 		}
 		seen[key] = true
 	}
@@ -2262,6 +3521,51 @@ func (l *Loader) validateThriftMappings(routeID string, cfg ThriftTranslateConfi
 	return nil
 }
 
+// validateThriftServices validates multiplexed service definitions.
+func (l *Loader) validateThriftServices(routeID string, cfg ThriftTranslateConfig) error {
+	if len(cfg.Services) == 0 {
+		return nil
+	}
+	if cfg.Service != "" {
+		return fmt.Errorf("route %s: thrift.service and thrift.services are mutually exclusive", routeID)
+	}
+	if cfg.IDLFile == "" {
+		return fmt.Errorf("route %s: thrift.services requires thrift.idl_file", routeID)
+	}
+	if cfg.Method != "" || len(cfg.Mappings) > 0 {
+		return fmt.Errorf("route %s: thrift.services cannot be combined with thrift.method or thrift.mappings", routeID)
+	}
+
+	names := make(map[string]bool)
+	wireNames := make(map[string]bool)
+	seen := make(map[string]bool)
+	for i, svc := range cfg.Services {
+		if svc.Name == "" {
+			return fmt.Errorf("route %s: thrift.services[%d]: name is required", routeID, i)
+		}
+		if names[svc.Name] {
+			return fmt.Errorf("route %s: thrift.services[%d]: duplicate service %s", routeID, i, svc.Name)
+		}
+		names[svc.Name] = true
+		wire := svc.MultiplexedName
+		if wire == "" {
+			wire = svc.Name
+		}
+		if wireNames[wire] {
+			return fmt.Errorf("route %s: thrift.services[%d]: duplicate multiplexed_name %s", routeID, i, wire)
+		}
+		wireNames[wire] = true
+		if len(svc.Mappings) == 0 {
+			return fmt.Errorf("route %s: thrift.services[%d]: at least one mapping is required", routeID, i)
+		}
+		label := fmt.Sprintf("thrift.services[%d] mapping", i)
+		if err := validateThriftMappingList(routeID, label, svc.Mappings, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // validThriftTypes lists the valid scalar/container type strings for inline schemas.
 var validThriftTypes = map[string]bool{
 	"bool": true, "byte": true, "i16": true, "i32": true, "i64": true,
@@ -2470,6 +3774,89 @@ func (l *Loader) validateMatchConfig(routeID string, mc MatchConfig) error {
 		return fmt.Errorf("route %s: max_match_body_size must be >= 0", routeID)
 	}
 
+	return validateMatchExpression(routeID, mc)
+}
+
+// validateMatchExpression checks match.expression against the ids of the
+// header, query, cookie and body matchers.
+func validateMatchExpression(routeID string, mc MatchConfig) error {
+	ids := make(map[string]bool)
+	var order []string
+	addID := func(kind, id string) error {
+		if id == "" {
+			return nil
+		}
+		if ids[id] {
+			return fmt.Errorf("route %s: match %s id %q is used more than once", routeID, kind, id)
+		}
+		ids[id] = true
+		order = append(order, id)
+		return nil
+	}
+	for _, h := range mc.Headers {
+		if err := addID("header", h.ID); err != nil {
+			return err
+		}
+	}
+	for _, q := range mc.Query {
+		if err := addID("query", q.ID); err != nil {
+			return err
+		}
+	}
+	for _, c := range mc.Cookies {
+		if err := addID("cookie", c.ID); err != nil {
+			return err
+		}
+	}
+	for _, b := range mc.Body {
+		if err := addID("body", b.ID); err != nil {
+			return err
+		}
+	}
+
+	if mc.Expression == "" {
+		return nil
+	}
+	expr, err := matchexpr.Parse(mc.Expression)
+	if err != nil {
+		return fmt.Errorf("route %s: match.expression: %w", routeID, err)
+	}
+	used := make(map[string]bool, len(expr.Names()))
+	for _, name := range expr.Names() {
+		if !ids[name] {
+			return fmt.Errorf("route %s: match.expression references undefined matcher %q", routeID, name)
+		}
+		used[name] = true
+	}
+	for _, id := range order {
+		if !used[id] {
+			return fmt.Errorf("route %s: matcher %q is not used by match.expression", routeID, id)
+		}
+	}
+	if !expr.Satisfiable() {
+		return fmt.Errorf("route %s: match.expression %q can never match", routeID, mc.Expression)
+	}
+	return nil
+}
+
+// validateErrorsConfig validates an ErrorsConfig for a given scope.
+func validateErrorsConfig(scope string, cfg ErrorsConfig) error {
+	switch cfg.Format {
+	case "", ErrorFormatLegacy, ErrorFormatProblemJSON:
+	default:
+		return fmt.Errorf("%s: errors.format must be %q or %q", scope, ErrorFormatLegacy, ErrorFormatProblemJSON)
+	}
+	for reason, entry := range cfg.Catalog {
+		if reason == "" {
+			return fmt.Errorf("%s: errors.catalog keys must be non-empty reason codes", scope)
+		}
+		if entry.Type == "" {
+			return fmt.Errorf("%s: errors.catalog[%s].type is required", scope, reason)
+		}
+		if u, err := url.Parse(entry.Type); err != nil || u.Scheme == "" {
+			return fmt.Errorf("%s: errors.catalog[%s].type must be an absolute URI", scope, reason)
+		}
+	}
 	return nil
 }
 
@@ -2760,6 +4147,36 @@ func (l *Loader) validatePIIRedactionConfig(scope string, cfg PIIRedactionConfig
 	return nil
 }
 
+func (l *Loader) validateResponseFieldPolicyConfig(scope string, cfg ResponseFieldPolicyConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.MaxBodySize < 0 {
+		return fmt.Errorf("%s: response_field_policy.max_body_size must be >= 0", scope)
+	}
+	if len(cfg.Rules) == 0 {
+		return fmt.Errorf("%s: response_field_policy requires at least one rule", scope)
+	}
+	seen := make(map[string]bool, len(cfg.Rules))
+	for i, rule := range cfg.Rules {
+		if rule.ID != "" {
+			if seen[rule.ID] {
+				return fmt.Errorf("%s: response_field_policy.rules[%d]: duplicate id %q", scope, i, rule.ID)
+			}
+			seen[rule.ID] = true
+		}
+		if len(rule.AllowFields) == 0 && len(rule.DenyFields) == 0 {
+			return fmt.Errorf("%s: response_field_policy.rules[%d]: requires allow_fields or deny_fields", scope, i)
+		}
+		for _, f := range append(append([]string{}, rule.AllowFields...), rule.DenyFields...) {
+			if f == "" || strings.HasPrefix(f, ".") || strings.HasSuffix(f, ".") || strings.Contains(f, "..") {
+				return fmt.Errorf("%s: response_field_policy.rules[%d]: invalid field path %q", scope, i, f)
+			}
+		}
+	}
+	return nil
+}
+
 func (l *Loader) validateFieldEncryptionConfig(scope string, cfg FieldEncryptionConfig) error {
 	if !cfg.Enabled {
 		return nil
@@ -2786,6 +4203,15 @@ func (l *Loader) validateFieldEncryptionConfig(scope string, cfg FieldEncryption
 	return nil
 }
 
+// isSHA256Hex reports whether s is a 64-character hex string.
+func isSHA256Hex(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
 // validateTransportConfig validates a transport config for a given scope.
 func (l *Loader) validateTransportConfig(scope string, cfg TransportConfig) error {
 	if cfg.MaxIdleConns < 0 {
@@ -2833,6 +4259,11 @@ func (l *Loader) validateTransportConfig(scope string, cfg TransportConfig) erro
 	if cfg.EnableHTTP3 != nil && *cfg.EnableHTTP3 && cfg.ForceHTTP2 != nil && *cfg.ForceHTTP2 {
 		return fmt.Errorf("%s: transport.enable_http3 and transport.force_http2 are mutually exclusive", scope)
 	}
+	switch cfg.IPFamily {
+	case "", "auto", "ipv4", "ipv6", "prefer_ipv4", "prefer_ipv6":
+	default:
+		return fmt.Errorf("%s: transport.ip_family must be auto, ipv4, ipv6, prefer_ipv4, or prefer_ipv6", scope)
+	}
 	return nil
 }
 
@@ -2910,6 +4341,30 @@ func (l *Loader) validateResponseLimitConfig(scope string, cfg ResponseLimitConf
 	return nil
 }
 
+// validateResponseBufferingConfig validates a response buffering config. The
+// global-only spill_dir and disk_budget are checked whether or not the
+// global config is enabled, since routes use them.
+func (l *Loader) validateResponseBufferingConfig(scope string, cfg ResponseBufferingConfig) error {
+	if cfg.DiskBudget < 0 {
+		return fmt.Errorf("%s: response_buffering.disk_budget must be >= 0", scope)
+	}
+	if cfg.SpillDir != "" {
+		if fi, err := os.Stat(cfg.SpillDir); err != nil || !fi.IsDir() {
+			return fmt.Errorf("%s: response_buffering.spill_dir %q must be an existing directory", scope, cfg.SpillDir)
+		}
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.MemoryThreshold < 0 || cfg.MaxSize < 0 || cfg.MaxDiskPerRequest < 0 {
+		return fmt.Errorf("%s: response_buffering.memory_threshold, max_size and max_disk_per_request must be >= 0", scope)
+	}
+	if cfg.MaxSize > 0 && cfg.MemoryThreshold > cfg.MaxSize {
+		return fmt.Errorf("%s: response_buffering.memory_threshold must not exceed max_size", scope)
+	}
+	return nil
+}
+
 // validateSecurityHeadersConfig validates a security headers config.
 func (l *Loader) validateSecurityHeadersConfig(scope string, cfg SecurityHeadersConfig) error {
 	if !cfg.Enabled {
@@ -2931,14 +4386,186 @@ func (l *Loader) validateMaintenanceConfig(scope string, cfg MaintenanceConfig)
 	if cfg.StatusCode != 0 && (cfg.StatusCode < 100 || cfg.StatusCode > 599) {
 		return fmt.Errorf("%s: maintenance.status_code must be a valid HTTP status (100-599)", scope)
 	}
-	for _, cidr := range cfg.ExcludeIPs {
-		if strings.Contains(cidr, "/") {
-			if _, _, err := net.ParseCIDR(cidr); err != nil {
-				return fmt.Errorf("%s: maintenance.exclude_ips: invalid CIDR %q: %w", scope, cidr, err)
-			}
-		} else {
-			if ip := net.ParseIP(cidr); ip == nil {
-				return fmt.Errorf("%s: maintenance.exclude_ips: invalid IP %q", scope, cidr)
+	for _, cidr := range cfg.ExcludeIPs {
+		if strings.Contains(cidr, "/") {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("%s: maintenance.exclude_ips: invalid CIDR %q: %w", scope, cidr, err)
+			}
+		} else {
+			if ip := net.ParseIP(cidr); ip == nil {
+				return fmt.Errorf("%s: maintenance.exclude_ips: invalid IP %q", scope, cidr)
+			}
+		}
+	}
+	return nil
+}
+
+// validateSyntheticMonitoringConfig validates a synthetic monitoring config.
+// Route configs are validated after merging with the global config.
+func (l *Loader) validateSyntheticMonitoringConfig(scope string, cfg SyntheticMonitoringConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Secret == "" && len(cfg.SourceCIDRs) == 0 {
+		return fmt.Errorf("%s: synthetic_monitoring requires secret or source_cidrs", scope)
+	}
+	if cfg.MaxSkew < 0 {
+		return fmt.Errorf("%s: synthetic_monitoring.max_skew must be >= 0", scope)
+	}
+	for _, cidr := range cfg.SourceCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("%s: synthetic_monitoring.source_cidrs: invalid CIDR %q: %w", scope, cidr, err)
+		}
+	}
+	return nil
+}
+
+// validateDegradedModeConfig validates a degraded mode config.
+func (l *Loader) validateDegradedModeConfig(scope string, cfg DegradedModeConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.ErrorRateThreshold < 0 || cfg.ErrorRateThreshold > 1 {
+		return fmt.Errorf("%s: degraded_mode.error_rate_threshold must be between 0.0 and 1.0", scope)
+	}
+	if cfg.ConsecutiveFailures < 0 {
+		return fmt.Errorf("%s: degraded_mode.consecutive_failures must be >= 0", scope)
+	}
+	if cfg.ErrorRateThreshold == 0 && cfg.ConsecutiveFailures == 0 {
+		return fmt.Errorf("%s: degraded_mode requires error_rate_threshold or consecutive_failures", scope)
+	}
+	if cfg.MinRequests < 0 {
+		return fmt.Errorf("%s: degraded_mode.min_requests must be >= 0", scope)
+	}
+	if cfg.Window < 0 {
+		return fmt.Errorf("%s: degraded_mode.window must be >= 0", scope)
+	}
+	if cfg.Fallback.StatusCode != 0 && (cfg.Fallback.StatusCode < 100 || cfg.Fallback.StatusCode > 599) {
+		return fmt.Errorf("%s: degraded_mode.fallback.status_code must be a valid HTTP status (100-599)", scope)
+	}
+	if cfg.Probation.SuccessThreshold < 0 {
+		return fmt.Errorf("%s: degraded_mode.probation.success_threshold must be >= 0", scope)
+	}
+	if cfg.Probation.Interval < 0 {
+		return fmt.Errorf("%s: degraded_mode.probation.interval must be >= 0", scope)
+	}
+	return nil
+}
+
+// validateRoutePeerFailover checks that a route's peer failover names
+// configured peers.
+func (l *Loader) validateRoutePeerFailover(scope string, rc RoutePeerFailoverConfig, pc PeerFailoverConfig) error {
+	if !rc.Enabled {
+		return nil
+	}
+	if len(pc.Peers) == 0 {
+		return fmt.Errorf("%s: peer_failover requires peer_failover.peers in the global config", scope)
+	}
+	for _, name := range rc.Peers {
+		if !slices.ContainsFunc(pc.Peers, func(p PeerConfig) bool { return p.Name == name }) {
+			return fmt.Errorf("%s: peer_failover peer %q not found in peer_failover.peers", scope, name)
+		}
+	}
+	return nil
+}
+
+// validateBreakGlassConfig checks a route's break-glass bypass profile.
+func (l *Loader) validateBreakGlassConfig(scope string, bg BreakGlassConfig) error {
+	if !bg.Enabled {
+		return nil
+	}
+	if len(bg.Bypass) == 0 {
+		return fmt.Errorf("%s: break_glass.bypass must list at least one feature", scope)
+	}
+	for i, name := range bg.Bypass {
+		if name == "" {
+			return fmt.Errorf("%s: break_glass.bypass[%d] must not be empty", scope, i)
+		}
+		if slices.Contains(bg.Bypass[:i], name) {
+			return fmt.Errorf("%s: break_glass.bypass lists %q twice", scope, name)
+		}
+	}
+	if bg.MaxTTL < 0 {
+		return fmt.Errorf("%s: break_glass.max_ttl must be >= 0", scope)
+	}
+	return nil
+}
+
+// validateMultipartFieldsConfig checks a route's multipart field capture.
+func (l *Loader) validateMultipartFieldsConfig(scope string, mf MultipartFieldsConfig) error {
+	if !mf.Enabled {
+		return nil
+	}
+	if len(mf.Fields) == 0 {
+		return fmt.Errorf("%s: multipart_fields.fields must list at least one field", scope)
+	}
+	for i, name := range mf.Fields {
+		if name == "" {
+			return fmt.Errorf("%s: multipart_fields.fields[%d] must not be empty", scope, i)
+		}
+		if slices.Contains(mf.Fields[:i], name) {
+			return fmt.Errorf("%s: multipart_fields.fields lists %q twice", scope, name)
+		}
+	}
+	if mf.MaxScanBytes < 0 || mf.MaxFieldSize < 0 {
+		return fmt.Errorf("%s: multipart_fields.max_scan_bytes and max_field_size must be >= 0", scope)
+	}
+	switch mf.OnLimit {
+	case "", "continue", "reject":
+	default:
+		return fmt.Errorf("%s: multipart_fields.on_limit must be 'continue' or 'reject'", scope)
+	}
+	return nil
+}
+
+// validateAuthRequirements checks a route's declarative authorization.
+func (l *Loader) validateAuthRequirements(scope string, auth RouteAuthConfig) error {
+	reqs := auth.Requirements
+	if !reqs.IsActive() {
+		return nil
+	}
+	if !auth.Required {
+		return fmt.Errorf("%s: auth.requirements requires auth.required", scope)
+	}
+	if err := validateAuthRequirement(scope+": auth.requirements", reqs.AuthRequirement); err != nil {
+		return err
+	}
+	validMethods := map[string]bool{
+		"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true,
+	}
+	for method, req := range reqs.Methods {
+		if !validMethods[method] {
+			return fmt.Errorf("%s: auth.requirements.methods: invalid method %q", scope, method)
+		}
+		if err := validateAuthRequirement(fmt.Sprintf("%s: auth.requirements.methods.%s", scope, method), req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateAuthRequirement(prefix string, req AuthRequirement) error {
+	for field, list := range map[string][]string{"scopes_any": req.ScopesAny, "scopes_all": req.ScopesAll, "roles_any": req.RolesAny} {
+		if slices.Contains(list, "") {
+			return fmt.Errorf("%s.%s must not contain empty values", prefix, field)
+		}
+	}
+	for claim, want := range req.Claims {
+		if claim == "" {
+			return fmt.Errorf("%s.claims: claim name must not be empty", prefix)
+		}
+		values, isList := want.([]interface{})
+		if !isList {
+			values = []interface{}{want}
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("%s.claims.%s must list at least one value", prefix, claim)
+		}
+		for _, v := range values {
+			switch v.(type) {
+			case string, int, int64, float64, bool:
+			default:
+				return fmt.Errorf("%s.claims.%s values must be strings, numbers or booleans", prefix, claim)
 			}
 		}
 	}
@@ -3029,6 +4656,254 @@ func (l *Loader) validateSSRFProtectionConfig(cfg SSRFProtectionConfig) error {
 	return nil
 }
 
+// validateEgressPolicy validates the egress policy and checks every static
+// outbound destination in the config against it. Template URLs are checked
+// only when their host is static; the rest is checked before dialing. In
+// warn mode violations are collected in cfg.EgressWarnings instead.
+func (l *Loader) validateEgressPolicy(cfg *Config) error {
+	ep := cfg.EgressPolicy
+	cfg.EgressWarnings = nil
+	if !ep.Enabled {
+		return nil
+	}
+	switch ep.Mode {
+	case "", "enforce", "warn":
+	default:
+		return fmt.Errorf("egress_policy.mode must be \"enforce\" or \"warn\", got %q", ep.Mode)
+	}
+	if len(ep.AllowedDomains) == 0 && len(ep.AllowedCIDRs) == 0 {
+		return fmt.Errorf("egress_policy: at least one of allowed_domains or allowed_cidrs is required")
+	}
+	for i, d := range ep.AllowedDomains {
+		if strings.Trim(d, ".*") == "" {
+			return fmt.Errorf("egress_policy.allowed_domains[%d]: domain is empty", i)
+		}
+	}
+	cidrs := make([]*net.IPNet, 0, len(ep.AllowedCIDRs))
+	for i, c := range ep.AllowedCIDRs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return fmt.Errorf("egress_policy.allowed_cidrs[%d]: invalid CIDR %q: %w", i, c, err)
+		}
+		cidrs = append(cidrs, n)
+	}
+
+	check := func(field, raw string) error {
+		host := egressHost(raw)
+		if host == "" || egressAllowed(host, ep.AllowedDomains, cidrs) {
+			return nil
+		}
+		w := EgressWarning{Field: field, Host: host, Message: "is not in the allowlist"}
+		if net.ParseIP(host) == nil && len(ep.AllowedDomains) == 0 {
+			// Hostnames are not resolved at load time.
+			w.Message = "is a hostname but allowed_domains is empty; allowed_cidrs only match IP addresses when the config is loaded, so add the domain to allowed_domains or use an IP address"
+		}
+		if ep.Mode == "warn" {
+			cfg.EgressWarnings = append(cfg.EgressWarnings, w)
+			return nil
+		}
+		return fmt.Errorf("%s", w)
+	}
+	checkTemplate := func(field, tmpl string) error {
+		if host, ok := templateStaticHost(tmpl); ok {
+			return check(field, host)
+		}
+		return nil
+	}
+	checkBackends := func(field string, backends []BackendConfig) error {
+		for i, b := range backends {
+			if err := check(fmt.Sprintf("%s[%d].url", field, i), b.URL); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(cfg.Upstreams)) {
+		if err := checkBackends(fmt.Sprintf("upstreams[%s].backends", name), cfg.Upstreams[name].Backends); err != nil {
+			return err
+		}
+	}
+	for _, r := range cfg.Routes {
+		p := fmt.Sprintf("routes[%s]", r.ID)
+		if err := checkBackends(p+".backends", r.Backends); err != nil {
+			return err
+		}
+		for i, ts := range r.TrafficSplit {
+			if err := checkBackends(fmt.Sprintf("%s.traffic_split[%d].backends", p, i), ts.Backends); err != nil {
+				return err
+			}
+		}
+		for _, v := range slices.Sorted(maps.Keys(r.Versioning.Versions)) {
+			if err := checkBackends(fmt.Sprintf("%s.versioning.versions[%s].backends", p, v), r.Versioning.Versions[v].Backends); err != nil {
+				return err
+			}
+		}
+		for _, t := range slices.Sorted(maps.Keys(r.TenantBackends)) {
+			if err := checkBackends(fmt.Sprintf("%s.tenant_backends[%s]", p, t), r.TenantBackends[t]); err != nil {
+				return err
+			}
+		}
+		if err := checkBackends(p+".mirror.backends", r.Mirror.Backends); err != nil {
+			return err
+		}
+		fallbacks := []struct {
+			name string
+			fb   HandlerFallbackConfig
+		}{
+			{"protocol", r.Protocol.Fallback},
+			{"lambda", r.Lambda.Fallback},
+			{"amqp", r.AMQP.Fallback},
+		}
+		for _, f := range fallbacks {
+			if err := checkBackends(p+"."+f.name+".fallback.backends", f.fb.Backends); err != nil {
+				return err
+			}
+		}
+		for i, b := range r.Aggregate.Backends {
+			if err := checkTemplate(fmt.Sprintf("%s.aggregate.backends[%d].url", p, i), b.URL); err != nil {
+				return err
+			}
+		}
+		for i, s := range r.Sequential.Steps {
+			if err := checkTemplate(fmt.Sprintf("%s.sequential.steps[%d].url", p, i), s.URL); err != nil {
+				return err
+			}
+		}
+		urls := []struct{ field, url string }{
+			{"ext_auth.url", r.ExtAuth.URL},
+			{"opa.url", r.OPA.URL},
+			{"backend_auth.token_url", r.BackendAuth.TokenURL},
+			{"audit_log.webhook_url", r.AuditLog.WebhookURL},
+			{"oidc.issuer", r.OIDC.Issuer},
+		}
+		if r.AI.Enabled {
+			urls = append(urls, struct{ field, url string }{"ai.base_url", aiBaseURL(r.AI)})
+		}
+		for _, u := range urls {
+			if err := check(p+"."+u.field, u.url); err != nil {
+				return err
+			}
+		}
+	}
+	for i, tr := range cfg.TCPRoutes {
+		if err := checkBackends(fmt.Sprintf("tcp_routes[%d].backends", i), tr.Backends); err != nil {
+			return err
+		}
+	}
+	for i, ur := range cfg.UDPRoutes {
+		if err := checkBackends(fmt.Sprintf("udp_routes[%d].backends", i), ur.Backends); err != nil {
+			return err
+		}
+	}
+	for i, ep := range cfg.Webhooks.Endpoints {
+		if err := check(fmt.Sprintf("webhooks.endpoints[%d].url", i), ep.URL); err != nil {
+			return err
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.ExtAuthServices)) {
+		if err := check(fmt.Sprintf("ext_auth_services[%s].url", name), cfg.ExtAuthServices[name].URL); err != nil {
+			return err
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.OPAPolicies)) {
+		if err := check(fmt.Sprintf("opa_policies[%s].url", name), cfg.OPAPolicies[name].URL); err != nil {
+			return err
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.BackendAuthProviders)) {
+		if err := check(fmt.Sprintf("backend_auth_providers[%s].token_url", name), cfg.BackendAuthProviders[name].TokenURL); err != nil {
+			return err
+		}
+	}
+	globals := []struct{ field, url string }{
+		{"authentication.jwt.jwks_url", cfg.Authentication.JWT.JWKSURL},
+		{"authentication.oauth.introspection_url", cfg.Authentication.OAuth.IntrospectionURL},
+		{"authentication.oauth.jwks_url", cfg.Authentication.OAuth.JWKSURL},
+		{"audit_log.webhook_url", cfg.AuditLog.WebhookURL},
+	}
+	for i, u := range cfg.Authentication.JWT.JWKSURLs {
+		globals = append(globals, struct{ field, url string }{fmt.Sprintf("authentication.jwt.jwks_urls[%d]", i), u})
+	}
+	for i, u := range cfg.Authentication.OAuth.JWKSURLs {
+		globals = append(globals, struct{ field, url string }{fmt.Sprintf("authentication.oauth.jwks_urls[%d]", i), u})
+	}
+	for _, g := range globals {
+		if err := check(g.field, g.url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aiBaseURL returns the base URL an AI provider connects to.
+func aiBaseURL(ai AIConfig) string {
+	if ai.BaseURL != "" {
+		return ai.BaseURL
+	}
+	switch ai.Provider {
+	case "openai":
+		return "https://api.openai.com"
+	case "anthropic":
+		return "https://api.anthropic.com"
+	case "gemini":
+		return "https://generativelanguage.googleapis.com"
+	}
+	return ""
+}
+
+// egressHost extracts the destination host of a URL or host:port address.
+func egressHost(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	if host, _, err := net.SplitHostPort(raw); err == nil {
+		return host
+	}
+	return ""
+}
+
+// templateStaticHost returns the host of a URL template when it contains
+// no template actions.
+func templateStaticHost(tmpl string) (string, bool) {
+	i := strings.Index(tmpl, "://")
+	if i < 0 || strings.Contains(tmpl[:i], "{{") {
+		return "", false
+	}
+	authority := tmpl[i+3:]
+	if j := strings.IndexAny(authority, "/?#"); j >= 0 {
+		authority = authority[:j]
+	}
+	if authority == "" || strings.Contains(authority, "{{") {
+		return "", false
+	}
+	return tmpl[:i+3] + authority, true
+}
+
+// egressAllowed reports whether host matches an allowed domain by suffix or,
+// for IP literals, falls within an allowed CIDR.
+func egressAllowed(host string, domains []string, cidrs []*net.IPNet) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ip := net.ParseIP(host); ip != nil {
+		for _, n := range cidrs {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, d := range domains {
+		d = strings.ToLower(strings.Trim(d, ".*"))
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
 // validateRequestDedupConfig validates request dedup config for a given scope.
 func (l *Loader) validateRequestDedupConfig(scope string, cfg RequestDedupConfig, redisAddr string) error {
 	if !cfg.Enabled {
@@ -3052,6 +4927,44 @@ func (l *Loader) validateRequestDedupConfig(scope string, cfg RequestDedupConfig
 	return nil
 }
 
+// validateContentDedupConfig validates content dedup config for a given scope.
+func (l *Loader) validateContentDedupConfig(scope string, cfg ContentDedupConfig, redisAddr string) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	switch cfg.Mode {
+	case "", "local", "distributed":
+		// valid
+	default:
+		return fmt.Errorf("%s: content_dedup.mode must be \"local\" or \"distributed\", got %q", scope, cfg.Mode)
+	}
+	switch cfg.OnDuplicate {
+	case "", "replay", "reject":
+		// valid
+	default:
+		return fmt.Errorf("%s: content_dedup.on_duplicate must be \"replay\" or \"reject\", got %q", scope, cfg.OnDuplicate)
+	}
+	if cfg.TTL < 0 {
+		return fmt.Errorf("%s: content_dedup.ttl must be >= 0", scope)
+	}
+	if cfg.WaitTimeout < 0 {
+		return fmt.Errorf("%s: content_dedup.wait_timeout must be >= 0", scope)
+	}
+	if cfg.MaxBodySize < 0 {
+		return fmt.Errorf("%s: content_dedup.max_body_size must be >= 0", scope)
+	}
+	for _, m := range cfg.Methods {
+		switch strings.ToUpper(m) {
+		case "GET", "HEAD", "OPTIONS":
+			return fmt.Errorf("%s: content_dedup.methods must be mutation methods, got %q", scope, m)
+		}
+	}
+	if cfg.Mode == "distributed" && redisAddr == "" {
+		return fmt.Errorf("%s: content_dedup.mode \"distributed\" requires redis.address to be configured", scope)
+	}
+	return nil
+}
+
 // validateIPBlocklistConfig validates IP blocklist config for a given scope.
 func (l *Loader) validateIPBlocklistConfig(scope string, cfg IPBlocklistConfig) error {
 	if !cfg.Enabled {
@@ -3168,8 +5081,11 @@ func (l *Loader) validateTenants(tc TenantsConfig, routeIDs map[string]bool) err
 	if strings.HasPrefix(tc.Key, "jwt_claim:") && len(tc.Key) > len("jwt_claim:") {
 		validKey = true
 	}
+	if strings.HasPrefix(tc.Key, "form:") && len(tc.Key) > len("form:") {
+		validKey = true
+	}
 	if !validKey {
-		return fmt.Errorf("tenants: key must be 'client_id', 'header:<name>', or 'jwt_claim:<name>'")
+		return fmt.Errorf("tenants: key must be 'client_id', 'header:<name>', 'jwt_claim:<name>', or 'form:<name>'")
 	}
 	if len(tc.Tenants) == 0 {
 		return fmt.Errorf("tenants: at least one tenant must be defined")
@@ -3202,6 +5118,11 @@ func (l *Loader) validateTenants(tc TenantsConfig, routeIDs map[string]bool) err
 				return fmt.Errorf("tenants.tiers[%s]: quota.period must be hourly, daily, monthly, or yearly", tierName)
 			}
 		}
+		if bq := tier.BandwidthQuota; bq != nil {
+			if err := validateBandwidthQuota(fmt.Sprintf("tenants.tiers[%s]: bandwidth_quota", tierName), bq.Limit, bq.Period, bq.Mode); err != nil {
+				return err
+			}
+		}
 		for k := range tier.ResponseHeaders {
 			if k == "" {
 				return fmt.Errorf("tenants.tiers[%s]: response_headers contains empty header name", tierName)
@@ -3228,6 +5149,11 @@ func (l *Loader) validateTenants(tc TenantsConfig, routeIDs map[string]bool) err
 				return fmt.Errorf("tenants[%s]: quota.period must be hourly, daily, monthly, or yearly", name)
 			}
 		}
+		if bq := t.BandwidthQuota; bq != nil {
+			if err := validateBandwidthQuota(fmt.Sprintf("tenants[%s]: bandwidth_quota", name), bq.Limit, bq.Period, bq.Mode); err != nil {
+				return err
+			}
+		}
 		if t.MaxBodySize < 0 {
 			return fmt.Errorf("tenants[%s]: max_body_size must be >= 0", name)
 		}
@@ -3274,6 +5200,231 @@ func (l *Loader) validateTenantBackends(route RouteConfig, cfg *Config) error {
 	return nil
 }
 
+// grpcErrorCodeNames are the gRPC error code names accepted in fallback
+// triggers.
+var grpcErrorCodeNames = map[string]bool{
+	"CANCELLED": true, "UNKNOWN": true, "INVALID_ARGUMENT": true,
+	"DEADLINE_EXCEEDED": true, "NOT_FOUND": true, "ALREADY_EXISTS": true,
+	"PERMISSION_DENIED": true, "RESOURCE_EXHAUSTED": true, "FAILED_PRECONDITION": true,
+	"ABORTED": true, "OUT_OF_RANGE": true, "UNIMPLEMENTED": true, "INTERNAL": true,
+	"UNAVAILABLE": true, "DATA_LOSS": true, "UNAUTHENTICATED": true,
+}
+
+func (l *Loader) validateHandlerFallbacks(route RouteConfig, cfg *Config) error {
+	fallbacks := []struct {
+		name    string
+		handler bool
+		fb      HandlerFallbackConfig
+	}{
+		{"protocol", route.Protocol.Type != "", route.Protocol.Fallback},
+		{"lambda", route.Lambda.Enabled, route.Lambda.Fallback},
+		{"amqp", route.AMQP.Enabled, route.AMQP.Fallback},
+	}
+	for _, f := range fallbacks {
+		if !f.fb.Enabled {
+			continue
+		}
+		scope := fmt.Sprintf("route %s: %s.fallback", route.ID, f.name)
+		if !f.handler {
+			return fmt.Errorf("%s requires %s to be configured", scope, f.name)
+		}
+		if err := l.validateHandlerFallback(scope, f.fb, cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Loader) validateKafka(route RouteConfig, _ *Config) error {
+	k := route.Kafka
+	if !k.Enabled {
+		return nil
+	}
+	routeID := route.ID
+	if len(k.Brokers) == 0 {
+		return fmt.Errorf("route %s: kafka.brokers is required", routeID)
+	}
+	for _, b := range k.Brokers {
+		if _, _, err := net.SplitHostPort(b); err != nil {
+			return fmt.Errorf("route %s: kafka.brokers: %q must be host:port", routeID, b)
+		}
+	}
+	if k.Topic == "" {
+		return fmt.Errorf("route %s: kafka.topic is required", routeID)
+	}
+	switch k.Acks {
+	case "", "none", "leader", "all":
+	default:
+		return fmt.Errorf("route %s: kafka.acks must be none, leader or all", routeID)
+	}
+	if k.Key != "" {
+		if _, err := template.New("key").Funcs(tmplutil.FuncMap()).Parse(k.Key); err != nil {
+			return fmt.Errorf("route %s: kafka.key template is invalid: %w", routeID, err)
+		}
+	}
+	for _, h := range k.Headers {
+		if h == "" {
+			return fmt.Errorf("route %s: kafka.headers must not contain empty names", routeID)
+		}
+	}
+	if k.Timeout < 0 {
+		return fmt.Errorf("route %s: kafka.timeout must be >= 0", routeID)
+	}
+	if (k.TLS.CertFile == "") != (k.TLS.KeyFile == "") {
+		return fmt.Errorf("route %s: kafka.tls.cert_file and key_file must be set together", routeID)
+	}
+	switch k.SASL.Mechanism {
+	case "":
+	case "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		if k.SASL.Username == "" || k.SASL.Password == "" {
+			return fmt.Errorf("route %s: kafka.sasl requires username and password", routeID)
+		}
+	default:
+		return fmt.Errorf("route %s: kafka.sasl.mechanism must be PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512", routeID)
+	}
+	if len(route.Backends) > 0 || route.Service.Name != "" || route.Upstream != "" {
+		return fmt.Errorf("route %s: kafka is mutually exclusive with backends, service, and upstream", routeID)
+	}
+	exclusive := []struct {
+		name string
+		on   bool
+	}{
+		{"echo", route.Echo},
+		{"static", route.Static.Enabled},
+		{"fastcgi", route.FastCGI.Enabled},
+		{"sequential", route.Sequential.Enabled},
+		{"aggregate", route.Aggregate.Enabled},
+		{"lambda", route.Lambda.Enabled},
+		{"amqp", route.AMQP.Enabled},
+		{"pubsub", route.PubSub.Enabled},
+		{"passthrough", route.Passthrough},
+	}
+	for _, x := range exclusive {
+		if x.on {
+			return fmt.Errorf("route %s: kafka is mutually exclusive with %s", routeID, x.name)
+		}
+	}
+	return nil
+}
+
+func (l *Loader) validateCachePrime(route RouteConfig, cfg *Config) error {
+	p := route.Cache.Prime
+	if !p.Enabled {
+		return nil
+	}
+	routeID := route.ID
+	if !route.Cache.Enabled {
+		return fmt.Errorf("route %s: cache.prime requires cache.enabled", routeID)
+	}
+	if p.Manifest == "" {
+		return fmt.Errorf("route %s: cache.prime.manifest is required", routeID)
+	}
+	if strings.Contains(p.Manifest, "://") {
+		u, err := url.Parse(p.Manifest)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("route %s: cache.prime.manifest must be a file path or an http(s) URL", routeID)
+		}
+	}
+	if p.Concurrency < 0 {
+		return fmt.Errorf("route %s: cache.prime.concurrency must be >= 0", routeID)
+	}
+	if p.Rate < 0 {
+		return fmt.Errorf("route %s: cache.prime.rate must be >= 0", routeID)
+	}
+	if p.Budget < 0 || p.Timeout < 0 {
+		return fmt.Errorf("route %s: cache.prime.budget and timeout must be >= 0", routeID)
+	}
+	if p.ReadyPercent < 0 || p.ReadyPercent > 100 {
+		return fmt.Errorf("route %s: cache.prime.ready_percent must be between 0 and 100", routeID)
+	}
+	// A shared bucket is primed once, from one route.
+	if b := route.Cache.Bucket; b != "" {
+		for _, other := range cfg.Routes {
+			if other.ID == routeID {
+				break
+			}
+			if other.Cache.Enabled && other.Cache.Bucket == b && other.Cache.Prime.Enabled {
+				return fmt.Errorf("route %s: cache bucket %q is already primed by route %s", routeID, b, other.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func (l *Loader) validateDecisionLog(route RouteConfig, cfg *Config) error {
+	d := route.DecisionLog
+	if !d.Enabled {
+		return nil
+	}
+	routeID := route.ID
+	switch d.Sink {
+	case "", "file":
+		if d.File == "" {
+			return fmt.Errorf("route %s: decision_log.file is required for the file sink", routeID)
+		}
+	case "audit_webhook":
+		if route.AuditLog.WebhookURL == "" && cfg.AuditLog.WebhookURL == "" {
+			return fmt.Errorf("route %s: decision_log sink audit_webhook requires audit_log.webhook_url on the route or globally", routeID)
+		}
+	default:
+		return fmt.Errorf("route %s: decision_log.sink must be file or audit_webhook", routeID)
+	}
+	if d.MaxEntries < 0 {
+		return fmt.Errorf("route %s: decision_log.max_entries must be >= 0", routeID)
+	}
+	if d.MaxValueLength < 0 {
+		return fmt.Errorf("route %s: decision_log.max_value_length must be >= 0", routeID)
+	}
+	if d.BufferSize < 0 {
+		return fmt.Errorf("route %s: decision_log.buffer_size must be >= 0", routeID)
+	}
+	if d.Rotation.MaxSize < 0 || d.Rotation.MaxBackups < 0 || d.Rotation.MaxAge < 0 {
+		return fmt.Errorf("route %s: decision_log.rotation values must be >= 0", routeID)
+	}
+	return nil
+}
+
+func (l *Loader) validateHandlerFallback(scope string, fb HandlerFallbackConfig, cfg *Config) error {
+	if fb.Upstream != "" {
+		if len(fb.Backends) > 0 {
+			return fmt.Errorf("%s: upstream and backends are mutually exclusive", scope)
+		}
+		if us, ok := cfg.Upstreams[fb.Upstream]; !ok {
+			return fmt.Errorf("%s: references unknown upstream %q", scope, fb.Upstream)
+		} else if us.DNSDiscovery.Enabled {
+			return fmt.Errorf("%s: upstream %q with dns_discovery can only be referenced by route upstream", scope, fb.Upstream)
+		}
+	} else if len(fb.Backends) == 0 {
+		return fmt.Errorf("%s: requires backends or upstream", scope)
+	}
+	for i, b := range fb.Backends {
+		if b.URL == "" {
+			return fmt.Errorf("%s: backends[%d] missing url", scope, i)
+		}
+	}
+	t := fb.Triggers
+	if len(t.GRPCCodes) == 0 && len(t.Statuses) == 0 && !t.ConnectionErrors {
+		return fmt.Errorf("%s: requires at least one trigger", scope)
+	}
+	for _, c := range t.GRPCCodes {
+		if !grpcErrorCodeNames[c] {
+			return fmt.Errorf("%s: triggers.grpc_codes: unknown gRPC error code %q", scope, c)
+		}
+	}
+	for _, st := range t.Statuses {
+		if st < 400 || st > 599 {
+			return fmt.Errorf("%s: triggers.statuses: %d must be 400-599", scope, st)
+		}
+	}
+	if fb.MaxRate < 0 {
+		return fmt.Errorf("%s: max_rate must be >= 0", scope)
+	}
+	if fb.MaxBodySize < 0 {
+		return fmt.Errorf("%s: max_body_size must be >= 0", scope)
+	}
+	return nil
+}
+
 func (l *Loader) validateDeprecation(route RouteConfig, _ *Config) error {
 	cfg := route.Deprecation
 	if !cfg.Enabled {
@@ -3350,18 +5501,17 @@ func (l *Loader) validateBatchBFeatures(route RouteConfig, cfg *Config) error {
 	}
 
 	// OPA
-	if route.OPA.Enabled {
-		if route.OPA.URL == "" {
-			return fmt.Errorf("route %s: opa.url is required", routeID)
-		}
-		if !strings.HasPrefix(route.OPA.URL, "http://") && !strings.HasPrefix(route.OPA.URL, "https://") {
-			return fmt.Errorf("route %s: opa.url must start with http:// or https://", routeID)
-		}
-		if route.OPA.PolicyPath == "" {
-			return fmt.Errorf("route %s: opa.policy_path is required", routeID)
+	if route.OPA.Enabled && route.OPA.Ref == "" {
+		if err := validateOPAConfig(fmt.Sprintf("route %s: opa", routeID), route.OPA); err != nil {
+			return err
 		}
 	}
 
+	// Lua
+	if route.Lua.Enabled && route.Lua.ShadowRequestScript != "" && !route.Lua.ShadowAsync {
+		return fmt.Errorf("route %s: lua.shadow_request_script requires shadow_async", routeID)
+	}
+
 	// Response Signing
 	if route.ResponseSigning.Enabled {
 		algo := route.ResponseSigning.Algorithm
@@ -3421,6 +5571,23 @@ func (l *Loader) validateBatchBFeatures(route RouteConfig, cfg *Config) error {
 		if route.Cache.StaleIfError < 0 {
 			return fmt.Errorf("route %s: cache.stale_if_error must be >= 0", routeID)
 		}
+		if route.Cache.SoftTimeout < 0 {
+			return fmt.Errorf("route %s: cache.soft_timeout must be >= 0", routeID)
+		}
+		if route.Cache.MaxVaries < 0 {
+			return fmt.Errorf("route %s: cache.max_varies must be >= 0", routeID)
+		}
+		if route.Cache.ServeStaleOnTimeout {
+			if route.Cache.SoftTimeout == 0 {
+				return fmt.Errorf("route %s: cache.serve_stale_on_timeout requires cache.soft_timeout", routeID)
+			}
+			if route.Cache.StaleIfError == 0 {
+				return fmt.Errorf("route %s: cache.serve_stale_on_timeout requires cache.stale_if_error", routeID)
+			}
+			if rt := route.TimeoutPolicy.Request; rt > 0 && route.Cache.SoftTimeout >= rt {
+				return fmt.Errorf("route %s: cache.soft_timeout must be less than timeout_policy.request", routeID)
+			}
+		}
 	}
 
 	// GraphQL Subscriptions
@@ -3515,6 +5682,9 @@ func (l *Loader) validateAI(route RouteConfig, _ *Config) error {
 	if route.PubSub.Enabled {
 		return fmt.Errorf("route %s: ai is mutually exclusive with pubsub", routeID)
 	}
+	if route.Kafka.Enabled {
+		return fmt.Errorf("route %s: ai is mutually exclusive with kafka", routeID)
+	}
 	if route.MockResponse.Enabled {
 		return fmt.Errorf("route %s: ai is mutually exclusive with mock_response", routeID)
 	}
@@ -3561,3 +5731,22 @@ func (l *Loader) validateAI(route RouteConfig, _ *Config) error {
 	return nil
 }
 
+// validateSchemaDrift checks an openapi schema_drift block.
+func validateSchemaDrift(c SchemaDriftConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.SampleRate < 0 || c.SampleRate > 100 {
+		return fmt.Errorf("openapi.schema_drift.sample_rate must be between 0 and 100")
+	}
+	if c.MaxFindings < 0 {
+		return fmt.Errorf("openapi.schema_drift.max_findings must be >= 0")
+	}
+	if c.MaxBodySize < 0 {
+		return fmt.Errorf("openapi.schema_drift.max_body_size must be >= 0")
+	}
+	if c.DigestInterval < 0 {
+		return fmt.Errorf("openapi.schema_drift.digest_interval must be >= 0")
+	}
+	return nil
+}
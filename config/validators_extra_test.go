@@ -1,6 +1,8 @@
 package config
 
 import (
+	"encoding/base64"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -200,6 +202,229 @@ func TestValidateMockAndStaticFiles(t *testing.T) {
 	}
 }
 
+// --- validateSchemaDrift ---
+
+func TestValidateSchemaDrift(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SchemaDriftConfig
+		wantErr string
+	}{
+		{name: "disabled ignores values", cfg: SchemaDriftConfig{SampleRate: 500}},
+		{name: "defaults", cfg: SchemaDriftConfig{Enabled: true}},
+		{name: "full sampling", cfg: SchemaDriftConfig{Enabled: true, SampleRate: 100, DigestInterval: time.Hour}},
+		{
+			name:    "sample_rate above 100",
+			cfg:     SchemaDriftConfig{Enabled: true, SampleRate: 150},
+			wantErr: "sample_rate must be between 0 and 100",
+		},
+		{
+			name:    "negative max_findings",
+			cfg:     SchemaDriftConfig{Enabled: true, MaxFindings: -1},
+			wantErr: "max_findings must be >= 0",
+		},
+		{
+			name:    "negative digest_interval",
+			cfg:     SchemaDriftConfig{Enabled: true, DigestInterval: -time.Second},
+			wantErr: "digest_interval must be >= 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSchemaDrift(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateStorageEncryption(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	keys := StorageEncryptionConfig{KeyID: "k1", KeyBase64: key}
+	distributedCache := func(encrypt bool, bucket string) RouteConfig {
+		return RouteConfig{ID: "r", Cache: CacheConfig{Enabled: true, Mode: "distributed", Encrypt: encrypt, Bucket: bucket}}
+	}
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{name: "unset"},
+		{name: "key only", cfg: Config{StorageEncryption: keys}},
+		{
+			name: "rotation",
+			cfg: Config{StorageEncryption: StorageEncryptionConfig{
+				KeyID: "k2", KeyBase64: key, PreviousKeyID: "k1", PreviousKeyBase64: key, PreviousKeyGrace: time.Hour,
+			}},
+		},
+		{
+			name:    "key_id without key",
+			cfg:     Config{StorageEncryption: StorageEncryptionConfig{KeyID: "k1"}},
+			wantErr: "storage_encryption.key_base64 is required",
+		},
+		{
+			name:    "missing key_id",
+			cfg:     Config{StorageEncryption: StorageEncryptionConfig{KeyBase64: key}},
+			wantErr: "storage_encryption.key_id must be 1-255 bytes",
+		},
+		{
+			name:    "short key",
+			cfg:     Config{StorageEncryption: StorageEncryptionConfig{KeyID: "k1", KeyBase64: "c2hvcnQ="}},
+			wantErr: "must decode to exactly 32 bytes",
+		},
+		{
+			name:    "previous key reuses the current ID",
+			cfg:     Config{StorageEncryption: StorageEncryptionConfig{KeyID: "k1", KeyBase64: key, PreviousKeyID: "k1", PreviousKeyBase64: key}},
+			wantErr: "previous_key_id must differ from key_id",
+		},
+		{
+			name:    "previous key without ID",
+			cfg:     Config{StorageEncryption: StorageEncryptionConfig{KeyID: "k1", KeyBase64: key, PreviousKeyBase64: key}},
+			wantErr: "storage_encryption.previous_key_id must be 1-255 bytes",
+		},
+		{
+			name:    "encrypted cache without key",
+			cfg:     Config{Routes: []RouteConfig{distributedCache(true, "")}},
+			wantErr: "route r: cache.encrypt requires storage_encryption.key_base64",
+		},
+		{name: "encrypted cache", cfg: Config{StorageEncryption: keys, Routes: []RouteConfig{distributedCache(true, "")}}},
+		{
+			name: "encrypted local cache",
+			cfg: Config{StorageEncryption: keys, Routes: []RouteConfig{
+				{ID: "r", Cache: CacheConfig{Enabled: true, Encrypt: true}},
+			}},
+			wantErr: `route r: cache.encrypt requires mode "distributed"`,
+		},
+		{
+			name: "bucket routes disagree",
+			cfg: Config{StorageEncryption: keys, Routes: []RouteConfig{
+				distributedCache(true, "shared"), distributedCache(false, "shared"),
+			}},
+			wantErr: `must agree on cache.encrypt`,
+		},
+		{
+			name: "route idempotency takes the global mode",
+			cfg: Config{
+				StorageEncryption: keys,
+				Idempotency:       IdempotencyConfig{Mode: "distributed"},
+				Routes:            []RouteConfig{{ID: "r", Idempotency: IdempotencyConfig{Enabled: true, Encrypt: true}}},
+			},
+		},
+		{
+			name: "global idempotency encrypt in local mode",
+			cfg: Config{
+				StorageEncryption: keys,
+				Idempotency:       IdempotencyConfig{Enabled: true, Encrypt: true},
+			},
+			wantErr: `global: idempotency.encrypt requires mode "distributed"`,
+		},
+		{
+			name: "local content dedup",
+			cfg: Config{StorageEncryption: keys, Routes: []RouteConfig{
+				{ID: "r", ContentDedup: ContentDedupConfig{Enabled: true, Encrypt: true}},
+			}},
+			wantErr: `route r: content_dedup.encrypt requires mode "distributed"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStorageEncryption(&tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// --- validateErrorsConfig ---
+
+func TestValidateErrorsConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ErrorsConfig
+		wantErr string
+	}{
+		{name: "unset"},
+		{name: "legacy", cfg: ErrorsConfig{Format: ErrorFormatLegacy}},
+		{
+			name: "problem_json with catalog",
+			cfg: ErrorsConfig{Format: ErrorFormatProblemJSON, Catalog: map[string]ErrorCatalogEntry{
+				"rate_limited": {Type: "https://errors.example.com/rate-limited", Title: "Slow down"},
+				"waf_blocked":  {Type: "urn:example:waf"},
+			}},
+		},
+		{name: "unknown format", cfg: ErrorsConfig{Format: "xml"}, wantErr: "errors.format must be"},
+		{
+			name:    "empty reason",
+			cfg:     ErrorsConfig{Catalog: map[string]ErrorCatalogEntry{"": {Type: "https://example.com"}}},
+			wantErr: "errors.catalog keys must be non-empty",
+		},
+		{
+			name:    "missing type",
+			cfg:     ErrorsConfig{Catalog: map[string]ErrorCatalogEntry{"rate_limited": {Title: "Slow down"}}},
+			wantErr: "errors.catalog[rate_limited].type is required",
+		},
+		{
+			name:    "relative type",
+			cfg:     ErrorsConfig{Catalog: map[string]ErrorCatalogEntry{"rate_limited": {Type: "/errors/rate-limited"}}},
+			wantErr: "errors.catalog[rate_limited].type must be an absolute URI",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateErrorsConfig("route r", tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestErrorsConfigMerge(t *testing.T) {
+	global := ErrorsConfig{Format: ErrorFormatProblemJSON, Catalog: map[string]ErrorCatalogEntry{
+		"rate_limited": {Type: "https://example.com/global"},
+		"waf_blocked":  {Type: "https://example.com/waf"},
+	}}
+	route := ErrorsConfig{Catalog: map[string]ErrorCatalogEntry{
+		"rate_limited": {Type: "https://example.com/route"},
+	}}
+	got := route.Merge(global)
+	if got.Format != ErrorFormatProblemJSON {
+		t.Errorf("format = %q, want the global format", got.Format)
+	}
+	if got.Catalog["rate_limited"].Type != "https://example.com/route" || got.Catalog["waf_blocked"].Type != "https://example.com/waf" {
+		t.Errorf("catalog = %+v, want route entries over global ones", got.Catalog)
+	}
+	if len(global.Catalog) != 2 || global.Catalog["rate_limited"].Type != "https://example.com/global" {
+		t.Error("merge modified the global catalog")
+	}
+	if got := (ErrorsConfig{Format: ErrorFormatLegacy}).Merge(global); got.Format != ErrorFormatLegacy {
+		t.Errorf("format = %q, want the route override", got.Format)
+	}
+}
+
 // --- validateFastCGI ---
 
 func TestValidateFastCGI(t *testing.T) {
@@ -574,6 +799,88 @@ func TestValidateBackendAuthAndStatusMapping(t *testing.T) {
 	}
 }
 
+// --- validateBackendHeadersSecret ---
+
+func TestValidateBackendHeadersSecret(t *testing.T) {
+	l := NewLoader()
+	route := func(bhs BackendHeadersSecretConfig) RouteConfig {
+		bhs.Enabled = true
+		return RouteConfig{ID: "r1", BackendHeadersSecret: bhs}
+	}
+	tests := []struct {
+		name    string
+		route   RouteConfig
+		wantErr string
+	}{
+		{
+			name:  "disabled",
+			route: RouteConfig{ID: "r1"},
+		},
+		{
+			name: "valid rotation pair",
+			route: route(BackendHeadersSecretConfig{Headers: []SecretHeaderConfig{
+				{Name: "X-Api-Key", Primary: "${env:OLD}", Secondary: "${env:NEW}", SecondaryPercent: 10},
+			}}),
+		},
+		{
+			name:    "no headers",
+			route:   route(BackendHeadersSecretConfig{}),
+			wantErr: "requires at least one header",
+		},
+		{
+			name:    "missing name",
+			route:   route(BackendHeadersSecretConfig{Headers: []SecretHeaderConfig{{Primary: "x"}}}),
+			wantErr: "headers[0].name is required",
+		},
+		{
+			name:    "missing primary",
+			route:   route(BackendHeadersSecretConfig{Headers: []SecretHeaderConfig{{Name: "X-Api-Key"}}}),
+			wantErr: "headers[0].primary is required",
+		},
+		{
+			name: "duplicate header",
+			route: route(BackendHeadersSecretConfig{Headers: []SecretHeaderConfig{
+				{Name: "X-Api-Key", Primary: "a"},
+				{Name: "x-api-key", Primary: "b"},
+			}}),
+			wantErr: "duplicate header",
+		},
+		{
+			name:    "percent out of range",
+			route:   route(BackendHeadersSecretConfig{Headers: []SecretHeaderConfig{{Name: "X-Api-Key", Primary: "a", Secondary: "b", SecondaryPercent: 101}}}),
+			wantErr: "secondary_percent must be between 0 and 100",
+		},
+		{
+			name:    "percent without secondary",
+			route:   route(BackendHeadersSecretConfig{Headers: []SecretHeaderConfig{{Name: "X-Api-Key", Primary: "a", SecondaryPercent: 50}}}),
+			wantErr: "secondary_percent requires secondary",
+		},
+		{
+			name:    "negative refresh interval",
+			route:   route(BackendHeadersSecretConfig{RefreshInterval: -1, Headers: []SecretHeaderConfig{{Name: "X-Api-Key", Primary: "a"}}}),
+			wantErr: "refresh_interval must be >= 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.validateBackendHeadersSecret(tt.route, nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 // --- validateSequentialProxy ---
 
 func TestValidateSequentialProxy(t *testing.T) {
@@ -887,6 +1194,78 @@ func TestValidateAggregateProxy(t *testing.T) {
 	}
 }
 
+// --- validatePropagateHeaders ---
+
+func TestValidatePropagateHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     PropagateHeadersConfig
+		wantErr string
+	}{
+		{name: "empty", cfg: PropagateHeadersConfig{}},
+		{
+			name: "valid",
+			cfg: PropagateHeadersConfig{
+				Headers:  []string{"X-Total-Count", "X-RateLimit-*"},
+				Rename:   map[string]string{"x-total-count": "X-Orders-Total", "X-RateLimit-Remaining": "X-Orders-Remaining"},
+				Conflict: "join",
+			},
+		},
+		{name: "rename without headers", cfg: PropagateHeadersConfig{Rename: map[string]string{"A": "B"}}, wantErr: "propagate_headers.rename requires headers"},
+		{name: "bare wildcard inside", cfg: PropagateHeadersConfig{Headers: []string{"X-*-Id"}}, wantErr: `invalid entry "X-*-Id"`},
+		{name: "hop-by-hop", cfg: PropagateHeadersConfig{Headers: []string{"transfer-encoding"}}, wantErr: "Transfer-Encoding cannot be propagated"},
+		{name: "set-cookie without opt-in", cfg: PropagateHeadersConfig{Headers: []string{"Set-Cookie"}}, wantErr: "Set-Cookie requires allow_set_cookie"},
+		{name: "set-cookie with opt-in", cfg: PropagateHeadersConfig{Headers: []string{"Set-Cookie"}, AllowSetCookie: true}},
+		{name: "rename unlisted", cfg: PropagateHeadersConfig{Headers: []string{"ETag"}, Rename: map[string]string{"Vary": "X-Vary"}}, wantErr: "Vary is not listed in headers"},
+		{name: "rename to hop-by-hop", cfg: PropagateHeadersConfig{Headers: []string{"ETag"}, Rename: map[string]string{"ETag": "Connection"}}, wantErr: `invalid target "Connection"`},
+		{name: "bad conflict", cfg: PropagateHeadersConfig{Headers: []string{"ETag"}, Conflict: "merge"}, wantErr: "conflict must be 'first', 'last' or 'join'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePropagateHeaders("route r1: aggregate backend a", tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	l := NewLoader()
+	err := l.validateSequentialProxy(RouteConfig{
+		ID: "r1",
+		Sequential: SequentialConfig{
+			Enabled: true,
+			Steps: []SequentialStep{
+				{URL: "http://svc1/api", PropagateHeaders: PropagateHeadersConfig{Headers: []string{"Upgrade"}}},
+				{URL: "http://svc2/api"},
+			},
+		},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "route r1: sequential step 0: propagate_headers.headers: Upgrade cannot be propagated") {
+		t.Errorf("expected the step to be named in the error, got %v", err)
+	}
+	err = l.validateAggregateProxy(RouteConfig{
+		ID: "r1",
+		Aggregate: AggregateConfig{
+			Enabled:              true,
+			MaxPropagatedHeaders: -1,
+			Backends: []AggregateBackend{
+				{Name: "users", URL: "http://users-svc/api"},
+				{Name: "orders", URL: "http://orders-svc/api"},
+			},
+		},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "max_propagated_headers and max_propagated_header_bytes must be >= 0") {
+		t.Errorf("expected a negative cap to be rejected, got %v", err)
+	}
+}
+
 // --- validateSmallRouteFeatures ---
 
 func TestValidateSmallRouteFeatures_SpikeArrest(t *testing.T) {
@@ -914,6 +1293,15 @@ func TestValidateSmallRouteFeatures_SpikeArrest(t *testing.T) {
 			route:   RouteConfig{ID: "r1", SpikeArrest: SpikeArrestConfig{Enabled: true, Rate: -5}},
 			wantErr: "spike_arrest rate must be > 0",
 		},
+		{
+			name:  "observe mode",
+			route: RouteConfig{ID: "r1", SpikeArrest: SpikeArrestConfig{Enabled: true, Rate: 10, Mode: "observe"}},
+		},
+		{
+			name:    "unknown mode",
+			route:   RouteConfig{ID: "r1", SpikeArrest: SpikeArrestConfig{Enabled: true, Rate: 10, Mode: "dry_run"}},
+			wantErr: `spike_arrest.mode must be "enforce" or "observe"`,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1248,6 +1636,37 @@ func TestValidateSmallRouteFeatures_SSE(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "stream_limits valid",
+			route: RouteConfig{
+				ID:           "r1",
+				SSE:          SSEConfig{Enabled: true},
+				StreamLimits: StreamLimitsConfig{MaxUpstreamStreams: 500, OnLimit: "fanout"},
+			},
+		},
+		{
+			name:    "stream_limits without sse or websocket",
+			route:   RouteConfig{ID: "r1", StreamLimits: StreamLimitsConfig{MaxUpstreamStreams: 10}},
+			wantErr: "stream_limits requires sse or websocket",
+		},
+		{
+			name: "stream_limits fanout on websocket",
+			route: RouteConfig{
+				ID:           "r1",
+				WebSocket:    WebSocketConfig{Enabled: true},
+				StreamLimits: StreamLimitsConfig{MaxUpstreamStreams: 10, OnLimit: "fanout"},
+			},
+			wantErr: "stream_limits.on_limit fanout requires sse",
+		},
+		{
+			name: "stream_limits invalid on_limit",
+			route: RouteConfig{
+				ID:           "r1",
+				SSE:          SSEConfig{Enabled: true},
+				StreamLimits: StreamLimitsConfig{OnLimit: "queue"},
+			},
+			wantErr: "stream_limits.on_limit must be reject or fanout",
+		},
 		{
 			name: "fanout negative buffer_size",
 			route: RouteConfig{
@@ -1343,7 +1762,7 @@ func TestValidateSmallRouteFeatures_ContentNegotiation(t *testing.T) {
 					Supported: []string{"json", "csv"},
 				},
 			},
-			wantErr: `content_negotiation supported format "csv" must be json, xml, or yaml`,
+			wantErr: `content_negotiation supported format "csv" must be json, xml, yaml, or cbor`,
 		},
 		{
 			name: "invalid default format",
@@ -1354,7 +1773,7 @@ func TestValidateSmallRouteFeatures_ContentNegotiation(t *testing.T) {
 					Default: "html",
 				},
 			},
-			wantErr: `content_negotiation default "html" must be json, xml, or yaml`,
+			wantErr: `content_negotiation default "html" must be json, xml, yaml, or cbor`,
 		},
 		{
 			name: "empty default is valid",
@@ -1756,7 +2175,35 @@ func TestValidateSmallRouteFeatures_BackendEncoding(t *testing.T) {
 		{
 			name:    "invalid encoding",
 			route:   RouteConfig{ID: "r1", BackendEncoding: BackendEncodingConfig{Encoding: "protobuf"}},
-			wantErr: "backend_encoding encoding must be 'xml' or 'yaml'",
+			wantErr: "backend_encoding encoding must be 'xml', 'yaml', 'cbor' or 'avro'",
+		},
+		{
+			name:  "cbor is valid",
+			route: RouteConfig{ID: "r1", BackendEncoding: BackendEncodingConfig{Encoding: "cbor", MaxBodySize: 1 << 20}},
+		},
+		{
+			name:  "avro with registry is valid",
+			route: RouteConfig{ID: "r1", BackendEncoding: BackendEncodingConfig{Encoding: "avro", Avro: AvroSchemaConfig{RegistryURL: "http://registry:8081"}}},
+		},
+		{
+			name:    "avro without schema source",
+			route:   RouteConfig{ID: "r1", BackendEncoding: BackendEncodingConfig{Encoding: "avro"}},
+			wantErr: "backend_encoding avro requires exactly one of avro.schema_file or avro.registry_url",
+		},
+		{
+			name:    "avro with both schema sources",
+			route:   RouteConfig{ID: "r1", BackendEncoding: BackendEncodingConfig{Encoding: "avro", Avro: AvroSchemaConfig{SchemaFile: "s.avsc", RegistryURL: "http://registry:8081"}}},
+			wantErr: "backend_encoding avro requires exactly one of avro.schema_file or avro.registry_url",
+		},
+		{
+			name:    "avro registry without scheme",
+			route:   RouteConfig{ID: "r1", BackendEncoding: BackendEncodingConfig{Encoding: "avro", Avro: AvroSchemaConfig{RegistryURL: "registry:8081"}}},
+			wantErr: "backend_encoding avro.registry_url must start with http:// or https://",
+		},
+		{
+			name:    "negative max body size",
+			route:   RouteConfig{ID: "r1", BackendEncoding: BackendEncodingConfig{Encoding: "cbor", MaxBodySize: -1}},
+			wantErr: "backend_encoding.max_body_size must be >= 0",
 		},
 	}
 	for _, tt := range tests {
@@ -1869,6 +2316,39 @@ func TestValidateSmallRouteFeatures_Quota(t *testing.T) {
 	}
 }
 
+func TestValidateSmallRouteFeatures_BandwidthQuota(t *testing.T) {
+	l := NewLoader()
+	tests := []struct {
+		name    string
+		bq      BandwidthQuotaConfig
+		wantErr string
+	}{
+		{name: "disabled", bq: BandwidthQuotaConfig{Limit: -1}},
+		{name: "valid", bq: BandwidthQuotaConfig{Enabled: true, Limit: 1 << 30, Period: "monthly", Key: "ip"}},
+		{name: "valid log_only", bq: BandwidthQuotaConfig{Enabled: true, Limit: 1024, Period: "daily", Key: "client_id", Mode: "log_only"}},
+		{name: "zero limit", bq: BandwidthQuotaConfig{Enabled: true, Period: "daily", Key: "ip"}, wantErr: "bandwidth_quota.limit must be > 0"},
+		{name: "bad period", bq: BandwidthQuotaConfig{Enabled: true, Limit: 1, Period: "weekly", Key: "ip"}, wantErr: "bandwidth_quota.period must be"},
+		{name: "bad mode", bq: BandwidthQuotaConfig{Enabled: true, Limit: 1, Period: "daily", Key: "ip", Mode: "drop"}, wantErr: "bandwidth_quota.mode must be reject or log_only"},
+		{name: "missing key", bq: BandwidthQuotaConfig{Enabled: true, Limit: 1, Period: "daily"}, wantErr: "bandwidth_quota.key is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.validateSmallRouteFeatures(RouteConfig{ID: "r1", BandwidthQuota: tt.bq}, nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
 func TestValidateSmallRouteFeatures_ProxyRateLimit(t *testing.T) {
 	l := NewLoader()
 	tests := []struct {
@@ -1996,3 +2476,737 @@ func TestValidateSmallRouteFeatures_ClaimsPropagation(t *testing.T) {
 
 // Suppress unused import warnings for time (used in SSE tests).
 var _ = time.Second
+
+func TestValidateNetworkFeatures_WAF(t *testing.T) {
+	l := NewLoader()
+	tests := []struct {
+		name    string
+		waf     WAFConfig
+		wantErr string
+	}{
+		{
+			name: "valid shadow rule files",
+			waf:  WAFConfig{Enabled: true, RuleFiles: []string{"a.conf"}, ShadowRuleFiles: []string{"b.conf"}, ReloadInterval: 30 * time.Second},
+		},
+		{
+			name:    "invalid mode",
+			waf:     WAFConfig{Enabled: true, Mode: "audit"},
+			wantErr: "WAF mode must be 'block' or 'detect'",
+		},
+		{
+			name:    "negative reload interval",
+			waf:     WAFConfig{Enabled: true, ReloadInterval: -time.Second},
+			wantErr: "WAF reload_interval must be >= 0",
+		},
+		{
+			name:    "empty shadow rule file",
+			waf:     WAFConfig{Enabled: true, ShadowRuleFiles: []string{"b.conf", " "}},
+			wantErr: "WAF shadow_rule_files[1] must not be empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.validateNetworkFeatures(RouteConfig{ID: "r1", WAF: tt.waf}, nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateNetworkFeatures_WebSocket(t *testing.T) {
+	l := NewLoader()
+	fmv := func(c WebSocketMessageValidationConfig) WebSocketConfig {
+		c.Enabled = true
+		return WebSocketConfig{Enabled: true, FirstMessageValidation: c}
+	}
+	tests := []struct {
+		name    string
+		ws      WebSocketConfig
+		wantErr string
+	}{
+		{
+			name: "valid",
+			ws:   WebSocketConfig{Enabled: true, MaxMessageSize: 65536, FirstMessageValidation: WebSocketMessageValidationConfig{Enabled: true, Schema: `{}`, Messages: 2, Action: "log"}},
+		},
+		{
+			name:    "negative max_message_size",
+			ws:      WebSocketConfig{Enabled: true, MaxMessageSize: -1},
+			wantErr: "websocket max_message_size must be >= 0",
+		},
+		{
+			name:    "negative max_connections",
+			ws:      WebSocketConfig{Enabled: true, MaxConnections: -1},
+			wantErr: "websocket max_connections must be >= 0",
+		},
+		{
+			name: "valid subprotocols",
+			ws:   WebSocketConfig{Enabled: true, Subprotocols: []string{"graphql-transport-ws", "v2.chat"}},
+		},
+		{
+			name:    "subprotocol list",
+			ws:      WebSocketConfig{Enabled: true, Subprotocols: []string{"a, b"}},
+			wantErr: "must be a non-empty token",
+		},
+		{
+			name:    "duplicate subprotocol",
+			ws:      WebSocketConfig{Enabled: true, Subprotocols: []string{"chat", "chat"}},
+			wantErr: "listed twice",
+		},
+		{
+			name:    "missing schema",
+			ws:      fmv(WebSocketMessageValidationConfig{}),
+			wantErr: "exactly one of schema or schema_file",
+		},
+		{
+			name:    "both schemas",
+			ws:      fmv(WebSocketMessageValidationConfig{Schema: `{}`, SchemaFile: "s.json"}),
+			wantErr: "exactly one of schema or schema_file",
+		},
+		{
+			name:    "negative messages",
+			ws:      fmv(WebSocketMessageValidationConfig{Schema: `{}`, Messages: -1}),
+			wantErr: "messages must be >= 0",
+		},
+		{
+			name:    "invalid action",
+			ws:      fmv(WebSocketMessageValidationConfig{Schema: `{}`, Action: "drop"}),
+			wantErr: "action must be close or log",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.validateNetworkFeatures(RouteConfig{ID: "r1", WebSocket: tt.ws}, nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDelegatedMiddleware_ResponseFieldPolicy(t *testing.T) {
+	l := NewLoader()
+	tests := []struct {
+		name    string
+		policy  ResponseFieldPolicyConfig
+		wantErr string
+	}{
+		{
+			name: "valid",
+			policy: ResponseFieldPolicyConfig{Enabled: true, Rules: []ResponseFieldPolicyRule{
+				{ID: "free", When: `auth.claims.plan == "free"`, DenyFields: []string{"cost_breakdown", "items.#.internal_notes"}},
+			}},
+		},
+		{
+			name:    "no rules",
+			policy:  ResponseFieldPolicyConfig{Enabled: true},
+			wantErr: "response_field_policy requires at least one rule",
+		},
+		{
+			name:    "negative max body size",
+			policy:  ResponseFieldPolicyConfig{Enabled: true, MaxBodySize: -1, Rules: []ResponseFieldPolicyRule{{DenyFields: []string{"a"}}}},
+			wantErr: "response_field_policy.max_body_size must be >= 0",
+		},
+		{
+			name:    "rule without fields",
+			policy:  ResponseFieldPolicyConfig{Enabled: true, Rules: []ResponseFieldPolicyRule{{When: "true"}}},
+			wantErr: "requires allow_fields or deny_fields",
+		},
+		{
+			name: "duplicate id",
+			policy: ResponseFieldPolicyConfig{Enabled: true, Rules: []ResponseFieldPolicyRule{
+				{ID: "a", DenyFields: []string{"x"}},
+				{ID: "a", DenyFields: []string{"y"}},
+			}},
+			wantErr: `duplicate id "a"`,
+		},
+		{
+			name:    "invalid path",
+			policy:  ResponseFieldPolicyConfig{Enabled: true, Rules: []ResponseFieldPolicyRule{{AllowFields: []string{"items..sku"}}}},
+			wantErr: `invalid field path "items..sku"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.validateDelegatedMiddleware(RouteConfig{ID: "r1", ResponseFieldPolicy: tt.policy}, &Config{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDelegatedSecurity_ContentDedup(t *testing.T) {
+	l := NewLoader()
+	tests := []struct {
+		name      string
+		dedup     ContentDedupConfig
+		redisAddr string
+		wantErr   string
+	}{
+		{
+			name:  "valid",
+			dedup: ContentDedupConfig{Enabled: true, TTL: 5 * time.Second, OnDuplicate: "reject", Methods: []string{"POST"}},
+		},
+		{
+			name:      "distributed with redis",
+			dedup:     ContentDedupConfig{Enabled: true, Mode: "distributed"},
+			redisAddr: "localhost:6379",
+		},
+		{
+			name:    "distributed without redis",
+			dedup:   ContentDedupConfig{Enabled: true, Mode: "distributed"},
+			wantErr: `content_dedup.mode "distributed" requires redis.address`,
+		},
+		{
+			name:    "invalid on_duplicate",
+			dedup:   ContentDedupConfig{Enabled: true, OnDuplicate: "drop"},
+			wantErr: "content_dedup.on_duplicate must be",
+		},
+		{
+			name:    "safe method",
+			dedup:   ContentDedupConfig{Enabled: true, Methods: []string{"POST", "get"}},
+			wantErr: `content_dedup.methods must be mutation methods, got "get"`,
+		},
+		{
+			name:    "negative wait timeout",
+			dedup:   ContentDedupConfig{Enabled: true, WaitTimeout: -time.Second},
+			wantErr: "content_dedup.wait_timeout must be >= 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Redis: RedisConfig{Address: tt.redisAddr}}
+			err := l.validateDelegatedSecurity(RouteConfig{ID: "r1", ContentDedup: tt.dedup}, cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateResilienceFeatures_ABTestAssignment(t *testing.T) {
+	l := NewLoader()
+	split := []TrafficSplitConfig{{Name: "a", Weight: 50}, {Name: "b", Weight: 50}}
+	tests := []struct {
+		name    string
+		ab      ABTestConfig
+		redis   string
+		wantErr string
+	}{
+		{name: "assignment disabled", ab: ABTestConfig{Assignment: ABTestAssignmentConfig{Key: "bogus"}}},
+		{name: "valid defaults", ab: ABTestConfig{Assignment: ABTestAssignmentConfig{Enabled: true}}},
+		{name: "valid redis", ab: ABTestConfig{Assignment: ABTestAssignmentConfig{Enabled: true, Key: "jwt_claim:sub", Store: "redis"}}, redis: "localhost:6379"},
+		{name: "valid exposure", ab: ABTestConfig{Assignment: ABTestAssignmentConfig{Enabled: true, Key: "cookie:uid"}, Exposure: ABTestExposureConfig{Enabled: true, SampleRate: 0.1}}},
+		{name: "bad key", ab: ABTestConfig{Assignment: ABTestAssignmentConfig{Enabled: true, Key: "header:"}}, wantErr: "invalid ab_test.assignment.key"},
+		{name: "bad store", ab: ABTestConfig{Assignment: ABTestAssignmentConfig{Enabled: true, Store: "memcached"}}, wantErr: "ab_test.assignment.store must be"},
+		{name: "redis without address", ab: ABTestConfig{Assignment: ABTestAssignmentConfig{Enabled: true, Store: "redis"}}, wantErr: "requires redis.address"},
+		{name: "negative ttl", ab: ABTestConfig{Assignment: ABTestAssignmentConfig{Enabled: true, TTL: -1}}, wantErr: "ab_test.assignment.ttl must be >= 0"},
+		{name: "exposure without assignment", ab: ABTestConfig{Exposure: ABTestExposureConfig{Enabled: true}}, wantErr: "ab_test.exposure requires ab_test.assignment"},
+		{name: "bad sample rate", ab: ABTestConfig{Assignment: ABTestAssignmentConfig{Enabled: true}, Exposure: ABTestExposureConfig{Enabled: true, SampleRate: 1.5}}, wantErr: "ab_test.exposure.sample_rate must be 0.0-1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.ab.Enabled = true
+			tt.ab.ExperimentName = "exp"
+			cfg := &Config{Redis: RedisConfig{Address: tt.redis}}
+			err := l.validateResilienceFeatures(RouteConfig{ID: "r1", TrafficSplit: split, ABTest: tt.ab}, cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRateLimiting_CostSource(t *testing.T) {
+	l := NewLoader()
+	tests := []struct {
+		name    string
+		route   RouteConfig
+		wantErr string
+	}{
+		{name: "per request", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10}}},
+		{name: "fixed", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10, CostSource: "fixed", Cost: 5, MaxCost: 8}}},
+		{name: "request cost", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10, CostSource: "request_cost"}}},
+		{name: "graphql", route: RouteConfig{GraphQL: GraphQLConfig{Enabled: true}, RateLimit: RateLimitConfig{Rate: 10, CostSource: "graphql_complexity"}}},
+		{name: "openapi", route: RouteConfig{OpenAPI: OpenAPIRouteConfig{SpecFile: "api.yaml", OperationID: "report"}, RateLimit: RateLimitConfig{Rate: 10, CostSource: "openapi_weight", OperationWeights: map[string]int{"report": 5}}}},
+		{name: "unknown source", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10, CostSource: "bytes"}}, wantErr: "invalid rate_limit.cost_source"},
+		{name: "graphql not enabled", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10, CostSource: "graphql_complexity"}}, wantErr: "requires graphql.enabled"},
+		{name: "openapi without spec", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10, CostSource: "openapi_weight"}}, wantErr: "requires openapi.spec_file"},
+		{name: "max cost without source", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10, MaxCost: 5}}, wantErr: "require rate_limit.cost_source"},
+		{name: "cost with other source", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10, CostSource: "request_cost", Cost: 3}}, wantErr: "rate_limit.cost only applies"},
+		{name: "negative max cost", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10, CostSource: "fixed", MaxCost: -1}}, wantErr: "rate_limit.max_cost must be >= 0"},
+		{name: "weights with other source", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10, CostSource: "fixed", OperationWeights: map[string]int{"a": 2}}}, wantErr: "operation_weights only applies"},
+		{name: "zero weight", route: RouteConfig{OpenAPI: OpenAPIRouteConfig{SpecFile: "api.yaml"}, RateLimit: RateLimitConfig{Rate: 10, CostSource: "openapi_weight", OperationWeights: map[string]int{"a": 0}}}, wantErr: "operation_weights[a] must be > 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.route.ID = "r1"
+			err := l.validateRateLimiting(tt.route, &Config{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRateLimiting_Headers(t *testing.T) {
+	l := NewLoader()
+	off := false
+	tests := []struct {
+		name    string
+		route   RouteConfig
+		wantErr string
+	}{
+		{name: "default", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10}}},
+		{name: "legacy", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10, LegacyHeaders: true}}},
+		{name: "disabled", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10, Headers: &off}}},
+		{name: "legacy without headers", route: RouteConfig{RateLimit: RateLimitConfig{Rate: 10, Headers: &off, LegacyHeaders: true}}, wantErr: "rate_limit.legacy_headers requires rate_limit.headers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.route.ID = "r1"
+			err := l.validateRateLimiting(tt.route, &Config{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConcurrencyLimit(t *testing.T) {
+	l := NewLoader()
+	withRedis := &Config{Redis: RedisConfig{Address: "localhost:6379"}}
+	tests := []struct {
+		name    string
+		cl      ConcurrencyLimitConfig
+		cfg     *Config
+		wantErr string
+	}{
+		{name: "disabled", cl: ConcurrencyLimitConfig{MaxInFlight: -1}},
+		{name: "local", cl: ConcurrencyLimitConfig{Enabled: true, MaxInFlight: 5, Key: "header:X-API-Key", MaxWait: time.Second}},
+		{name: "distributed", cl: ConcurrencyLimitConfig{Enabled: true, MaxInFlight: 5, Mode: "distributed"}, cfg: withRedis},
+		{name: "zero max", cl: ConcurrencyLimitConfig{Enabled: true}, wantErr: "max_in_flight must be > 0"},
+		{name: "bad key", cl: ConcurrencyLimitConfig{Enabled: true, MaxInFlight: 5, Key: "header:"}, wantErr: "concurrency_limit.key \"header:\" requires a non-empty header name"},
+		{name: "unknown key", cl: ConcurrencyLimitConfig{Enabled: true, MaxInFlight: 5, Key: "user"}, wantErr: "invalid concurrency_limit.key"},
+		{name: "negative wait", cl: ConcurrencyLimitConfig{Enabled: true, MaxInFlight: 5, MaxWait: -1}, wantErr: "must be >= 0"},
+		{name: "unknown mode", cl: ConcurrencyLimitConfig{Enabled: true, MaxInFlight: 5, Mode: "global"}, wantErr: "concurrency_limit.mode must be"},
+		{name: "distributed without redis", cl: ConcurrencyLimitConfig{Enabled: true, MaxInFlight: 5, Mode: "distributed"}, wantErr: "requires redis.address"},
+		{name: "distributed with wait", cl: ConcurrencyLimitConfig{Enabled: true, MaxInFlight: 5, Mode: "distributed", MaxWait: time.Second}, cfg: withRedis, wantErr: "max_wait is not supported in distributed mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if cfg == nil {
+				cfg = &Config{}
+			}
+			err := l.validateConcurrencyLimit(RouteConfig{ID: "r1", ConcurrencyLimit: tt.cl}, cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateXSDAndXMLLimits(t *testing.T) {
+	l := NewLoader()
+	tests := []struct {
+		name    string
+		v       ValidationConfig
+		wantErr string
+	}{
+		{name: "xsd file", v: ValidationConfig{Enabled: true, XSDFile: "order.xsd"}},
+		{name: "both request", v: ValidationConfig{Enabled: true, XSD: "<xs:schema/>", XSDFile: "order.xsd"}, wantErr: "xsd and xsd_file are mutually exclusive"},
+		{name: "both response", v: ValidationConfig{Enabled: true, ResponseXSD: "<xs:schema/>", ResponseXSDFile: "order.xsd"}, wantErr: "response_xsd and response_xsd_file are mutually exclusive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.validateTransformsAndValidation(RouteConfig{ID: "r1", Validation: tt.v}, &Config{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := validateXMLLimits(XMLLimitsConfig{MaxDepth: 50}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateXMLLimits(XMLLimitsConfig{MaxEntities: -1}); err == nil {
+		t.Error("expected error for negative limit")
+	}
+}
+
+func TestValidateRouteMetadata(t *testing.T) {
+	l := NewLoader()
+	many := make(map[string]string, MaxRouteMetadataKeys+1)
+	for i := 0; i <= MaxRouteMetadataKeys; i++ {
+		many["k"+strconv.Itoa(i)] = "v"
+	}
+	tests := []struct {
+		name    string
+		route   RouteConfig
+		wantErr string
+	}{
+		{name: "valid", route: RouteConfig{Metadata: map[string]string{"team": "payments", "cost_center": "42"}, ExposeMetadataHeaders: []string{"team"}}},
+		{name: "too many keys", route: RouteConfig{Metadata: many}, wantErr: "metadata has 33 keys (max 32)"},
+		{name: "bad key", route: RouteConfig{Metadata: map[string]string{"cost-center": "42"}}, wantErr: `metadata key "cost-center" must match`},
+		{name: "long value", route: RouteConfig{Metadata: map[string]string{"runbook": strings.Repeat("x", MaxRouteMetadataValueLength+1)}}, wantErr: "metadata.runbook exceeds 256 bytes"},
+		{name: "unknown header key", route: RouteConfig{Metadata: map[string]string{"team": "a"}, ExposeMetadataHeaders: []string{"tier"}}, wantErr: `expose_metadata_headers key "tier" is not in metadata`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.route.ID = "r1"
+			err := l.validateRouteMetadata(tt.route, &Config{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := validateRouteMetadataConfig(RouteMetadataConfig{LogKeys: []string{"team"}, MetricLabels: []string{"team", "tier"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, c := range []RouteMetadataConfig{
+		{LogKeys: []string{"team", "team"}},
+		{MetricLabels: []string{"route"}},
+		{MetricLabels: []string{"a.b"}},
+	} {
+		if err := validateRouteMetadataConfig(c); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+}
+
+func TestValidateRouteListeners(t *testing.T) {
+	l := NewLoader()
+	cfg := &Config{Listeners: []ListenerConfig{
+		{ID: "public", Protocol: ProtocolHTTP},
+		{ID: "internal", Protocol: ProtocolHTTP},
+		{ID: "db", Protocol: ProtocolTCP},
+	}}
+	tests := []struct {
+		listeners []string
+		wantErr   string
+	}{
+		{listeners: nil},
+		{listeners: []string{"public", "internal"}},
+		{listeners: []string{"edge"}, wantErr: `route r1: listeners: unknown listener "edge"`},
+		{listeners: []string{"db"}, wantErr: `listener "db" is not an http listener`},
+		{listeners: []string{"public", "public"}, wantErr: `duplicate listener "public"`},
+	}
+	for _, tt := range tests {
+		err := l.validateRouteListeners(RouteConfig{ID: "r1", Listeners: tt.listeners}, cfg)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error: %v", tt.listeners, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%v: error %v should contain %q", tt.listeners, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateKafka(t *testing.T) {
+	l := NewLoader()
+	base := func(mut func(*RouteConfig)) RouteConfig {
+		r := RouteConfig{ID: "r1", Kafka: KafkaConfig{Enabled: true, Brokers: []string{"kafka-1:9092"}, Topic: "orders"}}
+		if mut != nil {
+			mut(&r)
+		}
+		return r
+	}
+	tests := []struct {
+		name    string
+		route   RouteConfig
+		wantErr string
+	}{
+		{name: "minimal", route: base(nil)},
+		{name: "full", route: base(func(r *RouteConfig) {
+			r.Kafka.Key = "{{.Request.PathParams.tenant}}-{{.Claims.sub}}"
+			r.Kafka.Acks = "leader"
+			r.Kafka.Headers = []string{"X-Request-Id"}
+			r.Kafka.TLS = KafkaTLSConfig{Enabled: true, CertFile: "c.pem", KeyFile: "k.pem"}
+			r.Kafka.SASL = KafkaSASLConfig{Mechanism: "SCRAM-SHA-512", Username: "u", Password: "p"}
+		})},
+		{name: "no brokers", route: base(func(r *RouteConfig) { r.Kafka.Brokers = nil }), wantErr: "kafka.brokers is required"},
+		{name: "broker without port", route: base(func(r *RouteConfig) { r.Kafka.Brokers = []string{"kafka-1"} }), wantErr: "must be host:port"},
+		{name: "no topic", route: base(func(r *RouteConfig) { r.Kafka.Topic = "" }), wantErr: "kafka.topic is required"},
+		{name: "bad acks", route: base(func(r *RouteConfig) { r.Kafka.Acks = "1" }), wantErr: "kafka.acks must be"},
+		{name: "bad key", route: base(func(r *RouteConfig) { r.Kafka.Key = "{{.Request" }), wantErr: "kafka.key template is invalid"},
+		{name: "empty header", route: base(func(r *RouteConfig) { r.Kafka.Headers = []string{""} }), wantErr: "kafka.headers must not contain empty names"},
+		{name: "negative timeout", route: base(func(r *RouteConfig) { r.Kafka.Timeout = -time.Second }), wantErr: "kafka.timeout must be >= 0"},
+		{name: "cert without key", route: base(func(r *RouteConfig) { r.Kafka.TLS.CertFile = "c.pem" }), wantErr: "must be set together"},
+		{name: "bad mechanism", route: base(func(r *RouteConfig) { r.Kafka.SASL.Mechanism = "GSSAPI" }), wantErr: "kafka.sasl.mechanism must be"},
+		{name: "sasl without password", route: base(func(r *RouteConfig) { r.Kafka.SASL = KafkaSASLConfig{Mechanism: "PLAIN", Username: "u"} }), wantErr: "requires username and password"},
+		{name: "with backends", route: base(func(r *RouteConfig) { r.Backends = []BackendConfig{{URL: "http://b"}} }), wantErr: "mutually exclusive with backends"},
+		{name: "with amqp", route: base(func(r *RouteConfig) { r.AMQP.Enabled = true }), wantErr: "mutually exclusive with amqp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.validateKafka(tt.route, &Config{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDecisionLog(t *testing.T) {
+	l := NewLoader()
+	base := func(mut func(*RouteConfig)) RouteConfig {
+		r := RouteConfig{ID: "r1", DecisionLog: DecisionLogConfig{Enabled: true, File: "/var/log/runway/decisions.log"}}
+		if mut != nil {
+			mut(&r)
+		}
+		return r
+	}
+	webhook := &Config{AuditLog: AuditLogConfig{WebhookURL: "https://audit.example.com"}}
+	tests := []struct {
+		name    string
+		route   RouteConfig
+		cfg     *Config
+		wantErr string
+	}{
+		{name: "file sink", route: base(nil)},
+		{name: "disabled without file", route: RouteConfig{ID: "r1"}},
+		{name: "audit webhook from global", route: base(func(r *RouteConfig) {
+			r.DecisionLog = DecisionLogConfig{Enabled: true, Sink: "audit_webhook"}
+		}), cfg: webhook},
+		{name: "audit webhook from route", route: base(func(r *RouteConfig) {
+			r.DecisionLog = DecisionLogConfig{Enabled: true, Sink: "audit_webhook"}
+			r.AuditLog.WebhookURL = "https://audit.example.com"
+		})},
+		{name: "no file", route: base(func(r *RouteConfig) { r.DecisionLog.File = "" }), wantErr: "decision_log.file is required"},
+		{name: "audit webhook without url", route: base(func(r *RouteConfig) { r.DecisionLog.Sink = "audit_webhook" }), wantErr: "requires audit_log.webhook_url"},
+		{name: "bad sink", route: base(func(r *RouteConfig) { r.DecisionLog.Sink = "kafka" }), wantErr: "decision_log.sink must be"},
+		{name: "negative max entries", route: base(func(r *RouteConfig) { r.DecisionLog.MaxEntries = -1 }), wantErr: "decision_log.max_entries must be >= 0"},
+		{name: "negative max value length", route: base(func(r *RouteConfig) { r.DecisionLog.MaxValueLength = -1 }), wantErr: "decision_log.max_value_length must be >= 0"},
+		{name: "negative buffer size", route: base(func(r *RouteConfig) { r.DecisionLog.BufferSize = -1 }), wantErr: "decision_log.buffer_size must be >= 0"},
+		{name: "negative rotation", route: base(func(r *RouteConfig) { r.DecisionLog.Rotation.MaxAge = -1 }), wantErr: "decision_log.rotation values must be >= 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if cfg == nil {
+				cfg = &Config{}
+			}
+			err := l.validateDecisionLog(tt.route, cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCachePrime(t *testing.T) {
+	l := NewLoader()
+	base := func(mut func(*RouteConfig)) RouteConfig {
+		r := RouteConfig{ID: "r1", Cache: CacheConfig{Enabled: true, Prime: CachePrimeConfig{Enabled: true, Manifest: "/etc/runway/prime.json"}}}
+		if mut != nil {
+			mut(&r)
+		}
+		return r
+	}
+	bucket := func(id string, prime bool) RouteConfig {
+		return RouteConfig{ID: id, Cache: CacheConfig{Enabled: true, Bucket: "pages", Prime: CachePrimeConfig{Enabled: prime, Manifest: "/etc/runway/prime.json"}}}
+	}
+	tests := []struct {
+		name    string
+		route   RouteConfig
+		cfg     *Config
+		wantErr string
+	}{
+		{name: "file manifest", route: base(nil)},
+		{name: "url manifest", route: base(func(r *RouteConfig) { r.Cache.Prime.Manifest = "https://cdn.example.com/prime.json" })},
+		{name: "full", route: base(func(r *RouteConfig) {
+			r.Cache.Prime.Concurrency = 8
+			r.Cache.Prime.Rate = 50
+			r.Cache.Prime.Budget = 2 * time.Minute
+			r.Cache.Prime.Timeout = 5 * time.Second
+			r.Cache.Prime.ReadyPercent = 90
+		})},
+		{name: "disabled without manifest", route: RouteConfig{ID: "r1", Cache: CacheConfig{Enabled: true}}},
+		{name: "first route priming a bucket", route: bucket("a", true), cfg: &Config{Routes: []RouteConfig{bucket("a", true), bucket("b", false)}}},
+		{name: "cache disabled", route: base(func(r *RouteConfig) { r.Cache.Enabled = false }), wantErr: "cache.prime requires cache.enabled"},
+		{name: "no manifest", route: base(func(r *RouteConfig) { r.Cache.Prime.Manifest = "" }), wantErr: "cache.prime.manifest is required"},
+		{name: "bad scheme", route: base(func(r *RouteConfig) { r.Cache.Prime.Manifest = "s3://bucket/prime.json" }), wantErr: "must be a file path or an http(s) URL"},
+		{name: "negative concurrency", route: base(func(r *RouteConfig) { r.Cache.Prime.Concurrency = -1 }), wantErr: "cache.prime.concurrency must be >= 0"},
+		{name: "negative rate", route: base(func(r *RouteConfig) { r.Cache.Prime.Rate = -1 }), wantErr: "cache.prime.rate must be >= 0"},
+		{name: "negative budget", route: base(func(r *RouteConfig) { r.Cache.Prime.Budget = -time.Second }), wantErr: "budget and timeout must be >= 0"},
+		{name: "ready percent over 100", route: base(func(r *RouteConfig) { r.Cache.Prime.ReadyPercent = 101 }), wantErr: "ready_percent must be between 0 and 100"},
+		{name: "bucket primed twice", route: bucket("b", true), cfg: &Config{Routes: []RouteConfig{bucket("a", true), bucket("b", true)}}, wantErr: `cache bucket "pages" is already primed by route a`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if cfg == nil {
+				cfg = &Config{}
+			}
+			err := l.validateCachePrime(tt.route, cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCopyRequestPolicy(t *testing.T) {
+	l := NewLoader()
+	providers := map[string]BackendAuthConfig{"partner": {Type: "oauth2_client_credentials", TokenURL: "https://idp.example.com/token"}}
+	mirror := func(p CopyRequestPolicy) RouteConfig {
+		return RouteConfig{ID: "r1", Mirror: MirrorConfig{Enabled: true, CopyRequestPolicy: p}}
+	}
+	aggregate := func(mut func(*AggregateBackend)) RouteConfig {
+		b := AggregateBackend{Name: "users", URL: "http://users"}
+		mut(&b)
+		return RouteConfig{ID: "r1", Aggregate: AggregateConfig{Enabled: true, Backends: []AggregateBackend{b, {Name: "stats", URL: "http://stats"}}}}
+	}
+	tests := []struct {
+		name    string
+		route   RouteConfig
+		wantErr string
+	}{
+		{name: "mirror policy", route: mirror(CopyRequestPolicy{
+			StripHeaders:       []string{"Authorization"},
+			SetHeaders:         map[string]string{"X-Service-Token": `{{ secret "env:MIRROR_TOKEN" }}`, "X-Request": `{{ .Var "request_id" }}`},
+			ForwardQueryParams: QueryParamFilter{Deny: []string{"api_key"}},
+		})},
+		{name: "aggregate policy with auth", route: aggregate(func(b *AggregateBackend) {
+			b.SetHeaders = map[string]string{"X-Tenant": "{{ .Headers.Get \"X-Tenant\" }}"}
+			b.ForwardQueryParams = QueryParamFilter{Allow: []string{"lang"}}
+			b.BackendAuth = BackendAuthConfig{Ref: "partner"}
+		})},
+		{name: "disabled mirror", route: RouteConfig{ID: "r1", Mirror: MirrorConfig{CopyRequestPolicy: CopyRequestPolicy{StripHeaders: []string{""}}}}},
+		{name: "bad strip header", route: mirror(CopyRequestPolicy{StripHeaders: []string{"X Auth"}}), wantErr: `route r1: mirror: strip_headers: invalid header name "X Auth"`},
+		{name: "bad set header", route: mirror(CopyRequestPolicy{SetHeaders: map[string]string{"X:A": "1"}}), wantErr: "set_headers: invalid header name"},
+		{name: "set host", route: mirror(CopyRequestPolicy{SetHeaders: map[string]string{"host": "other"}}), wantErr: "set_headers cannot set Host"},
+		{name: "bad template", route: mirror(CopyRequestPolicy{SetHeaders: map[string]string{"X-A": "{{ .Method "}}), wantErr: "set_headers X-A: invalid template"},
+		{name: "allow and deny", route: mirror(CopyRequestPolicy{ForwardQueryParams: QueryParamFilter{Allow: []string{"a"}, Deny: []string{"b"}}}), wantErr: "allow and deny are mutually exclusive"},
+		{name: "empty query param", route: aggregate(func(b *AggregateBackend) { b.ForwardQueryParams.Deny = []string{""} }), wantErr: "route r1: aggregate backend users: forward_query_params entries must be non-empty"},
+		{name: "auth without ref", route: aggregate(func(b *AggregateBackend) { b.BackendAuth = BackendAuthConfig{Enabled: true} }), wantErr: "backend_auth requires ref"},
+		{name: "unknown ref", route: aggregate(func(b *AggregateBackend) { b.BackendAuth.Ref = "other" }), wantErr: `backend_auth ref "other" not found`},
+		{name: "ref with fields", route: aggregate(func(b *AggregateBackend) { b.BackendAuth = BackendAuthConfig{Ref: "partner", ClientID: "x"} }), wantErr: "backend_auth with ref cannot set other fields"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{BackendAuthProviders: providers, Routes: []RouteConfig{tt.route}}
+			err := l.validateMirrorAndCORS(tt.route, cfg)
+			if err == nil {
+				err = l.validateAggregateProxy(tt.route, cfg)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v should contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -539,6 +539,28 @@ func TestValidateTokenExchangeConfig_Errors(t *testing.T) {
 			},
 			wantErr: "token_exchange.jwks_url is required for jwt validation mode",
 		},
+		{
+			name: "jwks_url and jwks_urls both set",
+			modify: func(r *RouteConfig) {
+				r.TokenExchange.JWKSURLs = []string{"https://mirror.example.com/jwks.json"}
+			},
+			wantErr: "token_exchange: jwks_url and jwks_urls are mutually exclusive",
+		},
+		{
+			name: "duplicate jwks_urls",
+			modify: func(r *RouteConfig) {
+				r.TokenExchange.JWKSURL = ""
+				r.TokenExchange.JWKSURLs = []string{"https://a.example.com/jwks.json", "https://a.example.com/jwks.json"}
+			},
+			wantErr: `token_exchange: jwks_urls: duplicate URL "https://a.example.com/jwks.json"`,
+		},
+		{
+			name: "negative jwks_fetch_timeout",
+			modify: func(r *RouteConfig) {
+				r.TokenExchange.JWKSFetchTimeout = -time.Second
+			},
+			wantErr: "token_exchange: jwks_fetch_timeout must be >= 0",
+		},
 		{
 			name: "jwt mode missing trusted_issuers",
 			modify: func(r *RouteConfig) {
@@ -996,6 +1018,36 @@ func TestValidateBaggageConfig_TagErrors(t *testing.T) {
 			global:  &Config{},
 			wantErr: "baggage.tags[0].source must start with header:, jwt_claim:, query:, cookie:, or static:",
 		},
+		{
+			name: "negative max_members",
+			cfg: BaggageConfig{
+				Enabled:    true,
+				MaxMembers: -1,
+				Tags:       []BaggageTagDef{{Name: "foo", Source: "header:X-Foo", Header: "X-Out"}},
+			},
+			global:  &Config{},
+			wantErr: "baggage.max_members must be >= 0",
+		},
+		{
+			name: "negative max_bytes",
+			cfg: BaggageConfig{
+				Enabled:  true,
+				MaxBytes: -1,
+				Tags:     []BaggageTagDef{{Name: "foo", Source: "header:X-Foo", Header: "X-Out"}},
+			},
+			global:  &Config{},
+			wantErr: "baggage.max_bytes must be >= 0",
+		},
+		{
+			name: "invalid conflict_policy",
+			cfg: BaggageConfig{
+				Enabled:        true,
+				ConflictPolicy: "merge",
+				Tags:           []BaggageTagDef{{Name: "foo", Source: "header:X-Foo", Header: "X-Out"}},
+			},
+			global:  &Config{},
+			wantErr: "baggage.conflict_policy must be \"local\" or \"inbound\"",
+		},
 	}
 
 	l := NewLoader()
@@ -1401,3 +1453,214 @@ func TestValidateOutlierDetection_FirstErrorReturned(t *testing.T) {
 		t.Errorf("expected first error about interval, got: %v", err)
 	}
 }
+
+// --- validateAccessLog ---
+
+func TestValidateAccessLog_LevelAndSampling(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AccessLogConfig
+		wantErr string
+	}{
+		{
+			name: "valid level and sampling",
+			cfg: AccessLogConfig{
+				Level:    "warn",
+				Sampling: AccessLogSampling{Enabled: true, Threshold: 100, Burst: 200, Ratio: 0.05},
+			},
+		},
+		{
+			name:    "unknown level",
+			cfg:     AccessLogConfig{Level: "debug"},
+			wantErr: "access_log level must be info, warn or error",
+		},
+		{
+			name:    "missing threshold",
+			cfg:     AccessLogConfig{Sampling: AccessLogSampling{Enabled: true}},
+			wantErr: "access_log sampling.threshold must be > 0",
+		},
+		{
+			name:    "negative burst",
+			cfg:     AccessLogConfig{Sampling: AccessLogSampling{Enabled: true, Threshold: 10, Burst: -1}},
+			wantErr: "access_log sampling.burst must be >= 0",
+		},
+		{
+			name:    "ratio above one",
+			cfg:     AccessLogConfig{Sampling: AccessLogSampling{Enabled: true, Threshold: 10, Ratio: 1.5}},
+			wantErr: "access_log sampling.ratio must be between 0.0 and 1.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewLoader().validateAccessLog("r1", tt.cfg, "")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateAccessLogFields(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          AccessLogConfig
+		globalFormat string
+		wantErr      string
+	}{
+		{name: "route json", cfg: AccessLogConfig{Format: "json", Fields: []string{"time", "status", "jwt_sub"}}},
+		{name: "inherited json", cfg: AccessLogConfig{Fields: []string{"path"}}, globalFormat: "json"},
+		{
+			name:    "unknown field",
+			cfg:     AccessLogConfig{Format: "json", Fields: []string{"status", "latency"}},
+			wantErr: `access_log fields: unknown field "latency"`,
+		},
+		{
+			name:    "duplicate field",
+			cfg:     AccessLogConfig{Format: "json", Fields: []string{"status", "status"}},
+			wantErr: `duplicate field "status"`,
+		},
+		{
+			name:         "variable format",
+			cfg:          AccessLogConfig{Format: "$status", Fields: []string{"status"}},
+			globalFormat: "json",
+			wantErr:      "access_log fields requires format json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewLoader().validateAccessLog("r1", tt.cfg, tt.globalFormat)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	base := "listeners:\n  - id: http\n    address: \":8080\"\n    protocol: http\n"
+	if _, err := NewLoader().Parse([]byte(base + "logging:\n  format: json\n  fields: [time, bytes]\n")); err == nil || !strings.Contains(err.Error(), `logging.fields: unknown field "bytes"`) {
+		t.Errorf("expected an unknown field error, got %v", err)
+	}
+	if _, err := NewLoader().Parse([]byte(base + "logging:\n  fields: [time]\n")); err == nil || !strings.Contains(err.Error(), "requires logging.format json") {
+		t.Errorf("expected a format error, got %v", err)
+	}
+	if _, err := NewLoader().Parse([]byte(base + "logging:\n  format: json\n  fields: [time, status]\n")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateSyntheticMonitoringConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SyntheticMonitoringConfig
+		wantErr string
+	}{
+		{
+			name: "disabled is not checked",
+			cfg:  SyntheticMonitoringConfig{SourceCIDRs: []string{"bogus"}},
+		},
+		{
+			name: "valid secret and cidrs",
+			cfg: SyntheticMonitoringConfig{
+				Enabled:     true,
+				Secret:      "s3cret",
+				SourceCIDRs: []string{"10.0.0.0/8", "2001:db8::/32"},
+			},
+		},
+		{
+			name:    "no identification method",
+			cfg:     SyntheticMonitoringConfig{Enabled: true},
+			wantErr: "synthetic_monitoring requires secret or source_cidrs",
+		},
+		{
+			name:    "invalid cidr",
+			cfg:     SyntheticMonitoringConfig{Enabled: true, SourceCIDRs: []string{"10.0.0.1"}},
+			wantErr: "synthetic_monitoring.source_cidrs: invalid CIDR",
+		},
+		{
+			name:    "negative skew",
+			cfg:     SyntheticMonitoringConfig{Enabled: true, Secret: "s", MaxSkew: -time.Second},
+			wantErr: "synthetic_monitoring.max_skew must be >= 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewLoader().validateSyntheticMonitoringConfig("r1", tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateServiceDiscovery(t *testing.T) {
+	zoned := &Config{Registry: RegistryConfig{LocalZone: "us-east-1a"}}
+	tests := []struct {
+		name    string
+		svc     ServiceConfig
+		cfg     *Config
+		wantErr string
+	}{
+		{
+			name: "meta filter",
+			svc:  ServiceConfig{Name: "users", Meta: map[string]string{"version": "v2"}},
+			cfg:  &Config{},
+		},
+		{
+			name:    "empty meta key",
+			svc:     ServiceConfig{Name: "users", Meta: map[string]string{"": "v2"}},
+			cfg:     &Config{},
+			wantErr: "route r1: service.meta keys must not be empty",
+		},
+		{
+			name: "locality aware with local zone",
+			svc:  ServiceConfig{Name: "users", LocalityAware: true, ZoneMetaKey: "az"},
+			cfg:  zoned,
+		},
+		{
+			name:    "locality aware without local zone",
+			svc:     ServiceConfig{Name: "users", LocalityAware: true},
+			cfg:     &Config{},
+			wantErr: "route r1: service.locality_aware requires registry.local_zone",
+		},
+		{
+			name:    "locality aware without service name",
+			svc:     ServiceConfig{LocalityAware: true},
+			cfg:     zoned,
+			wantErr: "route r1: service.locality_aware requires service.name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateServiceDiscovery("route r1", tt.svc, tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,13 +1,16 @@
 package abtest
 
 import (
+	"net/http"
 	"sync"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/internal/canary"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/middleware"
 )
 
 // ABTest collects per-traffic-group metrics for a running experiment.
@@ -18,10 +21,14 @@ type ABTest struct {
 
 	mu      sync.RWMutex
 	metrics map[string]*canary.GroupMetrics
+
+	assigner   *assigner // nil unless assignment is enabled
+	onExposure func(r *http.Request, routeID string, data map[string]interface{})
 }
 
-// New creates a new ABTest.
-func New(routeID string, cfg config.ABTestConfig, wb *loadbalancer.WeightedBalancer) *ABTest {
+// New creates a new ABTest. The redis client is only used when the
+// assignment store is "redis".
+func New(routeID string, cfg config.ABTestConfig, wb *loadbalancer.WeightedBalancer, redisClient *redis.Client) *ABTest {
 	ab := &ABTest{
 		routeID:        routeID,
 		experimentName: cfg.ExperimentName,
@@ -32,9 +39,21 @@ func New(routeID string, cfg config.ABTestConfig, wb *loadbalancer.WeightedBalan
 	for _, g := range wb.GetGroups() {
 		ab.metrics[g.Name] = canary.NewGroupMetrics()
 	}
+	if cfg.Assignment.Enabled {
+		ab.assigner = newAssigner(routeID, cfg, wb, redisClient)
+	}
 	return ab
 }
 
+// Middleware returns the variant assignment middleware, or nil when
+// assignment is not enabled.
+func (ab *ABTest) Middleware() middleware.Middleware {
+	if ab.assigner == nil {
+		return nil
+	}
+	return ab.assignmentMiddleware()
+}
+
 // RecordRequest records a request outcome for a traffic group.
 func (ab *ABTest) RecordRequest(group string, statusCode int, latency time.Duration) {
 	ab.mu.RLock()
@@ -53,6 +72,9 @@ func (ab *ABTest) Reset() {
 	for _, gm := range ab.metrics {
 		gm.Reset()
 	}
+	if ab.assigner != nil {
+		ab.assigner.reset()
+	}
 	ab.startedAt = time.Now()
 }
 
@@ -63,6 +85,7 @@ type ABTestSnapshot struct {
 	StartedAt      time.Time                       `json:"started_at"`
 	DurationSec    float64                         `json:"duration_sec"`
 	Groups         map[string]canary.GroupSnapshot  `json:"groups"`
+	Assignment     *AssignmentSnapshot              `json:"assignment,omitempty"`
 }
 
 // Snapshot returns a point-in-time view of the A/B test metrics.
@@ -73,28 +96,46 @@ func (ab *ABTest) Snapshot() ABTestSnapshot {
 	for name, gm := range ab.metrics {
 		groups[name] = gm.Snapshot()
 	}
-	return ABTestSnapshot{
+	snap := ABTestSnapshot{
 		RouteID:        ab.routeID,
 		ExperimentName: ab.experimentName,
 		StartedAt:      ab.startedAt,
 		DurationSec:    time.Since(ab.startedAt).Seconds(),
 		Groups:         groups,
 	}
+	if ab.assigner != nil {
+		snap.Assignment = ab.assigner.snapshot()
+	}
+	return snap
 }
 
 // ABTestByRoute manages per-route A/B tests.
 type ABTestByRoute struct {
 	byroute.Manager[*ABTest]
+	redisClient *redis.Client
+	exposureMu  sync.RWMutex
+	onExposure  func(r *http.Request, routeID string, data map[string]interface{})
 }
 
 // NewABTestByRoute creates a new ABTestByRoute.
-func NewABTestByRoute() *ABTestByRoute {
-	return &ABTestByRoute{}
+func NewABTestByRoute(redisClient *redis.Client) *ABTestByRoute {
+	return &ABTestByRoute{redisClient: redisClient}
+}
+
+// SetOnExposure registers a callback invoked for each sampled exposure.
+func (m *ABTestByRoute) SetOnExposure(cb func(r *http.Request, routeID string, data map[string]interface{})) {
+	m.exposureMu.Lock()
+	defer m.exposureMu.Unlock()
+	m.onExposure = cb
 }
 
 // AddRoute creates and stores an A/B test for a route.
 func (m *ABTestByRoute) AddRoute(routeID string, cfg config.ABTestConfig, wb *loadbalancer.WeightedBalancer) {
-	m.Add(routeID, New(routeID, cfg, wb))
+	ab := New(routeID, cfg, wb, m.redisClient)
+	m.exposureMu.RLock()
+	ab.onExposure = m.onExposure
+	m.exposureMu.RUnlock()
+	m.Add(routeID, ab)
 }
 
 
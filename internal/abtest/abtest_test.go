@@ -1,11 +1,17 @@
 package abtest
 
 import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/variables"
 )
 
 func newTestWB(groups ...string) *loadbalancer.WeightedBalancer {
@@ -27,7 +33,7 @@ func TestRecordAndSnapshot(t *testing.T) {
 	ab := New("route1", config.ABTestConfig{
 		Enabled:        true,
 		ExperimentName: "test-exp",
-	}, wb)
+	}, wb, nil)
 
 	// Record some requests.
 	ab.RecordRequest("control", 200, 10*time.Millisecond)
@@ -70,7 +76,7 @@ func TestReset(t *testing.T) {
 	ab := New("r1", config.ABTestConfig{
 		Enabled:        true,
 		ExperimentName: "exp1",
-	}, wb)
+	}, wb, nil)
 
 	ab.RecordRequest("a", 200, 10*time.Millisecond)
 	ab.RecordRequest("b", 500, 20*time.Millisecond)
@@ -94,7 +100,7 @@ func TestUnknownGroupNoOp(t *testing.T) {
 	ab := New("r1", config.ABTestConfig{
 		Enabled:        true,
 		ExperimentName: "exp1",
-	}, wb)
+	}, wb, nil)
 
 	// Should not panic.
 	ab.RecordRequest("nonexistent", 200, 10*time.Millisecond)
@@ -107,7 +113,7 @@ func TestUnknownGroupNoOp(t *testing.T) {
 
 func TestByRouteManager(t *testing.T) {
 	wb := newTestWB("control", "experiment")
-	mgr := NewABTestByRoute()
+	mgr := NewABTestByRoute(nil)
 	mgr.AddRoute("route1", config.ABTestConfig{
 		Enabled:        true,
 		ExperimentName: "exp1",
@@ -130,3 +136,139 @@ func TestByRouteManager(t *testing.T) {
 		t.Fatal("expected stats for route1")
 	}
 }
+
+func newAssigningTest(wb *loadbalancer.WeightedBalancer, exposure config.ABTestExposureConfig) *ABTest {
+	return New("route1", config.ABTestConfig{
+		Enabled:        true,
+		ExperimentName: "checkout_v2",
+		Assignment:     config.ABTestAssignmentConfig{Enabled: true, Key: "header:X-User"},
+		Exposure:       exposure,
+	}, wb, nil)
+}
+
+// serveAs runs one request for user through ab's middleware and returns
+// the response, the variant header the backend saw and the traffic group.
+func serveAs(ab *ABTest, user string, cookies ...*http.Cookie) (*httptest.ResponseRecorder, string, string) {
+	req := httptest.NewRequest("GET", "/checkout", nil)
+	req.Header.Set("X-User", user)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	varCtx := variables.AcquireContext(req)
+	req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+
+	var backendHeader string
+	w := httptest.NewRecorder()
+	ab.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendHeader = r.Header.Get("X-Experiment-Variant")
+	})).ServeHTTP(w, req)
+	return w, backendHeader, varCtx.TrafficGroup
+}
+
+func TestAssignment_StickyAndExposed(t *testing.T) {
+	ab := newAssigningTest(newTestWB("a", "b"), config.ABTestExposureConfig{})
+
+	w, backend, group := serveAs(ab, "alice")
+	if group != "a" && group != "b" {
+		t.Fatalf("expected a traffic group, got %q", group)
+	}
+	want := "checkout_v2=" + group
+	if backend != want || w.Header().Get("X-Experiment-Variant") != want {
+		t.Errorf("expected %q to backend and client, got %q and %q", want, backend, w.Header().Get("X-Experiment-Variant"))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "ab_checkout_v2" || cookies[0].Value != group {
+		t.Fatalf("unexpected assignment cookie: %v", cookies)
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, _, got := serveAs(ab, "alice"); got != group {
+			t.Fatalf("request %d: assignment changed from %q to %q", i, group, got)
+		}
+	}
+
+	snap := ab.Snapshot().Assignment
+	if snap.Variants[group].Assignments != 1 || snap.Variants[group].Exposures != 5 {
+		t.Errorf("expected 1 assignment and 5 exposures, got %+v", snap.Variants[group])
+	}
+	if snap.StoreHits != 4 {
+		t.Errorf("expected 4 store hits, got %d", snap.StoreHits)
+	}
+}
+
+func TestAssignment_SurvivesWeightChange(t *testing.T) {
+	wb := newTestWB("a", "b")
+	ab := newAssigningTest(wb, config.ABTestExposureConfig{})
+
+	assigned := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		user := "user-" + strconv.Itoa(i)
+		_, _, assigned[user] = serveAs(ab, user)
+	}
+
+	wb.SetGroupWeights(map[string]int{"a": 100, "b": 0})
+	for user, group := range assigned {
+		if _, _, got := serveAs(ab, user); got != group {
+			t.Fatalf("%s moved from %q to %q after a weight change", user, group, got)
+		}
+	}
+	if _, _, got := serveAs(ab, "newcomer"); got != "a" {
+		t.Errorf("new users should follow the new weights, got %q", got)
+	}
+
+	// The variant cookie takes precedence over the store.
+	if _, _, got := serveAs(ab, "newcomer", &http.Cookie{Name: "ab_checkout_v2", Value: "b"}); got != "b" {
+		t.Errorf("expected cookie variant b, got %q", got)
+	}
+}
+
+func TestAssignment_ExposureEventsAndDrift(t *testing.T) {
+	ab := newAssigningTest(newTestWB("a", "b"), config.ABTestExposureConfig{Enabled: true, SampleRate: 1.0})
+	var events []map[string]interface{}
+	ab.onExposure = func(_ *http.Request, routeID string, data map[string]interface{}) {
+		events = append(events, data)
+	}
+
+	for i := 0; i < 1000; i++ {
+		serveAs(ab, "user-"+strconv.Itoa(i))
+	}
+
+	if len(events) != 1000 {
+		t.Fatalf("expected 1000 exposure events, got %d", len(events))
+	}
+	ev := events[0]
+	if ev["experiment"] != "checkout_v2" || ev["new_assignment"] != true {
+		t.Errorf("unexpected event: %v", ev)
+	}
+	if ev["identifier_hash"] != hashIdentifier("header:X-User:user-0") {
+		t.Errorf("expected hashed identifier, got %v", ev["identifier_hash"])
+	}
+
+	snap := ab.Snapshot().Assignment
+	if snap.ExposuresEmitted != 1000 {
+		t.Errorf("expected 1000 emitted exposures, got %d", snap.ExposuresEmitted)
+	}
+	var total int64
+	for name, v := range snap.Variants {
+		total += v.Assignments
+		if v.TargetShare != 0.5 {
+			t.Errorf("%s: expected target share 0.5, got %v", name, v.TargetShare)
+		}
+		if math.Abs(v.Drift) > 0.1 {
+			t.Errorf("%s: drift %v is too large for a 50/50 split", name, v.Drift)
+		}
+	}
+	if total != 1000 {
+		t.Errorf("expected 1000 assignments, got %d", total)
+	}
+}
+
+func TestAssignment_DisabledHasNoMiddleware(t *testing.T) {
+	ab := New("route1", config.ABTestConfig{Enabled: true, ExperimentName: "exp"}, newTestWB("a", "b"), nil)
+	if ab.Middleware() != nil {
+		t.Error("expected no middleware without assignment")
+	}
+	if ab.Snapshot().Assignment != nil {
+		t.Error("expected no assignment stats without assignment")
+	}
+}
@@ -0,0 +1,344 @@
+package abtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	expirable "github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/redis/go-redis/v9"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/ratelimit"
+	"github.com/wudi/runway/variables"
+)
+
+const (
+	defaultVariantHeader = "X-Experiment-Variant"
+	defaultMaxEntries    = 100000
+	defaultAssignmentTTL = 30 * 24 * time.Hour
+)
+
+// assignmentStore remembers the variant each identifier was assigned, so an
+// assignment survives later weight changes.
+type assignmentStore interface {
+	Get(ctx context.Context, id string) (string, bool, error)
+	Set(ctx context.Context, id, variant string) error
+}
+
+// localStore keeps assignments in an in-process LRU.
+type localStore struct {
+	lru *expirable.LRU[string, string]
+}
+
+func newLocalStore(maxEntries int, ttl time.Duration) *localStore {
+	return &localStore{lru: expirable.NewLRU[string, string](maxEntries, nil, ttl)}
+}
+
+func (s *localStore) Get(_ context.Context, id string) (string, bool, error) {
+	v, ok := s.lru.Get(id)
+	return v, ok, nil
+}
+
+func (s *localStore) Set(_ context.Context, id, variant string) error {
+	s.lru.Add(id, variant)
+	return nil
+}
+
+// redisStore shares assignments across runway instances.
+type redisStore struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func (s *redisStore) Get(ctx context.Context, id string) (string, bool, error) {
+	v, err := s.client.Get(ctx, s.prefix+id).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return v, true, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, id, variant string) error {
+	return s.client.Set(ctx, s.prefix+id, variant, s.ttl).Err()
+}
+
+// variantCounters tracks assignment activity for one variant.
+type variantCounters struct {
+	assignments atomic.Int64 // identifiers newly assigned to the variant
+	exposures   atomic.Int64 // requests served with the variant
+}
+
+// assigner pins identifiers to variants and exposes the assignment.
+type assigner struct {
+	routeID    string
+	experiment string
+	wb         *loadbalancer.WeightedBalancer
+	keyFn      func(*http.Request) string
+	header     string
+	cookieName string
+	ttl        time.Duration
+	storeName  string
+	store      assignmentStore
+	sampleRate float64 // 0 disables exposure events
+
+	variants    map[string]*variantCounters
+	storeHits   atomic.Int64
+	storeErrors atomic.Int64
+	emitted     atomic.Int64
+}
+
+func newAssigner(routeID string, cfg config.ABTestConfig, wb *loadbalancer.WeightedBalancer, redisClient *redis.Client) *assigner {
+	ac := cfg.Assignment
+	as := &assigner{
+		routeID:    routeID,
+		experiment: cfg.ExperimentName,
+		wb:         wb,
+		keyFn:      ratelimit.BuildKeyFunc(false, ac.Key),
+		header:     ac.Header,
+		cookieName: ac.CookieName,
+		ttl:        ac.TTL,
+		variants:   make(map[string]*variantCounters),
+	}
+	if as.header == "" {
+		as.header = defaultVariantHeader
+	}
+	if as.cookieName == "" {
+		as.cookieName = "ab_" + cookieToken(cfg.ExperimentName)
+	}
+	if as.ttl <= 0 {
+		as.ttl = defaultAssignmentTTL
+	}
+	if ac.Store == "redis" && redisClient != nil {
+		as.storeName = "redis"
+		as.store = &redisStore{client: redisClient, prefix: "abtest:" + routeID + ":" + cfg.ExperimentName + ":", ttl: as.ttl}
+	} else {
+		maxEntries := ac.MaxEntries
+		if maxEntries <= 0 {
+			maxEntries = defaultMaxEntries
+		}
+		as.storeName = "local"
+		as.store = newLocalStore(maxEntries, as.ttl)
+	}
+	if cfg.Exposure.Enabled {
+		as.sampleRate = cfg.Exposure.SampleRate
+		if as.sampleRate <= 0 || as.sampleRate > 1.0 {
+			as.sampleRate = 1.0
+		}
+	}
+	for _, g := range wb.GetGroups() {
+		as.variants[g.Name] = &variantCounters{}
+	}
+	return as
+}
+
+// cookieToken replaces characters that are not valid in a cookie name.
+func cookieToken(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// hashIdentifier returns the hex SHA-256 of an identifier. Raw identifiers
+// are never stored or emitted.
+func hashIdentifier(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+// resolve returns the variant for a request and whether it was newly
+// assigned. The variant cookie wins, then the store, then a deterministic
+// hash of the identifier over the configured weights.
+func (as *assigner) resolve(r *http.Request, idHash string) (string, bool) {
+	if c, err := r.Cookie(as.cookieName); err == nil && as.variants[c.Value] != nil {
+		return c.Value, false
+	}
+	ctx := r.Context()
+	variant, ok, err := as.store.Get(ctx, idHash)
+	if err != nil {
+		as.storeErrors.Add(1)
+	} else if ok && as.variants[variant] != nil {
+		as.storeHits.Add(1)
+		return variant, false
+	}
+	variant = as.hashToVariant(idHash)
+	if variant == "" {
+		return "", false
+	}
+	if err := as.store.Set(ctx, idHash, variant); err != nil {
+		as.storeErrors.Add(1)
+	}
+	return variant, true
+}
+
+// hashToVariant maps an identifier hash onto the current group weights.
+func (as *assigner) hashToVariant(idHash string) string {
+	groups := as.wb.GetGroups()
+	total := 0
+	for _, g := range groups {
+		total += g.Weight
+	}
+	if total <= 0 {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(as.experiment))
+	h.Write([]byte{0})
+	h.Write([]byte(idHash))
+	slot := int(h.Sum32() % uint32(total))
+	cumulative := 0
+	for _, g := range groups {
+		cumulative += g.Weight
+		if slot < cumulative {
+			return g.Name
+		}
+	}
+	return groups[len(groups)-1].Name
+}
+
+func (ab *ABTest) assignmentMiddleware() middleware.Middleware {
+	as := ab.assigner
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			varCtx := variables.GetFromRequest(r)
+			idHash := hashIdentifier(as.keyFn(r))
+
+			// A traffic group chosen by request rules takes precedence.
+			variant, assigned := varCtx.TrafficGroup, false
+			if as.variants[variant] == nil {
+				variant, assigned = as.resolve(r, idHash)
+			}
+			vc := as.variants[variant]
+			if vc == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if assigned {
+				vc.assignments.Add(1)
+			}
+			vc.exposures.Add(1)
+			varCtx.TrafficGroup = variant
+
+			value := as.experiment + "=" + variant
+			r.Header.Set(as.header, value)
+			w.Header().Set(as.header, value)
+			http.SetCookie(w, &http.Cookie{
+				Name:     as.cookieName,
+				Value:    variant,
+				Path:     "/",
+				MaxAge:   int(as.ttl.Seconds()),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+
+			ab.emitExposure(r, variant, idHash, assigned)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// emitExposure sends a sampled exposure event to the registered callback.
+func (ab *ABTest) emitExposure(r *http.Request, variant, idHash string, assigned bool) {
+	as := ab.assigner
+	if as.sampleRate == 0 || ab.onExposure == nil {
+		return
+	}
+	if as.sampleRate < 1.0 && rand.Float64() >= as.sampleRate {
+		return
+	}
+	as.emitted.Add(1)
+	ab.onExposure(r, ab.routeID, map[string]interface{}{
+		"experiment":      as.experiment,
+		"variant":         variant,
+		"identifier_hash": idHash,
+		"new_assignment":  assigned,
+		"timestamp":       time.Now().UTC().Format(time.RFC3339Nano),
+	})
+}
+
+// reset clears assignment counters. Stored assignments are kept so
+// clients stay in their variant.
+func (as *assigner) reset() {
+	for _, vc := range as.variants {
+		vc.assignments.Store(0)
+		vc.exposures.Store(0)
+	}
+	as.storeHits.Store(0)
+	as.storeErrors.Store(0)
+	as.emitted.Store(0)
+}
+
+// VariantAssignment reports assignment counts for one variant and how far
+// its share of assignments has drifted from its configured weight.
+type VariantAssignment struct {
+	Assignments int64   `json:"assignments"`
+	Exposures   int64   `json:"exposures"`
+	Share       float64 `json:"share"`
+	TargetShare float64 `json:"target_share"`
+	Drift       float64 `json:"drift"`
+}
+
+// AssignmentSnapshot is a JSON-serializable view of variant assignment.
+type AssignmentSnapshot struct {
+	Header           string                       `json:"header"`
+	CookieName       string                       `json:"cookie_name"`
+	Store            string                       `json:"store"`
+	StoreHits        int64                        `json:"store_hits"`
+	StoreErrors      int64                        `json:"store_errors"`
+	ExposureSampling float64                      `json:"exposure_sample_rate"`
+	ExposuresEmitted int64                        `json:"exposures_emitted"`
+	Variants         map[string]VariantAssignment `json:"variants"`
+}
+
+func (as *assigner) snapshot() *AssignmentSnapshot {
+	weights := as.wb.GetGroupWeights()
+	totalWeight := 0
+	for _, w := range weights {
+		totalWeight += w
+	}
+	var totalAssigned int64
+	for _, vc := range as.variants {
+		totalAssigned += vc.assignments.Load()
+	}
+
+	variants := make(map[string]VariantAssignment, len(as.variants))
+	for name, vc := range as.variants {
+		va := VariantAssignment{
+			Assignments: vc.assignments.Load(),
+			Exposures:   vc.exposures.Load(),
+		}
+		if totalWeight > 0 {
+			va.TargetShare = float64(weights[name]) / float64(totalWeight)
+		}
+		if totalAssigned > 0 {
+			va.Share = float64(va.Assignments) / float64(totalAssigned)
+			va.Drift = va.Share - va.TargetShare
+		}
+		variants[name] = va
+	}
+	return &AssignmentSnapshot{
+		Header:           as.header,
+		CookieName:       as.cookieName,
+		Store:            as.storeName,
+		StoreHits:        as.storeHits.Load(),
+		StoreErrors:      as.storeErrors.Load(),
+		ExposureSampling: as.sampleRate,
+		ExposuresEmitted: as.emitted.Load(),
+		Variants:         variants,
+	}
+}
@@ -0,0 +1,324 @@
+// Package artifact fetches digest-pinned artifacts, such as protobuf
+// descriptor sets, OpenAPI specs and WASM plugins, from OCI registries and
+// HTTPS URLs into a local content-addressed cache.
+//
+// A reference is a location followed by the sha256 digest of the
+// artifact's content:
+//
+//	oci://registry.example.com/team/protos:v3@sha256:<hex>
+//	https://specs.example.com/petstore.yaml@sha256:<hex>
+//
+// For OCI references the digest is that of the layer holding the
+// artifact, and the tag, when given, must list that layer. The digest is
+// mandatory: content that does not hash to it is rejected, and a cached
+// copy is reused without contacting the remote.
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxSize is the largest artifact fetched by default.
+	DefaultMaxSize = 64 << 20
+	// DefaultTimeout bounds fetching one artifact by default.
+	DefaultTimeout = 30 * time.Second
+)
+
+// ErrDigestMismatch is returned when fetched content does not hash to the
+// reference's digest.
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// IsRemote reports whether s is an artifact reference rather than a local
+// path.
+func IsRemote(s string) bool {
+	return strings.HasPrefix(s, "oci://") || strings.HasPrefix(s, "https://")
+}
+
+// Ref is a parsed artifact reference.
+type Ref struct {
+	Raw      string // as configured
+	Scheme   string // "oci" or "https"
+	Location string // the reference without its digest
+	Digest   string // "sha256:<hex>"
+
+	// OCI references only.
+	Registry   string
+	Repository string
+	Tag        string
+}
+
+// ParseRef parses an oci:// or https:// reference.
+func ParseRef(s string) (Ref, error) {
+	ref := Ref{Raw: s}
+	i := strings.LastIndex(s, "@sha256:")
+	if i < 0 {
+		return ref, fmt.Errorf("missing digest: append @sha256:<hex> to pin the content")
+	}
+	ref.Location, ref.Digest = s[:i], s[i+1:]
+	if hexDigest := strings.TrimPrefix(ref.Digest, "sha256:"); len(hexDigest) != sha256.Size*2 || !isLowerHex(hexDigest) {
+		return ref, fmt.Errorf("digest must be sha256: followed by 64 lowercase hex characters")
+	}
+	switch {
+	case strings.HasPrefix(ref.Location, "https://"):
+		ref.Scheme = "https"
+		if len(ref.Location) == len("https://") {
+			return ref, fmt.Errorf("missing host")
+		}
+	case strings.HasPrefix(ref.Location, "oci://"):
+		ref.Scheme = "oci"
+		registry, repo, ok := strings.Cut(strings.TrimPrefix(ref.Location, "oci://"), "/")
+		if !ok || registry == "" || repo == "" {
+			return ref, fmt.Errorf("want oci://registry/repository[:tag]")
+		}
+		if j := strings.LastIndex(repo, ":"); j > strings.LastIndex(repo, "/") {
+			repo, ref.Tag = repo[:j], repo[j+1:]
+			if ref.Tag == "" {
+				return ref, fmt.Errorf("empty tag")
+			}
+		}
+		ref.Registry, ref.Repository = registry, repo
+	default:
+		return ref, fmt.Errorf("scheme must be oci:// or https://")
+	}
+	return ref, nil
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Credentials authenticate pulls from a registry or HTTPS host.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Options configures a Store.
+type Options struct {
+	Dir         string
+	MaxSize     int64                  // default DefaultMaxSize
+	Timeout     time.Duration          // per artifact, default DefaultTimeout
+	Credentials map[string]Credentials // by host
+	Client      *http.Client           // default http.DefaultClient
+}
+
+// Store is a content-addressed artifact cache on disk. Artifacts are kept
+// at <dir>/sha256/<hex>, so references with the same digest share one file.
+type Store struct {
+	dir     string
+	maxSize int64
+	timeout time.Duration
+	creds   map[string]Credentials
+	client  *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]string // bearer token by registry and repository
+}
+
+// NewStore returns a store caching artifacts in opts.Dir.
+func NewStore(opts Options) *Store {
+	s := &Store{
+		dir:     opts.Dir,
+		maxSize: opts.MaxSize,
+		timeout: opts.Timeout,
+		creds:   opts.Credentials,
+		client:  opts.Client,
+		tokens:  make(map[string]string),
+	}
+	if s.maxSize <= 0 {
+		s.maxSize = DefaultMaxSize
+	}
+	if s.timeout <= 0 {
+		s.timeout = DefaultTimeout
+	}
+	if s.client == nil {
+		s.client = http.DefaultClient
+	}
+	return s
+}
+
+// Path returns where the artifact with digest is cached.
+func (s *Store) Path(digest string) string {
+	return filepath.Join(s.dir, "sha256", strings.TrimPrefix(digest, "sha256:"))
+}
+
+// Fetch returns the local path of ref's content, downloading and verifying
+// it unless a cached copy matches the digest.
+func (s *Store) Fetch(ctx context.Context, ref Ref) (string, error) {
+	path := s.Path(ref.Digest)
+	if err := verifyFile(path, ref.Digest); err == nil {
+		return path, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		// A corrupt or tampered copy is replaced.
+		os.Remove(path)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var body io.ReadCloser
+	var err error
+	switch ref.Scheme {
+	case "oci":
+		body, err = s.openOCI(ctx, ref)
+	default:
+		body, err = s.open(ctx, ref.Location, "", hostOf(ref.Location), "")
+	}
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	if err := s.store(body, path, ref.Digest); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// store writes r to path through a temporary file, which is renamed into
+// place only when its content hashes to digest.
+func (s *Store) store(r io.Reader, path, digest string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".fetch-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, s.maxSize+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("downloading: %w", err)
+	}
+	if n > s.maxSize {
+		return fmt.Errorf("artifact exceeds %d bytes", s.maxSize)
+	}
+	if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); got != digest {
+		return fmt.Errorf("%w: got %s", ErrDigestMismatch, got)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// verifyFile checks that the file at path hashes to digest.
+func verifyFile(path, digest string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); got != digest {
+		return fmt.Errorf("%w: got %s", ErrDigestMismatch, got)
+	}
+	return nil
+}
+
+// Entry is a cached artifact.
+type Entry struct {
+	Digest   string    `json:"digest"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// List returns the cached artifacts, sorted by digest.
+func (s *Store) List() ([]Entry, error) {
+	dirEntries, err := os.ReadDir(filepath.Join(s.dir, "sha256"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, de := range dirEntries {
+		if de.IsDir() || strings.HasPrefix(de.Name(), ".") {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, Entry{
+			Digest:   "sha256:" + de.Name(),
+			Path:     filepath.Join(s.dir, "sha256", de.Name()),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+	}
+	return entries, nil
+}
+
+func hostOf(rawURL string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(rawURL, "https://"), "http://")
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	return host
+}
+
+// open GETs url and returns the response body. Requests carry the host's
+// credentials; for registries (scope set), a 401 bearer challenge is
+// answered with a token from the registry's token service.
+func (s *Store) open(ctx context.Context, url, accept, host, scope string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	token := s.tokens[host+"/"+scope]
+	s.mu.Unlock()
+	resp, err := s.get(ctx, url, accept, host, token)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && scope != "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if token, err = s.token(ctx, challenge, host, scope); err != nil {
+			return nil, fmt.Errorf("GET %s: %w", url, err)
+		}
+		if resp, err = s.get(ctx, url, accept, host, token); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (s *Store) get(ctx context.Context, url, accept, host, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if c, ok := s.creds[host]; ok {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	return s.client.Do(req)
+}
@@ -0,0 +1,198 @@
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestParseRef(t *testing.T) {
+	d := digestOf([]byte("x"))
+	tests := []struct {
+		in      string
+		want    Ref
+		wantErr string
+	}{
+		{in: "https://specs.example.com/a.yaml@" + d, want: Ref{Scheme: "https", Location: "https://specs.example.com/a.yaml", Digest: d}},
+		{in: "oci://ghcr.io/acme/protos:v3@" + d, want: Ref{Scheme: "oci", Location: "oci://ghcr.io/acme/protos:v3", Digest: d, Registry: "ghcr.io", Repository: "acme/protos", Tag: "v3"}},
+		{in: "oci://localhost:5000/protos@" + d, want: Ref{Scheme: "oci", Location: "oci://localhost:5000/protos", Digest: d, Registry: "localhost:5000", Repository: "protos"}},
+		{in: "https://specs.example.com/a.yaml", wantErr: "missing digest"},
+		{in: "https://specs.example.com/a.yaml@sha256:abc", wantErr: "64 lowercase hex"},
+		{in: "oci://ghcr.io@" + d, wantErr: "want oci://registry/repository"},
+		{in: "oci://ghcr.io/acme/protos:@" + d, wantErr: "empty tag"},
+		{in: "http://specs.example.com/a.yaml@" + d, wantErr: "scheme must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRef(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			tt.want.Raw = tt.in
+			if err != nil || got != tt.want {
+				t.Errorf("ParseRef = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchHTTPS(t *testing.T) {
+	content := []byte(`openapi: "3.0.0"`)
+	var hits atomic.Int64
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	store := NewStore(Options{Dir: t.TempDir(), Client: srv.Client()})
+	ref, err := ParseRef(srv.URL + "/spec.yaml@" + digestOf(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		path, err := store.Fetch(context.Background(), ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(path); string(got) != string(content) {
+			t.Errorf("cached content = %q", got)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected the cached copy reused, got %d fetches", hits.Load())
+	}
+
+	// Another reference to the same content shares the cached copy.
+	other, _ := ParseRef(srv.URL + "/mirror/spec.yaml@" + digestOf(content))
+	if _, err := store.Fetch(context.Background(), other); err != nil || hits.Load() != 1 {
+		t.Errorf("expected a cache hit by digest, got %v after %d fetches", err, hits.Load())
+	}
+
+	// A corrupted copy is fetched again.
+	os.WriteFile(store.Path(ref.Digest), []byte("tampered"), 0o644)
+	if _, err := store.Fetch(context.Background(), ref); err != nil || hits.Load() != 2 {
+		t.Errorf("expected a refetch, got %v after %d fetches", err, hits.Load())
+	}
+}
+
+func TestFetchDigestMismatch(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not what was pinned"))
+	}))
+	defer srv.Close()
+
+	store := NewStore(Options{Dir: t.TempDir(), Client: srv.Client()})
+	ref, _ := ParseRef(srv.URL + "/plugin.wasm@" + digestOf([]byte("pinned")))
+	if _, err := store.Fetch(context.Background(), ref); !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("expected ErrDigestMismatch, got %v", err)
+	}
+	if entries, _ := store.List(); len(entries) != 0 {
+		t.Errorf("rejected content was cached: %+v", entries)
+	}
+}
+
+func TestFetchMaxSize(t *testing.T) {
+	content := []byte(strings.Repeat("a", 100))
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	store := NewStore(Options{Dir: t.TempDir(), Client: srv.Client(), MaxSize: 50})
+	ref, _ := ParseRef(srv.URL + "/big@" + digestOf(content))
+	if _, err := store.Fetch(context.Background(), ref); err == nil || !strings.Contains(err.Error(), "exceeds 50 bytes") {
+		t.Fatalf("expected a size error, got %v", err)
+	}
+}
+
+func TestFetchOCI(t *testing.T) {
+	layer := []byte("descriptor set bytes")
+	layerDigest := digestOf(layer)
+	var blobFetches atomic.Int64
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:acme/protos:pull" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			user, pass, _ := r.BasicAuth()
+			if user != "bot" || pass != "s3cret" {
+				http.Error(w, "denied", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token": "pull-token"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer pull-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="test-registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/acme/protos/manifests/v3":
+			if !strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.manifest.v1+json") {
+				http.Error(w, "bad accept", http.StatusNotAcceptable)
+				return
+			}
+			fmt.Fprintf(w, `{"schemaVersion": 2, "layers": [{"digest": %q, "size": %d}]}`, layerDigest, len(layer))
+		case "/v2/acme/protos/blobs/" + layerDigest:
+			blobFetches.Add(1)
+			w.Write(layer)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	registry := strings.TrimPrefix(srv.URL, "https://")
+	store := NewStore(Options{
+		Dir:         t.TempDir(),
+		Client:      srv.Client(),
+		Credentials: map[string]Credentials{registry: {Username: "bot", Password: "s3cret"}},
+	})
+
+	ref, _ := ParseRef("oci://" + registry + "/acme/protos:v3@" + layerDigest)
+	path, err := store.Fetch(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != string(layer) {
+		t.Errorf("cached content = %q", got)
+	}
+
+	// A pinned digest the tag does not contain is rejected.
+	wrong, _ := ParseRef("oci://" + registry + "/acme/protos:v3@" + digestOf([]byte("old layer")))
+	if _, err := store.Fetch(context.Background(), wrong); !errors.Is(err, ErrDigestMismatch) || !strings.Contains(err.Error(), layerDigest) {
+		t.Errorf("expected a mismatch listing the tag's layers, got %v", err)
+	}
+
+	// Without a tag, the blob is fetched by digest.
+	os.Remove(path)
+	untagged, _ := ParseRef("oci://" + registry + "/acme/protos@" + layerDigest)
+	if _, err := store.Fetch(context.Background(), untagged); err != nil || blobFetches.Load() != 2 {
+		t.Errorf("expected a blob fetch by digest, got %v after %d fetches", err, blobFetches.Load())
+	}
+
+	entries, err := store.List()
+	if err != nil || len(entries) != 1 || entries[0].Digest != layerDigest || entries[0].Size != int64(len(layer)) {
+		t.Errorf("List = %+v, %v", entries, err)
+	}
+}
@@ -0,0 +1,118 @@
+package artifact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const manifestAccept = "application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json"
+
+// maxManifestSize bounds manifest and token responses.
+const maxManifestSize = 4 << 20
+
+type manifest struct {
+	Layers []struct {
+		Digest string `json:"digest"`
+	} `json:"layers"`
+}
+
+// openOCI returns the blob of ref's layer. When ref has a tag, the tag's
+// manifest must list the layer.
+func (s *Store) openOCI(ctx context.Context, ref Ref) (io.ReadCloser, error) {
+	base := "https://" + ref.Registry + "/v2/" + ref.Repository
+	scope := "repository:" + ref.Repository + ":pull"
+	if ref.Tag != "" {
+		body, err := s.open(ctx, base+"/manifests/"+url.PathEscape(ref.Tag), manifestAccept, ref.Registry, scope)
+		if err != nil {
+			return nil, err
+		}
+		var m manifest
+		err = json.NewDecoder(io.LimitReader(body, maxManifestSize)).Decode(&m)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding manifest of %s: %w", ref.Location, err)
+		}
+		var layers []string
+		for _, l := range m.Layers {
+			layers = append(layers, l.Digest)
+		}
+		if !slices.Contains(layers, ref.Digest) {
+			return nil, fmt.Errorf("%w: %s has no layer %s (layers: %s)", ErrDigestMismatch, ref.Location, ref.Digest, strings.Join(layers, ", "))
+		}
+	}
+	return s.open(ctx, base+"/blobs/"+ref.Digest, "", ref.Registry, scope)
+}
+
+// token answers a registry's bearer challenge, such as
+//
+//	Bearer realm="https://auth.example.com/token",service="registry.example.com"
+//
+// with a pull token for scope, authenticating with the registry's
+// credentials when it has any.
+func (s *Store) token(ctx context.Context, challenge, host, scope string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unauthorized (challenge %q)", challenge)
+	}
+	attrs := parseChallenge(params)
+	realm, err := url.Parse(attrs["realm"])
+	if err != nil || realm.Scheme != "https" {
+		return "", fmt.Errorf("bearer challenge realm %q is not an https URL", attrs["realm"])
+	}
+	q := realm.Query()
+	if attrs["service"] != "" {
+		q.Set("service", attrs["service"])
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+
+	resp, err := s.get(ctx, realm.String(), "", host, "")
+	if err != nil {
+		return "", fmt.Errorf("requesting token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting token: %s", resp.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&tok); err != nil {
+		return "", fmt.Errorf("decoding token: %w", err)
+	}
+	token := tok.Token
+	if token == "" {
+		token = tok.AccessToken
+	}
+	if token == "" {
+		return "", fmt.Errorf("token service returned no token")
+	}
+	s.mu.Lock()
+	s.tokens[host+"/"+scope] = token
+	s.mu.Unlock()
+	return token, nil
+}
+
+// parseChallenge parses the comma-separated key="value" pairs of a
+// WWW-Authenticate challenge.
+func parseChallenge(params string) map[string]string {
+	attrs := make(map[string]string)
+	for params != "" {
+		var key, value string
+		key, params, _ = strings.Cut(strings.TrimLeft(params, ", "), "=")
+		if strings.HasPrefix(params, `"`) {
+			value, params, _ = strings.Cut(params[1:], `"`)
+		} else {
+			value, params, _ = strings.Cut(params, ",")
+		}
+		attrs[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return attrs
+}
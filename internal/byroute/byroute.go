@@ -1,6 +1,10 @@
 package byroute
 
-import "sync"
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
 
 // Manager is a generic thread-safe per-route object store.
 // It replaces the hand-written XxxByRoute structs that all follow
@@ -208,3 +212,103 @@ func (f *NamedFactory[T, C]) CloseAll() {
 		ForEach(&f.Manager, f.closeFn)
 	}
 }
+
+// Shared is a pool of named instances built from top-level definitions and
+// shared by every route that references them by name. Instances are built
+// on first reference, so unreferenced definitions cost nothing.
+type Shared[T any, C any] struct {
+	defs    map[string]C
+	newFn   func(name string, cfg C) (T, error)
+	statsFn func(T) any
+	closeFn func(T)
+
+	mu       sync.RWMutex
+	items    map[string]T
+	routes   map[string][]string // name -> referencing route IDs
+	routeRef map[string]string   // route ID -> name
+}
+
+// SharedStats groups a shared instance's stats with the routes that
+// reference it.
+type SharedStats struct {
+	Routes []string `json:"routes"`
+	Stats  any      `json:"stats"`
+}
+
+// NewShared creates a Shared pool over defs.
+func NewShared[T any, C any](defs map[string]C, newFn func(string, C) (T, error), statsFn func(T) any) *Shared[T, C] {
+	return &Shared[T, C]{
+		defs:     defs,
+		newFn:    newFn,
+		statsFn:  statsFn,
+		items:    make(map[string]T),
+		routes:   make(map[string][]string),
+		routeRef: make(map[string]string),
+	}
+}
+
+// WithClose sets the per-instance cleanup function and returns the pool for chaining.
+func (s *Shared[T, C]) WithClose(fn func(T)) *Shared[T, C] {
+	s.closeFn = fn
+	return s
+}
+
+// Acquire returns the instance for name, building it on first use, and
+// records routeID as referencing it.
+func (s *Shared[T, C]) Acquire(name, routeID string) (_ T, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[name]
+	if !ok {
+		cfg, found := s.defs[name]
+		if !found {
+			return item, fmt.Errorf("shared definition %q not found", name)
+		}
+		if item, err = s.newFn(name, cfg); err != nil {
+			return item, fmt.Errorf("shared definition %q: %w", name, err)
+		}
+		s.items[name] = item
+	}
+	if _, seen := s.routeRef[routeID]; !seen {
+		s.routes[name] = append(s.routes[name], routeID)
+	}
+	s.routeRef[routeID] = name
+	return item, nil
+}
+
+// RefOf returns the name of the shared instance routeID references.
+func (s *Shared[T, C]) RefOf(routeID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	name, ok := s.routeRef[routeID]
+	return name, ok
+}
+
+// Stats returns per-instance statistics with their referencing routes.
+func (s *Shared[T, C]) Stats() map[string]SharedStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string]SharedStats, len(s.items))
+	for name, item := range s.items {
+		routes := append([]string(nil), s.routes[name]...)
+		sort.Strings(routes)
+		ss := SharedStats{Routes: routes}
+		if s.statsFn != nil {
+			ss.Stats = s.statsFn(item)
+		}
+		result[name] = ss
+	}
+	return result
+}
+
+// CloseAll calls the close function once on every built instance.
+func (s *Shared[T, C]) CloseAll() {
+	if s.closeFn == nil {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, item := range s.items {
+		s.closeFn(item)
+	}
+}
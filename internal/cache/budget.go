@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pool accounts for the entries routes hold in one store. A dedicated store
+// has a single member; a shared bucket has one per route. Each member keeps
+// its entries within its own max_entries and max_bytes, and a full store
+// makes room by evicting from the member furthest over its fair share of
+// the store, so one route cannot push every other route's entries out.
+//
+// Accounting only covers entries stored through this process: in a shared
+// Redis store it is per instance, and entries that expire or are purged
+// are forgotten when a lookup misses them or they reach the front of their
+// route's LRU.
+type pool struct {
+	store    Store
+	capacity int           // entries the store holds; 0 = unbounded
+	ttl      time.Duration // store TTL; 0 = entries are not aged out of the accounts
+	size     func() int    // entries currently in the store; nil when unbounded
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // key → element in its owner's lru
+	members []*budget
+}
+
+// budget is one route's account in a pool.
+type budget struct {
+	pool        *pool
+	route       string
+	maxEntries  int   // 0 = limited by the store only
+	maxBytes    int64 // 0 = unlimited
+	maxVariants int   // 0 = unlimited
+
+	// Guarded by pool.mu.
+	lru    *list.List     // *tracked, least recently used first
+	bytes  int64          // body bytes of the tracked entries
+	groups map[string]int // variant group → entries
+
+	evictionsCaused   atomic.Int64 // entries evicted to make room for this route's
+	evictionsSuffered atomic.Int64 // this route's entries evicted by any route
+	variantCapHits    atomic.Int64 // stores skipped by max_variants_per_path
+}
+
+// tracked is an entry held in a budget.
+type tracked struct {
+	key     string
+	group   string // variant group; empty when not subject to max_variants_per_path
+	size    int64
+	expires time.Time // zero when the pool has no TTL
+	owner   *budget
+}
+
+// newPool creates the pool of a store, taking its capacity and TTL from
+// the store when it has them.
+func newPool(store Store) *pool {
+	p := &pool{store: store, entries: make(map[string]*list.Element)}
+	switch s := store.(type) {
+	case *MemoryStore:
+		p.capacity = s.maxSize
+		p.ttl = s.ttl
+		p.size = s.lru.Len
+	case *RedisStore:
+		p.ttl = s.ttl
+	}
+	return p
+}
+
+// join adds a route's budget to the pool, replacing any earlier budget of
+// the same route.
+func (p *pool) join(route string, maxEntries int, maxBytes int64, maxVariants int) *budget {
+	b := &budget{
+		pool:        p,
+		route:       route,
+		maxEntries:  maxEntries,
+		maxBytes:    maxBytes,
+		maxVariants: maxVariants,
+		lru:         list.New(),
+		groups:      make(map[string]int),
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, m := range p.members {
+		if m.route == route {
+			m.clearLocked()
+			p.members[i] = b
+			return b
+		}
+	}
+	p.members = append(p.members, b)
+	return b
+}
+
+// fairShare returns the entries each member may hold before a full store
+// evicts from it for others, or 0 for an unbounded store.
+func (p *pool) fairShare() int {
+	if p.capacity <= 0 || len(p.members) == 0 {
+		return 0
+	}
+	return max(p.capacity/len(p.members), 1)
+}
+
+// victim returns the member whose entry a full store evicts to make room
+// for b: the member furthest over its fair share, preferring b on ties.
+func (p *pool) victim(b *budget) *budget {
+	share := p.fairShare()
+	victim, over := b, b.lru.Len()-share
+	for _, m := range p.members {
+		if o := m.lru.Len() - share; o > over {
+			victim, over = m, o
+		}
+	}
+	return victim
+}
+
+// sweep forgets the entries at the front of each member's LRU that the
+// store has expired by now.
+func (p *pool) sweep(now time.Time) {
+	if p.ttl <= 0 {
+		return
+	}
+	for _, m := range p.members {
+		for el := m.lru.Front(); el != nil && !now.Before(el.Value.(*tracked).expires); el = m.lru.Front() {
+			p.remove(el)
+		}
+	}
+}
+
+// remove drops an entry from the accounts.
+func (p *pool) remove(el *list.Element) {
+	t := el.Value.(*tracked)
+	b := t.owner
+	b.lru.Remove(el)
+	b.bytes -= t.size
+	if t.group != "" {
+		if b.groups[t.group]--; b.groups[t.group] <= 0 {
+			delete(b.groups, t.group)
+		}
+	}
+	delete(p.entries, t.key)
+}
+
+// evict removes an entry from the store to make room for an entry of cause.
+func (p *pool) evict(el *list.Element, cause *budget) {
+	t := el.Value.(*tracked)
+	p.remove(el)
+	p.store.Delete(t.key)
+	cause.evictionsCaused.Add(1)
+	t.owner.evictionsSuffered.Add(1)
+}
+
+// clear forgets every entry in the pool, after the whole store was purged.
+func (p *pool) clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, m := range p.members {
+		m.clearLocked()
+	}
+}
+
+// store makes room for an entry of size body bytes under key and calls set
+// to write it. group is the entry's variant group, or empty when it is not
+// subject to max_variants_per_path. It reports false, without calling set,
+// when the entry does not fit the route's budget.
+func (b *budget) store(key, group string, size int64, now time.Time, set func()) bool {
+	p := b.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sweep(now)
+
+	// A stored key is replaced in place: it does not grow the store, and
+	// only counts as a new variant if another route stored it.
+	el, exists := p.entries[key]
+	owned := exists && el.Value.(*tracked).owner == b
+	if !owned && group != "" && b.maxVariants > 0 && b.groups[group] >= b.maxVariants {
+		b.variantCapHits.Add(1)
+		return false
+	}
+	if b.maxBytes > 0 && size > b.maxBytes {
+		return false
+	}
+	if exists {
+		p.remove(el)
+	}
+	for b.lru.Len() > 0 && (b.maxEntries > 0 && b.lru.Len() >= b.maxEntries || b.maxBytes > 0 && b.bytes+size > b.maxBytes) {
+		p.evict(b.lru.Front(), b)
+	}
+	if !exists && p.size != nil {
+		for p.size() >= p.capacity {
+			victim := p.victim(b)
+			if victim.lru.Len() == 0 {
+				break
+			}
+			p.evict(victim.lru.Front(), b)
+		}
+	}
+
+	set()
+	t := &tracked{key: key, group: group, size: size, owner: b}
+	if p.ttl > 0 {
+		t.expires = now.Add(p.ttl)
+	}
+	p.entries[key] = b.lru.PushBack(t)
+	b.bytes += size
+	if group != "" {
+		b.groups[group]++
+	}
+	return true
+}
+
+// admitsVariant reports whether the route may store key in variant group
+// group without exceeding max_variants_per_path, counting a cap hit if not.
+func (b *budget) admitsVariant(key, group string) bool {
+	if group == "" || b.maxVariants == 0 {
+		return true
+	}
+	p := b.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if el, ok := p.entries[key]; ok && el.Value.(*tracked).owner == b {
+		return true
+	}
+	if b.groups[group] >= b.maxVariants {
+		b.variantCapHits.Add(1)
+		return false
+	}
+	return true
+}
+
+// touch marks key as recently used.
+func (b *budget) touch(key string) {
+	p := b.pool
+	p.mu.Lock()
+	if el, ok := p.entries[key]; ok {
+		el.Value.(*tracked).owner.lru.MoveToBack(el)
+	}
+	p.mu.Unlock()
+}
+
+// forget drops key from the accounts after it left the store.
+func (b *budget) forget(key string) {
+	p := b.pool
+	p.mu.Lock()
+	if el, ok := p.entries[key]; ok {
+		p.remove(el)
+	}
+	p.mu.Unlock()
+}
+
+// clear forgets the route's entries after they were purged.
+func (b *budget) clear() {
+	b.pool.mu.Lock()
+	b.clearLocked()
+	b.pool.mu.Unlock()
+}
+
+func (b *budget) clearLocked() {
+	for el := b.lru.Front(); el != nil; el = b.lru.Front() {
+		b.pool.remove(el)
+	}
+}
+
+// stats adds the route's accounts to stats.
+func (b *budget) stats(stats *CacheStats, now time.Time) {
+	p := b.pool
+	p.mu.Lock()
+	p.sweep(now)
+	stats.Entries = b.lru.Len()
+	stats.Bytes = b.bytes
+	if len(p.members) > 1 {
+		stats.FairShare = p.fairShare()
+	}
+	p.mu.Unlock()
+	stats.MaxEntries = b.maxEntries
+	stats.MaxBytes = b.maxBytes
+	stats.MaxVariantsPerPath = b.maxVariants
+	stats.EvictionsCaused = b.evictionsCaused.Load()
+	stats.EvictionsSuffered = b.evictionsSuffered.Load()
+	stats.VariantCapHits = b.variantCapHits.Load()
+}
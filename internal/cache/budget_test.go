@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+// storeN stores n responses for distinct paths under prefix.
+func storeN(h *Handler, prefix string, n int) {
+	for i := 0; i < n; i++ {
+		r := httptest.NewRequest("GET", fmt.Sprintf("%s/%d", prefix, i), nil)
+		h.StoreForRequest(r, h.KeyForRequest(r), &Entry{StatusCode: 200, Body: []byte("0123456789")})
+	}
+}
+
+// cached reports whether the response for path is cached.
+func cached(h *Handler, path string) bool {
+	_, ok := h.Get(httptest.NewRequest("GET", path, nil))
+	return ok
+}
+
+func TestBudget_SharedBucketIsolation(t *testing.T) {
+	cbr := NewCacheByRoute(nil)
+	cbr.AddRoute("quiet", config.CacheConfig{Enabled: true, MaxSize: 10, Bucket: "shared"})
+	cbr.AddRoute("noisy", config.CacheConfig{Enabled: true, MaxSize: 10, Bucket: "shared"})
+	quiet, noisy := cbr.Lookup("quiet"), cbr.Lookup("noisy")
+
+	storeN(quiet, "/quiet", 3)
+	storeN(noisy, "/noisy", 100)
+
+	// The noisy route fills the rest of the bucket and then evicts its own
+	// entries, leaving the quiet route's alone.
+	for i := 0; i < 3; i++ {
+		if !cached(quiet, fmt.Sprintf("/quiet/%d", i)) {
+			t.Errorf("/quiet/%d was evicted by the other route", i)
+		}
+	}
+	if !cached(noisy, "/noisy/99") {
+		t.Error("latest noisy entry not cached")
+	}
+
+	stats := cbr.Stats()
+	q, n := stats["quiet"], stats["noisy"]
+	if q.Entries != 3 || n.Entries != 7 {
+		t.Errorf("entries = %d, %d, want 3, 7", q.Entries, n.Entries)
+	}
+	if q.Bytes != 30 {
+		t.Errorf("quiet bytes = %d, want 30", q.Bytes)
+	}
+	if q.FairShare != 5 {
+		t.Errorf("fair share = %d, want 5", q.FairShare)
+	}
+	if q.EvictionsSuffered != 0 || q.EvictionsCaused != 0 {
+		t.Errorf("quiet evictions = %d suffered, %d caused, want 0", q.EvictionsSuffered, q.EvictionsCaused)
+	}
+	if n.EvictionsCaused != 93 || n.EvictionsSuffered != 93 {
+		t.Errorf("noisy evictions = %d caused, %d suffered, want 93", n.EvictionsCaused, n.EvictionsSuffered)
+	}
+}
+
+func TestBudget_FullBucketEvictsRouteOverFairShare(t *testing.T) {
+	cbr := NewCacheByRoute(nil)
+	cbr.AddRoute("a", config.CacheConfig{Enabled: true, MaxSize: 10, Bucket: "shared"})
+	cbr.AddRoute("b", config.CacheConfig{Enabled: true, MaxSize: 10, Bucket: "shared"})
+	a, b := cbr.Lookup("a"), cbr.Lookup("b")
+
+	// a fills the bucket while b is idle; b's entries then take a's place
+	// until both hold their fair share.
+	storeN(a, "/a", 10)
+	storeN(b, "/b", 8)
+
+	stats := cbr.Stats()
+	if stats["a"].Entries != 5 || stats["b"].Entries != 5 {
+		t.Errorf("entries = %d, %d, want 5, 5", stats["a"].Entries, stats["b"].Entries)
+	}
+	if stats["a"].EvictionsSuffered != 5 || stats["b"].EvictionsCaused != 8 {
+		t.Errorf("a suffered %d, b caused %d, want 5, 8", stats["a"].EvictionsSuffered, stats["b"].EvictionsCaused)
+	}
+	if cached(a, "/a/4") || !cached(a, "/a/5") {
+		t.Error("a's least recently used entries should have been evicted")
+	}
+}
+
+func TestBudget_MaxEntries(t *testing.T) {
+	h := newTestHandler(config.CacheConfig{Enabled: true, MaxEntries: 3})
+	storeN(h, "/p", 3)
+
+	// A hit makes /p/0 the most recently used entry.
+	if !cached(h, "/p/0") {
+		t.Fatal("/p/0 not cached")
+	}
+	storeN(h, "/q", 1)
+
+	if cached(h, "/p/1") {
+		t.Error("least recently used entry /p/1 should have been evicted")
+	}
+	for _, p := range []string{"/p/0", "/p/2", "/q/0"} {
+		if !cached(h, p) {
+			t.Errorf("%s should be cached", p)
+		}
+	}
+	if s := h.Stats(); s.Entries != 3 || s.EvictionsCaused != 1 || s.MaxEntries != 3 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestBudget_MaxBytes(t *testing.T) {
+	h := newTestHandler(config.CacheConfig{Enabled: true, MaxBytes: 25})
+	storeN(h, "/p", 3) // 10 bytes each
+
+	if s := h.Stats(); s.Entries != 2 || s.Bytes != 20 {
+		t.Errorf("entries, bytes = %d, %d, want 2, 20", s.Entries, s.Bytes)
+	}
+
+	r := httptest.NewRequest("GET", "/big", nil)
+	h.StoreForRequest(r, h.KeyForRequest(r), &Entry{StatusCode: 200, Body: make([]byte, 26)})
+	if cached(h, "/big") {
+		t.Error("entry larger than max_bytes should not be stored")
+	}
+}
+
+func TestBudget_MaxVariantsPerPath(t *testing.T) {
+	h := newTestHandler(config.CacheConfig{
+		Enabled:            true,
+		KeyHeaders:         []string{"Authorization"},
+		MaxVariantsPerPath: 2,
+	})
+	store := func(path, user string) bool {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Authorization", "Bearer "+user)
+		h.StoreForRequest(r, h.KeyForRequest(r), &Entry{StatusCode: 200, Body: []byte(user)})
+		_, ok := h.Get(r)
+		return ok
+	}
+
+	for _, user := range []string{"alice", "bob"} {
+		if !store("/me", user) {
+			t.Errorf("variant for %s not stored", user)
+		}
+	}
+	if store("/me", "carol") {
+		t.Error("third variant of /me should not be stored")
+	}
+	if !store("/me", "alice") {
+		t.Error("existing variant should be restored")
+	}
+	if !store("/other", "carol") {
+		t.Error("variants of another path are counted separately")
+	}
+
+	if s := h.Stats(); s.VariantCapHits != 1 || s.Entries != 3 {
+		t.Errorf("variant cap hits, entries = %d, %d, want 1, 3", s.VariantCapHits, s.Entries)
+	}
+}
+
+func TestBudget_MaxVariantsPerPath_Vary(t *testing.T) {
+	h := newTestHandler(config.CacheConfig{Enabled: true, MaxVariantsPerPath: 1})
+	store := func(lang string) bool {
+		r := httptest.NewRequest("GET", "/page", nil)
+		r.Header.Set("Accept-Language", lang)
+		h.StoreForRequest(r, h.KeyForRequest(r), &Entry{
+			StatusCode: 200,
+			Headers:    map[string][]string{"Vary": {"Accept-Language"}},
+			Body:       []byte(lang),
+		})
+		_, ok := h.Get(r)
+		return ok
+	}
+
+	if !store("en") {
+		t.Fatal("first variant not stored")
+	}
+	if store("fr") {
+		t.Error("second Vary variant should not be stored")
+	}
+	if !store("en") {
+		t.Error("first variant should still be served")
+	}
+}
+
+func TestBudget_PurgeForgetsEntries(t *testing.T) {
+	cbr := NewCacheByRoute(nil)
+	cbr.AddRoute("a", config.CacheConfig{Enabled: true, MaxSize: 10, Bucket: "shared"})
+	cbr.AddRoute("b", config.CacheConfig{Enabled: true, MaxSize: 10, Bucket: "shared"})
+	storeN(cbr.Lookup("a"), "/a", 4)
+	storeN(cbr.Lookup("b"), "/b", 2)
+
+	if _, ok := cbr.PurgeRoute("a"); !ok {
+		t.Fatal("route a not found")
+	}
+	stats := cbr.Stats()
+	if stats["a"].Entries != 0 || stats["b"].Entries != 2 {
+		t.Errorf("entries after purge = %d, %d, want 0, 2", stats["a"].Entries, stats["b"].Entries)
+	}
+
+	cbr.PurgeAll()
+	if s := cbr.Stats()["b"]; s.Entries != 0 || s.Bytes != 0 {
+		t.Errorf("after purge all: entries %d, bytes %d", s.Entries, s.Bytes)
+	}
+}
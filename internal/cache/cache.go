@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"strconv"
 	"sync/atomic"
 )
 
@@ -10,6 +11,23 @@ type Cache struct {
 	hits         atomic.Int64
 	misses       atomic.Int64
 	notModifieds atomic.Int64
+	byClass      [5]classCounters // index 0=1xx ... 4=5xx
+}
+
+// classCounters tracks cache activity for one status class.
+type classCounters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+	stores atomic.Int64
+}
+
+// class returns the counters for statusCode's class, or nil if it has none.
+func (c *Cache) class(statusCode int) *classCounters {
+	idx := statusCode/100 - 1
+	if idx < 0 || idx >= len(c.byClass) {
+		return nil
+	}
+	return &c.byClass[idx]
 }
 
 // New creates a new Cache backed by the given store.
@@ -24,13 +42,48 @@ func (c *Cache) Get(key string) (*Entry, bool) {
 		c.misses.Add(1)
 		return nil, false
 	}
-	c.hits.Add(1)
+	c.recordHit(entry)
 	return entry, true
 }
 
+// GetFresh retrieves an entry from the cache, counting it as a miss if
+// fresh reports that it has expired.
+func (c *Cache) GetFresh(key string, fresh func(*Entry) bool) (*Entry, bool) {
+	entry, ok := c.store.Get(key)
+	if !ok || !fresh(entry) {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.recordHit(entry)
+	return entry, true
+}
+
+func (c *Cache) recordHit(entry *Entry) {
+	c.hits.Add(1)
+	if cc := c.class(entry.StatusCode); cc != nil {
+		cc.hits.Add(1)
+	}
+}
+
 // Set stores an entry in the cache.
 func (c *Cache) Set(key string, entry *Entry) {
 	c.store.Set(key, entry)
+	c.RecordStore(entry.StatusCode)
+}
+
+// RecordStore counts a stored entry under its status class.
+func (c *Cache) RecordStore(statusCode int) {
+	if cc := c.class(statusCode); cc != nil {
+		cc.stores.Add(1)
+	}
+}
+
+// RecordMiss counts a backend response fetched on a cache miss under its
+// status class.
+func (c *Cache) RecordMiss(statusCode int) {
+	if cc := c.class(statusCode); cc != nil {
+		cc.misses.Add(1)
+	}
 }
 
 // Delete removes a specific key from the cache.
@@ -56,7 +109,7 @@ func (c *Cache) RecordNotModified() {
 // Stats returns cache statistics.
 func (c *Cache) Stats() CacheStats {
 	ss := c.store.Stats()
-	return CacheStats{
+	stats := CacheStats{
 		Size:         ss.Size,
 		MaxSize:      ss.MaxSize,
 		Hits:         c.hits.Load(),
@@ -64,6 +117,21 @@ func (c *Cache) Stats() CacheStats {
 		Evictions:    ss.Evictions,
 		NotModifieds: c.notModifieds.Load(),
 	}
+	for i := range c.byClass {
+		cs := StatusClassStats{
+			Hits:   c.byClass[i].hits.Load(),
+			Misses: c.byClass[i].misses.Load(),
+			Stores: c.byClass[i].stores.Load(),
+		}
+		if cs == (StatusClassStats{}) {
+			continue
+		}
+		if stats.ByStatus == nil {
+			stats.ByStatus = make(map[string]StatusClassStats)
+		}
+		stats.ByStatus[strconv.Itoa(i+1)+"xx"] = cs
+	}
+	return stats
 }
 
 // CacheStats contains cache statistics.
@@ -75,4 +143,30 @@ type CacheStats struct {
 	Evictions    int64  `json:"evictions"`
 	NotModifieds int64  `json:"not_modifieds"`
 	Bucket       string `json:"bucket,omitempty"`
+
+	// NormalizedHits are hits whose path or query key normalization
+	// rewrote: an estimate of the hits normalization gained.
+	NormalizedHits int64 `json:"normalized_hits,omitempty"`
+
+	// The route's own entries and limits. Size and Evictions above cover
+	// the whole store, which a shared bucket splits between routes.
+	Entries            int   `json:"entries"`
+	Bytes              int64 `json:"bytes"`
+	MaxEntries         int   `json:"max_entries,omitempty"`
+	MaxBytes           int64 `json:"max_bytes,omitempty"`
+	MaxVariantsPerPath int   `json:"max_variants_per_path,omitempty"`
+	FairShare          int   `json:"fair_share,omitempty"`       // entries per route before a full bucket evicts from it
+	EvictionsCaused    int64 `json:"evictions_caused"`           // entries evicted to make room for the route's
+	EvictionsSuffered  int64 `json:"evictions_suffered"`         // route entries evicted for its own or other routes' entries
+	VariantCapHits     int64 `json:"variant_cap_hits,omitempty"` // responses not stored because of max_variants_per_path
+
+	ByStatus map[string]StatusClassStats `json:"by_status,omitempty"` // keyed by class, e.g. "2xx"
+}
+
+// StatusClassStats breaks cache activity down for one status class. Misses
+// are attributed to the status the backend returned for the missed request.
+type StatusClassStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Stores int64 `json:"stores"`
 }
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"testing"
 	"time"
+
+	"github.com/wudi/runway/internal/clock"
 )
 
 func TestCacheGetSet(t *testing.T) {
@@ -39,7 +41,8 @@ func TestCacheMiss(t *testing.T) {
 }
 
 func TestCacheExpiry(t *testing.T) {
-	c := New(NewMemoryStore(10, 10*time.Millisecond))
+	clk := clock.NewFake(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := New(newMemoryStore(10, 10*time.Millisecond, clk))
 
 	entry := &Entry{
 		StatusCode: 200,
@@ -48,7 +51,12 @@ func TestCacheExpiry(t *testing.T) {
 
 	c.Set("expired", entry)
 
-	time.Sleep(50 * time.Millisecond)
+	clk.Advance(9 * time.Millisecond)
+	if _, ok := c.Get("expired"); !ok {
+		t.Fatal("expected cache hit before the TTL")
+	}
+
+	clk.Advance(time.Millisecond)
 
 	_, ok := c.Get("expired")
 	if ok {
@@ -8,17 +8,25 @@ import (
 	"io"
 	"net/http"
 	"path"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	expirable "github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/redis/go-redis/v9"
 
 	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/cachekey"
+	"github.com/wudi/runway/internal/clock"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/graphql"
 	"github.com/wudi/runway/internal/middleware/tenant"
+	"github.com/wudi/runway/internal/storecrypt"
+	"github.com/wudi/runway/variables"
 )
 
 // Entry represents a cached response.
@@ -32,8 +40,16 @@ type Entry struct {
 	Tags         []string      // cache tags for tag-based purge
 	Path         string        // original request path for pattern-based purge
 	TTL          time.Duration // per-entry TTL override (0 = use handler default)
+
+	// Vary is set on a vary marker: the entry stored under a base key whose
+	// responses vary on these request headers. Markers are never served.
+	Vary []string
 }
 
+// DefaultMaxVaries is the number of request headers a response may Vary on
+// and still be cached.
+const DefaultMaxVaries = 4
+
 // Handler manages caching for a single route.
 type Handler struct {
 	cache                *Cache
@@ -44,16 +60,41 @@ type Handler struct {
 	conditional          bool
 	staleWhileRevalidate time.Duration
 	staleIfError         time.Duration
+	softTimeout          time.Duration // 0 unless serve_stale_on_timeout is set
 	revalidating         sync.Map // key dedup for background refresh
 	pathIndex            map[string]map[string]struct{} // path → set of cache keys
 	pathMu               sync.RWMutex
 	tagHeaders           []string // response headers to extract tags from
 	staticTags           []string // static tags for all entries
 	Bucket               string   // shared bucket name (empty if dedicated store)
+	routeTag             string   // store tag of the route's entries in a shared bucket
+
+	maxVaries int                              // Vary headers a cacheable response may name
+	varies    *expirable.LRU[string, []string] // base key → Vary header names
+
+	// Per-status cacheability. When both are empty, any 2xx is cached with
+	// the default TTL. Values are resolved TTLs (never 0).
+	statusTTLs   map[int]time.Duration // exact status code → TTL
+	classTTLs    map[int]time.Duration // status class (2 for 2xx) → TTL
+	negative     bool                  // any 4xx/5xx status is cacheable
+	writeThrough bool                  // successful writes purge the GET entry
+
+	normalizer     *cachekey.Normalizer // nil when keys are not normalized
+	normalizedHits atomic.Int64         // hits whose key normalization rewrote
+
+	budget *budget // the route's entries and bytes in its store
+
+	clock clock.Clock // ages entries
 }
 
 // NewHandler creates a new cache handler for a route with the given store backend.
 func NewHandler(cfg config.CacheConfig, store Store) *Handler {
+	return newHandler(cfg, newPool(store), "")
+}
+
+// newHandler creates the cache handler of route, accounting for its entries
+// in p.
+func newHandler(cfg config.CacheConfig, p *pool, route string) *Handler {
 	methods := cfg.Methods
 	if len(methods) == 0 {
 		methods = []string{"GET"}
@@ -79,8 +120,35 @@ func NewHandler(cfg config.CacheConfig, store Store) *Handler {
 	copy(keyHeaders, cfg.KeyHeaders)
 	sort.Strings(keyHeaders)
 
+	statusTTLs, classTTLs := parseStatusTTLs(cfg.StatusTTLs, ttl)
+	negative := false
+	for code := range statusTTLs {
+		negative = negative || code >= 400
+	}
+	for class := range classTTLs {
+		negative = negative || class >= 4
+	}
+
+	var softTimeout time.Duration
+	if cfg.ServeStaleOnTimeout {
+		softTimeout = cfg.SoftTimeout
+	}
+
+	maxVaries := cfg.MaxVaries
+	if maxVaries <= 0 {
+		maxVaries = DefaultMaxVaries
+	}
+	variesSize := cfg.MaxSize
+	if variesSize <= 0 {
+		variesSize = 1000
+	}
+
 	return &Handler{
-		cache:                New(store),
+		statusTTLs:           statusTTLs,
+		classTTLs:            classTTLs,
+		negative:             negative,
+		writeThrough:         cfg.WriteThroughInvalidation,
+		cache:                New(p.store),
 		ttl:                  ttl,
 		maxBodySize:          maxBodySize,
 		keyHeaders:           keyHeaders,
@@ -88,15 +156,62 @@ func NewHandler(cfg config.CacheConfig, store Store) *Handler {
 		conditional:          cfg.Conditional,
 		staleWhileRevalidate: cfg.StaleWhileRevalidate,
 		staleIfError:         cfg.StaleIfError,
+		softTimeout:          softTimeout,
 		pathIndex:            make(map[string]map[string]struct{}),
 		tagHeaders:           cfg.TagHeaders,
 		staticTags:           cfg.Tags,
+		normalizer:           cachekey.New(cfg.KeyNormalization),
+		maxVaries:            maxVaries,
+		varies:               expirable.NewLRU[string, []string](variesSize, nil, 0),
+		budget:               p.join(route, cfg.MaxEntries, cfg.MaxBytes, cfg.MaxVariantsPerPath),
+		clock:                clock.Default(),
+	}
+}
+
+// parseStatusTTLs splits configured status TTLs into exact codes and
+// classes, replacing unset TTLs with the default.
+func parseStatusTTLs(cfg map[string]time.Duration, def time.Duration) (map[int]time.Duration, map[int]time.Duration) {
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+	codes := make(map[int]time.Duration)
+	classes := make(map[int]time.Duration)
+	for status, ttl := range cfg {
+		if ttl <= 0 {
+			ttl = def
+		}
+		if len(status) == 3 && strings.HasSuffix(status, "xx") {
+			if class, err := strconv.Atoi(status[:1]); err == nil {
+				classes[class] = ttl
+			}
+			continue
+		}
+		if code, err := strconv.Atoi(status); err == nil {
+			codes[code] = ttl
+		}
 	}
+	return codes, classes
+}
+
+// statusTTL returns the TTL for a response status and whether that status
+// is cacheable at all. Exact codes take precedence over classes.
+func (h *Handler) statusTTL(statusCode int) (time.Duration, bool) {
+	if len(h.statusTTLs) == 0 && len(h.classTTLs) == 0 {
+		return h.ttl, statusCode >= 200 && statusCode < 300
+	}
+	if ttl, ok := h.statusTTLs[statusCode]; ok {
+		return ttl, true
+	}
+	if ttl, ok := h.classTTLs[statusCode/100]; ok {
+		return ttl, true
+	}
+	return h.ttl, statusCode == http.StatusOK
 }
 
 // BuildKey constructs a cache key from the request.
 // keyHeaders must already be sorted (done at construction time).
 // If a tenant is resolved, its ID is prepended to isolate cache entries per tenant.
+// The path and query are normalized when key_normalization is configured.
 func (h *Handler) BuildKey(r *http.Request, keyHeaders []string) string {
 	hash := sha256.New()
 	if ti := tenant.FromContext(r.Context()); ti != nil {
@@ -105,10 +220,20 @@ func (h *Handler) BuildKey(r *http.Request, keyHeaders []string) string {
 	}
 	io.WriteString(hash, r.Method)
 	hash.Write([]byte{'|'})
-	io.WriteString(hash, r.URL.Path)
-	if r.URL.RawQuery != "" {
-		hash.Write([]byte{'?'})
-		io.WriteString(hash, r.URL.RawQuery)
+	if h.normalizer != nil {
+		k := h.normalizer.Normalize(r.URL)
+		hash.Write(k.Path())
+		if q := k.Query(); len(q) > 0 {
+			hash.Write([]byte{'?'})
+			hash.Write(q)
+		}
+		k.Release()
+	} else {
+		io.WriteString(hash, r.URL.Path)
+		if r.URL.RawQuery != "" {
+			hash.Write([]byte{'?'})
+			io.WriteString(hash, r.URL.RawQuery)
+		}
 	}
 
 	for _, hdr := range keyHeaders {
@@ -156,8 +281,8 @@ cacheControlCheck:
 
 // ShouldStore checks if the response should be stored in cache.
 func (h *Handler) ShouldStore(statusCode int, headers http.Header, bodySize int64) bool {
-	// Only cache successful responses
-	if statusCode < 200 || statusCode >= 300 {
+	// Only cache successful responses, or the statuses listed in status_ttls
+	if _, ok := h.statusTTL(statusCode); !ok {
 		return false
 	}
 
@@ -172,18 +297,116 @@ func (h *Handler) ShouldStore(statusCode int, headers http.Header, bodySize int6
 		return false
 	}
 
+	// Vary: * and responses varying on too many headers are not cached
+	if names, ok := varyNames(headers); !ok || len(names) > h.maxVaries {
+		return false
+	}
+
 	return true
 }
 
+// varyNames returns the sorted, canonical request header names a response
+// varies on. It reports false for Vary: *, which no key can represent.
+func varyNames(headers http.Header) ([]string, bool) {
+	values := headers.Values("Vary")
+	if len(values) == 0 {
+		return nil, true
+	}
+	var names []string
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	sort.Strings(names)
+	return slices.Compact(names), true
+}
+
+// variantKey returns the key of r's variant of the responses stored under
+// base that vary on the given request headers.
+func variantKey(base string, r *http.Request, names []string) string {
+	hash := sha256.New()
+	for _, name := range names {
+		io.WriteString(hash, name)
+		hash.Write([]byte{'='})
+		io.WriteString(hash, strings.Join(r.Header.Values(name), ","))
+		hash.Write([]byte{'|'})
+	}
+	return base + "|" + hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
 // Get retrieves a cached response.
 func (h *Handler) Get(r *http.Request) (*Entry, bool) {
-	key := h.BuildKey(r, h.keyHeaders)
-	return h.cache.Get(key)
+	key := h.KeyForRequest(r)
+	e, ok := h.lookup(key, func(e *Entry) bool {
+		return h.usable(key, e) && h.Age(e) <= h.EntryTTL(e)
+	})
+	if ok {
+		h.RecordNormalizedHit(r)
+	}
+	return e, ok
+}
+
+// RecordNormalizedHit counts a hit for r if key normalization rewrote its
+// path or query. Such hits estimate what normalization gains: without it,
+// r would only hit if the exact same URL had been cached before.
+func (h *Handler) RecordNormalizedHit(r *http.Request) {
+	if h.normalizer == nil {
+		return
+	}
+	k := h.normalizer.Normalize(r.URL)
+	if k.Changed() {
+		h.normalizedHits.Add(1)
+	}
+	k.Release()
 }
 
 // KeyForRequest returns the cache key for a request using the handler's configured key headers.
+// When the responses for the request's key vary on request headers, the key
+// of the request's variant is returned.
 func (h *Handler) KeyForRequest(r *http.Request) string {
-	return h.BuildKey(r, h.keyHeaders)
+	key := h.BuildKey(r, h.keyHeaders)
+	if names, ok := h.varies.Get(key); ok {
+		return variantKey(key, r, names)
+	}
+	return key
+}
+
+// lookup reads key from the cache like Cache.GetFresh, marking hits as
+// recently used in the route's budget and forgetting keys the store no
+// longer holds.
+func (h *Handler) lookup(key string, fresh func(*Entry) bool) (*Entry, bool) {
+	found := false
+	e, ok := h.cache.GetFresh(key, func(e *Entry) bool {
+		found = true
+		return fresh(e)
+	})
+	switch {
+	case ok:
+		h.budget.touch(key)
+	case !found:
+		h.budget.forget(key)
+	}
+	return e, ok
+}
+
+// usable reports whether e, stored under key, can answer a request. A vary
+// marker cannot: its header names are remembered so that KeyForRequest
+// selects the variant key from then on. This is how an instance learns of
+// variants another instance stored in a shared store.
+func (h *Handler) usable(key string, e *Entry) bool {
+	if e.Vary == nil {
+		return true
+	}
+	h.varies.Add(key, e.Vary)
+	return false
 }
 
 // GetWithStaleness retrieves a cached response and indicates freshness.
@@ -191,15 +414,12 @@ func (h *Handler) KeyForRequest(r *http.Request) string {
 // (entry, fresh=false, stale=true) for stale-but-usable entries,
 // and (nil, false, false) for cache misses or expired entries.
 func (h *Handler) GetWithStaleness(key string) (entry *Entry, fresh bool, stale bool) {
-	e, ok := h.cache.Get(key)
+	e, ok := h.lookup(key, func(e *Entry) bool { return h.usable(key, e) })
 	if !ok {
 		return nil, false, false
 	}
-	ttl := h.ttl
-	if e.TTL > 0 {
-		ttl = e.TTL
-	}
-	age := time.Since(e.StoredAt)
+	ttl := h.EntryTTL(e)
+	age := h.Age(e)
 	if age <= ttl {
 		return e, true, false
 	}
@@ -215,11 +435,49 @@ func (h *Handler) GetWithStaleness(key string) (entry *Entry, fresh bool, stale
 	return nil, false, false
 }
 
+// GetStale returns any entry the store still holds for the request, ignoring
+// TTL and stale windows. Non-cacheable requests never match.
+func (h *Handler) GetStale(r *http.Request) *Entry {
+	if !h.ShouldCache(r) {
+		return nil
+	}
+	key := h.KeyForRequest(r)
+	e, ok := h.lookup(key, func(e *Entry) bool { return h.usable(key, e) })
+	if !ok {
+		return nil
+	}
+	return e
+}
+
 // TTL returns the cache TTL duration.
 func (h *Handler) TTL() time.Duration {
 	return h.ttl
 }
 
+// Age returns how long ago an entry was stored.
+func (h *Handler) Age(e *Entry) time.Duration {
+	return h.clock.Now().Sub(e.StoredAt)
+}
+
+// EntryTTL returns the TTL that applies to a stored entry.
+func (h *Handler) EntryTTL(e *Entry) time.Duration {
+	if e.TTL > 0 {
+		return e.TTL
+	}
+	return h.ttl
+}
+
+// applyStatusTTL sets the entry's TTL from status_ttls unless it already
+// carries an override.
+func (h *Handler) applyStatusTTL(entry *Entry) {
+	if entry.TTL > 0 {
+		return
+	}
+	if ttl, ok := h.statusTTL(entry.StatusCode); ok && ttl != h.ttl {
+		entry.TTL = ttl
+	}
+}
+
 // HasStaleSupport returns true if stale-while-revalidate or stale-if-error is configured.
 func (h *Handler) HasStaleSupport() bool {
 	return h.staleWhileRevalidate > 0 || h.staleIfError > 0
@@ -235,6 +493,19 @@ func (h *Handler) StaleIfError() time.Duration {
 	return h.staleIfError
 }
 
+// SoftTimeout returns how long a request with a stale-if-error entry waits
+// for the backend before the stale entry is served (0 = wait as usual).
+func (h *Handler) SoftTimeout() time.Duration {
+	return h.softTimeout
+}
+
+// RevalidationPending reports whether a background refresh is in flight for
+// the given key, without claiming it.
+func (h *Handler) RevalidationPending(key string) bool {
+	_, ok := h.revalidating.Load(key)
+	return ok
+}
+
 // IsRevalidating checks if a background revalidation is already in-flight for the given key.
 // Returns true if a revalidation was already running (caller should skip).
 func (h *Handler) IsRevalidating(key string) bool {
@@ -249,36 +520,116 @@ func (h *Handler) DoneRevalidating(key string) {
 
 // StoreByKey stores a response entry using a pre-computed cache key.
 func (h *Handler) StoreByKey(key string, entry *Entry) {
-	entry.StoredAt = time.Now()
-	h.cache.Set(key, entry)
+	entry.StoredAt = h.clock.Now()
+	h.applyStatusTTL(entry)
+	h.admit(key, "", entry, func() { h.cache.Set(key, entry) })
 }
 
 // Store stores a response in the cache.
 func (h *Handler) Store(r *http.Request, entry *Entry) {
-	entry.StoredAt = time.Now()
+	entry.StoredAt = h.clock.Now()
+	h.applyStatusTTL(entry)
 	key := h.BuildKey(r, h.keyHeaders)
-	h.cache.Set(key, entry)
+	h.admit(key, h.variantGroup(r), entry, func() { h.cache.Set(key, entry) })
+}
+
+// admit stores entry under key through the route's budget, which makes
+// room for it and calls set unless it does not fit (see budget.store).
+func (h *Handler) admit(key, group string, entry *Entry, set func()) bool {
+	return h.budget.store(key, group, int64(len(entry.Body)), h.clock.Now(), set)
+}
+
+// variantGroup returns the URL whose variants the response to r counts
+// toward for max_variants_per_path: the method, path and query, leaving
+// out what else tells variants apart (key_headers, Vary and the tenant).
+// It is empty when variants are not capped.
+func (h *Handler) variantGroup(r *http.Request) string {
+	if h.budget.maxVariants == 0 {
+		return ""
+	}
+	if h.normalizer != nil {
+		k := h.normalizer.Normalize(r.URL)
+		g := r.Method + " " + string(k.Path()) + "?" + string(k.Query())
+		k.Release()
+		return g
+	}
+	return r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery
+}
+
+// StoreForRequest stores the response to r under key, the key
+// KeyForRequest returned for r. A response that varies on request headers
+// is stored under the key of r's variant, with a vary marker under the base
+// key so lookups for the base key select variants.
+func (h *Handler) StoreForRequest(r *http.Request, key string, entry *Entry) {
+	base, _, _ := strings.Cut(key, "|")
+	names, _ := varyNames(entry.Headers)
+	group := h.variantGroup(r)
+	if len(names) == 0 {
+		h.varies.Remove(base)
+		h.storeWithMeta(base, group, r.URL.Path, entry)
+		return
+	}
+	vkey := variantKey(base, r, names)
+	if !h.budget.admitsVariant(vkey, group) {
+		return
+	}
+	h.varies.Add(base, names)
+	// The marker carries no tags, so purges count only servable entries. A
+	// marker left behind only costs a miss once its variants are gone.
+	marker := &Entry{Vary: names, StoredAt: h.clock.Now(), Path: r.URL.Path}
+	h.admit(base, "", marker, func() { h.setWithTags(base, marker, nil) })
+	h.storeWithMeta(vkey, group, r.URL.Path, entry)
+}
+
+// tagSetter is implemented by stores that index entries by tag.
+type tagSetter interface {
+	SetWithTags(key string, entry *Entry, tags []string)
+}
+
+// setWithTags stores an entry with tags, or without them when the store
+// keeps no tag index.
+func (h *Handler) setWithTags(key string, entry *Entry, tags []string) {
+	if ts, ok := h.cache.store.(tagSetter); ok {
+		ts.SetWithTags(key, entry, tags)
+		return
+	}
+	h.cache.store.Set(key, entry)
 }
 
 // StoreWithMeta stores a response in the cache with tag and path metadata.
 // It extracts tags from configured response headers and static tags,
 // and maintains a path→key reverse index for pattern-based purge.
 func (h *Handler) StoreWithMeta(key, reqPath string, entry *Entry) {
-	entry.StoredAt = time.Now()
+	h.storeWithMeta(key, "", reqPath, entry)
+}
+
+// storeWithMeta is StoreWithMeta for an entry in variant group group (see
+// variantGroup). Entries that do not fit the route's budget are dropped.
+func (h *Handler) storeWithMeta(key, group, reqPath string, entry *Entry) {
+	entry.StoredAt = h.clock.Now()
 	entry.Path = reqPath
+	h.applyStatusTTL(entry)
 
 	// Collect tags: static tags + header-extracted tags
 	tags := h.extractTags(entry.Headers)
 	entry.Tags = tags
 
-	// Store with tags
-	type tagSetter interface {
-		SetWithTags(key string, entry *Entry, tags []string)
+	// Store with tags. Entries in a shared bucket also carry the route's
+	// tag, so a route purge leaves other routes' entries alone.
+	storeTags := tags
+	if h.routeTag != "" {
+		storeTags = append(slices.Clip(tags), h.routeTag)
 	}
-	if ts, ok := h.cache.store.(tagSetter); ok && len(tags) > 0 {
-		ts.SetWithTags(key, entry, tags)
-	} else {
-		h.cache.Set(key, entry)
+	stored := h.admit(key, group, entry, func() {
+		if len(storeTags) > 0 {
+			h.setWithTags(key, entry, storeTags)
+			h.cache.RecordStore(entry.StatusCode)
+		} else {
+			h.cache.Set(key, entry)
+		}
+	})
+	if !stored {
+		return
 	}
 
 	// Update path index
@@ -325,7 +676,7 @@ func (h *Handler) PurgeByPathPattern(pattern string) int {
 			continue
 		}
 		for key := range keys {
-			h.cache.Delete(key)
+			h.delete(key)
 			count++
 		}
 		delete(h.pathIndex, p)
@@ -337,8 +688,13 @@ func (h *Handler) PurgeByPathPattern(pattern string) int {
 // Returns count of purged entries.
 func (h *Handler) PurgeByTags(tags []string) int {
 	count := h.cache.store.DeleteByTags(tags)
+	h.prunePathIndex()
+	return count
+}
 
-	// Clean up path index entries for deleted keys
+// prunePathIndex drops path index entries whose keys the store no longer
+// holds.
+func (h *Handler) prunePathIndex() {
 	// We can't know exactly which keys were deleted, so we rebuild lazily
 	// by removing path entries pointing to keys that no longer exist in the store.
 	h.pathMu.Lock()
@@ -346,6 +702,7 @@ func (h *Handler) PurgeByTags(tags []string) int {
 		for key := range keys {
 			if _, ok := h.cache.store.Get(key); !ok {
 				delete(keys, key)
+				h.budget.forget(key)
 			}
 		}
 		if len(keys) == 0 {
@@ -353,8 +710,6 @@ func (h *Handler) PurgeByTags(tags []string) int {
 		}
 	}
 	h.pathMu.Unlock()
-
-	return count
 }
 
 // InvalidateByPath invalidates cache entries matching the request path prefix.
@@ -364,14 +719,85 @@ func (h *Handler) InvalidateByPath(path string) {
 	h.cache.DeleteByPrefix(path)
 }
 
+// InvalidatesOnWrite reports whether successful writes must purge entries,
+// either because write-through invalidation is on or because negative
+// responses are cached.
+func (h *Handler) InvalidatesOnWrite() bool {
+	return h.writeThrough || h.negative
+}
+
+// InvalidateAfterWrite purges entries for the path of a write request that
+// completed with statusCode. Only successful writes purge: with write-through
+// invalidation every entry for the path goes, otherwise only negative (4xx
+// and 5xx) entries do.
+func (h *Handler) InvalidateAfterWrite(r *http.Request, statusCode int) {
+	if statusCode < 200 || statusCode >= 300 {
+		return
+	}
+
+	get := *r
+	get.Method = http.MethodGet
+	keys := []string{h.BuildKey(&get, h.keyHeaders)}
+	h.pathMu.RLock()
+	for key := range h.pathIndex[r.URL.Path] {
+		if key != keys[0] {
+			keys = append(keys, key)
+		}
+	}
+	h.pathMu.RUnlock()
+
+	for _, key := range keys {
+		if !h.writeThrough {
+			e, ok := h.cache.store.Get(key)
+			if !ok || e.StatusCode < 400 {
+				continue
+			}
+		}
+		h.delete(key)
+	}
+}
+
+// delete removes key from the cache and the route's budget.
+func (h *Handler) delete(key string) {
+	h.cache.Delete(key)
+	h.budget.forget(key)
+}
+
+// RecordMiss counts the status of a response fetched on a cache miss.
+func (h *Handler) RecordMiss(statusCode int) {
+	h.cache.RecordMiss(statusCode)
+}
+
 // Stats returns cache statistics.
 func (h *Handler) Stats() CacheStats {
-	return h.cache.Stats()
+	stats := h.cache.Stats()
+	stats.NormalizedHits = h.normalizedHits.Load()
+	h.budget.stats(&stats, h.clock.Now())
+	return stats
 }
 
 // Purge clears all cache entries.
 func (h *Handler) Purge() {
 	h.cache.Purge()
+	h.budget.pool.clear()
+	h.varies.Purge()
+}
+
+// PurgeRoute removes the route's entries and returns how many it removed.
+// In a shared bucket only the entries the route stored are removed.
+func (h *Handler) PurgeRoute() int {
+	if h.routeTag == "" {
+		size := h.Stats().Size
+		h.Purge()
+		return size
+	}
+	count := h.cache.store.DeleteByTags([]string{h.routeTag})
+	h.budget.clear()
+	h.varies.Purge()
+	h.pathMu.Lock()
+	clear(h.pathIndex)
+	h.pathMu.Unlock()
+	return count
 }
 
 // IsMutatingMethod returns true if the HTTP method may mutate resources.
@@ -552,7 +978,7 @@ func PopulateConditionalFields(entry *Entry) {
 			return
 		}
 	}
-	entry.LastModified = time.Now().Truncate(time.Second)
+	entry.LastModified = clock.Default().Now().Truncate(time.Second)
 }
 
 // CheckConditional checks request conditional headers against a cache entry.
@@ -602,6 +1028,9 @@ func WriteCachedResponse(w http.ResponseWriter, r *http.Request, entry *Entry, c
 		}
 	}
 	w.Header().Set("X-Cache", "HIT")
+	if varCtx := variables.GetFromRequest(r); varCtx != nil {
+		varCtx.Relayed = true
+	}
 
 	if conditional {
 		// Inject conditional headers
@@ -629,9 +1058,10 @@ func WriteCachedResponse(w http.ResponseWriter, r *http.Request, entry *Entry, c
 // CacheByRoute manages cache handlers per route.
 type CacheByRoute struct {
 	byroute.Manager[*Handler]
-	storeMu      sync.Mutex // protects bucketStores and redisClient during AddRoute
-	bucketStores map[string]Store
+	storeMu      sync.Mutex // protects bucketPools, redisClient and keys during AddRoute
+	bucketPools  map[string]*pool
 	redisClient  *redis.Client
+	keys         *storecrypt.Keyring // seals entries of routes with encrypt: true
 }
 
 // NewCacheByRoute creates a new route-based cache manager.
@@ -649,12 +1079,20 @@ func (cbr *CacheByRoute) SetRedisClient(client *redis.Client) {
 	cbr.redisClient = client
 }
 
+// SetKeyring sets the keyring that seals distributed entries of routes with
+// encrypt: true.
+func (cbr *CacheByRoute) SetKeyring(keys *storecrypt.Keyring) {
+	cbr.storeMu.Lock()
+	defer cbr.storeMu.Unlock()
+	cbr.keys = keys
+}
+
 // AddRoute adds a cache handler for a route.
 func (cbr *CacheByRoute) AddRoute(routeID string, cfg config.CacheConfig) {
 	cbr.storeMu.Lock()
 
-	if cbr.bucketStores == nil {
-		cbr.bucketStores = make(map[string]Store)
+	if cbr.bucketPools == nil {
+		cbr.bucketPools = make(map[string]*pool)
 	}
 
 	ttl := cfg.TTL
@@ -662,38 +1100,51 @@ func (cbr *CacheByRoute) AddRoute(routeID string, cfg config.CacheConfig) {
 		ttl = 60 * time.Second
 	}
 
-	// Extend store TTL to cover stale window so entries survive beyond the fresh period.
+	// Extend store TTL to cover the longest per-status TTL and the stale
+	// window so entries survive beyond the fresh period.
 	storeTTL := ttl
+	for _, statusTTL := range cfg.StatusTTLs {
+		if statusTTL > storeTTL {
+			storeTTL = statusTTL
+		}
+	}
 	staleMax := cfg.StaleWhileRevalidate
 	if cfg.StaleIfError > staleMax {
 		staleMax = cfg.StaleIfError
 	}
 	storeTTL += staleMax
 
-	var store Store
+	var p *pool
 	if cfg.Bucket != "" {
 		// Shared bucket mode — reuse store if already created
-		if existing, ok := cbr.bucketStores[cfg.Bucket]; ok {
-			store = existing
+		if existing, ok := cbr.bucketPools[cfg.Bucket]; ok {
+			p = existing
 		} else {
-			store = cbr.createStore(cfg, "gw:cache:bucket:"+cfg.Bucket+":", storeTTL)
-			cbr.bucketStores[cfg.Bucket] = store
+			p = newPool(cbr.createStore(cfg, "gw:cache:bucket:"+cfg.Bucket+":", storeTTL))
+			cbr.bucketPools[cfg.Bucket] = p
 		}
 	} else {
-		store = cbr.createStore(cfg, "gw:cache:"+routeID+":", storeTTL)
+		p = newPool(cbr.createStore(cfg, "gw:cache:"+routeID+":", storeTTL))
 	}
 
 	cbr.storeMu.Unlock()
 
-	h := NewHandler(cfg, store)
+	h := newHandler(cfg, p, routeID)
 	h.Bucket = cfg.Bucket
+	if cfg.Bucket != "" {
+		h.routeTag = routeTag(routeID)
+	}
 	cbr.Add(routeID, h)
 }
 
 // createStore creates a Store based on config mode.
 func (cbr *CacheByRoute) createStore(cfg config.CacheConfig, redisPrefix string, ttl time.Duration) Store {
 	if cfg.Mode == "distributed" && cbr.redisClient != nil {
-		return NewRedisStore(cbr.redisClient, redisPrefix, ttl)
+		store := NewRedisStore(cbr.redisClient, redisPrefix, ttl)
+		if cfg.Encrypt {
+			store.keys = cbr.keys
+		}
+		return store
 	}
 	maxSize := cfg.MaxSize
 	if maxSize <= 0 {
@@ -703,15 +1154,43 @@ func (cbr *CacheByRoute) createStore(cfg config.CacheConfig, redisPrefix string,
 }
 
 
-// PurgeRoute purges all cache entries for a specific route. Returns pre-purge size and whether the route was found.
+// routeTag returns the store tag of a route's entries in a shared bucket.
+// NUL never appears in tags taken from response headers.
+func routeTag(routeID string) string {
+	return "\x00route:" + routeID
+}
+
+// PurgeRoute purges all cache entries for a specific route. Returns the
+// number of entries removed and whether the route was found. In a shared
+// bucket, other routes' entries are kept.
 func (cbr *CacheByRoute) PurgeRoute(routeID string) (int, bool) {
 	h := cbr.Lookup(routeID)
 	if h == nil {
 		return 0, false
 	}
-	size := h.Stats().Size
-	h.Purge()
-	return size, true
+	return h.PurgeRoute(), true
+}
+
+// PurgeTag removes the entries tagged tag from every route's store, purging
+// each shared bucket once. Returns the number of entries removed.
+func (cbr *CacheByRoute) PurgeTag(tag string) int {
+	var handlers []*Handler
+	cbr.Range(func(_ string, h *Handler) bool {
+		handlers = append(handlers, h)
+		return true
+	})
+	total := 0
+	purged := make(map[Store]bool)
+	for _, h := range handlers {
+		if !purged[h.cache.store] {
+			purged[h.cache.store] = true
+			total += h.cache.store.DeleteByTags([]string{tag})
+		}
+	}
+	for _, h := range handlers {
+		h.prunePathIndex()
+	}
+	return total
 }
 
 // PurgeRouteKey deletes a specific key from a route's cache. Returns true if the route was found.
@@ -720,7 +1199,7 @@ func (cbr *CacheByRoute) PurgeRouteKey(routeID, key string) bool {
 	if h == nil {
 		return false
 	}
-	h.cache.Delete(key)
+	h.delete(key)
 	return true
 }
 
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/clock"
 )
 
 func newTestHandler(cfg config.CacheConfig) *Handler {
@@ -22,6 +23,15 @@ func newTestHandler(cfg config.CacheConfig) *Handler {
 	return NewHandler(cfg, NewMemoryStore(maxSize, ttl))
 }
 
+// newFakeClockHandler returns a handler whose entries age only when the
+// returned clock is advanced.
+func newFakeClockHandler(cfg config.CacheConfig, storeTTL time.Duration) (*Handler, *clock.Fake) {
+	clk := clock.NewFake(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	h := NewHandler(cfg, newMemoryStore(100, storeTTL, clk))
+	h.clock = clk
+	return h, clk
+}
+
 func TestHandlerShouldCache(t *testing.T) {
 	h := newTestHandler(config.CacheConfig{
 		Enabled: true,
@@ -162,6 +172,36 @@ func TestHandlerGetAndStore(t *testing.T) {
 	}
 }
 
+func TestHandlerKeyNormalization(t *testing.T) {
+	h := newTestHandler(config.CacheConfig{
+		Enabled: true,
+		KeyNormalization: config.CacheKeyNormalizationConfig{
+			SortQuery:          true,
+			IgnoreQueryParams:  []string{"utm_*", "fbclid"},
+			LowercasePath:      true,
+			StripTrailingSlash: true,
+		},
+	})
+
+	h.Store(httptest.NewRequest("GET", "/products?color=red&size=42", nil), &Entry{StatusCode: 200, Body: []byte("ok")})
+
+	for _, target := range []string{
+		"/products?color=red&size=42",
+		"/Products/?size=42&utm_source=news&color=red&fbclid=x",
+	} {
+		if _, ok := h.Get(httptest.NewRequest("GET", target, nil)); !ok {
+			t.Errorf("expected %s to hit", target)
+		}
+	}
+	if _, ok := h.Get(httptest.NewRequest("GET", "/products?color=blue&size=42", nil)); ok {
+		t.Error("different param values must not share a key")
+	}
+
+	if got := h.Stats().NormalizedHits; got != 1 {
+		t.Errorf("expected 1 normalized hit, got %d", got)
+	}
+}
+
 func TestWriteCachedResponse(t *testing.T) {
 	entry := &Entry{
 		StatusCode: 200,
@@ -774,14 +814,13 @@ func TestGetWithStaleness_Stale(t *testing.T) {
 		StaleWhileRevalidate: 5 * time.Second,
 		StaleIfError:         5 * time.Second,
 	}
-	store := NewMemoryStore(100, cfg.TTL+5*time.Second) // extended TTL for store
-	h := NewHandler(cfg, store)
+	h, clk := newFakeClockHandler(cfg, cfg.TTL+5*time.Second) // extended TTL for store
 
 	req := httptest.NewRequest("GET", "/api/data", nil)
 	h.Store(req, &Entry{StatusCode: 200, Body: []byte(`stale data`)})
 
-	// Wait for fresh TTL to expire
-	time.Sleep(80 * time.Millisecond)
+	// Let the fresh TTL expire
+	clk.Advance(80 * time.Millisecond)
 
 	key := h.KeyForRequest(req)
 	entry, fresh, stale := h.GetWithStaleness(key)
@@ -808,14 +847,13 @@ func TestGetWithStaleness_Expired(t *testing.T) {
 		StaleWhileRevalidate: 30 * time.Millisecond,
 		StaleIfError:         30 * time.Millisecond,
 	}
-	store := NewMemoryStore(100, cfg.TTL+30*time.Millisecond) // extended TTL for store
-	h := NewHandler(cfg, store)
+	h, clk := newFakeClockHandler(cfg, cfg.TTL+30*time.Millisecond) // extended TTL for store
 
 	req := httptest.NewRequest("GET", "/api/data", nil)
 	h.Store(req, &Entry{StatusCode: 200, Body: []byte(`old data`)})
 
-	// Wait for both TTL and stale window to expire
-	time.Sleep(100 * time.Millisecond)
+	// Let both TTL and stale window expire
+	clk.Advance(100 * time.Millisecond)
 
 	key := h.KeyForRequest(req)
 	entry, fresh, stale := h.GetWithStaleness(key)
@@ -1001,14 +1039,13 @@ func TestStaleWindow_SWRLargerThanSIE(t *testing.T) {
 		StaleWhileRevalidate: 10 * time.Second,
 		StaleIfError:         2 * time.Second,
 	}
-	store := NewMemoryStore(100, cfg.TTL+10*time.Second)
-	h := NewHandler(cfg, store)
+	h, clk := newFakeClockHandler(cfg, cfg.TTL+10*time.Second)
 
 	req := httptest.NewRequest("GET", "/test", nil)
 	h.Store(req, &Entry{StatusCode: 200, Body: []byte("data")})
 
-	// Wait for fresh TTL to expire
-	time.Sleep(80 * time.Millisecond)
+	// Let the fresh TTL expire
+	clk.Advance(80 * time.Millisecond)
 
 	key := h.KeyForRequest(req)
 	entry, fresh, stale := h.GetWithStaleness(key)
@@ -1025,14 +1062,13 @@ func TestStaleWindow_SIELargerThanSWR(t *testing.T) {
 		StaleWhileRevalidate: 2 * time.Second,
 		StaleIfError:         10 * time.Second,
 	}
-	store := NewMemoryStore(100, cfg.TTL+10*time.Second)
-	h := NewHandler(cfg, store)
+	h, clk := newFakeClockHandler(cfg, cfg.TTL+10*time.Second)
 
 	req := httptest.NewRequest("GET", "/test", nil)
 	h.Store(req, &Entry{StatusCode: 200, Body: []byte("data")})
 
-	// Wait for fresh TTL to expire
-	time.Sleep(80 * time.Millisecond)
+	// Let the fresh TTL expire
+	clk.Advance(80 * time.Millisecond)
 
 	key := h.KeyForRequest(req)
 	entry, fresh, stale := h.GetWithStaleness(key)
@@ -1043,6 +1079,9 @@ func TestStaleWindow_SIELargerThanSWR(t *testing.T) {
 
 func TestCacheByRoute_ExtendedStoreTTL(t *testing.T) {
 	// Verify that the store TTL is extended to cover the stale window
+	clk := clock.NewFake(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	clock.SetDefault(clk)
+	defer clock.SetDefault(clock.Real{})
 	cbr := NewCacheByRoute(nil)
 
 	cbr.AddRoute("route1", config.CacheConfig{
@@ -1061,8 +1100,8 @@ func TestCacheByRoute_ExtendedStoreTTL(t *testing.T) {
 	req := httptest.NewRequest("GET", "/test", nil)
 	h.Store(req, &Entry{StatusCode: 200, Body: []byte("test")})
 
-	// Wait for fresh TTL to expire but within stale window
-	time.Sleep(80 * time.Millisecond)
+	// Let the fresh TTL expire but stay within the stale window
+	clk.Advance(80 * time.Millisecond)
 
 	// The entry should still be retrievable (store TTL extended)
 	key := h.KeyForRequest(req)
@@ -1319,3 +1358,246 @@ func TestCacheByRouteDistributedFallback(t *testing.T) {
 		t.Errorf("expected 200, got %d", got.StatusCode)
 	}
 }
+
+func TestHandlerStatusTTLs(t *testing.T) {
+	h := newTestHandler(config.CacheConfig{
+		Enabled: true,
+		TTL:     time.Minute,
+		StatusTTLs: map[string]time.Duration{
+			"301": time.Hour,
+			"4xx": 30 * time.Second,
+			"404": 10 * time.Second,
+			"410": 0,
+		},
+	})
+
+	tests := []struct {
+		status    int
+		cacheable bool
+		ttl       time.Duration
+	}{
+		{200, true, time.Minute}, // 200 stays cacheable with the default TTL
+		{201, false, 0},          // other unlisted statuses are not cacheable
+		{301, true, time.Hour},
+		{404, true, 10 * time.Second}, // exact code wins over class
+		{403, true, 30 * time.Second},
+		{410, true, time.Minute}, // listed without a value uses ttl
+		{500, false, 0},
+	}
+	for _, tt := range tests {
+		if got := h.ShouldStore(tt.status, http.Header{}, 10); got != tt.cacheable {
+			t.Errorf("ShouldStore(%d) = %v, want %v", tt.status, got, tt.cacheable)
+		}
+		if !tt.cacheable {
+			continue
+		}
+		entry := &Entry{StatusCode: tt.status}
+		h.StoreByKey("k", entry)
+		if got := h.EntryTTL(entry); got != tt.ttl {
+			t.Errorf("EntryTTL(%d) = %v, want %v", tt.status, got, tt.ttl)
+		}
+	}
+}
+
+func TestHandlerGet_ExpiresByStatusTTL(t *testing.T) {
+	h, clk := newFakeClockHandler(config.CacheConfig{
+		Enabled:    true,
+		TTL:        time.Minute,
+		StatusTTLs: map[string]time.Duration{"404": 50 * time.Millisecond},
+	}, time.Minute)
+	req := httptest.NewRequest("GET", "/missing", nil)
+	h.Store(req, &Entry{StatusCode: 404, Headers: http.Header{}})
+
+	if _, ok := h.Get(req); !ok {
+		t.Fatal("expected fresh negative entry to hit")
+	}
+	clk.Advance(80 * time.Millisecond)
+	if _, ok := h.Get(req); ok {
+		t.Fatal("expected negative entry to expire after its status TTL")
+	}
+}
+
+func TestHandlerInvalidateAfterWrite(t *testing.T) {
+	for _, writeThrough := range []bool{false, true} {
+		h := newTestHandler(config.CacheConfig{
+			Enabled:                  true,
+			StatusTTLs:               map[string]time.Duration{"404": time.Minute},
+			WriteThroughInvalidation: writeThrough,
+		})
+		if !h.InvalidatesOnWrite() {
+			t.Fatal("negative caching should invalidate on write")
+		}
+
+		get := httptest.NewRequest("GET", "/items/1", nil)
+		other := httptest.NewRequest("GET", "/items/2", nil)
+		h.StoreWithMeta(h.KeyForRequest(get), "/items/1", &Entry{StatusCode: 404, Headers: http.Header{}})
+		h.StoreWithMeta(h.KeyForRequest(other), "/items/2", &Entry{StatusCode: 200, Headers: http.Header{}})
+
+		// A failed write purges nothing.
+		h.InvalidateAfterWrite(httptest.NewRequest("PUT", "/items/1", nil), 500)
+		if _, ok := h.Get(get); !ok {
+			t.Fatalf("writeThrough=%v: failed write must not purge", writeThrough)
+		}
+
+		h.InvalidateAfterWrite(httptest.NewRequest("PUT", "/items/1", nil), 201)
+		if _, ok := h.Get(get); ok {
+			t.Errorf("writeThrough=%v: negative entry should be purged by a successful write", writeThrough)
+		}
+
+		h.InvalidateAfterWrite(httptest.NewRequest("DELETE", "/items/2", nil), 204)
+		_, ok := h.Get(other)
+		if writeThrough == ok {
+			t.Errorf("writeThrough=%v: positive entry present=%v after successful write", writeThrough, ok)
+		}
+	}
+
+	if newTestHandler(config.CacheConfig{Enabled: true}).InvalidatesOnWrite() {
+		t.Error("default config should not invalidate on write")
+	}
+}
+
+func TestCacheStatsByStatusClass(t *testing.T) {
+	h := newTestHandler(config.CacheConfig{
+		Enabled:    true,
+		StatusTTLs: map[string]time.Duration{"404": time.Minute},
+	})
+	ok := httptest.NewRequest("GET", "/ok", nil)
+	missing := httptest.NewRequest("GET", "/missing", nil)
+
+	h.RecordMiss(200)
+	h.Store(ok, &Entry{StatusCode: 200, Headers: http.Header{}})
+	h.RecordMiss(404)
+	h.Store(missing, &Entry{StatusCode: 404, Headers: http.Header{}})
+	h.Get(missing)
+	h.Get(missing)
+	h.Get(ok)
+
+	stats := h.Stats()
+	want := map[string]StatusClassStats{
+		"2xx": {Hits: 1, Misses: 1, Stores: 1},
+		"4xx": {Hits: 2, Misses: 1, Stores: 1},
+	}
+	if len(stats.ByStatus) != len(want) {
+		t.Fatalf("unexpected by_status: %+v", stats.ByStatus)
+	}
+	for class, w := range want {
+		if stats.ByStatus[class] != w {
+			t.Errorf("%s: got %+v, want %+v", class, stats.ByStatus[class], w)
+		}
+	}
+}
+
+func TestHandler_VaryKeys(t *testing.T) {
+	h := newTestHandler(config.CacheConfig{Enabled: true})
+	request := func(lang string) *http.Request {
+		req := httptest.NewRequest("GET", "/greeting", nil)
+		if lang != "" {
+			req.Header.Set("Accept-Language", lang)
+		}
+		return req
+	}
+	store := func(lang, body string) {
+		req := request(lang)
+		h.StoreForRequest(req, h.KeyForRequest(req), &Entry{
+			StatusCode: 200,
+			Headers:    http.Header{"Vary": {"accept-language"}},
+			Body:       []byte(body),
+		})
+	}
+
+	store("de", "hallo")
+	if e, ok := h.Get(request("de")); !ok || string(e.Body) != "hallo" {
+		t.Fatalf("expected the de variant, got %v %v", e, ok)
+	}
+	if _, ok := h.Get(request("fr")); ok {
+		t.Fatal("a request for another language must not get the de variant")
+	}
+	store("fr", "bonjour")
+	if e, ok := h.Get(request("fr")); !ok || string(e.Body) != "bonjour" {
+		t.Fatalf("expected the fr variant, got %v %v", e, ok)
+	}
+	if e, ok := h.Get(request("de")); !ok || string(e.Body) != "hallo" {
+		t.Fatalf("storing fr must keep the de variant, got %v %v", e, ok)
+	}
+
+	// A handler sharing the store learns the variants from the marker
+	// stored under the base key: its first lookup misses, later ones hit.
+	other := NewHandler(config.CacheConfig{Enabled: true}, h.cache.store)
+	if _, ok := other.Get(request("de")); ok {
+		t.Fatal("the vary marker must not be served")
+	}
+	if e, ok := other.Get(request("de")); !ok || string(e.Body) != "hallo" {
+		t.Fatalf("expected the shared de variant, got %v %v", e, ok)
+	}
+
+	// A response without Vary goes back to the base key.
+	req := request("de")
+	h.StoreForRequest(req, h.KeyForRequest(req), &Entry{StatusCode: 200, Body: []byte("hello")})
+	if e, ok := h.Get(request("")); !ok || string(e.Body) != "hello" {
+		t.Fatalf("expected the unvaried entry, got %v %v", e, ok)
+	}
+}
+
+func TestHandler_ShouldStoreVary(t *testing.T) {
+	h := newTestHandler(config.CacheConfig{Enabled: true, MaxVaries: 2})
+	tests := []struct {
+		vary []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"Accept-Language"}, true},
+		{[]string{"Accept, Accept-Language"}, true},
+		{[]string{"Accept", "accept"}, true},
+		{[]string{"Accept, Accept-Language, Origin"}, false},
+		{[]string{"*"}, false},
+	}
+	for _, tt := range tests {
+		headers := http.Header{}
+		for _, v := range tt.vary {
+			headers.Add("Vary", v)
+		}
+		if got := h.ShouldStore(200, headers, 10); got != tt.want {
+			t.Errorf("Vary %q: ShouldStore = %v, want %v", tt.vary, got, tt.want)
+		}
+	}
+}
+
+func TestCacheByRoute_PurgeSharedBucket(t *testing.T) {
+	cbr := NewCacheByRoute(nil)
+	cbr.AddRoute("products", config.CacheConfig{Enabled: true, Bucket: "catalog", TagHeaders: []string{"Surrogate-Key"}})
+	cbr.AddRoute("prices", config.CacheConfig{Enabled: true, Bucket: "catalog", TagHeaders: []string{"Surrogate-Key"}})
+	cbr.AddRoute("search", config.CacheConfig{Enabled: true, TagHeaders: []string{"Surrogate-Key"}})
+
+	tagged := func(tags string) *Entry {
+		return &Entry{StatusCode: 200, Headers: http.Header{"Surrogate-Key": {tags}}}
+	}
+	cbr.Lookup("products").StoreWithMeta("p1", "/products/1", tagged("sku-1"))
+	cbr.Lookup("products").StoreWithMeta("p2", "/products/2", tagged("sku-2"))
+	cbr.Lookup("prices").StoreWithMeta("c1", "/prices/1", tagged("sku-1"))
+	cbr.Lookup("search").StoreWithMeta("s1", "/search", tagged("sku-1 sku-2"))
+
+	// A tag purge reaches every store, the shared bucket once.
+	if n := cbr.PurgeTag("sku-1"); n != 3 {
+		t.Errorf("expected 3 entries purged for sku-1, got %d", n)
+	}
+	if n := cbr.PurgeTag("sku-1"); n != 0 {
+		t.Errorf("expected nothing left tagged sku-1, got %d", n)
+	}
+
+	// A route purge in a shared bucket keeps the other routes' entries.
+	cbr.Lookup("prices").StoreWithMeta("c2", "/prices/2", tagged("sku-2"))
+	n, ok := cbr.PurgeRoute("products")
+	if !ok || n != 1 {
+		t.Errorf("expected 1 products entry purged, got %d %v", n, ok)
+	}
+	store := cbr.Lookup("prices").cache.store
+	if _, ok := store.Get("c2"); !ok {
+		t.Error("purging products must keep the prices entry in the shared bucket")
+	}
+	if _, ok := store.Get("p2"); ok {
+		t.Error("expected the products entry to be purged")
+	}
+	if _, ok := cbr.PurgeRoute("missing"); ok {
+		t.Error("expected not found for an unknown route")
+	}
+}
@@ -7,41 +7,75 @@ import (
 	"time"
 
 	expirable "github.com/hashicorp/golang-lru/v2/expirable"
+
+	"github.com/wudi/runway/internal/clock"
 )
 
 // MemoryStore is an in-memory LRU cache implementing Store.
 type MemoryStore struct {
-	lru       *expirable.LRU[string, *Entry]
+	lru       *expirable.LRU[string, memEntry]
 	mu        sync.Mutex // protects DeleteByPrefix atomicity and tag indexes
 	evictions atomic.Int64
 	maxSize   int
+	ttl       time.Duration
+	clock     clock.Clock
 	tagIndex  map[string]map[string]struct{} // tag → set of cache keys
 	keyTags   map[string][]string            // key → tags
 }
 
+// memEntry is a stored entry and when it expires by the store's clock.
+type memEntry struct {
+	entry   *Entry
+	expires time.Time
+}
+
 // NewMemoryStore creates a new in-memory LRU store with the given max size and TTL.
 func NewMemoryStore(maxSize int, ttl time.Duration) *MemoryStore {
+	return newMemoryStore(maxSize, ttl, clock.Default())
+}
+
+func newMemoryStore(maxSize int, ttl time.Duration, clk clock.Clock) *MemoryStore {
 	if maxSize <= 0 {
 		maxSize = 1000
 	}
 	s := &MemoryStore{
 		maxSize:  maxSize,
+		ttl:      ttl,
+		clock:    clk,
 		tagIndex: make(map[string]map[string]struct{}),
 		keyTags:  make(map[string][]string),
 	}
-	s.lru = expirable.NewLRU[string, *Entry](maxSize, func(key string, value *Entry) {
+	// The LRU expires entries by the wall clock. With a fake clock entries
+	// only expire on read, so advancing the clock is what ages them.
+	lruTTL := ttl
+	if !clock.IsReal(clk) {
+		lruTTL = 0
+	}
+	s.lru = expirable.NewLRU[string, memEntry](maxSize, func(key string, value memEntry) {
 		s.evictions.Add(1)
 		s.cleanTagIndexForKey(key)
-	}, ttl)
+	}, lruTTL)
 	return s
 }
 
 func (s *MemoryStore) Get(key string) (*Entry, bool) {
-	return s.lru.Get(key)
+	me, ok := s.lru.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if s.ttl > 0 && !s.clock.Now().Before(me.expires) {
+		s.Delete(key)
+		return nil, false
+	}
+	return me.entry, true
 }
 
 func (s *MemoryStore) Set(key string, entry *Entry) {
-	s.lru.Add(key, entry)
+	s.lru.Add(key, s.wrap(entry))
+}
+
+func (s *MemoryStore) wrap(entry *Entry) memEntry {
+	return memEntry{entry: entry, expires: s.clock.Now().Add(s.ttl)}
 }
 
 func (s *MemoryStore) Delete(key string) {
@@ -67,7 +101,7 @@ func (s *MemoryStore) SetWithTags(key string, entry *Entry, tags []string) {
 		}
 	}
 	s.mu.Unlock()
-	s.lru.Add(key, entry)
+	s.lru.Add(key, s.wrap(entry))
 }
 
 // DeleteByTags removes all entries matching any of the given tags. Returns count of deleted keys.
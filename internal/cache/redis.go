@@ -11,6 +11,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/storecrypt"
 )
 
 // RedisStore is a Redis-backed cache store implementing Store.
@@ -18,6 +19,7 @@ type RedisStore struct {
 	client *redis.Client
 	prefix string
 	ttl    time.Duration
+	keys   *storecrypt.Keyring // nil stores entries unsealed
 }
 
 // NewRedisStore creates a new Redis-backed store.
@@ -50,17 +52,32 @@ func (s *RedisStore) Get(key string) (*Entry, bool) {
 		return nil, false
 	}
 
+	plain, stale, err := s.keys.Open(data, s.prefix+key)
+	if err != nil {
+		logging.Warn("Redis cache decrypt failed, treating as miss", zap.Error(err))
+		return nil, false
+	}
+
 	var entry Entry
-	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+	if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(&entry); err != nil {
 		logging.Warn("Redis cache decode failed, treating as miss", zap.Error(err))
 		return nil, false
 	}
+	if stale {
+		err := s.keys.Reseal(plain, s.prefix+key, func(sealed []byte) error {
+			return s.client.Set(ctx, s.prefix+key, sealed, redis.KeepTTL).Err()
+		})
+		if err != nil {
+			logging.Warn("Redis cache re-encrypt failed", zap.Error(err))
+		}
+	}
 	return &entry, true
 }
 
 func (s *RedisStore) Set(key string, entry *Entry) {
-	var buf bytes.Buffer
-	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+	buf := storecrypt.GetBuffer()
+	defer storecrypt.PutBuffer(buf)
+	if err := gob.NewEncoder(buf).Encode(entry); err != nil {
 		logging.Warn("Redis cache encode failed", zap.Error(err))
 		return
 	}
@@ -68,7 +85,10 @@ func (s *RedisStore) Set(key string, entry *Entry) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 	defer cancel()
 
-	if err := s.client.Set(ctx, s.prefix+key, buf.Bytes(), s.ttl).Err(); err != nil {
+	err := s.keys.Seal(buf.Bytes(), s.prefix+key, func(data []byte) error {
+		return s.client.Set(ctx, s.prefix+key, data, s.ttl).Err()
+	})
+	if err != nil {
 		logging.Warn("Redis cache set failed", zap.Error(err))
 	}
 }
@@ -128,25 +148,20 @@ func (s *RedisStore) DeleteByTags(tags []string) int {
 		return 0
 	}
 
-	// Delete all member entry keys + the tag set keys
-	delKeys := make([]string, 0, len(members)+len(tagKeys))
+	// Delete all member entry keys, then the tag set keys. Members whose
+	// entries already expired are not counted.
+	delKeys := make([]string, 0, len(members))
 	for _, m := range members {
 		delKeys = append(delKeys, s.prefix+m)
 	}
-	delKeys = append(delKeys, tagKeys...)
-
-	deleted, err := s.client.Del(ctx, delKeys...).Result()
-	if err != nil {
+	pipe := s.client.TxPipeline()
+	deleted := pipe.Del(ctx, delKeys...)
+	pipe.Del(ctx, tagKeys...)
+	if _, err := pipe.Exec(ctx); err != nil {
 		logging.Warn("Redis cache tag bulk delete failed", zap.Error(err))
 		return 0
 	}
-
-	// Subtract tag set keys from count to return only entry deletions
-	entryCount := int(deleted) - len(tagKeys)
-	if entryCount < 0 {
-		entryCount = 0
-	}
-	return entryCount
+	return int(deleted.Val())
 }
 
 func (s *RedisStore) DeleteByPrefix(prefix string) {
@@ -1,12 +1,17 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"net/http"
 	"testing"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/storecrypt"
 )
 
 func redisAvailable(t *testing.T) *redis.Client {
@@ -72,6 +77,50 @@ func TestRedisStore_GetSet(t *testing.T) {
 	}
 }
 
+func TestRedisStore_EncryptedRotation(t *testing.T) {
+	client := redisAvailable(t)
+	prefix := "gw:test:encrypted:"
+	defer cleanupRedisKeys(t, client, prefix)
+	ctx := context.Background()
+	key1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	key2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	old, _ := storecrypt.New(config.StorageEncryptionConfig{KeyID: "k1", KeyBase64: key1}, nil)
+	store := NewRedisStore(client, prefix, 30*time.Second)
+	store.keys = old
+	store.Set("key1", &Entry{StatusCode: 200, Body: []byte(`{"ssn":"123-45-6789"}`)})
+
+	raw, _ := client.Get(ctx, prefix+"key1").Bytes()
+	if bytes.Contains(raw, []byte("123-45-6789")) {
+		t.Fatal("expected the body to be sealed in Redis")
+	}
+
+	rotated, _ := storecrypt.New(config.StorageEncryptionConfig{
+		KeyID: "k2", KeyBase64: key2, PreviousKeyID: "k1", PreviousKeyBase64: key1,
+	}, old)
+	store.keys = rotated
+	got, ok := store.Get("key1")
+	if !ok || string(got.Body) != `{"ssn":"123-45-6789"}` {
+		t.Fatalf("expected the previous key to open the entry, got %v", got)
+	}
+	raw, _ = client.Get(ctx, prefix+"key1").Bytes()
+	if !bytes.Contains(raw, []byte("k2")) {
+		t.Error("expected the entry rewritten under k2 on read")
+	}
+	if ttl := client.TTL(ctx, prefix+"key1").Val(); ttl <= 0 {
+		t.Errorf("expected the rewrite to keep the TTL, got %v", ttl)
+	}
+
+	raw[len(raw)-1] ^= 0x01
+	client.Set(ctx, prefix+"key1", raw, 30*time.Second)
+	if _, ok := store.Get("key1"); ok {
+		t.Error("expected a tampered entry to be a miss")
+	}
+	if rotated.Stats()["decrypt_failures"] != int64(1) {
+		t.Errorf("expected one decrypt failure, got %v", rotated.Stats())
+	}
+}
+
 func TestRedisStore_Miss(t *testing.T) {
 	client := redisAvailable(t)
 	prefix := "gw:test:miss:"
@@ -0,0 +1,182 @@
+// Package cachekey normalizes request paths and queries for cache and
+// coalesce keys, so equivalent requests share one key.
+package cachekey
+
+import (
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/wudi/runway/config"
+)
+
+// Normalizer rewrites a request's path and query into the form used in a
+// key. It is compiled once per route and never modifies the request.
+type Normalizer struct {
+	sortQuery          bool
+	lowercasePath      bool
+	stripTrailingSlash bool
+	ignore             *matcher
+	include            *matcher // when set, only matching params are kept
+}
+
+// New compiles cfg into a Normalizer. It returns nil when cfg enables no
+// normalization, leaving keys unchanged.
+func New(cfg config.CacheKeyNormalizationConfig) *Normalizer {
+	n := &Normalizer{
+		sortQuery:          cfg.SortQuery,
+		lowercasePath:      cfg.LowercasePath,
+		stripTrailingSlash: cfg.StripTrailingSlash,
+		ignore:             newMatcher(cfg.IgnoreQueryParams),
+		include:            newMatcher(cfg.IncludeQueryParams),
+	}
+	if !n.sortQuery && !n.lowercasePath && !n.stripTrailingSlash && n.ignore == nil && n.include == nil {
+		return nil
+	}
+	return n
+}
+
+// Key is a normalized path and query. It is pooled: call Release when done.
+type Key struct {
+	buf     []byte
+	pathEnd int
+	params  []string
+	changed bool
+}
+
+var keyPool = sync.Pool{New: func() any { return &Key{} }}
+
+// Path returns the normalized path.
+func (k *Key) Path() []byte { return k.buf[:k.pathEnd] }
+
+// Query returns the normalized raw query, without the leading '?'.
+func (k *Key) Query() []byte { return k.buf[k.pathEnd:] }
+
+// Changed reports whether normalization rewrote the path or query.
+func (k *Key) Changed() bool { return k.changed }
+
+// Release returns k to the pool. k must not be used afterwards.
+func (k *Key) Release() {
+	clear(k.params)
+	k.params = k.params[:0]
+	keyPool.Put(k)
+}
+
+// Normalize returns the normalized path and query of u.
+func (n *Normalizer) Normalize(u *url.URL) *Key {
+	k := keyPool.Get().(*Key)
+	k.buf = k.buf[:0]
+
+	p := u.Path
+	if n.stripTrailingSlash {
+		for len(p) > 1 && p[len(p)-1] == '/' {
+			p = p[:len(p)-1]
+		}
+	}
+	if n.lowercasePath {
+		for i := 0; i < len(p); i++ {
+			c := p[i]
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			k.buf = append(k.buf, c)
+		}
+	} else {
+		k.buf = append(k.buf, p...)
+	}
+	k.pathEnd = len(k.buf)
+
+	if !n.sortQuery && n.ignore == nil && n.include == nil {
+		k.buf = append(k.buf, u.RawQuery...)
+	} else {
+		for q := u.RawQuery; q != ""; {
+			var param string
+			param, q, _ = strings.Cut(q, "&")
+			if param == "" || !n.keep(paramName(param)) {
+				continue
+			}
+			k.params = append(k.params, param)
+		}
+		if n.sortQuery {
+			// Stable, so repeated params keep their relative order.
+			slices.SortStableFunc(k.params, func(a, b string) int {
+				return strings.Compare(paramName(a), paramName(b))
+			})
+		}
+		for i, param := range k.params {
+			if i > 0 {
+				k.buf = append(k.buf, '&')
+			}
+			k.buf = append(k.buf, param...)
+		}
+	}
+
+	k.changed = string(k.Path()) != u.Path || string(k.Query()) != u.RawQuery
+	return k
+}
+
+// keep reports whether the query param name belongs in the key.
+func (n *Normalizer) keep(name string) bool {
+	if strings.ContainsAny(name, "%+") {
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+	}
+	if n.include != nil {
+		return n.include.match(name)
+	}
+	return n.ignore == nil || !n.ignore.match(name)
+}
+
+// paramName returns the raw name of a "name=value" query param.
+func paramName(param string) string {
+	if i := strings.IndexByte(param, '='); i >= 0 {
+		return param[:i]
+	}
+	return param
+}
+
+// matcher matches query param names against glob patterns. Exact names and
+// "prefix*" patterns, the common cases, avoid path.Match.
+type matcher struct {
+	exact    map[string]struct{}
+	prefixes []string
+	globs    []string
+}
+
+func newMatcher(patterns []string) *matcher {
+	if len(patterns) == 0 {
+		return nil
+	}
+	m := &matcher{exact: make(map[string]struct{})}
+	for _, p := range patterns {
+		switch i := strings.IndexAny(p, `*?[\`); {
+		case i < 0:
+			m.exact[p] = struct{}{}
+		case i == len(p)-1 && p[i] == '*':
+			m.prefixes = append(m.prefixes, p[:i])
+		default:
+			m.globs = append(m.globs, p)
+		}
+	}
+	return m
+}
+
+func (m *matcher) match(name string) bool {
+	if _, ok := m.exact[name]; ok {
+		return true
+	}
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	for _, g := range m.globs {
+		if ok, _ := path.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
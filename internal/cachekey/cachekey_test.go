@@ -0,0 +1,100 @@
+package cachekey
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.CacheKeyNormalizationConfig
+		url         string
+		wantPath    string
+		wantQuery   string
+		wantChanged bool
+	}{
+		{
+			name:      "sort query keeps repeated param order",
+			cfg:       config.CacheKeyNormalizationConfig{SortQuery: true},
+			url:       "/a?z=1&b=2&a=3&b=1",
+			wantPath:  "/a",
+			wantQuery: "a=3&b=2&b=1&z=1", wantChanged: true,
+		},
+		{
+			name:      "ignore globs",
+			cfg:       config.CacheKeyNormalizationConfig{IgnoreQueryParams: []string{"utm_*", "fbclid", "x?"}},
+			url:       "/a?utm_source=x&id=7&fbclid=abc&xy=1&xyz=2",
+			wantPath:  "/a",
+			wantQuery: "id=7&xyz=2", wantChanged: true,
+		},
+		{
+			name:      "ignore matches escaped names",
+			cfg:       config.CacheKeyNormalizationConfig{IgnoreQueryParams: []string{"utm_*"}},
+			url:       "/a?utm%5Fsource=x&id=7",
+			wantPath:  "/a",
+			wantQuery: "id=7", wantChanged: true,
+		},
+		{
+			name:      "include allowlist",
+			cfg:       config.CacheKeyNormalizationConfig{IncludeQueryParams: []string{"id", "page*"}},
+			url:       "/a?session=1&page_size=10&id=7",
+			wantPath:  "/a",
+			wantQuery: "page_size=10&id=7", wantChanged: true,
+		},
+		{
+			name:     "lowercase and strip trailing slash",
+			cfg:      config.CacheKeyNormalizationConfig{LowercasePath: true, StripTrailingSlash: true},
+			url:      "/Users/ABC//?Q=1",
+			wantPath: "/users/abc", wantQuery: "Q=1", wantChanged: true,
+		},
+		{
+			name:     "root keeps its slash",
+			cfg:      config.CacheKeyNormalizationConfig{StripTrailingSlash: true},
+			url:      "/",
+			wantPath: "/",
+		},
+		{
+			name:      "already normal",
+			cfg:       config.CacheKeyNormalizationConfig{SortQuery: true, LowercasePath: true},
+			url:       "/a?a=1&b=2",
+			wantPath:  "/a",
+			wantQuery: "a=1&b=2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse(tt.url)
+			k := New(tt.cfg).Normalize(u)
+			defer k.Release()
+			if string(k.Path()) != tt.wantPath || string(k.Query()) != tt.wantQuery || k.Changed() != tt.wantChanged {
+				t.Errorf("got %q ? %q (changed %v), want %q ? %q (changed %v)",
+					k.Path(), k.Query(), k.Changed(), tt.wantPath, tt.wantQuery, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestNew_NilWhenDisabled(t *testing.T) {
+	if New(config.CacheKeyNormalizationConfig{}) != nil {
+		t.Error("expected nil normalizer for an empty config")
+	}
+}
+
+func BenchmarkNormalize(b *testing.B) {
+	n := New(config.CacheKeyNormalizationConfig{
+		SortQuery:          true,
+		IgnoreQueryParams:  []string{"utm_*", "fbclid"},
+		LowercasePath:      true,
+		StripTrailingSlash: true,
+	})
+	u, _ := url.Parse("/Products/Shoes/?utm_source=news&size=42&color=red&fbclid=abc&page=2")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := n.Normalize(u)
+		k.Release()
+	}
+}
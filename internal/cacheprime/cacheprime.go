@@ -0,0 +1,293 @@
+// Package cacheprime warms a route's cache from a manifest of request paths.
+//
+// A Job loads the manifest, then issues its requests from a pool of
+// workers, paced by an optional rate and bounded by a total time budget.
+// The requests themselves are issued by a FetchFunc, which runs them
+// through the route's pipeline so responses are cached as usual. Failed
+// requests are counted and logged; they never fail the job.
+package cacheprime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/logging"
+)
+
+// Job states.
+const (
+	StateLoading  = "loading"         // fetching the manifest
+	StateRunning  = "running"         // issuing priming requests
+	StateDone     = "done"            // every request was issued
+	StateFailed   = "failed"          // the manifest could not be loaded
+	StateExceeded = "budget_exceeded" // the budget ran out first
+	StateStopped  = "stopped"         // stopped by a reload or shutdown
+)
+
+const (
+	DefaultConcurrency = 4
+	DefaultBudget      = 5 * time.Minute
+	DefaultTimeout     = 10 * time.Second
+)
+
+// FetchFunc issues one priming request and returns the response status.
+type FetchFunc func(ctx context.Context, req Request) (int, error)
+
+// Job primes one route's cache.
+type Job struct {
+	routeID     string
+	manifest    string
+	concurrency int
+	rate        int
+	budget      time.Duration
+	timeout     time.Duration
+	readyPct    int
+	fetch       FetchFunc
+
+	total   atomic.Int64
+	primed  atomic.Int64
+	failed  atomic.Int64
+	stopped atomic.Bool
+
+	mu        sync.Mutex
+	state     string
+	lastError string
+	started   time.Time
+	finished  time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Snapshot is a point-in-time view of a job's progress.
+type Snapshot struct {
+	Manifest     string     `json:"manifest"`
+	State        string     `json:"state"`
+	Total        int64      `json:"total"`
+	Primed       int64      `json:"primed"`
+	Failed       int64      `json:"failed"`
+	Percent      float64    `json:"percent"` // share of requests issued, primed or failed
+	ReadyPercent int        `json:"ready_percent,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// Start starts priming routeID's cache in the background.
+func Start(routeID string, cfg config.CachePrimeConfig, fetch FetchFunc) *Job {
+	j := &Job{
+		routeID:     routeID,
+		manifest:    cfg.Manifest,
+		concurrency: cfg.Concurrency,
+		rate:        cfg.Rate,
+		budget:      cfg.Budget,
+		timeout:     cfg.Timeout,
+		readyPct:    cfg.ReadyPercent,
+		fetch:       fetch,
+		state:       StateLoading,
+		started:     time.Now(),
+		done:        make(chan struct{}),
+	}
+	if j.concurrency <= 0 {
+		j.concurrency = DefaultConcurrency
+	}
+	if j.budget <= 0 {
+		j.budget = DefaultBudget
+	}
+	if j.timeout <= 0 {
+		j.timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), j.budget)
+	j.cancel = cancel
+	go j.run(ctx)
+	return j
+}
+
+func (j *Job) run(ctx context.Context) {
+	defer close(j.done)
+	defer j.cancel()
+
+	loadCtx, cancel := context.WithTimeout(ctx, j.timeout)
+	reqs, err := LoadManifest(loadCtx, j.manifest)
+	cancel()
+	if err != nil {
+		j.finish(StateFailed, err.Error())
+		logging.Warn("Cache priming manifest failed",
+			zap.String("route", j.routeID),
+			zap.String("manifest", j.manifest),
+			zap.Error(err),
+		)
+		return
+	}
+	j.total.Store(int64(len(reqs)))
+	j.mu.Lock()
+	j.state = StateRunning
+	j.mu.Unlock()
+
+	var limiter *rate.Limiter
+	if j.rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(j.rate), 1)
+	}
+	queue := make(chan Request)
+	var wg sync.WaitGroup
+	for i := 0; i < j.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range queue {
+				j.prime(ctx, req)
+			}
+		}()
+	}
+feed:
+	for _, req := range reqs {
+		if limiter != nil && limiter.Wait(ctx) != nil {
+			break
+		}
+		select {
+		case queue <- req:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	state := StateDone
+	if j.primed.Load()+j.failed.Load() < j.total.Load() {
+		state = StateExceeded
+		if j.stopped.Load() {
+			state = StateStopped
+		}
+	}
+	j.finish(state, "")
+
+	fields := []zap.Field{
+		zap.String("route", j.routeID),
+		zap.String("state", state),
+		zap.Int64("total", j.total.Load()),
+		zap.Int64("primed", j.primed.Load()),
+		zap.Int64("failed", j.failed.Load()),
+		zap.Duration("duration", time.Since(j.started)),
+	}
+	if j.failed.Load() > 0 {
+		j.mu.Lock()
+		fields = append(fields, zap.String("last_error", j.lastError))
+		j.mu.Unlock()
+		logging.Warn("Cache priming finished with failures", fields...)
+		return
+	}
+	logging.Info("Cache priming finished", fields...)
+}
+
+// prime issues one request. Requests cut short by the budget or by Stop
+// are not counted.
+func (j *Job) prime(ctx context.Context, req Request) {
+	reqCtx, cancel := context.WithTimeout(ctx, j.timeout)
+	status, err := j.fetch(reqCtx, req)
+	cancel()
+	if err == nil && status >= 400 {
+		err = fmt.Errorf("status %d", status)
+	}
+	if err == nil {
+		j.primed.Add(1)
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	j.failed.Add(1)
+	j.mu.Lock()
+	j.lastError = req.Path + ": " + err.Error()
+	j.mu.Unlock()
+	logging.Debug("Cache priming request failed",
+		zap.String("route", j.routeID),
+		zap.String("path", req.Path),
+		zap.Error(err),
+	)
+}
+
+func (j *Job) finish(state, lastError string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.state = state
+	if lastError != "" {
+		j.lastError = lastError
+	}
+	j.finished = time.Now()
+}
+
+// Stop cancels the job and waits for its in-flight requests.
+func (j *Job) Stop() {
+	j.stopped.Store(true)
+	j.cancel()
+	<-j.done
+}
+
+// Done is closed when the job has finished.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Finished reports whether the job has finished.
+func (j *Job) Finished() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Snapshot returns the job's progress.
+func (j *Job) Snapshot() Snapshot {
+	j.mu.Lock()
+	s := Snapshot{
+		Manifest:     j.manifest,
+		State:        j.state,
+		ReadyPercent: j.readyPct,
+		StartedAt:    j.started,
+		LastError:    j.lastError,
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		s.FinishedAt = &finished
+	}
+	j.mu.Unlock()
+	s.Total = j.total.Load()
+	s.Primed = j.primed.Load()
+	s.Failed = j.failed.Load()
+	s.Percent = j.percent(s)
+	return s
+}
+
+func (j *Job) percent(s Snapshot) float64 {
+	if s.Total == 0 {
+		if s.FinishedAt != nil {
+			return 100
+		}
+		return 0
+	}
+	return float64(s.Primed+s.Failed) * 100 / float64(s.Total)
+}
+
+// ReadinessReason returns why readiness waits for the job, or "" when it
+// does not: ready_percent is unset, the job has finished, or enough of the
+// manifest has been issued.
+func (j *Job) ReadinessReason() string {
+	if j.readyPct <= 0 || j.Finished() {
+		return ""
+	}
+	s := j.Snapshot()
+	if s.State == StateRunning && s.Percent >= float64(j.readyPct) {
+		return ""
+	}
+	return fmt.Sprintf("cache priming for route %s at %.0f%%, need %d%%", j.routeID, s.Percent, j.readyPct)
+}
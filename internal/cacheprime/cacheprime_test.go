@@ -0,0 +1,216 @@
+package cacheprime
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func TestParseManifest(t *testing.T) {
+	reqs, err := ParseManifest([]byte(`{"entries": [
+		{"path": "/products/1"},
+		{"path": "/products/2?full=1", "headers": {"Accept": "application/json"},
+		 "variants": [{"Accept-Language": "de"}, {"Accept-Language": "fr", "Accept": "text/html"}]}
+	]}`))
+	if err != nil {
+		t.Fatalf("ParseManifest: %v", err)
+	}
+	if len(reqs) != 3 {
+		t.Fatalf("expected 3 requests, got %+v", reqs)
+	}
+	if reqs[0].Path != "/products/1" || len(reqs[0].Headers) != 0 {
+		t.Errorf("unexpected first request %+v", reqs[0])
+	}
+	if h := reqs[1].Headers; reqs[1].Path != "/products/2?full=1" || h["Accept"] != "application/json" || h["Accept-Language"] != "de" {
+		t.Errorf("unexpected de variant %+v", reqs[1])
+	}
+	if h := reqs[2].Headers; h["Accept"] != "text/html" || h["Accept-Language"] != "fr" {
+		t.Errorf("variant headers should override entry headers, got %+v", reqs[2])
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  string
+	}{
+		{"not json", `entries: [/a]`, "invalid manifest"},
+		{"truncated", `{"entries": [{"path": "/a"}`, "invalid manifest"},
+		{"trailing data", `{"entries": []} {}`, "data after the document"},
+		{"unknown field", `{"entries": [{"url": "/a"}]}`, "invalid manifest"},
+		{"missing path", `{"entries": [{"path": "/a"}, {"headers": {"X-A": "1"}}]}`, "manifest entry 1: path must start with /"},
+		{"relative path", `{"entries": [{"path": "a"}]}`, "path must start with /"},
+		{"bad header", `{"entries": [{"path": "/a", "headers": {"X A": "1"}}]}`, `invalid header name "X A"`},
+		{"bad variant header", `{"entries": [{"path": "/a", "variants": [{"": "1"}]}]}`, "manifest entry 0 variant 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifest([]byte(tt.manifest))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	const doc = `{"entries": [{"path": "/a"}, {"path": "/b"}]}`
+	file := filepath.Join(t.TempDir(), "manifest.json")
+	os.WriteFile(file, []byte(doc), 0o644)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/manifest.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(doc))
+	}))
+	defer srv.Close()
+
+	for _, source := range []string{file, srv.URL + "/manifest.json"} {
+		reqs, err := LoadManifest(context.Background(), source)
+		if err != nil || len(reqs) != 2 {
+			t.Errorf("LoadManifest(%s) = %+v, %v", source, reqs, err)
+		}
+	}
+	if _, err := LoadManifest(context.Background(), srv.URL+"/missing.json"); err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("expected a status error, got %v", err)
+	}
+	if _, err := LoadManifest(context.Background(), filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func writeManifest(t *testing.T, paths ...string) string {
+	t.Helper()
+	var entries []string
+	for _, p := range paths {
+		entries = append(entries, `{"path": "`+p+`"}`)
+	}
+	file := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(file, []byte(`{"entries": [`+strings.Join(entries, ",")+`]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestJobCountsFailures(t *testing.T) {
+	manifest := writeManifest(t, "/ok/1", "/ok/2", "/missing", "/broken")
+	var calls atomic.Int64
+	j := Start("products", config.CachePrimeConfig{Manifest: manifest, Concurrency: 2}, func(ctx context.Context, req Request) (int, error) {
+		calls.Add(1)
+		switch req.Path {
+		case "/missing":
+			return http.StatusNotFound, nil
+		case "/broken":
+			return 0, errors.New("connection refused")
+		}
+		return http.StatusOK, nil
+	})
+	<-j.Done()
+
+	s := j.Snapshot()
+	if s.State != StateDone || s.Total != 4 || s.Primed != 2 || s.Failed != 2 || s.Percent != 100 {
+		t.Errorf("unexpected snapshot %+v", s)
+	}
+	if s.FinishedAt == nil || s.LastError == "" {
+		t.Errorf("expected finish time and last error, got %+v", s)
+	}
+	if calls.Load() != 4 {
+		t.Errorf("expected 4 requests, got %d", calls.Load())
+	}
+}
+
+func TestJobManifestFailure(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "manifest.json")
+	os.WriteFile(file, []byte(`{"entries": [{"path": "nope"}]}`), 0o644)
+
+	j := Start("products", config.CachePrimeConfig{Manifest: file, ReadyPercent: 100}, func(ctx context.Context, req Request) (int, error) {
+		t.Error("no request expected")
+		return http.StatusOK, nil
+	})
+	<-j.Done()
+
+	s := j.Snapshot()
+	if s.State != StateFailed || !strings.Contains(s.LastError, "path must start with /") {
+		t.Errorf("unexpected snapshot %+v", s)
+	}
+	// A failed manifest does not hold readiness back.
+	if reason := j.ReadinessReason(); reason != "" {
+		t.Errorf("expected no readiness reason, got %q", reason)
+	}
+}
+
+func TestJobBudget(t *testing.T) {
+	manifest := writeManifest(t, "/a", "/b", "/c")
+	j := Start("products", config.CachePrimeConfig{Manifest: manifest, Concurrency: 1, Budget: 50 * time.Millisecond}, func(ctx context.Context, req Request) (int, error) {
+		if req.Path == "/a" {
+			return http.StatusOK, nil
+		}
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	select {
+	case <-j.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not stop at its budget")
+	}
+	s := j.Snapshot()
+	if s.State != StateExceeded || s.Primed != 1 || s.Failed != 0 {
+		t.Errorf("unexpected snapshot %+v", s)
+	}
+}
+
+func TestJobReadinessReason(t *testing.T) {
+	manifest := writeManifest(t, "/a", "/b", "/c", "/slow")
+	release := make(chan struct{})
+	j := Start("products", config.CachePrimeConfig{Manifest: manifest, ReadyPercent: 75}, func(ctx context.Context, req Request) (int, error) {
+		if req.Path == "/slow" {
+			<-release
+		}
+		return http.StatusOK, nil
+	})
+	defer j.Stop()
+
+	// The gate opens once 75% of the manifest is primed, before /slow.
+	deadline := time.Now().Add(5 * time.Second)
+	for j.Snapshot().Primed < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("priming stalled: %+v", j.Snapshot())
+		}
+		if reason := j.ReadinessReason(); !strings.Contains(reason, "cache priming for route products") {
+			t.Fatalf("expected a readiness reason, got %q", reason)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if reason := j.ReadinessReason(); reason != "" {
+		t.Errorf("expected ready at 75%%, got %q", reason)
+	}
+	close(release)
+	<-j.Done()
+}
+
+func TestJobStop(t *testing.T) {
+	manifest := writeManifest(t, "/a", "/b")
+	j := Start("products", config.CachePrimeConfig{Manifest: manifest, ReadyPercent: 100}, func(ctx context.Context, req Request) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	j.Stop()
+	if s := j.Snapshot(); s.State != StateStopped || s.Failed != 0 {
+		t.Errorf("unexpected snapshot %+v", s)
+	}
+	if reason := j.ReadinessReason(); reason != "" {
+		t.Errorf("expected a stopped job not to hold readiness, got %q", reason)
+	}
+}
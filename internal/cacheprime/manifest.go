@@ -0,0 +1,128 @@
+package cacheprime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// maxManifestSize caps the manifest read from a file or URL.
+const maxManifestSize = 16 << 20
+
+// Request is one priming request: a path, with its query, and the request
+// headers selecting a cache variant.
+type Request struct {
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// manifestEntry is an entry of the manifest document. Each variant is a set
+// of headers requested on top of the entry's headers; an entry without
+// variants is requested once.
+type manifestEntry struct {
+	Path     string              `json:"path"`
+	Headers  map[string]string   `json:"headers"`
+	Variants []map[string]string `json:"variants"`
+}
+
+type manifest struct {
+	Entries []manifestEntry `json:"entries"`
+}
+
+// ParseManifest parses a manifest document and expands its entries into
+// priming requests:
+//
+//	{"entries": [
+//	  {"path": "/products/1"},
+//	  {"path": "/products/2", "variants": [{"Accept-Language": "de"}, {"Accept-Language": "fr"}]}
+//	]}
+func ParseManifest(data []byte) ([]Request, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var m manifest
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("invalid manifest: data after the document")
+	}
+	var reqs []Request
+	for i, e := range m.Entries {
+		if !strings.HasPrefix(e.Path, "/") {
+			return nil, fmt.Errorf("manifest entry %d: path must start with /", i)
+		}
+		if err := checkHeaders(e.Headers); err != nil {
+			return nil, fmt.Errorf("manifest entry %d: %w", i, err)
+		}
+		if len(e.Variants) == 0 {
+			reqs = append(reqs, Request{Path: e.Path, Headers: e.Headers})
+			continue
+		}
+		for j, v := range e.Variants {
+			if err := checkHeaders(v); err != nil {
+				return nil, fmt.Errorf("manifest entry %d variant %d: %w", i, j, err)
+			}
+			headers := make(map[string]string, len(e.Headers)+len(v))
+			for name, value := range e.Headers {
+				headers[name] = value
+			}
+			for name, value := range v {
+				headers[name] = value
+			}
+			reqs = append(reqs, Request{Path: e.Path, Headers: headers})
+		}
+	}
+	return reqs, nil
+}
+
+func checkHeaders(headers map[string]string) error {
+	for name := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	}
+	return nil
+}
+
+// LoadManifest reads and parses the manifest at source, a file path or an
+// http(s) URL.
+func LoadManifest(ctx context.Context, source string) ([]Request, error) {
+	var data []byte
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetching manifest: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching manifest: status %d", resp.StatusCode)
+		}
+		data, err = io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("fetching manifest: %w", err)
+		}
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		data, err = io.ReadAll(io.LimitReader(f, maxManifestSize+1))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(data) > maxManifestSize {
+		return nil, fmt.Errorf("manifest exceeds %d bytes", maxManifestSize)
+	}
+	return ParseManifest(data)
+}
@@ -0,0 +1,35 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeAdvance(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	if !f.Now().Equal(start) {
+		t.Fatalf("expected %v, got %v", start, f.Now())
+	}
+	if got := f.Advance(90 * time.Second); !got.Equal(start.Add(90 * time.Second)) {
+		t.Fatalf("expected the clock advanced by 90s, got %v", got)
+	}
+	if !f.Now().Equal(start.Add(90 * time.Second)) {
+		t.Fatal("expected Now to return the advanced time")
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	if !IsReal(Default()) {
+		t.Fatal("expected the system clock by default")
+	}
+	f := NewFake(time.Unix(0, 0))
+	SetDefault(f)
+	defer SetDefault(Real{})
+	if Default() != Clock(f) {
+		t.Fatal("expected the fake clock after SetDefault")
+	}
+	if IsReal(Default()) {
+		t.Fatal("expected IsReal to be false for a fake clock")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/cluster/clusterpb"
+	"github.com/wudi/runway/internal/randutil"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -146,6 +147,9 @@ func (c *Client) Run(ctx context.Context) {
 	bo.InitialInterval = c.retryInterval
 	bo.MaxInterval = 60 * time.Second
 	bo.MaxElapsedTime = 0 // never give up
+	if randutil.Seeded() {
+		bo.RandomizationFactor = 0 // test mode: reconnect at exact intervals
+	}
 
 	for {
 		err := c.connectAndStream(ctx)
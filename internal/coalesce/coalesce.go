@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/cachekey"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/graphql"
 	"golang.org/x/sync/singleflight"
@@ -40,6 +41,7 @@ type Coalescer struct {
 	timeout    time.Duration
 	methods    map[string]bool
 	keyHeaders []string
+	normalizer *cachekey.Normalizer // nil when keys are not normalized
 
 	groupsCreated     atomic.Int64
 	requestsCoalesced atomic.Int64
@@ -73,6 +75,7 @@ func New(cfg config.CoalesceConfig) *Coalescer {
 		timeout:    timeout,
 		methods:    methods,
 		keyHeaders: keyHeaders,
+		normalizer: cachekey.New(cfg.KeyNormalization),
 	}
 }
 
@@ -87,9 +90,17 @@ func (c *Coalescer) BuildKey(r *http.Request) string {
 	h := sha256.New()
 	io.WriteString(h, r.Method)
 	h.Write([]byte{'\n'})
-	io.WriteString(h, r.URL.Path)
-	h.Write([]byte{'\n'})
-	io.WriteString(h, r.URL.RawQuery)
+	if c.normalizer != nil {
+		k := c.normalizer.Normalize(r.URL)
+		h.Write(k.Path())
+		h.Write([]byte{'\n'})
+		h.Write(k.Query())
+		k.Release()
+	} else {
+		io.WriteString(h, r.URL.Path)
+		h.Write([]byte{'\n'})
+		io.WriteString(h, r.URL.RawQuery)
+	}
 	h.Write([]byte{'\n'})
 
 	for _, hdr := range c.keyHeaders {
@@ -90,6 +90,22 @@ func TestBuildKey(t *testing.T) {
 	}
 }
 
+func TestBuildKey_Normalized(t *testing.T) {
+	c := New(config.CoalesceConfig{
+		Enabled:          true,
+		KeyNormalization: config.CacheKeyNormalizationConfig{SortQuery: true, IgnoreQueryParams: []string{"utm_*"}},
+	})
+
+	r1 := httptest.NewRequest("GET", "/api/products?page=1&sort=asc", nil)
+	r2 := httptest.NewRequest("GET", "/api/products?sort=asc&utm_source=mail&page=1", nil)
+	if c.BuildKey(r1) != c.BuildKey(r2) {
+		t.Error("requests that normalize to the same URL should share a key")
+	}
+	if r2.URL.RawQuery != "sort=asc&utm_source=mail&page=1" {
+		t.Errorf("normalization must not change the request, got %q", r2.URL.RawQuery)
+	}
+}
+
 func TestExecuteCoalescence(t *testing.T) {
 	c := New(config.CoalesceConfig{
 		Enabled: true,
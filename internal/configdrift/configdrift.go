@@ -0,0 +1,295 @@
+// Package configdrift publishes this replica's config hash to a shared store
+// and reports when peer replicas keep running a different config.
+package configdrift
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Defaults applied by New when a Config field is zero.
+const (
+	DefaultInterval    = 30 * time.Second
+	DefaultGracePeriod = 2 * time.Minute
+	DefaultKey         = "runway:config_hash"
+)
+
+// Entry is a replica's published hash.
+type Entry struct {
+	Hash      string    `json:"hash"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store holds the latest entry of every replica.
+type Store interface {
+	Publish(ctx context.Context, instance string, e Entry) error
+	Entries(ctx context.Context) (map[string]Entry, error)
+	Remove(ctx context.Context, instance string) error
+}
+
+// Config holds detector configuration.
+type Config struct {
+	Instance    string
+	Interval    time.Duration
+	GracePeriod time.Duration
+	Store       Store
+
+	// Hash returns the hash of the running config; it is called every
+	// Interval so runtime changes are published.
+	Hash func() string
+	// OnDrift is called once when peers have run a different config for
+	// longer than GracePeriod, and again only after hashes converged.
+	OnDrift func(Drift)
+}
+
+// Drift describes the replicas whose hash differs from the local one.
+type Drift struct {
+	Instance string            `json:"instance"`
+	Hash     string            `json:"hash"`
+	Peers    map[string]string `json:"peers"` // instance -> hash, diverging peers only
+	Since    time.Time         `json:"since"`
+}
+
+// Peer is a replica seen in the store.
+type Peer struct {
+	Instance      string     `json:"instance"`
+	Hash          string     `json:"hash"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DivergedSince *time.Time `json:"diverged_since,omitempty"`
+}
+
+// Status is a snapshot of the last comparison.
+type Status struct {
+	Instance    string     `json:"instance"`
+	Hash        string     `json:"hash"`
+	Drift       bool       `json:"drift"`
+	Peers       []Peer     `json:"peers"`
+	LastChecked time.Time  `json:"last_checked,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	DriftSince  *time.Time `json:"drift_since,omitempty"`
+}
+
+// Detector publishes the local hash and compares it with peers.
+type Detector struct {
+	cfg Config
+
+	mu       sync.Mutex
+	diverged map[string]time.Time // peer -> first seen with a different hash
+	reported bool
+	status   Status
+	started  bool
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// New creates a detector. Call Start to begin publishing.
+func New(cfg Config) *Detector {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.GracePeriod <= 0 {
+		cfg.GracePeriod = DefaultGracePeriod
+	}
+	return &Detector{
+		cfg:      cfg,
+		diverged: make(map[string]time.Time),
+		status:   Status{Instance: cfg.Instance, Peers: []Peer{}},
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+// Start checks immediately and then every Interval until Stop.
+func (d *Detector) Start() {
+	d.mu.Lock()
+	d.started = true
+	d.mu.Unlock()
+	go d.loop()
+}
+
+func (d *Detector) loop() {
+	defer close(d.doneCh)
+	d.Check(context.Background(), time.Now())
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			d.Check(context.Background(), now)
+		case <-d.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops the background loop and withdraws the local entry, so peers
+// do not compare against a replica that is gone or reconfigured.
+func (d *Detector) Stop() {
+	select {
+	case <-d.stopCh:
+		return
+	default:
+		close(d.stopCh)
+	}
+	d.mu.Lock()
+	started := d.started
+	d.mu.Unlock()
+	if started {
+		<-d.doneCh
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	d.cfg.Store.Remove(ctx, d.cfg.Instance)
+}
+
+// Check publishes the local hash, reads the peers' and updates the drift
+// state. Entries not refreshed for three intervals belong to replicas that
+// are gone; they are ignored and deleted.
+func (d *Detector) Check(ctx context.Context, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Interval)
+	defer cancel()
+
+	hash := d.cfg.Hash()
+	err := d.cfg.Store.Publish(ctx, d.cfg.Instance, Entry{Hash: hash, UpdatedAt: now})
+	var entries map[string]Entry
+	if err == nil {
+		entries, err = d.cfg.Store.Entries(ctx)
+	}
+
+	d.mu.Lock()
+	d.status.Hash = hash
+	d.status.LastChecked = now
+	if err != nil {
+		d.status.LastError = err.Error()
+		d.mu.Unlock()
+		return
+	}
+	d.status.LastError = ""
+
+	stale := 3 * d.cfg.Interval
+	peers := make([]Peer, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	var gone []string
+	for inst, e := range entries {
+		if inst == d.cfg.Instance {
+			continue
+		}
+		if now.Sub(e.UpdatedAt) > stale {
+			gone = append(gone, inst)
+			continue
+		}
+		p := Peer{Instance: inst, Hash: e.Hash, UpdatedAt: e.UpdatedAt}
+		if e.Hash != hash {
+			since, ok := d.diverged[inst]
+			if !ok {
+				since = now
+				d.diverged[inst] = since
+			}
+			p.DivergedSince = &since
+			seen[inst] = true
+		}
+		peers = append(peers, p)
+	}
+	for inst := range d.diverged {
+		if !seen[inst] {
+			delete(d.diverged, inst)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Instance < peers[j].Instance })
+	d.status.Peers = peers
+
+	// Drift is reported once the longest-diverging peer passes the grace
+	// period; it is re-armed when every peer agrees again.
+	var drift *Drift
+	var oldest time.Time
+	for _, since := range d.diverged {
+		if oldest.IsZero() || since.Before(oldest) {
+			oldest = since
+		}
+	}
+	switch {
+	case len(d.diverged) == 0:
+		d.reported = false
+		d.status.Drift = false
+		d.status.DriftSince = nil
+	case now.Sub(oldest) >= d.cfg.GracePeriod:
+		d.status.Drift = true
+		d.status.DriftSince = &oldest
+		if !d.reported {
+			d.reported = true
+			drift = &Drift{Instance: d.cfg.Instance, Hash: hash, Peers: make(map[string]string), Since: oldest}
+			for _, p := range peers {
+				if p.DivergedSince != nil {
+					drift.Peers[p.Instance] = p.Hash
+				}
+			}
+		}
+	}
+	d.mu.Unlock()
+
+	for _, inst := range gone {
+		d.cfg.Store.Remove(ctx, inst)
+	}
+	if drift != nil && d.cfg.OnDrift != nil {
+		d.cfg.OnDrift(*drift)
+	}
+}
+
+// Status returns the result of the last comparison.
+func (d *Detector) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := d.status
+	st.Peers = append([]Peer(nil), d.status.Peers...)
+	return st
+}
+
+// RedisStore keeps entries as fields of one Redis hash.
+type RedisStore struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisStore creates a store under key (DefaultKey when empty).
+func NewRedisStore(client *redis.Client, key string) *RedisStore {
+	if key == "" {
+		key = DefaultKey
+	}
+	return &RedisStore{client: client, key: key}
+}
+
+// Publish sets the instance's entry.
+func (s *RedisStore) Publish(ctx context.Context, instance string, e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, s.key, instance, data).Err()
+}
+
+// Entries returns every instance's entry, skipping malformed ones.
+func (s *RedisStore) Entries(ctx context.Context) (map[string]Entry, error) {
+	raw, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]Entry, len(raw))
+	for inst, v := range raw {
+		var e Entry
+		if json.Unmarshal([]byte(v), &e) == nil {
+			out[inst] = e
+		}
+	}
+	return out, nil
+}
+
+// Remove deletes the instance's entry.
+func (s *RedisStore) Remove(ctx context.Context, instance string) error {
+	return s.client.HDel(ctx, s.key, instance).Err()
+}
@@ -0,0 +1,135 @@
+package configdrift
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+func newMemStore() *memStore { return &memStore{entries: make(map[string]Entry)} }
+
+func (s *memStore) Publish(_ context.Context, instance string, e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[instance] = e
+	return nil
+}
+
+func (s *memStore) Entries(context.Context) (map[string]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]Entry, len(s.entries))
+	for k, v := range s.entries {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (s *memStore) Remove(_ context.Context, instance string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, instance)
+	return nil
+}
+
+func TestCheck_ReportsDriftAfterGracePeriodOnce(t *testing.T) {
+	store := newMemStore()
+	var drifts []Drift
+	d := New(Config{
+		Instance:    "a",
+		Interval:    time.Minute,
+		GracePeriod: 2 * time.Minute,
+		Store:       store,
+		Hash:        func() string { return "h1" },
+		OnDrift:     func(dr Drift) { drifts = append(drifts, dr) },
+	})
+	ctx := context.Background()
+	t0 := time.Unix(1000, 0)
+
+	store.Publish(ctx, "b", Entry{Hash: "h2", UpdatedAt: t0})
+	d.Check(ctx, t0)
+	if st := d.Status(); st.Drift || len(st.Peers) != 1 || st.Peers[0].DivergedSince == nil {
+		t.Fatalf("unexpected status within grace period: %+v", st)
+	}
+	if len(drifts) != 0 {
+		t.Fatal("drift reported within grace period")
+	}
+
+	t1 := t0.Add(2 * time.Minute)
+	store.Publish(ctx, "b", Entry{Hash: "h2", UpdatedAt: t1})
+	d.Check(ctx, t1)
+	d.Check(ctx, t1.Add(time.Second))
+	if len(drifts) != 1 {
+		t.Fatalf("expected one drift report, got %d", len(drifts))
+	}
+	if drifts[0].Peers["b"] != "h2" || !drifts[0].Since.Equal(t0) {
+		t.Errorf("unexpected drift: %+v", drifts[0])
+	}
+	if !d.Status().Drift {
+		t.Error("status should report drift")
+	}
+
+	// Converging re-arms the report.
+	t2 := t1.Add(time.Minute)
+	store.Publish(ctx, "b", Entry{Hash: "h1", UpdatedAt: t2})
+	d.Check(ctx, t2)
+	if d.Status().Drift {
+		t.Error("drift should clear once hashes match")
+	}
+	t3 := t2.Add(time.Minute)
+	store.Publish(ctx, "b", Entry{Hash: "h3", UpdatedAt: t3})
+	d.Check(ctx, t3)
+	t4 := t3.Add(2 * time.Minute)
+	store.Publish(ctx, "b", Entry{Hash: "h3", UpdatedAt: t4})
+	d.Check(ctx, t4)
+	if len(drifts) != 2 {
+		t.Errorf("expected drift to be reported again after converging, got %d reports", len(drifts))
+	}
+}
+
+func TestCheck_RemovesStaleEntries(t *testing.T) {
+	store := newMemStore()
+	d := New(Config{
+		Instance:    "a",
+		Interval:    time.Minute,
+		GracePeriod: time.Nanosecond,
+		Store:       store,
+		Hash:        func() string { return "h1" },
+		OnDrift:     func(Drift) { t.Error("stale peer should not cause drift") },
+	})
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+
+	store.Publish(ctx, "gone", Entry{Hash: "h2", UpdatedAt: now.Add(-4 * time.Minute)})
+	d.Check(ctx, now)
+
+	if st := d.Status(); len(st.Peers) != 0 || st.Hash != "h1" {
+		t.Errorf("unexpected status: %+v", st)
+	}
+	entries, _ := store.Entries(ctx)
+	if _, ok := entries["gone"]; ok {
+		t.Error("stale entry was not removed")
+	}
+	if _, ok := entries["a"]; !ok {
+		t.Error("local entry was not published")
+	}
+}
+
+func TestStop_RemovesLocalEntry(t *testing.T) {
+	store := newMemStore()
+	d := New(Config{Instance: "a", Store: store, Hash: func() string { return "h1" }})
+	d.Start()
+	d.Stop()
+	d.Stop()
+
+	entries, _ := store.Entries(context.Background())
+	if len(entries) != 0 {
+		t.Errorf("expected local entry to be removed, got %v", entries)
+	}
+}
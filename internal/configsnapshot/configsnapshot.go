@@ -0,0 +1,368 @@
+// Package configsnapshot keeps a bounded history of configs replaced by
+// reloads, so operators can roll back to a previous working config.
+package configsnapshot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wudi/runway/config"
+)
+
+// Defaults applied by New and NewRedisStore when a field is zero.
+const (
+	DefaultRetention = 10
+	DefaultKey       = "runway:config_snapshots"
+)
+
+// ErrNotFound is returned for an unknown snapshot ID.
+var ErrNotFound = errors.New("snapshot not found")
+
+// Snapshot is a persisted config. Config holds the rendered YAML with
+// secrets removed as described by Secrets and MaskedFields.
+type Snapshot struct {
+	ID           string                `json:"id"`
+	Timestamp    time.Time             `json:"timestamp"`
+	Hash         string                `json:"hash"`
+	Source       string                `json:"source"` // what replaced or captured the config: file, api, cluster, rollback, admin, schedule
+	Secrets      string                `json:"secrets"`
+	MaskedFields []config.MaskedSecret `json:"masked_fields,omitempty"`
+	Config       string                `json:"config,omitempty"`
+}
+
+// Store persists snapshots.
+type Store interface {
+	Save(ctx context.Context, s *Snapshot) error
+	Get(ctx context.Context, id string) (*Snapshot, error)
+	List(ctx context.Context) ([]*Snapshot, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// Config holds manager configuration.
+type Config struct {
+	Store     Store
+	Retention int
+	// Interval enables scheduled snapshots of the running config. A
+	// scheduled snapshot is skipped when the newest one has the same hash.
+	Interval time.Duration
+	// Build renders a config into a snapshot with its secrets removed.
+	Build func(cfg *config.Config) (*Snapshot, error)
+	// Current returns the running config; it is called every Interval.
+	Current func() *config.Config
+	// OnError is called when a scheduled snapshot fails.
+	OnError func(error)
+}
+
+// Manager adds snapshots to a store and prunes it to the retention count.
+type Manager struct {
+	cfg Config
+
+	mu sync.Mutex // serializes Add and pruning
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// New creates a manager. Call Start to begin scheduled snapshots.
+func New(cfg Config) *Manager {
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultRetention
+	}
+	return &Manager{
+		cfg:    cfg,
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+}
+
+// Start takes a snapshot every Interval until Stop. It does nothing when
+// Interval is zero.
+func (m *Manager) Start() {
+	if m.cfg.Interval <= 0 || m.cfg.Current == nil {
+		close(m.doneCh)
+		return
+	}
+	go m.loop()
+}
+
+func (m *Manager) loop() {
+	defer close(m.doneCh)
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.scheduled(context.Background()); err != nil && m.cfg.OnError != nil {
+				m.cfg.OnError(err)
+			}
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops scheduled snapshots. It must only be called after Start.
+func (m *Manager) Stop() {
+	m.stopOnce.Do(func() { close(m.stopCh) })
+	<-m.doneCh
+}
+
+func (m *Manager) scheduled(ctx context.Context) error {
+	s, err := m.cfg.Build(m.cfg.Current())
+	if err != nil {
+		return err
+	}
+	list, err := m.cfg.Store.List(ctx)
+	if err != nil {
+		return err
+	}
+	if len(list) > 0 && list[0].Hash == s.Hash {
+		return nil
+	}
+	s.Source = "schedule"
+	return m.Add(ctx, s)
+}
+
+// Capture builds a snapshot of cfg and adds it. source records what
+// replaced or captured the config.
+func (m *Manager) Capture(ctx context.Context, cfg *config.Config, source string) (*Snapshot, error) {
+	s, err := m.cfg.Build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.Source = source
+	if err := m.Add(ctx, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add assigns s an ID when it has none, saves it and deletes the oldest
+// snapshots beyond the retention count.
+func (m *Manager) Add(ctx context.Context, s *Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s.Timestamp.IsZero() {
+		s.Timestamp = time.Now()
+	}
+	if s.ID == "" {
+		s.ID = s.Timestamp.UTC().Format("20060102T150405.000000000Z")
+	}
+	if err := m.cfg.Store.Save(ctx, s); err != nil {
+		return fmt.Errorf("save snapshot: %w", err)
+	}
+	list, err := m.cfg.Store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("list snapshots: %w", err)
+	}
+	for _, old := range list[min(len(list), m.cfg.Retention):] {
+		if err := m.cfg.Store.Delete(ctx, old.ID); err != nil {
+			return fmt.Errorf("prune snapshot %s: %w", old.ID, err)
+		}
+	}
+	return nil
+}
+
+// List returns the snapshots newest first, without their config.
+func (m *Manager) List(ctx context.Context) ([]*Snapshot, error) {
+	list, err := m.cfg.Store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Snapshot, len(list))
+	for i, s := range list {
+		meta := *s
+		meta.Config = ""
+		out[i] = &meta
+	}
+	return out, nil
+}
+
+// Get returns a snapshot, or ErrNotFound.
+func (m *Manager) Get(ctx context.Context, id string) (*Snapshot, error) {
+	return m.cfg.Store.Get(ctx, id)
+}
+
+// sortNewestFirst orders snapshots by timestamp, newest first.
+func sortNewestFirst(list []*Snapshot) {
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Timestamp.Equal(list[j].Timestamp) {
+			return list[i].Timestamp.After(list[j].Timestamp)
+		}
+		return list[i].ID > list[j].ID
+	})
+}
+
+// validID matches the IDs Add assigns. Anything else could escape the
+// file store's directory.
+var validID = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z.\-_]*$`)
+
+// FileStore keeps one JSON file per snapshot in a directory.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store in dir, creating the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create snapshot dir: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(id string) (string, error) {
+	if !validID.MatchString(id) {
+		return "", ErrNotFound
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// Save writes the snapshot atomically.
+func (s *FileStore) Save(_ context.Context, snap *Snapshot) error {
+	p, err := s.path(snap.ID)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot id %q", snap.ID)
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Get reads a snapshot.
+func (s *FileStore) Get(_ context.Context, id string) (*Snapshot, error) {
+	p, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	return &snap, nil
+}
+
+// List reads every snapshot, newest first, skipping unreadable files.
+func (s *FileStore) List(ctx context.Context) ([]*Snapshot, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var list []*Snapshot
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		if snap, err := s.Get(ctx, id); err == nil {
+			list = append(list, snap)
+		}
+	}
+	sortNewestFirst(list)
+	return list, nil
+}
+
+// Delete removes a snapshot.
+func (s *FileStore) Delete(_ context.Context, id string) error {
+	p, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// RedisStore keeps snapshots as fields of one Redis hash, so replicas
+// sharing the key share the history.
+type RedisStore struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisStore creates a store under key (DefaultKey when empty).
+func NewRedisStore(client *redis.Client, key string) *RedisStore {
+	if key == "" {
+		key = DefaultKey
+	}
+	return &RedisStore{client: client, key: key}
+}
+
+// Save sets the snapshot's field.
+func (s *RedisStore) Save(ctx context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, s.key, snap.ID, data).Err()
+}
+
+// Get reads a snapshot.
+func (s *RedisStore) Get(ctx context.Context, id string) (*Snapshot, error) {
+	raw, err := s.client.HGet(ctx, s.key, id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal([]byte(raw), &snap); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	return &snap, nil
+}
+
+// List reads every snapshot, newest first, skipping malformed ones.
+func (s *RedisStore) List(ctx context.Context) ([]*Snapshot, error) {
+	raw, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Snapshot, 0, len(raw))
+	for _, v := range raw {
+		var snap Snapshot
+		if json.Unmarshal([]byte(v), &snap) == nil {
+			list = append(list, &snap)
+		}
+	}
+	sortNewestFirst(list)
+	return list, nil
+}
+
+// Delete removes a snapshot.
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	return s.client.HDel(ctx, s.key, id).Err()
+}
@@ -0,0 +1,87 @@
+package configsnapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func TestAdd_PrunesToRetention(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(Config{Store: store, Retention: 3})
+	ctx := context.Background()
+	t0 := time.Unix(1000, 0)
+	for i := 0; i < 5; i++ {
+		s := &Snapshot{Timestamp: t0.Add(time.Duration(i) * time.Minute), Hash: fmt.Sprint(i), Source: "file", Config: "routes: []\n"}
+		if err := m.Add(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := m.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("expected 3 snapshots after pruning, got %d", len(list))
+	}
+	for i, want := range []string{"4", "3", "2"} {
+		if list[i].Hash != want {
+			t.Errorf("snapshot %d: expected hash %s, got %s", i, want, list[i].Hash)
+		}
+		if list[i].Config != "" {
+			t.Errorf("snapshot %d: List should omit the config", i)
+		}
+	}
+	if _, err := m.Get(ctx, list[0].ID); err != nil {
+		t.Errorf("expected newest snapshot readable, got %v", err)
+	}
+	oldest := t0.UTC().Format("20060102T150405.000000000Z")
+	if _, err := m.Get(ctx, oldest); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected pruned snapshot gone, got %v", err)
+	}
+}
+
+func TestFileStore_RejectsPathIDs(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"../etc/passwd", "a/b", ".hidden", ""} {
+		if _, err := store.Get(context.Background(), id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%q: expected ErrNotFound, got %v", id, err)
+		}
+	}
+}
+
+func TestScheduled_SkipsUnchangedConfig(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := "a"
+	m := New(Config{
+		Store:   store,
+		Build:   func(*config.Config) (*Snapshot, error) { return &Snapshot{Hash: hash}, nil },
+		Current: func() *config.Config { return nil },
+	})
+	ctx := context.Background()
+	for _, h := range []string{"a", "a", "b"} {
+		hash = h
+		if err := m.scheduled(ctx); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	list, _ := m.List(ctx)
+	if len(list) != 2 || list[0].Hash != "b" || list[0].Source != "schedule" {
+		t.Errorf("expected snapshots b and a from the schedule, got %+v", list)
+	}
+}
@@ -0,0 +1,572 @@
+// Package debugtrace traces selected requests verbosely. An operator starts
+// a trace session through the admin API with a matcher — a client IP, an
+// authenticated client ID, a header value or a request ID prefix — a TTL
+// and a cap on the requests it traces. Matching requests are logged at
+// debug level whatever the runtime log level, with a trace_session field,
+// and the policy decisions made for them are logged when they end.
+//
+// Sessions live in memory only and end at their TTL, at their request cap
+// or when cancelled. While no session exists a request costs one atomic
+// load; otherwise matching is a map lookup per selector kind and a scan of
+// the request ID prefix sessions.
+package debugtrace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wudi/runway/internal/clock"
+	"github.com/wudi/runway/internal/decision"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/variables"
+)
+
+const (
+	// DefaultTTL is the lifetime of sessions started without a TTL.
+	DefaultTTL = 10 * time.Minute
+	// MaxTTL caps the lifetime of a session.
+	MaxTTL = time.Hour
+	// DefaultMaxRequests is the request cap of sessions started without one.
+	DefaultMaxRequests = 100
+	// MaxMaxRequests caps the request cap of a session.
+	MaxMaxRequests = 10000
+	// MaxSessions caps the sessions active at once.
+	MaxSessions = 32
+
+	// ResponseHeader returns a traced request's trace ID to the client
+	// when the session asks for it.
+	ResponseHeader = "X-Debug-Trace-Id"
+)
+
+var (
+	// ErrNotFound is returned for unknown or ended sessions.
+	ErrNotFound = errors.New("trace session not found")
+	// ErrTooManySessions is returned when MaxSessions are active.
+	ErrTooManySessions = fmt.Errorf("at most %d trace sessions can be active", MaxSessions)
+	// ErrDuplicateMatch is returned when an active session already has the
+	// same matcher.
+	ErrDuplicateMatch = errors.New("a trace session with the same match is active")
+)
+
+// Match selects the requests a session traces. Exactly one selector is set:
+// ClientIP, ClientID, Header (with HeaderValue) or RequestIDPrefix.
+type Match struct {
+	ClientIP        string `json:"client_ip,omitempty"`
+	ClientID        string `json:"client_id,omitempty"` // authenticated client, e.g. an API key's client_id
+	Header          string `json:"header,omitempty"`
+	HeaderValue     string `json:"header_value,omitempty"`
+	RequestIDPrefix string `json:"request_id_prefix,omitempty"`
+}
+
+// normalize validates m and returns it in canonical form.
+func (m Match) normalize() (Match, error) {
+	set := 0
+	for _, v := range []string{m.ClientIP, m.ClientID, m.Header, m.RequestIDPrefix} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return m, errors.New("match needs exactly one of client_ip, client_id, header or request_id_prefix")
+	}
+	if m.HeaderValue != "" && m.Header == "" {
+		return m, errors.New("match header_value requires header")
+	}
+	switch {
+	case m.ClientIP != "":
+		ip := net.ParseIP(m.ClientIP)
+		if ip == nil {
+			return m, fmt.Errorf("match client_ip %q is not an IP address", m.ClientIP)
+		}
+		m.ClientIP = ip.String()
+	case m.Header != "":
+		if m.HeaderValue == "" {
+			return m, errors.New("match header requires header_value")
+		}
+		m.Header = http.CanonicalHeaderKey(m.Header)
+	}
+	return m, nil
+}
+
+// Spec is a trace session to start.
+type Spec struct {
+	Match       Match
+	TTL         time.Duration // DefaultTTL when zero
+	MaxRequests int           // DefaultMaxRequests when zero
+	// ResponseHeader returns each traced request's trace ID in the
+	// X-Debug-Trace-Id response header.
+	ResponseHeader bool
+	Actor          string
+	RemoteAddr     string
+}
+
+// Session is a trace session as reported by the admin API.
+type Session struct {
+	ID             string    `json:"id"`
+	Match          Match     `json:"match"`
+	MaxRequests    int       `json:"max_requests"`
+	Traced         int64     `json:"traced"`
+	ResponseHeader bool      `json:"response_header"`
+	Actor          string    `json:"actor,omitempty"`
+	RemoteAddr     string    `json:"remote_addr,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+type session struct {
+	info   Session // Traced is kept in traced
+	traced atomic.Int64
+}
+
+func (s *session) snapshot() Session {
+	info := s.info
+	info.Traced = min(s.traced.Load(), int64(info.MaxRequests))
+	return info
+}
+
+// Tracer holds the trace sessions and traces the requests they match. It
+// is kept across reloads.
+type Tracer struct {
+	clock  clock.Clock
+	active atomic.Bool // any session exists
+
+	mu         sync.RWMutex
+	sessions   map[string]*session
+	byIP       map[string]*session
+	byClient   map[string]*session
+	byHeader   map[string]map[string]*session // canonical name → value
+	prefixes   []*session
+	nextExpiry atomic.Int64 // unix nanos of the earliest session end
+}
+
+// New creates a Tracer telling time with clk.
+func New(clk clock.Clock) *Tracer {
+	return &Tracer{
+		clock:    clk,
+		sessions: make(map[string]*session),
+		byIP:     make(map[string]*session),
+		byClient: make(map[string]*session),
+		byHeader: make(map[string]map[string]*session),
+	}
+}
+
+// Start starts a trace session.
+func (t *Tracer) Start(spec Spec) (Session, error) {
+	m, err := spec.Match.normalize()
+	if err != nil {
+		return Session{}, err
+	}
+	ttl := spec.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	if ttl < 0 || ttl > MaxTTL {
+		return Session{}, fmt.Errorf("ttl must be between 0 and %s", MaxTTL)
+	}
+	maxRequests := spec.MaxRequests
+	if maxRequests == 0 {
+		maxRequests = DefaultMaxRequests
+	}
+	if maxRequests < 0 || maxRequests > MaxMaxRequests {
+		return Session{}, fmt.Errorf("max_requests must be between 0 and %d", MaxMaxRequests)
+	}
+
+	now := t.clock.Now()
+	s := &session{info: Session{
+		ID:             newID(),
+		Match:          m,
+		MaxRequests:    maxRequests,
+		ResponseHeader: spec.ResponseHeader,
+		Actor:          spec.Actor,
+		RemoteAddr:     spec.RemoteAddr,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(ttl),
+	}}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pruneLocked(now)
+	if len(t.sessions) >= MaxSessions {
+		return Session{}, ErrTooManySessions
+	}
+	if t.lookupLocked(m) != nil {
+		return Session{}, ErrDuplicateMatch
+	}
+	t.sessions[s.info.ID] = s
+	switch {
+	case m.ClientIP != "":
+		t.byIP[m.ClientIP] = s
+	case m.ClientID != "":
+		t.byClient[m.ClientID] = s
+	case m.Header != "":
+		if t.byHeader[m.Header] == nil {
+			t.byHeader[m.Header] = make(map[string]*session)
+		}
+		t.byHeader[m.Header][m.HeaderValue] = s
+	default:
+		t.prefixes = append(t.prefixes, s)
+	}
+	t.updateLocked()
+	logging.Info("Debug trace session started",
+		zap.String("trace_session", s.info.ID),
+		zap.Any("match", m),
+		zap.Time("expires_at", s.info.ExpiresAt),
+		zap.Int("max_requests", maxRequests),
+		zap.String("actor", spec.Actor),
+		zap.String("remote_addr", spec.RemoteAddr),
+	)
+	return s.snapshot(), nil
+}
+
+// lookupLocked returns the session with matcher m.
+func (t *Tracer) lookupLocked(m Match) *session {
+	switch {
+	case m.ClientIP != "":
+		return t.byIP[m.ClientIP]
+	case m.ClientID != "":
+		return t.byClient[m.ClientID]
+	case m.Header != "":
+		return t.byHeader[m.Header][m.HeaderValue]
+	}
+	for _, s := range t.prefixes {
+		if s.info.Match.RequestIDPrefix == m.RequestIDPrefix {
+			return s
+		}
+	}
+	return nil
+}
+
+// Cancel ends a session.
+func (t *Tracer) Cancel(id string) (Session, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.sessions[id]
+	if !ok || !s.info.ExpiresAt.After(t.clock.Now()) {
+		return Session{}, ErrNotFound
+	}
+	t.removeLocked(s, "cancelled")
+	return s.snapshot(), nil
+}
+
+// Get returns an active session.
+func (t *Tracer) Get(id string) (Session, error) {
+	t.mu.RLock()
+	s, ok := t.sessions[id]
+	t.mu.RUnlock()
+	if !ok || !s.info.ExpiresAt.After(t.clock.Now()) {
+		return Session{}, ErrNotFound
+	}
+	return s.snapshot(), nil
+}
+
+// Sessions returns the active sessions, oldest first.
+func (t *Tracer) Sessions() []Session {
+	now := t.clock.Now()
+	t.mu.Lock()
+	t.pruneLocked(now)
+	out := make([]Session, 0, len(t.sessions))
+	for _, s := range t.sessions {
+		out = append(out, s.snapshot())
+	}
+	t.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// pruneLocked removes the sessions that expired by now.
+func (t *Tracer) pruneLocked(now time.Time) {
+	for _, s := range t.sessions {
+		if !s.info.ExpiresAt.After(now) {
+			t.removeLocked(s, "expired")
+		}
+	}
+}
+
+func (t *Tracer) removeLocked(s *session, reason string) {
+	if t.sessions[s.info.ID] != s {
+		return
+	}
+	delete(t.sessions, s.info.ID)
+	m := s.info.Match
+	switch {
+	case m.ClientIP != "":
+		delete(t.byIP, m.ClientIP)
+	case m.ClientID != "":
+		delete(t.byClient, m.ClientID)
+	case m.Header != "":
+		delete(t.byHeader[m.Header], m.HeaderValue)
+		if len(t.byHeader[m.Header]) == 0 {
+			delete(t.byHeader, m.Header)
+		}
+	default:
+		for i, p := range t.prefixes {
+			if p == s {
+				t.prefixes = append(t.prefixes[:i:i], t.prefixes[i+1:]...)
+				break
+			}
+		}
+	}
+	t.updateLocked()
+	logging.Info("Debug trace session ended",
+		zap.String("trace_session", s.info.ID),
+		zap.String("reason", reason),
+		zap.Int64("traced", s.snapshot().Traced),
+	)
+}
+
+// updateLocked refreshes the fast-path state after sessions changed.
+func (t *Tracer) updateLocked() {
+	var next time.Time
+	for _, s := range t.sessions {
+		if next.IsZero() || s.info.ExpiresAt.Before(next) {
+			next = s.info.ExpiresAt
+		}
+	}
+	t.nextExpiry.Store(next.UnixNano())
+	t.active.Store(len(t.sessions) > 0)
+}
+
+// Active reports whether any session exists.
+func (t *Tracer) Active() bool {
+	return t.active.Load()
+}
+
+// matchRequest returns the session of the first selector r matches: its
+// client IP, its headers, then its request ID.
+func (t *Tracer) matchRequest(r *http.Request, requestID string) *session {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.byIP) > 0 {
+		if s := t.byIP[variables.ExtractClientIP(r)]; s != nil {
+			return s
+		}
+	}
+	for name, values := range t.byHeader {
+		if v := r.Header.Get(name); v != "" {
+			if s := values[v]; s != nil {
+				return s
+			}
+		}
+	}
+	for _, s := range t.prefixes {
+		if strings.HasPrefix(requestID, s.info.Match.RequestIDPrefix) {
+			return s
+		}
+	}
+	return nil
+}
+
+// admit counts a request against s and reports whether it is traced. The
+// request reaching the cap ends the session.
+func (t *Tracer) admit(s *session) (int64, bool) {
+	now := t.clock.Now()
+	if !s.info.ExpiresAt.After(now) {
+		t.mu.Lock()
+		t.removeLocked(s, "expired")
+		t.mu.Unlock()
+		return 0, false
+	}
+	n := s.traced.Add(1)
+	if n > int64(s.info.MaxRequests) {
+		return 0, false
+	}
+	if n == int64(s.info.MaxRequests) {
+		t.mu.Lock()
+		t.removeLocked(s, "max_requests")
+		t.mu.Unlock()
+	}
+	return n, true
+}
+
+// Trace is the tracing of one request.
+type Trace struct {
+	ID      string // session ID and the request's number in the session
+	Session string
+	logger  *zap.Logger
+	rec     *decision.Recorder
+	start   time.Time
+}
+
+type traceKey struct{}
+
+// slot holds the trace of a request while sessions exist, so a trace
+// started once the client is authenticated is finished by the middleware.
+type slot struct {
+	trace *Trace
+}
+
+// FromContext returns the trace of a traced request, or nil.
+func FromContext(ctx context.Context) *Trace {
+	if sl, ok := ctx.Value(traceKey{}).(*slot); ok {
+		return sl.trace
+	}
+	return nil
+}
+
+// begin starts tracing a request matched by s, returning the request
+// carrying the trace and the trace, or nil when s is no longer admitting.
+func (t *Tracer) begin(w http.ResponseWriter, r *http.Request, sl *slot, s *session, matchedBy string) (*http.Request, *Trace) {
+	n, ok := t.admit(s)
+	if !ok {
+		return r, nil
+	}
+	tr := &Trace{
+		ID:      s.info.ID + "-" + strconv.FormatInt(n, 10),
+		Session: s.info.ID,
+		start:   time.Now(),
+	}
+	sl.trace = tr
+	if s.info.ResponseHeader {
+		w.Header().Set(ResponseHeader, tr.ID)
+	}
+
+	varCtx := variables.GetFromRequest(r)
+	tr.logger = logging.Verbose(
+		zap.String("trace_session", s.info.ID),
+		zap.String("trace_id", tr.ID),
+		zap.String("request_id", varCtx.RequestID),
+	)
+	ctx := logging.NewContext(r.Context(), tr.logger)
+	if rec := decision.FromContext(ctx); rec != nil {
+		tr.rec = rec
+	} else {
+		tr.rec = decision.NewRecorder(0, 0, nil)
+		ctx = decision.WithRecorder(ctx, tr.rec)
+	}
+	r = r.WithContext(ctx)
+
+	headers := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	logging.DebugContext(ctx, "Debug trace: request",
+		zap.String("matched_by", matchedBy),
+		zap.String("method", r.Method),
+		zap.String("host", r.Host),
+		zap.String("path", r.URL.Path),
+		zap.String("query", r.URL.RawQuery),
+		zap.String("client_ip", variables.ExtractClientIP(r)),
+		zap.String("proto", r.Proto),
+		zap.Strings("headers", headers),
+	)
+	return r, tr
+}
+
+// finish logs the end of a traced request with its decisions. r is the
+// request as the middleware saw it, which a trace started at
+// authentication does not carry.
+func (tr *Trace) finish(r *http.Request) {
+	varCtx := variables.GetFromRequest(r)
+	entries, dropped := tr.rec.Entries()
+	fields := []zap.Field{
+		zap.String("route", varCtx.RouteID),
+		zap.Int("status", varCtx.Status),
+		zap.Float64("duration_ms", float64(time.Since(tr.start).Microseconds())/1000),
+		zap.Any("decisions", entries),
+	}
+	if varCtx.UpstreamAddr != "" {
+		fields = append(fields,
+			zap.String("upstream", varCtx.UpstreamAddr),
+			zap.Int("upstream_status", varCtx.UpstreamStatus),
+		)
+	}
+	if varCtx.Identity != nil {
+		fields = append(fields, zap.String("client_id", varCtx.Identity.ClientID))
+	}
+	if varCtx.TenantID != "" {
+		fields = append(fields, zap.String("tenant", varCtx.TenantID))
+	}
+	if dropped > 0 {
+		fields = append(fields, zap.Int("dropped_decisions", dropped))
+	}
+	logging.DebugContext(logging.NewContext(r.Context(), tr.logger), "Debug trace: response", fields...)
+}
+
+// Middleware traces the requests that match a session by client IP,
+// header or request ID. It runs after the request ID is assigned and
+// outside the access log, which sets the recorded status.
+func (t *Tracer) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !t.active.Load() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if now := t.clock.Now(); now.UnixNano() >= t.nextExpiry.Load() {
+				t.mu.Lock()
+				t.pruneLocked(now)
+				t.mu.Unlock()
+			}
+
+			sl := &slot{}
+			r = r.WithContext(context.WithValue(r.Context(), traceKey{}, sl))
+			requestID := variables.GetFromRequest(r).RequestID
+			if s := t.matchRequest(r, requestID); s != nil {
+				r, _ = t.begin(w, r, sl, s, matchKind(s.info.Match))
+			}
+
+			next.ServeHTTP(w, r)
+
+			if sl.trace != nil {
+				sl.trace.finish(r)
+			}
+		})
+	}
+}
+
+// TraceClient starts tracing an authenticated request whose client has a
+// session, returning the request carrying the trace. Requests already
+// traced, or served while no session exists, are returned unchanged.
+func (t *Tracer) TraceClient(w http.ResponseWriter, r *http.Request, clientID string) *http.Request {
+	if t == nil || !t.active.Load() || clientID == "" {
+		return r
+	}
+	sl, ok := r.Context().Value(traceKey{}).(*slot)
+	if !ok || sl.trace != nil {
+		return r
+	}
+	t.mu.RLock()
+	s := t.byClient[clientID]
+	t.mu.RUnlock()
+	if s == nil {
+		return r
+	}
+	r, _ = t.begin(w, r, sl, s, "client_id")
+	return r
+}
+
+func matchKind(m Match) string {
+	switch {
+	case m.ClientIP != "":
+		return "client_ip"
+	case m.Header != "":
+		return "header"
+	}
+	return "request_id_prefix"
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,229 @@
+package debugtrace
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wudi/runway/internal/clock"
+	"github.com/wudi/runway/internal/decision"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/variables"
+)
+
+// observeLogs installs an info-level observer as the global logger.
+func observeLogs(t *testing.T) *observer.ObservedLogs {
+	t.Helper()
+	core, obs := observer.New(zapcore.InfoLevel)
+	original := logging.Global()
+	logging.SetGlobal(zap.New(core))
+	t.Cleanup(func() { logging.SetGlobal(original) })
+	return obs
+}
+
+// serve runs a request with the given request ID through the tracer's
+// middleware; the handler logs at debug level and records a decision.
+func serve(tr *Tracer, requestID string, edit func(*http.Request)) *httptest.ResponseRecorder {
+	h := tr.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.DebugContext(r.Context(), "handler detail")
+		if rec := decision.FromContext(r.Context()); rec != nil {
+			rec.Add("auth", decision.Allow)
+		}
+		variables.GetFromRequest(r).Status = http.StatusTeapot
+	}))
+	r := httptest.NewRequest("GET", "/items", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	if edit != nil {
+		edit(r)
+	}
+	varCtx := variables.NewContext(r)
+	varCtx.RequestID = requestID
+	r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func tracedMessages(obs *observer.ObservedLogs, session string) []string {
+	var msgs []string
+	for _, e := range obs.All() {
+		if e.ContextMap()["trace_session"] == session && e.Level == zapcore.DebugLevel {
+			msgs = append(msgs, e.Message)
+		}
+	}
+	return msgs
+}
+
+func TestStartValidation(t *testing.T) {
+	observeLogs(t)
+	tr := New(clock.Real{})
+	tests := []struct {
+		name    string
+		spec    Spec
+		wantErr string
+	}{
+		{"no selector", Spec{}, "exactly one of"},
+		{"two selectors", Spec{Match: Match{ClientIP: "10.0.0.1", ClientID: "c"}}, "exactly one of"},
+		{"bad ip", Spec{Match: Match{ClientIP: "nope"}}, "not an IP address"},
+		{"header without value", Spec{Match: Match{Header: "X-Tenant"}}, "requires header_value"},
+		{"value without header", Spec{Match: Match{ClientID: "c", HeaderValue: "v"}}, "header_value requires header"},
+		{"ttl too long", Spec{Match: Match{ClientID: "c"}, TTL: 2 * time.Hour}, "ttl must be"},
+		{"too many requests", Spec{Match: Match{ClientID: "c"}, MaxRequests: MaxMaxRequests + 1}, "max_requests must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tr.Start(tt.spec); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	s, err := tr.Start(Spec{Match: Match{Header: "x-tenant", HeaderValue: "acme"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Match.Header != "X-Tenant" || s.MaxRequests != DefaultMaxRequests || s.ExpiresAt.Sub(s.CreatedAt) != DefaultTTL {
+		t.Errorf("unexpected defaults %+v", s)
+	}
+	if _, err := tr.Start(Spec{Match: Match{Header: "X-Tenant", HeaderValue: "acme"}}); !errors.Is(err, ErrDuplicateMatch) {
+		t.Errorf("expected ErrDuplicateMatch, got %v", err)
+	}
+}
+
+func TestElevatedLoggingOnlyForMatchingRequests(t *testing.T) {
+	obs := observeLogs(t)
+	tr := New(clock.Real{})
+	if tr.Active() {
+		t.Fatal("no session should be active")
+	}
+	s, err := tr.Start(Spec{Match: Match{RequestIDPrefix: "support-"}, ResponseHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(tr, "support-123", nil)
+	if got := w.Header().Get(ResponseHeader); got != s.ID+"-1" {
+		t.Errorf("%s = %q, want %q", ResponseHeader, got, s.ID+"-1")
+	}
+	other := serve(tr, "abc", nil)
+	if other.Header().Get(ResponseHeader) != "" {
+		t.Error("unmatched request got a trace ID")
+	}
+
+	msgs := tracedMessages(obs, s.ID)
+	if strings.Join(msgs, ",") != "Debug trace: request,handler detail,Debug trace: response" {
+		t.Fatalf("unexpected traced entries %q", msgs)
+	}
+	for _, e := range obs.All() {
+		if e.Level == zapcore.DebugLevel && e.ContextMap()["request_id"] != "support-123" {
+			t.Errorf("debug entry for an untraced request: %s %v", e.Message, e.ContextMap())
+		}
+		if e.Message == "Debug trace: response" {
+			if e.ContextMap()["status"] != int64(http.StatusTeapot) {
+				t.Errorf("unexpected status in %v", e.ContextMap())
+			}
+			if d, ok := e.ContextMap()["decisions"].([]decision.Entry); !ok || len(d) != 1 {
+				t.Errorf("expected the recorded decision, got %v", e.ContextMap()["decisions"])
+			}
+		}
+	}
+	if got, _ := tr.Get(s.ID); got.Traced != 1 {
+		t.Errorf("traced = %d, want 1", got.Traced)
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	observeLogs(t)
+	tr := New(clock.Real{})
+	byIP, _ := tr.Start(Spec{Match: Match{ClientIP: "10.0.0.1"}, ResponseHeader: true})
+	byHeader, _ := tr.Start(Spec{Match: Match{Header: "X-Tenant", HeaderValue: "acme"}, ResponseHeader: true})
+
+	if got := serve(tr, "r1", nil).Header().Get(ResponseHeader); !strings.HasPrefix(got, byIP.ID) {
+		t.Errorf("expected the client IP session, got %q", got)
+	}
+	tenant := func(r *http.Request) {
+		r.RemoteAddr = "10.9.9.9:1"
+		r.Header.Set("X-Tenant", "acme")
+	}
+	if got := serve(tr, "r2", tenant).Header().Get(ResponseHeader); !strings.HasPrefix(got, byHeader.ID) {
+		t.Errorf("expected the header session, got %q", got)
+	}
+	if got := serve(tr, "r3", func(r *http.Request) { r.RemoteAddr = "10.9.9.9:1" }).Header().Get(ResponseHeader); got != "" {
+		t.Errorf("expected no trace, got %q", got)
+	}
+}
+
+func TestTraceClient(t *testing.T) {
+	obs := observeLogs(t)
+	tr := New(clock.Real{})
+	s, _ := tr.Start(Spec{Match: Match{ClientID: "partner-1"}, ResponseHeader: true})
+
+	h := tr.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stands in for authentication.
+		r = tr.TraceClient(w, r, r.Header.Get("X-Client"))
+		logging.DebugContext(r.Context(), "after auth")
+	}))
+	for _, client := range []string{"partner-1", "partner-2"} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Client", client)
+		r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, variables.NewContext(r)))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if traced := w.Header().Get(ResponseHeader) != ""; traced != (client == "partner-1") {
+			t.Errorf("%s: traced = %v", client, traced)
+		}
+	}
+	if msgs := tracedMessages(obs, s.ID); len(msgs) != 3 {
+		t.Errorf("expected request, after auth and response entries, got %q", msgs)
+	}
+}
+
+func TestSessionEnds(t *testing.T) {
+	observeLogs(t)
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	tr := New(clk)
+
+	capped, _ := tr.Start(Spec{Match: Match{RequestIDPrefix: "cap-"}, MaxRequests: 2, ResponseHeader: true})
+	for i, want := range []bool{true, true, false} {
+		if traced := serve(tr, "cap-x", nil).Header().Get(ResponseHeader) != ""; traced != want {
+			t.Errorf("request %d: traced = %v, want %v", i+1, traced, want)
+		}
+	}
+	if _, err := tr.Get(capped.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the capped session to end, got %v", err)
+	}
+	if tr.Active() {
+		t.Error("no session should remain")
+	}
+
+	timed, _ := tr.Start(Spec{Match: Match{RequestIDPrefix: "ttl-"}, TTL: time.Minute, ResponseHeader: true})
+	if serve(tr, "ttl-1", nil).Header().Get(ResponseHeader) == "" {
+		t.Fatal("expected a trace before the TTL")
+	}
+	clk.Advance(time.Minute)
+	if serve(tr, "ttl-2", nil).Header().Get(ResponseHeader) != "" {
+		t.Error("traced after the TTL")
+	}
+	if tr.Active() || len(tr.Sessions()) != 0 {
+		t.Errorf("expected the session to expire, got %+v", tr.Sessions())
+	}
+	if _, err := tr.Cancel(timed.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound cancelling an expired session, got %v", err)
+	}
+
+	cancelled, _ := tr.Start(Spec{Match: Match{RequestIDPrefix: "c-"}})
+	if got, err := tr.Cancel(cancelled.ID); err != nil || got.ID != cancelled.ID {
+		t.Errorf("Cancel = %+v, %v", got, err)
+	}
+	if serve(tr, "c-1", nil).Header().Get(ResponseHeader) != "" || tr.Active() {
+		t.Error("traced after cancel")
+	}
+}
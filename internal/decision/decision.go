@@ -0,0 +1,168 @@
+// Package decision records the policy decisions made for a request on
+// routes with decision_log enabled.
+//
+// A recorded request carries a Recorder in its context. Middlewares that
+// make policy decisions (authentication, request rules, WAF, rate limits,
+// quotas, geo and tenant resolution) append one compact Entry each. They
+// look the recorder up with FromContext and build the entry only when it
+// is non-nil, so routes without a decision log pay one context lookup.
+//
+// Values that identify a caller (client IDs, claim values, rate limit
+// keys, addresses) are recorded through Recorder.Hash, never verbatim.
+package decision
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// SchemaVersion is the version of the decision record schema. It changes
+// whenever a field is renamed or removed or its meaning changes.
+const SchemaVersion = 1
+
+// Outcomes.
+const (
+	Allow   = "allow"   // the stage let the request continue
+	Deny    = "deny"    // the stage answered the request itself
+	Match   = "match"   // a rule matched
+	Detect  = "detect"  // a rule matched but the stage does not block
+	Debit   = "debit"   // a rate limit or quota was charged
+	Resolve = "resolve" // an attribute of the request was determined
+	Route   = "route"   // the request was sent to a backend
+)
+
+const (
+	// DefaultMaxEntries is the number of entries kept per request.
+	DefaultMaxEntries = 64
+	// DefaultMaxValueLength caps the length of each attribute value.
+	DefaultMaxValueLength = 256
+	// maxAttrs caps the attributes of one entry.
+	maxAttrs = 16
+)
+
+// Entry is one decision.
+type Entry struct {
+	Stage   string            `json:"stage"`
+	Outcome string            `json:"outcome"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+	AtUS    int64             `json:"at_us"` // microseconds since the recording started
+}
+
+// Recorder accumulates the decisions made for one request. It is safe for
+// concurrent use.
+type Recorder struct {
+	start      time.Time
+	maxEntries int
+	maxValue   int
+	hashKey    []byte
+
+	mu      sync.Mutex
+	entries []Entry
+	dropped int
+
+	forward *Recorder // also receives the decisions, see Forward
+}
+
+// NewRecorder creates a recorder keeping at most maxEntries entries with
+// values cut to maxValue bytes; non-positive limits select the defaults.
+// With a hashKey, Hash is an HMAC under that key, so hashed values cannot
+// be recovered by hashing candidate values without it.
+func NewRecorder(maxEntries, maxValue int, hashKey []byte) *Recorder {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	if maxValue <= 0 {
+		maxValue = DefaultMaxValueLength
+	}
+	return &Recorder{
+		start:      time.Now(),
+		maxEntries: maxEntries,
+		maxValue:   maxValue,
+		hashKey:    hashKey,
+	}
+}
+
+type recorderKey struct{}
+
+// WithRecorder returns a context carrying rec.
+func WithRecorder(ctx context.Context, rec *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, rec)
+}
+
+// FromContext returns the recorder of a recorded request, or nil.
+func FromContext(ctx context.Context) *Recorder {
+	rec, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return rec
+}
+
+// Forward makes rec pass every decision it records on to parent as well,
+// so a recorder attached further out, such as a debug trace's, sees the
+// decisions recorded for a route's decision log. Values are forwarded as
+// recorded, hashed under rec's key. A nil parent is ignored.
+func (rec *Recorder) Forward(parent *Recorder) {
+	if parent != rec {
+		rec.forward = parent
+	}
+}
+
+// Add appends a decision. kv holds attribute names and values in turn; a
+// trailing name without a value is ignored. Entries past the recorder's
+// limit are counted but not kept.
+func (rec *Recorder) Add(stage, outcome string, kv ...string) {
+	if rec.forward != nil {
+		rec.forward.Add(stage, outcome, kv...)
+	}
+	e := Entry{Stage: stage, Outcome: outcome, AtUS: time.Since(rec.start).Microseconds()}
+	if n := len(kv) / 2; n > 0 {
+		if n > maxAttrs {
+			n = maxAttrs
+		}
+		e.Attrs = make(map[string]string, n)
+		for i := 0; i < 2*n; i += 2 {
+			v := kv[i+1]
+			if len(v) > rec.maxValue {
+				v = v[:rec.maxValue]
+			}
+			e.Attrs[kv[i]] = v
+		}
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.entries) >= rec.maxEntries {
+		rec.dropped++
+		return
+	}
+	rec.entries = append(rec.entries, e)
+}
+
+// Entries returns the recorded decisions and the number dropped over the
+// limit.
+func (rec *Recorder) Entries() ([]Entry, int) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]Entry(nil), rec.entries...), rec.dropped
+}
+
+// Hash returns the reference recorded in place of a sensitive value:
+// "sha256:" and the first 16 bytes of its (keyed) digest in hex. Empty
+// values stay empty.
+func (rec *Recorder) Hash(v string) string {
+	if v == "" {
+		return ""
+	}
+	var sum []byte
+	if len(rec.hashKey) > 0 {
+		mac := hmac.New(sha256.New, rec.hashKey)
+		mac.Write([]byte(v))
+		sum = mac.Sum(nil)
+	} else {
+		s := sha256.Sum256([]byte(v))
+		sum = s[:]
+	}
+	return "sha256:" + hex.EncodeToString(sum[:16])
+}
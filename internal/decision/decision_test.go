@@ -0,0 +1,81 @@
+package decision
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != nil {
+		t.Fatal("unrecorded context should have no recorder")
+	}
+	rec := NewRecorder(0, 0, nil)
+	if FromContext(WithRecorder(context.Background(), rec)) != rec {
+		t.Fatal("recorder not found in context")
+	}
+}
+
+func TestRecorder_Bounds(t *testing.T) {
+	rec := NewRecorder(2, 4, nil)
+	rec.Add("auth", Allow, "method", "jwt_long", "dangling")
+	rec.Add("rules", Match, "rule", "r1")
+	rec.Add("waf", Allow)
+
+	entries, dropped := rec.Entries()
+	if len(entries) != 2 || dropped != 1 {
+		t.Fatalf("entries = %d, dropped = %d; want 2 and 1", len(entries), dropped)
+	}
+	if entries[0].Stage != "auth" || entries[0].Outcome != Allow {
+		t.Errorf("first entry = %+v", entries[0])
+	}
+	if got := entries[0].Attrs["method"]; got != "jwt_" {
+		t.Errorf("value not truncated: %q", got)
+	}
+	if _, ok := entries[0].Attrs["dangling"]; ok || len(entries[0].Attrs) != 1 {
+		t.Errorf("trailing name should be ignored: %v", entries[0].Attrs)
+	}
+}
+
+func TestRecorder_Hash(t *testing.T) {
+	plain := NewRecorder(0, 0, nil)
+	keyed := NewRecorder(0, 0, []byte("secret"))
+
+	if plain.Hash("") != "" {
+		t.Error("empty value should stay empty")
+	}
+	h := plain.Hash("client-1")
+	if !strings.HasPrefix(h, "sha256:") || len(h) != len("sha256:")+32 {
+		t.Errorf("unexpected hash format %q", h)
+	}
+	if h != plain.Hash("client-1") {
+		t.Error("hash should be stable")
+	}
+	if h == plain.Hash("client-2") {
+		t.Error("different values should hash differently")
+	}
+	if keyed.Hash("client-1") == h {
+		t.Error("keyed hash should differ from the plain digest")
+	}
+}
+
+func TestRecorder_Forward(t *testing.T) {
+	outer := NewRecorder(0, 0, nil)
+	inner := NewRecorder(1, 0, []byte("k"))
+	inner.Forward(outer)
+	inner.Forward(inner) // ignored
+
+	inner.Add("auth", Allow, "client", inner.Hash("c1"))
+	inner.Add("waf", Allow)
+
+	if entries, dropped := inner.Entries(); len(entries) != 1 || dropped != 1 {
+		t.Errorf("inner: %d entries, %d dropped", len(entries), dropped)
+	}
+	entries, dropped := outer.Entries()
+	if len(entries) != 2 || dropped != 0 {
+		t.Fatalf("outer: %d entries, %d dropped; want 2 and 0", len(entries), dropped)
+	}
+	if entries[0].Attrs["client"] != inner.Hash("c1") {
+		t.Errorf("forwarded value should be the inner hash, got %q", entries[0].Attrs["client"])
+	}
+}
@@ -0,0 +1,271 @@
+// Package egress enforces the egress policy: outbound connections may only
+// reach allowlisted domains and CIDRs. The proxy dialer checks every
+// connection after the SSRF guard, and service discovery checks backends
+// before they reach a route's balancer.
+package egress
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+// Policy modes.
+const (
+	ModeEnforce = "enforce"
+	ModeWarn    = "warn"
+)
+
+// SourceProxy is the source of dials that carry no other source.
+const SourceProxy = "proxy"
+
+// maxRecent is the number of recent violations kept for the admin API.
+const maxRecent = 50
+
+// reportWindow is how long a violation keeps the health report in
+// "violation" status.
+const reportWindow = 5 * time.Minute
+
+// Violation is a destination outside the allowlist.
+type Violation struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Host    string    `json:"host"`
+	Detail  string    `json:"detail,omitempty"`
+	Blocked bool      `json:"blocked"`
+}
+
+// Policy checks destinations against the allowlist and counts violations
+// by the feature that attempted them.
+type Policy struct {
+	cfg     config.EgressPolicyConfig
+	mode    string
+	domains []string
+	cidrs   []*net.IPNet
+
+	mu       sync.Mutex
+	bySource map[string]int64
+	recent   []Violation
+	next     int
+}
+
+// New creates a policy from config. Returns an error if a CIDR is invalid.
+func New(cfg config.EgressPolicyConfig) (*Policy, error) {
+	p := &Policy{
+		cfg:      cfg,
+		mode:     cfg.Mode,
+		bySource: make(map[string]int64),
+	}
+	if p.mode == "" {
+		p.mode = ModeEnforce
+	}
+	for _, d := range cfg.AllowedDomains {
+		if d = strings.ToLower(strings.Trim(d, ".*")); d != "" {
+			p.domains = append(p.domains, d)
+		}
+	}
+	for _, c := range cfg.AllowedCIDRs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("egress: invalid allowed_cidrs entry %q: %w", c, err)
+		}
+		p.cidrs = append(p.cidrs, n)
+	}
+	return p, nil
+}
+
+// Config returns the config the policy was built from.
+func (p *Policy) Config() config.EgressPolicyConfig {
+	return p.cfg
+}
+
+// Enforcing reports whether violations are refused rather than only reported.
+func (p *Policy) Enforcing() bool {
+	return p.mode == ModeEnforce
+}
+
+// AllowsHost reports whether host matches an allowed domain by suffix or,
+// for IP literals, falls within an allowed CIDR.
+func (p *Policy) AllowsHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ip := net.ParseIP(host); ip != nil {
+		return p.allowsIP(ip)
+	}
+	return p.matchesDomain(host)
+}
+
+// CheckHost checks a destination before it is used, recording a violation
+// for source. It returns an error only when the policy refuses the host.
+func (p *Policy) CheckHost(source, host, detail string) error {
+	if p.AllowsHost(host) {
+		return nil
+	}
+	return p.violation(source, host, detail)
+}
+
+// CheckDial checks a connection to host resolved to ips. A host matching an
+// allowed domain may resolve anywhere; any other host must resolve only to
+// allowed CIDRs. The source is taken from ctx.
+func (p *Policy) CheckDial(ctx context.Context, host string, ips []net.IP) error {
+	name := strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(name) == nil && p.matchesDomain(name) {
+		return nil
+	}
+	for _, ip := range ips {
+		if !p.allowsIP(ip) {
+			return p.violation(SourceFrom(ctx), host, ip.String())
+		}
+	}
+	return nil
+}
+
+// FilterBackends checks discovered backends of owner, dropping those the
+// policy refuses. In warn mode every backend is kept.
+func (p *Policy) FilterBackends(source, owner string, backends []*loadbalancer.Backend) []*loadbalancer.Backend {
+	kept := make([]*loadbalancer.Backend, 0, len(backends))
+	for _, b := range backends {
+		host := ""
+		if u := b.ParsedURL; u != nil {
+			host = u.Hostname()
+		} else if u, err := url.Parse(b.URL); err == nil {
+			host = u.Hostname()
+		}
+		if err := p.CheckHost(source, host, owner); err != nil {
+			continue
+		}
+		kept = append(kept, b)
+	}
+	return kept
+}
+
+func (p *Policy) matchesDomain(host string) bool {
+	for _, d := range p.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) allowsIP(ip net.IP) bool {
+	for _, n := range p.cidrs {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// violation records a destination outside the allowlist and returns the
+// error refusing it in enforce mode.
+func (p *Policy) violation(source, host, detail string) error {
+	v := Violation{
+		Time:    time.Now(),
+		Source:  source,
+		Host:    host,
+		Detail:  detail,
+		Blocked: p.Enforcing(),
+	}
+	p.mu.Lock()
+	p.bySource[source]++
+	if len(p.recent) < maxRecent {
+		p.recent = append(p.recent, v)
+	} else {
+		p.recent[p.next] = v
+	}
+	p.next = (p.next + 1) % maxRecent
+	p.mu.Unlock()
+
+	logging.Warn("egress destination not in allowlist",
+		zap.String("source", source),
+		zap.String("host", host),
+		zap.String("detail", detail),
+		zap.Bool("blocked", v.Blocked),
+	)
+	if !v.Blocked {
+		return nil
+	}
+	return fmt.Errorf("egress: connection to %s blocked by egress policy (source %s)", host, source)
+}
+
+// Violations returns the number of violations by source.
+func (p *Policy) Violations() map[string]int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string]int64, len(p.bySource))
+	for k, v := range p.bySource {
+		out[k] = v
+	}
+	return out
+}
+
+// Recent returns the most recent violations, newest first.
+func (p *Policy) Recent() []Violation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]Violation, 0, len(p.recent))
+	for i := 1; i <= len(p.recent); i++ {
+		out = append(out, p.recent[(p.next-i+maxRecent)%maxRecent])
+	}
+	return out
+}
+
+// Stats returns admin status information.
+func (p *Policy) Stats() map[string]interface{} {
+	var total int64
+	violations := p.Violations()
+	for _, n := range violations {
+		total += n
+	}
+	return map[string]interface{}{
+		"enabled":              true,
+		"mode":                 p.mode,
+		"allowed_domains":      p.domains,
+		"allowed_cidrs":        p.cfg.AllowedCIDRs,
+		"total_violations":     total,
+		"violations_by_source": violations,
+		"recent":               p.Recent(),
+	}
+}
+
+// HealthReport summarizes violations for the health and dependency
+// reports: "violation" while the last one is recent, "ok" otherwise.
+func (p *Policy) HealthReport() map[string]interface{} {
+	report := map[string]interface{}{
+		"status":               "ok",
+		"mode":                 p.mode,
+		"violations_by_source": p.Violations(),
+	}
+	if recent := p.Recent(); len(recent) > 0 {
+		last := recent[0]
+		report["last_violation"] = last
+		if time.Since(last.Time) < reportWindow {
+			report["status"] = "violation"
+		}
+	}
+	return report
+}
+
+type sourceKey struct{}
+
+// WithSource tags ctx with the feature dialing through it.
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFrom returns the feature tagged on ctx, or SourceProxy.
+func SourceFrom(ctx context.Context) string {
+	if s, ok := ctx.Value(sourceKey{}).(string); ok {
+		return s
+	}
+	return SourceProxy
+}
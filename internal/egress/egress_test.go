@@ -0,0 +1,140 @@
+package egress
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/loadbalancer"
+)
+
+func newTestPolicy(t *testing.T, mode string) *Policy {
+	t.Helper()
+	p, err := New(config.EgressPolicyConfig{
+		Enabled:        true,
+		Mode:           mode,
+		AllowedDomains: []string{"internal.example.com", ".api.partner.io"},
+		AllowedCIDRs:   []string{"10.0.0.0/8"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func backends(urls ...string) []*loadbalancer.Backend {
+	var out []*loadbalancer.Backend
+	for _, u := range urls {
+		b := &loadbalancer.Backend{URL: u, Weight: 1, Healthy: true}
+		b.InitParsedURL()
+		out = append(out, b)
+	}
+	return out
+}
+
+func TestAllowsHost(t *testing.T) {
+	p := newTestPolicy(t, "")
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"internal.example.com", true},
+		{"users.internal.example.com", true},
+		{"Users.Internal.Example.COM.", true},
+		{"eu.api.partner.io", true},
+		{"evilinternal.example.com", false},
+		{"example.com", false},
+		{"10.1.2.3", true},
+		{"192.168.1.1", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := p.AllowsHost(tt.host); got != tt.want {
+			t.Errorf("AllowsHost(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDial(t *testing.T) {
+	p := newTestPolicy(t, "")
+	ctx := WithSource(context.Background(), "sequential")
+
+	// An allowed domain may resolve anywhere.
+	if err := p.CheckDial(ctx, "users.internal.example.com", []net.IP{net.ParseIP("203.0.113.5")}); err != nil {
+		t.Errorf("expected allowed domain to pass, got %v", err)
+	}
+	// Any other host must resolve only to allowed CIDRs.
+	if err := p.CheckDial(ctx, "svc.local", []net.IP{net.ParseIP("10.0.0.1")}); err != nil {
+		t.Errorf("expected host inside allowed CIDRs to pass, got %v", err)
+	}
+	if err := p.CheckDial(ctx, "svc.local", []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("203.0.113.5")}); err == nil {
+		t.Error("expected host resolving outside allowed CIDRs to be blocked")
+	}
+	if got := p.Violations(); got["sequential"] != 1 || len(got) != 1 {
+		t.Errorf("expected one violation counted for sequential, got %v", got)
+	}
+	if got := SourceFrom(context.Background()); got != SourceProxy {
+		t.Errorf("expected untagged dials attributed to %q, got %q", SourceProxy, got)
+	}
+}
+
+func TestDiscoveredBackendDrift(t *testing.T) {
+	p := newTestPolicy(t, "")
+
+	kept := p.FilterBackends("registry", "route orders", backends("http://10.0.0.1:8080", "http://10.0.0.2:8080"))
+	if len(kept) != 2 {
+		t.Fatalf("expected both discovered backends kept, got %d", len(kept))
+	}
+	if got := p.HealthReport()["status"]; got != "ok" {
+		t.Fatalf("expected ok health before drift, got %v", got)
+	}
+
+	// The registry starts returning an instance outside the allowlist.
+	kept = p.FilterBackends("registry", "route orders", backends("http://10.0.0.1:8080", "http://172.16.4.2:8080"))
+	if len(kept) != 1 || kept[0].URL != "http://10.0.0.1:8080" {
+		t.Fatalf("expected the drifted backend dropped, got %+v", kept)
+	}
+
+	report := p.HealthReport()
+	if report["status"] != "violation" {
+		t.Errorf("expected violation status in the health report, got %v", report["status"])
+	}
+	if got := report["violations_by_source"].(map[string]int64)["registry"]; got != 1 {
+		t.Errorf("expected 1 registry violation, got %d", got)
+	}
+	last := report["last_violation"].(Violation)
+	if last.Host != "172.16.4.2" || last.Detail != "route orders" || !last.Blocked {
+		t.Errorf("unexpected last violation %+v", last)
+	}
+}
+
+func TestWarnModeKeepsBackends(t *testing.T) {
+	p := newTestPolicy(t, ModeWarn)
+
+	kept := p.FilterBackends("dns_discovery", "upstream api", backends("http://203.0.113.7:80"))
+	if len(kept) != 1 {
+		t.Fatalf("expected warn mode to keep the backend, got %d", len(kept))
+	}
+	if err := p.CheckDial(context.Background(), "203.0.113.7", []net.IP{net.ParseIP("203.0.113.7")}); err != nil {
+		t.Errorf("expected warn mode to allow the dial, got %v", err)
+	}
+	if got := p.Violations(); got["dns_discovery"] != 1 || got[SourceProxy] != 1 {
+		t.Errorf("expected violations counted in warn mode, got %v", got)
+	}
+	if recent := p.Recent(); len(recent) != 2 || recent[0].Source != SourceProxy || recent[0].Blocked {
+		t.Errorf("expected unblocked violations newest first, got %+v", recent)
+	}
+}
+
+func TestRecentRing(t *testing.T) {
+	p := newTestPolicy(t, "")
+	for i := 0; i < maxRecent+5; i++ {
+		p.CheckHost("registry", "blocked.test", "")
+	}
+	p.CheckHost("webhook", "last.test", "")
+	recent := p.Recent()
+	if len(recent) != maxRecent || recent[0].Host != "last.test" {
+		t.Errorf("expected %d recent violations led by the newest, got %d led by %q", maxRecent, len(recent), recent[0].Host)
+	}
+}
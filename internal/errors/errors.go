@@ -94,6 +94,11 @@ var (
 		Code:    http.StatusRequestEntityTooLarge,
 		Message: "Request Entity Too Large",
 	}
+
+	ErrLoopDetected = &RunwayError{
+		Code:    http.StatusLoopDetected,
+		Message: "Loop Detected",
+	}
 )
 
 // preSerialized holds JSON-encoded bytes for base error singletons.
@@ -104,7 +109,7 @@ func init() {
 		ErrNotFound, ErrMethodNotAllowed, ErrUnauthorized, ErrForbidden,
 		ErrTooManyRequests, ErrBadGateway, ErrServiceUnavailable,
 		ErrGatewayTimeout, ErrBadRequest, ErrInternalServer,
-		ErrRequestEntityTooLarge,
+		ErrRequestEntityTooLarge, ErrLoopDetected,
 	}
 	preSerialized = make(map[*RunwayError][]byte, len(bases))
 	for _, e := range bases {
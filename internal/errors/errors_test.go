@@ -221,6 +221,7 @@ func TestSingletonCodes(t *testing.T) {
 		{ErrBadRequest, 400, "Bad Request"},
 		{ErrInternalServer, 500, "Internal Server Error"},
 		{ErrRequestEntityTooLarge, 413, "Request Entity Too Large"},
+		{ErrLoopDetected, 508, "Loop Detected"},
 	}
 
 	for _, tt := range tests {
@@ -236,8 +237,8 @@ func TestSingletonCodes(t *testing.T) {
 }
 
 func TestPreSerializedCount(t *testing.T) {
-	if len(preSerialized) != 11 {
-		t.Errorf("preSerialized has %d entries, want 11", len(preSerialized))
+	if len(preSerialized) != 12 {
+		t.Errorf("preSerialized has %d entries, want 12", len(preSerialized))
 	}
 }
 
@@ -245,3 +246,50 @@ func TestErrorInterface(t *testing.T) {
 	var _ error = New(500, "test")
 	var _ error = Wrap(fmt.Errorf("inner"), 500, "test")
 }
+
+func TestProblemWriteJSON(t *testing.T) {
+	p := NewProblem(http.StatusTooManyRequests, ReasonRateLimited)
+	p.Detail = "slow down"
+	p.Instance = "req-1"
+	p.Route = "orders"
+	p.Extensions = map[string]any{"retry_in": 3, "status": "ignored"}
+
+	w := httptest.NewRecorder()
+	p.WriteJSON(w)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, ProblemContentType)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"type": "about:blank", "title": "Too Many Requests", "status": float64(429), "detail": "slow down",
+		"instance": "req-1", "route": "orders", "reason": "rate_limited", "retry_in": float64(3),
+	}
+	if len(doc) != len(want) {
+		t.Errorf("document = %v, want %v", doc, want)
+	}
+	for k, v := range want {
+		if doc[k] != v {
+			t.Errorf("%s = %v, want %v", k, doc[k], v)
+		}
+	}
+}
+
+func TestReasonForStatus(t *testing.T) {
+	for code, want := range map[int]string{
+		http.StatusNotFound:            "not_found",
+		http.StatusRequestURITooLong:   "request_uri_too_long",
+		http.StatusServiceUnavailable:  "service_unavailable",
+		http.StatusUnprocessableEntity: "unprocessable_entity",
+		599:                            "status_599",
+	} {
+		if got := ReasonForStatus(code); got != want {
+			t.Errorf("ReasonForStatus(%d) = %q, want %q", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package errors
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details.
+const ProblemContentType = "application/problem+json"
+
+// DefaultProblemType is the problem type used when no catalog entry names
+// one; its title is the HTTP status text (RFC 9457 section 4.2.1).
+const DefaultProblemType = "about:blank"
+
+// Reason codes identify why the gateway generated an error response. They
+// are reported in the "reason" member of problem details and are the keys
+// of error catalogs. Errors without a specific code use the snake-cased
+// status text (see ReasonForStatus).
+const (
+	ReasonRouteNotFound           = "route_not_found"
+	ReasonRateLimited             = "rate_limited"
+	ReasonQuotaExceeded           = "quota_exceeded"
+	ReasonAuthenticationFailed    = "authentication_failed"
+	ReasonInsufficientPermissions = "insufficient_permissions"
+	ReasonWAFBlocked              = "waf_blocked"
+	ReasonBotBlocked              = "bot_blocked"
+	ReasonValidationFailed        = "validation_failed"
+	ReasonUpstreamTimeout         = "upstream_timeout"
+	ReasonRequestTimeout          = "request_timeout"
+	ReasonUpstreamError           = "upstream_error"
+	ReasonNoHealthyBackends       = "no_healthy_backends"
+	ReasonCircuitOpen             = "circuit_open"
+	ReasonConcurrencyLimited      = "concurrency_limited"
+	ReasonDegraded                = "degraded"
+)
+
+// Problem is an RFC 9457 problem details document. Route and Reason are
+// gateway extension members; Extensions holds any others.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Route      string
+	Reason     string
+	Extensions map[string]any
+}
+
+// NewProblem returns the default problem for a status code and reason: type
+// about:blank, titled with the status text.
+func NewProblem(status int, reason string) *Problem {
+	return &Problem{
+		Type:   DefaultProblemType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Reason: reason,
+	}
+}
+
+// MarshalJSON flattens the extension members into the document. The
+// standard members take precedence over extensions of the same name.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+7)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	if p.Route != "" {
+		m["route"] = p.Route
+	}
+	if p.Reason != "" {
+		m["reason"] = p.Reason
+	}
+	return json.Marshal(m)
+}
+
+// WriteJSON writes the problem as application/problem+json.
+func (p *Problem) WriteJSON(w http.ResponseWriter) {
+	body, _ := json.Marshal(p)
+	body = append(body, '\n')
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
+// ReasonForStatus returns the generic reason code for a status code, its
+// status text in snake case (e.g. "too_many_requests").
+func ReasonForStatus(code int) string {
+	text := http.StatusText(code)
+	if text == "" {
+		return "status_" + strconv.Itoa(code)
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r == '-' || r == ' ':
+			return '_'
+		}
+		return -1
+	}, text)
+}
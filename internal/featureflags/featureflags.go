@@ -0,0 +1,388 @@
+package featureflags
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPrefix           = "runway/flags/"
+	defaultRetryInterval    = time.Second
+	defaultMaxRetryInterval = 30 * time.Second
+)
+
+// Flag statuses reported by Stats.
+const (
+	StatusApplied   = "applied"
+	StatusRejected  = "rejected"
+	StatusMalformed = "malformed"
+)
+
+// Applier applies runtime overrides. The gateway implements it with the same
+// code paths used by the admin override endpoints.
+type Applier interface {
+	SetFeatureEnabled(routeID, feature string, enabled bool) error
+	ClearFeature(routeID, feature string)
+	SetMaintenance(routeID string, enabled bool) error
+	ResetMaintenance(routeID string) error
+	SetLogLevel(level string) error
+	ResetLogLevel() error
+}
+
+// Source delivers snapshots of every key under the flag prefix.
+type Source interface {
+	// Watch calls fn with all keys (relative to the prefix) and their values
+	// each time they change. It blocks until ctx is done or the connection
+	// fails, in which case the error is returned and the caller reconnects.
+	Watch(ctx context.Context, fn func(map[string]string)) error
+	// Name identifies the backend and prefix in logs and stats.
+	Name() string
+	Close() error
+}
+
+type flagKind int
+
+const (
+	kindFeature flagKind = iota
+	kindMaintenance
+	kindLogLevel
+)
+
+// flag is a parsed key/value pair.
+type flag struct {
+	kind    flagKind
+	route   string
+	feature string
+	enabled bool
+	level   string
+}
+
+// parseFlag parses a key relative to the prefix. Supported keys:
+//
+//	routes/<id>/features/<name>/enabled  true|false
+//	routes/<id>/maintenance              true|false
+//	log_level                            debug|info|warn|error
+func parseFlag(key, value string) (flag, error) {
+	value = strings.TrimSpace(value)
+	parts := strings.Split(key, "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "log_level":
+		switch value {
+		case "debug", "info", "warn", "error":
+			return flag{kind: kindLogLevel, level: value}, nil
+		}
+		return flag{}, fmt.Errorf("invalid log level %q", value)
+	case len(parts) == 5 && parts[0] == "routes" && parts[2] == "features" && parts[4] == "enabled":
+		if parts[1] == "" || parts[3] == "" {
+			return flag{}, fmt.Errorf("empty route or feature name")
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return flag{}, fmt.Errorf("invalid boolean %q", value)
+		}
+		return flag{kind: kindFeature, route: parts[1], feature: parts[3], enabled: enabled}, nil
+	case len(parts) == 3 && parts[0] == "routes" && parts[2] == "maintenance":
+		if parts[1] == "" {
+			return flag{}, fmt.Errorf("empty route name")
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return flag{}, fmt.Errorf("invalid boolean %q", value)
+		}
+		return flag{kind: kindMaintenance, route: parts[1], enabled: enabled}, nil
+	}
+	return flag{}, fmt.Errorf("unrecognized key")
+}
+
+// FlagState is the current state of a single key.
+type FlagState struct {
+	Value     string    `json:"value"`
+	Applied   string    `json:"applied,omitempty"` // value currently in effect
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Watcher watches a flag source and applies changes as runtime overrides.
+// Keys that are removed revert their override; malformed keys and values are
+// logged and ignored.
+type Watcher struct {
+	source           Source
+	applier          Applier
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+
+	mu       sync.Mutex
+	flags    map[string]*FlagState
+	lastErr  string
+	lastSync time.Time
+
+	connected  atomic.Bool
+	reconnects atomic.Int64
+	changes    atomic.Int64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a Watcher. Call Start to begin watching.
+func New(cfg config.FeatureFlagsConfig, source Source, applier Applier) *Watcher {
+	w := &Watcher{
+		source:           source,
+		applier:          applier,
+		retryInterval:    cfg.RetryInterval,
+		maxRetryInterval: cfg.MaxRetryInterval,
+		flags:            make(map[string]*FlagState),
+	}
+	if w.retryInterval <= 0 {
+		w.retryInterval = defaultRetryInterval
+	}
+	if w.maxRetryInterval <= 0 {
+		w.maxRetryInterval = defaultMaxRetryInterval
+	}
+	if w.retryInterval > w.maxRetryInterval {
+		w.retryInterval = w.maxRetryInterval
+	}
+	return w
+}
+
+// NewSource creates the Consul or etcd source for cfg, falling back to the
+// registry connection settings when the feature_flags block leaves them empty.
+func NewSource(cfg config.FeatureFlagsConfig, reg config.RegistryConfig) (Source, error) {
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+	switch cfg.Backend {
+	case "consul":
+		cc := cfg.Consul
+		if cc.Address == "" {
+			cc = reg.Consul
+		}
+		return newConsulSource(cc, prefix)
+	case "etcd":
+		ec := cfg.Etcd
+		if len(ec.Endpoints) == 0 {
+			ec = reg.Etcd
+		}
+		return newEtcdSource(ec, prefix)
+	}
+	return nil, fmt.Errorf("feature_flags: unsupported backend %q", cfg.Backend)
+}
+
+// Start begins watching in the background.
+func (w *Watcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	go w.run(ctx)
+}
+
+// Close stops watching and closes the source. Overrides stay in effect.
+func (w *Watcher) Close() error {
+	if w.cancel != nil {
+		w.cancel()
+		<-w.done
+	}
+	return w.source.Close()
+}
+
+// run keeps a watch open, reconnecting with exponential backoff on failure.
+// The last known flags stay in effect while disconnected.
+func (w *Watcher) run(ctx context.Context) {
+	defer close(w.done)
+	backoff := w.retryInterval
+	for {
+		err := w.source.Watch(ctx, func(snapshot map[string]string) {
+			w.connected.Store(true)
+			backoff = w.retryInterval
+			w.Sync(snapshot)
+		})
+		w.connected.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("watch ended")
+		}
+		w.mu.Lock()
+		w.lastErr = err.Error()
+		w.mu.Unlock()
+		w.reconnects.Add(1)
+		logging.Warn("feature flag watch failed, reconnecting",
+			zap.String("source", w.source.Name()),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > w.maxRetryInterval {
+			backoff = w.maxRetryInterval
+		}
+	}
+}
+
+// Sync applies a full snapshot of the flag prefix: new and changed keys are
+// applied and keys missing from the snapshot are reverted.
+func (w *Watcher) Sync(snapshot map[string]string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lastSync = time.Now()
+	w.lastErr = ""
+
+	for key, st := range w.flags {
+		if _, ok := snapshot[key]; !ok {
+			w.revertLocked(key, st)
+			delete(w.flags, key)
+		}
+	}
+	for key, value := range snapshot {
+		if st, ok := w.flags[key]; ok && st.Value == value {
+			continue
+		}
+		w.applyLocked(key, value)
+	}
+}
+
+// Reapply re-applies every applied or rejected flag. It is called after a
+// config reload, which rebuilds per-route handlers and may add routes.
+func (w *Watcher) Reapply() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for key, st := range w.flags {
+		if st.Status != StatusMalformed {
+			w.applyLocked(key, st.Value)
+		}
+	}
+}
+
+func (w *Watcher) applyLocked(key, value string) {
+	st, ok := w.flags[key]
+	if !ok {
+		st = &FlagState{}
+		w.flags[key] = st
+	}
+	st.Value = value
+	st.UpdatedAt = time.Now()
+
+	f, err := parseFlag(key, value)
+	if err != nil {
+		// A malformed value leaves any previously applied value in effect.
+		st.Status = StatusMalformed
+		st.Error = err.Error()
+		logging.Warn("ignoring malformed feature flag",
+			zap.String("source", w.source.Name()),
+			zap.String("key", key),
+			zap.String("value", value),
+			zap.Error(err))
+		return
+	}
+
+	switch f.kind {
+	case kindFeature:
+		err = w.applier.SetFeatureEnabled(f.route, f.feature, f.enabled)
+	case kindMaintenance:
+		err = w.applier.SetMaintenance(f.route, f.enabled)
+	case kindLogLevel:
+		err = w.applier.SetLogLevel(f.level)
+	}
+	if err != nil {
+		st.Status = StatusRejected
+		st.Error = err.Error()
+		logging.Warn("feature flag rejected",
+			zap.String("source", w.source.Name()),
+			zap.String("key", key),
+			zap.String("value", value),
+			zap.Error(err))
+		return
+	}
+
+	if st.Applied != value {
+		w.changes.Add(1)
+		logging.Info("feature flag applied",
+			zap.String("source", w.source.Name()),
+			zap.String("key", key),
+			zap.String("value", value),
+			zap.String("previous", st.Applied))
+	}
+	st.Status = StatusApplied
+	st.Error = ""
+	st.Applied = value
+}
+
+func (w *Watcher) revertLocked(key string, st *FlagState) {
+	if st.Applied == "" {
+		return
+	}
+	f, err := parseFlag(key, st.Applied)
+	if err != nil {
+		return
+	}
+	switch f.kind {
+	case kindFeature:
+		w.applier.ClearFeature(f.route, f.feature)
+	case kindMaintenance:
+		err = w.applier.ResetMaintenance(f.route)
+	case kindLogLevel:
+		err = w.applier.ResetLogLevel()
+	}
+	if err != nil {
+		logging.Warn("failed to revert feature flag",
+			zap.String("source", w.source.Name()),
+			zap.String("key", key),
+			zap.Error(err))
+		return
+	}
+	w.changes.Add(1)
+	logging.Info("feature flag removed, override reverted",
+		zap.String("source", w.source.Name()),
+		zap.String("key", key),
+		zap.String("previous", st.Applied))
+}
+
+// Stats returns the watch status and the state of every known key.
+func (w *Watcher) Stats() map[string]interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	keys := make([]string, 0, len(w.flags))
+	for k := range w.flags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	flags := make(map[string]FlagState, len(keys))
+	for _, k := range keys {
+		flags[k] = *w.flags[k]
+	}
+
+	stats := map[string]interface{}{
+		"enabled":    true,
+		"source":     w.source.Name(),
+		"connected":  w.connected.Load(),
+		"reconnects": w.reconnects.Load(),
+		"changes":    w.changes.Load(),
+		"flags":      flags,
+	}
+	if !w.lastSync.IsZero() {
+		stats["last_sync"] = w.lastSync
+	}
+	if w.lastErr != "" {
+		stats["last_error"] = w.lastErr
+	}
+	return stats
+}
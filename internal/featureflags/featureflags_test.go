@@ -0,0 +1,281 @@
+package featureflags
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+type fakeApplier struct {
+	mu          sync.Mutex
+	features    map[string]bool
+	maintenance map[string]bool
+	logLevel    string
+	resets      int
+}
+
+func newFakeApplier() *fakeApplier {
+	return &fakeApplier{features: map[string]bool{}, maintenance: map[string]bool{}, logLevel: "info"}
+}
+
+func (a *fakeApplier) SetFeatureEnabled(routeID, feature string, enabled bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if routeID == "missing" {
+		return errors.New("route not found")
+	}
+	a.features[routeID+"/"+feature] = enabled
+	return nil
+}
+
+func (a *fakeApplier) ClearFeature(routeID, feature string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.features, routeID+"/"+feature)
+}
+
+func (a *fakeApplier) SetMaintenance(routeID string, enabled bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.maintenance[routeID] = enabled
+	return nil
+}
+
+func (a *fakeApplier) ResetMaintenance(routeID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.maintenance, routeID)
+	return nil
+}
+
+func (a *fakeApplier) SetLogLevel(level string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.logLevel = level
+	return nil
+}
+
+func (a *fakeApplier) ResetLogLevel() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.logLevel = "info"
+	a.resets++
+	return nil
+}
+
+// fakeSource delivers snapshots pushed by the test and fails on demand.
+type fakeSource struct {
+	snapshots chan map[string]string
+	fail      chan error
+	watches   chan struct{}
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{
+		snapshots: make(chan map[string]string),
+		fail:      make(chan error),
+		watches:   make(chan struct{}, 10),
+	}
+}
+
+func (s *fakeSource) Watch(ctx context.Context, fn func(map[string]string)) error {
+	s.watches <- struct{}{}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-s.fail:
+			return err
+		case snap := <-s.snapshots:
+			fn(snap)
+		}
+	}
+}
+
+func (s *fakeSource) Name() string { return "fake://flags/" }
+func (s *fakeSource) Close() error { return nil }
+
+func TestParseFlag(t *testing.T) {
+	tests := []struct {
+		key, value string
+		want       flag
+		wantErr    bool
+	}{
+		{key: "routes/orders/features/waf/enabled", value: "false", want: flag{kind: kindFeature, route: "orders", feature: "waf"}},
+		{key: "routes/orders/features/waf/enabled", value: " true\n", want: flag{kind: kindFeature, route: "orders", feature: "waf", enabled: true}},
+		{key: "routes/orders/maintenance", value: "1", want: flag{kind: kindMaintenance, route: "orders", enabled: true}},
+		{key: "log_level", value: "debug", want: flag{kind: kindLogLevel, level: "debug"}},
+		{key: "log_level", value: "verbose", wantErr: true},
+		{key: "routes/orders/features/waf/enabled", value: "maybe", wantErr: true},
+		{key: "routes//maintenance", value: "true", wantErr: true},
+		{key: "routes/orders/features/waf", value: "true", wantErr: true},
+		{key: "something/else", value: "true", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseFlag(tt.key, tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseFlag(%q, %q): expected error", tt.key, tt.value)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseFlag(%q, %q) = %+v, %v; want %+v", tt.key, tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestWatcher_SyncAppliesAndReverts(t *testing.T) {
+	a := newFakeApplier()
+	w := New(config.FeatureFlagsConfig{}, newFakeSource(), a)
+
+	w.Sync(map[string]string{
+		"routes/orders/features/waf/enabled": "false",
+		"routes/orders/maintenance":          "true",
+		"log_level":                          "debug",
+	})
+	if enabled, ok := a.features["orders/waf"]; !ok || enabled {
+		t.Errorf("expected waf disabled on orders, got %v", a.features)
+	}
+	if !a.maintenance["orders"] || a.logLevel != "debug" {
+		t.Errorf("expected maintenance and log level applied: %v %s", a.maintenance, a.logLevel)
+	}
+
+	// Removing keys reverts their overrides
+	w.Sync(map[string]string{"routes/orders/features/waf/enabled": "false"})
+	if _, ok := a.maintenance["orders"]; ok {
+		t.Error("expected maintenance override reverted")
+	}
+	if a.logLevel != "info" || a.resets != 1 {
+		t.Errorf("expected log level reset, got %s (resets %d)", a.logLevel, a.resets)
+	}
+
+	w.Sync(map[string]string{})
+	if len(a.features) != 0 {
+		t.Errorf("expected feature override cleared, got %v", a.features)
+	}
+	if w.Stats()["changes"] != int64(6) {
+		t.Errorf("expected 6 changes, got %v", w.Stats()["changes"])
+	}
+}
+
+func TestWatcher_MalformedAndRejected(t *testing.T) {
+	a := newFakeApplier()
+	w := New(config.FeatureFlagsConfig{}, newFakeSource(), a)
+
+	w.Sync(map[string]string{
+		"routes/orders/maintenance":           "true",
+		"routes/missing/features/waf/enabled": "false",
+		"bogus/key":                           "1",
+	})
+	flags := w.Stats()["flags"].(map[string]FlagState)
+	if flags["bogus/key"].Status != StatusMalformed {
+		t.Errorf("expected bogus key malformed, got %+v", flags["bogus/key"])
+	}
+	if flags["routes/missing/features/waf/enabled"].Status != StatusRejected {
+		t.Errorf("expected missing route rejected, got %+v", flags["routes/missing/features/waf/enabled"])
+	}
+	if len(a.features) != 0 {
+		t.Errorf("expected nothing applied for rejected flag, got %v", a.features)
+	}
+
+	// A malformed update keeps the previously applied value in effect
+	w.Sync(map[string]string{"routes/orders/maintenance": "sometimes"})
+	if !a.maintenance["orders"] {
+		t.Error("expected previous maintenance value to stay in effect")
+	}
+	st := w.Stats()["flags"].(map[string]FlagState)["routes/orders/maintenance"]
+	if st.Status != StatusMalformed || st.Applied != "true" {
+		t.Errorf("unexpected state %+v", st)
+	}
+
+	// Removing the key still reverts the applied value
+	w.Sync(map[string]string{})
+	if _, ok := a.maintenance["orders"]; ok {
+		t.Error("expected maintenance reverted after removal")
+	}
+}
+
+func TestWatcher_Reconnects(t *testing.T) {
+	a := newFakeApplier()
+	src := newFakeSource()
+	w := New(config.FeatureFlagsConfig{RetryInterval: time.Millisecond, MaxRetryInterval: 5 * time.Millisecond}, src, a)
+	w.Start()
+	defer w.Close()
+
+	<-src.watches
+	src.snapshots <- map[string]string{"routes/orders/maintenance": "true"}
+	src.fail <- errors.New("connection reset")
+
+	// The watcher reconnects and keeps the last known flags in effect
+	select {
+	case <-src.watches:
+	case <-time.After(time.Second):
+		t.Fatal("expected watcher to reconnect")
+	}
+	a.mu.Lock()
+	applied := a.maintenance["orders"]
+	a.mu.Unlock()
+	if !applied {
+		t.Error("expected flags kept across reconnect")
+	}
+	stats := w.Stats()
+	if stats["reconnects"] != int64(1) || stats["last_error"] != "connection reset" {
+		t.Errorf("unexpected reconnect stats: %v", stats)
+	}
+
+	src.snapshots <- map[string]string{}
+	deadline := time.Now().Add(time.Second)
+	for {
+		a.mu.Lock()
+		_, still := a.maintenance["orders"]
+		a.mu.Unlock()
+		if !still {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected override reverted after reconnect snapshot")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOverrides_Wrap(t *testing.T) {
+	o := NewOverrides()
+	mw := o.Wrap("orders", "waf", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+	})
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		return rec.Code
+	}
+
+	if serve() != http.StatusForbidden {
+		t.Fatal("expected middleware to run without overrides")
+	}
+	o.Set("orders", "waf", false)
+	if serve() != http.StatusOK {
+		t.Fatal("expected middleware skipped while disabled")
+	}
+	o.Set("orders", "waf", true)
+	if serve() != http.StatusForbidden {
+		t.Fatal("expected middleware to run when explicitly enabled")
+	}
+	o.Clear("orders", "waf")
+	if len(o.Snapshot()) != 0 {
+		t.Errorf("expected no overrides, got %v", o.Snapshot())
+	}
+}
@@ -0,0 +1,87 @@
+package featureflags
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wudi/runway/internal/middleware"
+)
+
+type overrideKey struct {
+	route   string
+	feature string
+}
+
+// Overrides holds runtime per-route feature toggles. Reads are lock-free so
+// the check can sit in front of every route middleware.
+type Overrides struct {
+	mu sync.Mutex
+	m  atomic.Pointer[map[overrideKey]bool]
+}
+
+// NewOverrides creates an empty override set.
+func NewOverrides() *Overrides {
+	o := &Overrides{}
+	o.m.Store(&map[overrideKey]bool{})
+	return o
+}
+
+// Set records an override for a route feature.
+func (o *Overrides) Set(routeID, feature string, enabled bool) {
+	o.update(func(m map[overrideKey]bool) { m[overrideKey{routeID, feature}] = enabled })
+}
+
+// Clear removes an override, restoring the configured behaviour.
+func (o *Overrides) Clear(routeID, feature string) {
+	o.update(func(m map[overrideKey]bool) { delete(m, overrideKey{routeID, feature}) })
+}
+
+func (o *Overrides) update(fn func(map[overrideKey]bool)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	cur := *o.m.Load()
+	next := make(map[overrideKey]bool, len(cur)+1)
+	for k, v := range cur {
+		next[k] = v
+	}
+	fn(next)
+	o.m.Store(&next)
+}
+
+// Disabled reports whether a route feature has been switched off.
+func (o *Overrides) Disabled(routeID, feature string) bool {
+	m := *o.m.Load()
+	if len(m) == 0 {
+		return false
+	}
+	enabled, ok := m[overrideKey{routeID, feature}]
+	return ok && !enabled
+}
+
+// Wrap returns mw guarded by the route feature's override: while the feature
+// is disabled, requests skip mw and go straight to the next handler.
+func (o *Overrides) Wrap(routeID, feature string, mw middleware.Middleware) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if o.Disabled(routeID, feature) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Snapshot returns overrides grouped by route: route -> feature -> enabled.
+func (o *Overrides) Snapshot() map[string]map[string]bool {
+	out := make(map[string]map[string]bool)
+	for k, v := range *o.m.Load() {
+		if out[k.route] == nil {
+			out[k.route] = make(map[string]bool)
+		}
+		out[k.route][k.feature] = v
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package featureflags
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/wudi/runway/config"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// consulWaitTime bounds each Consul blocking query.
+const consulWaitTime = 5 * time.Minute
+
+// consulSource watches a Consul KV prefix with blocking queries.
+type consulSource struct {
+	kv      *consulapi.KV
+	address string
+	prefix  string
+}
+
+func newConsulSource(cfg config.ConsulConfig, prefix string) (*consulSource, error) {
+	consulCfg := consulapi.DefaultConfig()
+	consulCfg.Address = cfg.Address
+	consulCfg.Scheme = cfg.Scheme
+	consulCfg.Datacenter = cfg.Datacenter
+	consulCfg.Namespace = cfg.Namespace
+	if cfg.Token != "" {
+		consulCfg.Token = cfg.Token
+	}
+
+	client, err := consulapi.NewClient(consulCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Consul client: %w", err)
+	}
+	return &consulSource{kv: client.KV(), address: cfg.Address, prefix: prefix}, nil
+}
+
+func (s *consulSource) Name() string { return "consul://" + s.address + "/" + s.prefix }
+
+func (s *consulSource) Watch(ctx context.Context, fn func(map[string]string)) error {
+	var lastIndex uint64
+	for {
+		opts := (&consulapi.QueryOptions{
+			WaitIndex: lastIndex,
+			WaitTime:  consulWaitTime,
+		}).WithContext(ctx)
+
+		pairs, meta, err := s.kv.List(s.prefix, opts)
+		if err != nil {
+			return err
+		}
+		if meta.LastIndex == lastIndex {
+			continue // wait time elapsed without changes
+		}
+		if meta.LastIndex < lastIndex {
+			lastIndex = 0 // index went backwards, start over
+		} else {
+			lastIndex = meta.LastIndex
+		}
+
+		snapshot := make(map[string]string, len(pairs))
+		for _, p := range pairs {
+			key := strings.TrimPrefix(p.Key, s.prefix)
+			if key == "" || strings.HasSuffix(key, "/") {
+				continue // folder
+			}
+			snapshot[key] = string(p.Value)
+		}
+		fn(snapshot)
+	}
+}
+
+func (s *consulSource) Close() error { return nil }
+
+// etcdSource watches an etcd key prefix.
+type etcdSource struct {
+	client    *clientv3.Client
+	endpoints []string
+	prefix    string
+}
+
+func newEtcdSource(cfg config.EtcdConfig, prefix string) (*etcdSource, error) {
+	etcdCfg := clientv3.Config{
+		Endpoints:   cfg.Endpoints,
+		DialTimeout: 5 * time.Second,
+	}
+	if cfg.Username != "" {
+		etcdCfg.Username = cfg.Username
+		etcdCfg.Password = cfg.Password
+	}
+
+	client, err := clientv3.New(etcdCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create etcd client: %w", err)
+	}
+	return &etcdSource{client: client, endpoints: cfg.Endpoints, prefix: prefix}, nil
+}
+
+func (s *etcdSource) Name() string {
+	return "etcd://" + strings.Join(s.endpoints, ",") + "/" + s.prefix
+}
+
+func (s *etcdSource) Watch(ctx context.Context, fn func(map[string]string)) error {
+	resp, err := s.client.Get(ctx, s.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	snapshot := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		snapshot[strings.TrimPrefix(string(kv.Key), s.prefix)] = string(kv.Value)
+	}
+	fn(copyMap(snapshot))
+
+	// Require a leader so a partitioned member does not leave the watch
+	// silently stalled.
+	watchCh := s.client.Watch(clientv3.WithRequireLeader(ctx), s.prefix,
+		clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+	for wr := range watchCh {
+		if err := wr.Err(); err != nil {
+			return err
+		}
+		for _, ev := range wr.Events {
+			key := strings.TrimPrefix(string(ev.Kv.Key), s.prefix)
+			if ev.Type == clientv3.EventTypeDelete {
+				delete(snapshot, key)
+			} else {
+				snapshot[key] = string(ev.Kv.Value)
+			}
+		}
+		fn(copyMap(snapshot))
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("etcd watch channel closed")
+}
+
+func (s *etcdSource) Close() error { return s.client.Close() }
+
+func copyMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package graphql
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/wudi/runway/config"
+)
+
+// Allowlist holds the set of queries permitted to execute, keyed by the hex
+// SHA-256 of the query text. It is built once per route and rebuilt on config
+// reload, which also re-reads the backing file.
+type Allowlist struct {
+	entries map[string]config.GraphQLAllowlistEntry
+}
+
+// NewAllowlist builds an allowlist from inline entries plus the optional file.
+// The file is a YAML list using the same shape as the inline entries.
+func NewAllowlist(cfg config.GraphQLAllowlistConfig) (*Allowlist, error) {
+	entries := cfg.Queries
+	if cfg.File != "" {
+		data, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("graphql allowlist: %w", err)
+		}
+		var fileEntries []config.GraphQLAllowlistEntry
+		if err := yaml.Unmarshal(data, &fileEntries); err != nil {
+			return nil, fmt.Errorf("graphql allowlist: parse %s: %w", cfg.File, err)
+		}
+		entries = append(append([]config.GraphQLAllowlistEntry{}, entries...), fileEntries...)
+	}
+
+	al := &Allowlist{entries: make(map[string]config.GraphQLAllowlistEntry, len(entries))}
+	for _, e := range entries {
+		al.entries[strings.ToLower(e.Hash)] = e
+	}
+	return al, nil
+}
+
+// Lookup returns the entry for the given query hash.
+func (a *Allowlist) Lookup(hash string) (config.GraphQLAllowlistEntry, bool) {
+	e, ok := a.entries[hash]
+	return e, ok
+}
+
+// Len returns the number of allowlisted queries.
+func (a *Allowlist) Len() int {
+	return len(a.entries)
+}
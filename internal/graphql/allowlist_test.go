@@ -0,0 +1,202 @@
+package graphql
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func queryHash(q string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(q)))
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+}
+
+func TestAllowlistRejectsUnknownQuery(t *testing.T) {
+	known := `{ user { name } }`
+	p, err := New(config.GraphQLConfig{
+		Enabled: true,
+		Allowlist: config.GraphQLAllowlistConfig{
+			Enabled: true,
+			Queries: []config.GraphQLAllowlistEntry{{Hash: queryHash(known)}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := p.Middleware()(okHandler())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, makeGQLRequest(known))
+	if rec.Code != 200 {
+		t.Errorf("allowlisted query: expected 200, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, makeGQLRequest(`{ user { email } }`))
+	if rec.Code != 403 {
+		t.Errorf("free-form query: expected 403, got %d", rec.Code)
+	}
+
+	rejections := p.Stats()["rejections"].(map[string]interface{})
+	if rejections["not_allowlisted"].(int64) != 1 {
+		t.Errorf("expected 1 not_allowlisted rejection, got %v", rejections["not_allowlisted"])
+	}
+}
+
+func TestAllowlistIntrospectionExemption(t *testing.T) {
+	exempt := `{ __schema { types { name } } }`
+	other := `{ __type(name: "User") { name } }`
+	p, err := New(config.GraphQLConfig{
+		Enabled: true,
+		Allowlist: config.GraphQLAllowlistConfig{
+			Enabled: true,
+			Queries: []config.GraphQLAllowlistEntry{
+				{Hash: queryHash(exempt), AllowIntrospection: true},
+				{Hash: queryHash(other)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := p.Middleware()(okHandler())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, makeGQLRequest(exempt))
+	if rec.Code != 200 {
+		t.Errorf("exempt introspection: expected 200, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, makeGQLRequest(other))
+	if rec.Code != 403 {
+		t.Errorf("non-exempt introspection: expected 403, got %d", rec.Code)
+	}
+}
+
+func TestAllowlistFromFile(t *testing.T) {
+	q := `query Me { me { id } }`
+	path := filepath.Join(t.TempDir(), "allowlist.yaml")
+	content := fmt.Sprintf("- hash: %s\n  name: me\n", queryHash(q))
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(config.GraphQLConfig{
+		Enabled:   true,
+		Allowlist: config.GraphQLAllowlistConfig{Enabled: true, File: path},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stats()["allowlist_size"] != 1 {
+		t.Errorf("expected allowlist_size 1, got %v", p.Stats()["allowlist_size"])
+	}
+
+	rec := httptest.NewRecorder()
+	p.Middleware()(okHandler()).ServeHTTP(rec, makeGQLRequest(q))
+	if rec.Code != 200 {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+}
+
+func TestAllowlistMissingFile(t *testing.T) {
+	_, err := New(config.GraphQLConfig{
+		Enabled:   true,
+		Allowlist: config.GraphQLAllowlistConfig{Enabled: true, File: "/nonexistent/allowlist.yaml"},
+	})
+	if err == nil {
+		t.Fatal("expected error for missing allowlist file")
+	}
+}
+
+func TestCostRateLimitChargesComplexity(t *testing.T) {
+	p, err := New(config.GraphQLConfig{
+		Enabled: true,
+		CostRateLimit: config.GraphQLCostRateLimit{
+			Enabled: true,
+			Budget:  10,
+			Period:  time.Hour,
+			Key:     "header:X-Client",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := p.Middleware()(okHandler())
+
+	expensive := `{ a { b { c { d } } } e { f } }` // complexity 6
+	cheap := `{ a }`                               // complexity 1
+
+	send := func(client, q string) int {
+		r := makeGQLRequest(q)
+		r.Header.Set("X-Client", client)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	if code := send("alice", expensive); code != 200 {
+		t.Fatalf("first expensive query: expected 200, got %d", code)
+	}
+	if code := send("alice", expensive); code != 429 {
+		t.Fatalf("second expensive query: expected 429, got %d", code)
+	}
+	// Remaining budget (4) still admits cheap queries.
+	for i := 0; i < 4; i++ {
+		if code := send("alice", cheap); code != 200 {
+			t.Fatalf("cheap query %d: expected 200, got %d", i, code)
+		}
+	}
+	if code := send("alice", cheap); code != 429 {
+		t.Fatalf("budget exhausted: expected 429, got %d", code)
+	}
+	// Other clients have their own bucket.
+	if code := send("bob", expensive); code != 200 {
+		t.Fatalf("other client: expected 200, got %d", code)
+	}
+
+	stats := p.Stats()
+	rejections := stats["rejections"].(map[string]interface{})
+	if rejections["over_budget"].(int64) != 2 {
+		t.Errorf("expected 2 over_budget rejections, got %v", rejections["over_budget"])
+	}
+
+	top := stats["top_queries_by_cost"].([]queryCost)
+	if len(top) != 2 {
+		t.Fatalf("expected 2 tracked queries, got %d", len(top))
+	}
+	if top[0].Hash != queryHash(expensive) || top[0].TotalCost != 12 || top[0].Requests != 2 {
+		t.Errorf("unexpected top query: %+v", top[0])
+	}
+	if top[1].TotalCost != 4 {
+		t.Errorf("expected cheap query total cost 4, got %d", top[1].TotalCost)
+	}
+}
+
+func TestCostRateLimitQueryAboveBudget(t *testing.T) {
+	p, err := New(config.GraphQLConfig{
+		Enabled:       true,
+		CostRateLimit: config.GraphQLCostRateLimit{Enabled: true, Budget: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	p.Middleware()(okHandler()).ServeHTTP(rec, makeGQLRequest(`{ a { b { c } } }`))
+	if rec.Code != 429 {
+		t.Errorf("expected 429 for query costing more than the budget, got %d", rec.Code)
+	}
+}
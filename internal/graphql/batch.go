@@ -46,6 +46,9 @@ func (p *Parser) handleBatch(w http.ResponseWriter, r *http.Request, body []byte
 	resolvedBatch := make([]GraphQLRequest, len(batch))
 	copy(resolvedBatch, batch)
 
+	// The batch's complexity is charged once, after every query has passed
+	// validation, so a rejected batch never consumes any of the client's budget.
+	batchCost := 0
 	for i, gqlReq := range resolvedBatch {
 		reqBody, _ := json.Marshal(gqlReq)
 		info, newBody, err := p.resolveAndParse(gqlReq, reqBody)
@@ -82,9 +85,19 @@ func (p *Parser) handleBatch(w http.ResponseWriter, r *http.Request, body []byte
 			return
 		}
 
+		batchCost += info.Complexity
 		infos[i] = info
 	}
 
+	if !p.allowCost(r, batchCost) {
+		writeGraphQLError(w, fmt.Sprintf("batch cost %d exceeds remaining complexity budget", batchCost), 429)
+		return
+	}
+
+	for _, info := range infos {
+		p.costs.Record(info)
+	}
+
 	mode := p.cfg.Batching.Mode
 	if mode == "" {
 		mode = "pass_through"
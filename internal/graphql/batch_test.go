@@ -389,6 +389,43 @@ func TestBatchPerQueryRateLimiting(t *testing.T) {
 	}
 }
 
+func TestBatchCostChargedOnlyWhenWholeBatchFits(t *testing.T) {
+	p, err := New(config.GraphQLConfig{
+		Enabled:       true,
+		CostRateLimit: config.GraphQLCostRateLimit{Enabled: true, Budget: 10},
+		Batching: config.GraphQLBatchingConfig{
+			Enabled: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := p.Middleware()(okHandler())
+
+	expensive := GraphQLRequest{Query: `{ a { b { c { d } } } e { f } }`} // complexity 6
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, makeBatchRequest(batchBody(expensive, expensive)))
+	if w.Code != 429 {
+		t.Fatalf("expected 429 for batch over budget, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "batch cost 12") {
+		t.Errorf("expected error to report the batch cost, got: %s", w.Body.String())
+	}
+
+	// The rejected batch must not have consumed any budget.
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, makeBatchRequest(batchBody(expensive)))
+	if w.Code != 200 {
+		t.Fatalf("expected 200 after rejected batch was not charged, got %d", w.Code)
+	}
+
+	rejections := p.Stats()["rejections"].(map[string]interface{})
+	if rejections["over_budget"].(int64) != 1 {
+		t.Errorf("expected 1 over_budget rejection, got %v", rejections["over_budget"])
+	}
+}
+
 func TestBatchPassThroughMode(t *testing.T) {
 	p, err := New(config.GraphQLConfig{
 		Enabled: true,
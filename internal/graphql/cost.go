@@ -0,0 +1,138 @@
+package graphql
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/middleware/ratelimit"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// maxCostClients bounds the number of per-client buckets kept in memory.
+	maxCostClients = 10000
+	// maxTrackedQueries bounds the number of distinct queries tracked for cost stats.
+	maxTrackedQueries = 1000
+	// topQueriesLimit is the number of queries reported in stats.
+	topQueriesLimit = 10
+)
+
+// CostLimiter charges each request its computed complexity against a
+// per-client token bucket holding `budget` points that refill over `period`.
+type CostLimiter struct {
+	budget  int
+	period  time.Duration
+	keyFn   func(*http.Request) string
+	buckets *lru.Cache[string, *rate.Limiter]
+	mu      sync.Mutex // guards bucket creation
+}
+
+// NewCostLimiter creates a cost limiter from config.
+func NewCostLimiter(cfg config.GraphQLCostRateLimit) (*CostLimiter, error) {
+	period := cfg.Period
+	if period <= 0 {
+		period = time.Minute
+	}
+	buckets, err := lru.New[string, *rate.Limiter](maxCostClients)
+	if err != nil {
+		return nil, err
+	}
+	return &CostLimiter{
+		budget:  cfg.Budget,
+		period:  period,
+		keyFn:   ratelimit.BuildKeyFunc(false, cfg.Key),
+		buckets: buckets,
+	}, nil
+}
+
+// Allow charges cost against the client's bucket. A query costing more than
+// the whole budget is never allowed.
+func (c *CostLimiter) Allow(r *http.Request, cost int) bool {
+	if cost <= 0 {
+		return true
+	}
+	return c.limiter(c.keyFn(r)).AllowN(time.Now(), cost)
+}
+
+func (c *CostLimiter) limiter(key string) *rate.Limiter {
+	if l, ok := c.buckets.Get(key); ok {
+		return l
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l, ok := c.buckets.Get(key); ok {
+		return l
+	}
+	l := rate.NewLimiter(rate.Limit(float64(c.budget)/c.period.Seconds()), c.budget)
+	c.buckets.Add(key, l)
+	return l
+}
+
+// Stats returns cost limiter configuration and size.
+func (c *CostLimiter) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"budget":  c.budget,
+		"period":  c.period.String(),
+		"clients": c.buckets.Len(),
+	}
+}
+
+// queryCost is the cumulative cost accounted to a single query.
+type queryCost struct {
+	Hash          string `json:"hash"`
+	OperationName string `json:"operation_name,omitempty"`
+	Requests      int64  `json:"requests"`
+	TotalCost     int64  `json:"total_cost"`
+}
+
+// costTracker accumulates cost per distinct query for the top-N report.
+type costTracker struct {
+	mu      sync.Mutex
+	queries map[string]*queryCost
+}
+
+func newCostTracker() *costTracker {
+	return &costTracker{queries: make(map[string]*queryCost)}
+}
+
+// Record adds the executed query's complexity to its running total. Once the
+// tracker is full, queries not already tracked are ignored.
+func (t *costTracker) Record(info *GraphQLInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	qc, ok := t.queries[info.QueryHash]
+	if !ok {
+		if len(t.queries) >= maxTrackedQueries {
+			return
+		}
+		qc = &queryCost{Hash: info.QueryHash, OperationName: info.OperationName}
+		t.queries[info.QueryHash] = qc
+	}
+	qc.Requests++
+	qc.TotalCost += int64(info.Complexity)
+}
+
+// Top returns the n queries with the highest cumulative cost.
+func (t *costTracker) Top(n int) []queryCost {
+	t.mu.Lock()
+	out := make([]queryCost, 0, len(t.queries))
+	for _, qc := range t.queries {
+		out = append(out, *qc)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TotalCost != out[j].TotalCost {
+			return out[i].TotalCost > out[j].TotalCost
+		}
+		return out[i].Hash < out[j].Hash
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
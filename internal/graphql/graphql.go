@@ -32,6 +32,7 @@ type GraphQLInfo struct {
 	Depth         int
 	Complexity    int
 	Introspection bool
+	QueryHash     string // hex SHA-256 of the query text
 	VariablesHash string // hex SHA-256 of variables JSON
 }
 
@@ -40,6 +41,9 @@ type Parser struct {
 	cfg              config.GraphQLConfig
 	operationLimiter map[string]*rate.Limiter
 	apqCache         *APQCache
+	allowlist        *Allowlist
+	costLimiter      *CostLimiter
+	costs            *costTracker
 
 	// Atomic metrics
 	requestsTotal        atomic.Int64
@@ -51,6 +55,8 @@ type Parser struct {
 	introspectionBlocked atomic.Int64
 	rateLimited          atomic.Int64
 	parseErrors          atomic.Int64
+	notAllowlisted       atomic.Int64
+	overBudget           atomic.Int64
 
 	// Batch metrics
 	batchRequestsTotal atomic.Int64
@@ -63,6 +69,7 @@ func New(cfg config.GraphQLConfig) (*Parser, error) {
 	p := &Parser{
 		cfg:              cfg,
 		operationLimiter: make(map[string]*rate.Limiter),
+		costs:            newCostTracker(),
 	}
 
 	for opType, rps := range cfg.OperationLimits {
@@ -77,6 +84,22 @@ func New(cfg config.GraphQLConfig) (*Parser, error) {
 		p.apqCache = apq
 	}
 
+	if cfg.Allowlist.Enabled {
+		al, err := NewAllowlist(cfg.Allowlist)
+		if err != nil {
+			return nil, err
+		}
+		p.allowlist = al
+	}
+
+	if cfg.CostRateLimit.Enabled {
+		cl, err := NewCostLimiter(cfg.CostRateLimit)
+		if err != nil {
+			return nil, err
+		}
+		p.costLimiter = cl
+	}
+
 	return p, nil
 }
 
@@ -133,6 +156,8 @@ func analyzeDocument(doc *ast.QueryDocument, gqlReq GraphQLRequest) *GraphQLInfo
 	// Detect introspection
 	info.Introspection = detectIntrospection(doc)
 
+	info.QueryHash = fmt.Sprintf("%x", sha256.Sum256([]byte(gqlReq.Query)))
+
 	// Hash variables for cache key
 	if len(gqlReq.Variables) > 0 {
 		h := sha256.Sum256(gqlReq.Variables)
@@ -142,9 +167,19 @@ func analyzeDocument(doc *ast.QueryDocument, gqlReq GraphQLRequest) *GraphQLInfo
 	return info
 }
 
-// Check enforces depth limit, complexity limit, and introspection block.
+// Check enforces the allowlist, depth limit, complexity limit, and introspection block.
 func (p *Parser) Check(info *GraphQLInfo) error {
-	if !p.cfg.Introspection && info.Introspection {
+	allowIntrospection := p.cfg.Introspection
+	if p.allowlist != nil {
+		entry, ok := p.allowlist.Lookup(info.QueryHash)
+		if !ok {
+			p.notAllowlisted.Add(1)
+			return &GraphQLError{Message: "query is not allowlisted", StatusCode: 403}
+		}
+		allowIntrospection = allowIntrospection || entry.AllowIntrospection
+	}
+
+	if !allowIntrospection && info.Introspection {
 		p.introspectionBlocked.Add(1)
 		return &GraphQLError{Message: "introspection is not allowed", StatusCode: 403}
 	}
@@ -177,6 +212,23 @@ func (p *Parser) AllowOperation(info *GraphQLInfo) bool {
 	return limiter.Allow()
 }
 
+// AllowCost charges the operation's complexity against the client's cost
+// budget. Always true when complexity rate limiting is not configured.
+func (p *Parser) AllowCost(r *http.Request, info *GraphQLInfo) bool {
+	return p.allowCost(r, info.Complexity)
+}
+
+func (p *Parser) allowCost(r *http.Request, cost int) bool {
+	if p.costLimiter == nil {
+		return true
+	}
+	if !p.costLimiter.Allow(r, cost) {
+		p.overBudget.Add(1)
+		return false
+	}
+	return true
+}
+
 // Middleware returns the middleware function for this GraphQL parser.
 func (p *Parser) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
@@ -245,6 +297,12 @@ func (p *Parser) Middleware() middleware.Middleware {
 				return
 			}
 
+			if !p.AllowCost(r, info) {
+				writeGraphQLError(w, fmt.Sprintf("query cost %d exceeds remaining complexity budget", info.Complexity), 429)
+				return
+			}
+			p.costs.Record(info)
+
 			// Store info in context for downstream (e.g., cache key)
 			ctx := WithInfo(r.Context(), info)
 			r = r.WithContext(ctx)
@@ -326,6 +384,21 @@ func (p *Parser) Stats() map[string]interface{} {
 		"introspection_blocked": p.introspectionBlocked.Load(),
 		"rate_limited":          p.rateLimited.Load(),
 		"parse_errors":          p.parseErrors.Load(),
+		"rejections": map[string]interface{}{
+			"not_allowlisted": p.notAllowlisted.Load(),
+			"over_depth":      p.depthRejected.Load(),
+			"over_complexity": p.complexityRejected.Load(),
+			"over_budget":     p.overBudget.Load(),
+			"introspection":   p.introspectionBlocked.Load(),
+			"rate_limited":    p.rateLimited.Load(),
+		},
+		"top_queries_by_cost": p.costs.Top(topQueriesLimit),
+	}
+	if p.allowlist != nil {
+		stats["allowlist_size"] = p.allowlist.Len()
+	}
+	if p.costLimiter != nil {
+		stats["complexity_rate_limit"] = p.costLimiter.Stats()
 	}
 	if p.apqCache != nil {
 		stats["persisted_queries"] = p.apqCache.Stats()
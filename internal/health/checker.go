@@ -3,11 +3,17 @@ package health
 import (
 	"context"
 	"fmt"
+	"io"
+	"maps"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	grpcproxy "github.com/wudi/runway/internal/proxy/grpc"
 )
 
 // Status represents health status
@@ -17,6 +23,7 @@ const (
 	StatusHealthy   Status = "healthy"
 	StatusUnhealthy Status = "unhealthy"
 	StatusUnknown   Status = "unknown"
+	StatusDegraded  Status = "degraded" // some non-critical dependencies are failing
 )
 
 // CheckResult represents the result of a health check
@@ -28,15 +35,28 @@ type CheckResult struct {
 	Timestamp time.Time
 }
 
+// Probe types
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+	ProbeGRPC = "grpc"
+)
+
 // Backend represents a backend to check
 type Backend struct {
 	URL            string
+	Type           string            // ProbeHTTP (default), ProbeTCP or ProbeGRPC
 	HealthPath     string
-	Method         string        // HTTP method, default "GET"
+	Method         string            // HTTP method, default "GET", or "POST" with a body
+	Body           string            // HTTP request body
+	Headers        map[string]string // HTTP request headers
+	Send           string            // TCP: written once connected
+	Expect         string            // TCP: banner the reply must contain
+	Service        string            // gRPC: grpc.health.v1 service name
 	Timeout        time.Duration
 	Interval       time.Duration
-	HealthyAfter   int // consecutive successes needed to be healthy
-	UnhealthyAfter int // consecutive failures needed to be unhealthy
+	HealthyAfter   int           // consecutive successes needed to be healthy
+	UnhealthyAfter int           // consecutive failures needed to be unhealthy
 	ExpectedStatus []StatusRange // parsed ranges, default [{200, 399}]
 }
 
@@ -152,27 +172,7 @@ func (c *Checker) AddBackend(b Backend) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if b.HealthPath == "" {
-		b.HealthPath = "/health"
-	}
-	if b.Timeout == 0 {
-		b.Timeout = c.defaultTimeout
-	}
-	if b.Interval == 0 {
-		b.Interval = c.defaultInterval
-	}
-	if b.Method == "" {
-		b.Method = "GET"
-	}
-	if len(b.ExpectedStatus) == 0 {
-		b.ExpectedStatus = []StatusRange{{200, 399}}
-	}
-	if b.HealthyAfter == 0 {
-		b.HealthyAfter = 2
-	}
-	if b.UnhealthyAfter == 0 {
-		b.UnhealthyAfter = 3
-	}
+	applyBackendDefaults(&b, c.defaultTimeout, c.defaultInterval)
 
 	state := &backendState{
 		backend: b,
@@ -214,6 +214,9 @@ func (c *Checker) UpdateBackend(b Backend) {
 }
 
 func applyBackendDefaults(b *Backend, defaultTimeout, defaultInterval time.Duration) {
+	if b.Type == "" {
+		b.Type = ProbeHTTP
+	}
 	if b.HealthPath == "" {
 		b.HealthPath = "/health"
 	}
@@ -225,6 +228,9 @@ func applyBackendDefaults(b *Backend, defaultTimeout, defaultInterval time.Durat
 	}
 	if b.Method == "" {
 		b.Method = "GET"
+		if b.Body != "" {
+			b.Method = "POST"
+		}
 	}
 	if len(b.ExpectedStatus) == 0 {
 		b.ExpectedStatus = []StatusRange{{200, 399}}
@@ -238,11 +244,15 @@ func applyBackendDefaults(b *Backend, defaultTimeout, defaultInterval time.Durat
 }
 
 func backendsEqual(a, b Backend) bool {
-	if a.HealthPath != b.HealthPath || a.Method != b.Method ||
+	if a.Type != b.Type || a.HealthPath != b.HealthPath || a.Method != b.Method ||
+		a.Body != b.Body || a.Send != b.Send || a.Expect != b.Expect || a.Service != b.Service ||
 		a.Timeout != b.Timeout || a.Interval != b.Interval ||
 		a.HealthyAfter != b.HealthyAfter || a.UnhealthyAfter != b.UnhealthyAfter {
 		return false
 	}
+	if !maps.Equal(a.Headers, b.Headers) {
+		return false
+	}
 	if len(a.ExpectedStatus) != len(b.ExpectedStatus) {
 		return false
 	}
@@ -344,36 +354,111 @@ func (c *Checker) check(url string) {
 	backend := state.backend
 	c.mu.RUnlock()
 
-	// Perform HTTP health check
-	checkURL := url + backend.HealthPath
 	start := time.Now()
-
 	ctx, cancel := context.WithTimeout(c.ctx, backend.Timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, backend.Method, checkURL, nil)
+	var err error
+	switch backend.Type {
+	case ProbeTCP:
+		err = probeTCP(ctx, probeAddress(url), backend)
+	case ProbeGRPC:
+		err = grpcproxy.NewHealthChecker(backend.Service).Check(ctx, probeAddress(url))
+	default:
+		err = c.probeHTTP(ctx, url, backend)
+	}
+
+	c.updateStatus(url, err == nil, time.Since(start), err)
+}
+
+// probeHTTP sends the configured request and matches the response status.
+func (c *Checker) probeHTTP(ctx context.Context, backendURL string, backend Backend) error {
+	var body io.Reader
+	if backend.Body != "" {
+		body = strings.NewReader(backend.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, backend.Method, backendURL+backend.HealthPath, body)
 	if err != nil {
-		c.updateStatus(url, false, time.Since(start), err)
-		return
+		return err
+	}
+	for k, v := range backend.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
 	}
 
 	resp, err := c.client.Do(req)
-	latency := time.Since(start)
-
 	if err != nil {
-		c.updateStatus(url, false, latency, err)
-		return
+		return err
 	}
 	defer resp.Body.Close()
 
 	// Check against expected status ranges
-	healthy := matchStatus(resp.StatusCode, backend.ExpectedStatus)
-	var checkErr error
-	if !healthy {
-		checkErr = fmt.Errorf("unhealthy status code: %d", resp.StatusCode)
+	if !matchStatus(resp.StatusCode, backend.ExpectedStatus) {
+		return fmt.Errorf("unhealthy status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// maxBannerSize bounds how much of a TCP reply is searched for Expect.
+const maxBannerSize = 4096
+
+// probeTCP connects to addr and, when configured, writes Send and waits for
+// a reply containing Expect. The whole exchange must finish before ctx ends.
+func probeTCP(ctx context.Context, addr string, backend Backend) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if backend.Send != "" {
+		if _, err := conn.Write([]byte(backend.Send)); err != nil {
+			return fmt.Errorf("send: %w", err)
+		}
+	}
+	if backend.Expect == "" {
+		return nil
+	}
+
+	buf := make([]byte, 0, 512)
+	chunk := make([]byte, 512)
+	for len(buf) < maxBannerSize {
+		n, err := conn.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if strings.Contains(string(buf), backend.Expect) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("expected banner %q not received: %w", backend.Expect, err)
+		}
 	}
+	return fmt.Errorf("expected banner %q not found in first %d bytes", backend.Expect, maxBannerSize)
+}
 
-	c.updateStatus(url, healthy, latency, checkErr)
+// probeAddress returns the host:port a TCP or gRPC probe dials for a backend
+// URL. Scheme-less URLs are used as they are.
+func probeAddress(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	switch u.Scheme {
+	case "https", "grpcs":
+		return net.JoinHostPort(u.Hostname(), "443")
+	case "http", "grpc", "h2c":
+		return net.JoinHostPort(u.Hostname(), "80")
+	}
+	return u.Host
 }
 
 // updateStatus updates the health status with threshold logic
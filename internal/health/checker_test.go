@@ -1,11 +1,18 @@
 package health
 
 import (
+	"bufio"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func TestHealthChecker(t *testing.T) {
@@ -519,3 +526,156 @@ func TestGetBackendConfig(t *testing.T) {
 		t.Error("expected false for unknown backend")
 	}
 }
+
+func TestHTTPProbeBodyAndHeaders(t *testing.T) {
+	var method, body, token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		token = r.Header.Get("X-Probe-Token")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	checker := NewChecker(Config{DefaultTimeout: time.Second, DefaultInterval: time.Hour})
+	defer checker.Stop()
+
+	checker.AddBackend(Backend{
+		URL:            server.URL,
+		HealthPath:     "/ready",
+		Body:           `{"deep":true}`,
+		Headers:        map[string]string{"X-Probe-Token": "s3cret"},
+		HealthyAfter:   1,
+		UnhealthyAfter: 1,
+	})
+
+	if result := checker.CheckNow(server.URL); result.Status != StatusHealthy {
+		t.Fatalf("expected healthy, got %s (%v)", result.Status, result.Error)
+	}
+	if method != "POST" {
+		t.Errorf("expected a body to default the method to POST, got %s", method)
+	}
+	if body != `{"deep":true}` {
+		t.Errorf("unexpected body %q", body)
+	}
+	if token != "s3cret" {
+		t.Errorf("unexpected header %q", token)
+	}
+}
+
+// startBannerServer accepts connections, reads one line and answers reply.
+func startBannerServer(t *testing.T, reply string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(time.Second))
+				if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+					return
+				}
+				io.WriteString(conn, reply)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestTCPProbe(t *testing.T) {
+	addr := startBannerServer(t, "+PONG\r\n")
+
+	checker := NewChecker(Config{DefaultTimeout: 500 * time.Millisecond, DefaultInterval: time.Hour})
+	defer checker.Stop()
+
+	tests := []struct {
+		name   string
+		url    string
+		send   string
+		expect string
+		want   Status
+	}{
+		{"connect only", "tcp://" + addr, "", "", StatusHealthy},
+		{"banner matches", addr, "PING\r\n", "PONG", StatusHealthy},
+		{"banner mismatch", "tcp://" + addr + "/", "PING\r\n", "READY", StatusUnhealthy},
+		{"refused", "tcp://127.0.0.1:1", "", "", StatusUnhealthy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker.AddBackend(Backend{
+				URL:            tt.url,
+				Type:           ProbeTCP,
+				Send:           tt.send,
+				Expect:         tt.expect,
+				HealthyAfter:   1,
+				UnhealthyAfter: 1,
+			})
+			defer checker.RemoveBackend(tt.url)
+			if result := checker.CheckNow(tt.url); result.Status != tt.want {
+				t.Errorf("expected %s, got %s (%v)", tt.want, result.Status, result.Error)
+			}
+		})
+	}
+}
+
+func TestGRPCProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := grpchealth.NewServer()
+	hs.SetServingStatus("orders", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("billing", healthpb.HealthCheckResponse_NOT_SERVING)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	checker := NewChecker(Config{DefaultTimeout: 2 * time.Second, DefaultInterval: time.Hour})
+	defer checker.Stop()
+
+	url := "grpc://" + ln.Addr().String()
+	for service, want := range map[string]Status{"orders": StatusHealthy, "billing": StatusUnhealthy} {
+		checker.UpdateBackend(Backend{URL: url, Type: ProbeGRPC, Service: service, HealthyAfter: 1, UnhealthyAfter: 1})
+		if result := checker.CheckNow(url); result.Status != want {
+			t.Errorf("%s: expected %s, got %s (%v)", service, want, result.Status, result.Error)
+		}
+	}
+}
+
+func TestProbeHysteresisAppliesToTCP(t *testing.T) {
+	addr := startBannerServer(t, "OK\n")
+
+	checker := NewChecker(Config{DefaultTimeout: 500 * time.Millisecond, DefaultInterval: time.Hour})
+	defer checker.Stop()
+
+	checker.AddBackend(Backend{URL: addr, Type: ProbeTCP, Send: "HELLO\n", Expect: "OK", HealthyAfter: 2, UnhealthyAfter: 1})
+	checker.CheckNow(addr)
+	if got := checker.CheckNow(addr).Status; got != StatusHealthy {
+		t.Fatalf("expected healthy after two passes, got %s", got)
+	}
+}
+
+func TestProbeAddress(t *testing.T) {
+	tests := map[string]string{
+		"tcp://db:5432":        "db:5432",
+		"http://api":           "api:80",
+		"https://api":          "api:443",
+		"grpc://10.0.0.1:9090": "10.0.0.1:9090",
+		"10.0.0.1:6379":        "10.0.0.1:6379",
+	}
+	for in, want := range tests {
+		if got := probeAddress(in); got != want {
+			t.Errorf("probeAddress(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Dependency is an external dependency of the gateway (Redis, a service
+// registry, a geo database, an OPA server, ...) probed in the background.
+type Dependency struct {
+	Name             string
+	Kind             string // "redis", "registry", "geo_db", "opa", "ext_auth", "webhook"
+	Target           string // address, URL or path probed; a change restarts the probe
+	Check            func(ctx context.Context) error
+	Interval         time.Duration
+	Timeout          time.Duration
+	Critical         bool
+	AffectsReadiness bool // a failing critical dependency marks the instance not ready
+}
+
+// DependencyResult is the cached outcome of a dependency's latest probe.
+type DependencyResult struct {
+	Name             string    `json:"name"`
+	Kind             string    `json:"kind"`
+	Target           string    `json:"target"`
+	Status           Status    `json:"status"`
+	Critical         bool      `json:"critical"`
+	AffectsReadiness bool      `json:"affects_readiness"`
+	LatencyMs        float64   `json:"latency_ms"`
+	LastError        string    `json:"last_error,omitempty"`
+	LastCheck        time.Time `json:"last_check,omitempty"`
+	LastChange       time.Time `json:"last_change,omitempty"`
+	Checks           int64     `json:"checks"`
+	Failures         int64     `json:"failures"`
+}
+
+// DependencyMonitorConfig holds dependency monitor configuration.
+type DependencyMonitorConfig struct {
+	DefaultInterval time.Duration
+	DefaultTimeout  time.Duration
+	// OnChange is called when a dependency becomes healthy or unhealthy.
+	// The first result of a healthy dependency is not reported.
+	OnChange func(result DependencyResult, previous Status)
+}
+
+// DefaultDependencyMonitorConfig provides default dependency monitor settings.
+var DefaultDependencyMonitorConfig = DependencyMonitorConfig{
+	DefaultInterval: 15 * time.Second,
+	DefaultTimeout:  3 * time.Second,
+}
+
+// DependencyMonitor probes dependencies on their own intervals and caches
+// the results, so readers never trigger a probe.
+type DependencyMonitor struct {
+	mu              sync.RWMutex
+	deps            map[string]*dependencyState
+	defaultInterval time.Duration
+	defaultTimeout  time.Duration
+	onChange        func(result DependencyResult, previous Status)
+}
+
+type dependencyState struct {
+	dep        Dependency
+	status     Status
+	lastCheck  time.Time
+	lastChange time.Time
+	lastError  error
+	latency    time.Duration
+	checks     int64
+	failures   int64
+	cancel     context.CancelFunc
+}
+
+// NewDependencyMonitor creates a dependency monitor with no dependencies.
+func NewDependencyMonitor(cfg DependencyMonitorConfig) *DependencyMonitor {
+	if cfg.DefaultInterval == 0 {
+		cfg.DefaultInterval = DefaultDependencyMonitorConfig.DefaultInterval
+	}
+	if cfg.DefaultTimeout == 0 {
+		cfg.DefaultTimeout = DefaultDependencyMonitorConfig.DefaultTimeout
+	}
+	return &DependencyMonitor{
+		deps:            make(map[string]*dependencyState),
+		defaultInterval: cfg.DefaultInterval,
+		defaultTimeout:  cfg.DefaultTimeout,
+		onChange:        cfg.OnChange,
+	}
+}
+
+// Update replaces the set of monitored dependencies. Dependencies whose
+// target, interval and timeout are unchanged keep their probe and cached
+// state; removed dependencies stop being probed.
+func (m *DependencyMonitor) Update(deps []Dependency) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keep := make(map[string]bool, len(deps))
+	for _, d := range deps {
+		if d.Interval == 0 {
+			d.Interval = m.defaultInterval
+		}
+		if d.Timeout == 0 {
+			d.Timeout = m.defaultTimeout
+		}
+		keep[d.Name] = true
+
+		if st, ok := m.deps[d.Name]; ok {
+			if dependenciesEqual(st.dep, d) {
+				// Flags may change without restarting the probe.
+				st.dep.Critical = d.Critical
+				st.dep.AffectsReadiness = d.AffectsReadiness
+				st.dep.Check = d.Check
+				continue
+			}
+			st.cancel()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		st := &dependencyState{dep: d, status: StatusUnknown, cancel: cancel}
+		m.deps[d.Name] = st
+		go m.checkLoop(ctx, st)
+	}
+
+	for name, st := range m.deps {
+		if !keep[name] {
+			st.cancel()
+			delete(m.deps, name)
+		}
+	}
+}
+
+func dependenciesEqual(a, b Dependency) bool {
+	return a.Kind == b.Kind && a.Target == b.Target &&
+		a.Interval == b.Interval && a.Timeout == b.Timeout
+}
+
+// checkLoop probes a dependency until its context is cancelled.
+func (m *DependencyMonitor) checkLoop(ctx context.Context, st *dependencyState) {
+	m.check(ctx, st)
+
+	ticker := time.NewTicker(st.dep.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check(ctx, st)
+		}
+	}
+}
+
+// check runs a single probe and records its result.
+func (m *DependencyMonitor) check(ctx context.Context, st *dependencyState) {
+	m.mu.RLock()
+	dep := st.dep
+	m.mu.RUnlock()
+
+	checkCtx, cancel := context.WithTimeout(ctx, dep.Timeout)
+	start := time.Now()
+	err := dep.Check(checkCtx)
+	latency := time.Since(start)
+	cancel()
+
+	if ctx.Err() != nil {
+		// Removed or replaced while probing.
+		return
+	}
+
+	m.mu.Lock()
+	previous := st.status
+	st.lastCheck = time.Now()
+	st.lastError = err
+	st.latency = latency
+	st.checks++
+	if err != nil {
+		st.failures++
+		st.status = StatusUnhealthy
+	} else {
+		st.status = StatusHealthy
+	}
+	changed := previous != st.status
+	if changed {
+		st.lastChange = st.lastCheck
+	}
+	result := st.result()
+	m.mu.Unlock()
+
+	if changed && m.onChange != nil && !(previous == StatusUnknown && result.Status == StatusHealthy) {
+		go m.onChange(result, previous)
+	}
+}
+
+// result returns the cached result. Callers must hold m.mu.
+func (st *dependencyState) result() DependencyResult {
+	r := DependencyResult{
+		Name:             st.dep.Name,
+		Kind:             st.dep.Kind,
+		Target:           st.dep.Target,
+		Status:           st.status,
+		Critical:         st.dep.Critical,
+		AffectsReadiness: st.dep.AffectsReadiness,
+		LatencyMs:        float64(st.latency.Microseconds()) / 1000,
+		LastCheck:        st.lastCheck,
+		LastChange:       st.lastChange,
+		Checks:           st.checks,
+		Failures:         st.failures,
+	}
+	if st.lastError != nil {
+		r.LastError = st.lastError.Error()
+	}
+	return r
+}
+
+// Results returns the cached results of all dependencies, sorted by name.
+func (m *DependencyMonitor) Results() []DependencyResult {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := make([]DependencyResult, 0, len(m.deps))
+	for _, st := range m.deps {
+		results = append(results, st.result())
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+// Status summarizes all dependencies: unhealthy when a critical dependency
+// is failing, degraded when only non-critical ones are, healthy otherwise.
+func (m *DependencyMonitor) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := StatusHealthy
+	for _, st := range m.deps {
+		if st.status != StatusUnhealthy {
+			continue
+		}
+		if st.dep.Critical {
+			return StatusUnhealthy
+		}
+		status = StatusDegraded
+	}
+	return status
+}
+
+// ReadinessReasons returns a reason for each failing critical dependency
+// configured to affect readiness.
+func (m *DependencyMonitor) ReadinessReasons() []string {
+	var reasons []string
+	for _, r := range m.Results() {
+		if r.Status == StatusUnhealthy && r.Critical && r.AffectsReadiness {
+			reasons = append(reasons, fmt.Sprintf("dependency %s unavailable: %s", r.Name, r.LastError))
+		}
+	}
+	return reasons
+}
+
+// Stop stops all dependency probes.
+func (m *DependencyMonitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, st := range m.deps {
+		st.cancel()
+		delete(m.deps, name)
+	}
+}
+
+// DialCheck returns a probe that opens and closes a TCP connection to addr.
+func DialCheck(addr string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// HTTPCheck returns a probe that issues a GET to url and expects a 2xx response.
+func HTTPCheck(client *http.Client, url string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unhealthy status code: %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// FileCheck returns a probe that verifies path is a readable, non-empty file.
+func FileCheck(path string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		var b [1]byte
+		if _, err := f.Read(b[:]); err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		return nil
+	}
+}
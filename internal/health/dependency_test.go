@@ -0,0 +1,233 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or the deadline passes.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
+
+func resultByName(m *DependencyMonitor, name string) (DependencyResult, bool) {
+	for _, r := range m.Results() {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return DependencyResult{}, false
+}
+
+func TestDependencyMonitorResults(t *testing.T) {
+	m := NewDependencyMonitor(DependencyMonitorConfig{DefaultInterval: time.Hour})
+	defer m.Stop()
+
+	m.Update([]Dependency{
+		{Name: "redis", Kind: "redis", Check: func(ctx context.Context) error { return nil }, Critical: true},
+		{Name: "webhook:ops", Kind: "webhook", Check: func(ctx context.Context) error { return errors.New("refused") }},
+	})
+
+	waitFor(t, func() bool {
+		r1, _ := resultByName(m, "redis")
+		r2, _ := resultByName(m, "webhook:ops")
+		return r1.Status != StatusUnknown && r2.Status != StatusUnknown
+	})
+
+	results := m.Results()
+	if len(results) != 2 || results[0].Name != "redis" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results[0].Status != StatusHealthy {
+		t.Errorf("redis status = %s, want healthy", results[0].Status)
+	}
+	if results[1].Status != StatusUnhealthy || results[1].LastError != "refused" {
+		t.Errorf("webhook result = %+v, want unhealthy with error", results[1])
+	}
+	if results[1].Failures != 1 {
+		t.Errorf("failures = %d, want 1", results[1].Failures)
+	}
+
+	// Only a non-critical dependency is failing.
+	if got := m.Status(); got != StatusDegraded {
+		t.Errorf("Status() = %s, want degraded", got)
+	}
+}
+
+func TestDependencyMonitorReadiness(t *testing.T) {
+	m := NewDependencyMonitor(DependencyMonitorConfig{DefaultInterval: time.Hour})
+	defer m.Stop()
+
+	fail := func(ctx context.Context) error { return errors.New("down") }
+	m.Update([]Dependency{
+		{Name: "registry", Kind: "registry", Check: fail, Critical: true, AffectsReadiness: true},
+		{Name: "geo_db", Kind: "geo_db", Check: fail, Critical: true},
+		{Name: "webhook:ops", Kind: "webhook", Check: fail, AffectsReadiness: true},
+	})
+	waitFor(t, func() bool {
+		for _, r := range m.Results() {
+			if r.Status == StatusUnknown {
+				return false
+			}
+		}
+		return true
+	})
+
+	reasons := m.ReadinessReasons()
+	if len(reasons) != 1 {
+		t.Fatalf("reasons = %v, want only the registry", reasons)
+	}
+	if m.Status() != StatusUnhealthy {
+		t.Errorf("Status() = %s, want unhealthy", m.Status())
+	}
+}
+
+func TestDependencyMonitorTransitions(t *testing.T) {
+	var healthy atomic.Bool
+	var mu sync.Mutex
+	var events []Status
+
+	m := NewDependencyMonitor(DependencyMonitorConfig{
+		DefaultInterval: 10 * time.Millisecond,
+		OnChange: func(r DependencyResult, previous Status) {
+			mu.Lock()
+			events = append(events, r.Status)
+			mu.Unlock()
+		},
+	})
+	defer m.Stop()
+
+	healthy.Store(true)
+	m.Update([]Dependency{{
+		Name: "opa:opa:8181",
+		Kind: "opa",
+		Check: func(ctx context.Context) error {
+			if healthy.Load() {
+				return nil
+			}
+			return errors.New("down")
+		},
+	}})
+
+	waitFor(t, func() bool {
+		r, _ := resultByName(m, "opa:opa:8181")
+		return r.Status == StatusHealthy
+	})
+	healthy.Store(false)
+	waitFor(t, func() bool {
+		r, _ := resultByName(m, "opa:opa:8181")
+		return r.Status == StatusUnhealthy
+	})
+	healthy.Store(true)
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) == 2
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	// The initial unknown -> healthy result is not reported.
+	if events[0] != StatusUnhealthy || events[1] != StatusHealthy {
+		t.Errorf("events = %v, want [unhealthy healthy]", events)
+	}
+}
+
+func TestDependencyMonitorUpdateKeepsState(t *testing.T) {
+	var calls atomic.Int64
+	check := func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}
+
+	m := NewDependencyMonitor(DependencyMonitorConfig{DefaultInterval: time.Hour})
+	defer m.Stop()
+
+	m.Update([]Dependency{{Name: "redis", Kind: "redis", Target: "localhost:6379", Check: check}})
+	waitFor(t, func() bool { return calls.Load() == 1 })
+
+	// Same target: no new probe, flags updated in place.
+	m.Update([]Dependency{{Name: "redis", Kind: "redis", Target: "localhost:6379", Check: check, Critical: true}})
+	time.Sleep(20 * time.Millisecond)
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1 (probe restarted)", calls.Load())
+	}
+	r, _ := resultByName(m, "redis")
+	if !r.Critical || r.Status != StatusHealthy {
+		t.Errorf("result = %+v, want critical and healthy", r)
+	}
+
+	// Changed target restarts the probe.
+	m.Update([]Dependency{{Name: "redis", Kind: "redis", Target: "redis:6379", Check: check}})
+	waitFor(t, func() bool { return calls.Load() == 2 })
+
+	// Removed dependencies disappear.
+	m.Update(nil)
+	if len(m.Results()) != 0 {
+		t.Errorf("results = %+v, want none", m.Results())
+	}
+}
+
+func TestDependencyProbes(t *testing.T) {
+	ctx := context.Background()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	if err := DialCheck(addr)(ctx); err != nil {
+		t.Errorf("DialCheck on open port: %v", err)
+	}
+	ln.Close()
+	if err := DialCheck(addr)(ctx); err == nil {
+		t.Error("DialCheck on closed port: expected error")
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	if err := HTTPCheck(srv.Client(), srv.URL+"/health")(ctx); err != nil {
+		t.Errorf("HTTPCheck healthy: %v", err)
+	}
+	if err := HTTPCheck(srv.Client(), srv.URL+"/other")(ctx); err == nil {
+		t.Error("HTTPCheck 500: expected error")
+	}
+
+	path := filepath.Join(t.TempDir(), "geo.mmdb")
+	if err := FileCheck(path)(ctx); err == nil {
+		t.Error("FileCheck missing file: expected error")
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := FileCheck(path)(ctx); err == nil {
+		t.Error("FileCheck empty file: expected error")
+	}
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := FileCheck(path)(ctx); err != nil {
+		t.Errorf("FileCheck readable file: %v", err)
+	}
+}
@@ -25,8 +25,9 @@ func init() {
 	utilruntime.Must(gatewayv1.Install(scheme))
 }
 
-// ReloadFunc is called to apply a new config to the runway.
-type ReloadFunc func(cfg *config.Config)
+// ReloadFunc is called to apply a new config to the runway. It returns the
+// hash of the applied config, or the error the data plane reported.
+type ReloadFunc func(cfg *config.Config) (configHash string, err error)
 
 // ControllerConfig holds configuration for the ingress controller.
 type ControllerConfig struct {
@@ -42,9 +43,13 @@ type ControllerConfig struct {
 	EnableIngress bool
 	// EnableGatewayAPI enables watching Gateway API resources.
 	EnableGatewayAPI bool
+	// EnableRoutePolicy enables watching RoutePolicy resources referenced by
+	// HTTPRoute ExtensionRef filters. Requires the RoutePolicy CRD.
+	EnableRoutePolicy bool
 	// DebounceDelay is the coalescing delay for rebuild (default 100ms).
 	DebounceDelay time.Duration
-	// MetricsAddr is the bind address for controller-runtime metrics.
+	// MetricsAddr is the bind address for controller-runtime metrics. The
+	// /status and /resync endpoints are served there too.
 	MetricsAddr string
 	// BaseConfig is the base config merged with K8s-derived routes.
 	BaseConfig *config.Config
@@ -72,6 +77,10 @@ type Controller struct {
 	debounceTimer  *time.Timer
 	lastAppliedGen int64
 	reloading      atomic.Bool
+	pending        atomic.Int64 // changes waiting for the debounce timer
+	resync         atomic.Bool  // rebuild even if the store is unchanged
+
+	health healthState
 
 	// Status
 	statusUpdater *StatusUpdater
@@ -79,29 +88,15 @@ type Controller struct {
 
 // NewController creates and configures a new ingress controller.
 func NewController(cfg ControllerConfig) (*Controller, error) {
-	if cfg.IngressClass == "" {
-		cfg.IngressClass = "runway"
-	}
-	if cfg.ControllerName == "" {
-		cfg.ControllerName = "runway.wudi.io/ingress-controller"
-	}
-	if cfg.DebounceDelay == 0 {
-		cfg.DebounceDelay = 100 * time.Millisecond
-	}
-	if cfg.MetricsAddr == "" {
-		cfg.MetricsAddr = ":9090"
-	}
-
-	store := NewStore()
-
-	if cfg.LeaderElectionNamespace == "" {
-		cfg.LeaderElectionNamespace = "default"
-	}
+	c := newController(cfg, NewStore())
+	cfg = c.cfg
+	store := c.store
 
 	opts := ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
-			BindAddress: cfg.MetricsAddr,
+			BindAddress:   cfg.MetricsAddr,
+			ExtraHandlers: c.healthHandlers(),
 		},
 		HealthProbeBindAddress:  "", // disabled; use runway's /health and /ready
 		LeaderElection:          true,
@@ -123,13 +118,8 @@ func NewController(cfg ControllerConfig) (*Controller, error) {
 		return nil, err
 	}
 
-	c := &Controller{
-		cfg:           cfg,
-		store:         store,
-		manager:       mgr,
-		reloadFn:      cfg.ReloadFn,
-		statusUpdater: NewStatusUpdater(mgr.GetClient(), cfg.PublishAddress),
-	}
+	c.manager = mgr
+	c.statusUpdater = NewStatusUpdater(mgr.GetClient(), cfg.PublishAddress)
 
 	// Register reconcilers
 	if cfg.EnableIngress {
@@ -144,6 +134,11 @@ func NewController(cfg ControllerConfig) (*Controller, error) {
 		if err := NewHTTPRouteReconciler(mgr, store, c, cfg.ControllerName); err != nil {
 			return nil, err
 		}
+		if cfg.EnableRoutePolicy {
+			if err := NewRoutePolicyReconciler(mgr, store, c); err != nil {
+				return nil, err
+			}
+		}
 	}
 	if err := NewSecretReconciler(mgr, store, c); err != nil {
 		return nil, err
@@ -155,6 +150,31 @@ func NewController(cfg ControllerConfig) (*Controller, error) {
 	return c, nil
 }
 
+// newController applies the config defaults and builds a Controller over
+// store without a manager.
+func newController(cfg ControllerConfig, store *Store) *Controller {
+	if cfg.IngressClass == "" {
+		cfg.IngressClass = "runway"
+	}
+	if cfg.ControllerName == "" {
+		cfg.ControllerName = "runway.wudi.io/ingress-controller"
+	}
+	if cfg.DebounceDelay == 0 {
+		cfg.DebounceDelay = 100 * time.Millisecond
+	}
+	if cfg.MetricsAddr == "" {
+		cfg.MetricsAddr = ":9090"
+	}
+	if cfg.LeaderElectionNamespace == "" {
+		cfg.LeaderElectionNamespace = "default"
+	}
+	return &Controller{
+		cfg:      cfg,
+		store:    store,
+		reloadFn: cfg.ReloadFn,
+	}
+}
+
 // Start starts the controller-runtime manager. Blocks until ctx is cancelled.
 func (c *Controller) Start(ctx context.Context) error {
 	return c.manager.Start(ctx)
@@ -162,6 +182,7 @@ func (c *Controller) Start(ctx context.Context) error {
 
 // TriggerReload schedules a debounced config rebuild and reload.
 func (c *Controller) TriggerReload() {
+	c.pending.Add(1)
 	c.debounceMu.Lock()
 	defer c.debounceMu.Unlock()
 
@@ -173,14 +194,24 @@ func (c *Controller) TriggerReload() {
 
 // doReload performs the actual config translation and reload.
 func (c *Controller) doReload() {
+	force := c.resync.Swap(false)
 	gen := c.store.Generation()
-	if gen == c.lastAppliedGen {
+	if gen == c.lastAppliedGen && !force {
+		c.pending.Store(0)
 		return // no changes since last reload
 	}
 	if !c.reloading.CompareAndSwap(false, true) {
-		return // already reloading
+		// Already reloading; try again once that rebuild is done so the
+		// change is not dropped.
+		if force {
+			c.resync.Store(true)
+		}
+		c.TriggerReload()
+		return
 	}
 	defer c.reloading.Store(false)
+	c.pending.Store(0)
+	start := time.Now()
 
 	translator := NewTranslator(c.store, c.cfg.BaseConfig, TranslatorConfig{
 		IngressClass:      c.cfg.IngressClass,
@@ -188,22 +219,33 @@ func (c *Controller) doReload() {
 		WatchWithoutClass: c.cfg.WatchWithoutClass,
 		DefaultHTTPPort:   c.cfg.DefaultHTTPPort,
 		DefaultHTTPSPort:  c.cfg.DefaultHTTPSPort,
+		EnableRoutePolicy: c.cfg.EnableRoutePolicy,
 	})
 
 	newCfg, warnings := translator.Translate()
 	for _, w := range warnings {
 		ctrl.Log.Info("Translation warning", "warning", w)
 	}
+	c.health.setRejections(translator.Rejections())
 
 	if err := config.Validate(newCfg); err != nil {
 		ctrl.Log.Error(err, "Config validation failed, skipping reload")
+		c.health.record(start, rebuildInvalid, "", err)
 		return
 	}
 
+	var hash string
 	if c.reloadFn != nil {
-		c.reloadFn(newCfg)
+		var err error
+		if hash, err = c.reloadFn(newCfg); err != nil {
+			// Leave lastAppliedGen so the next change retries.
+			ctrl.Log.Error(err, "Runway rejected the config")
+			c.health.record(start, rebuildReloadError, "", err)
+			return
+		}
 	}
 	c.lastAppliedGen = gen
+	c.health.record(start, rebuildSuccess, hash, nil)
 }
 
 // Store returns the internal resource store.
@@ -216,6 +258,21 @@ func (c *Controller) StatusUpdater() *StatusUpdater {
 	return c.statusUpdater
 }
 
+// updateHTTPRouteStatus sets the Accepted condition on each parent of hr,
+// rejecting routes with filters or backendRefs that cannot be translated.
+func (c *Controller) updateHTTPRouteStatus(ctx context.Context, hr *gatewayv1.HTTPRoute) error {
+	accepted, reason, msg := true, string(gatewayv1.RouteReasonAccepted), "HTTPRoute accepted"
+	if ferr := checkHTTPRoute(c.store, hr, c.cfg.EnableRoutePolicy); ferr != nil {
+		accepted, reason, msg = false, string(ferr.Reason), ferr.Message
+	}
+	for _, ref := range hr.Spec.ParentRefs {
+		if err := c.statusUpdater.UpdateHTTPRouteStatus(ctx, hr, ref, accepted, reason, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // IsLeader returns true if this instance is the leader.
 func (c *Controller) IsLeader() bool {
 	// controller-runtime doesn't expose a direct IsLeader check,
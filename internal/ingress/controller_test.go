@@ -0,0 +1,250 @@
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/wudi/runway/config"
+)
+
+// fakeController builds a Controller over a fake client holding objs and
+// returns a function that reconciles every object through the reconcilers.
+func fakeController(t *testing.T, reload ReloadFunc, objs ...client.Object) (*Controller, client.Client, func()) {
+	t.Helper()
+	cl := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&networkingv1.Ingress{}, &gatewayv1.GatewayClass{}, &gatewayv1.Gateway{}, &gatewayv1.HTTPRoute{}).
+		Build()
+	c := newController(ControllerConfig{
+		EnableIngress:    true,
+		EnableGatewayAPI: true,
+		DebounceDelay:    time.Hour, // rebuilds are driven by the test
+		ReloadFn:         reload,
+	}, NewStore())
+	c.statusUpdater = NewStatusUpdater(cl, "10.0.0.1")
+
+	gwr := &GatewayReconciler{client: cl, store: c.store, controller: c, controllerName: c.cfg.ControllerName}
+	reconcilers := []reconcile.Reconciler{
+		&gatewayClassReconciler{r: gwr},
+		gwr,
+		&HTTPRouteReconciler{client: cl, store: c.store, controller: c, controllerName: c.cfg.ControllerName},
+		&IngressReconciler{client: cl, store: c.store, controller: c, ingressClass: c.cfg.IngressClass},
+	}
+	return c, cl, func() {
+		t.Helper()
+		for _, obj := range objs {
+			req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}}
+			for _, r := range reconcilers {
+				if !handles(r, obj) {
+					continue
+				}
+				if _, err := r.Reconcile(context.Background(), req); err != nil {
+					t.Fatalf("reconcile %s: %v", obj.GetName(), err)
+				}
+			}
+		}
+	}
+}
+
+func handles(r reconcile.Reconciler, obj client.Object) bool {
+	switch r.(type) {
+	case *gatewayClassReconciler:
+		_, ok := obj.(*gatewayv1.GatewayClass)
+		return ok
+	case *GatewayReconciler:
+		_, ok := obj.(*gatewayv1.Gateway)
+		return ok
+	case *HTTPRouteReconciler:
+		_, ok := obj.(*gatewayv1.HTTPRoute)
+		return ok
+	case *IngressReconciler:
+		_, ok := obj.(*networkingv1.Ingress)
+		return ok
+	}
+	return false
+}
+
+func healthObjects() []client.Object {
+	className := "runway"
+	pathPrefix := networkingv1.PathTypePrefix
+	return []client.Object{
+		&gatewayv1.GatewayClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "runway"},
+			Spec:       gatewayv1.GatewayClassSpec{ControllerName: "runway.wudi.io/ingress-controller"},
+		},
+		&gatewayv1.Gateway{
+			ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default"},
+			Spec: gatewayv1.GatewaySpec{
+				GatewayClassName: "runway",
+				Listeners:        []gatewayv1.Listener{{Name: "http", Port: 8080, Protocol: gatewayv1.HTTPProtocolType}},
+			},
+		},
+		&gatewayv1.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{Name: "good", Namespace: "default"},
+			Spec: gatewayv1.HTTPRouteSpec{
+				CommonRouteSpec: gatewayv1.CommonRouteSpec{ParentRefs: []gatewayv1.ParentReference{{Name: "gw"}}},
+				Rules: []gatewayv1.HTTPRouteRule{{
+					BackendRefs: []gatewayv1.HTTPBackendRef{{BackendRef: gatewayv1.BackendRef{
+						BackendObjectReference: gatewayv1.BackendObjectReference{Name: "api", Port: ptr(gatewayv1.PortNumber(8080))},
+					}}},
+				}},
+			},
+		},
+		&gatewayv1.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{Name: "bad", Namespace: "default"},
+			Spec: gatewayv1.HTTPRouteSpec{
+				CommonRouteSpec: gatewayv1.CommonRouteSpec{ParentRefs: []gatewayv1.ParentReference{{Name: "gw"}}},
+				Rules: []gatewayv1.HTTPRouteRule{{
+					Matches: []gatewayv1.HTTPRouteMatch{{Path: &gatewayv1.HTTPPathMatch{Value: ptr("/files")}}},
+					BackendRefs: []gatewayv1.HTTPBackendRef{{BackendRef: gatewayv1.BackendRef{
+						BackendObjectReference: gatewayv1.BackendObjectReference{Kind: ptr(gatewayv1.Kind("Bucket")), Name: "files"},
+					}}},
+				}},
+			},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "static", Namespace: "web"},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: &className,
+				Rules: []networkingv1.IngressRule{{
+					IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:     "/static",
+							PathType: &pathPrefix,
+							Backend: networkingv1.IngressBackend{Resource: &corev1.TypedLocalObjectReference{
+								Kind: "StorageBucket", Name: "assets",
+							}},
+						}},
+					}},
+				}},
+			},
+		},
+	}
+}
+
+func TestControllerRejectsInvalidBackendRefs(t *testing.T) {
+	var applied *config.Config
+	c, cl, reconcileAll := fakeController(t, func(cfg *config.Config) (string, error) {
+		applied = cfg
+		return "abc123", nil
+	}, healthObjects()...)
+	reconcileAll()
+
+	if got := c.Status()["debounce_queue_depth"]; got != int64(5) {
+		t.Errorf("expected 5 queued changes before the rebuild, got %v", got)
+	}
+	c.doReload()
+
+	want := []Rejection{
+		{Kind: "Ingress", Namespace: "web", Name: "static", Reason: IngressReasonInvalidBackend},
+		{Kind: "HTTPRoute", Namespace: "default", Name: "bad", Reason: string(gatewayv1.RouteReasonInvalidKind)},
+	}
+	got := c.Rejections()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d rejections, got %+v", len(want), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Kind != w.Kind || g.Namespace != w.Namespace || g.Name != w.Name || g.Reason != w.Reason || g.Message == "" {
+			t.Errorf("rejection %d = %+v, want %+v", i, g, w)
+		}
+	}
+
+	if applied == nil {
+		t.Fatal("expected the config without the rejected resources to be applied")
+	}
+	for _, r := range applied.Routes {
+		if r.ID == "hr-default-bad-0-0" || r.ID == "ing-web-static-0-0" {
+			t.Errorf("rejected resource translated to route %s", r.ID)
+		}
+	}
+
+	status := c.Status()
+	if status["config_hash"] != "abc123" || status["rejected_count"] != 2 || status["debounce_queue_depth"] != int64(0) {
+		t.Errorf("unexpected status %v", status)
+	}
+	if _, ok := status["last_successful_rebuild"]; !ok {
+		t.Error("expected last_successful_rebuild after a reload")
+	}
+
+	var hr gatewayv1.HTTPRoute
+	if err := cl.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "bad"}, &hr); err != nil {
+		t.Fatal(err)
+	}
+	if len(hr.Status.Parents) != 1 || hr.Status.Parents[0].Conditions[0].Reason != string(gatewayv1.RouteReasonInvalidKind) {
+		t.Errorf("expected the HTTPRoute status to report InvalidKind, got %+v", hr.Status.Parents)
+	}
+}
+
+func TestControllerReloadErrorAndResync(t *testing.T) {
+	reloadErr := errors.New("listener ingress-http: address already in use")
+	var calls int
+	c, _, reconcileAll := fakeController(t, func(cfg *config.Config) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", reloadErr
+		}
+		return "def456", nil
+	}, healthObjects()...)
+	reconcileAll()
+	c.doReload()
+
+	status := c.Status()
+	if status["last_reload_error"] != reloadErr.Error() {
+		t.Fatalf("expected the data plane error in the status, got %v", status)
+	}
+	if _, ok := status["last_successful_rebuild"]; ok {
+		t.Error("expected no successful rebuild yet")
+	}
+
+	// The failed reload left the generation unapplied, so the next rebuild
+	// retries it; after that only a resync rebuilds the unchanged store.
+	c.doReload()
+	if calls != 2 {
+		t.Fatalf("expected the unapplied generation to be retried, got %d calls", calls)
+	}
+	c.doReload()
+	if calls != 2 {
+		t.Fatalf("expected no rebuild without changes, got %d calls", calls)
+	}
+
+	srv := httptest.NewServer(c.healthHandlers()["/resync"])
+	defer srv.Close()
+	resp, err := http.Post(srv.URL, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202 from /resync, got %d", resp.StatusCode)
+	}
+	c.doReload() // the debounce timer is an hour out; run the rebuild now
+	if calls != 3 {
+		t.Fatalf("expected a forced rebuild after /resync, got %d calls", calls)
+	}
+
+	rec := httptest.NewRecorder()
+	c.healthHandlers()["/status"].ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["config_hash"] != "def456" || body["last_reload_error"] != reloadErr.Error() || body["rebuilds"] != float64(3) {
+		t.Errorf("unexpected /status body %v", body)
+	}
+}
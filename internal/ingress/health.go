@@ -0,0 +1,162 @@
+package ingress
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Rebuild results used as the result label of runway_ingress_rebuilds_total.
+const (
+	rebuildSuccess     = "success"
+	rebuildInvalid     = "invalid_config"
+	rebuildReloadError = "reload_error"
+)
+
+var (
+	rebuildDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "runway_ingress_reconcile_duration_seconds",
+		Help:    "Duration of config rebuilds (translate, validate and reload) in seconds",
+		Buckets: prometheus.DefBuckets,
+	})
+	rebuildsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "runway_ingress_rebuilds_total",
+		Help: "Total config rebuilds by result",
+	}, []string{"result"})
+	rejectedResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "runway_ingress_rejected_resources",
+		Help: "Resources left out of the last rebuilt config, by reason",
+	}, []string{"reason"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(rebuildDuration, rebuildsTotal, rejectedResources)
+}
+
+// healthState records the outcome of config rebuilds for the status
+// endpoint.
+type healthState struct {
+	mu               sync.Mutex
+	lastRebuild      time.Time // last rebuild applied by the data plane
+	lastAttempt      time.Time
+	lastRebuildError string // validation or reload error of the last attempt
+	lastReloadError  string // last error reported by ReloadFn
+	lastReloadErrAt  time.Time
+	configHash       string
+	rebuilds         int64
+	rejections       []Rejection
+}
+
+// setRejections replaces the rejection list with the one from the latest
+// translation and updates the per-reason gauge.
+func (h *healthState) setRejections(rs []Rejection) {
+	counts := make(map[string]int, len(rs))
+	for _, r := range rs {
+		counts[r.Reason]++
+	}
+	rejectedResources.Reset()
+	for reason, n := range counts {
+		rejectedResources.WithLabelValues(reason).Set(float64(n))
+	}
+
+	h.mu.Lock()
+	h.rejections = rs
+	h.mu.Unlock()
+}
+
+// record stores the outcome of a rebuild that started at start.
+func (h *healthState) record(start time.Time, result, hash string, err error) {
+	rebuildDuration.Observe(time.Since(start).Seconds())
+	rebuildsTotal.WithLabelValues(result).Inc()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastAttempt = start
+	h.rebuilds++
+	if err != nil {
+		h.lastRebuildError = err.Error()
+		if result == rebuildReloadError {
+			h.lastReloadError = err.Error()
+			h.lastReloadErrAt = start
+		}
+		return
+	}
+	h.lastRebuild = start
+	h.lastRebuildError = ""
+	h.configHash = hash
+}
+
+// Rejections returns the resources left out of the last rebuilt config.
+func (c *Controller) Rejections() []Rejection {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+	return append([]Rejection(nil), c.health.rejections...)
+}
+
+// Status returns the rebuild health served at /status.
+func (c *Controller) Status() map[string]any {
+	h := &c.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rejected := h.rejections
+	if rejected == nil {
+		rejected = []Rejection{}
+	}
+	status := map[string]any{
+		"debounce_queue_depth": c.pending.Load(),
+		"rebuilding":           c.reloading.Load(),
+		"rebuilds":             h.rebuilds,
+		"config_hash":          h.configHash,
+		"rejected_count":       len(rejected),
+		"rejected":             rejected,
+	}
+	if !h.lastRebuild.IsZero() {
+		status["last_successful_rebuild"] = h.lastRebuild
+	}
+	if !h.lastAttempt.IsZero() {
+		status["last_attempt"] = h.lastAttempt
+	}
+	if h.lastRebuildError != "" {
+		status["last_rebuild_error"] = h.lastRebuildError
+	}
+	if h.lastReloadError != "" {
+		status["last_reload_error"] = h.lastReloadError
+		status["last_reload_error_at"] = h.lastReloadErrAt
+	}
+	return status
+}
+
+// Resync forces a full rebuild and reload on the next debounce tick, even if
+// no watched resource changed.
+func (c *Controller) Resync() {
+	c.resync.Store(true)
+	c.TriggerReload()
+}
+
+// healthHandlers returns the handlers added to the metrics server.
+func (c *Controller) healthHandlers() map[string]http.Handler {
+	return map[string]http.Handler{
+		"/status": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(c.Status())
+		}),
+		"/resync": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			c.Resync()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]string{"status": "resync scheduled"})
+		}),
+	}
+}
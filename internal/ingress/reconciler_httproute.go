@@ -62,12 +62,8 @@ func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req reconcile.Reque
 
 	// Update status for each parentRef
 	if r.controller.IsLeader() {
-		for _, ref := range hr.Spec.ParentRefs {
-			if err := r.controller.StatusUpdater().UpdateHTTPRouteStatus(
-				ctx, &hr, ref, true, "Accepted", "HTTPRoute accepted",
-			); err != nil {
-				log.Error(err, "Failed to update HTTPRoute status")
-			}
+		if err := r.controller.updateHTTPRouteStatus(ctx, &hr); err != nil {
+			log.Error(err, "Failed to update HTTPRoute status")
 		}
 	}
 
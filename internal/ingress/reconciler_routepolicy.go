@@ -0,0 +1,76 @@
+package ingress
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// RoutePolicyReconciler watches RoutePolicy resources referenced by HTTPRoute
+// ExtensionRef filters.
+type RoutePolicyReconciler struct {
+	client     client.Client
+	store      *Store
+	controller *Controller
+}
+
+// NewRoutePolicyReconciler registers the RoutePolicy reconciler with the manager.
+func NewRoutePolicyReconciler(mgr ctrl.Manager, store *Store, controller *Controller) error {
+	r := &RoutePolicyReconciler{
+		client:     mgr.GetClient(),
+		store:      store,
+		controller: controller,
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(newRoutePolicy()).
+		Complete(r)
+}
+
+func (r *RoutePolicyReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithValues("routepolicy", req.NamespacedName)
+
+	rp := newRoutePolicy()
+	if err := r.client.Get(ctx, req.NamespacedName, rp); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return reconcile.Result{}, err
+		}
+		log.V(1).Info("RoutePolicy deleted")
+		r.store.DeleteRoutePolicy(req.NamespacedName)
+	} else {
+		log.V(1).Info("RoutePolicy updated")
+		r.store.SetRoutePolicy(rp)
+	}
+	r.controller.TriggerReload()
+
+	// Referencing HTTPRoutes may have become accepted or rejected.
+	if r.controller.IsLeader() {
+		for _, hr := range r.store.ListHTTPRoutes() {
+			if hr.Namespace != req.Namespace || !referencesRoutePolicy(hr, req.Name) {
+				continue
+			}
+			if err := r.controller.updateHTTPRouteStatus(ctx, hr.DeepCopy()); err != nil {
+				log.Error(err, "Failed to update HTTPRoute status", "httproute", hr.Name)
+			}
+		}
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// referencesRoutePolicy reports whether any rule of hr has an ExtensionRef
+// filter naming the RoutePolicy.
+func referencesRoutePolicy(hr *gatewayv1.HTTPRoute, name string) bool {
+	for _, rule := range hr.Spec.Rules {
+		for _, f := range rule.Filters {
+			ref := f.ExtensionRef
+			if f.Type == gatewayv1.HTTPRouteFilterExtensionRef && ref != nil &&
+				string(ref.Group) == RoutePolicyGVK.Group && string(ref.Kind) == RoutePolicyGVK.Kind && string(ref.Name) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
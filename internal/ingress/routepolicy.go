@@ -0,0 +1,69 @@
+package ingress
+
+import (
+	"fmt"
+
+	"github.com/goccy/go-yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/wudi/runway/config"
+)
+
+// RoutePolicyGVK identifies the RoutePolicy CRD, referenced from HTTPRoute
+// rules by ExtensionRef filters.
+var RoutePolicyGVK = schema.GroupVersionKind{
+	Group:   "runway.wudi.io",
+	Version: "v1alpha1",
+	Kind:    "RoutePolicy",
+}
+
+// RoutePolicySpec is the whitelisted subset of RouteConfig a RoutePolicy
+// may set. Fields use the same names as the runway config file.
+type RoutePolicySpec struct {
+	RateLimit *config.RateLimitConfig `yaml:"rate_limit"`
+	Cache     *config.CacheConfig     `yaml:"cache"`
+	CORS      *config.CORSConfig      `yaml:"cors"`
+	WAF       *config.WAFConfig       `yaml:"waf"`
+}
+
+// newRoutePolicy returns an empty RoutePolicy object for watching and fetching.
+func newRoutePolicy() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(RoutePolicyGVK)
+	return u
+}
+
+// parseRoutePolicySpec decodes a RoutePolicy's spec. Fields outside the
+// whitelist are rejected rather than ignored.
+func parseRoutePolicySpec(u *unstructured.Unstructured) (*RoutePolicySpec, error) {
+	spec, _, err := unstructured.NestedMap(u.Object, "spec")
+	if err != nil {
+		return nil, err
+	}
+	data, err := yaml.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	var ps RoutePolicySpec
+	if err := yaml.UnmarshalWithOptions(data, &ps, yaml.DisallowUnknownField()); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	return &ps, nil
+}
+
+// apply merges the policy into rc. Set sections replace the route's.
+func (ps *RoutePolicySpec) apply(rc *config.RouteConfig) {
+	if ps.RateLimit != nil {
+		rc.RateLimit = *ps.RateLimit
+	}
+	if ps.Cache != nil {
+		rc.Cache = *ps.Cache
+	}
+	if ps.CORS != nil {
+		rc.CORS = *ps.CORS
+	}
+	if ps.WAF != nil {
+		rc.WAF = *ps.WAF
+	}
+}
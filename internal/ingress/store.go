@@ -7,6 +7,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 )
@@ -24,6 +25,7 @@ type Store struct {
 	endpointSlices map[types.NamespacedName]*discoveryv1.EndpointSlice
 	secrets        map[types.NamespacedName]*corev1.Secret
 	services       map[types.NamespacedName]*corev1.Service
+	routePolicies  map[types.NamespacedName]*unstructured.Unstructured
 
 	generation atomic.Int64
 }
@@ -38,6 +40,7 @@ func NewStore() *Store {
 		endpointSlices: make(map[types.NamespacedName]*discoveryv1.EndpointSlice),
 		secrets:        make(map[types.NamespacedName]*corev1.Secret),
 		services:       make(map[types.NamespacedName]*corev1.Service),
+		routePolicies:  make(map[types.NamespacedName]*unstructured.Unstructured),
 	}
 }
 
@@ -226,6 +229,29 @@ func (s *Store) GetService(key types.NamespacedName) (*corev1.Service, bool) {
 	return svc, ok
 }
 
+// --- RoutePolicies ---
+
+func (s *Store) SetRoutePolicy(rp *unstructured.Unstructured) {
+	s.mu.Lock()
+	s.routePolicies[keyOf(rp.GetNamespace(), rp.GetName())] = rp
+	s.generation.Add(1)
+	s.mu.Unlock()
+}
+
+func (s *Store) DeleteRoutePolicy(key types.NamespacedName) {
+	s.mu.Lock()
+	delete(s.routePolicies, key)
+	s.generation.Add(1)
+	s.mu.Unlock()
+}
+
+func (s *Store) GetRoutePolicy(key types.NamespacedName) (*unstructured.Unstructured, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rp, ok := s.routePolicies[key]
+	return rp, ok
+}
+
 func keyOf(namespace, name string) types.NamespacedName {
 	return types.NamespacedName{Namespace: namespace, Name: name}
 }
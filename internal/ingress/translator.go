@@ -16,6 +16,20 @@ type Translator struct {
 	watchWithoutClass bool           // claim unclassed Ingress resources
 	defaultHTTPPort   int            // default HTTP listener port
 	defaultHTTPSPort  int            // default HTTPS listener port
+	routePolicy       bool           // resolve ExtensionRef filters to RoutePolicies
+
+	rejections []Rejection // resources left out of the last Translate
+}
+
+// Rejection describes a resource that was left out of the translated config.
+// Reason is a short machine-readable code (the Gateway API condition reason
+// for HTTPRoutes).
+type Rejection struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+	Message   string `json:"message"`
 }
 
 // TranslatorConfig holds configuration for the Translator.
@@ -25,6 +39,7 @@ type TranslatorConfig struct {
 	WatchWithoutClass bool
 	DefaultHTTPPort   int
 	DefaultHTTPSPort  int
+	EnableRoutePolicy bool
 }
 
 // NewTranslator creates a new Translator.
@@ -45,6 +60,7 @@ func NewTranslator(store *Store, baseCfg *config.Config, tc TranslatorConfig) *T
 		watchWithoutClass: tc.WatchWithoutClass,
 		defaultHTTPPort:   httpPort,
 		defaultHTTPSPort:  httpsPort,
+		routePolicy:       tc.EnableRoutePolicy,
 	}
 }
 
@@ -53,6 +69,7 @@ func NewTranslator(store *Store, baseCfg *config.Config, tc TranslatorConfig) *T
 func (t *Translator) Translate() (*config.Config, []string) {
 	cfg := t.cloneBase()
 	var warnings []string
+	t.rejections = nil
 
 	// Translate Ingress resources
 	ingRoutes, ingListeners, ingWarnings := t.translateIngresses()
@@ -129,6 +146,19 @@ func (t *Translator) Translate() (*config.Config, []string) {
 	return cfg, warnings
 }
 
+// Rejections returns the resources left out of the config by the last
+// Translate, in translation order.
+func (t *Translator) Rejections() []Rejection {
+	return t.rejections
+}
+
+// reject records a resource left out of the config and returns the matching
+// warning.
+func (t *Translator) reject(kind, namespace, name, reason, msg string) string {
+	t.rejections = append(t.rejections, Rejection{Kind: kind, Namespace: namespace, Name: name, Reason: reason, Message: msg})
+	return fmt.Sprintf("%s %s/%s not accepted: %s", kind, namespace, name, msg)
+}
+
 // cloneBase creates a shallow copy of the base config (or a default if nil).
 func (t *Translator) cloneBase() *config.Config {
 	if t.baseConfig == nil {
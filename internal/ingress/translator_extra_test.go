@@ -2,12 +2,16 @@ package ingress
 
 import (
 	"errors"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 
@@ -306,8 +310,8 @@ func TestApplyAnnotationsAllBranches(t *testing.T) {
 		if rc.TimeoutPolicy.Request.String() != "5s" {
 			t.Errorf("expected timeout 5s, got %v", rc.TimeoutPolicy.Request)
 		}
-		if rc.Retries != 2 {
-			t.Errorf("expected 2 retries, got %d", rc.Retries)
+		if rc.RetryPolicy.MaxRetries != 2 {
+			t.Errorf("expected 2 retries, got %d", rc.RetryPolicy.MaxRetries)
 		}
 		if !rc.CORS.Enabled {
 			t.Error("expected CORS enabled")
@@ -341,8 +345,8 @@ func TestApplyAnnotationsAllBranches(t *testing.T) {
 		if rc.RateLimit.Enabled {
 			t.Error("rate limit should not be enabled")
 		}
-		if rc.Retries != 0 {
-			t.Errorf("retries should be 0, got %d", rc.Retries)
+		if rc.RetryPolicy.MaxRetries != 0 {
+			t.Errorf("retries should be 0, got %d", rc.RetryPolicy.MaxRetries)
 		}
 	})
 }
@@ -473,7 +477,7 @@ func TestApplyHTTPRouteFilter(t *testing.T) {
 		rc := newEmptyRouteConfig("test-rhm")
 		var warnings []string
 
-		tr.applyHTTPRouteFilter(&rc, gatewayv1.HTTPRouteFilter{
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
 			Type: gatewayv1.HTTPRouteFilterRequestHeaderModifier,
 			RequestHeaderModifier: &gatewayv1.HTTPHeaderFilter{
 				Add: []gatewayv1.HTTPHeader{
@@ -504,7 +508,7 @@ func TestApplyHTTPRouteFilter(t *testing.T) {
 		rc := newEmptyRouteConfig("test-resp")
 		var warnings []string
 
-		tr.applyHTTPRouteFilter(&rc, gatewayv1.HTTPRouteFilter{
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
 			Type: gatewayv1.HTTPRouteFilterResponseHeaderModifier,
 			ResponseHeaderModifier: &gatewayv1.HTTPHeaderFilter{
 				Add: []gatewayv1.HTTPHeader{
@@ -536,7 +540,7 @@ func TestApplyHTTPRouteFilter(t *testing.T) {
 		var warnings []string
 
 		replacePrefixMatch := "/new-prefix"
-		tr.applyHTTPRouteFilter(&rc, gatewayv1.HTTPRouteFilter{
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
 			Type: gatewayv1.HTTPRouteFilterURLRewrite,
 			URLRewrite: &gatewayv1.HTTPURLRewriteFilter{
 				Path: &gatewayv1.HTTPPathModifier{
@@ -546,8 +550,11 @@ func TestApplyHTTPRouteFilter(t *testing.T) {
 			},
 		}, &warnings)
 
-		if !rc.StripPrefix {
-			t.Error("expected StripPrefix=true for URL rewrite with prefix replace")
+		if rc.Rewrite.Prefix != "/new-prefix" {
+			t.Errorf("expected rewrite prefix /new-prefix, got %q", rc.Rewrite.Prefix)
+		}
+		if rc.StripPrefix {
+			t.Error("StripPrefix should not be set with a rewrite prefix")
 		}
 	})
 
@@ -555,13 +562,13 @@ func TestApplyHTTPRouteFilter(t *testing.T) {
 		rc := newEmptyRouteConfig("test-rewrite-nil")
 		var warnings []string
 
-		tr.applyHTTPRouteFilter(&rc, gatewayv1.HTTPRouteFilter{
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
 			Type:       gatewayv1.HTTPRouteFilterURLRewrite,
 			URLRewrite: &gatewayv1.HTTPURLRewriteFilter{},
 		}, &warnings)
 
-		if rc.StripPrefix {
-			t.Error("StripPrefix should not be set when path is nil")
+		if rc.StripPrefix || rc.Rewrite != (config.RewriteConfig{}) {
+			t.Error("no rewrite should be set when path is nil")
 		}
 	})
 
@@ -570,7 +577,7 @@ func TestApplyHTTPRouteFilter(t *testing.T) {
 		var warnings []string
 
 		scheme := "https"
-		tr.applyHTTPRouteFilter(&rc, gatewayv1.HTTPRouteFilter{
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
 			Type: gatewayv1.HTTPRouteFilterRequestRedirect,
 			RequestRedirect: &gatewayv1.HTTPRequestRedirectFilter{
 				Scheme: &scheme,
@@ -586,8 +593,8 @@ func TestApplyHTTPRouteFilter(t *testing.T) {
 		rc := newEmptyRouteConfig("test-unsup")
 		var warnings []string
 
-		tr.applyHTTPRouteFilter(&rc, gatewayv1.HTTPRouteFilter{
-			Type: gatewayv1.HTTPRouteFilterRequestMirror,
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
+			Type: gatewayv1.HTTPRouteFilterCORS,
 		}, &warnings)
 
 		if len(warnings) != 1 {
@@ -599,7 +606,7 @@ func TestApplyHTTPRouteFilter(t *testing.T) {
 		rc := newEmptyRouteConfig("test-nil-rhm")
 		var warnings []string
 
-		tr.applyHTTPRouteFilter(&rc, gatewayv1.HTTPRouteFilter{
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
 			Type:                  gatewayv1.HTTPRouteFilterRequestHeaderModifier,
 			RequestHeaderModifier: nil,
 		}, &warnings)
@@ -613,7 +620,7 @@ func TestApplyHTTPRouteFilter(t *testing.T) {
 		rc := newEmptyRouteConfig("test-nil-resp")
 		var warnings []string
 
-		tr.applyHTTPRouteFilter(&rc, gatewayv1.HTTPRouteFilter{
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
 			Type:                   gatewayv1.HTTPRouteFilterResponseHeaderModifier,
 			ResponseHeaderModifier: nil,
 		}, &warnings)
@@ -627,7 +634,7 @@ func TestApplyHTTPRouteFilter(t *testing.T) {
 		rc := newEmptyRouteConfig("test-nil-redir")
 		var warnings []string
 
-		tr.applyHTTPRouteFilter(&rc, gatewayv1.HTTPRouteFilter{
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
 			Type:            gatewayv1.HTTPRouteFilterRequestRedirect,
 			RequestRedirect: nil,
 		}, &warnings)
@@ -2196,6 +2203,324 @@ func TestNewTranslatorCustomPorts(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// RequestMirror, URLRewrite and ExtensionRef filters
+// ---------------------------------------------------------------------------
+
+func newRoutePolicyObject(namespace, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	rp := newRoutePolicy()
+	rp.SetNamespace(namespace)
+	rp.SetName(name)
+	rp.Object["spec"] = spec
+	return rp
+}
+
+func TestApplyHTTPRouteFilterMirrorRewritePolicy(t *testing.T) {
+	store := NewStore()
+	store.SetService(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "shadow", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: "shadow.example.com"},
+	})
+	store.SetRoutePolicy(newRoutePolicyObject("default", "strict", map[string]interface{}{
+		"rate_limit": map[string]interface{}{"enabled": true, "rate": int64(10), "period": "1s"},
+		"cors":       map[string]interface{}{"enabled": true, "allow_origins": []interface{}{"https://app.example.com"}},
+	}))
+	tr := NewTranslator(store, nil, TranslatorConfig{
+		IngressClass:      "runway",
+		ControllerName:    "runway.wudi.io/ingress-controller",
+		EnableRoutePolicy: true,
+	})
+	port := gatewayv1.PortNumber(9000)
+
+	t.Run("request mirror", func(t *testing.T) {
+		rc := newEmptyRouteConfig("test-mirror")
+		var warnings []string
+		pct := int32(25)
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
+			Type: gatewayv1.HTTPRouteFilterRequestMirror,
+			RequestMirror: &gatewayv1.HTTPRequestMirrorFilter{
+				BackendRef: gatewayv1.BackendObjectReference{Name: "shadow", Port: &port},
+				Percent:    &pct,
+			},
+		}, &warnings)
+
+		if len(warnings) != 0 {
+			t.Errorf("unexpected warnings: %v", warnings)
+		}
+		if !rc.Mirror.Enabled || rc.Mirror.Percentage != 25 {
+			t.Errorf("expected enabled mirror at 25%%, got %+v", rc.Mirror)
+		}
+		if len(rc.Mirror.Backends) != 1 || rc.Mirror.Backends[0].URL != "http://shadow.example.com:9000" {
+			t.Errorf("unexpected mirror backends: %v", rc.Mirror.Backends)
+		}
+	})
+
+	t.Run("request mirror fraction", func(t *testing.T) {
+		den := int32(1000)
+		got := mirrorPercentage(&gatewayv1.HTTPRequestMirrorFilter{Fraction: &gatewayv1.Fraction{Numerator: 500, Denominator: &den}})
+		if got != 50 {
+			t.Errorf("expected 50, got %d", got)
+		}
+		if got := mirrorPercentage(&gatewayv1.HTTPRequestMirrorFilter{}); got != 100 {
+			t.Errorf("expected 100 by default, got %d", got)
+		}
+	})
+
+	t.Run("request mirror at zero percent", func(t *testing.T) {
+		rc := newEmptyRouteConfig("test-mirror-zero")
+		var warnings []string
+		zero := int32(0)
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
+			Type: gatewayv1.HTTPRouteFilterRequestMirror,
+			RequestMirror: &gatewayv1.HTTPRequestMirrorFilter{
+				BackendRef: gatewayv1.BackendObjectReference{Name: "shadow", Port: &port},
+				Percent:    &zero,
+			},
+		}, &warnings)
+
+		if rc.Mirror.Enabled {
+			t.Error("mirror should not be enabled at 0%")
+		}
+	})
+
+	t.Run("request mirror with unsupported kind", func(t *testing.T) {
+		rc := newEmptyRouteConfig("test-mirror-kind")
+		var warnings []string
+		kind := gatewayv1.Kind("Bucket")
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
+			Type: gatewayv1.HTTPRouteFilterRequestMirror,
+			RequestMirror: &gatewayv1.HTTPRequestMirrorFilter{
+				BackendRef: gatewayv1.BackendObjectReference{Kind: &kind, Name: "shadow"},
+			},
+		}, &warnings)
+
+		if rc.Mirror.Enabled {
+			t.Error("mirror should not be enabled without backends")
+		}
+		if len(warnings) != 2 {
+			t.Errorf("expected 2 warnings, got %v", warnings)
+		}
+	})
+
+	t.Run("URL rewrite full path and hostname", func(t *testing.T) {
+		rc := newEmptyRouteConfig("test-full")
+		var warnings []string
+		full := "/v2/$id"
+		host := gatewayv1.PreciseHostname("internal.example.com")
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
+			Type: gatewayv1.HTTPRouteFilterURLRewrite,
+			URLRewrite: &gatewayv1.HTTPURLRewriteFilter{
+				Hostname: &host,
+				Path: &gatewayv1.HTTPPathModifier{
+					Type:            gatewayv1.FullPathHTTPPathModifier,
+					ReplaceFullPath: &full,
+				},
+			},
+		}, &warnings)
+
+		if rc.Rewrite.Host != "internal.example.com" {
+			t.Errorf("expected rewrite host, got %q", rc.Rewrite.Host)
+		}
+		re := regexp.MustCompile(rc.Rewrite.Regex)
+		if got := re.ReplaceAllString("/v1/users/42", rc.Rewrite.Replacement); got != "/v2/$id" {
+			t.Errorf("expected /v2/$id, got %q", got)
+		}
+	})
+
+	t.Run("extension ref to RoutePolicy", func(t *testing.T) {
+		rc := newEmptyRouteConfig("test-policy")
+		rc.Cache.Enabled = true
+		var warnings []string
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
+			Type:         gatewayv1.HTTPRouteFilterExtensionRef,
+			ExtensionRef: &gatewayv1.LocalObjectReference{Group: "runway.wudi.io", Kind: "RoutePolicy", Name: "strict"},
+		}, &warnings)
+
+		if len(warnings) != 0 {
+			t.Errorf("unexpected warnings: %v", warnings)
+		}
+		if !rc.RateLimit.Enabled || rc.RateLimit.Rate != 10 || rc.RateLimit.Period != time.Second {
+			t.Errorf("rate limit not merged: %+v", rc.RateLimit)
+		}
+		if !rc.CORS.Enabled || len(rc.CORS.AllowOrigins) != 1 {
+			t.Errorf("cors not merged: %+v", rc.CORS)
+		}
+		if !rc.Cache.Enabled {
+			t.Error("sections absent from the policy should be kept")
+		}
+	})
+
+	t.Run("extension ref to missing RoutePolicy", func(t *testing.T) {
+		rc := newEmptyRouteConfig("test-policy-missing")
+		var warnings []string
+		tr.applyHTTPRouteFilter(&rc, "default", gatewayv1.HTTPRouteFilter{
+			Type:         gatewayv1.HTTPRouteFilterExtensionRef,
+			ExtensionRef: &gatewayv1.LocalObjectReference{Group: "runway.wudi.io", Kind: "RoutePolicy", Name: "absent"},
+		}, &warnings)
+
+		if len(warnings) != 1 {
+			t.Errorf("expected 1 warning, got %v", warnings)
+		}
+	})
+}
+
+func TestCheckHTTPRouteFilters(t *testing.T) {
+	store := NewStore()
+	store.SetRoutePolicy(newRoutePolicyObject("default", "ok", map[string]interface{}{
+		"waf": map[string]interface{}{"enabled": true},
+	}))
+	store.SetRoutePolicy(newRoutePolicyObject("default", "bad", map[string]interface{}{
+		"timeout": "5s",
+	}))
+	exact := gatewayv1.PathMatchExact
+	replace := "/"
+
+	policyRef := func(name string) gatewayv1.HTTPRouteFilter {
+		return gatewayv1.HTTPRouteFilter{
+			Type:         gatewayv1.HTTPRouteFilterExtensionRef,
+			ExtensionRef: &gatewayv1.LocalObjectReference{Group: "runway.wudi.io", Kind: "RoutePolicy", Name: gatewayv1.ObjectName(name)},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		rule        gatewayv1.HTTPRouteRule
+		routePolicy bool
+		reason      gatewayv1.RouteConditionReason
+	}{
+		{
+			name: "header modifier accepted",
+			rule: gatewayv1.HTTPRouteRule{Filters: []gatewayv1.HTTPRouteFilter{{Type: gatewayv1.HTTPRouteFilterRequestHeaderModifier}}},
+		},
+		{
+			name:        "RoutePolicy accepted",
+			rule:        gatewayv1.HTTPRouteRule{Filters: []gatewayv1.HTTPRouteFilter{policyRef("ok")}},
+			routePolicy: true,
+		},
+		{
+			name:        "missing RoutePolicy",
+			rule:        gatewayv1.HTTPRouteRule{Filters: []gatewayv1.HTTPRouteFilter{policyRef("absent")}},
+			routePolicy: true,
+			reason:      RouteReasonRoutePolicyNotFound,
+		},
+		{
+			name:        "RoutePolicy with non-whitelisted field",
+			rule:        gatewayv1.HTTPRouteRule{Filters: []gatewayv1.HTTPRouteFilter{policyRef("bad")}},
+			routePolicy: true,
+			reason:      RouteReasonInvalidRoutePolicy,
+		},
+		{
+			name:   "RoutePolicy support disabled",
+			rule:   gatewayv1.HTTPRouteRule{Filters: []gatewayv1.HTTPRouteFilter{policyRef("ok")}},
+			reason: gatewayv1.RouteReasonUnsupportedValue,
+		},
+		{
+			name: "unknown extension kind",
+			rule: gatewayv1.HTTPRouteRule{Filters: []gatewayv1.HTTPRouteFilter{{
+				Type:         gatewayv1.HTTPRouteFilterExtensionRef,
+				ExtensionRef: &gatewayv1.LocalObjectReference{Group: "example.com", Kind: "Widget", Name: "w"},
+			}}},
+			routePolicy: true,
+			reason:      gatewayv1.RouteReasonUnsupportedValue,
+		},
+		{
+			name:   "unsupported filter type",
+			rule:   gatewayv1.HTTPRouteRule{Filters: []gatewayv1.HTTPRouteFilter{{Type: gatewayv1.HTTPRouteFilterExternalAuth}}},
+			reason: gatewayv1.RouteReasonUnsupportedValue,
+		},
+		{
+			name: "prefix rewrite on exact match",
+			rule: gatewayv1.HTTPRouteRule{
+				Matches: []gatewayv1.HTTPRouteMatch{{Path: &gatewayv1.HTTPPathMatch{Type: &exact}}},
+				Filters: []gatewayv1.HTTPRouteFilter{{
+					Type: gatewayv1.HTTPRouteFilterURLRewrite,
+					URLRewrite: &gatewayv1.HTTPURLRewriteFilter{Path: &gatewayv1.HTTPPathModifier{
+						Type:               gatewayv1.PrefixMatchHTTPPathModifier,
+						ReplacePrefixMatch: &replace,
+					}},
+				}},
+			},
+			reason: gatewayv1.RouteReasonIncompatibleFilters,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hr := &gatewayv1.HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{Name: "r", Namespace: "default"},
+				Spec:       gatewayv1.HTTPRouteSpec{Rules: []gatewayv1.HTTPRouteRule{tt.rule}},
+			}
+			ferr := checkHTTPRouteFilters(store, hr, tt.routePolicy)
+			if tt.reason == "" {
+				if ferr != nil {
+					t.Fatalf("expected accepted, got %s: %s", ferr.Reason, ferr.Message)
+				}
+				return
+			}
+			if ferr == nil {
+				t.Fatalf("expected reason %s, got accepted", tt.reason)
+			}
+			if ferr.Reason != tt.reason {
+				t.Errorf("expected reason %s, got %s (%s)", tt.reason, ferr.Reason, ferr.Message)
+			}
+			if !strings.HasPrefix(ferr.Message, "rule 0 filter 0: ") {
+				t.Errorf("expected message to locate the filter, got %q", ferr.Message)
+			}
+		})
+	}
+}
+
+func TestTranslateHTTPRouteRejectedFilter(t *testing.T) {
+	store := NewStore()
+	controllerName := gatewayv1.GatewayController("runway.wudi.io/ingress-controller")
+	store.SetGatewayClass(&gatewayv1.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "runway"},
+		Spec:       gatewayv1.GatewayClassSpec{ControllerName: controllerName},
+	})
+	store.SetGateway(&gatewayv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default"},
+		Spec: gatewayv1.GatewaySpec{
+			GatewayClassName: "runway",
+			Listeners:        []gatewayv1.Listener{{Name: "http", Port: 8080, Protocol: gatewayv1.HTTPProtocolType}},
+		},
+	})
+	store.SetHTTPRoute(&gatewayv1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-route", Namespace: "default"},
+		Spec: gatewayv1.HTTPRouteSpec{
+			CommonRouteSpec: gatewayv1.CommonRouteSpec{
+				ParentRefs: []gatewayv1.ParentReference{{Name: "gw"}},
+			},
+			Rules: []gatewayv1.HTTPRouteRule{{
+				Filters: []gatewayv1.HTTPRouteFilter{{
+					Type:         gatewayv1.HTTPRouteFilterExtensionRef,
+					ExtensionRef: &gatewayv1.LocalObjectReference{Group: "runway.wudi.io", Kind: "RoutePolicy", Name: "absent"},
+				}},
+			}},
+		},
+	})
+
+	tr := NewTranslator(store, nil, TranslatorConfig{
+		IngressClass:      "runway",
+		ControllerName:    "runway.wudi.io/ingress-controller",
+		EnableRoutePolicy: true,
+	})
+	cfg, warnings := tr.Translate()
+	for _, r := range cfg.Routes {
+		if strings.HasPrefix(r.ID, "hr-default-policy-route") {
+			t.Errorf("rejected HTTPRoute should not produce routes, got %s", r.ID)
+		}
+	}
+	var found bool
+	for _, w := range warnings {
+		if strings.Contains(w, "RoutePolicy default/absent not found") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected rejection warning, got %v", warnings)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // helpers
 // ---------------------------------------------------------------------------
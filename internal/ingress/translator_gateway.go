@@ -130,6 +130,10 @@ func (t *Translator) httpRouteToRoutes(hr *gatewayv1.HTTPRoute) ([]config.RouteC
 	var routes []config.RouteConfig
 	var warnings []string
 
+	if ferr := checkHTTPRoute(t.store, hr, t.routePolicy); ferr != nil {
+		return nil, []string{t.reject("HTTPRoute", hr.Namespace, hr.Name, string(ferr.Reason), ferr.Message)}
+	}
+
 	// Collect hostnames from the route
 	var hostnames []string
 	for _, h := range hr.Spec.Hostnames {
@@ -198,9 +202,9 @@ func (t *Translator) httpRouteToRoutes(hr *gatewayv1.HTTPRoute) ([]config.RouteC
 				rc.Methods = []string{string(*match.Method)}
 			}
 
-			// Filters (request/response header modification, redirect, URL rewrite)
+			// Filters (header modification, URL rewrite, mirror, RoutePolicy)
 			for _, f := range rule.Filters {
-				t.applyHTTPRouteFilter(&rc, f, &warnings)
+				t.applyHTTPRouteFilter(&rc, hr.Namespace, f, &warnings)
 			}
 
 			routes = append(routes, rc)
@@ -212,6 +216,20 @@ func (t *Translator) httpRouteToRoutes(hr *gatewayv1.HTTPRoute) ([]config.RouteC
 
 // resolveHTTPBackendRef resolves a Gateway API BackendRef to BackendConfigs.
 func (t *Translator) resolveHTTPBackendRef(namespace string, ref gatewayv1.HTTPBackendRef) ([]config.BackendConfig, []string) {
+	backends, warnings := t.resolveBackendObjectRef(namespace, ref.BackendObjectReference)
+
+	weight := 1
+	if ref.Weight != nil {
+		weight = int(*ref.Weight)
+	}
+	for i := range backends {
+		backends[i].Weight = weight
+	}
+	return backends, warnings
+}
+
+// resolveBackendObjectRef resolves a Service reference to BackendConfigs.
+func (t *Translator) resolveBackendObjectRef(namespace string, ref gatewayv1.BackendObjectReference) ([]config.BackendConfig, []string) {
 	var warnings []string
 
 	// Only support Service kind
@@ -234,27 +252,138 @@ func (t *Translator) resolveHTTPBackendRef(namespace string, ref gatewayv1.HTTPB
 		port = 80
 	}
 
-	weight := 1
-	if ref.Weight != nil {
-		weight = int(*ref.Weight)
-	}
-
 	// Check for ExternalName service
 	svcKey := keyOf(ns, svcName)
 	if svc, ok := t.store.GetService(svcKey); ok && svc.Spec.Type == "ExternalName" {
 		url := fmt.Sprintf("http://%s:%d", svc.Spec.ExternalName, port)
-		return []config.BackendConfig{{URL: url, Weight: weight}}, nil
+		return []config.BackendConfig{{URL: url, Weight: 1}}, nil
 	}
 
-	backends := t.resolveEndpointSliceBackends(ns, svcName, port)
-	for i := range backends {
-		backends[i].Weight = weight
+	return t.resolveEndpointSliceBackends(ns, svcName, port), warnings
+}
+
+// mirrorPercentage converts a RequestMirror filter's percent or fraction to
+// a whole percentage. Unset means every request is mirrored.
+func mirrorPercentage(m *gatewayv1.HTTPRequestMirrorFilter) int {
+	switch {
+	case m.Percent != nil:
+		return int(*m.Percent)
+	case m.Fraction != nil:
+		den := int32(100)
+		if m.Fraction.Denominator != nil && *m.Fraction.Denominator > 0 {
+			den = *m.Fraction.Denominator
+		}
+		return int(m.Fraction.Numerator * 100 / den)
 	}
-	return backends, warnings
+	return 100
+}
+
+// Accepted condition reasons for ExtensionRef filters, alongside the
+// Gateway API's UnsupportedValue and IncompatibleFilters.
+const (
+	RouteReasonRoutePolicyNotFound gatewayv1.RouteConditionReason = "RoutePolicyNotFound"
+	RouteReasonInvalidRoutePolicy  gatewayv1.RouteConditionReason = "InvalidRoutePolicy"
+)
+
+// filterError describes an HTTPRoute filter that cannot be translated. It
+// becomes the reason and message of the route's Accepted=False condition.
+type filterError struct {
+	Reason  gatewayv1.RouteConditionReason
+	Message string
+}
+
+func (e *filterError) Error() string {
+	return e.Message
+}
+
+// checkHTTPRoute returns the first filter or backendRef of hr that cannot be
+// translated, or nil.
+func checkHTTPRoute(store *Store, hr *gatewayv1.HTTPRoute, routePolicy bool) *filterError {
+	if ferr := checkHTTPRouteFilters(store, hr, routePolicy); ferr != nil {
+		return ferr
+	}
+	return checkHTTPRouteBackendRefs(hr)
+}
+
+// checkHTTPRouteBackendRefs returns the first backendRef of hr that is not a
+// Service, or nil. Like filters, one bad reference rejects the whole route so
+// a rule is never served by a subset of its backends.
+func checkHTTPRouteBackendRefs(hr *gatewayv1.HTTPRoute) *filterError {
+	for i, rule := range hr.Spec.Rules {
+		for j, ref := range rule.BackendRefs {
+			if ref.Group != nil && *ref.Group != "" {
+				return &filterError{gatewayv1.RouteReasonInvalidKind, fmt.Sprintf("rule %d backendRef %d: group %s is not supported", i, j, *ref.Group)}
+			}
+			if ref.Kind != nil && *ref.Kind != "Service" {
+				return &filterError{gatewayv1.RouteReasonInvalidKind, fmt.Sprintf("rule %d backendRef %d: kind %s is not supported", i, j, *ref.Kind)}
+			}
+		}
+	}
+	return nil
+}
+
+// checkHTTPRouteFilters returns the first filter of hr that cannot be
+// translated, or nil. Such routes are rejected as a whole rather than served
+// without the filter. routePolicy reports whether RoutePolicies are watched.
+func checkHTTPRouteFilters(store *Store, hr *gatewayv1.HTTPRoute, routePolicy bool) *filterError {
+	for i, rule := range hr.Spec.Rules {
+		for j, f := range rule.Filters {
+			if ferr := checkHTTPRouteFilter(store, hr.Namespace, rule, f, routePolicy); ferr != nil {
+				ferr.Message = fmt.Sprintf("rule %d filter %d: %s", i, j, ferr.Message)
+				return ferr
+			}
+		}
+	}
+	return nil
+}
+
+func checkHTTPRouteFilter(store *Store, namespace string, rule gatewayv1.HTTPRouteRule, f gatewayv1.HTTPRouteFilter, routePolicy bool) *filterError {
+	switch f.Type {
+	case gatewayv1.HTTPRouteFilterRequestHeaderModifier, gatewayv1.HTTPRouteFilterResponseHeaderModifier:
+		return nil
+	case gatewayv1.HTTPRouteFilterURLRewrite:
+		if f.URLRewrite == nil || f.URLRewrite.Path == nil || f.URLRewrite.Path.Type != gatewayv1.PrefixMatchHTTPPathModifier {
+			return nil
+		}
+		for _, m := range rule.Matches {
+			if m.Path != nil && m.Path.Type != nil && *m.Path.Type != gatewayv1.PathMatchPathPrefix {
+				return &filterError{gatewayv1.RouteReasonIncompatibleFilters, "URLRewrite ReplacePrefixMatch requires PathPrefix matches"}
+			}
+		}
+		return nil
+	case gatewayv1.HTTPRouteFilterRequestMirror:
+		if f.RequestMirror != nil {
+			if k := f.RequestMirror.BackendRef.Kind; k != nil && *k != "Service" {
+				return &filterError{gatewayv1.RouteReasonUnsupportedValue, fmt.Sprintf("RequestMirror backendRef kind %s is not supported", *k)}
+			}
+		}
+		return nil
+	case gatewayv1.HTTPRouteFilterExtensionRef:
+		ref := f.ExtensionRef
+		if ref == nil {
+			return &filterError{gatewayv1.RouteReasonUnsupportedValue, "ExtensionRef filter has no extensionRef"}
+		}
+		if string(ref.Group) != RoutePolicyGVK.Group || string(ref.Kind) != RoutePolicyGVK.Kind {
+			return &filterError{gatewayv1.RouteReasonUnsupportedValue, fmt.Sprintf("ExtensionRef %s/%s is not supported, only %s/%s", ref.Group, ref.Kind, RoutePolicyGVK.Group, RoutePolicyGVK.Kind)}
+		}
+		if !routePolicy {
+			return &filterError{gatewayv1.RouteReasonUnsupportedValue, "RoutePolicy support is disabled (--enable-route-policy)"}
+		}
+		rp, ok := store.GetRoutePolicy(keyOf(namespace, string(ref.Name)))
+		if !ok {
+			return &filterError{RouteReasonRoutePolicyNotFound, fmt.Sprintf("RoutePolicy %s/%s not found", namespace, ref.Name)}
+		}
+		if _, err := parseRoutePolicySpec(rp); err != nil {
+			return &filterError{RouteReasonInvalidRoutePolicy, fmt.Sprintf("RoutePolicy %s/%s: %v", namespace, ref.Name, err)}
+		}
+		return nil
+	}
+	return &filterError{gatewayv1.RouteReasonUnsupportedValue, fmt.Sprintf("filter type %s is not supported", f.Type)}
 }
 
-// applyHTTPRouteFilter applies a Gateway API filter to a RouteConfig.
-func (t *Translator) applyHTTPRouteFilter(rc *config.RouteConfig, f gatewayv1.HTTPRouteFilter, warnings *[]string) {
+// applyHTTPRouteFilter applies a Gateway API filter to a RouteConfig. namespace
+// is the HTTPRoute's namespace, used to resolve mirror backends and RoutePolicies.
+func (t *Translator) applyHTTPRouteFilter(rc *config.RouteConfig, namespace string, f gatewayv1.HTTPRouteFilter, warnings *[]string) {
 	switch f.Type {
 	case gatewayv1.HTTPRouteFilterRequestRedirect:
 		if f.RequestRedirect != nil {
@@ -263,14 +392,66 @@ func (t *Translator) applyHTTPRouteFilter(rc *config.RouteConfig, f gatewayv1.HT
 			*warnings = append(*warnings, fmt.Sprintf("route %s: RequestRedirect filter requires manual rules configuration", rc.ID))
 		}
 	case gatewayv1.HTTPRouteFilterURLRewrite:
-		if f.URLRewrite != nil && f.URLRewrite.Path != nil {
-			switch f.URLRewrite.Path.Type {
+		if f.URLRewrite == nil {
+			return
+		}
+		if f.URLRewrite.Hostname != nil {
+			rc.Rewrite.Host = string(*f.URLRewrite.Hostname)
+		}
+		if p := f.URLRewrite.Path; p != nil {
+			switch p.Type {
 			case gatewayv1.PrefixMatchHTTPPathModifier:
-				if f.URLRewrite.Path.ReplacePrefixMatch != nil {
-					rc.StripPrefix = true
+				if p.ReplacePrefixMatch != nil {
+					rc.Rewrite.Prefix = *p.ReplacePrefixMatch
+					if rc.Rewrite.Prefix == "" {
+						rc.Rewrite.Prefix = "/"
+					}
+				}
+			case gatewayv1.FullPathHTTPPathModifier:
+				if p.ReplaceFullPath != nil {
+					full := *p.ReplaceFullPath
+					if full == "" {
+						full = "/"
+					}
+					rc.Rewrite.Regex = "^.*$"
+					rc.Rewrite.Replacement = strings.ReplaceAll(full, "$", "$$")
 				}
 			}
 		}
+	case gatewayv1.HTTPRouteFilterRequestMirror:
+		if f.RequestMirror == nil {
+			return
+		}
+		pct := mirrorPercentage(f.RequestMirror)
+		if pct == 0 {
+			return
+		}
+		backends, w := t.resolveBackendObjectRef(namespace, f.RequestMirror.BackendRef)
+		*warnings = append(*warnings, w...)
+		if len(backends) == 0 {
+			*warnings = append(*warnings, fmt.Sprintf("route %s: RequestMirror backend %s could not be resolved", rc.ID, f.RequestMirror.BackendRef.Name))
+			return
+		}
+		// Multiple mirror filters share one mirror: all backends receive
+		// copies, at the highest percentage.
+		rc.Mirror.Enabled = true
+		rc.Mirror.Backends = append(rc.Mirror.Backends, backends...)
+		rc.Mirror.Percentage = max(rc.Mirror.Percentage, pct)
+	case gatewayv1.HTTPRouteFilterExtensionRef:
+		if f.ExtensionRef == nil {
+			return
+		}
+		rp, ok := t.store.GetRoutePolicy(keyOf(namespace, string(f.ExtensionRef.Name)))
+		if !ok {
+			*warnings = append(*warnings, fmt.Sprintf("route %s: RoutePolicy %s/%s not found", rc.ID, namespace, f.ExtensionRef.Name))
+			return
+		}
+		ps, err := parseRoutePolicySpec(rp)
+		if err != nil {
+			*warnings = append(*warnings, fmt.Sprintf("route %s: RoutePolicy %s/%s: %v", rc.ID, namespace, f.ExtensionRef.Name, err))
+			return
+		}
+		ps.apply(rc)
 	case gatewayv1.HTTPRouteFilterRequestHeaderModifier:
 		if f.RequestHeaderModifier != nil {
 			if rc.Transform.Request.Headers.Add == nil {
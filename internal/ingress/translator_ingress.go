@@ -23,6 +23,10 @@ func (t *Translator) translateIngresses() ([]config.RouteConfig, []config.Listen
 		if !t.shouldProcessIngress(ing) {
 			continue
 		}
+		if msg := checkIngressBackends(ing); msg != "" {
+			warnings = append(warnings, t.reject("Ingress", ing.Namespace, ing.Name, IngressReasonInvalidBackend, msg))
+			continue
+		}
 		ann := NewAnnotationParser(ing.Annotations)
 
 		// Collect TLS entries
@@ -63,6 +67,30 @@ func (t *Translator) translateIngresses() ([]config.RouteConfig, []config.Listen
 	return routes, listeners, warnings
 }
 
+// IngressReasonInvalidBackend is the rejection reason for an Ingress with a
+// backend that does not reference a Service.
+const IngressReasonInvalidBackend = "InvalidBackend"
+
+// checkIngressBackends returns why ing cannot be translated, or "". Resource
+// backends are not supported, and an Ingress with one is rejected as a whole,
+// matching HTTPRoutes.
+func checkIngressBackends(ing *networkingv1.Ingress) string {
+	if b := ing.Spec.DefaultBackend; b != nil && b.Service == nil {
+		return "defaultBackend does not reference a Service"
+	}
+	for i, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for j, path := range rule.HTTP.Paths {
+			if path.Backend.Service == nil {
+				return fmt.Sprintf("rule %d path %d: backend does not reference a Service", i, j)
+			}
+		}
+	}
+	return ""
+}
+
 // shouldProcessIngress returns true if the Ingress matches our ingress class.
 func (t *Translator) shouldProcessIngress(ing *networkingv1.Ingress) bool {
 	// Check spec.ingressClassName
@@ -213,7 +241,7 @@ func (t *Translator) applyAnnotations(rc *config.RouteConfig, ann *AnnotationPar
 		rc.TimeoutPolicy.Request = ann.GetDuration(AnnTimeout, 0)
 	}
 	if ann.Has(AnnRetryMax) {
-		rc.Retries = ann.GetInt(AnnRetryMax, 0)
+		rc.RetryPolicy.MaxRetries = ann.GetInt(AnnRetryMax, 0)
 	}
 	if ann.Has(AnnCORSEnabled) {
 		rc.CORS.Enabled = ann.GetBool(AnnCORSEnabled, false)
@@ -108,8 +108,8 @@ func TestTranslateIngress(t *testing.T) {
 	if route.Backends[0].URL != "http://10.0.0.1:8080" {
 		t.Errorf("expected http://10.0.0.1:8080, got %s", route.Backends[0].URL)
 	}
-	if route.Retries != 3 {
-		t.Errorf("expected 3 retries, got %d", route.Retries)
+	if route.RetryPolicy.MaxRetries != 3 {
+		t.Errorf("expected 3 retries, got %d", route.RetryPolicy.MaxRetries)
 	}
 }
 
@@ -0,0 +1,127 @@
+// Package ipaddr normalizes client IP addresses and derives per-client keys
+// from them.
+//
+// IPv6 clients commonly rotate through the addresses of a delegated prefix
+// (often a /64 per host and a /56 per site), so keying limits and blocks by
+// the full address lets a single client appear as many. Keys therefore
+// group IPv6 addresses by network: a coarser prefix is harder to evade but
+// may put several subscribers of a provider behind one key, and it keeps
+// less of the address, which also suits privacy. IPv4 addresses are always
+// keyed individually.
+package ipaddr
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// DefaultIPv6Prefix is the IPv6 prefix length keys are aggregated to when
+// none is configured.
+const DefaultIPv6Prefix = 56
+
+// Parse parses a client address. It accepts bracketed IPv6 addresses and a
+// trailing port, as found in RemoteAddr and some X-Forwarded-For values.
+// Zone identifiers are dropped and IPv4-mapped IPv6 addresses are returned
+// as IPv4, so one client always yields the same address.
+func Parse(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		host := s
+		if h, _, err := net.SplitHostPort(s); err == nil {
+			host = h
+		} else if len(s) > 2 && s[0] == '[' && s[len(s)-1] == ']' {
+			host = s[1 : len(s)-1]
+		}
+		if addr, err = netip.ParseAddr(host); err != nil {
+			return netip.Addr{}, false
+		}
+	}
+	return addr.WithZone("").Unmap(), true
+}
+
+// Normalize returns the canonical form of the client address s, or s with
+// surrounding space removed if it is not an IP address.
+func Normalize(s string) string {
+	if addr, ok := Parse(s); ok {
+		return addr.String()
+	}
+	return strings.TrimSpace(s)
+}
+
+// ParsePrefix parses an IP address or CIDR. Single addresses become /32 or
+// /128 prefixes, and IPv4-mapped IPv6 prefixes become IPv4 prefixes so they
+// match IPv4 clients.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid IP %q", s)
+		}
+		addr = addr.WithZone("").Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", s)
+	}
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	return p.Masked(), nil
+}
+
+// KeyPrefix returns the network a client key stands for: the address
+// itself for IPv4, or its enclosing network of ipv6Bits for IPv6. A zero or
+// negative ipv6Bits selects DefaultIPv6Prefix; 128 disables aggregation.
+func KeyPrefix(addr netip.Addr, ipv6Bits int) netip.Prefix {
+	addr = addr.WithZone("").Unmap()
+	if addr.Is4() {
+		return netip.PrefixFrom(addr, 32)
+	}
+	p, _ := addr.Prefix(IPv6PrefixLen(ipv6Bits))
+	return p
+}
+
+// IPv6PrefixLen returns the IPv6 prefix length keys are aggregated to for a
+// configured value: DefaultIPv6Prefix for zero or negative values, capped
+// at 128.
+func IPv6PrefixLen(bits int) int {
+	if bits <= 0 {
+		return DefaultIPv6Prefix
+	}
+	return min(bits, 128)
+}
+
+// FormatKey formats a key prefix: single addresses as the bare address,
+// aggregated networks in CIDR notation.
+func FormatKey(p netip.Prefix) string {
+	if p.IsSingleIP() {
+		return p.Addr().String()
+	}
+	return p.String()
+}
+
+// Key returns the client key for the address s with IPv6 addresses
+// aggregated to ipv6Bits (see KeyPrefix). Values that are not IP addresses
+// are returned normalized as by Normalize.
+func Key(s string, ipv6Bits int) string {
+	addr, ok := Parse(s)
+	if !ok {
+		return strings.TrimSpace(s)
+	}
+	return FormatKey(KeyPrefix(addr, ipv6Bits))
+}
+
+// IsAggregated reports whether key is an aggregated IPv6 network as
+// returned by Key, rather than a single address or another kind of key.
+func IsAggregated(key string) bool {
+	p, err := netip.ParsePrefix(key)
+	return err == nil && p.Addr().Is6() && !p.IsSingleIP()
+}
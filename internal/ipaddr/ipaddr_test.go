@@ -0,0 +1,124 @@
+package ipaddr
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"1.2.3.4", "1.2.3.4"},
+		{" 1.2.3.4 ", "1.2.3.4"},
+		{"1.2.3.4:8080", "1.2.3.4"},
+		{"::ffff:1.2.3.4", "1.2.3.4"},
+		{"[::ffff:1.2.3.4]:443", "1.2.3.4"},
+		{"2001:DB8::1", "2001:db8::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{"fe80::1%eth0", "fe80::1"},
+		{"[fe80::1%25eth0]:80", "fe80::1"},
+		{"2001:0db8:0000:0000:0000:0000:0000:0001", "2001:db8::1"},
+		{"unknown", "unknown"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "10.0.0.1", want: "10.0.0.1/32"},
+		{in: "10.1.2.3/8", want: "10.0.0.0/8"},
+		{in: "::1", want: "::1/128"},
+		{in: "2001:db8::1/32", want: "2001:db8::/32"},
+		{in: "::ffff:10.0.0.0/104", want: "10.0.0.0/8"},
+		{in: "::ffff:10.0.0.1", want: "10.0.0.1/32"},
+		{in: "fe80::1%eth0", want: "fe80::1/128"},
+		{in: "10.0.0.0/33", wantErr: true},
+		{in: "bogus", wantErr: true},
+	}
+	for _, tt := range tests {
+		p, err := ParsePrefix(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePrefix(%q) = %v, want error", tt.in, p)
+			}
+			continue
+		}
+		if err != nil || p.String() != tt.want {
+			t.Errorf("ParsePrefix(%q) = %v, %v, want %s", tt.in, p, err, tt.want)
+		}
+	}
+
+	// A mapped prefix matches IPv4 clients.
+	p, _ := ParsePrefix("::ffff:192.168.0.0/112")
+	addr, _ := Parse("192.168.3.4")
+	if !p.Contains(addr) {
+		t.Errorf("%v should contain %v", p, addr)
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		bits int
+		want string
+	}{
+		{"1.2.3.4", 0, "1.2.3.4"},
+		{"::ffff:1.2.3.4", 56, "1.2.3.4"},
+		{"2001:db8:1234:5678::1", 0, "2001:db8:1234:5600::/56"},
+		{"2001:db8:1234:56ff:aaaa::1", 56, "2001:db8:1234:5600::/56"},
+		{"2001:db8:1234:5678::1", 64, "2001:db8:1234:5678::/64"},
+		{"2001:db8:1234:5678::1", 128, "2001:db8:1234:5678::1"},
+		{"[2001:db8::1]:443", 48, "2001:db8::/48"},
+		{"fe80::1%eth0", 128, "fe80::1"},
+		{"not-an-ip", 56, "not-an-ip"},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.in, tt.bits); got != tt.want {
+			t.Errorf("Key(%q, %d) = %q, want %q", tt.in, tt.bits, got, tt.want)
+		}
+	}
+
+	// Addresses rotating within the /56 share a key.
+	a := Key("2001:db8:1:100::1", 56)
+	b := Key("2001:db8:1:1ff:ffff::2", 56)
+	if a != b {
+		t.Errorf("keys differ within /56: %q, %q", a, b)
+	}
+}
+
+func TestIsAggregated(t *testing.T) {
+	tests := map[string]bool{
+		"2001:db8::/56":    true,
+		"2001:db8::1":      false,
+		"2001:db8::1/128":  false,
+		"10.0.0.0/8":       false,
+		"1.2.3.4":          false,
+		"header:X-Key:a/b": false,
+	}
+	for key, want := range tests {
+		if got := IsAggregated(key); got != want {
+			t.Errorf("IsAggregated(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestKeyPrefix(t *testing.T) {
+	p := KeyPrefix(netip.MustParseAddr("2001:db8::1"), 0)
+	if p.Bits() != DefaultIPv6Prefix {
+		t.Errorf("default bits = %d, want %d", p.Bits(), DefaultIPv6Prefix)
+	}
+	if p := KeyPrefix(netip.MustParseAddr("::ffff:1.2.3.4"), 56); p.String() != "1.2.3.4/32" {
+		t.Errorf("mapped prefix = %v", p)
+	}
+}
@@ -0,0 +1,405 @@
+package listener
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+// defaultCertReloadInterval is how often certificate files are polled when
+// tls.reload is enabled without an interval.
+const defaultCertReloadInterval = 30 * time.Second
+
+// CertStatus describes the manual TLS certificates a listener serves.
+type CertStatus struct {
+	Reload         bool       `json:"reload"`                    // certificate files are polled for changes
+	ReloadInterval string     `json:"reload_interval,omitempty"` // poll interval
+	LastReload     time.Time  `json:"last_reload"`               // when a certificate was last (re)loaded
+	LastError      string     `json:"last_error,omitempty"`      // the most recent failed reload
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+	NotAfter       time.Time  `json:"not_after"` // earliest expiry of the served certificates
+	Certificates   []CertInfo `json:"certificates"`
+}
+
+// CertInfo describes one served certificate.
+type CertInfo struct {
+	CertFile string    `json:"cert_file,omitempty"` // empty for in-memory certificates
+	KeyFile  string    `json:"key_file,omitempty"`
+	Hosts    []string  `json:"hosts,omitempty"`
+	Subject  string    `json:"subject,omitempty"`
+	DNSNames []string  `json:"dns_names,omitempty"`
+	Serial   string    `json:"serial,omitempty"`
+	NotAfter time.Time `json:"not_after"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// CertReloadError reports a certificate file that changed on disk but
+// could not be loaded. The previous certificate is still served.
+type CertReloadError struct {
+	ListenerID string
+	CertFile   string
+	KeyFile    string
+	Err        error
+}
+
+func (e *CertReloadError) Error() string {
+	return fmt.Sprintf("listener %s: reloading %s: %v", e.ListenerID, e.CertFile, e.Err)
+}
+
+func (e *CertReloadError) Unwrap() error { return e.Err }
+
+// fileStamp identifies a version of a file on disk.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stampOf returns the version of the file at path, or the zero stamp when
+// it cannot be stat'ed. It follows symlinks, as swapped by kubelet when a
+// mounted Secret changes.
+func stampOf(path string) fileStamp {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+}
+
+// certPair is one certificate and the files it was loaded from.
+type certPair struct {
+	certFile, keyFile string // empty for in-memory certificates, which never reload
+	hosts             []string
+	certStamp         fileStamp
+	keyStamp          fileStamp
+	cert              tls.Certificate
+	loadedAt          time.Time
+}
+
+// certSet is the set of manual TLS certificates of a listener. It is not
+// safe for concurrent use; HTTPListener serializes access.
+type certSet struct {
+	pairs      []*certPair
+	lastReload time.Time
+	lastErr    error
+	lastErrAt  time.Time
+}
+
+// newCertSet loads the certificates of tlsCfg: the top-level
+// cert_file/key_file first, if set, then each certificates entry.
+func newCertSet(tlsCfg config.TLSConfig) (*certSet, error) {
+	now := time.Now()
+	s := &certSet{lastReload: now}
+	if tlsCfg.CertFile != "" && tlsCfg.KeyFile != "" {
+		p := &certPair{certFile: tlsCfg.CertFile, keyFile: tlsCfg.KeyFile}
+		if err := p.load(now); err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		s.pairs = append(s.pairs, p)
+	}
+	for i, cp := range tlsCfg.Certificates {
+		p := &certPair{hosts: cp.Hosts}
+		var err error
+		if len(cp.CertData) > 0 && len(cp.KeyData) > 0 {
+			p.cert, err = tls.X509KeyPair(cp.CertData, cp.KeyData)
+			p.loadedAt = now
+		} else {
+			p.certFile, p.keyFile = cp.CertFile, cp.KeyFile
+			err = p.load(now)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate[%d]: %w", i, err)
+		}
+		s.pairs = append(s.pairs, p)
+	}
+	return s, nil
+}
+
+// load reads the pair's files. The stamps are taken before reading so a
+// write racing the read is picked up by the next poll.
+func (p *certPair) load(now time.Time) error {
+	p.certStamp, p.keyStamp = stampOf(p.certFile), stampOf(p.keyFile)
+	cert, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		return err
+	}
+	p.cert, p.loadedAt = cert, now
+	return nil
+}
+
+// changed reports whether the pair's files differ from those last read.
+func (p *certPair) changed() bool {
+	if p.certFile == "" {
+		return false
+	}
+	return stampOf(p.certFile) != p.certStamp || stampOf(p.keyFile) != p.keyStamp
+}
+
+// refresh reloads the pairs whose files changed and returns them. A pair
+// that fails to load keeps its previous certificate and is retried once its
+// files change again.
+func (s *certSet) refresh(now time.Time) (reloaded []*certPair, errs []*CertReloadError) {
+	for _, p := range s.pairs {
+		if !p.changed() {
+			continue
+		}
+		next := *p
+		if err := next.load(now); err != nil {
+			// Remember the failed version so it is reported once.
+			p.certStamp, p.keyStamp = next.certStamp, next.keyStamp
+			s.lastErr, s.lastErrAt = err, now
+			errs = append(errs, &CertReloadError{CertFile: p.certFile, KeyFile: p.keyFile, Err: err})
+			continue
+		}
+		*p = next
+		s.lastReload = now
+		reloaded = append(reloaded, p)
+	}
+	return reloaded, errs
+}
+
+// certificates returns the served certificates in configuration order.
+func (s *certSet) certificates() []tls.Certificate {
+	certs := make([]tls.Certificate, len(s.pairs))
+	for i, p := range s.pairs {
+		certs[i] = p.cert
+	}
+	return certs
+}
+
+func (s *certSet) status() CertStatus {
+	st := CertStatus{LastReload: s.lastReload, Certificates: make([]CertInfo, 0, len(s.pairs))}
+	if s.lastErr != nil {
+		at := s.lastErrAt
+		st.LastError, st.LastErrorAt = s.lastErr.Error(), &at
+	}
+	for _, p := range s.pairs {
+		info := CertInfo{CertFile: p.certFile, KeyFile: p.keyFile, Hosts: p.hosts, LoadedAt: p.loadedAt}
+		if leaf := leafOf(&p.cert); leaf != nil {
+			info.Subject = leaf.Subject.String()
+			info.DNSNames = leaf.DNSNames
+			info.Serial = strings.ToUpper(leaf.SerialNumber.Text(16))
+			info.NotAfter = leaf.NotAfter
+			if st.NotAfter.IsZero() || leaf.NotAfter.Before(st.NotAfter) {
+				st.NotAfter = leaf.NotAfter
+			}
+		}
+		st.Certificates = append(st.Certificates, info)
+	}
+	return st
+}
+
+// leafOf returns the parsed leaf certificate of cert, or nil.
+func leafOf(cert *tls.Certificate) *x509.Certificate {
+	if cert.Leaf != nil {
+		return cert.Leaf
+	}
+	if len(cert.Certificate) == 0 {
+		return nil
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil
+	}
+	return leaf
+}
+
+// certManager serves a listener's manual TLS certificates and, with
+// tls.reload enabled, polls their files and swaps in renewed certificates.
+// Handshakes read the current set without locking.
+type certManager struct {
+	listenerID string
+	onError    func(*CertReloadError) // called for each failed reload
+
+	current atomic.Pointer[[]tls.Certificate]
+
+	mu       sync.Mutex // guards the fields below
+	set      *certSet
+	interval time.Duration // 0 when reloading is off
+	stopCh   chan struct{} // closes the running poller, nil when stopped
+}
+
+func newCertManager(listenerID string, tlsCfg config.TLSConfig, onError func(*CertReloadError)) (*certManager, error) {
+	m := &certManager{listenerID: listenerID, onError: onError}
+	if err := m.replace(tlsCfg); err != nil {
+		return nil, err
+	}
+	m.interval = reloadInterval(tlsCfg.Reload)
+	return m, nil
+}
+
+func reloadInterval(rc config.TLSReloadConfig) time.Duration {
+	if !rc.Enabled {
+		return 0
+	}
+	if rc.Interval > 0 {
+		return rc.Interval
+	}
+	return defaultCertReloadInterval
+}
+
+// getCertificate selects a certificate by SNI hostname, falling back to the
+// first one.
+func (m *certManager) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certs := *m.current.Load()
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no TLS certificates loaded")
+	}
+	if len(certs) > 1 && hello.ServerName != "" {
+		for i := range certs {
+			if err := hello.SupportsCertificate(&certs[i]); err == nil {
+				return &certs[i], nil
+			}
+		}
+	}
+	return &certs[0], nil
+}
+
+// first returns the first certificate.
+func (m *certManager) first() *tls.Certificate {
+	certs := *m.current.Load()
+	if len(certs) == 0 {
+		return nil
+	}
+	return &certs[0]
+}
+
+// replace loads the certificates of tlsCfg and serves them instead of the
+// current ones. On error the current ones are kept.
+func (m *certManager) replace(tlsCfg config.TLSConfig) error {
+	set, err := newCertSet(tlsCfg)
+	if err != nil {
+		return err
+	}
+	m.install(set)
+	return nil
+}
+
+// install serves the certificates of a loaded set instead of the current
+// ones.
+func (m *certManager) install(set *certSet) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set = set
+	m.publish()
+}
+
+// publish serves the certificates of m.set. m.mu must be held.
+func (m *certManager) publish() {
+	certs := m.set.certificates()
+	m.current.Store(&certs)
+}
+
+// refresh reloads certificate files that changed on disk. Failures are
+// logged, passed to onError and returned; the previous certificate keeps
+// being served for each.
+func (m *certManager) refresh() []*CertReloadError {
+	m.mu.Lock()
+	reloaded, errs := m.set.refresh(time.Now())
+	if len(reloaded) > 0 {
+		m.publish()
+	}
+	m.mu.Unlock()
+
+	for _, p := range reloaded {
+		fields := []zap.Field{zap.String("listener", m.listenerID), zap.String("cert_file", p.certFile)}
+		if leaf := leafOf(&p.cert); leaf != nil {
+			fields = append(fields, zap.Time("not_after", leaf.NotAfter))
+		}
+		logging.Info("reloaded TLS certificate", fields...)
+	}
+	for _, e := range errs {
+		e.ListenerID = m.listenerID
+		logging.Error("failed to reload TLS certificate, serving the previous one",
+			zap.String("listener", m.listenerID),
+			zap.String("cert_file", e.CertFile),
+			zap.String("key_file", e.KeyFile),
+			zap.Error(e.Err))
+		if m.onError != nil {
+			m.onError(e)
+		}
+	}
+	return errs
+}
+
+// setReload applies reload settings, restarting a running poller when the
+// interval changes.
+func (m *certManager) setReload(rc config.TLSReloadConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	interval := reloadInterval(rc)
+	if interval == m.interval {
+		return
+	}
+	m.interval = interval
+	if m.stopCh != nil {
+		close(m.stopCh)
+		m.stopCh = nil
+		m.startLocked()
+	}
+}
+
+// start starts polling when reloading is enabled.
+func (m *certManager) start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopCh == nil {
+		m.startLocked()
+	}
+}
+
+func (m *certManager) startLocked() {
+	if m.interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	m.stopCh = stop
+	go m.poll(m.interval, stop)
+}
+
+// stop stops polling.
+func (m *certManager) stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopCh != nil {
+		close(m.stopCh)
+		m.stopCh = nil
+	}
+}
+
+func (m *certManager) poll(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.refresh()
+		}
+	}
+}
+
+func (m *certManager) status() CertStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.set.status()
+	if m.interval > 0 {
+		st.Reload, st.ReloadInterval = true, m.interval.String()
+	}
+	return st
+}
+
+// certsChanged reports whether two TLS configs name different certificates.
+func certsChanged(a, b config.TLSConfig) bool {
+	return a.CertFile != b.CertFile || a.KeyFile != b.KeyFile || !reflect.DeepEqual(a.Certificates, b.Certificates)
+}
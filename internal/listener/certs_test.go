@@ -0,0 +1,253 @@
+package listener
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+// writeCertPair writes a self-signed certificate for dnsName with the given
+// serial to certFile and keyFile, and moves their mtime forward so the
+// change is seen even on coarse-grained file systems.
+func writeCertPair(t *testing.T, certFile, keyFile string, serial int64, dnsName string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Duration(serial) * time.Hour),
+		DNSNames:     []string{dnsName},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFileAt(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), serial)
+	writeFileAt(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), serial)
+}
+
+func writeFileAt(t *testing.T, path string, data []byte, version int64) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(time.Duration(version) * time.Minute)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedSerial(t *testing.T, cert *tls.Certificate) int64 {
+	t.Helper()
+	leaf := leafOf(cert)
+	if leaf == nil {
+		t.Fatal("no leaf certificate")
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertManagerReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertPair(t, certFile, keyFile, 1, "a.example.com")
+
+	m, err := newCertManager("web", config.TLSConfig{CertFile: certFile, KeyFile: keyFile}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := m.status().LastReload
+
+	if errs := m.refresh(); len(errs) != 0 {
+		t.Fatalf("unchanged files: unexpected errors %v", errs)
+	}
+	if got := servedSerial(t, m.first()); got != 1 {
+		t.Fatalf("expected serial 1, got %d", got)
+	}
+
+	writeCertPair(t, certFile, keyFile, 2, "a.example.com")
+	if errs := m.refresh(); len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	if got := servedSerial(t, m.first()); got != 2 {
+		t.Fatalf("expected the renewed serial 2, got %d", got)
+	}
+	st := m.status()
+	if st.LastReload.Before(before) {
+		t.Errorf("last reload went backwards: %v then %v", before, st.LastReload)
+	}
+	if st.Certificates[0].Serial != "2" || st.NotAfter.IsZero() {
+		t.Errorf("status does not reflect the renewed certificate: %+v", st)
+	}
+}
+
+func TestCertManagerKeepsCertOnFailedReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertPair(t, certFile, keyFile, 1, "a.example.com")
+
+	var reported []*CertReloadError
+	m, err := newCertManager("web", config.TLSConfig{CertFile: certFile, KeyFile: keyFile}, func(e *CertReloadError) {
+		reported = append(reported, e)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A truncated certificate.
+	data, _ := os.ReadFile(certFile)
+	writeFileAt(t, certFile, data[:len(data)/2], 2)
+	if errs := m.refresh(); len(errs) != 1 {
+		t.Fatalf("expected one error, got %v", errs)
+	}
+	if got := servedSerial(t, m.first()); got != 1 {
+		t.Fatalf("expected the previous serial 1 to be served, got %d", got)
+	}
+	if len(reported) != 1 || reported[0].ListenerID != "web" || reported[0].CertFile != certFile {
+		t.Fatalf("expected one reported error for web, got %+v", reported)
+	}
+	st := m.status()
+	if st.LastError == "" || st.LastErrorAt == nil {
+		t.Errorf("expected the error in the status, got %+v", st)
+	}
+
+	// The failed version is reported once.
+	if errs := m.refresh(); len(errs) != 0 || len(reported) != 1 {
+		t.Fatalf("expected no new errors, got %v", errs)
+	}
+
+	// A key that does not match the certificate.
+	writeCertPair(t, certFile, filepath.Join(dir, "other.key"), 3, "a.example.com")
+	if errs := m.refresh(); len(errs) != 1 {
+		t.Fatalf("expected a mismatched key error, got %v", errs)
+	}
+	if got := servedSerial(t, m.first()); got != 1 {
+		t.Fatalf("expected the previous serial 1 to be served, got %d", got)
+	}
+
+	writeCertPair(t, certFile, keyFile, 4, "a.example.com")
+	if errs := m.refresh(); len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	if got := servedSerial(t, m.first()); got != 4 {
+		t.Fatalf("expected serial 4 once fixed, got %d", got)
+	}
+}
+
+func TestCertManagerReloadsSNICertificates(t *testing.T) {
+	dir := t.TempDir()
+	aCert, aKey := filepath.Join(dir, "a.crt"), filepath.Join(dir, "a.key")
+	bCert, bKey := filepath.Join(dir, "b.crt"), filepath.Join(dir, "b.key")
+	writeCertPair(t, aCert, aKey, 1, "a.example.com")
+	writeCertPair(t, bCert, bKey, 1, "b.example.com")
+
+	m, err := newCertManager("web", config.TLSConfig{Certificates: []config.TLSCertPair{
+		{CertFile: aCert, KeyFile: aKey, Hosts: []string{"a.example.com"}},
+		{CertFile: bCert, KeyFile: bKey, Hosts: []string{"b.example.com"}},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeCertPair(t, bCert, bKey, 2, "b.example.com")
+	m.refresh()
+
+	for host, want := range map[string]int64{"a.example.com": 1, "b.example.com": 2} {
+		cert, err := m.getCertificate(&tls.ClientHelloInfo{
+			ServerName:        host,
+			SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+			SupportedVersions: []uint16{tls.VersionTLS13},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := servedSerial(t, cert); got != want {
+			t.Errorf("%s: expected serial %d, got %d", host, want, got)
+		}
+	}
+	if st := m.status(); len(st.Certificates) != 2 || st.Certificates[1].Hosts[0] != "b.example.com" {
+		t.Errorf("unexpected status %+v", st)
+	}
+}
+
+func TestHTTPListenerReloadsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertPair(t, certFile, keyFile, 1, "localhost")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	l, err := NewHTTPListener(HTTPListenerConfig{
+		ID:      "web",
+		Address: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		TLS: config.TLSConfig{
+			Enabled:  true,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			Reload:   config.TLSReloadConfig{Enabled: true, Interval: 10 * time.Millisecond},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		l.Stop(ctx)
+	})
+
+	handshakeSerial := func() int64 {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+	if got := handshakeSerial(); got != 1 {
+		t.Fatalf("expected serial 1, got %d", got)
+	}
+
+	writeCertPair(t, certFile, keyFile, 2, "localhost")
+	deadline := time.Now().Add(5 * time.Second)
+	for handshakeSerial() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("renewed certificate was not served")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	st := l.CertStatus()
+	if st == nil || !st.Reload || st.ReloadInterval != "10ms" || st.Certificates[0].Serial != "2" {
+		t.Errorf("unexpected status %+v", st)
+	}
+}
@@ -0,0 +1,120 @@
+package listener
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// sharedSocket accepts connections on a listening socket and hands each to
+// the current generation of a listener's HTTP server. Starting a new
+// generation lets the listener serve new connections with new server
+// settings while the previous server drains its own, without closing the
+// socket.
+type sharedSocket struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	current *socketGen
+	closed  bool
+}
+
+// socketGen is the net.Listener one HTTP server generation serves.
+type socketGen struct {
+	sock  *sharedSocket
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newSharedSocket(ln net.Listener) *sharedSocket {
+	s := &sharedSocket{ln: ln}
+	go s.acceptLoop()
+	return s
+}
+
+// next starts a new generation and returns it. The previous generation
+// receives no further connections.
+func (s *sharedSocket) next() *socketGen {
+	g := &socketGen{sock: s, conns: make(chan net.Conn), done: make(chan struct{})}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		g.Close()
+	}
+	s.current = g
+	return g
+}
+
+// Close closes the socket and ends the current generation.
+func (s *sharedSocket) Close() error {
+	return s.ln.Close()
+}
+
+func (s *sharedSocket) acceptLoop() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			s.mu.Lock()
+			s.closed = true
+			if s.current != nil {
+				s.current.Close()
+			}
+			s.mu.Unlock()
+			return
+		}
+		s.deliver(c)
+	}
+}
+
+// deliver hands c to the current generation, or closes it when there is
+// none accepting.
+func (s *sharedSocket) deliver(c net.Conn) {
+	for {
+		s.mu.Lock()
+		g := s.current
+		s.mu.Unlock()
+		if g == nil {
+			c.Close()
+			return
+		}
+		select {
+		case g.conns <- c:
+			return
+		case <-g.done:
+			s.mu.Lock()
+			replaced := s.current != g
+			s.mu.Unlock()
+			if !replaced {
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+// Accept waits for the next connection handed to this generation.
+func (g *socketGen) Accept() (net.Conn, error) {
+	select {
+	case c := <-g.conns:
+		return c, nil
+	case <-g.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops this generation receiving connections; the socket stays open.
+func (g *socketGen) Close() error {
+	g.once.Do(func() { close(g.done) })
+	return nil
+}
+
+// Addr returns the socket's address.
+func (g *socketGen) Addr() net.Addr {
+	return g.sock.ln.Addr()
+}
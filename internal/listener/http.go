@@ -4,32 +4,53 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"reflect"
+	"slices"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/quic-go/quic-go"
 	"github.com/quic-go/quic-go/http3"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/acme"
 )
 
+// ErrRestartRequired is returned by Update for changes a running listener
+// cannot apply in place: its address, turning TLS on or off, or its ACME
+// settings.
+var ErrRestartRequired = errors.New("listener change requires a restart")
+
+// defaultDrainTimeout bounds how long a replaced server generation drains
+// its connections.
+const defaultDrainTimeout = 30 * time.Second
+
 // HTTPListener wraps an HTTP server as a Listener
 type HTTPListener struct {
-	id          string
-	address     string
+	id           string
+	address      string
+	handler      http.Handler
+	tlsCfg       *tls.Config // hands each handshake tlsCurrent (nil without TLS)
+	tlsCurrent   atomic.Pointer[tls.Config]
+	certs        *certManager  // manual TLS certificates (nil with ACME or without TLS)
+	acmeMgr      *acme.Manager // ACME certificate manager (nil if manual TLS)
+	streams      streamEnforcer
+	drainTimeout time.Duration
+
+	mu          sync.Mutex // guards the fields below
+	cfg         HTTPListenerConfig
+	settings    serverSettings
 	server      *http.Server
-	handler     http.Handler
-	tlsCfg      *tls.Config
-	listener    net.Listener
-	certPtr     atomic.Pointer[tls.Certificate]   // single-cert hot reload
-	certsPtr    atomic.Pointer[[]tls.Certificate]  // multi-cert SNI hot reload
+	socket      *sharedSocket // nil until started
 	enableHTTP3 bool
 	http3Server *http3.Server
 	udpConn     net.PacketConn
-	acmeMgr     *acme.Manager // ACME certificate manager (nil if manual TLS)
+	drains      sync.WaitGroup // replaced servers still draining
 }
 
 // HTTPListenerConfig holds configuration for creating an HTTP listener
@@ -45,16 +66,63 @@ type HTTPListenerConfig struct {
 	MaxHeaderBytes    int
 	ReadHeaderTimeout time.Duration
 	EnableHTTP3       bool
+	HTTP2             config.StreamHardeningConfig
+	HTTP3             config.StreamHardeningConfig
+	DrainTimeout      time.Duration                 // bounds draining a server replaced by Update (default 30s)
+	OnStreamEnforce   func(protocol, action string) // called for each stream limit enforcement
+	OnCertReloadError func(*CertReloadError)        // called when a changed certificate fails to load
+}
+
+// serverSettings are the http.Server settings a listener applies to new
+// connections.
+type serverSettings struct {
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	maxHeaderBytes    int
+}
+
+// resolveServerSettings fills in defaults for unset server settings.
+func resolveServerSettings(cfg HTTPListenerConfig) serverSettings {
+	s := serverSettings{
+		readTimeout:       cfg.ReadTimeout,
+		writeTimeout:      cfg.WriteTimeout,
+		idleTimeout:       cfg.IdleTimeout,
+		readHeaderTimeout: cfg.ReadHeaderTimeout,
+		maxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	if s.readTimeout == 0 {
+		s.readTimeout = 30 * time.Second
+	}
+	if s.writeTimeout == 0 {
+		s.writeTimeout = 30 * time.Second
+	}
+	if s.idleTimeout == 0 {
+		s.idleTimeout = 60 * time.Second
+	}
+	if s.maxHeaderBytes == 0 {
+		s.maxHeaderBytes = 1 << 20 // 1MB
+	}
+	if s.readHeaderTimeout == 0 {
+		s.readHeaderTimeout = 10 * time.Second
+	}
+	return s
 }
 
 // NewHTTPListener creates a new HTTP listener
 func NewHTTPListener(cfg HTTPListenerConfig) (*HTTPListener, error) {
 	h := &HTTPListener{
-		id:          cfg.ID,
-		address:     cfg.Address,
-		handler:     cfg.Handler,
-		enableHTTP3: cfg.EnableHTTP3,
+		id:           cfg.ID,
+		address:      cfg.Address,
+		enableHTTP3:  cfg.EnableHTTP3,
+		drainTimeout: cfg.DrainTimeout,
+		cfg:          cfg,
+	}
+	if h.drainTimeout <= 0 {
+		h.drainTimeout = defaultDrainTimeout
 	}
+	h.streams.onEnforce = cfg.OnStreamEnforce
 
 	// Set up TLS if enabled
 	if cfg.TLS.Enabled {
@@ -65,110 +133,125 @@ func NewHTTPListener(cfg HTTPListenerConfig) (*HTTPListener, error) {
 				return nil, fmt.Errorf("failed to initialize ACME: %w", err)
 			}
 			h.acmeMgr = acmeMgr
-			h.tlsCfg = acmeMgr.TLSConfig()
-		} else if len(cfg.TLS.Certificates) > 0 {
-			// Multi-cert SNI mode (used by ingress controller and multi-host configs)
-			certs, err := loadCertPairs(cfg.TLS)
-			if err != nil {
-				return nil, err
-			}
-			h.certsPtr.Store(&certs)
-
-			h.tlsCfg = &tls.Config{
-				GetCertificate: h.getCertificateSNI,
-				MinVersion:     tls.VersionTLS12,
-			}
 		} else {
-			// Single-cert manual TLS mode
-			cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+			// Manual TLS: the top-level cert_file/key_file and/or the
+			// per-SNI certificates list
+			certs, err := newCertManager(cfg.ID, cfg.TLS, cfg.OnCertReloadError)
 			if err != nil {
-				return nil, fmt.Errorf("failed to load TLS certificates: %w", err)
-			}
-
-			h.certPtr.Store(&cert)
-
-			h.tlsCfg = &tls.Config{
-				GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
-					return h.certPtr.Load(), nil
-				},
-				MinVersion: tls.VersionTLS12,
+				return nil, err
 			}
+			h.certs = certs
 		}
 
-		// mTLS: Configure client certificate authentication (applies to both ACME and manual)
-		if cfg.TLS.ClientAuth != "" {
-			switch cfg.TLS.ClientAuth {
-			case "request":
-				h.tlsCfg.ClientAuth = tls.RequestClientCert
-			case "require":
-				h.tlsCfg.ClientAuth = tls.RequireAnyClientCert
-			case "verify":
-				h.tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
-			default:
-				h.tlsCfg.ClientAuth = tls.NoClientCert
-			}
-
-			// Load client CA if specified
-			if cfg.TLS.ClientCAFile != "" {
-				caCert, err := os.ReadFile(cfg.TLS.ClientCAFile)
-				if err != nil {
-					return nil, fmt.Errorf("failed to read client CA file: %w", err)
-				}
-				caPool := x509.NewCertPool()
-				if !caPool.AppendCertsFromPEM(caCert) {
-					return nil, fmt.Errorf("failed to parse client CA certificate")
-				}
-				h.tlsCfg.ClientCAs = caPool
-			}
+		current, err := h.buildTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
 		}
+		h.tlsCurrent.Store(current)
+		// Handshakes read the current config, so Update can swap it.
+		h.tlsCfg = &tls.Config{GetConfigForClient: h.configForClient}
 	}
 
-	// Apply defaults
-	readTimeout := cfg.ReadTimeout
-	if readTimeout == 0 {
-		readTimeout = 30 * time.Second
+	h.handler = h.streams.wrap(cfg.Handler)
+	h.settings = resolveServerSettings(cfg)
+	h.server = h.newServer()
+
+	// Serve HTTP/2 ourselves so each connection gets the current stream
+	// limits and a tracker.
+	if h.tlsCfg != nil {
+		h.streams.setH2(resolveStreamLimits(cfg.HTTP2, defaultH2MaxStreams, h.settings.maxHeaderBytes), h.server)
 	}
 
-	writeTimeout := cfg.WriteTimeout
-	if writeTimeout == 0 {
-		writeTimeout = 30 * time.Second
+	// Set up HTTP/3 server if enabled
+	if cfg.EnableHTTP3 && h.tlsCfg != nil {
+		h.http3Server = h.newHTTP3Server(cfg.HTTP3)
 	}
 
-	idleTimeout := cfg.IdleTimeout
-	if idleTimeout == 0 {
-		idleTimeout = 60 * time.Second
+	return h, nil
+}
+
+// buildTLSConfig returns the TLS config handshakes use for tc's client
+// authentication settings.
+func (h *HTTPListener) buildTLSConfig(tc config.TLSConfig) (*tls.Config, error) {
+	var c *tls.Config
+	if h.acmeMgr != nil {
+		c = h.acmeMgr.TLSConfig()
+	} else {
+		c = &tls.Config{
+			GetCertificate: h.certs.getCertificate,
+			MinVersion:     tls.VersionTLS12,
+		}
 	}
 
-	maxHeaderBytes := cfg.MaxHeaderBytes
-	if maxHeaderBytes == 0 {
-		maxHeaderBytes = 1 << 20 // 1MB
+	// mTLS: Configure client certificate authentication (applies to both ACME and manual)
+	if tc.ClientAuth != "" {
+		switch tc.ClientAuth {
+		case "request":
+			c.ClientAuth = tls.RequestClientCert
+		case "require":
+			c.ClientAuth = tls.RequireAnyClientCert
+		case "verify":
+			c.ClientAuth = tls.RequireAndVerifyClientCert
+		default:
+			c.ClientAuth = tls.NoClientCert
+		}
+
+		// Load client CA if specified
+		if tc.ClientCAFile != "" {
+			caCert, err := os.ReadFile(tc.ClientCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read client CA file: %w", err)
+			}
+			caPool := x509.NewCertPool()
+			if !caPool.AppendCertsFromPEM(caCert) {
+				return nil, fmt.Errorf("failed to parse client CA certificate")
+			}
+			c.ClientCAs = caPool
+		}
 	}
 
-	readHeaderTimeout := cfg.ReadHeaderTimeout
-	if readHeaderTimeout == 0 {
-		readHeaderTimeout = 10 * time.Second
+	for _, proto := range []string{"h2", "http/1.1"} {
+		if !slices.Contains(c.NextProtos, proto) {
+			c.NextProtos = append(c.NextProtos, proto)
+		}
 	}
+	return c, nil
+}
+
+// configForClient returns the current TLS config for a handshake.
+func (h *HTTPListener) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return h.tlsCurrent.Load(), nil
+}
 
-	h.server = &http.Server{
-		Addr:              cfg.Address,
-		Handler:           cfg.Handler,
-		ReadTimeout:       readTimeout,
-		WriteTimeout:      writeTimeout,
-		IdleTimeout:       idleTimeout,
-		MaxHeaderBytes:    maxHeaderBytes,
-		ReadHeaderTimeout: readHeaderTimeout,
+// newServer returns an HTTP server with the listener's current settings.
+func (h *HTTPListener) newServer() *http.Server {
+	srv := &http.Server{
+		Addr:              h.address,
+		Handler:           h.handler,
+		ReadTimeout:       h.settings.readTimeout,
+		WriteTimeout:      h.settings.writeTimeout,
+		IdleTimeout:       h.settings.idleTimeout,
+		MaxHeaderBytes:    h.settings.maxHeaderBytes,
+		ReadHeaderTimeout: h.settings.readHeaderTimeout,
 		TLSConfig:         h.tlsCfg,
 	}
-
-	// Set up HTTP/3 server if enabled
-	if cfg.EnableHTTP3 && h.tlsCfg != nil {
-		h.http3Server = &http3.Server{
-			Handler:   cfg.Handler,
-			TLSConfig: http3.ConfigureTLSConfig(h.tlsCfg),
-		}
+	if h.tlsCfg != nil {
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){"h2": h.streams.serveH2}
 	}
+	return srv
+}
 
-	return h, nil
+// newHTTP3Server returns an HTTP/3 server with the given stream limits.
+func (h *HTTPListener) newHTTP3Server(limits config.StreamHardeningConfig) *http3.Server {
+	h3 := resolveStreamLimits(limits, defaultH3MaxStreams, h.settings.maxHeaderBytes)
+	h.streams.h3.Store(&h3)
+	return &http3.Server{
+		Handler:        h.handler,
+		TLSConfig:      http3.ConfigureTLSConfig(h.tlsCfg),
+		QUICConfig:     &quic.Config{Allow0RTT: true, GetConfigForClient: h.streams.quicConfig},
+		ConnContext:    h.streams.h3ConnContext,
+		MaxHeaderBytes: h.settings.maxHeaderBytes,
+	}
 }
 
 // ID returns the listener ID
@@ -199,34 +282,27 @@ func (h *HTTPListener) Start(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to listen on %s: %w", h.address, err)
 	}
-	h.listener = ln
 
-	if h.tlsCfg != nil {
-		h.listener = tls.NewListener(ln, h.tlsCfg)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.socket = newSharedSocket(ln)
+	if h.certs != nil {
+		h.certs.start()
 	}
 
 	errCh := make(chan error, 2)
-	go func() {
-		if err := h.server.Serve(h.listener); err != nil && err != http.ErrServerClosed {
-			errCh <- err
-		}
-	}()
+	h.serve(h.server, errCh)
 
 	// Start HTTP/3 QUIC listener on the same port via UDP
 	if h.http3Server != nil {
 		udpConn, err := net.ListenPacket("udp", h.address)
 		if err != nil {
 			// Shut down the TCP listener since we failed to start UDP
+			h.socket.Close()
 			h.server.Shutdown(ctx)
 			return fmt.Errorf("failed to listen UDP for HTTP/3 on %s: %w", h.address, err)
 		}
-		h.udpConn = udpConn
-
-		go func() {
-			if err := h.http3Server.Serve(udpConn); err != nil && err != http.ErrServerClosed {
-				errCh <- err
-			}
-		}()
+		h.serveHTTP3(udpConn, errCh)
 	}
 
 	// Check for immediate startup errors
@@ -238,12 +314,50 @@ func (h *HTTPListener) Start(ctx context.Context) error {
 	}
 }
 
-// Stop stops the HTTP listener
+// serve serves a new generation of the socket's connections with srv.
+// h.mu must be held.
+func (h *HTTPListener) serve(srv *http.Server, errCh chan<- error) {
+	var ln net.Listener = h.socket.next()
+	if h.tlsCfg != nil {
+		ln = tls.NewListener(ln, h.tlsCfg)
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			select {
+			case errCh <- err:
+			default:
+			}
+		}
+	}()
+}
+
+// serveHTTP3 serves HTTP/3 on udpConn. h.mu must be held.
+func (h *HTTPListener) serveHTTP3(udpConn net.PacketConn, errCh chan<- error) {
+	h.udpConn = udpConn
+	srv := h.http3Server
+	go func() {
+		if err := srv.Serve(udpConn); err != nil && err != http.ErrServerClosed {
+			select {
+			case errCh <- err:
+			default:
+			}
+		}
+	}()
+}
+
+// Stop stops the HTTP listener. Connections still open when ctx expires
+// are closed.
 func (h *HTTPListener) Stop(ctx context.Context) error {
 	// Shut down ACME HTTP challenge server
 	if h.acmeMgr != nil {
 		h.acmeMgr.Stop(ctx)
 	}
+	if h.certs != nil {
+		h.certs.stop()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
 	// Shut down HTTP/3 first
 	if h.http3Server != nil {
@@ -253,26 +367,203 @@ func (h *HTTPListener) Stop(ctx context.Context) error {
 		h.udpConn.Close()
 	}
 
-	return h.server.Shutdown(ctx)
+	if h.socket != nil {
+		h.socket.Close()
+	}
+	err := h.server.Shutdown(ctx)
+	if err != nil {
+		h.server.Close()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		h.drains.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+	}
+	return err
+}
+
+// Release closes the listener's sockets so another listener can bind its
+// address. HTTP/3 connections are closed; Stop drains the others.
+func (h *HTTPListener) Release() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.socket != nil {
+		h.socket.Close()
+	}
+	if h.http3Server != nil {
+		h.http3Server.Close()
+	}
+	if h.udpConn != nil {
+		h.udpConn.Close()
+	}
+}
+
+// Update applies cfg to the running listener without closing its socket:
+// TLS settings apply to new handshakes, server timeouts and header limits
+// to new connections, and HTTP/3 is started or stopped on the same
+// address. Connections accepted under the previous settings drain with
+// their server. The handler and callbacks of cfg are not used.
+//
+// Update applies all of cfg or, on error, none of it. It returns
+// ErrRestartRequired for changes only a new listener can apply.
+func (h *HTTPListener) Update(cfg HTTPListenerConfig) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if cfg.Address != h.address || cfg.TLS.Enabled != (h.tlsCfg != nil) ||
+		cfg.TLS.Enabled && (cfg.ACME.Enabled != (h.acmeMgr != nil) || !reflect.DeepEqual(cfg.ACME, h.cfg.ACME)) {
+		return ErrRestartRequired
+	}
+
+	// Load everything that can fail before changing anything.
+	var set *certSet
+	if h.certs != nil && certsChanged(h.cfg.TLS, cfg.TLS) {
+		var err error
+		if set, err = newCertSet(cfg.TLS); err != nil {
+			return err
+		}
+	}
+	var tlsConf *tls.Config
+	if h.tlsCfg != nil {
+		var err error
+		if tlsConf, err = h.buildTLSConfig(cfg.TLS); err != nil {
+			return err
+		}
+	}
+	enableHTTP3 := cfg.EnableHTTP3 && h.tlsCfg != nil
+	var udpConn net.PacketConn
+	if enableHTTP3 && h.http3Server == nil && h.socket != nil {
+		var err error
+		if udpConn, err = net.ListenPacket("udp", h.address); err != nil {
+			return fmt.Errorf("failed to listen UDP for HTTP/3 on %s: %w", h.address, err)
+		}
+	}
+
+	if h.certs != nil {
+		if set != nil {
+			h.certs.install(set)
+		}
+		h.certs.setReload(cfg.TLS.Reload)
+	}
+	if tlsConf != nil {
+		h.tlsCurrent.Store(tlsConf)
+	}
+	if h.certs != nil && set == nil {
+		// Same files: pick up any that changed on disk.
+		h.certs.refresh()
+	}
+
+	if settings := resolveServerSettings(cfg); settings != h.settings {
+		h.settings = settings
+		h.replaceServer(cfg.HTTP2)
+	} else if h.tlsCfg != nil {
+		h.streams.setH2(resolveStreamLimits(cfg.HTTP2, defaultH2MaxStreams, h.settings.maxHeaderBytes), h.server)
+	}
+
+	switch {
+	case enableHTTP3 && h.http3Server == nil:
+		h.http3Server = h.newHTTP3Server(cfg.HTTP3)
+		if udpConn != nil {
+			h.serveHTTP3(udpConn, nil)
+		}
+	case !enableHTTP3 && h.http3Server != nil:
+		h.drainHTTP3()
+	case enableHTTP3:
+		limits := resolveStreamLimits(cfg.HTTP3, defaultH3MaxStreams, h.settings.maxHeaderBytes)
+		h.streams.h3.Store(&limits)
+	}
+
+	h.enableHTTP3 = cfg.EnableHTTP3
+	cfg.Handler, cfg.OnStreamEnforce, cfg.OnCertReloadError = h.cfg.Handler, h.cfg.OnStreamEnforce, h.cfg.OnCertReloadError
+	h.cfg = cfg
+	return nil
+}
+
+// replaceServer serves new connections with a server built from the
+// current settings and drains the previous one. h.mu must be held.
+func (h *HTTPListener) replaceServer(h2 config.StreamHardeningConfig) {
+	prev := h.server
+	h.server = h.newServer()
+	if h.tlsCfg != nil {
+		h.streams.setH2(resolveStreamLimits(h2, defaultH2MaxStreams, h.settings.maxHeaderBytes), h.server)
+	}
+	if h.socket == nil {
+		return
+	}
+	h.serve(h.server, nil)
+	h.drains.Add(1)
+	go func() {
+		defer h.drains.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), h.drainTimeout)
+		defer cancel()
+		if err := prev.Shutdown(ctx); err != nil {
+			prev.Close()
+		}
+	}()
+}
+
+// drainHTTP3 stops serving HTTP/3, letting open connections finish their
+// requests. h.mu must be held.
+func (h *HTTPListener) drainHTTP3() {
+	srv, udpConn := h.http3Server, h.udpConn
+	h.http3Server, h.udpConn = nil, nil
+	h.drains.Add(1)
+	go func() {
+		defer h.drains.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), h.drainTimeout)
+		defer cancel()
+		srv.Shutdown(ctx)
+		srv.Close()
+		if udpConn != nil {
+			udpConn.Close()
+		}
+	}()
 }
 
 // ReloadTLSCert hot-swaps the TLS certificate without restarting the listener.
 func (h *HTTPListener) ReloadTLSCert(certFile, keyFile string) error {
-	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
-	if err != nil {
-		return fmt.Errorf("failed to load TLS certificates: %w", err)
+	if h.certs == nil {
+		return fmt.Errorf("listener %s does not use manual TLS", h.id)
 	}
-	h.certPtr.Store(&cert)
-	return nil
+	return h.certs.replace(config.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+}
+
+// SetStreamLimits applies new HTTP/2 and HTTP/3 stream limits to
+// connections accepted from now on; existing connections keep theirs.
+func (h *HTTPListener) SetStreamLimits(h2, h3 config.StreamHardeningConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tlsCfg != nil {
+		h.streams.setH2(resolveStreamLimits(h2, defaultH2MaxStreams, h.settings.maxHeaderBytes), h.server)
+	}
+	if h.http3Server != nil {
+		limits := resolveStreamLimits(h3, defaultH3MaxStreams, h.settings.maxHeaderBytes)
+		h.streams.h3.Store(&limits)
+	}
+}
+
+// StreamStats returns stream limit enforcement counts keyed
+// "<protocol>.<action>", e.g. "h2.reset_flood".
+func (h *HTTPListener) StreamStats() map[string]int64 {
+	return h.streams.stats()
 }
 
 // HTTP3Enabled returns whether HTTP/3 is enabled on this listener.
 func (h *HTTPListener) HTTP3Enabled() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	return h.enableHTTP3
 }
 
 // Server returns the underlying HTTP server
 func (h *HTTPListener) Server() *http.Server {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	return h.server
 }
 
@@ -281,72 +572,44 @@ func (h *HTTPListener) ACMEManager() *acme.Manager {
 	return h.acmeMgr
 }
 
-// CertPtr returns the manual TLS certificate pointer (for expiry monitoring of manual certs).
+// CertPtr returns the first manual TLS certificate (for expiry monitoring of manual certs).
 func (h *HTTPListener) CertPtr() *tls.Certificate {
-	return h.certPtr.Load()
-}
-
-// ReloadCertificates hot-swaps the multi-cert SNI certificate set without restarting the listener.
-func (h *HTTPListener) ReloadCertificates(tlsCfg config.TLSConfig) error {
-	certs, err := loadCertPairs(tlsCfg)
-	if err != nil {
-		return err
+	if h.certs == nil {
+		return nil
 	}
-	h.certsPtr.Store(&certs)
-	return nil
+	return h.certs.first()
 }
 
-// getCertificateSNI selects a certificate by SNI hostname from the multi-cert set.
-func (h *HTTPListener) getCertificateSNI(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
-	certsPtr := h.certsPtr.Load()
-	if certsPtr == nil || len(*certsPtr) == 0 {
-		return nil, fmt.Errorf("no TLS certificates loaded")
+// CertStatus reports the manual TLS certificates served, or nil with ACME
+// or without TLS.
+func (h *HTTPListener) CertStatus() *CertStatus {
+	if h.certs == nil {
+		return nil
 	}
-	certs := *certsPtr
+	st := h.certs.status()
+	return &st
+}
 
-	// Match by SNI
-	serverName := hello.ServerName
-	if serverName != "" {
-		for i := range certs {
-			if err := hello.SupportsCertificate(&certs[i]); err == nil {
-				return &certs[i], nil
-			}
-		}
+// SetCertReload applies new certificate reload settings.
+func (h *HTTPListener) SetCertReload(rc config.TLSReloadConfig) {
+	if h.certs != nil {
+		h.certs.setReload(rc)
 	}
-
-	// Fallback: return first certificate
-	return &certs[0], nil
 }
 
-// loadCertPairs loads TLS certificates from a TLSConfig, supporting both
-// in-memory CertData/KeyData and file-based CertFile/KeyFile.
-// If a top-level CertFile/KeyFile is set, it is loaded as the first cert.
-func loadCertPairs(tlsCfg config.TLSConfig) ([]tls.Certificate, error) {
-	var certs []tls.Certificate
-
-	// Load top-level cert if present
-	if tlsCfg.CertFile != "" && tlsCfg.KeyFile != "" {
-		cert, err := tls.LoadX509KeyPair(tlsCfg.CertFile, tlsCfg.KeyFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
-		}
-		certs = append(certs, cert)
+// RefreshCertificates reloads certificate files that changed on disk now,
+// without waiting for the next poll, and returns the failures.
+func (h *HTTPListener) RefreshCertificates() []*CertReloadError {
+	if h.certs == nil {
+		return nil
 	}
+	return h.certs.refresh()
+}
 
-	// Load each certificate pair
-	for i, cp := range tlsCfg.Certificates {
-		var cert tls.Certificate
-		var err error
-		if len(cp.CertData) > 0 && len(cp.KeyData) > 0 {
-			cert, err = tls.X509KeyPair(cp.CertData, cp.KeyData)
-		} else {
-			cert, err = tls.LoadX509KeyPair(cp.CertFile, cp.KeyFile)
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to load certificate[%d]: %w", i, err)
-		}
-		certs = append(certs, cert)
+// ReloadCertificates hot-swaps the multi-cert SNI certificate set without restarting the listener.
+func (h *HTTPListener) ReloadCertificates(tlsCfg config.TLSConfig) error {
+	if h.certs == nil {
+		return fmt.Errorf("listener %s does not use manual TLS", h.id)
 	}
-
-	return certs, nil
+	return h.certs.replace(tlsCfg)
 }
@@ -1,6 +1,7 @@
 package listener
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -8,13 +9,20 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+
 	"github.com/wudi/runway/config"
 )
 
@@ -229,3 +237,268 @@ func TestHTTPListenerHTTP3WithoutTLS(t *testing.T) {
 		t.Error("expected http3Server to be nil without TLS")
 	}
 }
+
+// startTLSListener starts a TLS listener on a free port serving handler.
+func startTLSListener(t *testing.T, handler http.Handler, h2 config.StreamHardeningConfig, onEnforce func(protocol, action string)) (*HTTPListener, string) {
+	t.Helper()
+	certFile, keyFile := generateTestCert(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	l, err := NewHTTPListener(HTTPListenerConfig{
+		ID:              "h2-limits",
+		Address:         addr,
+		Handler:         handler,
+		TLS:             config.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile},
+		HTTP2:           h2,
+		OnStreamEnforce: onEnforce,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		l.Stop(ctx)
+	})
+	return l, addr
+}
+
+// resetFlood opens an HTTP/2 connection, opens and immediately resets n
+// streams, then pings. It reports whether the connection was closed.
+func resetFlood(t *testing.T, addr string, n int) bool {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if p := conn.ConnectionState().NegotiatedProtocol; p != "h2" {
+		t.Fatalf("expected h2 to be negotiated, got %q", p)
+	}
+
+	if _, err := conn.Write([]byte(http2.ClientPreface)); err != nil {
+		t.Fatal(err)
+	}
+	fr := http2.NewFramer(conn, conn)
+	if err := fr.WriteSettings(); err != nil {
+		t.Fatal(err)
+	}
+	var block bytes.Buffer
+	enc := hpack.NewEncoder(&block)
+	for _, f := range [][2]string{{":method", "GET"}, {":scheme", "https"}, {":authority", addr}, {":path", "/"}} {
+		enc.WriteField(hpack.HeaderField{Name: f[0], Value: f[1]})
+	}
+	for i := 0; i < n; i++ {
+		id := uint32(2*i + 1)
+		if err := fr.WriteHeaders(http2.HeadersFrameParam{StreamID: id, BlockFragment: block.Bytes(), EndStream: true, EndHeaders: true}); err != nil {
+			return true
+		}
+		if err := fr.WriteRSTStream(id, http2.ErrCodeCancel); err != nil {
+			return true
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	fr.WritePing(false, [8]byte{1})
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				t.Fatal("timed out waiting for the ping ack or connection close")
+			}
+			return true
+		}
+		if p, ok := f.(*http2.PingFrame); ok && p.IsAck() {
+			return false
+		}
+	}
+}
+
+func TestHTTPListenerHTTP2ResetFlood(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	var enforced atomic.Int64
+	l, addr := startTLSListener(t, handler, config.StreamHardeningConfig{MaxResets: 5}, func(protocol, action string) {
+		if protocol == "h2" && action == ActionResetFlood {
+			enforced.Add(1)
+		}
+	})
+
+	if !resetFlood(t, addr, 20) {
+		t.Fatal("expected the reset flood to close the connection")
+	}
+	if got := l.StreamStats()["h2.reset_flood"]; got != 1 {
+		t.Errorf("expected 1 reset_flood enforcement, got %d", got)
+	}
+	if enforced.Load() != 1 {
+		t.Errorf("expected the enforcement callback once, got %d", enforced.Load())
+	}
+	if resetFlood(t, addr, 3) {
+		t.Error("expected a connection within the limit to stay open")
+	}
+
+	// New connections pick up reloaded limits.
+	l.SetStreamLimits(config.StreamHardeningConfig{MaxResets: 50}, config.StreamHardeningConfig{})
+	if resetFlood(t, addr, 20) {
+		t.Error("expected the raised limit to apply to a new connection")
+	}
+}
+
+func TestHTTPListenerHTTP2HeaderListSize(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	_, addr := startTLSListener(t, handler, config.StreamHardeningConfig{MaxHeaderListSize: 4096}, nil)
+
+	client := &http.Client{Transport: &http2.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	req, _ := http.NewRequest("GET", "https://"+addr+"/", nil)
+	req.Header.Set("X-Small", "ok")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+
+	// The limit is advertised in SETTINGS, so a compliant client refuses
+	// to send the request.
+	req, _ = http.NewRequest("GET", "https://"+addr+"/", nil)
+	req.Header.Set("X-Large", strings.Repeat("a", 5000))
+	if _, err := client.Do(req); err == nil || !strings.Contains(err.Error(), "advertised limit") {
+		t.Errorf("expected the advertised header list limit to apply, got %v", err)
+	}
+}
+
+func TestStreamEnforcerHeaderListSize(t *testing.T) {
+	var e streamEnforcer
+	h := e.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	limits := resolveStreamLimits(config.StreamHardeningConfig{MaxHeaderListSize: 512}, defaultH3MaxStreams, 1<<20)
+	tracker := newConnTracker("h3", limits, func() {})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Large", strings.Repeat("a", 600))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req.WithContext(withConnTracker(req.Context(), tracker)))
+	if rec.Code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("expected 431, got %d", rec.Code)
+	}
+	if got := e.stats()["h3.header_list_size"]; got != 1 {
+		t.Errorf("expected 1 header_list_size enforcement, got %d", got)
+	}
+
+	// Untracked (HTTP/1) requests are not limited here.
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for an untracked request, got %d", rec.Code)
+	}
+}
+
+func TestConnTrackerRequestWindow(t *testing.T) {
+	closed := 0
+	tr := newConnTracker("h2", config.StreamHardeningConfig{MaxRequests: 2, MaxResets: 1, Window: 50 * time.Millisecond}, func() { closed++ })
+	for i := 0; i < 2; i++ {
+		if ok, _ := tr.request(); !ok {
+			t.Fatalf("request %d: expected to be within the limit", i)
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	if ok, _ := tr.request(); !ok {
+		t.Fatal("expected a new window to reset the request count")
+	}
+	tr.request()
+	if ok, c := tr.request(); ok || !c {
+		t.Fatalf("expected the third request in a window to close the connection, got ok=%v closed=%v", ok, c)
+	}
+	if ok, c := tr.reset(); ok || c {
+		t.Errorf("expected a closed connection to reject without closing again, got ok=%v closed=%v", ok, c)
+	}
+	if closed != 1 {
+		t.Errorf("expected close to be called once, got %d", closed)
+	}
+}
+
+func TestHTTPListenerUpdateServerSettings(t *testing.T) {
+	arrived, release := make(chan struct{}), make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(arrived)
+			<-release
+		}
+		w.WriteHeader(200)
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	cfg := HTTPListenerConfig{ID: "web", Address: addr, Handler: handler}
+	l, err := NewHTTPListener(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		l.Stop(ctx)
+	})
+
+	slow := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			slow <- 0
+			return
+		}
+		resp.Body.Close()
+		slow <- resp.StatusCode
+	}()
+	<-arrived
+
+	cfg.MaxHeaderBytes = 1024
+	if err := l.Update(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// New connections get the new header limit; the request in flight on
+	// the previous server completes.
+	req, _ := http.NewRequest("GET", "http://"+addr+"/", nil)
+	req.Header.Set("X-Large", strings.Repeat("a", 8192))
+	resp, err := (&http.Client{Transport: &http.Transport{}}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("large headers after update: status %d, want 431", resp.StatusCode)
+	}
+	close(release)
+	if code := <-slow; code != 200 {
+		t.Errorf("in-flight request: status %d, want 200", code)
+	}
+
+	cfg.Address = "127.0.0.1:0"
+	if err := l.Update(cfg); !errors.Is(err, ErrRestartRequired) {
+		t.Errorf("address change: err = %v, want ErrRestartRequired", err)
+	}
+}
@@ -58,6 +58,13 @@ func (m *Manager) Add(l Listener) error {
 	return nil
 }
 
+// Replace adds a listener, replacing any listener with the same ID
+func (m *Manager) Replace(l Listener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners[l.ID()] = l
+}
+
 // Get returns a listener by ID
 func (m *Manager) Get(id string) (Listener, bool) {
 	m.mu.RLock()
@@ -0,0 +1,259 @@
+package listener
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+
+	"github.com/wudi/runway/config"
+)
+
+// Stream enforcement actions, reported per protocol ("h2" or "h3").
+const (
+	ActionResetFlood     = "reset_flood"      // connection closed after too many client stream resets
+	ActionRequestRate    = "request_rate"     // connection closed after too many requests
+	ActionHeaderListSize = "header_list_size" // request rejected with 431
+)
+
+// Stream limit defaults, safe but permissive.
+const (
+	defaultH2MaxStreams = 250
+	defaultH3MaxStreams = 100
+	defaultMaxResets    = 500
+	defaultMaxRequests  = 20000
+	defaultStreamWindow = 10 * time.Second
+)
+
+// resolveStreamLimits fills in defaults for unset stream limits.
+func resolveStreamLimits(cfg config.StreamHardeningConfig, maxStreams, maxHeaderBytes int) config.StreamHardeningConfig {
+	if cfg.MaxConcurrentStreams == 0 {
+		cfg.MaxConcurrentStreams = maxStreams
+	}
+	if cfg.MaxHeaderListSize == 0 {
+		cfg.MaxHeaderListSize = maxHeaderBytes
+	}
+	if cfg.MaxResets == 0 {
+		cfg.MaxResets = defaultMaxResets
+	}
+	if cfg.MaxRequests == 0 {
+		cfg.MaxRequests = defaultMaxRequests
+	}
+	if cfg.Window == 0 {
+		cfg.Window = defaultStreamWindow
+	}
+	return cfg
+}
+
+// h2Policy is the HTTP/2 server a connection is served with. A new policy
+// is built when the limits or the listener's server change, so connections
+// keep the limits and timeouts they were accepted under.
+type h2Policy struct {
+	limits config.StreamHardeningConfig
+	owner  *http.Server // the listener's server the policy was built for
+	server *http2.Server
+	base   *http.Server // carries max_header_list_size and timeouts into ServeConn
+}
+
+// streamEnforcer tracks the stream limits of a listener's HTTP/2 and
+// HTTP/3 connections and counts enforcement actions.
+type streamEnforcer struct {
+	h2        atomic.Pointer[h2Policy]
+	h3        atomic.Pointer[config.StreamHardeningConfig]
+	onEnforce func(protocol, action string)
+
+	mu     sync.Mutex
+	counts map[string]int64 // "<protocol>.<action>"
+}
+
+// setH2 installs limits for HTTP/2 connections accepted from now on, and
+// hooks the policy's graceful shutdown into srv.
+func (e *streamEnforcer) setH2(limits config.StreamHardeningConfig, srv *http.Server) {
+	if p := e.h2.Load(); p != nil && p.limits == limits && p.owner == srv {
+		return
+	}
+	p := &h2Policy{
+		limits: limits,
+		owner:  srv,
+		server: &http2.Server{MaxConcurrentStreams: uint32(limits.MaxConcurrentStreams)},
+		base: &http.Server{
+			ReadTimeout:       srv.ReadTimeout,
+			WriteTimeout:      srv.WriteTimeout,
+			IdleTimeout:       srv.IdleTimeout,
+			ReadHeaderTimeout: srv.ReadHeaderTimeout,
+			MaxHeaderBytes:    limits.MaxHeaderListSize,
+		},
+	}
+	// ConfigureServer prepares the policy for graceful shutdown, which is
+	// triggered by shutting down its base server.
+	if err := http2.ConfigureServer(p.base, p.server); err == nil {
+		srv.RegisterOnShutdown(func() { p.base.Shutdown(context.Background()) })
+	}
+	e.h2.Store(p)
+}
+
+// serveH2 serves an HTTP/2 connection negotiated over TLS. It replaces the
+// net/http default so each connection gets the current limits and a
+// tracker.
+func (e *streamEnforcer) serveH2(hs *http.Server, c *tls.Conn, h http.Handler) {
+	p := e.h2.Load()
+	ctx := context.Background()
+	if bc, ok := h.(interface{ BaseContext() context.Context }); ok {
+		ctx = bc.BaseContext()
+	}
+	t := newConnTracker("h2", p.limits, func() { c.Close() })
+	p.server.ServeConn(c, &http2.ServeConnOpts{
+		Context:    withConnTracker(ctx, t),
+		BaseConfig: p.base,
+		Handler:    h,
+	})
+}
+
+// quicConfig returns the QUIC settings for a new HTTP/3 connection.
+func (e *streamEnforcer) quicConfig(*quic.ClientInfo) (*quic.Config, error) {
+	return &quic.Config{
+		Allow0RTT:          true,
+		MaxIncomingStreams: int64(e.h3.Load().MaxConcurrentStreams),
+	}, nil
+}
+
+// h3ConnContext attaches a tracker to a new HTTP/3 connection.
+func (e *streamEnforcer) h3ConnContext(ctx context.Context, c *quic.Conn) context.Context {
+	t := newConnTracker("h3", *e.h3.Load(), func() {
+		c.CloseWithError(quic.ApplicationErrorCode(http3.ErrCodeExcessiveLoad), "stream limits exceeded")
+	})
+	return withConnTracker(ctx, t)
+}
+
+// wrap enforces the tracked connection's limits on each request. Requests
+// on untracked (HTTP/1) connections pass through.
+func (e *streamEnforcer) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, _ := r.Context().Value(connTrackerKey{}).(*connTracker)
+		if t == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok, closed := t.request(); !ok {
+			if closed {
+				e.enforce(t.protocol, ActionRequestRate)
+			}
+			return
+		}
+		if headerListSize(r) > t.limits.MaxHeaderListSize {
+			e.enforce(t.protocol, ActionHeaderListSize)
+			http.Error(w, http.StatusText(http.StatusRequestHeaderFieldsTooLarge), http.StatusRequestHeaderFieldsTooLarge)
+			return
+		}
+		next.ServeHTTP(w, r)
+		if r.Context().Err() != nil {
+			if _, closed := t.reset(); closed {
+				e.enforce(t.protocol, ActionResetFlood)
+			}
+		}
+	})
+}
+
+func (e *streamEnforcer) enforce(protocol, action string) {
+	e.mu.Lock()
+	if e.counts == nil {
+		e.counts = make(map[string]int64)
+	}
+	e.counts[protocol+"."+action]++
+	e.mu.Unlock()
+	if e.onEnforce != nil {
+		e.onEnforce(protocol, action)
+	}
+}
+
+// stats returns enforcement counts keyed "<protocol>.<action>".
+func (e *streamEnforcer) stats() map[string]int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make(map[string]int64, len(e.counts))
+	for k, v := range e.counts {
+		out[k] = v
+	}
+	return out
+}
+
+type connTrackerKey struct{}
+
+func withConnTracker(ctx context.Context, t *connTracker) context.Context {
+	return context.WithValue(ctx, connTrackerKey{}, t)
+}
+
+// connTracker counts the requests and client resets of one connection in
+// fixed windows and closes the connection once a limit is exceeded.
+type connTracker struct {
+	protocol string
+	limits   config.StreamHardeningConfig
+	close    func()
+
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int
+	resets      int
+	closed      bool
+}
+
+func newConnTracker(protocol string, limits config.StreamHardeningConfig, close func()) *connTracker {
+	return &connTracker{protocol: protocol, limits: limits, close: close, windowStart: time.Now()}
+}
+
+// request records a new request. ok is false once the connection is
+// closed; closed reports whether this request exceeded the limit and
+// closed it.
+func (t *connTracker) request() (ok, closed bool) {
+	return t.record(func() bool {
+		t.requests++
+		return t.requests <= t.limits.MaxRequests
+	})
+}
+
+// reset records a stream the client abandoned, with the results of request.
+func (t *connTracker) reset() (ok, closed bool) {
+	return t.record(func() bool {
+		t.resets++
+		return t.resets <= t.limits.MaxResets
+	})
+}
+
+func (t *connTracker) record(within func() bool) (ok, closed bool) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return false, false
+	}
+	if now := time.Now(); now.Sub(t.windowStart) >= t.limits.Window {
+		t.windowStart, t.requests, t.resets = now, 0, 0
+	}
+	if within() {
+		t.mu.Unlock()
+		return true, false
+	}
+	t.closed = true
+	t.mu.Unlock()
+	t.close()
+	return false, true
+}
+
+// headerListSize returns the request's header list size as HTTP/2 and
+// HTTP/3 account it: name and value lengths plus 32 bytes per field,
+// including pseudo-headers.
+func headerListSize(r *http.Request) int {
+	n := len(":method") + len(r.Method) + len(":path") + len(r.URL.RequestURI()) +
+		len(":authority") + len(r.Host) + len(":scheme") + len("https") + 4*32
+	for name, values := range r.Header {
+		for _, v := range values {
+			n += len(name) + len(v) + 32
+		}
+	}
+	return n
+}
@@ -22,12 +22,16 @@ type TCPListener struct {
 	listener    net.Listener
 	proxy       *tcp.Proxy
 	tlsCfg      *tls.Config
+	certs       *certManager // set when terminating TLS
 	sniRouting  bool
-	idleTimeout time.Duration
+	idleTimeout atomic.Int64 // time.Duration
 	activeConns int64
 	connWg      sync.WaitGroup
 	closeCh     chan struct{}
 	closeOnce   sync.Once
+
+	mu  sync.Mutex       // serializes Update
+	tls config.TLSConfig // last applied
 }
 
 // TCPListenerConfig holds configuration for creating a TCP listener
@@ -38,41 +42,48 @@ type TCPListenerConfig struct {
 	TLS         config.TLSConfig
 	SNIRouting  bool
 	IdleTimeout time.Duration
+
+	OnCertReloadError func(*CertReloadError) // called when a changed certificate fails to load
 }
 
 // NewTCPListener creates a new TCP listener
 func NewTCPListener(cfg TCPListenerConfig) (*TCPListener, error) {
 	l := &TCPListener{
-		id:          cfg.ID,
-		address:     cfg.Address,
-		proxy:       cfg.Proxy,
-		sniRouting:  cfg.SNIRouting,
-		idleTimeout: cfg.IdleTimeout,
-		closeCh:     make(chan struct{}),
+		id:         cfg.ID,
+		address:    cfg.Address,
+		proxy:      cfg.Proxy,
+		sniRouting: cfg.SNIRouting,
+		closeCh:    make(chan struct{}),
+		tls:        cfg.TLS,
 	}
+	l.idleTimeout.Store(int64(tcpIdleTimeout(cfg.IdleTimeout)))
 
 	// Set up TLS if enabled (but don't terminate - just for verification)
 	// For SNI routing, we peek at the handshake without terminating
 	if cfg.TLS.Enabled && !cfg.SNIRouting {
-		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		certs, err := newCertManager(cfg.ID, cfg.TLS, cfg.OnCertReloadError)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load TLS certificates: %w", err)
+			return nil, err
 		}
+		l.certs = certs
 
 		l.tlsCfg = &tls.Config{
-			Certificates: []tls.Certificate{cert},
-			MinVersion:   tls.VersionTLS12,
+			GetCertificate: certs.getCertificate,
+			MinVersion:     tls.VersionTLS12,
 		}
 	}
 
-	// Apply defaults
-	if l.idleTimeout == 0 {
-		l.idleTimeout = 5 * time.Minute
-	}
-
 	return l, nil
 }
 
+// tcpIdleTimeout applies the default idle timeout.
+func tcpIdleTimeout(d time.Duration) time.Duration {
+	if d == 0 {
+		return 5 * time.Minute
+	}
+	return d
+}
+
 // ID returns the listener ID
 func (l *TCPListener) ID() string {
 	return l.id
@@ -99,6 +110,9 @@ func (l *TCPListener) Start(ctx context.Context) error {
 	if l.tlsCfg != nil {
 		ln = tls.NewListener(ln, l.tlsCfg)
 	}
+	if l.certs != nil {
+		l.certs.start()
+	}
 
 	l.listener = ln
 
@@ -156,8 +170,8 @@ func (l *TCPListener) handleConn(ctx context.Context, conn net.Conn) {
 	}()
 
 	// Set idle timeout
-	if l.idleTimeout > 0 {
-		conn.SetDeadline(time.Now().Add(l.idleTimeout))
+	if idle := time.Duration(l.idleTimeout.Load()); idle > 0 {
+		conn.SetDeadline(time.Now().Add(idle))
 	}
 
 	// Delegate to proxy
@@ -171,6 +185,9 @@ func (l *TCPListener) Stop(ctx context.Context) error {
 	l.closeOnce.Do(func() {
 		close(l.closeCh)
 	})
+	if l.certs != nil {
+		l.certs.stop()
+	}
 
 	if l.listener != nil {
 		l.listener.Close()
@@ -193,7 +210,62 @@ func (l *TCPListener) Stop(ctx context.Context) error {
 	return nil
 }
 
+// Release stops accepting connections so another listener can bind the
+// address; Stop waits for the open ones.
+func (l *TCPListener) Release() {
+	l.closeOnce.Do(func() {
+		close(l.closeCh)
+	})
+	if l.listener != nil {
+		l.listener.Close()
+	}
+}
+
 // ActiveConnections returns the number of active connections
 func (l *TCPListener) ActiveConnections() int64 {
 	return atomic.LoadInt64(&l.activeConns)
 }
+
+// CertStatus reports the TLS certificates served, or nil when the listener
+// does not terminate TLS.
+func (l *TCPListener) CertStatus() *CertStatus {
+	if l.certs == nil {
+		return nil
+	}
+	st := l.certs.status()
+	return &st
+}
+
+// SetCertReload applies new certificate reload settings.
+func (l *TCPListener) SetCertReload(rc config.TLSReloadConfig) {
+	if l.certs != nil {
+		l.certs.setReload(rc)
+	}
+}
+
+// Update applies cfg to the running listener in place: the certificates
+// and reload settings of TLS termination, and the idle timeout of new
+// connections. It returns ErrRestartRequired when the address, TLS
+// termination or SNI routing changes, and keeps the current certificates
+// when the new ones fail to load.
+func (l *TCPListener) Update(cfg TCPListenerConfig) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	terminate := cfg.TLS.Enabled && !cfg.SNIRouting
+	if cfg.Address != l.address || cfg.SNIRouting != l.sniRouting || terminate != (l.certs != nil) {
+		return ErrRestartRequired
+	}
+	if l.certs != nil {
+		if certsChanged(l.tls, cfg.TLS) {
+			if err := l.certs.replace(cfg.TLS); err != nil {
+				return err
+			}
+		} else {
+			l.certs.refresh()
+		}
+		l.certs.setReload(cfg.TLS.Reload)
+	}
+	l.tls = cfg.TLS
+	l.idleTimeout.Store(int64(tcpIdleTimeout(cfg.IdleTimeout)))
+	return nil
+}
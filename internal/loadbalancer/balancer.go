@@ -12,10 +12,14 @@ type Backend struct {
 	URL            string
 	Weight         int
 	Healthy        bool
+	Drained        bool // taken out of rotation by an operator; health changes leave it set
 	ActiveRequests int64
 	ParsedURL      *url.URL // pre-parsed URL to avoid per-request parsing
 }
 
+// InRotation reports whether the backend may be selected: healthy and not drained.
+func (b *Backend) InRotation() bool { return b.Healthy && !b.Drained }
+
 // InitParsedURL pre-parses the backend URL for use in the proxy hot path.
 // Errors are silently ignored; the proxy falls back to url.Parse if ParsedURL is nil.
 func (b *Backend) InitParsedURL() {
@@ -43,7 +47,7 @@ type Balancer interface {
 	MarkUnhealthy(url string)
 	// GetBackends returns all backends
 	GetBackends() []*Backend
-	// HealthyCount returns the number of healthy backends
+	// HealthyCount returns the number of healthy, non-drained backends
 	HealthyCount() int
 	// GetBackendByURL returns the original Backend pointer for a URL, or nil.
 	// Used by switch_backend rule action to route to a specific backend
@@ -51,6 +55,14 @@ type Balancer interface {
 	GetBackendByURL(url string) *Backend
 }
 
+// Drainer is implemented by balancers whose backends can be drained: taken
+// out of rotation administratively, independent of their health.
+type Drainer interface {
+	// SetDrained drains or undrains the backend with the given URL and
+	// reports whether the balancer has such a backend.
+	SetDrained(url string, drained bool) bool
+}
+
 // baseBalancer provides common functionality for balancers
 type baseBalancer struct {
 	backends      []*Backend
@@ -74,7 +86,7 @@ func (b *baseBalancer) buildIndex() {
 func (b *baseBalancer) rebuildHealthyCache() {
 	healthy := make([]*Backend, 0, len(b.backends))
 	for _, be := range b.backends {
-		if be.Healthy {
+		if be.InRotation() {
 			healthy = append(healthy, be)
 		}
 	}
@@ -94,11 +106,12 @@ func (b *baseBalancer) UpdateBackends(backends []*Backend) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// Preserve health status for existing backends (reuse old index for O(1) lookup)
+	// Preserve health and drain status for existing backends (reuse old index for O(1) lookup)
 	if b.urlIndex != nil {
 		for _, backend := range backends {
 			if idx, ok := b.urlIndex[backend.URL]; ok {
 				backend.Healthy = b.backends[idx].Healthy
+				backend.Drained = b.backends[idx].Drained
 			} else {
 				backend.Healthy = true
 			}
@@ -135,6 +148,20 @@ func (b *baseBalancer) MarkUnhealthy(url string) {
 	}
 }
 
+// SetDrained drains or undrains a backend. Unlike MarkUnhealthy, a drain is
+// only lifted by SetDrained, so health checks cannot put the backend back.
+func (b *baseBalancer) SetDrained(url string, drained bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	idx, ok := b.urlIndex[url]
+	if ok {
+		b.backends[idx].Drained = drained
+		b.rebuildHealthyCache()
+	}
+	return ok
+}
+
 // GetBackends returns a copy of all backends
 func (b *baseBalancer) GetBackends() []*Backend {
 	b.mu.RLock()
@@ -146,20 +173,21 @@ func (b *baseBalancer) GetBackends() []*Backend {
 			URL:            backend.URL,
 			Weight:         backend.Weight,
 			Healthy:        backend.Healthy,
+			Drained:        backend.Drained,
 			ActiveRequests: atomic.LoadInt64(&backend.ActiveRequests),
 		}
 	}
 	return result
 }
 
-// HealthyCount returns the number of healthy backends
+// HealthyCount returns the number of healthy, non-drained backends
 func (b *baseBalancer) HealthyCount() int {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
 	count := 0
 	for _, backend := range b.backends {
-		if backend.Healthy {
+		if backend.InRotation() {
 			count++
 		}
 	}
@@ -183,15 +211,15 @@ type RequestAwareBalancer interface {
 	NextForHTTPRequest(r *http.Request) (*Backend, string)
 }
 
-// healthyBackends returns a slice of healthy backends (caller must hold lock).
-// Returns the backends slice directly when all are healthy (zero allocations).
+// healthyBackends returns a slice of healthy, non-drained backends (caller must hold lock).
+// Returns the backends slice directly when all are in rotation (zero allocations).
 func (b *baseBalancer) healthyBackends() []*Backend {
 	for _, backend := range b.backends {
-		if !backend.Healthy {
-			// At least one unhealthy: allocate filtered slice.
+		if !backend.InRotation() {
+			// At least one out of rotation: allocate filtered slice.
 			healthy := make([]*Backend, 0, len(b.backends))
 			for _, be := range b.backends {
-				if be.Healthy {
+				if be.InRotation() {
 					healthy = append(healthy, be)
 				}
 			}
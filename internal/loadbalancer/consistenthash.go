@@ -165,6 +165,13 @@ func (ch *ConsistentHash) MarkHealthy(url string) {
 	ch.rebuildRing()
 }
 
+// SetDrained drains or undrains a backend and rebuilds the ring.
+func (ch *ConsistentHash) SetDrained(url string, drained bool) bool {
+	ok := ch.baseBalancer.SetDrained(url, drained)
+	ch.rebuildRing()
+	return ok
+}
+
 // MarkUnhealthy marks a backend unhealthy and rebuilds the ring.
 func (ch *ConsistentHash) MarkUnhealthy(url string) {
 	ch.baseBalancer.MarkUnhealthy(url)
@@ -1,6 +1,7 @@
 package loadbalancer
 
 import (
+	"fmt"
 	"net/http"
 	"testing"
 
@@ -128,6 +129,23 @@ func TestConsistentHashAllUnhealthy(t *testing.T) {
 	}
 }
 
+func TestConsistentHashDrain(t *testing.T) {
+	backends := []*Backend{
+		{URL: "http://a:8080", Weight: 1, Healthy: true},
+		{URL: "http://b:8080", Weight: 1, Healthy: true},
+	}
+	ch := NewConsistentHash(backends, config.ConsistentHashConfig{Key: "ip"})
+
+	ch.SetDrained("http://a:8080", true)
+	for i := 0; i < 20; i++ {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = fmt.Sprintf("10.0.0.%d:1234", i)
+		if b, _ := ch.NextForHTTPRequest(req); b == nil || b.URL != "http://b:8080" {
+			t.Fatalf("expected drained backend removed from the ring, got %v", b)
+		}
+	}
+}
+
 func TestConsistentHashIPMode(t *testing.T) {
 	backends := []*Backend{
 		{URL: "http://a:8080", Weight: 1, Healthy: true},
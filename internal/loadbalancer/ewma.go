@@ -0,0 +1,238 @@
+package loadbalancer
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/randutil"
+)
+
+// Peak-EWMA defaults.
+const (
+	DefaultEWMADecayTime     = 10 * time.Second
+	DefaultEWMANoDataPenalty = 500 * time.Millisecond
+)
+
+// ewmaStaleFactor is the number of decay time constants after which a
+// backend's average no longer counts as recent data (its weight is below 1%).
+const ewmaStaleFactor = 5
+
+// peakEWMA is a backend's latency average. It jumps to any sample above
+// the average and decays towards lower ones by the time since the last
+// sample, so a slowing backend is penalized at once and recovers gradually.
+type peakEWMA struct {
+	mu    sync.Mutex
+	cost  float64   // milliseconds
+	stamp time.Time // last sample; zero when there is none
+}
+
+func (e *peakEWMA) observe(rtt float64, now time.Time, tau float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stamp.IsZero() {
+		e.cost = rtt
+	} else if rtt > e.cost {
+		e.cost = rtt
+	} else {
+		td := math.Max(float64(now.Sub(e.stamp)), 0)
+		w := math.Exp(-td / tau)
+		e.cost = e.cost*w + rtt*(1-w)
+	}
+	e.stamp = now
+}
+
+// get returns the average and whether it is recent.
+func (e *peakEWMA) get(now time.Time, staleAfter time.Duration) (float64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stamp.IsZero() || now.Sub(e.stamp) > staleAfter {
+		return 0, false
+	}
+	return e.cost, true
+}
+
+func (e *peakEWMA) reset() {
+	e.mu.Lock()
+	e.cost = 0
+	e.stamp = time.Time{}
+	e.mu.Unlock()
+}
+
+// PeakEWMA implements peak-EWMA load balancing. Each backend is scored by
+// its latency average times its in-flight requests plus one, and picked at
+// random with probability inversely proportional to its score (scaled by
+// its weight), so load spreads while faster backends get more of it.
+// Backends without recent samples are scored at the no-data penalty so new
+// and recovered backends are probed gently.
+type PeakEWMA struct {
+	baseBalancer
+	latencies map[string]*peakEWMA
+	latMu     sync.RWMutex
+	tau       float64 // decay time constant in nanoseconds
+	staleAt   time.Duration
+	penalty   float64 // milliseconds
+
+	now func() time.Time // indirection for tests
+}
+
+// NewPeakEWMA creates a new peak-EWMA balancer.
+func NewPeakEWMA(backends []*Backend, cfg config.EWMAConfig) *PeakEWMA {
+	decay := cfg.DecayTime
+	if decay <= 0 {
+		decay = DefaultEWMADecayTime
+	}
+	penalty := cfg.NoDataPenalty
+	if penalty <= 0 {
+		penalty = DefaultEWMANoDataPenalty
+	}
+	p := &PeakEWMA{
+		latencies: make(map[string]*peakEWMA),
+		tau:       float64(decay),
+		staleAt:   ewmaStaleFactor * decay,
+		penalty:   float64(penalty) / float64(time.Millisecond),
+		now:       time.Now,
+	}
+	for _, b := range backends {
+		if b.Weight == 0 {
+			b.Weight = 1
+		}
+		p.latencies[b.URL] = &peakEWMA{}
+	}
+	p.backends = backends
+	p.buildIndex()
+	return p
+}
+
+// RecordLatency records a response time observation for a backend.
+func (p *PeakEWMA) RecordLatency(url string, d time.Duration) {
+	p.latMu.RLock()
+	e, ok := p.latencies[url]
+	p.latMu.RUnlock()
+	if ok {
+		e.observe(float64(d)/float64(time.Millisecond), p.now(), p.tau)
+	}
+}
+
+// score returns a backend's latency average (or the no-data penalty) and
+// its score. Caller must hold latMu.
+func (p *PeakEWMA) score(b *Backend, now time.Time) (float64, float64, bool) {
+	cost, ok := 0.0, false
+	if e := p.latencies[b.URL]; e != nil {
+		cost, ok = e.get(now, p.staleAt)
+	}
+	if !ok {
+		cost = p.penalty
+	}
+	// Sub-microsecond averages would make the inverse blow up.
+	cost = math.Max(cost, 0.001)
+	return cost, cost * float64(atomic.LoadInt64(&b.ActiveRequests)+1), ok
+}
+
+// Next picks a healthy backend at random, weighted by weight / score.
+func (p *PeakEWMA) Next() *Backend {
+	healthy := p.CachedHealthyBackends()
+	switch len(healthy) {
+	case 0:
+		return nil
+	case 1:
+		return healthy[0]
+	}
+
+	now := p.now()
+	var stack [16]float64
+	weights := stack[:0]
+	total := 0.0
+
+	p.latMu.RLock()
+	for _, b := range healthy {
+		_, s, _ := p.score(b, now)
+		w := float64(b.Weight) / s
+		weights = append(weights, w)
+		total += w
+	}
+	p.latMu.RUnlock()
+
+	r := randutil.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return healthy[i]
+		}
+		r -= w
+	}
+	return healthy[len(healthy)-1]
+}
+
+// MarkHealthy marks a backend as healthy. A backend recovering from
+// unhealthy or ejected starts over without data.
+func (p *PeakEWMA) MarkHealthy(url string) {
+	p.mu.RLock()
+	idx, ok := p.urlIndex[url]
+	recovered := ok && !p.backends[idx].Healthy
+	p.mu.RUnlock()
+
+	p.baseBalancer.MarkHealthy(url)
+	if recovered {
+		p.latMu.RLock()
+		if e, ok := p.latencies[url]; ok {
+			e.reset()
+		}
+		p.latMu.RUnlock()
+	}
+}
+
+// EWMAScore is a backend's peak-EWMA state.
+type EWMAScore struct {
+	LatencyMs float64 `json:"latency_ms"`
+	InFlight  int64   `json:"in_flight"`
+	Score     float64 `json:"score"`
+	NoData    bool    `json:"no_data"`
+}
+
+// GetScores returns a snapshot of the peak-EWMA state per backend URL.
+func (p *PeakEWMA) GetScores() map[string]EWMAScore {
+	now := p.now()
+	p.mu.RLock()
+	backends := p.backends
+	p.mu.RUnlock()
+
+	p.latMu.RLock()
+	defer p.latMu.RUnlock()
+	result := make(map[string]EWMAScore, len(backends))
+	for _, b := range backends {
+		cost, s, ok := p.score(b, now)
+		result[b.URL] = EWMAScore{
+			LatencyMs: cost,
+			InFlight:  atomic.LoadInt64(&b.ActiveRequests),
+			Score:     s,
+			NoData:    !ok,
+		}
+	}
+	return result
+}
+
+// UpdateBackends updates backends and adds/removes latency trackers.
+func (p *PeakEWMA) UpdateBackends(backends []*Backend) {
+	p.baseBalancer.UpdateBackends(backends)
+
+	p.latMu.Lock()
+	defer p.latMu.Unlock()
+
+	newSet := make(map[string]bool, len(backends))
+	for _, b := range backends {
+		if b.Weight == 0 {
+			b.Weight = 1
+		}
+		newSet[b.URL] = true
+		if _, ok := p.latencies[b.URL]; !ok {
+			p.latencies[b.URL] = &peakEWMA{}
+		}
+	}
+	for url := range p.latencies {
+		if !newSet[url] {
+			delete(p.latencies, url)
+		}
+	}
+}
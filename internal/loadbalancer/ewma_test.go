@@ -0,0 +1,224 @@
+package loadbalancer
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/randutil"
+)
+
+func newTestPeakEWMA(urls ...string) (*PeakEWMA, *time.Time) {
+	var backends []*Backend
+	for _, u := range urls {
+		backends = append(backends, &Backend{URL: u, Weight: 1, Healthy: true})
+	}
+	p := NewPeakEWMA(backends, config.EWMAConfig{})
+	now := time.Unix(1700000000, 0)
+	p.now = func() time.Time { return now }
+	return p, &now
+}
+
+func TestPeakEWMAPeakAndDecay(t *testing.T) {
+	p, now := newTestPeakEWMA("http://a:8080")
+
+	p.RecordLatency("http://a:8080", 10*time.Millisecond)
+	// A slower sample is taken at once.
+	p.RecordLatency("http://a:8080", 100*time.Millisecond)
+	if got := p.GetScores()["http://a:8080"].LatencyMs; got != 100 {
+		t.Fatalf("expected peak of 100ms, got %v", got)
+	}
+
+	// A faster sample right away barely moves the average...
+	p.RecordLatency("http://a:8080", 10*time.Millisecond)
+	if got := p.GetScores()["http://a:8080"].LatencyMs; got != 100 {
+		t.Fatalf("expected no decay without elapsed time, got %v", got)
+	}
+	// ...while one a decay time constant later moves it most of the way.
+	*now = now.Add(DefaultEWMADecayTime)
+	p.RecordLatency("http://a:8080", 10*time.Millisecond)
+	got := p.GetScores()["http://a:8080"].LatencyMs
+	if got < 40 || got > 45 {
+		t.Fatalf("expected ~43ms after one time constant, got %v", got)
+	}
+}
+
+func TestPeakEWMANoDataPenalty(t *testing.T) {
+	p, now := newTestPeakEWMA("http://a:8080", "http://b:8080")
+	p.RecordLatency("http://a:8080", 20*time.Millisecond)
+
+	scores := p.GetScores()
+	if s := scores["http://b:8080"]; !s.NoData || s.LatencyMs != 500 {
+		t.Fatalf("expected the new backend scored at the 500ms penalty, got %+v", s)
+	}
+	if s := scores["http://a:8080"]; s.NoData || s.LatencyMs != 20 {
+		t.Fatalf("expected the sampled backend scored at 20ms, got %+v", s)
+	}
+
+	// Samples older than five time constants no longer count.
+	*now = now.Add(ewmaStaleFactor*DefaultEWMADecayTime + time.Second)
+	if s := p.GetScores()["http://a:8080"]; !s.NoData {
+		t.Fatalf("expected stale data to fall back to the penalty, got %+v", s)
+	}
+}
+
+func TestPeakEWMASpreadsByScore(t *testing.T) {
+	randutil.Seed(1)
+	p, _ := newTestPeakEWMA("http://fast:8080", "http://slow:8080")
+	p.RecordLatency("http://fast:8080", 10*time.Millisecond)
+	p.RecordLatency("http://slow:8080", 40*time.Millisecond)
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		counts[p.Next().URL]++
+	}
+	// Inverse scores 1/10 and 1/40: the slow backend gets ~20%, not 0%.
+	if share := float64(counts["http://slow:8080"]) / 10000; share < 0.17 || share > 0.23 {
+		t.Fatalf("expected ~20%% to the slower backend, got %.3f", share)
+	}
+
+	// In-flight requests raise the score: with 3 in flight, fast is scored 40.
+	fast := p.GetBackendByURL("http://fast:8080")
+	for i := 0; i < 3; i++ {
+		fast.IncrActive()
+	}
+	counts = map[string]int{}
+	for i := 0; i < 10000; i++ {
+		counts[p.Next().URL]++
+	}
+	if share := float64(counts["http://slow:8080"]) / 10000; share < 0.45 || share > 0.55 {
+		t.Fatalf("expected an even split once fast has requests in flight, got %.3f", share)
+	}
+}
+
+func TestPeakEWMAHealthAndRecovery(t *testing.T) {
+	p, _ := newTestPeakEWMA("http://a:8080", "http://b:8080")
+	p.RecordLatency("http://a:8080", 10*time.Millisecond)
+	p.RecordLatency("http://b:8080", 10*time.Millisecond)
+
+	// Unhealthy or ejected backends are never picked.
+	p.MarkUnhealthy("http://a:8080")
+	for i := 0; i < 100; i++ {
+		if got := p.Next(); got.URL != "http://b:8080" {
+			t.Fatalf("expected only b while a is out, got %s", got.URL)
+		}
+	}
+
+	// A recovered backend starts over at the penalty.
+	p.MarkHealthy("http://a:8080")
+	if s := p.GetScores()["http://a:8080"]; !s.NoData {
+		t.Fatalf("expected the recovered backend to have no data, got %+v", s)
+	}
+	// Marking an already healthy backend keeps its data.
+	p.MarkHealthy("http://b:8080")
+	if s := p.GetScores()["http://b:8080"]; s.NoData {
+		t.Fatalf("expected b to keep its data, got %+v", s)
+	}
+
+	p.MarkUnhealthy("http://b:8080")
+	p.MarkUnhealthy("http://a:8080")
+	if got := p.Next(); got != nil {
+		t.Fatalf("expected nil with no healthy backends, got %v", got)
+	}
+}
+
+func TestPeakEWMAUpdateBackends(t *testing.T) {
+	p, _ := newTestPeakEWMA("http://a:8080", "http://b:8080")
+	p.RecordLatency("http://a:8080", 10*time.Millisecond)
+
+	p.UpdateBackends([]*Backend{
+		{URL: "http://a:8080", Weight: 1},
+		{URL: "http://c:8080"},
+	})
+	scores := p.GetScores()
+	if _, ok := scores["http://b:8080"]; ok {
+		t.Error("expected removed backend dropped from scores")
+	}
+	if s := scores["http://a:8080"]; s.NoData {
+		t.Error("expected a to keep its data across updates")
+	}
+	if s := scores["http://c:8080"]; !s.NoData {
+		t.Error("expected the added backend to start without data")
+	}
+}
+
+// simulateP99 sends one request per millisecond for the duration, with
+// backend latency given by latency(url, t), and returns the p99 latency of
+// requests sent after degradeAt.
+func simulateP99(t *testing.T, b Balancer, setNow func(time.Time), latency func(url string, at time.Duration) time.Duration, degradeAt, duration time.Duration) time.Duration {
+	t.Helper()
+	type inflight struct {
+		backend *Backend
+		done    time.Duration
+		latency time.Duration
+	}
+	recorder, _ := b.(LatencyRecorder)
+	start := time.Unix(1700000000, 0)
+	var pending []inflight
+	var measured []time.Duration
+
+	for at := time.Duration(0); at < duration; at += time.Millisecond {
+		setNow(start.Add(at))
+		kept := pending[:0]
+		for _, req := range pending {
+			if req.done > at {
+				kept = append(kept, req)
+				continue
+			}
+			req.backend.DecrActive()
+			if recorder != nil {
+				recorder.RecordLatency(req.backend.URL, req.latency)
+			}
+		}
+		pending = kept
+
+		backend := b.Next()
+		backend.IncrActive()
+		lat := latency(backend.URL, at)
+		pending = append(pending, inflight{backend, at + lat, lat})
+		if at >= degradeAt {
+			measured = append(measured, lat)
+		}
+	}
+
+	sort.Slice(measured, func(i, j int) bool { return measured[i] < measured[j] })
+	return measured[len(measured)*99/100]
+}
+
+func TestPeakEWMABeatsRoundRobinWhenBackendDegrades(t *testing.T) {
+	randutil.Seed(42)
+	urls := []string{"http://a:8080", "http://b:8080", "http://c:8080", "http://d:8080", "http://e:8080"}
+	// Every backend answers in 10ms until e degrades to 1s after 5s.
+	degradeAt := 5 * time.Second
+	latency := func(url string, at time.Duration) time.Duration {
+		if url == "http://e:8080" && at >= degradeAt {
+			return time.Second
+		}
+		return 10 * time.Millisecond
+	}
+
+	newBackends := func() []*Backend {
+		var bs []*Backend
+		for _, u := range urls {
+			bs = append(bs, &Backend{URL: u, Weight: 1, Healthy: true})
+		}
+		return bs
+	}
+
+	rr := NewRoundRobin(newBackends())
+	rrP99 := simulateP99(t, rr, func(time.Time) {}, latency, degradeAt, 20*time.Second)
+
+	pe := NewPeakEWMA(newBackends(), config.EWMAConfig{})
+	var now time.Time
+	pe.now = func() time.Time { return now }
+	peP99 := simulateP99(t, pe, func(t time.Time) { now = t }, latency, degradeAt, 20*time.Second)
+
+	if rrP99 != time.Second {
+		t.Fatalf("expected round_robin p99 at the degraded latency, got %v", rrP99)
+	}
+	if peP99 >= rrP99/10 {
+		t.Fatalf("expected ewma p99 well below round_robin's %v, got %v", rrP99, peP99)
+	}
+	t.Logf("p99 after degradation: round_robin=%v ewma=%v", rrP99, peP99)
+}
@@ -153,6 +153,57 @@ func TestHealthyCount(t *testing.T) {
 	}
 }
 
+func TestRoundRobinDrain(t *testing.T) {
+	backends := []*Backend{
+		{URL: "http://server1:8080", Weight: 1, Healthy: true},
+		{URL: "http://server2:8080", Weight: 1, Healthy: true},
+	}
+
+	rr := NewRoundRobin(backends)
+
+	if !rr.SetDrained("http://server1:8080", true) {
+		t.Fatal("expected server1 to be found")
+	}
+	if rr.SetDrained("http://unknown:8080", true) {
+		t.Error("expected unknown backend not to be found")
+	}
+
+	// A health check passing must not put a drained backend back.
+	rr.MarkHealthy("http://server1:8080")
+	for i := 0; i < 5; i++ {
+		if b := rr.Next(); b.URL != "http://server2:8080" {
+			t.Fatalf("expected only server2 while server1 is drained, got %s", b.URL)
+		}
+	}
+	if rr.HealthyCount() != 1 {
+		t.Errorf("expected healthy count 1, got %d", rr.HealthyCount())
+	}
+
+	// Drain survives backend updates for backends still present.
+	rr.UpdateBackends([]*Backend{
+		{URL: "http://server1:8080", Weight: 1},
+		{URL: "http://server2:8080", Weight: 1},
+	})
+	var drained *Backend
+	for _, b := range rr.GetBackends() {
+		if b.URL == "http://server1:8080" {
+			drained = b
+		}
+	}
+	if drained == nil || !drained.Drained || !drained.Healthy {
+		t.Fatalf("expected server1 healthy and still drained, got %+v", drained)
+	}
+
+	rr.SetDrained("http://server1:8080", false)
+	results := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		results[rr.Next().URL]++
+	}
+	if results["http://server1:8080"] != 5 {
+		t.Errorf("expected server1 back in rotation, got %d of 10", results["http://server1:8080"])
+	}
+}
+
 func TestWeightedRoundRobin(t *testing.T) {
 	backends := []*Backend{
 		{URL: "http://server1:8080", Weight: 3, Healthy: true},
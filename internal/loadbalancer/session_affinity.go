@@ -71,6 +71,14 @@ func (s *SessionAffinityBalancer) MarkUnhealthy(url string) {
 	s.inner.MarkUnhealthy(url)
 }
 
+// SetDrained delegates to the inner balancer.
+func (s *SessionAffinityBalancer) SetDrained(url string, drained bool) bool {
+	if d, ok := s.inner.(Drainer); ok {
+		return d.SetDrained(url, drained)
+	}
+	return false
+}
+
 // GetBackends delegates to the inner balancer.
 func (s *SessionAffinityBalancer) GetBackends() []*Backend {
 	return s.inner.GetBackends()
@@ -96,7 +104,7 @@ func (s *SessionAffinityBalancer) NextForHTTPRequest(r *http.Request) (*Backend,
 		if err == nil {
 			backendURL := string(decoded)
 			for _, b := range s.inner.GetBackends() {
-				if b.URL == backendURL && b.Healthy {
+				if b.URL == backendURL && b.InRotation() {
 					return b, ""
 				}
 			}
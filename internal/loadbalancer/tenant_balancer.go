@@ -48,6 +48,20 @@ func (t *TenantAwareBalancer) MarkUnhealthy(url string) {
 	}
 }
 
+// SetDrained drains or undrains a backend across all balancers.
+func (t *TenantAwareBalancer) SetDrained(url string, drained bool) bool {
+	found := false
+	if d, ok := t.defaultBalancer.(Drainer); ok {
+		found = d.SetDrained(url, drained)
+	}
+	for _, b := range t.tenantBalancers {
+		if d, ok := b.(Drainer); ok && d.SetDrained(url, drained) {
+			found = true
+		}
+	}
+	return found
+}
+
 // GetBackends returns the default balancer's backends.
 func (t *TenantAwareBalancer) GetBackends() []*Backend {
 	return t.defaultBalancer.GetBackends()
@@ -101,6 +101,20 @@ func (vb *VersionedBalancer) MarkUnhealthy(url string) {
 	}
 }
 
+// SetDrained drains or undrains a backend across all versions.
+func (vb *VersionedBalancer) SetDrained(url string, drained bool) bool {
+	vb.mu.RLock()
+	defer vb.mu.RUnlock()
+
+	found := false
+	for _, rr := range vb.versions {
+		if rr.SetDrained(url, drained) {
+			found = true
+		}
+	}
+	return found
+}
+
 // GetBackends returns all backends across all versions.
 func (vb *VersionedBalancer) GetBackends() []*Backend {
 	vb.mu.RLock()
@@ -1,12 +1,13 @@
 package loadbalancer
 
 import (
-	"math/rand"
 	"net/http"
 	"strings"
 	"sync"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/randutil"
+	"github.com/wudi/runway/variables"
 )
 
 // TrafficGroup represents a group of backends with a weight
@@ -15,6 +16,7 @@ type TrafficGroup struct {
 	Weight       int
 	Balancer     *RoundRobin
 	MatchHeaders map[string]string
+	MatchBaggage map[string]string
 }
 
 // WeightedBalancer implements traffic splitting across multiple backend groups
@@ -51,6 +53,7 @@ func NewWeightedBalancer(splits []config.TrafficSplitConfig) *WeightedBalancer {
 			Weight:       split.Weight,
 			Balancer:     NewRoundRobin(backends),
 			MatchHeaders: split.MatchHeaders,
+			MatchBaggage: split.MatchBaggage,
 		}
 		wb.groups = append(wb.groups, group)
 		wb.groupsByName[split.Name] = group
@@ -84,7 +87,7 @@ func (wb *WeightedBalancer) NextForRequest(headers map[string]string) *Backend {
 		return nil
 	}
 
-	roll := rand.Intn(wb.totalWeight)
+	roll := randutil.Intn(wb.totalWeight)
 	cumulative := 0
 	for _, group := range wb.groups {
 		cumulative += group.Weight
@@ -116,7 +119,7 @@ func (wb *WeightedBalancer) NextForHTTPRequest(r *http.Request) (*Backend, strin
 		}
 	}
 
-	// Check header-based overrides
+	// Check header- and baggage-based overrides
 	if r != nil {
 		headers := make(map[string]string)
 		for _, group := range wb.groups {
@@ -125,7 +128,10 @@ func (wb *WeightedBalancer) NextForHTTPRequest(r *http.Request) (*Backend, strin
 			}
 		}
 		for _, group := range wb.groups {
-			if len(group.MatchHeaders) > 0 && matchAllHeaders(headers, group.MatchHeaders) {
+			if len(group.MatchHeaders) == 0 && len(group.MatchBaggage) == 0 {
+				continue
+			}
+			if matchAllHeaders(headers, group.MatchHeaders) && matchAllBaggage(r, group.MatchBaggage) {
 				return group.Balancer.Next(), group.Name
 			}
 		}
@@ -136,7 +142,7 @@ func (wb *WeightedBalancer) NextForHTTPRequest(r *http.Request) (*Backend, strin
 		return nil, ""
 	}
 
-	roll := rand.Intn(wb.totalWeight)
+	roll := randutil.Intn(wb.totalWeight)
 	cumulative := 0
 	for _, group := range wb.groups {
 		cumulative += group.Weight
@@ -202,6 +208,19 @@ func (wb *WeightedBalancer) MarkUnhealthy(url string) {
 	}
 }
 
+// SetDrained drains or undrains a backend across all groups
+func (wb *WeightedBalancer) SetDrained(url string, drained bool) bool {
+	wb.mu.RLock()
+	defer wb.mu.RUnlock()
+	found := false
+	for _, g := range wb.groups {
+		if g.Balancer.SetDrained(url, drained) {
+			found = true
+		}
+	}
+	return found
+}
+
 // GetBackends returns all backends across all groups
 func (wb *WeightedBalancer) GetBackends() []*Backend {
 	wb.mu.RLock()
@@ -274,6 +293,17 @@ func (wb *WeightedBalancer) GetGroupWeights() map[string]int {
 	return result
 }
 
+// matchAllBaggage reports whether every required baggage member is present
+// on the request with the given value.
+func matchAllBaggage(r *http.Request, required map[string]string) bool {
+	for key, val := range required {
+		if variables.BaggageValue(r, key) != val {
+			return false
+		}
+	}
+	return true
+}
+
 func matchAllHeaders(requestHeaders, required map[string]string) bool {
 	for key, val := range required {
 		reqVal, ok := requestHeaders[key]
@@ -1,6 +1,7 @@
 package loadbalancer
 
 import (
+	"net/http/httptest"
 	"testing"
 
 	"github.com/wudi/runway/config"
@@ -80,6 +81,43 @@ func TestWeightedBalancerHeaderOverride(t *testing.T) {
 	}
 }
 
+func TestWeightedBalancerBaggageMatch(t *testing.T) {
+	splits := []config.TrafficSplitConfig{
+		{
+			Name:   "stable",
+			Weight: 100,
+			Backends: []config.BackendConfig{
+				{URL: "http://stable:8080", Weight: 1},
+			},
+		},
+		{
+			Name:   "beta",
+			Weight: 0,
+			Backends: []config.BackendConfig{
+				{URL: "http://beta:8080", Weight: 1},
+			},
+			MatchBaggage: map[string]string{"cohort": "beta"},
+		},
+	}
+
+	wb := NewWeightedBalancer(splits)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Baggage", "tenant=acme,cohort=beta")
+	for i := 0; i < 100; i++ {
+		b, _ := wb.NextForHTTPRequest(r)
+		if b == nil || b.URL != "http://beta:8080" {
+			t.Fatal("expected beta backend for matching baggage")
+		}
+	}
+
+	r2 := httptest.NewRequest("GET", "/", nil)
+	r2.Header.Set("Baggage", "cohort=ga")
+	if b, _ := wb.NextForHTTPRequest(r2); b == nil || b.URL != "http://stable:8080" {
+		t.Fatal("expected stable backend for non-matching baggage")
+	}
+}
+
 func TestWeightedBalancerInterface(t *testing.T) {
 	splits := []config.TrafficSplitConfig{
 		{
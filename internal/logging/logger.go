@@ -1,6 +1,8 @@
 package logging
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"os"
 	"sync"
@@ -13,6 +15,13 @@ import (
 var (
 	globalLogger *zap.Logger
 	globalMu     sync.RWMutex
+
+	// level is shared by every logger created with New so the log level can
+	// be changed at runtime without rebuilding the logger.
+	level = zap.NewAtomicLevel()
+
+	// output is the sink of the logger New created last.
+	output zapcore.WriteSyncer = zapcore.Lock(os.Stdout)
 )
 
 func init() {
@@ -35,17 +44,11 @@ type Config struct {
 // When Output is a file path, the returned io.Closer must be closed on shutdown
 // to flush and close the underlying log file. For stdout/stderr the closer is nil.
 func New(cfg Config) (*zap.Logger, io.Closer, error) {
-	var lvl zapcore.Level
-	switch cfg.Level {
-	case "debug":
-		lvl = zapcore.DebugLevel
-	case "warn":
-		lvl = zapcore.WarnLevel
-	case "error":
-		lvl = zapcore.ErrorLevel
-	default:
+	lvl, err := parseLevel(cfg.Level)
+	if err != nil {
 		lvl = zapcore.InfoLevel
 	}
+	level.SetLevel(lvl)
 
 	encCfg := zap.NewProductionEncoderConfig()
 	encCfg.TimeKey = "time"
@@ -73,7 +76,11 @@ func New(cfg Config) (*zap.Logger, io.Closer, error) {
 		closer = lj
 	}
 
-	core := zapcore.NewCore(encoder, ws, lvl)
+	globalMu.Lock()
+	output = ws
+	globalMu.Unlock()
+
+	core := zapcore.NewCore(encoder, ws, level)
 	logger := zap.New(core,
 		zap.AddCaller(),
 		zap.AddCallerSkip(1),
@@ -82,6 +89,35 @@ func New(cfg Config) (*zap.Logger, io.Closer, error) {
 	return logger, closer, nil
 }
 
+func parseLevel(name string) (zapcore.Level, error) {
+	switch name {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "warn":
+		return zapcore.WarnLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+	return zapcore.InfoLevel, fmt.Errorf("invalid log level %q (valid: debug, info, warn, error)", name)
+}
+
+// SetLevel changes the level of loggers created with New at runtime.
+func SetLevel(name string) error {
+	lvl, err := parseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.SetLevel(lvl)
+	return nil
+}
+
+// Level returns the current runtime log level.
+func Level() string {
+	return level.Level().String()
+}
+
 // Global returns the global logger.
 func Global() *zap.Logger {
 	globalMu.RLock()
@@ -96,6 +132,15 @@ func SetGlobal(l *zap.Logger) {
 	globalMu.Unlock()
 }
 
+// Output returns the sink of the logger New created last, stdout before
+// New is called. Writers that encode their own lines, such as JSON access
+// logs, write to it to share the log file and its rotation.
+func Output() io.Writer {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return output
+}
+
 // Info logs at info level using the global logger.
 func Info(msg string, fields ...zap.Field) {
 	Global().Info(msg, fields...)
@@ -121,6 +166,50 @@ func With(fields ...zap.Field) *zap.Logger {
 	return Global().With(fields...)
 }
 
+// Verbose returns a child of the global logger with fields that writes
+// entries of every level, whatever the runtime level. It serves requests
+// traced through the admin API.
+func Verbose(fields ...zap.Field) *zap.Logger {
+	return Global().WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return verboseCore{c}
+	})).With(fields...)
+}
+
+// verboseCore writes entries of every level to its core. Cores check the
+// level in Check, not Write, so bypassing Check is enough.
+type verboseCore struct{ zapcore.Core }
+
+func (c verboseCore) Enabled(zapcore.Level) bool { return true }
+
+func (c verboseCore) With(fields []zapcore.Field) zapcore.Core {
+	return verboseCore{c.Core.With(fields)}
+}
+
+func (c verboseCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+type loggerKey struct{}
+
+// NewContext returns a context carrying l as the logger of one request.
+func NewContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the request logger of ctx, or the global logger.
+func FromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return Global()
+}
+
+// DebugContext logs at debug level using the request logger of ctx, so the
+// entry is written for traced requests at any runtime level.
+func DebugContext(ctx context.Context, msg string, fields ...zap.Field) {
+	FromContext(ctx).Debug(msg, fields...)
+}
+
 // Sync flushes any buffered log entries.
 func Sync() {
 	Global().Sync()
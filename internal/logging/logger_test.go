@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -188,3 +189,47 @@ func TestLevelFiltering(t *testing.T) {
 	}
 }
 
+
+func TestSetLevel(t *testing.T) {
+	l, _, err := New(Config{Level: "info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer SetLevel("info")
+
+	if l.Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("expected debug disabled at info level")
+	}
+	if err := SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	if !l.Core().Enabled(zapcore.DebugLevel) || Level() != "debug" {
+		t.Errorf("expected existing logger to switch to debug, level=%s", Level())
+	}
+	if err := SetLevel("verbose"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+	if Level() != "debug" {
+		t.Errorf("expected invalid level to be ignored, got %s", Level())
+	}
+}
+
+func TestVerboseContextLogger(t *testing.T) {
+	original := Global()
+	core, obs := observer.New(zapcore.WarnLevel)
+	SetGlobal(zap.New(core))
+	defer SetGlobal(original)
+
+	traced := NewContext(context.Background(), Verbose(zap.String("trace_session", "s1")))
+	DebugContext(traced, "traced")
+	DebugContext(context.Background(), "untraced")
+	Debug("global")
+
+	entries := obs.All()
+	if len(entries) != 1 || entries[0].Message != "traced" {
+		t.Fatalf("expected only the traced entry, got %+v", entries)
+	}
+	if entries[0].ContextMap()["trace_session"] != "s1" {
+		t.Errorf("expected the trace_session field, got %v", entries[0].ContextMap())
+	}
+}
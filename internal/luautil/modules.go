@@ -222,3 +222,43 @@ func logError(L *lua.LState) int {
 	logging.Error("lua_log", zap.String("message", msg))
 	return 0
 }
+
+// MetricRecorder receives the samples a script reports through the runway
+// module.
+type MetricRecorder interface {
+	Inc(name string, labels map[string]string, v float64) error
+	Set(name string, labels map[string]string, v float64) error
+}
+
+// RegisterMetrics registers the runway module with metric_inc and
+// metric_set functions that report to rec. Both take a metric name, a
+// table of labels or nil, and a value (optional for metric_inc, default 1).
+// They return true, or false and a message when the sample is rejected.
+func RegisterMetrics(L *lua.LState, rec MetricRecorder) {
+	mod := L.NewTable()
+	L.SetField(mod, "metric_inc", L.NewFunction(func(L *lua.LState) int {
+		return recordMetric(L, rec.Inc, float64(L.OptNumber(3, 1)))
+	}))
+	L.SetField(mod, "metric_set", L.NewFunction(func(L *lua.LState) int {
+		return recordMetric(L, rec.Set, float64(L.CheckNumber(3)))
+	}))
+	L.SetGlobal("runway", mod)
+}
+
+func recordMetric(L *lua.LState, record func(string, map[string]string, float64) error, v float64) int {
+	name := L.CheckString(1)
+	var labels map[string]string
+	if tbl := L.OptTable(2, nil); tbl != nil {
+		labels = make(map[string]string)
+		tbl.ForEach(func(k, v lua.LValue) {
+			labels[lua.LVAsString(k)] = lua.LVAsString(v)
+		})
+	}
+	if err := record(name, labels, v); err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LTrue)
+	return 1
+}
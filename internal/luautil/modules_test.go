@@ -1,6 +1,8 @@
 package luautil
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	lua "github.com/yuin/gopher-lua"
@@ -174,3 +176,47 @@ func TestLuaValueConversions(t *testing.T) {
 		t.Errorf("expected bool to encode as 'true', got %s", L.GetGlobal("result_bool").String())
 	}
 }
+
+type testRecorder struct {
+	calls []string
+}
+
+func (r *testRecorder) Inc(name string, labels map[string]string, v float64) error {
+	if name == "bad" {
+		return errors.New("invalid metric name")
+	}
+	r.calls = append(r.calls, fmt.Sprintf("inc %s %v %g", name, labels, v))
+	return nil
+}
+
+func (r *testRecorder) Set(name string, labels map[string]string, v float64) error {
+	r.calls = append(r.calls, fmt.Sprintf("set %s %v %g", name, labels, v))
+	return nil
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	L := newTestState()
+	defer L.Close()
+	rec := &testRecorder{}
+	RegisterMetrics(L, rec)
+
+	if err := L.DoString(`
+		runway.metric_inc("hits")
+		runway.metric_inc("hits", {kind = "a"}, 3)
+		runway.metric_set("depth", nil, 5)
+		ok, msg = runway.metric_inc("bad")
+	`); err != nil {
+		t.Fatalf("metric calls failed: %v", err)
+	}
+
+	want := []string{"inc hits map[] 1", "inc hits map[kind:a] 3", "set depth map[] 5"}
+	if fmt.Sprint(rec.calls) != fmt.Sprint(want) {
+		t.Errorf("expected calls %v, got %v", want, rec.calls)
+	}
+	if L.GetGlobal("ok") != lua.LFalse || L.GetGlobal("msg").String() != "invalid metric name" {
+		t.Errorf("expected a rejected sample to return false and the error, got %v, %v", L.GetGlobal("ok"), L.GetGlobal("msg"))
+	}
+	if err := L.DoString(`runway.metric_set("depth")`); err == nil {
+		t.Error("expected metric_set without a value to fail")
+	}
+}
@@ -0,0 +1,260 @@
+// Package matchexpr compiles boolean expressions over named route matchers,
+// such as "header_beta || (cookie_beta && !query_legacy)".
+//
+// Expressions combine identifiers with ! (not), && (and), || (or) and
+// parentheses; ! binds tightest and && binds tighter than ||. They are
+// compiled once into a flat node list and evaluated with short-circuiting,
+// so a matcher is only run when its result can change the outcome.
+package matchexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxSatisfiableVars bounds the truth table Satisfiable walks.
+const maxSatisfiableVars = 16
+
+type op uint8
+
+const (
+	opVar op = iota
+	opNot
+	opAnd
+	opOr
+)
+
+type node struct {
+	op   op
+	a, b int32 // operands; a is the variable index for opVar
+}
+
+// Expr is a compiled expression. It is safe for concurrent use.
+type Expr struct {
+	src   string
+	nodes []node
+	root  int32
+	names []string
+}
+
+// Parse compiles src.
+func Parse(src string) (*Expr, error) {
+	p := &parser{src: src, e: &Expr{src: src}}
+	p.next()
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != tokEOF {
+		return nil, p.errorf("unexpected %s", p.describe())
+	}
+	p.e.root = root
+	return p.e, nil
+}
+
+// MustParse is like Parse but panics if src is invalid.
+func MustParse(src string) *Expr {
+	e, err := Parse(src)
+	if err != nil {
+		panic("matchexpr: Parse(" + strconv.Quote(src) + "): " + err.Error())
+	}
+	return e
+}
+
+// String returns the source expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Names returns the identifiers the expression references, in order of
+// first appearance. Eval passes indexes into this slice to its leaf func.
+func (e *Expr) Names() []string {
+	return e.names
+}
+
+// Eval evaluates the expression, calling leaf for the value of the i-th
+// name. leaf is only called for names whose value is needed.
+func (e *Expr) Eval(leaf func(i int) bool) bool {
+	return e.eval(e.root, leaf)
+}
+
+func (e *Expr) eval(i int32, leaf func(int) bool) bool {
+	n := &e.nodes[i]
+	switch n.op {
+	case opVar:
+		return leaf(int(n.a))
+	case opNot:
+		return !e.eval(n.a, leaf)
+	case opAnd:
+		return e.eval(n.a, leaf) && e.eval(n.b, leaf)
+	default:
+		return e.eval(n.a, leaf) || e.eval(n.b, leaf)
+	}
+}
+
+// Satisfiable reports whether some assignment of the names makes the
+// expression true. Expressions over more than 16 names are assumed
+// satisfiable.
+func (e *Expr) Satisfiable() bool {
+	if len(e.names) > maxSatisfiableVars {
+		return true
+	}
+	for mask := 0; mask < 1<<len(e.names); mask++ {
+		if e.Eval(func(i int) bool { return mask&(1<<i) != 0 }) {
+			return true
+		}
+	}
+	return false
+}
+
+type token uint8
+
+const (
+	tokEOF token = iota
+	tokIdent
+	tokNot
+	tokAnd
+	tokOr
+	tokLParen
+	tokRParen
+	tokInvalid
+)
+
+type parser struct {
+	src   string
+	pos   int // offset after the current token
+	start int // offset of the current token
+	tok   token
+	ident string
+	e     *Expr
+}
+
+func (p *parser) next() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n' || p.src[p.pos] == '\r') {
+		p.pos++
+	}
+	p.start = p.pos
+	if p.pos >= len(p.src) {
+		p.tok = tokEOF
+		return
+	}
+	rest := p.src[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "&&"):
+		p.tok, p.pos = tokAnd, p.pos+2
+	case strings.HasPrefix(rest, "||"):
+		p.tok, p.pos = tokOr, p.pos+2
+	case rest[0] == '!':
+		p.tok, p.pos = tokNot, p.pos+1
+	case rest[0] == '(':
+		p.tok, p.pos = tokLParen, p.pos+1
+	case rest[0] == ')':
+		p.tok, p.pos = tokRParen, p.pos+1
+	case isIdentStart(rest[0]):
+		end := 1
+		for end < len(rest) && isIdentChar(rest[end]) {
+			end++
+		}
+		p.tok, p.ident, p.pos = tokIdent, rest[:end], p.pos+end
+	default:
+		p.tok, p.pos = tokInvalid, p.pos+1
+	}
+}
+
+func (p *parser) parseOr() (int32, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return 0, err
+	}
+	for p.tok == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return 0, err
+		}
+		left = p.add(node{op: opOr, a: left, b: right})
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (int32, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for p.tok == tokAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		left = p.add(node{op: opAnd, a: left, b: right})
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (int32, error) {
+	switch p.tok {
+	case tokNot:
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		return p.add(node{op: opNot, a: operand}), nil
+	case tokLParen:
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return 0, err
+		}
+		if p.tok != tokRParen {
+			return 0, p.errorf("expected ) but found %s", p.describe())
+		}
+		p.next()
+		return inner, nil
+	case tokIdent:
+		idx := p.nameIndex(p.ident)
+		p.next()
+		return p.add(node{op: opVar, a: int32(idx)}), nil
+	}
+	return 0, p.errorf("expected a matcher name but found %s", p.describe())
+}
+
+func (p *parser) add(n node) int32 {
+	p.e.nodes = append(p.e.nodes, n)
+	return int32(len(p.e.nodes) - 1)
+}
+
+func (p *parser) nameIndex(name string) int {
+	for i, n := range p.e.names {
+		if n == name {
+			return i
+		}
+	}
+	p.e.names = append(p.e.names, name)
+	return len(p.e.names) - 1
+}
+
+func (p *parser) describe() string {
+	switch p.tok {
+	case tokEOF:
+		return "end of expression"
+	case tokIdent:
+		return fmt.Sprintf("%q", p.ident)
+	}
+	return fmt.Sprintf("%q", p.src[p.start:p.pos])
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid expression at offset %d: %s", p.start, fmt.Sprintf(format, args...))
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c == '-' || c == '.' || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,92 @@
+package matchexpr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAndEval(t *testing.T) {
+	tests := []struct {
+		expr string
+		vals map[string]bool
+		want bool
+	}{
+		{"a", map[string]bool{"a": true}, true},
+		{"a || b || c", map[string]bool{"c": true}, true},
+		{"a || b || c", map[string]bool{}, false},
+		{"a && b", map[string]bool{"a": true}, false},
+		{"!a", map[string]bool{}, true},
+		{"a || b && c", map[string]bool{"a": true}, true},    // && binds tighter
+		{"(a || b) && c", map[string]bool{"a": true}, false}, // parentheses override
+		{"!(a && b) && !!c", map[string]bool{"a": true, "c": true}, true},
+		{"header_beta||cookie_beta", map[string]bool{"cookie_beta": true}, true},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		got := e.Eval(func(i int) bool { return tt.vals[e.Names()[i]] })
+		if got != tt.want {
+			t.Errorf("%q with %v = %v, want %v", tt.expr, tt.vals, got, tt.want)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	e, err := Parse("b || (a && !b) || c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(e.Names(), ","); got != "b,a,c" {
+		t.Errorf("Names() = %s, want b,a,c", got)
+	}
+}
+
+func TestEvalShortCircuits(t *testing.T) {
+	e, _ := Parse("a || b")
+	calls := 0
+	e.Eval(func(i int) bool {
+		calls++
+		return true
+	})
+	if calls != 1 {
+		t.Errorf("expected the second operand to be skipped, got %d calls", calls)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{"", "a ||", "(a", "a b", "a & b", "a | b", "1a", "a)", "!"} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q): expected error", src)
+		}
+	}
+}
+
+func TestSatisfiable(t *testing.T) {
+	tests := map[string]bool{
+		"a":                    true,
+		"a && !a":              false,
+		"(a || b) && !a && !b": false,
+		"a || !a":              true,
+		"a && !b":              true,
+	}
+	for src, want := range tests {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.Satisfiable(); got != want {
+			t.Errorf("Satisfiable(%q) = %v, want %v", src, got, want)
+		}
+	}
+}
+
+func BenchmarkEval(b *testing.B) {
+	e, _ := Parse("header_beta || cookie_beta || query_beta")
+	vals := []bool{false, false, true}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e.Eval(func(i int) bool { return vals[i] })
+	}
+}
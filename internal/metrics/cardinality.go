@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// CardinalityReport estimates the time series the collector exports.
+type CardinalityReport struct {
+	TotalSeries int                     `json:"total_series"`
+	Instruments []InstrumentCardinality `json:"instruments"` // largest first
+}
+
+// InstrumentCardinality counts the series of one metric family. Each
+// histogram bucket and summary quantile, and their _sum and _count, is a
+// series of its own.
+type InstrumentCardinality struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	LabelSets int    `json:"label_sets"`
+	Series    int    `json:"series"`
+}
+
+// Cardinality gathers the registry and counts the active series per
+// instrument, so operators can see what a metrics mode change saves.
+func (c *Collector) Cardinality() CardinalityReport {
+	families, _ := c.registry.Gather()
+	report := CardinalityReport{Instruments: make([]InstrumentCardinality, 0, len(families))}
+	for _, fam := range families {
+		ic := InstrumentCardinality{
+			Name:      fam.GetName(),
+			Type:      metricTypeName(fam.GetType()),
+			LabelSets: len(fam.GetMetric()),
+		}
+		for _, m := range fam.GetMetric() {
+			ic.Series += seriesOf(fam.GetType(), m)
+		}
+		report.TotalSeries += ic.Series
+		report.Instruments = append(report.Instruments, ic)
+	}
+	sort.Slice(report.Instruments, func(i, j int) bool {
+		a, b := report.Instruments[i], report.Instruments[j]
+		if a.Series != b.Series {
+			return a.Series > b.Series
+		}
+		return a.Name < b.Name
+	})
+	return report
+}
+
+// seriesOf returns the number of exposed series for one label set.
+func seriesOf(t dto.MetricType, m *dto.Metric) int {
+	switch t {
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		// Buckets plus _sum and _count, and +Inf, which is only gathered
+		// when it is an explicit bound.
+		n := len(m.GetHistogram().GetBucket()) + 2
+		if !hasInfBucket(m.GetHistogram()) {
+			n++
+		}
+		return n
+	case dto.MetricType_SUMMARY:
+		return len(m.GetSummary().GetQuantile()) + 2
+	}
+	return 1
+}
+
+func hasInfBucket(h *dto.Histogram) bool {
+	b := h.GetBucket()
+	return len(b) > 0 && math.IsInf(b[len(b)-1].GetUpperBound(), 1)
+}
+
+func metricTypeName(t dto.MetricType) string {
+	switch t {
+	case dto.MetricType_COUNTER:
+		return "counter"
+	case dto.MetricType_GAUGE:
+		return "gauge"
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		return "histogram"
+	case dto.MetricType_SUMMARY:
+		return "summary"
+	}
+	return "untyped"
+}
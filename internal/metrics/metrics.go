@@ -3,6 +3,7 @@ package metrics
 import (
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -12,6 +13,14 @@ import (
 // DefaultBuckets are default histogram buckets in seconds
 var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0}
 
+// DefaultSummaryWindow is the default sliding window over which summary
+// quantiles are computed.
+const DefaultSummaryWindow = time.Minute
+
+// summaryObjectives are the quantiles of the request duration summary and
+// their allowed error.
+var summaryObjectives = map[float64]float64{0.5: 0.05, 0.95: 0.01, 0.99: 0.001}
+
 // statusCodeStrings caches string representations of all standard HTTP status codes
 // (100-599) to avoid per-request strconv.Itoa allocations. Array lookup is faster than map.
 var statusCodeStrings [600]string
@@ -29,12 +38,23 @@ func statusCodeString(code int) string {
 	return strconv.Itoa(code)
 }
 
+// statusClassStrings holds the status class labels, indexed by code / 100.
+var statusClassStrings = [6]string{"", "1xx", "2xx", "3xx", "4xx", "5xx"}
+
+func statusClassString(code int) string {
+	if code >= 100 && code < 600 {
+		return statusClassStrings[code/100]
+	}
+	return strconv.Itoa(code)
+}
+
 // Collector tracks runway metrics using prometheus/client_golang
 type Collector struct {
 	registry *prometheus.Registry
 
 	requestsTotal    *prometheus.CounterVec
 	requestDuration  *prometheus.HistogramVec
+	requestSummary   *prometheus.SummaryVec
 	cacheHitsTotal   *prometheus.CounterVec
 	cacheMissesTotal *prometheus.CounterVec
 	retryTotal       *prometheus.CounterVec
@@ -42,11 +62,46 @@ type Collector struct {
 	backendHealth    *prometheus.GaugeVec
 	activeRequests   *prometheus.GaugeVec
 	rateLimitRejects     *prometheus.CounterVec
+	rateLimitObserved     *prometheus.CounterVec
+	spikeArrestObserved   *prometheus.CounterVec
 	cacheNotModifiedTotal *prometheus.CounterVec
+	degradedResponses     *prometheus.CounterVec
+	syntheticTotal        *prometheus.CounterVec
+	syntheticDuration     *prometheus.HistogramVec
+	clientAbortsTotal     *prometheus.CounterVec
+	peerFailoverTotal     *prometheus.CounterVec
+	handlerFallbackTotal  *prometheus.CounterVec
+	staleOnTimeoutTotal   *prometheus.CounterVec
+	staleRefreshTotal     *prometheus.CounterVec
+	authzDeniedTotal      *prometheus.CounterVec
+	streamEnforcedTotal   *prometheus.CounterVec
+	configInfo            *prometheus.GaugeVec
+
+	routeInfo             *routeInfoCollector
+
+	plugins *PluginMetrics
+}
+
+// Options configures the request duration instruments of a Collector.
+type Options struct {
+	Buckets       []float64     // histogram bounds in seconds; DefaultBuckets when empty
+	SummaryWindow time.Duration // summary quantile window; DefaultSummaryWindow when zero
 }
 
 // NewCollector creates a new metrics collector backed by prometheus/client_golang
 func NewCollector() *Collector {
+	return NewCollectorWithOptions(Options{})
+}
+
+// NewCollectorWithOptions creates a metrics collector with custom request
+// duration instruments.
+func NewCollectorWithOptions(opts Options) *Collector {
+	if len(opts.Buckets) == 0 {
+		opts.Buckets = DefaultBuckets
+	}
+	if opts.SummaryWindow <= 0 {
+		opts.SummaryWindow = DefaultSummaryWindow
+	}
 	reg := prometheus.NewRegistry()
 
 	c := &Collector{
@@ -58,7 +113,13 @@ func NewCollector() *Collector {
 		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "runway_request_duration_seconds",
 			Help:    "Request duration in seconds",
-			Buckets: DefaultBuckets,
+			Buckets: opts.Buckets,
+		}, []string{"route"}),
+		requestSummary: prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Name:       "runway_request_duration_summary_seconds",
+			Help:       "Request duration quantiles in seconds, for routes with metrics mode summary",
+			Objectives: summaryObjectives,
+			MaxAge:     opts.SummaryWindow,
 		}, []string{"route"}),
 		cacheHitsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "runway_cache_hits_total",
@@ -88,15 +149,69 @@ func NewCollector() *Collector {
 			Name: "runway_rate_limit_rejects_total",
 			Help: "Total rate limit rejections",
 		}, []string{"route"}),
+		rateLimitObserved: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "runway_rate_limit_observed_rejections_total",
+			Help: "Total requests an observe-mode rate limit would have rejected, by tier",
+		}, []string{"route", "tier"}),
+		spikeArrestObserved: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "runway_spike_arrest_observed_rejections_total",
+			Help: "Total requests an observe-mode spike arrest would have rejected",
+		}, []string{"route"}),
 		cacheNotModifiedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "runway_cache_not_modified_total",
 			Help: "Total 304 Not Modified responses from conditional cache hits",
 		}, []string{"route"}),
+		degradedResponses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "runway_degraded_responses_total",
+			Help: "Total responses served while a route is in degraded mode, by source",
+		}, []string{"route", "source"}),
+		syntheticTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "runway_synthetic_requests_total",
+			Help: "Total synthetic monitor probes, excluded from runway_requests_total",
+		}, []string{"route", "status"}),
+		syntheticDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "runway_synthetic_request_duration_seconds",
+			Help:    "Synthetic monitor probe duration in seconds",
+			Buckets: DefaultBuckets,
+		}, []string{"route"}),
+		clientAbortsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "runway_client_aborts_total",
+			Help: "Total requests abandoned by the client, by phase",
+		}, []string{"route", "phase"}),
+		peerFailoverTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "runway_peer_failover_total",
+			Help: "Total requests forwarded to or received from peer gateways, by peer and outcome",
+		}, []string{"route", "peer", "outcome"}),
+		handlerFallbackTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "runway_handler_fallback_total",
+			Help: "Total translator, lambda and amqp handler failures that triggered a fallback, by trigger and outcome",
+		}, []string{"route", "trigger", "outcome"}),
+		staleOnTimeoutTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "runway_cache_stale_on_timeout_total",
+			Help: "Total stale cache entries served because the backend exceeded the soft timeout",
+		}, []string{"route"}),
+		staleRefreshTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "runway_cache_stale_refresh_total",
+			Help: "Total background cache refreshes left running after a stale-on-timeout serve, by outcome",
+		}, []string{"route", "outcome"}),
+		authzDeniedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "runway_authz_denied_total",
+			Help: "Total authenticated requests rejected by auth.requirements, by unmet requirement",
+		}, []string{"route", "requirement"}),
+		streamEnforcedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "runway_listener_stream_enforcements_total",
+			Help: "Total HTTP/2 and HTTP/3 stream limit enforcements, by listener, protocol and action",
+		}, []string{"listener", "protocol", "action"}),
+		configInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "runway_config_info",
+			Help: "Always 1; the hash label identifies the running configuration",
+		}, []string{"hash"}),
 	}
 
 	reg.MustRegister(
 		c.requestsTotal,
 		c.requestDuration,
+		c.requestSummary,
 		c.cacheHitsTotal,
 		c.cacheMissesTotal,
 		c.retryTotal,
@@ -104,8 +219,24 @@ func NewCollector() *Collector {
 		c.backendHealth,
 		c.activeRequests,
 		c.rateLimitRejects,
+		c.rateLimitObserved,
+		c.spikeArrestObserved,
 		c.cacheNotModifiedTotal,
+		c.degradedResponses,
+		c.syntheticTotal,
+		c.syntheticDuration,
+		c.clientAbortsTotal,
+		c.peerFailoverTotal,
+		c.handlerFallbackTotal,
+		c.staleOnTimeoutTotal,
+		c.staleRefreshTotal,
+		c.authzDeniedTotal,
+		c.streamEnforcedTotal,
+		c.configInfo,
 	)
+	c.routeInfo = &routeInfoCollector{}
+	reg.MustRegister(c.routeInfo)
+	c.plugins = newPluginMetrics(reg)
 
 	return c
 }
@@ -116,6 +247,184 @@ func (c *Collector) RecordRequest(route, method string, statusCode int, duration
 	c.requestDuration.WithLabelValues(route).Observe(duration.Seconds())
 }
 
+// RouteRecorder records the requests of one route. The route's label values
+// and instruments are bound once, so recording a request neither branches on
+// the route's metrics mode nor allocates after the first request with a
+// given method and status.
+type RouteRecorder struct {
+	c        *Collector
+	route    string
+	duration prometheus.Observer
+	status   func(int) string
+
+	mu       sync.RWMutex
+	requests map[requestLabels]prometheus.Counter
+}
+
+type requestLabels struct {
+	method string
+	status string
+}
+
+// RouteOptions selects the instruments a RouteRecorder records.
+type RouteOptions struct {
+	Mode        string // "histogram" (default when empty), "summary", or "counters"
+	StatusClass bool   // label requests with the status class (4xx) instead of the code
+}
+
+// Route returns a recorder for route. Callers obtain it when the route's
+// handler is built and keep it for the route's lifetime. Duration series of
+// instruments the route no longer records are removed.
+func (c *Collector) Route(route string, opts RouteOptions) *RouteRecorder {
+	rr := &RouteRecorder{
+		c:        c,
+		route:    route,
+		status:   statusCodeString,
+		requests: make(map[requestLabels]prometheus.Counter),
+	}
+	switch opts.Mode {
+	case "summary":
+		c.requestDuration.DeleteLabelValues(route)
+		rr.duration = c.requestSummary.WithLabelValues(route)
+	case "counters":
+		c.requestDuration.DeleteLabelValues(route)
+		c.requestSummary.DeleteLabelValues(route)
+		rr.duration = discardObserver{}
+	default:
+		c.requestSummary.DeleteLabelValues(route)
+		rr.duration = c.requestDuration.WithLabelValues(route)
+	}
+	if opts.StatusClass {
+		rr.status = statusClassString
+	}
+	return rr
+}
+
+// discardObserver drops observations, for routes recording counters only.
+type discardObserver struct{}
+
+func (discardObserver) Observe(float64) {}
+
+// RecordRequest records a completed request, as Collector.RecordRequest.
+func (rr *RouteRecorder) RecordRequest(method string, statusCode int, duration time.Duration) {
+	key := requestLabels{method, rr.status(statusCode)}
+	rr.mu.RLock()
+	counter, ok := rr.requests[key]
+	rr.mu.RUnlock()
+	if !ok {
+		counter = rr.c.requestsTotal.WithLabelValues(rr.route, method, key.status)
+		rr.mu.Lock()
+		rr.requests[key] = counter
+		rr.mu.Unlock()
+	}
+	counter.Inc()
+	rr.duration.Observe(duration.Seconds())
+}
+
+// RecordSyntheticRequest records a completed synthetic monitor probe.
+func (c *Collector) RecordSyntheticRequest(route string, statusCode int, duration time.Duration) {
+	c.syntheticTotal.WithLabelValues(route, statusCodeString(statusCode)).Inc()
+	c.syntheticDuration.WithLabelValues(route).Observe(duration.Seconds())
+}
+
+// RecordClientAbort records a request abandoned by the client in phase.
+// The request itself is recorded by RecordRequest with status 499.
+func (c *Collector) RecordClientAbort(route, phase string) {
+	c.clientAbortsTotal.WithLabelValues(route, phase).Inc()
+}
+
+// RecordPeerFailover records a peer failover outcome for a route. outcome
+// is one of served, error, circuit_open, or loop_rejected.
+func (c *Collector) RecordPeerFailover(route, peer, outcome string) {
+	c.peerFailoverTotal.WithLabelValues(route, peer, outcome).Inc()
+}
+
+// RecordHandlerFallback records a triggered handler fallback for a route.
+// outcome is one of served, rate_limited, or not_replayable.
+func (c *Collector) RecordHandlerFallback(route, trigger, outcome string) {
+	c.handlerFallbackTotal.WithLabelValues(route, trigger, outcome).Inc()
+}
+
+// RecordCacheStaleOnTimeout records a stale entry served because the
+// backend exceeded the cache soft timeout.
+func (c *Collector) RecordCacheStaleOnTimeout(route string) {
+	c.staleOnTimeoutTotal.WithLabelValues(route).Inc()
+}
+
+// RecordCacheStaleRefresh records the outcome of a background refresh after
+// a stale-on-timeout serve. outcome is one of refreshed, not_cacheable,
+// error, or timeout.
+func (c *Collector) RecordCacheStaleRefresh(route, outcome string) {
+	c.staleRefreshTotal.WithLabelValues(route, outcome).Inc()
+}
+
+// RecordAuthzDenied records a 403 from auth.requirements. requirement is
+// scopes_any, scopes_all, roles_any, or claims.<name>.
+func (c *Collector) RecordAuthzDenied(route, requirement string) {
+	c.authzDeniedTotal.WithLabelValues(route, requirement).Inc()
+}
+
+// RecordStreamEnforcement records a listener closing an HTTP/2 or HTTP/3
+// connection, or rejecting a request, for exceeding its stream limits.
+func (c *Collector) RecordStreamEnforcement(listener, protocol, action string) {
+	c.streamEnforcedTotal.WithLabelValues(listener, protocol, action).Inc()
+}
+
+// SetConfigHash replaces the runway_config_info series with one for hash.
+func (c *Collector) SetConfigHash(hash string) {
+	c.configInfo.Reset()
+	c.configInfo.WithLabelValues(hash).Set(1)
+}
+
+// SetSpillDiskUsage registers runway_response_spill_disk_bytes, reporting
+// the bytes usage returns. Call it once; later calls are ignored.
+func (c *Collector) SetSpillDiskUsage(usage func() int64) {
+	c.registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "runway_response_spill_disk_bytes",
+		Help: "Bytes of buffered response bodies currently spilled to disk",
+	}, func() float64 { return float64(usage()) }))
+}
+
+// SetRouteInfo replaces the runway_route_info series with one per route,
+// labelled with the route ID and the metadata keys in labels. Routes
+// without a key get an empty label value.
+func (c *Collector) SetRouteInfo(labels []string, metadata map[string]map[string]string) {
+	desc := prometheus.NewDesc("runway_route_info",
+		"Always 1; labels carry route metadata selected by route_metadata.metric_labels",
+		append([]string{"route"}, labels...), nil)
+	series := make([][]string, 0, len(metadata))
+	for route, md := range metadata {
+		values := make([]string, len(labels)+1)
+		values[0] = route
+		for i, l := range labels {
+			values[i+1] = md[l]
+		}
+		series = append(series, values)
+	}
+	c.routeInfo.mu.Lock()
+	c.routeInfo.desc, c.routeInfo.series = desc, series
+	c.routeInfo.mu.Unlock()
+}
+
+// routeInfoCollector exports runway_route_info. It describes no metrics,
+// making it an unchecked collector, because its label set follows
+// route_metadata.metric_labels and changes on reload.
+type routeInfoCollector struct {
+	mu     sync.RWMutex
+	desc   *prometheus.Desc
+	series [][]string
+}
+
+func (r *routeInfoCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (r *routeInfoCollector) Collect(ch chan<- prometheus.Metric) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, values := range r.series {
+		ch <- prometheus.MustNewConstMetric(r.desc, prometheus.GaugeValue, 1, values...)
+	}
+}
+
 // RecordCacheHit records a cache hit
 func (c *Collector) RecordCacheHit(route string) {
 	c.cacheHitsTotal.WithLabelValues(route).Inc()
@@ -126,6 +435,12 @@ func (c *Collector) RecordCacheMiss(route string) {
 	c.cacheMissesTotal.WithLabelValues(route).Inc()
 }
 
+// RecordDegradedResponse records a response served in degraded mode.
+// source is one of stale, fallback, error_page, or canary.
+func (c *Collector) RecordDegradedResponse(route, source string) {
+	c.degradedResponses.WithLabelValues(route, source).Inc()
+}
+
 // RecordRetry records a retry attempt
 func (c *Collector) RecordRetry(route string) {
 	c.retryTotal.WithLabelValues(route).Inc()
@@ -155,11 +470,28 @@ func (c *Collector) RecordRateLimitReject(route string) {
 	c.rateLimitRejects.WithLabelValues(route).Inc()
 }
 
+// RecordRateLimitObservedReject records a request an observe-mode rate
+// limit would have rejected. tier is empty for untiered limits.
+func (c *Collector) RecordRateLimitObservedReject(route, tier string) {
+	c.rateLimitObserved.WithLabelValues(route, tier).Inc()
+}
+
+// RecordSpikeArrestObservedReject records a request an observe-mode spike
+// arrest would have rejected.
+func (c *Collector) RecordSpikeArrestObservedReject(route string) {
+	c.spikeArrestObserved.WithLabelValues(route).Inc()
+}
+
 // RecordCacheNotModified records a 304 Not Modified response from a conditional cache hit
 func (c *Collector) RecordCacheNotModified(route string) {
 	c.cacheNotModifiedTotal.WithLabelValues(route).Inc()
 }
 
+// Plugins returns the registry of metrics reported by Lua and WASM plugins.
+func (c *Collector) Plugins() *PluginMetrics {
+	return c.plugins
+}
+
 // Handler returns an http.Handler that serves the Prometheus metrics
 func (c *Collector) Handler() http.Handler {
 	return promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{})
@@ -180,6 +512,10 @@ type MetricsSnapshot struct {
 	RetryTotal          map[string]int64             `json:"retry_total"`
 	CircuitBreakerState map[string]int               `json:"circuit_breaker_state"`
 	BackendHealth       map[string]int               `json:"backend_health"`
+	ClientAborts        map[string]map[string]int64  `json:"client_aborts"` // route -> phase -> count
+	CacheStaleOnTimeout map[string]int64             `json:"cache_stale_on_timeout"`
+	CacheStaleRefresh   map[string]map[string]int64  `json:"cache_stale_refresh"` // route -> outcome -> count
+	AuthzDenied         map[string]map[string]int64  `json:"authz_denied"`        // route -> requirement -> count
 }
 
 // HistogramSnapshot is a snapshot of histogram data
@@ -199,6 +535,10 @@ func (c *Collector) Snapshot() *MetricsSnapshot {
 		RetryTotal:          make(map[string]int64),
 		CircuitBreakerState: make(map[string]int),
 		BackendHealth:       make(map[string]int),
+		ClientAborts:        make(map[string]map[string]int64),
+		CacheStaleOnTimeout: make(map[string]int64),
+		CacheStaleRefresh:   make(map[string]map[string]int64),
+		AuthzDenied:         make(map[string]map[string]int64),
 	}
 
 	families, _ := c.registry.Gather()
@@ -235,6 +575,29 @@ func (c *Collector) Snapshot() *MetricsSnapshot {
 			case "runway_backend_health":
 				key := labels["route"] + "|" + labels["backend"]
 				snap.BackendHealth[key] = int(m.GetGauge().GetValue())
+			case "runway_client_aborts_total":
+				phases := snap.ClientAborts[labels["route"]]
+				if phases == nil {
+					phases = make(map[string]int64)
+					snap.ClientAborts[labels["route"]] = phases
+				}
+				phases[labels["phase"]] = int64(m.GetCounter().GetValue())
+			case "runway_cache_stale_on_timeout_total":
+				snap.CacheStaleOnTimeout[labels["route"]] = int64(m.GetCounter().GetValue())
+			case "runway_cache_stale_refresh_total":
+				outcomes := snap.CacheStaleRefresh[labels["route"]]
+				if outcomes == nil {
+					outcomes = make(map[string]int64)
+					snap.CacheStaleRefresh[labels["route"]] = outcomes
+				}
+				outcomes[labels["outcome"]] = int64(m.GetCounter().GetValue())
+			case "runway_authz_denied_total":
+				reqs := snap.AuthzDenied[labels["route"]]
+				if reqs == nil {
+					reqs = make(map[string]int64)
+					snap.AuthzDenied[labels["route"]] = reqs
+				}
+				reqs[labels["requirement"]] = int64(m.GetCounter().GetValue())
 			}
 		}
 	}
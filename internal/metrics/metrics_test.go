@@ -33,6 +33,32 @@ func TestCollectorRecordRequest(t *testing.T) {
 	}
 }
 
+func TestRouteRecorder(t *testing.T) {
+	c := NewCollector()
+	rr := c.Route("route1", RouteOptions{})
+
+	rr.RecordRequest("GET", 200, 100*time.Millisecond)
+	c.RecordRequest("route1", "GET", 200, 100*time.Millisecond)
+	rr.RecordRequest("POST", 500, 50*time.Millisecond)
+
+	snap := c.Snapshot()
+	if snap.RequestsTotal["route1|GET|200"] != 2 {
+		t.Errorf("expected 2 GET 200 requests, got %d", snap.RequestsTotal["route1|GET|200"])
+	}
+	if snap.RequestsTotal["route1|POST|500"] != 1 {
+		t.Errorf("expected 1 POST 500 request, got %d", snap.RequestsTotal["route1|POST|500"])
+	}
+	if hd := snap.RequestDurations["route1"]; hd == nil || hd.Count != 3 {
+		t.Errorf("expected 3 duration entries, got %+v", hd)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		rr.RecordRequest("GET", 200, time.Millisecond)
+	}); allocs != 0 {
+		t.Errorf("expected no allocations per recorded request, got %v", allocs)
+	}
+}
+
 func TestCollectorCacheMetrics(t *testing.T) {
 	c := NewCollector()
 
@@ -50,6 +76,40 @@ func TestCollectorCacheMetrics(t *testing.T) {
 	}
 }
 
+func TestCollectorClientAborts(t *testing.T) {
+	c := NewCollector()
+
+	c.RecordClientAbort("route1", "during_backend")
+	c.RecordClientAbort("route1", "during_backend")
+	c.RecordClientAbort("route1", "during_response")
+
+	snap := c.Snapshot()
+
+	if snap.ClientAborts["route1"]["during_backend"] != 2 {
+		t.Errorf("expected 2 aborts during backend, got %d", snap.ClientAborts["route1"]["during_backend"])
+	}
+	if snap.ClientAborts["route1"]["during_response"] != 1 {
+		t.Errorf("expected 1 abort during response, got %d", snap.ClientAborts["route1"]["during_response"])
+	}
+}
+
+func TestCollectorAuthzDenied(t *testing.T) {
+	c := NewCollector()
+
+	c.RecordAuthzDenied("route1", "scopes_any")
+	c.RecordAuthzDenied("route1", "scopes_any")
+	c.RecordAuthzDenied("route1", "claims.tier")
+
+	snap := c.Snapshot()
+
+	if snap.AuthzDenied["route1"]["scopes_any"] != 2 {
+		t.Errorf("expected 2 scopes_any denials, got %d", snap.AuthzDenied["route1"]["scopes_any"])
+	}
+	if snap.AuthzDenied["route1"]["claims.tier"] != 1 {
+		t.Errorf("expected 1 claims.tier denial, got %d", snap.AuthzDenied["route1"]["claims.tier"])
+	}
+}
+
 func TestCollectorCircuitBreakerState(t *testing.T) {
 	c := NewCollector()
 
@@ -105,6 +165,38 @@ func TestWritePrometheus(t *testing.T) {
 	}
 }
 
+func TestCollectorRouteInfo(t *testing.T) {
+	c := NewCollector()
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		c.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		return w.Body.String()
+	}
+
+	md := map[string]map[string]string{
+		"api":  {"team": "payments", "tier": "gold", "runbook": "https://wiki/api"},
+		"echo": nil,
+	}
+	c.SetRouteInfo([]string{"team", "tier"}, md)
+	body := scrape()
+	if !strings.Contains(body, `runway_route_info{route="api",team="payments",tier="gold"} 1`) {
+		t.Errorf("missing api route info:\n%s", body)
+	}
+	if !strings.Contains(body, `runway_route_info{route="echo",team="",tier=""} 1`) {
+		t.Errorf("missing echo route info:\n%s", body)
+	}
+	if strings.Contains(body, "runbook") {
+		t.Error("keys outside the label allowlist must not be exported")
+	}
+
+	// A new label set replaces the metric.
+	c.SetRouteInfo([]string{"team"}, map[string]map[string]string{"api": md["api"]})
+	body = scrape()
+	if !strings.Contains(body, `runway_route_info{route="api",team="payments"} 1`) || strings.Contains(body, "tier=") || strings.Contains(body, `route="echo"`) {
+		t.Errorf("expected only the team label for api:\n%s", body)
+	}
+}
+
 func TestCollectorActiveRequests(t *testing.T) {
 	c := NewCollector()
 
@@ -134,3 +226,73 @@ func TestCollectorRateLimitRejects(t *testing.T) {
 		t.Error("missing runway_rate_limit_rejects_total")
 	}
 }
+
+func TestRouteRecorderModes(t *testing.T) {
+	c := NewCollectorWithOptions(Options{Buckets: []float64{0.1, 1}})
+	c.Route("hist", RouteOptions{}).RecordRequest("GET", 200, 50*time.Millisecond)
+	c.Route("sum", RouteOptions{Mode: "summary"}).RecordRequest("GET", 200, 50*time.Millisecond)
+	c.Route("count", RouteOptions{Mode: "counters", StatusClass: true}).RecordRequest("GET", 404, 50*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	c.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	if !strings.Contains(body, `runway_request_duration_seconds_bucket{route="hist",le="0.1"} 1`) {
+		t.Error("expected custom histogram bucket for route hist")
+	}
+	if !strings.Contains(body, `runway_request_duration_summary_seconds{route="sum",quantile="0.99"}`) {
+		t.Error("expected summary quantiles for route sum")
+	}
+	if strings.Contains(body, `runway_request_duration_seconds_count{route="sum"}`) {
+		t.Error("summary route must not record a histogram")
+	}
+	if strings.Contains(body, `route="count",le=`) || strings.Contains(body, `{route="count",quantile=`) {
+		t.Error("counters route must not record durations")
+	}
+	if !strings.Contains(body, `runway_requests_total{method="GET",route="count",status="4xx"} 1`) {
+		t.Error("expected status class label for route count")
+	}
+}
+
+func TestRouteModeChangeDropsDurationSeries(t *testing.T) {
+	c := NewCollector()
+	c.Route("r", RouteOptions{}).RecordRequest("GET", 200, time.Millisecond)
+	c.Route("r", RouteOptions{Mode: "counters"})
+
+	snap := c.Snapshot()
+	if _, ok := snap.RequestDurations["r"]; ok {
+		t.Error("expected histogram series to be removed after switching to counters")
+	}
+	if snap.RequestsTotal["r|GET|200"] != 1 {
+		t.Error("request counter must survive a mode change")
+	}
+}
+
+func TestCardinality(t *testing.T) {
+	c := NewCollectorWithOptions(Options{Buckets: []float64{0.1, 1}})
+	c.Route("a", RouteOptions{}).RecordRequest("GET", 200, time.Millisecond)
+	c.Route("b", RouteOptions{}).RecordRequest("GET", 200, time.Millisecond)
+	c.Route("c", RouteOptions{Mode: "summary"}).RecordRequest("GET", 200, time.Millisecond)
+
+	report := c.Cardinality()
+	byName := make(map[string]InstrumentCardinality)
+	total := 0
+	for _, ic := range report.Instruments {
+		byName[ic.Name] = ic
+		total += ic.Series
+	}
+	if total != report.TotalSeries {
+		t.Errorf("total %d does not match instrument sum %d", report.TotalSeries, total)
+	}
+	// 2 routes x (2 bounds + +Inf + _sum + _count)
+	if h := byName["runway_request_duration_seconds"]; h.LabelSets != 2 || h.Series != 10 || h.Type != "histogram" {
+		t.Errorf("unexpected histogram cardinality %+v", h)
+	}
+	// 1 route x (3 quantiles + _sum + _count)
+	if s := byName["runway_request_duration_summary_seconds"]; s.Series != 5 || s.Type != "summary" {
+		t.Errorf("unexpected summary cardinality %+v", s)
+	}
+	if r := byName["runway_requests_total"]; r.Series != 3 {
+		t.Errorf("expected 3 request counter series, got %+v", r)
+	}
+}
@@ -0,0 +1,245 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultPluginMaxSeries is the default cap on distinct metric and label
+// value combinations a single plugin may create.
+const DefaultPluginMaxSeries = 100
+
+// maxPluginLabels caps the labels a plugin metric may declare.
+const maxPluginLabels = 8
+
+// ErrPluginSeriesLimit is returned when recording a sample would create a
+// new series beyond the plugin's cap.
+var ErrPluginSeriesLimit = errors.New("plugin metric series limit reached")
+
+var pluginMetricName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// PluginMetrics holds the counters and gauges that Lua scripts and WASM
+// plugins create at runtime. An instrument is registered on first use as
+// runway_plugin_<plugin>_<name> and carries route and plugin labels ahead
+// of the plugin's own.
+type PluginMetrics struct {
+	registry *prometheus.Registry
+
+	mu        sync.Mutex
+	maxSeries int
+	families  map[string]*pluginFamily           // by full metric name
+	series    map[string]map[string]pluginSeries // plugin -> series key -> series
+}
+
+type pluginFamily struct {
+	plugin  string
+	gauge   bool
+	labels  []string // plugin label names, sorted
+	counter *prometheus.CounterVec
+	gaugeV  *prometheus.GaugeVec
+}
+
+type pluginSeries struct {
+	family string
+	route  string
+	values []string // route, plugin, then plugin label values
+}
+
+func newPluginMetrics(reg *prometheus.Registry) *PluginMetrics {
+	return &PluginMetrics{
+		registry:  reg,
+		maxSeries: DefaultPluginMaxSeries,
+		families:  make(map[string]*pluginFamily),
+		series:    make(map[string]map[string]pluginSeries),
+	}
+}
+
+// SetMaxSeries sets the per-plugin series cap. Zero or less restores the
+// default. Series already created are kept.
+func (pm *PluginMetrics) SetMaxSeries(n int) {
+	if n <= 0 {
+		n = DefaultPluginMaxSeries
+	}
+	pm.mu.Lock()
+	pm.maxSeries = n
+	pm.mu.Unlock()
+}
+
+// Inc adds v to the counter name of plugin on route.
+func (pm *PluginMetrics) Inc(plugin, route, name string, labels map[string]string, v float64) error {
+	if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("counter %s: value must be a finite number >= 0", name)
+	}
+	return pm.record(false, plugin, route, name, labels, v)
+}
+
+// Set sets the gauge name of plugin on route to v.
+func (pm *PluginMetrics) Set(plugin, route, name string, labels map[string]string, v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("gauge %s: value must be a finite number", name)
+	}
+	return pm.record(true, plugin, route, name, labels, v)
+}
+
+func (pm *PluginMetrics) record(gauge bool, plugin, route, name string, labels map[string]string, v float64) error {
+	if plugin == "" {
+		return errors.New("plugin metrics require a plugin name")
+	}
+	if !pluginMetricName.MatchString(name) {
+		return fmt.Errorf("invalid metric name %q", name)
+	}
+	if len(labels) > maxPluginLabels {
+		return fmt.Errorf("metric %s: at most %d labels allowed", name, maxPluginLabels)
+	}
+	names := make([]string, 0, len(labels))
+	for l := range labels {
+		if !pluginMetricName.MatchString(l) || strings.HasPrefix(l, "__") {
+			return fmt.Errorf("metric %s: invalid label name %q", name, l)
+		}
+		if l == "route" || l == "plugin" {
+			return fmt.Errorf("metric %s: label %q is reserved", name, l)
+		}
+		names = append(names, l)
+	}
+	sort.Strings(names)
+	values := make([]string, 0, len(names)+2)
+	values = append(values, route, plugin)
+	for _, l := range names {
+		values = append(values, labels[l])
+	}
+	fq := "runway_plugin_" + sanitizePluginName(plugin) + "_" + name
+	key := fq + "\xff" + strings.Join(values, "\xff")
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	fam := pm.families[fq]
+	if fam != nil {
+		switch {
+		case fam.plugin != plugin:
+			return fmt.Errorf("metric %s is already registered by plugin %q", fq, fam.plugin)
+		case fam.gauge != gauge:
+			return fmt.Errorf("metric %s is already registered as a %s", fq, fam.kind())
+		case !slices.Equal(fam.labels, names):
+			return fmt.Errorf("metric %s is registered with labels [%s]", fq, strings.Join(fam.labels, ", "))
+		}
+	}
+	set := pm.series[plugin]
+	if _, ok := set[key]; !ok && len(set) >= pm.maxSeries {
+		return fmt.Errorf("%w (%d) for plugin %q", ErrPluginSeriesLimit, pm.maxSeries, plugin)
+	}
+	if fam == nil {
+		var err error
+		if fam, err = pm.register(fq, plugin, gauge, names); err != nil {
+			return err
+		}
+	}
+	if set == nil {
+		set = make(map[string]pluginSeries)
+		pm.series[plugin] = set
+	}
+	if _, ok := set[key]; !ok {
+		set[key] = pluginSeries{family: fq, route: route, values: values}
+	}
+
+	if gauge {
+		fam.gaugeV.WithLabelValues(values...).Set(v)
+	} else {
+		fam.counter.WithLabelValues(values...).Add(v)
+	}
+	return nil
+}
+
+func (pm *PluginMetrics) register(fq, plugin string, gauge bool, names []string) (*pluginFamily, error) {
+	fam := &pluginFamily{plugin: plugin, gauge: gauge, labels: names}
+	labelNames := append([]string{"route", "plugin"}, names...)
+	help := fmt.Sprintf("Plugin metric reported by %s", plugin)
+	if gauge {
+		fam.gaugeV = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: fq, Help: help}, labelNames)
+	} else {
+		fam.counter = prometheus.NewCounterVec(prometheus.CounterOpts{Name: fq, Help: help}, labelNames)
+	}
+	if err := pm.registry.Register(fam.collector()); err != nil {
+		return nil, fmt.Errorf("register metric %s: %w", fq, err)
+	}
+	pm.families[fq] = fam
+	return fam, nil
+}
+
+// Retain drops the series of plugins that are no longer configured on a
+// route. active maps plugin name to the routes it is configured on.
+// Instruments of plugins missing from active are unregistered; everything
+// else keeps its values.
+func (pm *PluginMetrics) Retain(active map[string]map[string]bool) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	for plugin, set := range pm.series {
+		routes := active[plugin]
+		for key, s := range set {
+			if routes[s.route] {
+				continue
+			}
+			if fam := pm.families[s.family]; fam != nil {
+				fam.delete(s.values)
+			}
+			delete(set, key)
+		}
+		if len(set) == 0 {
+			delete(pm.series, plugin)
+		}
+	}
+	for fq, fam := range pm.families {
+		if _, ok := active[fam.plugin]; !ok {
+			pm.registry.Unregister(fam.collector())
+			delete(pm.families, fq)
+		}
+	}
+}
+
+// Series returns the number of series plugin has created.
+func (pm *PluginMetrics) Series(plugin string) int {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	return len(pm.series[plugin])
+}
+
+func (f *pluginFamily) kind() string {
+	if f.gauge {
+		return "gauge"
+	}
+	return "counter"
+}
+
+func (f *pluginFamily) collector() prometheus.Collector {
+	if f.gauge {
+		return f.gaugeV
+	}
+	return f.counter
+}
+
+func (f *pluginFamily) delete(values []string) {
+	if f.gauge {
+		f.gaugeV.DeleteLabelValues(values...)
+	} else {
+		f.counter.DeleteLabelValues(values...)
+	}
+}
+
+// sanitizePluginName maps a plugin name onto the metric name charset.
+func sanitizePluginName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, c *Collector) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestPluginMetricsExposed(t *testing.T) {
+	c := NewCollector()
+	pm := c.Plugins()
+
+	if err := pm.Inc("geo-tagger", "api", "lookups_total", map[string]string{"result": "hit"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.Inc("geo-tagger", "api", "lookups_total", map[string]string{"result": "hit"}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.Set("geo-tagger", "api", "cache_size", nil, 42); err != nil {
+		t.Fatal(err)
+	}
+
+	body := scrape(t, c)
+	for _, want := range []string{
+		`runway_plugin_geo_tagger_lookups_total{plugin="geo-tagger",result="hit",route="api"} 3`,
+		`runway_plugin_geo_tagger_cache_size{plugin="geo-tagger",route="api"} 42`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in scrape output:\n%s", want, body)
+		}
+	}
+}
+
+func TestPluginMetricsValidation(t *testing.T) {
+	pm := NewCollector().Plugins()
+
+	tests := []struct {
+		name   string
+		plugin string
+		metric string
+		labels map[string]string
+		value  float64
+	}{
+		{"no plugin", "", "hits", nil, 1},
+		{"bad name", "p", "1hits", nil, 1},
+		{"dash in name", "p", "hit-count", nil, 1},
+		{"bad label", "p", "hits", map[string]string{"a-b": "x"}, 1},
+		{"reserved label", "p", "hits", map[string]string{"route": "x"}, 1},
+		{"internal label", "p", "hits", map[string]string{"__name": "x"}, 1},
+		{"negative counter", "p", "hits", nil, -1},
+	}
+	for _, tt := range tests {
+		if err := pm.Inc(tt.plugin, "r", tt.metric, tt.labels, tt.value); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	if err := pm.Inc("p", "r", "hits", map[string]string{"kind": "a"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.Inc("p", "r", "hits", map[string]string{"other": "a"}, 1); err == nil {
+		t.Error("expected an error for a changed label set")
+	}
+	if err := pm.Set("p", "r", "hits", map[string]string{"kind": "a"}, 1); err == nil {
+		t.Error("expected an error for a counter reused as a gauge")
+	}
+	if err := pm.Inc("p_hits", "r", "x", nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.Inc("p", "r", "hits_x", nil, 1); err == nil {
+		t.Error("expected an error for a name owned by another plugin")
+	}
+}
+
+func TestPluginMetricsSeriesLimit(t *testing.T) {
+	pm := NewCollector().Plugins()
+	pm.SetMaxSeries(2)
+
+	for _, user := range []string{"a", "b"} {
+		if err := pm.Inc("p", "r", "hits", map[string]string{"user": user}, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := pm.Inc("p", "r", "hits", map[string]string{"user": "c"}, 1)
+	if !errors.Is(err, ErrPluginSeriesLimit) {
+		t.Fatalf("expected ErrPluginSeriesLimit, got %v", err)
+	}
+	if err := pm.Inc("p", "r", "hits", map[string]string{"user": "a"}, 1); err != nil {
+		t.Errorf("expected an existing series to keep working, got %v", err)
+	}
+	if err := pm.Inc("other", "r", "hits", map[string]string{"user": "c"}, 1); err != nil {
+		t.Errorf("expected the cap to be per plugin, got %v", err)
+	}
+}
+
+func TestPluginMetricsRetain(t *testing.T) {
+	c := NewCollector()
+	pm := c.Plugins()
+	pm.Inc("keep", "r1", "hits", nil, 1)
+	pm.Inc("keep", "r2", "hits", nil, 1)
+	pm.Inc("gone", "r1", "hits", nil, 1)
+
+	pm.Retain(map[string]map[string]bool{"keep": {"r1": true}})
+
+	body := scrape(t, c)
+	if !strings.Contains(body, `runway_plugin_keep_hits{plugin="keep",route="r1"} 1`) {
+		t.Errorf("expected the retained series to keep its value:\n%s", body)
+	}
+	if strings.Contains(body, `route="r2"`) || strings.Contains(body, "runway_plugin_gone_hits") {
+		t.Errorf("expected removed series to be dropped:\n%s", body)
+	}
+	if pm.Series("keep") != 1 || pm.Series("gone") != 0 {
+		t.Errorf("unexpected series counts %d, %d", pm.Series("keep"), pm.Series("gone"))
+	}
+	if err := pm.Set("gone", "r1", "hits", nil, 1); err != nil {
+		t.Errorf("expected a removed plugin's name to be reusable, got %v", err)
+	}
+}
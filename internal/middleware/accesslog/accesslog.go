@@ -60,6 +60,7 @@ func (e *ParseError) Error() string {
 type CompiledAccessLog struct {
 	Enabled          *bool
 	Format           string
+	JSONFields       Fields // nil inherits the global fields
 	headersInclude   map[string]bool
 	headersExclude   map[string]bool
 	sensitiveHeaders map[string]bool
@@ -68,15 +69,27 @@ type CompiledAccessLog struct {
 	methods          map[string]bool
 	sampleRate       float64
 	contentTypes     map[string]bool
+	minSeverity      int
+	sampler          *Sampler
 }
 
 // New compiles an AccessLogConfig into a CompiledAccessLog.
 func New(cfg config.AccessLogConfig) (*CompiledAccessLog, error) {
 	c := &CompiledAccessLog{
-		Enabled:    cfg.Enabled,
-		Format:     cfg.Format,
-		Body:       cfg.Body,
-		sampleRate: cfg.Conditions.SampleRate,
+		Enabled:     cfg.Enabled,
+		Format:      cfg.Format,
+		Body:        cfg.Body,
+		sampleRate:  cfg.Conditions.SampleRate,
+		minSeverity: parseSeverity(cfg.Level),
+		sampler:     NewSampler(cfg.Sampling),
+	}
+
+	if len(cfg.Fields) > 0 {
+		fields, err := CompileFields(cfg.Fields)
+		if err != nil {
+			return nil, err
+		}
+		c.JSONFields = fields
 	}
 
 	// Default body max size
@@ -170,6 +183,20 @@ func (c *CompiledAccessLog) ShouldLog(status int, method string) bool {
 	return true
 }
 
+// Admit applies the route's minimum level and adaptive sampling to a request
+// log with the given status. Call it after ShouldLog so filtered requests do
+// not count toward the sampling rate.
+func (c *CompiledAccessLog) Admit(routeID string, status int) bool {
+	severity := severityForStatus(status)
+	if severity < c.minSeverity {
+		return false
+	}
+	if c.sampler != nil {
+		return c.sampler.Allow(routeID, severity)
+	}
+	return true
+}
+
 // MaskHeaderValue returns "***" if the header name is sensitive, otherwise returns the value.
 func (c *CompiledAccessLog) MaskHeaderValue(name, value string) string {
 	if c.sensitiveHeaders[http.CanonicalHeaderKey(name)] {
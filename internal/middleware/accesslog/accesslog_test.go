@@ -249,6 +249,23 @@ func TestNew_InvalidStatusRange(t *testing.T) {
 	}
 }
 
+func TestNew_Fields(t *testing.T) {
+	c, err := New(config.AccessLogConfig{})
+	if err != nil || c.JSONFields != nil {
+		t.Fatalf("expected inherited fields, got %v, %v", c.JSONFields, err)
+	}
+	c, err = New(config.AccessLogConfig{Format: "json", Fields: []string{"status", "path"}})
+	if err != nil || len(c.JSONFields) != 2 {
+		t.Fatalf("expected 2 fields, got %v, %v", c.JSONFields, err)
+	}
+	if _, err := New(config.AccessLogConfig{Format: "json", Fields: []string{"latency"}}); err == nil {
+		t.Error("expected error for an unknown field")
+	}
+	if all, _ := CompileFields(nil); len(all) != len(config.AccessLogFields) {
+		t.Errorf("expected every field by default, got %d", len(all))
+	}
+}
+
 func TestShouldCaptureBody(t *testing.T) {
 	c, err := New(config.AccessLogConfig{
 		Body: config.AccessLogBodyConfig{
@@ -0,0 +1,121 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
+)
+
+// Fields is a compiled list of the fields of "json" access logs.
+type Fields []fieldFunc
+
+// fieldFunc returns one field of the log of a finished request.
+type fieldFunc func(r *http.Request, varCtx *variables.Context, start time.Time) zap.Field
+
+var fieldFuncs = map[string]fieldFunc{
+	"time": func(_ *http.Request, _ *variables.Context, start time.Time) zap.Field {
+		return zap.String("time", start.UTC().Format(time.RFC3339Nano))
+	},
+	"method": func(r *http.Request, _ *variables.Context, _ time.Time) zap.Field {
+		return zap.String("method", r.Method)
+	},
+	"path": func(r *http.Request, _ *variables.Context, _ time.Time) zap.Field {
+		return zap.String("path", r.URL.Path)
+	},
+	"status": func(_ *http.Request, varCtx *variables.Context, _ time.Time) zap.Field {
+		return zap.Int("status", varCtx.Status)
+	},
+	"duration_ms": func(_ *http.Request, varCtx *variables.Context, _ time.Time) zap.Field {
+		return zap.Float64("duration_ms", float64(varCtx.ResponseTime.Microseconds())/1000)
+	},
+	"route_id": func(_ *http.Request, varCtx *variables.Context, _ time.Time) zap.Field {
+		return zap.String("route_id", varCtx.RouteID)
+	},
+	"listener_id": func(_ *http.Request, varCtx *variables.Context, _ time.Time) zap.Field {
+		return zap.String("listener_id", varCtx.ListenerID)
+	},
+	"client_ip": func(r *http.Request, _ *variables.Context, _ time.Time) zap.Field {
+		return zap.String("client_ip", variables.ExtractClientIP(r))
+	},
+	"request_id": func(_ *http.Request, varCtx *variables.Context, _ time.Time) zap.Field {
+		return zap.String("request_id", varCtx.RequestID)
+	},
+	"upstream_addr": func(_ *http.Request, varCtx *variables.Context, _ time.Time) zap.Field {
+		return zap.String("upstream_addr", varCtx.UpstreamAddr)
+	},
+	"bytes_sent": func(_ *http.Request, varCtx *variables.Context, _ time.Time) zap.Field {
+		return zap.Int64("bytes_sent", varCtx.BodyBytesSent)
+	},
+	"user_agent": func(r *http.Request, _ *variables.Context, _ time.Time) zap.Field {
+		return zap.String("user_agent", r.UserAgent())
+	},
+	"tenant": func(_ *http.Request, varCtx *variables.Context, _ time.Time) zap.Field {
+		return zap.String("tenant", varCtx.TenantID)
+	},
+	"jwt_sub": func(_ *http.Request, varCtx *variables.Context, _ time.Time) zap.Field {
+		var sub string
+		if id := varCtx.Identity; id != nil && id.AuthType == "jwt" {
+			sub, _ = id.Claims["sub"].(string)
+		}
+		return zap.String("jwt_sub", sub)
+	},
+}
+
+// CompileFields compiles a list of field names, or config.AccessLogFields
+// when names is empty.
+func CompileFields(names []string) (Fields, error) {
+	if len(names) == 0 {
+		names = config.AccessLogFields
+	}
+	fields := make(Fields, 0, len(names))
+	for _, name := range names {
+		fn, ok := fieldFuncs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown access log field %q", name)
+		}
+		fields = append(fields, fn)
+	}
+	return fields, nil
+}
+
+// Append appends the fields of the log of r, which started at start, to dst.
+// varCtx must hold the response status, size and time.
+func (f Fields) Append(dst []zap.Field, r *http.Request, varCtx *variables.Context, start time.Time) []zap.Field {
+	for _, fn := range f {
+		dst = append(dst, fn(r, varCtx, start))
+	}
+	return dst
+}
+
+// BodyField returns a captured body under key. A JSON body is nested as a
+// JSON value; anything else, including JSON cut short by the capture size,
+// is logged as a string.
+func BodyField(key, body string) zap.Field {
+	if json.Valid([]byte(body)) {
+		return zap.Reflect(key, json.RawMessage(body))
+	}
+	return zap.String(key, body)
+}
+
+// jsonEncoder encodes only the fields it is given: no time, level, message
+// or caller keys.
+var jsonEncoder = zapcore.NewJSONEncoder(zapcore.EncoderConfig{})
+
+// WriteJSON writes fields to w as one JSON object on its own line.
+func WriteJSON(w io.Writer, fields []zap.Field) error {
+	buf, err := jsonEncoder.EncodeEntry(zapcore.Entry{}, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+	_, err = w.Write(buf.Bytes())
+	return err
+}
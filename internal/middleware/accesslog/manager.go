@@ -9,13 +9,16 @@ import (
 
 // AccessLogStatus represents the status of an access log config for admin API.
 type AccessLogStatus struct {
-	Enabled        *bool    `json:"enabled,omitempty"`
-	Format         string   `json:"format,omitempty"`
-	BodyCapture    bool     `json:"body_capture"`
-	StatusCodes    []string `json:"status_codes,omitempty"`
-	Methods        []string `json:"methods,omitempty"`
-	SampleRate     float64  `json:"sample_rate,omitempty"`
-	HeadersInclude []string `json:"headers_include,omitempty"`
+	Enabled        *bool          `json:"enabled,omitempty"`
+	Format         string         `json:"format,omitempty"`
+	Fields         []string       `json:"fields,omitempty"`
+	BodyCapture    bool           `json:"body_capture"`
+	StatusCodes    []string       `json:"status_codes,omitempty"`
+	Methods        []string       `json:"methods,omitempty"`
+	SampleRate     float64        `json:"sample_rate,omitempty"`
+	HeadersInclude []string       `json:"headers_include,omitempty"`
+	Level          string         `json:"level,omitempty"`
+	Sampling       *SamplerStatus `json:"sampling,omitempty"`
 }
 
 // AccessLogByRoute manages per-route access log configs.
@@ -53,15 +56,22 @@ func (m *AccessLogByRoute) Stats() map[string]AccessLogStatus {
 	defer m.rawMu.RUnlock()
 	result := make(map[string]AccessLogStatus, len(m.raw))
 	for id, cfg := range m.raw {
-		result[id] = AccessLogStatus{
+		status := AccessLogStatus{
 			Enabled:        cfg.Enabled,
 			Format:         cfg.Format,
+			Fields:         cfg.Fields,
 			BodyCapture:    cfg.Body.Enabled,
 			StatusCodes:    cfg.Conditions.StatusCodes,
 			Methods:        cfg.Conditions.Methods,
 			SampleRate:     cfg.Conditions.SampleRate,
 			HeadersInclude: cfg.HeadersInclude,
+			Level:          cfg.Level,
 		}
+		if c := m.Lookup(id); c != nil && c.sampler != nil {
+			st := c.sampler.Status()
+			status.Sampling = &st
+		}
+		result[id] = status
 	}
 	return result
 }
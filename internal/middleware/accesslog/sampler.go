@@ -0,0 +1,167 @@
+package accesslog
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+// Severity of a request log, derived from the response status.
+const (
+	severityInfo = iota
+	severityWarn
+	severityError
+)
+
+// severityForStatus classifies a response: 5xx is an error, 4xx a warning.
+func severityForStatus(status int) int {
+	switch {
+	case status >= 500:
+		return severityError
+	case status >= 400:
+		return severityWarn
+	}
+	return severityInfo
+}
+
+// parseSeverity converts a configured level to a minimum severity.
+func parseSeverity(level string) int {
+	switch level {
+	case "warn":
+		return severityWarn
+	case "error":
+		return severityError
+	}
+	return severityInfo
+}
+
+// Sampler is a per-route token bucket that engages sampling of info-level
+// request logs when the route's log rate exceeds a threshold.
+type Sampler struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64
+	ratio     float64
+	tokens    float64
+	last      time.Time
+	sampling  bool
+	since     time.Time
+	engaged   int64
+	dropped   int64
+	randFloat func() float64
+	now       func() time.Time
+}
+
+// SamplerStatus is the admin-facing sampling state of a route.
+type SamplerStatus struct {
+	Threshold float64    `json:"threshold"`
+	Ratio     float64    `json:"ratio"`
+	Sampling  bool       `json:"sampling"`
+	Since     *time.Time `json:"since,omitempty"`
+	Engaged   int64      `json:"engaged"`
+	Dropped   int64      `json:"dropped"`
+}
+
+// NewSampler creates a sampler from config, or returns nil when disabled.
+func NewSampler(cfg config.AccessLogSampling) *Sampler {
+	if !cfg.Enabled || cfg.Threshold <= 0 {
+		return nil
+	}
+	burst := float64(cfg.Burst)
+	if burst <= 0 {
+		burst = cfg.Threshold
+	}
+	ratio := cfg.Ratio
+	if ratio <= 0 {
+		ratio = 0.1
+	}
+	return &Sampler{
+		rate:      cfg.Threshold,
+		burst:     burst,
+		ratio:     ratio,
+		tokens:    burst,
+		last:      time.Now(),
+		randFloat: rand.Float64,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a log of the given severity should be written.
+// Warnings and errors are always written but still count toward the rate.
+func (s *Sampler) Allow(routeID string, severity int) bool {
+	s.mu.Lock()
+	now := s.now()
+	s.tokens += now.Sub(s.last).Seconds() * s.rate
+	if s.tokens > s.burst {
+		s.tokens = s.burst
+	}
+	s.last = now
+
+	var engaged, disengaged bool
+	if !s.sampling {
+		if s.tokens >= 1 {
+			s.tokens--
+			s.mu.Unlock()
+			return true
+		}
+		s.sampling = true
+		s.since = now
+		s.engaged++
+		engaged = true
+	} else {
+		// Disengage once the bucket has refilled halfway, so a rate hovering
+		// at the threshold does not flap.
+		s.tokens--
+		if s.tokens < 0 {
+			s.tokens = 0
+		}
+		if s.tokens >= s.burst/2 {
+			s.sampling = false
+			disengaged = true
+		}
+	}
+
+	keep := disengaged || severity > severityInfo || s.randFloat() < s.ratio
+	if !keep {
+		s.dropped++
+	}
+	ratio, dropped := s.ratio, s.dropped
+	s.mu.Unlock()
+
+	if engaged {
+		logging.Warn("Access log sampling engaged",
+			zap.String("route_id", routeID),
+			zap.Float64("threshold", s.rate),
+			zap.Float64("ratio", ratio),
+		)
+	}
+	if disengaged {
+		logging.Info("Access log sampling disengaged",
+			zap.String("route_id", routeID),
+			zap.Int64("dropped_total", dropped),
+		)
+	}
+	return keep
+}
+
+// Status returns the sampler's current state.
+func (s *Sampler) Status() SamplerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := SamplerStatus{
+		Threshold: s.rate,
+		Ratio:     s.ratio,
+		Sampling:  s.sampling,
+		Engaged:   s.engaged,
+		Dropped:   s.dropped,
+	}
+	if s.sampling {
+		since := s.since
+		st.Since = &since
+	}
+	return st
+}
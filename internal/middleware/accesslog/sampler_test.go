@@ -0,0 +1,125 @@
+package accesslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func newTestSampler(t *testing.T, cfg config.AccessLogSampling) (*Sampler, *time.Time) {
+	t.Helper()
+	s := NewSampler(cfg)
+	if s == nil {
+		t.Fatal("NewSampler returned nil")
+	}
+	now := time.Unix(1000, 0)
+	s.now = func() time.Time { return now }
+	s.last = now
+	s.randFloat = func() float64 { return 0.5 }
+	return s, &now
+}
+
+func TestNewSampler_Disabled(t *testing.T) {
+	if NewSampler(config.AccessLogSampling{Threshold: 10}) != nil {
+		t.Error("expected nil sampler when disabled")
+	}
+	s := NewSampler(config.AccessLogSampling{Enabled: true, Threshold: 10})
+	if s.burst != 10 || s.ratio != 0.1 {
+		t.Errorf("defaults: burst=%v ratio=%v, want 10 and 0.1", s.burst, s.ratio)
+	}
+}
+
+func TestSampler_EngagesAndDisengages(t *testing.T) {
+	s, now := newTestSampler(t, config.AccessLogSampling{Enabled: true, Threshold: 10, Burst: 4, Ratio: 0.25})
+
+	// The burst is written in full.
+	for i := 0; i < 4; i++ {
+		if !s.Allow("r1", severityInfo) {
+			t.Fatalf("log %d dropped within burst", i)
+		}
+	}
+	// Bucket is empty: sampling engages and info logs are kept at the ratio.
+	if s.Allow("r1", severityInfo) {
+		t.Error("info log kept while sampling (rand 0.5 >= ratio 0.25)")
+	}
+	if !s.Allow("r1", severityWarn) || !s.Allow("r1", severityError) {
+		t.Error("warn/error logs must always be kept while sampling")
+	}
+	st := s.Status()
+	if !st.Sampling || st.Engaged != 1 || st.Dropped != 1 || st.Since == nil {
+		t.Errorf("status = %+v, want sampling with one drop", st)
+	}
+
+	// A quiet second refills the bucket and sampling disengages.
+	*now = now.Add(time.Second)
+	if !s.Allow("r1", severityInfo) {
+		t.Error("log dropped after rate recovered")
+	}
+	if st := s.Status(); st.Sampling || st.Since != nil {
+		t.Errorf("status = %+v, want not sampling", st)
+	}
+	if !s.Allow("r1", severityInfo) {
+		t.Error("log dropped after sampling disengaged")
+	}
+}
+
+func TestSampler_KeepsRatio(t *testing.T) {
+	s, _ := newTestSampler(t, config.AccessLogSampling{Enabled: true, Threshold: 1, Burst: 1, Ratio: 0.5})
+	s.Allow("r1", severityInfo)
+
+	s.randFloat = func() float64 { return 0.2 }
+	if !s.Allow("r1", severityInfo) {
+		t.Error("info log below ratio dropped")
+	}
+	s.randFloat = func() float64 { return 0.7 }
+	if s.Allow("r1", severityInfo) {
+		t.Error("info log above ratio kept")
+	}
+}
+
+func TestAdmit_Level(t *testing.T) {
+	tests := []struct {
+		level  string
+		status int
+		want   bool
+	}{
+		{"", 200, true},
+		{"info", 302, true},
+		{"warn", 200, false},
+		{"warn", 404, true},
+		{"warn", 503, true},
+		{"error", 404, false},
+		{"error", 500, true},
+	}
+	for _, tt := range tests {
+		c, err := New(config.AccessLogConfig{Level: tt.level})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Admit("r1", tt.status); got != tt.want {
+			t.Errorf("level %q status %d: Admit = %v, want %v", tt.level, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestStats_Sampling(t *testing.T) {
+	m := NewAccessLogByRoute()
+	if err := m.AddRoute("r1", config.AccessLogConfig{
+		Level:    "warn",
+		Sampling: config.AccessLogSampling{Enabled: true, Threshold: 50},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddRoute("r2", config.AccessLogConfig{Format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := m.Stats()
+	if st := stats["r1"]; st.Level != "warn" || st.Sampling == nil || st.Sampling.Threshold != 50 {
+		t.Errorf("r1 stats = %+v", st)
+	}
+	if stats["r2"].Sampling != nil {
+		t.Error("r2 should have no sampling status")
+	}
+}
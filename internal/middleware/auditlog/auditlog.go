@@ -30,6 +30,16 @@ type AuditEntry struct {
 	DurationMS   float64       `json:"duration_ms"`
 	RequestBody  string        `json:"request_body,omitempty"`
 	ResponseBody string        `json:"response_body,omitempty"`
+
+	// Route metadata selected by route_metadata.log_keys.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Administrative events (e.g. break-glass) set these instead of the
+	// request fields above.
+	Event   string                 `json:"event,omitempty"`
+	Actor   string                 `json:"actor,omitempty"`
+	Reason  string                 `json:"reason,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
 }
 
 // AuditLogger manages async audit log delivery for a single route.
@@ -40,6 +50,7 @@ type AuditLogger struct {
 	httpClient *http.Client
 	methodSet  map[string]struct{}
 	statusSet  map[int]struct{}
+	skipShadow bool
 
 	// Stats counters.
 	enqueued atomic.Int64
@@ -77,6 +88,7 @@ func New(routeID string, cfg config.AuditLogConfig) *AuditLogger {
 		routeID:    routeID,
 		queue:      make(chan *AuditEntry, cfg.BufferSize),
 		httpClient: &http.Client{Timeout: 10 * time.Second},
+		skipShadow: cfg.SkipShadow == nil || *cfg.SkipShadow,
 		stopCh:     make(chan struct{}),
 		doneCh:     make(chan struct{}),
 	}
@@ -135,6 +147,12 @@ func (al *AuditLogger) Stats() map[string]interface{} {
 func (al *AuditLogger) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Copies shadowed from another route.
+			if al.skipShadow && variables.IsShadow(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			// Method filter.
 			if al.methodSet != nil {
 				if _, ok := al.methodSet[r.Method]; !ok {
@@ -199,6 +217,7 @@ func (al *AuditLogger) Middleware() middleware.Middleware {
 				Duration:   duration,
 				DurationMS: float64(duration.Nanoseconds()) / 1e6,
 				RequestBody: reqBody,
+				Metadata:    variables.GetFromRequest(r).LogMetadata,
 			}
 
 			if al.cfg.IncludeBody {
@@ -141,7 +141,7 @@ func (a *APIKeyAuth) extractKey(r *http.Request) string {
 func (a *APIKeyAuth) IsEnabled() bool {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
-	return len(a.keys) > 0
+	return len(a.keys) > 0 || a.manager != nil
 }
 
 // AddKey adds a new API key
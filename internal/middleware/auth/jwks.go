@@ -2,57 +2,321 @@ package auth
 
 import (
 	"context"
+	"crypto"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/lestrrat-go/jwx/v2/jwk"
+	"go.uber.org/zap"
+
+	"github.com/wudi/runway/internal/logging"
+)
+
+const (
+	// DefaultJWKSFetchTimeout bounds a single JWKS fetch by default.
+	DefaultJWKSFetchTimeout = 10 * time.Second
+	// jwksMinBackoff is the backoff after a URL's first failed fetch. It
+	// doubles with every further failure, up to the refresh interval.
+	jwksMinBackoff = time.Second
+	// maxJWKSSize bounds the size of a fetched key set.
+	maxJWKSSize = 1 << 20
 )
 
+// JWKSOptions configures a JWKSProvider.
+type JWKSOptions struct {
+	URLs            []string      // tried in order; later URLs are fallbacks
+	RefreshInterval time.Duration // default 1h
+	FetchTimeout    time.Duration // per fetch (default DefaultJWKSFetchTimeout)
+	Client          *http.Client  // default http.DefaultClient
+}
+
 // JWKSProvider fetches and caches JSON Web Key Sets for JWT validation.
+//
+// Each refresh tries the URLs in order, skipping URLs backing off from
+// failed fetches, and keeps the key set of the first that succeeds. When
+// every URL fails, the last good key set stays in use indefinitely and the
+// refresh is retried as soon as a URL's backoff expires.
 type JWKSProvider struct {
-	cache   *jwk.Cache
-	url     string
+	sources []*jwksSource
 	refresh time.Duration
+	timeout time.Duration
+	client  *http.Client
+
+	keys atomic.Pointer[jwk.Set] // last good key set
+
+	mu          sync.Mutex // guards sources' state and the fields below
+	activeURL   string
+	fetchedAt   time.Time
+	rotatedAt   time.Time
+	fingerprint string
+	stale       bool
+
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-// NewJWKSProvider creates a JWKS provider that auto-refreshes keys.
+// jwksSource is the fetch state of one JWKS URL.
+type jwksSource struct {
+	url         string
+	failures    int // consecutive
+	lastSuccess time.Time
+	lastError   string
+	lastErrorAt time.Time
+	retryAt     time.Time // skipped by refreshes until then
+}
+
+// JWKSStats is the admin API snapshot of a JWKSProvider.
+type JWKSStats struct {
+	URLs        []JWKSURLStats `json:"urls"`
+	ActiveURL   string         `json:"active_url"`   // URL the key set in use was last fetched from
+	LastFetched time.Time      `json:"last_fetched"` // last successful refresh
+	LastRotated time.Time      `json:"last_rotated"` // when the key set in use was first fetched
+	Keys        int            `json:"keys"`
+	Stale       bool           `json:"stale"` // the last refresh failed on every URL
+}
+
+// JWKSURLStats is the fetch state of one JWKS URL.
+type JWKSURLStats struct {
+	URL          string     `json:"url"`
+	LastSuccess  *time.Time `json:"last_success,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastErrorAt  *time.Time `json:"last_error_at,omitempty"`
+	Failures     int        `json:"consecutive_failures"`
+	BackoffUntil *time.Time `json:"backoff_until,omitempty"`
+}
+
+// NewJWKSProvider creates a JWKS provider for a single URL that
+// auto-refreshes keys.
 func NewJWKSProvider(jwksURL string, refreshInterval time.Duration) (*JWKSProvider, error) {
-	if refreshInterval <= 0 {
-		refreshInterval = time.Hour
+	return NewJWKSProviderWithOptions(JWKSOptions{URLs: []string{jwksURL}, RefreshInterval: refreshInterval})
+}
+
+// NewJWKSProviderWithOptions creates a JWKS provider that auto-refreshes
+// keys from opts.URLs. The initial fetch must succeed on one of the URLs.
+func NewJWKSProviderWithOptions(opts JWKSOptions) (*JWKSProvider, error) {
+	if len(opts.URLs) == 0 {
+		return nil, fmt.Errorf("no JWKS URL configured")
+	}
+	p := &JWKSProvider{
+		refresh: opts.RefreshInterval,
+		timeout: opts.FetchTimeout,
+		client:  opts.Client,
+		done:    make(chan struct{}),
+	}
+	if p.refresh <= 0 {
+		p.refresh = time.Hour
+	}
+	if p.timeout <= 0 {
+		p.timeout = DefaultJWKSFetchTimeout
+	}
+	if p.client == nil {
+		p.client = http.DefaultClient
+	}
+	for _, u := range opts.URLs {
+		p.sources = append(p.sources, &jwksSource{url: u})
 	}
 
-	ctx := context.Background()
-	cache := jwk.NewCache(ctx)
+	if err := p.refreshKeys(); err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	go p.run()
+	return p, nil
+}
 
-	err := cache.Register(jwksURL, jwk.WithMinRefreshInterval(refreshInterval))
-	if err != nil {
-		return nil, fmt.Errorf("failed to register JWKS URL: %w", err)
+// run refreshes the key set every refresh interval, retrying failed
+// refreshes as soon as a URL's backoff expires.
+func (p *JWKSProvider) run() {
+	timer := time.NewTimer(p.refresh)
+	defer timer.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-timer.C:
+		}
+		next := p.refresh
+		if err := p.refreshKeys(); err != nil {
+			logging.Warn("JWKS refresh failed, keeping the last key set", zap.Error(err))
+			next = p.nextRetry()
+		}
+		timer.Reset(next)
 	}
+}
+
+// nextRetry returns the time until the first URL's backoff expires.
+func (p *JWKSProvider) nextRetry() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	next := p.refresh
+	for _, s := range p.sources {
+		if d := time.Until(s.retryAt); d < next {
+			next = d
+		}
+	}
+	return max(next, jwksMinBackoff)
+}
 
-	// Initial fetch to verify the URL works
-	_, err = cache.Refresh(ctx, jwksURL)
+// refreshKeys fetches the key set from the first URL that is not backing
+// off and succeeds.
+func (p *JWKSProvider) refreshKeys() error {
+	var errs []error
+	for _, s := range p.sources {
+		p.mu.Lock()
+		backingOff := time.Now().Before(s.retryAt)
+		p.mu.Unlock()
+		if backingOff {
+			continue
+		}
+		set, err := p.fetch(s.url)
+		if err != nil {
+			p.recordFailure(s, err)
+			errs = append(errs, fmt.Errorf("%s: %w", s.url, err))
+			continue
+		}
+		p.store(s, set)
+		return nil
+	}
+	p.mu.Lock()
+	p.stale = p.keys.Load() != nil
+	p.mu.Unlock()
+	if len(errs) == 0 {
+		return fmt.Errorf("all JWKS URLs are backing off")
+	}
+	return errors.Join(errs...)
+}
+
+func (p *JWKSProvider) fetch(url string) (jwk.Set, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize+1))
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch JWKS from %s: %w", jwksURL, err)
+		return nil, err
 	}
+	if len(body) > maxJWKSSize {
+		return nil, fmt.Errorf("key set exceeds %d bytes", maxJWKSSize)
+	}
+	set, err := jwk.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing key set: %w", err)
+	}
+	if set.Len() == 0 {
+		return nil, fmt.Errorf("key set has no keys")
+	}
+	return set, nil
+}
 
-	return &JWKSProvider{
-		cache:   cache,
-		url:     jwksURL,
-		refresh: refreshInterval,
-	}, nil
+func (p *JWKSProvider) recordFailure(s *jwksSource, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	s.failures++
+	s.lastError = err.Error()
+	s.lastErrorAt = now
+	backoff := p.refresh
+	if shift := s.failures - 1; shift < 32 {
+		backoff = min(jwksMinBackoff<<shift, p.refresh)
+	}
+	s.retryAt = now.Add(backoff)
+}
+
+func (p *JWKSProvider) store(s *jwksSource, set jwk.Set) {
+	fp := fingerprint(set)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	s.failures = 0
+	s.retryAt = time.Time{}
+	s.lastSuccess = now
+	p.activeURL = s.url
+	p.fetchedAt = now
+	p.stale = false
+	if fp != p.fingerprint {
+		p.fingerprint = fp
+		p.rotatedAt = now
+	}
+	p.keys.Store(&set)
+}
+
+// fingerprint identifies the keys of set, independent of their order and
+// of the document's formatting.
+func fingerprint(set jwk.Set) string {
+	ids := make([]string, 0, set.Len())
+	for i := range set.Len() {
+		key, _ := set.Key(i)
+		tp, err := key.Thumbprint(crypto.SHA256)
+		if err != nil {
+			ids = append(ids, "kid:"+key.KeyID())
+			continue
+		}
+		ids = append(ids, key.KeyID()+":"+hex.EncodeToString(tp))
+	}
+	slices.Sort(ids)
+	return fmt.Sprint(ids)
+}
+
+// Stats returns the admin API snapshot.
+func (p *JWKSProvider) Stats() JWKSStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := JWKSStats{
+		URLs:        make([]JWKSURLStats, 0, len(p.sources)),
+		ActiveURL:   p.activeURL,
+		LastFetched: p.fetchedAt,
+		LastRotated: p.rotatedAt,
+		Stale:       p.stale,
+	}
+	if set := p.keys.Load(); set != nil {
+		st.Keys = (*set).Len()
+	}
+	now := time.Now()
+	for _, s := range p.sources {
+		us := JWKSURLStats{URL: s.url, LastError: s.lastError, Failures: s.failures}
+		if !s.lastSuccess.IsZero() {
+			t := s.lastSuccess
+			us.LastSuccess = &t
+		}
+		if !s.lastErrorAt.IsZero() {
+			t := s.lastErrorAt
+			us.LastErrorAt = &t
+		}
+		if s.retryAt.After(now) {
+			t := s.retryAt
+			us.BackoffUntil = &t
+		}
+		st.URLs = append(st.URLs, us)
+	}
+	return st
 }
 
 // KeyFunc returns a jwt.Keyfunc compatible with golang-jwt/jwt/v5.
 func (p *JWKSProvider) KeyFunc() jwt.Keyfunc {
 	return func(token *jwt.Token) (interface{}, error) {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		keySet, err := p.cache.Get(ctx, p.url)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get JWKS: %w", err)
+		set := p.keys.Load()
+		if set == nil {
+			return nil, fmt.Errorf("failed to get JWKS: no key set fetched")
 		}
+		keySet := *set
 
 		// Find key by kid header
 		kid, ok := token.Header["kid"].(string)
@@ -85,6 +349,5 @@ func (p *JWKSProvider) KeyFunc() jwt.Keyfunc {
 
 // Close stops the background refresh goroutine.
 func (p *JWKSProvider) Close() {
-	// jwk.Cache doesn't expose a close method; it stops when context is cancelled.
-	// The context used in NewCache is Background, so this is a no-op.
+	p.closeOnce.Do(func() { close(p.done) })
 }
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
@@ -49,8 +50,8 @@ func TestNewJWKSProvider(t *testing.T) {
 	if provider == nil {
 		t.Fatal("NewJWKSProvider() returned nil")
 	}
-	if provider.url != srv.URL {
-		t.Errorf("expected url %q, got %q", srv.URL, provider.url)
+	if provider.sources[0].url != srv.URL {
+		t.Errorf("expected url %q, got %q", srv.URL, provider.sources[0].url)
 	}
 }
 
@@ -206,3 +207,118 @@ func TestJWKSProviderClose(t *testing.T) {
 	// Close should not panic
 	provider.Close()
 }
+
+// switchableJWKS serves the JWKS of key, or 503 while failing is set.
+type switchableJWKS struct {
+	mu      sync.Mutex
+	key     *ecdsa.PrivateKey
+	failing bool
+}
+
+func (s *switchableJWKS) set(key *ecdsa.PrivateKey, failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.key, s.failing = key, failing
+}
+
+func (s *switchableJWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	key, failing := s.key, s.failing
+	s.mu.Unlock()
+	if failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	jwkKey, _ := jwk.FromRaw(&key.PublicKey)
+	jwkKey.Set(jwk.KeyIDKey, "k1")
+	set := jwk.NewSet()
+	set.AddKey(jwkKey)
+	json.NewEncoder(w).Encode(set)
+}
+
+func TestJWKSProviderFailover(t *testing.T) {
+	key1, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	key2, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	primary, backup := &switchableJWKS{}, &switchableJWKS{}
+	primary.set(key1, true)
+	backup.set(key1, false)
+	primarySrv, backupSrv := httptest.NewServer(primary), httptest.NewServer(backup)
+	defer primarySrv.Close()
+	defer backupSrv.Close()
+
+	p, err := NewJWKSProviderWithOptions(JWKSOptions{
+		URLs:         []string{primarySrv.URL, backupSrv.URL},
+		FetchTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("expected the backup to serve the initial fetch, got %v", err)
+	}
+	defer p.Close()
+
+	verify := func(key *ecdsa.PrivateKey) error {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"sub": "u"})
+		token.Header["kid"] = "k1"
+		s, _ := token.SignedString(key)
+		_, err := jwt.Parse(s, p.KeyFunc())
+		return err
+	}
+
+	st := p.Stats()
+	if st.ActiveURL != backupSrv.URL || st.Keys != 1 || st.Stale {
+		t.Errorf("expected the backup active, got %+v", st)
+	}
+	if u := st.URLs[0]; u.Failures != 1 || u.LastError == "" || u.BackoffUntil == nil {
+		t.Errorf("expected the primary backing off, got %+v", u)
+	}
+	rotated := st.LastRotated
+
+	// The primary recovers but is skipped until its backoff expires.
+	primary.set(key1, false)
+	p.refreshKeys()
+	if st := p.Stats(); st.ActiveURL != backupSrv.URL {
+		t.Errorf("expected the primary skipped while backing off, got %s", st.ActiveURL)
+	}
+	p.sources[0].retryAt = time.Time{}
+	p.refreshKeys()
+	st = p.Stats()
+	if st.ActiveURL != primarySrv.URL || st.URLs[0].Failures != 0 || st.URLs[0].BackoffUntil != nil {
+		t.Errorf("expected the primary active again, got %+v", st)
+	}
+	if !st.LastRotated.Equal(rotated) {
+		t.Errorf("expected the same keys not to count as a rotation")
+	}
+
+	// Every URL failing keeps the last good key set.
+	primary.set(key1, true)
+	backup.set(key1, true)
+	if err := p.refreshKeys(); err == nil {
+		t.Fatal("expected the refresh to fail")
+	}
+	if st := p.Stats(); !st.Stale || st.Keys != 1 {
+		t.Errorf("expected the stale key set kept, got %+v", st)
+	}
+	if err := verify(key1); err != nil {
+		t.Errorf("expected the stale key set to verify tokens, got %v", err)
+	}
+	if err := p.refreshKeys(); err == nil || err.Error() != "all JWKS URLs are backing off" {
+		t.Errorf("expected all URLs backing off, got %v", err)
+	}
+	if d := p.nextRetry(); d < jwksMinBackoff || d > 2*jwksMinBackoff {
+		t.Errorf("expected a retry after the primary's backoff, got %v", d)
+	}
+
+	// A new key set is a rotation.
+	backup.set(key2, false)
+	p.sources[0].retryAt, p.sources[1].retryAt = time.Time{}, time.Time{}
+	p.refreshKeys()
+	st = p.Stats()
+	if st.Stale || st.ActiveURL != backupSrv.URL || !st.LastRotated.After(rotated) {
+		t.Errorf("expected a rotation from the backup, got %+v", st)
+	}
+	if st.URLs[0].Failures != 2 {
+		t.Errorf("expected 2 consecutive primary failures, got %d", st.URLs[0].Failures)
+	}
+	if err := verify(key2); err != nil {
+		t.Errorf("expected the rotated key to verify tokens, got %v", err)
+	}
+}
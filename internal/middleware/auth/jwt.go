@@ -39,9 +39,13 @@ func NewJWTAuth(cfg config.JWTConfig) (*JWTAuth, error) {
 		auth.algorithm = "HS256"
 	}
 
-	// If JWKS URL is configured, use it for key resolution
-	if cfg.JWKSURL != "" {
-		provider, err := NewJWKSProvider(cfg.JWKSURL, cfg.JWKSRefreshInterval)
+	// If JWKS URLs are configured, use them for key resolution
+	if urls := cfg.JWKSEndpoints(); len(urls) > 0 {
+		provider, err := NewJWKSProviderWithOptions(JWKSOptions{
+			URLs:            urls,
+			RefreshInterval: cfg.JWKSRefreshInterval,
+			FetchTimeout:    cfg.JWKSFetchTimeout,
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize JWKS: %w", err)
 		}
@@ -185,6 +189,16 @@ func (a *JWTAuth) IsEnabled() bool {
 	return len(a.secret) > 0 || a.publicKey != nil || a.jwksProvider != nil
 }
 
+// JWKSStats returns the JWKS provider's admin snapshot, or nil when keys
+// are not fetched from JWKS.
+func (a *JWTAuth) JWKSStats() *JWKSStats {
+	if a.jwksProvider == nil {
+		return nil
+	}
+	st := a.jwksProvider.Stats()
+	return &st
+}
+
 // Close releases JWKS resources if any.
 func (a *JWTAuth) Close() {
 	if a.jwksProvider != nil {
@@ -5,6 +5,8 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"net/http"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -23,8 +25,31 @@ type APIKeyManager struct {
 	rateLimiters map[string]*rate.Limiter // keyHash → per-key limiter
 	mu           sync.RWMutex
 	metrics      KeyManagerMetrics
+
+	// writeMu serializes read-modify-write cycles on stored keys so that
+	// concurrent rotations, revocations and usage updates cannot overwrite
+	// each other. Stored *ManagedKey values are never mutated in place.
+	writeMu sync.Mutex
+
+	// usage holds per-key usage recorded since the last flush (keyHash →
+	// *keyUsage), so authentication never writes to the store.
+	usage     sync.Map
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// usageFlushInterval is how often recorded key usage is written to the store.
+const usageFlushInterval = 10 * time.Second
+
+// keyUsage is the usage of a key not yet written to the store.
+type keyUsage struct {
+	count atomic.Int64
+	last  atomic.Int64 // unix nanoseconds of the latest use
 }
 
+// errKeyRotated is returned when rotating a key that has already been rotated.
+var errKeyRotated = errors.New(http.StatusConflict, "Conflict").WithDetails("key has already been rotated")
+
 // KeyManagerMetrics tracks manager-level activity.
 type KeyManagerMetrics struct {
 	Generated   atomic.Int64
@@ -47,20 +72,32 @@ func NewAPIKeyManager(cfg KeyManagerConfig) *APIKeyManager {
 	if keyLen <= 0 {
 		keyLen = 32
 	}
-	return &APIKeyManager{
+	m := &APIKeyManager{
 		store:        cfg.Store,
 		keyLength:    keyLen,
 		keyPrefix:    cfg.KeyPrefix,
 		defaultRL:    cfg.DefaultRL,
 		rateLimiters: make(map[string]*rate.Limiter),
+		done:         make(chan struct{}),
 	}
+	go m.flushLoop()
+	return m
 }
 
 // GenerateKey creates a new managed key and returns the raw key (only time visible).
 func (m *APIKeyManager) GenerateKey(clientID, name string, roles []string, rl *KeyRateLimit, ttl time.Duration) (string, error) {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	rawKey, _, err := m.generateLocked(clientID, name, roles, rl, ttl, nil)
+	return rawKey, err
+}
+
+// generateLocked creates and stores a new key. apply, if non-nil, adjusts the
+// key before it is stored. Callers must hold writeMu.
+func (m *APIKeyManager) generateLocked(clientID, name string, roles []string, rl *KeyRateLimit, ttl time.Duration, apply func(*ManagedKey)) (string, *ManagedKey, error) {
 	rawBytes := make([]byte, m.keyLength)
 	if _, err := rand.Read(rawBytes); err != nil {
-		return "", fmt.Errorf("generating random key: %w", err)
+		return "", nil, fmt.Errorf("generating random key: %w", err)
 	}
 	rawKey := m.keyPrefix + hex.EncodeToString(rawBytes)
 
@@ -80,9 +117,12 @@ func (m *APIKeyManager) GenerateKey(clientID, name string, roles []string, rl *K
 	if ttl > 0 {
 		mk.ExpiresAt = now.Add(ttl)
 	}
+	if apply != nil {
+		apply(mk)
+	}
 
 	if err := m.store.Store(keyHash, mk); err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	// Set up rate limiter
@@ -100,66 +140,162 @@ func (m *APIKeyManager) GenerateKey(clientID, name string, roles []string, rl *K
 	}
 
 	m.metrics.Generated.Add(1)
-	return rawKey, nil
+	return rawKey, mk, nil
 }
 
 // RotateKey creates a new key replacing the one identified by prefix, with a grace period for the old key.
 func (m *APIKeyManager) RotateKey(keyPrefix string, gracePeriod time.Duration) (string, error) {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
 	oldKey, oldHash := m.findByPrefix(keyPrefix)
 	if oldKey == nil {
 		return "", errors.ErrNotFound.WithDetails("key not found")
 	}
+	newRawKey, _, err := m.rotateLocked(oldKey, oldHash, gracePeriod)
+	return newRawKey, err
+}
+
+// rotateLocked replaces oldKey with a new key carrying the same metadata and
+// starts the old key's grace period. Callers must hold writeMu.
+func (m *APIKeyManager) rotateLocked(oldKey *ManagedKey, oldHash string, gracePeriod time.Duration) (string, *ManagedKey, error) {
 	if oldKey.Revoked {
-		return "", errors.ErrForbidden.WithDetails("cannot rotate a revoked key")
+		return "", nil, errors.ErrForbidden.WithDetails("cannot rotate a revoked key")
 	}
-
-	// Generate new key with same metadata
-	newRawKey, err := m.GenerateKey(oldKey.ClientID, oldKey.Name, oldKey.Roles, oldKey.RateLimit, 0)
-	if err != nil {
-		return "", err
+	if !oldKey.RotationDeadline.IsZero() {
+		return "", nil, errKeyRotated
 	}
 
-	// Set expiration on new key to match old key's original expiration
-	newHash := sha256.Sum256([]byte(newRawKey))
-	newKeyHash := hex.EncodeToString(newHash[:])
-	if newMK, ok := m.store.Lookup(newKeyHash); ok {
-		newMK.ExpiresAt = oldKey.ExpiresAt
-		newMK.RotatedFrom = oldHash
-		m.store.Store(newKeyHash, newMK)
+	// Generate new key with same metadata, expiring with the old key's original expiration
+	newRawKey, newKey, err := m.generateLocked(oldKey.ClientID, oldKey.Name, oldKey.Roles, oldKey.RateLimit, 0, func(mk *ManagedKey) {
+		mk.ExpiresAt = oldKey.ExpiresAt
+		mk.RotatedFrom = oldHash
+	})
+	if err != nil {
+		return "", nil, err
 	}
 
 	// Mark old key with rotation deadline
-	oldKey.RotationDeadline = time.Now().Add(gracePeriod)
-	m.store.Store(oldHash, oldKey)
+	updated := *oldKey
+	updated.RotationDeadline = time.Now().Add(gracePeriod)
+	if err := m.store.Store(oldHash, &updated); err != nil {
+		return "", nil, err
+	}
 
 	m.metrics.Rotated.Add(1)
-	return newRawKey, nil
+	return newRawKey, newKey, nil
 }
 
 // RevokeKey marks a key as revoked (returns 403 on use).
 func (m *APIKeyManager) RevokeKey(keyPrefix string) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
 	mk, hash := m.findByPrefix(keyPrefix)
 	if mk == nil {
 		return errors.ErrNotFound.WithDetails("key not found")
 	}
-	mk.Revoked = true
-	mk.RevokedAt = time.Now()
-	return m.store.Store(hash, mk)
+	_, err := m.revokeLocked(mk, hash)
+	return err
+}
+
+// revokeLocked stores a revoked copy of mk. Callers must hold writeMu.
+func (m *APIKeyManager) revokeLocked(mk *ManagedKey, hash string) (*ManagedKey, error) {
+	updated := *mk
+	updated.Revoked = true
+	updated.RevokedAt = time.Now()
+	if err := m.store.Store(hash, &updated); err != nil {
+		return nil, err
+	}
+	m.metrics.Revoked.Add(1)
+	return &updated, nil
 }
 
 // UnrevokeKey restores a revoked key.
 func (m *APIKeyManager) UnrevokeKey(keyPrefix string) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
 	mk, hash := m.findByPrefix(keyPrefix)
 	if mk == nil {
 		return errors.ErrNotFound.WithDetails("key not found")
 	}
-	mk.Revoked = false
-	mk.RevokedAt = time.Time{}
-	return m.store.Store(hash, mk)
+	updated := *mk
+	updated.Revoked = false
+	updated.RevokedAt = time.Time{}
+	return m.store.Store(hash, &updated)
+}
+
+// OwnedKeys returns the keys belonging to owner, oldest first.
+func (m *APIKeyManager) OwnedKeys(owner string) []*ManagedKey {
+	m.flushUsage()
+	var result []*ManagedKey
+	for _, mk := range m.store.List() {
+		if mk.ClientID == owner {
+			result = append(result, mk)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// CreateOwnedKey generates a key for owner, refusing once owner holds maxKeys
+// active keys (0 = unlimited). Returns the raw key and its stored metadata.
+func (m *APIKeyManager) CreateOwnedKey(owner, name string, ttl time.Duration, maxKeys int) (string, *ManagedKey, error) {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	if maxKeys > 0 {
+		active := 0
+		now := time.Now()
+		for _, mk := range m.store.List() {
+			if mk.ClientID == owner && mk.Active(now) {
+				active++
+			}
+		}
+		if active >= maxKeys {
+			return "", nil, errors.ErrForbidden.WithDetails(fmt.Sprintf("key limit of %d reached", maxKeys))
+		}
+	}
+	return m.generateLocked(owner, name, nil, nil, ttl, nil)
+}
+
+// RotateOwnedKey rotates the key with the given ID if it belongs to owner.
+func (m *APIKeyManager) RotateOwnedKey(owner, id string, gracePeriod time.Duration) (string, *ManagedKey, error) {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	mk, hash := m.findOwned(owner, id)
+	if mk == nil {
+		return "", nil, errors.ErrNotFound.WithDetails("key not found")
+	}
+	return m.rotateLocked(mk, hash, gracePeriod)
+}
+
+// RevokeOwnedKey revokes the key with the given ID if it belongs to owner.
+// The returned bool is false when the key was already revoked.
+func (m *APIKeyManager) RevokeOwnedKey(owner, id string) (*ManagedKey, bool, error) {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	mk, hash := m.findOwned(owner, id)
+	if mk == nil {
+		return nil, false, errors.ErrNotFound.WithDetails("key not found")
+	}
+	if mk.Revoked {
+		return mk, false, nil
+	}
+	revoked, err := m.revokeLocked(mk, hash)
+	return revoked, err == nil, err
 }
 
 // DeleteKey permanently removes a key.
 func (m *APIKeyManager) DeleteKey(keyPrefix string) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
 	mk, hash := m.findByPrefix(keyPrefix)
 	if mk == nil {
 		return errors.ErrNotFound.WithDetails("key not found")
@@ -167,6 +303,7 @@ func (m *APIKeyManager) DeleteKey(keyPrefix string) error {
 	m.mu.Lock()
 	delete(m.rateLimiters, hash)
 	m.mu.Unlock()
+	m.usage.Delete(hash)
 	return m.store.Remove(hash)
 }
 
@@ -204,10 +341,7 @@ func (m *APIKeyManager) Authenticate(rawKey string) (*variables.Identity, error)
 		return nil, errors.ErrTooManyRequests.WithDetails("API key rate limit exceeded")
 	}
 
-	// Update usage
-	mk.LastUsedAt = time.Now()
-	mk.UsageCount++
-	m.store.Store(keyHash, mk)
+	m.recordUsage(keyHash)
 
 	claims := map[string]interface{}{
 		"client_id": mk.ClientID,
@@ -236,12 +370,71 @@ func (m *APIKeyManager) Stats() map[string]interface{} {
 
 // ListKeys returns all managed keys.
 func (m *APIKeyManager) ListKeys() map[string]*ManagedKey {
+	m.flushUsage()
 	return m.store.List()
 }
 
-// Close closes the underlying store.
+// Close writes pending usage and closes the underlying store.
 func (m *APIKeyManager) Close() {
-	m.store.Close()
+	m.closeOnce.Do(func() {
+		close(m.done)
+		m.flushUsage()
+		m.store.Close()
+	})
+}
+
+// recordUsage counts a successful authentication with the key.
+func (m *APIKeyManager) recordUsage(keyHash string) {
+	v, ok := m.usage.Load(keyHash)
+	if !ok {
+		v, _ = m.usage.LoadOrStore(keyHash, &keyUsage{})
+	}
+	u := v.(*keyUsage)
+	u.count.Add(1)
+	u.last.Store(time.Now().UnixNano())
+}
+
+func (m *APIKeyManager) flushLoop() {
+	ticker := time.NewTicker(usageFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.flushUsage()
+		}
+	}
+}
+
+// flushUsage adds the usage recorded since the last flush to the latest
+// stored copy of each key, so a concurrent revocation or rotation is not
+// overwritten. Usage of revoked keys is dropped.
+func (m *APIKeyManager) flushUsage() {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	m.usage.Range(func(k, v any) bool {
+		keyHash, u := k.(string), v.(*keyUsage)
+		n := u.count.Swap(0)
+		if n == 0 {
+			return true
+		}
+		cur, ok := m.store.Lookup(keyHash)
+		if !ok {
+			m.usage.Delete(keyHash)
+			return true
+		}
+		if cur.Revoked {
+			return true
+		}
+		updated := *cur
+		updated.UsageCount += n
+		if last := time.Unix(0, u.last.Load()); last.After(updated.LastUsedAt) {
+			updated.LastUsedAt = last
+		}
+		m.store.Store(keyHash, &updated)
+		return true
+	})
 }
 
 // findByPrefix finds a key by its display prefix.
@@ -254,3 +447,16 @@ func (m *APIKeyManager) findByPrefix(prefix string) (*ManagedKey, string) {
 	}
 	return nil, ""
 }
+
+// findOwned finds a key by ID, returning nil unless it belongs to owner.
+func (m *APIKeyManager) findOwned(owner, id string) (*ManagedKey, string) {
+	if id == "" {
+		return nil, ""
+	}
+	for hash, mk := range m.store.List() {
+		if mk.ID() == id && mk.ClientID == owner {
+			return mk, hash
+		}
+	}
+	return nil, ""
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -976,3 +977,54 @@ func TestAPIKeyAuthQueryParamWithManager(t *testing.T) {
 		t.Errorf("expected managed-qp, got %q", identity2.ClientID)
 	}
 }
+
+// countingStore counts the writes to a key store.
+type countingStore struct {
+	KeyStore
+	stores atomic.Int64
+}
+
+func (cs *countingStore) Store(keyHash string, key *ManagedKey) error {
+	cs.stores.Add(1)
+	return cs.KeyStore.Store(keyHash, key)
+}
+
+func TestAuthenticateDefersUsageWrites(t *testing.T) {
+	store := &countingStore{KeyStore: NewMemoryKeyStore(60 * time.Second)}
+	mgr := NewAPIKeyManager(KeyManagerConfig{Store: store})
+	defer mgr.Close()
+
+	rawKey, _ := mgr.GenerateKey("client-1", "test", nil, nil, 0)
+	generated := store.stores.Load()
+
+	for i := 0; i < 100; i++ {
+		if _, err := mgr.Authenticate(rawKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := store.stores.Load() - generated; n != 0 {
+		t.Errorf("expected no store writes on authentication, got %d", n)
+	}
+
+	mgr.flushUsage()
+	if n := store.stores.Load() - generated; n != 1 {
+		t.Errorf("expected one write per flushed key, got %d", n)
+	}
+	for _, mk := range store.List() {
+		if mk.UsageCount != 100 {
+			t.Errorf("expected 100 usages after flush, got %d", mk.UsageCount)
+		}
+	}
+
+	// Usage of a key revoked before the flush is dropped.
+	mgr.Authenticate(rawKey)
+	for _, mk := range store.List() {
+		mgr.RevokeKey(mk.KeyPrefix)
+	}
+	mgr.flushUsage()
+	for _, mk := range store.List() {
+		if mk.UsageCount != 100 || !mk.Revoked {
+			t.Errorf("expected the revoked key unchanged, got usage %d revoked %v", mk.UsageCount, mk.Revoked)
+		}
+	}
+}
@@ -24,6 +24,20 @@ type ManagedKey struct {
 	RotationDeadline time.Time     `json:"rotation_deadline,omitempty"`
 }
 
+// ID returns a stable, non-secret identifier for the key derived from its hash.
+func (mk *ManagedKey) ID() string {
+	return mk.KeyHash[:min(len(mk.KeyHash), 16)]
+}
+
+// Active reports whether the key can still authenticate at now: it is not
+// revoked, expired, or replaced by a rotation.
+func (mk *ManagedKey) Active(now time.Time) bool {
+	if mk.Revoked || !mk.RotationDeadline.IsZero() {
+		return false
+	}
+	return mk.ExpiresAt.IsZero() || now.Before(mk.ExpiresAt)
+}
+
 // KeyRateLimit defines per-key rate limiting.
 type KeyRateLimit struct {
 	Rate   int           `json:"rate"`
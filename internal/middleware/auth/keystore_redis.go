@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+// redisKeyStoreTimeout bounds each Redis round trip of the key store.
+const redisKeyStoreTimeout = 2 * time.Second
+
+// RedisKeyStore is a Redis-backed key store shared by all gateway instances.
+// Keys are stored as JSON under prefix+keyHash and expire with the key's
+// expiration or rotation deadline, whichever comes first.
+type RedisKeyStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisKeyStore creates a new Redis-backed key store.
+func NewRedisKeyStore(client *redis.Client) *RedisKeyStore {
+	return &RedisKeyStore{
+		client: client,
+		prefix: "gw:apikey:",
+	}
+}
+
+// Lookup returns the key data for a given hash. Fails closed on errors.
+func (rs *RedisKeyStore) Lookup(keyHash string) (*ManagedKey, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisKeyStoreTimeout)
+	defer cancel()
+
+	data, err := rs.client.Get(ctx, rs.prefix+keyHash).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			logging.Warn("API key store Redis GET error",
+				zap.String("key_hash", keyHash),
+				zap.Error(err),
+			)
+		}
+		return nil, false
+	}
+	var mk ManagedKey
+	if err := json.Unmarshal(data, &mk); err != nil {
+		return nil, false
+	}
+	return &mk, true
+}
+
+// Store saves a key to Redis.
+func (rs *RedisKeyStore) Store(keyHash string, key *ManagedKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	var ttl time.Duration
+	deadline := key.ExpiresAt
+	if !key.RotationDeadline.IsZero() && (deadline.IsZero() || key.RotationDeadline.Before(deadline)) {
+		deadline = key.RotationDeadline
+	}
+	if !deadline.IsZero() {
+		ttl = time.Until(deadline)
+		if ttl <= 0 {
+			return rs.Remove(keyHash)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisKeyStoreTimeout)
+	defer cancel()
+	if err := rs.client.Set(ctx, rs.prefix+keyHash, data, ttl).Err(); err != nil {
+		logging.Warn("API key store Redis SET error",
+			zap.String("key_hash", keyHash),
+			zap.Error(err),
+		)
+		return err
+	}
+	return nil
+}
+
+// Remove deletes a key from Redis.
+func (rs *RedisKeyStore) Remove(keyHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisKeyStoreTimeout)
+	defer cancel()
+	return rs.client.Del(ctx, rs.prefix+keyHash).Err()
+}
+
+// List returns all keys.
+func (rs *RedisKeyStore) List() map[string]*ManagedKey {
+	ctx, cancel := context.WithTimeout(context.Background(), redisKeyStoreTimeout)
+	defer cancel()
+
+	result := make(map[string]*ManagedKey)
+	iter := rs.client.Scan(ctx, 0, rs.prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		data, err := rs.client.Get(ctx, iter.Val()).Bytes()
+		if err != nil {
+			continue
+		}
+		var mk ManagedKey
+		if err := json.Unmarshal(data, &mk); err != nil {
+			continue
+		}
+		result[iter.Val()[len(rs.prefix):]] = &mk
+	}
+	if err := iter.Err(); err != nil {
+		logging.Warn("API key store Redis SCAN error", zap.Error(err))
+	}
+	return result
+}
+
+// Size returns the number of stored keys.
+func (rs *RedisKeyStore) Size() int {
+	return len(rs.List())
+}
+
+// Close is a no-op (shared client).
+func (rs *RedisKeyStore) Close() {}
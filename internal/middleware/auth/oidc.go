@@ -0,0 +1,956 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/variables"
+)
+
+// OIDC defaults.
+const (
+	DefaultOIDCCallbackPath = "/oauth2/callback"
+	DefaultOIDCLogoutPath   = "/oauth2/logout"
+	DefaultOIDCCookieName   = "runway_oidc"
+	DefaultOIDCMaxAge       = 8 * time.Hour
+)
+
+const (
+	// oidcLoginTTL bounds how long a login may take at the IdP.
+	oidcLoginTTL = 10 * time.Minute
+	// oidcDefaultTokenTTL is used when the IdP reports no token lifetime.
+	oidcDefaultTokenTTL = 5 * time.Minute
+	// oidcMaxCookieSize is the largest cookie browsers reliably keep.
+	oidcMaxCookieSize = 4000
+	// oidcHTTPTimeout bounds each request to the IdP.
+	oidcHTTPTimeout = 10 * time.Second
+)
+
+// oidcDroppedClaims are ID token claims only meaningful to the login itself.
+var oidcDroppedClaims = []string{"nonce", "at_hash", "c_hash"}
+
+// OIDCStats holds OIDC login statistics.
+type OIDCStats struct {
+	LoginRedirects  uint64 `json:"login_redirects"`
+	LoginSuccesses  uint64 `json:"login_successes"`
+	LoginFailures   uint64 `json:"login_failures"`
+	StateMismatches uint64 `json:"state_mismatches"`
+	SessionAuths    uint64 `json:"session_auths"`
+	SessionsExpired uint64 `json:"sessions_expired"`
+	Refreshes       uint64 `json:"refreshes"`
+	RefreshFailures uint64 `json:"refresh_failures"`
+	Logouts         uint64 `json:"logouts"`
+}
+
+// OIDCStatus is the admin view of a route's OIDC relying party.
+type OIDCStatus struct {
+	Issuer       string    `json:"issuer"`
+	ClientID     string    `json:"client_id"`
+	CallbackPath string    `json:"callback_path"`
+	LogoutPath   string    `json:"logout_path"`
+	SessionStore string    `json:"session_store"`
+	Discovered   bool      `json:"discovered"`
+	Stats        OIDCStats `json:"stats"`
+}
+
+// OIDCSessionStore keeps sessions server side. The session cookie then only
+// carries a random session ID, and logout revokes the session everywhere.
+type OIDCSessionStore interface {
+	// Get returns the sealed session, or nil when the ID is unknown.
+	Get(ctx context.Context, id string) ([]byte, error)
+	Set(ctx context.Context, id string, data []byte, ttl time.Duration) error
+	Delete(ctx context.Context, id string) error
+}
+
+// oidcSession is the login session kept in the cookie or session store.
+type oidcSession struct {
+	Subject      string                 `json:"sub"`
+	Claims       map[string]interface{} `json:"claims"`
+	Expires      int64                  `json:"exp"`     // absolute session expiry
+	TokenExpires int64                  `json:"tok_exp"` // when the tokens must be renewed
+	RefreshToken string                 `json:"rt,omitempty"`
+}
+
+// oidcLogin is the state of a login in progress, kept in a short-lived
+// cookie until the IdP redirects back to the callback.
+type oidcLogin struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	ReturnTo string `json:"return_to"`
+	Expires  int64  `json:"exp"`
+}
+
+type oidcProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+type oidcTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// OIDCAuth is an OpenID Connect relying party for one route. Browsers
+// without a session are redirected to the IdP (authorization code flow with
+// PKCE); the callback exchanges the code and stores the verified ID token
+// claims in an encrypted session cookie, or in a session store referenced
+// by the cookie. Sessions resolve into the same Identity the JWT auth
+// produces.
+type OIDCAuth struct {
+	issuer        string
+	clientID      string
+	clientSecret  string
+	scopes        []string
+	callbackPath  string
+	logoutPath    string
+	postLogout    string
+	clientIDClaim string
+	authParams    map[string]string
+	baseURL       string // external origin; empty derives it from the request
+
+	// trustedPeer reports whether the request came from a trusted proxy,
+	// whose X-Forwarded-Proto can be believed. nil trusts no proxy.
+	trustedPeer func(*http.Request) bool
+	// listeners and domains scope the callback and logout endpoints to
+	// the route's listeners and hosts. Empty matches any.
+	listeners map[string]bool
+	domains   []string
+
+	aead           cipher.AEAD
+	store          OIDCSessionStore // nil keeps the session in the cookie
+	cookieName     string
+	maxAge         time.Duration
+	refresh        bool
+	cookieDomain   string
+	cookieSecure   bool
+	cookieSameSite http.SameSite
+
+	client *http.Client
+	now    func() time.Time // indirection for tests
+
+	metaMu        sync.Mutex
+	meta          *oidcProviderMetadata
+	jwks          *JWKSProvider
+	discoverGroup singleflight.Group
+
+	refreshGroup singleflight.Group
+
+	loginRedirects  atomic.Uint64
+	loginSuccesses  atomic.Uint64
+	loginFailures   atomic.Uint64
+	stateMismatches atomic.Uint64
+	sessionAuths    atomic.Uint64
+	sessionsExpired atomic.Uint64
+	refreshes       atomic.Uint64
+	refreshFailures atomic.Uint64
+	logouts         atomic.Uint64
+}
+
+// NewOIDCAuth creates an OIDC relying party. The IdP is discovered on first
+// use, so an unreachable IdP does not fail config loading. store may be nil
+// to keep sessions in the cookie.
+func NewOIDCAuth(cfg config.OIDCConfig, store OIDCSessionStore) (*OIDCAuth, error) {
+	if len(cfg.Session.Secret) < 32 {
+		return nil, fmt.Errorf("oidc: session.secret must be at least 32 bytes")
+	}
+	key := sha256.Sum256([]byte(cfg.Session.Secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+
+	a := &OIDCAuth{
+		issuer:        strings.TrimSuffix(cfg.Issuer, "/"),
+		clientID:      cfg.ClientID,
+		clientSecret:  cfg.ClientSecret,
+		scopes:        cfg.Scopes,
+		callbackPath:  cfg.CallbackPath,
+		logoutPath:    cfg.LogoutPath,
+		postLogout:    cfg.PostLogoutRedirect,
+		clientIDClaim: cfg.ClientIDClaim,
+		authParams:    cfg.AuthorizationParams,
+		baseURL:       strings.TrimSuffix(cfg.BaseURL, "/"),
+		aead:          aead,
+		store:         store,
+		cookieName:    cfg.Session.CookieName,
+		maxAge:        cfg.Session.MaxAge,
+		refresh:       cfg.Session.Refresh == nil || *cfg.Session.Refresh,
+		cookieDomain:  cfg.Session.Domain,
+		cookieSecure:  cfg.Session.Secure == nil || *cfg.Session.Secure,
+		client:        &http.Client{Timeout: oidcHTTPTimeout},
+		now:           time.Now,
+	}
+	if len(a.scopes) == 0 {
+		a.scopes = []string{"openid", "profile", "email"}
+	}
+	if a.callbackPath == "" {
+		a.callbackPath = DefaultOIDCCallbackPath
+	}
+	if a.logoutPath == "" {
+		a.logoutPath = DefaultOIDCLogoutPath
+	}
+	if a.postLogout == "" {
+		a.postLogout = "/"
+	}
+	if a.clientIDClaim == "" {
+		a.clientIDClaim = "sub"
+	}
+	if a.cookieName == "" {
+		a.cookieName = DefaultOIDCCookieName
+	}
+	if a.maxAge <= 0 {
+		a.maxAge = DefaultOIDCMaxAge
+	}
+	switch strings.ToLower(cfg.Session.SameSite) {
+	case "strict":
+		a.cookieSameSite = http.SameSiteStrictMode
+	case "none":
+		a.cookieSameSite = http.SameSiteNoneMode
+	default:
+		a.cookieSameSite = http.SameSiteLaxMode
+	}
+	return a, nil
+}
+
+// Authenticate resolves the session cookie into an identity. Sessions whose
+// tokens have expired are renewed with the refresh token, re-issuing the
+// cookie on w; without one the session ends with the tokens.
+func (a *OIDCAuth) Authenticate(w http.ResponseWriter, r *http.Request) (*variables.Identity, error) {
+	cookie, err := r.Cookie(a.cookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, errors.ErrUnauthorized.WithDetails("OIDC session not provided")
+	}
+	sess, err := a.loadSession(r.Context(), cookie.Value)
+	if err != nil {
+		return nil, errors.ErrUnauthorized.WithDetails("invalid OIDC session")
+	}
+
+	now := a.now().Unix()
+	if now >= sess.Expires {
+		a.sessionsExpired.Add(1)
+		return nil, errors.ErrUnauthorized.WithDetails("OIDC session expired")
+	}
+	if now >= sess.TokenExpires {
+		if !a.refresh || sess.RefreshToken == "" {
+			a.sessionsExpired.Add(1)
+			return nil, errors.ErrUnauthorized.WithDetails("OIDC session expired")
+		}
+		refreshed, err := a.refreshSession(r.Context(), sess)
+		if err != nil {
+			a.refreshFailures.Add(1)
+			logging.Warn("OIDC session refresh failed",
+				zap.String("issuer", a.issuer),
+				zap.String("subject", sess.Subject),
+				zap.Error(err),
+			)
+			return nil, errors.ErrUnauthorized.WithDetails("OIDC session expired")
+		}
+		if err := a.saveSession(r.Context(), w, a.sessionID(cookie.Value), refreshed); err != nil {
+			logging.Warn("OIDC session save failed", zap.String("issuer", a.issuer), zap.Error(err))
+		}
+		sess = refreshed
+	}
+
+	a.sessionAuths.Add(1)
+	return &variables.Identity{
+		ClientID: sess.Subject,
+		AuthType: "oidc",
+		Claims:   sess.Claims,
+	}, nil
+}
+
+// BrowserNavigation reports whether r is a top-level browser navigation,
+// which is redirected to log in rather than answered with a 401.
+func BrowserNavigation(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" && mode != "navigate" {
+		return false
+	}
+	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// StartLogin redirects the browser to the IdP's authorization endpoint,
+// remembering the requested URL to return to after the callback.
+func (a *OIDCAuth) StartLogin(w http.ResponseWriter, r *http.Request) {
+	a.loginRedirects.Add(1)
+	meta, err := a.discover(r.Context())
+	if err != nil {
+		a.loginFailures.Add(1)
+		logging.Warn("OIDC discovery failed", zap.String("issuer", a.issuer), zap.Error(err))
+		errors.ErrBadGateway.WithDetails("identity provider unavailable").WriteJSON(w)
+		return
+	}
+
+	returnTo := r.URL.RequestURI()
+	if !isRelativePath(returnTo) {
+		returnTo = "/"
+	}
+	login := oidcLogin{
+		State:    randomToken(),
+		Nonce:    randomToken(),
+		Verifier: randomToken(),
+		ReturnTo: returnTo,
+		Expires:  a.now().Add(oidcLoginTTL).Unix(),
+	}
+	sealed, err := a.seal(a.loginCookieName(login.State), login)
+	if err != nil {
+		a.loginFailures.Add(1)
+		errors.ErrInternalServer.WithDetails("failed to start login").WriteJSON(w)
+		return
+	}
+	// The login cookie must come back on the IdP's cross-site redirect, so
+	// it is never SameSite=Strict.
+	sameSite := http.SameSiteLaxMode
+	if a.cookieSameSite == http.SameSiteNoneMode {
+		sameSite = http.SameSiteNoneMode
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.loginCookieName(login.State),
+		Value:    sealed,
+		Path:     a.callbackPath,
+		Domain:   a.cookieDomain,
+		MaxAge:   int(oidcLoginTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   a.cookieSecure,
+		SameSite: sameSite,
+	})
+
+	challenge := sha256.Sum256([]byte(login.Verifier))
+	params := url.Values{}
+	for k, v := range a.authParams {
+		params.Set(k, v)
+	}
+	params.Set("response_type", "code")
+	params.Set("client_id", a.clientID)
+	params.Set("redirect_uri", a.absoluteURL(r, a.callbackPath))
+	params.Set("scope", strings.Join(a.scopes, " "))
+	params.Set("state", login.State)
+	params.Set("nonce", login.Nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+	http.Redirect(w, r, withQuery(meta.AuthorizationEndpoint, params), http.StatusFound)
+}
+
+// ServeHTTP dispatches the callback and logout endpoints.
+func (a *OIDCAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == a.callbackPath && r.Method == http.MethodGet:
+		a.HandleCallback(w, r)
+	case r.URL.Path == a.logoutPath && (r.Method == http.MethodGet || r.Method == http.MethodPost):
+		a.HandleLogout(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// HandleCallback validates the login state, exchanges the authorization
+// code and starts the session.
+func (a *OIDCAuth) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	state := q.Get("state")
+
+	// CSRF protection: the state must match the login cookie this browser
+	// received when the login started.
+	var login oidcLogin
+	cookieName := a.loginCookieName(state)
+	cookie, err := r.Cookie(cookieName)
+	if state == "" || err != nil || a.open(cookieName, cookie.Value, &login) != nil ||
+		!hmac.Equal([]byte(login.State), []byte(state)) || a.now().Unix() >= login.Expires {
+		a.stateMismatches.Add(1)
+		a.loginFailures.Add(1)
+		http.Error(w, "invalid or expired login state", http.StatusForbidden)
+		return
+	}
+	a.clearCookie(w, cookieName, a.callbackPath)
+
+	if e := q.Get("error"); e != "" {
+		a.loginFailures.Add(1)
+		logging.Warn("OIDC login rejected by identity provider",
+			zap.String("issuer", a.issuer),
+			zap.String("error", e),
+			zap.String("description", q.Get("error_description")),
+		)
+		http.Error(w, "OIDC authentication failed", http.StatusForbidden)
+		return
+	}
+
+	tokens, err := a.tokenRequest(r.Context(), url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {q.Get("code")},
+		"redirect_uri":  {a.absoluteURL(r, a.callbackPath)},
+		"code_verifier": {login.Verifier},
+	})
+	if err == nil && tokens.IDToken == "" {
+		err = fmt.Errorf("token response has no id_token")
+	}
+	var claims jwt.MapClaims
+	if err == nil {
+		claims, err = a.verifyIDToken(r.Context(), tokens.IDToken, login.Nonce)
+	}
+	if err != nil {
+		a.loginFailures.Add(1)
+		logging.Warn("OIDC callback failed", zap.String("issuer", a.issuer), zap.Error(err))
+		http.Error(w, "OIDC authentication failed", http.StatusForbidden)
+		return
+	}
+
+	now := a.now()
+	sess := a.newSession(claims, tokens, now)
+	sess.Expires = now.Add(a.maxAge).Unix()
+	if err := a.saveSession(r.Context(), w, "", sess); err != nil {
+		a.loginFailures.Add(1)
+		logging.Warn("OIDC session save failed", zap.String("issuer", a.issuer), zap.Error(err))
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	a.loginSuccesses.Add(1)
+	http.Redirect(w, r, login.ReturnTo, http.StatusFound)
+}
+
+// HandleLogout ends the session and, when the IdP supports RP-initiated
+// logout, ends the IdP session as well.
+func (a *OIDCAuth) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	a.logouts.Add(1)
+	if cookie, err := r.Cookie(a.cookieName); err == nil && a.store != nil {
+		if err := a.store.Delete(r.Context(), cookie.Value); err != nil {
+			logging.Warn("OIDC session delete failed", zap.String("issuer", a.issuer), zap.Error(err))
+		}
+	}
+	a.clearCookie(w, a.cookieName, "/")
+
+	redirectTo := a.postLogout
+	if meta, err := a.discover(r.Context()); err == nil && meta.EndSessionEndpoint != "" {
+		redirectTo = withQuery(meta.EndSessionEndpoint, url.Values{
+			"client_id":                {a.clientID},
+			"post_logout_redirect_uri": {a.absoluteURL(r, a.postLogout)},
+		})
+	}
+	http.Redirect(w, r, redirectTo, http.StatusFound)
+}
+
+// MatchesPath reports whether path is the callback or logout endpoint.
+func (a *OIDCAuth) MatchesPath(path string) bool {
+	return path == a.callbackPath || path == a.logoutPath
+}
+
+// Matches reports whether r is for the callback or logout endpoint on one
+// of the route's listeners and hosts.
+func (a *OIDCAuth) Matches(r *http.Request) bool {
+	if !a.MatchesPath(r.URL.Path) {
+		return false
+	}
+	if len(a.listeners) > 0 {
+		vc, ok := r.Context().Value(variables.RequestContextKey{}).(*variables.Context)
+		if !ok || !a.listeners[vc.ListenerID] {
+			return false
+		}
+	}
+	return len(a.domains) == 0 || matchDomain(r.Host, a.domains)
+}
+
+// matchDomain reports whether host, without its port, is one of domains:
+// exact names or "*.example.com" wildcards, as in route match.domains.
+func matchDomain(host string, domains []string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, d := range domains {
+		if suffix, ok := strings.CutPrefix(d, "*"); ok {
+			if len(host) > len(suffix) && strings.HasSuffix(strings.ToLower(host), strings.ToLower(suffix)) {
+				return true
+			}
+		} else if strings.EqualFold(host, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the admin status of the relying party.
+func (a *OIDCAuth) Status() OIDCStatus {
+	a.metaMu.Lock()
+	discovered := a.meta != nil
+	a.metaMu.Unlock()
+	store := "cookie"
+	if a.store != nil {
+		store = "redis"
+	}
+	return OIDCStatus{
+		Issuer:       a.issuer,
+		ClientID:     a.clientID,
+		CallbackPath: a.callbackPath,
+		LogoutPath:   a.logoutPath,
+		SessionStore: store,
+		Discovered:   discovered,
+		Stats: OIDCStats{
+			LoginRedirects:  a.loginRedirects.Load(),
+			LoginSuccesses:  a.loginSuccesses.Load(),
+			LoginFailures:   a.loginFailures.Load(),
+			StateMismatches: a.stateMismatches.Load(),
+			SessionAuths:    a.sessionAuths.Load(),
+			SessionsExpired: a.sessionsExpired.Load(),
+			Refreshes:       a.refreshes.Load(),
+			RefreshFailures: a.refreshFailures.Load(),
+			Logouts:         a.logouts.Load(),
+		},
+	}
+}
+
+// discover returns the IdP's discovery document, fetching and caching it
+// on first use. Concurrent callers share one fetch, made without holding
+// metaMu. A failed fetch is retried on the next call.
+func (a *OIDCAuth) discover(ctx context.Context) (*oidcProviderMetadata, error) {
+	a.metaMu.Lock()
+	meta := a.meta
+	a.metaMu.Unlock()
+	if meta != nil {
+		return meta, nil
+	}
+
+	v, err, _ := a.discoverGroup.Do("", func() (interface{}, error) {
+		meta, jwks, err := a.fetchMetadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+		a.metaMu.Lock()
+		a.meta, a.jwks = meta, jwks
+		a.metaMu.Unlock()
+		return meta, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*oidcProviderMetadata), nil
+}
+
+// fetchMetadata fetches and checks the discovery document.
+func (a *OIDCAuth) fetchMetadata(ctx context.Context) (*oidcProviderMetadata, *JWKSProvider, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("discovery returned status %d", resp.StatusCode)
+	}
+	var meta oidcProviderMetadata
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&meta); err != nil {
+		return nil, nil, fmt.Errorf("invalid discovery document: %w", err)
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != a.issuer {
+		return nil, nil, fmt.Errorf("discovery issuer %q does not match %q", meta.Issuer, a.issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, nil, fmt.Errorf("discovery document lacks authorization, token or jwks endpoint")
+	}
+	jwks, err := NewJWKSProvider(meta.JWKSURI, time.Hour)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &meta, jwks, nil
+}
+
+// tokenRequest calls the token endpoint with the client's credentials.
+func (a *OIDCAuth) tokenRequest(ctx context.Context, form url.Values) (*oidcTokenResponse, error) {
+	meta, err := a.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form.Set("client_id", a.clientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if a.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tokens oidcTokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("invalid token response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.Error != "" {
+		return nil, fmt.Errorf("token endpoint returned status %d: %s %s", resp.StatusCode, tokens.Error, tokens.ErrorDescription)
+	}
+	return &tokens, nil
+}
+
+// verifyIDToken validates an ID token's signature, issuer, audience and
+// expiry, and its nonce when one is given.
+func (a *OIDCAuth) verifyIDToken(ctx context.Context, raw, nonce string) (jwt.MapClaims, error) {
+	meta, err := a.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.metaMu.Lock()
+	jwks := a.jwks
+	a.metaMu.Unlock()
+	token, err := jwt.Parse(raw, jwks.KeyFunc(),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(a.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(a.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid id_token claims")
+	}
+	if nonce != "" {
+		if got, _ := claims["nonce"].(string); !hmac.Equal([]byte(got), []byte(nonce)) {
+			return nil, fmt.Errorf("id_token nonce mismatch")
+		}
+	}
+	return claims, nil
+}
+
+// newSession builds a session from verified ID token claims. The absolute
+// expiry is left to the caller.
+func (a *OIDCAuth) newSession(claims jwt.MapClaims, tokens *oidcTokenResponse, now time.Time) *oidcSession {
+	sess := &oidcSession{Claims: make(map[string]interface{}, len(claims))}
+	for k, v := range claims {
+		sess.Claims[k] = v
+	}
+	for _, k := range oidcDroppedClaims {
+		delete(sess.Claims, k)
+	}
+	sess.Subject, _ = claims[a.clientIDClaim].(string)
+
+	switch {
+	case tokens.ExpiresIn > 0:
+		sess.TokenExpires = now.Add(time.Duration(tokens.ExpiresIn) * time.Second).Unix()
+	default:
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			sess.TokenExpires = exp.Unix()
+		} else {
+			sess.TokenExpires = now.Add(oidcDefaultTokenTTL).Unix()
+		}
+	}
+	if a.refresh {
+		sess.RefreshToken = tokens.RefreshToken
+	}
+	return sess
+}
+
+// refreshSession renews a session's tokens. Concurrent requests carrying
+// the same refresh token share one call, so a rotating refresh token is
+// only redeemed once.
+func (a *OIDCAuth) refreshSession(ctx context.Context, sess *oidcSession) (*oidcSession, error) {
+	v, err, _ := a.refreshGroup.Do(sess.RefreshToken, func() (interface{}, error) {
+		tokens, err := a.tokenRequest(ctx, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {sess.RefreshToken},
+		})
+		if err != nil {
+			return nil, err
+		}
+		now := a.now()
+		var refreshed *oidcSession
+		if tokens.IDToken != "" {
+			claims, err := a.verifyIDToken(ctx, tokens.IDToken, "")
+			if err != nil {
+				return nil, err
+			}
+			refreshed = a.newSession(claims, tokens, now)
+		} else {
+			// Without a new ID token the identity is unchanged.
+			refreshed = a.newSession(nil, tokens, now)
+			refreshed.Subject, refreshed.Claims = sess.Subject, sess.Claims
+		}
+		if refreshed.RefreshToken == "" {
+			refreshed.RefreshToken = sess.RefreshToken
+		}
+		refreshed.Expires = sess.Expires
+		a.refreshes.Add(1)
+		return refreshed, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*oidcSession), nil
+}
+
+// loadSession opens the session referenced by a session cookie value.
+func (a *OIDCAuth) loadSession(ctx context.Context, value string) (*oidcSession, error) {
+	var sess oidcSession
+	if a.store == nil {
+		if err := a.open(a.cookieName, value, &sess); err != nil {
+			return nil, err
+		}
+		return &sess, nil
+	}
+	data, err := a.store.Get(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("session not found")
+	}
+	if err := a.open(a.cookieName, string(data), &sess); err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+// saveSession writes the session and sets the session cookie. id is the
+// existing session ID for a store-backed session, or empty to create one.
+func (a *OIDCAuth) saveSession(ctx context.Context, w http.ResponseWriter, id string, sess *oidcSession) error {
+	sealed, err := a.seal(a.cookieName, sess)
+	if err != nil {
+		return err
+	}
+	ttl := time.Unix(sess.Expires, 0).Sub(a.now())
+	value := sealed
+	if a.store != nil {
+		if id == "" {
+			id = randomToken()
+		}
+		if err := a.store.Set(ctx, id, []byte(sealed), ttl); err != nil {
+			return err
+		}
+		value = id
+	} else if len(a.cookieName)+len(value) > oidcMaxCookieSize {
+		return fmt.Errorf("session of %d bytes is too large for a cookie; use the redis session store", len(value))
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.cookieName,
+		Value:    value,
+		Path:     "/",
+		Domain:   a.cookieDomain,
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   a.cookieSecure,
+		SameSite: a.cookieSameSite,
+	})
+	return nil
+}
+
+// sessionID returns the store's session ID for a cookie value, or "" for
+// cookie-kept sessions.
+func (a *OIDCAuth) sessionID(value string) string {
+	if a.store == nil {
+		return ""
+	}
+	return value
+}
+
+// seal encrypts v with AES-GCM, binding it to name so a value issued for
+// one cookie does not open as another.
+func (a *OIDCAuth) seal(name string, v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(a.aead.Seal(nonce, nonce, data, []byte(name))), nil
+}
+
+// open decrypts a value sealed for name into v.
+func (a *OIDCAuth) open(name, value string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return err
+	}
+	if len(raw) < a.aead.NonceSize() {
+		return fmt.Errorf("sealed value too short")
+	}
+	nonce, ciphertext := raw[:a.aead.NonceSize()], raw[a.aead.NonceSize():]
+	data, err := a.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// loginCookieName names the login cookie of a state, so logins started in
+// several tabs do not overwrite each other.
+func (a *OIDCAuth) loginCookieName(state string) string {
+	if len(state) > 16 {
+		state = state[:16]
+	}
+	return a.cookieName + "_login_" + state
+}
+
+func (a *OIDCAuth) clearCookie(w http.ResponseWriter, name, path string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     path,
+		Domain:   a.cookieDomain,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.cookieSecure,
+		SameSite: a.cookieSameSite,
+	})
+}
+
+// absoluteURL makes path absolute against the configured base URL or,
+// without one, the host the request was sent to. X-Forwarded-Proto is only
+// believed from a trusted proxy.
+func (a *OIDCAuth) absoluteURL(r *http.Request, path string) string {
+	if a.baseURL != "" {
+		return a.baseURL + path
+	}
+	scheme := "http"
+	if r.TLS != nil || (a.trustedPeer != nil && a.trustedPeer(r) && r.Header.Get("X-Forwarded-Proto") == "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + path
+}
+
+// withQuery appends params to endpoint, keeping any query it already has.
+func withQuery(endpoint string, params url.Values) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// randomToken returns 32 random bytes, base64url encoded.
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// OIDCByRoute manages per-route OIDC relying parties.
+type OIDCByRoute struct {
+	byroute.Manager[*OIDCAuth]
+	redisClient *redis.Client
+	trustedPeer func(*http.Request) bool
+	dial        func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// NewOIDCByRoute creates a new OIDCByRoute manager.
+func NewOIDCByRoute(redisClient *redis.Client) *OIDCByRoute {
+	return &OIDCByRoute{redisClient: redisClient}
+}
+
+// SetTrustedProxies sets the check for requests arriving from a trusted
+// proxy, for relying parties added afterwards.
+func (m *OIDCByRoute) SetTrustedProxies(trustedPeer func(*http.Request) bool) {
+	m.trustedPeer = trustedPeer
+}
+
+// SetDialer makes relying parties added afterwards connect to the IdP
+// through dial.
+func (m *OIDCByRoute) SetDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	m.dial = dial
+}
+
+// AddRoute creates and registers the relying party for a route. Its
+// callback and logout endpoints are served on the route's listeners and
+// match.domains only.
+func (m *OIDCByRoute) AddRoute(routeID string, rc config.RouteConfig) error {
+	cfg := rc.OIDC
+	var store OIDCSessionStore
+	if cfg.Session.Store == "redis" {
+		if m.redisClient == nil {
+			return fmt.Errorf("oidc: session store \"redis\" requires redis")
+		}
+		store = NewRedisOIDCSessionStore(m.redisClient, "runway:oidc:"+routeID+":")
+	}
+	a, err := NewOIDCAuth(cfg, store)
+	if err != nil {
+		return err
+	}
+	a.trustedPeer = m.trustedPeer
+	if m.dial != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DialContext = m.dial
+		a.client.Transport = t
+	}
+	a.domains = rc.Match.Domains
+	if len(rc.Listeners) > 0 {
+		a.listeners = make(map[string]bool, len(rc.Listeners))
+		for _, id := range rc.Listeners {
+			a.listeners[id] = true
+		}
+	}
+	m.Add(routeID, a)
+	return nil
+}
+
+// Match returns the relying party whose callback or logout endpoint r is
+// for, or nil.
+func (m *OIDCByRoute) Match(r *http.Request) *OIDCAuth {
+	var found *OIDCAuth
+	m.Range(func(_ string, a *OIDCAuth) bool {
+		if a.Matches(r) {
+			found = a
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// Stats returns the status of every route's relying party.
+func (m *OIDCByRoute) Stats() map[string]OIDCStatus {
+	return byroute.CollectStats(&m.Manager, func(a *OIDCAuth) OIDCStatus { return a.Status() })
+}
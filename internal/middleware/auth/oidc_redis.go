@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisOIDCSessionStore keeps OIDC sessions in Redis under prefix+ID.
+type RedisOIDCSessionStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisOIDCSessionStore creates a Redis-backed session store.
+func NewRedisOIDCSessionStore(client *redis.Client, prefix string) *RedisOIDCSessionStore {
+	return &RedisOIDCSessionStore{client: client, prefix: prefix}
+}
+
+// Get returns the sealed session, or nil when the ID is unknown.
+func (s *RedisOIDCSessionStore) Get(ctx context.Context, id string) ([]byte, error) {
+	data, err := s.client.Get(ctx, s.prefix+id).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Set stores a sealed session until ttl elapses.
+func (s *RedisOIDCSessionStore) Set(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+id, data, ttl).Err()
+}
+
+// Delete removes a session.
+func (s *RedisOIDCSessionStore) Delete(ctx context.Context, id string) error {
+	return s.client.Del(ctx, s.prefix+id).Err()
+}
@@ -0,0 +1,648 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/variables"
+)
+
+// fakeIdP is a minimal OpenID provider: discovery, JWKS, and a token
+// endpoint for the authorization code (with PKCE) and refresh grants.
+type fakeIdP struct {
+	srv *httptest.Server
+	key *ecdsa.PrivateKey
+	now func() time.Time
+
+	mu            sync.Mutex
+	tokenTTL      time.Duration
+	codes         map[string][2]string // code -> challenge, nonce
+	refreshTokens map[string]bool
+	refreshCalls  int
+	email         string
+}
+
+func newFakeIdP(t *testing.T, now func() time.Time) *fakeIdP {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{
+		key:           key,
+		now:           now,
+		tokenTTL:      time.Hour,
+		codes:         make(map[string][2]string),
+		refreshTokens: make(map[string]bool),
+		email:         "alice@example.com",
+	}
+
+	jwkKey, err := jwk.FromRaw(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwkKey.Set(jwk.KeyIDKey, "k1")
+	jwkKey.Set(jwk.AlgorithmKey, "ES256")
+	set := jwk.NewSet()
+	set.AddKey(jwkKey)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.srv.URL,
+			"authorization_endpoint": idp.srv.URL + "/authorize",
+			"token_endpoint":         idp.srv.URL + "/token",
+			"jwks_uri":               idp.srv.URL + "/jwks",
+			"end_session_endpoint":   idp.srv.URL + "/logout",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(set)
+	})
+	mux.HandleFunc("/token", idp.handleToken)
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+	return idp
+}
+
+// issueCode records an authorization for the given PKCE challenge and nonce.
+func (idp *fakeIdP) issueCode(challenge, nonce string) string {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	code := randomToken()
+	idp.codes[code] = [2]string{challenge, nonce}
+	return code
+}
+
+func (idp *fakeIdP) revokeRefreshTokens() {
+	idp.mu.Lock()
+	idp.refreshTokens = make(map[string]bool)
+	idp.mu.Unlock()
+}
+
+func (idp *fakeIdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	if id, secret, _ := r.BasicAuth(); id != "dashboard" || secret != "s3cret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+	r.ParseForm()
+	nonce := ""
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		grant, ok := idp.codes[r.PostForm.Get("code")]
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant[0] {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		delete(idp.codes, r.PostForm.Get("code"))
+		nonce = grant[1]
+	case "refresh_token":
+		idp.refreshCalls++
+		rt := r.PostForm.Get("refresh_token")
+		if !idp.refreshTokens[rt] {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		// Refresh tokens rotate.
+		delete(idp.refreshTokens, rt)
+	}
+
+	now := idp.now()
+	claims := jwt.MapClaims{
+		"iss":   idp.srv.URL,
+		"sub":   "alice",
+		"aud":   "dashboard",
+		"iat":   now.Unix(),
+		"exp":   now.Add(idp.tokenTTL).Unix(),
+		"email": idp.email,
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = "k1"
+	idToken, _ := token.SignedString(idp.key)
+	refresh := randomToken()
+	idp.refreshTokens[refresh] = true
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":  "at-" + randomToken(),
+		"id_token":      idToken,
+		"refresh_token": refresh,
+		"expires_in":    int64(idp.tokenTTL.Seconds()),
+	})
+}
+
+type memorySessionStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (s *memorySessionStore) Get(_ context.Context, id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[id], nil
+}
+
+func (s *memorySessionStore) Set(_ context.Context, id string, data []byte, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[id] = data
+	return nil
+}
+
+func (s *memorySessionStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, id)
+	return nil
+}
+
+func newTestOIDC(t *testing.T, mutate func(*config.OIDCConfig), store OIDCSessionStore) (*OIDCAuth, *fakeIdP, *time.Time) {
+	t.Helper()
+	now := time.Now().Truncate(time.Second)
+	clock := func() time.Time { return now }
+	idp := newFakeIdP(t, clock)
+	cfg := config.OIDCConfig{
+		Enabled:      true,
+		Issuer:       idp.srv.URL,
+		ClientID:     "dashboard",
+		ClientSecret: "s3cret",
+		Session: config.OIDCSessionConfig{
+			Secret: "0123456789abcdef0123456789abcdef",
+		},
+	}
+	if mutate != nil {
+		mutate(&cfg)
+	}
+	a, err := NewOIDCAuth(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.now = clock
+	return a, idp, &now
+}
+
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, c := range cookies {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// oidcLoginFlow runs a browser through the login and returns the session cookie.
+func oidcLoginFlow(t *testing.T, a *OIDCAuth, idp *fakeIdP, target string) *http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "http://example.com"+target, nil)
+	req.Header.Set("Accept", "text/html")
+	rec := httptest.NewRecorder()
+	a.StartLogin(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected login redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	q := loc.Query()
+	code := idp.issueCode(q.Get("code_challenge"), q.Get("nonce"))
+
+	cb := httptest.NewRequest(http.MethodGet, "http://example.com/oauth2/callback?code="+code+"&state="+q.Get("state"), nil)
+	for _, c := range rec.Result().Cookies() {
+		cb.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, cb)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected callback redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Location"); got != target {
+		t.Fatalf("expected redirect back to %s, got %s", target, got)
+	}
+	session := findCookie(rec.Result().Cookies(), a.cookieName)
+	if session == nil {
+		t.Fatal("expected session cookie")
+	}
+	return session
+}
+
+func authenticateWith(a *OIDCAuth, cookie *http.Cookie) (*httptest.ResponseRecorder, error) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/dash", nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	_, err := a.Authenticate(rec, req)
+	return rec, err
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	a, idp, _ := newTestOIDC(t, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/dash?tab=1", nil)
+	req.Header.Set("Accept", "text/html")
+	rec := httptest.NewRecorder()
+	a.StartLogin(rec, req)
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	q := loc.Query()
+	if loc.Path != "/authorize" || q.Get("response_type") != "code" || q.Get("client_id") != "dashboard" {
+		t.Fatalf("unexpected authorization request %s", loc)
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("expected a PKCE S256 challenge, got %s", loc.RawQuery)
+	}
+	if q.Get("redirect_uri") != "http://example.com/oauth2/callback" || q.Get("scope") != "openid profile email" {
+		t.Fatalf("unexpected redirect_uri or scope: %s", loc.RawQuery)
+	}
+	loginCookie := findCookie(rec.Result().Cookies(), a.loginCookieName(q.Get("state")))
+	if loginCookie == nil || loginCookie.Path != "/oauth2/callback" || !loginCookie.HttpOnly {
+		t.Fatalf("expected an HttpOnly login cookie scoped to the callback, got %+v", loginCookie)
+	}
+
+	session := oidcLoginFlow(t, a, idp, "/dash?tab=1")
+	if !session.HttpOnly || !session.Secure || session.SameSite != http.SameSiteLaxMode || session.Path != "/" {
+		t.Fatalf("unexpected session cookie attributes %+v", session)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/dash", nil)
+	req.AddCookie(session)
+	identity, err := a.Authenticate(httptest.NewRecorder(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.ClientID != "alice" || identity.AuthType != "oidc" || identity.Claims["email"] != "alice@example.com" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+	if _, ok := identity.Claims["nonce"]; ok {
+		t.Error("expected the nonce claim dropped from the identity")
+	}
+	if s := a.Status().Stats; s.LoginRedirects != 2 || s.LoginSuccesses != 1 || s.SessionAuths != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestOIDCCallbackValidatesState(t *testing.T) {
+	a, idp, _ := newTestOIDC(t, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/dash", nil)
+	rec := httptest.NewRecorder()
+	a.StartLogin(rec, req)
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	q := loc.Query()
+	code := idp.issueCode(q.Get("code_challenge"), q.Get("nonce"))
+	loginCookies := rec.Result().Cookies()
+
+	tests := []struct {
+		name    string
+		state   string
+		cookies []*http.Cookie
+	}{
+		{"no login cookie", q.Get("state"), nil},
+		{"forged state", randomToken(), loginCookies},
+		{"missing state", "", loginCookies},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := httptest.NewRequest(http.MethodGet, "http://example.com/oauth2/callback?code="+code+"&state="+tt.state, nil)
+			for _, c := range tt.cookies {
+				cb.AddCookie(c)
+			}
+			rec := httptest.NewRecorder()
+			a.ServeHTTP(rec, cb)
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("expected 403, got %d", rec.Code)
+			}
+			if findCookie(rec.Result().Cookies(), a.cookieName) != nil {
+				t.Fatal("expected no session on a state mismatch")
+			}
+		})
+	}
+	if got := a.Status().Stats.StateMismatches; got != 3 {
+		t.Errorf("expected 3 state mismatches, got %d", got)
+	}
+}
+
+func TestOIDCCallbackRejectsFailedExchange(t *testing.T) {
+	a, _, _ := newTestOIDC(t, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/dash", nil)
+	rec := httptest.NewRecorder()
+	a.StartLogin(rec, req)
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+
+	// A code the IdP never issued.
+	cb := httptest.NewRequest(http.MethodGet, "http://example.com/oauth2/callback?code=bogus&state="+loc.Query().Get("state"), nil)
+	for _, c := range rec.Result().Cookies() {
+		cb.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, cb)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+	if got := a.Status().Stats.LoginFailures; got != 1 {
+		t.Errorf("expected 1 login failure, got %d", got)
+	}
+}
+
+func TestOIDCSessionExpiry(t *testing.T) {
+	a, idp, now := newTestOIDC(t, func(c *config.OIDCConfig) { c.Session.MaxAge = time.Hour }, nil)
+	idp.tokenTTL = 2 * time.Hour
+	session := oidcLoginFlow(t, a, idp, "/dash")
+
+	if _, err := authenticateWith(a, session); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(time.Hour + time.Second)
+	_, err := authenticateWith(a, session)
+	if re, ok := err.(*errors.RunwayError); !ok || !strings.Contains(re.Details, "expired") {
+		t.Fatalf("expected the session to expire with max_age, got %v", err)
+	}
+	if got := a.Status().Stats.SessionsExpired; got != 1 {
+		t.Errorf("expected 1 expired session, got %d", got)
+	}
+}
+
+func TestOIDCRefresh(t *testing.T) {
+	a, idp, now := newTestOIDC(t, nil, nil)
+	idp.tokenTTL = time.Minute
+	session := oidcLoginFlow(t, a, idp, "/dash")
+
+	// Tokens expired: the session is renewed and the cookie re-issued.
+	*now = now.Add(2 * time.Minute)
+	idp.email = "alice@new.example.com"
+	rec, err := authenticateWith(a, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewed := findCookie(rec.Result().Cookies(), a.cookieName)
+	if renewed == nil || renewed.Value == session.Value {
+		t.Fatal("expected a renewed session cookie")
+	}
+	if idp.refreshCalls != 1 {
+		t.Fatalf("expected 1 refresh, got %d", idp.refreshCalls)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/dash", nil)
+	req.AddCookie(renewed)
+	identity, err := a.Authenticate(httptest.NewRecorder(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Claims["email"] != "alice@new.example.com" {
+		t.Errorf("expected claims from the refreshed ID token, got %v", identity.Claims["email"])
+	}
+	if idp.refreshCalls != 1 {
+		t.Errorf("expected the renewed session used without refreshing, got %d refreshes", idp.refreshCalls)
+	}
+
+	// Once the IdP revokes the refresh token, the session ends.
+	*now = now.Add(2 * time.Minute)
+	idp.revokeRefreshTokens()
+	if _, err := authenticateWith(a, renewed); err == nil {
+		t.Fatal("expected a failed refresh to end the session")
+	}
+	if s := a.Status().Stats; s.Refreshes != 1 || s.RefreshFailures != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestOIDCRefreshDisabled(t *testing.T) {
+	off := false
+	a, idp, now := newTestOIDC(t, func(c *config.OIDCConfig) { c.Session.Refresh = &off }, nil)
+	idp.tokenTTL = time.Minute
+	session := oidcLoginFlow(t, a, idp, "/dash")
+
+	*now = now.Add(2 * time.Minute)
+	if _, err := authenticateWith(a, session); err == nil {
+		t.Fatal("expected the session to end with the tokens")
+	}
+	if idp.refreshCalls != 0 {
+		t.Errorf("expected no refresh, got %d", idp.refreshCalls)
+	}
+}
+
+func TestOIDCSessionStoreAndLogout(t *testing.T) {
+	store := &memorySessionStore{data: make(map[string][]byte)}
+	a, idp, _ := newTestOIDC(t, func(c *config.OIDCConfig) { c.PostLogoutRedirect = "/bye" }, store)
+	session := oidcLoginFlow(t, a, idp, "/dash")
+
+	if len(store.data) != 1 || store.data[session.Value] == nil {
+		t.Fatalf("expected the cookie to reference the stored session, got %q", session.Value)
+	}
+	if _, err := authenticateWith(a, session); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/oauth2/logout", nil)
+	req.AddCookie(session)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected logout redirect, got %d", rec.Code)
+	}
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	if loc.Path != "/logout" || loc.Query().Get("post_logout_redirect_uri") != "http://example.com/bye" {
+		t.Fatalf("expected RP-initiated logout at the IdP, got %s", loc)
+	}
+	if c := findCookie(rec.Result().Cookies(), a.cookieName); c == nil || c.MaxAge >= 0 {
+		t.Fatalf("expected the session cookie cleared, got %+v", c)
+	}
+	if len(store.data) != 0 {
+		t.Fatal("expected the stored session deleted")
+	}
+	// A copy of the cookie no longer works.
+	if _, err := authenticateWith(a, session); err == nil {
+		t.Fatal("expected the logged out session rejected")
+	}
+}
+
+func TestOIDCRejectsTamperedSession(t *testing.T) {
+	a, idp, _ := newTestOIDC(t, nil, nil)
+	session := oidcLoginFlow(t, a, idp, "/dash")
+
+	raw, _ := base64.RawURLEncoding.DecodeString(session.Value)
+	raw[len(raw)-1] ^= 1
+	tampered := *session
+	tampered.Value = base64.RawURLEncoding.EncodeToString(raw)
+	if _, err := authenticateWith(a, &tampered); err == nil {
+		t.Fatal("expected a tampered session rejected")
+	}
+
+	// A value sealed for the login cookie does not open as a session.
+	sealed, _ := a.seal(a.loginCookieName("x"), oidcSession{Subject: "mallory", Expires: time.Now().Add(time.Hour).Unix(), TokenExpires: time.Now().Add(time.Hour).Unix()})
+	if _, err := authenticateWith(a, &http.Cookie{Name: a.cookieName, Value: sealed}); err == nil {
+		t.Fatal("expected a value sealed for another cookie rejected")
+	}
+}
+
+func TestBrowserNavigation(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    bool
+	}{
+		{"page load", http.MethodGet, map[string]string{"Accept": "text/html,application/xhtml+xml"}, true},
+		{"navigate", http.MethodGet, map[string]string{"Accept": "text/html", "Sec-Fetch-Mode": "navigate"}, true},
+		{"fetch", http.MethodGet, map[string]string{"Accept": "text/html", "Sec-Fetch-Mode": "cors"}, false},
+		{"xhr", http.MethodGet, map[string]string{"Accept": "text/html", "X-Requested-With": "XMLHttpRequest"}, false},
+		{"api client", http.MethodGet, map[string]string{"Accept": "application/json"}, false},
+		{"form post", http.MethodPost, map[string]string{"Accept": "text/html"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := BrowserNavigation(req); got != tt.want {
+				t.Errorf("BrowserNavigation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCRedirectURIOrigin(t *testing.T) {
+	redirectURI := func(a *OIDCAuth, remote, proto string) string {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/dash", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-Proto", proto)
+		rec := httptest.NewRecorder()
+		a.StartLogin(rec, req)
+		loc, _ := url.Parse(rec.Header().Get("Location"))
+		return loc.Query().Get("redirect_uri")
+	}
+
+	a, _, _ := newTestOIDC(t, nil, nil)
+	a.trustedPeer = func(r *http.Request) bool { return strings.HasPrefix(r.RemoteAddr, "10.0.0.1:") }
+	if got := redirectURI(a, "203.0.113.7:4000", "https"); got != "http://example.com/oauth2/callback" {
+		t.Errorf("X-Forwarded-Proto from an untrusted peer must be ignored, got %s", got)
+	}
+	if got := redirectURI(a, "10.0.0.1:4000", "https"); got != "https://example.com/oauth2/callback" {
+		t.Errorf("expected https from a trusted proxy, got %s", got)
+	}
+
+	a, _, _ = newTestOIDC(t, func(c *config.OIDCConfig) { c.BaseURL = "https://app.example.com/" }, nil)
+	if got := redirectURI(a, "203.0.113.7:4000", ""); got != "https://app.example.com/oauth2/callback" {
+		t.Errorf("expected the configured base URL, got %s", got)
+	}
+}
+
+func TestOIDCByRouteMatchScope(t *testing.T) {
+	m := NewOIDCByRoute(nil)
+	rc := config.RouteConfig{
+		Listeners: []string{"public"},
+		Match:     config.MatchConfig{Domains: []string{"app.example.com", "*.apps.example.com"}},
+		OIDC: config.OIDCConfig{
+			Enabled:  true,
+			Issuer:   "https://idp.example.com",
+			ClientID: "dashboard",
+			Session:  config.OIDCSessionConfig{Secret: "0123456789abcdef0123456789abcdef"},
+		},
+	}
+	if err := m.AddRoute("dash", rc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url, listener string
+		want          bool
+	}{
+		{"http://app.example.com/oauth2/callback", "public", true},
+		{"http://app.example.com:8443/oauth2/logout", "public", true},
+		{"http://eu.apps.example.com/oauth2/callback", "public", true},
+		{"http://other.example.com/oauth2/callback", "public", false},
+		{"http://app.example.com/oauth2/callback", "internal", false},
+		{"http://app.example.com/dash", "public", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		vc := variables.NewContext(req)
+		vc.ListenerID = tt.listener
+		req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, vc))
+		if got := m.Match(req) != nil; got != tt.want {
+			t.Errorf("%s on %s: matched = %v, want %v", tt.url, tt.listener, got, tt.want)
+		}
+	}
+}
+
+func TestOIDCDiscoverSharesOneFetch(t *testing.T) {
+	idp := newFakeIdP(t, time.Now)
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	var slow *httptest.Server
+	slow = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 slow.URL,
+			"authorization_endpoint": idp.srv.URL + "/authorize",
+			"token_endpoint":         idp.srv.URL + "/token",
+			"jwks_uri":               idp.srv.URL + "/jwks",
+		})
+	}))
+	defer slow.Close()
+
+	a, err := NewOIDCAuth(config.OIDCConfig{
+		Issuer:   slow.URL,
+		ClientID: "dashboard",
+		Session:  config.OIDCSessionConfig{Secret: "0123456789abcdef0123456789abcdef"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.discover(context.Background())
+		}()
+	}
+	for fetches.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The fetch in flight must not block readers of the cached metadata.
+	done := make(chan struct{})
+	go func() {
+		a.Status()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Status blocked behind the discovery fetch")
+	}
+
+	close(release)
+	wg.Wait()
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("expected one discovery fetch, got %d", n)
+	}
+	if !a.Status().Discovered {
+		t.Error("expected the metadata to be cached")
+	}
+}
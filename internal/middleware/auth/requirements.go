@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
+)
+
+// Requirements evaluates a route's declarative authorization against the
+// identity resolved by authentication.
+type Requirements struct {
+	base    requirement
+	methods map[string]requirement
+	verbose bool
+}
+
+type requirement struct {
+	scopesAny []string
+	scopesAll []string
+	rolesAny  []string
+	claims    []claimRequirement // sorted by name
+}
+
+type claimRequirement struct {
+	name     string
+	accepted []string // as strings
+}
+
+// Unmet describes the requirement an identity failed.
+type Unmet struct {
+	Requirement string   `json:"requirement"` // scopes_any, scopes_all, roles_any, or claims.<name>
+	Missing     []string `json:"missing"`     // scopes, roles or claim values that would have satisfied it
+}
+
+func (u *Unmet) Error() string {
+	return fmt.Sprintf("requirement %s not met: need %s", u.Requirement, strings.Join(u.Missing, ", "))
+}
+
+// NewRequirements compiles cfg, or returns nil when it has no conditions.
+func NewRequirements(cfg config.AuthRequirementsConfig) *Requirements {
+	if !cfg.IsActive() {
+		return nil
+	}
+	rq := &Requirements{
+		base:    compileRequirement(cfg.AuthRequirement),
+		verbose: cfg.VerboseErrors == nil || *cfg.VerboseErrors,
+	}
+	if len(cfg.Methods) > 0 {
+		rq.methods = make(map[string]requirement, len(cfg.Methods))
+		for m, r := range cfg.Methods {
+			rq.methods[strings.ToUpper(m)] = compileRequirement(r)
+		}
+	}
+	return rq
+}
+
+func compileRequirement(r config.AuthRequirement) requirement {
+	req := requirement{scopesAny: r.ScopesAny, scopesAll: r.ScopesAll, rolesAny: r.RolesAny}
+	for name, want := range r.Claims {
+		values, isList := want.([]interface{})
+		if !isList {
+			values = []interface{}{want}
+		}
+		cr := claimRequirement{name: name}
+		for _, v := range values {
+			cr.accepted = append(cr.accepted, fmt.Sprint(v))
+		}
+		req.claims = append(req.claims, cr)
+	}
+	sort.Slice(req.claims, func(i, j int) bool { return req.claims[i].name < req.claims[j].name })
+	return req
+}
+
+// Verbose reports whether 403 responses name the unmet requirement.
+func (rq *Requirements) Verbose() bool {
+	return rq.verbose
+}
+
+// Check returns the first requirement the identity fails for a request
+// with the given method, or nil if all are met. A method-specific entry
+// replaces the route-wide requirements.
+func (rq *Requirements) Check(method string, id *variables.Identity) *Unmet {
+	req, ok := rq.methods[method]
+	if !ok {
+		req = rq.base
+	}
+	var claims map[string]interface{}
+	if id != nil {
+		claims = id.Claims
+	}
+
+	if len(req.scopesAny) > 0 || len(req.scopesAll) > 0 {
+		scopes := claimStrings(claims, true, "scope", "scp", "scopes")
+		if len(req.scopesAny) > 0 && !containsAny(scopes, req.scopesAny) {
+			return &Unmet{Requirement: "scopes_any", Missing: req.scopesAny}
+		}
+		if missing := missingFrom(scopes, req.scopesAll); len(missing) > 0 {
+			return &Unmet{Requirement: "scopes_all", Missing: missing}
+		}
+	}
+	if len(req.rolesAny) > 0 && !containsAny(claimStrings(claims, false, "roles", "role"), req.rolesAny) {
+		return &Unmet{Requirement: "roles_any", Missing: req.rolesAny}
+	}
+	for _, cr := range req.claims {
+		if !containsAny(claimStrings(claims, false, cr.name), cr.accepted) {
+			return &Unmet{Requirement: "claims." + cr.name, Missing: cr.accepted}
+		}
+	}
+	return nil
+}
+
+// claimStrings collects the values of the first present claim among names.
+// Arrays contribute each element. With split, a string is split on
+// whitespace, as OAuth "scope" claims are space-delimited.
+func claimStrings(claims map[string]interface{}, split bool, names ...string) []string {
+	for _, name := range names {
+		v, ok := claims[name]
+		if !ok {
+			continue
+		}
+		switch val := v.(type) {
+		case string:
+			if split {
+				return strings.Fields(val)
+			}
+			return []string{val}
+		case []string:
+			return val
+		case []interface{}:
+			out := make([]string, 0, len(val))
+			for _, item := range val {
+				out = append(out, fmt.Sprint(item))
+			}
+			return out
+		default:
+			return []string{fmt.Sprint(val)}
+		}
+	}
+	return nil
+}
+
+func containsAny(have, want []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func missingFrom(have, want []string) []string {
+	var missing []string
+	for _, w := range want {
+		if !containsAny(have, []string{w}) {
+			missing = append(missing, w)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
+)
+
+func TestRequirementsInactive(t *testing.T) {
+	if rq := NewRequirements(config.AuthRequirementsConfig{}); rq != nil {
+		t.Errorf("expected nil requirements for an empty config, got %+v", rq)
+	}
+}
+
+func TestRequirementsAPIKeyRoles(t *testing.T) {
+	rq := NewRequirements(config.AuthRequirementsConfig{
+		AuthRequirement: config.AuthRequirement{RolesAny: []string{"admin", "billing"}},
+	})
+	id := &variables.Identity{ClientID: "c1", AuthType: "api_key", Claims: map[string]interface{}{"roles": []string{"billing"}}}
+	if unmet := rq.Check("GET", id); unmet != nil {
+		t.Errorf("expected billing role to satisfy roles_any, got %v", unmet)
+	}
+
+	id.Claims["roles"] = []string{"reader"}
+	unmet := rq.Check("GET", id)
+	if unmet == nil || unmet.Requirement != "roles_any" || strings.Join(unmet.Missing, ",") != "admin,billing" {
+		t.Errorf("expected roles_any to be unmet, got %+v", unmet)
+	}
+	if unmet := rq.Check("GET", &variables.Identity{ClientID: "c2", AuthType: "api_key"}); unmet == nil {
+		t.Error("expected an identity without roles to fail")
+	}
+}
+
+func TestRequirementsJWTScopes(t *testing.T) {
+	rq := NewRequirements(config.AuthRequirementsConfig{
+		AuthRequirement: config.AuthRequirement{
+			ScopesAny: []string{"orders:read", "orders:admin"},
+			ScopesAll: []string{"profile", "email"},
+		},
+	})
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		want   string // unmet requirement, "" when met
+		miss   string
+	}{
+		{"space-delimited scope", map[string]interface{}{"scope": "openid profile email orders:read"}, "", ""},
+		{"scp array", map[string]interface{}{"scp": []interface{}{"orders:admin", "profile", "email"}}, "", ""},
+		{"no matching scope", map[string]interface{}{"scope": "profile email"}, "scopes_any", "orders:read,orders:admin"},
+		{"missing one of all", map[string]interface{}{"scope": "orders:read profile"}, "scopes_all", "email"},
+		{"no scope claim", map[string]interface{}{"sub": "alice"}, "scopes_any", "orders:read,orders:admin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unmet := rq.Check("GET", &variables.Identity{ClientID: "alice", AuthType: "jwt", Claims: tt.claims})
+			if tt.want == "" {
+				if unmet != nil {
+					t.Errorf("expected requirements met, got %v", unmet)
+				}
+				return
+			}
+			if unmet == nil || unmet.Requirement != tt.want || strings.Join(unmet.Missing, ",") != tt.miss {
+				t.Errorf("expected %s missing %s, got %+v", tt.want, tt.miss, unmet)
+			}
+		})
+	}
+}
+
+func TestRequirementsClaims(t *testing.T) {
+	rq := NewRequirements(config.AuthRequirementsConfig{
+		AuthRequirement: config.AuthRequirement{Claims: map[string]interface{}{
+			"tier":           []interface{}{"gold", "platinum"},
+			"email_verified": true,
+		}},
+	})
+	id := &variables.Identity{AuthType: "jwt", Claims: map[string]interface{}{"tier": "gold", "email_verified": true}}
+	if unmet := rq.Check("GET", id); unmet != nil {
+		t.Errorf("expected claims met, got %v", unmet)
+	}
+	id.Claims["email_verified"] = false
+	if unmet := rq.Check("GET", id); unmet == nil || unmet.Requirement != "claims.email_verified" {
+		t.Errorf("expected claims.email_verified unmet, got %+v", unmet)
+	}
+	id.Claims["tier"] = "silver"
+	if unmet := rq.Check("GET", id); unmet == nil || unmet.Requirement != "claims.email_verified" {
+		t.Errorf("expected claims to be checked in name order, got %+v", unmet)
+	}
+}
+
+func TestRequirementsMethodOverride(t *testing.T) {
+	rq := NewRequirements(config.AuthRequirementsConfig{
+		AuthRequirement: config.AuthRequirement{ScopesAny: []string{"orders:read"}},
+		Methods: map[string]config.AuthRequirement{
+			"POST":   {ScopesAll: []string{"orders:write"}},
+			"DELETE": {RolesAny: []string{"admin"}},
+		},
+	})
+	reader := &variables.Identity{AuthType: "jwt", Claims: map[string]interface{}{"scope": "orders:read"}}
+	writer := &variables.Identity{AuthType: "jwt", Claims: map[string]interface{}{"scope": "orders:write"}}
+
+	if unmet := rq.Check("GET", reader); unmet != nil {
+		t.Errorf("expected GET allowed for reader, got %v", unmet)
+	}
+	if unmet := rq.Check("POST", reader); unmet == nil || unmet.Requirement != "scopes_all" {
+		t.Errorf("expected POST denied for reader, got %+v", unmet)
+	}
+	// The POST entry replaces the route-wide requirement.
+	if unmet := rq.Check("POST", writer); unmet != nil {
+		t.Errorf("expected POST allowed for writer, got %v", unmet)
+	}
+	if unmet := rq.Check("DELETE", reader); unmet == nil || unmet.Requirement != "roles_any" {
+		t.Errorf("expected DELETE to require a role, got %+v", unmet)
+	}
+}
+
+func TestRequirementsVerbose(t *testing.T) {
+	off := false
+	if rq := NewRequirements(config.AuthRequirementsConfig{AuthRequirement: config.AuthRequirement{RolesAny: []string{"a"}}}); !rq.Verbose() {
+		t.Error("expected verbose errors by default")
+	}
+	if rq := NewRequirements(config.AuthRequirementsConfig{AuthRequirement: config.AuthRequirement{RolesAny: []string{"a"}}, VerboseErrors: &off}); rq.Verbose() {
+		t.Error("expected verbose_errors: false to be honoured")
+	}
+}
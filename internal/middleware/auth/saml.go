@@ -27,6 +27,7 @@ import (
 
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/xmlsafe"
 	"github.com/wudi/runway/variables"
 )
 
@@ -289,7 +290,7 @@ func (a *SAMLAuth) authenticateFromHeader(headerVal string) (*variables.Identity
 	}
 
 	var assertion saml.Assertion
-	if err := xml.Unmarshal(xmlData, &assertion); err != nil {
+	if err := xmlsafe.Unmarshal(xmlData, &assertion); err != nil {
 		a.tokenFailures.Add(1)
 		return nil, errors.ErrUnauthorized.WithDetails("invalid SAML assertion XML")
 	}
@@ -435,6 +436,16 @@ func (a *SAMLAuth) HandleACS(w http.ResponseWriter, r *http.Request) {
 		possibleRequestIDs = []string{} // empty slice = strict validation
 	}
 
+	// Bound the response document before the SAML library parses it.
+	if raw, err := base64.StdEncoding.DecodeString(r.PostForm.Get("SAMLResponse")); err == nil && len(raw) > 0 {
+		if err := xmlsafe.Check(raw); err != nil {
+			a.ssoFailures.Add(1)
+			log.Printf("saml: ACS response rejected: %v", err)
+			http.Error(w, "SAML authentication failed", http.StatusForbidden)
+			return
+		}
+	}
+
 	assertion, err := a.sp.ParseResponse(r, possibleRequestIDs)
 	if err != nil {
 		a.ssoFailures.Add(1)
@@ -538,7 +549,7 @@ func (a *SAMLAuth) handleIDPInitiatedLogout(w http.ResponseWriter, r *http.Reque
 	if samlReq := r.URL.Query().Get("SAMLRequest"); samlReq != "" {
 		if reqData, err := base64.StdEncoding.DecodeString(samlReq); err == nil {
 			var lr saml.LogoutRequest
-			if err := xml.Unmarshal(reqData, &lr); err == nil {
+			if err := xmlsafe.Unmarshal(reqData, &lr); err == nil {
 				logoutReqID = lr.ID
 			}
 		}
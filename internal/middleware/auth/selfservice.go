@@ -0,0 +1,470 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	expirable "github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware/auditlog"
+	"github.com/wudi/runway/variables"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// maxOwnerLimiters caps the number of owners whose create/rotate and list
+// limiters are kept; idle limiters also expire once they would have refilled.
+const maxOwnerLimiters = 10000
+
+// Self-service key lifecycle event types, matching the webhook event names.
+const (
+	KeyEventCreated = "api_key.created"
+	KeyEventRotated = "api_key.rotated"
+	KeyEventRevoked = "api_key.revoked"
+)
+
+// maxSelfServiceBody caps the size of self-service request bodies.
+const maxSelfServiceBody = 64 << 10
+
+// KeySelfService serves client-facing endpoints that let authenticated users
+// manage their own managed API keys:
+//
+//	POST   {prefix}              create a key (the secret is returned once)
+//	GET    {prefix}              list the caller's keys
+//	POST   {prefix}/{id}/rotate  rotate a key
+//	DELETE {prefix}/{id}         revoke a key
+//
+// The owner of every key is the caller's identity, taken from a configurable
+// JWT claim, and is enforced on every operation.
+type KeySelfService struct {
+	manager      *APIKeyManager
+	authenticate func(r *http.Request) (*variables.Identity, error)
+	pathPrefix   string
+	listeners    map[string]bool // listener IDs serving the endpoints; nil = all
+	ownerClaim   string
+	maxKeys      int
+	maxTTL       time.Duration
+	gracePeriod  time.Duration
+	createLimits *ownerLimiters
+	listLimits   *ownerLimiters
+
+	onEvent  func(eventType string, data map[string]interface{})
+	auditLog *auditlog.AuditLogger
+
+	created     atomic.Int64
+	rotated     atomic.Int64
+	revoked     atomic.Int64
+	rateLimited atomic.Int64
+	denied      atomic.Int64
+}
+
+// SelfServiceStats holds self-service endpoint counters.
+type SelfServiceStats struct {
+	PathPrefix  string `json:"path_prefix"`
+	Created     int64  `json:"created"`
+	Rotated     int64  `json:"rotated"`
+	Revoked     int64  `json:"revoked"`
+	RateLimited int64  `json:"rate_limited"`
+	Denied      int64  `json:"denied"`
+}
+
+// NewKeySelfService creates the self-service endpoints on top of manager.
+// authenticate resolves the caller's identity, typically JWTAuth.Authenticate.
+func NewKeySelfService(cfg config.APIKeySelfServiceConfig, manager *APIKeyManager, authenticate func(r *http.Request) (*variables.Identity, error)) *KeySelfService {
+	prefix := strings.TrimRight(cfg.PathPrefix, "/")
+	if prefix == "" {
+		prefix = "/keys"
+	}
+	maxKeys := cfg.MaxKeys
+	if maxKeys == 0 {
+		maxKeys = 10
+	}
+	grace := cfg.GracePeriod
+	if grace == 0 {
+		grace = time.Hour
+	}
+	createRL := KeyRateLimit{Rate: 10, Period: time.Hour, Burst: 10}
+	if cfg.CreateRateLimit != nil {
+		createRL = KeyRateLimit{
+			Rate:   cfg.CreateRateLimit.Rate,
+			Period: cfg.CreateRateLimit.Period,
+			Burst:  cfg.CreateRateLimit.Burst,
+		}
+	}
+	listRL := KeyRateLimit{Rate: 60, Period: time.Minute, Burst: 10}
+	if cfg.ListRateLimit != nil {
+		listRL = KeyRateLimit{
+			Rate:   cfg.ListRateLimit.Rate,
+			Period: cfg.ListRateLimit.Period,
+			Burst:  cfg.ListRateLimit.Burst,
+		}
+	}
+	var listeners map[string]bool
+	if len(cfg.Listeners) > 0 {
+		listeners = make(map[string]bool, len(cfg.Listeners))
+		for _, id := range cfg.Listeners {
+			listeners[id] = true
+		}
+	}
+	return &KeySelfService{
+		manager:      manager,
+		authenticate: authenticate,
+		pathPrefix:   prefix,
+		listeners:    listeners,
+		ownerClaim:   cfg.OwnerClaim,
+		maxKeys:      maxKeys,
+		maxTTL:       cfg.MaxTTL,
+		gracePeriod:  grace,
+		createLimits: newOwnerLimiters(createRL),
+		listLimits:   newOwnerLimiters(listRL),
+	}
+}
+
+// ownerLimiters holds one token bucket per key owner.
+type ownerLimiters struct {
+	limit    KeyRateLimit
+	mu       sync.Mutex
+	limiters *expirable.LRU[string, *rate.Limiter]
+}
+
+func newOwnerLimiters(limit KeyRateLimit) *ownerLimiters {
+	// A limiter idle for this long has refilled its burst, so dropping it
+	// and starting a fresh one later is equivalent.
+	refill := limit.Period * time.Duration(limit.Burst) / time.Duration(limit.Rate)
+	return &ownerLimiters{
+		limit:    limit,
+		limiters: expirable.NewLRU[string, *rate.Limiter](maxOwnerLimiters, nil, refill),
+	}
+}
+
+// allow takes a token from owner's bucket.
+func (o *ownerLimiters) allow(owner string) bool {
+	o.mu.Lock()
+	lim, ok := o.limiters.Get(owner)
+	if !ok {
+		lim = rate.NewLimiter(rate.Every(o.limit.Period/time.Duration(o.limit.Rate)), o.limit.Burst)
+	}
+	o.limiters.Add(owner, lim) // restarts the idle expiry
+	o.mu.Unlock()
+	return lim.Allow()
+}
+
+// SetOnEvent sets a callback invoked for every key lifecycle event.
+func (s *KeySelfService) SetOnEvent(fn func(eventType string, data map[string]interface{})) {
+	s.onEvent = fn
+}
+
+// SetAuditLogger delivers every key lifecycle event to al as an audit log
+// entry. The self-service endpoints take ownership of al and close it in Close.
+func (s *KeySelfService) SetAuditLogger(al *auditlog.AuditLogger) {
+	s.auditLog = al
+}
+
+// Close flushes and stops the audit logger, if any.
+func (s *KeySelfService) Close() {
+	if s.auditLog != nil {
+		s.auditLog.Close()
+	}
+}
+
+// MatchesPath returns true if the request path is served by the self-service endpoints.
+func (s *KeySelfService) MatchesPath(path string) bool {
+	return path == s.pathPrefix || strings.HasPrefix(path, s.pathPrefix+"/")
+}
+
+// Matches returns true if r is served by the self-service endpoints: its path
+// matches and it arrived on one of the configured listeners.
+func (s *KeySelfService) Matches(r *http.Request) bool {
+	if !s.MatchesPath(r.URL.Path) {
+		return false
+	}
+	if s.listeners == nil {
+		return true
+	}
+	varCtx, ok := r.Context().Value(variables.RequestContextKey{}).(*variables.Context)
+	return ok && s.listeners[varCtx.ListenerID]
+}
+
+// ServeHTTP dispatches a self-service request after authenticating the caller.
+func (s *KeySelfService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	identity, err := s.authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeSelfServiceError(w, err)
+		return
+	}
+	owner := s.ownerOf(identity)
+	if owner == "" {
+		s.denied.Add(1)
+		errors.ErrForbidden.WithDetails("token does not identify a key owner").WriteJSON(w)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	rest := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, s.pathPrefix), "/")
+	id, action, _ := strings.Cut(rest, "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		s.handleList(w, r, owner)
+	case id == "" && r.Method == http.MethodPost:
+		s.handleCreate(w, r, owner)
+	case id != "" && action == "rotate" && r.Method == http.MethodPost:
+		s.handleRotate(w, r, owner, id)
+	case id != "" && action == "" && r.Method == http.MethodDelete:
+		s.handleRevoke(w, r, owner, id)
+	case id == "" || action == "" || action == "rotate":
+		errors.ErrMethodNotAllowed.WriteJSON(w)
+	default:
+		errors.ErrNotFound.WriteJSON(w)
+	}
+}
+
+// ownerOf returns the key owner for identity: the configured claim when set,
+// otherwise the identity's client ID.
+func (s *KeySelfService) ownerOf(identity *variables.Identity) string {
+	if s.ownerClaim == "" {
+		return identity.ClientID
+	}
+	v, _ := identity.Claims[s.ownerClaim].(string)
+	return v
+}
+
+// selfServiceKey is the client-facing view of a managed key. It never
+// includes the key hash or the secret.
+type selfServiceKey struct {
+	ID               string     `json:"id"`
+	KeyPrefix        string     `json:"key_prefix"`
+	Name             string     `json:"name,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	UsageCount       int64      `json:"usage_count"`
+	Revoked          bool       `json:"revoked"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	RotationDeadline *time.Time `json:"rotation_deadline,omitempty"`
+	RotatedFrom      string     `json:"rotated_from,omitempty"`
+}
+
+func newSelfServiceKey(mk *ManagedKey) selfServiceKey {
+	optTime := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+	v := selfServiceKey{
+		ID:               mk.ID(),
+		KeyPrefix:        mk.KeyPrefix,
+		Name:             mk.Name,
+		CreatedAt:        mk.CreatedAt,
+		ExpiresAt:        optTime(mk.ExpiresAt),
+		LastUsedAt:       optTime(mk.LastUsedAt),
+		UsageCount:       mk.UsageCount,
+		Revoked:          mk.Revoked,
+		RevokedAt:        optTime(mk.RevokedAt),
+		RotationDeadline: optTime(mk.RotationDeadline),
+	}
+	if mk.RotatedFrom != "" {
+		v.RotatedFrom = mk.RotatedFrom[:min(len(mk.RotatedFrom), 16)]
+	}
+	return v
+}
+
+func (s *KeySelfService) handleList(w http.ResponseWriter, r *http.Request, owner string) {
+	// Listing reads every stored key, so it is limited per owner too.
+	if !s.listLimits.allow(owner) {
+		s.rateLimited.Add(1)
+		errors.ErrTooManyRequests.WithDetails("key listing rate limit exceeded").WriteJSON(w)
+		return
+	}
+
+	keys := s.manager.OwnedKeys(owner)
+	result := make([]selfServiceKey, 0, len(keys))
+	for _, mk := range keys {
+		result = append(result, newSelfServiceKey(mk))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": result})
+}
+
+func (s *KeySelfService) handleCreate(w http.ResponseWriter, r *http.Request, owner string) {
+	var req struct {
+		Name string `json:"name"`
+		TTL  string `json:"ttl"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxSelfServiceBody)).Decode(&req); err != nil && err != io.EOF {
+			errors.ErrBadRequest.WithDetails("Invalid JSON body").WriteJSON(w)
+			return
+		}
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			errors.ErrBadRequest.WithDetails("ttl must be a positive duration").WriteJSON(w)
+			return
+		}
+	}
+	if s.maxTTL > 0 {
+		if ttl > s.maxTTL {
+			errors.ErrBadRequest.WithDetails(fmt.Sprintf("ttl must not exceed %s", s.maxTTL)).WriteJSON(w)
+			return
+		}
+		if ttl == 0 {
+			ttl = s.maxTTL
+		}
+	}
+
+	if !s.allow(owner) {
+		errors.ErrTooManyRequests.WithDetails("key creation rate limit exceeded").WriteJSON(w)
+		return
+	}
+
+	rawKey, mk, err := s.manager.CreateOwnedKey(owner, req.Name, ttl, s.maxKeys)
+	if err != nil {
+		s.countDenied(err)
+		writeSelfServiceError(w, err)
+		return
+	}
+
+	s.created.Add(1)
+	s.audit(r, KeyEventCreated, owner, map[string]interface{}{"key_id": mk.ID(), "name": mk.Name})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		Key string `json:"key"`
+		selfServiceKey
+	}{rawKey, newSelfServiceKey(mk)})
+}
+
+func (s *KeySelfService) handleRotate(w http.ResponseWriter, r *http.Request, owner, id string) {
+	if !s.allow(owner) {
+		errors.ErrTooManyRequests.WithDetails("key creation rate limit exceeded").WriteJSON(w)
+		return
+	}
+
+	rawKey, mk, err := s.manager.RotateOwnedKey(owner, id, s.gracePeriod)
+	if err != nil {
+		s.countDenied(err)
+		writeSelfServiceError(w, err)
+		return
+	}
+
+	s.rotated.Add(1)
+	s.audit(r, KeyEventRotated, owner, map[string]interface{}{"key_id": mk.ID(), "replaces": id})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Key                 string    `json:"key"`
+		PreviousKeyDeadline time.Time `json:"previous_key_valid_until"`
+		selfServiceKey
+	}{rawKey, time.Now().Add(s.gracePeriod), newSelfServiceKey(mk)})
+}
+
+func (s *KeySelfService) handleRevoke(w http.ResponseWriter, r *http.Request, owner, id string) {
+	mk, changed, err := s.manager.RevokeOwnedKey(owner, id)
+	if err != nil {
+		s.countDenied(err)
+		writeSelfServiceError(w, err)
+		return
+	}
+
+	if changed {
+		s.revoked.Add(1)
+		s.audit(r, KeyEventRevoked, owner, map[string]interface{}{"key_id": mk.ID()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newSelfServiceKey(mk))
+}
+
+// allow applies the per-owner rate limit on minting new keys.
+func (s *KeySelfService) allow(owner string) bool {
+	if !s.createLimits.allow(owner) {
+		s.rateLimited.Add(1)
+		return false
+	}
+	return true
+}
+
+// countDenied counts operations refused for ownership or quota reasons.
+func (s *KeySelfService) countDenied(err error) {
+	if rErr, ok := err.(*errors.RunwayError); ok && (rErr.Code == http.StatusNotFound || rErr.Code == http.StatusForbidden) {
+		s.denied.Add(1)
+	}
+}
+
+// audit records a lifecycle event in the log and the audit log, and notifies
+// the event callback.
+func (s *KeySelfService) audit(r *http.Request, eventType, owner string, data map[string]interface{}) {
+	clientIP := variables.ExtractClientIP(r)
+	requestID := variables.GetFromRequest(r).RequestID
+	fields := []zap.Field{
+		zap.String("event", eventType),
+		zap.String("owner", owner),
+		zap.String("client_ip", clientIP),
+	}
+	if requestID != "" {
+		fields = append(fields, zap.String("request_id", requestID))
+	}
+	for k, v := range data {
+		fields = append(fields, zap.Any(k, v))
+	}
+	logging.Info("API key audit", fields...)
+
+	if s.auditLog != nil {
+		details := make(map[string]interface{}, len(data))
+		for k, v := range data {
+			details[k] = v
+		}
+		s.auditLog.Enqueue(&auditlog.AuditEntry{
+			Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+			RequestID: requestID,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			ClientIP:  clientIP,
+			Event:     eventType,
+			Actor:     owner,
+			Details:   details,
+		})
+	}
+
+	if s.onEvent != nil {
+		data["owner"] = owner
+		s.onEvent(eventType, data)
+	}
+}
+
+// Stats returns self-service endpoint counters.
+func (s *KeySelfService) Stats() SelfServiceStats {
+	return SelfServiceStats{
+		PathPrefix:  s.pathPrefix,
+		Created:     s.created.Load(),
+		Rotated:     s.rotated.Load(),
+		Revoked:     s.revoked.Load(),
+		RateLimited: s.rateLimited.Load(),
+		Denied:      s.denied.Load(),
+	}
+}
+
+func writeSelfServiceError(w http.ResponseWriter, err error) {
+	if rErr, ok := err.(*errors.RunwayError); ok {
+		rErr.WriteJSON(w)
+		return
+	}
+	errors.ErrInternalServer.WriteJSON(w)
+}
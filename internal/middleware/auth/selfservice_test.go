@@ -0,0 +1,404 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/middleware/auditlog"
+	"github.com/wudi/runway/variables"
+)
+
+// testBearerAuth treats the bearer token as the caller's subject.
+func testBearerAuth(r *http.Request) (*variables.Identity, error) {
+	sub := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if sub == "" {
+		return nil, errors.ErrUnauthorized.WithDetails("Missing token")
+	}
+	return &variables.Identity{
+		ClientID: sub,
+		AuthType: "jwt",
+		Claims:   map[string]interface{}{"sub": sub, "user": "u-" + sub},
+	}, nil
+}
+
+func newTestSelfService(t *testing.T, cfg config.APIKeySelfServiceConfig) (*KeySelfService, *APIKeyManager) {
+	t.Helper()
+	store := NewMemoryKeyStore(time.Minute)
+	t.Cleanup(store.Close)
+	mgr := NewAPIKeyManager(KeyManagerConfig{KeyPrefix: "gw_", Store: store})
+	cfg.Enabled = true
+	return NewKeySelfService(cfg, mgr, testBearerAuth), mgr
+}
+
+func doSelfService(s *KeySelfService, method, path, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if user != "" {
+		req.Header.Set("Authorization", "Bearer "+user)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+type createdKeyResp struct {
+	Key string `json:"key"`
+	ID  string `json:"id"`
+}
+
+func createTestKey(t *testing.T, s *KeySelfService, user string) createdKeyResp {
+	t.Helper()
+	rec := doSelfService(s, http.MethodPost, "/keys", user, `{"name":"ci"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp createdKeyResp
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Key == "" || resp.ID == "" {
+		t.Fatalf("create: missing key or id: %s", rec.Body.String())
+	}
+	return resp
+}
+
+func TestSelfServiceLifecycle(t *testing.T) {
+	s, mgr := newTestSelfService(t, config.APIKeySelfServiceConfig{})
+
+	var events []string
+	s.SetOnEvent(func(eventType string, data map[string]interface{}) {
+		if data["owner"] != "alice" {
+			t.Errorf("event owner = %v, want alice", data["owner"])
+		}
+		events = append(events, eventType)
+	})
+
+	created := createTestKey(t, s, "alice")
+	if identity, err := mgr.Authenticate(created.Key); err != nil || identity.ClientID != "alice" {
+		t.Fatalf("new key does not authenticate as alice: %v", err)
+	}
+
+	// The list never exposes secrets or hashes.
+	rec := doSelfService(s, http.MethodGet, "/keys", "alice", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: status %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), created.Key) || strings.Contains(rec.Body.String(), "key_hash") {
+		t.Errorf("list leaks secret material: %s", rec.Body.String())
+	}
+	var list struct {
+		Keys []selfServiceKey `json:"keys"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Keys) != 1 || list.Keys[0].ID != created.ID || list.Keys[0].Name != "ci" {
+		t.Fatalf("list = %+v", list.Keys)
+	}
+
+	// Rotate: the new key works, the old one stays valid for the grace period.
+	rec = doSelfService(s, http.MethodPost, "/keys/"+created.ID+"/rotate", "alice", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rotate: status %d: %s", rec.Code, rec.Body.String())
+	}
+	var rotated createdKeyResp
+	json.Unmarshal(rec.Body.Bytes(), &rotated)
+	if rotated.ID == created.ID {
+		t.Fatal("rotate returned the old key")
+	}
+	if _, err := mgr.Authenticate(rotated.Key); err != nil {
+		t.Errorf("rotated key: %v", err)
+	}
+	if _, err := mgr.Authenticate(created.Key); err != nil {
+		t.Errorf("old key within grace period: %v", err)
+	}
+
+	// Revoke the new key.
+	rec = doSelfService(s, http.MethodDelete, "/keys/"+rotated.ID, "alice", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revoke: status %d: %s", rec.Code, rec.Body.String())
+	}
+	// Revoking again is idempotent and not audited twice.
+	doSelfService(s, http.MethodDelete, "/keys/"+rotated.ID, "alice", "")
+
+	want := []string{KeyEventCreated, KeyEventRotated, KeyEventRevoked}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if st := s.Stats(); st.Created != 1 || st.Rotated != 1 || st.Revoked != 1 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestSelfServiceRevokedKeyRejectedImmediately(t *testing.T) {
+	s, mgr := newTestSelfService(t, config.APIKeySelfServiceConfig{})
+	created := createTestKey(t, s, "alice")
+
+	if _, err := mgr.Authenticate(created.Key); err != nil {
+		t.Fatal(err)
+	}
+	if rec := doSelfService(s, http.MethodDelete, "/keys/"+created.ID, "alice", ""); rec.Code != http.StatusOK {
+		t.Fatalf("revoke: status %d", rec.Code)
+	}
+
+	_, err := mgr.Authenticate(created.Key)
+	if rErr, ok := err.(*errors.RunwayError); !ok || rErr.Code != http.StatusForbidden {
+		t.Fatalf("revoked key: err = %v, want 403", err)
+	}
+	// A revoked key cannot be rotated back to life.
+	if rec := doSelfService(s, http.MethodPost, "/keys/"+created.ID+"/rotate", "alice", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("rotate revoked: status %d, want 403", rec.Code)
+	}
+}
+
+func TestSelfServiceRevokeRacesUsage(t *testing.T) {
+	s, mgr := newTestSelfService(t, config.APIKeySelfServiceConfig{})
+	created := createTestKey(t, s, "alice")
+
+	// Concurrent authentications record usage while the key is revoked; the
+	// usage updates must never overwrite the revocation.
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					mgr.Authenticate(created.Key)
+				}
+			}
+		}()
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if rec := doSelfService(s, http.MethodDelete, "/keys/"+created.ID, "alice", ""); rec.Code != http.StatusOK {
+		t.Fatalf("revoke: status %d", rec.Code)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := mgr.Authenticate(created.Key); err == nil {
+			t.Fatal("revoked key authenticated")
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	keys := mgr.OwnedKeys("alice")
+	if len(keys) != 1 || !keys[0].Revoked {
+		t.Fatalf("key revocation lost: %+v", keys)
+	}
+}
+
+func TestSelfServiceConcurrentRotation(t *testing.T) {
+	s, mgr := newTestSelfService(t, config.APIKeySelfServiceConfig{
+		CreateRateLimit: &config.KeyRateLimitConfig{Rate: 100, Period: time.Second, Burst: 100},
+	})
+	created := createTestKey(t, s, "alice")
+
+	const n = 10
+	codes := make([]int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = doSelfService(s, http.MethodPost, "/keys/"+created.ID+"/rotate", "alice", "").Code
+		}(i)
+	}
+	wg.Wait()
+
+	ok, conflict := 0, 0
+	for _, c := range codes {
+		switch c {
+		case http.StatusOK:
+			ok++
+		case http.StatusConflict:
+			conflict++
+		}
+	}
+	if ok != 1 || conflict != n-1 {
+		t.Errorf("rotations: %d succeeded, %d conflicted (codes %v)", ok, conflict, codes)
+	}
+	if keys := mgr.OwnedKeys("alice"); len(keys) != 2 {
+		t.Errorf("owned keys = %d, want 2 (original + one replacement)", len(keys))
+	}
+}
+
+func TestSelfServiceOwnership(t *testing.T) {
+	s, mgr := newTestSelfService(t, config.APIKeySelfServiceConfig{})
+	created := createTestKey(t, s, "alice")
+
+	if rec := doSelfService(s, http.MethodPost, "/keys/"+created.ID+"/rotate", "mallory", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("rotate by other user: status %d, want 404", rec.Code)
+	}
+	if rec := doSelfService(s, http.MethodDelete, "/keys/"+created.ID, "mallory", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("revoke by other user: status %d, want 404", rec.Code)
+	}
+	rec := doSelfService(s, http.MethodGet, "/keys", "mallory", "")
+	if strings.Contains(rec.Body.String(), created.ID) {
+		t.Errorf("other user's list includes alice's key: %s", rec.Body.String())
+	}
+	if _, err := mgr.Authenticate(created.Key); err != nil {
+		t.Errorf("alice's key affected by other user: %v", err)
+	}
+	if st := s.Stats(); st.Denied != 2 {
+		t.Errorf("denied = %d, want 2", st.Denied)
+	}
+}
+
+func TestSelfServiceOwnerClaim(t *testing.T) {
+	s, mgr := newTestSelfService(t, config.APIKeySelfServiceConfig{OwnerClaim: "user"})
+	createTestKey(t, s, "alice")
+	if keys := mgr.OwnedKeys("u-alice"); len(keys) != 1 {
+		t.Errorf("keys owned by claim value = %d, want 1", len(keys))
+	}
+
+	s, _ = newTestSelfService(t, config.APIKeySelfServiceConfig{OwnerClaim: "tenant"})
+	if rec := doSelfService(s, http.MethodPost, "/keys", "alice", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("missing owner claim: status %d, want 403", rec.Code)
+	}
+}
+
+func TestSelfServiceLimits(t *testing.T) {
+	s, _ := newTestSelfService(t, config.APIKeySelfServiceConfig{
+		MaxKeys: 2,
+		MaxTTL:  24 * time.Hour,
+	})
+
+	createTestKey(t, s, "alice")
+	second := createTestKey(t, s, "alice")
+	if rec := doSelfService(s, http.MethodPost, "/keys", "alice", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("over key limit: status %d, want 403", rec.Code)
+	}
+	// Revoked keys do not count toward the limit.
+	doSelfService(s, http.MethodDelete, "/keys/"+second.ID, "alice", "")
+	createTestKey(t, s, "alice")
+
+	if rec := doSelfService(s, http.MethodPost, "/keys", "bob", `{"ttl":"48h"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("ttl above max: status %d, want 400", rec.Code)
+	}
+	rec := doSelfService(s, http.MethodPost, "/keys", "bob", "")
+	var resp selfServiceKey
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.ExpiresAt == nil {
+		t.Error("key without ttl should expire at max_ttl")
+	}
+}
+
+func TestSelfServiceCreateRateLimit(t *testing.T) {
+	s, _ := newTestSelfService(t, config.APIKeySelfServiceConfig{
+		CreateRateLimit: &config.KeyRateLimitConfig{Rate: 1, Period: time.Hour, Burst: 2},
+	})
+
+	createTestKey(t, s, "alice")
+	createTestKey(t, s, "alice")
+	if rec := doSelfService(s, http.MethodPost, "/keys", "alice", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("third create: status %d, want 429", rec.Code)
+	}
+	// Limits are per owner.
+	createTestKey(t, s, "bob")
+	if st := s.Stats(); st.RateLimited != 1 {
+		t.Errorf("rate_limited = %d, want 1", st.RateLimited)
+	}
+}
+
+func TestSelfServiceListRateLimit(t *testing.T) {
+	s, _ := newTestSelfService(t, config.APIKeySelfServiceConfig{
+		ListRateLimit: &config.KeyRateLimitConfig{Rate: 1, Period: time.Hour, Burst: 2},
+	})
+
+	for i := 0; i < 2; i++ {
+		if rec := doSelfService(s, http.MethodGet, "/keys", "alice", ""); rec.Code != http.StatusOK {
+			t.Fatalf("list %d: status %d", i, rec.Code)
+		}
+	}
+	if rec := doSelfService(s, http.MethodGet, "/keys", "alice", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("third list: status %d, want 429", rec.Code)
+	}
+	// Listing does not use up the create budget, and limits are per owner.
+	createTestKey(t, s, "alice")
+	if rec := doSelfService(s, http.MethodGet, "/keys", "bob", ""); rec.Code != http.StatusOK {
+		t.Errorf("other owner: status %d, want 200", rec.Code)
+	}
+	if st := s.Stats(); st.RateLimited != 1 {
+		t.Errorf("rate_limited = %d, want 1", st.RateLimited)
+	}
+}
+
+func TestSelfServiceAuditLog(t *testing.T) {
+	var mu sync.Mutex
+	var entries []auditlog.AuditEntry
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []auditlog.AuditEntry
+		json.NewDecoder(r.Body).Decode(&batch)
+		mu.Lock()
+		entries = append(entries, batch...)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	s, _ := newTestSelfService(t, config.APIKeySelfServiceConfig{})
+	s.SetAuditLogger(auditlog.New("", config.AuditLogConfig{Enabled: true, WebhookURL: srv.URL}))
+
+	created := createTestKey(t, s, "alice")
+	doSelfService(s, http.MethodDelete, "/keys/"+created.ID, "alice", "")
+	s.Close() // flushes the queued entries
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", len(entries))
+	}
+	for i, want := range []string{KeyEventCreated, KeyEventRevoked} {
+		e := entries[i]
+		if e.Event != want || e.Actor != "alice" || e.Details["key_id"] != created.ID {
+			t.Errorf("entry %d = %+v, want %s by alice for %s", i, e, want, created.ID)
+		}
+	}
+	if entries[0].Method != http.MethodPost || entries[0].Path != "/keys" {
+		t.Errorf("create entry request = %s %s", entries[0].Method, entries[0].Path)
+	}
+}
+
+func TestSelfServiceRouting(t *testing.T) {
+	s, _ := newTestSelfService(t, config.APIKeySelfServiceConfig{PathPrefix: "/account/keys/"})
+
+	if !s.MatchesPath("/account/keys") || !s.MatchesPath("/account/keys/abc/rotate") {
+		t.Error("expected prefix paths to match")
+	}
+	if s.MatchesPath("/account/keysets") || s.MatchesPath("/keys") {
+		t.Error("unexpected match")
+	}
+
+	// Without listeners the endpoints are served on every listener; with
+	// them, only on those.
+	req := httptest.NewRequest(http.MethodGet, "/account/keys", nil)
+	if !s.Matches(req) {
+		t.Error("expected a match on any listener")
+	}
+	scoped, _ := newTestSelfService(t, config.APIKeySelfServiceConfig{PathPrefix: "/account/keys", Listeners: []string{"public"}})
+	for listener, want := range map[string]bool{"public": true, "internal": false, "": false} {
+		varCtx := variables.NewContext(req)
+		varCtx.ListenerID = listener
+		r := req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+		if got := scoped.Matches(r); got != want {
+			t.Errorf("listener %q: Matches = %v, want %v", listener, got, want)
+		}
+	}
+
+	if rec := doSelfService(s, http.MethodGet, "/account/keys", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated: status %d, want 401", rec.Code)
+	}
+	if rec := doSelfService(s, http.MethodPut, "/account/keys", "alice", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT collection: status %d, want 405", rec.Code)
+	}
+	if rec := doSelfService(s, http.MethodPost, "/account/keys/abc/enable", "alice", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown action: status %d, want 404", rec.Code)
+	}
+}
@@ -176,10 +176,62 @@ func (p *TokenProvider) Stats() map[string]interface{} {
 	return stats
 }
 
-// BackendAuthByRoute manages per-route backend auth token providers.
-type BackendAuthByRoute = byroute.NamedFactory[*TokenProvider, config.BackendAuthConfig]
+// BackendAuthByRoute manages per-route backend auth token providers. Routes
+// that reference a shared provider use the provider's token cache directly.
+type BackendAuthByRoute struct {
+	byroute.Manager[*TokenProvider]
+	providers *byroute.Shared[*TokenProvider, config.BackendAuthConfig]
+}
 
 // NewBackendAuthByRoute creates a new per-route backend auth manager.
-func NewBackendAuthByRoute() *BackendAuthByRoute {
-	return byroute.NewNamedFactory(New, func(p *TokenProvider) any { return p.Stats() })
+// providers are the named definitions routes may reference via
+// backend_auth.ref.
+func NewBackendAuthByRoute(providers map[string]config.BackendAuthConfig) *BackendAuthByRoute {
+	return &BackendAuthByRoute{
+		providers: byroute.NewShared(providers, New, func(p *TokenProvider) any { return p.Stats() }),
+	}
+}
+
+// AddRoute creates a token provider for the route, or attaches the route to
+// the referenced shared provider.
+func (m *BackendAuthByRoute) AddRoute(routeID string, cfg config.BackendAuthConfig) error {
+	var (
+		p   *TokenProvider
+		err error
+	)
+	if cfg.Ref == "" {
+		p, err = New(routeID, cfg)
+	} else {
+		p, err = m.providers.Acquire(cfg.Ref, routeID)
+	}
+	if err != nil {
+		return err
+	}
+	m.Add(routeID, p)
+	return nil
+}
+
+// AcquireShared returns the named shared provider for a route component
+// with its own credentials, such as an aggregate backend. referrer
+// identifies the component in SharedStats.
+func (m *BackendAuthByRoute) AcquireShared(name, referrer string) (*TokenProvider, error) {
+	return m.providers.Acquire(name, referrer)
+}
+
+// Stats returns stats for routes with an inline backend_auth config. Routes
+// referencing a shared provider are reported by SharedStats.
+func (m *BackendAuthByRoute) Stats() map[string]any {
+	result := make(map[string]any)
+	m.Range(func(id string, p *TokenProvider) bool {
+		if _, shared := m.providers.RefOf(id); !shared {
+			result[id] = p.Stats()
+		}
+		return true
+	})
+	return result
+}
+
+// SharedStats returns stats per shared provider with the routes using it.
+func (m *BackendAuthByRoute) SharedStats() map[string]byroute.SharedStats {
+	return m.providers.Stats()
 }
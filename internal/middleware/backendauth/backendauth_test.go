@@ -194,7 +194,7 @@ func TestBackendAuthByRoute(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	m := NewBackendAuthByRoute()
+	m := NewBackendAuthByRoute(nil)
 
 	err := m.AddRoute("r1", config.BackendAuthConfig{
 		Enabled:      true,
@@ -260,3 +260,44 @@ func TestTokenProvider_Middleware(t *testing.T) {
 		t.Errorf("expected 'Bearer mw-token', got %q", gotAuth)
 	}
 }
+
+func TestBackendAuthByRoute_SharedProvider(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "shared-token",
+			"expires_in":   3600,
+		})
+	}))
+	defer ts.Close()
+
+	m := NewBackendAuthByRoute(map[string]config.BackendAuthConfig{
+		"billing": {Type: "oauth2_client_credentials", TokenURL: ts.URL + "/token", ClientID: "c", ClientSecret: "s"},
+	})
+	for _, id := range []string{"r1", "r2"} {
+		if err := m.AddRoute(id, config.BackendAuthConfig{Ref: "billing"}); err != nil {
+			t.Fatalf("AddRoute(%s): %v", id, err)
+		}
+	}
+
+	for _, id := range []string{"r1", "r2"} {
+		r := httptest.NewRequest("GET", "/", nil)
+		m.Lookup(id).Apply(r)
+		if got := r.Header.Get("Authorization"); got != "Bearer shared-token" {
+			t.Errorf("%s: expected shared token, got %q", id, got)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected one token fetch for the shared provider, got %d", calls.Load())
+	}
+
+	shared := m.SharedStats()["billing"]
+	if len(shared.Routes) != 2 || shared.Routes[0] != "r1" {
+		t.Errorf("unexpected referencing routes: %v", shared.Routes)
+	}
+	if len(m.Stats()) != 0 {
+		t.Errorf("referencing routes should not appear in per-route stats: %v", m.Stats())
+	}
+}
@@ -0,0 +1,559 @@
+package backendenc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/wudi/runway/config"
+)
+
+const (
+	// maxAvroDepth bounds nesting while decoding recursive schemas.
+	maxAvroDepth = 64
+	// maxAvroItems bounds the total number of array and map elements per body,
+	// so zero-width elements (null, empty records) cannot inflate memory.
+	maxAvroItems = 1 << 20
+	// maxSchemaSize bounds schema files and registry responses.
+	maxSchemaSize = 1 << 20
+)
+
+var errAvroShort = errors.New("avro: unexpected end of data")
+
+// avroType is a node of a parsed Avro schema.
+type avroType struct {
+	kind     string      // primitive name, "record", "enum", "array", "map", "union" or "fixed"
+	name     string      // full name of named types
+	fields   []avroField // record
+	symbols  []string    // enum
+	items    *avroType   // array items, map values
+	branches []*avroType // union
+	size     int         // fixed
+}
+
+type avroField struct {
+	name string
+	typ  *avroType
+}
+
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// avroParser resolves named type references while parsing a schema.
+type avroParser struct {
+	named map[string]*avroType
+}
+
+// parseAvroSchema parses a JSON Avro schema. Logical types are decoded as
+// their underlying type.
+func parseAvroSchema(data []byte) (*avroType, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("avro: invalid schema JSON: %w", err)
+	}
+	p := &avroParser{named: make(map[string]*avroType)}
+	return p.parse(raw, "", 0)
+}
+
+func (p *avroParser) parse(v interface{}, ns string, depth int) (*avroType, error) {
+	if depth > maxAvroDepth {
+		return nil, errors.New("avro: schema nested too deeply")
+	}
+	switch s := v.(type) {
+	case string:
+		if avroPrimitives[s] {
+			return &avroType{kind: s}, nil
+		}
+		if t := p.lookup(s, ns); t != nil {
+			return t, nil
+		}
+		return nil, fmt.Errorf("avro: unknown type %q", s)
+	case []interface{}:
+		if len(s) == 0 {
+			return nil, errors.New("avro: empty union")
+		}
+		u := &avroType{kind: "union"}
+		for _, b := range s {
+			bt, err := p.parse(b, ns, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if bt.kind == "union" {
+				return nil, errors.New("avro: unions may not contain unions")
+			}
+			u.branches = append(u.branches, bt)
+		}
+		return u, nil
+	case map[string]interface{}:
+		tname, ok := s["type"].(string)
+		if !ok {
+			if s["type"] == nil {
+				return nil, errors.New("avro: schema object without type")
+			}
+			return p.parse(s["type"], ns, depth+1)
+		}
+		switch tname {
+		case "record", "error":
+			name, rns, err := p.define(s, ns)
+			if err != nil {
+				return nil, err
+			}
+			t := &avroType{kind: "record", name: name}
+			p.named[name] = t
+			fields, _ := s["fields"].([]interface{})
+			for _, f := range fields {
+				fm, ok := f.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("avro: record %s has an invalid field", name)
+				}
+				fname, _ := fm["name"].(string)
+				if fname == "" {
+					return nil, fmt.Errorf("avro: record %s has a field without a name", name)
+				}
+				ft, err := p.parse(fm["type"], rns, depth+1)
+				if err != nil {
+					return nil, fmt.Errorf("avro: field %s.%s: %w", name, fname, err)
+				}
+				t.fields = append(t.fields, avroField{name: fname, typ: ft})
+			}
+			return t, nil
+		case "enum":
+			name, _, err := p.define(s, ns)
+			if err != nil {
+				return nil, err
+			}
+			t := &avroType{kind: "enum", name: name}
+			symbols, _ := s["symbols"].([]interface{})
+			for _, sym := range symbols {
+				str, ok := sym.(string)
+				if !ok {
+					return nil, fmt.Errorf("avro: enum %s has a non-string symbol", name)
+				}
+				t.symbols = append(t.symbols, str)
+			}
+			if len(t.symbols) == 0 {
+				return nil, fmt.Errorf("avro: enum %s has no symbols", name)
+			}
+			p.named[name] = t
+			return t, nil
+		case "fixed":
+			name, _, err := p.define(s, ns)
+			if err != nil {
+				return nil, err
+			}
+			size, ok := s["size"].(float64)
+			if !ok || size < 0 || size > maxSchemaSize || size != math.Trunc(size) {
+				return nil, fmt.Errorf("avro: fixed %s has an invalid size", name)
+			}
+			t := &avroType{kind: "fixed", name: name, size: int(size)}
+			p.named[name] = t
+			return t, nil
+		case "array", "map":
+			key := "items"
+			if tname == "map" {
+				key = "values"
+			}
+			items, err := p.parse(s[key], ns, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			return &avroType{kind: tname, items: items}, nil
+		default:
+			return p.parse(tname, ns, depth+1)
+		}
+	default:
+		return nil, fmt.Errorf("avro: invalid schema %v", v)
+	}
+}
+
+// define returns the full name of a named type and the namespace for its
+// children. Redefining a name is an error.
+func (p *avroParser) define(s map[string]interface{}, ns string) (string, string, error) {
+	name, _ := s["name"].(string)
+	if name == "" {
+		return "", "", errors.New("avro: named type without a name")
+	}
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		ns = name[:i]
+	} else {
+		if n, ok := s["namespace"].(string); ok {
+			ns = n
+		}
+		if ns != "" {
+			name = ns + "." + name
+		}
+	}
+	if _, dup := p.named[name]; dup {
+		return "", "", fmt.Errorf("avro: type %s defined twice", name)
+	}
+	return name, ns, nil
+}
+
+func (p *avroParser) lookup(name, ns string) *avroType {
+	if !strings.Contains(name, ".") && ns != "" {
+		if t, ok := p.named[ns+"."+name]; ok {
+			return t
+		}
+	}
+	return p.named[name]
+}
+
+// avroDecoder decodes Avro binary data into JSON-compatible values.
+type avroDecoder struct {
+	buf   []byte
+	pos   int
+	items int
+}
+
+// decodeAvro decodes a complete Avro binary datum. Unions decode to the
+// value of their branch, bytes and fixed to base64 strings (via []byte),
+// enums to their symbol, and non-finite floats to null.
+func decodeAvro(t *avroType, data []byte) (interface{}, error) {
+	d := &avroDecoder{buf: data}
+	v, err := d.decode(t, 0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.buf) {
+		return nil, fmt.Errorf("avro: %d trailing bytes", len(d.buf)-d.pos)
+	}
+	return v, nil
+}
+
+func (d *avroDecoder) long() (int64, error) {
+	var u uint64
+	var shift uint
+	for i := 0; i < 10; i++ {
+		if d.pos >= len(d.buf) {
+			return 0, errAvroShort
+		}
+		b := d.buf[d.pos]
+		d.pos++
+		u |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return int64(u>>1) ^ -int64(u&1), nil
+		}
+		shift += 7
+	}
+	return 0, errors.New("avro: varint overflows 64 bits")
+}
+
+func (d *avroDecoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(d.buf)-d.pos {
+		return nil, errAvroShort
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// length reads a bytes/string length, which must fit in the remaining data.
+func (d *avroDecoder) length() (int, error) {
+	n, err := d.long()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > int64(len(d.buf)-d.pos) {
+		return 0, fmt.Errorf("avro: invalid length %d", n)
+	}
+	return int(n), nil
+}
+
+// blockCount reads the element count of the next array or map block.
+func (d *avroDecoder) blockCount() (int, error) {
+	n, err := d.long()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		if n == math.MinInt64 {
+			return 0, errors.New("avro: invalid block count")
+		}
+		n = -n
+		// A negative count is followed by the block size in bytes.
+		if size, err := d.long(); err != nil {
+			return 0, err
+		} else if size < 0 {
+			return 0, errors.New("avro: invalid block size")
+		}
+	}
+	if n > int64(maxAvroItems-d.items) {
+		return 0, fmt.Errorf("avro: more than %d collection elements", maxAvroItems)
+	}
+	d.items += int(n)
+	return int(n), nil
+}
+
+func finite(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return f
+}
+
+func (d *avroDecoder) decode(t *avroType, depth int) (interface{}, error) {
+	if depth > maxAvroDepth {
+		return nil, errors.New("avro: data nested too deeply")
+	}
+	switch t.kind {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] > 1 {
+			return nil, fmt.Errorf("avro: invalid boolean byte %d", b[0])
+		}
+		return b[0] == 1, nil
+	case "int":
+		n, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("avro: int %d out of range", n)
+		}
+		return n, nil
+	case "long":
+		return d.long()
+	case "float":
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return finite(float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))), nil
+	case "double":
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return finite(math.Float64frombits(binary.LittleEndian.Uint64(b))), nil
+	case "bytes", "string":
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		b, _ := d.next(n)
+		if t.kind == "string" {
+			return string(b), nil
+		}
+		return append([]byte(nil), b...), nil
+	case "fixed":
+		b, err := d.next(t.size)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case "enum":
+		i, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(t.symbols)) {
+			return nil, fmt.Errorf("avro: enum %s index %d out of range", t.name, i)
+		}
+		return t.symbols[i], nil
+	case "union":
+		i, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(t.branches)) {
+			return nil, fmt.Errorf("avro: union index %d out of range", i)
+		}
+		return d.decode(t.branches[i], depth+1)
+	case "record":
+		m := make(map[string]interface{}, len(t.fields))
+		for _, f := range t.fields {
+			v, err := d.decode(f.typ, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			m[f.name] = v
+		}
+		return m, nil
+	case "array":
+		arr := []interface{}{}
+		for {
+			n, err := d.blockCount()
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				return arr, nil
+			}
+			for i := 0; i < n; i++ {
+				v, err := d.decode(t.items, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+		}
+	case "map":
+		m := make(map[string]interface{})
+		for {
+			n, err := d.blockCount()
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				return m, nil
+			}
+			for i := 0; i < n; i++ {
+				kl, err := d.length()
+				if err != nil {
+					return nil, err
+				}
+				k, _ := d.next(kl)
+				v, err := d.decode(t.items, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				m[string(k)] = v
+			}
+		}
+	default:
+		return nil, fmt.Errorf("avro: unsupported type %s", t.kind)
+	}
+}
+
+// avroRegistry fetches writer schemas by ID from a Confluent-style schema
+// registry. Schema IDs are immutable, so schemas are cached until evicted.
+type avroRegistry struct {
+	url    string
+	client *http.Client
+	cache  *lru.Cache[uint32, *avroType]
+	group  singleflight.Group
+}
+
+func (r *avroRegistry) schema(id uint32) (*avroType, error) {
+	if t, ok := r.cache.Get(id); ok {
+		return t, nil
+	}
+	v, err, _ := r.group.Do(strconv.FormatUint(uint64(id), 10), func() (interface{}, error) {
+		resp, err := r.client.Get(r.url + "/schemas/ids/" + strconv.FormatUint(uint64(id), 10))
+		if err != nil {
+			return nil, fmt.Errorf("schema registry: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("schema registry returned %d", resp.StatusCode)
+		}
+		var body struct {
+			Schema     string `json:"schema"`
+			SchemaType string `json:"schemaType"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxSchemaSize)).Decode(&body); err != nil {
+			return nil, fmt.Errorf("schema registry: invalid response: %w", err)
+		}
+		if body.SchemaType != "" && body.SchemaType != "AVRO" {
+			return nil, fmt.Errorf("schema registry: schema type %s is not AVRO", body.SchemaType)
+		}
+		t, err := parseAvroSchema([]byte(body.Schema))
+		if err != nil {
+			return nil, err
+		}
+		r.cache.Add(id, t)
+		return t, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*avroType), nil
+}
+
+// avroCodec decodes Avro bodies with a fixed schema or with schemas from a
+// registry named by the wire format header.
+type avroCodec struct {
+	schema   *avroType
+	registry *avroRegistry
+}
+
+func newAvroCodec(cfg config.AvroSchemaConfig) (*avroCodec, error) {
+	if cfg.SchemaFile != "" {
+		f, err := os.Open(cfg.SchemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("avro schema: %w", err)
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, maxSchemaSize))
+		if err != nil {
+			return nil, fmt.Errorf("avro schema: %w", err)
+		}
+		t, err := parseAvroSchema(data)
+		if err != nil {
+			return nil, fmt.Errorf("avro schema %s: %w", cfg.SchemaFile, err)
+		}
+		return &avroCodec{schema: t}, nil
+	}
+	if cfg.RegistryURL == "" {
+		return nil, errors.New("avro requires schema_file or registry_url")
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	size := cfg.CacheSize
+	if size <= 0 {
+		size = 1000
+	}
+	cache, err := lru.New[uint32, *avroType](size)
+	if err != nil {
+		return nil, err
+	}
+	return &avroCodec{registry: &avroRegistry{
+		url:    strings.TrimRight(cfg.RegistryURL, "/"),
+		client: &http.Client{Timeout: timeout},
+		cache:  cache,
+	}}, nil
+}
+
+// decode converts an Avro body to JSON. With a registry, the body must start
+// with the wire format header: a zero magic byte and a big-endian schema ID.
+// The schema ID is returned whenever the header could be read.
+func (c *avroCodec) decode(data []byte) ([]byte, *uint32, error) {
+	schema := c.schema
+	var id *uint32
+	if c.registry != nil {
+		if len(data) < 5 || data[0] != 0 {
+			return nil, nil, errors.New("avro: missing schema registry wire format header")
+		}
+		sid := binary.BigEndian.Uint32(data[1:5])
+		id = &sid
+		t, err := c.registry.schema(sid)
+		if err != nil {
+			return nil, id, err
+		}
+		schema, data = t, data[5:]
+	}
+	v, err := decodeAvro(schema, data)
+	if err != nil {
+		return nil, id, err
+	}
+	out, err := json.Marshal(v)
+	return out, id, err
+}
+
+// cachedSchemas returns the number of registry schemas held in the cache.
+func (c *avroCodec) cachedSchemas() int {
+	if c.registry == nil {
+		return 0
+	}
+	return c.registry.cache.Len()
+}
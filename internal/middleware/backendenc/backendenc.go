@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
@@ -16,27 +17,48 @@ import (
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/xmlsafe"
 )
 
+// defaultMaxBodySize is the largest cbor or avro body decoded when
+// max_body_size is not set.
+const defaultMaxBodySize = 10 << 20
+
 // Encoder decodes backend responses from a non-JSON format to JSON.
 type Encoder struct {
-	encoding string
-	encoded  atomic.Int64
-	errors   atomic.Int64
+	encoding    string
+	maxBodySize int64
+	avro        *avroCodec
+	encoded     atomic.Int64
+	errors      atomic.Int64
 }
 
 // Snapshot is a point-in-time copy of encoder metrics.
 type Snapshot struct {
-	Encoding string `json:"encoding"`
-	Encoded  int64  `json:"encoded"`
-	Errors   int64  `json:"errors"`
+	Encoding      string `json:"encoding"`
+	Encoded       int64  `json:"encoded"`
+	Errors        int64  `json:"errors"`
+	SchemasCached int    `json:"schemas_cached,omitempty"`
 }
 
-// New creates an Encoder for the given encoding type.
-func New(cfg config.BackendEncodingConfig) *Encoder {
-	return &Encoder{
-		encoding: cfg.Encoding,
+// New creates an Encoder for the given encoding type. For avro, the schema
+// file is read here; registry schemas are fetched on first use.
+func New(cfg config.BackendEncodingConfig) (*Encoder, error) {
+	e := &Encoder{
+		encoding:    cfg.Encoding,
+		maxBodySize: cfg.MaxBodySize,
+	}
+	if e.maxBodySize <= 0 {
+		e.maxBodySize = defaultMaxBodySize
+	}
+	if cfg.Encoding == "avro" {
+		codec, err := newAvroCodec(cfg.Avro)
+		if err != nil {
+			return nil, err
+		}
+		e.avro = codec
 	}
+	return e, nil
 }
 
 // Encoding returns the configured encoding type.
@@ -94,11 +116,74 @@ func (e *Encoder) Decode(data []byte, contentType string) ([]byte, bool) {
 		}
 		e.encoded.Add(1)
 		return result, true
+	case "cbor", "avro":
+		if !e.accepts(contentType) {
+			return data, false
+		}
+		result, _, err := e.decodeBinary(data)
+		if err != nil {
+			e.errors.Add(1)
+			return data, false
+		}
+		e.encoded.Add(1)
+		return result, true
 	default:
 		return data, false
 	}
 }
 
+// binary reports whether the encoding is a binary codec. Binary bodies are
+// size-limited, and a body that fails to decode is answered with a 502
+// instead of being passed through.
+func (e *Encoder) binary() bool {
+	return e.encoding == "cbor" || e.encoding == "avro"
+}
+
+// accepts reports whether a binary codec should decode a body with the given
+// content type. CBOR requires a cbor content type; Avro bodies are usually
+// sent as application/octet-stream, so anything but JSON or text is decoded.
+func (e *Encoder) accepts(contentType string) bool {
+	if e.encoding == "cbor" {
+		return strings.Contains(contentType, "cbor")
+	}
+	return !strings.Contains(contentType, "json") && !strings.HasPrefix(contentType, "text/")
+}
+
+// decodeBinary decodes a cbor or avro body, returning the Avro schema ID
+// when the body named one.
+func (e *Encoder) decodeBinary(data []byte) ([]byte, *uint32, error) {
+	if e.encoding == "avro" {
+		return e.avro.decode(data)
+	}
+	result, err := cborToJSON(data)
+	return result, nil, err
+}
+
+// decodeError is the 502 body sent when a binary backend response cannot be
+// decoded.
+type decodeError struct {
+	Code     int     `json:"code"`
+	Message  string  `json:"message"`
+	Encoding string  `json:"encoding"`
+	SchemaID *uint32 `json:"schema_id,omitempty"`
+	Details  string  `json:"details"`
+}
+
+func (e *Encoder) writeDecodeError(w http.ResponseWriter, schemaID *uint32, details string) {
+	e.errors.Add(1)
+	body, _ := json.Marshal(decodeError{
+		Code:     http.StatusBadGateway,
+		Message:  "Bad Gateway",
+		Encoding: e.encoding,
+		SchemaID: schemaID,
+		Details:  details,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusBadGateway)
+	w.Write(body)
+}
+
 // DecodeBytes is a standalone function for decoding bytes with a named encoding.
 // Used by aggregate/sequential for per-backend mixed encodings.
 func DecodeBytes(data []byte, encoding string) ([]byte, error) {
@@ -113,6 +198,8 @@ func DecodeBytes(data []byte, encoding string) ([]byte, error) {
 		return rssDecode(data)
 	case "string":
 		return stringDecode(data)
+	case "cbor":
+		return cborToJSON(data)
 	default:
 		return data, nil
 	}
@@ -136,8 +223,14 @@ func safeJSONDecode(data []byte) ([]byte, error) {
 	}
 }
 
-// rssDecode parses RSS/Atom/JSON Feed and converts to JSON.
+// rssDecode parses RSS/Atom/JSON Feed and converts to JSON. XML feeds are
+// checked against the global xml_limits before the feed parser sees them.
 func rssDecode(data []byte) ([]byte, error) {
+	if b := bytes.TrimSpace(data); len(b) > 0 && b[0] == '<' {
+		if err := xmlsafe.Check(data); err != nil {
+			return nil, err
+		}
+	}
 	fp := gofeed.NewParser()
 	feed, err := fp.Parse(bytes.NewReader(data))
 	if err != nil {
@@ -153,16 +246,24 @@ func stringDecode(data []byte) ([]byte, error) {
 
 // Stats returns a snapshot of encoder metrics.
 func (e *Encoder) Stats() Snapshot {
-	return Snapshot{
+	s := Snapshot{
 		Encoding: e.encoding,
 		Encoded:  e.encoded.Load(),
 		Errors:   e.errors.Load(),
 	}
+	if e.avro != nil {
+		s.SchemasCached = e.avro.cachedSchemas()
+	}
+	return s
 }
 
-// xmlToJSON converts XML bytes to JSON.
+// xmlToJSON converts XML bytes to JSON. The document is checked against
+// the global xml_limits first.
 func xmlToJSON(data []byte) ([]byte, error) {
-	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder, err := xmlsafe.NewDecoder(data)
+	if err != nil {
+		return nil, err
+	}
 	result, err := decodeXMLElement(decoder)
 	if err != nil {
 		return nil, err
@@ -320,10 +421,10 @@ type EncoderByRoute = byroute.Factory[*Encoder, config.BackendEncodingConfig]
 
 // NewEncoderByRoute creates a new encoder manager.
 func NewEncoderByRoute() *EncoderByRoute {
-	return byroute.SimpleFactory(New, func(e *Encoder) any { return e.Stats() })
+	return byroute.NewFactory(New, func(e *Encoder) any { return e.Stats() })
 }
 
-// Middleware returns a middleware that decodes XML/YAML backend responses to JSON.
+// Middleware returns a middleware that decodes XML/YAML/CBOR/Avro backend responses to JSON.
 func (enc *Encoder) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -332,13 +433,32 @@ func (enc *Encoder) Middleware() middleware.Middleware {
 				header:         make(http.Header),
 				statusCode:     200,
 			}
+			if enc.binary() {
+				bw.limit = enc.maxBodySize
+			}
 			next.ServeHTTP(bw, r)
 
 			body := bw.body.Bytes()
 			ct := bw.header.Get("Content-Type")
 
-			decoded, ok := enc.Decode(body, ct)
-			if ok {
+			if enc.binary() {
+				// Only successful responses are decoded; backend error
+				// bodies pass through unchanged.
+				if bw.overflow {
+					enc.writeDecodeError(w, nil, fmt.Sprintf("backend response exceeds max_body_size of %d bytes", enc.maxBodySize))
+					return
+				}
+				if bw.statusCode/100 == 2 && len(body) > 0 && enc.accepts(ct) {
+					decoded, schemaID, err := enc.decodeBinary(body)
+					if err != nil {
+						enc.writeDecodeError(w, schemaID, err.Error())
+						return
+					}
+					enc.encoded.Add(1)
+					bw.header.Set("Content-Type", "application/json")
+					body = decoded
+				}
+			} else if decoded, ok := enc.Decode(body, ct); ok {
 				bw.header.Set("Content-Type", "application/json")
 				body = decoded
 			}
@@ -360,8 +480,22 @@ type backendEncWriter struct {
 	statusCode int
 	body       bytes.Buffer
 	header     http.Header
+	limit      int64 // max buffered 2xx body; 0 = unlimited
+	overflow   bool
 }
 
 func (w *backendEncWriter) Header() http.Header { return w.header }
 func (w *backendEncWriter) WriteHeader(code int) { w.statusCode = code }
-func (w *backendEncWriter) Write(b []byte) (int, error) { return w.body.Write(b) }
+func (w *backendEncWriter) Write(b []byte) (int, error) {
+	if w.limit > 0 && w.statusCode/100 == 2 && int64(w.body.Len()+len(b)) > w.limit {
+		// Drop the body but keep the backend writing so it is not
+		// stalled; the response is replaced with a 502.
+		w.overflow = true
+		w.body.Reset()
+		return len(b), nil
+	}
+	if w.overflow {
+		return len(b), nil
+	}
+	return w.body.Write(b)
+}
@@ -1,14 +1,24 @@
 package backendenc
 
 import (
+	"bytes"
 	"encoding/json"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/wudi/runway/config"
 )
 
 func TestXMLToJSON_Simple(t *testing.T) {
-	enc := New(config.BackendEncodingConfig{Encoding: "xml"})
+	enc, _ := New(config.BackendEncodingConfig{Encoding: "xml"})
 
 	xmlData := []byte(`<response><name>alice</name><age>30</age></response>`)
 	result, ok := enc.Decode(xmlData, "application/xml")
@@ -31,7 +41,7 @@ func TestXMLToJSON_Simple(t *testing.T) {
 }
 
 func TestXMLToJSON_NestedObjects(t *testing.T) {
-	enc := New(config.BackendEncodingConfig{Encoding: "xml"})
+	enc, _ := New(config.BackendEncodingConfig{Encoding: "xml"})
 
 	xmlData := []byte(`<response><user><name>alice</name><email>a@b.com</email></user></response>`)
 	result, ok := enc.Decode(xmlData, "text/xml")
@@ -54,7 +64,7 @@ func TestXMLToJSON_NestedObjects(t *testing.T) {
 }
 
 func TestXMLToJSON_Arrays(t *testing.T) {
-	enc := New(config.BackendEncodingConfig{Encoding: "xml"})
+	enc, _ := New(config.BackendEncodingConfig{Encoding: "xml"})
 
 	// Repeated elements become arrays
 	xmlData := []byte(`<response><item>a</item><item>b</item><item>c</item></response>`)
@@ -81,7 +91,7 @@ func TestXMLToJSON_Arrays(t *testing.T) {
 }
 
 func TestXMLToJSON_Attributes(t *testing.T) {
-	enc := New(config.BackendEncodingConfig{Encoding: "xml"})
+	enc, _ := New(config.BackendEncodingConfig{Encoding: "xml"})
 
 	xmlData := []byte(`<item id="42" active="true"><name>widget</name></item>`)
 	result, ok := enc.Decode(xmlData, "application/xml")
@@ -107,7 +117,7 @@ func TestXMLToJSON_Attributes(t *testing.T) {
 }
 
 func TestXMLToJSON_EmptyElement(t *testing.T) {
-	enc := New(config.BackendEncodingConfig{Encoding: "xml"})
+	enc, _ := New(config.BackendEncodingConfig{Encoding: "xml"})
 
 	xmlData := []byte(`<response><empty></empty><name>test</name></response>`)
 	result, ok := enc.Decode(xmlData, "application/xml")
@@ -126,7 +136,7 @@ func TestXMLToJSON_EmptyElement(t *testing.T) {
 }
 
 func TestYAMLToJSON(t *testing.T) {
-	enc := New(config.BackendEncodingConfig{Encoding: "yaml"})
+	enc, _ := New(config.BackendEncodingConfig{Encoding: "yaml"})
 
 	yamlData := []byte("name: alice\nage: 30\nitems:\n  - one\n  - two\n")
 	result, ok := enc.Decode(yamlData, "application/x-yaml")
@@ -153,7 +163,7 @@ func TestYAMLToJSON(t *testing.T) {
 }
 
 func TestDecodingError_Passthrough(t *testing.T) {
-	enc := New(config.BackendEncodingConfig{Encoding: "xml"})
+	enc, _ := New(config.BackendEncodingConfig{Encoding: "xml"})
 
 	invalidXML := []byte(`not valid xml at all`)
 	result, ok := enc.Decode(invalidXML, "application/xml")
@@ -173,8 +183,31 @@ func TestDecodingError_Passthrough(t *testing.T) {
 	}
 }
 
+func TestXMLToJSON_RejectsEntityAttacks(t *testing.T) {
+	enc, _ := New(config.BackendEncodingConfig{Encoding: "xml"})
+
+	payloads := map[string]string{
+		"billion laughs": `<!DOCTYPE r [<!ENTITY a "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa">` +
+			`<!ENTITY b "&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;">` +
+			`<!ENTITY c "&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;">` +
+			`<!ENTITY d "&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;">]><r>&d;</r>`,
+		"xxe": `<!DOCTYPE r [<!ENTITY x SYSTEM "file:///etc/passwd">]><r>&x;</r>`,
+	}
+	for name, p := range payloads {
+		if _, ok := enc.Decode([]byte(p), "application/xml"); ok {
+			t.Errorf("%s: expected decode to be refused", name)
+		}
+	}
+
+	// Internal entities within the limits still expand.
+	result, ok := enc.Decode([]byte(`<!DOCTYPE r [<!ENTITY n "alice">]><r><name>&n;</name></r>`), "application/xml")
+	if !ok || !strings.Contains(string(result), `"alice"`) {
+		t.Errorf("expected entity to expand, got %s", result)
+	}
+}
+
 func TestWrongContentType(t *testing.T) {
-	enc := New(config.BackendEncodingConfig{Encoding: "xml"})
+	enc, _ := New(config.BackendEncodingConfig{Encoding: "xml"})
 
 	// JSON content type should not be decoded
 	data := []byte(`{"already":"json"}`)
@@ -220,7 +253,7 @@ func TestByRoute(t *testing.T) {
 }
 
 func TestStats(t *testing.T) {
-	enc := New(config.BackendEncodingConfig{Encoding: "xml"})
+	enc, _ := New(config.BackendEncodingConfig{Encoding: "xml"})
 
 	xmlData := []byte(`<root><key>value</key></root>`)
 	enc.Decode(xmlData, "application/xml")
@@ -234,3 +267,227 @@ func TestStats(t *testing.T) {
 		t.Errorf("expected encoded=2, got %d", s.Encoded)
 	}
 }
+
+// zigzag appends an Avro long.
+func zigzag(b []byte, n int64) []byte {
+	u := uint64(n<<1) ^ uint64(n>>63)
+	for u >= 0x80 {
+		b = append(b, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(b, byte(u))
+}
+
+func avroString(b []byte, s string) []byte {
+	return append(zigzag(b, int64(len(s))), s...)
+}
+
+const userSchema = `{
+  "type": "record", "name": "User", "namespace": "events",
+  "fields": [
+    {"name": "id", "type": "long"},
+    {"name": "name", "type": "string"},
+    {"name": "email", "type": ["null", "string"]},
+    {"name": "role", "type": {"type": "enum", "name": "Role", "symbols": ["ADMIN", "USER"]}},
+    {"name": "tags", "type": {"type": "array", "items": "string"}},
+    {"name": "manager", "type": ["null", "User"]}
+  ]
+}`
+
+// userDatum encodes {id: 42, name: alice, email: a@b.c, role: USER, tags: [x], manager: null}.
+func userDatum() []byte {
+	b := zigzag(nil, 42)
+	b = avroString(b, "alice")
+	b = avroString(zigzag(b, 1), "a@b.c")
+	b = zigzag(b, 1)
+	b = avroString(zigzag(b, 1), "x")
+	b = zigzag(b, 0)
+	return zigzag(b, 0)
+}
+
+func serveBackend(enc *Encoder, status int, contentType string, body []byte) *httptest.ResponseRecorder {
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.Write(body)
+	})
+	w := httptest.NewRecorder()
+	enc.Middleware()(backend).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	return w
+}
+
+func TestAvro_SchemaFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user.avsc")
+	if err := os.WriteFile(path, []byte(userSchema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := New(config.BackendEncodingConfig{Encoding: "avro", Avro: config.AvroSchemaConfig{SchemaFile: path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serveBackend(enc, 200, "application/octet-stream", userDatum())
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected decoded JSON, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	want := `{"email":"a@b.c","id":42,"manager":null,"name":"alice","role":"USER","tags":["x"]}`
+	if w.Body.String() != want {
+		t.Errorf("expected %s, got %s", want, w.Body.String())
+	}
+
+	// Backend error bodies are not decoded.
+	if w := serveBackend(enc, 500, "text/plain", []byte("boom")); w.Code != 500 || w.Body.String() != "boom" {
+		t.Errorf("expected the error body to pass through, got %d %q", w.Code, w.Body.String())
+	}
+
+	if _, err := New(config.BackendEncodingConfig{Encoding: "avro", Avro: config.AvroSchemaConfig{SchemaFile: filepath.Join(t.TempDir(), "missing.avsc")}}); err == nil {
+		t.Error("expected an error for a missing schema file")
+	}
+}
+
+func TestAvro_Registry(t *testing.T) {
+	var fetches atomic.Int32
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if r.URL.Path != "/schemas/ids/7" {
+			http.Error(w, `{"error_code":40403,"message":"Schema not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"schema": userSchema})
+	}))
+	defer registry.Close()
+
+	enc, err := New(config.BackendEncodingConfig{Encoding: "avro", Avro: config.AvroSchemaConfig{RegistryURL: registry.URL + "/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := append([]byte{0, 0, 0, 0, 7}, userDatum()...)
+	for i := 0; i < 2; i++ {
+		if w := serveBackend(enc, 200, "application/vnd.kafka.avro.v2", body); w.Code != 200 || !strings.Contains(w.Body.String(), `"name":"alice"`) {
+			t.Fatalf("expected decoded JSON, got %d %s", w.Code, w.Body.String())
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("expected the schema to be fetched once, got %d", n)
+	}
+
+	w := serveBackend(enc, 200, "application/octet-stream", append([]byte{0, 0, 0, 0, 9}, userDatum()...))
+	var derr decodeError
+	if err := json.Unmarshal(w.Body.Bytes(), &derr); err != nil {
+		t.Fatalf("expected a JSON error body, got %q", w.Body.String())
+	}
+	if w.Code != http.StatusBadGateway || derr.SchemaID == nil || *derr.SchemaID != 9 || derr.Encoding != "avro" {
+		t.Errorf("expected a 502 naming schema 9, got %d %+v", w.Code, derr)
+	}
+
+	if w := serveBackend(enc, 200, "application/octet-stream", userDatum()); w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "wire format header") {
+		t.Errorf("expected a 502 for a body without the wire header, got %d %s", w.Code, w.Body.String())
+	}
+
+	s := enc.Stats()
+	if s.Encoded != 2 || s.Errors != 2 || s.SchemasCached != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestAvro_MalformedInputs(t *testing.T) {
+	schema, err := parseAvroSchema([]byte(userSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := userDatum()
+
+	cases := map[string][]byte{
+		"empty":             nil,
+		"truncated":         valid[:len(valid)-3],
+		"trailing bytes":    append(append([]byte{}, valid...), 0),
+		"varint overflow":   bytes.Repeat([]byte{0xff}, 11),
+		"huge string":       zigzag(zigzag(nil, 1), 1<<40),
+		"negative string":   zigzag(zigzag(nil, 1), -5),
+		"bad union index":   append(avroString(zigzag(nil, 1), "a"), zigzag(nil, 5)...),
+		"min block count":   append(zigzag(avroString(zigzag(avroString(zigzag(nil, 1), "a"), 0), ""), 0), zigzag(nil, math.MinInt64)...),
+		"too many elements": append(zigzag(avroString(zigzag(avroString(zigzag(nil, 1), "a"), 0), ""), 0), zigzag(nil, maxAvroItems+1)...),
+	}
+	for name, data := range cases {
+		if _, err := decodeAvro(schema, data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// A long array of zero-width elements is bounded by the element budget.
+	nulls, _ := parseAvroSchema([]byte(`{"type":"array","items":"null"}`))
+	if _, err := decodeAvro(nulls, zigzag(nil, 1<<40)); err == nil {
+		t.Error("expected the element budget to reject a huge null array")
+	}
+
+	// Random corruptions of a valid datum must never panic.
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		data := append([]byte{}, valid[:rng.Intn(len(valid)+1)]...)
+		for j := rng.Intn(4); j >= 0 && len(data) > 0; j-- {
+			data[rng.Intn(len(data))] = byte(rng.Intn(256))
+		}
+		decodeAvro(schema, data)
+	}
+
+	for _, bad := range []string{`{"type":"record","name":"A","fields":[{"name":"b","type":"B"}]}`, `[]`, `[["int"]]`, `{"type":"enum","name":"E","symbols":[]}`, `{"type":"fixed","name":"F","size":-1}`, `not json`} {
+		if _, err := parseAvroSchema([]byte(bad)); err == nil {
+			t.Errorf("expected schema %s to be rejected", bad)
+		}
+	}
+}
+
+func TestCBOR_Decode(t *testing.T) {
+	enc, _ := New(config.BackendEncodingConfig{Encoding: "cbor", MaxBodySize: 64})
+
+	body, _ := cbor.Marshal(map[interface{}]interface{}{"name": "alice", 1: []byte{0xde, 0xad}, "n": -3})
+	w := serveBackend(enc, 200, "application/cbor", body)
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected decoded JSON, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if want := `{"1":"3q0=","n":-3,"name":"alice"}`; w.Body.String() != want {
+		t.Errorf("expected %s, got %s", want, w.Body.String())
+	}
+
+	w = serveBackend(enc, 200, "application/cbor", []byte{0xa1, 0x61})
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), `"encoding":"cbor"`) {
+		t.Errorf("expected a 502 for malformed CBOR, got %d %s", w.Code, w.Body.String())
+	}
+
+	big, _ := cbor.Marshal(strings.Repeat("x", 100))
+	if w := serveBackend(enc, 200, "application/cbor", big); w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "max_body_size") {
+		t.Errorf("expected a 502 for an oversized body, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := serveBackend(enc, 200, "application/json", []byte(`{"a":1}`)); w.Body.String() != `{"a":1}` {
+		t.Errorf("expected JSON to pass through, got %s", w.Body.String())
+	}
+}
+
+func TestCBOR_MalformedInputs(t *testing.T) {
+	cases := [][]byte{
+		{0x9f}, // unterminated indefinite array
+		{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, // byte string longer than the data
+		{0xf9, 0x7e, 0x00},                         // NaN
+		{0xa2, 0x61, 0x61, 0x01, 0x61, 0x61, 0x02}, // duplicate map key
+		{0xa1, 0xa0, 0x01},                         // map key that is a map
+		{0x01, 0x02},                               // trailing data
+		bytes.Repeat([]byte{0x81}, 100),            // nested too deeply
+	}
+	for _, data := range cases {
+		if _, err := cborToJSON(data); err == nil {
+			t.Errorf("expected % x to be rejected", data)
+		}
+	}
+
+	valid, _ := cbor.Marshal(map[string]interface{}{"a": []interface{}{1, "two", 3.5, nil, true}, "b": map[string]interface{}{"c": []byte("d")}})
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		data := append([]byte{}, valid[:rng.Intn(len(valid)+1)]...)
+		for j := rng.Intn(4); j >= 0 && len(data) > 0; j-- {
+			data[rng.Intn(len(data))] = byte(rng.Intn(256))
+		}
+		cborToJSON(data)
+	}
+}
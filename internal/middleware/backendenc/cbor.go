@@ -0,0 +1,86 @@
+package backendenc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// cborDecMode rejects input that cannot be represented as JSON and bounds
+// nesting and collection sizes so malformed bodies cannot exhaust memory.
+var cborDecMode = func() cbor.DecMode {
+	dm, err := cbor.DecOptions{
+		MaxNestedLevels:  32,
+		MaxArrayElements: 131072,
+		MaxMapPairs:      131072,
+		DupMapKey:        cbor.DupMapKeyEnforcedAPF,
+		BigIntDec:        cbor.BigIntDecodePointer,
+		NaN:              cbor.NaNDecodeForbidden,
+		Inf:              cbor.InfDecodeForbidden,
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return dm
+}()
+
+// cborToJSON converts a single CBOR data item to JSON. Byte strings become
+// base64 strings, tagged values their content, and integer or boolean map
+// keys their string form.
+func cborToJSON(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := cborDecMode.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	n, err := normalizeCBOR(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(n)
+}
+
+// normalizeCBOR rewrites decoded CBOR values into types encoding/json accepts.
+func normalizeCBOR(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			var key string
+			switch kv := k.(type) {
+			case string:
+				key = kv
+			case uint64:
+				key = strconv.FormatUint(kv, 10)
+			case int64:
+				key = strconv.FormatInt(kv, 10)
+			case bool:
+				key = strconv.FormatBool(kv)
+			default:
+				return nil, fmt.Errorf("cbor: unsupported map key type %T", k)
+			}
+			n, err := normalizeCBOR(item)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = n
+		}
+		return m, nil
+	case []interface{}:
+		for i, item := range val {
+			n, err := normalizeCBOR(item)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = n
+		}
+		return val, nil
+	case cbor.Tag:
+		return normalizeCBOR(val.Content)
+	case cbor.SimpleValue:
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", val)
+	default:
+		return v, nil
+	}
+}
@@ -2,7 +2,11 @@ package baggage
 
 import (
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"unicode"
 
 	"go.opentelemetry.io/otel/baggage"
 
@@ -13,12 +17,31 @@ import (
 	"github.com/wudi/runway/variables"
 )
 
+const (
+	// DefaultMaxMembers is the W3C Baggage member limit.
+	DefaultMaxMembers = 64
+	// DefaultMaxBytes is the W3C Baggage serialized size limit.
+	DefaultMaxBytes = 8192
+	// TruncatedHeader is set on the response with the number of baggage
+	// members dropped to satisfy the limits.
+	TruncatedHeader = "X-Baggage-Truncated"
+)
+
 // compiledTag is a pre-compiled baggage tag definition.
 type compiledTag struct {
-	name    string
-	header  string       // custom backend header; empty = W3C-only tag
-	w3cKey  string       // resolved W3C baggage key; empty if w3c_baggage disabled
-	extract extract.Func // value extractor
+	name     string
+	header   string       // custom backend header; empty = W3C-only tag
+	key      string       // baggage namespace key: baggage_key, else name
+	w3c      bool         // emit as a W3C baggage member
+	priority int          // truncation priority
+	extract  extract.Func // value extractor
+}
+
+// entry is a baggage member candidate for the outbound header.
+type entry struct {
+	member   baggage.Member
+	priority int
+	local    bool
 }
 
 // Propagator extracts and propagates baggage tags for a single route.
@@ -26,7 +49,15 @@ type Propagator struct {
 	tags           []compiledTag
 	propagateTrace bool
 	w3cBaggage     bool
-	propagated     atomic.Int64
+	maxMembers     int
+	maxBytes       int
+	inboundWins    bool
+
+	propagated atomic.Int64
+	truncated  atomic.Int64
+	dropped    atomic.Int64
+	invalid    atomic.Int64
+	conflicts  atomic.Int64
 }
 
 // New creates a Propagator from config.
@@ -34,22 +65,35 @@ func New(cfg config.BaggageConfig) (*Propagator, error) {
 	tags := make([]compiledTag, 0, len(cfg.Tags))
 	for _, td := range cfg.Tags {
 		ct := compiledTag{
-			name:    td.Name,
-			header:  td.Header,
-			extract: extract.Build(td.Source),
+			name:     td.Name,
+			header:   td.Header,
+			key:      td.BaggageKey,
+			w3c:      cfg.W3CBaggage,
+			priority: td.Priority,
+			extract:  extract.Build(td.Source),
 		}
-		if cfg.W3CBaggage {
-			ct.w3cKey = td.BaggageKey
-			if ct.w3cKey == "" {
-				ct.w3cKey = td.Name
-			}
+		if ct.key == "" {
+			ct.key = td.Name
 		}
 		tags = append(tags, ct)
 	}
+
+	maxMembers := cfg.MaxMembers
+	if maxMembers <= 0 {
+		maxMembers = DefaultMaxMembers
+	}
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+
 	return &Propagator{
 		tags:           tags,
 		propagateTrace: cfg.PropagateTrace,
 		w3cBaggage:     cfg.W3CBaggage,
+		maxMembers:     maxMembers,
+		maxBytes:       maxBytes,
+		inboundWins:    cfg.ConflictPolicy == "inbound",
 	}, nil
 }
 
@@ -59,37 +103,88 @@ func (p *Propagator) Middleware() middleware.Middleware {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			vc := variables.GetFromRequest(r)
 
-			// Build W3C baggage from existing upstream context
-			var bag baggage.Baggage
-			if p.w3cBaggage {
-				bag = baggage.FromContext(r.Context())
+			entries := p.inbound(r)
+			byKey := make(map[string]int, len(entries))
+			for i, e := range entries {
+				byKey[e.member.Key()] = i
 			}
 
-			for _, tag := range p.tags {
-				val := tag.extract(r)
+			values := make([]string, len(p.tags))
+			for i, tag := range p.tags {
+				val := sanitizeValue(tag.extract(r))
 				if val == "" {
 					continue
 				}
+
+				idx, conflict := byKey[tag.key]
+				if conflict {
+					p.conflicts.Add(1)
+					if p.inboundWins {
+						val = entries[idx].member.Value()
+					}
+				}
+				values[i] = val
+
 				// Store in variable context custom data
 				vc.SetCustom(tag.name, val)
+
 				// Propagate as custom header to backend (skip when header is empty = W3C-only tag)
 				if tag.header != "" {
 					r.Header.Set(tag.header, val)
 				}
+
 				// Add to W3C baggage
-				if tag.w3cKey != "" {
-					m, err := baggage.NewMember(tag.w3cKey, val)
-					if err != nil {
-						continue // value not W3C-encodable, skip
-					}
-					bag, _ = bag.SetMember(m)
+				if !tag.w3c || (conflict && p.inboundWins) {
+					continue
+				}
+				m, err := baggage.NewMemberRaw(tag.key, val)
+				if err != nil {
+					p.invalid.Add(1)
+					continue // key not W3C-encodable, skip
+				}
+				e := entry{member: m, priority: tag.priority, local: true}
+				if conflict {
+					entries[idx] = e
+				} else {
+					byKey[tag.key] = len(entries)
+					entries = append(entries, e)
 				}
 			}
 
-			// Store W3C baggage in context for OTEL propagator to serialize
+			kept, dropped := p.enforceLimits(entries)
+
+			var bag baggage.Baggage
+			parts := make([]string, 0, len(kept))
+			for _, m := range kept {
+				bag, _ = bag.SetMember(m)
+				parts = append(parts, m.String())
+				vc.SetBaggage(m.Key(), m.Value())
+			}
+			// Locally extracted tags are always visible in the namespace,
+			// even when they are not (or no longer) propagated.
+			for i, tag := range p.tags {
+				if values[i] != "" {
+					vc.SetBaggage(tag.key, values[i])
+				}
+			}
+
+			// With W3C baggage on, rewrite the forwarded header so oversized
+			// or malformed inbound baggage never reaches the backend, and
+			// store it in context for the OTEL propagator to serialize.
+			// Otherwise the inbound header is forwarded untouched.
 			if p.w3cBaggage {
-				ctx := baggage.ContextWithBaggage(r.Context(), bag)
-				r = r.WithContext(ctx)
+				if len(parts) > 0 {
+					r.Header.Set("Baggage", strings.Join(parts, ","))
+				} else {
+					r.Header.Del("Baggage")
+				}
+				r = r.WithContext(baggage.ContextWithBaggage(r.Context(), bag))
+			}
+
+			if dropped > 0 {
+				p.truncated.Add(1)
+				p.dropped.Add(int64(dropped))
+				w.Header().Set(TruncatedHeader, strconv.Itoa(dropped))
 			}
 
 			// Set propagate trace flag for proxy layer
@@ -103,6 +198,100 @@ func (p *Propagator) Middleware() middleware.Middleware {
 	}
 }
 
+// inbound returns the request's baggage members in header order. Members
+// that fail W3C parsing are skipped individually rather than discarding the
+// whole header. Without a header, baggage already in the request context
+// (e.g. extracted by the tracing middleware) is used.
+func (p *Propagator) inbound(r *http.Request) []entry {
+	var entries []entry
+	seen := make(map[string]bool)
+	for _, line := range r.Header.Values("Baggage") {
+		for _, raw := range strings.Split(line, ",") {
+			raw = strings.TrimSpace(raw)
+			if raw == "" {
+				continue
+			}
+			b, err := baggage.Parse(raw)
+			if err != nil || b.Len() != 1 {
+				p.invalid.Add(1)
+				continue
+			}
+			m := b.Members()[0]
+			if seen[m.Key()] {
+				continue
+			}
+			seen[m.Key()] = true
+			entries = append(entries, entry{member: m})
+		}
+	}
+	if len(entries) > 0 || len(r.Header.Values("Baggage")) > 0 {
+		return entries
+	}
+
+	members := baggage.FromContext(r.Context()).Members()
+	sort.Slice(members, func(i, j int) bool { return members[i].Key() < members[j].Key() })
+	for _, m := range members {
+		entries = append(entries, entry{member: m})
+	}
+	return entries
+}
+
+// enforceLimits drops the lowest-priority members until the set fits within
+// maxMembers and maxBytes. Local tags outrank inbound members of equal
+// priority; earlier members outrank later ones. Survivors keep their
+// original order.
+func (p *Propagator) enforceLimits(entries []entry) ([]baggage.Member, int) {
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ea, eb := entries[order[a]], entries[order[b]]
+		if ea.priority != eb.priority {
+			return ea.priority > eb.priority
+		}
+		return ea.local && !eb.local
+	})
+
+	keep := make([]bool, len(entries))
+	count, size := 0, 0
+	for _, i := range order {
+		if count == p.maxMembers {
+			break
+		}
+		n := len(entries[i].member.String())
+		if count > 0 {
+			n++ // list separator
+		}
+		if size+n > p.maxBytes {
+			continue
+		}
+		keep[i] = true
+		count++
+		size += n
+	}
+
+	kept := make([]baggage.Member, 0, count)
+	for i, e := range entries {
+		if keep[i] {
+			kept = append(kept, e.member)
+		}
+	}
+	return kept, len(entries) - count
+}
+
+// sanitizeValue trims surrounding whitespace and removes control characters
+// and invalid UTF-8 so the value can be percent-encoded per the W3C spec.
+func sanitizeValue(v string) string {
+	v = strings.TrimSpace(strings.ToValidUTF8(v, ""))
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, v)
+}
+
 // Propagated returns the count of requests propagated.
 func (p *Propagator) Propagated() int64 {
 	return p.propagated.Load()
@@ -124,6 +313,12 @@ func NewBaggageByRoute() *BaggageByRoute {
 			"propagated":      p.Propagated(),
 			"propagate_trace": p.propagateTrace,
 			"w3c_baggage":     p.w3cBaggage,
+			"max_members":     p.maxMembers,
+			"max_bytes":       p.maxBytes,
+			"truncated":       p.truncated.Load(),
+			"dropped_members": p.dropped.Load(),
+			"invalid_members": p.invalid.Load(),
+			"conflicts":       p.conflicts.Load(),
 		}
 	})
 }
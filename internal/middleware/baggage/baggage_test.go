@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"go.opentelemetry.io/otel/baggage"
@@ -724,3 +725,196 @@ func TestStatsIncludeNewFields(t *testing.T) {
 		t.Errorf("expected w3c_baggage=true, got %v", m["w3c_baggage"])
 	}
 }
+
+// --- Limits, sanitization, and conflict handling ---
+
+func serveBaggage(t *testing.T, p *Propagator, inbound string, setup func(*http.Request)) (*httptest.ResponseRecorder, *http.Request) {
+	t.Helper()
+	var got *http.Request
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r })
+	req := httptest.NewRequest("GET", "/test", nil)
+	if inbound != "" {
+		req.Header.Set("Baggage", inbound)
+	}
+	if setup != nil {
+		setup(req)
+	}
+	rec := httptest.NewRecorder()
+	setupVarContext(p.Middleware()(inner)).ServeHTTP(rec, req)
+	return rec, got
+}
+
+func TestPropagator_MaxMembersDropsLowestPriority(t *testing.T) {
+	p, err := New(config.BaggageConfig{
+		Enabled:    true,
+		W3CBaggage: true,
+		MaxMembers: 3,
+		Tags: []config.BaggageTagDef{
+			{Name: "tenant", Source: "static:acme", Priority: 10},
+			{Name: "env", Source: "static:prod"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, r := serveBaggage(t, p, "a=1,b=2,c=3", nil)
+
+	// tenant (priority 10) outranks everything; env (local, priority 0)
+	// outranks inbound members; then inbound in order: a survives.
+	if got := r.Header.Get("Baggage"); got != "a=1,tenant=acme,env=prod" {
+		t.Errorf("unexpected forwarded baggage %q", got)
+	}
+	if got := rec.Header().Get(TruncatedHeader); got != "2" {
+		t.Errorf("expected %s: 2, got %q", TruncatedHeader, got)
+	}
+	bag := baggage.FromContext(r.Context())
+	if bag.Len() != 3 || bag.Member("b").Value() != "" {
+		t.Errorf("context baggage not truncated: %s", bag.String())
+	}
+	vc := variables.GetFromRequest(r)
+	if _, ok := vc.GetBaggage("c"); ok {
+		t.Error("dropped inbound member must not be registered")
+	}
+}
+
+func TestPropagator_MaxBytes(t *testing.T) {
+	p, err := New(config.BaggageConfig{Enabled: true, W3CBaggage: true, MaxBytes: 20, Tags: []config.BaggageTagDef{
+		{Name: "env", Source: "static:prod", Header: "X-Env"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	big := "big=" + strings.Repeat("x", 100)
+	rec, r := serveBaggage(t, p, big+",small=1,other=22", nil)
+
+	// env=prod (local) is kept first, then small=1 fits; other=22 would
+	// exceed 20 bytes.
+	if got := r.Header.Get("Baggage"); got != "small=1,env=prod" {
+		t.Errorf("unexpected forwarded baggage %q", got)
+	}
+	if got := rec.Header().Get(TruncatedHeader); got != "2" {
+		t.Errorf("expected two dropped members, got %q", got)
+	}
+}
+
+func TestPropagator_InvalidInboundMembersSkipped(t *testing.T) {
+	p, err := New(config.BaggageConfig{Enabled: true, W3CBaggage: true, Tags: []config.BaggageTagDef{
+		{Name: "env", Source: "static:prod", Header: "X-Env"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, r := serveBaggage(t, p, "good=1,bad key=2,=3,also-good=a%20b", nil)
+
+	if got := r.Header.Get("Baggage"); got != "good=1,also-good=a%20b,env=prod" {
+		t.Errorf("unexpected forwarded baggage %q", got)
+	}
+	if rec.Header().Get(TruncatedHeader) != "" {
+		t.Error("invalid members are not truncation")
+	}
+	if v, _ := variables.GetFromRequest(r).GetBaggage("also-good"); v != "a b" {
+		t.Errorf("expected decoded value 'a b', got %q", v)
+	}
+	if got := p.invalid.Load(); got != 2 {
+		t.Errorf("expected 2 invalid members, got %d", got)
+	}
+}
+
+func TestPropagator_HeaderUntouchedWithoutW3C(t *testing.T) {
+	p, err := New(config.BaggageConfig{Enabled: true, MaxMembers: 1, Tags: []config.BaggageTagDef{
+		{Name: "env", Source: "static:prod", Header: "X-Env"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, r := serveBaggage(t, p, "a=1,bad key=2,b=3", nil)
+
+	if got := r.Header.Get("Baggage"); got != "a=1,bad key=2,b=3" {
+		t.Errorf("inbound baggage must be forwarded as is, got %q", got)
+	}
+	if baggage.FromContext(r.Context()).Len() != 0 {
+		t.Error("baggage must not be stored in context without w3c_baggage")
+	}
+	if v, _ := variables.GetFromRequest(r).GetBaggage("a"); v != "1" {
+		t.Errorf("expected inbound member in namespace, got %q", v)
+	}
+}
+
+func TestPropagator_SanitizesLocalValues(t *testing.T) {
+	p, err := New(config.BaggageConfig{Enabled: true, W3CBaggage: true, Tags: []config.BaggageTagDef{
+		{Name: "user", Source: "header:X-User"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, r := serveBaggage(t, p, "", func(req *http.Request) {
+		req.Header.Set("X-User", " jane\tdoe,admin ")
+	})
+
+	if got := r.Header.Get("Baggage"); got != "user=janedoe%2Cadmin" {
+		t.Errorf("unexpected forwarded baggage %q", got)
+	}
+	if v, _ := variables.GetFromRequest(r).GetBaggage("user"); v != "janedoe,admin" {
+		t.Errorf("expected sanitized namespace value, got %q", v)
+	}
+}
+
+func TestPropagator_ConflictLocalWins(t *testing.T) {
+	p, err := New(config.BaggageConfig{Enabled: true, W3CBaggage: true, Tags: []config.BaggageTagDef{
+		{Name: "tenant", Source: "header:X-Tenant", Header: "X-Backend-Tenant"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, r := serveBaggage(t, p, "tenant=spoofed,region=eu", func(req *http.Request) {
+		req.Header.Set("X-Tenant", "acme")
+	})
+
+	if got := r.Header.Get("Baggage"); got != "tenant=acme,region=eu" {
+		t.Errorf("unexpected forwarded baggage %q", got)
+	}
+	vc := variables.GetFromRequest(r)
+	if v, _ := vc.GetBaggage("tenant"); v != "acme" {
+		t.Errorf("expected local value in namespace, got %q", v)
+	}
+	if v, _ := vc.GetBaggage("region"); v != "eu" {
+		t.Errorf("expected inbound member region=eu, got %q", v)
+	}
+	if p.conflicts.Load() != 1 {
+		t.Errorf("expected 1 conflict, got %d", p.conflicts.Load())
+	}
+}
+
+func TestPropagator_ConflictInboundWins(t *testing.T) {
+	p, err := New(config.BaggageConfig{
+		Enabled:        true,
+		W3CBaggage:     true,
+		ConflictPolicy: "inbound",
+		Tags: []config.BaggageTagDef{
+			{Name: "tenant", Source: "header:X-Tenant", Header: "X-Backend-Tenant"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, r := serveBaggage(t, p, "tenant=upstream", func(req *http.Request) {
+		req.Header.Set("X-Tenant", "acme")
+	})
+
+	if got := r.Header.Get("Baggage"); got != "tenant=upstream" {
+		t.Errorf("unexpected forwarded baggage %q", got)
+	}
+	if got := r.Header.Get("X-Backend-Tenant"); got != "upstream" {
+		t.Errorf("expected backend header to carry inbound value, got %q", got)
+	}
+	if v, _ := variables.GetFromRequest(r).GetBaggage("tenant"); v != "upstream" {
+		t.Errorf("expected inbound value in namespace, got %q", v)
+	}
+}
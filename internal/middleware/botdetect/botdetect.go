@@ -9,6 +9,7 @@ import (
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/errors"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/variables"
 )
 
 // BotDetector checks User-Agent against deny/allow regex patterns.
@@ -78,6 +79,7 @@ func (bd *BotDetector) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if !bd.Check(r) {
+				variables.AddSignal(r, variables.SignalBot)
 				errors.ErrForbidden.WithDetails("Bot detected").WriteJSON(w)
 				return
 			}
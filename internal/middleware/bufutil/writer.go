@@ -7,15 +7,22 @@ import (
 	"bytes"
 	"net/http"
 	"strconv"
+	"strings"
+
+	"github.com/wudi/runway/internal/middleware/spillbuf"
 )
 
 // Writer is a full-buffering http.ResponseWriter that captures status,
 // headers, and body. Nothing is written to any underlying writer; the caller
 // is responsible for flushing via FlushTo or reading fields directly.
+//
+// A Writer from NewFor on a route with response_buffering holds the body in
+// a spill buffer instead of Body; read it with Bytes and check Aborted.
 type Writer struct {
 	StatusCode int
 	Body       bytes.Buffer
 	header     http.Header
+	spill      *spillbuf.Buffer
 }
 
 // New creates a Writer with status 200 and empty headers.
@@ -26,6 +33,14 @@ func New() *Writer {
 	}
 }
 
+// NewFor creates a Writer for r, buffering the body under the route's
+// response_buffering limits when it has them.
+func NewFor(r *http.Request) *Writer {
+	w := New()
+	w.spill = spillbuf.NewBuffer(r)
+	return w
+}
+
 // Header returns the buffered response headers.
 func (w *Writer) Header() http.Header { return w.header }
 
@@ -33,27 +48,116 @@ func (w *Writer) Header() http.Header { return w.header }
 func (w *Writer) WriteHeader(code int) { w.StatusCode = code }
 
 // Write appends b to the internal body buffer.
-func (w *Writer) Write(b []byte) (int, error) { return w.Body.Write(b) }
+func (w *Writer) Write(b []byte) (int, error) {
+	if w.spill != nil {
+		return w.spill.Write(b)
+	}
+	return w.Body.Write(b)
+}
+
+// Bytes returns the buffered body. A spilled body is valid until the request
+// ends.
+func (w *Writer) Bytes() ([]byte, error) {
+	if w.spill != nil {
+		return w.spill.Bytes()
+	}
+	return w.Body.Bytes(), nil
+}
+
+// Aborted writes a 502 to dst and returns true if the body outgrew the
+// route's response_buffering limits.
+func (w *Writer) Aborted(dst http.ResponseWriter) bool {
+	if w.spill == nil || w.spill.Err() == nil {
+		return false
+	}
+	spillbuf.WriteAbort(dst, w.spill.Err())
+	return true
+}
 
 // Flush is a no-op; everything stays buffered until FlushTo is called.
 func (w *Writer) Flush() {}
 
-// FlushTo writes the buffered response (headers, status, body) to dst.
+// FlushTo writes the buffered response (headers, status, body, trailers) to dst.
 func (w *Writer) FlushTo(dst http.ResponseWriter) {
-	CopyHeaders(dst.Header(), w.header)
+	w.CopyHeadersTo(dst.Header())
 	dst.WriteHeader(w.StatusCode)
-	if w.Body.Len() > 0 {
+	if w.spill != nil {
+		w.spill.WriteTo(dst)
+	} else if w.Body.Len() > 0 {
 		dst.Write(w.Body.Bytes())
 	}
+	w.WriteTrailers(dst)
 }
 
 // FlushToWithLength writes the buffered response to dst, setting
-// Content-Length to match the (possibly transformed) body.
+// Content-Length to match the (possibly transformed) body. Content-Length is
+// omitted when the response has trailers, which need chunked encoding.
 func (w *Writer) FlushToWithLength(dst http.ResponseWriter, body []byte) {
-	CopyHeaders(dst.Header(), w.header)
-	dst.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.CopyHeadersTo(dst.Header())
+	if w.HasTrailers() {
+		dst.Header().Del("Content-Length")
+	} else {
+		dst.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	}
 	dst.WriteHeader(w.StatusCode)
 	dst.Write(body)
+	w.WriteTrailers(dst)
+}
+
+// HasTrailers reports whether the buffered response declared or set trailers.
+func (w *Writer) HasTrailers() bool {
+	if len(w.header["Trailer"]) > 0 {
+		return true
+	}
+	for k := range w.header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CopyHeadersTo copies the buffered headers into dst, leaving out trailer
+// values so they are not sent before the body. The Trailer declaration itself
+// is copied.
+func (w *Writer) CopyHeadersTo(dst http.Header) {
+	declared := w.declaredTrailers()
+	for k, vs := range w.header {
+		if declared[k] || strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		for _, v := range vs {
+			dst.Add(k, v)
+		}
+	}
+}
+
+// WriteTrailers sets the buffered trailer values on dst. Call it after the
+// body has been written so dst sends them as trailers.
+func (w *Writer) WriteTrailers(dst http.ResponseWriter) {
+	declared := w.declaredTrailers()
+	for k, vs := range w.header {
+		if declared[k] || strings.HasPrefix(k, http.TrailerPrefix) {
+			dst.Header()[k] = vs
+		}
+	}
+}
+
+// declaredTrailers returns the canonical names listed in the Trailer header.
+func (w *Writer) declaredTrailers() map[string]bool {
+	values := w.header["Trailer"]
+	if len(values) == 0 {
+		return nil
+	}
+	declared := make(map[string]bool)
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				declared[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+	}
+	return declared
 }
 
 // CopyHeaders copies all header values from src into dst.
@@ -9,7 +9,6 @@ import (
 	"sync"
 	"sync/atomic"
 
-	"github.com/andybalholm/brotli"
 	"github.com/klauspost/compress/zstd"
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
@@ -39,22 +38,6 @@ func (cw *countWriter) Write(p []byte) (int, error) {
 	return n, err
 }
 
-// pooledZstdWriter wraps a *zstd.Encoder and returns it to a pool on Close.
-type pooledZstdWriter struct {
-	enc  *zstd.Encoder
-	pool *sync.Pool
-}
-
-func (pw *pooledZstdWriter) Write(p []byte) (int, error) {
-	return pw.enc.Write(p)
-}
-
-func (pw *pooledZstdWriter) Close() error {
-	err := pw.enc.Close()
-	pw.pool.Put(pw.enc)
-	return err
-}
-
 // AlgorithmMetrics tracks compression metrics for one algorithm.
 type AlgorithmMetrics struct {
 	BytesIn  atomic.Int64
@@ -92,7 +75,7 @@ type Compressor struct {
 	algorithms   map[string]bool
 	algoOrder    []string
 	metrics      map[string]*AlgorithmMetrics
-	zstdPool     sync.Pool
+	encoders     map[string]*boundedPool[resetEncoder]
 }
 
 // New creates a new Compressor from config.
@@ -104,6 +87,7 @@ func New(cfg config.CompressionConfig) *Compressor {
 		contentTypes: make(map[string]bool),
 		algorithms:   make(map[string]bool),
 		metrics:      make(map[string]*AlgorithmMetrics),
+		encoders:     make(map[string]*boundedPool[resetEncoder]),
 	}
 
 	if c.level <= 0 || c.level > 11 {
@@ -154,16 +138,9 @@ func New(cfg config.CompressionConfig) *Compressor {
 		c.contentTypes["image/svg+xml"] = true
 	}
 
-	// Initialize zstd pool
-	zstdLevel := zstd.SpeedDefault
-	if c.level > 0 {
-		zstdLevel = zstd.EncoderLevelFromZstd(c.level)
-	}
-	c.zstdPool = sync.Pool{
-		New: func() any {
-			enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstdLevel))
-			return enc
-		},
+	// Encoders are pooled per algorithm and native level
+	for algo := range c.algorithms {
+		c.encoders[algo] = encoderPool(algo, c.nativeLevel(algo))
 	}
 
 	return c
@@ -255,26 +232,16 @@ func (c *Compressor) NegotiateEncoding(r *http.Request) string {
 	return bestAlgo
 }
 
-// newEncodingWriter creates a writer for the specified algorithm.
-func (c *Compressor) newEncodingWriter(w io.Writer, algo string) encodingWriter {
+// nativeLevel maps the configured level onto algo's own range: gzip is
+// clamped to 9, brotli uses 0-11 as is, zstd maps to its four speed levels.
+func (c *Compressor) nativeLevel(algo string) int {
 	switch algo {
-	case "gzip":
-		level := c.level
-		if level > 9 {
-			level = 9
-		}
-		gz, _ := gzip.NewWriterLevel(w, level)
-		return gz
 	case "br":
-		return brotli.NewWriterLevel(w, c.level)
+		return c.level
 	case "zstd":
-		enc := c.zstdPool.Get().(*zstd.Encoder)
-		enc.Reset(w)
-		return &pooledZstdWriter{enc: enc, pool: &c.zstdPool}
+		return int(zstd.EncoderLevelFromZstd(c.level))
 	default:
-		// Fallback to gzip
-		gz, _ := gzip.NewWriterLevel(w, c.level)
-		return gz
+		return min(c.level, gzip.BestCompression)
 	}
 }
 
@@ -306,12 +273,15 @@ func (c *Compressor) isCompressibleType(contentType string) bool {
 }
 
 // CompressingResponseWriter wraps a ResponseWriter to compress the response.
+// The compression decision is deferred until the response headers or the
+// first min_size bytes settle it, so skipped responses never touch an encoder.
 type CompressingResponseWriter struct {
 	http.ResponseWriter
 	compressor    *Compressor
 	algorithm     string
-	encWriter     encodingWriter
-	countWriter   *countWriter
+	encWriter     resetEncoder
+	encPool       *boundedPool[resetEncoder]
+	countWriter   countWriter
 	headerWritten bool
 	statusCode    int
 	buf           []byte
@@ -320,6 +290,11 @@ type CompressingResponseWriter struct {
 	bytesIn       int64
 }
 
+// writerPool recycles CompressingResponseWriters used by the middleware.
+var writerPool = sync.Pool{
+	New: func() any { return &CompressingResponseWriter{} },
+}
+
 // NewCompressingResponseWriter creates a new compressing writer.
 func NewCompressingResponseWriter(w http.ResponseWriter, c *Compressor, algo string) *CompressingResponseWriter {
 	return &CompressingResponseWriter{
@@ -330,6 +305,46 @@ func NewCompressingResponseWriter(w http.ResponseWriter, c *Compressor, algo str
 	}
 }
 
+// acquireWriter returns a pooled writer initialized like NewCompressingResponseWriter.
+func acquireWriter(w http.ResponseWriter, c *Compressor, algo string) *CompressingResponseWriter {
+	cw := writerPool.Get().(*CompressingResponseWriter)
+	cw.ResponseWriter = w
+	cw.compressor = c
+	cw.algorithm = algo
+	cw.statusCode = 200
+	return cw
+}
+
+// releaseWriter resets cw and returns it to the pool. cw must be closed.
+func releaseWriter(cw *CompressingResponseWriter) {
+	*cw = CompressingResponseWriter{}
+	writerPool.Put(cw)
+}
+
+// skipFromHeaders reports whether the response headers alone rule out
+// compression: a non-compressible Content-Type or a Content-Length below
+// min_size.
+func (w *CompressingResponseWriter) skipFromHeaders() bool {
+	h := w.ResponseWriter.Header()
+	if ct := h.Get("Content-Type"); ct != "" && !w.compressor.isCompressibleType(ct) {
+		return true
+	}
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < w.compressor.minSize {
+			return true
+		}
+	}
+	return false
+}
+
+// decide records the compression decision and writes the headers and any
+// buffered body.
+func (w *CompressingResponseWriter) decide(compress bool) {
+	w.decided = true
+	w.compressing = compress
+	w.flushBuffer()
+}
+
 // WriteHeader captures status code.
 func (w *CompressingResponseWriter) WriteHeader(code int) {
 	if w.headerWritten {
@@ -337,46 +352,27 @@ func (w *CompressingResponseWriter) WriteHeader(code int) {
 	}
 	w.statusCode = code
 
-	if w.decided {
-		w.headerWritten = true
-		if w.compressing {
-			w.ResponseWriter.Header().Del("Content-Length")
-			w.ResponseWriter.Header().Set("Content-Encoding", w.algorithm)
-			w.ResponseWriter.Header().Add("Vary", "Accept-Encoding")
-		}
-		w.ResponseWriter.WriteHeader(code)
-		return
-	}
-
-	ct := w.ResponseWriter.Header().Get("Content-Type")
-	if ct != "" && !w.compressor.isCompressibleType(ct) {
-		w.decided = true
-		w.compressing = false
-		w.headerWritten = true
-		w.ResponseWriter.WriteHeader(code)
-		return
+	if w.skipFromHeaders() {
+		w.decide(false)
 	}
 }
 
 func (w *CompressingResponseWriter) Write(b []byte) (int, error) {
 	if !w.decided {
-		w.buf = append(w.buf, b...)
-
-		ct := w.ResponseWriter.Header().Get("Content-Type")
-		if ct != "" && !w.compressor.isCompressibleType(ct) {
-			w.decided = true
-			w.compressing = false
-			w.flushBuffer()
-			return len(b), nil
+		if w.skipFromHeaders() {
+			w.decide(false)
+			return w.ResponseWriter.Write(b)
 		}
-
-		if len(w.buf) >= w.compressor.minSize {
-			w.decided = true
-			w.compressing = true
-			w.flushBuffer()
+		if len(w.buf)+len(b) < w.compressor.minSize {
+			if w.buf == nil {
+				w.buf = bufferPool.get()
+			}
+			w.buf = append(w.buf, b...)
 			return len(b), nil
 		}
-		return len(b), nil
+		// min_size reached: b goes straight to the encoder, behind
+		// whatever was buffered, without being copied.
+		w.decide(true)
 	}
 
 	if w.compressing && w.encWriter != nil {
@@ -393,20 +389,24 @@ func (w *CompressingResponseWriter) flushBuffer() {
 			w.ResponseWriter.Header().Del("Content-Length")
 			w.ResponseWriter.Header().Set("Content-Encoding", w.algorithm)
 			w.ResponseWriter.Header().Add("Vary", "Accept-Encoding")
-			cw := &countWriter{w: w.ResponseWriter}
-			w.countWriter = cw
-			w.encWriter = w.compressor.newEncodingWriter(cw, w.algorithm)
+			w.countWriter = countWriter{w: w.ResponseWriter}
+			w.encPool = w.compressor.encoders[w.algorithm]
+			w.encWriter = w.encPool.get()
+			w.encWriter.Reset(&w.countWriter)
 		}
 		w.ResponseWriter.WriteHeader(w.statusCode)
 	}
 
-	if len(w.buf) > 0 {
-		if w.compressing && w.encWriter != nil {
-			w.bytesIn += int64(len(w.buf))
-			w.encWriter.Write(w.buf)
-		} else {
-			w.ResponseWriter.Write(w.buf)
+	if w.buf != nil {
+		if len(w.buf) > 0 {
+			if w.compressing && w.encWriter != nil {
+				w.bytesIn += int64(len(w.buf))
+				w.encWriter.Write(w.buf)
+			} else {
+				w.ResponseWriter.Write(w.buf)
+			}
 		}
+		putBuffer(w.buf)
 		w.buf = nil
 	}
 }
@@ -414,19 +414,17 @@ func (w *CompressingResponseWriter) flushBuffer() {
 // Close finishes compression — must be called after request completes.
 func (w *CompressingResponseWriter) Close() {
 	if !w.decided {
-		w.decided = true
-		w.compressing = false
-		w.flushBuffer()
+		w.decide(false)
 		return
 	}
 	if w.compressing && w.encWriter != nil {
 		w.encWriter.Close()
+		w.encPool.put(w.encWriter)
+		w.encWriter = nil
 		// Record metrics
 		if m, ok := w.compressor.metrics[w.algorithm]; ok {
 			m.BytesIn.Add(w.bytesIn)
-			if w.countWriter != nil {
-				m.BytesOut.Add(w.countWriter.n)
-			}
+			m.BytesOut.Add(w.countWriter.n)
 			m.Count.Add(1)
 		}
 	}
@@ -435,9 +433,7 @@ func (w *CompressingResponseWriter) Close() {
 // Flush implements http.Flusher.
 func (w *CompressingResponseWriter) Flush() {
 	if !w.decided {
-		w.decided = true
-		w.compressing = len(w.buf) >= w.compressor.minSize
-		w.flushBuffer()
+		w.decide(len(w.buf) >= w.compressor.minSize)
 	}
 	if w.compressing && w.encWriter != nil {
 		if f, ok := w.encWriter.(optionalFlusher); ok {
@@ -476,10 +472,11 @@ func (c *Compressor) Middleware() middleware.Middleware {
 				next.ServeHTTP(w, r)
 				return
 			}
-			cw := NewCompressingResponseWriter(w, c, algo)
+			cw := acquireWriter(w, c, algo)
 			r.Header.Del("Accept-Encoding")
 			next.ServeHTTP(cw, r)
 			cw.Close()
+			releaseWriter(cw)
 		})
 	}
 }
@@ -2,6 +2,7 @@ package compression
 
 import (
 	"compress/gzip"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -284,3 +285,165 @@ func TestAlgorithmsConfig(t *testing.T) {
 		t.Errorf("expected empty for br-only request with gzip-only config, got %q", got)
 	}
 }
+
+func TestMiddleware_ReusesEncoders(t *testing.T) {
+	c := New(config.CompressionConfig{Enabled: true, MinSize: 10})
+	handler := c.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// Two writes straddling min_size: the first is buffered, the
+		// second goes straight to the encoder.
+		w.Write([]byte(`{"k":`))
+		w.Write([]byte(`"` + r.URL.Query().Get("v") + `"}`))
+	}))
+
+	for i := 0; i < 3; i++ {
+		for _, algo := range []string{"gzip", "br", "zstd"} {
+			v := fmt.Sprintf("%s-%d-%s", algo, i, strings.Repeat("x", 50))
+			want := `{"k":"` + v + `"}`
+			r := httptest.NewRequest("GET", "/?v="+v, nil)
+			r.Header.Set("Accept-Encoding", algo)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Encoding"); got != algo {
+				t.Fatalf("%s: Content-Encoding = %q", algo, got)
+			}
+			var rd io.Reader
+			switch algo {
+			case "gzip":
+				gz, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rd = gz
+			case "br":
+				rd = brotli.NewReader(w.Body)
+			case "zstd":
+				zr, err := zstd.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer zr.Close()
+				rd = zr
+			}
+			got, err := io.ReadAll(rd)
+			if err != nil {
+				t.Fatalf("%s: decompress: %v", algo, err)
+			}
+			if string(got) != want {
+				t.Errorf("%s: body = %q, want %q", algo, got, want)
+			}
+		}
+	}
+}
+
+func TestSkipFromContentLength(t *testing.T) {
+	c := New(config.CompressionConfig{Enabled: true, MinSize: 100})
+
+	w := httptest.NewRecorder()
+	cw := NewCompressingResponseWriter(w, c, "gzip")
+	cw.Header().Set("Content-Type", "application/json")
+	cw.Header().Set("Content-Length", "11")
+	cw.WriteHeader(http.StatusCreated)
+
+	// Decided from headers: nothing is buffered and the status is sent at once.
+	if !cw.decided || cw.compressing {
+		t.Fatalf("expected pass-through decision from Content-Length, decided=%v compressing=%v", cw.decided, cw.compressing)
+	}
+	if w.Code != http.StatusCreated {
+		t.Errorf("status = %d, want 201", w.Code)
+	}
+	cw.Write([]byte(`{"ok":true}`))
+	cw.Close()
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != `{"ok":true}` {
+		t.Errorf("unexpected response: encoding=%q body=%q", w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+}
+
+func TestBoundedPool(t *testing.T) {
+	created := 0
+	p := newBoundedPool(2, func() int { created++; return created })
+
+	a, b, c := p.get(), p.get(), p.get()
+	if created != 3 {
+		t.Fatalf("created = %d, want 3", created)
+	}
+	p.put(a)
+	p.put(b)
+	p.put(c) // dropped: pool is full
+	if len(p.free) != 2 {
+		t.Errorf("idle = %d, want 2", len(p.free))
+	}
+	p.get()
+	p.get()
+	p.get()
+	if created != 4 {
+		t.Errorf("created = %d, want 4 after draining the pool", created)
+	}
+}
+
+func TestEncoderPoolSharedByLevel(t *testing.T) {
+	a := New(config.CompressionConfig{Enabled: true, Level: 5})
+	b := New(config.CompressionConfig{Enabled: true, Level: 5})
+	c := New(config.CompressionConfig{Enabled: true, Level: 10})
+	if a.encoders["gzip"] != b.encoders["gzip"] {
+		t.Error("expected routes with the same level to share a gzip pool")
+	}
+	if a.encoders["br"] == c.encoders["br"] {
+		t.Error("expected different brotli levels to use different pools")
+	}
+	// gzip clamps levels above 9, so 10 shares the level 9 pool.
+	d := New(config.CompressionConfig{Enabled: true, Level: 9})
+	if c.encoders["gzip"] != d.encoders["gzip"] {
+		t.Error("expected clamped gzip levels to share a pool")
+	}
+}
+
+// discardResponseWriter is a reusable ResponseWriter for benchmarks, so
+// the numbers reflect the compressor rather than the recorder.
+type discardResponseWriter struct {
+	h http.Header
+}
+
+func (d *discardResponseWriter) Header() http.Header         { return d.h }
+func (d *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (d *discardResponseWriter) WriteHeader(int)             {}
+
+func benchmarkMiddleware(b *testing.B, algo, contentType string, body []byte) {
+	c := New(config.CompressionConfig{Enabled: true, Algorithms: []string{algo}})
+	handler := c.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write(body)
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	w := &discardResponseWriter{h: make(http.Header)}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		req.Header.Set("Accept-Encoding", algo)
+		clear(w.h)
+		handler.ServeHTTP(w, req)
+	}
+}
+
+var (
+	benchSmallJSON = []byte(`{"id":1,"name":"widget","tags":["a","b"]}`)
+	benchLargeJSON = []byte(`[` + strings.Repeat(`{"id":12345,"name":"widget","description":"a reasonably long description field","price":19.99},`, 400) + `{}]`)
+)
+
+func BenchmarkMiddleware_SmallJSON(b *testing.B) {
+	for _, algo := range []string{"gzip", "br", "zstd"} {
+		b.Run(algo, func(b *testing.B) { benchmarkMiddleware(b, algo, "application/json", benchSmallJSON) })
+	}
+}
+
+func BenchmarkMiddleware_LargeJSON(b *testing.B) {
+	for _, algo := range []string{"gzip", "br", "zstd"} {
+		b.Run(algo, func(b *testing.B) { benchmarkMiddleware(b, algo, "application/json", benchLargeJSON) })
+	}
+}
+
+func BenchmarkMiddleware_SkippedContentType(b *testing.B) {
+	benchmarkMiddleware(b, "zstd", "image/png", benchLargeJSON)
+}
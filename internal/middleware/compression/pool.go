@@ -0,0 +1,113 @@
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// maxIdleEncoders bounds the idle encoders kept per (algorithm, level).
+	// Encoders released while the pool is full are dropped, so memory held
+	// after a burst shrinks back to this bound.
+	maxIdleEncoders = 32
+
+	// maxIdleBuffers bounds the idle min_size buffers kept per pool.
+	maxIdleBuffers = 256
+
+	// maxPooledBufferCap is the largest buffer returned to a pool; buffers
+	// grown past it (large min_size) are left to the GC.
+	maxPooledBufferCap = 64 << 10
+)
+
+// resetEncoder is an encoder that can be pointed at a new destination and
+// reused after Close. gzip.Writer, brotli.Writer and zstd.Encoder all qualify.
+type resetEncoder interface {
+	encodingWriter
+	Reset(w io.Writer)
+}
+
+// boundedPool is a free list holding at most cap(free) idle items. Unlike
+// sync.Pool it never retains more than its bound between GCs.
+type boundedPool[T any] struct {
+	free chan T
+	new  func() T
+}
+
+func newBoundedPool[T any](size int, newFn func() T) *boundedPool[T] {
+	return &boundedPool[T]{free: make(chan T, size), new: newFn}
+}
+
+func (p *boundedPool[T]) get() T {
+	select {
+	case v := <-p.free:
+		return v
+	default:
+		return p.new()
+	}
+}
+
+func (p *boundedPool[T]) put(v T) {
+	select {
+	case p.free <- v:
+	default:
+	}
+}
+
+// encoderPoolKey identifies encoders interchangeable with each other.
+type encoderPoolKey struct {
+	algo  string
+	level int
+}
+
+var (
+	encoderPoolsMu sync.Mutex
+	encoderPools   = make(map[encoderPoolKey]*boundedPool[resetEncoder])
+)
+
+// encoderPool returns the process-wide pool for algo at level, creating it
+// on first use. Routes with the same algorithm and level share a pool.
+func encoderPool(algo string, level int) *boundedPool[resetEncoder] {
+	key := encoderPoolKey{algo: algo, level: level}
+	encoderPoolsMu.Lock()
+	defer encoderPoolsMu.Unlock()
+	if p, ok := encoderPools[key]; ok {
+		return p
+	}
+	var newFn func() resetEncoder
+	switch algo {
+	case "br":
+		newFn = func() resetEncoder { return brotli.NewWriterLevel(nil, level) }
+	case "zstd":
+		newFn = func() resetEncoder {
+			// Concurrency 1 encodes synchronously on the request goroutine
+			// and keeps a single window per encoder.
+			enc, _ := zstd.NewWriter(nil,
+				zstd.WithEncoderLevel(zstd.EncoderLevel(level)),
+				zstd.WithEncoderConcurrency(1))
+			return enc
+		}
+	default:
+		newFn = func() resetEncoder {
+			gz, _ := gzip.NewWriterLevel(nil, level)
+			return gz
+		}
+	}
+	p := newBoundedPool(maxIdleEncoders, newFn)
+	encoderPools[key] = p
+	return p
+}
+
+// bufferPool holds the buffers that accumulate a response until it reaches
+// min_size. Buffers are stored as slices, so get and put do not allocate.
+var bufferPool = newBoundedPool(maxIdleBuffers, func() []byte { return make([]byte, 0, 1024) })
+
+func putBuffer(b []byte) {
+	if cap(b) > maxPooledBufferCap {
+		return
+	}
+	bufferPool.put(b[:0])
+}
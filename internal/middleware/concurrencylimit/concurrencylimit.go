@@ -0,0 +1,353 @@
+package concurrencylimit
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/ratelimit"
+	"github.com/wudi/runway/variables"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultQueueSize = 10
+	defaultMaxKeys   = 10000
+	defaultLeaseTTL  = 5 * time.Minute
+
+	// topKeys is the number of busiest keys reported in stats.
+	topKeys = 10
+)
+
+// ErrConcurrencyLimit is returned when a client has too many requests in flight.
+// Its message distinguishes it from rate limit rejections, which share the 429 status.
+var ErrConcurrencyLimit = &errors.RunwayError{
+	Code:    http.StatusTooManyRequests,
+	Message: "Too Many Concurrent Requests",
+}
+
+// keyState is one key's counting semaphore. waiters are granted permits in
+// FIFO order as in-flight requests finish.
+type keyState struct {
+	key      string
+	inFlight int
+	waiters  []chan struct{}
+	elem     *list.Element
+}
+
+func (ks *keyState) idle() bool {
+	return ks.inFlight == 0 && len(ks.waiters) == 0
+}
+
+// Limiter caps the in-flight requests per client key. Keys are kept in an
+// LRU bounded by max_keys; only idle keys are evicted, so the bound can be
+// exceeded while every tracked key has requests in flight.
+type Limiter struct {
+	max       int
+	maxWait   time.Duration
+	queueSize int
+	maxKeys   int
+	keyFn     func(*http.Request) string
+
+	mu   sync.Mutex
+	keys map[string]*keyState
+	lru  *list.List // front = most recently used
+
+	// distributed mode
+	redis    *redis.Client
+	prefix   string
+	leaseTTL time.Duration
+
+	admitted  atomic.Int64
+	waited    atomic.Int64
+	rejected  atomic.Int64
+	evictions atomic.Int64
+	failOpen  atomic.Int64
+}
+
+// New creates a Limiter for a route. redisClient is used when cfg.Mode is
+// "distributed".
+func New(routeID string, cfg config.ConcurrencyLimitConfig, redisClient *redis.Client) *Limiter {
+	l := &Limiter{
+		max:       cfg.MaxInFlight,
+		maxWait:   cfg.MaxWait,
+		queueSize: cfg.QueueSize,
+		maxKeys:   cfg.MaxKeys,
+		keyFn:     ratelimit.BuildKeyFunc(false, cfg.Key),
+		keys:      make(map[string]*keyState),
+		lru:       list.New(),
+		prefix:    "gw:cl:" + routeID + ":",
+		leaseTTL:  cfg.LeaseTTL,
+	}
+	if l.queueSize <= 0 {
+		l.queueSize = defaultQueueSize
+	}
+	if l.maxKeys <= 0 {
+		l.maxKeys = defaultMaxKeys
+	}
+	if l.leaseTTL <= 0 {
+		l.leaseTTL = defaultLeaseTTL
+	}
+	if cfg.Mode == "distributed" {
+		l.redis = redisClient
+	}
+	return l
+}
+
+// Middleware returns the concurrency limiting middleware.
+func (l *Limiter) Middleware() middleware.Middleware {
+	limit := strconv.Itoa(l.max)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := l.keyFn(r)
+			release, ok := l.acquire(r.Context(), key)
+			if !ok {
+				if r.Context().Err() != nil {
+					return // client went away while queued
+				}
+				l.rejected.Add(1)
+				variables.AddSignal(r, variables.SignalRateLimit)
+				variables.SetErrorReason(r, errors.ReasonConcurrencyLimited)
+				w.Header().Set("X-Concurrency-Limit", limit)
+				ErrConcurrencyLimit.WithDetails(
+					fmt.Sprintf("at most %d requests may be in flight per client", l.max),
+				).WriteJSON(w)
+				return
+			}
+			// Released even if the handler panics or the client disconnects.
+			defer release()
+			l.admitted.Add(1)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// acquire takes a permit for key, waiting up to max_wait for one to free up.
+// The returned release func must be called exactly once when ok is true.
+func (l *Limiter) acquire(ctx context.Context, key string) (release func(), ok bool) {
+	if l.redis != nil {
+		return l.acquireDistributed(ctx, key)
+	}
+
+	l.mu.Lock()
+	ks := l.touch(key)
+	if ks.inFlight < l.max {
+		ks.inFlight++
+		l.mu.Unlock()
+		return func() { l.release(key) }, true
+	}
+	if l.maxWait <= 0 || len(ks.waiters) >= l.queueSize {
+		l.mu.Unlock()
+		return nil, false
+	}
+	ch := make(chan struct{})
+	ks.waiters = append(ks.waiters, ch)
+	l.mu.Unlock()
+
+	l.waited.Add(1)
+	timer := time.NewTimer(l.maxWait)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		return func() { l.release(key) }, true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, w := range ks.waiters {
+		if w == ch {
+			ks.waiters = append(ks.waiters[:i], ks.waiters[i+1:]...)
+			return nil, false
+		}
+	}
+	// The permit was handed over just as the wait ended; give it back.
+	l.releaseLocked(ks)
+	return nil, false
+}
+
+// touch returns key's state, creating it and evicting idle keys beyond
+// max_keys as needed. Callers hold l.mu.
+func (l *Limiter) touch(key string) *keyState {
+	if ks, ok := l.keys[key]; ok {
+		l.lru.MoveToFront(ks.elem)
+		return ks
+	}
+	for e := l.lru.Back(); e != nil && len(l.keys) >= l.maxKeys; {
+		prev := e.Prev()
+		if victim := e.Value.(*keyState); victim.idle() {
+			l.lru.Remove(e)
+			delete(l.keys, victim.key)
+			l.evictions.Add(1)
+		}
+		e = prev
+	}
+	ks := &keyState{key: key}
+	ks.elem = l.lru.PushFront(ks)
+	l.keys[key] = ks
+	return ks
+}
+
+func (l *Limiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ks, ok := l.keys[key]; ok {
+		l.releaseLocked(ks)
+	}
+}
+
+// releaseLocked hands the permit to the oldest waiter, or returns it.
+func (l *Limiter) releaseLocked(ks *keyState) {
+	if len(ks.waiters) > 0 {
+		ch := ks.waiters[0]
+		ks.waiters = ks.waiters[1:]
+		close(ch)
+		return
+	}
+	if ks.inFlight > 0 {
+		ks.inFlight--
+	}
+}
+
+// incrScript takes a distributed permit. The counter's TTL is refreshed on
+// every acquire, so permits leaked by a crashed instance expire once the key
+// has been quiet for lease_ttl.
+var incrScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+if n > tonumber(ARGV[1]) then
+    redis.call('DECR', KEYS[1])
+    return 0
+end
+return n
+`)
+
+// decrScript returns a distributed permit, never taking the counter below zero.
+var decrScript = redis.NewScript(`
+local n = redis.call('DECR', KEYS[1])
+if n <= 0 then
+    redis.call('DEL', KEYS[1])
+end
+return n
+`)
+
+// acquireDistributed takes a permit from the shared Redis counter. Redis
+// errors fail open. The local key state is still tracked for stats.
+func (l *Limiter) acquireDistributed(ctx context.Context, key string) (func(), bool) {
+	rctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	n, err := incrScript.Run(rctx, l.redis, []string{l.prefix + key}, l.max, l.leaseTTL.Milliseconds()).Int64()
+	cancel()
+	counted := err == nil
+	if err != nil {
+		l.failOpen.Add(1)
+		logging.Warn("Redis concurrency limit unavailable, failing open", zap.Error(err))
+	} else if n == 0 {
+		return nil, false
+	}
+
+	l.mu.Lock()
+	l.touch(key).inFlight++
+	l.mu.Unlock()
+	return func() {
+		l.release(key)
+		if !counted {
+			return
+		}
+		// The request context may already be cancelled; the permit must be
+		// returned regardless.
+		rctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if err := decrScript.Run(rctx, l.redis, []string{l.prefix + key}).Err(); err != nil {
+			logging.Warn("Redis concurrency limit release failed; permit expires with lease_ttl",
+				zap.String("key", key), zap.Error(err))
+		}
+	}, true
+}
+
+// KeyInFlight is one key's in-flight and queued request counts.
+type KeyInFlight struct {
+	Key      string `json:"key"`
+	InFlight int    `json:"in_flight"`
+	Waiting  int    `json:"waiting"`
+}
+
+// Snapshot is a point-in-time view of a limiter.
+type Snapshot struct {
+	MaxInFlight int           `json:"max_in_flight"`
+	Mode        string        `json:"mode"`
+	TrackedKeys int           `json:"tracked_keys"`
+	InFlight    int           `json:"in_flight"`
+	TopKeys     []KeyInFlight `json:"top_keys"`
+	Admitted    int64         `json:"admitted"`
+	Waited      int64         `json:"waited"`
+	Rejected    int64         `json:"rejected"`
+	Evictions   int64         `json:"evictions"`
+	FailOpen    int64         `json:"fail_open,omitempty"`
+}
+
+// Snapshot returns the limiter's stats, including the busiest keys on this
+// instance. In distributed mode in-flight counts are local to the instance.
+func (l *Limiter) Snapshot() Snapshot {
+	snap := Snapshot{
+		MaxInFlight: l.max,
+		Mode:        "local",
+		TopKeys:     []KeyInFlight{},
+		Admitted:    l.admitted.Load(),
+		Waited:      l.waited.Load(),
+		Rejected:    l.rejected.Load(),
+		Evictions:   l.evictions.Load(),
+		FailOpen:    l.failOpen.Load(),
+	}
+	if l.redis != nil {
+		snap.Mode = "distributed"
+	}
+
+	l.mu.Lock()
+	snap.TrackedKeys = len(l.keys)
+	for _, ks := range l.keys {
+		snap.InFlight += ks.inFlight
+		if !ks.idle() {
+			snap.TopKeys = append(snap.TopKeys, KeyInFlight{Key: ks.key, InFlight: ks.inFlight, Waiting: len(ks.waiters)})
+		}
+	}
+	l.mu.Unlock()
+
+	sort.Slice(snap.TopKeys, func(i, j int) bool {
+		a, b := snap.TopKeys[i], snap.TopKeys[j]
+		if a.InFlight != b.InFlight {
+			return a.InFlight > b.InFlight
+		}
+		return a.Key < b.Key
+	})
+	if len(snap.TopKeys) > topKeys {
+		snap.TopKeys = snap.TopKeys[:topKeys]
+	}
+	return snap
+}
+
+// ConcurrencyLimitByRoute manages per-route concurrency limiters.
+type ConcurrencyLimitByRoute = byroute.NamedFactory[*Limiter, config.ConcurrencyLimitConfig]
+
+// NewConcurrencyLimitByRoute creates a new per-route concurrency limit manager.
+// The redis client is captured in the constructor closure.
+func NewConcurrencyLimitByRoute(redisClient *redis.Client) *ConcurrencyLimitByRoute {
+	return byroute.SimpleNamedFactory(
+		func(routeID string, cfg config.ConcurrencyLimitConfig) *Limiter {
+			return New(routeID, cfg, redisClient)
+		},
+		func(l *Limiter) any { return l.Snapshot() },
+	)
+}
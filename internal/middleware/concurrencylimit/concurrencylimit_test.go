@@ -0,0 +1,272 @@
+package concurrencylimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wudi/runway/config"
+)
+
+// blockingHandler holds requests until release is closed.
+type blockingHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{started: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (h *blockingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.started <- struct{}{}
+	select {
+	case <-h.release:
+	case <-r.Context().Done():
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func request(client string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-API-Key", client)
+	return r
+}
+
+func serveAsync(h http.Handler, r *http.Request) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		done <- w
+	}()
+	return done
+}
+
+func TestLimiterRejectsExcess(t *testing.T) {
+	l := New("r", config.ConcurrencyLimitConfig{MaxInFlight: 2, Key: "header:X-API-Key"}, nil)
+	bh := newBlockingHandler()
+	h := l.Middleware()(bh)
+
+	first := serveAsync(h, request("a"))
+	second := serveAsync(h, request("a"))
+	<-bh.started
+	<-bh.started
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request("a"))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	var body map[string]any
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body["message"] != "Too Many Concurrent Requests" {
+		t.Errorf("expected concurrency limit message, got %v", body["message"])
+	}
+	if w.Header().Get("X-Concurrency-Limit") != "2" {
+		t.Errorf("expected X-Concurrency-Limit 2, got %q", w.Header().Get("X-Concurrency-Limit"))
+	}
+
+	// Another client is unaffected.
+	other := serveAsync(h, request("b"))
+	<-bh.started
+
+	snap := l.Snapshot()
+	if snap.InFlight != 3 || snap.Rejected != 1 {
+		t.Errorf("expected 3 in flight and 1 rejection, got %+v", snap)
+	}
+	if len(snap.TopKeys) != 2 || snap.TopKeys[0].InFlight != 2 {
+		t.Errorf("expected busiest key first, got %+v", snap.TopKeys)
+	}
+
+	close(bh.release)
+	<-first
+	<-second
+	<-other
+	if snap := l.Snapshot(); snap.InFlight != 0 {
+		t.Errorf("expected no requests in flight, got %d", snap.InFlight)
+	}
+}
+
+func TestLimiterQueueGrantsPermit(t *testing.T) {
+	l := New("r", config.ConcurrencyLimitConfig{MaxInFlight: 1, Key: "header:X-API-Key", MaxWait: time.Second}, nil)
+	bh := newBlockingHandler()
+	h := l.Middleware()(bh)
+
+	first := serveAsync(h, request("a"))
+	<-bh.started
+	queued := serveAsync(h, request("a"))
+
+	// Wait for the second request to be queued, then finish the first.
+	deadline := time.Now().Add(time.Second)
+	for l.Snapshot().Waited == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	bh.release <- struct{}{}
+	<-first
+	<-bh.started
+	close(bh.release)
+
+	if w := <-queued; w.Code != http.StatusOK {
+		t.Errorf("expected queued request to succeed, got %d", w.Code)
+	}
+	if snap := l.Snapshot(); snap.InFlight != 0 || snap.Admitted != 2 {
+		t.Errorf("expected 2 admitted and none in flight, got %+v", snap)
+	}
+}
+
+func TestLimiterQueueTimeoutAndFull(t *testing.T) {
+	l := New("r", config.ConcurrencyLimitConfig{
+		MaxInFlight: 1,
+		Key:         "header:X-API-Key",
+		MaxWait:     20 * time.Millisecond,
+		QueueSize:   1,
+	}, nil)
+	bh := newBlockingHandler()
+	h := l.Middleware()(bh)
+
+	first := serveAsync(h, request("a"))
+	<-bh.started
+
+	queued := serveAsync(h, request("a"))
+	deadline := time.Now().Add(time.Second)
+	for l.Snapshot().Waited == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The queue holds one waiter, so a third request is rejected at once.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request("a"))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 with full queue, got %d", w.Code)
+	}
+	if w := <-queued; w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 after max_wait, got %d", w.Code)
+	}
+
+	close(bh.release)
+	<-first
+	if snap := l.Snapshot(); snap.InFlight != 0 || snap.Rejected != 2 {
+		t.Errorf("expected 2 rejections and none in flight, got %+v", snap)
+	}
+}
+
+func TestLimiterEvictsIdleKeys(t *testing.T) {
+	l := New("r", config.ConcurrencyLimitConfig{MaxInFlight: 1, Key: "header:X-API-Key", MaxKeys: 3}, nil)
+	h := l.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 10; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), request(fmt.Sprintf("client-%d", i)))
+	}
+	snap := l.Snapshot()
+	if snap.TrackedKeys != 3 {
+		t.Errorf("expected 3 tracked keys, got %d", snap.TrackedKeys)
+	}
+	if snap.Evictions != 7 {
+		t.Errorf("expected 7 evictions, got %d", snap.Evictions)
+	}
+}
+
+func TestLimiterKeepsBusyKeys(t *testing.T) {
+	l := New("r", config.ConcurrencyLimitConfig{MaxInFlight: 1, Key: "header:X-API-Key", MaxKeys: 1}, nil)
+	bh := newBlockingHandler()
+	h := l.Middleware()(bh)
+
+	busy := serveAsync(h, request("busy"))
+	<-bh.started
+	other := serveAsync(h, request("other"))
+	<-bh.started
+
+	// "busy" could not be evicted while in flight, so its limit still holds.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request("busy"))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 for busy key, got %d", w.Code)
+	}
+	if snap := l.Snapshot(); snap.Evictions != 0 {
+		t.Errorf("expected no evictions of busy keys, got %d", snap.Evictions)
+	}
+	close(bh.release)
+	<-busy
+	<-other
+}
+
+// TestLimiterClientDisconnectReleasesPermit checks that a client hanging up
+// mid-request, whether in flight or queued, does not leak permits.
+func TestLimiterClientDisconnectReleasesPermit(t *testing.T) {
+	l := New("r", config.ConcurrencyLimitConfig{MaxInFlight: 1, Key: "header:X-API-Key", MaxWait: 5 * time.Second}, nil)
+	bh := newBlockingHandler()
+	srv := httptest.NewServer(l.Middleware()(bh))
+	defer srv.Close()
+
+	send := func(ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		req.Header.Set("X-API-Key", "a")
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	inFlightCtx, cancelInFlight := context.WithCancel(context.Background())
+	queuedCtx, cancelQueued := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); send(inFlightCtx) }()
+	<-bh.started
+	go func() { defer wg.Done(); send(queuedCtx) }()
+	deadline := time.Now().Add(time.Second)
+	for l.Snapshot().Waited == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancelQueued()
+	cancelInFlight()
+	wg.Wait()
+
+	deadline = time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if snap := l.Snapshot(); snap.InFlight == 0 && len(snap.TopKeys) == 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if snap := l.Snapshot(); snap.InFlight != 0 || len(snap.TopKeys) != 0 {
+		t.Fatalf("expected permits released after disconnect, got %+v", snap)
+	}
+
+	// The key can take a new request.
+	close(bh.release)
+	if err := send(context.Background()); err != nil {
+		t.Fatalf("expected request to be admitted, got %v", err)
+	}
+}
+
+func TestLimiterDistributedFailOpen(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr:        "localhost:1", // unlikely to have Redis here
+		DialTimeout: 50 * time.Millisecond,
+	})
+	defer client.Close()
+
+	l := New("r", config.ConcurrencyLimitConfig{MaxInFlight: 1, Mode: "distributed"}, client)
+	called := false
+	h := l.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !called || w.Code != http.StatusOK {
+		t.Errorf("expected fail-open, got called=%v code=%d", called, w.Code)
+	}
+	snap := l.Snapshot()
+	if snap.Mode != "distributed" || snap.FailOpen != 1 || snap.InFlight != 0 {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+}
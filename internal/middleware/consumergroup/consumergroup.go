@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"sync"
 	"sync/atomic"
 
 	"github.com/wudi/runway/internal/byroute"
@@ -15,10 +14,46 @@ import (
 
 type contextKey struct{}
 
+// Limits whose rejections are counted per group (see CountRejection).
+const (
+	LimitRateLimit = "rate_limit"
+	LimitQuota     = "quota"
+	LimitPriority  = "priority"
+)
+
 // GroupInfo contains the resolved consumer group stored in request context.
 type GroupInfo struct {
 	Name  string
 	Group *config.ConsumerGroup
+
+	counters *groupCounters // nil for infos not resolved by a GroupManager
+}
+
+// groupCounters counts the requests of one group.
+type groupCounters struct {
+	requests atomic.Int64
+	rejected map[string]*atomic.Int64 // by limit; fixed at construction
+}
+
+func newGroupCounters() *groupCounters {
+	return &groupCounters{rejected: map[string]*atomic.Int64{
+		LimitRateLimit: {},
+		LimitQuota:     {},
+		LimitPriority:  {},
+	}}
+}
+
+// CountRejection counts a request rejected by limit (LimitRateLimit,
+// LimitQuota or LimitPriority) against the request's consumer group.
+// Requests without a group are not counted.
+func CountRejection(r *http.Request, limit string) {
+	info := FromContext(r.Context())
+	if info == nil || info.counters == nil {
+		return
+	}
+	if c, ok := info.counters.rejected[limit]; ok {
+		c.Add(1)
+	}
 }
 
 // WithGroup stores GroupInfo in a context.
@@ -34,30 +69,41 @@ func FromContext(ctx context.Context) *GroupInfo {
 
 // GroupManager manages consumer group resolution and per-group metrics.
 type GroupManager struct {
-	groups           map[string]*config.ConsumerGroup
-	totalRequests    atomic.Int64
-	perGroupRequests sync.Map // string -> *atomic.Int64
+	groups             map[string]*config.ConsumerGroup
+	counters           map[string]*groupCounters
+	clients            map[string]string
+	claim              string
+	totalRequests      atomic.Int64
+	unresolvedRequests atomic.Int64
 }
 
 // NewGroupManager creates a GroupManager from config.
 func NewGroupManager(cfg config.ConsumerGroupsConfig) *GroupManager {
-	groups := make(map[string]*config.ConsumerGroup, len(cfg.Groups))
-	m := &GroupManager{groups: groups}
+	m := &GroupManager{
+		groups:   make(map[string]*config.ConsumerGroup, len(cfg.Groups)),
+		counters: make(map[string]*groupCounters, len(cfg.Groups)),
+		clients:  cfg.Clients,
+		claim:    cfg.GroupClaim(),
+	}
 	for name, g := range cfg.Groups {
 		g := g // copy for pointer stability
-		groups[name] = &g
-		m.perGroupRequests.Store(name, &atomic.Int64{})
+		m.groups[name] = &g
+		m.counters[name] = newGroupCounters()
 	}
 	return m
 }
 
-// Middleware returns a middleware that resolves the consumer group from identity claims.
+// Middleware returns a middleware that resolves the consumer group of the
+// authenticated identity, stores it in the request context and sets
+// $consumer_group.
 //
 // Resolution order:
-//  1. Claims["consumer_group"] (string) -- explicit assignment
-//  2. Claims["roles"] ([]interface{} of strings) -- first role matching a defined group
+//  1. consumer_groups.clients[ClientID] -- explicit mapping in config
+//  2. Claims[claim] (string) -- explicit assignment, claim "consumer_group" by default
+//  3. Claims["roles"] (API key roles or a JWT roles claim) -- first role matching a defined group
 //
-// If no group is found the request passes through without setting context.
+// If no group is found the request passes through without setting context,
+// and the route's own limits apply.
 func (gm *GroupManager) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -69,16 +115,17 @@ func (gm *GroupManager) Middleware() middleware.Middleware {
 
 			name, group := gm.resolve(v.Identity)
 			if group == nil {
+				gm.unresolvedRequests.Add(1)
 				next.ServeHTTP(w, r)
 				return
 			}
 
 			gm.totalRequests.Add(1)
-			if counter, ok := gm.perGroupRequests.Load(name); ok {
-				counter.(*atomic.Int64).Add(1)
-			}
+			counters := gm.counters[name]
+			counters.requests.Add(1)
+			v.ConsumerGroup = name
 
-			info := &GroupInfo{Name: name, Group: group}
+			info := &GroupInfo{Name: name, Group: group, counters: counters}
 			ctx := WithGroup(r.Context(), info)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -87,12 +134,21 @@ func (gm *GroupManager) Middleware() middleware.Middleware {
 
 // resolve determines the consumer group for an identity.
 func (gm *GroupManager) resolve(id *variables.Identity) (string, *config.ConsumerGroup) {
+	// 1. Explicit client mapping
+	if id.ClientID != "" {
+		if name, ok := gm.clients[id.ClientID]; ok {
+			if g, exists := gm.groups[name]; exists {
+				return name, g
+			}
+		}
+	}
+
 	if id.Claims == nil {
 		return "", nil
 	}
 
-	// 1. Explicit consumer_group claim
-	if cg, ok := id.Claims["consumer_group"]; ok {
+	// 2. Group claim
+	if cg, ok := id.Claims[gm.claim]; ok {
 		if name, ok := cg.(string); ok {
 			if g, exists := gm.groups[name]; exists {
 				return name, g
@@ -100,14 +156,19 @@ func (gm *GroupManager) resolve(id *variables.Identity) (string, *config.Consume
 		}
 	}
 
-	// 2. First matching role
-	if roles, ok := id.Claims["roles"]; ok {
-		if roleSlice, ok := roles.([]interface{}); ok {
-			for _, role := range roleSlice {
-				if name, ok := role.(string); ok {
-					if g, exists := gm.groups[name]; exists {
-						return name, g
-					}
+	// 3. First matching role: []string from API keys, []interface{} from JWTs
+	switch roles := id.Claims["roles"].(type) {
+	case []string:
+		for _, name := range roles {
+			if g, exists := gm.groups[name]; exists {
+				return name, g
+			}
+		}
+	case []interface{}:
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				if g, exists := gm.groups[name]; exists {
+					return name, g
 				}
 			}
 		}
@@ -134,16 +195,24 @@ func (gm *GroupManager) Stats() map[string]interface{} {
 		if len(g.Metadata) > 0 {
 			gs["metadata"] = g.Metadata
 		}
-		if counter, ok := gm.perGroupRequests.Load(name); ok {
-			gs["requests"] = counter.(*atomic.Int64).Load()
+		if c := gm.counters[name]; c != nil {
+			rejected := make(map[string]int64, len(c.rejected))
+			for limit, n := range c.rejected {
+				rejected[limit] = n.Load()
+			}
+			gs["requests"] = c.requests.Load()
+			gs["rejected"] = rejected
 		}
 		groupStats[name] = gs
 	}
 	return map[string]interface{}{
-		"enabled":        true,
-		"group_count":    len(gm.groups),
-		"total_requests": gm.totalRequests.Load(),
-		"groups":         groupStats,
+		"enabled":             true,
+		"group_count":         len(gm.groups),
+		"client_mappings":     len(gm.clients),
+		"claim":               gm.claim,
+		"total_requests":      gm.totalRequests.Load(),
+		"unresolved_requests": gm.unresolvedRequests.Load(),
+		"groups":              groupStats,
 	}
 }
 
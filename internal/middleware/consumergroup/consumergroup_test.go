@@ -4,7 +4,6 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
-	"sync/atomic"
 	"testing"
 
 	"github.com/wudi/runway/config"
@@ -169,23 +168,74 @@ func TestPerGroupRequestCounting(t *testing.T) {
 		t.Errorf("expected total=8, got %d", gm.totalRequests.Load())
 	}
 
-	premiumCounter, ok := gm.perGroupRequests.Load("premium")
-	if !ok {
-		t.Fatal("expected counter for premium")
+	if n := gm.counters["premium"].requests.Load(); n != 5 {
+		t.Errorf("expected premium=5, got %d", n)
 	}
-	if premiumCounter.(*atomic.Int64).Load() != 5 {
-		t.Errorf("expected premium=5, got %d", premiumCounter.(*atomic.Int64).Load())
+	if n := gm.counters["standard"].requests.Load(); n != 3 {
+		t.Errorf("expected standard=3, got %d", n)
 	}
+}
 
-	standardCounter, ok := gm.perGroupRequests.Load("standard")
-	if !ok {
-		t.Fatal("expected counter for standard")
+func TestGroupResolution(t *testing.T) {
+	cfg := testConfig()
+	cfg.Clients = map[string]string{"partner-app": "premium"}
+	cfg.Claim = "plan"
+	gm := NewGroupManager(cfg)
+
+	tests := []struct {
+		name     string
+		clientID string
+		claims   map[string]interface{}
+		want     string
+	}{
+		{"client mapping wins", "partner-app", map[string]interface{}{"plan": "standard"}, "premium"},
+		{"client mapping without claims", "partner-app", nil, "premium"},
+		{"configured claim", "c1", map[string]interface{}{"plan": "standard"}, "standard"},
+		{"default claim ignored", "c1", map[string]interface{}{"consumer_group": "premium"}, ""},
+		{"api key roles", "c1", map[string]interface{}{"roles": []string{"admin", "standard"}}, "standard"},
+		{"jwt roles", "c1", map[string]interface{}{"roles": []interface{}{"premium"}}, "premium"},
+		{"unknown", "c1", map[string]interface{}{"roles": []string{"admin"}}, ""},
 	}
-	if standardCounter.(*atomic.Int64).Load() != 3 {
-		t.Errorf("expected standard=3, got %d", standardCounter.(*atomic.Int64).Load())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, _ := gm.resolve(&variables.Identity{ClientID: tt.clientID, Claims: tt.claims})
+			if name != tt.want {
+				t.Errorf("resolve = %q, want %q", name, tt.want)
+			}
+		})
 	}
 }
 
+func TestGroupVariableAndRejections(t *testing.T) {
+	gm := NewGroupManager(testConfig())
+	var groups []string
+	handler := gm.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		groups = append(groups, variables.NewResolver().Resolve("$consumer_group", variables.GetFromRequest(r)))
+		CountRejection(r, LimitRateLimit)
+		CountRejection(r, LimitQuota)
+		CountRejection(r, LimitRateLimit)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), reqWithClaims(map[string]interface{}{"consumer_group": "standard"}))
+	handler.ServeHTTP(httptest.NewRecorder(), reqWithClaims(map[string]interface{}{"sub": "nobody"}))
+
+	if len(groups) != 2 || groups[0] != "standard" || groups[1] != "" {
+		t.Errorf("$consumer_group = %q, want [standard \"\"]", groups)
+	}
+	stats := gm.Stats()
+	if n := stats["unresolved_requests"].(int64); n != 1 {
+		t.Errorf("unresolved_requests = %d, want 1", n)
+	}
+	rejected := stats["groups"].(map[string]interface{})["standard"].(map[string]interface{})["rejected"].(map[string]int64)
+	if rejected[LimitRateLimit] != 2 || rejected[LimitQuota] != 1 || rejected[LimitPriority] != 0 {
+		t.Errorf("rejected = %v", rejected)
+	}
+
+	// Rejections of requests without a group are not counted anywhere.
+	CountRejection(httptest.NewRequest("GET", "/", nil), LimitRateLimit)
+}
+
 func TestStats(t *testing.T) {
 	gm := NewGroupManager(testConfig())
 	mw := gm.Middleware()
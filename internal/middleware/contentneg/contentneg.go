@@ -11,6 +11,7 @@ import (
 	"strings"
 	"sync/atomic"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/goccy/go-yaml"
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
@@ -25,6 +26,7 @@ type Negotiator struct {
 	jsonCount      atomic.Int64
 	xmlCount       atomic.Int64
 	yamlCount      atomic.Int64
+	cborCount      atomic.Int64
 	notAcceptable  atomic.Int64
 }
 
@@ -33,7 +35,7 @@ func New(cfg config.ContentNegotiationConfig, outputEncoding ...string) (*Negoti
 	supported := make(map[string]bool, len(cfg.Supported))
 	for _, f := range cfg.Supported {
 		switch f {
-		case "json", "xml", "yaml":
+		case "json", "xml", "yaml", "cbor":
 			supported[f] = true
 		default:
 			return nil, fmt.Errorf("unsupported content negotiation format: %s", f)
@@ -107,6 +109,10 @@ func (n *Negotiator) Middleware() middleware.Middleware {
 				encoded, err = jsonToYAML(body)
 				contentType = "application/yaml; charset=utf-8"
 				n.yamlCount.Add(1)
+			case "cbor":
+				encoded, err = jsonToCBOR(body)
+				contentType = "application/cbor"
+				n.cborCount.Add(1)
 			case "json-collection":
 				encoded, err = jsonCollectionExtract(body)
 				contentType = "application/json; charset=utf-8"
@@ -212,6 +218,8 @@ func (n *Negotiator) negotiate(accept string) string {
 			format = "xml"
 		case "application/yaml", "text/yaml", "application/x-yaml":
 			format = "yaml"
+		case "application/cbor":
+			format = "cbor"
 		case "*/*":
 			format = n.defaultFmt
 		default:
@@ -312,6 +320,52 @@ func jsonToYAML(data []byte) ([]byte, error) {
 	return yaml.Marshal(parsed)
 }
 
+// cborEncMode encodes maps with sorted keys so identical JSON produces
+// identical CBOR.
+var cborEncMode = func() cbor.EncMode {
+	em, err := cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return em
+}()
+
+// jsonToCBOR converts JSON bytes to CBOR. Integral JSON numbers are encoded
+// as CBOR integers, all other numbers as floats.
+func jsonToCBOR(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var parsed interface{}
+	if err := dec.Decode(&parsed); err != nil {
+		return nil, err
+	}
+	return cborEncMode.Marshal(cborNumbers(parsed))
+}
+
+// cborNumbers replaces json.Number values with int64, uint64 or float64.
+func cborNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			val[k] = cborNumbers(child)
+		}
+	case []interface{}:
+		for i, child := range val {
+			val[i] = cborNumbers(child)
+		}
+	case json.Number:
+		if i, err := strconv.ParseInt(string(val), 10, 64); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(string(val), 10, 64); err == nil {
+			return u
+		}
+		f, _ := val.Float64()
+		return f
+	}
+	return v
+}
+
 // bodyBufferWriter captures the response for re-encoding.
 type bodyBufferWriter struct {
 	http.ResponseWriter
@@ -338,6 +392,7 @@ func (n *Negotiator) Stats() map[string]interface{} {
 		"json_count":      n.jsonCount.Load(),
 		"xml_count":       n.xmlCount.Load(),
 		"yaml_count":      n.yamlCount.Load(),
+		"cbor_count":      n.cborCount.Load(),
 		"not_acceptable":  n.notAcceptable.Load(),
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/wudi/runway/config"
 )
 
@@ -117,6 +118,41 @@ func TestNegotiator_YAMLConversion(t *testing.T) {
 	}
 }
 
+func TestNegotiator_CBORConversion(t *testing.T) {
+	n, err := New(config.ContentNegotiationConfig{
+		Enabled:   true,
+		Supported: []string{"json", "cbor"},
+		Default:   "json",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"alice","age":30,"score":1.5,"tags":["a"]}`))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/cbor")
+	w := httptest.NewRecorder()
+	n.Middleware()(inner).ServeHTTP(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/cbor" {
+		t.Fatalf("expected application/cbor, got %s", ct)
+	}
+	var decoded map[string]interface{}
+	if err := cbor.Unmarshal(w.Body.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid CBOR body: %v", err)
+	}
+	if decoded["name"] != "alice" || decoded["age"] != uint64(30) || decoded["score"] != 1.5 {
+		t.Errorf("unexpected CBOR content %v", decoded)
+	}
+	if n.Stats()["cbor_count"] != int64(1) {
+		t.Errorf("expected cbor_count=1, got %v", n.Stats()["cbor_count"])
+	}
+}
+
 func TestNegotiator_NotAcceptable(t *testing.T) {
 	cfg := config.ContentNegotiationConfig{
 		Enabled:   true,
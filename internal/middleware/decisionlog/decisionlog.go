@@ -0,0 +1,382 @@
+// Package decisionlog emits a structured record of the policy decisions
+// made for each request on routes with decision_log enabled. Decisions are
+// collected by the decision.Recorder the middleware attaches to the request
+// and written, keyed by request ID, to a rotating file or the audit
+// webhook when the request ends.
+package decisionlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/decision"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/auditlog"
+	"github.com/wudi/runway/variables"
+)
+
+// Sinks.
+const (
+	SinkFile         = "file"
+	SinkAuditWebhook = "audit_webhook"
+)
+
+// AuditEvent is the audit entry event of records sent to the audit webhook.
+const AuditEvent = "decision_record"
+
+const defaultBufferSize = 1000
+
+// Record is the decision record of one request.
+type Record struct {
+	SchemaVersion int              `json:"schema_version"`
+	Timestamp     string           `json:"timestamp"`
+	RequestID     string           `json:"request_id"`
+	RouteID       string           `json:"route_id"`
+	Method        string           `json:"method"`
+	Path          string           `json:"path"`
+	StatusCode    int              `json:"status_code"`
+	DurationMS    float64          `json:"duration_ms"`
+	Decisions     []decision.Entry `json:"decisions"`
+	Dropped       int              `json:"dropped_decisions,omitempty"` // decisions over max_entries
+}
+
+// Logger records the decisions of one route's requests.
+type Logger struct {
+	routeID    string
+	sink       string
+	maxEntries int
+	maxValue   int
+	hashKey    []byte
+
+	file  *fileSink             // sink file
+	audit *auditlog.AuditLogger // sink audit_webhook
+
+	recorded  atomic.Int64
+	dropped   atomic.Int64
+	closeOnce sync.Once
+}
+
+// New creates a Logger. audit is the route's effective audit_log config,
+// whose webhook receives the records with sink audit_webhook.
+func New(routeID string, cfg config.DecisionLogConfig, audit config.AuditLogConfig) (*Logger, error) {
+	l := &Logger{
+		routeID:    routeID,
+		sink:       cfg.Sink,
+		maxEntries: cfg.MaxEntries,
+		maxValue:   cfg.MaxValueLength,
+	}
+	if cfg.HashKey != "" {
+		l.hashKey = []byte(cfg.HashKey)
+	}
+	if l.sink == "" {
+		l.sink = SinkFile
+	}
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	switch l.sink {
+	case SinkAuditWebhook:
+		audit.Enabled = true
+		audit.BufferSize = bufferSize
+		audit.SampleRate = 1
+		l.audit = auditlog.New(routeID, audit)
+	default:
+		l.file = openFile(cfg.File, cfg.Rotation, bufferSize)
+	}
+	return l, nil
+}
+
+// Middleware attaches a decision recorder to each request and emits its
+// record when the request ends. Copies shadowed from another route are not
+// recorded.
+func (l *Logger) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if variables.IsShadow(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			rec := decision.NewRecorder(l.maxEntries, l.maxValue, l.hashKey)
+			// A debug trace of the request keeps seeing the decisions.
+			if outer := decision.FromContext(r.Context()); outer != nil {
+				rec.Forward(outer)
+			}
+			r = r.WithContext(decision.WithRecorder(r.Context(), rec))
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(sw, r)
+
+			varCtx := variables.GetFromRequest(r)
+			if varCtx.UpstreamAddr != "" {
+				rec.Add("backend", decision.Route,
+					"upstream", varCtx.UpstreamAddr,
+					"status", strconv.Itoa(varCtx.UpstreamStatus))
+			}
+			requestID := middleware.RequestIDFromContext(r.Context())
+			if requestID == "" {
+				requestID = middleware.GetRequestID(r)
+			}
+			entries, dropped := rec.Entries()
+			duration := time.Since(start)
+			l.emit(&Record{
+				SchemaVersion: decision.SchemaVersion,
+				Timestamp:     start.UTC().Format(time.RFC3339Nano),
+				RequestID:     requestID,
+				RouteID:       l.routeID,
+				Method:        r.Method,
+				Path:          r.URL.Path,
+				StatusCode:    sw.status,
+				DurationMS:    float64(duration.Nanoseconds()) / 1e6,
+				Decisions:     entries,
+				Dropped:       dropped,
+			}, duration)
+		})
+	}
+}
+
+func (l *Logger) emit(rec *Record, duration time.Duration) {
+	l.recorded.Add(1)
+	if l.audit != nil {
+		l.audit.Enqueue(&auditlog.AuditEntry{
+			Timestamp:  rec.Timestamp,
+			RequestID:  rec.RequestID,
+			RouteID:    rec.RouteID,
+			Method:     rec.Method,
+			Path:       rec.Path,
+			StatusCode: rec.StatusCode,
+			Duration:   duration,
+			DurationMS: rec.DurationMS,
+			Event:      AuditEvent,
+			Details:    map[string]interface{}{"record": rec},
+		})
+		return
+	}
+	if !l.file.enqueue(rec) {
+		l.dropped.Add(1)
+	}
+}
+
+// Close stops the route's sink. A record file shared with other routes
+// stays open until its last route closes.
+func (l *Logger) Close() {
+	l.closeOnce.Do(func() {
+		if l.audit != nil {
+			l.audit.Close()
+		}
+		if l.file != nil {
+			l.file.release()
+		}
+	})
+}
+
+// Stats returns the logger's counters.
+func (l *Logger) Stats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"sink":     l.sink,
+		"recorded": l.recorded.Load(),
+		"dropped":  l.dropped.Load(),
+	}
+	if l.file != nil {
+		stats["file"] = l.file.path
+		stats["write_errors"] = l.file.errors.Load()
+	}
+	if l.audit != nil {
+		stats["audit"] = l.audit.Stats()
+	}
+	return stats
+}
+
+// fileSink writes records as JSON lines to a rotating file. Routes logging
+// to the same file share one sink, across reloads too, so a single writer
+// owns the file and its rotation.
+type fileSink struct {
+	path   string
+	queue  chan *Record
+	out    *lumberjack.Logger
+	errors atomic.Int64
+	refs   int // guarded by filesMu
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+var (
+	filesMu sync.Mutex
+	files   = make(map[string]*fileSink)
+)
+
+func openFile(path string, rot config.LogRotationConfig, bufferSize int) *fileSink {
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	if fs, ok := files[path]; ok {
+		fs.refs++
+		return fs
+	}
+	fs := &fileSink{
+		path:  path,
+		queue: make(chan *Record, bufferSize),
+		out: &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    rot.MaxSize,
+			MaxBackups: rot.MaxBackups,
+			MaxAge:     rot.MaxAge,
+			Compress:   rot.Compress,
+			LocalTime:  rot.LocalTime,
+		},
+		refs: 1,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	files[path] = fs
+	go fs.writeLoop()
+	return fs
+}
+
+// enqueue queues rec without blocking. It reports false when the queue is
+// full.
+func (fs *fileSink) enqueue(rec *Record) bool {
+	select {
+	case fs.queue <- rec:
+		return true
+	default:
+		return false
+	}
+}
+
+func (fs *fileSink) writeLoop() {
+	defer close(fs.done)
+	w := bufio.NewWriter(fs.out)
+	enc := json.NewEncoder(w)
+	write := func(rec *Record) {
+		if err := enc.Encode(rec); err != nil {
+			fs.errors.Add(1)
+		}
+	}
+	flush := func() {
+		if err := w.Flush(); err != nil {
+			fs.errors.Add(1)
+			logging.Warn("decision log write failed", zap.String("file", fs.path), zap.Error(err))
+		}
+	}
+	for {
+		select {
+		case rec := <-fs.queue:
+			write(rec)
+			// Flush once the queue is drained, batching writes under load.
+			if len(fs.queue) == 0 {
+				flush()
+			}
+		case <-fs.stop:
+			for {
+				select {
+				case rec := <-fs.queue:
+					write(rec)
+				default:
+					flush()
+					fs.out.Close()
+					return
+				}
+			}
+		}
+	}
+}
+
+// release drops a route's reference, closing the file after the last one
+// once the queued records are written.
+func (fs *fileSink) release() {
+	filesMu.Lock()
+	fs.refs--
+	last := fs.refs == 0
+	if last {
+		delete(files, fs.path)
+	}
+	filesMu.Unlock()
+	if last {
+		close(fs.stop)
+		<-fs.done
+	}
+}
+
+// statusWriter captures the response status.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code >= 200 {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// DecisionLogByRoute manages per-route decision loggers.
+type DecisionLogByRoute struct {
+	byroute.Manager[*Logger]
+}
+
+// NewDecisionLogByRoute creates a new per-route decision log manager.
+func NewDecisionLogByRoute() *DecisionLogByRoute {
+	return &DecisionLogByRoute{}
+}
+
+// AddRoute adds a decision logger for a route. audit is the route's
+// effective audit_log config.
+func (m *DecisionLogByRoute) AddRoute(routeID string, cfg config.DecisionLogConfig, audit config.AuditLogConfig) error {
+	l, err := New(routeID, cfg, audit)
+	if err != nil {
+		return err
+	}
+	m.Add(routeID, l)
+	return nil
+}
+
+// Stats returns per-route decision log stats.
+func (m *DecisionLogByRoute) Stats() map[string]interface{} {
+	return byroute.CollectStats(&m.Manager, func(l *Logger) interface{} { return l.Stats() })
+}
+
+// CloseAll closes every route's logger.
+func (m *DecisionLogByRoute) CloseAll() {
+	byroute.ForEach(&m.Manager, (*Logger).Close)
+}
@@ -0,0 +1,128 @@
+package decisionlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/decision"
+	"github.com/wudi/runway/variables"
+)
+
+// decide is a handler recording one decision.
+func decide(stage, outcome string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rec := decision.FromContext(r.Context()); rec != nil {
+			rec.Add(stage, outcome, "key", rec.Hash("client-1"))
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+func readRecords(t *testing.T, path string) []Record {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestFileSink_SharedAcrossRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.log")
+	cfg := config.DecisionLogConfig{Enabled: true, File: path}
+	a, _ := New("a", cfg, config.AuditLogConfig{})
+	b, _ := New("b", cfg, config.AuditLogConfig{})
+	if a.file != b.file {
+		t.Fatal("routes logging to one file should share its sink")
+	}
+
+	for _, l := range []*Logger{a, b} {
+		req := httptest.NewRequest("POST", "/x", nil)
+		l.Middleware()(decide("quota", decision.Debit)).ServeHTTP(httptest.NewRecorder(), req)
+	}
+	a.Close()
+	a.Close() // idempotent
+
+	// b keeps the file open after a closes.
+	req := httptest.NewRequest("POST", "/x", nil)
+	b.Middleware()(decide("quota", decision.Deny)).ServeHTTP(httptest.NewRecorder(), req)
+	b.Close()
+
+	records := readRecords(t, path)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	if records[0].RouteID != "a" || records[1].RouteID != "b" || records[2].Decisions[0].Outcome != decision.Deny {
+		t.Errorf("unexpected records %+v", records)
+	}
+	if records[0].StatusCode != http.StatusAccepted {
+		t.Errorf("unexpected record header %+v", records[0])
+	}
+}
+
+func TestMiddleware_SkipsShadow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.log")
+	l, _ := New("r", config.DecisionLogConfig{Enabled: true, File: path}, config.AuditLogConfig{})
+	req := httptest.NewRequest("GET", "/", nil)
+	varCtx := variables.NewContext(req)
+	varCtx.ShadowOf = "r-v1"
+	req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+	l.Middleware()(decide("waf", decision.Allow)).ServeHTTP(httptest.NewRecorder(), req)
+	l.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected shadowed requests not to be recorded, stat: %v", err)
+	}
+}
+
+func TestAuditWebhookSink(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		entries []map[string]interface{}
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&batch)
+		mu.Lock()
+		entries = append(entries, batch...)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	l, _ := New("r", config.DecisionLogConfig{Enabled: true, Sink: SinkAuditWebhook},
+		config.AuditLogConfig{WebhookURL: hook.URL, SampleRate: 0.01})
+	req := httptest.NewRequest("GET", "/", nil)
+	l.Middleware()(decide("geo", decision.Allow)).ServeHTTP(httptest.NewRecorder(), req)
+	l.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(entries))
+	}
+	if entries[0]["event"] != AuditEvent {
+		t.Errorf("expected event %q, got %v", AuditEvent, entries[0]["event"])
+	}
+	record, _ := entries[0]["details"].(map[string]interface{})["record"].(map[string]interface{})
+	decisions, _ := record["decisions"].([]interface{})
+	if len(decisions) != 1 || decisions[0].(map[string]interface{})["stage"] != "geo" {
+		t.Errorf("unexpected record %v", record)
+	}
+}
@@ -0,0 +1,410 @@
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/storecrypt"
+)
+
+// contentDedupKey marks a request that has already been fingerprinted, so a
+// request re-entering the chain (e.g. a gateway-level retry) never matches
+// its own in-flight entry.
+type contentDedupKey struct{}
+
+// ContentDedup suppresses identical mutation requests (same method, path,
+// canonical body and configured headers) that arrive while the original is
+// in flight or within a short TTL after it completed. Unlike idempotency it
+// needs no client-supplied key.
+type ContentDedup struct {
+	ttl            time.Duration
+	waitTimeout    time.Duration
+	methods        map[string]bool
+	includeHeaders []string
+	maxBodySize    int64
+	reject         bool
+	mode           string
+	store          Store
+	claims         *redisClaims // nil in local mode
+	metrics        ContentDedupMetrics
+
+	mu       sync.Mutex
+	inflight map[string]*inflightEntry
+}
+
+// ContentDedupMetrics tracks content dedup statistics.
+type ContentDedupMetrics struct {
+	TotalRequests   atomic.Int64
+	Hits            atomic.Int64
+	Misses          atomic.Int64
+	Replays         atomic.Int64
+	Rejected        atomic.Int64
+	Skipped         atomic.Int64
+	StoreErrors     atomic.Int64
+	ResponsesStored atomic.Int64
+}
+
+// ContentDedupStatus is the admin API representation.
+type ContentDedupStatus struct {
+	TTL             string   `json:"ttl"`
+	Methods         []string `json:"methods"`
+	IncludeHeaders  []string `json:"include_headers"`
+	MaxBodySize     int64    `json:"max_body_size"`
+	OnDuplicate     string   `json:"on_duplicate"`
+	Mode            string   `json:"mode"`
+	TotalRequests   int64    `json:"total_requests"`
+	Hits            int64    `json:"hits"`
+	Misses          int64    `json:"misses"`
+	Replays         int64    `json:"replays"`
+	Rejected        int64    `json:"rejected"`
+	Skipped         int64    `json:"skipped"`
+	StoreErrors     int64    `json:"store_errors"`
+	ResponsesStored int64    `json:"responses_stored"`
+}
+
+// NewContentDedup creates a ContentDedup from config. keys seals the stored
+// responses when the route sets encrypt.
+func NewContentDedup(routeID string, cfg config.ContentDedupConfig, redisClient *redis.Client, keys *storecrypt.Keyring) (*ContentDedup, error) {
+	ttl := cfg.TTL
+	if ttl == 0 {
+		ttl = 10 * time.Second
+	}
+	waitTimeout := cfg.WaitTimeout
+	if waitTimeout == 0 {
+		waitTimeout = 10 * time.Second
+	}
+	maxBodySize := cfg.MaxBodySize
+	if maxBodySize == 0 {
+		maxBodySize = 1 << 20 // 1MB
+	}
+	mode := cfg.Mode
+	if mode == "" {
+		mode = "local"
+	}
+
+	methods := cfg.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+	methodSet := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		methodSet[strings.ToUpper(m)] = true
+	}
+
+	headers := make([]string, len(cfg.IncludeHeaders))
+	for i, h := range cfg.IncludeHeaders {
+		headers[i] = strings.ToLower(h)
+	}
+	sort.Strings(headers)
+
+	cd := &ContentDedup{
+		ttl:            ttl,
+		waitTimeout:    waitTimeout,
+		methods:        methodSet,
+		includeHeaders: headers,
+		maxBodySize:    maxBodySize,
+		reject:         cfg.OnDuplicate == "reject",
+		mode:           mode,
+		inflight:       make(map[string]*inflightEntry),
+	}
+	if mode == "distributed" && redisClient != nil {
+		prefix := "gw:cdedup:" + routeID + ":"
+		if !cfg.Encrypt {
+			keys = nil
+		}
+		cd.store = NewRedisStore(redisClient, prefix, keys)
+		cd.claims = &redisClaims{client: redisClient, prefix: prefix + "inflight:"}
+	} else {
+		cd.store = NewMemoryStore(ttl)
+	}
+	return cd, nil
+}
+
+// fingerprint hashes method, path, query, configured headers and the
+// canonicalized body.
+func (cd *ContentDedup) fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.RawQuery))
+	for _, name := range cd.includeHeaders {
+		for _, v := range r.Header.Values(name) {
+			h.Write([]byte{0})
+			h.Write([]byte(name))
+			h.Write([]byte{':'})
+			h.Write([]byte(v))
+		}
+	}
+	h.Write([]byte{0})
+	h.Write(canonicalBody(r.Header.Get("Content-Type"), body))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// canonicalBody normalizes JSON (key order, whitespace) and form bodies (key
+// order) so semantically identical payloads share a fingerprint. Other
+// content types are hashed as-is.
+func canonicalBody(contentType string, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	ct := strings.ToLower(contentType)
+	switch {
+	case strings.Contains(ct, "json"):
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil || dec.More() {
+			return body
+		}
+		// encoding/json writes map keys in sorted order
+		if out, err := json.Marshal(v); err == nil {
+			return out
+		}
+	case strings.HasPrefix(ct, "application/x-www-form-urlencoded"):
+		if vals, err := url.ParseQuery(string(body)); err == nil {
+			return []byte(vals.Encode())
+		}
+	}
+	return body
+}
+
+// Middleware returns a middleware that replays (or rejects) duplicate
+// mutation requests instead of forwarding them.
+func (cd *ContentDedup) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !cd.methods[r.Method] || r.Context().Value(contentDedupKey{}) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cd.metrics.TotalRequests.Add(1)
+
+			var body []byte
+			if r.Body != nil && r.Body != http.NoBody {
+				var err error
+				body, err = io.ReadAll(io.LimitReader(r.Body, cd.maxBodySize+1))
+				if err != nil || int64(len(body)) > cd.maxBodySize {
+					// Too large (or unreadable) to fingerprint — forward untouched
+					cd.metrics.Skipped.Add(1)
+					r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+					next.ServeHTTP(w, r)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			fp := cd.fingerprint(r, body)
+			r = r.WithContext(context.WithValue(r.Context(), contentDedupKey{}, fp))
+
+			stored, err := cd.store.Get(r.Context(), fp)
+			if err != nil {
+				cd.metrics.StoreErrors.Add(1) // fail-open
+			}
+			if stored != nil {
+				cd.duplicate(w, stored)
+				return
+			}
+
+			cd.mu.Lock()
+			if entry, ok := cd.inflight[fp]; ok {
+				cd.mu.Unlock()
+				cd.waitLocal(w, r, entry)
+				return
+			}
+			entry := &inflightEntry{done: make(chan struct{})}
+			cd.inflight[fp] = entry
+			cd.mu.Unlock()
+
+			// In distributed mode another instance may already own the request
+			if cd.claims != nil && !cd.claims.claim(r.Context(), fp, cd.waitTimeout) {
+				cd.finish(fp, entry, cd.waitRemote(w, r, fp))
+				return
+			}
+
+			cd.metrics.Misses.Add(1)
+			cw := newCapturingWriter(w)
+			completed := false
+			defer func() {
+				// Waiters see the original's outcome; later duplicates only
+				// replay non-5xx responses so a client retry after a backend
+				// failure is forwarded again. A panic publishes nothing.
+				var resp *StoredResponse
+				if completed {
+					resp = cw.toStoredResponse()
+					if resp.StatusCode < 500 {
+						if err := cd.store.Set(context.Background(), fp, resp, cd.ttl); err != nil {
+							cd.metrics.StoreErrors.Add(1)
+						} else {
+							cd.metrics.ResponsesStored.Add(1)
+						}
+					}
+				}
+				if cd.claims != nil {
+					cd.claims.release(fp)
+				}
+				cd.finish(fp, entry, resp)
+			}()
+			next.ServeHTTP(cw, r)
+			completed = true
+		})
+	}
+}
+
+// finish publishes the outcome to local waiters and clears the in-flight entry.
+func (cd *ContentDedup) finish(fp string, entry *inflightEntry, resp *StoredResponse) {
+	cd.mu.Lock()
+	entry.resp = resp
+	close(entry.done)
+	delete(cd.inflight, fp)
+	cd.mu.Unlock()
+}
+
+// duplicate answers a duplicate request with the stored response or a 409.
+func (cd *ContentDedup) duplicate(w http.ResponseWriter, resp *StoredResponse) {
+	cd.metrics.Hits.Add(1)
+	if cd.reject || resp == nil {
+		cd.metrics.Rejected.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error":"duplicate request"}`))
+		return
+	}
+	cd.metrics.Replays.Add(1)
+	replayResponse(w, resp)
+}
+
+// waitLocal handles a duplicate of a request in flight on this instance.
+func (cd *ContentDedup) waitLocal(w http.ResponseWriter, r *http.Request, entry *inflightEntry) {
+	if cd.reject {
+		cd.duplicate(w, nil)
+		return
+	}
+	timer := time.NewTimer(cd.waitTimeout)
+	defer timer.Stop()
+	select {
+	case <-entry.done:
+		cd.duplicate(w, entry.resp)
+	case <-timer.C:
+		cd.duplicate(w, nil)
+	case <-r.Context().Done():
+		http.Error(w, "request cancelled", http.StatusGatewayTimeout)
+	}
+}
+
+// waitRemote handles a duplicate of a request in flight on another instance
+// by polling the shared store for its response. It returns the replayed
+// response, or nil if none was written.
+func (cd *ContentDedup) waitRemote(w http.ResponseWriter, r *http.Request, fp string) *StoredResponse {
+	if cd.reject {
+		cd.duplicate(w, nil)
+		return nil
+	}
+	deadline := time.Now().Add(cd.waitTimeout)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for time.Now().Before(deadline) {
+		select {
+		case <-ticker.C:
+			if stored, _ := cd.store.Get(r.Context(), fp); stored != nil {
+				cd.duplicate(w, stored)
+				return stored
+			}
+		case <-r.Context().Done():
+			http.Error(w, "request cancelled", http.StatusGatewayTimeout)
+			return nil
+		}
+	}
+	cd.duplicate(w, nil)
+	return nil
+}
+
+// Status returns the admin status snapshot.
+func (cd *ContentDedup) Status() ContentDedupStatus {
+	methods := make([]string, 0, len(cd.methods))
+	for m := range cd.methods {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	onDuplicate := "replay"
+	if cd.reject {
+		onDuplicate = "reject"
+	}
+	return ContentDedupStatus{
+		TTL:             cd.ttl.String(),
+		Methods:         methods,
+		IncludeHeaders:  cd.includeHeaders,
+		MaxBodySize:     cd.maxBodySize,
+		OnDuplicate:     onDuplicate,
+		Mode:            cd.mode,
+		TotalRequests:   cd.metrics.TotalRequests.Load(),
+		Hits:            cd.metrics.Hits.Load(),
+		Misses:          cd.metrics.Misses.Load(),
+		Replays:         cd.metrics.Replays.Load(),
+		Rejected:        cd.metrics.Rejected.Load(),
+		Skipped:         cd.metrics.Skipped.Load(),
+		StoreErrors:     cd.metrics.StoreErrors.Load(),
+		ResponsesStored: cd.metrics.ResponsesStored.Load(),
+	}
+}
+
+// Close releases store resources.
+func (cd *ContentDedup) Close() {
+	cd.store.Close()
+}
+
+// readCloser pairs a reader with the original body's Close.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// redisClaims marks fingerprints as in flight across instances.
+type redisClaims struct {
+	client *redis.Client
+	prefix string
+}
+
+// claim reports whether this instance now owns fp. Redis errors fail open.
+func (c *redisClaims) claim(ctx context.Context, fp string, ttl time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	ok, err := c.client.SetNX(ctx, c.prefix+fp, 1, ttl).Result()
+	return ok || err != nil
+}
+
+func (c *redisClaims) release(fp string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	c.client.Del(ctx, c.prefix+fp)
+}
+
+// ContentDedupByRoute manages per-route content dedup handlers.
+type ContentDedupByRoute = byroute.NamedFactory[*ContentDedup, config.ContentDedupConfig]
+
+// NewContentDedupByRoute creates a new ContentDedupByRoute manager.
+// The redis client and keyring are captured in the constructor closure.
+func NewContentDedupByRoute(redisClient *redis.Client, keys *storecrypt.Keyring) *ContentDedupByRoute {
+	return byroute.NewNamedFactory(
+		func(routeID string, cfg config.ContentDedupConfig) (*ContentDedup, error) {
+			return NewContentDedup(routeID, cfg, redisClient, keys)
+		},
+		func(cd *ContentDedup) any { return cd.Status() },
+	).WithClose((*ContentDedup).Close)
+}
@@ -0,0 +1,267 @@
+package dedup
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func newTestContentDedup(t *testing.T, cfg config.ContentDedupConfig) *ContentDedup {
+	t.Helper()
+	cfg.Enabled = true
+	cd, err := NewContentDedup("test", cfg, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cd.Close)
+	return cd
+}
+
+func postJSON(body string) *http.Request {
+	r := httptest.NewRequest("POST", "/webhooks", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestContentDedup_CanonicalFingerprint(t *testing.T) {
+	cd := newTestContentDedup(t, config.ContentDedupConfig{IncludeHeaders: []string{"X-Tenant"}})
+
+	fp := func(r *http.Request) string {
+		body, _ := io.ReadAll(r.Body)
+		return cd.fingerprint(r, body)
+	}
+
+	a := fp(postJSON(`{"id": 1, "event": "paid", "amount": 10.50}`))
+	b := fp(postJSON(`{"amount":10.50,"event":"paid","id":1}`))
+	if a != b {
+		t.Error("expected JSON key order and whitespace to be ignored")
+	}
+	if a == fp(postJSON(`{"amount":10.5,"event":"paid","id":1}`)) {
+		t.Error("expected number representation to be preserved")
+	}
+
+	form := func(body string) *http.Request {
+		r := httptest.NewRequest("POST", "/webhooks", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+	if fp(form("b=2&a=1")) != fp(form("a=1&b=2")) {
+		t.Error("expected form key order to be ignored")
+	}
+
+	r1 := postJSON(`{}`)
+	r1.Header.Set("X-Tenant", "a")
+	r2 := postJSON(`{}`)
+	r2.Header.Set("X-Tenant", "b")
+	if fp(r1) == fp(r2) {
+		t.Error("expected included header to change the fingerprint")
+	}
+}
+
+func TestContentDedup_ReplaysCompletedDuplicate(t *testing.T) {
+	cd := newTestContentDedup(t, config.ContentDedupConfig{})
+
+	var calls atomic.Int32
+	handler := cd.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Order", "42")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, postJSON(`{"event":"paid"}`))
+		if rec.Code != http.StatusCreated || rec.Body.String() != `{"event":"paid"}` || rec.Header().Get("X-Order") != "42" {
+			t.Fatalf("request %d: unexpected response %d %q", i, rec.Code, rec.Body.String())
+		}
+		if i == 1 && rec.Header().Get("X-Dedup-Replayed") != "true" {
+			t.Error("expected duplicate to be marked as replayed")
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected backend called once, got %d", calls.Load())
+	}
+
+	// GET is never deduplicated
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/webhooks", nil))
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected GETs forwarded, got %d calls", calls.Load())
+	}
+
+	st := cd.Status()
+	if st.Hits != 1 || st.Misses != 1 || st.Replays != 1 || st.TotalRequests != 2 {
+		t.Errorf("unexpected status: %+v", st)
+	}
+}
+
+func TestContentDedup_ConcurrentInflight(t *testing.T) {
+	for _, mode := range []string{"replay", "reject"} {
+		t.Run(mode, func(t *testing.T) {
+			cd := newTestContentDedup(t, config.ContentDedupConfig{OnDuplicate: mode})
+
+			var calls atomic.Int32
+			release := make(chan struct{})
+			started := make(chan struct{})
+			handler := cd.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					close(started)
+				}
+				<-release
+				w.WriteHeader(http.StatusAccepted)
+			}))
+
+			var wg sync.WaitGroup
+			first := httptest.NewRecorder()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				handler.ServeHTTP(first, postJSON(`{"a":1}`))
+			}()
+			<-started
+
+			dup := httptest.NewRecorder()
+			dupDone := make(chan struct{})
+			go func() {
+				defer close(dupDone)
+				handler.ServeHTTP(dup, postJSON(`{ "a" : 1 }`))
+			}()
+			if mode == "reject" {
+				<-dupDone // rejected without waiting for the original
+			} else {
+				time.Sleep(20 * time.Millisecond) // let the duplicate start waiting
+			}
+			close(release)
+			wg.Wait()
+			<-dupDone
+
+			if calls.Load() != 1 {
+				t.Errorf("expected one backend call, got %d", calls.Load())
+			}
+			want := http.StatusAccepted
+			if mode == "reject" {
+				want = http.StatusConflict
+			}
+			if dup.Code != want {
+				t.Errorf("expected duplicate status %d, got %d", want, dup.Code)
+			}
+		})
+	}
+}
+
+func TestContentDedup_ServerErrorsNotStored(t *testing.T) {
+	cd := newTestContentDedup(t, config.ContentDedupConfig{})
+
+	var calls atomic.Int32
+	handler := cd.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, postJSON(`{"a":1}`))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", rec.Code)
+	}
+
+	// A client retry after a 5xx is forwarded again
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, postJSON(`{"a":1}`))
+	if rec.Code != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("expected retry forwarded, got %d after %d calls", rec.Code, calls.Load())
+	}
+}
+
+func TestContentDedup_GatewayRetryDoesNotSelfDedup(t *testing.T) {
+	cd := newTestContentDedup(t, config.ContentDedupConfig{})
+
+	// The backend handler is wrapped by the dedup middleware; the retrying
+	// handler re-enters the same chain with the same request, as a
+	// gateway-level retry would.
+	var calls atomic.Int32
+	var chain http.Handler
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		if calls.Add(1) == 1 {
+			rec := httptest.NewRecorder()
+			chain.ServeHTTP(rec, r) // retry
+			w.WriteHeader(rec.Code)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	chain = cd.Middleware()(backend)
+
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		chain.ServeHTTP(rec, postJSON(`{"a":1}`))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("retry deadlocked waiting on its own in-flight entry")
+	}
+	if rec.Code != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("expected retry forwarded, got %d after %d calls", rec.Code, calls.Load())
+	}
+	if st := cd.Status(); st.Hits != 0 {
+		t.Errorf("expected no dedup hits for a retry, got %d", st.Hits)
+	}
+}
+
+func TestContentDedup_OversizedBodySkipped(t *testing.T) {
+	cd := newTestContentDedup(t, config.ContentDedupConfig{MaxBodySize: 8})
+
+	var calls atomic.Int32
+	var got []string
+	handler := cd.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, string(body))
+	}))
+
+	big := `{"data":"0123456789"}`
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), postJSON(big))
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected oversized requests forwarded, got %d calls", calls.Load())
+	}
+	if got[0] != big {
+		t.Errorf("expected full body forwarded, got %q", got[0])
+	}
+	if cd.Status().Skipped != 2 {
+		t.Errorf("expected 2 skipped, got %d", cd.Status().Skipped)
+	}
+}
+
+func TestContentDedup_TTLExpiry(t *testing.T) {
+	cd := newTestContentDedup(t, config.ContentDedupConfig{TTL: 50 * time.Millisecond})
+
+	var calls atomic.Int32
+	handler := cd.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), postJSON(`{"a":1}`))
+	time.Sleep(80 * time.Millisecond)
+	handler.ServeHTTP(httptest.NewRecorder(), postJSON(`{"a":1}`))
+	if calls.Load() != 2 {
+		t.Errorf("expected request after TTL forwarded, got %d calls", calls.Load())
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/storecrypt"
 )
 
 // CompiledDedup is a compiled per-route dedup handler created once during route setup.
@@ -66,8 +67,9 @@ type DedupStatus struct {
 	ResponsesStored int64    `json:"responses_stored"`
 }
 
-// New creates a new CompiledDedup from config.
-func New(routeID string, cfg config.RequestDedupConfig, redisClient *redis.Client) (*CompiledDedup, error) {
+// New creates a new CompiledDedup from config. keys seals the stored
+// responses when the route sets encrypt.
+func New(routeID string, cfg config.RequestDedupConfig, redisClient *redis.Client, keys *storecrypt.Keyring) (*CompiledDedup, error) {
 	ttl := cfg.TTL
 	if ttl == 0 {
 		ttl = 60 * time.Second
@@ -90,7 +92,10 @@ func New(routeID string, cfg config.RequestDedupConfig, redisClient *redis.Clien
 
 	var store Store
 	if mode == "distributed" && redisClient != nil {
-		store = NewRedisStore(redisClient, "gw:dedup:"+routeID+":")
+		if !cfg.Encrypt {
+			keys = nil
+		}
+		store = NewRedisStore(redisClient, "gw:dedup:"+routeID+":", keys)
 	} else {
 		store = NewMemoryStore(ttl)
 	}
@@ -309,11 +314,11 @@ func (w *capturingWriter) toStoredResponse() *StoredResponse {
 type DedupByRoute = byroute.NamedFactory[*CompiledDedup, config.RequestDedupConfig]
 
 // NewDedupByRoute creates a new DedupByRoute manager.
-// The redis client is captured in the constructor closure.
-func NewDedupByRoute(redisClient *redis.Client) *DedupByRoute {
+// The redis client and keyring are captured in the constructor closure.
+func NewDedupByRoute(redisClient *redis.Client, keys *storecrypt.Keyring) *DedupByRoute {
 	return byroute.NewNamedFactory(
 		func(routeID string, cfg config.RequestDedupConfig) (*CompiledDedup, error) {
-			return New(routeID, cfg, redisClient)
+			return New(routeID, cfg, redisClient, keys)
 		},
 		func(cd *CompiledDedup) any { return cd.Status() },
 	).WithClose((*CompiledDedup).Close)
@@ -17,7 +17,7 @@ func TestFingerprint(t *testing.T) {
 	cd, err := New("test", config.RequestDedupConfig{
 		Enabled:        true,
 		IncludeHeaders: []string{"X-Custom"},
-	}, nil)
+	}, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -72,7 +72,7 @@ func TestFingerprint(t *testing.T) {
 }
 
 func TestFingerprintBodyRestored(t *testing.T) {
-	cd, _ := New("test", config.RequestDedupConfig{Enabled: true}, nil)
+	cd, _ := New("test", config.RequestDedupConfig{Enabled: true}, nil, nil)
 	defer cd.Close()
 
 	body := "test body content"
@@ -98,7 +98,7 @@ func TestFingerprintNoBody(t *testing.T) {
 	cd, _ := New("test", config.RequestDedupConfig{
 		Enabled:     true,
 		IncludeBody: &f,
-	}, nil)
+	}, nil, nil)
 	defer cd.Close()
 
 	r1 := httptest.NewRequest("POST", "/test", strings.NewReader("hello"))
@@ -116,7 +116,7 @@ func TestMiddlewareDedup(t *testing.T) {
 	cd, _ := New("test", config.RequestDedupConfig{
 		Enabled: true,
 		TTL:     5 * time.Second,
-	}, nil)
+	}, nil, nil)
 	defer cd.Close()
 
 	callCount := 0
@@ -173,7 +173,7 @@ func TestMiddlewareConcurrentInflight(t *testing.T) {
 	cd, _ := New("test", config.RequestDedupConfig{
 		Enabled: true,
 		TTL:     5 * time.Second,
-	}, nil)
+	}, nil, nil)
 	defer cd.Close()
 
 	callCount := int32(0)
@@ -233,7 +233,7 @@ func TestMiddlewareWithBody(t *testing.T) {
 	cd, _ := New("test", config.RequestDedupConfig{
 		Enabled: true,
 		TTL:     5 * time.Second,
-	}, nil)
+	}, nil, nil)
 	defer cd.Close()
 
 	callCount := 0
@@ -273,7 +273,7 @@ func TestMiddlewareWithBody(t *testing.T) {
 }
 
 func TestDedupByRoute(t *testing.T) {
-	m := NewDedupByRoute(nil)
+	m := NewDedupByRoute(nil, nil)
 
 	err := m.AddRoute("route-1", config.RequestDedupConfig{
 		Enabled: true,
@@ -0,0 +1,534 @@
+// Package degraded implements per-route degraded mode: when a route's
+// upstream error budget is exhausted, live traffic stops and responses are
+// served from stale cache entries, a static fallback, or the error page until
+// the backend passes a probation of canary requests.
+package degraded
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/cache"
+	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/bufutil"
+	"github.com/wudi/runway/internal/middleware/slo"
+	"github.com/wudi/runway/variables"
+)
+
+// Route states.
+const (
+	StateNormal   = "normal"
+	StateDegraded = "degraded"
+)
+
+// Sources of responses served while degraded, used as metric labels.
+const (
+	SourceStale     = "stale"
+	SourceFallback  = "fallback"
+	SourceErrorPage = "error_page"
+	SourceCanary    = "canary"
+)
+
+// Admin overrides.
+const (
+	overrideNone int32 = iota
+	overrideForceDegraded
+	overrideForceNormal
+)
+
+const (
+	defaultMinRequests      = 20
+	defaultWindow           = 60 * time.Second
+	defaultSuccessThreshold = 5
+	defaultProbeInterval    = 5 * time.Second
+)
+
+// StaleLookup returns any cached entry for the request regardless of its age,
+// or nil when there is none.
+type StaleLookup func(r *http.Request) *cache.Entry
+
+// fallback is the compiled static fallback response.
+type fallback struct {
+	statusCode  int
+	body        []byte
+	contentType string
+	retryAfter  string
+	headers     map[string]string
+}
+
+// Controller tracks upstream 5xx responses for a route and switches it into
+// degraded mode when the configured thresholds are crossed.
+type Controller struct {
+	routeID          string
+	errorRate        float64
+	minRequests      int64
+	consecutiveLimit int64
+	successThreshold int64
+	probeInterval    time.Duration
+	windowDur        time.Duration
+	fallback         *fallback
+
+	mu          sync.Mutex
+	state       string
+	reason      string
+	window      *slo.SlidingWindow
+	consecutive int64
+	probation   int64
+	lastProbe   time.Time
+	enteredAt   time.Time
+
+	override     atomic.Int32
+	onTransition func(from, to, reason string)
+
+	transitions     atomic.Int64
+	canaries        atomic.Int64
+	servedStale     atomic.Int64
+	servedFallback  atomic.Int64
+	servedErrorPage atomic.Int64
+}
+
+// Snapshot is a point-in-time view of a controller.
+type Snapshot struct {
+	State               string     `json:"state"`
+	Override            string     `json:"override,omitempty"`
+	Reason              string     `json:"reason,omitempty"`
+	EnteredAt           *time.Time `json:"entered_at,omitempty"`
+	WindowRequests      int64      `json:"window_requests"`
+	WindowErrors        int64      `json:"window_errors"`
+	ConsecutiveFailures int64      `json:"consecutive_failures"`
+	ProbationSuccesses  int64      `json:"probation_successes"`
+	ProbationRequired   int64      `json:"probation_required"`
+	Transitions         int64      `json:"transitions"`
+	Canaries            int64      `json:"canaries"`
+	ServedStale         int64      `json:"served_stale"`
+	ServedFallback      int64      `json:"served_fallback"`
+	ServedErrorPage     int64      `json:"served_error_page"`
+}
+
+// New creates a Controller from config.
+func New(routeID string, cfg config.DegradedModeConfig) *Controller {
+	minRequests := cfg.MinRequests
+	if minRequests <= 0 {
+		minRequests = defaultMinRequests
+	}
+	window := cfg.Window
+	if window <= 0 {
+		window = defaultWindow
+	}
+	successThreshold := cfg.Probation.SuccessThreshold
+	if successThreshold <= 0 {
+		successThreshold = defaultSuccessThreshold
+	}
+	interval := cfg.Probation.Interval
+	if interval <= 0 {
+		interval = defaultProbeInterval
+	}
+
+	c := &Controller{
+		routeID:          routeID,
+		errorRate:        cfg.ErrorRateThreshold,
+		minRequests:      int64(minRequests),
+		consecutiveLimit: int64(cfg.ConsecutiveFailures),
+		successThreshold: int64(successThreshold),
+		probeInterval:    interval,
+		windowDur:        window,
+		state:            StateNormal,
+		window:           slo.NewSlidingWindow(window),
+	}
+
+	fb := cfg.Fallback
+	if fb.StatusCode != 0 || fb.Body != "" {
+		statusCode := fb.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusServiceUnavailable
+		}
+		contentType := fb.ContentType
+		if contentType == "" {
+			contentType = "text/html; charset=utf-8"
+		}
+		c.fallback = &fallback{
+			statusCode:  statusCode,
+			body:        []byte(fb.Body),
+			contentType: contentType,
+			retryAfter:  fb.RetryAfter,
+			headers:     fb.Headers,
+		}
+	}
+	return c
+}
+
+// SetOnTransition registers a callback invoked after every mode change.
+func (c *Controller) SetOnTransition(cb func(from, to, reason string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onTransition = cb
+}
+
+// State returns the effective mode, taking admin overrides into account.
+func (c *Controller) State() string {
+	switch c.override.Load() {
+	case overrideForceDegraded:
+		return StateDegraded
+	case overrideForceNormal:
+		return StateNormal
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// ForceDegraded pins the route in degraded mode until ResetOverride.
+func (c *Controller) ForceDegraded() {
+	c.override.Store(overrideForceDegraded)
+	c.transition(StateDegraded, "admin override")
+}
+
+// ForceNormal pins the route in normal mode until ResetOverride.
+func (c *Controller) ForceNormal() {
+	c.override.Store(overrideForceNormal)
+	c.transition(StateNormal, "admin override")
+}
+
+// ResetOverride returns the route to automatic mode selection. The error
+// window is cleared so the route starts from a clean slate.
+func (c *Controller) ResetOverride() {
+	c.override.Store(overrideNone)
+	c.transition(StateNormal, "admin reset")
+}
+
+// record accounts a live response seen in normal mode and trips into degraded
+// mode when a threshold is crossed.
+func (c *Controller) record(isError bool) {
+	c.mu.Lock()
+	c.window.Record(isError)
+	if c.state != StateNormal {
+		c.mu.Unlock()
+		return
+	}
+	if isError {
+		c.consecutive++
+	} else {
+		c.consecutive = 0
+	}
+	var reason string
+	if c.consecutiveLimit > 0 && c.consecutive >= c.consecutiveLimit {
+		reason = fmt.Sprintf("%d consecutive upstream failures", c.consecutive)
+	} else if c.errorRate > 0 {
+		total, errs := c.window.Snapshot()
+		if total >= c.minRequests && float64(errs)/float64(total) >= c.errorRate {
+			reason = fmt.Sprintf("upstream error rate %.2f over %s", float64(errs)/float64(total), c.windowDur)
+		}
+	}
+	c.mu.Unlock()
+
+	if reason != "" && c.override.Load() == overrideNone {
+		c.transition(StateDegraded, reason)
+	}
+}
+
+// acquireProbe reports whether the caller may send a canary request to the
+// backend. At most one canary is admitted per probation interval.
+func (c *Controller) acquireProbe() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Sub(c.lastProbe) < c.probeInterval {
+		return false
+	}
+	c.lastProbe = now
+	return true
+}
+
+// recordProbe accounts a canary outcome and exits degraded mode once the
+// probation threshold is met. A failed canary restarts probation.
+func (c *Controller) recordProbe(success bool) {
+	c.canaries.Add(1)
+	c.mu.Lock()
+	if c.state != StateDegraded {
+		c.mu.Unlock()
+		return
+	}
+	if !success {
+		c.probation = 0
+		c.mu.Unlock()
+		return
+	}
+	c.probation++
+	done := c.probation >= c.successThreshold
+	n := c.probation
+	c.mu.Unlock()
+
+	if done {
+		c.transition(StateNormal, fmt.Sprintf("passed probation (%d canaries)", n))
+	}
+}
+
+// transition switches to state `to`, resetting window and probation counters,
+// and fires the transition callback when the state actually changed.
+func (c *Controller) transition(to, reason string) {
+	c.mu.Lock()
+	from := c.state
+	c.state = to
+	c.reason = reason
+	c.consecutive = 0
+	c.probation = 0
+	if to == StateDegraded {
+		if from != to {
+			c.enteredAt = time.Now()
+			// Start probing only after a full interval.
+			c.lastProbe = c.enteredAt
+		}
+	} else {
+		c.window = slo.NewSlidingWindow(c.windowDur)
+		c.enteredAt = time.Time{}
+	}
+	cb := c.onTransition
+	c.mu.Unlock()
+
+	if from == to {
+		return
+	}
+	c.transitions.Add(1)
+	if cb != nil {
+		cb(from, to, reason)
+	}
+}
+
+// Middleware returns the degraded mode middleware. stale may be nil when the
+// route has no cache; onServe, if non-nil, is called with the source of each
+// response served while degraded.
+func (c *Controller) Middleware(stale StaleLookup, onServe func(source string)) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served := func(source string) {
+				if onServe != nil {
+					onServe(source)
+				}
+			}
+
+			if c.State() == StateNormal {
+				sw := &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+				next.ServeHTTP(sw, r)
+				if c.override.Load() == overrideNone {
+					c.record(sw.statusCode >= 500)
+				}
+				return
+			}
+
+			// Canaries are only sent while degradation is automatic; a
+			// forced mode stays put until an operator resets it.
+			if c.override.Load() == overrideNone && c.acquireProbe() {
+				bw := bufutil.New()
+				next.ServeHTTP(bw, r)
+				ok := bw.StatusCode < 500
+				c.recordProbe(ok)
+				if ok {
+					served(SourceCanary)
+					bw.FlushTo(w)
+					return
+				}
+			}
+
+			source := c.serveDegraded(w, r, stale)
+			served(source)
+		})
+	}
+}
+
+// serveDegraded writes the best available degraded response: a stale cache
+// entry, the static fallback, or a plain 503 for the error page middleware to
+// render. It returns the source used.
+func (c *Controller) serveDegraded(w http.ResponseWriter, r *http.Request, stale StaleLookup) string {
+	w.Header().Set("X-Degraded-Mode", "true")
+
+	if stale != nil {
+		if entry := stale(r); entry != nil {
+			c.servedStale.Add(1)
+			markRelayed(r)
+			bufutil.CopyHeaders(w.Header(), entry.Headers)
+			w.Header().Set("X-Cache", "STALE")
+			w.WriteHeader(entry.StatusCode)
+			w.Write(entry.Body)
+			return SourceStale
+		}
+	}
+
+	if fb := c.fallback; fb != nil {
+		c.servedFallback.Add(1)
+		markRelayed(r)
+		for k, v := range fb.headers {
+			w.Header().Set(k, v)
+		}
+		w.Header().Set("Content-Type", fb.contentType)
+		if fb.retryAfter != "" {
+			w.Header().Set("Retry-After", fb.retryAfter)
+		}
+		w.WriteHeader(fb.statusCode)
+		w.Write(fb.body)
+		return SourceFallback
+	}
+
+	c.servedErrorPage.Add(1)
+	variables.SetErrorReason(r, errors.ReasonDegraded)
+	errors.ErrServiceUnavailable.WithDetails("Route is in degraded mode").WriteJSON(w)
+	return SourceErrorPage
+}
+
+// markRelayed records that the response is a stale entry or the configured
+// fallback, so it is not rendered as a gateway error.
+func markRelayed(r *http.Request) {
+	if varCtx := variables.GetFromRequest(r); varCtx != nil {
+		varCtx.Relayed = true
+	}
+}
+
+// Snapshot returns a point-in-time copy of controller state and counters.
+func (c *Controller) Snapshot() Snapshot {
+	state := c.State()
+
+	c.mu.Lock()
+	total, errs := c.window.Snapshot()
+	snap := Snapshot{
+		State:               state,
+		Reason:              c.reason,
+		ConsecutiveFailures: c.consecutive,
+		ProbationSuccesses:  c.probation,
+		ProbationRequired:   c.successThreshold,
+	}
+	if !c.enteredAt.IsZero() {
+		t := c.enteredAt
+		snap.EnteredAt = &t
+	}
+	c.mu.Unlock()
+
+	switch c.override.Load() {
+	case overrideForceDegraded:
+		snap.Override = "force_degraded"
+	case overrideForceNormal:
+		snap.Override = "force_normal"
+	}
+	snap.WindowRequests = total
+	snap.WindowErrors = errs
+	snap.Transitions = c.transitions.Load()
+	snap.Canaries = c.canaries.Load()
+	snap.ServedStale = c.servedStale.Load()
+	snap.ServedFallback = c.servedFallback.Load()
+	snap.ServedErrorPage = c.servedErrorPage.Load()
+	return snap
+}
+
+// statusWriter captures the response status code.
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+	written    bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.written {
+		w.statusCode = code
+		w.written = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.written = true
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, fmt.Errorf("underlying ResponseWriter does not implement http.Hijacker")
+}
+
+// DegradedByRoute manages per-route degraded mode controllers.
+type DegradedByRoute struct {
+	byroute.Manager[*Controller]
+	mu           sync.Mutex
+	onTransition func(routeID, from, to, reason string)
+}
+
+// NewDegradedByRoute creates a new manager.
+func NewDegradedByRoute() *DegradedByRoute {
+	return &DegradedByRoute{}
+}
+
+// SetOnTransition sets the transition callback for all current and future controllers.
+func (m *DegradedByRoute) SetOnTransition(cb func(routeID, from, to, reason string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onTransition = cb
+	m.Range(func(routeID string, c *Controller) bool {
+		c.SetOnTransition(bindRoute(routeID, cb))
+		return true
+	})
+}
+
+// AddRoute creates a controller for the given route.
+func (m *DegradedByRoute) AddRoute(routeID string, cfg config.DegradedModeConfig) error {
+	m.mu.Lock()
+	cb := m.onTransition
+	m.mu.Unlock()
+
+	c := New(routeID, cfg)
+	c.SetOnTransition(bindRoute(routeID, cb))
+	m.Add(routeID, c)
+	return nil
+}
+
+// ForceDegraded pins a route in degraded mode.
+func (m *DegradedByRoute) ForceDegraded(routeID string) error {
+	return m.with(routeID, (*Controller).ForceDegraded)
+}
+
+// ForceNormal pins a route in normal mode.
+func (m *DegradedByRoute) ForceNormal(routeID string) error {
+	return m.with(routeID, (*Controller).ForceNormal)
+}
+
+// ResetOverride returns a route to automatic mode selection.
+func (m *DegradedByRoute) ResetOverride(routeID string) error {
+	return m.with(routeID, (*Controller).ResetOverride)
+}
+
+func (m *DegradedByRoute) with(routeID string, fn func(*Controller)) error {
+	c, ok := m.Get(routeID)
+	if !ok {
+		return fmt.Errorf("no degraded mode controller for route %q", routeID)
+	}
+	fn(c)
+	return nil
+}
+
+// Stats returns snapshots for all routes.
+func (m *DegradedByRoute) Stats() map[string]any {
+	return byroute.CollectStats(&m.Manager, func(c *Controller) any { return c.Snapshot() })
+}
+
+func bindRoute(routeID string, cb func(routeID, from, to, reason string)) func(from, to, reason string) {
+	if cb == nil {
+		return nil
+	}
+	return func(from, to, reason string) { cb(routeID, from, to, reason) }
+}
@@ -0,0 +1,287 @@
+package degraded
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/cache"
+)
+
+// backend returns a handler whose status can be switched at runtime.
+func backend(status *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte("live"))
+	})
+}
+
+func serve(h http.Handler) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/page", nil))
+	return rec
+}
+
+func TestConsecutiveFailuresTripToFallback(t *testing.T) {
+	c := New("r1", config.DegradedModeConfig{
+		Enabled:             true,
+		ConsecutiveFailures: 3,
+		Fallback:            config.DegradedFallbackConfig{Body: "<h1>Back soon</h1>", RetryAfter: "30"},
+		Probation:           config.DegradedProbationConfig{Interval: time.Hour},
+	})
+	var transitions []string
+	c.SetOnTransition(func(from, to, reason string) { transitions = append(transitions, from+"->"+to) })
+
+	var status atomic.Int32
+	status.Store(502)
+	var sources []string
+	h := c.Middleware(nil, func(s string) { sources = append(sources, s) })(backend(&status))
+
+	for i := 0; i < 3; i++ {
+		if rec := serve(h); rec.Code != 502 {
+			t.Fatalf("request %d: expected live 502, got %d", i, rec.Code)
+		}
+	}
+	if c.State() != StateDegraded {
+		t.Fatalf("expected degraded after 3 failures, got %s", c.State())
+	}
+
+	rec := serve(h)
+	if rec.Code != 503 || rec.Body.String() != "<h1>Back soon</h1>" {
+		t.Errorf("expected fallback 503, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") != "30" || rec.Header().Get("X-Degraded-Mode") != "true" {
+		t.Errorf("unexpected headers: %v", rec.Header())
+	}
+	if len(sources) != 1 || sources[0] != SourceFallback {
+		t.Errorf("expected one fallback source, got %v", sources)
+	}
+	if len(transitions) != 1 || transitions[0] != "normal->degraded" {
+		t.Errorf("unexpected transitions: %v", transitions)
+	}
+}
+
+func TestErrorRateRespectsMinRequests(t *testing.T) {
+	c := New("r1", config.DegradedModeConfig{
+		Enabled:            true,
+		ErrorRateThreshold: 0.5,
+		MinRequests:        10,
+	})
+	var status atomic.Int32
+	status.Store(500)
+	h := c.Middleware(nil, nil)(backend(&status))
+
+	for i := 0; i < 9; i++ {
+		serve(h)
+	}
+	if c.State() != StateNormal {
+		t.Fatal("should not trip below min_requests")
+	}
+	serve(h)
+	if c.State() != StateDegraded {
+		t.Fatal("expected degraded once min_requests reached")
+	}
+}
+
+func TestServesStaleBeforeFallback(t *testing.T) {
+	c := New("r1", config.DegradedModeConfig{
+		Enabled:             true,
+		ConsecutiveFailures: 1,
+		Fallback:            config.DegradedFallbackConfig{Body: "fallback"},
+		Probation:           config.DegradedProbationConfig{Interval: time.Hour},
+	})
+	stale := func(r *http.Request) *cache.Entry {
+		if r.URL.Path != "/page" {
+			return nil
+		}
+		return &cache.Entry{StatusCode: 200, Headers: http.Header{"Content-Type": {"text/plain"}}, Body: []byte("cached")}
+	}
+	var status atomic.Int32
+	status.Store(503)
+	h := c.Middleware(stale, nil)(backend(&status))
+
+	serve(h) // trips
+	rec := serve(h)
+	if rec.Code != 200 || rec.Body.String() != "cached" || rec.Header().Get("X-Cache") != "STALE" {
+		t.Errorf("expected stale entry, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/other", nil))
+	if rec.Body.String() != "fallback" {
+		t.Errorf("expected fallback for uncached path, got %q", rec.Body.String())
+	}
+}
+
+func TestErrorPageWhenNoFallback(t *testing.T) {
+	c := New("r1", config.DegradedModeConfig{Enabled: true, ConsecutiveFailures: 1})
+	c.ForceDegraded()
+
+	var status atomic.Int32
+	status.Store(200)
+	h := c.Middleware(nil, nil)(backend(&status))
+
+	rec := serve(h)
+	if rec.Code != 503 || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected JSON 503, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if c.Snapshot().ServedErrorPage != 1 {
+		t.Errorf("expected served_error_page 1, got %d", c.Snapshot().ServedErrorPage)
+	}
+}
+
+func TestProbationExitsDegradedMode(t *testing.T) {
+	c := New("r1", config.DegradedModeConfig{
+		Enabled:             true,
+		ConsecutiveFailures: 1,
+		Probation:           config.DegradedProbationConfig{SuccessThreshold: 2, Interval: time.Millisecond},
+	})
+	var exits int
+	c.SetOnTransition(func(from, to, reason string) {
+		if to == StateNormal {
+			exits++
+		}
+	})
+
+	var status atomic.Int32
+	status.Store(500)
+	h := c.Middleware(nil, nil)(backend(&status))
+	serve(h)
+	if c.State() != StateDegraded {
+		t.Fatal("expected degraded")
+	}
+
+	// A failing canary restarts probation and serves the degraded response.
+	time.Sleep(2 * time.Millisecond)
+	if rec := serve(h); rec.Code != 503 || rec.Header().Get("X-Degraded-Mode") != "true" {
+		t.Fatalf("failed canary: expected degraded 503, got %d", rec.Code)
+	}
+
+	status.Store(200)
+	for i := 0; i < 2; i++ {
+		time.Sleep(2 * time.Millisecond)
+		if rec := serve(h); rec.Code != 200 || rec.Body.String() != "live" {
+			t.Fatalf("canary %d: expected live 200, got %d", i, rec.Code)
+		}
+	}
+	if c.State() != StateNormal {
+		t.Fatalf("expected normal after probation, got %s", c.State())
+	}
+	if exits != 1 {
+		t.Errorf("expected 1 exit transition, got %d", exits)
+	}
+	if got := c.Snapshot().Canaries; got != 3 {
+		t.Errorf("expected 3 canaries, got %d", got)
+	}
+}
+
+func TestCanaryRateLimited(t *testing.T) {
+	c := New("r1", config.DegradedModeConfig{
+		Enabled:             true,
+		ConsecutiveFailures: 1,
+		Probation:           config.DegradedProbationConfig{Interval: time.Hour},
+	})
+	var calls atomic.Int32
+	h := c.Middleware(nil, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(500)
+	}))
+
+	for i := 0; i < 10; i++ {
+		serve(h)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected only the tripping request to reach the backend, got %d", calls.Load())
+	}
+}
+
+func TestAdminOverrides(t *testing.T) {
+	m := NewDegradedByRoute()
+	var events []string
+	m.SetOnTransition(func(routeID, from, to, reason string) { events = append(events, routeID+":"+to) })
+	m.AddRoute("api", config.DegradedModeConfig{Enabled: true, ConsecutiveFailures: 1})
+
+	if err := m.ForceDegraded("missing"); err == nil {
+		t.Error("expected error for unknown route")
+	}
+	if err := m.ForceDegraded("api"); err != nil {
+		t.Fatal(err)
+	}
+	c := m.Lookup("api")
+	if c.State() != StateDegraded || c.Snapshot().Override != "force_degraded" {
+		t.Errorf("expected forced degraded, got %+v", c.Snapshot())
+	}
+
+	// Forced normal ignores failures.
+	m.ForceNormal("api")
+	var status atomic.Int32
+	status.Store(500)
+	h := c.Middleware(nil, nil)(backend(&status))
+	serve(h)
+	serve(h)
+	if c.State() != StateNormal {
+		t.Error("forced normal route must not trip")
+	}
+
+	m.ResetOverride("api")
+	serve(h)
+	if c.State() != StateDegraded {
+		t.Error("expected automatic tripping after reset")
+	}
+
+	want := []string{"api:degraded", "api:normal", "api:degraded"}
+	if len(events) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d: expected %s, got %s", i, want[i], events[i])
+		}
+	}
+	if _, ok := m.Stats()["api"]; !ok {
+		t.Error("expected stats for route api")
+	}
+}
+
+func TestWindowResetConcurrentWithTraffic(t *testing.T) {
+	c := New("r1", config.DegradedModeConfig{Enabled: true, ErrorRateThreshold: 0.99, MinRequests: 1 << 20})
+	var status atomic.Int32
+	status.Store(200)
+	h := c.Middleware(nil, nil)(backend(&status))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				serve(h)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			c.ResetOverride()
+			c.Snapshot()
+		}
+	}
+
+	// The last reset precedes no traffic, so every request is in the window.
+	c.ResetOverride()
+	serve(h)
+	if snap := c.Snapshot(); snap.WindowRequests != 1 {
+		t.Errorf("expected 1 request in the window after reset, got %d", snap.WindowRequests)
+	}
+}
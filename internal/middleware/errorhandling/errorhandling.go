@@ -48,8 +48,9 @@ func (h *ErrorHandler) Middleware() middleware.Middleware {
 
 			h.total.Add(1)
 
-			// Non-error status codes pass through unchanged.
-			if bw.statusCode < 400 {
+			// Non-error status codes, and gateway errors the route renders as
+			// problem details, pass through unchanged.
+			if bw.statusCode < 400 || variables.GetFromRequest(r).RendersProblem() {
 				w.WriteHeader(bw.statusCode)
 				w.Write(bw.buf.Bytes())
 				return
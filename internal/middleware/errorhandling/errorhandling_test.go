@@ -308,3 +308,26 @@ func TestImplicitOK(t *testing.T) {
 		t.Errorf("expected body 'implicit ok', got %q", w.Body.String())
 	}
 }
+
+func TestProblemJSONGatewayErrorsPassThrough(t *testing.T) {
+	h := New(config.ErrorHandlingConfig{Mode: "pass_status"})
+	for _, upstream := range []int{0, 502} {
+		handler := h.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			variables.GetFromRequest(r).UpstreamStatus = upstream
+			w.WriteHeader(502)
+			w.Write([]byte("original"))
+		}))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		vc := &variables.Context{ErrorFormat: config.ErrorFormatProblemJSON, Request: r}
+		r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, vc))
+		handler.ServeHTTP(w, r)
+
+		// Gateway errors are left to the problem renderer; backend errors
+		// are still reformatted.
+		if passed := w.Body.String() == "original"; passed != (upstream == 0) {
+			t.Errorf("upstream status %d: body = %q", upstream, w.Body.String())
+		}
+	}
+}
@@ -217,6 +217,13 @@ func (ep *CompiledErrorPages) Render(statusCode int, r *http.Request, varCtx *va
 	return sb.String(), contentType
 }
 
+// RendersHTML reports whether the Accept header asks for HTML and Render has
+// an HTML page for the status code.
+func (ep *CompiledErrorPages) RendersHTML(statusCode int, accept string) bool {
+	page := ep.findPage(statusCode)
+	return page != nil && page.html != nil && strings.Contains(strings.ToLower(accept), "text/html")
+}
+
 // Metrics returns the current metrics snapshot.
 func (ep *CompiledErrorPages) Metrics() ErrorPagesSnapshot {
 	return ErrorPagesSnapshot{
@@ -309,9 +316,9 @@ func (w *errorPageWriter) WriteHeader(code int) {
 	}
 	w.wroteHeader = true
 
-	if code >= 400 && w.ep.ShouldIntercept(code) {
+	varCtx := variables.GetFromRequest(w.r)
+	if code >= 400 && !varCtx.RendersProblem() && w.ep.ShouldIntercept(code) {
 		w.intercepted = true
-		varCtx := variables.GetFromRequest(w.r)
 		body, contentType := w.ep.Render(code, w.r, varCtx)
 
 		w.ResponseWriter.Header().Del("Content-Encoding")
@@ -1,6 +1,8 @@
 package errorpages
 
 import (
+	"context"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
@@ -343,3 +345,32 @@ func TestMissingFile(t *testing.T) {
 func contains(s, substr string) bool {
 	return strings.Contains(s, substr)
 }
+
+func TestMiddleware_SkipsProblemJSONGatewayErrors(t *testing.T) {
+	ep, err := New(config.ErrorPagesConfig{
+		Enabled: true,
+		Pages:   map[string]config.ErrorPageEntry{"5xx": {JSON: `{"page":true}`}},
+	}, config.ErrorPagesConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, upstream := range []int{0, 503} {
+		r := httptest.NewRequest("GET", "/", nil)
+		varCtx := variables.NewContext(r)
+		varCtx.ErrorFormat = config.ErrorFormatProblemJSON
+		varCtx.UpstreamStatus = upstream
+		r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx))
+
+		w := httptest.NewRecorder()
+		ep.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(503)
+			w.Write([]byte("original"))
+		})).ServeHTTP(w, r)
+
+		// Gateway errors are left to the problem renderer; backend errors
+		// still get the page.
+		if passed := w.Body.String() == "original"; passed != (upstream == 0) {
+			t.Errorf("upstream status %d: body = %q", upstream, w.Body.String())
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -79,6 +80,12 @@ func (jsonCodec) Name() string {
 
 // New creates a new ExtAuth client from config.
 func New(cfg config.ExtAuthConfig) (*ExtAuth, error) {
+	return newExtAuth(cfg, nil)
+}
+
+// newExtAuth is New; connections to the service go through dial when it
+// is non-nil.
+func newExtAuth(cfg config.ExtAuthConfig, dial func(ctx context.Context, network, addr string) (net.Conn, error)) (*ExtAuth, error) {
 	ea := &ExtAuth{
 		url:      cfg.URL,
 		failOpen: cfg.FailOpen,
@@ -118,21 +125,31 @@ func New(cfg config.ExtAuthConfig) (*ExtAuth, error) {
 
 	// Initialize transport
 	if ea.protocol == "grpc" {
-		conn, err := dialGRPC(cfg)
+		conn, err := dialGRPC(cfg, dial)
 		if err != nil {
 			return nil, fmt.Errorf("ext_auth grpc dial: %w", err)
 		}
 		ea.grpcConn = conn
 	} else {
-		ea.httpClient = &http.Client{
-			Timeout: ea.timeout,
-		}
+		// The per-check context carries the timeout, so routes sharing this
+		// client can use different timeouts.
+		ea.httpClient = &http.Client{}
+		var transport *http.Transport
 		if cfg.TLS.Enabled {
 			tlsConfig, err := buildHTTPTLSConfig(cfg.TLS)
 			if err != nil {
 				return nil, fmt.Errorf("ext_auth tls: %w", err)
 			}
-			ea.httpClient.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+			transport = &http.Transport{TLSClientConfig: tlsConfig}
+		}
+		if dial != nil {
+			if transport == nil {
+				transport = http.DefaultTransport.(*http.Transport).Clone()
+			}
+			transport.DialContext = dial
+		}
+		if transport != nil {
+			ea.httpClient.Transport = transport
 		}
 	}
 
@@ -318,11 +335,16 @@ func (ea *ExtAuth) Close() {
 	}
 }
 
-func dialGRPC(cfg config.ExtAuthConfig) (*grpc.ClientConn, error) {
+func dialGRPC(cfg config.ExtAuthConfig, dial func(ctx context.Context, network, addr string) (net.Conn, error)) (*grpc.ClientConn, error) {
 	target := strings.TrimPrefix(cfg.URL, "grpc://")
 
 	var opts []grpc.DialOption
 	opts = append(opts, grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})))
+	if dial != nil {
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dial(ctx, "tcp", addr)
+		}))
+	}
 
 	if cfg.TLS.Enabled {
 		creds, err := buildGRPCTLSCredentials(cfg.TLS)
@@ -426,11 +448,88 @@ func (ea *ExtAuth) Middleware() middleware.Middleware {
 	}
 }
 
-// ExtAuthByRoute manages per-route external auth clients.
-type ExtAuthByRoute = byroute.Factory[*ExtAuth, config.ExtAuthConfig]
+// withOverrides returns a view of ea that shares its transport, cache and
+// metrics but uses the route's timeout and fail_open settings.
+func (ea *ExtAuth) withOverrides(cfg config.ExtAuthConfig) *ExtAuth {
+	v := *ea
+	if cfg.Timeout > 0 {
+		v.timeout = cfg.Timeout
+	}
+	if cfg.FailOpen {
+		v.failOpen = true
+	}
+	return &v
+}
+
+// ExtAuthByRoute manages per-route external auth clients. Routes that
+// reference a shared service get a view over the service's client, cache
+// and metrics.
+type ExtAuthByRoute struct {
+	byroute.Manager[*ExtAuth]
+	services *byroute.Shared[*ExtAuth, config.ExtAuthConfig]
+	dial     func(ctx context.Context, network, addr string) (net.Conn, error)
+}
 
-// NewExtAuthByRoute creates a new per-route ext auth manager.
-func NewExtAuthByRoute() *ExtAuthByRoute {
-	return byroute.NewFactory(New, func(ea *ExtAuth) any { return ea.metrics.Snapshot() }).
+// NewExtAuthByRoute creates a new per-route ext auth manager. services are
+// the named definitions routes may reference via ext_auth.ref.
+func NewExtAuthByRoute(services map[string]config.ExtAuthConfig) *ExtAuthByRoute {
+	m := &ExtAuthByRoute{}
+	newFn := func(_ string, cfg config.ExtAuthConfig) (*ExtAuth, error) { return newExtAuth(cfg, m.dial) }
+	m.services = byroute.NewShared(services, newFn, func(ea *ExtAuth) any { return ea.metrics.Snapshot() }).
 		WithClose((*ExtAuth).Close)
+	return m
+}
+
+// SetDialer makes clients created afterwards connect to their service
+// through dial.
+func (m *ExtAuthByRoute) SetDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	m.dial = dial
+}
+
+// AddRoute creates an ext auth client for the route, or a view over the
+// referenced shared service.
+func (m *ExtAuthByRoute) AddRoute(routeID string, cfg config.ExtAuthConfig) error {
+	if cfg.Ref == "" {
+		ea, err := newExtAuth(cfg, m.dial)
+		if err != nil {
+			return err
+		}
+		m.Add(routeID, ea)
+		return nil
+	}
+	shared, err := m.services.Acquire(cfg.Ref, routeID)
+	if err != nil {
+		return err
+	}
+	m.Add(routeID, shared.withOverrides(cfg))
+	return nil
+}
+
+// Stats returns metrics for routes with an inline ext_auth config. Routes
+// referencing a shared service are reported by SharedStats.
+func (m *ExtAuthByRoute) Stats() map[string]any {
+	result := make(map[string]any)
+	m.Range(func(id string, ea *ExtAuth) bool {
+		if _, shared := m.services.RefOf(id); !shared {
+			result[id] = ea.metrics.Snapshot()
+		}
+		return true
+	})
+	return result
+}
+
+// SharedStats returns metrics per shared service with the routes using it.
+func (m *ExtAuthByRoute) SharedStats() map[string]byroute.SharedStats {
+	return m.services.Stats()
+}
+
+// CloseAll closes inline clients and every shared service once.
+func (m *ExtAuthByRoute) CloseAll() {
+	m.Range(func(id string, ea *ExtAuth) bool {
+		if _, shared := m.services.RefOf(id); !shared {
+			ea.Close()
+		}
+		return true
+	})
+	m.services.CloseAll()
 }
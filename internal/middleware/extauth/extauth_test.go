@@ -427,7 +427,7 @@ func TestExtAuthByRoute(t *testing.T) {
 	}))
 	defer authServer.Close()
 
-	mgr := NewExtAuthByRoute()
+	mgr := NewExtAuthByRoute(nil)
 
 	err := mgr.AddRoute("route1", config.ExtAuthConfig{
 		Enabled: true,
@@ -455,3 +455,52 @@ func TestExtAuthByRoute(t *testing.T) {
 		t.Error("expected stats for route1")
 	}
 }
+
+func TestExtAuthByRoute_SharedService(t *testing.T) {
+	var calls atomic.Int32
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer authServer.Close()
+
+	mgr := NewExtAuthByRoute(map[string]config.ExtAuthConfig{
+		"main-authz": {URL: authServer.URL, CacheTTL: time.Minute},
+	})
+	if err := mgr.AddRoute("route1", config.ExtAuthConfig{Ref: "main-authz"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.AddRoute("route2", config.ExtAuthConfig{Ref: "main-authz", Timeout: time.Second, FailOpen: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	ea1, ea2 := mgr.Lookup("route1"), mgr.Lookup("route2")
+	if ea1.httpClient != ea2.httpClient || ea1.cache != ea2.cache {
+		t.Error("routes referencing the same service should share client and cache")
+	}
+	if ea1.failOpen || !ea2.failOpen || ea2.timeout != time.Second {
+		t.Errorf("unexpected overrides: route1 fail_open=%v, route2 fail_open=%v timeout=%v", ea1.failOpen, ea2.failOpen, ea2.timeout)
+	}
+
+	// The second route hits the cache populated by the first.
+	ea1.Check(httptest.NewRequest("GET", "/a", nil))
+	ea2.Check(httptest.NewRequest("GET", "/a", nil))
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 auth call with shared cache, got %d", calls.Load())
+	}
+
+	if len(mgr.Stats()) != 0 {
+		t.Errorf("referencing routes should not appear in per-route stats: %v", mgr.Stats())
+	}
+	shared := mgr.SharedStats()["main-authz"]
+	if len(shared.Routes) != 2 || shared.Routes[0] != "route1" || shared.Routes[1] != "route2" {
+		t.Errorf("unexpected referencing routes: %v", shared.Routes)
+	}
+	if snap := shared.Stats.(ExtAuthSnapshot); snap.CacheHits != 1 {
+		t.Errorf("expected 1 shared cache hit, got %d", snap.CacheHits)
+	}
+
+	if err := mgr.AddRoute("route3", config.ExtAuthConfig{Ref: "missing"}); err == nil {
+		t.Error("expected error for unknown ref")
+	}
+}
@@ -0,0 +1,407 @@
+package fieldpolicy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/tidwall/gjson"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/bufutil"
+	"github.com/wudi/runway/internal/rules"
+	"github.com/wudi/runway/variables"
+)
+
+const defaultMaxBodySize = 1 << 20 // 1MB
+
+// fieldPath is a parsed gjson-style path. "#" matches every array element
+// and numeric segments match an array index.
+type fieldPath []string
+
+// compiledRule is a response field rule with its predicate compiled.
+type compiledRule struct {
+	id    string
+	when  *rules.Condition // nil matches every request
+	allow []fieldPath
+	deny  []fieldPath
+
+	matched atomic.Int64
+	applied atomic.Int64
+}
+
+// Policy strips JSON response fields the caller is not entitled to see.
+type Policy struct {
+	rules       []*compiledRule
+	maxBodySize int64
+
+	filtered         atomic.Int64
+	skippedNonJSON   atomic.Int64
+	skippedOversized atomic.Int64
+}
+
+// New creates a Policy from config, compiling all rule predicates upfront.
+func New(cfg config.ResponseFieldPolicyConfig) (*Policy, error) {
+	p := &Policy{maxBodySize: cfg.MaxBodySize}
+	if p.maxBodySize <= 0 {
+		p.maxBodySize = defaultMaxBodySize
+	}
+	for i, rc := range cfg.Rules {
+		cr := &compiledRule{id: rc.ID}
+		if cr.id == "" {
+			cr.id = "rule-" + strconv.Itoa(i)
+		}
+		if rc.When != "" {
+			cond, err := rules.CompileCondition(rc.When)
+			if err != nil {
+				return nil, fmt.Errorf("response_field_policy rule %s: invalid when expression: %w", cr.id, err)
+			}
+			cr.when = cond
+		}
+		for _, f := range rc.AllowFields {
+			cr.allow = append(cr.allow, parsePath(f))
+		}
+		for _, f := range rc.DenyFields {
+			cr.deny = append(cr.deny, parsePath(f))
+		}
+		p.rules = append(p.rules, cr)
+	}
+	return p, nil
+}
+
+// parsePath splits a gjson-style path on unescaped dots.
+func parsePath(path string) fieldPath {
+	var segs fieldPath
+	var cur strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path):
+			i++
+			cur.WriteByte(path[i])
+		case path[i] == '.':
+			segs = append(segs, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(path[i])
+		}
+	}
+	return append(segs, cur.String())
+}
+
+// Middleware returns a middleware that evaluates rule predicates against the
+// request identity and filters the JSON response body for matching rules.
+func (p *Policy) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			matched := p.match(r)
+			if len(matched) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Ask for an identity-encoded body so it can be inspected.
+			r.Header.Del("Accept-Encoding")
+
+			cw := &cappedWriter{Writer: bufutil.NewFor(r), dst: w, max: p.maxBodySize}
+			next.ServeHTTP(cw, r)
+			if cw.passthrough {
+				p.skippedOversized.Add(1)
+				cw.WriteTrailers(w)
+				return
+			}
+			bw := cw.Writer
+			if bw.Aborted(w) {
+				return
+			}
+
+			body, err := bw.Bytes()
+			if err != nil || len(body) == 0 {
+				bw.FlushTo(w)
+				return
+			}
+			if bw.Header().Get("Content-Encoding") != "" || !gjson.ValidBytes(body) {
+				p.skippedNonJSON.Add(1)
+				bw.FlushTo(w)
+				return
+			}
+
+			bw.FlushToWithLength(w, p.apply(body, matched))
+		})
+	}
+}
+
+// cappedWriter buffers a response body up to max bytes. A longer body is
+// not filtered: the writer switches to passthrough, sending the buffered
+// part and everything after it straight to dst.
+type cappedWriter struct {
+	*bufutil.Writer
+	dst         http.ResponseWriter
+	max         int64
+	n           int64
+	passthrough bool
+}
+
+func (w *cappedWriter) Write(b []byte) (int, error) {
+	if w.passthrough {
+		return w.dst.Write(b)
+	}
+	if w.n+int64(len(b)) <= w.max {
+		w.n += int64(len(b))
+		return w.Writer.Write(b)
+	}
+	w.passthrough = true
+	w.Writer.FlushTo(w.dst)
+	return w.dst.Write(b)
+}
+
+func (w *cappedWriter) Flush() {
+	if !w.passthrough {
+		return
+	}
+	if f, ok := w.dst.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// match returns the rules whose predicates match the request.
+func (p *Policy) match(r *http.Request) []*compiledRule {
+	var env *rules.RequestEnv
+	var matched []*compiledRule
+	for _, cr := range p.rules {
+		if cr.when != nil {
+			if env == nil {
+				env = rules.AcquireRequestEnv(r, variables.GetFromRequest(r))
+			}
+			if !cr.when.Match(env) {
+				continue
+			}
+		}
+		cr.matched.Add(1)
+		matched = append(matched, cr)
+	}
+	if env != nil {
+		rules.ReleaseRequestEnv(env)
+	}
+	return matched
+}
+
+// apply filters body through each matched rule in order: allow_fields first
+// projects the document onto the listed paths, then deny_fields are removed.
+func (p *Policy) apply(body []byte, matched []*compiledRule) []byte {
+	current := string(body)
+	modified := false
+	for _, cr := range matched {
+		if len(cr.allow) > 0 {
+			root := gjson.Parse(current)
+			out, ok := project(root, cr.allow)
+			if !ok {
+				out = emptyLike(root)
+			}
+			if out != current {
+				current = out
+				modified = true
+			}
+		}
+		if len(cr.deny) > 0 {
+			if out, changed := prune(gjson.Parse(current), cr.deny); changed {
+				current = out
+				modified = true
+			}
+		}
+		cr.applied.Add(1)
+	}
+	if !modified {
+		return body
+	}
+	p.filtered.Add(1)
+	return []byte(current)
+}
+
+// descend returns the remainder of each path whose first segment matches.
+func descend(paths []fieldPath, match func(seg string) bool) []fieldPath {
+	var sub []fieldPath
+	for _, fp := range paths {
+		if len(fp) > 0 && match(fp[0]) {
+			sub = append(sub, fp[1:])
+		}
+	}
+	return sub
+}
+
+func hasTerminal(paths []fieldPath) bool {
+	for _, fp := range paths {
+		if len(fp) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func emptyLike(val gjson.Result) string {
+	if val.IsArray() {
+		return "[]"
+	}
+	return "{}"
+}
+
+// project returns val restricted to the given paths. ok is false when no path
+// selects anything inside val.
+func project(val gjson.Result, paths []fieldPath) (string, bool) {
+	if hasTerminal(paths) {
+		return val.Raw, true
+	}
+	switch {
+	case val.IsObject():
+		var b strings.Builder
+		n := 0
+		b.WriteByte('{')
+		val.ForEach(func(key, child gjson.Result) bool {
+			name := key.String()
+			sub := descend(paths, func(seg string) bool { return seg == name })
+			if len(sub) == 0 {
+				return true
+			}
+			raw, ok := project(child, sub)
+			if !ok {
+				return true
+			}
+			if n > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(key.Raw)
+			b.WriteByte(':')
+			b.WriteString(raw)
+			n++
+			return true
+		})
+		b.WriteByte('}')
+		return b.String(), n > 0
+	case val.IsArray():
+		var b strings.Builder
+		n, i := 0, 0
+		b.WriteByte('[')
+		val.ForEach(func(_, elem gjson.Result) bool {
+			idx := strconv.Itoa(i)
+			i++
+			sub := descend(paths, func(seg string) bool { return seg == "#" || seg == idx })
+			if len(sub) == 0 {
+				return true
+			}
+			raw, ok := project(elem, sub)
+			if !ok {
+				return true
+			}
+			if n > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(raw)
+			n++
+			return true
+		})
+		b.WriteByte(']')
+		return b.String(), n > 0
+	default:
+		return "", false
+	}
+}
+
+// prune returns val with every path removed. changed is false (and val.Raw
+// returned untouched) when no path matched.
+func prune(val gjson.Result, paths []fieldPath) (string, bool) {
+	var b strings.Builder
+	changed := false
+	n := 0
+	write := func(prefix, raw string) {
+		if n > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(prefix)
+		b.WriteString(raw)
+		n++
+	}
+
+	switch {
+	case val.IsObject():
+		b.WriteByte('{')
+		val.ForEach(func(key, child gjson.Result) bool {
+			name := key.String()
+			sub := descend(paths, func(seg string) bool { return seg == name })
+			if hasTerminal(sub) {
+				changed = true
+				return true
+			}
+			raw := child.Raw
+			if len(sub) > 0 {
+				if out, ch := prune(child, sub); ch {
+					raw, changed = out, true
+				}
+			}
+			write(key.Raw+":", raw)
+			return true
+		})
+		b.WriteByte('}')
+	case val.IsArray():
+		i := 0
+		b.WriteByte('[')
+		val.ForEach(func(_, elem gjson.Result) bool {
+			idx := strconv.Itoa(i)
+			i++
+			sub := descend(paths, func(seg string) bool { return seg == "#" || seg == idx })
+			if hasTerminal(sub) {
+				changed = true
+				return true
+			}
+			raw := elem.Raw
+			if len(sub) > 0 {
+				if out, ch := prune(elem, sub); ch {
+					raw, changed = out, true
+				}
+			}
+			write("", raw)
+			return true
+		})
+		b.WriteByte(']')
+	default:
+		return val.Raw, false
+	}
+
+	if !changed {
+		return val.Raw, false
+	}
+	return b.String(), true
+}
+
+// Stats returns policy statistics including per-rule application counts.
+func (p *Policy) Stats() map[string]interface{} {
+	ruleStats := make([]map[string]interface{}, len(p.rules))
+	for i, cr := range p.rules {
+		when := ""
+		if cr.when != nil {
+			when = cr.when.Expression
+		}
+		ruleStats[i] = map[string]interface{}{
+			"id":      cr.id,
+			"when":    when,
+			"matched": cr.matched.Load(),
+			"applied": cr.applied.Load(),
+		}
+	}
+	return map[string]interface{}{
+		"rules":             ruleStats,
+		"filtered":          p.filtered.Load(),
+		"skipped_non_json":  p.skippedNonJSON.Load(),
+		"skipped_oversized": p.skippedOversized.Load(),
+		"max_body_size":     p.maxBodySize,
+	}
+}
+
+// FieldPolicyByRoute manages per-route response field policies.
+type FieldPolicyByRoute = byroute.Factory[*Policy, config.ResponseFieldPolicyConfig]
+
+// NewFieldPolicyByRoute creates a new per-route response field policy manager.
+func NewFieldPolicyByRoute() *FieldPolicyByRoute {
+	return byroute.NewFactory(New, func(p *Policy) any { return p.Stats() })
+}
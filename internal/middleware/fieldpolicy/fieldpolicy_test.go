@@ -0,0 +1,281 @@
+package fieldpolicy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
+)
+
+const orderBody = `{
+  "id": "o-1",
+  "total": 42.5,
+  "cost_breakdown": {"tax": 2.5, "shipping": 5},
+  "internal_notes": "vip",
+  "items": [
+    {"sku": "a", "qty": 1, "internal_notes": "fragile", "vendors": [{"name": "x", "margin": 0.3}, {"name": "y", "margin": 0.2}]},
+    {"sku": "b", "qty": 2, "vendors": [{"name": "z", "margin": 0.1}]}
+  ]
+}`
+
+func newPolicy(t *testing.T, rules ...config.ResponseFieldPolicyRule) *Policy {
+	t.Helper()
+	p, err := New(config.ResponseFieldPolicyConfig{Enabled: true, Rules: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func decode(t *testing.T, body []byte) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal(body, &m); err != nil {
+		t.Fatalf("invalid JSON %q: %v", body, err)
+	}
+	return m
+}
+
+func TestParsePath(t *testing.T) {
+	tests := map[string]fieldPath{
+		"a":             {"a"},
+		"items.#.notes": {"items", "#", "notes"},
+		"items.0":       {"items", "0"},
+		`a\.b.c`:        {"a.b", "c"},
+	}
+	for in, want := range tests {
+		if got := parsePath(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("parsePath(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestApply_DenyFields(t *testing.T) {
+	p := newPolicy(t, config.ResponseFieldPolicyRule{
+		DenyFields: []string{"cost_breakdown", "internal_notes", "items.#.internal_notes"},
+	})
+
+	out := decode(t, p.apply([]byte(orderBody), p.rules))
+	if _, ok := out["cost_breakdown"]; ok {
+		t.Error("expected cost_breakdown removed")
+	}
+	if _, ok := out["internal_notes"]; ok {
+		t.Error("expected internal_notes removed")
+	}
+	items := out["items"].([]interface{})
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+	first := items[0].(map[string]interface{})
+	if _, ok := first["internal_notes"]; ok {
+		t.Error("expected items.0.internal_notes removed")
+	}
+	if first["sku"] != "a" || out["total"] != 42.5 {
+		t.Errorf("expected other fields kept: %v", out)
+	}
+}
+
+func TestApply_DenyNestedArrayPaths(t *testing.T) {
+	p := newPolicy(t, config.ResponseFieldPolicyRule{
+		DenyFields: []string{"items.#.vendors.#.margin", "items.1"},
+	})
+
+	out := decode(t, p.apply([]byte(orderBody), p.rules))
+	items := out["items"].([]interface{})
+	if len(items) != 1 {
+		t.Fatalf("expected items.1 removed, got %d items", len(items))
+	}
+	vendors := items[0].(map[string]interface{})["vendors"].([]interface{})
+	if len(vendors) != 2 {
+		t.Fatalf("expected 2 vendors, got %d", len(vendors))
+	}
+	for _, v := range vendors {
+		vm := v.(map[string]interface{})
+		if _, ok := vm["margin"]; ok {
+			t.Errorf("expected margin removed from %v", vm)
+		}
+		if vm["name"] == nil {
+			t.Errorf("expected name kept in %v", vm)
+		}
+	}
+}
+
+func TestApply_AllowFields(t *testing.T) {
+	p := newPolicy(t, config.ResponseFieldPolicyRule{
+		AllowFields: []string{"id", "items.#.sku", "items.#.vendors.#.name"},
+	})
+
+	got := string(p.apply([]byte(orderBody), p.rules))
+	want := `{"id":"o-1","items":[{"sku":"a","vendors":[{"name":"x"},{"name":"y"}]},{"sku":"b","vendors":[{"name":"z"}]}]}`
+	if got != want {
+		t.Errorf("unexpected projection:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestApply_AllowNothingMatched(t *testing.T) {
+	p := newPolicy(t, config.ResponseFieldPolicyRule{AllowFields: []string{"missing"}})
+	if got := string(p.apply([]byte(orderBody), p.rules)); got != "{}" {
+		t.Errorf("expected empty object, got %s", got)
+	}
+}
+
+func TestApply_TopLevelArray(t *testing.T) {
+	p := newPolicy(t, config.ResponseFieldPolicyRule{DenyFields: []string{"#.secret"}})
+	got := string(p.apply([]byte(`[{"a":1,"secret":2},{"a":3,"secret":4}]`), p.rules))
+	if got != `[{"a":1},{"a":3}]` {
+		t.Errorf("unexpected result %s", got)
+	}
+}
+
+func TestApply_NoChangeKeepsOriginalBytes(t *testing.T) {
+	p := newPolicy(t, config.ResponseFieldPolicyRule{DenyFields: []string{"not_there", "items.#.nope"}})
+	if got := string(p.apply([]byte(orderBody), p.rules)); got != orderBody {
+		t.Errorf("expected body untouched, got %s", got)
+	}
+	if p.filtered.Load() != 0 {
+		t.Errorf("expected filtered 0, got %d", p.filtered.Load())
+	}
+}
+
+func serve(p *Policy, claims map[string]interface{}, body string, contentType string) *httptest.ResponseRecorder {
+	handler := p.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}))
+	req := httptest.NewRequest("GET", "/orders/1", nil)
+	varCtx := variables.NewContext(req)
+	if claims != nil {
+		varCtx.Identity = &variables.Identity{ClientID: "c1", AuthType: "jwt", Claims: claims}
+	}
+	req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_ClaimsPredicate(t *testing.T) {
+	p := newPolicy(t,
+		config.ResponseFieldPolicyRule{
+			ID:         "free-tier",
+			When:       `auth.claims.plan == "free"`,
+			DenyFields: []string{"cost_breakdown", "items.#.internal_notes"},
+		},
+		config.ResponseFieldPolicyRule{
+			ID:         "everyone",
+			DenyFields: []string{"internal_notes"},
+		},
+	)
+
+	// Free plan: both rules apply
+	rec := serve(p, map[string]interface{}{"plan": "free"}, orderBody, "application/json")
+	out := decode(t, rec.Body.Bytes())
+	if _, ok := out["cost_breakdown"]; ok {
+		t.Error("expected cost_breakdown removed for free plan")
+	}
+	if _, ok := out["internal_notes"]; ok {
+		t.Error("expected internal_notes removed for everyone")
+	}
+	if rec.Header().Get("Content-Length") != strconv.Itoa(rec.Body.Len()) {
+		t.Errorf("Content-Length mismatch: %s vs %d", rec.Header().Get("Content-Length"), rec.Body.Len())
+	}
+
+	// Pro plan: only the unconditional rule applies
+	rec = serve(p, map[string]interface{}{"plan": "pro"}, orderBody, "application/json")
+	out = decode(t, rec.Body.Bytes())
+	if _, ok := out["cost_breakdown"]; !ok {
+		t.Error("expected cost_breakdown kept for pro plan")
+	}
+
+	stats := p.Stats()["rules"].([]map[string]interface{})
+	if stats[0]["id"] != "free-tier" || stats[0]["applied"] != int64(1) {
+		t.Errorf("unexpected free-tier stats: %v", stats[0])
+	}
+	if stats[1]["applied"] != int64(2) {
+		t.Errorf("unexpected everyone stats: %v", stats[1])
+	}
+}
+
+func TestMiddleware_SkipsNonJSONAndOversized(t *testing.T) {
+	p, err := New(config.ResponseFieldPolicyConfig{
+		Enabled:     true,
+		MaxBodySize: 64,
+		Rules:       []config.ResponseFieldPolicyRule{{DenyFields: []string{"internal_notes"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(p, nil, "<html>internal_notes</html>", "text/html")
+	if rec.Body.String() != "<html>internal_notes</html>" {
+		t.Errorf("expected non-JSON body untouched, got %s", rec.Body.String())
+	}
+
+	big := `{"internal_notes":"` + strings.Repeat("x", 100) + `"}`
+	rec = serve(p, nil, big, "application/json")
+	if rec.Body.String() != big {
+		t.Error("expected oversized body untouched")
+	}
+
+	stats := p.Stats()
+	if stats["skipped_non_json"] != int64(1) || stats["skipped_oversized"] != int64(1) {
+		t.Errorf("unexpected skip counters: %v", stats)
+	}
+}
+
+func TestMiddleware_OversizedStreamsPastCap(t *testing.T) {
+	p, err := New(config.ResponseFieldPolicyConfig{
+		Enabled:     true,
+		MaxBodySize: 64,
+		Rules:       []config.ResponseFieldPolicyRule{{DenyFields: []string{"internal_notes"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunk := `{"internal_notes":"` + strings.Repeat("x", 30) + `",`
+	rec := httptest.NewRecorder()
+	handler := p.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(chunk))
+		if rec.Body.Len() != 0 {
+			t.Error("expected the body under the cap to be buffered")
+		}
+		w.Write([]byte(chunk))
+		// Past the cap, the response goes out as it is written.
+		if rec.Body.String() != chunk+chunk {
+			t.Errorf("expected passthrough after the cap, client has %q", rec.Body.String())
+		}
+		w.Write([]byte(`"n":1}`))
+	}))
+	req := httptest.NewRequest("GET", "/orders/1", nil)
+	req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, variables.NewContext(req)))
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected status and headers kept, got %d %v", rec.Code, rec.Header())
+	}
+	if want := chunk + chunk + `"n":1}`; rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body.String(), want)
+	}
+	if got := p.Stats()["skipped_oversized"]; got != int64(1) {
+		t.Errorf("skipped_oversized = %v, want 1", got)
+	}
+}
+
+func TestNew_InvalidExpression(t *testing.T) {
+	_, err := New(config.ResponseFieldPolicyConfig{
+		Enabled: true,
+		Rules:   []config.ResponseFieldPolicyRule{{ID: "bad", When: "auth.claims.plan ==", DenyFields: []string{"a"}}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "rule bad") {
+		t.Errorf("expected compile error naming the rule, got %v", err)
+	}
+}
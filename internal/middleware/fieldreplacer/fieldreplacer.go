@@ -58,11 +58,18 @@ func New(cfg config.FieldReplacerConfig) (*FieldReplacer, error) {
 func (fr *FieldReplacer) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			bw := bufutil.New()
+			bw := bufutil.NewFor(r)
 			next.ServeHTTP(bw, r)
+			if bw.Aborted(w) {
+				return
+			}
 
-			result := fr.apply(bw.Body.Bytes())
-			bw.FlushToWithLength(w, result)
+			body, err := bw.Bytes()
+			if err != nil {
+				bw.FlushTo(w)
+				return
+			}
+			bw.FlushToWithLength(w, fr.apply(body))
 		})
 	}
 }
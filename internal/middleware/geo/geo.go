@@ -8,6 +8,7 @@ import (
 
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/decision"
 	"github.com/wudi/runway/internal/logging"
 	"github.com/wudi/runway/variables"
 	"go.uber.org/zap"
@@ -103,6 +104,9 @@ func (g *CompiledGeo) Handle(w http.ResponseWriter, r *http.Request) (*http.Requ
 			zap.Error(err),
 		)
 		// On lookup error, allow the request through
+		if rec := decision.FromContext(r.Context()); rec != nil {
+			rec.Add("geo", decision.Allow, "ip", rec.Hash(clientIP), "error", "lookup failed")
+		}
 		g.metrics.Allowed.Add(1)
 		return r, true
 	}
@@ -122,6 +126,16 @@ func (g *CompiledGeo) Handle(w http.ResponseWriter, r *http.Request) (*http.Requ
 
 	// Check allow/deny rules
 	allowed := g.checkRules(result)
+	if rec := decision.FromContext(r.Context()); rec != nil {
+		outcome := decision.Allow
+		if !allowed {
+			outcome = decision.Deny
+			if g.shadowMode {
+				outcome = decision.Detect
+			}
+		}
+		rec.Add("geo", outcome, "ip", rec.Hash(clientIP), "country", result.CountryCode)
+	}
 
 	if !allowed {
 		if g.shadowMode {
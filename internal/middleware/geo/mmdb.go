@@ -2,9 +2,9 @@ package geo
 
 import (
 	"fmt"
-	"net/netip"
 
 	"github.com/oschwald/maxminddb-golang/v2"
+	"github.com/wudi/runway/internal/ipaddr"
 )
 
 type mmdbProvider struct {
@@ -31,9 +31,9 @@ func newMMDBProvider(path string) (*mmdbProvider, error) {
 }
 
 func (p *mmdbProvider) Lookup(ip string) (*GeoResult, error) {
-	addr, err := netip.ParseAddr(ip)
-	if err != nil {
-		return nil, fmt.Errorf("invalid IP address: %w", err)
+	addr, ok := ipaddr.Parse(ip)
+	if !ok {
+		return nil, fmt.Errorf("invalid IP address %q", ip)
 	}
 
 	var record mmdbRecord
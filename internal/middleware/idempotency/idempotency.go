@@ -13,6 +13,7 @@ import (
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/variables"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/storecrypt"
 )
 
 // CompiledIdempotency is a compiled per-route idempotency handler created once during route setup.
@@ -39,8 +40,9 @@ type inflightEntry struct {
 	resp *StoredResponse
 }
 
-// New creates a new CompiledIdempotency from config.
-func New(routeID string, cfg config.IdempotencyConfig, redisClient *redis.Client) (*CompiledIdempotency, error) {
+// New creates a new CompiledIdempotency from config. keys seals the stored
+// responses when the route sets encrypt.
+func New(routeID string, cfg config.IdempotencyConfig, redisClient *redis.Client, keys *storecrypt.Keyring) (*CompiledIdempotency, error) {
 	headerName := cfg.HeaderName
 	if headerName == "" {
 		headerName = "Idempotency-Key"
@@ -84,7 +86,10 @@ func New(routeID string, cfg config.IdempotencyConfig, redisClient *redis.Client
 
 	var store Store
 	if mode == "distributed" && redisClient != nil {
-		store = NewRedisStore(redisClient, "gw:idem:"+routeID+":")
+		if !cfg.Encrypt {
+			keys = nil
+		}
+		store = NewRedisStore(redisClient, "gw:idem:"+routeID+":", keys)
 	} else {
 		store = NewMemoryStore(ttl)
 	}
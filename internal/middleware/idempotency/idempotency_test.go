@@ -14,7 +14,7 @@ import (
 )
 
 func newTestIdempotency(cfg config.IdempotencyConfig) *CompiledIdempotency {
-	ci, _ := New("test-route", cfg, nil)
+	ci, _ := New("test-route", cfg, nil, nil)
 	return ci
 }
 
@@ -395,7 +395,7 @@ func TestMemoryStoreExpiry(t *testing.T) {
 }
 
 func TestManagerAddAndGet(t *testing.T) {
-	m := NewIdempotencyByRoute(nil)
+	m := NewIdempotencyByRoute(nil, nil)
 
 	err := m.AddRoute("route-1", config.IdempotencyConfig{Enabled: true})
 	if err != nil {
@@ -427,7 +427,7 @@ func TestCustomHeaderName(t *testing.T) {
 	ci, _ := New("test", config.IdempotencyConfig{
 		Enabled:    true,
 		HeaderName: "X-Request-Id",
-	}, nil)
+	}, nil, nil)
 	defer ci.Close()
 
 	r := httptest.NewRequest("POST", "/", nil)
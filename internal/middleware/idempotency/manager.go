@@ -4,17 +4,18 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/storecrypt"
 )
 
 // IdempotencyByRoute manages per-route idempotency handlers.
 type IdempotencyByRoute = byroute.NamedFactory[*CompiledIdempotency, config.IdempotencyConfig]
 
 // NewIdempotencyByRoute creates a new IdempotencyByRoute manager.
-// The redis client is captured in the constructor closure.
-func NewIdempotencyByRoute(redisClient *redis.Client) *IdempotencyByRoute {
+// The redis client and keyring are captured in the constructor closure.
+func NewIdempotencyByRoute(redisClient *redis.Client, keys *storecrypt.Keyring) *IdempotencyByRoute {
 	return byroute.NewNamedFactory(
 		func(routeID string, cfg config.IdempotencyConfig) (*CompiledIdempotency, error) {
-			return New(routeID, cfg, redisClient)
+			return New(routeID, cfg, redisClient, keys)
 		},
 		func(ci *CompiledIdempotency) any { return ci.Status() },
 	).WithClose((*CompiledIdempotency).Close)
@@ -9,6 +9,7 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/storecrypt"
 	"go.uber.org/zap"
 )
 
@@ -20,13 +21,16 @@ func init() {
 type RedisStore struct {
 	client *redis.Client
 	prefix string
+	keys   *storecrypt.Keyring // nil stores responses unsealed
 }
 
-// NewRedisStore creates a new Redis-backed idempotency store.
-func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+// NewRedisStore creates a new Redis-backed idempotency store. A non-nil keyring
+// seals stored responses.
+func NewRedisStore(client *redis.Client, prefix string, keys *storecrypt.Keyring) *RedisStore {
 	return &RedisStore{
 		client: client,
 		prefix: prefix,
+		keys:   keys,
 	}
 }
 
@@ -43,17 +47,32 @@ func (s *RedisStore) Get(ctx context.Context, key string) (*StoredResponse, erro
 		return nil, nil // fail-open
 	}
 
+	plain, stale, err := s.keys.Open(data, s.prefix+key)
+	if err != nil {
+		logging.Warn("Redis idempotency decrypt failed, treating as miss", zap.Error(err))
+		return nil, nil
+	}
+
 	var resp StoredResponse
-	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&resp); err != nil {
+	if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(&resp); err != nil {
 		logging.Warn("Redis idempotency decode failed, treating as miss", zap.Error(err))
 		return nil, nil
 	}
+	if stale {
+		err := s.keys.Reseal(plain, s.prefix+key, func(sealed []byte) error {
+			return s.client.Set(ctx, s.prefix+key, sealed, redis.KeepTTL).Err()
+		})
+		if err != nil {
+			logging.Warn("Redis idempotency re-encrypt failed", zap.Error(err))
+		}
+	}
 	return &resp, nil
 }
 
 func (s *RedisStore) Set(ctx context.Context, key string, resp *StoredResponse, ttl time.Duration) error {
-	var buf bytes.Buffer
-	if err := gob.NewEncoder(&buf).Encode(resp); err != nil {
+	buf := storecrypt.GetBuffer()
+	defer storecrypt.PutBuffer(buf)
+	if err := gob.NewEncoder(buf).Encode(resp); err != nil {
 		logging.Warn("Redis idempotency encode failed", zap.Error(err))
 		return err
 	}
@@ -61,7 +80,10 @@ func (s *RedisStore) Set(ctx context.Context, key string, resp *StoredResponse,
 	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
 	defer cancel()
 
-	if err := s.client.Set(ctx, s.prefix+key, buf.Bytes(), ttl).Err(); err != nil {
+	err := s.keys.Seal(buf.Bytes(), s.prefix+key, func(data []byte) error {
+		return s.client.Set(ctx, s.prefix+key, data, ttl).Err()
+	})
+	if err != nil {
 		logging.Warn("Redis idempotency set failed", zap.Error(err))
 		return err
 	}
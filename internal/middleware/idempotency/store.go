@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/wudi/runway/internal/clock"
 )
 
 // StoredResponse holds a cached response for replay.
@@ -29,6 +31,7 @@ type MemoryStore struct {
 	mu      sync.Mutex
 	entries map[string]*memEntry
 	cancel  context.CancelFunc
+	clock   clock.Clock
 }
 
 type memEntry struct {
@@ -43,6 +46,7 @@ func NewMemoryStore(ttl time.Duration) *MemoryStore {
 	ms := &MemoryStore{
 		entries: make(map[string]*memEntry),
 		cancel:  cancel,
+		clock:   clock.Default(),
 	}
 	cleanupInterval := ttl / 2
 	if cleanupInterval > 30*time.Second {
@@ -63,7 +67,7 @@ func (ms *MemoryStore) Get(_ context.Context, key string) (*StoredResponse, erro
 	if !ok {
 		return nil, nil
 	}
-	if time.Now().After(e.expiry) {
+	if ms.clock.Now().After(e.expiry) {
 		delete(ms.entries, key)
 		return nil, nil
 	}
@@ -76,7 +80,7 @@ func (ms *MemoryStore) Set(_ context.Context, key string, resp *StoredResponse,
 
 	ms.entries[key] = &memEntry{
 		resp:   resp,
-		expiry: time.Now().Add(ttl),
+		expiry: ms.clock.Now().Add(ttl),
 	}
 	return nil
 }
@@ -95,7 +99,7 @@ func (ms *MemoryStore) cleanup(ctx context.Context, interval time.Duration) {
 			return
 		case <-ticker.C:
 			ms.mu.Lock()
-			now := time.Now()
+			now := ms.clock.Now()
 			for key, e := range ms.entries {
 				if now.After(e.expiry) {
 					delete(ms.entries, key)
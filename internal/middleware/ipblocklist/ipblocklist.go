@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -15,6 +17,7 @@ import (
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/ipaddr"
 	"github.com/wudi/runway/internal/logging"
 	"github.com/wudi/runway/variables"
 	"go.uber.org/zap"
@@ -33,6 +36,8 @@ type Blocklist struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
+	temp *TempBlocks // nil unless reputation scoring is enabled
+
 	metrics *BlocklistMetrics
 }
 
@@ -58,6 +63,135 @@ type BlocklistStatus struct {
 	LoggedHits     int64  `json:"logged_hits"`
 	FeedRefreshes  int64  `json:"feed_refreshes"`
 	FeedErrors     int64  `json:"feed_errors"`
+
+	TemporaryEntries int         `json:"temporary_entries,omitempty"`
+	Temporary        []TempBlock `json:"temporary,omitempty"`
+}
+
+// TempBlock is an IP blocked until Expires, such as by reputation scoring.
+// IP is an IPv6 network in CIDR notation when the block covers an
+// aggregated client key.
+type TempBlock struct {
+	IP         string    `json:"ip"`
+	Aggregated bool      `json:"aggregated,omitempty"` // IP is an IPv6 network, not a single address
+	Expires    time.Time `json:"expires"`
+	Signals    []string  `json:"signals,omitempty"` // signals that led to the block
+	Score      float64   `json:"score,omitempty"`   // score that crossed the threshold
+	Strikes    int       `json:"strikes,omitempty"` // how many times the IP has been blocked
+}
+
+// TempBlocks holds temporary IP blocks. It outlives the blocklists it is
+// attached to, so blocks survive config reloads.
+type TempBlocks struct {
+	mu     sync.RWMutex
+	blocks map[netip.Prefix]TempBlock
+	bits   map[int]int // blocked prefix lengths -> number of blocks
+}
+
+// NewTempBlocks creates an empty set of temporary blocks.
+func NewTempBlocks() *TempBlocks {
+	return &TempBlocks{blocks: make(map[netip.Prefix]TempBlock), bits: make(map[int]int)}
+}
+
+// Add blocks b.IP, an address or CIDR, until b.Expires, replacing any
+// earlier block of it. Expired blocks are dropped.
+func (t *TempBlocks) Add(b TempBlock) {
+	p, err := ipaddr.ParsePrefix(b.IP)
+	if err != nil {
+		return
+	}
+	b.Aggregated = !p.IsSingleIP()
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, old := range t.blocks {
+		if !old.Expires.After(now) {
+			t.delete(k)
+		}
+	}
+	if _, ok := t.blocks[p]; !ok {
+		t.bits[p.Bits()]++
+	}
+	t.blocks[p] = b
+}
+
+// delete removes the block of p. t.mu must be held.
+func (t *TempBlocks) delete(p netip.Prefix) {
+	if _, ok := t.blocks[p]; !ok {
+		return
+	}
+	delete(t.blocks, p)
+	if t.bits[p.Bits()]--; t.bits[p.Bits()] == 0 {
+		delete(t.bits, p.Bits())
+	}
+}
+
+// Remove lifts the block of ip, an address or CIDR as passed to Add. It
+// reports whether ip was blocked.
+func (t *TempBlocks) Remove(ip string) bool {
+	p, err := ipaddr.ParsePrefix(ip)
+	if err != nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, ok := t.blocks[p]
+	t.delete(p)
+	return ok && b.Expires.After(time.Now())
+}
+
+// Get returns the active block of ip. For a single address this is any
+// block covering it, including that of its aggregated network.
+func (t *TempBlocks) Get(ip string) (TempBlock, bool) {
+	p, err := ipaddr.ParsePrefix(ip)
+	if err != nil {
+		return TempBlock{}, false
+	}
+	if p.IsSingleIP() {
+		return t.lookup(p.Addr())
+	}
+	t.mu.RLock()
+	b, ok := t.blocks[p]
+	t.mu.RUnlock()
+	if !ok || !b.Expires.After(time.Now()) {
+		return TempBlock{}, false
+	}
+	return b, true
+}
+
+// lookup returns the active block covering addr.
+func (t *TempBlocks) lookup(addr netip.Addr) (TempBlock, bool) {
+	now := time.Now()
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for bits := range t.bits {
+		if bits > addr.BitLen() {
+			continue
+		}
+		p, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if b, ok := t.blocks[p]; ok && b.Expires.After(now) {
+			return b, true
+		}
+	}
+	return TempBlock{}, false
+}
+
+// List returns the active blocks, soonest to expire first.
+func (t *TempBlocks) List() []TempBlock {
+	now := time.Now()
+	t.mu.RLock()
+	out := make([]TempBlock, 0, len(t.blocks))
+	for _, b := range t.blocks {
+		if b.Expires.After(now) {
+			out = append(out, b)
+		}
+	}
+	t.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Expires.Before(out[j].Expires) })
+	return out
 }
 
 // New creates a new Blocklist from config.
@@ -93,10 +227,25 @@ func New(cfg config.IPBlocklistConfig) (*Blocklist, error) {
 	return bl, nil
 }
 
+// SetTemporary makes the blocklist also enforce the temporary blocks in tb.
+// It must be called before the blocklist serves requests.
+func (bl *Blocklist) SetTemporary(tb *TempBlocks) {
+	bl.temp = tb
+}
+
 // Check returns true if the IP is blocked.
 func (bl *Blocklist) Check(ip net.IP) bool {
 	bl.metrics.TotalChecks.Add(1)
 
+	// Check temporary blocks
+	if bl.temp != nil {
+		if addr, ok := netip.AddrFromSlice(ip); ok {
+			if _, blocked := bl.temp.lookup(addr.WithZone("").Unmap()); blocked {
+				return true
+			}
+		}
+	}
+
 	// Check static entries
 	for _, n := range bl.staticNets {
 		if n.Contains(ip) {
@@ -123,11 +272,12 @@ func (bl *Blocklist) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			clientIP := variables.ExtractClientIP(r)
-			ip := net.ParseIP(clientIP)
-			if ip == nil {
+			addr, ok := ipaddr.Parse(clientIP)
+			if !ok {
 				next.ServeHTTP(w, r)
 				return
 			}
+			ip := net.IP(addr.AsSlice())
 
 			if bl.Check(ip) {
 				if bl.action == "log" {
@@ -163,7 +313,7 @@ func (bl *Blocklist) Close() {
 
 // Status returns the admin status snapshot.
 func (bl *Blocklist) Status() BlocklistStatus {
-	return BlocklistStatus{
+	st := BlocklistStatus{
 		Action:        bl.action,
 		StaticEntries: bl.metrics.StaticEntries,
 		FeedEntries:   bl.metrics.FeedEntries.Load(),
@@ -174,6 +324,11 @@ func (bl *Blocklist) Status() BlocklistStatus {
 		FeedRefreshes: bl.metrics.FeedRefreshes.Load(),
 		FeedErrors:    bl.metrics.FeedErrors.Load(),
 	}
+	if bl.temp != nil {
+		st.Temporary = bl.temp.List()
+		st.TemporaryEntries = len(st.Temporary)
+	}
+	return st
 }
 
 // refreshLoop runs a ticker-based refresh loop for a single feed.
@@ -283,7 +438,7 @@ func parseTextFeed(r interface{ Read([]byte) (int, error) }) []string {
 }
 
 // parseEntries parses a list of IP/CIDR strings into net.IPNet pointers.
-// Single IPs are converted to /32 or /128.
+// Single IPs are converted to /32 or /128, and IPv4-mapped entries to IPv4.
 func parseEntries(entries []string) ([]*net.IPNet, error) {
 	var nets []*net.IPNet
 	for _, entry := range entries {
@@ -291,27 +446,14 @@ func parseEntries(entries []string) ([]*net.IPNet, error) {
 		if entry == "" {
 			continue
 		}
-
-		// Try CIDR first
-		if strings.Contains(entry, "/") {
-			_, ipNet, err := net.ParseCIDR(entry)
-			if err != nil {
-				return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
-			}
-			nets = append(nets, ipNet)
-			continue
-		}
-
-		// Single IP
-		ip := net.ParseIP(entry)
-		if ip == nil {
-			return nil, fmt.Errorf("invalid IP %q", entry)
-		}
-		if ip.To4() != nil {
-			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)})
-		} else {
-			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+		p, err := ipaddr.ParsePrefix(entry)
+		if err != nil {
+			return nil, err
 		}
+		nets = append(nets, &net.IPNet{
+			IP:   p.Addr().AsSlice(),
+			Mask: net.CIDRMask(p.Bits(), p.Addr().BitLen()),
+		})
 	}
 	return nets, nil
 }
@@ -342,3 +342,35 @@ func TestDefaultAction(t *testing.T) {
 		t.Errorf("expected default action=block, got %s", bl.action)
 	}
 }
+
+func TestTemporaryBlocks(t *testing.T) {
+	bl, err := New(config.IPBlocklistConfig{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bl.Close()
+	tb := NewTempBlocks()
+	bl.SetTemporary(tb)
+
+	tb.Add(TempBlock{IP: "1.2.3.4", Expires: time.Now().Add(time.Minute), Signals: []string{"waf"}})
+	tb.Add(TempBlock{IP: "5.6.7.8", Expires: time.Now().Add(-time.Second)})
+
+	if !bl.Check(net.ParseIP("1.2.3.4")) {
+		t.Error("expected temporarily blocked IP to be blocked")
+	}
+	if bl.Check(net.ParseIP("5.6.7.8")) {
+		t.Error("expired block should not apply")
+	}
+
+	st := bl.Status()
+	if st.TemporaryEntries != 1 || st.Temporary[0].IP != "1.2.3.4" || st.Temporary[0].Signals[0] != "waf" {
+		t.Errorf("expected temporary block in status, got %+v", st.Temporary)
+	}
+
+	if !tb.Remove("1.2.3.4") {
+		t.Error("expected Remove to report the active block")
+	}
+	if bl.Check(net.ParseIP("1.2.3.4")) {
+		t.Error("removed block should not apply")
+	}
+}
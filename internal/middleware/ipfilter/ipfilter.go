@@ -1,20 +1,23 @@
 package ipfilter
 
 import (
-	"net"
 	"net/http"
+	"net/netip"
 
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/ipaddr"
 	"github.com/wudi/runway/variables"
 )
 
-// Filter checks client IPs against allow/deny lists
+// Filter checks client IPs against allow/deny lists. Entries of either
+// family may be mixed in one list; IPv4-mapped IPv6 clients and entries are
+// treated as IPv4.
 type Filter struct {
 	enabled bool
-	allow   []*net.IPNet
-	deny    []*net.IPNet
+	allow   []netip.Prefix
+	deny    []netip.Prefix
 	order   string // "allow_first" or "deny_first"
 }
 
@@ -29,40 +32,27 @@ func New(cfg config.IPFilterConfig) (*Filter, error) {
 		f.order = "deny_first"
 	}
 
-	for _, cidr := range cfg.Allow {
-		_, ipNet, err := net.ParseCIDR(cidr)
-		if err != nil {
-			// Try as single IP
-			ip := net.ParseIP(cidr)
-			if ip == nil {
-				return nil, err
-			}
-			if ip.To4() != nil {
-				_, ipNet, _ = net.ParseCIDR(cidr + "/32")
-			} else {
-				_, ipNet, _ = net.ParseCIDR(cidr + "/128")
-			}
-		}
-		f.allow = append(f.allow, ipNet)
+	var err error
+	if f.allow, err = parsePrefixes(cfg.Allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = parsePrefixes(cfg.Deny); err != nil {
+		return nil, err
 	}
+	return f, nil
+}
 
-	for _, cidr := range cfg.Deny {
-		_, ipNet, err := net.ParseCIDR(cidr)
+// parsePrefixes parses CIDRs and single IPs, which become /32 or /128.
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		p, err := ipaddr.ParsePrefix(entry)
 		if err != nil {
-			ip := net.ParseIP(cidr)
-			if ip == nil {
-				return nil, err
-			}
-			if ip.To4() != nil {
-				_, ipNet, _ = net.ParseCIDR(cidr + "/32")
-			} else {
-				_, ipNet, _ = net.ParseCIDR(cidr + "/128")
-			}
+			return nil, err
 		}
-		f.deny = append(f.deny, ipNet)
+		prefixes = append(prefixes, p)
 	}
-
-	return f, nil
+	return prefixes, nil
 }
 
 // Check returns true if the IP is allowed
@@ -71,9 +61,8 @@ func (f *Filter) Check(r *http.Request) bool {
 		return true
 	}
 
-	clientIP := variables.ExtractClientIP(r)
-	ip := net.ParseIP(clientIP)
-	if ip == nil {
+	ip, ok := ipaddr.Parse(variables.ExtractClientIP(r))
+	if !ok {
 		return false
 	}
 
@@ -83,7 +72,7 @@ func (f *Filter) Check(r *http.Request) bool {
 	return f.checkDenyFirst(ip)
 }
 
-func (f *Filter) checkAllowFirst(ip net.IP) bool {
+func (f *Filter) checkAllowFirst(ip netip.Addr) bool {
 	// If allow list exists and IP matches, allow
 	if len(f.allow) > 0 {
 		for _, cidr := range f.allow {
@@ -104,7 +93,7 @@ func (f *Filter) checkAllowFirst(ip net.IP) bool {
 	return true
 }
 
-func (f *Filter) checkDenyFirst(ip net.IP) bool {
+func (f *Filter) checkDenyFirst(ip netip.Addr) bool {
 	// Check deny list first
 	for _, cidr := range f.deny {
 		if cidr.Contains(ip) {
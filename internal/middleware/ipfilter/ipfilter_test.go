@@ -70,6 +70,41 @@ func TestFilterAllowFirst(t *testing.T) {
 	}
 }
 
+func TestFilterIPv6(t *testing.T) {
+	f, err := New(config.IPFilterConfig{
+		Enabled: true,
+		Allow:   []string{"10.0.0.0/8", "2001:db8::/32", "::ffff:172.16.0.0/108"},
+		Order:   "allow_first",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		allowed    bool
+	}{
+		{"IPv4", "10.1.2.3:1234", true},
+		{"IPv4-mapped client", "[::ffff:10.1.2.3]:1234", true},
+		{"IPv6", "[2001:db8::1]:1234", true},
+		{"IPv6 with zone", "[2001:db8::1%eth0]:1234", true},
+		{"IPv6 outside list", "[2001:db9::1]:1234", false},
+		{"IPv4-mapped entry", "172.16.5.5:1234", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+
+			if got := f.Check(r); got != tt.allowed {
+				t.Errorf("Check() = %v, want %v", got, tt.allowed)
+			}
+		})
+	}
+}
+
 func TestFilterDisabled(t *testing.T) {
 	f, _ := New(config.IPFilterConfig{
 		Enabled: false,
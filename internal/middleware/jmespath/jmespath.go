@@ -42,10 +42,17 @@ func New(cfg config.JMESPathConfig) (*JMESPath, error) {
 func (jp *JMESPath) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			bw := bufutil.New()
+			bw := bufutil.NewFor(r)
 			next.ServeHTTP(bw, r)
+			if bw.Aborted(w) {
+				return
+			}
 
-			body := bw.Body.Bytes()
+			body, err := bw.Bytes()
+			if err != nil {
+				bw.FlushTo(w)
+				return
+			}
 
 			// Only transform JSON responses
 			ct := bw.Header().Get("Content-Type")
@@ -84,11 +91,14 @@ func (jp *JMESPath) Middleware() middleware.Middleware {
 
 			jp.applied.Add(1)
 
-			bufutil.CopyHeaders(w.Header(), bw.Header())
+			bw.CopyHeadersTo(w.Header())
 			w.Header().Set("Content-Type", "application/json")
-			w.Header().Set("Content-Length", strconv.Itoa(len(encoded)))
+			if !bw.HasTrailers() {
+				w.Header().Set("Content-Length", strconv.Itoa(len(encoded)))
+			}
 			w.WriteHeader(bw.StatusCode)
 			w.Write(encoded)
+			bw.WriteTrailers(w)
 		})
 	}
 }
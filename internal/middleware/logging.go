@@ -7,10 +7,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/logging"
 	"github.com/wudi/runway/internal/middleware/accesslog"
 	"github.com/wudi/runway/variables"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var loggingRWPool = sync.Pool{
@@ -27,6 +29,9 @@ type LoggingConfig struct {
 	SkipPaths []string
 	// JSON enables JSON logging
 	JSON bool
+	// Fields are the fields of logs whose format is "json"; empty selects
+	// config.AccessLogFields. They are written to Output.
+	Fields []string
 }
 
 // DefaultLoggingConfig provides default logging settings
@@ -65,6 +70,11 @@ func LoggingWithConfig(cfg LoggingConfig) Middleware {
 	}
 
 	resolver := variables.NewResolver()
+	jsonFields, err := accesslog.CompileFields(cfg.Fields)
+	if err != nil {
+		logging.Warn("invalid access log fields, using the defaults", zap.Error(err))
+		jsonFields, _ = accesslog.CompileFields(nil)
+	}
 	skipPaths := make(map[string]bool)
 	for _, p := range cfg.SkipPaths {
 		skipPaths[p] = true
@@ -94,10 +104,16 @@ func LoggingWithConfig(cfg LoggingConfig) Middleware {
 
 			// Get or create variable context
 			varCtx := variables.GetFromRequest(r)
-			varCtx.Status = lrw.status
+			varCtx.Status = varCtx.RecordedStatus(lrw.status)
 			varCtx.BodyBytesSent = lrw.bytes
 			varCtx.ResponseTime = duration
 
+			// Synthetic monitor probes are counted separately, not logged;
+			// internal requests are not logged at all
+			if varCtx.Synthetic || varCtx.Internal {
+				return
+			}
+
 			// Per-route access log overrides
 			var alCfg *accesslog.CompiledAccessLog
 			if varCtx.AccessLogConfig != nil {
@@ -110,7 +126,12 @@ func LoggingWithConfig(cfg LoggingConfig) Middleware {
 			}
 
 			// Check conditional logging (status codes, methods, sampling)
-			if alCfg != nil && !alCfg.ShouldLog(lrw.status, r.Method) {
+			if alCfg != nil && !alCfg.ShouldLog(varCtx.Status, r.Method) {
+				return
+			}
+
+			// Per-route minimum level and adaptive sampling under log bursts
+			if alCfg != nil && !alCfg.Admit(varCtx.RouteID, varCtx.Status) {
 				return
 			}
 
@@ -120,15 +141,21 @@ func LoggingWithConfig(cfg LoggingConfig) Middleware {
 				format = alCfg.Format
 			}
 
-			if cfg.JSON {
+			if format == config.AccessLogFormatJSON {
+				fields := jsonFields
+				if alCfg != nil && alCfg.JSONFields != nil {
+					fields = alCfg.JSONFields
+				}
+				writeJSONLog(cfg.Output, fields, r, varCtx, start, alCfg, lrw.Header())
+			} else if cfg.JSON {
 				// Stack-allocated array avoids slice growth allocations.
-				var fields [16]zap.Field
+				var fields [20]zap.Field
 				n := 0
 				fields[n] = zap.String("request_id", varCtx.RequestID); n++
 				fields[n] = zap.String("remote_addr", variables.ExtractClientIP(r)); n++
 				fields[n] = zap.String("method", r.Method); n++
 				fields[n] = zap.String("path", r.URL.Path); n++
-				fields[n] = zap.Int("status", lrw.status); n++
+				fields[n] = zap.Int("status", varCtx.Status); n++
 				fields[n] = zap.Int64("body_bytes", lrw.bytes); n++
 				fields[n] = zap.Duration("response_time", duration); n++
 				if r.URL.RawQuery != "" {
@@ -137,18 +164,39 @@ func LoggingWithConfig(cfg LoggingConfig) Middleware {
 				if varCtx.RouteID != "" {
 					fields[n] = zap.String("route_id", varCtx.RouteID); n++
 				}
+				if varCtx.ListenerID != "" {
+					fields[n] = zap.String("listener_id", varCtx.ListenerID); n++
+				}
 				if varCtx.UpstreamAddr != "" {
 					fields[n] = zap.String("upstream_addr", varCtx.UpstreamAddr); n++
 				}
+				if varCtx.ServedByPeer != "" {
+					fields[n] = zap.String("served_by_peer", varCtx.ServedByPeer); n++
+				}
+				if varCtx.FallbackTrigger != "" {
+					fields[n] = zap.String("fallback_trigger", varCtx.FallbackTrigger); n++
+				}
 				if varCtx.TenantID != "" {
 					fields[n] = zap.String("tenant_id", varCtx.TenantID); n++
 				}
+				if varCtx.RateLimitCost > 0 {
+					fields[n] = zap.Int("rate_limit_cost", varCtx.RateLimitCost); n++
+				}
+				if varCtx.ClientAbort != variables.AbortNone {
+					fields[n] = zap.String("client_abort", varCtx.ClientAbort.String()); n++
+				}
+				if varCtx.TimeoutReason != "" {
+					fields[n] = zap.String("timeout", varCtx.TimeoutReason); n++
+				}
 				if varCtx.Identity != nil {
 					fields[n] = zap.String("auth_client_id", varCtx.Identity.ClientID); n++
 				}
 				if ua := r.UserAgent(); ua != "" {
 					fields[n] = zap.String("user_agent", ua); n++
 				}
+				if len(varCtx.LogMetadata) > 0 {
+					fields[n] = zap.Object("route_metadata", stringFields(varCtx.LogMetadata)); n++
+				}
 
 				// Per-route header/body capture may exceed the fixed array; use append for overflow.
 				extra := fields[:n]
@@ -185,6 +233,42 @@ func LoggingWithConfig(cfg LoggingConfig) Middleware {
 	}
 }
 
+// writeJSONLog writes the access log of r as one JSON object with the
+// selected fields, followed by the captured headers and bodies.
+func writeJSONLog(w io.Writer, fields accesslog.Fields, r *http.Request, varCtx *variables.Context, start time.Time, alCfg *accesslog.CompiledAccessLog, respHeader http.Header) {
+	if !logging.Global().Core().Enabled(zapcore.InfoLevel) {
+		return
+	}
+	entry := fields.Append(make([]zap.Field, 0, len(fields)+4), r, varCtx, start)
+	if alCfg != nil && alCfg.HasHeaderCapture() {
+		if h := alCfg.CaptureRequestHeaders(r); len(h) > 0 {
+			entry = append(entry, zap.Object("request_headers", stringFields(h)))
+		}
+		if h := alCfg.CaptureResponseHeaders(respHeader); len(h) > 0 {
+			entry = append(entry, zap.Object("response_headers", stringFields(h)))
+		}
+	}
+	if body := varCtx.Custom["_al_req_body"]; body != "" {
+		entry = append(entry, accesslog.BodyField("request_body", body))
+	}
+	if body := varCtx.Custom["_al_resp_body"]; body != "" {
+		entry = append(entry, accesslog.BodyField("response_body", body))
+	}
+	if err := accesslog.WriteJSON(w, entry); err != nil {
+		logging.Debug("writing JSON access log failed", zap.Error(err))
+	}
+}
+
+// stringFields logs a string map as an object without reflection.
+type stringFields map[string]string
+
+func (m stringFields) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for k, v := range m {
+		enc.AddString(k, v)
+	}
+	return nil
+}
+
 // loggingResponseWriter wraps http.ResponseWriter to capture status and bytes
 type loggingResponseWriter struct {
 	http.ResponseWriter
@@ -2,13 +2,18 @@ package middleware
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/middleware/accesslog"
 	"github.com/wudi/runway/variables"
 )
 
@@ -434,3 +439,68 @@ func TestLoggingJSONWithoutOptionalFields(t *testing.T) {
 		t.Errorf("expected status 200, got %d", rr.Code)
 	}
 }
+
+func TestLoggingJSONFormat(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	})
+
+	var out bytes.Buffer
+	mw := LoggingWithConfig(LoggingConfig{
+		Format: "json",
+		Output: &out,
+		JSON:   true,
+		Fields: []string{"method", "status", "bytes_sent", "jwt_sub", "tenant"},
+	})
+
+	req := httptest.NewRequest("POST", "/items", nil)
+	varCtx := variables.AcquireContext(req)
+	varCtx.Identity = &variables.Identity{AuthType: "jwt", Claims: map[string]interface{}{"sub": "user-1"}}
+	req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+	mw(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("expected one JSON object, got %q: %v", out.String(), err)
+	}
+	want := map[string]any{"method": "POST", "status": 201.0, "bytes_sent": 7.0, "jwt_sub": "user-1", "tenant": ""}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("got %v, want %v", entry, want)
+	}
+}
+
+func TestLoggingJSONFormatRouteOverride(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	var out bytes.Buffer
+	mw := LoggingWithConfig(LoggingConfig{Format: `$status`, Output: &out, JSON: true})
+
+	alCfg, err := accesslog.New(config.AccessLogConfig{Format: "json", Fields: []string{"path", "route_id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/items", nil)
+	varCtx := variables.AcquireContext(req)
+	varCtx.RouteID = "items"
+	varCtx.AccessLogConfig = alCfg
+	varCtx.Custom = map[string]string{
+		"_al_req_body":  `{"q": [1, 2]}`,
+		"_al_resp_body": `{"truncat`,
+	}
+	req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+	mw(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if got, want := out.String(), `{"path":"/items","route_id":"items","request_body":{"q":[1,2]},"response_body":"{\"truncat"}`+"\n"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// Other routes keep the global format, which does not write to Output.
+	out.Reset()
+	mw(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/other", nil))
+	if out.Len() != 0 {
+		t.Errorf("expected no JSON access log, got %s", out.String())
+	}
+}
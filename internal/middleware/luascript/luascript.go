@@ -12,7 +12,9 @@ import (
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/luautil"
+	"github.com/wudi/runway/internal/metrics"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/shadow"
 	"github.com/wudi/runway/variables"
 )
 
@@ -22,6 +24,16 @@ type LuaScript struct {
 	responseProto *lua.FunctionProto
 	pool          sync.Pool
 
+	// async, if set, runs shadowProto off the request path. Without a
+	// shadow script the request script is run there instead, and enforce
+	// is false.
+	async       *shadow.Evaluator
+	shadowProto *lua.FunctionProto
+	enforce     bool
+
+	// metrics, if set, is exposed to scripts as the runway module.
+	metrics luautil.MetricRecorder
+
 	requestsRun  atomic.Int64
 	responsesRun atomic.Int64
 	errors       atomic.Int64
@@ -47,6 +59,18 @@ func New(cfg config.LuaConfig) (*LuaScript, error) {
 		ls.responseProto = proto
 	}
 
+	if cfg.ShadowRequestScript != "" {
+		proto, err := luautil.CompileScript(cfg.ShadowRequestScript, "shadow_request")
+		if err != nil {
+			return nil, err
+		}
+		ls.shadowProto = proto
+	}
+	ls.enforce = !cfg.ShadowAsync || ls.shadowProto != nil
+	if cfg.ShadowAsync && (ls.shadowProto != nil || ls.requestProto != nil) {
+		ls.async = shadow.NewEvaluator(ls.evaluateShadow, true)
+	}
+
 	ls.pool = sync.Pool{
 		New: func() interface{} {
 			L := lua.NewState(lua.Options{SkipOpenLibs: true})
@@ -56,6 +80,9 @@ func New(cfg config.LuaConfig) (*LuaScript, error) {
 			lua.OpenTable(L)
 			lua.OpenMath(L)
 			luautil.RegisterAll(L)
+			if ls.metrics != nil {
+				luautil.RegisterMetrics(L, ls.metrics)
+			}
 			return L
 		},
 	}
@@ -77,44 +104,36 @@ func (ls *LuaScript) putLuaState(L *lua.LState) {
 // The script may return two values (status, body) to short-circuit the request.
 func (ls *LuaScript) RequestMiddleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
-		if ls.requestProto == nil {
+		if ls.requestProto == nil && ls.async == nil {
 			return next
 		}
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			L := ls.getLuaState()
-			defer ls.putLuaState(L)
-
-			reqUD := luautil.NewRequestUserData(L, r)
-			fn := L.NewFunctionFromProto(ls.requestProto)
-
-			L.SetGlobal("req", reqUD)
-
-			// Set ctx global if variable context is available.
-			if varCtx := variables.GetFromRequest(r); varCtx != nil {
-				L.SetGlobal("ctx", luautil.NewContextUserData(L, r, varCtx))
+			var snap *shadow.Request
+			if ls.async != nil {
+				snap = ls.async.Capture(r)
+			}
+			if !ls.enforce || ls.requestProto == nil {
+				ls.async.Submit(snap, shadow.NoVerdict)
+				next.ServeHTTP(w, r)
+				return
 			}
 
 			ls.requestsRun.Add(1)
-			if err := L.CallByParam(lua.P{
-				Fn:      fn,
-				NRet:    2,
-				Protect: true,
-			}); err != nil {
+			status, body, err := ls.runRequest(ls.requestProto, r)
+			if snap != nil {
+				ls.async.Submit(snap, scriptVerdict(status, err))
+			}
+			if err != nil {
 				ls.errors.Add(1)
 				http.Error(w, "lua request script error", http.StatusInternalServerError)
 				return
 			}
 
 			// Check for early termination: return status, body
-			ret1 := L.Get(-2)
-			ret2 := L.Get(-1)
-			L.Pop(2)
-
-			if status, ok := ret1.(lua.LNumber); ok && int(status) > 0 {
-				code := int(status)
-				w.WriteHeader(code)
-				if body, ok := ret2.(lua.LString); ok {
-					w.Write([]byte(string(body)))
+			if status > 0 {
+				w.WriteHeader(status)
+				if body != "" {
+					w.Write([]byte(body))
 				}
 				return
 			}
@@ -124,6 +143,66 @@ func (ls *LuaScript) RequestMiddleware() middleware.Middleware {
 	}
 }
 
+// runRequest runs a request-phase script against r. status is non-zero
+// when the script returned a status to short-circuit the request with.
+func (ls *LuaScript) runRequest(proto *lua.FunctionProto, r *http.Request) (status int, body string, err error) {
+	L := ls.getLuaState()
+	defer ls.putLuaState(L)
+
+	reqUD := luautil.NewRequestUserData(L, r)
+	fn := L.NewFunctionFromProto(proto)
+
+	L.SetGlobal("req", reqUD)
+
+	// Set ctx global if variable context is available.
+	if varCtx := variables.GetFromRequest(r); varCtx != nil {
+		L.SetGlobal("ctx", luautil.NewContextUserData(L, r, varCtx))
+	}
+
+	if err := L.CallByParam(lua.P{
+		Fn:      fn,
+		NRet:    2,
+		Protect: true,
+	}); err != nil {
+		return 0, "", err
+	}
+
+	ret1 := L.Get(-2)
+	ret2 := L.Get(-1)
+	L.Pop(2)
+
+	if n, ok := ret1.(lua.LNumber); ok && int(n) > 0 {
+		status = int(n)
+		if s, ok := ret2.(lua.LString); ok {
+			body = string(s)
+		}
+	}
+	return status, body, nil
+}
+
+// evaluateShadow runs the shadow script, or the unenforced request script,
+// on a request snapshot off the request path.
+func (ls *LuaScript) evaluateShadow(req *shadow.Request) shadow.Verdict {
+	proto := ls.shadowProto
+	if proto == nil {
+		proto = ls.requestProto
+	}
+	status, _, err := ls.runRequest(proto, req.HTTPRequest())
+	return scriptVerdict(status, err)
+}
+
+// scriptVerdict maps a request script outcome to a shadow verdict: a
+// short-circuit status blocks the request.
+func scriptVerdict(status int, err error) shadow.Verdict {
+	switch {
+	case err != nil:
+		return shadow.Error
+	case status > 0:
+		return shadow.Block
+	}
+	return shadow.Allow
+}
+
 // ResponseMiddleware returns a middleware that executes the response-phase Lua script.
 // It buffers the response body so the Lua script can read and modify it.
 func (ls *LuaScript) ResponseMiddleware() middleware.Middleware {
@@ -178,11 +257,16 @@ func (ls *LuaScript) ResponseMiddleware() middleware.Middleware {
 
 // Stats returns execution statistics for this script.
 func (ls *LuaScript) Stats() map[string]interface{} {
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"requests_run":  ls.requestsRun.Load(),
 		"responses_run": ls.responsesRun.Load(),
 		"errors":        ls.errors.Load(),
 	}
+	if ls.async != nil {
+		stats["enforce"] = ls.enforce
+		stats["shadow_async"] = ls.async.Stats()
+	}
+	return stats
 }
 
 // --- Buffered response writer ---
@@ -243,11 +327,45 @@ func (bw *bufferedResponseWriter) SetBody(s string) {
 // --- LuaScriptByRoute manager ---
 
 // LuaScriptByRoute manages per-route Lua scripts.
-type LuaScriptByRoute = byroute.Factory[*LuaScript, config.LuaConfig]
+type LuaScriptByRoute struct {
+	*byroute.NamedFactory[*LuaScript, config.LuaConfig]
+	metrics *metrics.PluginMetrics
+}
 
 // NewLuaScriptByRoute creates a new per-route Lua script manager.
 func NewLuaScriptByRoute() *LuaScriptByRoute {
-	return byroute.NewFactory(New, func(ls *LuaScript) any {
+	m := &LuaScriptByRoute{}
+	m.NamedFactory = byroute.NewNamedFactory(func(routeID string, cfg config.LuaConfig) (*LuaScript, error) {
+		ls, err := New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if m.metrics != nil {
+			ls.metrics = &scriptMetrics{pm: m.metrics, plugin: cfg.MetricsName(), route: routeID}
+		}
+		return ls, nil
+	}, func(ls *LuaScript) any {
 		return ls.Stats()
 	})
+	return m
+}
+
+// SetPluginMetrics exposes pm to the scripts of routes added afterwards.
+func (m *LuaScriptByRoute) SetPluginMetrics(pm *metrics.PluginMetrics) {
+	m.metrics = pm
+}
+
+// scriptMetrics reports a route's script metrics under its plugin name.
+type scriptMetrics struct {
+	pm     *metrics.PluginMetrics
+	plugin string
+	route  string
+}
+
+func (s *scriptMetrics) Inc(name string, labels map[string]string, v float64) error {
+	return s.pm.Inc(s.plugin, s.route, name, labels, v)
+}
+
+func (s *scriptMetrics) Set(name string, labels map[string]string, v float64) error {
+	return s.pm.Set(s.plugin, s.route, name, labels, v)
 }
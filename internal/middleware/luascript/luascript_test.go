@@ -5,9 +5,13 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/metrics"
+	"github.com/wudi/runway/internal/shadow"
 	"github.com/wudi/runway/variables"
 )
 
@@ -741,3 +745,131 @@ func TestResponseScript_DelHeader(t *testing.T) {
 	}
 }
 
+
+// waitShadowAsync waits until ls has evaluated n requests off the request path.
+func waitShadowAsync(t *testing.T, ls *LuaScript, n int64) shadow.Stats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s := ls.Stats()["shadow_async"].(shadow.Stats)
+		if s.Evaluated >= n {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d async evaluations, got %+v", n, s)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestShadowRequestScript(t *testing.T) {
+	ls, err := New(config.LuaConfig{
+		Enabled: true,
+		RequestScript: `
+			if req:path() == "/blocked" then return 403, "no" end
+			req:set_header("X-Lua", "enforced")
+		`,
+		ShadowAsync: true,
+		ShadowRequestScript: `
+			if req:path() == "/error" then error("boom") end
+			if string.find(req:body(), "secret") then return 451, "" end
+			req:set_header("X-Lua", "shadow")
+		`,
+	})
+	if err != nil {
+		t.Fatalf("failed to create LuaScript: %v", err)
+	}
+
+	var seen []string
+	handler := ls.RequestMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		seen = append(seen, r.Header.Get("X-Lua")+":"+string(body))
+	}))
+	for _, tc := range []struct{ path, body string }{
+		{"/ok", "secret"},
+		{"/blocked", "plain"},
+		{"/error", "plain"},
+		{"/ok", "plain"},
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", tc.path, strings.NewReader(tc.body)))
+	}
+
+	if strings.Join(seen, ",") != "enforced:secret,enforced:plain,enforced:plain" {
+		t.Errorf("expected only the enforcing script to affect requests, got %v", seen)
+	}
+	s := waitShadowAsync(t, ls, 4)
+	if s.Blocked != 1 || s.Allowed != 2 || s.Errors != 1 {
+		t.Errorf("unexpected shadow verdicts %+v", s)
+	}
+	want := shadow.Divergence{Compared: 3, ShadowBlocked: 1, EnforcingBlocked: 1}
+	if s.Divergence == nil || *s.Divergence != want {
+		t.Errorf("expected divergence %+v, got %+v", want, s.Divergence)
+	}
+	if stats := ls.Stats(); stats["requests_run"] != int64(4) || stats["errors"] != int64(0) {
+		t.Errorf("shadow runs must not count as enforced runs, got %v", stats)
+	}
+}
+
+func TestShadowAsync_RequestScriptNotEnforced(t *testing.T) {
+	ls, err := New(config.LuaConfig{
+		Enabled:       true,
+		RequestScript: `return 403, "forbidden by lua"`,
+		ShadowAsync:   true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create LuaScript: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	ls.RequestMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the request script not to be enforced, got %d", rec.Code)
+	}
+	if s := waitShadowAsync(t, ls, 1); s.Blocked != 1 || s.Divergence != nil {
+		t.Errorf("expected one short-circuit verdict without divergence, got %+v", s)
+	}
+}
+
+func TestLuaScriptByRoute_Metrics(t *testing.T) {
+	c := metrics.NewCollector()
+	m := NewLuaScriptByRoute()
+	m.SetPluginMetrics(c.Plugins())
+	err := m.AddRoute("orders", config.LuaConfig{
+		Enabled: true,
+		Name:    "tagger",
+		RequestScript: `
+			runway.metric_inc("requests_total", {method = req:method()})
+			runway.metric_set("last_body_size", nil, 7)
+			local ok, err = runway.metric_inc("bad-name")
+			if ok or err == nil then
+				return 500, "expected an invalid name to be rejected"
+			end
+		`,
+	})
+	if err != nil {
+		t.Fatalf("AddRoute failed: %v", err)
+	}
+
+	handler := m.Lookup("orders").RequestMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/orders", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, want := range []string{
+		`runway_plugin_tagger_requests_total{method="GET",plugin="tagger",route="orders"} 2`,
+		`runway_plugin_tagger_last_body_size{plugin="tagger",route="orders"} 7`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("expected %q in metrics output", want)
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 // CompiledMaintenance holds pre-compiled maintenance mode state for a route.
 type CompiledMaintenance struct {
 	enabled     atomic.Bool
+	configured  bool // enabled state from config, restored by Reset
 	statusCode  int
 	body        []byte
 	contentType string
@@ -66,6 +67,7 @@ func New(cfg config.MaintenanceConfig) *CompiledMaintenance {
 		retryAfter:   cfg.RetryAfter,
 		excludePaths: cfg.ExcludePaths,
 		headers:      cfg.Headers,
+		configured:   cfg.Enabled,
 	}
 	cm.enabled.Store(cfg.Enabled)
 
@@ -100,6 +102,11 @@ func (cm *CompiledMaintenance) Disable() {
 	cm.enabled.Store(false)
 }
 
+// Reset restores the enabled state from config, discarding runtime overrides.
+func (cm *CompiledMaintenance) Reset() {
+	cm.enabled.Store(cm.configured)
+}
+
 // ShouldBlock returns true if the request should be blocked by maintenance mode.
 // It checks excludePaths and excludeIPs before deciding.
 func (cm *CompiledMaintenance) ShouldBlock(r *http.Request) bool {
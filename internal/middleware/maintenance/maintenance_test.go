@@ -87,6 +87,23 @@ func TestEnableDisable(t *testing.T) {
 	}
 }
 
+func TestReset(t *testing.T) {
+	cm := New(config.MaintenanceConfig{Enabled: true})
+
+	cm.Disable()
+	cm.Reset()
+	if !cm.IsEnabled() {
+		t.Fatal("expected Reset to restore configured enabled state")
+	}
+
+	cm = New(config.MaintenanceConfig{})
+	cm.Enable()
+	cm.Reset()
+	if cm.IsEnabled() {
+		t.Fatal("expected Reset to restore configured disabled state")
+	}
+}
+
 func TestExcludePaths(t *testing.T) {
 	cm := New(config.MaintenanceConfig{
 		Enabled:      true,
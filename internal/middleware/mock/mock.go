@@ -9,6 +9,7 @@ import (
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/middleware"
 	"github.com/wudi/runway/internal/middleware/mock/specmock"
+	"github.com/wudi/runway/variables"
 )
 
 // Handler is a mock response handler that can serve static or spec-based responses.
@@ -43,6 +44,9 @@ func (m *MockHandler) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			m.served.Add(1)
+			if varCtx := variables.GetFromRequest(r); varCtx != nil {
+				varCtx.Relayed = true
+			}
 			for k, v := range m.headers {
 				w.Header().Set(k, v)
 			}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/wudi/runway/internal/randutil"
 )
 
 // generator produces values from OpenAPI schema definitions.
@@ -18,7 +19,7 @@ func newGenerator(seed int64) *generator {
 	if seed != 0 {
 		rng = rand.New(rand.NewSource(seed))
 	} else {
-		rng = rand.New(rand.NewSource(rand.Int63()))
+		rng = rand.New(rand.NewSource(randutil.Int63()))
 	}
 	return &generator{rng: rng}
 }
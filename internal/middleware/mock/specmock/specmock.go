@@ -10,6 +10,7 @@ import (
 
 	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/variables"
 )
 
 // SpecMocker serves mock responses derived from an OpenAPI spec.
@@ -39,6 +40,9 @@ func (s *SpecMocker) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			s.served.Add(1)
+			if varCtx := variables.GetFromRequest(r); varCtx != nil {
+				varCtx.Relayed = true
+			}
 
 			// Set custom headers
 			for k, v := range s.headers {
@@ -13,6 +13,7 @@ import (
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/variables"
 )
 
 // Modifier is the interface for request/response modifiers.
@@ -32,7 +33,7 @@ type compiledModifier struct {
 
 // compiledCondition evaluates whether a modifier should run.
 type compiledCondition struct {
-	condType string // "header", "cookie", "query", "path_regex"
+	condType string // "header", "cookie", "query", "path_regex", "body"
 	name     string
 	regex    *regexp.Regexp // nil means existence check only
 }
@@ -57,6 +58,10 @@ func (cc *compiledCondition) matches(r *http.Request) bool {
 	case "path_regex":
 		value = r.URL.Path
 		exists = true
+	case "body":
+		res := variables.BodyField(r, cc.name)
+		value = res.String()
+		exists = res.Exists()
 	default:
 		return false
 	}
@@ -375,7 +380,7 @@ func compileCondition(cfg *config.ConditionConfig) (*compiledCondition, error) {
 	}
 
 	switch cfg.Type {
-	case "header", "cookie", "query":
+	case "header", "cookie", "query", "body":
 		if cfg.Name == "" {
 			return nil, fmt.Errorf("condition type %s requires name", cfg.Type)
 		}
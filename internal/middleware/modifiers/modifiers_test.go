@@ -1,11 +1,14 @@
 package modifiers
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
 )
 
 func TestHeaderCopy(t *testing.T) {
@@ -216,3 +219,53 @@ func TestPathRegexCondition(t *testing.T) {
 		handler.ServeHTTP(httptest.NewRecorder(), req)
 	})
 }
+
+func TestBodyCondition(t *testing.T) {
+	chain, err := Compile([]config.ModifierConfig{
+		{
+			Type:  "header_set",
+			Name:  "X-Plan",
+			Value: "premium",
+			Scope: "request",
+			Condition: &config.ConditionConfig{
+				Type:  "body",
+				Name:  "account.plan",
+				Value: `^(gold|platinum)$`,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(body string) string {
+		var got string
+		handler := chain.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header.Get("X-Plan")
+		}))
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		vc := variables.NewContext(req)
+		req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, vc))
+		variables.EnableBodyFields(req, 0)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return got
+	}
+
+	if got := run(`{"account":{"plan":"gold"}}`); got != "premium" {
+		t.Errorf("expected premium, got %q", got)
+	}
+	if got := run(`{"account":{"plan":"free"}}`); got != "" {
+		t.Errorf("expected no header for non-matching value, got %q", got)
+	}
+	if got := run(`{"account":{}}`); got != "" {
+		t.Errorf("expected no header for missing field, got %q", got)
+	}
+
+	if _, err := Compile([]config.ModifierConfig{{
+		Type: "header_set", Name: "X", Value: "y",
+		Condition: &config.ConditionConfig{Type: "body"},
+	}}); err == nil {
+		t.Error("expected error for body condition without a path")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/clock"
 	"github.com/wudi/runway/variables"
 	"github.com/wudi/runway/internal/middleware"
 )
@@ -23,6 +24,7 @@ type NonceChecker struct {
 	maxAge          time.Duration
 	metrics         *NonceMetrics
 	routeID         string
+	clock           clock.Clock
 }
 
 // New creates a new NonceChecker from config.
@@ -56,6 +58,7 @@ func New(routeID string, cfg config.NonceConfig, store NonceStore) *NonceChecker
 		timestampHeader: cfg.TimestampHeader,
 		maxAge:          cfg.MaxAge,
 		routeID:         routeID,
+		clock:           clock.Default(),
 		metrics: &NonceMetrics{
 			StoreSize: store.Size,
 		},
@@ -94,7 +97,7 @@ func (nc *NonceChecker) Check(r *http.Request) (bool, int, string) {
 				return false, http.StatusBadRequest, "invalid timestamp"
 			}
 			if nc.maxAge > 0 {
-				age := time.Since(ts)
+				age := nc.clock.Now().Sub(ts)
 				if age > nc.maxAge || age < -nc.maxAge {
 					nc.metrics.StaleTimestamp.Add(1)
 					nc.metrics.Rejected.Add(1)
@@ -4,6 +4,8 @@ import (
 	"context"
 	"sync"
 	"time"
+
+	"github.com/wudi/runway/internal/clock"
 )
 
 // NonceStore provides atomic check-and-store for nonce values.
@@ -20,6 +22,7 @@ type MemoryStore struct {
 	mu      sync.Mutex
 	entries map[string]time.Time // value = expiry time
 	cancel  context.CancelFunc
+	clock   clock.Clock
 }
 
 // NewMemoryStore creates a new in-memory nonce store.
@@ -29,6 +32,7 @@ func NewMemoryStore(ttl time.Duration) *MemoryStore {
 	ms := &MemoryStore{
 		entries: make(map[string]time.Time),
 		cancel:  cancel,
+		clock:   clock.Default(),
 	}
 	cleanupInterval := ttl / 2
 	if cleanupInterval > 30*time.Second {
@@ -46,7 +50,7 @@ func (ms *MemoryStore) CheckAndStore(_ context.Context, key string, ttl time.Dur
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	now := time.Now()
+	now := ms.clock.Now()
 
 	// Check if key exists and not expired
 	if expiry, exists := ms.entries[key]; exists {
@@ -82,7 +86,7 @@ func (ms *MemoryStore) cleanup(ctx context.Context, interval time.Duration) {
 			return
 		case <-ticker.C:
 			ms.mu.Lock()
-			now := time.Now()
+			now := ms.clock.Now()
 			for key, expiry := range ms.entries {
 				if now.After(expiry) {
 					delete(ms.entries, key)
@@ -14,19 +14,33 @@ import (
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/shadow"
 	"github.com/wudi/runway/variables"
 )
 
 // OPAEnforcer evaluates requests against an OPA policy endpoint.
 type OPAEnforcer struct {
-	url           string
-	policyPath    string
-	timeout       time.Duration
-	failOpen      bool
-	includeBody   bool
-	cacheTTL      time.Duration
-	headers       []string
-	client        *http.Client
+	url         string
+	policyPath  string
+	timeout     time.Duration
+	failOpen    bool
+	includeBody bool
+	cacheTTL    time.Duration
+	headers     []string
+	client      *http.Client
+	*decisions
+
+	// async, if set, evaluates shadowPolicyPath off the request path.
+	// Without a shadow policy the policy itself is evaluated there instead,
+	// and enforce is false.
+	async            *shadow.Evaluator
+	shadowPolicyPath string
+	enforce          bool
+}
+
+// decisions holds the decision cache and counters, shared by every route
+// that references the same named policy.
+type decisions struct {
 	cache         sync.Map
 	totalRequests atomic.Int64
 	totalDenied   atomic.Int64
@@ -80,16 +94,23 @@ func New(cfg config.OPAConfig) (*OPAEnforcer, error) {
 		timeout = 5 * time.Second
 	}
 
-	return &OPAEnforcer{
-		url:         cfg.URL,
-		policyPath:  cfg.PolicyPath,
-		timeout:     timeout,
-		failOpen:    cfg.FailOpen,
-		includeBody: cfg.IncludeBody,
-		cacheTTL:    cfg.CacheTTL,
-		headers:     cfg.Headers,
-		client:      &http.Client{Timeout: timeout},
-	}, nil
+	e := &OPAEnforcer{
+		url:              cfg.URL,
+		policyPath:       cfg.PolicyPath,
+		timeout:          timeout,
+		failOpen:         cfg.FailOpen,
+		includeBody:      cfg.IncludeBody,
+		cacheTTL:         cfg.CacheTTL,
+		headers:          cfg.Headers,
+		client:           &http.Client{}, // timeout is applied per query
+		decisions:        &decisions{},
+		shadowPolicyPath: cfg.ShadowPolicyPath,
+		enforce:          !cfg.ShadowAsync || cfg.ShadowPolicyPath != "",
+	}
+	if cfg.ShadowAsync {
+		e.async = shadow.NewEvaluator(e.evaluateShadow, cfg.IncludeBody)
+	}
+	return e, nil
 }
 
 // Evaluate checks the request against the OPA policy.
@@ -122,7 +143,7 @@ func (e *OPAEnforcer) Evaluate(r *http.Request) (bool, error) {
 	}
 
 	// Send request to OPA
-	allowed, err := e.query(r.Context(), input)
+	allowed, err := e.query(r.Context(), e.policyPath, input)
 	if err != nil {
 		e.totalErrors.Add(1)
 		if e.failOpen {
@@ -196,14 +217,29 @@ func (e *OPAEnforcer) buildInput(r *http.Request) (*opaInput, error) {
 	return input, nil
 }
 
-// query sends the input to the OPA server and returns the decision.
-func (e *OPAEnforcer) query(ctx context.Context, input *opaInput) (bool, error) {
+// evaluateShadow queries the shadow policy, or the unenforced policy, for a
+// request snapshot off the request path. The decision cache is bypassed.
+func (e *OPAEnforcer) evaluateShadow(req *shadow.Request) shadow.Verdict {
+	path := e.shadowPolicyPath
+	if path == "" {
+		path = e.policyPath
+	}
+	input, err := e.buildInput(req.HTTPRequest())
+	if err != nil {
+		return shadow.Error
+	}
+	return decisionVerdict(e.query(context.Background(), path, input))
+}
+
+// query sends the input to the OPA server and returns the decision of the
+// policy at policyPath.
+func (e *OPAEnforcer) query(ctx context.Context, policyPath string, input *opaInput) (bool, error) {
 	body, err := json.Marshal(input)
 	if err != nil {
 		return false, fmt.Errorf("opa: marshal input: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/v1/data/%s", e.url, e.policyPath)
+	url := fmt.Sprintf("%s/v1/data/%s", e.url, policyPath)
 
 	ctx, cancel := context.WithTimeout(ctx, e.timeout)
 	defer cancel()
@@ -247,7 +283,20 @@ func (e *OPAEnforcer) cacheKey(r *http.Request) string {
 func (e *OPAEnforcer) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !e.enforce {
+				e.async.Submit(e.async.Capture(r), shadow.NoVerdict)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var snap *shadow.Request
+			if e.async != nil {
+				snap = e.async.Capture(r)
+			}
 			allowed, err := e.Evaluate(r)
+			if snap != nil {
+				e.async.Submit(snap, decisionVerdict(allowed, err))
+			}
 			if err != nil {
 				http.Error(w, `{"error":"policy evaluation failed"}`, http.StatusForbidden)
 				return
@@ -261,6 +310,17 @@ func (e *OPAEnforcer) Middleware() middleware.Middleware {
 	}
 }
 
+// decisionVerdict maps an enforcing decision to a shadow verdict.
+func decisionVerdict(allowed bool, err error) shadow.Verdict {
+	switch {
+	case err != nil:
+		return shadow.Error
+	case !allowed:
+		return shadow.Block
+	}
+	return shadow.Allow
+}
+
 // TotalRequests returns the total number of policy evaluations.
 func (e *OPAEnforcer) TotalRequests() int64 {
 	return e.totalRequests.Load()
@@ -276,16 +336,79 @@ func (e *OPAEnforcer) TotalErrors() int64 {
 	return e.totalErrors.Load()
 }
 
-// OPAByRoute manages per-route OPA enforcers.
-type OPAByRoute = byroute.Factory[*OPAEnforcer, config.OPAConfig]
+// withOverrides returns a view of e that shares its client, cache and
+// counters but uses the route's timeout and fail_open settings.
+func (e *OPAEnforcer) withOverrides(cfg config.OPAConfig) *OPAEnforcer {
+	v := *e
+	if cfg.Timeout > 0 {
+		v.timeout = cfg.Timeout
+	}
+	if cfg.FailOpen {
+		v.failOpen = true
+	}
+	return &v
+}
+
+func enforcerStats(e *OPAEnforcer) any {
+	stats := map[string]interface{}{
+		"total_requests": e.TotalRequests(),
+		"total_denied":   e.TotalDenied(),
+		"total_errors":   e.TotalErrors(),
+	}
+	if e.async != nil {
+		stats["enforce"] = e.enforce
+		stats["shadow_async"] = e.async.Stats()
+	}
+	return stats
+}
+
+// OPAByRoute manages per-route OPA enforcers. Routes that reference a shared
+// policy get a view over the policy's client, cache and counters.
+type OPAByRoute struct {
+	byroute.Manager[*OPAEnforcer]
+	policies *byroute.Shared[*OPAEnforcer, config.OPAConfig]
+}
+
+// NewOPAByRoute creates a new per-route OPA enforcer manager. policies are
+// the named definitions routes may reference via opa.ref.
+func NewOPAByRoute(policies map[string]config.OPAConfig) *OPAByRoute {
+	newFn := func(_ string, cfg config.OPAConfig) (*OPAEnforcer, error) { return New(cfg) }
+	return &OPAByRoute{policies: byroute.NewShared(policies, newFn, enforcerStats)}
+}
 
-// NewOPAByRoute creates a new per-route OPA enforcer manager.
-func NewOPAByRoute() *OPAByRoute {
-	return byroute.NewFactory(New, func(e *OPAEnforcer) any {
-		return map[string]interface{}{
-			"total_requests": e.TotalRequests(),
-			"total_denied":   e.TotalDenied(),
-			"total_errors":   e.TotalErrors(),
+// AddRoute creates an enforcer for the route, or a view over the referenced
+// shared policy.
+func (m *OPAByRoute) AddRoute(routeID string, cfg config.OPAConfig) error {
+	if cfg.Ref == "" {
+		e, err := New(cfg)
+		if err != nil {
+			return err
 		}
+		m.Add(routeID, e)
+		return nil
+	}
+	shared, err := m.policies.Acquire(cfg.Ref, routeID)
+	if err != nil {
+		return err
+	}
+	m.Add(routeID, shared.withOverrides(cfg))
+	return nil
+}
+
+// Stats returns counters for routes with an inline opa config. Routes
+// referencing a shared policy are reported by SharedStats.
+func (m *OPAByRoute) Stats() map[string]any {
+	result := make(map[string]any)
+	m.Range(func(id string, e *OPAEnforcer) bool {
+		if _, shared := m.policies.RefOf(id); !shared {
+			result[id] = enforcerStats(e)
+		}
+		return true
 	})
+	return result
+}
+
+// SharedStats returns counters per shared policy with the routes using it.
+func (m *OPAByRoute) SharedStats() map[string]byroute.SharedStats {
+	return m.policies.Stats()
 }
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/shadow"
 	"github.com/wudi/runway/variables"
 )
 
@@ -315,7 +316,7 @@ func TestOPAByRoute(t *testing.T) {
 	}))
 	defer opaServer.Close()
 
-	m := NewOPAByRoute()
+	m := NewOPAByRoute(nil)
 
 	err := m.AddRoute("route1", config.OPAConfig{
 		Enabled:    true,
@@ -345,7 +346,7 @@ func TestOPAByRoute(t *testing.T) {
 }
 
 func TestOPAByRoute_AddRouteError(t *testing.T) {
-	m := NewOPAByRoute()
+	m := NewOPAByRoute(nil)
 
 	// Missing URL should return error
 	err := m.AddRoute("route1", config.OPAConfig{
@@ -413,3 +414,144 @@ func TestOPAEnforcer_HeadersFilter(t *testing.T) {
 		t.Error("X-Other header should not be sent when headers filter is configured")
 	}
 }
+
+func TestOPAByRoute_SharedPolicy(t *testing.T) {
+	m := NewOPAByRoute(map[string]config.OPAConfig{
+		"authz": {URL: "http://127.0.0.1:1", PolicyPath: "authz/allow"},
+	})
+	if err := m.AddRoute("route1", config.OPAConfig{Ref: "authz"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddRoute("route2", config.OPAConfig{Ref: "authz", FailOpen: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The policy server is unreachable: route1 fails closed, route2 fails open.
+	if _, err := m.Lookup("route1").Evaluate(httptest.NewRequest("GET", "/", nil)); err == nil {
+		t.Error("expected route1 to fail closed")
+	}
+	if allowed, err := m.Lookup("route2").Evaluate(httptest.NewRequest("GET", "/", nil)); err != nil || !allowed {
+		t.Errorf("expected route2 to fail open, got allowed=%v err=%v", allowed, err)
+	}
+
+	shared := m.SharedStats()["authz"]
+	if len(shared.Routes) != 2 {
+		t.Errorf("expected 2 referencing routes, got %v", shared.Routes)
+	}
+	stats := shared.Stats.(map[string]interface{})
+	if stats["total_requests"] != int64(2) || stats["total_errors"] != int64(2) {
+		t.Errorf("expected counters shared across routes, got %v", stats)
+	}
+	if len(m.Stats()) != 0 {
+		t.Errorf("referencing routes should not appear in per-route stats: %v", m.Stats())
+	}
+}
+
+// waitShadowAsync waits until e has evaluated n requests off the request path.
+func waitShadowAsync(t *testing.T, e *OPAEnforcer, n int64) shadow.Stats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s := e.async.Stats()
+		if s.Evaluated >= n {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d async evaluations, got %+v", n, s)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOPAEnforcer_ShadowPolicy(t *testing.T) {
+	var shadowIdentity atomic.Value
+	opaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input opaInput
+		json.NewDecoder(r.Body).Decode(&input)
+		var allowed bool
+		if r.URL.Path == "/v1/data/authz/strict" {
+			if input.Input.Identity != nil {
+				shadowIdentity.Store(input.Input.Identity.ClientID)
+			}
+			allowed = input.Input.Path != "/admin"
+		} else {
+			allowed = input.Input.Method != "DELETE"
+		}
+		json.NewEncoder(w).Encode(opaResponse{Result: allowed})
+	}))
+	defer opaServer.Close()
+
+	enforcer, err := New(config.OPAConfig{
+		Enabled:          true,
+		URL:              opaServer.URL,
+		PolicyPath:       "authz/allow",
+		ShadowAsync:      true,
+		ShadowPolicyPath: "authz/strict",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := enforcer.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	codes := map[string]int{}
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/admin", nil),
+		httptest.NewRequest("DELETE", "/items", nil),
+		httptest.NewRequest("GET", "/items", nil),
+	} {
+		vc := variables.NewContext(req)
+		vc.Identity = &variables.Identity{ClientID: "client-1"}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, withVarContext(req, vc))
+		codes[req.Method+" "+req.URL.Path] = rec.Code
+	}
+	if codes["GET /admin"] != http.StatusOK || codes["DELETE /items"] != http.StatusForbidden {
+		t.Errorf("expected only the enforcing policy to decide, got %v", codes)
+	}
+
+	s := waitShadowAsync(t, enforcer, 3)
+	if s.Blocked != 1 || s.Allowed != 2 {
+		t.Errorf("expected 1 deny and 2 allow shadow verdicts, got %+v", s)
+	}
+	want := shadow.Divergence{Compared: 3, ShadowBlocked: 1, EnforcingBlocked: 1}
+	if s.Divergence == nil || *s.Divergence != want {
+		t.Errorf("expected divergence %+v, got %+v", want, s.Divergence)
+	}
+	if shadowIdentity.Load() != "client-1" {
+		t.Errorf("expected identity in shadow input, got %v", shadowIdentity.Load())
+	}
+	if enforcer.TotalRequests() != 3 || enforcer.TotalDenied() != 1 {
+		t.Errorf("shadow evaluations must not count as enforced decisions, got %d/%d",
+			enforcer.TotalRequests(), enforcer.TotalDenied())
+	}
+}
+
+func TestOPAEnforcer_ShadowAsyncWithoutShadowPolicy(t *testing.T) {
+	opaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(opaResponse{Result: false})
+	}))
+	defer opaServer.Close()
+
+	enforcer, err := New(config.OPAConfig{
+		Enabled:     true,
+		URL:         opaServer.URL,
+		PolicyPath:  "authz/allow",
+		ShadowAsync: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	enforcer.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the policy not to be enforced, got %d", rec.Code)
+	}
+	if s := waitShadowAsync(t, enforcer, 1); s.Blocked != 1 || s.Divergence != nil {
+		t.Errorf("expected one deny verdict without divergence, got %+v", s)
+	}
+	if stats := enforcerStats(enforcer).(map[string]interface{}); stats["enforce"] != false || stats["total_requests"] != int64(0) {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
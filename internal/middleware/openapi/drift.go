@@ -0,0 +1,497 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/shadow"
+)
+
+// Drift finding kinds.
+const (
+	DriftUnknownField    = "unknown_field"
+	DriftMissingRequired = "missing_required"
+	DriftTypeMismatch    = "type_mismatch"
+)
+
+const (
+	defaultDriftSampleRate  = 1
+	defaultDriftMaxFindings = 100
+	defaultDriftMaxBodySize = 1 << 20
+
+	// Per-finding caps, so a route's report stays within max_findings
+	// times a small constant however the backend names its fields.
+	driftMaxExamples   = 3
+	driftMaxPointerLen = 512
+	driftMaxExampleLen = 256
+
+	// driftMaxDepth and driftMaxItems bound the work spent on one body.
+	driftMaxDepth = 32
+	driftMaxItems = 100
+
+	// driftDigestFindings is the number of findings listed in a digest.
+	driftDigestFindings = 10
+)
+
+// DriftFinding is one distinct way responses drifted from the response
+// schema. Findings are keyed by kind, status and JSON pointer; array
+// indexes in pointers are written as "*".
+type DriftFinding struct {
+	Kind      string    `json:"kind"`
+	Status    int       `json:"status"`
+	Pointer   string    `json:"pointer"`
+	Expected  string    `json:"expected,omitempty"` // type_mismatch: the schema type
+	Actual    string    `json:"actual,omitempty"`   // type_mismatch: the JSON type seen
+	Count     int64     `json:"count"`              // responses showing the finding
+	Examples  []string  `json:"examples"`           // request paths of some of those responses
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// DriftReport is the schema drift observed on one route.
+type DriftReport struct {
+	RouteID         string         `json:"route_id"`
+	OperationID     string         `json:"operation_id,omitempty"`
+	SampleRate      float64        `json:"sample_rate"`
+	Sampled         int64          `json:"sampled"`
+	Inspected       int64          `json:"inspected"`
+	Drifted         int64          `json:"drifted"`          // inspected responses with at least one finding
+	Skipped         int64          `json:"skipped"`          // not JSON, too large, or no schema for the status
+	Dropped         int64          `json:"dropped"`          // the shared worker queue was full
+	FindingsDropped int64          `json:"findings_dropped"` // new findings not kept because max_findings was reached
+	Findings        []DriftFinding `json:"findings"`
+}
+
+type driftKey struct {
+	kind    string
+	status  int
+	pointer string
+}
+
+// DriftDetector samples a route's responses and checks them against the
+// operation's response schemas on the shared shadow workers. It never
+// changes a response.
+type DriftDetector struct {
+	routeID     string
+	spec        string
+	operation   *openapi3.Operation
+	operationID string
+	sampleRate  float64
+	maxFindings int
+	maxBodySize int64
+
+	sampled         atomic.Int64
+	inspected       atomic.Int64
+	drifted         atomic.Int64
+	skipped         atomic.Int64
+	dropped         atomic.Int64
+	findingsDropped atomic.Int64
+
+	mu       sync.Mutex
+	findings map[driftKey]*DriftFinding
+	pending  int64 // occurrences since the last digest
+
+	done chan struct{}
+}
+
+// NewDriftDetector returns a detector for the operation of c. With a
+// digest interval, emit is called with a summary of new drift every
+// interval until Close.
+func NewDriftDetector(routeID, spec string, c *CompiledOpenAPI, cfg config.SchemaDriftConfig, emit func(routeID string, data map[string]interface{})) *DriftDetector {
+	d := &DriftDetector{
+		routeID:     routeID,
+		spec:        spec,
+		operationID: c.OperationID(),
+		sampleRate:  cfg.SampleRate,
+		maxFindings: cfg.MaxFindings,
+		maxBodySize: cfg.MaxBodySize,
+		findings:    make(map[driftKey]*DriftFinding),
+		done:        make(chan struct{}),
+	}
+	if c.route != nil {
+		d.operation = c.route.Operation
+	}
+	if d.sampleRate == 0 {
+		d.sampleRate = defaultDriftSampleRate
+	}
+	if d.maxFindings == 0 {
+		d.maxFindings = defaultDriftMaxFindings
+	}
+	if d.maxBodySize == 0 {
+		d.maxBodySize = defaultDriftMaxBodySize
+	}
+	if cfg.DigestInterval > 0 && emit != nil {
+		go d.digestLoop(cfg.DigestInterval, emit)
+	}
+	return d
+}
+
+// Spec returns the spec_id, or spec_file, the route validates against.
+func (d *DriftDetector) Spec() string {
+	return d.spec
+}
+
+// Middleware copies sampled responses, up to max_body_size, while they
+// stream to the client and queues the copy for inspection.
+func (d *DriftDetector) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rand.Float64()*100 >= d.sampleRate {
+				next.ServeHTTP(w, r)
+				return
+			}
+			d.sampled.Add(1)
+			tw := &driftTeeWriter{ResponseWriter: w, max: d.maxBodySize}
+			next.ServeHTTP(tw, r)
+			if tw.overflowed {
+				d.skipped.Add(1)
+				return
+			}
+			if tw.status == 0 {
+				tw.status = http.StatusOK
+			}
+			status, contentType, body, path := tw.status, tw.contentType, tw.buf.Bytes(), r.URL.Path
+			if !shadow.Go(func() { d.Inspect(status, contentType, body, path) }) {
+				d.dropped.Add(1)
+			}
+		})
+	}
+}
+
+// driftTeeWriter passes the response through and keeps a copy of the body
+// until it grows past max.
+type driftTeeWriter struct {
+	http.ResponseWriter
+	max         int64
+	buf         bytes.Buffer
+	status      int
+	contentType string
+	overflowed  bool
+}
+
+func (w *driftTeeWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.contentType = w.ResponseWriter.Header().Get("Content-Type")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *driftTeeWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.overflowed {
+		if int64(w.buf.Len()+len(b)) > w.max {
+			w.overflowed = true
+			w.buf = bytes.Buffer{}
+		} else {
+			w.buf.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *driftTeeWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Inspect checks one response body against the schema for its status and
+// content type and records the findings. path is kept as an example.
+func (d *DriftDetector) Inspect(status int, contentType string, body []byte, path string) {
+	schema := d.responseSchema(status, contentType)
+	if schema == nil {
+		d.skipped.Add(1)
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		d.skipped.Add(1)
+		return
+	}
+	d.inspected.Add(1)
+
+	seen := make(map[driftKey]DriftFinding)
+	walkDrift(schema, v, "", 0, func(f DriftFinding) {
+		if len(f.Pointer) > driftMaxPointerLen {
+			f.Pointer = f.Pointer[:driftMaxPointerLen]
+		}
+		f.Status = status
+		seen[driftKey{f.Kind, status, f.Pointer}] = f
+	})
+	if len(seen) == 0 {
+		return
+	}
+	d.drifted.Add(1)
+	if len(path) > driftMaxExampleLen {
+		path = path[:driftMaxExampleLen]
+	}
+	d.record(seen, path)
+}
+
+// responseSchema returns the JSON schema documented for status and
+// contentType, or nil.
+func (d *DriftDetector) responseSchema(status int, contentType string) *openapi3.Schema {
+	if d.operation == nil || d.operation.Responses == nil {
+		return nil
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !(mt == "application/json" || strings.HasSuffix(mt, "+json")) {
+		return nil
+	}
+	ref := d.operation.Responses.Status(status)
+	if ref == nil {
+		ref = d.operation.Responses.Default()
+	}
+	if ref == nil || ref.Value == nil {
+		return nil
+	}
+	media := ref.Value.Content.Get(contentType)
+	if media == nil || media.Schema == nil {
+		return nil
+	}
+	return media.Schema.Value
+}
+
+func (d *DriftDetector) record(seen map[driftKey]DriftFinding, path string) {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, found := range seen {
+		f := d.findings[key]
+		if f == nil {
+			if len(d.findings) >= d.maxFindings {
+				d.findingsDropped.Add(1)
+				continue
+			}
+			found.FirstSeen = now
+			f = &found
+			d.findings[key] = f
+		}
+		f.Count++
+		f.LastSeen = now
+		if len(f.Examples) < driftMaxExamples && !slices.Contains(f.Examples, path) {
+			f.Examples = append(f.Examples, path)
+		}
+		d.pending++
+	}
+}
+
+// sortFindings orders findings most frequent first.
+func (d *DriftReport) sortFindings() {
+	sort.Slice(d.Findings, func(i, j int) bool {
+		a, b := d.Findings[i], d.Findings[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Pointer < b.Pointer
+	})
+}
+
+// Report returns the route's counters and findings, most frequent first.
+func (d *DriftDetector) Report() DriftReport {
+	r := DriftReport{
+		RouteID:         d.routeID,
+		OperationID:     d.operationID,
+		SampleRate:      d.sampleRate,
+		Sampled:         d.sampled.Load(),
+		Inspected:       d.inspected.Load(),
+		Drifted:         d.drifted.Load(),
+		Skipped:         d.skipped.Load(),
+		Dropped:         d.dropped.Load(),
+		FindingsDropped: d.findingsDropped.Load(),
+	}
+	d.mu.Lock()
+	r.Findings = make([]DriftFinding, 0, len(d.findings))
+	for _, f := range d.findings {
+		c := *f
+		c.Examples = slices.Clone(f.Examples)
+		r.Findings = append(r.Findings, c)
+	}
+	d.mu.Unlock()
+	r.sortFindings()
+	return r
+}
+
+func (d *DriftDetector) digestLoop(interval time.Duration, emit func(string, map[string]interface{})) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+			if data := d.digest(); data != nil {
+				emit(d.routeID, data)
+			}
+		}
+	}
+}
+
+// digest returns the webhook payload for drift seen since the last digest,
+// or nil when there was none.
+func (d *DriftDetector) digest() map[string]interface{} {
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = 0
+	d.mu.Unlock()
+	if pending == 0 {
+		return nil
+	}
+	r := d.Report()
+	total := len(r.Findings)
+	if len(r.Findings) > driftDigestFindings {
+		r.Findings = r.Findings[:driftDigestFindings]
+	}
+	return map[string]interface{}{
+		"spec":             d.spec,
+		"operation_id":     d.operationID,
+		"new_occurrences":  pending,
+		"total_findings":   total,
+		"inspected":        r.Inspected,
+		"drifted":          r.Drifted,
+		"top_findings":     r.Findings,
+		"findings_dropped": r.FindingsDropped,
+	}
+}
+
+// Close stops the digest.
+func (d *DriftDetector) Close() {
+	select {
+	case <-d.done:
+	default:
+		close(d.done)
+	}
+}
+
+// walkDrift reports where v departs from schema. Unknown fields are not
+// descended into; oneOf and anyOf schemas are not inspected further.
+func walkDrift(schema *openapi3.Schema, v any, ptr string, depth int, report func(DriftFinding)) {
+	if schema == nil || depth > driftMaxDepth {
+		return
+	}
+	actual := jsonType(v)
+	if actual == "null" {
+		if schema.Type != nil && !schema.Nullable && !schema.Type.Includes("null") {
+			report(DriftFinding{Kind: DriftTypeMismatch, Pointer: ptr, Expected: typeNames(schema.Type), Actual: actual})
+		}
+		return
+	}
+	if schema.Type != nil && !typePermits(schema.Type, actual) {
+		report(DriftFinding{Kind: DriftTypeMismatch, Pointer: ptr, Expected: typeNames(schema.Type), Actual: actual})
+		return
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		if len(schema.OneOf) > 0 || len(schema.AnyOf) > 0 {
+			return
+		}
+		props := map[string]*openapi3.SchemaRef{}
+		var required []string
+		open := collectObject(schema, props, &required, 0) || len(props) == 0
+		for _, name := range required {
+			if _, ok := val[name]; !ok {
+				report(DriftFinding{Kind: DriftMissingRequired, Pointer: ptr + "/" + escapePointer(name)})
+			}
+		}
+		for name, child := range val {
+			p := ptr + "/" + escapePointer(name)
+			if ref, ok := props[name]; ok {
+				if ref != nil {
+					walkDrift(ref.Value, child, p, depth+1, report)
+				}
+			} else if !open {
+				report(DriftFinding{Kind: DriftUnknownField, Pointer: p})
+			}
+		}
+	case []any:
+		if schema.Items == nil {
+			return
+		}
+		for i, item := range val {
+			if i == driftMaxItems {
+				break
+			}
+			walkDrift(schema.Items.Value, item, ptr+"/*", depth+1, report)
+		}
+	}
+}
+
+// collectObject gathers the properties and required names of schema and
+// its allOf parts. It reports whether any of them sets
+// additionalProperties, allowing fields beyond the properties.
+func collectObject(schema *openapi3.Schema, props map[string]*openapi3.SchemaRef, required *[]string, depth int) bool {
+	if schema == nil || depth > driftMaxDepth {
+		return true
+	}
+	open := schema.AdditionalProperties.Schema != nil ||
+		(schema.AdditionalProperties.Has != nil && *schema.AdditionalProperties.Has)
+	for name, ref := range schema.Properties {
+		props[name] = ref
+	}
+	*required = append(*required, schema.Required...)
+	for _, part := range schema.AllOf {
+		if part != nil && collectObject(part.Value, props, required, depth+1) {
+			open = true
+		}
+	}
+	return open
+}
+
+// jsonType names the JSON type of a value decoded with UseNumber.
+func jsonType(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if strings.ContainsAny(val.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	}
+	return "unknown"
+}
+
+func typePermits(types *openapi3.Types, actual string) bool {
+	if types.Includes(actual) {
+		return true
+	}
+	return actual == "integer" && types.Includes("number")
+}
+
+func typeNames(types *openapi3.Types) string {
+	return strings.Join(types.Slice(), "|")
+}
+
+// escapePointer escapes a JSON pointer reference token (RFC 6901).
+func escapePointer(s string) string {
+	if !strings.ContainsAny(s, "~/") {
+		return s
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,133 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func newTestDrift(t *testing.T, operationID string, cfg config.SchemaDriftConfig, emit func(string, map[string]interface{})) *DriftDetector {
+	t.Helper()
+	doc, err := LoadSpec("testdata/petstore.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := NewFromOperationID(doc, operationID, false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Enabled = true
+	d := NewDriftDetector("pets", "petstore", compiled, cfg, emit)
+	t.Cleanup(d.Close)
+	return d
+}
+
+func findingsByPointer(r DriftReport) map[string]DriftFinding {
+	m := make(map[string]DriftFinding, len(r.Findings))
+	for _, f := range r.Findings {
+		m[f.Kind+" "+f.Pointer] = f
+	}
+	return m
+}
+
+func TestDriftDetector_Findings(t *testing.T) {
+	d := newTestDrift(t, "listPets", config.SchemaDriftConfig{}, nil)
+
+	d.Inspect(200, "application/json", []byte(`[{"id":1,"name":"a"}]`), "/pets")
+	d.Inspect(200, "application/json", []byte(`[{"id":1,"name":"a","color":"red"},{"id":"2","color":"blue"}]`), "/pets?page=2")
+	d.Inspect(200, "application/json; charset=utf-8", []byte(`[{"id":1.5,"name":"a","color":null}]`), "/pets/other")
+	d.Inspect(200, "text/plain", []byte(`hello`), "/pets")
+	d.Inspect(500, "application/json", []byte(`{}`), "/pets")
+
+	r := d.Report()
+	if r.Inspected != 3 || r.Drifted != 2 || r.Skipped != 2 {
+		t.Errorf("unexpected counters %+v", r)
+	}
+	got := findingsByPointer(r)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 findings, got %+v", r.Findings)
+	}
+	// Array elements share one pointer; a response counts once.
+	if f := got["unknown_field /*/color"]; f.Count != 2 || len(f.Examples) != 2 || f.Status != 200 {
+		t.Errorf("unexpected unknown field finding %+v", f)
+	}
+	if f := got["missing_required /*/name"]; f.Count != 1 {
+		t.Errorf("unexpected missing field finding %+v", f)
+	}
+	if f := got["type_mismatch /*/id"]; f.Count != 2 || f.Expected != "integer" {
+		t.Errorf("unexpected type mismatch finding %+v", f)
+	}
+	if r.Findings[0].Count < r.Findings[len(r.Findings)-1].Count {
+		t.Error("expected findings ordered by count")
+	}
+}
+
+func TestDriftDetector_MaxFindings(t *testing.T) {
+	d := newTestDrift(t, "getPet", config.SchemaDriftConfig{MaxFindings: 2}, nil)
+	for i := 0; i < 5; i++ {
+		d.Inspect(200, "application/json", []byte(fmt.Sprintf(`{"id":1,"name":"a","extra%d":true}`, i)), "/pets/1")
+	}
+	r := d.Report()
+	if len(r.Findings) != 2 || r.FindingsDropped != 3 {
+		t.Errorf("expected 2 findings kept and 3 dropped, got %d and %d", len(r.Findings), r.FindingsDropped)
+	}
+}
+
+func TestDriftDetector_MiddlewareIsObserveOnly(t *testing.T) {
+	d := newTestDrift(t, "getPet", config.SchemaDriftConfig{SampleRate: 100, MaxBodySize: 64}, nil)
+	handler := d.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/pets/big" {
+			fmt.Fprintf(w, `{"id":1,"name":"%0100d"}`, 0)
+			return
+		}
+		w.Write([]byte(`{"id":1,"name":"a","owner":"b"}`))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/pets/1", nil))
+	if rec.Body.String() != `{"id":1,"name":"a","owner":"b"}` {
+		t.Errorf("response changed: %q", rec.Body.String())
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/pets/big", nil))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for d.Report().Inspected == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	r := d.Report()
+	if r.Sampled != 2 || r.Skipped != 1 {
+		t.Errorf("expected both sampled and the oversized one skipped, got %+v", r)
+	}
+	if f := findingsByPointer(r)["unknown_field /owner"]; f.Examples[0] != "/pets/1" {
+		t.Errorf("expected the unknown field found off-path, got %+v", r.Findings)
+	}
+}
+
+func TestDriftDetector_Digest(t *testing.T) {
+	digests := make(chan map[string]interface{}, 4)
+	d := newTestDrift(t, "getPet", config.SchemaDriftConfig{DigestInterval: 10 * time.Millisecond}, func(routeID string, data map[string]interface{}) {
+		if routeID == "pets" {
+			digests <- data
+		}
+	})
+	d.Inspect(200, "application/json", []byte(`{"id":1}`), "/pets/1")
+
+	select {
+	case data := <-digests:
+		if data["new_occurrences"] != int64(1) || data["spec"] != "petstore" {
+			t.Errorf("unexpected digest %v", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a digest")
+	}
+	select {
+	case data := <-digests:
+		t.Errorf("expected no digest without new drift, got %v", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
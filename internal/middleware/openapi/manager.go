@@ -2,6 +2,7 @@ package openapi
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/getkin/kin-openapi/openapi3"
@@ -25,6 +26,9 @@ type OpenAPIByRoute struct {
 	byroute.Manager[*CompiledOpenAPI]
 	specMu    sync.RWMutex
 	specCache map[string]*openapi3.T
+
+	digestMu      sync.RWMutex
+	onDriftDigest func(routeID string, data map[string]interface{})
 }
 
 // NewOpenAPIByRoute creates a new per-route OpenAPI manager.
@@ -59,6 +63,14 @@ func (m *OpenAPIByRoute) AddRoute(routeID string, cfg config.OpenAPIRouteConfig)
 		return fmt.Errorf("route %s: %w", routeID, err)
 	}
 
+	if cfg.SchemaDrift.Enabled {
+		spec := cfg.SpecID
+		if spec == "" {
+			spec = specFile
+		}
+		compiled.drift = NewDriftDetector(routeID, spec, compiled, cfg.SchemaDrift, m.emitDriftDigest)
+	}
+
 	m.Add(routeID, compiled)
 	return nil
 }
@@ -75,6 +87,45 @@ func (m *OpenAPIByRoute) AddRouteWithDoc(routeID, path, method string, doc *open
 	return nil
 }
 
+// SetOnDriftDigest sets the callback receiving schema drift digests.
+func (m *OpenAPIByRoute) SetOnDriftDigest(fn func(routeID string, data map[string]interface{})) {
+	m.digestMu.Lock()
+	m.onDriftDigest = fn
+	m.digestMu.Unlock()
+}
+
+func (m *OpenAPIByRoute) emitDriftDigest(routeID string, data map[string]interface{}) {
+	m.digestMu.RLock()
+	fn := m.onDriftDigest
+	m.digestMu.RUnlock()
+	if fn != nil {
+		fn(routeID, data)
+	}
+}
+
+// DriftReports returns the schema drift reports of the routes validating
+// against spec, a spec_id or spec_file, ordered by route ID. It reports
+// false when no route has schema_drift enabled for spec.
+func (m *OpenAPIByRoute) DriftReports(spec string) ([]DriftReport, bool) {
+	var reports []DriftReport
+	m.Range(func(_ string, c *CompiledOpenAPI) bool {
+		if c.drift != nil && c.drift.Spec() == spec {
+			reports = append(reports, c.drift.Report())
+		}
+		return true
+	})
+	sort.Slice(reports, func(i, j int) bool { return reports[i].RouteID < reports[j].RouteID })
+	return reports, reports != nil
+}
+
+// Close stops the schema drift digests.
+func (m *OpenAPIByRoute) Close() {
+	byroute.ForEach(&m.Manager, func(c *CompiledOpenAPI) {
+		if c.drift != nil {
+			c.drift.Close()
+		}
+	})
+}
 
 // Stats returns per-route OpenAPI validation status.
 func (m *OpenAPIByRoute) Stats() map[string]OpenAPIStatus {
@@ -2,6 +2,7 @@ package openapi
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -31,6 +32,10 @@ func (m *OpenAPIMetrics) Snapshot() map[string]int64 {
 	}
 }
 
+// RateLimitWeightExtension is the operation extension declaring its rate
+// limit cost.
+const RateLimitWeightExtension = "x-rate-limit-weight"
+
 // CompiledOpenAPI holds a pre-built OpenAPI route for validating requests/responses.
 type CompiledOpenAPI struct {
 	router           routers.Router
@@ -40,6 +45,7 @@ type CompiledOpenAPI struct {
 	validateResponse bool
 	logOnly          bool
 	metrics          *OpenAPIMetrics
+	drift            *DriftDetector // nil unless schema_drift is enabled
 }
 
 // noopAuthFunc skips authentication validation (runway handles auth separately).
@@ -170,11 +176,42 @@ func (c *CompiledOpenAPI) ValidatesResponse() bool {
 	return c.validateResponse
 }
 
+// Drift returns the route's schema drift detector, or nil.
+func (c *CompiledOpenAPI) Drift() *DriftDetector {
+	return c.drift
+}
+
 // IsLogOnly returns whether validation errors should be logged instead of rejected.
 func (c *CompiledOpenAPI) IsLogOnly() bool {
 	return c.logOnly
 }
 
+// OperationID returns the operationId of the compiled operation.
+func (c *CompiledOpenAPI) OperationID() string {
+	if c.route == nil || c.route.Operation == nil {
+		return ""
+	}
+	return c.route.Operation.OperationID
+}
+
+// RateLimitWeight returns the operation's x-rate-limit-weight extension,
+// the number of rate limit tokens a call to the operation costs.
+func (c *CompiledOpenAPI) RateLimitWeight() (int, bool) {
+	if c.route == nil || c.route.Operation == nil {
+		return 0, false
+	}
+	switch v := c.route.Operation.Extensions[RateLimitWeightExtension].(type) {
+	case float64:
+		return int(v), v > 0
+	case int:
+		return v, v > 0
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err == nil && n > 0
+	}
+	return 0, false
+}
+
 // GetMetrics returns the OpenAPI validation metrics.
 func (c *CompiledOpenAPI) GetMetrics() *OpenAPIMetrics {
 	return c.metrics
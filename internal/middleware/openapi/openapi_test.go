@@ -152,6 +152,32 @@ func TestNewFromOperationID(t *testing.T) {
 	})
 }
 
+func TestRateLimitWeight(t *testing.T) {
+	doc, err := LoadSpec("testdata/petstore.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	create, err := NewFromOperationID(doc, "createPet", true, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if create.OperationID() != "createPet" {
+		t.Errorf("expected operationId createPet, got %q", create.OperationID())
+	}
+	if w, ok := create.RateLimitWeight(); !ok || w != 5 {
+		t.Errorf("expected weight 5, got %d (%v)", w, ok)
+	}
+
+	list, err := NewFromOperationID(doc, "listPets", true, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := list.RateLimitWeight(); ok {
+		t.Error("listPets declares no weight")
+	}
+}
+
 func TestMetrics(t *testing.T) {
 	doc, err := LoadSpec("testdata/petstore.yaml")
 	if err != nil {
@@ -51,6 +51,7 @@ func GenerateRoutes(specCfg config.OpenAPISpecConfig) ([]config.RouteConfig, err
 					ValidateRequest:  valReqPtr,
 					ValidateResponse: specCfg.Validation.Response,
 					LogOnly:          specCfg.Validation.LogOnly,
+					SchemaDrift:      specCfg.SchemaDrift,
 				},
 			}
 
@@ -0,0 +1,317 @@
+// Package problem renders error responses the gateway generates itself as
+// RFC 9457 problem details (application/problem+json).
+package problem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/errorpages"
+	"github.com/wudi/runway/variables"
+)
+
+// maxDetailBody caps how much of the original error body is kept to derive
+// the problem's detail from.
+const maxDetailBody = 4 << 10
+
+// legacyMembers are the members of RunwayError and error page JSON bodies
+// that are not carried over into problem details as extensions.
+var legacyMembers = map[string]bool{
+	"code": true, "message": true, "details": true, "error": true, "request_id": true,
+	"status": true, "type": true, "title": true, "detail": true, "instance": true,
+	"route": true, "reason": true,
+}
+
+// Renderer rewrites the gateway-generated error responses of a route, or of
+// requests that match no route, as problem details. Backend responses and
+// responses relayed from a cache or mock pass through untouched.
+type Renderer struct {
+	routeID string // empty for the global renderer
+	format  string
+	catalog map[string]config.ErrorCatalogEntry
+	pages   *errorpages.CompiledErrorPages // HTML alternative for browsers; may be nil
+
+	rendered atomic.Int64
+	html     atomic.Int64
+}
+
+// New creates a renderer from an effective (merged) errors config. pages are
+// the error pages rendered instead when the client prefers HTML.
+func New(routeID string, cfg config.ErrorsConfig, pages *errorpages.CompiledErrorPages) *Renderer {
+	format := cfg.Format
+	if format == "" {
+		format = config.ErrorFormatLegacy
+	}
+	return &Renderer{
+		routeID: routeID,
+		format:  format,
+		catalog: cfg.Catalog,
+		pages:   pages,
+	}
+}
+
+// Middleware returns the route middleware. It records the route's error
+// format on the request, so the global renderer and the error_pages and
+// error_handling middlewares know who formats gateway errors, and renders
+// them when the format is problem_json.
+func (rr *Renderer) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			varCtx := variables.GetFromRequest(r)
+			varCtx.ErrorFormat = rr.format
+			if rr.format != config.ErrorFormatProblemJSON {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rr.serve(next, w, r, varCtx, varCtx.RendersProblem)
+		})
+	}
+}
+
+// ServeGlobal serves r with next from the listener chain. It renders the
+// gateway errors of requests no route middleware took care of: unmatched
+// routes and rejections by global middlewares.
+func (rr *Renderer) ServeGlobal(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	varCtx := variables.GetFromRequest(r)
+	rr.serve(next, w, r, varCtx, func() bool {
+		return varCtx.ErrorFormat == "" && varCtx.GatewayError()
+	})
+}
+
+// serve runs next, holding back error responses for which owned reports
+// true when their header is written, and renders those once next returns.
+func (rr *Renderer) serve(next http.Handler, w http.ResponseWriter, r *http.Request, varCtx *variables.Context, owned func() bool) {
+	pw := &problemWriter{ResponseWriter: w, owned: owned}
+	next.ServeHTTP(pw, r)
+	if pw.intercepted && !pw.hijacked {
+		rr.render(w, r, varCtx, pw.status, pw.body.Bytes())
+	}
+}
+
+// render writes the problem document, or the HTML error page when the
+// client prefers HTML and one is configured for the status.
+func (rr *Renderer) render(w http.ResponseWriter, r *http.Request, varCtx *variables.Context, status int, body []byte) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	rr.rendered.Add(1)
+
+	if rr.pages != nil && rr.pages.RendersHTML(status, r.Header.Get("Accept")) {
+		rr.html.Add(1)
+		page, contentType := rr.pages.Render(status, r, varCtx)
+		h.Set("Content-Type", contentType)
+		h.Set("Content-Length", strconv.Itoa(len(page)))
+		w.WriteHeader(status)
+		w.Write([]byte(page))
+		return
+	}
+	rr.Problem(status, varCtx, body).WriteJSON(w)
+}
+
+// Problem builds the problem document for a gateway error with the given
+// status and original body. The route's catalog entry for the reason code,
+// if any, sets its type and title.
+func (rr *Renderer) Problem(status int, varCtx *variables.Context, body []byte) *errors.Problem {
+	p := errors.NewProblem(status, Reason(status, varCtx))
+	if e, ok := rr.catalog[p.Reason]; ok {
+		p.Type = e.Type
+		if e.Title != "" {
+			p.Title = e.Title
+		}
+	}
+	p.Detail, p.Extensions = detailFrom(body, p.Title, http.StatusText(status))
+	p.Instance = varCtx.RequestID
+	p.Route = varCtx.RouteID
+	if p.Route == "" {
+		p.Route = rr.routeID
+	}
+	return p
+}
+
+// Reason returns the reason code of a gateway error: the one recorded with
+// variables.SetErrorReason, else one derived from the timeout and abuse
+// signals of the request, else the generic code for the status.
+func Reason(status int, varCtx *variables.Context) string {
+	if varCtx.ErrorReason != "" {
+		return varCtx.ErrorReason
+	}
+	switch status {
+	case http.StatusGatewayTimeout:
+		switch varCtx.TimeoutReason {
+		case variables.TimeoutBackend:
+			return errors.ReasonUpstreamTimeout
+		case variables.TimeoutRequest:
+			return errors.ReasonRequestTimeout
+		}
+	case http.StatusTooManyRequests:
+		if varCtx.Signals&variables.SignalRateLimit != 0 {
+			return errors.ReasonRateLimited
+		}
+	case http.StatusForbidden:
+		if varCtx.Signals&variables.SignalWAF != 0 {
+			return errors.ReasonWAFBlocked
+		}
+		if varCtx.Signals&variables.SignalBot != 0 {
+			return errors.ReasonBotBlocked
+		}
+	}
+	return errors.ReasonForStatus(status)
+}
+
+// detailFrom derives the problem detail from the original error body: the
+// details, error or message member of a JSON body, or a plain-text body.
+// Text merely repeating one of titles is dropped. Other members of a JSON
+// body are returned as extensions.
+func detailFrom(body []byte, titles ...string) (string, map[string]any) {
+	isTitle := func(s string) bool {
+		for _, t := range titles {
+			if strings.EqualFold(s, t) {
+				return true
+			}
+		}
+		return false
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return "", nil
+	}
+	var detail string
+	var ext map[string]any
+	if body[0] == '{' {
+		var m map[string]any
+		if json.Unmarshal(body, &m) != nil {
+			return "", nil
+		}
+		for _, key := range []string{"details", "error", "message"} {
+			if s, ok := m[key].(string); ok && s != "" && !isTitle(s) {
+				detail = s
+				break
+			}
+		}
+		for k, v := range m {
+			if !legacyMembers[k] {
+				if ext == nil {
+					ext = make(map[string]any)
+				}
+				ext[k] = v
+			}
+		}
+	} else if body[0] != '<' {
+		detail = string(body)
+	}
+	if isTitle(detail) {
+		detail = ""
+	}
+	return detail, ext
+}
+
+// Stats returns the renderer's format and counters.
+func (rr *Renderer) Stats() map[string]any {
+	reasons := make([]string, 0, len(rr.catalog))
+	for reason := range rr.catalog {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return map[string]any{
+		"format":        rr.format,
+		"catalog":       reasons,
+		"error_pages":   rr.pages != nil,
+		"rendered":      rr.rendered.Load(),
+		"rendered_html": rr.html.Load(),
+	}
+}
+
+// problemWriter holds back error responses the renderer owns, keeping the
+// start of their body, and passes everything else through.
+type problemWriter struct {
+	http.ResponseWriter
+	owned       func() bool
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+	intercepted bool
+	hijacked    bool
+}
+
+func (w *problemWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	if code < 200 {
+		w.ResponseWriter.WriteHeader(code) // informational responses pass through
+		return
+	}
+	w.wroteHeader = true
+	if code >= 400 && w.owned() {
+		w.intercepted = true
+		w.status = code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *problemWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.intercepted {
+		if room := maxDetailBody - w.body.Len(); room > 0 {
+			w.body.Write(b[:min(len(b), room)])
+		}
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *problemWriter) Flush() {
+	if w.intercepted {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *problemWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.hijacked = true
+		return hj.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *problemWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RendererByRoute manages per-route renderers.
+type RendererByRoute struct {
+	byroute.Manager[*Renderer]
+}
+
+// NewRendererByRoute creates a new per-route renderer manager.
+func NewRendererByRoute() *RendererByRoute {
+	return &RendererByRoute{}
+}
+
+// AddRoute registers the renderer for a route from the global and route
+// errors configs and the route's compiled error pages.
+func (m *RendererByRoute) AddRoute(routeID string, global, route config.ErrorsConfig, pages *errorpages.CompiledErrorPages) {
+	m.Add(routeID, New(routeID, route.Merge(global), pages))
+}
+
+// Stats returns renderer stats for all routes.
+func (m *RendererByRoute) Stats() map[string]any {
+	return byroute.CollectStats(&m.Manager, func(rr *Renderer) any { return rr.Stats() })
+}
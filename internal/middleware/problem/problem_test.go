@@ -0,0 +1,208 @@
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/middleware/errorpages"
+	"github.com/wudi/runway/variables"
+)
+
+func newRequest(accept string) (*http.Request, *variables.Context) {
+	r := httptest.NewRequest("GET", "/orders", nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	varCtx := variables.NewContext(r)
+	varCtx.RequestID = "req-1"
+	return r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx)), varCtx
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != errors.ProblemContentType {
+		t.Fatalf("Content-Type = %q, want %q (body %s)", ct, errors.ProblemContentType, w.Body)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid problem document %q: %v", w.Body, err)
+	}
+	return doc
+}
+
+func TestProblemShape(t *testing.T) {
+	rr := New("orders", config.ErrorsConfig{
+		Format: config.ErrorFormatProblemJSON,
+		Catalog: map[string]config.ErrorCatalogEntry{
+			errors.ReasonRateLimited: {Type: "https://errors.example.com/rate-limited", Title: "Slow down"},
+		},
+	}, nil)
+
+	tests := []struct {
+		name    string
+		prepare func(*variables.Context)
+		write   func(http.ResponseWriter)
+		status  int
+		want    map[string]any
+	}{
+		{
+			name:    "rate limit",
+			prepare: func(c *variables.Context) { c.Signals |= variables.SignalRateLimit },
+			write: func(w http.ResponseWriter) {
+				errors.ErrTooManyRequests.WithDetails("request cost 5 exceeds remaining rate limit budget 2").WriteJSON(w)
+			},
+			status: http.StatusTooManyRequests,
+			want: map[string]any{
+				"type": "https://errors.example.com/rate-limited", "title": "Slow down",
+				"detail": "request cost 5 exceeds remaining rate limit budget 2", "reason": errors.ReasonRateLimited,
+			},
+		},
+		{
+			name:    "auth",
+			prepare: func(c *variables.Context) { c.ErrorReason = errors.ReasonAuthenticationFailed },
+			write: func(w http.ResponseWriter) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"unauthorized","login_url":"/saml/login"}`))
+			},
+			status: http.StatusUnauthorized,
+			want: map[string]any{
+				"type": "about:blank", "title": "Unauthorized",
+				"reason": errors.ReasonAuthenticationFailed, "login_url": "/saml/login",
+			},
+		},
+		{
+			name:    "upstream timeout",
+			prepare: func(c *variables.Context) { c.TimeoutReason = variables.TimeoutBackend },
+			write:   func(w http.ResponseWriter) { errors.ErrGatewayTimeout.WriteJSON(w) },
+			status:  http.StatusGatewayTimeout,
+			want: map[string]any{
+				"type": "about:blank", "title": "Gateway Timeout", "reason": errors.ReasonUpstreamTimeout,
+			},
+		},
+		{
+			name:   "plain text",
+			write:  func(w http.ResponseWriter) { http.Error(w, "Quota exceeded", http.StatusTooManyRequests) },
+			status: http.StatusTooManyRequests,
+			want: map[string]any{
+				"type": "about:blank", "title": "Too Many Requests", "detail": "Quota exceeded", "reason": "too_many_requests",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, varCtx := newRequest("application/json")
+			if tt.prepare != nil {
+				tt.prepare(varCtx)
+			}
+			w := httptest.NewRecorder()
+			rr.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { tt.write(w) })).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			doc := decode(t, w)
+			tt.want["status"] = float64(tt.status)
+			tt.want["instance"] = "req-1"
+			tt.want["route"] = "orders"
+			if len(doc) != len(tt.want) {
+				t.Errorf("document = %v, want %v", doc, tt.want)
+			}
+			for k, v := range tt.want {
+				if doc[k] != v {
+					t.Errorf("%s = %v, want %v", k, doc[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestPassThrough(t *testing.T) {
+	rr := New("orders", config.ErrorsConfig{Format: config.ErrorFormatProblemJSON}, nil)
+	tests := []struct {
+		name    string
+		prepare func(*variables.Context)
+		status  int
+	}{
+		{name: "success", status: http.StatusOK},
+		{name: "backend error", prepare: func(c *variables.Context) { c.UpstreamStatus = http.StatusNotFound }, status: http.StatusNotFound},
+		{name: "relayed error", prepare: func(c *variables.Context) { c.Relayed = true }, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, varCtx := newRequest("")
+			w := httptest.NewRecorder()
+			rr.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.prepare != nil {
+					tt.prepare(varCtx)
+				}
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(tt.status)
+				w.Write([]byte("original"))
+			})).ServeHTTP(w, r)
+			if w.Code != tt.status || w.Body.String() != "original" || w.Header().Get("Content-Type") != "text/plain" {
+				t.Errorf("got %d %q %q, want the original response", w.Code, w.Header().Get("Content-Type"), w.Body)
+			}
+		})
+	}
+}
+
+func TestHTMLErrorPage(t *testing.T) {
+	pages, err := errorpages.New(config.ErrorPagesConfig{
+		Enabled: true,
+		Pages:   map[string]config.ErrorPageEntry{"4xx": {HTML: "<p>{{.StatusCode}}</p>", JSON: `{"custom":true}`}},
+	}, config.ErrorPagesConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := New("orders", config.ErrorsConfig{Format: config.ErrorFormatProblemJSON}, pages)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { errors.ErrForbidden.WriteJSON(w) })
+
+	r, _ := newRequest("text/html,application/xhtml+xml,*/*;q=0.8")
+	w := httptest.NewRecorder()
+	rr.Middleware()(next).ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden || w.Body.String() != "<p>403</p>" {
+		t.Errorf("got %d %q, want the HTML error page", w.Code, w.Body)
+	}
+
+	// Non-browser clients get problem details, not the JSON error page.
+	r, _ = newRequest("application/json")
+	w = httptest.NewRecorder()
+	rr.Middleware()(next).ServeHTTP(w, r)
+	if doc := decode(t, w); doc["reason"] != "forbidden" {
+		t.Errorf("reason = %v, want forbidden", doc["reason"])
+	}
+}
+
+func TestFormatOwnership(t *testing.T) {
+	global := New("", config.ErrorsConfig{Format: config.ErrorFormatProblemJSON}, nil)
+	legacy := New("orders", config.ErrorsConfig{Format: config.ErrorFormatLegacy}, nil)
+
+	// A route opting out of problem_json keeps the legacy body.
+	r, _ := newRequest("")
+	w := httptest.NewRecorder()
+	global.ServeGlobal(legacy.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errors.ErrForbidden.WriteJSON(w)
+	})), w, r)
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q, want the legacy error", w.Header().Get("Content-Type"))
+	}
+
+	// Errors outside any route are rendered by the global renderer.
+	r, varCtx := newRequest("")
+	varCtx.ErrorReason = errors.ReasonRouteNotFound
+	w = httptest.NewRecorder()
+	global.ServeGlobal(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errors.ErrNotFound.WriteJSON(w)
+	}), w, r)
+	doc := decode(t, w)
+	if doc["reason"] != errors.ReasonRouteNotFound || doc["route"] != nil {
+		t.Errorf("document = %v, want route_not_found without a route", doc)
+	}
+}
@@ -0,0 +1,413 @@
+package quota
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/ratelimit"
+	"github.com/wudi/runway/variables"
+	"go.uber.org/zap"
+)
+
+// redisFlushBytes is how many bytes a request counts locally before pushing
+// them to Redis. Smaller values make concurrent requests see each other
+// sooner at the cost of more round trips.
+const redisFlushBytes = 64 << 10
+
+// errBandwidthExceeded is returned from a request body read once the
+// bandwidth quota is used up.
+var errBandwidthExceeded = stderrors.New("bandwidth quota exceeded")
+
+// bandwidthEntry tracks bytes used by one key within a billing window.
+type bandwidthEntry struct {
+	mu          sync.Mutex
+	bytes       int64
+	windowStart time.Time
+}
+
+// BandwidthEnforcer limits the total request and response bytes a client
+// may transfer per billing period. Bytes are counted as bodies stream, so
+// chunked uploads and streamed responses are included.
+type BandwidthEnforcer struct {
+	limit   int64
+	period  string
+	logOnly bool
+	keyFn   func(*http.Request) string
+	routeID string
+
+	// In-memory store
+	entries sync.Map // map[string]*bandwidthEntry
+
+	// Redis store (nil for in-memory mode)
+	redisClient *redis.Client
+
+	bytesIn        atomic.Int64
+	bytesOut       atomic.Int64
+	allowed        atomic.Int64
+	rejected       atomic.Int64
+	aborted        atomic.Int64
+	exceededLogged atomic.Int64
+	stopCh         chan struct{}
+}
+
+// NewBandwidth creates a BandwidthEnforcer.
+func NewBandwidth(routeID string, cfg config.BandwidthQuotaConfig, redisClient *redis.Client) *BandwidthEnforcer {
+	var rc *redis.Client
+	if cfg.Redis && redisClient != nil {
+		rc = redisClient
+	}
+
+	be := &BandwidthEnforcer{
+		limit:       cfg.Limit,
+		period:      cfg.Period,
+		logOnly:     cfg.Mode == "log_only",
+		keyFn:       ratelimit.BuildIPKeyFunc(false, cfg.Key, cfg.IPv6AggregationPrefix),
+		routeID:     routeID,
+		redisClient: rc,
+		stopCh:      make(chan struct{}),
+	}
+
+	if rc == nil {
+		go be.cleanup()
+	}
+
+	return be
+}
+
+// Middleware returns bandwidth quota enforcement middleware.
+func (be *BandwidthEnforcer) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			be.Serve(w, r, next, be.keyFn(r))
+		})
+	}
+}
+
+// Serve enforces the quota for key around next. Requests that start with
+// the budget used up are rejected with 429. A request body that crosses
+// the limit is cut off and answered with 413; response bodies are never
+// cut off but are always counted.
+func (be *BandwidthEnforcer) Serve(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	if key == "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	windowStart, windowEnd := billingWindow(be.period, time.Now())
+	used, err := be.usage(r.Context(), key, windowStart)
+	if err != nil {
+		// On error, allow the request (fail open)
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	remaining := be.limit - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	w.Header().Set("X-Bandwidth-Limit", strconv.FormatInt(be.limit, 10))
+	w.Header().Set("X-Bandwidth-Remaining", strconv.FormatInt(remaining, 10))
+	w.Header().Set("X-Bandwidth-Reset", strconv.FormatInt(windowEnd.Unix(), 10))
+
+	if used >= be.limit {
+		if !be.logOnly {
+			be.rejected.Add(1)
+			w.Header().Set("Retry-After", strconv.FormatInt(int64(time.Until(windowEnd).Seconds())+1, 10))
+			variables.SetErrorReason(r, errors.ReasonQuotaExceeded)
+			http.Error(w, "Bandwidth quota exceeded", http.StatusTooManyRequests)
+			return
+		}
+		be.logExceeded(key, used)
+	}
+	be.allowed.Add(1)
+
+	m := &meter{be: be, key: key, windowStart: windowStart, windowEnd: windowEnd, ctx: r.Context(), total: used, logged: used >= be.limit}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = &countingBody{ReadCloser: r.Body, m: m}
+	}
+	bw := &bandwidthWriter{ResponseWriter: w, m: m}
+	next.ServeHTTP(bw, r)
+
+	if m.exceeded.Load() && !bw.wroteHeader {
+		bw.WriteHeader(http.StatusRequestEntityTooLarge)
+	}
+	m.flush()
+}
+
+// usage returns the bytes already used by key in the current window.
+func (be *BandwidthEnforcer) usage(ctx context.Context, key string, windowStart time.Time) (int64, error) {
+	if be.redisClient != nil {
+		n, err := be.redisClient.Get(ctx, be.redisKey(key, windowStart)).Int64()
+		if err == redis.Nil {
+			return 0, nil
+		}
+		return n, err
+	}
+	v, ok := be.entries.Load(key)
+	if !ok {
+		return 0, nil
+	}
+	entry := v.(*bandwidthEntry)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if !entry.windowStart.Equal(windowStart) {
+		return 0, nil
+	}
+	return entry.bytes, nil
+}
+
+// add records n bytes for key and returns the new window total.
+func (be *BandwidthEnforcer) add(ctx context.Context, key string, windowStart, windowEnd time.Time, n int64) (int64, error) {
+	if be.redisClient != nil {
+		rKey := be.redisKey(key, windowStart)
+		total, err := be.redisClient.IncrBy(ctx, rKey, n).Result()
+		if err != nil {
+			return 0, err
+		}
+		// Set expiry on first increment
+		if total == n {
+			be.redisClient.ExpireAt(ctx, rKey, windowEnd.Add(time.Minute))
+		}
+		return total, nil
+	}
+
+	actual, _ := be.entries.LoadOrStore(key, &bandwidthEntry{windowStart: windowStart})
+	entry := actual.(*bandwidthEntry)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if !entry.windowStart.Equal(windowStart) {
+		entry.bytes = 0
+		entry.windowStart = windowStart
+	}
+	entry.bytes += n
+	return entry.bytes, nil
+}
+
+func (be *BandwidthEnforcer) redisKey(key string, windowStart time.Time) string {
+	return fmt.Sprintf("bwquota:%s:%s:%d:%s", be.routeID, be.period, windowStart.Unix(), key)
+}
+
+// Usage returns the bytes used by key in the current window.
+func (be *BandwidthEnforcer) Usage(ctx context.Context, key string) int64 {
+	windowStart, _ := billingWindow(be.period, time.Now())
+	n, _ := be.usage(ctx, key, windowStart)
+	return n
+}
+
+func (be *BandwidthEnforcer) logExceeded(key string, used int64) {
+	be.exceededLogged.Add(1)
+	logging.Warn("bandwidth quota exceeded (log only)",
+		zap.String("route_id", be.routeID),
+		zap.String("key", key),
+		zap.Int64("used", used),
+		zap.Int64("limit", be.limit),
+	)
+}
+
+// cleanup periodically removes expired in-memory entries.
+func (be *BandwidthEnforcer) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			windowStart, _ := billingWindow(be.period, time.Now())
+			be.entries.Range(func(key, value any) bool {
+				entry := value.(*bandwidthEntry)
+				entry.mu.Lock()
+				stale := !entry.windowStart.Equal(windowStart)
+				entry.mu.Unlock()
+				if stale {
+					be.entries.Delete(key)
+				}
+				return true
+			})
+		case <-be.stopCh:
+			return
+		}
+	}
+}
+
+// Close stops the background cleanup goroutine.
+func (be *BandwidthEnforcer) Close() {
+	select {
+	case <-be.stopCh:
+	default:
+		close(be.stopCh)
+	}
+}
+
+// Stats returns bandwidth enforcer stats.
+func (be *BandwidthEnforcer) Stats() map[string]interface{} {
+	mode := "reject"
+	if be.logOnly {
+		mode = "log_only"
+	}
+	return map[string]interface{}{
+		"limit":           be.limit,
+		"period":          be.period,
+		"mode":            mode,
+		"redis":           be.redisClient != nil,
+		"bytes_in":        be.bytesIn.Load(),
+		"bytes_out":       be.bytesOut.Load(),
+		"allowed":         be.allowed.Load(),
+		"rejected":        be.rejected.Load(),
+		"aborted":         be.aborted.Load(),
+		"exceeded_logged": be.exceededLogged.Load(),
+	}
+}
+
+// meter counts the bytes of one request against its key. Request and
+// response bodies may be transferred concurrently, so counting is locked.
+type meter struct {
+	be          *BandwidthEnforcer
+	key         string
+	windowStart time.Time
+	windowEnd   time.Time
+	ctx         context.Context
+
+	mu       sync.Mutex
+	total    int64 // last known window total, including pending
+	pending  int64 // bytes not yet pushed to the store
+	logged   bool
+	exceeded atomic.Bool // request body crossed the limit in reject mode
+}
+
+// record counts n bytes and reports whether the window total is now over
+// the limit.
+func (m *meter) record(n int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending += n
+	m.total += n
+	if m.be.redisClient == nil || m.pending >= redisFlushBytes {
+		m.push(m.ctx)
+	}
+	over := m.total > m.be.limit
+	if over && m.be.logOnly && !m.logged {
+		m.logged = true
+		m.be.logExceeded(m.key, m.total)
+	}
+	return over
+}
+
+// push writes pending bytes to the store. Caller must hold m.mu.
+func (m *meter) push(ctx context.Context) {
+	if m.pending == 0 {
+		return
+	}
+	total, err := m.be.add(ctx, m.key, m.windowStart, m.windowEnd, m.pending)
+	if err != nil {
+		return
+	}
+	m.pending = 0
+	m.total = total
+}
+
+// flush pushes any remaining bytes, even if the client has gone away.
+func (m *meter) flush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.push(context.WithoutCancel(m.ctx))
+}
+
+// countingBody counts request body bytes as they are read. In reject mode
+// it fails the read that takes the total over the limit.
+type countingBody struct {
+	io.ReadCloser
+	m *meter
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	if b.m.exceeded.Load() {
+		return 0, errBandwidthExceeded
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.m.be.bytesIn.Add(int64(n))
+		if b.m.record(int64(n)) && !b.m.be.logOnly {
+			if b.m.exceeded.CompareAndSwap(false, true) {
+				b.m.be.aborted.Add(1)
+			}
+			return n, errBandwidthExceeded
+		}
+	}
+	return n, err
+}
+
+// bandwidthWriter counts response body bytes. Once the request body has
+// been cut off, it replaces whatever error response the backend path
+// produces with 413.
+type bandwidthWriter struct {
+	http.ResponseWriter
+	m           *meter
+	wroteHeader bool
+	suppressed  bool
+}
+
+func (w *bandwidthWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if w.m.exceeded.Load() {
+		w.suppressed = true
+		errors.ErrRequestEntityTooLarge.WithDetails(
+			fmt.Sprintf("Request exceeds bandwidth quota of %d bytes", w.m.be.limit),
+		).WriteJSON(w.ResponseWriter)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *bandwidthWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.suppressed {
+		return len(b), nil
+	}
+	n, err := w.ResponseWriter.Write(b)
+	if n > 0 {
+		w.m.be.bytesOut.Add(int64(n))
+		w.m.record(int64(n))
+	}
+	return n, err
+}
+
+func (w *bandwidthWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *bandwidthWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// BandwidthByRoute manages per-route bandwidth quota enforcers.
+type BandwidthByRoute = byroute.NamedFactory[*BandwidthEnforcer, config.BandwidthQuotaConfig]
+
+// NewBandwidthByRoute creates a new per-route bandwidth quota manager.
+func NewBandwidthByRoute(redisClient *redis.Client) *BandwidthByRoute {
+	return byroute.SimpleNamedFactory(
+		func(routeID string, cfg config.BandwidthQuotaConfig) *BandwidthEnforcer {
+			return NewBandwidth(routeID, cfg, redisClient)
+		},
+		func(be *BandwidthEnforcer) any { return be.Stats() },
+	).WithClose((*BandwidthEnforcer).Close)
+}
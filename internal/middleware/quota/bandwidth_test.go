@@ -0,0 +1,167 @@
+package quota
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+func newBandwidth(t *testing.T, limit int64, mode string) *BandwidthEnforcer {
+	t.Helper()
+	be := NewBandwidth("route1", config.BandwidthQuotaConfig{
+		Enabled: true,
+		Limit:   limit,
+		Period:  "daily",
+		Key:     "ip",
+		Mode:    mode,
+	}, nil)
+	t.Cleanup(be.Close)
+	return be
+}
+
+// echoBackend reads the whole body like the proxy does, answering 502 if
+// the read fails, and echoes what it received.
+func echoBackend(received *int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		*received = int64(len(body))
+		if err != nil {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		w.Write(body)
+	})
+}
+
+func bandwidthRequest(body io.Reader) *http.Request {
+	req := httptest.NewRequest("POST", "/", body)
+	req.RemoteAddr = "1.2.3.4:1234"
+	return req
+}
+
+func TestBandwidth_StreamedUploadCrossesLimit(t *testing.T) {
+	be := newBandwidth(t, 1000, "")
+	var received int64
+	handler := be.Middleware()(echoBackend(&received))
+
+	// Stream ten 256-byte chunks with no Content-Length.
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < 10; i++ {
+			if _, err := pw.Write(bytes.Repeat([]byte("x"), 256)); err != nil {
+				return
+			}
+		}
+		pw.Close()
+	}()
+	defer pr.Close()
+
+	req := bandwidthRequest(pr)
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", w.Code)
+	}
+	if received >= 10*256 {
+		t.Errorf("upload should have been cut off, backend received %d bytes", received)
+	}
+	if received <= 1000-256 {
+		t.Errorf("upload should have been allowed up to the limit, backend received %d bytes", received)
+	}
+
+	stats := be.Stats()
+	if stats["aborted"] != int64(1) {
+		t.Errorf("expected 1 aborted request, got %v", stats["aborted"])
+	}
+	if stats["bytes_in"] != received {
+		t.Errorf("expected bytes_in %d, got %v", received, stats["bytes_in"])
+	}
+
+	// The budget is spent, so the next request is rejected up front.
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, bandwidthRequest(strings.NewReader("hi")))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("X-Bandwidth-Remaining") != "0" {
+		t.Errorf("expected 0 remaining, got %q", w.Header().Get("X-Bandwidth-Remaining"))
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("missing Retry-After header")
+	}
+}
+
+func TestBandwidth_ResponseFinishesButIsRecorded(t *testing.T) {
+	be := newBandwidth(t, 100, "")
+	handler := be.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("y"), 300))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, bandwidthRequest(nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 300 {
+		t.Fatalf("expected full 300-byte response, got %d with %d bytes", w.Code, w.Body.Len())
+	}
+	if w.Header().Get("X-Bandwidth-Limit") != "100" || w.Header().Get("X-Bandwidth-Remaining") != "100" {
+		t.Errorf("unexpected headers: %v", w.Header())
+	}
+	if got := be.Usage(bandwidthRequest(nil).Context(), "1.2.3.4"); got != 300 {
+		t.Errorf("expected 300 bytes used, got %d", got)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, bandwidthRequest(nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 once the response exhausted the budget, got %d", w.Code)
+	}
+}
+
+func TestBandwidth_LogOnly(t *testing.T) {
+	be := newBandwidth(t, 100, "log_only")
+	var received int64
+	handler := be.Middleware()(echoBackend(&received))
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, bandwidthRequest(strings.NewReader(strings.Repeat("z", 200))))
+		if w.Code != http.StatusOK || received != 200 {
+			t.Fatalf("request %d: log_only must not interfere, got %d after %d bytes", i, w.Code, received)
+		}
+	}
+
+	stats := be.Stats()
+	if stats["rejected"] != int64(0) || stats["aborted"] != int64(0) {
+		t.Errorf("log_only should not reject: %v", stats)
+	}
+	if stats["exceeded_logged"] != int64(2) {
+		t.Errorf("expected 2 logged exceedances, got %v", stats["exceeded_logged"])
+	}
+	if stats["bytes_in"] != int64(400) || stats["bytes_out"] != int64(400) {
+		t.Errorf("unexpected byte counters: %v", stats)
+	}
+}
+
+func TestBandwidth_SeparateKeys(t *testing.T) {
+	be := newBandwidth(t, 10, "")
+	handler := be.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+
+	req := bandwidthRequest(nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	other := bandwidthRequest(nil)
+	other.RemoteAddr = "5.6.7.8:1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, other)
+	if w.Code != http.StatusOK {
+		t.Errorf("a different client should have its own budget, got %d", w.Code)
+	}
+}
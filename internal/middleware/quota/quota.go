@@ -13,8 +13,12 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/decision"
+	"github.com/wudi/runway/internal/errors"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/consumergroup"
 	"github.com/wudi/runway/internal/middleware/ratelimit"
+	"github.com/wudi/runway/variables"
 )
 
 // quotaEntry tracks usage within a billing window.
@@ -29,6 +33,7 @@ type QuotaEnforcer struct {
 	period     string
 	keyFn      func(*http.Request) string
 	routeID    string
+	skipShadow bool
 
 	// In-memory store
 	entries sync.Map // map[string]*quotaEntry
@@ -51,8 +56,9 @@ func New(routeID string, cfg config.QuotaConfig, redisClient *redis.Client) *Quo
 	qe := &QuotaEnforcer{
 		limit:       cfg.Limit,
 		period:      cfg.Period,
-		keyFn:       ratelimit.BuildKeyFunc(false, cfg.Key),
+		keyFn:       ratelimit.BuildIPKeyFunc(false, cfg.Key, cfg.IPv6AggregationPrefix),
 		routeID:     routeID,
+		skipShadow:  cfg.SkipShadow == nil || *cfg.SkipShadow,
 		redisClient: rc,
 		stopCh:      make(chan struct{}),
 	}
@@ -65,10 +71,18 @@ func New(routeID string, cfg config.QuotaConfig, redisClient *redis.Client) *Quo
 	return qe
 }
 
-// Middleware returns quota enforcement middleware.
+// Middleware returns quota enforcement middleware. Consumers of a consumer
+// group with a quota are held to the group's quota instead of the route's;
+// usage is counted per client either way.
 func (qe *QuotaEnforcer) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Copies shadowed from another route don't use up the quota.
+			if qe.skipShadow && variables.IsShadow(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			key := qe.keyFn(r)
 			windowStart, windowEnd := qe.currentWindow(time.Now())
 
@@ -81,24 +95,46 @@ func (qe *QuotaEnforcer) Middleware() middleware.Middleware {
 				count, err = qe.memoryAllow(key, windowStart)
 			}
 
+			rec := decision.FromContext(r.Context())
 			if err != nil {
 				// On error, allow the request (fail open)
+				if rec != nil {
+					rec.Add("quota", decision.Allow, "error", "store unavailable")
+				}
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			remaining := qe.limit - count
+			limit := qe.limit
+			if info := consumergroup.FromContext(r.Context()); info != nil && info.Group != nil && info.Group.Quota > 0 {
+				limit = info.Group.Quota
+			}
+
+			remaining := limit - count
 			if remaining < 0 {
 				remaining = 0
 			}
 
-			w.Header().Set("X-Quota-Limit", strconv.FormatInt(qe.limit, 10))
+			w.Header().Set("X-Quota-Limit", strconv.FormatInt(limit, 10))
 			w.Header().Set("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
 			w.Header().Set("X-Quota-Reset", strconv.FormatInt(windowEnd.Unix(), 10))
 
-			if count > qe.limit {
+			if rec != nil {
+				outcome := decision.Debit
+				if count > limit {
+					outcome = decision.Deny
+				}
+				rec.Add("quota", outcome,
+					"key", rec.Hash(key),
+					"used", strconv.FormatInt(count, 10),
+					"limit", strconv.FormatInt(limit, 10))
+			}
+
+			if count > limit {
 				qe.rejected.Add(1)
+				consumergroup.CountRejection(r, consumergroup.LimitQuota)
 				w.Header().Set("Retry-After", strconv.FormatInt(int64(time.Until(windowEnd).Seconds())+1, 10))
+				variables.SetErrorReason(r, errors.ReasonQuotaExceeded)
 				http.Error(w, "Quota exceeded", http.StatusTooManyRequests)
 				return
 			}
@@ -143,8 +179,14 @@ func (qe *QuotaEnforcer) redisAllow(ctx context.Context, key string, windowStart
 
 // currentWindow returns the start and end of the current billing window.
 func (qe *QuotaEnforcer) currentWindow(now time.Time) (time.Time, time.Time) {
+	return billingWindow(qe.period, now)
+}
+
+// billingWindow returns the start and end of the billing window for period
+// that contains now.
+func billingWindow(period string, now time.Time) (time.Time, time.Time) {
 	now = now.UTC()
-	switch qe.period {
+	switch period {
 	case "hourly":
 		start := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, time.UTC)
 		return start, start.Add(time.Hour)
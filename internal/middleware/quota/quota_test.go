@@ -1,6 +1,7 @@
 package quota
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/variables"
 )
 
 func TestQuotaEnforcer_AllowsWithinLimit(t *testing.T) {
@@ -322,3 +324,39 @@ func TestQuotaEnforcer_Stats(t *testing.T) {
 		t.Errorf("expected redis=false, got %v", stats["redis"])
 	}
 }
+
+func TestQuotaEnforcer_SkipShadow(t *testing.T) {
+	shadowed := func() *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "1.2.3.4:1234"
+		varCtx := variables.NewContext(req)
+		varCtx.ShadowOf = "orders-v1"
+		return req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) })
+
+	qe := New("route1", config.QuotaConfig{Enabled: true, Limit: 1, Period: "daily", Key: "ip"}, nil)
+	defer qe.Close()
+	handler := qe.Middleware()(ok)
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, shadowed())
+		if w.Code != 200 {
+			t.Fatalf("request %d: expected shadowed requests to bypass the quota, got %d", i, w.Code)
+		}
+	}
+	if qe.allowed.Load() != 0 {
+		t.Errorf("expected shadowed requests not counted, got %d", qe.allowed.Load())
+	}
+
+	counted := false
+	qe2 := New("route1", config.QuotaConfig{Enabled: true, Limit: 1, Period: "daily", Key: "ip", SkipShadow: &counted}, nil)
+	defer qe2.Close()
+	handler = qe2.Middleware()(ok)
+	handler.ServeHTTP(httptest.NewRecorder(), shadowed())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, shadowed())
+	if w.Code != 429 {
+		t.Errorf("expected shadowed requests counted with skip_shadow false, got %d", w.Code)
+	}
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/wudi/runway/internal/decision"
+	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/middleware/consumergroup"
+	"github.com/wudi/runway/internal/simulate"
+	"github.com/wudi/runway/variables"
+)
+
+// costLimit computes how many tokens a request debits. A zero costLimit
+// debits one token per request and leaves responses unchanged.
+type costLimit struct {
+	fn  func(*http.Request) int
+	max int // 0 caps the cost at the limiter's burst
+}
+
+func newCostLimit(fn func(*http.Request) int, maxCost int) costLimit {
+	return costLimit{fn: fn, max: maxCost}
+}
+
+// cost returns the request's cost clamped to [1, max]. burst is the
+// limiter's capacity, used as the cap when no max is configured.
+func (c costLimit) cost(r *http.Request, burst int) int {
+	if c.fn == nil {
+		return 1
+	}
+	n := c.fn(r)
+	maxCost := c.max
+	if maxCost <= 0 {
+		maxCost = burst
+	}
+	if n > maxCost {
+		n = maxCost
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// record exposes the request's cost in the X-RateLimit-Cost response header
+// and the access log.
+func (c costLimit) record(w http.ResponseWriter, r *http.Request, cost int) {
+	if c.fn == nil {
+		return
+	}
+	w.Header().Set("X-RateLimit-Cost", strconv.Itoa(cost))
+	variables.GetFromRequest(r).RateLimitCost = cost
+}
+
+// noteSimulated explains a simulated request's rate limit decision.
+func noteSimulated(r *http.Request, d Decision) {
+	if !simulate.Active(r.Context()) {
+		return
+	}
+	verdict := "allowed"
+	if !d.Allowed {
+		verdict = "rejected"
+	}
+	simulate.Note(r.Context(), "key %q: cost %d %s, %d remaining; nothing consumed", d.Key, d.Cost, verdict, d.Remaining)
+}
+
+// recordDecision records the rate limit debit of a recorded request. The
+// key is hashed. A request an observe-mode limiter would have rejected is
+// recorded as detected.
+func recordDecision(r *http.Request, d Decision, observe bool) {
+	rec := decision.FromContext(r.Context())
+	if rec == nil {
+		return
+	}
+	outcome := decision.Debit
+	if !d.Allowed {
+		outcome = decision.Deny
+		if observe {
+			outcome = decision.Detect
+		}
+	}
+	kv := []string{
+		"key", rec.Hash(d.Key),
+		"cost", strconv.Itoa(d.Cost),
+		"remaining", strconv.Itoa(d.Remaining),
+		"limit", strconv.Itoa(d.Limit),
+	}
+	if d.Tier != "" {
+		kv = append(kv, "tier", d.Tier)
+	}
+	rec.Add("rate_limit", outcome, kv...)
+}
+
+// reject writes the 429 response and reports the rejection to reputation
+// scoring. Cost-based limits state the request's cost and the budget that
+// was left. wait is the time until the limit resets.
+func (c costLimit) reject(w http.ResponseWriter, r *http.Request, wait time.Duration, cost, remaining int) {
+	variables.AddSignal(r, variables.SignalRateLimit)
+	consumergroup.CountRejection(r, consumergroup.LimitRateLimit)
+	retryAfter := int(wait.Seconds())
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	if c.fn == nil {
+		errors.ErrTooManyRequests.WriteJSON(w)
+		return
+	}
+	errors.ErrTooManyRequests.WithDetails(
+		fmt.Sprintf("request cost %d exceeds remaining rate limit budget %d", cost, remaining),
+	).WriteJSON(w)
+}
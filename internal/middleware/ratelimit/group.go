@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/consumergroup"
+)
+
+// GroupLimiterFunc builds the limiter enforcing a consumer group's rate
+// limit of rate requests per second on a route.
+type GroupLimiterFunc func(group string, rate int) RateLimitMiddleware
+
+// groupLimits applies the rate limit of a request's consumer group in place
+// of the route's own. Each group gets its own limiter, built on first use
+// and kept for the life of the route.
+type groupLimits struct {
+	build    GroupLimiterFunc
+	mu       sync.Mutex
+	limiters map[string]RateLimitMiddleware
+}
+
+// limiter returns the limiter of group, building it on first use.
+func (g *groupLimits) limiter(group string, rate int) RateLimitMiddleware {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	l, ok := g.limiters[group]
+	if !ok {
+		l = g.build(group, rate)
+		g.limiters[group] = l
+	}
+	return l
+}
+
+// wrap returns a middleware sending requests of groups with a rate limit
+// through their group's limiter and all others through route.
+func (g *groupLimits) wrap(route middleware.Middleware) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		routeHandler := route(next)
+		var handlers sync.Map // group name -> http.Handler
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := consumergroup.FromContext(r.Context())
+			if info == nil || info.Group == nil || info.Group.RateLimit <= 0 {
+				routeHandler.ServeHTTP(w, r)
+				return
+			}
+			h, ok := handlers.Load(info.Name)
+			if !ok {
+				h, _ = handlers.LoadOrStore(info.Name, g.limiter(info.Name, info.Group.RateLimit).Middleware()(next))
+			}
+			h.(http.Handler).ServeHTTP(w, r)
+		})
+	}
+}
+
+// SetGroupLimits makes a route's rate limit defer to the rate limit of the
+// request's consumer group, enforced by limiters from build. It has no
+// effect on routes without a rate limiter.
+func (rl *RateLimitByRoute) SetGroupLimits(routeID string, build GroupLimiterFunc) {
+	if v := rl.Lookup(routeID); v != nil {
+		v.groups = &groupLimits{build: build, limiters: make(map[string]RateLimitMiddleware)}
+	}
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/middleware/consumergroup"
+)
+
+func TestSetGroupLimits(t *testing.T) {
+	rl := NewRateLimitByRoute()
+	rl.AddRoute("r1", Config{Rate: 100, Period: time.Second, Burst: 100, PerIP: true})
+
+	built := map[string]int{}
+	rl.SetGroupLimits("r1", func(group string, rate int) RateLimitMiddleware {
+		built[group]++
+		return NewLimiter(Config{Rate: rate, Period: time.Second, Burst: rate, PerIP: true})
+	})
+	rl.SetGroupLimits("unknown", func(string, int) RateLimitMiddleware {
+		t.Fatal("built limiter for route without rate limit")
+		return nil
+	})
+
+	handler := rl.GetMiddleware("r1")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(info *consumergroup.GroupInfo) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		if info != nil {
+			r = r.WithContext(consumergroup.WithGroup(r.Context(), info))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	bronze := &consumergroup.GroupInfo{Name: "bronze", Group: &config.ConsumerGroup{RateLimit: 2}}
+	for i := 0; i < 2; i++ {
+		if code := send(bronze); code != http.StatusOK {
+			t.Fatalf("bronze request %d: status %d, want 200", i, code)
+		}
+	}
+	if code := send(bronze); code != http.StatusTooManyRequests {
+		t.Errorf("bronze over limit: status %d, want 429", code)
+	}
+
+	// Groups without a rate limit and requests without a group use the
+	// route's limiter, which the bronze requests did not consume.
+	noLimit := &consumergroup.GroupInfo{Name: "internal", Group: &config.ConsumerGroup{Priority: 1}}
+	for _, info := range []*consumergroup.GroupInfo{nil, noLimit} {
+		if code := send(info); code != http.StatusOK {
+			t.Errorf("route limit: status %d, want 200", code)
+		}
+	}
+
+	if built["bronze"] != 1 || len(built) != 1 {
+		t.Errorf("built = %v, want one bronze limiter", built)
+	}
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Decision is a limiter's verdict on one request and the quota left after it.
+type Decision struct {
+	Allowed   bool
+	Limit     int       // capacity of the limit the request was counted against
+	Remaining int       // quota left after the request
+	Reset     time.Time // when the quota replenishes; when rejected, when the request would fit
+	Cost      int       // tokens the request debits
+	Key       string    // per-client key the request was counted under
+	Tier      string    // tier the request was counted in, for tiered limiters
+}
+
+// HeaderConfig selects the quota headers a limiter writes on every
+// response. The zero value writes the draft RFC RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers.
+type HeaderConfig struct {
+	Disabled bool // write no quota headers, so limits are not disclosed
+	Legacy   bool // also write X-RateLimit-Limit, -Remaining and -Reset
+}
+
+// write sets the quota headers for d. RateLimit-Reset is the number of
+// seconds until d.Reset, counted from now; the legacy X-RateLimit-Reset is
+// the Unix time of d.Reset.
+func (h HeaderConfig) write(w http.ResponseWriter, d Decision, now time.Time) {
+	if h.Disabled {
+		return
+	}
+	limit := strconv.Itoa(d.Limit)
+	remaining := strconv.Itoa(d.Remaining)
+	header := w.Header()
+	header.Set("RateLimit-Limit", limit)
+	header.Set("RateLimit-Remaining", remaining)
+	header.Set("RateLimit-Reset", strconv.FormatInt(resetSeconds(d.Reset, now), 10))
+	if h.Legacy {
+		header.Set("X-RateLimit-Limit", limit)
+		header.Set("X-RateLimit-Remaining", remaining)
+		header.Set("X-RateLimit-Reset", strconv.FormatInt(d.Reset.Unix(), 10))
+	}
+}
+
+// resetSeconds returns the whole seconds from now until reset, rounded up
+// so clients never retry early.
+func resetSeconds(reset, now time.Time) int64 {
+	wait := reset.Sub(now)
+	if wait <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(wait.Seconds()))
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/internal/clock"
+)
+
+func serveQuota(t *testing.T, mw func(http.Handler) http.Handler) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/api", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	rr := httptest.NewRecorder()
+	mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestQuotaHeadersTokenBucket(t *testing.T) {
+	clk := clock.NewFake(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	l := NewLimiter(Config{Rate: 10, Period: time.Minute, Burst: 2, PerIP: true})
+	l.tb.clock = clk
+	mw := l.Middleware()
+
+	rr := serveQuota(t, mw)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	for name, want := range map[string]string{
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "1",
+		"RateLimit-Reset":     "60",
+	} {
+		if got := rr.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if got := rr.Header().Get("X-RateLimit-Limit"); got != "" {
+		t.Errorf("legacy headers should be off by default, got X-RateLimit-Limit %q", got)
+	}
+
+	serveQuota(t, mw)
+	rr = serveQuota(t, mw)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	// One token refills every 6s.
+	if rr.Header().Get("RateLimit-Remaining") != "0" || rr.Header().Get("RateLimit-Reset") != "6" {
+		t.Errorf("expected 0 remaining resetting in 6s, got remaining=%q reset=%q",
+			rr.Header().Get("RateLimit-Remaining"), rr.Header().Get("RateLimit-Reset"))
+	}
+	if got := rr.Header().Get("Retry-After"); got != "6" {
+		t.Errorf("Retry-After = %q, want 6", got)
+	}
+}
+
+func TestQuotaHeadersLegacy(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 30, 0, time.UTC)
+	clk := clock.NewFake(start)
+	l := NewSlidingWindowLimiter(Config{Rate: 5, Period: time.Minute, PerIP: true, Headers: HeaderConfig{Legacy: true}})
+	l.sw.clock = clk
+
+	rr := serveQuota(t, l.Middleware())
+	// The window ends on the minute.
+	if got := rr.Header().Get("RateLimit-Reset"); got != "30" {
+		t.Errorf("RateLimit-Reset = %q, want 30", got)
+	}
+	wantReset := strconv.FormatInt(start.Add(30*time.Second).Unix(), 10)
+	for name, want := range map[string]string{
+		"X-RateLimit-Limit":     "5",
+		"X-RateLimit-Remaining": "4",
+		"X-RateLimit-Reset":     wantReset,
+	} {
+		if got := rr.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestQuotaHeadersDisabled(t *testing.T) {
+	tl := NewTieredLimiter(TieredConfig{
+		Tiers:       map[string]Config{"free": {Rate: 1, Period: time.Minute}},
+		TierKey:     "header:X-Tier",
+		DefaultTier: "free",
+		Headers:     HeaderConfig{Disabled: true, Legacy: true},
+	})
+	mw := tl.Middleware()
+
+	rr := serveQuota(t, mw)
+	for _, name := range []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "X-RateLimit-Limit"} {
+		if got := rr.Header().Get(name); got != "" {
+			t.Errorf("expected no %s, got %q", name, got)
+		}
+	}
+	if rr = serveQuota(t, mw); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+}
+
+func TestDecide(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	limiters := map[string]RateLimitMiddleware{
+		"token_bucket":   NewLimiter(Config{Rate: 2, Period: time.Minute, PerIP: true}),
+		"sliding_window": NewSlidingWindowLimiter(Config{Rate: 2, Period: time.Minute, PerIP: true}),
+		"tiered": NewTieredLimiter(TieredConfig{
+			Tiers:       map[string]Config{"free": {Rate: 2, Period: time.Minute}},
+			TierKey:     "header:X-Tier",
+			DefaultTier: "free",
+		}),
+	}
+	for name, l := range limiters {
+		var got []Decision
+		for i := 0; i < 3; i++ {
+			d, err := l.Decide(req)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			got = append(got, d)
+		}
+		if !got[0].Allowed || !got[1].Allowed || got[2].Allowed {
+			t.Errorf("%s: expected allow, allow, reject, got %v %v %v", name, got[0].Allowed, got[1].Allowed, got[2].Allowed)
+		}
+		if got[0].Limit != 2 || got[0].Remaining != 1 || got[2].Remaining != 0 {
+			t.Errorf("%s: expected limit 2 with 1 then 0 remaining, got %+v", name, got)
+		}
+		if !got[2].Reset.After(time.Now()) {
+			t.Errorf("%s: expected a future reset, got %v", name, got[2].Reset)
+		}
+	}
+}
+
+func TestResetSeconds(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		reset time.Time
+		want  int64
+	}{
+		{now.Add(-time.Second), 0},
+		{now, 0},
+		{now.Add(time.Millisecond), 1},
+		{now.Add(2 * time.Second), 2},
+		{now.Add(2500 * time.Millisecond), 3},
+	}
+	for _, tt := range tests {
+		if got := resetSeconds(tt.reset, now); got != tt.want {
+			t.Errorf("resetSeconds(%v) = %d, want %d", tt.reset.Sub(now), got, tt.want)
+		}
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/wudi/runway/internal/byroute"
-	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/clock"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/simulate"
 	"github.com/wudi/runway/variables"
 )
 
@@ -17,10 +19,10 @@ import (
 type TokenBucket struct {
 	rate       float64       // tokens per second
 	burst      int           // max tokens
-	burstStr   string        // cached strconv.Itoa(burst) for headers
 	period     time.Duration // refill period
 	buckets    *shardedMap[*bucket]
 	cleanupInt time.Duration
+	clock      clock.Clock
 }
 
 type bucket struct {
@@ -36,6 +38,18 @@ type Config struct {
 	Burst  int           // max burst size
 	PerIP  bool          // rate limit per IP instead of globally
 	Key    string        // custom key extraction strategy
+
+	IPv6Prefix int // IPv6 client IPs are keyed by their network of this length (0: ipaddr.DefaultIPv6Prefix)
+
+	Cost    func(*http.Request) int // per-request token cost (nil debits 1)
+	MaxCost int                     // cost cap (default burst)
+
+	// Observe runs the limiter without rejecting: requests it would reject
+	// pass with WouldRejectHeader set and are counted.
+	Observe          bool
+	OnObservedReject func(tier string) // called for every would-be rejection
+
+	Headers HeaderConfig // quota headers written on every response
 }
 
 // NewTokenBucket creates a new token bucket rate limiter
@@ -50,10 +64,10 @@ func NewTokenBucket(cfg Config) *TokenBucket {
 	tb := &TokenBucket{
 		rate:       float64(cfg.Rate) / cfg.Period.Seconds(),
 		burst:      cfg.Burst,
-		burstStr:   strconv.Itoa(cfg.Burst),
 		period:     cfg.Period,
 		buckets:    newShardedMap[*bucket](),
 		cleanupInt: 5 * time.Minute,
+		clock:      clock.Default(),
 	}
 
 	// Start cleanup goroutine
@@ -64,7 +78,19 @@ func NewTokenBucket(cfg Config) *TokenBucket {
 
 // Allow checks if a request should be allowed
 func (tb *TokenBucket) Allow(key string) (allowed bool, remaining int, resetTime time.Time) {
-	now := time.Now()
+	return tb.AllowN(key, 1)
+}
+
+// AllowN checks if a request costing n tokens should be allowed and debits
+// them if so. On rejection remaining is the number of tokens left.
+func (tb *TokenBucket) AllowN(key string, n int) (allowed bool, remaining int, resetTime time.Time) {
+	return tb.takeN(key, n, true)
+}
+
+// takeN is AllowN; without consume the bucket is left as it was, for
+// simulated requests.
+func (tb *TokenBucket) takeN(key string, n int, consume bool) (allowed bool, remaining int, resetTime time.Time) {
+	now := tb.clock.Now()
 
 	s := tb.buckets.getShard(key)
 	s.mu.Lock()
@@ -76,7 +102,13 @@ func (tb *TokenBucket) Allow(key string) (allowed bool, remaining int, resetTime
 			lastTime:  now,
 			maxTokens: tb.burst,
 		}
-		s.items[key] = b
+		if consume {
+			s.items[key] = b
+		}
+	}
+	if !consume {
+		cp := *b
+		b = &cp
 	}
 
 	// Add tokens based on time elapsed
@@ -90,19 +122,20 @@ func (tb *TokenBucket) Allow(key string) (allowed bool, remaining int, resetTime
 	// Calculate reset time
 	resetTime = now.Add(tb.period)
 
-	if b.tokens >= 1 {
-		b.tokens--
+	if b.tokens >= float64(n) {
+		b.tokens -= float64(n)
 		remaining = int(b.tokens)
 		s.mu.Unlock()
 		return true, remaining, resetTime
 	}
 
-	// Calculate time until next token
-	waitTime := time.Duration((1 - b.tokens) / tb.rate * float64(time.Second))
+	// Calculate time until enough tokens are available
+	waitTime := time.Duration((float64(n) - b.tokens) / tb.rate * float64(time.Second))
 	resetTime = now.Add(waitTime)
+	remaining = int(b.tokens)
 	s.mu.Unlock()
 
-	return false, 0, resetTime
+	return false, remaining, resetTime
 }
 
 // cleanup removes stale buckets periodically
@@ -111,7 +144,7 @@ func (tb *TokenBucket) cleanup() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		now := time.Now()
+		now := tb.clock.Now()
 		cutoff := 2 * tb.period
 		tb.buckets.deleteFunc(func(_ string, b *bucket) bool {
 			return now.Sub(b.lastTime) > cutoff
@@ -121,27 +154,42 @@ func (tb *TokenBucket) cleanup() {
 
 // Limiter provides rate limiting middleware
 type Limiter struct {
-	tb    *TokenBucket
-	perIP bool
-	keyFn func(*http.Request) string
+	tb      *TokenBucket
+	perIP   bool
+	keyFn   func(*http.Request) string
+	cost    costLimit
+	observe *Observer // set in observe mode
+	headers HeaderConfig
 }
 
 // NewLimiter creates a new rate limiter
 func NewLimiter(cfg Config) *Limiter {
 	return &Limiter{
-		tb:    NewTokenBucket(cfg),
-		perIP: cfg.PerIP,
-		keyFn: BuildKeyFunc(cfg.PerIP, cfg.Key),
+		tb:      NewTokenBucket(cfg),
+		perIP:   cfg.PerIP,
+		keyFn:   BuildIPKeyFunc(cfg.PerIP, cfg.Key, cfg.IPv6Prefix),
+		cost:    newCostLimit(cfg.Cost, cfg.MaxCost),
+		observe: newObserver(cfg.Observe, cfg.OnObservedReject),
+		headers: cfg.Headers,
 	}
 }
 
 // BuildKeyFunc returns a key extraction function based on configuration.
 // All strategies fall back to client IP when the specified value is absent.
+// IPv6 client IPs are aggregated to ipaddr.DefaultIPv6Prefix.
 func BuildKeyFunc(perIP bool, key string) func(*http.Request) string {
+	return BuildIPKeyFunc(perIP, key, 0)
+}
+
+// BuildIPKeyFunc is like BuildKeyFunc, but IPv6 client IPs are keyed by
+// their network of ipv6Prefix bits (0 for the default, 128 for the full
+// address). IPv4 client IPs are keyed as is.
+func BuildIPKeyFunc(perIP bool, key string, ipv6Prefix int) func(*http.Request) string {
+	clientIP := func(r *http.Request) string {
+		return variables.ClientIPKey(r, ipv6Prefix)
+	}
 	if perIP || key == "ip" {
-		return func(r *http.Request) string {
-			return variables.ExtractClientIP(r)
-		}
+		return clientIP
 	}
 
 	if key == "client_id" {
@@ -150,7 +198,7 @@ func BuildKeyFunc(perIP bool, key string) func(*http.Request) string {
 			if varCtx.Identity != nil && varCtx.Identity.ClientID != "" {
 				return varCtx.Identity.ClientID
 			}
-			return variables.ExtractClientIP(r)
+			return clientIP(r)
 		}
 	}
 
@@ -161,7 +209,7 @@ func BuildKeyFunc(perIP bool, key string) func(*http.Request) string {
 			if v := r.Header.Get(name); v != "" {
 				return prefix + v
 			}
-			return variables.ExtractClientIP(r)
+			return clientIP(r)
 		}
 	}
 
@@ -172,7 +220,7 @@ func BuildKeyFunc(perIP bool, key string) func(*http.Request) string {
 			if c, err := r.Cookie(name); err == nil && c.Value != "" {
 				return prefix + c.Value
 			}
-			return variables.ExtractClientIP(r)
+			return clientIP(r)
 		}
 	}
 
@@ -201,7 +249,31 @@ func BuildKeyFunc(perIP bool, key string) func(*http.Request) string {
 					}
 				}
 			}
-			return variables.ExtractClientIP(r)
+			return clientIP(r)
+		}
+	}
+
+	if strings.HasPrefix(key, "body:") {
+		path := key[len("body:"):]
+		prefix := "body:" + path + ":"
+		return func(r *http.Request) string {
+			if v := variables.BodyField(r, path).String(); v != "" {
+				return prefix + v
+			}
+			return clientIP(r)
+		}
+	}
+
+	if strings.HasPrefix(key, "baggage:") {
+		name := key[len("baggage:"):]
+		prefix := "baggage:" + name + ":"
+		// Only members the baggage middleware registered count: the raw
+		// inbound header is client-controlled and unsanitized.
+		return func(r *http.Request) string {
+			if v, ok := variables.GetFromRequest(r).GetBaggage(name); ok && v != "" {
+				return prefix + v
+			}
+			return clientIP(r)
 		}
 	}
 
@@ -211,7 +283,7 @@ func BuildKeyFunc(perIP bool, key string) func(*http.Request) string {
 		if varCtx.Identity != nil && varCtx.Identity.ClientID != "" {
 			return varCtx.Identity.ClientID
 		}
-		return variables.ExtractClientIP(r)
+		return clientIP(r)
 	}
 }
 
@@ -222,26 +294,22 @@ func (l *Limiter) SetKeyFunc(fn func(*http.Request) string) {
 
 // Middleware creates a rate limiting middleware
 func (l *Limiter) Middleware() middleware.Middleware {
-	burstStr := l.tb.burstStr
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			key := l.keyFn(r)
-
-			allowed, remaining, resetTime := l.tb.Allow(key)
-
-			// Set rate limit headers
-			w.Header().Set("X-RateLimit-Limit", burstStr)
-			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
-			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetTime.Unix(), 10))
-
-			if !allowed {
-				retryAfter := int(time.Until(resetTime).Seconds())
-				if retryAfter < 1 {
-					retryAfter = 1
+			d := l.decide(r, !simulate.Active(r.Context()))
+			noteSimulated(r, d)
+			recordDecision(r, d, l.observe != nil)
+
+			now := l.tb.clock.Now()
+			l.headers.write(w, d, now)
+			l.cost.record(w, r, d.Cost)
+
+			if !d.Allowed {
+				if l.observe == nil {
+					l.cost.reject(w, r, d.Reset.Sub(now), d.Cost, d.Remaining)
+					return
 				}
-				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
-				errors.ErrTooManyRequests.WriteJSON(w)
-				return
+				l.observe.WouldReject(w, r, d.Key, "")
 			}
 
 			next.ServeHTTP(w, r)
@@ -249,32 +317,74 @@ func (l *Limiter) Middleware() middleware.Middleware {
 	}
 }
 
+// decide counts the request against its client's bucket. Without consume
+// the bucket is left as it was.
+func (l *Limiter) decide(r *http.Request, consume bool) Decision {
+	key := l.keyFn(r)
+	cost := l.cost.cost(r, l.tb.burst)
+	allowed, remaining, resetTime := l.tb.takeN(key, cost, consume)
+	return Decision{
+		Allowed:   allowed,
+		Limit:     l.tb.burst,
+		Remaining: remaining,
+		Reset:     resetTime,
+		Cost:      cost,
+		Key:       key,
+	}
+}
+
 // Allow checks if a request is allowed (for manual checking)
 func (l *Limiter) Allow(r *http.Request) bool {
-	key := l.keyFn(r)
-	allowed, _, _ := l.tb.Allow(key)
-	return allowed
+	return l.decide(r, true).Allowed
+}
+
+// Decide is Allow reporting the quota left. It never fails.
+func (l *Limiter) Decide(r *http.Request) (Decision, error) {
+	return l.decide(r, true), nil
 }
 
 // RateLimitMiddleware is the interface for both local and distributed rate limiters.
 type RateLimitMiddleware interface {
 	Middleware() middleware.Middleware
+
+	// Decide counts the request and reports the verdict with the quota
+	// left. Distributed limiters return an error, with an allowing
+	// Decision, when their store is unreachable.
+	Decide(r *http.Request) (Decision, error)
 }
 
+var (
+	_ RateLimitMiddleware = (*Limiter)(nil)
+	_ RateLimitMiddleware = (*TieredLimiter)(nil)
+	_ RateLimitMiddleware = (*RedisLimiter)(nil)
+)
+
 // TieredLimiter provides per-tier rate limiting, each tier having independent limits.
 type TieredLimiter struct {
 	tiers       map[string]*TokenBucket
 	tierKeyFn   func(*http.Request) string
 	keyFn       func(*http.Request) string
 	defaultTier string
+	cost        costLimit
+	observe     *Observer // set in observe mode
+	headers     HeaderConfig
 }
 
 // TieredConfig holds tiered rate limiter configuration.
 type TieredConfig struct {
-	Tiers       map[string]Config // per-tier limits
-	TierKey     string            // "header:<name>" or "jwt_claim:<name>"
-	DefaultTier string            // fallback tier
+	Tiers       map[string]Config          // per-tier limits
+	TierKey     string                     // "header:<name>" or "jwt_claim:<name>"
+	DefaultTier string                     // fallback tier
 	KeyFn       func(*http.Request) string // per-client key function
+	IPv6Prefix  int                        // as Config.IPv6Prefix, for the default client IP key
+
+	Cost    func(*http.Request) int // per-request token cost (nil debits 1)
+	MaxCost int                     // cost cap (default tier burst)
+
+	Observe          bool              // as Config.Observe
+	OnObservedReject func(tier string) // called for every would-be rejection
+
+	Headers HeaderConfig // quota headers written on every response
 }
 
 // NewTieredLimiter creates a new tiered rate limiter.
@@ -284,10 +394,13 @@ func NewTieredLimiter(cfg TieredConfig) *TieredLimiter {
 		tierKeyFn:   buildTierKeyFunc(cfg.TierKey),
 		keyFn:       cfg.KeyFn,
 		defaultTier: cfg.DefaultTier,
+		cost:        newCostLimit(cfg.Cost, cfg.MaxCost),
+		observe:     newObserver(cfg.Observe, cfg.OnObservedReject),
+		headers:     cfg.Headers,
 	}
 	if tl.keyFn == nil {
 		tl.keyFn = func(r *http.Request) string {
-			return variables.ExtractClientIP(r)
+			return variables.ClientIPKey(r, cfg.IPv6Prefix)
 		}
 	}
 	for name, tc := range cfg.Tiers {
@@ -334,37 +447,26 @@ func buildTierKeyFunc(tierKey string) func(*http.Request) string {
 func (tl *TieredLimiter) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Determine tier (rule override takes precedence)
-			tierName := tl.tierKeyFn(r)
-			if varCtx := variables.GetFromRequest(r); varCtx.Overrides != nil && varCtx.Overrides.RateLimitTier != "" {
-				tierName = varCtx.Overrides.RateLimitTier
-			}
-			tb, ok := tl.tiers[tierName]
-			if !ok {
-				tb = tl.tiers[tl.defaultTier]
-			}
+			tb, d := tl.decide(r, !simulate.Active(r.Context()))
 			if tb == nil {
 				next.ServeHTTP(w, r)
 				return
 			}
-
-			// Rate limit within tier using the per-client key
-			key := tl.keyFn(r)
-			allowed, remaining, resetTime := tb.Allow(key)
-
-			w.Header().Set("X-RateLimit-Limit", tb.burstStr)
-			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
-			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetTime.Unix(), 10))
-			w.Header().Set("X-RateLimit-Tier", tierName)
-
-			if !allowed {
-				retryAfter := int(time.Until(resetTime).Seconds())
-				if retryAfter < 1 {
-					retryAfter = 1
+			simulate.Note(r.Context(), "tier %q", d.Tier)
+			noteSimulated(r, d)
+			recordDecision(r, d, tl.observe != nil)
+
+			now := tb.clock.Now()
+			tl.headers.write(w, d, now)
+			w.Header().Set("X-RateLimit-Tier", d.Tier)
+			tl.cost.record(w, r, d.Cost)
+
+			if !d.Allowed {
+				if tl.observe == nil {
+					tl.cost.reject(w, r, d.Reset.Sub(now), d.Cost, d.Remaining)
+					return
 				}
-				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
-				errors.ErrTooManyRequests.WriteJSON(w)
-				return
+				tl.observe.WouldReject(w, r, d.Key, d.Tier)
 			}
 
 			next.ServeHTTP(w, r)
@@ -372,27 +474,83 @@ func (tl *TieredLimiter) Middleware() middleware.Middleware {
 	}
 }
 
+// decide counts the request in its tier's bucket. It returns a nil bucket
+// when neither the request's tier nor the default tier exists.
+func (tl *TieredLimiter) decide(r *http.Request, consume bool) (*TokenBucket, Decision) {
+	// Determine tier (rule override takes precedence)
+	tierName := tl.tierKeyFn(r)
+	if varCtx := variables.GetFromRequest(r); varCtx.Overrides != nil && varCtx.Overrides.RateLimitTier != "" {
+		tierName = varCtx.Overrides.RateLimitTier
+	}
+	tb, ok := tl.tiers[tierName]
+	if !ok {
+		tb = tl.tiers[tl.defaultTier]
+	}
+	if tb == nil {
+		return nil, Decision{Allowed: true, Tier: tierName}
+	}
+
+	// Rate limit within tier using the per-client key
+	key := tl.keyFn(r)
+	cost := tl.cost.cost(r, tb.burst)
+	allowed, remaining, resetTime := tb.takeN(key, cost, consume)
+	return tb, Decision{
+		Allowed:   allowed,
+		Limit:     tb.burst,
+		Remaining: remaining,
+		Reset:     resetTime,
+		Cost:      cost,
+		Key:       key,
+		Tier:      tierName,
+	}
+}
+
+// Decide counts the request in its tier and reports the quota left.
+// Requests without a usable tier are allowed with a zero Limit. It never
+// fails.
+func (tl *TieredLimiter) Decide(r *http.Request) (Decision, error) {
+	_, d := tl.decide(r, true)
+	return d, nil
+}
+
 // rateLimiterVariant wraps different rate limiter implementations behind a single type.
 type rateLimiterVariant struct {
 	algorithm string                // "token_bucket", "sliding_window", "tiered"
-	mode      string                // "local" or "distributed"
+	mode      string                // "local", "distributed" or "observe"
 	local     *Limiter              // set for token_bucket
 	sliding   *SlidingWindowLimiter // set for local sliding_window
 	redis     *RedisLimiter         // set for distributed sliding_window
 	tiered    *TieredLimiter        // set for tiered
+	key       string                // key strategy of token_bucket, part of its state fingerprint
+	restored  atomic.Int64          // buckets restored from saved state
+	observe   *Observer             // set in observe mode
+	groups    *groupLimits          // consumer group overrides, if enabled
+}
+
+// localMode returns the mode of a local limiter.
+func localMode(observe bool) string {
+	if observe {
+		return "observe"
+	}
+	return "local"
 }
 
 func (v *rateLimiterVariant) Middleware() middleware.Middleware {
+	var mw middleware.Middleware
 	switch {
 	case v.tiered != nil:
-		return v.tiered.Middleware()
+		mw = v.tiered.Middleware()
 	case v.redis != nil:
-		return v.redis.Middleware()
+		mw = v.redis.Middleware()
 	case v.sliding != nil:
-		return v.sliding.Middleware()
+		mw = v.sliding.Middleware()
 	default:
-		return v.local.Middleware()
+		mw = v.local.Middleware()
+	}
+	if v.groups != nil {
+		return v.groups.wrap(mw)
 	}
+	return mw
 }
 
 // RateLimitByRoute manages per-route rate limiters backed by byroute.Manager.
@@ -407,10 +565,13 @@ func NewRateLimitByRoute() *RateLimitByRoute {
 
 // AddRoute adds a token-bucket rate limiter for a specific route.
 func (rl *RateLimitByRoute) AddRoute(routeID string, cfg Config) {
+	l := NewLimiter(cfg)
 	rl.Add(routeID, &rateLimiterVariant{
 		algorithm: "token_bucket",
-		mode:      "local",
-		local:     NewLimiter(cfg),
+		mode:      localMode(cfg.Observe),
+		local:     l,
+		key:       cfg.Key,
+		observe:   l.observe,
 	})
 }
 
@@ -425,19 +586,23 @@ func (rl *RateLimitByRoute) AddRouteDistributed(routeID string, cfg RedisLimiter
 
 // AddRouteSlidingWindow adds a sliding window rate limiter for a specific route.
 func (rl *RateLimitByRoute) AddRouteSlidingWindow(routeID string, cfg Config) {
+	l := NewSlidingWindowLimiter(cfg)
 	rl.Add(routeID, &rateLimiterVariant{
 		algorithm: "sliding_window",
-		mode:      "local",
-		sliding:   NewSlidingWindowLimiter(cfg),
+		mode:      localMode(cfg.Observe),
+		sliding:   l,
+		observe:   l.observe,
 	})
 }
 
 // AddRouteTiered adds a tiered rate limiter for a specific route.
 func (rl *RateLimitByRoute) AddRouteTiered(routeID string, cfg TieredConfig) {
+	tl := NewTieredLimiter(cfg)
 	rl.Add(routeID, &rateLimiterVariant{
 		algorithm: "tiered",
-		mode:      "local",
-		tiered:    NewTieredLimiter(cfg),
+		mode:      localMode(cfg.Observe),
+		tiered:    tl,
+		observe:   tl.observe,
 	})
 }
 
@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/wudi/runway/internal/clock"
+	"github.com/wudi/runway/internal/simulate"
 	"github.com/wudi/runway/variables"
 )
 
@@ -46,19 +50,27 @@ func TestTokenBucketRefill(t *testing.T) {
 	}
 
 	tb := NewTokenBucket(cfg)
+	clk := clock.NewFake(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	tb.clock = clk
 
 	// Use all tokens
 	for i := 0; i < 10; i++ {
 		tb.Allow("test-key")
 	}
 
-	// Wait for some refill
-	time.Sleep(200 * time.Millisecond)
+	// Time stands still: nothing refills
+	if allowed, _, _ := tb.Allow("test-key"); allowed {
+		t.Fatal("should not refill while the clock is frozen")
+	}
 
-	// Should have some tokens now
-	allowed, _, _ := tb.Allow("test-key")
+	// 50ms at 100/s refills 5 tokens
+	clk.Advance(50 * time.Millisecond)
+	allowed, remaining, _ := tb.Allow("test-key")
 	if !allowed {
-		t.Error("should have refilled some tokens")
+		t.Fatal("should have refilled some tokens")
+	}
+	if remaining != 4 {
+		t.Errorf("expected 4 tokens left after refilling 5 and taking 1, got %d", remaining)
 	}
 }
 
@@ -115,8 +127,8 @@ func TestLimiterMiddleware(t *testing.T) {
 		}
 
 		// Check rate limit headers
-		if rr.Header().Get("X-RateLimit-Limit") == "" {
-			t.Error("missing X-RateLimit-Limit header")
+		if rr.Header().Get("RateLimit-Limit") == "" {
+			t.Error("missing RateLimit-Limit header")
 		}
 	}
 
@@ -136,6 +148,74 @@ func TestLimiterMiddleware(t *testing.T) {
 	}
 }
 
+func TestLimiterRetryAfterUsesClock(t *testing.T) {
+	limiter := NewLimiter(Config{Rate: 1, Period: time.Minute, Burst: 1})
+	clk := clock.NewFake(time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC))
+	limiter.tb.clock = clk
+
+	handler := limiter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/test", nil))
+		return rr
+	}
+
+	serve()
+	rr := serve()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	// One token per minute: the wait is measured against the frozen clock,
+	// not the wall clock 25 years later.
+	if got := rr.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("expected Retry-After 60, got %q", got)
+	}
+
+	clk.Advance(time.Minute)
+	if rr := serve(); rr.Code != http.StatusOK {
+		t.Errorf("expected 200 after advancing a minute, got %d", rr.Code)
+	}
+}
+
+func TestLimiterMiddleware_SimulationDoesNotConsume(t *testing.T) {
+	for name, mw := range map[string]func() http.Handler{
+		"token_bucket": func() http.Handler {
+			return NewLimiter(Config{Rate: 1, Period: time.Minute, PerIP: true}).Middleware()(http.NotFoundHandler())
+		},
+		"sliding_window": func() http.Handler {
+			return NewSlidingWindowLimiter(Config{Rate: 1, Period: time.Minute, PerIP: true}).Middleware()(http.NotFoundHandler())
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := mw()
+			serve := func(simulated bool) int {
+				req := httptest.NewRequest("GET", "/api/test", nil)
+				req.RemoteAddr = "192.168.1.1:12345"
+				if simulated {
+					req = req.WithContext(simulate.WithTrace(req.Context(), simulate.NewTrace()))
+				}
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				return rr.Code
+			}
+
+			for i := 0; i < 3; i++ {
+				if code := serve(true); code != http.StatusNotFound {
+					t.Fatalf("simulated request %d: expected to pass, got %d", i, code)
+				}
+			}
+			if code := serve(false); code != http.StatusNotFound {
+				t.Fatalf("expected the first real request to pass, got %d", code)
+			}
+			if code := serve(true); code != http.StatusTooManyRequests {
+				t.Errorf("expected the simulation to see the exhausted limit, got %d", code)
+			}
+		})
+	}
+}
+
 func TestLimiterDifferentIPs(t *testing.T) {
 	cfg := Config{
 		Rate:   2,
@@ -230,6 +310,45 @@ func TestBuildKeyFunc_IP(t *testing.T) {
 	}
 }
 
+func TestBuildIPKeyFunc_IPv6Aggregation(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		prefix     int
+		remoteAddr string
+		want       string
+	}{
+		{"default /56", "ip", 0, "[2001:db8:1:1ab::1]:5678", "2001:db8:1:100::/56"},
+		{"configured /64", "ip", 64, "[2001:db8:1:1ab::1]:5678", "2001:db8:1:1ab::/64"},
+		{"no aggregation", "ip", 128, "[2001:db8:1:1ab::1]:5678", "2001:db8:1:1ab::1"},
+		{"IPv4 unchanged", "ip", 48, "1.2.3.4:5678", "1.2.3.4"},
+		{"IPv4-mapped", "ip", 0, "[::ffff:1.2.3.4]:5678", "1.2.3.4"},
+		{"header fallback", "header:X-Key", 0, "[2001:db8::1]:5678", "2001:db8::/56"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if got := BuildIPKeyFunc(false, tt.key, tt.prefix)(r); got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Rotating addresses of one /56 share a bucket.
+	l := NewLimiter(Config{Rate: 1, Period: time.Minute, Burst: 1, PerIP: true})
+	handler := l.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i, addr := range []string{"[2001:db8:0:10::1]:1", "[2001:db8:0:20::2]:1"} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if want := []int{200, 429}[i]; rec.Code != want {
+			t.Errorf("request %d: status %d, want %d", i, rec.Code, want)
+		}
+	}
+}
+
 func TestBuildKeyFunc_PerIPFlag(t *testing.T) {
 	fn := BuildKeyFunc(true, "")
 	r := httptest.NewRequest("GET", "/", nil)
@@ -282,6 +401,34 @@ func TestBuildKeyFunc_Cookie(t *testing.T) {
 	}
 }
 
+func TestBuildKeyFunc_Baggage(t *testing.T) {
+	fn := BuildKeyFunc(false, "baggage:tenant")
+
+	// The raw inbound header is not trusted
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "1.2.3.4:5678"
+	r.Header.Set("Baggage", "region=eu,tenant=acme%20corp;ttl=5")
+	if got := fn(r); got != "1.2.3.4" {
+		t.Errorf("expected IP fallback for header-only baggage, got %q", got)
+	}
+
+	// Member registered in the variable context by the baggage middleware
+	vc := variables.NewContext(r)
+	vc.SetBaggage("tenant", "globex")
+	r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, vc))
+	if got := fn(r); got != "baggage:tenant:globex" {
+		t.Errorf("expected context baggage key, got %q", got)
+	}
+
+	// Member missing — fallback to IP
+	r2 := httptest.NewRequest("GET", "/", nil)
+	r2.RemoteAddr = "1.2.3.4:5678"
+	r2.Header.Set("Baggage", "region=eu")
+	if got := fn(r2); got != "1.2.3.4" {
+		t.Errorf("expected IP fallback, got %q", got)
+	}
+}
+
 func TestBuildKeyFunc_JWTClaim(t *testing.T) {
 	fn := BuildKeyFunc(false, "jwt_claim:sub")
 
@@ -596,3 +743,102 @@ func BenchmarkTokenBucketAllowParallel(b *testing.B) {
 		}
 	})
 }
+
+func TestTokenBucket_AllowN(t *testing.T) {
+	tb := NewTokenBucket(Config{Rate: 10, Period: time.Hour, Burst: 10})
+
+	allowed, remaining, _ := tb.AllowN("k", 4)
+	if !allowed || remaining != 6 {
+		t.Fatalf("expected allowed with 6 remaining, got %v/%d", allowed, remaining)
+	}
+	allowed, remaining, _ = tb.AllowN("k", 6)
+	if !allowed || remaining != 0 {
+		t.Fatalf("expected allowed with 0 remaining, got %v/%d", allowed, remaining)
+	}
+
+	tb2 := NewTokenBucket(Config{Rate: 10, Period: time.Hour, Burst: 10})
+	tb2.AllowN("k", 7)
+	allowed, remaining, _ = tb2.AllowN("k", 5)
+	if allowed {
+		t.Fatal("cost 5 should be rejected with 3 tokens left")
+	}
+	if remaining != 3 {
+		t.Errorf("expected 3 remaining on rejection, got %d", remaining)
+	}
+	// A rejected request debits nothing.
+	if allowed, _, _ := tb2.AllowN("k", 3); !allowed {
+		t.Error("cost 3 should fit in the remaining budget")
+	}
+}
+
+func TestLimiter_CostBased(t *testing.T) {
+	limiter := NewLimiter(Config{
+		Rate:    10,
+		Period:  time.Hour,
+		Burst:   10,
+		PerIP:   true,
+		Cost:    func(r *http.Request) int { n, _ := strconv.Atoi(r.Header.Get("X-Cost")); return n },
+		MaxCost: 8,
+	})
+	handler := limiter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(cost string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/report", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Cost", cost)
+		varCtx := variables.NewContext(req)
+		req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if got := varCtx.RateLimitCost; rr.Header().Get("X-RateLimit-Cost") != strconv.Itoa(got) {
+			t.Errorf("access log cost %d does not match header %q", got, rr.Header().Get("X-RateLimit-Cost"))
+		}
+		return rr
+	}
+
+	// Clamped from 50 to the max cost of 8.
+	rr := send("50")
+	if rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Cost") != "8" || rr.Header().Get("RateLimit-Remaining") != "2" {
+		t.Fatalf("expected clamped cost 8 with 2 remaining, got %d cost=%q remaining=%q",
+			rr.Code, rr.Header().Get("X-RateLimit-Cost"), rr.Header().Get("RateLimit-Remaining"))
+	}
+	// Costs below 1 are charged as 1.
+	if rr := send("0"); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Cost") != "1" {
+		t.Fatalf("expected cost 1, got %d cost=%q", rr.Code, rr.Header().Get("X-RateLimit-Cost"))
+	}
+
+	rr = send("3")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "request cost 3 exceeds remaining rate limit budget 1") {
+		t.Errorf("429 body should state cost and remaining budget, got %s", body)
+	}
+}
+
+func TestBuildKeyFunc_Body(t *testing.T) {
+	fn := BuildKeyFunc(false, "body:user.id")
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"user":{"id":"u-42"}}`))
+	r.RemoteAddr = "1.2.3.4:5678"
+	r.Header.Set("Content-Type", "application/json")
+	vc := variables.NewContext(r)
+	r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, vc))
+	variables.EnableBodyFields(r, 0)
+	if got := fn(r); got != "body:user.id:u-42" {
+		t.Errorf("expected body key, got %q", got)
+	}
+
+	// Field missing — fallback to IP
+	r2 := httptest.NewRequest("POST", "/", strings.NewReader(`{"user":{}}`))
+	r2.RemoteAddr = "1.2.3.4:5678"
+	r2.Header.Set("Content-Type", "application/json")
+	vc2 := variables.NewContext(r2)
+	r2 = r2.WithContext(context.WithValue(r2.Context(), variables.RequestContextKey{}, vc2))
+	variables.EnableBodyFields(r2, 0)
+	if got := fn(r2); got != "1.2.3.4" {
+		t.Errorf("expected IP fallback, got %q", got)
+	}
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wudi/runway/internal/ipaddr"
+	"github.com/wudi/runway/internal/simulate"
+)
+
+// WouldRejectHeader marks responses to requests an observe-mode limiter
+// would have rejected.
+const WouldRejectHeader = "X-RateLimit-Would-Reject"
+
+const (
+	// ObservedTopKeys is the number of keys reported per observe-mode limiter.
+	ObservedTopKeys = 20
+	// observedKeysTracked bounds the keys counted per observe-mode limiter.
+	observedKeysTracked = 5 * ObservedTopKeys
+)
+
+// Observer counts the requests an observe-mode limiter would have
+// rejected. Keys are counted with the space-saving algorithm: once
+// observedKeysTracked keys are tracked, a new key replaces the least
+// counted one and inherits its count, so heavy hitters are never lost.
+type Observer struct {
+	onReject func(tier string) // called for every would-be rejection
+	total    atomic.Int64
+
+	mu   sync.Mutex
+	keys map[observedKey]int64
+}
+
+type observedKey struct {
+	key  string
+	tier string
+}
+
+// NewObserver creates an Observer. onReject, if set, is called for every
+// request counted.
+func NewObserver(onReject func(tier string)) *Observer {
+	return &Observer{onReject: onReject, keys: make(map[observedKey]int64)}
+}
+
+// newObserver returns an Observer when observe is set, or nil.
+func newObserver(observe bool, onReject func(tier string)) *Observer {
+	if !observe {
+		return nil
+	}
+	return NewObserver(onReject)
+}
+
+// WouldReject tags the response to a request the limiter would have
+// rejected and counts it under key and tier. Simulated requests are only
+// tagged.
+func (o *Observer) WouldReject(w http.ResponseWriter, r *http.Request, key, tier string) {
+	w.Header().Set(WouldRejectHeader, "true")
+	if simulate.Active(r.Context()) {
+		simulate.Note(r.Context(), "observe mode: not rejected")
+		return
+	}
+	o.total.Add(1)
+
+	k := observedKey{key, tier}
+	o.mu.Lock()
+	if _, ok := o.keys[k]; ok || len(o.keys) < observedKeysTracked {
+		o.keys[k]++
+	} else {
+		var minKey observedKey
+		minCount := int64(-1)
+		for ck, c := range o.keys {
+			if minCount < 0 || c < minCount {
+				minKey, minCount = ck, c
+			}
+		}
+		delete(o.keys, minKey)
+		o.keys[k] = minCount + 1
+	}
+	o.mu.Unlock()
+
+	if o.onReject != nil {
+		o.onReject(tier)
+	}
+}
+
+// ObservedRejections reports the requests an observe-mode limiter would
+// have rejected.
+type ObservedRejections struct {
+	Total   int64         `json:"total"`
+	TopKeys []ObservedKey `json:"top_keys"` // most rejected first, at most ObservedTopKeys
+}
+
+// ObservedKey is a key an observe-mode limiter would have limited.
+type ObservedKey struct {
+	Key        string `json:"key"`
+	Aggregated bool   `json:"aggregated,omitempty"` // Key is an IPv6 network, not a single client
+	Tier       string `json:"tier,omitempty"`
+	Count      int64  `json:"count"` // an upper bound once more keys were seen than are tracked
+}
+
+// Stats returns the requests counted so far.
+func (o *Observer) Stats() ObservedRejections {
+	o.mu.Lock()
+	keys := make([]ObservedKey, 0, len(o.keys))
+	for k, c := range o.keys {
+		keys = append(keys, ObservedKey{Key: k.key, Aggregated: ipaddr.IsAggregated(k.key), Tier: k.tier, Count: c})
+	}
+	o.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if len(keys) > ObservedTopKeys {
+		keys = keys[:ObservedTopKeys]
+	}
+	return ObservedRejections{Total: o.total.Load(), TopKeys: keys}
+}
+
+// ObservedRejections returns what a route's observe-mode limiter would have
+// rejected, or false when the route's limiter is not in observe mode.
+func (rl *RateLimitByRoute) ObservedRejections(routeID string) (ObservedRejections, bool) {
+	if v := rl.Lookup(routeID); v != nil && v.observe != nil {
+		return v.observe.Stats(), true
+	}
+	return ObservedRejections{}, false
+}
+
+// CarryObserved copies the buckets of routes limited in observe mode in
+// from into rl, for routes whose limiter parameters are unchanged. Switching
+// a route from observe to enforcement on reload thus starts from the bucket
+// levels real traffic left, not full bursts.
+func (rl *RateLimitByRoute) CarryObserved(from *RateLimitByRoute) int {
+	st := from.SnapshotState(DefaultStateActiveWithin, DefaultStateMaxKeys)
+	for routeID := range st.Routes {
+		if v := from.Lookup(routeID); v == nil || v.observe == nil {
+			delete(st.Routes, routeID)
+		}
+	}
+	return rl.RestoreState(st, DefaultStateMaxKeys)
+}
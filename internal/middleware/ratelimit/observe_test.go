@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/internal/clock"
+)
+
+// trafficPattern sends the same bursty traffic from three clients through
+// the route's limiter, advancing clk between rounds. It returns the number
+// of requests rejected with 429 and the number tagged as would-be rejected.
+func trafficPattern(t *testing.T, rl *RateLimitByRoute, routeID string, clk *clock.Fake) (rejected, tagged int) {
+	t.Helper()
+	h := rl.GetMiddleware(routeID)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for round := range 4 {
+		for i, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+			for range (i + 1) * 3 {
+				req := httptest.NewRequest("GET", "/", nil)
+				req.RemoteAddr = ip + ":1234"
+				req.Header.Set("X-Tier", []string{"free", "pro"}[i%2])
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if rec.Code == http.StatusTooManyRequests {
+					rejected++
+				}
+				if rec.Header().Get(WouldRejectHeader) == "true" {
+					tagged++
+				}
+			}
+		}
+		clk.Advance(time.Duration(round+1) * 700 * time.Millisecond)
+	}
+	return rejected, tagged
+}
+
+func TestObserveModeTokenAccounting(t *testing.T) {
+	start := time.Now()
+	tiers := map[string]Config{
+		"free": {Rate: 2, Period: time.Second, Burst: 3},
+		"pro":  {Rate: 5, Period: time.Second, Burst: 6},
+	}
+	build := func(observe bool) (*RateLimitByRoute, *clock.Fake) {
+		clk := clock.NewFake(start)
+		rl := NewRateLimitByRoute()
+		rl.AddRoute("bucket", Config{Rate: 2, Period: time.Second, Burst: 4, PerIP: true, Observe: observe})
+		rl.AddRouteTiered("tiered", TieredConfig{
+			Tiers: tiers, TierKey: "header:X-Tier", DefaultTier: "free", Observe: observe,
+		})
+		rl.Lookup("bucket").local.tb.clock = clk
+		for _, tb := range rl.Lookup("tiered").tiered.tiers {
+			tb.clock = clk
+		}
+		return rl, clk
+	}
+	enforce, enforceClk := build(false)
+	observe, observeClk := build(true)
+
+	for _, routeID := range []string{"bucket", "tiered"} {
+		rejected, _ := trafficPattern(t, enforce, routeID, enforceClk)
+		notRejected, tagged := trafficPattern(t, observe, routeID, observeClk)
+		if rejected == 0 {
+			t.Fatalf("%s: expected the pattern to exceed the limit", routeID)
+		}
+		if notRejected != 0 {
+			t.Errorf("%s: observe mode rejected %d requests", routeID, notRejected)
+		}
+		if tagged != rejected {
+			t.Errorf("%s: observe mode tagged %d requests, enforce rejected %d", routeID, tagged, rejected)
+		}
+		stats, ok := observe.ObservedRejections(routeID)
+		if !ok || stats.Total != int64(rejected) {
+			t.Errorf("%s: expected %d observed rejections, got %+v", routeID, rejected, stats)
+		}
+	}
+
+	// Both modes must leave every bucket at the same level.
+	now := enforceClk.Now()
+	snapshot := func(rl *RateLimitByRoute) map[string][]BucketState {
+		out := map[string][]BucketState{"bucket": rl.Lookup("bucket").local.tb.snapshot(now, time.Hour, 100)}
+		for name, tb := range rl.Lookup("tiered").tiered.tiers {
+			out[name] = tb.snapshot(now, time.Hour, 100)
+		}
+		return out
+	}
+	if e, o := snapshot(enforce), snapshot(observe); !reflect.DeepEqual(e, o) {
+		t.Errorf("bucket state differs:\nenforce %+v\nobserve %+v", e, o)
+	}
+
+	if _, ok := enforce.ObservedRejections("bucket"); ok {
+		t.Error("expected no observed rejections for an enforcing limiter")
+	}
+	if mode, _ := observe.LimiterInfo("tiered"); mode != "observe" {
+		t.Errorf("expected mode observe, got %q", mode)
+	}
+}
+
+func TestObserverTopKeys(t *testing.T) {
+	var calls []string
+	o := NewObserver(func(tier string) { calls = append(calls, tier) })
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+
+	// A heavy hitter seen early must survive a flood of one-off keys.
+	for range 50 {
+		o.WouldReject(rec, req, "heavy", "pro")
+	}
+	for i := range 3 * observedKeysTracked {
+		o.WouldReject(rec, req, fmt.Sprintf("key-%d", i), "")
+	}
+
+	st := o.Stats()
+	if want := int64(50 + 3*observedKeysTracked); st.Total != want {
+		t.Errorf("expected total %d, got %d", want, st.Total)
+	}
+	if len(st.TopKeys) != ObservedTopKeys {
+		t.Errorf("expected %d top keys, got %d", ObservedTopKeys, len(st.TopKeys))
+	}
+	if top := st.TopKeys[0]; top.Key != "heavy" || top.Tier != "pro" || top.Count < 50 {
+		t.Errorf("expected the heavy hitter first, got %+v", top)
+	}
+	if len(o.keys) > observedKeysTracked {
+		t.Errorf("expected at most %d tracked keys, got %d", observedKeysTracked, len(o.keys))
+	}
+	if len(calls) != int(st.Total) || calls[0] != "pro" {
+		t.Errorf("expected onReject for every rejection, got %d calls", len(calls))
+	}
+}
+
+func TestCarryObserved(t *testing.T) {
+	cfg := Config{Rate: 10, Period: time.Minute, Burst: 5, PerIP: true}
+	observeCfg := cfg
+	observeCfg.Observe = true
+
+	before := NewRateLimitByRoute()
+	before.AddRoute("observed", observeCfg)
+	before.AddRoute("enforced", cfg)
+	sendN(t, before, "observed", "10.0.0.1", 8)
+	sendN(t, before, "enforced", "10.0.0.1", 5)
+
+	// Reload switching the observed route to enforcement.
+	after := NewRateLimitByRoute()
+	after.AddRoute("observed", cfg)
+	after.AddRoute("enforced", cfg)
+	if n := after.CarryObserved(before); n != 1 {
+		t.Fatalf("expected 1 bucket carried, got %d", n)
+	}
+	if got := sendN(t, after, "observed", "10.0.0.1", 5); got != 0 {
+		t.Errorf("expected enforcement to start from the spent bucket, got %d allowed", got)
+	}
+	if got := sendN(t, after, "enforced", "10.0.0.1", 5); got != 5 {
+		t.Errorf("expected enforcing routes to be left to saved state, got %d allowed", got)
+	}
+}
@@ -3,55 +3,111 @@ package ratelimit
 import (
 	"context"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
-	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/clock"
+	"github.com/wudi/runway/internal/decision"
 	"github.com/wudi/runway/internal/logging"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/simulate"
 	"go.uber.org/zap"
 )
 
-// slidingWindowScript implements a sliding window rate limiter using Redis sorted sets.
+// slidingWindowScript implements a sliding window rate limiter using a Redis
+// sorted set with one entry per request, whose member ends in the request's
+// cost, and a counter (KEYS[2]) holding the summed cost of the window. A
+// request costing N still adds a single entry.
 // Returns: [allowed (0/1), remaining, resetTimestamp]
 var slidingWindowScript = redis.NewScript(`
 local key = KEYS[1]
+local total_key = KEYS[2]
 local now = tonumber(ARGV[1])
 local window = tonumber(ARGV[2])
 local limit = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+-- Remove entries outside the window, releasing their cost
+local count = tonumber(redis.call('GET', total_key) or '0')
+local expired = redis.call('ZRANGEBYSCORE', key, 0, now - window)
+if #expired > 0 then
+    for _, member in ipairs(expired) do
+        count = count - (tonumber(string.match(member, ':(%d+)$')) or 1)
+    end
+    redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+end
+if count < 0 or redis.call('ZCARD', key) == 0 then
+    count = 0
+end
 
--- Remove entries outside the window
-redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
-
--- Count current entries
-local count = redis.call('ZCARD', key)
-
-if count < limit then
-    -- Add the current request
-    redis.call('ZADD', key, now, now .. '-' .. math.random(1000000))
+if count + cost <= limit then
+    -- Add the current request as one entry carrying its cost
+    local member = now .. '-' .. math.random(1000000) .. ':' .. cost
+    redis.call('ZADD', key, now, member)
     redis.call('PEXPIRE', key, window)
-    return {1, limit - count - 1, now + window}
+    redis.call('SET', total_key, count + cost, 'PX', window)
+    return {1, limit - count - cost, now + window}
 else
     -- Rejected
+    if #expired > 0 then
+        redis.call('SET', total_key, count, 'PX', window)
+    end
     local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
     local reset = now + window
     if #oldest >= 2 then
         reset = tonumber(oldest[2]) + window
     end
-    return {0, 0, reset}
+    local remaining = limit - count
+    if remaining < 0 then
+        remaining = 0
+    end
+    return {0, remaining, reset}
+end
+`)
+
+// peekScript evaluates slidingWindowScript's decision without adding or
+// removing entries, for simulated requests.
+var peekScript = redis.NewScript(`
+local key = KEYS[1]
+local total_key = KEYS[2]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+local count = tonumber(redis.call('GET', total_key) or '0')
+for _, member in ipairs(redis.call('ZRANGEBYSCORE', key, 0, now - window)) do
+    count = count - (tonumber(string.match(member, ':(%d+)$')) or 1)
 end
+if count < 0 or redis.call('ZCOUNT', key, '(' .. (now - window), '+inf') == 0 then
+    count = 0
+end
+if count + cost <= limit then
+    return {1, limit - count - cost, now + window}
+end
+local remaining = limit - count
+if remaining < 0 then
+    remaining = 0
+end
+return {0, remaining, now + window}
 `)
 
+// costKeySuffix names the counter holding the summed cost of a window's
+// entries, stored next to its sorted set.
+const costKeySuffix = ":cost"
+
 // RedisLimiter provides Redis-backed distributed rate limiting.
 type RedisLimiter struct {
-	client *redis.Client
-	prefix string
-	rate   int
-	window time.Duration
-	burst  int
-	perIP  bool
-	keyFn  func(*http.Request) string
+	client  *redis.Client
+	prefix  string
+	rate    int
+	window  time.Duration
+	burst   int
+	perIP   bool
+	keyFn   func(*http.Request) string
+	cost    costLimit
+	clock   clock.Clock
+	headers HeaderConfig
 }
 
 // RedisLimiterConfig holds config for creating a RedisLimiter.
@@ -63,6 +119,13 @@ type RedisLimiterConfig struct {
 	Burst  int
 	PerIP  bool
 	Key    string
+
+	IPv6Prefix int // as Config.IPv6Prefix
+
+	Cost    func(*http.Request) int // per-request token cost (nil debits 1)
+	MaxCost int                     // cost cap (default burst)
+
+	Headers HeaderConfig // quota headers written on every response
 }
 
 // NewRedisLimiter creates a new Redis-backed rate limiter.
@@ -77,13 +140,16 @@ func NewRedisLimiter(cfg RedisLimiterConfig) *RedisLimiter {
 		cfg.Prefix = "gw:rl:"
 	}
 	return &RedisLimiter{
-		client: cfg.Client,
-		prefix: cfg.Prefix,
-		rate:   cfg.Burst, // burst is the window limit
-		window: cfg.Period,
-		burst:  cfg.Burst,
-		perIP:  cfg.PerIP,
-		keyFn:  BuildKeyFunc(cfg.PerIP, cfg.Key),
+		client:  cfg.Client,
+		prefix:  cfg.Prefix,
+		rate:    cfg.Burst, // burst is the window limit
+		window:  cfg.Period,
+		burst:   cfg.Burst,
+		perIP:   cfg.PerIP,
+		keyFn:   BuildIPKeyFunc(cfg.PerIP, cfg.Key, cfg.IPv6Prefix),
+		cost:    newCostLimit(cfg.Cost, cfg.MaxCost),
+		clock:   clock.Default(),
+		headers: cfg.Headers,
 	}
 }
 
@@ -91,44 +157,26 @@ func NewRedisLimiter(cfg RedisLimiterConfig) *RedisLimiter {
 func (rl *RedisLimiter) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			key := rl.prefix + rl.keyFn(r)
-
-			ctx, cancel := context.WithTimeout(r.Context(), 100*time.Millisecond)
-			defer cancel()
-
-			nowMs := time.Now().UnixMilli()
-			windowMs := rl.window.Milliseconds()
-
-			result, err := slidingWindowScript.Run(ctx, rl.client,
-				[]string{key},
-				nowMs,
-				windowMs,
-				rl.rate,
-			).Int64Slice()
-
+			d, err := rl.decide(r, !simulate.Active(r.Context()))
 			if err != nil {
 				// Fail open: if Redis is unreachable, allow the request
 				logging.Warn("Redis rate limit unavailable, failing open", zap.Error(err))
+				simulate.Note(r.Context(), "redis unavailable, failing open: %v", err)
+				if rec := decision.FromContext(r.Context()); rec != nil {
+					rec.Add("rate_limit", decision.Allow, "error", "redis unavailable")
+				}
 				next.ServeHTTP(w, r)
 				return
 			}
+			noteSimulated(r, d)
+			recordDecision(r, d, false)
 
-			allowed := result[0] == 1
-			remaining := int(result[1])
-			resetMs := result[2]
-			resetTime := time.UnixMilli(resetMs)
-
-			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rl.burst))
-			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
-			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetTime.Unix(), 10))
+			now := rl.clock.Now()
+			rl.headers.write(w, d, now)
+			rl.cost.record(w, r, d.Cost)
 
-			if !allowed {
-				retryAfter := int(time.Until(resetTime).Seconds())
-				if retryAfter < 1 {
-					retryAfter = 1
-				}
-				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
-				errors.ErrTooManyRequests.WriteJSON(w)
+			if !d.Allowed {
+				rl.cost.reject(w, r, d.Reset.Sub(now), d.Cost, d.Remaining)
 				return
 			}
 
@@ -136,3 +184,47 @@ func (rl *RedisLimiter) Middleware() middleware.Middleware {
 		})
 	}
 }
+
+// decide counts the request in its client's window in Redis. Without
+// consume the window is only read.
+func (rl *RedisLimiter) decide(r *http.Request, consume bool) (Decision, error) {
+	key := rl.prefix + rl.keyFn(r)
+	cost := rl.cost.cost(r, rl.rate)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	nowMs := rl.clock.Now().UnixMilli()
+	windowMs := rl.window.Milliseconds()
+
+	script := slidingWindowScript
+	if !consume {
+		script = peekScript
+	}
+	result, err := script.Run(ctx, rl.client,
+		[]string{key, key + costKeySuffix},
+		nowMs,
+		windowMs,
+		rl.rate,
+		cost,
+	).Int64Slice()
+	if err != nil {
+		return Decision{Allowed: true, Limit: rl.burst, Cost: cost, Key: key}, err
+	}
+
+	return Decision{
+		Allowed:   result[0] == 1,
+		Limit:     rl.burst,
+		Remaining: int(result[1]),
+		Reset:     time.UnixMilli(result[2]),
+		Cost:      cost,
+		Key:       key,
+	}, nil
+}
+
+// Decide counts the request and reports the quota left. When Redis is
+// unreachable it returns the error with an allowing Decision, so callers
+// can fail open like the middleware does.
+func (rl *RedisLimiter) Decide(r *http.Request) (Decision, error) {
+	return rl.decide(r, true)
+}
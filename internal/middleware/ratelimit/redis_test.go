@@ -1,8 +1,10 @@
 package ratelimit
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
@@ -134,3 +136,65 @@ func TestSlidingWindowScript(t *testing.T) {
 		t.Fatal("slidingWindowScript is nil")
 	}
 }
+
+func TestRedisLimiter_CostBased(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr:        "localhost:6379",
+		DialTimeout: 100 * time.Millisecond,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	prefix := "test:rl:cost:" + strconv.FormatInt(time.Now().UnixNano(), 10) + ":"
+	t.Cleanup(func() {
+		keys, _ := client.Keys(context.Background(), prefix+"*").Result()
+		if len(keys) > 0 {
+			client.Del(context.Background(), keys...)
+		}
+	})
+
+	rl := NewRedisLimiter(RedisLimiterConfig{
+		Client: client,
+		Prefix: prefix,
+		Rate:   10,
+		Period: time.Minute,
+		PerIP:  true,
+		Cost:   func(*http.Request) int { return 4 },
+	})
+	handler := rl.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var codes []int
+	var remaining []string
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		codes = append(codes, w.Code)
+		remaining = append(remaining, w.Header().Get("RateLimit-Remaining"))
+		if w.Header().Get("X-RateLimit-Cost") != "4" {
+			t.Errorf("request %d: expected X-RateLimit-Cost 4, got %q", i, w.Header().Get("X-RateLimit-Cost"))
+		}
+	}
+	if codes[0] != 200 || codes[1] != 200 || codes[2] != 429 {
+		t.Fatalf("expected 200, 200, 429, got %v", codes)
+	}
+	if remaining[0] != "6" || remaining[1] != "2" || remaining[2] != "2" {
+		t.Errorf("expected remaining 6, 2, 2, got %v", remaining)
+	}
+
+	// Each admitted request is one entry regardless of its cost.
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	key := prefix + rl.keyFn(r)
+	if n, err := client.ZCard(context.Background(), key).Result(); err != nil || n != 2 {
+		t.Errorf("expected 2 window entries, got %d (%v)", n, err)
+	}
+	if total, err := client.Get(context.Background(), key+costKeySuffix).Int(); err != nil || total != 8 {
+		t.Errorf("expected window cost 8, got %d (%v)", total, err)
+	}
+}
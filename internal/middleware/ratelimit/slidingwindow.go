@@ -2,11 +2,11 @@ package ratelimit
 
 import (
 	"net/http"
-	"strconv"
 	"time"
 
-	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/clock"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/simulate"
 )
 
 // window tracks counts for two adjacent fixed windows.
@@ -25,6 +25,7 @@ type SlidingWindowCounter struct {
 	period     time.Duration
 	windows    *shardedMap[*window]
 	cleanupInt time.Duration
+	clock      clock.Clock
 }
 
 // NewSlidingWindowCounter creates a new sliding window counter rate limiter.
@@ -42,6 +43,7 @@ func NewSlidingWindowCounter(cfg Config) *SlidingWindowCounter {
 		period:     cfg.Period,
 		windows:    newShardedMap[*window](),
 		cleanupInt: 5 * time.Minute,
+		clock:      clock.Default(),
 	}
 
 	go sw.cleanup()
@@ -51,7 +53,19 @@ func NewSlidingWindowCounter(cfg Config) *SlidingWindowCounter {
 
 // Allow checks if a request should be allowed using sliding window interpolation.
 func (sw *SlidingWindowCounter) Allow(key string) (allowed bool, remaining int, resetTime time.Time) {
-	now := time.Now()
+	return sw.AllowN(key, 1)
+}
+
+// AllowN checks if a request costing n should be allowed and counts it n
+// times if so. On rejection remaining is the estimated budget left.
+func (sw *SlidingWindowCounter) AllowN(key string, n int) (allowed bool, remaining int, resetTime time.Time) {
+	return sw.takeN(key, n, true)
+}
+
+// takeN is AllowN; without consume the window is left as it was, for
+// simulated requests.
+func (sw *SlidingWindowCounter) takeN(key string, n int, consume bool) (allowed bool, remaining int, resetTime time.Time) {
+	now := sw.clock.Now()
 	resetTime = now.Add(sw.period)
 
 	s := sw.windows.getShard(key)
@@ -62,7 +76,13 @@ func (sw *SlidingWindowCounter) Allow(key string) (allowed bool, remaining int,
 		w = &window{
 			currStart: now.Truncate(sw.period),
 		}
-		s.items[key] = w
+		if consume {
+			s.items[key] = w
+		}
+	}
+	if !consume {
+		cp := *w
+		w = &cp
 	}
 
 	// Rotate windows if we've moved past the current window
@@ -85,10 +105,10 @@ func (sw *SlidingWindowCounter) Allow(key string) (allowed bool, remaining int,
 	// Reset time is the end of the current window
 	resetTime = w.currStart.Add(sw.period)
 
-	if estimate < float64(sw.rate) {
-		w.currCount++
+	if estimate+float64(n-1) < float64(sw.rate) {
+		w.currCount += n
 		w.lastUsed = now
-		rem := float64(sw.rate) - estimate - 1
+		rem := float64(sw.rate) - estimate - float64(n)
 		if rem < 0 {
 			rem = 0
 		}
@@ -98,7 +118,11 @@ func (sw *SlidingWindowCounter) Allow(key string) (allowed bool, remaining int,
 
 	w.lastUsed = now
 	s.mu.Unlock()
-	return false, 0, resetTime
+	rem := float64(sw.rate) - estimate
+	if rem < 0 {
+		rem = 0
+	}
+	return false, int(rem), resetTime
 }
 
 // cleanup removes stale windows periodically.
@@ -107,7 +131,7 @@ func (sw *SlidingWindowCounter) cleanup() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		now := time.Now()
+		now := sw.clock.Now()
 		cutoff := 2 * sw.period
 		sw.windows.deleteFunc(func(_ string, w *window) bool {
 			return now.Sub(w.lastUsed) > cutoff
@@ -117,17 +141,23 @@ func (sw *SlidingWindowCounter) cleanup() {
 
 // SlidingWindowLimiter provides sliding window rate limiting middleware.
 type SlidingWindowLimiter struct {
-	sw    *SlidingWindowCounter
-	perIP bool
-	keyFn func(*http.Request) string
+	sw      *SlidingWindowCounter
+	perIP   bool
+	keyFn   func(*http.Request) string
+	cost    costLimit
+	observe *Observer // set in observe mode
+	headers HeaderConfig
 }
 
 // NewSlidingWindowLimiter creates a new sliding window rate limiter.
 func NewSlidingWindowLimiter(cfg Config) *SlidingWindowLimiter {
 	return &SlidingWindowLimiter{
-		sw:    NewSlidingWindowCounter(cfg),
-		perIP: cfg.PerIP,
-		keyFn: BuildKeyFunc(cfg.PerIP, cfg.Key),
+		sw:      NewSlidingWindowCounter(cfg),
+		perIP:   cfg.PerIP,
+		keyFn:   BuildIPKeyFunc(cfg.PerIP, cfg.Key, cfg.IPv6Prefix),
+		cost:    newCostLimit(cfg.Cost, cfg.MaxCost),
+		observe: newObserver(cfg.Observe, cfg.OnObservedReject),
+		headers: cfg.Headers,
 	}
 }
 
@@ -135,22 +165,20 @@ func NewSlidingWindowLimiter(cfg Config) *SlidingWindowLimiter {
 func (l *SlidingWindowLimiter) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			key := l.keyFn(r)
-
-			allowed, remaining, resetTime := l.sw.Allow(key)
-
-			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(l.sw.rate))
-			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
-			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetTime.Unix(), 10))
-
-			if !allowed {
-				retryAfter := int(time.Until(resetTime).Seconds())
-				if retryAfter < 1 {
-					retryAfter = 1
+			d := l.decide(r, !simulate.Active(r.Context()))
+			noteSimulated(r, d)
+			recordDecision(r, d, l.observe != nil)
+
+			now := l.sw.clock.Now()
+			l.headers.write(w, d, now)
+			l.cost.record(w, r, d.Cost)
+
+			if !d.Allowed {
+				if l.observe == nil {
+					l.cost.reject(w, r, d.Reset.Sub(now), d.Cost, d.Remaining)
+					return
 				}
-				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
-				errors.ErrTooManyRequests.WriteJSON(w)
-				return
+				l.observe.WouldReject(w, r, d.Key, "")
 			}
 
 			next.ServeHTTP(w, r)
@@ -158,11 +186,30 @@ func (l *SlidingWindowLimiter) Middleware() middleware.Middleware {
 	}
 }
 
+// decide counts the request in its client's window. Without consume the
+// window is left as it was.
+func (l *SlidingWindowLimiter) decide(r *http.Request, consume bool) Decision {
+	key := l.keyFn(r)
+	cost := l.cost.cost(r, l.sw.rate)
+	allowed, remaining, resetTime := l.sw.takeN(key, cost, consume)
+	return Decision{
+		Allowed:   allowed,
+		Limit:     l.sw.rate,
+		Remaining: remaining,
+		Reset:     resetTime,
+		Cost:      cost,
+		Key:       key,
+	}
+}
+
 // Allow checks if a request is allowed (for manual checking).
 func (l *SlidingWindowLimiter) Allow(r *http.Request) bool {
-	key := l.keyFn(r)
-	allowed, _, _ := l.sw.Allow(key)
-	return allowed
+	return l.decide(r, true).Allowed
+}
+
+// Decide is Allow reporting the quota left. It never fails.
+func (l *SlidingWindowLimiter) Decide(r *http.Request) (Decision, error) {
+	return l.decide(r, true), nil
 }
 
 // ensure SlidingWindowLimiter implements RateLimitMiddleware
@@ -6,6 +6,8 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/wudi/runway/internal/clock"
 )
 
 func TestSlidingWindowCounter_BasicAllowDeny(t *testing.T) {
@@ -45,6 +47,8 @@ func TestSlidingWindowCounter_WindowRotation(t *testing.T) {
 	}
 
 	sw := NewSlidingWindowCounter(cfg)
+	clk := clock.NewFake(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	sw.clock = clk
 
 	// Use all 10 requests in the first window
 	for i := 0; i < 10; i++ {
@@ -60,16 +64,18 @@ func TestSlidingWindowCounter_WindowRotation(t *testing.T) {
 		t.Error("should be denied after using all requests")
 	}
 
-	// Wait for window to rotate (prev count will still influence)
-	time.Sleep(110 * time.Millisecond)
+	// Enter the next window (prev count will still influence)
+	clk.Advance(100 * time.Millisecond)
 
-	// After one period, prev=10, curr=0
-	// weight ≈ 1.0 (just entered new window), estimate ≈ 10 * 1.0 = 10
-	// At the very start of the new window, we'd still be limited.
-	// Wait a bit more so the weight drops enough
-	time.Sleep(60 * time.Millisecond)
+	// At the very start of the new window, prev=10, curr=0, weight = 1.0,
+	// estimate = 10, so we're still limited.
+	allowed, _, _ = sw.Allow("test-key")
+	if allowed {
+		t.Error("should be denied at the start of the next window")
+	}
 
-	// Now weight should be about 0.4, estimate ≈ 10 * 0.4 = 4, which is < 10
+	// 60ms in, weight = 0.4, estimate = 10 * 0.4 = 4, which is < 10
+	clk.Advance(60 * time.Millisecond)
 	allowed, _, _ = sw.Allow("test-key")
 	if !allowed {
 		t.Error("should be allowed after window rotation with reduced weight")
@@ -83,14 +89,16 @@ func TestSlidingWindowCounter_FullRecovery(t *testing.T) {
 	}
 
 	sw := NewSlidingWindowCounter(cfg)
+	clk := clock.NewFake(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	sw.clock = clk
 
 	// Use all requests
 	for i := 0; i < 5; i++ {
 		sw.Allow("test-key")
 	}
 
-	// Wait for 2 full periods so prev window has zero impact
-	time.Sleep(110 * time.Millisecond)
+	// Advance 2 full periods so prev window has zero impact
+	clk.Advance(100 * time.Millisecond)
 
 	// Should be fully recovered
 	for i := 0; i < 5; i++ {
@@ -151,14 +159,14 @@ func TestSlidingWindowLimiter_Middleware(t *testing.T) {
 			t.Errorf("request %d: expected 200, got %d", i, rr.Code)
 		}
 
-		if rr.Header().Get("X-RateLimit-Limit") == "" {
-			t.Error("missing X-RateLimit-Limit header")
+		if rr.Header().Get("RateLimit-Limit") == "" {
+			t.Error("missing RateLimit-Limit header")
 		}
-		if rr.Header().Get("X-RateLimit-Remaining") == "" {
-			t.Error("missing X-RateLimit-Remaining header")
+		if rr.Header().Get("RateLimit-Remaining") == "" {
+			t.Error("missing RateLimit-Remaining header")
 		}
-		if rr.Header().Get("X-RateLimit-Reset") == "" {
-			t.Error("missing X-RateLimit-Reset header")
+		if rr.Header().Get("RateLimit-Reset") == "" {
+			t.Error("missing RateLimit-Reset header")
 		}
 	}
 
@@ -402,3 +410,22 @@ func BenchmarkSlidingWindowCounterAllowParallel(b *testing.B) {
 		}
 	})
 }
+
+func TestSlidingWindowCounter_AllowN(t *testing.T) {
+	sw := NewSlidingWindowCounter(Config{Rate: 10, Period: time.Hour})
+
+	allowed, remaining, _ := sw.AllowN("k", 7)
+	if !allowed || remaining != 3 {
+		t.Fatalf("expected allowed with 3 remaining, got %v/%d", allowed, remaining)
+	}
+	allowed, remaining, _ = sw.AllowN("k", 4)
+	if allowed || remaining != 3 {
+		t.Fatalf("expected rejection with 3 remaining, got %v/%d", allowed, remaining)
+	}
+	if allowed, remaining, _ = sw.AllowN("k", 3); !allowed || remaining != 0 {
+		t.Fatalf("expected cost 3 to use the rest of the window, got %v/%d", allowed, remaining)
+	}
+	if allowed, _, _ = sw.Allow("k"); allowed {
+		t.Error("window should be exhausted")
+	}
+}
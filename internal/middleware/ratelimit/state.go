@@ -0,0 +1,438 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wudi/runway/internal/clock"
+)
+
+// Defaults applied by NewPersister when a field is zero.
+const (
+	DefaultStateSaveInterval = 30 * time.Second
+	DefaultStateActiveWithin = 10 * time.Minute
+	DefaultStateMaxKeys      = 10000
+)
+
+// BucketState is a saved token bucket.
+type BucketState struct {
+	Key    string    `json:"key"`
+	Tier   string    `json:"tier,omitempty"` // set for tiered limiters
+	Tokens float64   `json:"tokens"`
+	Last   time.Time `json:"last"` // last refill
+}
+
+// RouteState holds a route's saved buckets. Fingerprint identifies the
+// limiter config they were saved under; they are only restored into a
+// limiter with the same fingerprint.
+type RouteState struct {
+	Fingerprint string        `json:"fingerprint"`
+	Buckets     []BucketState `json:"buckets"`
+}
+
+// State is the saved bucket state of every local token bucket and tiered
+// limiter.
+type State struct {
+	SavedAt time.Time             `json:"saved_at"`
+	Routes  map[string]RouteState `json:"routes"`
+}
+
+// horizon is how long an empty bucket takes to refill. A bucket last
+// refilled longer ago than that is full, so saving it is pointless.
+func (tb *TokenBucket) horizon() time.Duration {
+	return time.Duration(float64(tb.burst) / tb.rate * float64(time.Second))
+}
+
+// snapshot returns the buckets refilled within activeWithin that are not
+// full yet, most recently used first, at most limit of them.
+func (tb *TokenBucket) snapshot(now time.Time, activeWithin time.Duration, limit int) []BucketState {
+	if tb.rate <= 0 {
+		return nil
+	}
+	cutoff := min(activeWithin, tb.horizon())
+	var out []BucketState
+	for i := range tb.buckets.shards {
+		s := &tb.buckets.shards[i]
+		s.mu.Lock()
+		for k, b := range s.items {
+			if now.Sub(b.lastTime) < cutoff {
+				out = append(out, BucketState{Key: k, Tokens: b.tokens, Last: b.lastTime})
+			}
+		}
+		s.mu.Unlock()
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Last.After(out[j].Last) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// restore seeds buckets from saved states. States that would have refilled
+// by now and keys that already have a bucket are skipped. It returns the
+// number of buckets restored.
+func (tb *TokenBucket) restore(now time.Time, states []BucketState) int {
+	if tb.rate <= 0 {
+		return 0
+	}
+	horizon := tb.horizon()
+	n := 0
+	for _, st := range states {
+		if now.Sub(st.Last) >= horizon {
+			continue
+		}
+		b := &bucket{
+			tokens:    min(max(st.Tokens, 0), float64(tb.burst)),
+			lastTime:  st.Last,
+			maxTokens: tb.burst,
+		}
+		s := tb.buckets.getShard(st.Key)
+		s.mu.Lock()
+		if _, exists := s.items[st.Key]; !exists {
+			s.items[st.Key] = b
+			n++
+		}
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// fingerprint identifies the bucket parameters, so buckets saved under
+// different limits are not restored.
+func (tb *TokenBucket) fingerprint() string {
+	return fmt.Sprintf("%g/%d", tb.rate, tb.burst)
+}
+
+// fingerprint identifies the limiter config of a route's variant, or "" for
+// variants whose state is not saved.
+func (v *rateLimiterVariant) fingerprint() string {
+	switch {
+	case v.tiered != nil:
+		names := make([]string, 0, len(v.tiered.tiers))
+		for name := range v.tiered.tiers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var sb strings.Builder
+		sb.WriteString("tiered")
+		for _, name := range names {
+			sb.WriteString(";" + name + "=" + v.tiered.tiers[name].fingerprint())
+		}
+		return sb.String()
+	case v.local != nil:
+		return fmt.Sprintf("token_bucket:%s:%t:%s", v.local.tb.fingerprint(), v.local.perIP, v.key)
+	}
+	return ""
+}
+
+// SnapshotState returns the buckets of every local token bucket and tiered
+// limiter that were used within activeWithin, at most maxKeys per route.
+func (rl *RateLimitByRoute) SnapshotState(activeWithin time.Duration, maxKeys int) *State {
+	now := clock.Default().Now()
+	st := &State{SavedAt: now, Routes: make(map[string]RouteState)}
+	rl.Range(func(routeID string, v *rateLimiterVariant) bool {
+		fp := v.fingerprint()
+		if fp == "" {
+			return true
+		}
+		var buckets []BucketState
+		if v.tiered != nil {
+			for name, tb := range v.tiered.tiers {
+				for _, b := range tb.snapshot(now, activeWithin, maxKeys) {
+					b.Tier = name
+					buckets = append(buckets, b)
+				}
+			}
+			sort.Slice(buckets, func(i, j int) bool { return buckets[i].Last.After(buckets[j].Last) })
+			if len(buckets) > maxKeys {
+				buckets = buckets[:maxKeys]
+			}
+		} else {
+			buckets = v.local.tb.snapshot(now, activeWithin, maxKeys)
+		}
+		if len(buckets) > 0 {
+			st.Routes[routeID] = RouteState{Fingerprint: fp, Buckets: buckets}
+		}
+		return true
+	})
+	return st
+}
+
+// RestoreState seeds the buckets of routes whose limiter config matches the
+// saved fingerprint, at most maxKeys per route. It returns the total number
+// of buckets restored.
+func (rl *RateLimitByRoute) RestoreState(st *State, maxKeys int) int {
+	if st == nil {
+		return 0
+	}
+	now := clock.Default().Now()
+	total := 0
+	rl.Range(func(routeID string, v *rateLimiterVariant) bool {
+		rs, ok := st.Routes[routeID]
+		if !ok || rs.Fingerprint != v.fingerprint() {
+			return true
+		}
+		buckets := rs.Buckets
+		if len(buckets) > maxKeys {
+			buckets = buckets[:maxKeys]
+		}
+		n := 0
+		if v.tiered != nil {
+			byTier := make(map[string][]BucketState)
+			for _, b := range buckets {
+				byTier[b.Tier] = append(byTier[b.Tier], b)
+			}
+			for name, states := range byTier {
+				if tb := v.tiered.tiers[name]; tb != nil {
+					n += tb.restore(now, states)
+				}
+			}
+		} else {
+			n = v.local.tb.restore(now, buckets)
+		}
+		v.restored.Add(int64(n))
+		total += n
+		return true
+	})
+	return total
+}
+
+// RestoredBuckets returns the number of buckets restored into a route's
+// limiter from saved state.
+func (rl *RateLimitByRoute) RestoredBuckets(routeID string) int64 {
+	if v := rl.Lookup(routeID); v != nil {
+		return v.restored.Load()
+	}
+	return 0
+}
+
+// StateStore persists saved bucket state.
+type StateStore interface {
+	// Load returns the saved state, or nil when nothing was saved.
+	Load(ctx context.Context) (*State, error)
+	Save(ctx context.Context, st *State) error
+}
+
+// FileStateStore keeps the state in a JSON file.
+type FileStateStore struct {
+	path string
+}
+
+// NewFileStateStore creates a store writing to path, creating its directory
+// if needed.
+func NewFileStateStore(path string) (*FileStateStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create rate limit state dir: %w", err)
+	}
+	return &FileStateStore{path: path}, nil
+}
+
+// Load reads the state file.
+func (s *FileStateStore) Load(_ context.Context) (*State, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("decode rate limit state: %w", err)
+	}
+	return &st, nil
+}
+
+// Save writes the state file atomically.
+func (s *FileStateStore) Save(_ context.Context, st *State) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".ratelimit-state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// RedisStateStore keeps the state in one Redis key. Buckets are local to an
+// instance, so each instance needs its own key.
+type RedisStateStore struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisStateStore creates a store under key.
+func NewRedisStateStore(client *redis.Client, key string) *RedisStateStore {
+	return &RedisStateStore{client: client, key: key}
+}
+
+// Load reads the state from Redis.
+func (s *RedisStateStore) Load(ctx context.Context) (*State, error) {
+	data, err := s.client.Get(ctx, s.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("decode rate limit state: %w", err)
+	}
+	return &st, nil
+}
+
+// Save writes the state to Redis.
+func (s *RedisStateStore) Save(ctx context.Context, st *State) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.key, data, 0).Err()
+}
+
+// PersisterConfig holds persister configuration.
+type PersisterConfig struct {
+	Store        StateStore
+	Interval     time.Duration // periodic save; skipped when no bucket changed
+	ActiveWithin time.Duration // save buckets used within this window
+	MaxKeys      int           // buckets saved and restored per route
+	// Limiters returns the running rate limiters; it is called on every save.
+	Limiters func() *RateLimitByRoute
+	// OnError is called when a periodic or final save fails.
+	OnError func(error)
+}
+
+// Persister saves the running limiters' buckets periodically and on Stop,
+// and restores them into new limiters.
+type Persister struct {
+	cfg PersisterConfig
+
+	mu         sync.Mutex // serializes saves
+	lastActive time.Time  // newest bucket refill in the last save
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// NewPersister creates a persister. Call Start to begin periodic saves.
+func NewPersister(cfg PersisterConfig) *Persister {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultStateSaveInterval
+	}
+	if cfg.ActiveWithin <= 0 {
+		cfg.ActiveWithin = DefaultStateActiveWithin
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = DefaultStateMaxKeys
+	}
+	return &Persister{
+		cfg:    cfg,
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+}
+
+// Start saves the buckets every Interval until Stop.
+func (p *Persister) Start() {
+	go p.loop()
+}
+
+func (p *Persister) loop() {
+	defer close(p.doneCh)
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.save(context.Background(), false); err != nil {
+				p.onError(err)
+			}
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops periodic saves and saves the buckets once more. It must only
+// be called after Start.
+func (p *Persister) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+		<-p.doneCh
+		if err := p.Save(context.Background()); err != nil {
+			p.onError(err)
+		}
+	})
+}
+
+func (p *Persister) onError(err error) {
+	if p.cfg.OnError != nil {
+		p.cfg.OnError(err)
+	}
+}
+
+// Save saves the running limiters' buckets.
+func (p *Persister) Save(ctx context.Context) error {
+	return p.save(ctx, true)
+}
+
+// save writes a snapshot of the running limiters. Unless force is set, the
+// write is skipped when no bucket was used since the last save.
+func (p *Persister) save(ctx context.Context, force bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := p.cfg.Limiters().SnapshotState(p.cfg.ActiveWithin, p.cfg.MaxKeys)
+	var newest time.Time
+	for _, rs := range st.Routes {
+		for _, b := range rs.Buckets {
+			if b.Last.After(newest) {
+				newest = b.Last
+			}
+		}
+	}
+	if !force && !newest.After(p.lastActive) {
+		return nil
+	}
+	if err := p.cfg.Store.Save(ctx, st); err != nil {
+		return fmt.Errorf("save rate limit state: %w", err)
+	}
+	p.lastActive = newest
+	return nil
+}
+
+// Restore loads the saved state into rl and returns the number of buckets
+// restored.
+func (p *Persister) Restore(ctx context.Context, rl *RateLimitByRoute) (int, error) {
+	st, err := p.cfg.Store.Load(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("load rate limit state: %w", err)
+	}
+	return rl.RestoreState(st, p.cfg.MaxKeys), nil
+}
+
+// Carry copies the buckets of from into to, for routes whose limiter config
+// is unchanged. It is used on reload, where the saved state would be stale.
+func (p *Persister) Carry(from, to *RateLimitByRoute) int {
+	return to.RestoreState(from.SnapshotState(p.cfg.ActiveWithin, p.cfg.MaxKeys), p.cfg.MaxKeys)
+}
@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func sendN(t *testing.T, rl *RateLimitByRoute, routeID, ip string, n int) (allowed int) {
+	t.Helper()
+	h := rl.GetMiddleware(routeID)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for range n {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code == http.StatusOK {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func newStatePersister(t *testing.T, limiters func() *RateLimitByRoute) *Persister {
+	t.Helper()
+	store, err := NewFileStateStore(filepath.Join(t.TempDir(), "state", "ratelimit.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPersister(PersisterConfig{Store: store, Limiters: limiters})
+}
+
+func TestPersisterRestartKeepsBurstSpent(t *testing.T) {
+	cfg := Config{Rate: 10, Period: time.Minute, Burst: 5, PerIP: true}
+
+	before := NewRateLimitByRoute()
+	before.AddRoute("api", cfg)
+	before.AddRouteTiered("tiered", TieredConfig{
+		Tiers:       map[string]Config{"free": {Rate: 10, Period: time.Minute, Burst: 3}},
+		DefaultTier: "free",
+	})
+	if got := sendN(t, before, "api", "10.0.0.1", 5); got != 5 {
+		t.Fatalf("expected the burst of 5 to be allowed, got %d", got)
+	}
+	sendN(t, before, "tiered", "10.0.0.1", 3)
+
+	p := newStatePersister(t, func() *RateLimitByRoute { return before })
+	if err := p.Save(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart mid-window: the client must not get a second burst.
+	after := NewRateLimitByRoute()
+	after.AddRoute("api", cfg)
+	after.AddRouteTiered("tiered", TieredConfig{
+		Tiers:       map[string]Config{"free": {Rate: 10, Period: time.Minute, Burst: 3}},
+		DefaultTier: "free",
+	})
+	n, err := p.Restore(context.Background(), after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 buckets restored, got %d", n)
+	}
+	if got := sendN(t, after, "api", "10.0.0.1", 5); got != 0 {
+		t.Errorf("expected no requests allowed after restart, got %d", got)
+	}
+	if got := sendN(t, after, "tiered", "10.0.0.1", 3); got != 0 {
+		t.Errorf("expected no tiered requests allowed after restart, got %d", got)
+	}
+	if got := sendN(t, after, "api", "10.0.0.2", 5); got != 5 {
+		t.Errorf("expected another client to get its own burst, got %d", got)
+	}
+	if got := after.RestoredBuckets("api"); got != 1 {
+		t.Errorf("expected 1 restored bucket for api, got %d", got)
+	}
+}
+
+func TestPersisterSkipsChangedConfig(t *testing.T) {
+	before := NewRateLimitByRoute()
+	before.AddRoute("api", Config{Rate: 10, Period: time.Minute, Burst: 5, PerIP: true})
+	sendN(t, before, "api", "10.0.0.1", 5)
+
+	after := NewRateLimitByRoute()
+	after.AddRoute("api", Config{Rate: 20, Period: time.Minute, Burst: 5, PerIP: true})
+	p := newStatePersister(t, func() *RateLimitByRoute { return before })
+	if n := p.Carry(before, after); n != 0 {
+		t.Fatalf("expected no buckets carried into a changed limiter, got %d", n)
+	}
+	if got := sendN(t, after, "api", "10.0.0.1", 5); got != 5 {
+		t.Errorf("expected a fresh burst under the new limits, got %d", got)
+	}
+}
+
+func TestRestoreStateBoundsAndStaleness(t *testing.T) {
+	rl := NewRateLimitByRoute()
+	rl.AddRoute("api", Config{Rate: 60, Period: time.Minute, Burst: 10, PerIP: true})
+	fp := rl.Lookup("api").fingerprint()
+
+	now := time.Now()
+	st := &State{Routes: map[string]RouteState{"api": {Fingerprint: fp, Buckets: []BucketState{
+		{Key: "10.0.0.1", Tokens: 0, Last: now},
+		{Key: "10.0.0.2", Tokens: 0, Last: now},
+		{Key: "10.0.0.3", Tokens: 0, Last: now},
+		{Key: "10.0.0.4", Tokens: 0, Last: now.Add(-time.Minute)}, // refilled after 10s
+	}}}}
+	if n := rl.RestoreState(st, 2); n != 2 {
+		t.Fatalf("expected the cap of 2 buckets restored, got %d", n)
+	}
+	if got := sendN(t, rl, "api", "10.0.0.3", 1); got != 1 {
+		t.Errorf("expected a bucket past the cap to start full, got %d allowed", got)
+	}
+
+	rl = NewRateLimitByRoute()
+	rl.AddRoute("api", Config{Rate: 60, Period: time.Minute, Burst: 10, PerIP: true})
+	if n := rl.RestoreState(st, 10); n != 3 {
+		t.Errorf("expected the stale bucket to be skipped, got %d restored", n)
+	}
+}
+
+func TestPersisterSaveDebounced(t *testing.T) {
+	rl := NewRateLimitByRoute()
+	rl.AddRoute("api", Config{Rate: 10, Period: time.Minute, Burst: 5, PerIP: true})
+	sendN(t, rl, "api", "10.0.0.1", 1)
+
+	store := &countingStore{}
+	p := NewPersister(PersisterConfig{Store: store, Limiters: func() *RateLimitByRoute { return rl }})
+	for range 2 {
+		if err := p.save(context.Background(), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.saves != 1 {
+		t.Errorf("expected an idle save to be skipped, got %d saves", store.saves)
+	}
+	sendN(t, rl, "api", "10.0.0.1", 1)
+	p.save(context.Background(), false)
+	if store.saves != 2 {
+		t.Errorf("expected a save after new traffic, got %d saves", store.saves)
+	}
+}
+
+type countingStore struct {
+	saves int
+	last  *State
+}
+
+func (s *countingStore) Load(context.Context) (*State, error) { return s.last, nil }
+
+func (s *countingStore) Save(_ context.Context, st *State) error {
+	s.saves++
+	s.last = st
+	return nil
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 	"sync/atomic"
+
+	"github.com/wudi/runway/internal/ipaddr"
 )
 
 // contextKey is the type for the real IP context key.
@@ -13,7 +16,7 @@ type contextKey struct{}
 
 // CompiledRealIP extracts the real client IP from trusted proxy chains.
 type CompiledRealIP struct {
-	trustedNets []*net.IPNet
+	trustedNets []netip.Prefix
 	headers     []string // ordered list of headers to check
 	maxHops     int      // 0 = unlimited
 
@@ -23,25 +26,14 @@ type CompiledRealIP struct {
 
 // New creates a CompiledRealIP from a list of trusted proxy CIDRs.
 func New(cidrs []string, headers []string, maxHops int) (*CompiledRealIP, error) {
-	nets := make([]*net.IPNet, 0, len(cidrs))
+	nets := make([]netip.Prefix, 0, len(cidrs))
 	for _, cidr := range cidrs {
-		// Handle bare IPs by adding /32 or /128
-		if !strings.Contains(cidr, "/") {
-			ip := net.ParseIP(cidr)
-			if ip == nil {
-				return nil, &net.ParseError{Type: "IP address", Text: cidr}
-			}
-			if ip.To4() != nil {
-				cidr += "/32"
-			} else {
-				cidr += "/128"
-			}
-		}
-		_, ipNet, err := net.ParseCIDR(cidr)
+		// Bare IPs become /32 or /128; IPv4-mapped entries match IPv4 peers
+		p, err := ipaddr.ParsePrefix(cidr)
 		if err != nil {
-			return nil, err
+			return nil, &net.ParseError{Type: "IP address", Text: cidr}
 		}
-		nets = append(nets, ipNet)
+		nets = append(nets, p)
 	}
 
 	if len(headers) == 0 {
@@ -59,8 +51,14 @@ func New(cidrs []string, headers []string, maxHops int) (*CompiledRealIP, error)
 // It walks the X-Forwarded-For chain from right to left, skipping
 // IPs that match trusted proxy CIDRs, and returns the first
 // untrusted IP. If no trusted proxies are configured, it falls
-// back to the first XFF entry (legacy behavior).
+// back to the first XFF entry (legacy behavior). The result is
+// normalized: ports and zones are stripped and IPv4-mapped IPv6
+// addresses are returned as IPv4.
 func (c *CompiledRealIP) Extract(r *http.Request) string {
+	return ipaddr.Normalize(c.extract(r))
+}
+
+func (c *CompiledRealIP) extract(r *http.Request) string {
 	c.totalRequests.Add(1)
 
 	remoteIP := extractHost(r.RemoteAddr)
@@ -131,14 +129,20 @@ func (c *CompiledRealIP) walkXFF(xff string) string {
 	return ""
 }
 
+// TrustedPeer reports whether r arrived directly from a trusted proxy, so
+// the forwarding headers it set can be believed.
+func (c *CompiledRealIP) TrustedPeer(r *http.Request) bool {
+	return c.isTrusted(extractHost(r.RemoteAddr))
+}
+
 // isTrusted checks if an IP string matches any trusted CIDR.
 func (c *CompiledRealIP) isTrusted(ipStr string) bool {
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
+	addr, ok := ipaddr.Parse(ipStr)
+	if !ok {
 		return false
 	}
 	for _, n := range c.trustedNets {
-		if n.Contains(ip) {
+		if n.Contains(addr) {
 			return true
 		}
 	}
@@ -168,12 +172,15 @@ func (c *CompiledRealIP) legacyExtract(r *http.Request, remoteIP string) string
 // client IP and stores it in the request context.
 func (c *CompiledRealIP) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		realIP := c.Extract(r)
-		ctx := context.WithValue(r.Context(), contextKey{}, realIP)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(WithClientIP(r.Context(), c.Extract(r))))
 	})
 }
 
+// WithClientIP returns a copy of ctx carrying ip as the real client IP.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, contextKey{}, ip)
+}
+
 // FromContext retrieves the real client IP from the request context.
 // Returns empty string if not set.
 func FromContext(ctx context.Context) string {
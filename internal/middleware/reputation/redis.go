@@ -0,0 +1,137 @@
+package reputation
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+// reportScript decays an IP's shared score, adds points and blocks the IP
+// when the score reaches the threshold. While a block is active no points
+// are added.
+// KEYS: the IP's hash.
+// ARGV: now ms, half-life ms, points, threshold, key TTL ms, then the block
+// durations in ms.
+// Returns: [score, strikes, crossed, blocked until ms]
+var reportScript = redis.NewScript(`
+local v = redis.call('HMGET', KEYS[1], 's', 't', 'k', 'u')
+local now = tonumber(ARGV[1])
+local score = tonumber(v[1]) or 0
+local ts = tonumber(v[2]) or now
+local strikes = tonumber(v[3]) or 0
+local untilms = tonumber(v[4]) or 0
+if now > ts then
+    score = score * math.pow(2, -(now - ts) / tonumber(ARGV[2]))
+end
+if untilms > now then
+    return {tostring(score), strikes, 0, untilms}
+end
+score = score + tonumber(ARGV[3])
+local crossed = 0
+local stored = score
+if score >= tonumber(ARGV[4]) then
+    strikes = strikes + 1
+    crossed = 1
+    untilms = now + tonumber(ARGV[5 + math.min(strikes, #ARGV - 5)])
+    stored = 0
+end
+redis.call('HSET', KEYS[1], 's', tostring(stored), 't', now, 'k', strikes, 'u', untilms)
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return {tostring(score), strikes, crossed, untilms}
+`)
+
+const redisTimeout = 100 * time.Millisecond
+
+// sharedResult is an IP's state in the shared store after a report.
+type sharedResult struct {
+	score   float64
+	strikes int
+	crossed bool // this report triggered the block
+	until   time.Time
+}
+
+// redisStore shares scores, strikes and blocks across runway instances.
+// When Redis is unavailable the tracker falls back to local scoring.
+type redisStore struct {
+	client   *redis.Client
+	prefix   string
+	ttl      time.Duration
+	errors   atomic.Int64
+	degraded atomic.Bool
+}
+
+func (s *redisStore) add(ip string, points, threshold float64, halfLife time.Duration, durations []time.Duration, now time.Time) (sharedResult, bool) {
+	args := make([]interface{}, 0, 5+len(durations))
+	args = append(args, now.UnixMilli(), halfLife.Milliseconds(), points, threshold, s.ttl.Milliseconds())
+	for _, d := range durations {
+		args = append(args, d.Milliseconds())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	res, err := reportScript.Run(ctx, s.client, []string{s.prefix + ip}, args...).Slice()
+	if err != nil || len(res) != 4 {
+		s.fail(err)
+		return sharedResult{}, false
+	}
+	s.recovered()
+
+	str, _ := res[0].(string)
+	score, _ := strconv.ParseFloat(str, 64)
+	strikes, _ := res[1].(int64)
+	crossed, _ := res[2].(int64)
+	until, _ := res[3].(int64)
+	return sharedResult{
+		score:   score,
+		strikes: int(strikes),
+		crossed: crossed == 1,
+		until:   time.UnixMilli(until),
+	}, true
+}
+
+// get returns ip's shared score decayed to now.
+func (s *redisStore) get(ip string, halfLife time.Duration, now time.Time) (sharedResult, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	v, err := s.client.HMGet(ctx, s.prefix+ip, "s", "t", "k", "u").Result()
+	if err != nil || len(v) != 4 || v[0] == nil {
+		if err != nil {
+			s.fail(err)
+		}
+		return sharedResult{}, false
+	}
+	field := func(i int) float64 {
+		str, _ := v[i].(string)
+		f, _ := strconv.ParseFloat(str, 64)
+		return f
+	}
+	e := entry{score: field(0), updated: time.UnixMilli(int64(field(1)))}
+	e.decay(now, halfLife)
+	return sharedResult{score: e.score, strikes: int(field(2)), until: time.UnixMilli(int64(field(3)))}, true
+}
+
+func (s *redisStore) clear(ip string) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := s.client.Del(ctx, s.prefix+ip).Err(); err != nil {
+		s.fail(err)
+	}
+}
+
+func (s *redisStore) fail(err error) {
+	s.errors.Add(1)
+	if !s.degraded.Swap(true) {
+		logging.Warn("Reputation Redis store unavailable, falling back to local scores", zap.Error(err))
+	}
+}
+
+func (s *redisStore) recovered() {
+	if s.degraded.Swap(false) {
+		logging.Info("Reputation Redis store recovered, using shared scores")
+	}
+}
@@ -0,0 +1,412 @@
+package reputation
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	expirable "github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/redis/go-redis/v9"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/ipaddr"
+	"github.com/wudi/runway/internal/middleware/ipblocklist"
+	"github.com/wudi/runway/variables"
+)
+
+const (
+	defaultThreshold   = 100
+	defaultHalfLife    = 10 * time.Minute
+	defaultMaxEntries  = 100000
+	defaultHistorySize = 20
+)
+
+var defaultBlockDurations = []time.Duration{5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 24 * time.Hour}
+
+// signals maps each threat signal to its config name and default weight.
+var signals = [...]struct {
+	bit    variables.ThreatSignals
+	name   string
+	weight float64
+}{
+	{variables.SignalWAF, "waf", 25},
+	{variables.SignalAuthFailure, "auth_failure", 5},
+	{variables.SignalRateLimit, "rate_limit", 2},
+	{variables.SignalBot, "bot", 20},
+}
+
+// signalNames returns the names of the signals set in s.
+func signalNames(s variables.ThreatSignals) []string {
+	var names []string
+	for _, sig := range signals {
+		if s&sig.bit != 0 {
+			names = append(names, sig.name)
+		}
+	}
+	return names
+}
+
+// Event is one scored request in an IP's history.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Signals []string  `json:"signals"`
+	Points  float64   `json:"points"`
+	Score   float64   `json:"score"` // score after the event, before any block reset it
+	Blocked bool      `json:"blocked,omitempty"`
+}
+
+// Record is the admin API view of an IP's reputation.
+type Record struct {
+	IP           string     `json:"ip"`
+	Aggregated   bool       `json:"aggregated,omitempty"` // IP is the IPv6 network scored for the address
+	Score        float64    `json:"score"`
+	Strikes      int        `json:"strikes"`
+	Exempt       bool       `json:"exempt,omitempty"`
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+	History      []Event    `json:"history"`
+}
+
+// Block describes an automatic block.
+type Block struct {
+	IP       string  // address, or IPv6 network in CIDR notation
+	Score    float64 // score that crossed the threshold
+	Strikes  int
+	Duration time.Duration
+	Until    time.Time
+	Signals  []string // signals reported since the previous block
+}
+
+// entry is the per-IP state kept by an instance.
+type entry struct {
+	score   float64
+	updated time.Time
+	strikes int
+	signals variables.ThreatSignals // reported since the last block
+	history []Event
+}
+
+// decay brings the score forward to now.
+func (e *entry) decay(now time.Time, halfLife time.Duration) {
+	if dt := now.Sub(e.updated); dt > 0 && e.score > 0 {
+		e.score *= math.Exp2(-float64(dt) / float64(halfLife))
+	}
+	e.updated = now
+}
+
+// Tracker scores client IPs from the threat signals recorded on requests and
+// temporarily blocks IPs whose score reaches the threshold. Scores decay
+// exponentially; each block of the same IP lasts longer than the last. An
+// IP's strikes are forgotten once it has reported no signals for the longest
+// block duration plus ten half-lives. IPv6 addresses are scored and blocked
+// by their network of the configured aggregation prefix.
+type Tracker struct {
+	weights     [len(signals)]float64
+	ipv6Prefix  int
+	threshold   float64
+	halfLife    time.Duration
+	durations   []time.Duration
+	exempt      []netip.Prefix
+	historySize int
+	storeName   string
+
+	mu      sync.Mutex
+	entries *expirable.LRU[netip.Prefix, *entry]
+	blocks  *ipblocklist.TempBlocks
+	shared  *redisStore // nil for the memory store
+	onBlock func(Block)
+
+	reported    [len(signals)]atomic.Int64
+	blocksTotal atomic.Int64
+	now         func() time.Time
+}
+
+// New creates a Tracker that enforces its blocks through blocks. client is
+// used when cfg.Store is "redis".
+func New(cfg config.ReputationConfig, client *redis.Client, blocks *ipblocklist.TempBlocks) (*Tracker, error) {
+	t := &Tracker{
+		threshold:   cfg.Threshold,
+		halfLife:    cfg.HalfLife,
+		durations:   cfg.BlockDurations,
+		historySize: cfg.HistorySize,
+		ipv6Prefix:  cfg.IPv6AggregationPrefix,
+		storeName:   "memory",
+		blocks:      blocks,
+		now:         time.Now,
+	}
+	for i, sig := range signals {
+		t.weights[i] = sig.weight
+		if w, ok := cfg.Weights[sig.name]; ok {
+			t.weights[i] = w
+		}
+	}
+	if t.threshold <= 0 {
+		t.threshold = defaultThreshold
+	}
+	if t.halfLife <= 0 {
+		t.halfLife = defaultHalfLife
+	}
+	if len(t.durations) == 0 {
+		t.durations = defaultBlockDurations
+	}
+	if t.historySize <= 0 {
+		t.historySize = defaultHistorySize
+	}
+	maxEntries := cfg.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+
+	for _, entry := range cfg.ExemptCIDRs {
+		p, err := ipaddr.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("reputation: invalid exempt entry: %w", err)
+		}
+		t.exempt = append(t.exempt, p)
+	}
+
+	idle := t.durations[0]
+	for _, d := range t.durations {
+		idle = max(idle, d)
+	}
+	idle += 10 * t.halfLife
+	t.entries = expirable.NewLRU[netip.Prefix, *entry](maxEntries, nil, idle)
+
+	if cfg.Store == "redis" && client != nil {
+		t.storeName = "redis"
+		t.shared = &redisStore{client: client, prefix: "gw:rep:", ttl: idle}
+	}
+	return t, nil
+}
+
+// OnBlock sets a callback invoked for every automatic block. It must be set
+// before the tracker observes requests.
+func (t *Tracker) OnBlock(fn func(Block)) {
+	t.onBlock = fn
+}
+
+// Observe scores the client IP from the signals recorded on r. It runs once
+// the response is written and the status is recorded on the variable
+// context; a 401 or 403 without another signal counts as an auth failure.
+func (t *Tracker) Observe(r *http.Request) {
+	varCtx := variables.GetFromRequest(r)
+	s := varCtx.Signals
+	if s == 0 && (varCtx.Status == http.StatusUnauthorized || varCtx.Status == http.StatusForbidden) {
+		s = variables.SignalAuthFailure
+	}
+	if s != 0 {
+		t.Report(variables.ExtractClientIP(r), s)
+	}
+}
+
+// Report adds the weights of the signals in s to ip's score, blocking ip
+// when the score reaches the threshold. Exempt and already blocked IPs are
+// not scored.
+func (t *Tracker) Report(ip string, s variables.ThreatSignals) {
+	addr, ok := ipaddr.Parse(ip)
+	if !ok {
+		return
+	}
+	if t.isExempt(addr) {
+		return
+	}
+	if _, blocked := t.blocks.Get(addr.String()); blocked {
+		return
+	}
+	key := ipaddr.KeyPrefix(addr, t.ipv6Prefix)
+	keyStr := ipaddr.FormatKey(key)
+
+	var points float64
+	for i, sig := range signals {
+		if s&sig.bit != 0 {
+			points += t.weights[i]
+			t.reported[i].Add(1)
+		}
+	}
+	if points == 0 {
+		return
+	}
+	now := t.now()
+
+	var res sharedResult
+	shared := false
+	if t.shared != nil {
+		res, shared = t.shared.add(keyStr, points, t.threshold, t.halfLife, t.durations, now)
+	}
+
+	t.mu.Lock()
+	e, ok := t.entries.Get(key)
+	if !ok {
+		e = &entry{updated: now}
+	}
+	e.signals |= s
+	ev := Event{Time: now, Signals: signalNames(s), Points: points}
+
+	var block *Block
+	if shared {
+		e.score, e.strikes, e.updated = res.score, res.strikes, now
+		ev.Score = res.score
+		if res.until.After(now) {
+			// Blocked by this report, or by another instance sharing the
+			// store whose block this instance has not enforced yet.
+			block = &Block{Score: res.score, Strikes: res.strikes, Until: res.until}
+			ev.Blocked = res.crossed
+		}
+	} else {
+		e.decay(now, t.halfLife)
+		e.score += points
+		ev.Score = e.score
+		if e.score >= t.threshold {
+			e.strikes++
+			d := t.durations[min(e.strikes, len(t.durations))-1]
+			block = &Block{Score: e.score, Strikes: e.strikes, Until: now.Add(d)}
+			ev.Blocked = true
+		}
+	}
+	if block != nil {
+		block.IP = keyStr
+		block.Duration = block.Until.Sub(now)
+		block.Signals = signalNames(e.signals)
+		e.score = 0
+		e.signals = 0
+	}
+	e.history = append(e.history, ev)
+	if n := len(e.history) - t.historySize; n > 0 {
+		e.history = append(e.history[:0], e.history[n:]...)
+	}
+	t.entries.Add(key, e)
+	t.mu.Unlock()
+
+	if block == nil {
+		return
+	}
+	t.blocks.Add(ipblocklist.TempBlock{
+		IP:      block.IP,
+		Expires: block.Until,
+		Signals: block.Signals,
+		Score:   block.Score,
+		Strikes: block.Strikes,
+	})
+	if !shared || res.crossed {
+		t.blocksTotal.Add(1)
+		if t.onBlock != nil {
+			t.onBlock(*block)
+		}
+	}
+}
+
+func (t *Tracker) isExempt(addr netip.Addr) bool {
+	for _, p := range t.exempt {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the scoring key for ip, an address or a network as shown in
+// a Record, and the address exemption is checked against.
+func (t *Tracker) key(ip string) (netip.Prefix, netip.Addr, error) {
+	p, err := ipaddr.ParsePrefix(ip)
+	if err != nil {
+		return netip.Prefix{}, netip.Addr{}, fmt.Errorf("invalid IP %q", ip)
+	}
+	if p.IsSingleIP() {
+		return ipaddr.KeyPrefix(p.Addr(), t.ipv6Prefix), p.Addr(), nil
+	}
+	return p, p.Addr(), nil
+}
+
+// Lookup returns the current score, strikes, active block and recent
+// history of ip, or of the IPv6 network it is aggregated to.
+func (t *Tracker) Lookup(ip string) (Record, error) {
+	key, addr, err := t.key(ip)
+	if err != nil {
+		return Record{}, err
+	}
+	now := t.now()
+	rec := Record{
+		IP:         ipaddr.FormatKey(key),
+		Aggregated: !key.IsSingleIP(),
+		Exempt:     t.isExempt(addr),
+		History:    []Event{},
+	}
+
+	t.mu.Lock()
+	if e, ok := t.entries.Get(key); ok {
+		e.decay(now, t.halfLife)
+		rec.Score, rec.Strikes = e.score, e.strikes
+		rec.History = append(rec.History, e.history...)
+	}
+	t.mu.Unlock()
+
+	if t.shared != nil {
+		if res, ok := t.shared.get(rec.IP, t.halfLife, now); ok {
+			rec.Score, rec.Strikes = res.score, res.strikes
+		}
+	}
+	if b, ok := t.blocks.Get(rec.IP); ok {
+		rec.BlockedUntil = &b.Expires
+	}
+	return rec, nil
+}
+
+// Clear forgets the score, strikes and history of ip, or of the IPv6
+// network it is aggregated to, and lifts its block. It reports whether
+// anything was known about it.
+func (t *Tracker) Clear(ip string) (bool, error) {
+	key, _, err := t.key(ip)
+	if err != nil {
+		return false, err
+	}
+	keyStr := ipaddr.FormatKey(key)
+	t.mu.Lock()
+	found := t.entries.Remove(key)
+	t.mu.Unlock()
+	if t.blocks.Remove(keyStr) {
+		found = true
+	}
+	if t.shared != nil {
+		t.shared.clear(keyStr)
+	}
+	return found, nil
+}
+
+// Stats returns a snapshot of reputation scoring.
+func (t *Tracker) Stats() map[string]interface{} {
+	reported := make(map[string]int64, len(signals))
+	for i, sig := range signals {
+		reported[sig.name] = t.reported[i].Load()
+	}
+	weights := make(map[string]float64, len(signals))
+	for i, sig := range signals {
+		weights[sig.name] = t.weights[i]
+	}
+	stats := map[string]interface{}{
+		"enabled":         true,
+		"store":           t.storeName,
+		"tracked_ips":     t.entries.Len(),
+		"ipv6_prefix":     ipaddr.IPv6PrefixLen(t.ipv6Prefix),
+		"threshold":       t.threshold,
+		"half_life":       t.halfLife.String(),
+		"weights":         weights,
+		"signals":         reported,
+		"blocks_total":    t.blocksTotal.Load(),
+		"active_blocks":   len(t.blocks.List()),
+		"block_durations": durationStrings(t.durations),
+	}
+	if t.shared != nil {
+		stats["store_errors"] = t.shared.errors.Load()
+	}
+	return stats
+}
+
+func durationStrings(ds []time.Duration) []string {
+	out := make([]string, len(ds))
+	for i, d := range ds {
+		out[i] = d.String()
+	}
+	return out
+}
@@ -0,0 +1,298 @@
+package reputation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/middleware/ipblocklist"
+	"github.com/wudi/runway/variables"
+)
+
+// newTestTracker returns a tracker with a controllable clock.
+func newTestTracker(t *testing.T, cfg config.ReputationConfig) (*Tracker, *time.Time) {
+	t.Helper()
+	tr, err := New(cfg, nil, ipblocklist.NewTempBlocks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tr.now = func() time.Time { return now }
+	return tr, &now
+}
+
+func TestReport_BlocksAtThresholdWithEscalation(t *testing.T) {
+	tr, now := newTestTracker(t, config.ReputationConfig{
+		Weights:        map[string]float64{"waf": 50},
+		Threshold:      100,
+		BlockDurations: []time.Duration{time.Minute, time.Hour},
+	})
+	var blocks []Block
+	tr.OnBlock(func(b Block) { blocks = append(blocks, b) })
+
+	tr.Report("10.0.0.1", variables.SignalWAF)
+	if len(blocks) != 0 {
+		t.Fatal("blocked below threshold")
+	}
+	tr.Report("10.0.0.1", variables.SignalWAF|variables.SignalBot)
+	if len(blocks) != 1 {
+		t.Fatalf("expected a block at the threshold, got %d", len(blocks))
+	}
+	b := blocks[0]
+	if b.Strikes != 1 || b.Duration != time.Minute || b.Score != 120 {
+		t.Errorf("unexpected first block: %+v", b)
+	}
+	if len(b.Signals) != 2 || b.Signals[0] != "waf" || b.Signals[1] != "bot" {
+		t.Errorf("expected originating signals waf and bot, got %v", b.Signals)
+	}
+	if tb, ok := tr.blocks.Get("10.0.0.1"); !ok || tb.Signals[0] != "waf" {
+		t.Errorf("expected temporary block with signals, got %+v", tb)
+	}
+
+	// Blocked IPs are not scored further.
+	tr.Report("10.0.0.1", variables.SignalWAF)
+	tr.Report("10.0.0.1", variables.SignalWAF)
+	if len(blocks) != 1 {
+		t.Fatal("blocked IP was scored")
+	}
+
+	// The second block lasts longer, and the last duration repeats.
+	*now = now.Add(2 * time.Minute)
+	tr.blocks.Remove("10.0.0.1")
+	for range 2 {
+		tr.Report("10.0.0.1", variables.SignalWAF)
+	}
+	tr.blocks.Remove("10.0.0.1")
+	for range 2 {
+		tr.Report("10.0.0.1", variables.SignalWAF)
+	}
+	if len(blocks) != 3 || blocks[1].Duration != time.Hour || blocks[2].Duration != time.Hour || blocks[2].Strikes != 3 {
+		t.Errorf("expected escalating blocks, got %+v", blocks)
+	}
+}
+
+func TestReport_ScoreDecays(t *testing.T) {
+	tr, now := newTestTracker(t, config.ReputationConfig{
+		Weights:  map[string]float64{"rate_limit": 40},
+		HalfLife: time.Minute,
+	})
+	tr.Report("10.0.0.2", variables.SignalRateLimit)
+	*now = now.Add(2 * time.Minute)
+
+	rec, err := tr.Lookup("10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Score != 10 {
+		t.Errorf("expected score 40 to decay to 10 after two half-lives, got %v", rec.Score)
+	}
+
+	// Decayed points no longer count toward the threshold.
+	tr.Report("10.0.0.2", variables.SignalRateLimit)
+	tr.Report("10.0.0.2", variables.SignalRateLimit)
+	if _, blocked := tr.blocks.Get("10.0.0.2"); blocked {
+		t.Error("expected decayed score to stay below the threshold")
+	}
+}
+
+func TestReport_ExemptAndZeroWeight(t *testing.T) {
+	tr, _ := newTestTracker(t, config.ReputationConfig{
+		Weights:     map[string]float64{"waf": 200, "bot": 0},
+		ExemptCIDRs: []string{"192.168.0.0/16", "::1"},
+	})
+	tr.Report("192.168.4.2", variables.SignalWAF)
+	tr.Report("::1", variables.SignalWAF)
+	tr.Report("10.0.0.3", variables.SignalBot)
+
+	if blocks := tr.blocks.List(); len(blocks) != 0 {
+		t.Errorf("expected no blocks, got %+v", blocks)
+	}
+	if tr.entries.Len() != 0 {
+		t.Errorf("expected no tracked IPs, got %d", tr.entries.Len())
+	}
+	if rec, _ := tr.Lookup("192.168.4.2"); !rec.Exempt {
+		t.Error("expected exempt IP to be reported as exempt")
+	}
+}
+
+func TestLookupAndClear(t *testing.T) {
+	tr, _ := newTestTracker(t, config.ReputationConfig{
+		Weights:     map[string]float64{"auth_failure": 60},
+		HistorySize: 2,
+	})
+	tr.Report("10.0.0.4", variables.SignalRateLimit)
+	for range 3 {
+		tr.Report("10.0.0.4", variables.SignalAuthFailure)
+	}
+
+	rec, err := tr.Lookup("10.0.0.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Strikes != 1 || rec.BlockedUntil == nil || len(rec.History) != 2 {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	// Only two events are kept, the rate-limit rejection (default weight
+	// 2) still counts, and the last report arrives while blocked.
+	if !rec.History[1].Blocked || rec.History[1].Score != 122 {
+		t.Errorf("expected the blocking event last in history, got %+v", rec.History)
+	}
+
+	if _, err := tr.Lookup("not-an-ip"); err == nil {
+		t.Error("expected error for invalid IP")
+	}
+
+	found, err := tr.Clear("10.0.0.4")
+	if err != nil || !found {
+		t.Fatalf("expected clear to find the IP, got %v %v", found, err)
+	}
+	rec, _ = tr.Lookup("10.0.0.4")
+	if rec.Score != 0 || rec.Strikes != 0 || rec.BlockedUntil != nil || len(rec.History) != 0 {
+		t.Errorf("expected cleared record, got %+v", rec)
+	}
+	if found, _ := tr.Clear("10.0.0.4"); found {
+		t.Error("expected second clear to find nothing")
+	}
+}
+
+func TestReport_IPv6Aggregation(t *testing.T) {
+	tr, _ := newTestTracker(t, config.ReputationConfig{
+		Weights:     map[string]float64{"waf": 60},
+		ExemptCIDRs: []string{"2001:db8:ff::1"},
+	})
+	var blocks []Block
+	tr.OnBlock(func(b Block) { blocks = append(blocks, b) })
+
+	// Addresses rotating within the default /56 share a score.
+	tr.Report("2001:db8:1:100::1", variables.SignalWAF)
+	tr.Report("2001:db8:1:1ff::2", variables.SignalWAF)
+	if len(blocks) != 1 || blocks[0].IP != "2001:db8:1:100::/56" {
+		t.Fatalf("expected a block of the /56, got %+v", blocks)
+	}
+	if _, ok := tr.blocks.Get("2001:db8:1:1aa::3"); !ok {
+		t.Error("expected another address of the /56 to be blocked")
+	}
+	if _, ok := tr.blocks.Get("2001:db8:1:200::1"); ok {
+		t.Error("expected the neighbouring /56 not to be blocked")
+	}
+
+	rec, err := tr.Lookup("2001:db8:1:150::9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.IP != "2001:db8:1:100::/56" || !rec.Aggregated || rec.BlockedUntil == nil {
+		t.Errorf("expected aggregated record, got %+v", rec)
+	}
+	if found, _ := tr.Clear("2001:db8:1:100::/56"); !found {
+		t.Error("expected clear by network to find the block")
+	}
+	if _, ok := tr.blocks.Get("2001:db8:1:100::1"); ok {
+		t.Error("expected the block to be lifted")
+	}
+
+	// Exemptions apply to the address, not its network.
+	tr.Report("2001:db8:ff::1", variables.SignalWAF)
+	tr.Report("2001:db8:ff::1", variables.SignalWAF)
+	if len(blocks) != 1 {
+		t.Errorf("exempt address was scored: %+v", blocks)
+	}
+
+	// A /128 prefix keys addresses individually.
+	tr128, _ := newTestTracker(t, config.ReputationConfig{
+		Weights:               map[string]float64{"waf": 60},
+		IPv6AggregationPrefix: 128,
+	})
+	tr128.Report("2001:db8:1:100::1", variables.SignalWAF)
+	tr128.Report("2001:db8:1:100::2", variables.SignalWAF)
+	if n := len(tr128.blocks.List()); n != 0 {
+		t.Errorf("expected no block without aggregation, got %d", n)
+	}
+	if rec, _ := tr128.Lookup("2001:db8:1:100::1"); rec.IP != "2001:db8:1:100::1" || rec.Aggregated {
+		t.Errorf("expected a single-address record, got %+v", rec)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	tr, _ := newTestTracker(t, config.ReputationConfig{
+		Weights: map[string]float64{"auth_failure": 1, "rate_limit": 7},
+	})
+
+	observe := func(status int, signals variables.ThreatSignals) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.5:1234"
+		varCtx := variables.NewContext(r)
+		varCtx.Status = status
+		varCtx.Signals = signals
+		r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx))
+		tr.Observe(r)
+	}
+
+	observe(http.StatusOK, 0)
+	observe(http.StatusUnauthorized, 0)
+	observe(http.StatusForbidden, 0)
+	// A rate-limit rejection counts as rate_limit, not as an auth failure.
+	observe(http.StatusTooManyRequests, variables.SignalRateLimit)
+
+	rec, _ := tr.Lookup("10.0.0.5")
+	if rec.Score != 9 || len(rec.History) != 3 {
+		t.Errorf("expected two auth failures and one rate-limit rejection, got %+v", rec)
+	}
+	if stats := tr.Stats(); stats["signals"].(map[string]int64)["auth_failure"] != 2 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func redisAvailable(t *testing.T) *redis.Client {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{
+		Addr:        "localhost:6379",
+		DialTimeout: 100 * time.Millisecond,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	return client
+}
+
+func TestRedisStore_SharedAcrossInstances(t *testing.T) {
+	client := redisAvailable(t)
+	defer client.Del(context.Background(), "gw:rep:10.0.0.6")
+
+	cfg := config.ReputationConfig{Weights: map[string]float64{"waf": 60}, Store: "redis"}
+	a, err := New(cfg, client, ipblocklist.NewTempBlocks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := New(cfg, client, ipblocklist.NewTempBlocks())
+	var blocks int
+	a.OnBlock(func(Block) { blocks++ })
+	b.OnBlock(func(Block) { blocks++ })
+
+	a.Report("10.0.0.6", variables.SignalWAF)
+	b.Report("10.0.0.6", variables.SignalWAF)
+	if blocks != 1 {
+		t.Fatalf("expected one block from the shared score, got %d", blocks)
+	}
+	if _, ok := b.blocks.Get("10.0.0.6"); !ok {
+		t.Fatal("expected the crossing instance to block")
+	}
+
+	// The other instance enforces the shared block on its next report.
+	a.Report("10.0.0.6", variables.SignalWAF)
+	if _, ok := a.blocks.Get("10.0.0.6"); !ok || blocks != 1 {
+		t.Errorf("expected shared block enforced without another event, blocks=%d", blocks)
+	}
+
+	if _, err := a.Clear("10.0.0.6"); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := b.Lookup("10.0.0.6"); rec.Strikes != 0 {
+		t.Errorf("expected cleared shared strikes, got %+v", rec)
+	}
+}
@@ -74,7 +74,15 @@ func RequestIDWithConfig(cfg RequestIDConfig) Middleware {
 			ctx := context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
+			abort := varCtx.AbortResponse
 			variables.ReleaseContext(varCtx)
+
+			// Everything inside has logged and recorded the request; cut the
+			// connection so the client can't mistake a partial response for
+			// a complete one.
+			if abort {
+				panic(http.ErrAbortHandler)
+			}
 		})
 	}
 }
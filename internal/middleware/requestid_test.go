@@ -219,3 +219,17 @@ func TestRequestIDWithConfigDefaults(t *testing.T) {
 		t.Error("expected X-Request-ID to be set via default generator")
 	}
 }
+
+func TestRequestIDAbortsMarkedResponse(t *testing.T) {
+	handler := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		variables.GetFromRequest(r).AbortResponse = true
+	}))
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler panic, got %v", rec)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -2,6 +2,7 @@ package requestqueue
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"sync/atomic"
 	"time"
@@ -92,6 +93,50 @@ func (q *RequestQueue) Middleware() middleware.Middleware {
 	}
 }
 
+var (
+	// ErrQueueFull is returned by Hold when MaxDepth requests are already held.
+	ErrQueueFull = errors.New("request queue full")
+	// ErrQueueTimeout is returned by Hold when release does not happen
+	// within MaxWait.
+	ErrQueueTimeout = errors.New("request queue timeout")
+)
+
+// Hold parks a request in the queue until release is closed, up to MaxWait.
+// Unlike Middleware, a held request does not wait for a free slot but for
+// an external signal; the queue only bounds how many requests may wait.
+// It returns ErrQueueFull without waiting when the queue is at MaxDepth,
+// and the context error when the client goes away.
+func (q *RequestQueue) Hold(ctx context.Context, release <-chan struct{}) error {
+	select {
+	case q.sem <- struct{}{}:
+	default:
+		q.rejected.Add(1)
+		return ErrQueueFull
+	}
+	q.enqueued.Add(1)
+	q.currentDepth.Add(1)
+	start := time.Now()
+	defer func() {
+		<-q.sem
+		q.currentDepth.Add(-1)
+		q.totalWaitNs.Add(int64(time.Since(start)))
+	}()
+
+	timer := time.NewTimer(q.maxWait)
+	defer timer.Stop()
+	select {
+	case <-release:
+		q.dequeued.Add(1)
+		return nil
+	case <-timer.C:
+		q.timedOut.Add(1)
+		return ErrQueueTimeout
+	case <-ctx.Done():
+		q.rejected.Add(1)
+		return ctx.Err()
+	}
+}
+
 // QueueSnapshot is a point-in-time view of queue metrics.
 type QueueSnapshot struct {
 	MaxDepth     int     `json:"max_depth"`
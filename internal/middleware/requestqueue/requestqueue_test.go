@@ -260,3 +260,46 @@ func TestMergeRequestQueueConfig(t *testing.T) {
 		t.Fatalf("expected maxWait=60s from global, got %v", merged.MaxWait)
 	}
 }
+
+func TestHold(t *testing.T) {
+	q := New(2, 5*time.Second)
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = q.Hold(context.Background(), release)
+		}()
+	}
+	for q.Snapshot().CurrentDepth != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := q.Hold(context.Background(), release); err != ErrQueueFull {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("expected released holds, got %v", err)
+		}
+	}
+	snap := q.Snapshot()
+	if snap.Dequeued != 2 || snap.Rejected != 1 || snap.CurrentDepth != 0 {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+}
+
+func TestHoldTimeout(t *testing.T) {
+	q := New(1, 20*time.Millisecond)
+	if err := q.Hold(context.Background(), make(chan struct{})); err != ErrQueueTimeout {
+		t.Fatalf("expected ErrQueueTimeout, got %v", err)
+	}
+	if q.Snapshot().TimedOut != 1 {
+		t.Fatalf("expected timedOut=1, got %d", q.Snapshot().TimedOut)
+	}
+}
@@ -102,11 +102,14 @@ func (rbg *RespBodyGen) Middleware() middleware.Middleware {
 			rbg.generated.Add(1)
 
 			rendered := buf.Bytes()
-			bufutil.CopyHeaders(w.Header(), bw.Header())
+			bw.CopyHeadersTo(w.Header())
 			w.Header().Set("Content-Type", rbg.contentType)
-			w.Header().Set("Content-Length", strconv.Itoa(len(rendered)))
+			if !bw.HasTrailers() {
+				w.Header().Set("Content-Length", strconv.Itoa(len(rendered)))
+			}
 			w.WriteHeader(bw.StatusCode)
 			w.Write(rendered)
+			bw.WriteTrailers(w)
 		})
 	}
 }
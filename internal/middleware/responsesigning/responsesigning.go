@@ -211,10 +211,11 @@ func (s *Signer) Middleware() middleware.Middleware {
 
 			s.totalSigned.Add(1)
 
-			bufutil.CopyHeaders(w.Header(), bw.Header())
+			bw.CopyHeadersTo(w.Header())
 			w.Header().Set(s.header, sigValue)
 			w.WriteHeader(bw.StatusCode)
 			w.Write(bw.Body.Bytes())
+			bw.WriteTrailers(w)
 		})
 	}
 }
@@ -0,0 +1,264 @@
+package secretheaders
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRefreshInterval = time.Minute
+	resolveTimeout         = 10 * time.Second
+)
+
+// secretValue is one side of a rotation pair. The version increments each
+// time a refresh observes a new value.
+type secretValue struct {
+	ref     string
+	value   atomic.Pointer[string]
+	version atomic.Int64
+
+	served     atomic.Int64
+	lastServed atomic.Int64 // unix nano
+}
+
+// secretHeader is a header with its primary and optional secondary value.
+type secretHeader struct {
+	name      string
+	percent   int
+	primary   *secretValue
+	secondary *secretValue // nil when no secondary is configured
+}
+
+// Injector sets secret-backed headers on requests toward the backend.
+type Injector struct {
+	routeID  string
+	headers  []*secretHeader
+	registry *config.SecretRegistry
+	interval time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	refreshing    atomic.Bool
+	nextRefresh   atomic.Int64 // unix nano
+	refreshes     atomic.Int64
+	refreshErrors atomic.Int64
+	lastRefresh   atomic.Int64 // unix nano
+}
+
+// New creates an Injector, resolving every secret reference once. The
+// references are re-resolved by the first request after each refresh
+// interval, or on demand through Refresh.
+func New(routeID string, cfg config.BackendHeadersSecretConfig, registry *config.SecretRegistry) (*Injector, error) {
+	interval := cfg.RefreshInterval
+	if interval == 0 {
+		interval = defaultRefreshInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	inj := &Injector{
+		routeID:  routeID,
+		registry: registry,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for _, hc := range cfg.Headers {
+		h := &secretHeader{
+			name:    http.CanonicalHeaderKey(hc.Name),
+			percent: hc.SecondaryPercent,
+			primary: &secretValue{ref: hc.Primary},
+		}
+		if hc.Secondary != "" {
+			h.secondary = &secretValue{ref: hc.Secondary}
+		}
+		inj.headers = append(inj.headers, h)
+	}
+	if err := inj.refresh(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return inj, nil
+}
+
+// refresh re-resolves every reference. Values that fail to resolve keep
+// their previous value; the first error is returned.
+func (inj *Injector) refresh() error {
+	inj.nextRefresh.Store(time.Now().Add(inj.interval).UnixNano())
+	ctx, cancel := context.WithTimeout(inj.ctx, resolveTimeout)
+	defer cancel()
+
+	var firstErr error
+	for _, h := range inj.headers {
+		for _, sv := range []*secretValue{h.primary, h.secondary} {
+			if sv == nil {
+				continue
+			}
+			v, err := inj.registry.ResolveValue(ctx, sv.ref)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			if old := sv.value.Load(); old == nil || *old != v {
+				sv.value.Store(&v)
+				sv.version.Add(1)
+			}
+		}
+	}
+	inj.refreshes.Add(1)
+	if firstErr != nil {
+		inj.refreshErrors.Add(1)
+		return firstErr
+	}
+	inj.lastRefresh.Store(time.Now().UnixNano())
+	return nil
+}
+
+// Refresh re-resolves every reference immediately.
+func (inj *Injector) Refresh() {
+	if err := inj.refresh(); err != nil {
+		logging.Warn("backend secret header refresh failed",
+			zap.String("route_id", inj.routeID),
+			zap.Error(err),
+		)
+	}
+}
+
+// maybeRefresh starts a background refresh once the refresh interval has
+// elapsed. Requests keep the current values while it runs.
+func (inj *Injector) maybeRefresh(now int64) {
+	if now < inj.nextRefresh.Load() || inj.ctx.Err() != nil {
+		return
+	}
+	if !inj.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer inj.refreshing.Store(false)
+		inj.Refresh()
+	}()
+}
+
+// Close cancels any refresh in progress and stops further refreshes.
+func (inj *Injector) Close() {
+	inj.cancel()
+}
+
+// pick returns the value to send for h on this request.
+func (h *secretHeader) pick() *secretValue {
+	if h.secondary != nil && h.percent > 0 && (h.percent >= 100 || rand.Intn(100) < h.percent) {
+		return h.secondary
+	}
+	return h.primary
+}
+
+// Apply sets the secret headers on r. It replaces r.Header with a copy so
+// the values never appear in the header map seen by the access log, debug
+// endpoint, or any middleware wrapping the route.
+func (inj *Injector) Apply(r *http.Request) {
+	r.Header = r.Header.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	now := time.Now().UnixNano()
+	inj.maybeRefresh(now)
+	for _, h := range inj.headers {
+		sv := h.pick()
+		v := sv.value.Load()
+		if v == nil {
+			continue
+		}
+		r.Header.Set(h.name, *v)
+		sv.served.Add(1)
+		sv.lastServed.Store(now)
+	}
+}
+
+// Middleware returns a middleware that injects the secret headers.
+func (inj *Injector) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r2 := new(http.Request)
+			*r2 = *r
+			inj.Apply(r2)
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
+
+// ValueStats reports how one side of a rotation pair has been used. It
+// never includes the secret value.
+type ValueStats struct {
+	Version      int64  `json:"version"`
+	Served       int64  `json:"served"`
+	LastServedAt string `json:"last_served_at,omitempty"`
+}
+
+// HeaderStats reports per-header rotation state.
+type HeaderStats struct {
+	SecondaryPercent int         `json:"secondary_percent"`
+	Primary          ValueStats  `json:"primary"`
+	Secondary        *ValueStats `json:"secondary,omitempty"`
+}
+
+// Stats is the admin API snapshot for an Injector.
+type Stats struct {
+	RefreshInterval string                 `json:"refresh_interval"`
+	Refreshes       int64                  `json:"refreshes"`
+	RefreshErrors   int64                  `json:"refresh_errors"`
+	LastRefreshAt   string                 `json:"last_refresh_at,omitempty"`
+	Headers         map[string]HeaderStats `json:"headers"`
+}
+
+func (sv *secretValue) stats() ValueStats {
+	s := ValueStats{Version: sv.version.Load(), Served: sv.served.Load()}
+	if ts := sv.lastServed.Load(); ts > 0 {
+		s.LastServedAt = time.Unix(0, ts).Format(time.RFC3339)
+	}
+	return s
+}
+
+// Stats returns a snapshot of refresh and per-version usage counters.
+func (inj *Injector) Stats() Stats {
+	s := Stats{
+		RefreshInterval: inj.interval.String(),
+		Refreshes:       inj.refreshes.Load(),
+		RefreshErrors:   inj.refreshErrors.Load(),
+		Headers:         make(map[string]HeaderStats, len(inj.headers)),
+	}
+	if ts := inj.lastRefresh.Load(); ts > 0 {
+		s.LastRefreshAt = time.Unix(0, ts).Format(time.RFC3339)
+	}
+	for _, h := range inj.headers {
+		hs := HeaderStats{SecondaryPercent: h.percent, Primary: h.primary.stats()}
+		if h.secondary != nil {
+			sec := h.secondary.stats()
+			hs.Secondary = &sec
+		}
+		s.Headers[h.name] = hs
+	}
+	return s
+}
+
+// SecretHeadersByRoute manages per-route secret header injectors.
+type SecretHeadersByRoute = byroute.NamedFactory[*Injector, config.BackendHeadersSecretConfig]
+
+// NewSecretHeadersByRoute creates a new per-route manager that resolves
+// secret references through registry.
+func NewSecretHeadersByRoute(registry *config.SecretRegistry) *SecretHeadersByRoute {
+	newFn := func(routeID string, cfg config.BackendHeadersSecretConfig) (*Injector, error) {
+		return New(routeID, cfg, registry)
+	}
+	return byroute.NewNamedFactory(newFn, func(inj *Injector) any { return inj.Stats() }).
+		WithClose((*Injector).Close)
+}
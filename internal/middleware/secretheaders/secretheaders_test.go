@@ -0,0 +1,154 @@
+package secretheaders
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func newInjector(t *testing.T, cfg config.BackendHeadersSecretConfig) *Injector {
+	t.Helper()
+	inj, err := New("test", cfg, config.NewDefaultSecretRegistry(config.SecretsConfig{}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(inj.Close)
+	return inj
+}
+
+func serve(inj *Injector, r *http.Request) http.Header {
+	var got http.Header
+	inj.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	return got
+}
+
+func TestInjectsPrimaryWithoutTouchingOuterRequest(t *testing.T) {
+	t.Setenv("SH_PRIMARY", "old-key")
+	inj := newInjector(t, config.BackendHeadersSecretConfig{
+		Headers: []config.SecretHeaderConfig{{Name: "x-api-key", Primary: "${env:SH_PRIMARY}"}},
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	got := serve(inj, r)
+
+	if got.Get("X-Api-Key") != "old-key" {
+		t.Errorf("expected primary value, got %q", got.Get("X-Api-Key"))
+	}
+	if r.Header.Get("X-Api-Key") != "" {
+		t.Error("secret must not be visible on the outer request headers")
+	}
+}
+
+func TestSecondaryPercent(t *testing.T) {
+	t.Setenv("SH_OLD", "old")
+	t.Setenv("SH_NEW", "new")
+
+	tests := []struct {
+		percent int
+		want    string
+	}{
+		{0, "old"},
+		{100, "new"},
+	}
+	for _, tt := range tests {
+		inj := newInjector(t, config.BackendHeadersSecretConfig{
+			Headers: []config.SecretHeaderConfig{{
+				Name:             "X-Api-Key",
+				Primary:          "${env:SH_OLD}",
+				Secondary:        "${env:SH_NEW}",
+				SecondaryPercent: tt.percent,
+			}},
+		})
+		for i := 0; i < 20; i++ {
+			if got := serve(inj, httptest.NewRequest("GET", "/", nil)).Get("X-Api-Key"); got != tt.want {
+				t.Fatalf("percent=%d: expected %q, got %q", tt.percent, tt.want, got)
+			}
+		}
+	}
+
+	inj := newInjector(t, config.BackendHeadersSecretConfig{
+		Headers: []config.SecretHeaderConfig{{
+			Name:             "X-Api-Key",
+			Primary:          "${env:SH_OLD}",
+			Secondary:        "${env:SH_NEW}",
+			SecondaryPercent: 50,
+		}},
+	})
+	for i := 0; i < 1000; i++ {
+		serve(inj, httptest.NewRequest("GET", "/", nil))
+	}
+	hs := inj.Stats().Headers["X-Api-Key"]
+	if hs.Primary.Served == 0 || hs.Secondary == nil || hs.Secondary.Served == 0 {
+		t.Fatalf("expected both versions to be served, got %+v", hs)
+	}
+	if hs.Primary.Served+hs.Secondary.Served != 1000 {
+		t.Errorf("expected 1000 served in total, got %d", hs.Primary.Served+hs.Secondary.Served)
+	}
+}
+
+func TestRefreshPicksUpNewValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	os.WriteFile(path, []byte("v1\n"), 0o600)
+
+	inj := newInjector(t, config.BackendHeadersSecretConfig{
+		Headers: []config.SecretHeaderConfig{{Name: "X-Api-Key", Primary: "${file:" + path + "}"}},
+	})
+	if got := serve(inj, httptest.NewRequest("GET", "/", nil)).Get("X-Api-Key"); got != "v1" {
+		t.Fatalf("expected v1, got %q", got)
+	}
+
+	os.WriteFile(path, []byte("v2\n"), 0o600)
+	inj.Refresh()
+	if got := serve(inj, httptest.NewRequest("GET", "/", nil)).Get("X-Api-Key"); got != "v2" {
+		t.Fatalf("expected v2 after refresh, got %q", got)
+	}
+	if v := inj.Stats().Headers["X-Api-Key"].Primary.Version; v != 2 {
+		t.Errorf("expected version 2, got %d", v)
+	}
+
+	// A failed refresh keeps serving the last good value.
+	os.Remove(path)
+	inj.Refresh()
+	if got := serve(inj, httptest.NewRequest("GET", "/", nil)).Get("X-Api-Key"); got != "v2" {
+		t.Fatalf("expected last good value after failed refresh, got %q", got)
+	}
+	if s := inj.Stats(); s.RefreshErrors != 1 {
+		t.Errorf("expected 1 refresh error, got %d", s.RefreshErrors)
+	}
+}
+
+func TestRequestRefreshesAfterInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	os.WriteFile(path, []byte("v1\n"), 0o600)
+
+	inj := newInjector(t, config.BackendHeadersSecretConfig{
+		RefreshInterval: time.Millisecond,
+		Headers:         []config.SecretHeaderConfig{{Name: "X-Api-Key", Primary: "${file:" + path + "}"}},
+	})
+	os.WriteFile(path, []byte("v2\n"), 0o600)
+	time.Sleep(5 * time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for serve(inj, httptest.NewRequest("GET", "/", nil)).Get("X-Api-Key") != "v2" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a request after the refresh interval to pick up v2")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNewFailsOnUnresolvableRef(t *testing.T) {
+	_, err := New("test", config.BackendHeadersSecretConfig{
+		Headers: []config.SecretHeaderConfig{{Name: "X-Api-Key", Primary: "${env:DEFINITELY_UNSET_SH}"}},
+	}, config.NewDefaultSecretRegistry(config.SecretsConfig{}))
+	if err == nil {
+		t.Fatal("expected error for unresolvable reference")
+	}
+}
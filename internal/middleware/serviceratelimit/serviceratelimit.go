@@ -9,6 +9,7 @@ import (
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/errors"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/variables"
 	"golang.org/x/time/rate"
 )
 
@@ -42,6 +43,7 @@ func (sl *ServiceLimiter) Middleware() middleware.Middleware {
 			if !sl.limiter.Allow() {
 				sl.rejected.Add(1)
 				w.Header().Set("Retry-After", strconv.Itoa(1))
+				variables.SetErrorReason(r, errors.ReasonRateLimited)
 				errors.New(http.StatusTooManyRequests, "Service rate limit exceeded").WriteJSON(w)
 				return
 			}
@@ -12,6 +12,7 @@ import (
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/logging"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/variables"
 	"go.uber.org/zap"
 )
 
@@ -24,6 +25,7 @@ type Tracker struct {
 	actionHeader    bool
 	actionShedLoad  bool
 	shedLoadPercent float64
+	countAborts     bool // count client aborts as errors instead of skipping them
 
 	shedCount atomic.Int64
 }
@@ -108,8 +110,15 @@ func (t *Tracker) Middleware() middleware.Middleware {
 			sw := &sloWriter{ResponseWriter: w, statusCode: 200}
 			next.ServeHTTP(sw, r)
 
-			// Post-request: record outcome
+			// Post-request: record outcome. Requests abandoned by the client
+			// say nothing about the backend and are left out of the budget.
 			isErr := t.errorCodeSet[sw.statusCode]
+			if variables.GetFromRequest(r).ClientAbort != variables.AbortNone {
+				if !t.countAborts {
+					return
+				}
+				isErr = true
+			}
 			t.window.Record(isErr)
 
 			// Log warning if budget exhausted
@@ -181,7 +190,12 @@ func (w *sloWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 // SLOByRoute manages per-route SLO trackers.
 type SLOByRoute = byroute.Factory[*Tracker, config.SLOConfig]
 
-// NewSLOByRoute creates a new per-route SLO manager.
-func NewSLOByRoute() *SLOByRoute {
-	return byroute.SimpleFactory(NewTracker, func(t *Tracker) any { return t.Snapshot() })
+// NewSLOByRoute creates a new per-route SLO manager. countAborts makes
+// requests abandoned by the client count as errors.
+func NewSLOByRoute(countAborts bool) *SLOByRoute {
+	return byroute.SimpleFactory(func(cfg config.SLOConfig) *Tracker {
+		t := NewTracker(cfg)
+		t.countAborts = countAborts
+		return t
+	}, func(t *Tracker) any { return t.Snapshot() })
 }
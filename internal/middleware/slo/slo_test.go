@@ -1,12 +1,14 @@
 package slo
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
 )
 
 func TestSlidingWindow_RecordAndSnapshot(t *testing.T) {
@@ -263,7 +265,7 @@ func TestTracker_Snapshot(t *testing.T) {
 }
 
 func TestSLOByRoute(t *testing.T) {
-	m := NewSLOByRoute()
+	m := NewSLOByRoute(false)
 	m.AddRoute("route1", config.SLOConfig{
 		Enabled: true,
 		Target:  0.999,
@@ -283,6 +285,32 @@ func TestSLOByRoute(t *testing.T) {
 	}
 }
 
+func TestSLOByRoute_ClientAborts(t *testing.T) {
+	aborted := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		variables.GetFromRequest(r).ClientAbort = variables.AbortDuringBackend
+		w.WriteHeader(variables.StatusClientClosedRequest)
+	})
+	cfg := config.SLOConfig{Enabled: true, Target: 0.99, Window: time.Hour}
+
+	for _, countAborts := range []bool{false, true} {
+		m := NewSLOByRoute(countAborts)
+		m.AddRoute("route1", cfg)
+		tracker := m.Lookup("route1")
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, variables.NewContext(req)))
+		tracker.Middleware()(aborted).ServeHTTP(httptest.NewRecorder(), req)
+
+		total, errors := tracker.window.Snapshot()
+		if countAborts && (total != 1 || errors != 1) {
+			t.Errorf("expected the abort counted as an error, got %d/%d", errors, total)
+		}
+		if !countAborts && total != 0 {
+			t.Errorf("expected the abort left out of the budget, got %d requests", total)
+		}
+	}
+}
+
 func TestSloWriter_Flush(t *testing.T) {
 	rec := httptest.NewRecorder()
 	sw := &sloWriter{ResponseWriter: rec, statusCode: 200}
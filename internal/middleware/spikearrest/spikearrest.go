@@ -10,6 +10,7 @@ import (
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/errors"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/ratelimit"
 	"github.com/wudi/runway/variables"
 	"golang.org/x/time/rate"
 )
@@ -18,11 +19,15 @@ import (
 type SpikeArrester struct {
 	global   *rate.Limiter // non-nil when !perIP
 	perIP    bool
-	limiters sync.Map // ip -> *ipEntry
+	ipv6Bits int      // IPv6 clients share a limiter per network of this length
+	limiters sync.Map // client IP key -> *ipEntry
 	rps      rate.Limit
 	burst    int
 	allowed  atomic.Int64
 	rejected atomic.Int64
+
+	observe    *ratelimit.Observer // set in observe mode
+	onObserved func()              // called for every would-be rejection
 }
 
 type ipEntry struct {
@@ -43,9 +48,13 @@ func New(cfg config.SpikeArrestConfig) *SpikeArrester {
 	rps := rate.Limit(float64(cfg.Rate) / period.Seconds())
 
 	sa := &SpikeArrester{
-		perIP: cfg.PerIP,
-		rps:   rps,
-		burst: burst,
+		perIP:    cfg.PerIP,
+		ipv6Bits: cfg.IPv6AggregationPrefix,
+		rps:      rps,
+		burst:    burst,
+	}
+	if cfg.Mode == "observe" {
+		sa.observe = ratelimit.NewObserver(nil)
 	}
 	if !cfg.PerIP {
 		sa.global = rate.NewLimiter(rps, burst)
@@ -61,8 +70,10 @@ func (sa *SpikeArrester) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			var limiter *rate.Limiter
+			key := "global"
 			if sa.perIP {
-				ip := variables.ExtractClientIP(r)
+				ip := variables.ClientIPKey(r, sa.ipv6Bits)
+				key = ip
 				entry, _ := sa.limiters.LoadOrStore(ip, &ipEntry{
 					limiter: rate.NewLimiter(sa.rps, sa.burst),
 				})
@@ -74,9 +85,16 @@ func (sa *SpikeArrester) Middleware() middleware.Middleware {
 			}
 
 			if !limiter.Allow() {
-				sa.rejected.Add(1)
-				errors.New(http.StatusTooManyRequests, "Spike arrest: rate exceeded").WriteJSON(w)
-				return
+				if sa.observe == nil {
+					sa.rejected.Add(1)
+					variables.SetErrorReason(r, errors.ReasonRateLimited)
+					errors.New(http.StatusTooManyRequests, "Spike arrest: rate exceeded").WriteJSON(w)
+					return
+				}
+				sa.observe.WouldReject(w, r, key, "")
+				if sa.onObserved != nil {
+					sa.onObserved()
+				}
 			}
 			sa.allowed.Add(1)
 			next.ServeHTTP(w, r)
@@ -109,6 +127,10 @@ func (sa *SpikeArrester) Stats() map[string]interface{} {
 		"rejected": sa.rejected.Load(),
 		"per_ip":   sa.perIP,
 	}
+	if sa.observe != nil {
+		result["mode"] = "observe"
+		result["observed_rejections"] = sa.observe.Stats()
+	}
 	if sa.perIP {
 		count := 0
 		sa.limiters.Range(func(_, _ interface{}) bool {
@@ -128,9 +150,33 @@ func MergeSpikeArrestConfig(perRoute, global config.SpikeArrestConfig) config.Sp
 }
 
 // SpikeArrestByRoute manages per-route spike arresters.
-type SpikeArrestByRoute = byroute.Factory[*SpikeArrester, config.SpikeArrestConfig]
+type SpikeArrestByRoute struct {
+	byroute.Manager[*SpikeArrester]
+	onObserved func(routeID string)
+}
 
 // NewSpikeArrestByRoute creates a new per-route spike arrest manager.
 func NewSpikeArrestByRoute() *SpikeArrestByRoute {
-	return byroute.SimpleFactory(New, func(sa *SpikeArrester) any { return sa.Stats() })
+	return &SpikeArrestByRoute{}
+}
+
+// SetOnObservedReject sets the callback for every request an observe-mode
+// arrester would have rejected. It applies to routes added afterwards.
+func (m *SpikeArrestByRoute) SetOnObservedReject(fn func(routeID string)) {
+	m.onObserved = fn
+}
+
+// AddRoute creates a spike arrester for a route.
+func (m *SpikeArrestByRoute) AddRoute(routeID string, cfg config.SpikeArrestConfig) error {
+	sa := New(cfg)
+	if fn := m.onObserved; fn != nil && sa.observe != nil {
+		sa.onObserved = func() { fn(routeID) }
+	}
+	m.Add(routeID, sa)
+	return nil
+}
+
+// Stats returns per-route spike arrest stats.
+func (m *SpikeArrestByRoute) Stats() map[string]any {
+	return byroute.CollectStats(&m.Manager, func(sa *SpikeArrester) any { return sa.Stats() })
 }
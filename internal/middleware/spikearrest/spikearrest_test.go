@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/middleware/ratelimit"
 )
 
 func TestSpikeArrester_AllowsWithinRate(t *testing.T) {
@@ -68,6 +69,49 @@ func TestSpikeArrester_RejectsOverBurst(t *testing.T) {
 	}
 }
 
+func TestSpikeArrester_ObserveMode(t *testing.T) {
+	m := NewSpikeArrestByRoute()
+	var observed []string
+	m.SetOnObservedReject(func(routeID string) { observed = append(observed, routeID) })
+	m.AddRoute("api", config.SpikeArrestConfig{
+		Enabled: true,
+		Rate:    5,
+		Period:  time.Second,
+		Burst:   5,
+		PerIP:   true,
+		Mode:    "observe",
+	})
+
+	handler := m.Lookup("api").Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	tagged := 0
+	for i := 0; i < 20; i++ {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected observe mode to never reject, got %d", rr.Code)
+		}
+		if rr.Header().Get(ratelimit.WouldRejectHeader) == "true" {
+			tagged++
+		}
+	}
+
+	if tagged != 15 {
+		t.Errorf("expected 15 requests tagged, got %d", tagged)
+	}
+	if len(observed) != 15 || observed[0] != "api" {
+		t.Errorf("expected 15 observed rejections for api, got %v", observed)
+	}
+	stats := m.Lookup("api").Stats()
+	obs := stats["observed_rejections"].(ratelimit.ObservedRejections)
+	if stats["rejected"].(int64) != 0 || obs.Total != 15 || obs.TopKeys[0].Key != "10.0.0.1" {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
 func TestSpikeArrester_PerIP(t *testing.T) {
 	sa := New(config.SpikeArrestConfig{
 		Enabled: true,
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package spillbuf
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the first size bytes of f; mapping is not used here.
+func mapFile(f *os.File, size int64) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(io.NewSectionReader(f, 0, size), data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func unmapFile([]byte) error {
+	return nil
+}
@@ -0,0 +1,417 @@
+// Package spillbuf buffers response bodies for middleware that must see the
+// whole body before rewriting it. Bodies up to a memory threshold stay in
+// pooled memory; larger ones spill to a temp file, bounded per request and
+// by a disk budget shared by all requests. Bodies over a hard maximum are
+// refused, and the middleware answers 502 instead of exhausting memory.
+package spillbuf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	gatewayerrors "github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/middleware"
+)
+
+// Defaults for unset config fields.
+const (
+	DefaultMemoryThreshold = 1 << 20   // 1MiB
+	DefaultMaxSize         = 256 << 20 // 256MiB
+	DefaultDiskBudget      = 1 << 30   // 1GiB
+
+	// maxPooled keeps buffers that grew for unusually large bodies out of
+	// the pool.
+	maxPooled = 4 << 20
+)
+
+var (
+	// ErrTooLarge is returned by Write once the body exceeds max_size.
+	ErrTooLarge = errors.New("spillbuf: response body exceeds max_size")
+	// ErrDiskBudget is returned by Write when spilling would exceed
+	// max_disk_per_request or the global disk_budget.
+	ErrDiskBudget = errors.New("spillbuf: spill disk budget exhausted")
+)
+
+var memPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// Disk is the spill directory and the budget shared by all buffered bodies.
+type Disk struct {
+	dir    string
+	budget int64
+	used   *atomic.Int64 // shared with the Disks built across reloads
+}
+
+// NewDisk builds a Disk from the global response_buffering config. prev is
+// the Disk being replaced on reload; its usage carries over so spill files
+// of in-flight requests stay counted.
+func NewDisk(cfg config.ResponseBufferingConfig, prev *Disk) *Disk {
+	d := &Disk{dir: cfg.SpillDir, budget: cfg.DiskBudget, used: new(atomic.Int64)}
+	if d.dir == "" {
+		d.dir = os.TempDir()
+	}
+	if d.budget == 0 {
+		d.budget = DefaultDiskBudget
+	}
+	if prev != nil {
+		d.used = prev.used
+	}
+	return d
+}
+
+// Usage returns the bytes currently held in spill files.
+func (d *Disk) Usage() int64 {
+	return d.used.Load()
+}
+
+func (d *Disk) reserve(n int64) bool {
+	for {
+		cur := d.used.Load()
+		if cur+n > d.budget {
+			return false
+		}
+		if d.used.CompareAndSwap(cur, cur+n) {
+			return true
+		}
+	}
+}
+
+func (d *Disk) release(n int64) {
+	d.used.Add(-n)
+}
+
+// Stats returns the directory, budget and usage for the admin API.
+func (d *Disk) Stats() map[string]any {
+	return map[string]any{
+		"spill_dir":        d.dir,
+		"disk_budget":      d.budget,
+		"disk_usage_bytes": d.used.Load(),
+	}
+}
+
+// Buffering holds one route's buffering limits and stats.
+type Buffering struct {
+	memThreshold int64
+	maxSize      int64
+	maxDisk      int64
+	disk         *Disk
+
+	buffered     atomic.Int64
+	spills       atomic.Int64
+	bytesSpilled atomic.Int64
+	aborts       atomic.Int64
+}
+
+// New creates a Buffering for a route. disk is shared by all routes.
+func New(cfg config.ResponseBufferingConfig, disk *Disk) *Buffering {
+	b := &Buffering{
+		memThreshold: cfg.MemoryThreshold,
+		maxSize:      cfg.MaxSize,
+		maxDisk:      cfg.MaxDiskPerRequest,
+		disk:         disk,
+	}
+	if b.memThreshold == 0 {
+		b.memThreshold = DefaultMemoryThreshold
+	}
+	if b.maxSize == 0 {
+		b.maxSize = DefaultMaxSize
+	}
+	if b.maxDisk == 0 {
+		b.maxDisk = b.maxSize
+	}
+	if b.disk == nil {
+		b.disk = NewDisk(config.ResponseBufferingConfig{}, nil)
+	}
+	return b
+}
+
+// Stats returns the route's limits and counters.
+func (b *Buffering) Stats() map[string]any {
+	return map[string]any{
+		"memory_threshold":     b.memThreshold,
+		"max_size":             b.maxSize,
+		"max_disk_per_request": b.maxDisk,
+		"buffered":             b.buffered.Load(),
+		"spills":               b.spills.Load(),
+		"bytes_spilled":        b.bytesSpilled.Load(),
+		"aborts":               b.aborts.Load(),
+	}
+}
+
+type ctxKey struct{}
+
+// tracker is the per-request state placed in the context by Middleware.
+type tracker struct {
+	b       *Buffering
+	mu      sync.Mutex
+	buffers []*Buffer
+}
+
+// Middleware makes the route's limits available to the buffering
+// middleware further down the chain and closes every buffer they opened
+// when the request ends, including on panic or client abort.
+func (b *Buffering) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := &tracker{b: b}
+			defer t.closeAll()
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, t)))
+		})
+	}
+}
+
+func (t *tracker) closeAll() {
+	t.mu.Lock()
+	buffers := t.buffers
+	t.buffers = nil
+	t.mu.Unlock()
+	for _, buf := range buffers {
+		buf.Close()
+	}
+}
+
+// NewBuffer returns a buffer using the limits of the route serving r, or nil
+// when the route has no response_buffering. The buffer is closed when the
+// request ends; callers may close it earlier.
+func NewBuffer(r *http.Request) *Buffer {
+	t, _ := r.Context().Value(ctxKey{}).(*tracker)
+	if t == nil {
+		return nil
+	}
+	buf := t.b.newBuffer()
+	t.mu.Lock()
+	t.buffers = append(t.buffers, buf)
+	t.mu.Unlock()
+	return buf
+}
+
+func (b *Buffering) newBuffer() *Buffer {
+	b.buffered.Add(1)
+	return &Buffer{b: b, mem: memPool.Get().(*bytes.Buffer)}
+}
+
+// Buffer holds one response body, in memory up to the route's threshold and
+// in a temp file beyond it. It is not safe for concurrent use.
+type Buffer struct {
+	b    *Buffering
+	mem  *bytes.Buffer
+	file *os.File
+	size int64
+	err  error
+
+	reserved int64  // disk budget held by the spill file
+	mapped   []byte // Bytes of a spilled body
+	closed   bool
+}
+
+// Write appends p. Once the body would exceed max_size or the disk budget,
+// Write returns ErrTooLarge or ErrDiskBudget and keeps returning it.
+func (buf *Buffer) Write(p []byte) (int, error) {
+	if buf.err != nil {
+		return 0, buf.err
+	}
+	if buf.closed {
+		return 0, os.ErrClosed
+	}
+	n := int64(len(p))
+	if buf.size+n > buf.b.maxSize {
+		return 0, buf.fail(ErrTooLarge)
+	}
+	if buf.file == nil && buf.size+n <= buf.b.memThreshold {
+		buf.mem.Write(p)
+		buf.size += n
+		return len(p), nil
+	}
+	if buf.file == nil {
+		if err := buf.spill(); err != nil {
+			return 0, buf.fail(err)
+		}
+	}
+	if buf.reserved+n > buf.b.maxDisk || !buf.b.disk.reserve(n) {
+		return 0, buf.fail(ErrDiskBudget)
+	}
+	buf.reserved += n
+	if _, err := buf.file.Write(p); err != nil {
+		return 0, buf.fail(err)
+	}
+	buf.size += n
+	buf.b.bytesSpilled.Add(n)
+	return len(p), nil
+}
+
+// spill moves the in-memory bytes to a new temp file.
+func (buf *Buffer) spill() error {
+	n := int64(buf.mem.Len())
+	if n > buf.b.maxDisk || !buf.b.disk.reserve(n) {
+		return ErrDiskBudget
+	}
+	buf.reserved = n
+	f, err := os.CreateTemp(buf.b.disk.dir, "runway-spill-*")
+	if err != nil {
+		return fmt.Errorf("spillbuf: %w", err)
+	}
+	buf.file = f
+	if _, err := f.Write(buf.mem.Bytes()); err != nil {
+		return fmt.Errorf("spillbuf: %w", err)
+	}
+	buf.b.spills.Add(1)
+	buf.b.bytesSpilled.Add(n)
+	buf.putMem()
+	return nil
+}
+
+func (buf *Buffer) fail(err error) error {
+	buf.err = err
+	buf.b.aborts.Add(1)
+	return err
+}
+
+// Err returns the error that stopped Write, if any.
+func (buf *Buffer) Err() error {
+	return buf.err
+}
+
+// Len returns the number of bytes buffered.
+func (buf *Buffer) Len() int64 {
+	return buf.size
+}
+
+// Spilled reports whether the body moved to disk.
+func (buf *Buffer) Spilled() bool {
+	return buf.file != nil
+}
+
+// Reader returns a reader over the buffered body. Each call starts at the
+// beginning of the body.
+func (buf *Buffer) Reader() io.ReadSeeker {
+	if buf.file != nil {
+		return io.NewSectionReader(buf.file, 0, buf.size)
+	}
+	return bytes.NewReader(buf.mem.Bytes())
+}
+
+// Bytes returns the whole body. A spilled body is mapped from its file where
+// the platform allows, so it does not count against the heap. The slice is
+// valid until Close.
+func (buf *Buffer) Bytes() ([]byte, error) {
+	if buf.file == nil {
+		return buf.mem.Bytes(), nil
+	}
+	if buf.mapped == nil && buf.size > 0 {
+		data, err := mapFile(buf.file, buf.size)
+		if err != nil {
+			return nil, fmt.Errorf("spillbuf: %w", err)
+		}
+		buf.mapped = data
+	}
+	return buf.mapped, nil
+}
+
+// WriteTo copies the body to w.
+func (buf *Buffer) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, buf.Reader())
+}
+
+// Close removes the spill file, releases its disk budget and returns the
+// memory to the pool. It is safe to call more than once.
+func (buf *Buffer) Close() error {
+	if buf.closed {
+		return nil
+	}
+	buf.closed = true
+	var err error
+	if buf.mapped != nil {
+		err = unmapFile(buf.mapped)
+		buf.mapped = nil
+	}
+	if buf.file != nil {
+		buf.file.Close()
+		if rerr := os.Remove(buf.file.Name()); rerr != nil && err == nil {
+			err = rerr
+		}
+		buf.file = nil
+	}
+	if buf.reserved > 0 {
+		buf.b.disk.release(buf.reserved)
+		buf.reserved = 0
+	}
+	buf.putMem()
+	return err
+}
+
+func (buf *Buffer) putMem() {
+	if buf.mem == nil {
+		return
+	}
+	if buf.mem.Cap() <= maxPooled {
+		buf.mem.Reset()
+		memPool.Put(buf.mem)
+	}
+	buf.mem = nil
+}
+
+// WriteAbort answers a request whose body could not be buffered with a 502
+// naming the limit that was hit.
+func WriteAbort(w http.ResponseWriter, err error) {
+	detail := "Response body could not be buffered"
+	switch {
+	case errors.Is(err, ErrTooLarge):
+		detail = "Response body exceeds the route's response_buffering.max_size"
+	case errors.Is(err, ErrDiskBudget):
+		detail = "Response body exceeds the response_buffering disk budget"
+	}
+	gatewayerrors.ErrBadGateway.WithDetails(detail).WriteJSON(w)
+}
+
+// BufferingByRoute manages per-route Buffering over a shared Disk.
+type BufferingByRoute struct {
+	byroute.Manager[*Buffering]
+	diskMu sync.Mutex
+	disk   *Disk
+}
+
+// NewBufferingByRoute creates a new per-route buffering manager.
+func NewBufferingByRoute() *BufferingByRoute {
+	return &BufferingByRoute{disk: NewDisk(config.ResponseBufferingConfig{}, nil)}
+}
+
+// SetDisk sets the spill directory and budget used by routes added after.
+func (m *BufferingByRoute) SetDisk(d *Disk) {
+	m.diskMu.Lock()
+	defer m.diskMu.Unlock()
+	m.disk = d
+}
+
+// Disk returns the shared spill directory and budget.
+func (m *BufferingByRoute) Disk() *Disk {
+	m.diskMu.Lock()
+	defer m.diskMu.Unlock()
+	return m.disk
+}
+
+// AddRoute adds buffering limits for a route.
+func (m *BufferingByRoute) AddRoute(routeID string, cfg config.ResponseBufferingConfig) error {
+	m.Add(routeID, New(cfg, m.Disk()))
+	return nil
+}
+
+// Stats returns the shared disk usage and per-route stats.
+func (m *BufferingByRoute) Stats() map[string]any {
+	routes := byroute.CollectStats(&m.Manager, func(b *Buffering) any { return b.Stats() })
+	stats := m.Disk().Stats()
+	stats["routes"] = routes
+	return stats
+}
+
+// MergeResponseBufferingConfig merges per-route config with global config.
+func MergeResponseBufferingConfig(perRoute, global config.ResponseBufferingConfig) config.ResponseBufferingConfig {
+	return config.MergeNonZero(global, perRoute)
+}
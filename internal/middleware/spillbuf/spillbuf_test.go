@@ -0,0 +1,176 @@
+package spillbuf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+func testBuffering(t *testing.T, cfg config.ResponseBufferingConfig, budget int64) (*Buffering, string) {
+	t.Helper()
+	dir := t.TempDir()
+	disk := NewDisk(config.ResponseBufferingConfig{SpillDir: dir, DiskBudget: budget}, nil)
+	return New(cfg, disk), dir
+}
+
+func spillFiles(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestBuffer_Spill(t *testing.T) {
+	b, dir := testBuffering(t, config.ResponseBufferingConfig{MemoryThreshold: 8, MaxSize: 1024}, 0)
+	buf := b.newBuffer()
+
+	buf.Write([]byte("small"))
+	if buf.Spilled() || spillFiles(t, dir) != 0 {
+		t.Fatal("expected a body under the threshold to stay in memory")
+	}
+	buf.Write([]byte(" body, now spilled"))
+	if !buf.Spilled() || spillFiles(t, dir) != 1 {
+		t.Fatal("expected the body to spill past the threshold")
+	}
+
+	want := "small body, now spilled"
+	got, err := io.ReadAll(buf.Reader())
+	if err != nil || string(got) != want {
+		t.Errorf("Reader = %q, %v", got, err)
+	}
+	data, err := buf.Bytes()
+	if err != nil || string(data) != want {
+		t.Errorf("Bytes = %q, %v", data, err)
+	}
+	if b.disk.Usage() != int64(len(want)) {
+		t.Errorf("expected %d bytes of disk usage, got %d", len(want), b.disk.Usage())
+	}
+
+	buf.Close()
+	if spillFiles(t, dir) != 0 || b.disk.Usage() != 0 {
+		t.Errorf("expected Close to remove the spill file, usage %d", b.disk.Usage())
+	}
+	stats := b.Stats()
+	if stats["spills"] != int64(1) || stats["bytes_spilled"] != int64(len(want)) || stats["aborts"] != int64(0) {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
+
+func TestBuffer_MaxSize(t *testing.T) {
+	b, dir := testBuffering(t, config.ResponseBufferingConfig{MemoryThreshold: 4, MaxSize: 16}, 0)
+	buf := b.newBuffer()
+	defer buf.Close()
+
+	if _, err := buf.Write(bytes.Repeat([]byte("a"), 10)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buf.Write(bytes.Repeat([]byte("a"), 10)); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge past max_size, got %v", err)
+	}
+	if _, err := buf.Write([]byte("a")); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected the error to stick, got %v", err)
+	}
+	if b.Stats()["aborts"] != int64(1) {
+		t.Errorf("expected 1 abort, got %v", b.Stats()["aborts"])
+	}
+	buf.Close()
+	if spillFiles(t, dir) != 0 || b.disk.Usage() != 0 {
+		t.Error("expected the aborted buffer's spill file to be removed")
+	}
+}
+
+func TestBuffer_DiskBudget(t *testing.T) {
+	b, _ := testBuffering(t, config.ResponseBufferingConfig{MemoryThreshold: 4, MaxSize: 1024}, 32)
+	first := b.newBuffer()
+	defer first.Close()
+	if _, err := first.Write(bytes.Repeat([]byte("a"), 24)); err != nil {
+		t.Fatal(err)
+	}
+
+	second := b.newBuffer()
+	defer second.Close()
+	if _, err := second.Write(bytes.Repeat([]byte("b"), 16)); !errors.Is(err, ErrDiskBudget) {
+		t.Fatalf("expected ErrDiskBudget once the shared budget is used, got %v", err)
+	}
+
+	perRequest, _ := testBuffering(t, config.ResponseBufferingConfig{MemoryThreshold: 4, MaxSize: 1024, MaxDiskPerRequest: 8}, 0)
+	third := perRequest.newBuffer()
+	defer third.Close()
+	if _, err := third.Write(bytes.Repeat([]byte("c"), 16)); !errors.Is(err, ErrDiskBudget) {
+		t.Errorf("expected ErrDiskBudget past max_disk_per_request, got %v", err)
+	}
+}
+
+func TestDisk_UsageSurvivesReload(t *testing.T) {
+	b, dir := testBuffering(t, config.ResponseBufferingConfig{MemoryThreshold: 4}, 0)
+	buf := b.newBuffer()
+	buf.Write(bytes.Repeat([]byte("a"), 10))
+
+	reloaded := NewDisk(config.ResponseBufferingConfig{SpillDir: dir}, b.disk)
+	if reloaded.Usage() != 10 {
+		t.Fatalf("expected in-flight spill usage to carry over, got %d", reloaded.Usage())
+	}
+	buf.Close()
+	if reloaded.Usage() != 0 {
+		t.Errorf("expected usage to drop once the old buffer closes, got %d", reloaded.Usage())
+	}
+}
+
+// spillHandler writes a body large enough to spill, then calls after.
+func spillHandler(t *testing.T, after func(r *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := NewBuffer(r)
+		if buf == nil {
+			t.Fatal("expected a buffer inside the middleware")
+		}
+		buf.Write(bytes.Repeat([]byte("x"), 64))
+		if !buf.Spilled() {
+			t.Fatal("expected the body to spill")
+		}
+		after(r)
+	})
+}
+
+func TestMiddleware_CleanupOnPanic(t *testing.T) {
+	b, dir := testBuffering(t, config.ResponseBufferingConfig{MemoryThreshold: 16}, 0)
+	h := b.Middleware()(spillHandler(t, func(*http.Request) { panic(http.ErrAbortHandler) }))
+
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	if spillFiles(t, dir) != 0 || b.disk.Usage() != 0 {
+		t.Errorf("expected the spill file removed after a panic, usage %d", b.disk.Usage())
+	}
+}
+
+func TestMiddleware_CleanupOnClientAbort(t *testing.T) {
+	b, dir := testBuffering(t, config.ResponseBufferingConfig{MemoryThreshold: 16}, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	h := b.Middleware()(spillHandler(t, func(r *http.Request) {
+		cancel()
+		<-r.Context().Done() // the handler gives up with the client
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+
+	if spillFiles(t, dir) != 0 || b.disk.Usage() != 0 {
+		t.Errorf("expected the spill file removed after the client went away, usage %d", b.disk.Usage())
+	}
+}
+
+func TestNewBuffer_NoBuffering(t *testing.T) {
+	if buf := NewBuffer(httptest.NewRequest("GET", "/", nil)); buf != nil {
+		t.Error("expected no buffer outside the middleware")
+	}
+}
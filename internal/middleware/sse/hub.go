@@ -14,12 +14,14 @@ import (
 
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/middleware/streamlimit"
 )
 
 // Hub manages a single upstream SSE connection and broadcasts events to connected clients.
 type Hub struct {
 	cfg      config.SSEFanoutConfig
 	balancer loadbalancer.Balancer
+	gate     *streamlimit.Gate // nil when the route has no stream limits
 
 	clients sync.Map // uint64 → *Client
 	buffer  *RingBuffer
@@ -49,6 +51,12 @@ func NewHub(cfg config.SSEFanoutConfig, balancer loadbalancer.Balancer) *Hub {
 	}
 }
 
+// SetStreamGate makes the upstream connection take a stream slot. Call it
+// before Start.
+func (h *Hub) SetStreamGate(gate *streamlimit.Gate) {
+	h.gate = gate
+}
+
 // Start begins the upstream connection loop in a background goroutine.
 func (h *Hub) Start() {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -192,12 +200,22 @@ func (h *Hub) connectLoop(ctx context.Context) {
 
 // connectUpstream establishes a single upstream SSE connection and reads events.
 func (h *Hub) connectUpstream(ctx context.Context) error {
-	backend := h.balancer.Next()
-	if backend == nil {
+	var backendURL string
+	if h.gate != nil {
+		picked, release, ok := h.gate.Pick(h.balancer, "", 0)
+		if !ok {
+			return fmt.Errorf("upstream stream limit reached")
+		}
+		defer release()
+		backendURL = picked
+	} else if backend := h.balancer.Next(); backend != nil {
+		backendURL = backend.URL
+	}
+	if backendURL == "" {
 		return fmt.Errorf("no backends available")
 	}
 
-	url := strings.TrimRight(backend.URL, "/")
+	url := strings.TrimRight(backendURL, "/")
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
@@ -11,7 +11,10 @@ import (
 
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/loadbalancer"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/streamlimit"
+	"github.com/wudi/runway/variables"
 )
 
 // SSEHandler manages SSE proxying for a single route.
@@ -29,6 +32,13 @@ type SSEHandler struct {
 	heartbeatsSent atomic.Int64
 
 	hub *Hub // non-nil when fan-out is enabled
+
+	// Stream limits for per-connection clients; see SetStreamGate.
+	gate            *streamlimit.Gate
+	balancer        func() loadbalancer.Balancer
+	overflow        *Hub // serves clients over the limit when on_limit is fanout
+	overflowOnce    sync.Once
+	overflowStarted atomic.Bool
 }
 
 // New creates an SSEHandler from config.
@@ -57,6 +67,14 @@ func (h *SSEHandler) Middleware() middleware.Middleware {
 				return
 			}
 
+			if h.gate != nil {
+				release, ok := h.admit(w, r)
+				if !ok {
+					return
+				}
+				defer release()
+			}
+
 			// Forward Last-Event-ID to backend
 			if h.forwardLastEventID {
 				if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
@@ -75,16 +93,78 @@ func (h *SSEHandler) Middleware() middleware.Middleware {
 	}
 }
 
+// admit takes an upstream stream slot for a per-connection client and pins
+// the proxy to the backend it was taken on. A client over the limit is
+// served from the overflow hub or rejected; admit then reports false.
+func (h *SSEHandler) admit(w http.ResponseWriter, r *http.Request) (release func(), ok bool) {
+	varCtx := variables.GetFromRequest(r)
+	var preferred string
+	if varCtx.Overrides != nil {
+		preferred = varCtx.Overrides.SwitchBackend
+	}
+	reserve := 0
+	if h.overflow != nil {
+		reserve = 1 // keep a slot for the overflow hub's own connection
+	}
+	backend, release, ok := h.gate.Pick(h.balancer(), preferred, reserve)
+	if !ok {
+		if h.overflow == nil {
+			h.gate.Reject(w)
+			return nil, false
+		}
+		h.overflowOnce.Do(func() {
+			h.overflow.Start()
+			h.overflowStarted.Store(true)
+		})
+		h.gate.RecordFanIn()
+		h.activeConns.Add(1)
+		h.totalConns.Add(1)
+		defer h.activeConns.Add(-1)
+		h.overflow.ServeClient(w, r)
+		return nil, false
+	}
+	if backend != "" {
+		if varCtx.Overrides == nil {
+			varCtx.Overrides = &variables.ValueOverrides{}
+		}
+		varCtx.Overrides.SwitchBackend = backend
+	}
+	return release, true
+}
+
+// SetStreamGate limits the upstream streams of per-connection clients.
+// balancer returns the route's current balancer. overflow, when non-nil,
+// serves the clients over the limit; it is started on first use.
+func (h *SSEHandler) SetStreamGate(gate *streamlimit.Gate, balancer func() loadbalancer.Balancer, overflow *Hub) {
+	h.gate = gate
+	h.balancer = balancer
+	h.overflow = overflow
+}
+
 // SetHub sets the fan-out hub for this handler.
 func (h *SSEHandler) SetHub(hub *Hub) {
 	h.hub = hub
 }
 
-// StopHub stops the fan-out hub if one is set.
+// StopHub stops the fan-out hub if one is set, and the overflow hub if it
+// was started.
 func (h *SSEHandler) StopHub() {
 	if h.hub != nil {
 		h.hub.Stop()
 	}
+	if h.overflowStarted.Load() {
+		h.overflow.Stop()
+	}
+}
+
+// ActiveConnections returns the number of open client streams.
+func (h *SSEHandler) ActiveConnections() int64 {
+	return h.activeConns.Load()
+}
+
+// Fanout reports whether clients are served from a fan-out hub.
+func (h *SSEHandler) Fanout() bool {
+	return h.hub != nil
 }
 
 // Stats returns handler statistics.
@@ -98,6 +178,9 @@ func (h *SSEHandler) Stats() map[string]interface{} {
 	if h.hub != nil {
 		stats["fanout"] = h.hub.Stats()
 	}
+	if h.overflowStarted.Load() {
+		stats["overflow_fanout"] = h.overflow.Stats()
+	}
 	return stats
 }
 
@@ -0,0 +1,147 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/middleware/streamlimit"
+	"github.com/wudi/runway/variables"
+)
+
+// limitedSSEServer serves h's middleware in front of a stand-in proxy that
+// holds every stream open and reports the backend it was pinned to.
+func limitedSSEServer(t *testing.T, h *SSEHandler, pinned chan<- string) *httptest.Server {
+	t.Helper()
+	proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		varCtx := variables.GetFromRequest(r)
+		pinned <- varCtx.Overrides.SwitchBackend
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	mw := h.Middleware()(proxy)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), variables.RequestContextKey{}, variables.NewContext(r))
+		mw.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func openStream(t *testing.T, url string) (*http.Response, context.CancelFunc) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	return resp, func() {
+		cancel()
+		resp.Body.Close()
+	}
+}
+
+func TestSSEStreamLimitRejects(t *testing.T) {
+	bal := loadbalancer.NewRoundRobin([]*loadbalancer.Backend{{URL: "http://notify:8080", Weight: 1, Healthy: true}})
+	gate := streamlimit.New(config.StreamLimitsConfig{MaxUpstreamStreams: 1, RetryAfter: 2 * time.Second},
+		streamlimit.NewRegistry().Get("route:r", 1), nil, "")
+	h := New(config.SSEConfig{Enabled: true})
+	h.SetStreamGate(gate, func() loadbalancer.Balancer { return bal }, nil)
+	pinned := make(chan string, 4)
+	srv := limitedSSEServer(t, h, pinned)
+
+	resp, closeFirst := openStream(t, srv.URL)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the first stream admitted, got %d", resp.StatusCode)
+	}
+	if got := <-pinned; got != "http://notify:8080" {
+		t.Errorf("expected the proxy pinned to the admitted backend, got %q", got)
+	}
+
+	resp2, closeSecond := openStream(t, srv.URL)
+	closeSecond()
+	if resp2.StatusCode != http.StatusServiceUnavailable || resp2.Header.Get("Retry-After") != "2" {
+		t.Fatalf("expected 503 with Retry-After 2 over the limit, got %d %q", resp2.StatusCode, resp2.Header.Get("Retry-After"))
+	}
+
+	closeFirst()
+	deadline := time.Now().Add(2 * time.Second)
+	for gate.Stats()["streams"].(map[string]int)["http://notify:8080"] != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	resp3, closeThird := openStream(t, srv.URL)
+	defer closeThird()
+	if resp3.StatusCode != http.StatusOK {
+		t.Errorf("expected a stream admitted once the slot is free, got %d", resp3.StatusCode)
+	}
+}
+
+func TestSSEStreamLimitFanoutOverflow(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: tick\n\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	bal := loadbalancer.NewRoundRobin([]*loadbalancer.Backend{{URL: upstream.URL, Weight: 1, Healthy: true}})
+	gate := streamlimit.New(config.StreamLimitsConfig{MaxUpstreamStreams: 2, OnLimit: "fanout"},
+		streamlimit.NewRegistry().Get("route:r", 2), nil, "")
+	fanoutCfg := config.SSEFanoutConfig{ReconnectDelay: 50 * time.Millisecond}
+	overflow := NewHub(fanoutCfg, bal)
+	overflow.SetStreamGate(gate)
+	h := New(config.SSEConfig{Enabled: true})
+	h.SetStreamGate(gate, func() loadbalancer.Balancer { return bal }, overflow)
+	defer h.StopHub()
+	pinned := make(chan string, 4)
+	srv := limitedSSEServer(t, h, pinned)
+
+	// One slot is kept for the hub, so the second client overflows.
+	_, closeFirst := openStream(t, srv.URL)
+	defer closeFirst()
+	<-pinned
+
+	resp, closeSecond := openStream(t, srv.URL)
+	defer closeSecond()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the overflow client served, got %d", resp.StatusCode)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "id: ") {
+		t.Fatalf("expected events from the shared hub, got %q (%v)", line, err)
+	}
+	select {
+	case b := <-pinned:
+		t.Errorf("the overflow client must not reach the proxy, pinned to %q", b)
+	default:
+	}
+
+	stats := gate.Stats()
+	if stats["fanout_clients"] != int64(1) || stats["streams"].(map[string]int)[upstream.URL] != 2 {
+		t.Errorf("expected one fanned-in client and two upstream streams, got %v", stats)
+	}
+	if _, ok := h.Stats()["overflow_fanout"]; !ok {
+		t.Error("expected overflow hub stats once it started")
+	}
+}
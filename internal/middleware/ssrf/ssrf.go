@@ -108,6 +108,16 @@ func (sd *SafeDialer) DialContext(ctx context.Context, network, addr string) (ne
 	return sd.inner.DialContext(ctx, network, resolvedAddr)
 }
 
+// Check returns an error if a connection to ip must be refused. Every refusal
+// is counted in the blocked-request stats.
+func (sd *SafeDialer) Check(ip net.IP) error {
+	if sd.isBlocked(ip) {
+		sd.blockedRequests.Add(1)
+		return fmt.Errorf("ssrf: connection to %s blocked (private/reserved IP)", ip)
+	}
+	return nil
+}
+
 // isBlocked returns true if the IP falls in a blocked range and is not in the allow list.
 func (sd *SafeDialer) isBlocked(ip net.IP) bool {
 	// Check allow list first (exempt from blocking)
@@ -0,0 +1,243 @@
+// Package streamlimit caps the long-lived SSE and WebSocket streams the
+// runway keeps open to each backend. A stream takes a slot before its backend
+// connection is established and gives it back when the stream ends, so a
+// reconnect storm can never open more streams than the cap.
+package streamlimit
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/loadbalancer"
+)
+
+// Limiter counts the open streams of each backend against a cap.
+type Limiter struct {
+	mu     sync.Mutex
+	max    int // 0 = unlimited, streams are only counted
+	counts map[string]int
+}
+
+// tryAcquire takes a slot on backend unless fewer than reserve+1 are free.
+func (l *Limiter) tryAcquire(backend string, reserve int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.max > 0 && l.counts[backend] >= l.max-reserve {
+		return false
+	}
+	l.counts[backend]++
+	return true
+}
+
+func (l *Limiter) release(backend string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n := l.counts[backend] - 1; n > 0 {
+		l.counts[backend] = n
+	} else {
+		delete(l.counts, backend)
+	}
+}
+
+// Max returns the per-backend cap, 0 when unlimited.
+func (l *Limiter) Max() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.max
+}
+
+// Counts returns the open streams per backend.
+func (l *Limiter) Counts() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counts := make(map[string]int, len(l.counts))
+	for b, n := range l.counts {
+		counts[b] = n
+	}
+	return counts
+}
+
+// Registry holds the limiters of routes and upstreams. It outlives config
+// reloads, so streams opened before a reload keep counting against the cap.
+type Registry struct {
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{limiters: make(map[string]*Limiter)}
+}
+
+// Get returns the limiter for key with its cap set to max, creating it on
+// first use.
+func (r *Registry) Get(key string, max int) *Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.limiters[key]
+	if !ok {
+		l = &Limiter{counts: make(map[string]int)}
+		r.limiters[key] = l
+	}
+	l.mu.Lock()
+	l.max = max
+	l.mu.Unlock()
+	return l
+}
+
+// Gate enforces the stream limits of one route: its own
+// max_upstream_streams and that of the upstream it uses.
+type Gate struct {
+	route      *Limiter
+	upstream   *Limiter // nil when the route has no upstream with a cap
+	upName     string
+	fanout     bool
+	retryAfter string
+
+	admitted atomic.Int64
+	rejected atomic.Int64
+	fannedIn atomic.Int64
+}
+
+// New creates a gate. route is the route's limiter; upstream, when non-nil,
+// is shared with every route using the upstream named upName.
+func New(cfg config.StreamLimitsConfig, route, upstream *Limiter, upName string) *Gate {
+	retryAfter := cfg.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = 5 * time.Second
+	}
+	return &Gate{
+		route:      route,
+		upstream:   upstream,
+		upName:     upName,
+		fanout:     cfg.OnLimit == "fanout",
+		retryAfter: strconv.Itoa(int((retryAfter + time.Second - 1) / time.Second)),
+	}
+}
+
+// Fanout reports whether clients over the limit are served from a shared
+// fan-out hub instead of being rejected.
+func (g *Gate) Fanout() bool {
+	return g.fanout
+}
+
+// Acquire takes a stream slot on backend from the route and upstream
+// limiters, leaving reserve slots free on each. The returned release gives
+// the slots back; it must be called exactly once.
+func (g *Gate) Acquire(backend string, reserve int) (release func(), ok bool) {
+	if !g.route.tryAcquire(backend, reserve) {
+		return nil, false
+	}
+	if g.upstream != nil && !g.upstream.tryAcquire(backend, reserve) {
+		g.route.release(backend)
+		return nil, false
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.route.release(backend)
+			if g.upstream != nil {
+				g.upstream.release(backend)
+			}
+		})
+	}, true
+}
+
+// Pick selects a backend with a free stream slot and takes the slot. A
+// preferred backend (from a switch_backend rule) is the only candidate when
+// set; otherwise each healthy backend is tried at most once in balancer
+// order. When no backend is available at all, Pick succeeds with an empty
+// URL and leaves the error to the proxy.
+func (g *Gate) Pick(bal loadbalancer.Balancer, preferred string, reserve int) (backend string, release func(), ok bool) {
+	if preferred != "" {
+		if b := bal.GetBackendByURL(preferred); b != nil && b.InRotation() {
+			return g.admit(preferred, reserve)
+		}
+	}
+	tried := make(map[string]bool)
+	for i := 0; i < len(bal.GetBackends()); i++ {
+		b := bal.Next()
+		if b == nil {
+			break
+		}
+		if tried[b.URL] {
+			continue
+		}
+		tried[b.URL] = true
+		if url, release, ok := g.admit(b.URL, reserve); ok {
+			return url, release, true
+		}
+	}
+	if len(tried) == 0 {
+		return "", func() {}, true
+	}
+	return "", nil, false
+}
+
+func (g *Gate) admit(backend string, reserve int) (string, func(), bool) {
+	release, ok := g.Acquire(backend, reserve)
+	if !ok {
+		return "", nil, false
+	}
+	g.admitted.Add(1)
+	return backend, release, true
+}
+
+// Reject answers a client over the limit with 503 and Retry-After.
+func (g *Gate) Reject(w http.ResponseWriter) {
+	g.rejected.Add(1)
+	w.Header().Set("Retry-After", g.retryAfter)
+	errors.ErrServiceUnavailable.WithDetails("Upstream stream limit reached").WriteJSON(w)
+}
+
+// RecordFanIn counts a client over the limit served from the fan-out hub.
+func (g *Gate) RecordFanIn() {
+	g.fannedIn.Add(1)
+}
+
+// Stats returns the gate's limits, open streams per backend and counters.
+func (g *Gate) Stats() map[string]interface{} {
+	onLimit := "reject"
+	if g.fanout {
+		onLimit = "fanout"
+	}
+	stats := map[string]interface{}{
+		"max_upstream_streams": g.route.Max(),
+		"on_limit":             onLimit,
+		"streams":              g.route.Counts(),
+		"admitted":             g.admitted.Load(),
+		"rejected":             g.rejected.Load(),
+		"fanout_clients":       g.fannedIn.Load(),
+	}
+	if g.upstream != nil {
+		stats["upstream"] = map[string]interface{}{
+			"name":                 g.upName,
+			"max_upstream_streams": g.upstream.Max(),
+			"streams":              g.upstream.Counts(),
+		}
+	}
+	return stats
+}
+
+// GateByRoute manages per-route stream gates.
+type GateByRoute struct {
+	byroute.Manager[*Gate]
+}
+
+// NewGateByRoute creates a new per-route stream gate manager.
+func NewGateByRoute() *GateByRoute {
+	return &GateByRoute{}
+}
+
+// Stats returns stats for all routes.
+func (m *GateByRoute) Stats() map[string]interface{} {
+	return byroute.CollectStats(&m.Manager, func(g *Gate) interface{} {
+		return g.Stats()
+	})
+}
@@ -0,0 +1,154 @@
+package streamlimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/loadbalancer"
+)
+
+func testBalancer(urls ...string) loadbalancer.Balancer {
+	backends := make([]*loadbalancer.Backend, len(urls))
+	for i, u := range urls {
+		backends[i] = &loadbalancer.Backend{URL: u, Weight: 1, Healthy: true}
+	}
+	return loadbalancer.NewRoundRobin(backends)
+}
+
+func TestGate_PickSpreadsAcrossBackends(t *testing.T) {
+	reg := NewRegistry()
+	g := New(config.StreamLimitsConfig{MaxUpstreamStreams: 2}, reg.Get("route:r", 2), nil, "")
+	bal := testBalancer("http://a", "http://b")
+
+	var releases []func()
+	for i := 0; i < 4; i++ {
+		_, release, ok := g.Pick(bal, "", 0)
+		if !ok {
+			t.Fatalf("pick %d: expected a free slot", i)
+		}
+		releases = append(releases, release)
+	}
+	if _, _, ok := g.Pick(bal, "", 0); ok {
+		t.Fatal("expected every backend to be full")
+	}
+	if _, _, ok := g.Pick(bal, "http://a", 0); ok {
+		t.Fatal("expected the preferred backend to be full")
+	}
+
+	releases[0]()
+	releases[0]() // a second call is a no-op
+	if got := reg.Get("route:r", 2).Counts(); got["http://a"]+got["http://b"] != 3 {
+		t.Errorf("expected 3 open streams after one release, got %v", got)
+	}
+
+	w := httptest.NewRecorder()
+	g.Reject(w)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "5" {
+		t.Errorf("expected 503 with Retry-After 5, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestGate_UpstreamSharedAcrossRoutes(t *testing.T) {
+	reg := NewRegistry()
+	up := reg.Get("upstream:notify", 3)
+	a := New(config.StreamLimitsConfig{}, reg.Get("route:a", 0), up, "notify")
+	b := New(config.StreamLimitsConfig{}, reg.Get("route:b", 0), up, "notify")
+
+	for i := 0; i < 2; i++ {
+		if _, ok := a.Acquire("http://x", 0); !ok {
+			t.Fatal("expected route a to acquire")
+		}
+	}
+	if _, ok := b.Acquire("http://x", 0); !ok {
+		t.Fatal("expected route b to take the last upstream slot")
+	}
+	if _, ok := b.Acquire("http://x", 0); ok {
+		t.Fatal("expected the upstream cap to apply across routes")
+	}
+	if got := reg.Get("route:b", 0).Counts()["http://x"]; got != 1 {
+		t.Errorf("a failed upstream acquire must give the route slot back, got %d", got)
+	}
+}
+
+func TestGate_Reserve(t *testing.T) {
+	reg := NewRegistry()
+	g := New(config.StreamLimitsConfig{MaxUpstreamStreams: 2, OnLimit: "fanout"}, reg.Get("route:r", 2), nil, "")
+	if _, ok := g.Acquire("http://a", 1); !ok {
+		t.Fatal("expected the first client to acquire")
+	}
+	if _, ok := g.Acquire("http://a", 1); ok {
+		t.Fatal("expected the reserved slot to be kept for the hub")
+	}
+	if _, ok := g.Acquire("http://a", 0); !ok {
+		t.Fatal("expected the hub to take the reserved slot")
+	}
+}
+
+func TestRegistry_CountsSurviveReload(t *testing.T) {
+	reg := NewRegistry()
+	old := New(config.StreamLimitsConfig{MaxUpstreamStreams: 2}, reg.Get("route:r", 2), nil, "")
+	release, _ := old.Acquire("http://a", 0)
+	old.Acquire("http://a", 0)
+
+	// The reloaded route gets a new gate on the same limiter.
+	reloaded := New(config.StreamLimitsConfig{MaxUpstreamStreams: 2}, reg.Get("route:r", 2), nil, "")
+	if _, ok := reloaded.Acquire("http://a", 0); ok {
+		t.Fatal("expected streams opened before the reload to count")
+	}
+	release()
+	if _, ok := reloaded.Acquire("http://a", 0); !ok {
+		t.Fatal("expected the slot released by the old gate to be free")
+	}
+}
+
+// TestGate_ReconnectStorm has many clients repeatedly connect at once and
+// checks the open streams never exceed the cap.
+func TestGate_ReconnectStorm(t *testing.T) {
+	const limit = 5
+	reg := NewRegistry()
+	g := New(config.StreamLimitsConfig{MaxUpstreamStreams: limit}, reg.Get("route:r", limit), reg.Get("upstream:u", limit), "u")
+	bal := testBalancer("http://a")
+
+	var open, peak, admitted atomic.Int64
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for round := 0; round < 50; round++ {
+				_, release, ok := g.Pick(bal, "", 0)
+				if !ok {
+					continue
+				}
+				admitted.Add(1)
+				n := open.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				open.Add(-1)
+				release()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if p := peak.Load(); p > limit {
+		t.Errorf("open streams peaked at %d, above the cap of %d", p, limit)
+	}
+	if admitted.Load() == 0 {
+		t.Error("expected some streams to be admitted")
+	}
+	if got := reg.Get("upstream:u", limit).Counts(); len(got) != 0 {
+		t.Errorf("expected every slot returned, got %v", got)
+	}
+}
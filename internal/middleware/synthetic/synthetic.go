@@ -0,0 +1,264 @@
+package synthetic
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/clock"
+	"github.com/wudi/runway/internal/health"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/realip"
+	"github.com/wudi/runway/variables"
+)
+
+const (
+	defaultHeader  = "X-Synthetic-Probe"
+	defaultMaxSkew = 5 * time.Minute
+)
+
+// HealthFunc returns the last known health status of each route backend,
+// keyed by backend URL.
+type HealthFunc func() map[string]health.Status
+
+// Monitor identifies synthetic monitor probes for a route.
+type Monitor struct {
+	header            string
+	secret            []byte
+	maxSkew           time.Duration
+	nets              []*net.IPNet
+	respondFromHealth bool
+	clock             clock.Clock // checks signature timestamps
+
+	probes          atomic.Int64
+	byCIDR          atomic.Int64
+	bySignature     atomic.Int64
+	rejected        atomic.Int64
+	healthResponses atomic.Int64
+}
+
+// Snapshot is a point-in-time copy of monitor settings and counters.
+type Snapshot struct {
+	Header            string   `json:"header,omitempty"`
+	SourceCIDRs       []string `json:"source_cidrs,omitempty"`
+	RespondFromHealth bool     `json:"respond_from_health"`
+	Probes            int64    `json:"probes"`
+	ByCIDR            int64    `json:"by_cidr"`
+	BySignature       int64    `json:"by_signature"`
+	Rejected          int64    `json:"rejected"`
+	HealthResponses   int64    `json:"health_responses"`
+}
+
+// New creates a Monitor from config.
+func New(cfg config.SyntheticMonitoringConfig) (*Monitor, error) {
+	m := &Monitor{
+		header:            cfg.Header,
+		secret:            []byte(cfg.Secret),
+		maxSkew:           cfg.MaxSkew,
+		respondFromHealth: cfg.RespondFromHealth,
+		clock:             clock.Default(),
+	}
+	if m.header == "" {
+		m.header = defaultHeader
+	}
+	if m.maxSkew == 0 {
+		m.maxSkew = defaultMaxSkew
+	}
+	for _, cidr := range cfg.SourceCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		m.nets = append(m.nets, ipNet)
+	}
+	return m, nil
+}
+
+// Sign returns a probe header value for the given method, path and time.
+// Monitors compute the same value to authenticate their probes.
+func Sign(secret, method, path string, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",sig=" + signature([]byte(secret), ts, method, path)
+}
+
+func signature(secret []byte, ts, method, path string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts + "\n" + method + "\n" + path))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Identify reports whether r is a synthetic probe. Source CIDRs are matched
+// against the trusted client IP; the probe header is only accepted with a
+// valid, fresh signature, so clients cannot opt out of limits by setting it.
+func (m *Monitor) Identify(r *http.Request) bool {
+	if len(m.nets) > 0 {
+		if ip := clientIP(r); ip != nil {
+			for _, n := range m.nets {
+				if n.Contains(ip) {
+					m.byCIDR.Add(1)
+					return true
+				}
+			}
+		}
+	}
+
+	value := r.Header.Get(m.header)
+	if value == "" || len(m.secret) == 0 {
+		return false
+	}
+	if m.verify(value, r.Method, r.URL.Path) {
+		m.bySignature.Add(1)
+		return true
+	}
+	m.rejected.Add(1)
+	return false
+}
+
+// verify checks a "t=<unix>,sig=<hex>" header value.
+func (m *Monitor) verify(value, method, path string) bool {
+	var ts, sig string
+	for _, part := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return false
+		}
+		switch k {
+		case "t":
+			ts = v
+		case "sig":
+			sig = v
+		}
+	}
+	if ts == "" || sig == "" {
+		return false
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	skew := m.clock.Now().Sub(time.Unix(unix, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > m.maxSkew {
+		return false
+	}
+	expected := signature(m.secret, ts, method, path)
+	return hmac.Equal([]byte(sig), []byte(expected))
+}
+
+// clientIP returns the trusted client IP, falling back to RemoteAddr.
+// X-Forwarded-For is never consulted directly.
+func clientIP(r *http.Request) net.IP {
+	if ip := realip.FromContext(r.Context()); ip != "" {
+		return net.ParseIP(ip)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// Middleware returns a middleware that marks identified probes on the
+// variable context so rate limiting, spike arrest and quota are skipped and
+// metrics and access logs treat them separately. The probe header is removed
+// before the request reaches the backend. healthFn is consulted when
+// respond_from_health is set and may be nil.
+func (m *Monitor) Middleware(healthFn HealthFunc) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			probe := m.Identify(r)
+			r.Header.Del(m.header)
+			if !probe {
+				next.ServeHTTP(w, r)
+				return
+			}
+			m.probes.Add(1)
+
+			varCtx := variables.GetFromRequest(r)
+			varCtx.Synthetic = true
+			varCtx.SkipFlags |= variables.SkipRateLimit | variables.SkipSpikeArrest | variables.SkipQuota
+
+			if m.respondFromHealth && healthFn != nil && m.writeHealth(w, healthFn()) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeHealth answers a probe from backend health. It returns false when no
+// backend has been checked yet, so the probe is proxied instead.
+func (m *Monitor) writeHealth(w http.ResponseWriter, statuses map[string]health.Status) bool {
+	healthy, known := 0, 0
+	for _, s := range statuses {
+		switch s {
+		case health.StatusHealthy, health.StatusDegraded:
+			healthy++
+			known++
+		case health.StatusUnhealthy:
+			known++
+		}
+	}
+	if known == 0 {
+		return false
+	}
+	m.healthResponses.Add(1)
+
+	status, code := "healthy", http.StatusOK
+	if healthy == 0 {
+		status, code = "unhealthy", http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   status,
+		"backends": statuses,
+	})
+	return true
+}
+
+// Snapshot returns a point-in-time copy of settings and counters.
+func (m *Monitor) Snapshot() Snapshot {
+	cidrs := make([]string, len(m.nets))
+	for i, n := range m.nets {
+		cidrs[i] = n.String()
+	}
+	s := Snapshot{
+		SourceCIDRs:       cidrs,
+		RespondFromHealth: m.respondFromHealth,
+		Probes:            m.probes.Load(),
+		ByCIDR:            m.byCIDR.Load(),
+		BySignature:       m.bySignature.Load(),
+		Rejected:          m.rejected.Load(),
+		HealthResponses:   m.healthResponses.Load(),
+	}
+	if len(m.secret) > 0 {
+		s.Header = m.header
+	}
+	return s
+}
+
+// MergeSyntheticMonitoringConfig merges per-route over global config.
+func MergeSyntheticMonitoringConfig(perRoute, global config.SyntheticMonitoringConfig) config.SyntheticMonitoringConfig {
+	return config.MergeNonZero(global, perRoute)
+}
+
+// SyntheticByRoute is a ByRoute manager for per-route synthetic monitoring.
+type SyntheticByRoute = byroute.Factory[*Monitor, config.SyntheticMonitoringConfig]
+
+// NewSyntheticByRoute creates a new manager.
+func NewSyntheticByRoute() *SyntheticByRoute {
+	return byroute.NewFactory(New, func(m *Monitor) any { return m.Snapshot() })
+}
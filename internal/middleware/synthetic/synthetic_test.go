@@ -0,0 +1,227 @@
+package synthetic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/clock"
+	"github.com/wudi/runway/internal/health"
+	"github.com/wudi/runway/variables"
+)
+
+func newRequest(method, path string) (*http.Request, *variables.Context) {
+	r := httptest.NewRequest(method, path, nil)
+	varCtx := variables.NewContext(r)
+	r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx))
+	return r, varCtx
+}
+
+func mustNew(t *testing.T, cfg config.SyntheticMonitoringConfig) *Monitor {
+	t.Helper()
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSignedHeaderIdentifiesProbe(t *testing.T) {
+	m := mustNew(t, config.SyntheticMonitoringConfig{Enabled: true, Secret: "s3cret"})
+
+	r, varCtx := newRequest("GET", "/api/health")
+	r.Header.Set(defaultHeader, Sign("s3cret", "GET", "/api/health", time.Now()))
+
+	var sawHeader string
+	h := m.Middleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sawHeader = r.Header.Get(defaultHeader)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !varCtx.Synthetic {
+		t.Fatal("expected request to be marked synthetic")
+	}
+	want := variables.SkipRateLimit | variables.SkipSpikeArrest | variables.SkipQuota
+	if varCtx.SkipFlags&want != want {
+		t.Errorf("expected skip flags %b, got %b", want, varCtx.SkipFlags)
+	}
+	if sawHeader != "" {
+		t.Errorf("expected probe header to be stripped, got %q", sawHeader)
+	}
+	if s := m.Snapshot(); s.Probes != 1 || s.BySignature != 1 {
+		t.Errorf("unexpected snapshot: %+v", s)
+	}
+}
+
+func TestSignatureSkewUsesClock(t *testing.T) {
+	m := mustNew(t, config.SyntheticMonitoringConfig{Enabled: true, Secret: "s3cret"})
+	signed := time.Unix(1700000000, 0)
+	fake := clock.NewFake(signed)
+	m.clock = fake
+
+	probe := func() bool {
+		r, varCtx := newRequest("GET", "/api")
+		r.Header.Set(defaultHeader, Sign("s3cret", "GET", "/api", signed))
+		m.Middleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), r)
+		return varCtx.Synthetic
+	}
+	if !probe() {
+		t.Fatal("expected probe signed at the clock's time to be accepted")
+	}
+	fake.Advance(10 * time.Minute)
+	if probe() {
+		t.Error("expected probe to be rejected once the clock moved past max_skew")
+	}
+}
+
+func TestSpoofedHeaderRejected(t *testing.T) {
+	m := mustNew(t, config.SyntheticMonitoringConfig{Enabled: true, Secret: "s3cret"})
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"wrong secret", Sign("guess", "GET", "/api", now)},
+		{"other path", Sign("s3cret", "GET", "/other", now)},
+		{"other method", Sign("s3cret", "POST", "/api", now)},
+		{"stale", Sign("s3cret", "GET", "/api", now.Add(-10*time.Minute))},
+		{"future", Sign("s3cret", "GET", "/api", now.Add(10*time.Minute))},
+		{"garbage", "true"},
+		{"missing sig", "t=1700000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, varCtx := newRequest("GET", "/api")
+			r.Header.Set(defaultHeader, tt.value)
+
+			var sawHeader string
+			h := m.Middleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sawHeader = r.Header.Get(defaultHeader)
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if varCtx.Synthetic || varCtx.SkipFlags != 0 {
+				t.Fatal("spoofed probe must not be marked synthetic")
+			}
+			if sawHeader != "" {
+				t.Error("expected probe header to be stripped")
+			}
+		})
+	}
+
+	if s := m.Snapshot(); s.Rejected != int64(len(tests)) || s.Probes != 0 {
+		t.Errorf("unexpected snapshot: %+v", s)
+	}
+}
+
+func TestHeaderIgnoredWithoutSecret(t *testing.T) {
+	m := mustNew(t, config.SyntheticMonitoringConfig{Enabled: true, SourceCIDRs: []string{"10.0.0.0/8"}})
+
+	r, _ := newRequest("GET", "/api")
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set(defaultHeader, "t=1,sig=00")
+	if m.Identify(r) {
+		t.Fatal("header must not identify a probe when no secret is configured")
+	}
+}
+
+func TestSourceCIDR(t *testing.T) {
+	m := mustNew(t, config.SyntheticMonitoringConfig{Enabled: true, SourceCIDRs: []string{"10.1.0.0/16"}})
+
+	r, _ := newRequest("GET", "/api")
+	r.RemoteAddr = "10.1.2.3:5555"
+	if !m.Identify(r) {
+		t.Fatal("expected probe from source CIDR")
+	}
+
+	// X-Forwarded-For alone must not be trusted.
+	r, _ = newRequest("GET", "/api")
+	r.RemoteAddr = "192.0.2.1:5555"
+	r.Header.Set("X-Forwarded-For", "10.1.2.3")
+	if m.Identify(r) {
+		t.Fatal("X-Forwarded-For must not identify a probe")
+	}
+
+	if s := m.Snapshot(); s.ByCIDR != 1 {
+		t.Errorf("expected 1 CIDR match, got %d", s.ByCIDR)
+	}
+}
+
+func TestRespondFromHealth(t *testing.T) {
+	m := mustNew(t, config.SyntheticMonitoringConfig{
+		Enabled:           true,
+		SourceCIDRs:       []string{"10.0.0.0/8"},
+		RespondFromHealth: true,
+	})
+
+	tests := []struct {
+		name     string
+		statuses map[string]health.Status
+		wantCode int
+		proxied  bool
+	}{
+		{"healthy", map[string]health.Status{"http://a": health.StatusHealthy, "http://b": health.StatusUnhealthy}, http.StatusOK, false},
+		{"all unhealthy", map[string]health.Status{"http://a": health.StatusUnhealthy}, http.StatusServiceUnavailable, false},
+		{"unknown falls through", map[string]health.Status{"http://a": health.StatusUnknown}, http.StatusTeapot, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxied := false
+			h := m.Middleware(func() map[string]health.Status { return tt.statuses })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				proxied = true
+				w.WriteHeader(http.StatusTeapot)
+			}))
+
+			r, _ := newRequest("GET", "/api")
+			r.RemoteAddr = "10.0.0.5:1234"
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected %d, got %d", tt.wantCode, rec.Code)
+			}
+			if proxied != tt.proxied {
+				t.Errorf("expected proxied=%v, got %v", tt.proxied, proxied)
+			}
+			if !tt.proxied {
+				var body map[string]interface{}
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatalf("invalid JSON body: %v", err)
+				}
+				if body["backends"] == nil {
+					t.Error("expected backends in body")
+				}
+			}
+		})
+	}
+}
+
+func TestNonProbePassesThrough(t *testing.T) {
+	m := mustNew(t, config.SyntheticMonitoringConfig{
+		Enabled:           true,
+		SourceCIDRs:       []string{"10.0.0.0/8"},
+		RespondFromHealth: true,
+	})
+
+	called := false
+	h := m.Middleware(func() map[string]health.Status {
+		return map[string]health.Status{"http://a": health.StatusHealthy}
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	r, varCtx := newRequest("GET", "/api")
+	r.RemoteAddr = "192.0.2.1:1234"
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !called || varCtx.Synthetic {
+		t.Fatal("expected regular request to reach the backend unmarked")
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/decision"
 	"github.com/wudi/runway/internal/middleware"
 	"github.com/wudi/runway/internal/middleware/quota"
 	"github.com/wudi/runway/variables"
@@ -50,7 +51,8 @@ type Manager struct {
 	defaultTenant string
 	rateLimiters  map[string]*rate.Limiter
 	quotaEnforcers map[string]*quota.QuotaEnforcer
-	mu            sync.RWMutex   // protects rateLimiters + quotaEnforcers
+	bandwidthEnforcers map[string]*quota.BandwidthEnforcer
+	mu            sync.RWMutex   // protects rateLimiters + quotaEnforcers + bandwidthEnforcers
 	writeMu       sync.Mutex     // serializes CUD operations
 	redisClient   *redis.Client  // for quota enforcers in CRUD
 	tiers         map[string]config.TenantTierConfig
@@ -75,7 +77,7 @@ func NewManager(cfg config.TenantsConfig, redisClient *redis.Client) *Manager {
 	for name, tc := range cfg.Tenants {
 		if tc.Tier != "" {
 			if tier, ok := cfg.Tiers[tc.Tier]; ok {
-				tc = mergeTenantWithTier(tc, tier)
+				tc = MergeTenantWithTier(tc, tier)
 			}
 		}
 		tenants[name] = tc
@@ -86,6 +88,7 @@ func NewManager(cfg config.TenantsConfig, redisClient *redis.Client) *Manager {
 		defaultTenant:       cfg.DefaultTenant,
 		rateLimiters:        make(map[string]*rate.Limiter),
 		quotaEnforcers:      make(map[string]*quota.QuotaEnforcer),
+		bandwidthEnforcers:  make(map[string]*quota.BandwidthEnforcer),
 		tenantAllowed:       make(map[string]*atomic.Int64),
 		tenantRejected:      make(map[string]*atomic.Int64),
 		tenantRateLimited:   make(map[string]*atomic.Int64),
@@ -125,11 +128,28 @@ func NewManager(cfg config.TenantsConfig, redisClient *redis.Client) *Manager {
 			}
 			m.quotaEnforcers[name] = quota.New("tenant:"+name, qcfg, redisClient)
 		}
+
+		if tc.BandwidthQuota != nil {
+			m.bandwidthEnforcers[name] = quota.NewBandwidth("tenant:"+name, bandwidthConfig(tc.BandwidthQuota), redisClient)
+		}
 	}
 
 	return m
 }
 
+// recordDecision records the tenant resolution of a recorded request.
+func recordDecision(r *http.Request, outcome, tenantID, reason string) {
+	rec := decision.FromContext(r.Context())
+	if rec == nil {
+		return
+	}
+	if reason == "" {
+		rec.Add("tenant", outcome, "tenant", tenantID)
+		return
+	}
+	rec.Add("tenant", outcome, "tenant", tenantID, "reason", reason)
+}
+
 // Middleware returns a middleware that resolves tenant and enforces policies.
 func (m *Manager) Middleware(routeAllowed []string, routeRequired bool) middleware.Middleware {
 	// Pre-compute allowed set for O(1) lookup
@@ -151,6 +171,7 @@ func (m *Manager) Middleware(routeAllowed []string, routeRequired bool) middlewa
 					tenantID = m.defaultTenant
 				} else if routeRequired {
 					m.rejected.Add(1)
+					recordDecision(r, decision.Deny, tenantID, "unknown tenant")
 					http.Error(w, "Unknown tenant", http.StatusForbidden)
 					return
 				} else {
@@ -168,6 +189,7 @@ func (m *Manager) Middleware(routeAllowed []string, routeRequired bool) middlewa
 					tc = m.state.Load().tenants[tenantID]
 				} else if routeRequired {
 					m.rejected.Add(1)
+					recordDecision(r, decision.Deny, tenantID, "unknown tenant")
 					http.Error(w, "Unknown tenant", http.StatusForbidden)
 					return
 				} else {
@@ -195,6 +217,7 @@ func (m *Manager) Middleware(routeAllowed []string, routeRequired bool) middlewa
 					if c := m.tenantRejected[tenantID]; c != nil {
 						c.Add(1)
 					}
+					recordDecision(r, decision.Deny, tenantID, "route not allowed")
 					http.Error(w, "Tenant not authorized for this route", http.StatusForbidden)
 					return
 				}
@@ -206,6 +229,7 @@ func (m *Manager) Middleware(routeAllowed []string, routeRequired bool) middlewa
 				if c := m.tenantRejected[tenantID]; c != nil {
 					c.Add(1)
 				}
+				recordDecision(r, decision.Deny, tenantID, "route not allowed")
 				http.Error(w, "Tenant not authorized for this route", http.StatusForbidden)
 				return
 			}
@@ -217,6 +241,7 @@ func (m *Manager) Middleware(routeAllowed []string, routeRequired bool) middlewa
 					if c := m.tenantRateLimited[tenantID]; c != nil {
 						c.Add(1)
 					}
+					recordDecision(r, decision.Deny, tenantID, "rate limit")
 					w.Header().Set("Retry-After", "1")
 					http.Error(w, "Tenant rate limit exceeded", http.StatusTooManyRequests)
 					return
@@ -235,6 +260,7 @@ func (m *Manager) Middleware(routeAllowed []string, routeRequired bool) middlewa
 					if c := m.tenantQuotaExceeded[tenantID]; c != nil {
 						c.Add(1)
 					}
+					recordDecision(r, decision.Deny, tenantID, "quota")
 					w.Header().Set("Retry-After", qw.header.Get("Retry-After"))
 					http.Error(w, "Tenant quota exceeded", http.StatusTooManyRequests)
 					return
@@ -245,6 +271,7 @@ func (m *Manager) Middleware(routeAllowed []string, routeRequired bool) middlewa
 			if c := m.tenantAllowed[tenantID]; c != nil {
 				c.Add(1)
 			}
+			recordDecision(r, decision.Resolve, tenantID, "")
 
 			// Store tenant info in context
 			info := &TenantInfo{
@@ -258,6 +285,7 @@ func (m *Manager) Middleware(routeAllowed []string, routeRequired bool) middlewa
 			// Set TenantID on variable context for logging / variable resolution
 			if varCtx := variables.GetFromRequest(r); varCtx != nil {
 				varCtx.TenantID = tenantID
+				varCtx.TenantTier = tc.Tier
 			}
 
 			// Per-tenant timeout: context.WithTimeout naturally uses the lesser of
@@ -282,6 +310,14 @@ func (m *Manager) Middleware(routeAllowed []string, routeRequired bool) middlewa
 				r.Header.Set("X-Tenant-"+k, v)
 			}
 
+			// Per-tenant bandwidth quota counts request and response bytes as they stream
+			if be, ok := m.bandwidthEnforcers[tenantID]; ok {
+				inner := next
+				next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					be.Serve(w, r, inner, tenantID)
+				})
+			}
+
 			// Wrap response writer for tenant usage analytics (conditional per architecture rules)
 			if metrics := m.tenantMetrics[tenantID]; metrics != nil {
 				tw := &tenantResponseWriter{ResponseWriter: w, status: 200}
@@ -331,6 +367,11 @@ func (m *Manager) Stats() map[string]interface{} {
 		if metrics := m.tenantMetrics[name]; metrics != nil {
 			ts["analytics"] = metrics.Snapshot()
 		}
+		if be := m.bandwidthEnforcer(name); be != nil {
+			bs := be.Stats()
+			bs["used"] = be.Usage(context.Background(), name)
+			ts["bandwidth"] = bs
+		}
 		tenantStats[name] = ts
 	}
 	return map[string]interface{}{
@@ -347,6 +388,16 @@ func (m *Manager) Close() {
 	for _, qe := range m.quotaEnforcers {
 		qe.Close()
 	}
+	for _, be := range m.bandwidthEnforcers {
+		be.Close()
+	}
+}
+
+// bandwidthEnforcer returns the bandwidth quota enforcer for a tenant, if any.
+func (m *Manager) bandwidthEnforcer(id string) *quota.BandwidthEnforcer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.bandwidthEnforcers[id]
 }
 
 // GetTenant returns the config for a tenant.
@@ -377,7 +428,7 @@ func (m *Manager) AddTenant(id string, cfg config.TenantConfig) error {
 	// Merge tier if specified
 	if cfg.Tier != "" {
 		if tier, ok := m.tiers[cfg.Tier]; ok {
-			cfg = mergeTenantWithTier(cfg, tier)
+			cfg = MergeTenantWithTier(cfg, tier)
 		}
 	}
 
@@ -416,7 +467,7 @@ func (m *Manager) UpdateTenant(id string, cfg config.TenantConfig) error {
 	// Merge tier if specified
 	if cfg.Tier != "" {
 		if tier, ok := m.tiers[cfg.Tier]; ok {
-			cfg = mergeTenantWithTier(cfg, tier)
+			cfg = MergeTenantWithTier(cfg, tier)
 		}
 	}
 
@@ -435,6 +486,10 @@ func (m *Manager) UpdateTenant(id string, cfg config.TenantConfig) error {
 		qe.Close()
 		delete(m.quotaEnforcers, id)
 	}
+	if be, ok := m.bandwidthEnforcers[id]; ok {
+		be.Close()
+		delete(m.bandwidthEnforcers, id)
+	}
 	delete(m.rateLimiters, id)
 	m.initTenantResources(id, cfg)
 	m.mu.Unlock()
@@ -467,6 +522,10 @@ func (m *Manager) RemoveTenant(id string) error {
 		qe.Close()
 		delete(m.quotaEnforcers, id)
 	}
+	if be, ok := m.bandwidthEnforcers[id]; ok {
+		be.Close()
+		delete(m.bandwidthEnforcers, id)
+	}
 	delete(m.rateLimiters, id)
 	m.mu.Unlock()
 
@@ -497,18 +556,36 @@ func (m *Manager) initTenantResources(id string, tc config.TenantConfig) {
 		}
 		m.quotaEnforcers[id] = quota.New("tenant:"+id, qcfg, m.redisClient)
 	}
+	if tc.BandwidthQuota != nil {
+		m.bandwidthEnforcers[id] = quota.NewBandwidth("tenant:"+id, bandwidthConfig(tc.BandwidthQuota), m.redisClient)
+	}
 }
 
-// mergeTenantWithTier merges tier defaults into a tenant config.
+// bandwidthConfig converts a tenant bandwidth quota to an enforcer config.
+// The enforcer is keyed by tenant ID, so no key expression is needed.
+func bandwidthConfig(bq *config.TenantBandwidthConfig) config.BandwidthQuotaConfig {
+	return config.BandwidthQuotaConfig{
+		Enabled: true,
+		Limit:   bq.Limit,
+		Period:  bq.Period,
+		Redis:   bq.Redis,
+		Mode:    bq.Mode,
+	}
+}
+
+// MergeTenantWithTier merges tier defaults into a tenant config.
 // Tenant-specific non-zero values override tier defaults.
 // For maps (metadata, response_headers), values are merged with tenant keys winning.
-func mergeTenantWithTier(tc config.TenantConfig, tier config.TenantTierConfig) config.TenantConfig {
+func MergeTenantWithTier(tc config.TenantConfig, tier config.TenantTierConfig) config.TenantConfig {
 	if tc.RateLimit == nil && tier.RateLimit != nil {
 		tc.RateLimit = tier.RateLimit
 	}
 	if tc.Quota == nil && tier.Quota != nil {
 		tc.Quota = tier.Quota
 	}
+	if tc.BandwidthQuota == nil && tier.BandwidthQuota != nil {
+		tc.BandwidthQuota = tier.BandwidthQuota
+	}
 	if tc.MaxBodySize == 0 && tier.MaxBodySize > 0 {
 		tc.MaxBodySize = tier.MaxBodySize
 	}
@@ -577,6 +654,13 @@ func buildKeyFunc(key string) func(*http.Request) string {
 			}
 			return ""
 		}
+	case strings.HasPrefix(key, "form:"):
+		// Captured from multipart uploads by the route's multipart_fields.
+		fieldName := key[len("form:"):]
+		return func(r *http.Request) string {
+			v, _ := variables.FormField(r, fieldName)
+			return v
+		}
 	default:
 		return func(r *http.Request) string { return "" }
 	}
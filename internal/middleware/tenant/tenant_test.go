@@ -1,12 +1,16 @@
 package tenant
 
 import (
+	"bytes"
+	"context"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
 )
 
 func okHandler() http.Handler {
@@ -42,6 +46,37 @@ func TestManager_ResolveTenantByHeader(t *testing.T) {
 	}
 }
 
+func TestManager_ResolveTenantByFormField(t *testing.T) {
+	cfg := config.TenantsConfig{
+		Enabled: true,
+		Key:     "form:tenant_id",
+		Tenants: map[string]config.TenantConfig{
+			"acme": {},
+		},
+	}
+	m := NewManager(cfg, nil)
+	defer m.Close()
+	handler := m.Middleware(nil, true)(okHandler())
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("tenant_id", "acme")
+	mw.Close()
+	req := httptest.NewRequest("POST", "/", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	varCtx := variables.NewContext(req)
+	req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+	if _, err := variables.ScanMultipart(req, variables.MultipartScan{Fields: []string{"tenant_id"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != 200 || w.Header().Get("X-Tenant-ID") != "acme" {
+		t.Errorf("expected tenant acme from the form field, got %d %q", w.Code, w.Header().Get("X-Tenant-ID"))
+	}
+}
+
 func TestManager_UnknownTenantRejected(t *testing.T) {
 	cfg := config.TenantsConfig{
 		Enabled: true,
@@ -229,6 +264,53 @@ func TestManager_QuotaEnforced(t *testing.T) {
 	}
 }
 
+func TestManager_BandwidthQuotaEnforced(t *testing.T) {
+	cfg := config.TenantsConfig{
+		Enabled: true,
+		Key:     "header:X-Tenant-ID",
+		Tiers: map[string]config.TenantTierConfig{
+			"free": {BandwidthQuota: &config.TenantBandwidthConfig{Limit: 100, Period: "daily"}},
+		},
+		Tenants: map[string]config.TenantConfig{
+			"acme": {Tier: "free"},
+		},
+	}
+	m := NewManager(cfg, nil)
+	defer m.Close()
+
+	handler := m.Middleware(nil, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 150))
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != 200 || w.Body.Len() != 150 {
+		t.Fatalf("expected full response, got %d with %d bytes", w.Code, w.Body.Len())
+	}
+	if w.Header().Get("X-Bandwidth-Limit") != "100" {
+		t.Errorf("expected X-Bandwidth-Limit=100, got %q", w.Header().Get("X-Bandwidth-Limit"))
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != 429 {
+		t.Errorf("expected 429 for bandwidth quota exceeded, got %d", w.Code)
+	}
+
+	ts := m.Stats()["tenants"].(map[string]interface{})["acme"].(map[string]interface{})
+	bs, ok := ts["bandwidth"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected bandwidth stats, got %v", ts)
+	}
+	if bs["used"] != int64(150) || bs["bytes_out"] != int64(150) || bs["rejected"] != int64(1) {
+		t.Errorf("unexpected bandwidth stats: %v", bs)
+	}
+}
+
 func TestManager_ContextPropagation(t *testing.T) {
 	cfg := config.TenantsConfig{
 		Enabled: true,
@@ -3,11 +3,13 @@ package timeout
 import (
 	"context"
 	"fmt"
+	"maps"
 	"net/http"
 	"time"
 
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/errors"
 	"github.com/wudi/runway/internal/middleware"
 	"github.com/wudi/runway/variables"
 )
@@ -46,8 +48,12 @@ func (ct *CompiledTimeout) Metrics() TimeoutSnapshot {
 }
 
 // Middleware returns an HTTP middleware that enforces the request-level timeout.
-// It sets context.WithTimeout on the request context and wraps the ResponseWriter
-// to inject a Retry-After header on 504 responses.
+// It sets context.WithTimeout on the request context. When the deadline
+// expires before the response has started, whatever the handler wrote is
+// discarded and a 504 with Retry-After and X-Timeout-Reason: request is written
+// through the normal response path, so error pages, metrics and access logs
+// see it. When the response had already started, the request is recorded as a
+// 504 and the connection is aborted.
 func (ct *CompiledTimeout) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		// If no request timeout is configured, pass through
@@ -57,8 +63,9 @@ func (ct *CompiledTimeout) Middleware() middleware.Middleware {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ct.metrics.TotalRequests.Add(1)
 
+			varCtx := variables.GetFromRequest(r)
 			timeout := ct.Request
-			if varCtx := variables.GetFromRequest(r); varCtx.Overrides != nil && varCtx.Overrides.TimeoutOverride > 0 {
+			if varCtx.Overrides != nil && varCtx.Overrides.TimeoutOverride > 0 {
 				// Override can only tighten, not loosen
 				if varCtx.Overrides.TimeoutOverride < timeout {
 					timeout = varCtx.Overrides.TimeoutOverride
@@ -68,50 +75,99 @@ func (ct *CompiledTimeout) Middleware() middleware.Middleware {
 			ctx, cancel := context.WithTimeout(r.Context(), timeout)
 			defer cancel()
 
-			rw := &retryAfterWriter{
+			// Headers set before the handler ran are the gateway's own
+			// (request ID, CORS, ...) and survive a timeout; the handler's
+			// are discarded with its response.
+			gatewayHeaders := w.Header().Clone()
+			tw := &timeoutWriter{
 				ResponseWriter: w,
+				ctx:            ctx,
 				retryAfter:     ct.retryAfter,
 			}
-			next.ServeHTTP(rw, r.WithContext(ctx))
+			next.ServeHTTP(tw, r.WithContext(ctx))
 
-			// Check if the context deadline was exceeded (timeout)
-			if ctx.Err() == context.DeadlineExceeded {
-				ct.metrics.RequestTimeouts.Add(1)
+			if ctx.Err() != context.DeadlineExceeded {
+				if varCtx.TimeoutReason == variables.TimeoutBackend {
+					ct.metrics.BackendTimeouts.Add(1)
+				}
+				return
+			}
+			// The client going away is not a timeout
+			if varCtx.ClientAbort != variables.AbortNone {
+				return
+			}
+
+			ct.metrics.RequestTimeouts.Add(1)
+			varCtx.TimeoutReason = variables.TimeoutRequest
+			if tw.started {
+				varCtx.AbortResponse = true
+				return
 			}
+
+			h := w.Header()
+			clear(h)
+			maps.Copy(h, gatewayHeaders)
+			h.Del("Content-Length")
+			h.Del("Content-Encoding")
+			h.Set("X-Timeout-Reason", variables.TimeoutRequest)
+			if ct.retryAfter != "" {
+				h.Set("Retry-After", ct.retryAfter)
+			}
+			errors.ErrGatewayTimeout.WriteJSON(w)
 		})
 	}
 }
 
-// retryAfterWriter intercepts WriteHeader to inject a Retry-After header on 504 responses.
-type retryAfterWriter struct {
+// timeoutWriter passes the response through until the request deadline
+// expires. Writes made after that, before the response started, are discarded
+// so the middleware can answer with its own 504. It also injects a Retry-After
+// header on 504 responses.
+type timeoutWriter struct {
 	http.ResponseWriter
-	retryAfter    string
-	headerWritten bool
+	ctx        context.Context
+	retryAfter string
+	started    bool // header written to the client
+	discarded  bool // deadline expired before the response started
 }
 
-func (w *retryAfterWriter) WriteHeader(code int) {
-	if !w.headerWritten {
-		w.headerWritten = true
-		if code == http.StatusGatewayTimeout && w.retryAfter != "" {
-			w.ResponseWriter.Header().Set("Retry-After", w.retryAfter)
-		}
+func (w *timeoutWriter) WriteHeader(code int) {
+	if w.started || w.discarded {
+		return
+	}
+	if w.ctx.Err() == context.DeadlineExceeded {
+		w.discarded = true
+		return
+	}
+	w.started = true
+	if code == http.StatusGatewayTimeout && w.retryAfter != "" {
+		w.ResponseWriter.Header().Set("Retry-After", w.retryAfter)
 	}
 	w.ResponseWriter.WriteHeader(code)
 }
 
-func (w *retryAfterWriter) Write(b []byte) (int, error) {
-	if !w.headerWritten {
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	if !w.started {
 		w.WriteHeader(http.StatusOK)
 	}
+	if w.discarded {
+		return len(b), nil
+	}
 	return w.ResponseWriter.Write(b)
 }
 
-func (w *retryAfterWriter) Flush() {
+func (w *timeoutWriter) Flush() {
+	if w.discarded {
+		return
+	}
 	if f, ok := w.ResponseWriter.(http.Flusher); ok {
 		f.Flush()
 	}
 }
 
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // TimeoutStatus describes the timeout configuration and metrics for a route.
 type TimeoutStatus struct {
 	Request       string          `json:"request,omitempty"`
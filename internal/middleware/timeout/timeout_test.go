@@ -1,12 +1,15 @@
 package timeout
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
 )
 
 func TestMiddlewareTimeoutFires(t *testing.T) {
@@ -130,10 +133,10 @@ func TestTimeoutByRouteAddGetStats(t *testing.T) {
 	}
 }
 
-func TestRetryAfterWriterInjectsOnlyOn504(t *testing.T) {
+func TestTimeoutWriterInjectsOnlyOn504(t *testing.T) {
 	// Test 504: should inject Retry-After
 	rec := httptest.NewRecorder()
-	w := &retryAfterWriter{ResponseWriter: rec, retryAfter: "30"}
+	w := &timeoutWriter{ResponseWriter: rec, ctx: context.Background(), retryAfter: "30"}
 	w.WriteHeader(http.StatusGatewayTimeout)
 	if rec.Header().Get("Retry-After") != "30" {
 		t.Error("expected Retry-After: 30 on 504")
@@ -141,7 +144,7 @@ func TestRetryAfterWriterInjectsOnlyOn504(t *testing.T) {
 
 	// Test 200: should NOT inject
 	rec2 := httptest.NewRecorder()
-	w2 := &retryAfterWriter{ResponseWriter: rec2, retryAfter: "30"}
+	w2 := &timeoutWriter{ResponseWriter: rec2, ctx: context.Background(), retryAfter: "30"}
 	w2.WriteHeader(http.StatusOK)
 	if rec2.Header().Get("Retry-After") != "" {
 		t.Error("did not expect Retry-After on 200")
@@ -186,18 +189,109 @@ func TestMetrics(t *testing.T) {
 	}
 }
 
-func TestRetryAfterWriterFlush(t *testing.T) {
+func TestTimeoutWriterFlush(t *testing.T) {
 	rec := httptest.NewRecorder()
-	w := &retryAfterWriter{ResponseWriter: rec, retryAfter: "30"}
+	w := &timeoutWriter{ResponseWriter: rec, ctx: context.Background(), retryAfter: "30"}
 	w.Flush()
 	// Just ensure it doesn't panic; httptest.ResponseRecorder implements Flusher
 }
 
-func TestRetryAfterWriterImplicitWriteHeader(t *testing.T) {
+func TestTimeoutWriterImplicitWriteHeader(t *testing.T) {
 	rec := httptest.NewRecorder()
-	w := &retryAfterWriter{ResponseWriter: rec, retryAfter: "30"}
+	w := &timeoutWriter{ResponseWriter: rec, ctx: context.Background(), retryAfter: "30"}
 	w.Write([]byte("data"))
 	if rec.Code != http.StatusOK {
 		t.Errorf("expected implicit 200, got %d", rec.Code)
 	}
 }
+
+func TestMiddlewareTimeoutReplacesHandlerResponse(t *testing.T) {
+	ct := New(config.TimeoutConfig{Request: 20 * time.Millisecond})
+	handler := ct.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		// Connection-level failure reported by the handler after the deadline
+		w.Header().Set("Content-Length", "11")
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Header().Set("X-Backend", "users-1")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("bad gateway"))
+	}))
+
+	varCtx := variables.NewContext(nil)
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+	rec := httptest.NewRecorder()
+	rec.Header().Set("X-Request-ID", "req-1")
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", rec.Code)
+	}
+	for _, h := range []string{"Set-Cookie", "X-Backend"} {
+		if got := rec.Header().Get(h); got != "" {
+			t.Errorf("expected handler's %s to be dropped, got %q", h, got)
+		}
+	}
+	if got := rec.Header().Get("X-Request-ID"); got != "req-1" {
+		t.Errorf("expected gateway X-Request-ID kept, got %q", got)
+	}
+	if got := rec.Header().Get("X-Timeout-Reason"); got != variables.TimeoutRequest {
+		t.Errorf("expected X-Timeout-Reason request, got %q", got)
+	}
+	if rec.Header().Get("Content-Length") != "" {
+		t.Error("expected handler's Content-Length to be dropped")
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected JSON error body, got %q", rec.Body.String())
+	}
+	if varCtx.TimeoutReason != variables.TimeoutRequest {
+		t.Errorf("expected TimeoutReason request, got %q", varCtx.TimeoutReason)
+	}
+	if varCtx.AbortResponse {
+		t.Error("did not expect abort before the response started")
+	}
+}
+
+func TestMiddlewareTimeoutAfterResponseStarted(t *testing.T) {
+	ct := New(config.TimeoutConfig{Request: 20 * time.Millisecond})
+	handler := ct.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		<-r.Context().Done()
+	}))
+
+	varCtx := variables.NewContext(nil)
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the started 200 to be kept, got %d", rec.Code)
+	}
+	if !varCtx.AbortResponse {
+		t.Error("expected the response to be marked for abort")
+	}
+	if got := varCtx.RecordedStatus(rec.Code); got != http.StatusGatewayTimeout {
+		t.Errorf("expected recorded status 504, got %d", got)
+	}
+}
+
+func TestMiddlewareCountsBackendTimeouts(t *testing.T) {
+	ct := New(config.TimeoutConfig{Request: time.Second})
+	handler := ct.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		variables.GetFromRequest(r).TimeoutReason = variables.TimeoutBackend
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+
+	varCtx := variables.NewContext(nil)
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	snap := ct.Metrics()
+	if snap.BackendTimeouts != 1 || snap.RequestTimeouts != 0 {
+		t.Errorf("expected 1 backend and 0 request timeouts, got %+v", snap)
+	}
+}
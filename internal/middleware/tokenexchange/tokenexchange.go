@@ -8,10 +8,11 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/internal/logging"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/auth"
 	"go.uber.org/zap"
 )
 
@@ -44,6 +45,8 @@ type ExchangeStatus struct {
 	CacheHits       int64  `json:"cache_hits"`
 	ValidationFails int64  `json:"validation_fails"`
 	IssueFails      int64  `json:"issue_fails"`
+
+	JWKS *auth.JWKSStats `json:"jwks,omitempty"` // jwt validation mode only
 }
 
 // New creates a TokenExchanger from config.
@@ -56,7 +59,11 @@ func New(routeID string, cfg config.TokenExchangeConfig) (*TokenExchanger, error
 	var validator SubjectValidator
 	switch cfg.ValidationMode {
 	case "jwt":
-		v, err := NewJWTValidator(cfg.JWKSURL, cfg.TrustedIssuers, 1*time.Hour)
+		v, err := NewJWTValidatorWithOptions(auth.JWKSOptions{
+			URLs:            cfg.JWKSEndpoints(),
+			RefreshInterval: 1 * time.Hour,
+			FetchTimeout:    cfg.JWKSFetchTimeout,
+		}, cfg.TrustedIssuers)
 		if err != nil {
 			return nil, err
 		}
@@ -136,7 +143,7 @@ func (te *TokenExchanger) Status() ExchangeStatus {
 	if te.cache != nil {
 		cacheSize = te.cache.size()
 	}
-	return ExchangeStatus{
+	st := ExchangeStatus{
 		RouteID:         te.routeID,
 		CacheSize:       cacheSize,
 		Total:           te.metrics.Total.Load(),
@@ -145,6 +152,18 @@ func (te *TokenExchanger) Status() ExchangeStatus {
 		ValidationFails: te.metrics.ValidationFails.Load(),
 		IssueFails:      te.metrics.IssueFails.Load(),
 	}
+	if v, ok := te.validator.(*JWTValidator); ok {
+		jwks := v.JWKSStats()
+		st.JWKS = &jwks
+	}
+	return st
+}
+
+// Close stops the subject token validator's background work.
+func (te *TokenExchanger) Close() {
+	if v, ok := te.validator.(*JWTValidator); ok {
+		v.Close()
+	}
 }
 
 // Middleware returns a middleware that performs token exchange.
@@ -182,10 +201,71 @@ func (te *TokenExchanger) Middleware() middleware.Middleware {
 	}
 }
 
-// TokenExchangeByRoute manages per-route token exchangers.
-type TokenExchangeByRoute = byroute.NamedFactory[*TokenExchanger, config.TokenExchangeConfig]
+// TokenExchangeByRoute manages per-route token exchangers. Routes that
+// reference a shared exchanger use its validator, signing key and exchange
+// cache directly.
+type TokenExchangeByRoute struct {
+	byroute.Manager[*TokenExchanger]
+	exchangers *byroute.Shared[*TokenExchanger, config.TokenExchangeConfig]
+}
+
+// NewTokenExchangeByRoute creates a new manager. exchangers are the named
+// definitions routes may reference via token_exchange.ref.
+func NewTokenExchangeByRoute(exchangers map[string]config.TokenExchangeConfig) *TokenExchangeByRoute {
+	newFn := func(name string, cfg config.TokenExchangeConfig) (*TokenExchanger, error) {
+		cfg.Enabled = true // shared definitions have no enabled flag of their own
+		return New(name, cfg)
+	}
+	return &TokenExchangeByRoute{
+		exchangers: byroute.NewShared(exchangers, newFn, func(te *TokenExchanger) any { return te.Status() }).
+			WithClose((*TokenExchanger).Close),
+	}
+}
+
+// AddRoute creates a token exchanger for the route, or attaches the route to
+// the referenced shared exchanger.
+func (m *TokenExchangeByRoute) AddRoute(routeID string, cfg config.TokenExchangeConfig) error {
+	var (
+		te  *TokenExchanger
+		err error
+	)
+	if cfg.Ref == "" {
+		te, err = New(routeID, cfg)
+	} else {
+		te, err = m.exchangers.Acquire(cfg.Ref, routeID)
+	}
+	if err != nil {
+		return err
+	}
+	m.Add(routeID, te)
+	return nil
+}
+
+// Stats returns status for routes with an inline token_exchange config.
+// Routes referencing a shared exchanger are reported by SharedStats.
+func (m *TokenExchangeByRoute) Stats() map[string]any {
+	result := make(map[string]any)
+	m.Range(func(id string, te *TokenExchanger) bool {
+		if _, shared := m.exchangers.RefOf(id); !shared {
+			result[id] = te.Status()
+		}
+		return true
+	})
+	return result
+}
+
+// SharedStats returns status per shared exchanger with the routes using it.
+func (m *TokenExchangeByRoute) SharedStats() map[string]byroute.SharedStats {
+	return m.exchangers.Stats()
+}
 
-// NewTokenExchangeByRoute creates a new manager.
-func NewTokenExchangeByRoute() *TokenExchangeByRoute {
-	return byroute.NewNamedFactory(New, func(te *TokenExchanger) any { return te.Status() })
+// CloseAll closes inline exchangers and every shared exchanger once.
+func (m *TokenExchangeByRoute) CloseAll() {
+	m.Range(func(id string, te *TokenExchanger) bool {
+		if _, shared := m.exchangers.RefOf(id); !shared {
+			te.Close()
+		}
+		return true
+	})
+	m.exchangers.CloseAll()
 }
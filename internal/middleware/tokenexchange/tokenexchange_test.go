@@ -12,12 +12,14 @@ import (
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/wudi/runway/config"
 )
 
 // testKeyPair generates RSA key pair and returns PEM-encoded private key and a JWKS server.
@@ -1289,7 +1291,7 @@ func TestExchangeMetricsConsistency(t *testing.T) {
 }
 
 func TestTokenExchangeByRoute_MultipleRoutes(t *testing.T) {
-	m := NewTokenExchangeByRoute()
+	m := NewTokenExchangeByRoute(nil)
 
 	// GetExchanger for nonexistent route
 	if m.Lookup("nonexistent") != nil {
@@ -1303,6 +1305,63 @@ func TestTokenExchangeByRoute_MultipleRoutes(t *testing.T) {
 	}
 }
 
+func TestTokenExchangeByRoute_SharedExchanger(t *testing.T) {
+	var introspections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		introspections.Add(1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"active": true,
+			"sub":    "user-1",
+			"iss":    "https://auth.example.com",
+		})
+	}))
+	defer server.Close()
+
+	m := NewTokenExchangeByRoute(map[string]config.TokenExchangeConfig{
+		"internal": {
+			ValidationMode:   "introspection",
+			IntrospectionURL: server.URL,
+			ClientID:         "runway",
+			ClientSecret:     "secret",
+			Issuer:           "https://gw.example.com",
+			SigningAlgorithm: "HS256",
+			SigningSecret:    "supersecretkeythatisatleast32bytes!",
+			TokenLifetime:    15 * time.Minute,
+			CacheTTL:         time.Minute,
+		},
+	})
+	defer m.CloseAll()
+	for _, id := range []string{"r1", "r2"} {
+		if err := m.AddRoute(id, config.TokenExchangeConfig{Ref: "internal"}); err != nil {
+			t.Fatalf("AddRoute(%s): %v", id, err)
+		}
+	}
+	if err := m.AddRoute("r3", config.TokenExchangeConfig{Ref: "missing"}); err == nil {
+		t.Error("expected error for unknown ref")
+	}
+
+	// The second route is served from the exchange cache the first one filled.
+	for _, id := range []string{"r1", "r2"} {
+		if _, err := m.Lookup(id).Exchange("subject-token"); err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+	}
+	if introspections.Load() != 1 {
+		t.Errorf("expected one introspection for the shared exchanger, got %d", introspections.Load())
+	}
+
+	shared := m.SharedStats()["internal"]
+	if len(shared.Routes) != 2 || shared.Routes[0] != "r1" {
+		t.Errorf("unexpected referencing routes: %v", shared.Routes)
+	}
+	if st := shared.Stats.(ExchangeStatus); st.Total != 2 || st.CacheHits != 1 {
+		t.Errorf("expected status shared across routes, got %+v", st)
+	}
+	if len(m.Stats()) != 0 {
+		t.Errorf("referencing routes should not appear in per-route stats: %v", m.Stats())
+	}
+}
+
 func TestJWTValidator_MultipleIssuers(t *testing.T) {
 	subjectKey, _, jwksServer := testKeyPair(t)
 	defer jwksServer.Close()
@@ -33,7 +33,13 @@ type JWTValidator struct {
 
 // NewJWTValidator creates a JWT validator using a JWKS URL.
 func NewJWTValidator(jwksURL string, trustedIssuers []string, refreshInterval time.Duration) (*JWTValidator, error) {
-	provider, err := auth.NewJWKSProvider(jwksURL, refreshInterval)
+	return NewJWTValidatorWithOptions(auth.JWKSOptions{URLs: []string{jwksURL}, RefreshInterval: refreshInterval}, trustedIssuers)
+}
+
+// NewJWTValidatorWithOptions creates a JWT validator fetching keys from
+// the JWKS URLs of opts, in failover order.
+func NewJWTValidatorWithOptions(opts auth.JWKSOptions, trustedIssuers []string) (*JWTValidator, error) {
+	provider, err := auth.NewJWKSProviderWithOptions(opts)
 	if err != nil {
 		return nil, fmt.Errorf("token exchange: JWKS setup failed: %w", err)
 	}
@@ -49,6 +55,16 @@ func NewJWTValidator(jwksURL string, trustedIssuers []string, refreshInterval ti
 	}, nil
 }
 
+// JWKSStats returns the JWKS provider's admin snapshot.
+func (v *JWTValidator) JWKSStats() auth.JWKSStats {
+	return v.jwks.Stats()
+}
+
+// Close stops the JWKS refresh.
+func (v *JWTValidator) Close() {
+	v.jwks.Close()
+}
+
 // Validate validates a JWT subject token.
 func (v *JWTValidator) Validate(tokenStr, _ string) (*ValidatedToken, error) {
 	parser := jwt.NewParser(jwt.WithExpirationRequired())
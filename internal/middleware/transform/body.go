@@ -15,6 +15,7 @@ import (
 
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/bufutil"
 	"github.com/wudi/runway/internal/tmplutil"
 	"github.com/wudi/runway/variables"
 )
@@ -200,56 +201,31 @@ func (ct *CompiledBodyTransform) TransformRequest(r *http.Request, varCtx *varia
 }
 
 // ResponseBodyTransformMiddleware creates a middleware that buffers the response,
-// transforms the JSON body, and replays it to the client.
+// transforms the JSON body, and replays it to the client. On routes with
+// response_buffering, large bodies are buffered on disk.
 func ResponseBodyTransformMiddleware(ct *CompiledBodyTransform) middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			bw := &bodyBufferWriter{
-				ResponseWriter: w,
-				statusCode:     200,
-				header:         make(http.Header),
-			}
+			bw := bufutil.NewFor(r)
 			next.ServeHTTP(bw, r)
+			if bw.Aborted(w) {
+				return
+			}
 
-			body := bw.body.Bytes()
-			if isJSON(bw.header.Get("Content-Type")) && len(body) > 0 {
+			body, err := bw.Bytes()
+			if err != nil {
+				bw.FlushTo(w)
+				return
+			}
+			if isJSON(bw.Header().Get("Content-Type")) && len(body) > 0 {
 				varCtx := variables.GetFromRequest(r)
 				body = ct.Transform(body, varCtx)
 			}
-
-			// Copy captured headers to real writer
-			for k, vv := range bw.header {
-				for _, v := range vv {
-					w.Header().Add(k, v)
-				}
-			}
-			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
-			w.WriteHeader(bw.statusCode)
-			w.Write(body)
+			bw.FlushToWithLength(w, body)
 		})
 	}
 }
 
-// bodyBufferWriter captures the response for transformation.
-type bodyBufferWriter struct {
-	http.ResponseWriter
-	statusCode int
-	body       bytes.Buffer
-	header     http.Header
-}
-
-func (bw *bodyBufferWriter) Header() http.Header {
-	return bw.header
-}
-
-func (bw *bodyBufferWriter) WriteHeader(code int) {
-	bw.statusCode = code
-}
-
-func (bw *bodyBufferWriter) Write(b []byte) (int, error) {
-	return bw.body.Write(b)
-}
-
 // applyTarget extracts a nested path as the root response.
 func (ct *CompiledBodyTransform) applyTarget(body []byte) []byte {
 	result := gjson.GetBytes(body, ct.target)
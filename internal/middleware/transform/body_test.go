@@ -6,10 +6,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/middleware/spillbuf"
 	"github.com/wudi/runway/variables"
 )
 
@@ -432,6 +434,55 @@ func TestCompiledBodyTransform_ResponseMiddleware(t *testing.T) {
 	}
 }
 
+func TestCompiledBodyTransform_ResponseMiddlewareSpill(t *testing.T) {
+	ct, err := NewCompiledBodyTransform(config.BodyTransformConfig{DenyFields: []string{"secret"}})
+	if err != nil {
+		t.Fatalf("failed to compile: %v", err)
+	}
+	dir := t.TempDir()
+	disk := spillbuf.NewDisk(config.ResponseBufferingConfig{SpillDir: dir}, nil)
+	buffering := spillbuf.New(config.ResponseBufferingConfig{MemoryThreshold: 64, MaxSize: 4096}, disk)
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		})
+		handler := buffering.Middleware()(ResponseBodyTransformMiddleware(ct)(backend))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+		return w
+	}
+
+	padding := strings.Repeat("x", 512)
+	w := serve(`{"name":"alice","secret":"xyz","padding":"` + padding + `"}`)
+	var data map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatalf("failed to parse result: %v", err)
+	}
+	if data["name"] != "alice" || data["padding"] != padding {
+		t.Errorf("unexpected body %v", data)
+	}
+	if _, ok := data["secret"]; ok {
+		t.Error("expected secret to be removed from the spilled body")
+	}
+	if buffering.Stats()["spills"] != int64(1) {
+		t.Errorf("expected the body to spill, stats %v", buffering.Stats())
+	}
+
+	w = serve(`{"padding":"` + strings.Repeat("x", 8192) + `"}`)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 past max_size, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "xxxx") {
+		t.Error("expected no part of the oversized body to reach the client")
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 || disk.Usage() != 0 {
+		t.Errorf("expected spill files removed, found %d, usage %d", len(entries), disk.Usage())
+	}
+}
+
 func TestCompiledBodyTransform_EmptyBody(t *testing.T) {
 	cfg := config.BodyTransformConfig{
 		AddFields: map[string]string{"key": "val"},
@@ -3,6 +3,7 @@ package validation
 import (
 	"bytes"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,7 +14,12 @@ import (
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/logging"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/xmlsafe"
+	"github.com/wudi/runway/internal/xsd"
+	"github.com/wudi/runway/variables"
+	"go.uber.org/zap"
 )
 
 // ValidationMetrics tracks validation counters.
@@ -34,15 +40,31 @@ func (m *ValidationMetrics) Snapshot() map[string]int64 {
 	}
 }
 
-// Validator validates request/response bodies against JSON schemas.
+// Validator validates request/response bodies against JSON schemas and,
+// for XML bodies, XML schemas (XSD).
 type Validator struct {
 	enabled        bool
 	requestSchema  *jsonschema.Schema
 	responseSchema *jsonschema.Schema
+	requestXSD     *xsd.Schema
+	responseXSD    *xsd.Schema
 	logOnly        bool
 	metrics        *ValidationMetrics
 }
 
+// XSDError is a body that does not conform to its XML schema. Requests
+// failing with it are rejected with 422 Unprocessable Entity; the message
+// names the line, column and element path of the violation.
+type XSDError struct {
+	Err *xsd.ValidationError
+}
+
+func (e *XSDError) Error() string {
+	return "XML validation failed: " + e.Err.Error()
+}
+
+func (e *XSDError) Unwrap() error { return e.Err }
+
 // New creates a new Validator from config.
 func New(cfg config.ValidationConfig) (*Validator, error) {
 	v := &Validator{
@@ -56,21 +78,54 @@ func New(cfg config.ValidationConfig) (*Validator, error) {
 	}
 
 	var err error
-	v.requestSchema, err = compileSchema(cfg.Schema, cfg.SchemaFile)
+	v.requestSchema, err = CompileSchema(cfg.Schema, cfg.SchemaFile)
 	if err != nil {
 		return nil, fmt.Errorf("request schema: %w", err)
 	}
 
-	v.responseSchema, err = compileSchema(cfg.ResponseSchema, cfg.ResponseSchemaFile)
+	v.responseSchema, err = CompileSchema(cfg.ResponseSchema, cfg.ResponseSchemaFile)
 	if err != nil {
 		return nil, fmt.Errorf("response schema: %w", err)
 	}
 
+	v.requestXSD, err = CompileXSD(cfg.XSD, cfg.XSDFile)
+	if err != nil {
+		return nil, fmt.Errorf("request xsd: %w", err)
+	}
+
+	v.responseXSD, err = CompileXSD(cfg.ResponseXSD, cfg.ResponseXSDFile)
+	if err != nil {
+		return nil, fmt.Errorf("response xsd: %w", err)
+	}
+
 	return v, nil
 }
 
-// compileSchema compiles a JSON schema from an inline string or file path.
-func compileSchema(inline, file string) (*jsonschema.Schema, error) {
+// CompileXSD compiles an XML schema from an inline string or file path.
+// It returns nil when neither is set.
+func CompileXSD(inline, file string) (*xsd.Schema, error) {
+	data := []byte(inline)
+	if file != "" {
+		var err error
+		if data, err = os.ReadFile(file); err != nil {
+			return nil, fmt.Errorf("failed to read xsd file: %w", err)
+		}
+	} else if inline == "" {
+		return nil, nil
+	}
+	return xsd.Compile(data)
+}
+
+// looksLikeXML reports whether a body starts with markup, for responses
+// whose Content-Type is not available to the validator.
+func looksLikeXML(body []byte) bool {
+	b := bytes.TrimLeft(body, " \t\r\n\ufeff")
+	return len(b) > 0 && b[0] == '<'
+}
+
+// CompileSchema compiles a JSON schema from an inline string or file path.
+// It returns nil when neither is set.
+func CompileSchema(inline, file string) (*jsonschema.Schema, error) {
 	var schemaStr string
 	if file != "" {
 		data, err := os.ReadFile(file)
@@ -104,12 +159,12 @@ func compileSchema(inline, file string) (*jsonschema.Schema, error) {
 
 // IsEnabled returns whether validation is enabled.
 func (v *Validator) IsEnabled() bool {
-	return v.enabled && (v.requestSchema != nil || v.responseSchema != nil)
+	return v.enabled && (v.requestSchema != nil || v.responseSchema != nil || v.requestXSD != nil || v.responseXSD != nil)
 }
 
 // HasResponseSchema returns whether response validation is configured.
 func (v *Validator) HasResponseSchema() bool {
-	return v.responseSchema != nil
+	return v.responseSchema != nil || v.responseXSD != nil
 }
 
 // IsLogOnly returns whether validation errors should be logged instead of rejected.
@@ -122,8 +177,16 @@ func (v *Validator) GetMetrics() *ValidationMetrics {
 	return v.metrics
 }
 
-// Validate validates the request body against the request schema.
+// Validate validates the request body against the request schema, or the
+// request XSD for XML bodies.
 func (v *Validator) Validate(r *http.Request) error {
+	if v.requestSchema == nil && v.requestXSD == nil {
+		return nil
+	}
+	ct := r.Header.Get("Content-Type")
+	if v.requestXSD != nil && xmlsafe.IsXMLContentType(ct) {
+		return v.validateXML(r)
+	}
 	if v.requestSchema == nil {
 		return nil
 	}
@@ -139,6 +202,12 @@ func (v *Validator) Validate(r *http.Request) error {
 		return nil
 	}
 
+	// Multipart uploads are never buffered: the form fields captured by the
+	// route's multipart_fields are validated as an object instead.
+	if _, ok := variables.IsMultipartForm(r); ok {
+		return v.validateForm(r)
+	}
+
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		return fmt.Errorf("failed to read request body")
@@ -159,7 +228,6 @@ func (v *Validator) Validate(r *http.Request) error {
 	}
 
 	// Only validate JSON content
-	ct := r.Header.Get("Content-Type")
 	if ct != "" && ct != "application/json" && ct != "application/json; charset=utf-8" {
 		return nil
 	}
@@ -180,8 +248,68 @@ func (v *Validator) Validate(r *http.Request) error {
 	return nil
 }
 
-// ValidateResponseBody validates response body bytes against the response schema.
+// validateXML validates an XML request body against the request XSD. The
+// body is parsed under the global xml_limits.
+func (v *Validator) validateXML(r *http.Request) error {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return fmt.Errorf("failed to read request body")
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	v.metrics.RequestsValidated.Add(1)
+	if err := v.requestXSD.Validate(body); err != nil {
+		v.metrics.RequestsFailed.Add(1)
+		return xsdError(err)
+	}
+	return nil
+}
+
+// xsdError wraps schema violations as *XSDError; parse failures and limit
+// violations are reported as invalid XML.
+func xsdError(err error) error {
+	var ve *xsd.ValidationError
+	if stderrors.As(err, &ve) {
+		return &XSDError{Err: ve}
+	}
+	return fmt.Errorf("invalid XML body: %s", err.Error())
+}
+
+// validateForm validates the multipart form fields captured for r. Requests
+// whose fields were not scanned pass unvalidated.
+func (v *Validator) validateForm(r *http.Request) error {
+	vc, ok := r.Context().Value(variables.RequestContextKey{}).(*variables.Context)
+	if !ok || vc.FormFields() == nil {
+		return nil
+	}
+	fields := vc.FormFields()
+	data := make(map[string]interface{}, len(fields))
+	for k, val := range fields {
+		data[k] = val
+	}
+	v.metrics.RequestsValidated.Add(1)
+	if err := v.requestSchema.Validate(data); err != nil {
+		v.metrics.RequestsFailed.Add(1)
+		return fmt.Errorf("validation failed: %s", err.Error())
+	}
+	return nil
+}
+
+// ValidateResponseBody validates response body bytes against the response
+// schema, or the response XSD when the body is XML.
 func (v *Validator) ValidateResponseBody(body []byte) error {
+	if v.responseXSD != nil && looksLikeXML(body) {
+		v.metrics.ResponsesValidated.Add(1)
+		if err := v.responseXSD.Validate(body); err != nil {
+			v.metrics.ResponsesFailed.Add(1)
+			return fmt.Errorf("response %s", xsdError(err))
+		}
+		return nil
+	}
 	if v.responseSchema == nil {
 		return nil
 	}
@@ -207,8 +335,17 @@ func (v *Validator) ValidateResponseBody(body []byte) error {
 	return nil
 }
 
-// RejectValidation sends a 400 Bad Request with validation error details.
+// errUnprocessable is sent for bodies that parse but violate their XSD.
+var errUnprocessable = errors.New(http.StatusUnprocessableEntity, "Unprocessable Entity")
+
+// RejectValidation sends a 400 Bad Request with validation error details,
+// or 422 Unprocessable Entity for XSD violations.
 func RejectValidation(w http.ResponseWriter, err error) {
+	var xe *XSDError
+	if stderrors.As(err, &xe) {
+		errUnprocessable.WithDetails(err.Error()).WriteJSON(w)
+		return
+	}
 	errors.ErrBadRequest.WithDetails(err.Error()).WriteJSON(w)
 }
 
@@ -222,6 +359,8 @@ func NewValidatorByRoute() *ValidatorByRoute {
 			"enabled":             v.enabled,
 			"has_request_schema":  v.requestSchema != nil,
 			"has_response_schema": v.responseSchema != nil,
+			"has_request_xsd":     v.requestXSD != nil,
+			"has_response_xsd":    v.responseXSD != nil,
 			"log_only":            v.logOnly,
 			"metrics":             v.metrics.Snapshot(),
 		}
@@ -233,6 +372,13 @@ func (v *Validator) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if err := v.Validate(r); err != nil {
+				if v.logOnly {
+					logging.Warn("Request validation failed (log only)",
+						zap.String("path", r.URL.Path), zap.Error(err))
+					next.ServeHTTP(w, r)
+					return
+				}
+				variables.SetErrorReason(r, errors.ReasonValidationFailed)
 				RejectValidation(w, err)
 				return
 			}
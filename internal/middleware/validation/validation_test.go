@@ -2,8 +2,11 @@ package validation
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/wudi/runway/config"
@@ -392,3 +395,95 @@ func TestValidatorNestedObjects(t *testing.T) {
 		}
 	})
 }
+
+const orderXSD = `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="order">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="qty" type="xs:positiveInteger"/>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`
+
+func TestValidatorXSDRequest(t *testing.T) {
+	v, err := New(config.ValidationConfig{Enabled: true, XSD: orderXSD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := v.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(ct, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", ct)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := send("application/xml", "<order><qty>3</qty></order>"); w.Code != http.StatusOK {
+		t.Errorf("expected valid XML to pass, got %d", w.Code)
+	}
+
+	w := send("text/xml; charset=utf-8", "<order>\n  <qty>-1</qty>\n</order>")
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", w.Code)
+	}
+	var body map[string]any
+	json.Unmarshal(w.Body.Bytes(), &body)
+	details, _ := body["details"].(string)
+	if !strings.Contains(details, "line 2, column 3: /order/qty") {
+		t.Errorf("expected error location in details, got %q", details)
+	}
+
+	// Malformed and malicious XML is a bad request, not a schema violation.
+	xxe := `<!DOCTYPE order [<!ENTITY x SYSTEM "file:///etc/passwd">]><order><qty>&x;</qty></order>`
+	if w := send("application/soap+xml", xxe); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for external entity, got %d", w.Code)
+	}
+
+	// Non-XML bodies are not checked against the XSD.
+	if w := send("application/json", `{"qty":-1}`); w.Code != http.StatusOK {
+		t.Errorf("expected JSON to pass without a JSON schema, got %d", w.Code)
+	}
+}
+
+func TestValidatorLogOnlyMiddleware(t *testing.T) {
+	v, err := New(config.ValidationConfig{Enabled: true, XSD: orderXSD, LogOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	called := false
+	h := v.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	r := httptest.NewRequest("POST", "/", strings.NewReader("<order/>"))
+	r.Header.Set("Content-Type", "application/xml")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if !called {
+		t.Error("expected log_only to pass the request through")
+	}
+	if v.GetMetrics().RequestsFailed.Load() != 1 {
+		t.Errorf("expected failure to be counted")
+	}
+}
+
+func TestValidatorXSDResponse(t *testing.T) {
+	v, err := New(config.ValidationConfig{Enabled: true, ResponseXSD: orderXSD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.HasResponseSchema() {
+		t.Fatal("expected response XSD to count as a response schema")
+	}
+	if err := v.ValidateResponseBody([]byte("<order><qty>1</qty></order>")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := v.ValidateResponseBody([]byte("<order><qty>x</qty></order>")); err == nil {
+		t.Error("expected response XSD violation")
+	}
+}
+
+func TestValidatorBadXSD(t *testing.T) {
+	if _, err := New(config.ValidationConfig{Enabled: true, XSD: "<notaschema/>"}); err == nil {
+		t.Error("expected error for invalid xsd")
+	}
+}
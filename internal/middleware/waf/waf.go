@@ -1,136 +1,398 @@
 package waf
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/corazawaf/coraza/v3"
 	"github.com/corazawaf/coraza/v3/types"
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/decision"
 	"github.com/wudi/runway/internal/logging"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/shadow"
+	"github.com/wudi/runway/internal/simulate"
+	"github.com/wudi/runway/variables"
 	"go.uber.org/zap"
 )
 
+// defaultReloadInterval is how often rule files are checked for changes.
+const defaultReloadInterval = 10 * time.Second
+
+// topRulesLimit caps the number of rule IDs reported in stats.
+const topRulesLimit = 10
+
 // WAF wraps coraza WAF engine for a single route.
 type WAF struct {
-	engine coraza.WAF
-	mode   string // "block" or "detect"
+	routeID string
+	cfg     config.WAFConfig
+	mode    string // "block" or "detect"
+
+	// active is enforced according to mode; shadow, if set, is evaluated
+	// in detect-only mode on the same traffic. Both are swapped atomically.
+	active atomic.Pointer[ruleSet]
+	shadow atomic.Pointer[ruleSet]
+
+	// async, if set, evaluates the shadow set off the request path. Without
+	// shadow rule files the active set is evaluated there instead, and
+	// enforce is false.
+	async   *shadow.Evaluator
+	enforce bool
+
+	reloadMu    sync.Mutex // serializes reloads and promotion
+	activeFiles []string
+	shadowFiles []string
+	failedSig   map[string]string // set kind -> signature that last failed to compile
+
+	cancel func()
 
 	// Metrics
 	requestsTotal atomic.Int64
 	blockedTotal  atomic.Int64
 	detectedTotal atomic.Int64
+	reloads       atomic.Int64
+	reloadErrors  atomic.Int64
+	promotions    atomic.Int64
+	lastError     atomic.Value // string
+}
+
+// ruleSet is a compiled set of rules together with its provenance and
+// per-rule interruption counters.
+type ruleSet struct {
+	engine   coraza.WAF
+	version  int64
+	hash     string
+	files    []string
+	loadedAt time.Time
+	sig      string // file modtime/size signature, guarded by WAF.reloadMu
+
+	matches  atomic.Int64
+	ruleMu   sync.Mutex
+	ruleHits map[int]int64
+}
+
+// RuleCount is the number of interruptions attributed to a rule ID.
+type RuleCount struct {
+	ID    int   `json:"id"`
+	Count int64 `json:"count"`
+}
+
+// RuleSetStatus is the admin API representation of a rule set.
+type RuleSetStatus struct {
+	Version  int64       `json:"version"`
+	Hash     string      `json:"hash"`
+	Files    []string    `json:"files,omitempty"`
+	LoadedAt time.Time   `json:"loaded_at"`
+	Matches  int64       `json:"matches"`
+	TopRules []RuleCount `json:"top_rules"`
 }
 
 // New creates a new WAF from config.
 func New(cfg config.WAFConfig) (*WAF, error) {
-	wafCfg := coraza.NewWAFConfig()
+	return NewForRoute("", cfg)
+}
 
-	// Apply inline rules
-	for _, rule := range cfg.InlineRules {
-		wafCfg = wafCfg.WithDirectives(rule)
+// NewForRoute creates a new WAF for the given route. When rule files are
+// configured, they are polled for changes until Close is called.
+func NewForRoute(routeID string, cfg config.WAFConfig) (*WAF, error) {
+	active, err := newRuleSet(cfg, cfg.RuleFiles, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize WAF: %w", err)
 	}
 
-	// Apply rule files
-	for _, path := range cfg.RuleFiles {
-		wafCfg = wafCfg.WithDirectives(fmt.Sprintf("Include %s", path))
+	mode := cfg.Mode
+	if mode == "" {
+		mode = "block"
 	}
 
-	// Apply built-in rule sets
-	if cfg.SQLInjection {
-		wafCfg = wafCfg.WithDirectives(`
-			SecRule ARGS|ARGS_NAMES|REQUEST_BODY "@detectSQLi" "id:1001,phase:2,deny,status:403,msg:'SQL Injection detected',tag:'attack-sqli'"
-		`)
+	w := &WAF{
+		routeID:     routeID,
+		cfg:         cfg,
+		mode:        mode,
+		activeFiles: cfg.RuleFiles,
+		shadowFiles: cfg.ShadowRuleFiles,
+		failedSig:   make(map[string]string),
+		cancel:      func() {},
+		enforce:     !cfg.ShadowAsync || len(cfg.ShadowRuleFiles) > 0,
 	}
-	if cfg.XSS {
-		wafCfg = wafCfg.WithDirectives(`
-			SecRule ARGS|ARGS_NAMES|REQUEST_BODY "@detectXSS" "id:1002,phase:2,deny,status:403,msg:'XSS detected',tag:'attack-xss'"
-		`)
+	w.active.Store(active)
+	if cfg.ShadowAsync {
+		w.async = shadow.NewEvaluator(w.evaluateAsync, true)
 	}
 
-	engine, err := coraza.NewWAF(wafCfg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize WAF: %w", err)
+	if len(cfg.ShadowRuleFiles) > 0 {
+		rs, err := newRuleSet(cfg, cfg.ShadowRuleFiles, 1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize WAF shadow rule set: %w", err)
+		}
+		w.shadow.Store(rs)
 	}
 
-	mode := cfg.Mode
-	if mode == "" {
-		mode = "block"
+	if len(cfg.RuleFiles) > 0 || len(cfg.ShadowRuleFiles) > 0 {
+		interval := cfg.ReloadInterval
+		if interval == 0 {
+			interval = defaultReloadInterval
+		}
+		done := make(chan struct{})
+		var once sync.Once
+		w.cancel = func() { once.Do(func() { close(done) }) }
+		go w.reloadLoop(interval, done)
 	}
 
-	return &WAF{
-		engine: engine,
-		mode:   mode,
-	}, nil
+	return w, nil
 }
 
 // Middleware returns an HTTP middleware that runs WAF inspection.
 func (w *WAF) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if simulate.Active(r.Context()) {
+				w.serveSimulated(rw, r, next)
+				return
+			}
 			w.requestsTotal.Add(1)
 
-			tx := w.engine.NewTransaction()
-			defer func() {
-				tx.ProcessLogging()
-				if err := tx.Close(); err != nil {
-					logging.Error("WAF transaction close error", zap.Error(err))
+			if !w.enforce {
+				w.async.Submit(w.async.Capture(r), shadow.NoVerdict)
+				if rec := decision.FromContext(r.Context()); rec != nil {
+					rec.Add("waf", decision.Allow, "mode", "async")
 				}
-			}()
-
-			// Process connection and URI
-			tx.ProcessConnection(clientIP(r), 0, "", 0)
-			tx.ProcessURI(r.URL.String(), r.Method, r.Proto)
+				next.ServeHTTP(rw, r)
+				return
+			}
 
-			// Process request headers
-			for k, vv := range r.Header {
-				for _, v := range vv {
-					tx.AddRequestHeader(k, v)
+			if w.cfg.XMLProtection {
+				if it := inspectXML(r); it != nil {
+					w.handleInterruption(w.active.Load(), it, rw, r)
+					if w.mode != "detect" {
+						return
+					}
 				}
 			}
 
-			if it := tx.ProcessRequestHeaders(); it != nil {
-				w.handleInterruption(it, rw)
-				return
+			var snap *shadow.Request
+			if rs := w.shadow.Load(); rs != nil {
+				if w.async != nil {
+					snap = w.async.Capture(r)
+				} else {
+					w.evaluateShadow(rs, r)
+				}
 			}
 
-			// Process request body if present
-			if r.Body != nil && r.ContentLength > 0 {
-				it, _, err := tx.ReadRequestBodyFrom(r.Body)
-				if err != nil {
-					logging.Error("WAF request body read error", zap.Error(err))
-				}
-				if it != nil {
-					w.handleInterruption(it, rw)
-					return
+			active := w.active.Load()
+			tx := active.engine.NewTransaction()
+			defer func() {
+				tx.ProcessLogging()
+				if err := tx.Close(); err != nil {
+					logging.Error("WAF transaction close error", zap.Error(err))
 				}
-				r.Body.Close()
+			}()
 
-				// Replace body with what coraza buffered
-				reader, err := tx.RequestBodyReader()
-				if err == nil {
-					r.Body = readCloser{reader}
+			it := inspectActive(tx, r)
+			if snap != nil {
+				verdict := shadow.Allow
+				if it != nil {
+					verdict = shadow.Block
 				}
-			}
-
-			it, err := tx.ProcessRequestBody()
-			if err != nil {
-				logging.Error("WAF process request body error", zap.Error(err))
+				w.async.Submit(snap, verdict)
 			}
 			if it != nil {
-				w.handleInterruption(it, rw)
+				w.handleInterruption(active, it, rw, r)
 				return
 			}
+			if rec := decision.FromContext(r.Context()); rec != nil {
+				rec.Add("waf", decision.Allow, "mode", w.mode)
+			}
 
 			next.ServeHTTP(rw, r)
 		})
 	}
 }
 
+// serveSimulated inspects a simulated request with the active rule set,
+// leaving counters, logs and shadow evaluation untouched.
+func (w *WAF) serveSimulated(rw http.ResponseWriter, r *http.Request, next http.Handler) {
+	tx := w.active.Load().engine.NewTransaction()
+	defer tx.Close()
+
+	var it *types.Interruption
+	if w.cfg.XMLProtection {
+		if it = inspectXML(r); it != nil {
+			simulate.Match(r.Context(), fmt.Sprintf("waf rule %d", it.RuleID))
+		}
+	}
+	if it == nil {
+		it = inspectActive(tx, r)
+		for _, mr := range tx.MatchedRules() {
+			if id := mr.Rule().ID(); id != 0 {
+				simulate.Match(r.Context(), fmt.Sprintf("waf rule %d", id))
+			}
+		}
+	}
+	if it == nil {
+		next.ServeHTTP(rw, r)
+		return
+	}
+	if !w.enforce || w.mode == "detect" {
+		simulate.Note(r.Context(), "rule %d matched; not blocking in %s mode", it.RuleID, w.simulatedMode())
+		next.ServeHTTP(rw, r)
+		return
+	}
+	simulate.Note(r.Context(), "blocked by rule %d (action %s)", it.RuleID, it.Action)
+	status := it.Status
+	if status == 0 {
+		status = http.StatusForbidden
+	}
+	rw.WriteHeader(status)
+	rw.Write([]byte(`{"error":"request blocked by WAF"}`))
+}
+
+// simulatedMode names the reason a matching rule does not block.
+func (w *WAF) simulatedMode() string {
+	if !w.enforce {
+		return "async shadow"
+	}
+	return w.mode
+}
+
+// processHeaders feeds connection, URI and headers into tx and runs phase 1.
+func processHeaders(tx types.Transaction, r *http.Request) *types.Interruption {
+	tx.ProcessConnection(clientIP(r), 0, "", 0)
+	tx.ProcessURI(r.URL.String(), r.Method, r.Proto)
+	for k, vv := range r.Header {
+		for _, v := range vv {
+			tx.AddRequestHeader(k, v)
+		}
+	}
+	// net/http moves Host out of the header map; rules still expect it.
+	if r.Host != "" {
+		tx.AddRequestHeader("Host", r.Host)
+	}
+	return tx.ProcessRequestHeaders()
+}
+
+// inspectActive runs phases 1 and 2 of tx against r. The request body is
+// replaced with the copy coraza buffered.
+func inspectActive(tx types.Transaction, r *http.Request) *types.Interruption {
+	if it := processHeaders(tx, r); it != nil {
+		return it
+	}
+
+	// Process request body if present. Without body access coraza reads
+	// nothing, so the body is left in place.
+	if r.Body != nil && r.ContentLength > 0 && tx.IsRequestBodyAccessible() && !tx.IsRuleEngineOff() {
+		it, _, err := tx.ReadRequestBodyFrom(r.Body)
+		if err != nil {
+			logging.Error("WAF request body read error", zap.Error(err))
+		}
+		if it != nil {
+			return it
+		}
+		r.Body.Close()
+
+		// Replace body with what coraza buffered
+		reader, err := tx.RequestBodyReader()
+		if err == nil {
+			r.Body = readCloser{reader}
+		}
+	}
+
+	it, err := tx.ProcessRequestBody()
+	if err != nil {
+		logging.Error("WAF process request body error", zap.Error(err))
+	}
+	return it
+}
+
+// inspect runs rs against r and its buffered body in a transaction of its own.
+func inspect(rs *ruleSet, r *http.Request, body []byte) (*types.Interruption, error) {
+	tx := rs.engine.NewTransaction()
+	defer func() {
+		if err := tx.Close(); err != nil {
+			logging.Error("WAF shadow transaction close error", zap.Error(err))
+		}
+	}()
+
+	if it := processHeaders(tx, r); it != nil {
+		return it, nil
+	}
+	if len(body) > 0 {
+		if it, _, err := tx.ReadRequestBodyFrom(bytes.NewReader(body)); it != nil || err != nil {
+			return it, err
+		}
+	}
+	return tx.ProcessRequestBody()
+}
+
+// evaluateShadow runs the shadow rule set against r without affecting the
+// response. At most shadow.MaxBodySize bytes of body are read and evaluated;
+// they are replayed ahead of the rest so the active set reads the whole body.
+func (w *WAF) evaluateShadow(rs *ruleSet, r *http.Request) {
+	var body []byte
+	if r.Body != nil && r.ContentLength > 0 {
+		body = make([]byte, min(r.ContentLength, shadow.MaxBodySize))
+		n, err := io.ReadFull(r.Body, body)
+		body = body[:n]
+		r.Body = prefixedBody{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			logging.Error("WAF shadow request body read error", zap.Error(err))
+			return
+		}
+	}
+	if it, _ := inspect(rs, r, body); it != nil {
+		w.recordShadow(rs, it, r.URL.Path)
+	}
+}
+
+// evaluateAsync runs the shadow rule set, or the unenforced active set, on a
+// request snapshot off the request path.
+func (w *WAF) evaluateAsync(req *shadow.Request) shadow.Verdict {
+	rs := w.shadow.Load()
+	if rs == nil {
+		if w.enforce {
+			// The shadow set was promoted after this request was queued.
+			return shadow.Allow
+		}
+		rs = w.active.Load()
+	}
+	it, err := inspect(rs, req.HTTPRequest(), req.Body)
+	switch {
+	case it != nil:
+		w.recordShadow(rs, it, req.URL.Path)
+		return shadow.Block
+	case err != nil:
+		logging.Error("WAF shadow evaluation error", zap.String("route", w.routeID), zap.Error(err))
+		return shadow.Error
+	}
+	return shadow.Allow
+}
+
+// recordShadow counts and logs an interruption from a rule set evaluated
+// in detect-only mode.
+func (w *WAF) recordShadow(rs *ruleSet, it *types.Interruption, path string) {
+	rs.record(it)
+	logging.Info("WAF shadow rule set would block request",
+		zap.String("route", w.routeID),
+		zap.String("shadow_hash", rs.hash),
+		zap.Int("rule_id", it.RuleID),
+		zap.String("path", path),
+	)
+}
+
 // readCloser wraps an io.Reader with a no-op Close.
 type readCloser struct {
 	r interface{ Read([]byte) (int, error) }
@@ -139,21 +401,40 @@ type readCloser struct {
 func (rc readCloser) Read(p []byte) (int, error) { return rc.r.Read(p) }
 func (rc readCloser) Close() error               { return nil }
 
+// prefixedBody replays a body prefix read for shadow evaluation before the
+// rest of the original body, which it closes.
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
+
 // handleInterruption handles a WAF interruption (block or detect mode).
-func (w *WAF) handleInterruption(it *types.Interruption, rw http.ResponseWriter) {
+func (w *WAF) handleInterruption(rs *ruleSet, it *types.Interruption, rw http.ResponseWriter, r *http.Request) {
+	rs.record(it)
+	rec := decision.FromContext(r.Context())
 	if w.mode == "detect" {
+		if rec != nil {
+			rec.Add("waf", decision.Detect, "rule", strconv.Itoa(it.RuleID))
+		}
 		w.detectedTotal.Add(1)
 		logging.Warn("WAF detected threat (detect mode, not blocking)",
 			zap.Int("status", it.Status),
 			zap.String("action", it.Action),
+			zap.Int("rule_id", it.RuleID),
 		)
 		return
 	}
 
+	if rec != nil {
+		rec.Add("waf", decision.Deny, "rule", strconv.Itoa(it.RuleID), "action", it.Action)
+	}
 	w.blockedTotal.Add(1)
+	variables.AddSignal(r, variables.SignalWAF)
 	logging.Warn("WAF blocked request",
 		zap.Int("status", it.Status),
 		zap.String("action", it.Action),
+		zap.Int("rule_id", it.RuleID),
+		zap.String("data", it.Data),
 	)
 	status := it.Status
 	if status == 0 {
@@ -165,12 +446,371 @@ func (w *WAF) handleInterruption(it *types.Interruption, rw http.ResponseWriter)
 
 // Stats returns metrics snapshot.
 func (w *WAF) Stats() map[string]interface{} {
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"mode":           w.mode,
 		"requests_total": w.requestsTotal.Load(),
 		"blocked_total":  w.blockedTotal.Load(),
 		"detected_total": w.detectedTotal.Load(),
+		"active":         w.active.Load().status(),
+		"reloads":        w.reloads.Load(),
+		"reload_errors":  w.reloadErrors.Load(),
+		"promotions":     w.promotions.Load(),
+	}
+	if rs := w.shadow.Load(); rs != nil {
+		stats["shadow"] = rs.status()
+		stats["would_block_total"] = rs.matches.Load()
+	}
+	if w.async != nil {
+		stats["enforce"] = w.enforce
+		stats["shadow_async"] = w.async.Stats()
+	}
+	if msg, _ := w.lastError.Load().(string); msg != "" {
+		stats["last_reload_error"] = msg
+	}
+	return stats
+}
+
+// PromoteShadow makes the shadow rule set the active one. The promoted
+// files are watched in place of rule_files until the next config reload.
+func (w *WAF) PromoteShadow() error {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	shadow := w.shadow.Load()
+	if shadow == nil {
+		return errors.New("no shadow rule set configured")
+	}
+	promoted := &ruleSet{
+		engine:   shadow.engine,
+		version:  w.active.Load().version + 1,
+		hash:     shadow.hash,
+		files:    shadow.files,
+		loadedAt: time.Now(),
+		sig:      shadow.sig,
+		ruleHits: make(map[int]int64),
+	}
+	w.active.Store(promoted)
+	w.shadow.Store(nil)
+	w.activeFiles, w.shadowFiles = w.shadowFiles, nil
+	clear(w.failedSig)
+	w.promotions.Add(1)
+
+	logging.Info("WAF shadow rule set promoted",
+		zap.String("route", w.routeID),
+		zap.Int64("version", promoted.version),
+		zap.String("hash", promoted.hash),
+	)
+	return nil
+}
+
+// Close stops the rule file poller.
+func (w *WAF) Close() {
+	w.cancel()
+}
+
+// reloadLoop polls the watched rule files until done is closed.
+func (w *WAF) reloadLoop(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			w.checkReload()
+		}
+	}
+}
+
+// checkReload recompiles any rule set whose files changed on disk.
+func (w *WAF) checkReload() {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	w.reloadSet("active", &w.active, w.activeFiles)
+	w.reloadSet("shadow", &w.shadow, w.shadowFiles)
+}
+
+// reloadSet swaps in a recompiled rule set when its files changed. A set
+// that fails to compile is kept, and the failure is reported once per change.
+func (w *WAF) reloadSet(kind string, cur *atomic.Pointer[ruleSet], files []string) {
+	rs := cur.Load()
+	if rs == nil || len(files) == 0 {
+		return
+	}
+	sig := signature(files)
+	if sig == rs.sig || sig == w.failedSig[kind] {
+		return
+	}
+
+	hash, err := hashRules(w.cfg, files)
+	if err == nil && hash == rs.hash {
+		rs.sig = sig
+		return
+	}
+	var next *ruleSet
+	if err == nil {
+		next, err = newRuleSet(w.cfg, files, rs.version+1)
+	}
+	if err != nil {
+		w.failedSig[kind] = sig
+		w.reloadErrors.Add(1)
+		file, line := locateCompileError(w.cfg, files)
+		msg := err.Error()
+		if file != "" {
+			msg = fmt.Sprintf("%s:%d: %s", file, line, msg)
+		}
+		w.lastError.Store(msg)
+		logging.Error("WAF rule reload failed, keeping previous rule set",
+			zap.String("route", w.routeID),
+			zap.String("set", kind),
+			zap.String("file", file),
+			zap.Int("line", line),
+			zap.Error(err),
+		)
+		return
+	}
+
+	cur.Store(next)
+	delete(w.failedSig, kind)
+	w.lastError.Store("")
+	w.reloads.Add(1)
+	logging.Info("WAF rule set reloaded",
+		zap.String("route", w.routeID),
+		zap.String("set", kind),
+		zap.Int64("version", next.version),
+		zap.String("hash", next.hash),
+	)
+}
+
+// newRuleSet compiles cfg's inline and built-in rules with the given rule files.
+func newRuleSet(cfg config.WAFConfig, files []string, version int64) (*ruleSet, error) {
+	hash, err := hashRules(cfg, files)
+	if err != nil {
+		return nil, err
+	}
+	sig := signature(files)
+	engine, err := compile(cfg, files, "")
+	if err != nil {
+		return nil, err
+	}
+	return &ruleSet{
+		engine:   engine,
+		version:  version,
+		hash:     hash,
+		files:    files,
+		loadedAt: time.Now(),
+		sig:      sig,
+		ruleHits: make(map[int]int64),
+	}, nil
+}
+
+// compile builds a coraza engine from inline rules, rule files, extra
+// directives and the built-in rule sets, in that order.
+func compile(cfg config.WAFConfig, files []string, extra string) (coraza.WAF, error) {
+	wafCfg := coraza.NewWAFConfig()
+
+	// Apply inline rules
+	for _, rule := range cfg.InlineRules {
+		wafCfg = wafCfg.WithDirectives(rule)
 	}
+
+	// Apply rule files
+	for _, path := range files {
+		wafCfg = wafCfg.WithDirectives(fmt.Sprintf("Include %s", path))
+	}
+	if extra != "" {
+		wafCfg = wafCfg.WithDirectives(extra)
+	}
+
+	// Apply built-in rule sets. WebSocket upgrade requests carry no body, so
+	// their headers are inspected too, before the connection is upgraded.
+	if cfg.SQLInjection {
+		wafCfg = wafCfg.WithDirectives(`
+			SecRule ARGS|ARGS_NAMES|REQUEST_BODY "@detectSQLi" "id:1001,phase:2,deny,status:403,msg:'SQL Injection detected',tag:'attack-sqli'"
+			SecRule REQUEST_HEADERS:Upgrade "@rx (?i)^websocket$" "id:1003,phase:1,deny,status:403,msg:'SQL Injection detected in WebSocket upgrade',tag:'attack-sqli',chain"
+				SecRule ARGS|ARGS_NAMES|REQUEST_HEADERS|!REQUEST_HEADERS:Sec-WebSocket-Key "@detectSQLi" "t:none"
+		`)
+	}
+	if cfg.XSS {
+		wafCfg = wafCfg.WithDirectives(`
+			SecRule ARGS|ARGS_NAMES|REQUEST_BODY "@detectXSS" "id:1002,phase:2,deny,status:403,msg:'XSS detected',tag:'attack-xss'"
+			SecRule REQUEST_HEADERS:Upgrade "@rx (?i)^websocket$" "id:1004,phase:1,deny,status:403,msg:'XSS detected in WebSocket upgrade',tag:'attack-xss',chain"
+				SecRule ARGS|ARGS_NAMES|REQUEST_HEADERS|!REQUEST_HEADERS:Sec-WebSocket-Key "@detectXSS" "t:none"
+		`)
+	}
+
+	return coraza.NewWAF(wafCfg)
+}
+
+// record counts an interruption against the rule that triggered it.
+func (rs *ruleSet) record(it *types.Interruption) {
+	rs.matches.Add(1)
+	rs.ruleMu.Lock()
+	rs.ruleHits[it.RuleID]++
+	rs.ruleMu.Unlock()
+}
+
+func (rs *ruleSet) status() RuleSetStatus {
+	rs.ruleMu.Lock()
+	top := make([]RuleCount, 0, len(rs.ruleHits))
+	for id, n := range rs.ruleHits {
+		top = append(top, RuleCount{ID: id, Count: n})
+	}
+	rs.ruleMu.Unlock()
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].ID < top[j].ID
+	})
+	if len(top) > topRulesLimit {
+		top = top[:topRulesLimit]
+	}
+
+	return RuleSetStatus{
+		Version:  rs.version,
+		Hash:     rs.hash,
+		Files:    rs.files,
+		LoadedAt: rs.loadedAt,
+		Matches:  rs.matches.Load(),
+		TopRules: top,
+	}
+}
+
+// expandFiles resolves glob patterns in rule file paths.
+func expandFiles(files []string) []string {
+	var out []string
+	for _, f := range files {
+		if strings.Contains(f, "*") {
+			matches, _ := filepath.Glob(f)
+			out = append(out, matches...)
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// signature is a cheap change detector over rule file modtimes and sizes.
+func signature(files []string) string {
+	var b strings.Builder
+	for _, f := range expandFiles(files) {
+		b.WriteString(f)
+		if fi, err := os.Stat(f); err == nil {
+			fmt.Fprintf(&b, "|%d|%d;", fi.ModTime().UnixNano(), fi.Size())
+		} else {
+			b.WriteString("|missing;")
+		}
+	}
+	return b.String()
+}
+
+// hashRules returns a short content hash identifying a rule set version.
+func hashRules(cfg config.WAFConfig, files []string) (string, error) {
+	h := sha256.New()
+	for _, rule := range cfg.InlineRules {
+		fmt.Fprintf(h, "inline:%s\n", rule)
+	}
+	fmt.Fprintf(h, "sqli:%t xss:%t\n", cfg.SQLInjection, cfg.XSS)
+	for _, f := range expandFiles(files) {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return "", fmt.Errorf("reading rule file: %w", err)
+		}
+		fmt.Fprintf(h, "file:%s:%d\n", f, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// directive is a logical SecLang directive and the line it starts on.
+type directive struct {
+	text string
+	line int
+}
+
+// splitDirectives splits SecLang source into directives the way coraza's
+// parser does: comments and blank lines are skipped, trailing backslashes
+// continue a directive and backtick blocks span lines.
+func splitDirectives(src string) []directive {
+	var (
+		out        []directive
+		buf        strings.Builder
+		start      int
+		inBacktick bool
+	)
+	for i, raw := range strings.Split(src, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if buf.Len() == 0 {
+			start = i + 1
+		}
+		if !inBacktick && line[len(line)-1] == '`' {
+			inBacktick = true
+		} else if inBacktick && line[0] == '`' {
+			inBacktick = false
+		}
+		switch {
+		case inBacktick:
+			buf.WriteString(line)
+			buf.WriteString("\n")
+		case line[len(line)-1] == '\\':
+			buf.WriteString(strings.TrimSuffix(line, "\\"))
+		default:
+			buf.WriteString(line)
+			out = append(out, directive{text: buf.String(), line: start})
+			buf.Reset()
+		}
+	}
+	if buf.Len() > 0 {
+		out = append(out, directive{text: buf.String(), line: start})
+	}
+	return out
+}
+
+// locateCompileError finds the rule file and line of the first directive
+// that fails to compile, since coraza's errors carry neither. It returns
+// "" when the failure cannot be attributed to a single file.
+func locateCompileError(cfg config.WAFConfig, files []string) (string, int) {
+	files = expandFiles(files)
+	for i, path := range files {
+		if _, err := compile(cfg, files[:i+1], ""); err == nil {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return path, 0
+		}
+		dirs := splitDirectives(string(data))
+		fails := func(n int) bool {
+			texts := make([]string, n)
+			for j := range texts {
+				texts[j] = dirs[j].text
+			}
+			_, err := compile(cfg, files[:i], strings.Join(texts, "\n"))
+			return err != nil
+		}
+		// Binary search for the shortest failing prefix.
+		lo, hi := 1, len(dirs)
+		if hi == 0 || !fails(hi) {
+			return path, 0
+		}
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if fails(mid) {
+				hi = mid
+			} else {
+				lo = mid + 1
+			}
+		}
+		return path, dirs[lo-1].line
+	}
+	return "", 0
 }
 
 // clientIP extracts client IP from the request.
@@ -190,9 +830,22 @@ func clientIP(r *http.Request) string {
 }
 
 // WAFByRoute manages WAF instances per route.
-type WAFByRoute = byroute.Factory[*WAF, config.WAFConfig]
+type WAFByRoute struct {
+	*byroute.NamedFactory[*WAF, config.WAFConfig]
+}
 
 // NewWAFByRoute creates a new per-route WAF manager.
 func NewWAFByRoute() *WAFByRoute {
-	return byroute.NewFactory(New, func(w *WAF) any { return w.Stats() })
+	return &WAFByRoute{
+		byroute.NewNamedFactory(NewForRoute, func(w *WAF) any { return w.Stats() }).WithClose((*WAF).Close),
+	}
+}
+
+// PromoteShadow promotes the shadow rule set of the given route.
+func (m *WAFByRoute) PromoteShadow(routeID string) error {
+	w, ok := m.Get(routeID)
+	if !ok {
+		return fmt.Errorf("no WAF for route %q", routeID)
+	}
+	return w.PromoteShadow()
 }
@@ -1,12 +1,17 @@
 package waf
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/shadow"
 )
 
 func TestNew(t *testing.T) {
@@ -357,3 +362,483 @@ func TestMiddleware_RequestWithBody(t *testing.T) {
 		t.Error("expected next handler to be called for clean POST body")
 	}
 }
+
+// --- Rule file reload and shadow rule sets ---
+
+func writeRules(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const blockAdminRule = `SecRule REQUEST_URI "@beginsWith /admin" "id:2001,phase:1,deny,status:403"`
+const blockDebugRule = `SecRule REQUEST_URI "@beginsWith /debug" "id:2002,phase:1,deny,status:403"`
+
+func statusFor(t *testing.T, w *WAF, path string) int {
+	t.Helper()
+	h := w.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	return rec.Code
+}
+
+func TestReload_SwapsRuleSetOnChange(t *testing.T) {
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "rules.conf")
+	base := time.Now().Add(-time.Hour)
+	writeRules(t, rules, blockAdminRule, base)
+
+	w, err := New(config.WAFConfig{Enabled: true, RuleFiles: []string{rules}, ReloadInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	before := w.active.Load()
+
+	if statusFor(t, w, "/debug") != http.StatusOK {
+		t.Fatal("expected /debug to pass with initial rules")
+	}
+
+	writeRules(t, rules, blockAdminRule+"\n"+blockDebugRule, base.Add(time.Minute))
+	w.checkReload()
+
+	after := w.active.Load()
+	if after.version != 2 || after.hash == before.hash {
+		t.Errorf("expected version 2 with new hash, got version %d hash %s (was %s)", after.version, after.hash, before.hash)
+	}
+	if statusFor(t, w, "/debug") != http.StatusForbidden {
+		t.Error("expected /debug to be blocked after reload")
+	}
+	if w.reloads.Load() != 1 {
+		t.Errorf("expected 1 reload, got %d", w.reloads.Load())
+	}
+
+	// Touching the file without changing content keeps the version.
+	writeRules(t, rules, blockAdminRule+"\n"+blockDebugRule, base.Add(2*time.Minute))
+	w.checkReload()
+	if w.active.Load() != after {
+		t.Error("unchanged content must not swap the rule set")
+	}
+}
+
+func TestReload_CompileErrorKeepsPreviousSet(t *testing.T) {
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "rules.conf")
+	base := time.Now().Add(-time.Hour)
+	writeRules(t, rules, blockAdminRule, base)
+
+	w, err := New(config.WAFConfig{Enabled: true, RuleFiles: []string{rules}, ReloadInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	before := w.active.Load()
+
+	broken := "# header comment\n" + blockAdminRule + "\n\nSecRule ARGS \"@bogusOperator x\" \"id:2003,phase:1,deny\"\n"
+	writeRules(t, rules, broken, base.Add(time.Minute))
+	w.checkReload()
+
+	if w.active.Load() != before {
+		t.Fatal("failed compile must keep the previous rule set")
+	}
+	if statusFor(t, w, "/admin") != http.StatusForbidden {
+		t.Error("previous rules must remain enforced")
+	}
+	msg, _ := w.Stats()["last_reload_error"].(string)
+	if !strings.HasPrefix(msg, rules+":4:") {
+		t.Errorf("expected error located at %s:4, got %q", rules, msg)
+	}
+
+	// The same broken content is reported once.
+	w.checkReload()
+	if w.reloadErrors.Load() != 1 {
+		t.Errorf("expected 1 reload error, got %d", w.reloadErrors.Load())
+	}
+}
+
+func TestShadow_CountsWouldBlockWithoutBlocking(t *testing.T) {
+	dir := t.TempDir()
+	active := filepath.Join(dir, "active.conf")
+	shadow := filepath.Join(dir, "shadow.conf")
+	writeRules(t, active, blockAdminRule, time.Now())
+	writeRules(t, shadow, blockAdminRule+"\n"+blockDebugRule, time.Now())
+
+	w, err := New(config.WAFConfig{
+		Enabled:         true,
+		RuleFiles:       []string{active},
+		ShadowRuleFiles: []string{shadow},
+		ReloadInterval:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 3; i++ {
+		if statusFor(t, w, "/debug") != http.StatusOK {
+			t.Fatal("shadow rules must not block")
+		}
+	}
+	if statusFor(t, w, "/admin") != http.StatusForbidden {
+		t.Fatal("active rules must still block")
+	}
+
+	stats := w.Stats()
+	if stats["would_block_total"] != int64(4) {
+		t.Errorf("expected would_block_total 4, got %v", stats["would_block_total"])
+	}
+	sh := stats["shadow"].(RuleSetStatus)
+	if len(sh.TopRules) != 2 || sh.TopRules[0] != (RuleCount{ID: 2002, Count: 3}) {
+		t.Errorf("unexpected shadow top rules: %+v", sh.TopRules)
+	}
+	ac := stats["active"].(RuleSetStatus)
+	if ac.Hash == sh.Hash || ac.Version != 1 || sh.Version != 1 {
+		t.Errorf("unexpected versions/hashes: active %+v shadow %+v", ac, sh)
+	}
+	if stats["blocked_total"] != int64(1) {
+		t.Errorf("expected blocked_total 1, got %v", stats["blocked_total"])
+	}
+}
+
+func TestShadow_BodyAvailableToActiveSet(t *testing.T) {
+	dir := t.TempDir()
+	shadow := filepath.Join(dir, "shadow.conf")
+	writeRules(t, shadow, blockAdminRule, time.Now())
+
+	w, err := New(config.WAFConfig{
+		Enabled:         true,
+		InlineRules:     []string{"SecRequestBodyAccess On"},
+		ShadowRuleFiles: []string{shadow},
+		ReloadInterval:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	var got string
+	h := w.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	}))
+	req := httptest.NewRequest("POST", "/api", strings.NewReader(`{"name":"test"}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != `{"name":"test"}` {
+		t.Errorf("expected body to reach backend, got %q", got)
+	}
+}
+
+func TestShadow_LargeBodyEvaluatesPrefix(t *testing.T) {
+	dir := t.TempDir()
+	shadowFile := filepath.Join(dir, "shadow.conf")
+	writeRules(t, shadowFile, `SecRule REQUEST_BODY "@contains attack" "id:2003,phase:2,deny,status:403"`, time.Now())
+
+	w, err := New(config.WAFConfig{
+		Enabled:         true,
+		InlineRules:     []string{"SecRequestBodyAccess On"},
+		ShadowRuleFiles: []string{shadowFile},
+		ReloadInterval:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	var got int
+	h := w.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = len(b)
+	}))
+	body := "attack=1&pad=" + strings.Repeat("a", 4*shadow.MaxBodySize)
+	req := httptest.NewRequest("POST", "/api", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || got != len(body) {
+		t.Errorf("expected the whole body to reach backend, got %d with %d of %d bytes", rec.Code, got, len(body))
+	}
+	if w.Stats()["would_block_total"] != int64(1) {
+		t.Errorf("expected the shadow set to match the body prefix, got %v", w.Stats()["would_block_total"])
+	}
+}
+
+// waitShadowAsync waits until w has evaluated n requests off the request path.
+func waitShadowAsync(t *testing.T, w *WAF, n int64) shadow.Stats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s := w.Stats()["shadow_async"].(shadow.Stats)
+		if s.Evaluated >= n {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d async evaluations, got %+v", n, s)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestShadowAsync_ComparesWithActiveSet(t *testing.T) {
+	dir := t.TempDir()
+	active := filepath.Join(dir, "active.conf")
+	shadowFile := filepath.Join(dir, "shadow.conf")
+	writeRules(t, active, blockAdminRule, time.Now())
+	writeRules(t, shadowFile, blockAdminRule+"\n"+blockDebugRule, time.Now())
+
+	w, err := New(config.WAFConfig{
+		Enabled:         true,
+		RuleFiles:       []string{active},
+		ShadowRuleFiles: []string{shadowFile},
+		ShadowAsync:     true,
+		ReloadInterval:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if statusFor(t, w, "/debug") != http.StatusOK {
+		t.Fatal("shadow rules must not block")
+	}
+	if statusFor(t, w, "/admin") != http.StatusForbidden {
+		t.Fatal("active rules must still block")
+	}
+	statusFor(t, w, "/ok")
+
+	s := waitShadowAsync(t, w, 3)
+	if s.Blocked != 2 || s.Allowed != 1 {
+		t.Errorf("expected 2 would-block and 1 allow verdicts, got %+v", s)
+	}
+	want := shadow.Divergence{Compared: 3, ShadowBlocked: 1}
+	if s.Divergence == nil || *s.Divergence != want {
+		t.Errorf("expected divergence %+v, got %+v", want, s.Divergence)
+	}
+	if got := w.Stats()["would_block_total"]; got != int64(2) {
+		t.Errorf("expected would_block_total 2, got %v", got)
+	}
+}
+
+func TestShadowAsync_WithoutShadowRulesDoesNotEnforce(t *testing.T) {
+	w, err := New(config.WAFConfig{
+		Enabled:      true,
+		SQLInjection: true,
+		InlineRules:  []string{"SecRequestBodyAccess On"},
+		ShadowAsync:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	h := w.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	}))
+	body := "q=1' OR '1'='1"
+	req := httptest.NewRequest("POST", "/search", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || got != body {
+		t.Fatalf("expected request to pass with its body, got %d %q", rec.Code, got)
+	}
+	if s := waitShadowAsync(t, w, 1); s.Blocked != 1 || s.Divergence != nil {
+		t.Errorf("expected one would-block verdict without divergence, got %+v", s)
+	}
+	if stats := w.Stats(); stats["blocked_total"] != int64(0) || stats["enforce"] != false {
+		t.Errorf("expected nothing enforced, got %v", stats)
+	}
+}
+
+func TestPromoteShadow(t *testing.T) {
+	dir := t.TempDir()
+	active := filepath.Join(dir, "active.conf")
+	shadow := filepath.Join(dir, "shadow.conf")
+	base := time.Now().Add(-time.Hour)
+	writeRules(t, active, blockAdminRule, base)
+	writeRules(t, shadow, blockDebugRule, base)
+
+	m := NewWAFByRoute()
+	defer m.CloseAll()
+	if err := m.AddRoute("api", config.WAFConfig{
+		Enabled:         true,
+		RuleFiles:       []string{active},
+		ShadowRuleFiles: []string{shadow},
+		ReloadInterval:  time.Hour,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.PromoteShadow("missing"); err == nil {
+		t.Error("expected error for unknown route")
+	}
+
+	w := m.Lookup("api")
+	shadowHash := w.shadow.Load().hash
+	if err := m.PromoteShadow("api"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.PromoteShadow(); err == nil {
+		t.Error("expected error when no shadow set remains")
+	}
+
+	if got := w.active.Load(); got.hash != shadowHash || got.version != 2 {
+		t.Errorf("expected promoted set version 2 hash %s, got %d %s", shadowHash, got.version, got.hash)
+	}
+	if statusFor(t, w, "/debug") != http.StatusForbidden || statusFor(t, w, "/admin") != http.StatusOK {
+		t.Error("expected promoted rules to be enforced")
+	}
+
+	// The promoted files are now the ones watched.
+	writeRules(t, shadow, blockDebugRule+"\n"+blockAdminRule, base.Add(time.Minute))
+	w.checkReload()
+	if statusFor(t, w, "/admin") != http.StatusForbidden {
+		t.Error("expected promoted files to be reloaded")
+	}
+	if _, ok := w.Stats()["shadow"]; ok {
+		t.Error("expected no shadow set after promotion")
+	}
+}
+
+func TestSplitDirectives(t *testing.T) {
+	src := "# comment\n\nSecRuleEngine On\nSecRule ARGS \"@rx a\" \\\n  \"id:1,deny\"\nSecAction \"id:2\"\n"
+	dirs := splitDirectives(src)
+	if len(dirs) != 3 {
+		t.Fatalf("expected 3 directives, got %d: %+v", len(dirs), dirs)
+	}
+	if dirs[0].line != 3 || dirs[1].line != 4 || dirs[2].line != 6 {
+		t.Errorf("unexpected lines: %+v", dirs)
+	}
+	if dirs[1].text != `SecRule ARGS "@rx a" "id:1,deny"` {
+		t.Errorf("unexpected continuation join: %q", dirs[1].text)
+	}
+}
+
+func TestMiddleware_InspectsWebSocketUpgrade(t *testing.T) {
+	w, err := New(config.WAFConfig{
+		Enabled:      true,
+		Mode:         "block",
+		SQLInjection: true,
+		XSS:          true,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	upgrade := func(path string, header map[string]string) *http.Request {
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		blocked bool
+	}{
+		{"clean upgrade", upgrade("/ws?room=lobby", nil), false},
+		{"sqli in query", upgrade("/ws?room=1'+OR+'1'='1", nil), true},
+		{"sqli in header", upgrade("/ws", map[string]string{"X-Room": "1' OR '1'='1"}), true},
+		{"xss in protocol header", upgrade("/ws", map[string]string{"Sec-WebSocket-Protocol": "<script>alert(1)</script>"}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := w.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, tt.req)
+
+			if called == tt.blocked {
+				t.Errorf("expected blocked=%v, next called=%v", tt.blocked, called)
+			}
+			if tt.blocked && rw.Code != http.StatusForbidden {
+				t.Errorf("expected 403, got %d", rw.Code)
+			}
+		})
+	}
+
+	// Headers are only inspected on upgrade requests.
+	r := httptest.NewRequest("GET", "/api", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Room", "1' OR '1'='1")
+	called := false
+	w.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		called = true
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	if !called {
+		t.Error("plain request headers should not be inspected by the upgrade rules")
+	}
+}
+
+func TestMiddleware_XMLProtection(t *testing.T) {
+	w, err := New(config.WAFConfig{Enabled: true, Mode: "block", XMLProtection: true})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	var gotBody string
+	handler := w.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		rw.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(ct, body string) int {
+		req := httptest.NewRequest("POST", "/soap", strings.NewReader(body))
+		req.Header.Set("Content-Type", ct)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	lol := `<!ENTITY a "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa">` +
+		`<!ENTITY b "&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;">` +
+		`<!ENTITY c "&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;">` +
+		`<!ENTITY d "&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;">`
+	attacks := map[string]string{
+		"billion laughs": `<!DOCTYPE e [` + lol + `]><e>&d;</e>`,
+		"xxe":            `<!DOCTYPE e [<!ENTITY x SYSTEM "http://169.254.169.254/latest/meta-data/">]><e>&x;</e>`,
+		"deep nesting":   strings.Repeat("<a>", 500) + strings.Repeat("</a>", 500),
+	}
+	for name, body := range attacks {
+		if code := send("text/xml; charset=utf-8", body); code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", name, code)
+		}
+	}
+
+	soap := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><ping/></soap:Body></soap:Envelope>`
+	if code := send("application/soap+xml", soap); code != http.StatusOK {
+		t.Errorf("expected clean SOAP request to pass, got %d", code)
+	}
+	if gotBody != soap {
+		t.Errorf("expected body to reach the backend intact, got %q", gotBody)
+	}
+
+	// Non-XML content types are not checked.
+	if code := send("text/plain", attacks["xxe"]); code != http.StatusOK {
+		t.Errorf("expected text/plain to pass, got %d", code)
+	}
+
+	hits := w.active.Load().status().TopRules
+	if len(hits) == 0 {
+		t.Error("expected built-in XML rules to be counted")
+	}
+}
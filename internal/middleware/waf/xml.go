@@ -0,0 +1,56 @@
+package waf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/wudi/runway/internal/xmlsafe"
+)
+
+// Built-in XML protection rule IDs, reported like SecLang rule IDs.
+const (
+	ruleXMLExternalEntity = 1005 // external DTD, external or parameter entity (XXE)
+	ruleXMLEntityBomb     = 1006 // entity expansion or declaration count (billion laughs)
+	ruleXMLStructure      = 1007 // document size, nesting depth or attribute count
+)
+
+var xmlRuleIDs = map[xmlsafe.Violation]int{
+	xmlsafe.ViolationExternalEntity:  ruleXMLExternalEntity,
+	xmlsafe.ViolationParameterEntity: ruleXMLExternalEntity,
+	xmlsafe.ViolationEntityExpansion: ruleXMLEntityBomb,
+	xmlsafe.ViolationEntityCount:     ruleXMLEntityBomb,
+	xmlsafe.ViolationSize:            ruleXMLStructure,
+	xmlsafe.ViolationDepth:           ruleXMLStructure,
+	xmlsafe.ViolationAttributes:      ruleXMLStructure,
+}
+
+// inspectXML checks an XML request body against the global xml_limits and
+// returns an interruption for the first violation. Malformed documents are
+// left to the backend. The body is restored for downstream handlers.
+func inspectXML(r *http.Request) *types.Interruption {
+	if r.Body == nil || r.Body == http.NoBody || !xmlsafe.IsXMLContentType(r.Header.Get("Content-Type")) {
+		return nil
+	}
+	limit := xmlsafe.CurrentLimits().MaxBytes
+	buf, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if err != nil {
+		return nil
+	}
+
+	var xe *xmlsafe.Error
+	if err := xmlsafe.Check(buf); !errors.As(err, &xe) {
+		return nil
+	}
+	id, ok := xmlRuleIDs[xe.Violation]
+	if !ok {
+		return nil // syntax error
+	}
+	return &types.Interruption{RuleID: id, Action: "deny", Status: http.StatusForbidden, Data: xe.Error()}
+}
@@ -0,0 +1,165 @@
+package warmup
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/middleware"
+)
+
+// Warmer ramps an instance's traffic share from an initial weight to 100
+// after start-up (or a large reload) so cold caches and pools are not hit
+// with a full share immediately.
+type Warmer struct {
+	mu            sync.RWMutex
+	cfg           config.WarmupConfig
+	startedAt     time.Time
+	restartReason string
+
+	restarts atomic.Int64
+	rejected atomic.Int64
+	allowed  atomic.Int64
+	seq      atomic.Uint64
+
+	now func() time.Time
+}
+
+// New creates a Warmer that starts warming up immediately.
+func New(cfg config.WarmupConfig) *Warmer {
+	w := &Warmer{now: time.Now}
+	w.cfg = withDefaults(cfg)
+	w.startedAt = w.now()
+	w.restartReason = "startup"
+	return w
+}
+
+func withDefaults(cfg config.WarmupConfig) config.WarmupConfig {
+	if cfg.Duration <= 0 {
+		cfg.Duration = 60 * time.Second
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = 1
+	}
+	if cfg.ReloadThreshold <= 0 {
+		cfg.ReloadThreshold = 0.5
+	}
+	return cfg
+}
+
+// Weight returns the current traffic weight in the range 0-100.
+func (w *Warmer) Weight() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.weightLocked()
+}
+
+func (w *Warmer) weightLocked() int {
+	elapsed := w.now().Sub(w.startedAt)
+	if elapsed >= w.cfg.Duration {
+		return 100
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	initial := w.cfg.InitialWeight
+	return initial + int(int64(100-initial)*int64(elapsed)/int64(w.cfg.Duration))
+}
+
+// WarmingUp reports whether the ramp is still in progress.
+func (w *Warmer) WarmingUp() bool {
+	return w.Weight() < 100
+}
+
+// Restart begins a new warm-up ramp.
+func (w *Warmer) Restart(reason string) {
+	w.mu.Lock()
+	w.startedAt = w.now()
+	w.restartReason = reason
+	w.mu.Unlock()
+	w.restarts.Add(1)
+}
+
+// Finish ends the current ramp so the weight is 100 immediately.
+func (w *Warmer) Finish() {
+	w.mu.Lock()
+	w.startedAt = w.now().Add(-w.cfg.Duration)
+	w.mu.Unlock()
+}
+
+// Reconfigure applies new settings without restarting the current ramp.
+func (w *Warmer) Reconfigure(cfg config.WarmupConfig) {
+	w.mu.Lock()
+	w.cfg = withDefaults(cfg)
+	w.mu.Unlock()
+}
+
+// ShouldRestart reports whether a reload that rebuilt the given fraction of
+// routes is large enough to warrant a new warm-up.
+func (w *Warmer) ShouldRestart(changedFraction float64) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return changedFraction > w.cfg.ReloadThreshold
+}
+
+// Admit applies self-throttling to a single request. When self_throttle is
+// enabled it rejects the not-yet-warm fraction of requests with 503 and
+// Retry-After, returning false once the response has been written.
+func (w *Warmer) Admit(rw http.ResponseWriter) bool {
+	w.mu.RLock()
+	throttle := w.cfg.SelfThrottle
+	weight := w.weightLocked()
+	retryAfter := w.cfg.RetryAfter
+	w.mu.RUnlock()
+
+	// Spread rejections evenly: of every 100 requests, reject 100-weight.
+	if throttle && weight < 100 && int(w.seq.Add(1)%100) < 100-weight {
+		w.rejected.Add(1)
+		rw.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		http.Error(rw, `{"error":"instance warming up"}`, http.StatusServiceUnavailable)
+		return false
+	}
+	w.allowed.Add(1)
+	return true
+}
+
+// Middleware returns a middleware that self-throttles requests during warm-up.
+func (w *Warmer) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if !w.Admit(rw) {
+				return
+			}
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// Stats returns current warm-up state.
+func (w *Warmer) Stats() map[string]interface{} {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	weight := w.weightLocked()
+	remaining := w.cfg.Duration - w.now().Sub(w.startedAt)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return map[string]interface{}{
+		"enabled":          true,
+		"warming_up":       weight < 100,
+		"weight":           weight,
+		"initial_weight":   w.cfg.InitialWeight,
+		"duration":         w.cfg.Duration.String(),
+		"remaining":        remaining.String(),
+		"started_at":       w.startedAt,
+		"restart_reason":   w.restartReason,
+		"restarts":         w.restarts.Load(),
+		"self_throttle":    w.cfg.SelfThrottle,
+		"reload_threshold": w.cfg.ReloadThreshold,
+		"rejected":         w.rejected.Load(),
+		"allowed":          w.allowed.Load(),
+	}
+}
@@ -0,0 +1,131 @@
+package warmup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func newTestWarmer(cfg config.WarmupConfig) (*Warmer, *time.Time) {
+	now := time.Unix(1000, 0)
+	w := New(cfg)
+	w.now = func() time.Time { return now }
+	w.startedAt = now
+	return w, &now
+}
+
+func TestWarmer_WeightRamp(t *testing.T) {
+	w, now := newTestWarmer(config.WarmupConfig{Enabled: true, InitialWeight: 20, Duration: 100 * time.Second})
+
+	if got := w.Weight(); got != 20 {
+		t.Errorf("expected initial weight 20, got %d", got)
+	}
+	*now = now.Add(50 * time.Second)
+	if got := w.Weight(); got != 60 {
+		t.Errorf("expected weight 60 halfway, got %d", got)
+	}
+	*now = now.Add(50 * time.Second)
+	if got := w.Weight(); got != 100 {
+		t.Errorf("expected weight 100 after duration, got %d", got)
+	}
+	if w.WarmingUp() {
+		t.Error("expected warm-up to be complete")
+	}
+}
+
+func TestWarmer_RestartAndFinish(t *testing.T) {
+	w, now := newTestWarmer(config.WarmupConfig{Enabled: true, Duration: 10 * time.Second})
+
+	*now = now.Add(20 * time.Second)
+	if w.WarmingUp() {
+		t.Fatal("expected warm-up to be complete")
+	}
+
+	w.Restart("reload")
+	if got := w.Weight(); got != 0 {
+		t.Errorf("expected weight 0 after restart, got %d", got)
+	}
+	stats := w.Stats()
+	if stats["restarts"] != int64(1) || stats["restart_reason"] != "reload" {
+		t.Errorf("unexpected restart stats: %v", stats)
+	}
+
+	w.Finish()
+	if got := w.Weight(); got != 100 {
+		t.Errorf("expected weight 100 after finish, got %d", got)
+	}
+}
+
+func TestWarmer_ShouldRestart(t *testing.T) {
+	w := New(config.WarmupConfig{Enabled: true, ReloadThreshold: 0.25})
+	if w.ShouldRestart(0.25) {
+		t.Error("expected no restart at the threshold")
+	}
+	if !w.ShouldRestart(0.3) {
+		t.Error("expected restart above the threshold")
+	}
+
+	// Default threshold is 0.5
+	w = New(config.WarmupConfig{Enabled: true})
+	if w.ShouldRestart(0.4) || !w.ShouldRestart(0.6) {
+		t.Error("expected default threshold of 0.5")
+	}
+}
+
+func TestWarmer_SelfThrottle(t *testing.T) {
+	w, now := newTestWarmer(config.WarmupConfig{
+		Enabled:       true,
+		InitialWeight: 0,
+		Duration:      100 * time.Second,
+		SelfThrottle:  true,
+		RetryAfter:    7,
+	})
+	*now = now.Add(30 * time.Second) // weight 30
+
+	handler := w.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+
+	rejected := 0
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code == http.StatusServiceUnavailable {
+			rejected++
+			if rec.Header().Get("Retry-After") != "7" {
+				t.Fatalf("expected Retry-After 7, got %q", rec.Header().Get("Retry-After"))
+			}
+		}
+	}
+	if rejected != 70 {
+		t.Errorf("expected 70 of 100 requests rejected at weight 30, got %d", rejected)
+	}
+
+	*now = now.Add(70 * time.Second)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 once warm, got %d", rec.Code)
+	}
+}
+
+func TestWarmer_NoThrottleWithoutSelfThrottle(t *testing.T) {
+	w, _ := newTestWarmer(config.WarmupConfig{Enabled: true, Duration: time.Minute})
+
+	handler := w.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200 without self_throttle, got %d", rec.Code)
+		}
+	}
+	if w.Stats()["allowed"] != int64(10) {
+		t.Errorf("expected 10 allowed, got %v", w.Stats()["allowed"])
+	}
+}
@@ -22,6 +22,13 @@ const (
 	LogLevelError = 4
 )
 
+// Result codes for host_metric_counter_inc / host_metric_gauge_set.
+const (
+	MetricOK      = 0  // sample recorded
+	MetricInvalid = -1 // invalid name, labels or value, or metrics unavailable
+	MetricLimit   = -2 // the plugin's series limit was reached
+)
+
 // RequestContext is serialized as JSON and written to guest memory for on_request.
 type RequestContext struct {
 	Method   string            `json:"method"`
@@ -2,12 +2,15 @@ package wasm
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	"go.uber.org/zap"
 
+	"github.com/wudi/runway/internal/metrics"
 )
 
 type ctxKey struct{}
@@ -24,6 +27,8 @@ type hostState struct {
 	routeID       string
 	scheme        string
 	logger        *zap.Logger
+	plugin        string
+	metrics       *metrics.PluginMetrics
 }
 
 func contextWithHostState(ctx context.Context, hs *hostState) context.Context {
@@ -115,6 +120,16 @@ func registerHostFunctions(rt wazero.Runtime) (wazero.CompiledModule, error) {
 		WithParameterNames("status", "body_ptr", "body_len").
 		Export("host_send_response")
 
+	env.NewFunctionBuilder().
+		WithFunc(hostMetricCounterInc).
+		WithParameterNames("name_ptr", "name_len", "labels_ptr", "labels_len", "value").
+		Export("host_metric_counter_inc")
+
+	env.NewFunctionBuilder().
+		WithFunc(hostMetricGaugeSet).
+		WithParameterNames("name_ptr", "name_len", "labels_ptr", "labels_len", "value").
+		Export("host_metric_gauge_set")
+
 	return env.Compile(context.Background())
 }
 
@@ -313,3 +328,52 @@ func hostSendResponse(ctx context.Context, mod api.Module, status, bodyPtr, body
 		Body:       body,
 	}
 }
+
+func hostMetricCounterInc(ctx context.Context, mod api.Module, namePtr, nameLen, labelsPtr, labelsLen uint32, value float64) int32 {
+	return hostRecordMetric(ctx, mod, false, namePtr, nameLen, labelsPtr, labelsLen, value)
+}
+
+func hostMetricGaugeSet(ctx context.Context, mod api.Module, namePtr, nameLen, labelsPtr, labelsLen uint32, value float64) int32 {
+	return hostRecordMetric(ctx, mod, true, namePtr, nameLen, labelsPtr, labelsLen, value)
+}
+
+// hostRecordMetric reports a sample to the plugin metrics. labels is a JSON
+// object of string values, or empty. Returns MetricOK, MetricInvalid or
+// MetricLimit.
+func hostRecordMetric(ctx context.Context, mod api.Module, gauge bool, namePtr, nameLen, labelsPtr, labelsLen uint32, value float64) int32 {
+	hs := hostStateFromContext(ctx)
+	if hs == nil || hs.metrics == nil {
+		return MetricInvalid
+	}
+	name, ok := readGuestString(mod, namePtr, nameLen)
+	if !ok {
+		return MetricInvalid
+	}
+	raw, ok := readGuestBytes(mod, labelsPtr, labelsLen)
+	if !ok {
+		return MetricInvalid
+	}
+	var labels map[string]string
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &labels); err != nil {
+			return MetricInvalid
+		}
+	}
+
+	var err error
+	if gauge {
+		err = hs.metrics.Set(hs.plugin, hs.routeID, name, labels, value)
+	} else {
+		err = hs.metrics.Inc(hs.plugin, hs.routeID, name, labels, value)
+	}
+	switch {
+	case err == nil:
+		return MetricOK
+	case errors.Is(err, metrics.ErrPluginSeriesLimit):
+		return MetricLimit
+	}
+	if hs.logger != nil {
+		hs.logger.Debug("wasm plugin metric rejected", zap.String("plugin", hs.plugin), zap.Error(err))
+	}
+	return MetricInvalid
+}
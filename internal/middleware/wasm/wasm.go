@@ -17,6 +17,7 @@ import (
 	"github.com/wudi/runway/internal/byroute"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/metrics"
 	"github.com/wudi/runway/internal/middleware"
 )
 
@@ -28,6 +29,8 @@ type WasmPlugin struct {
 	pool     *InstancePool
 	timeout  time.Duration
 	compiled wazero.CompiledModule
+	routeID  string
+	metrics  *metrics.PluginMetrics
 
 	requestInvocations  atomic.Int64
 	responseInvocations atomic.Int64
@@ -175,9 +178,11 @@ func (p *WasmPlugin) RequestMiddleware() middleware.Middleware {
 				reqHeaders:   reqHeaders,
 				reqBody:      reqBody,
 				pluginConfig: p.cfg.Config,
-				routeID:      "", // set from context if available
+				routeID:      p.routeID,
 				scheme:       schemeFromRequest(r),
 				logger:       logger,
+				plugin:       p.cfg.MetricsName(),
+				metrics:      p.metrics,
 			}
 			ctx = contextWithHostState(ctx, hs)
 
@@ -274,9 +279,11 @@ func (p *WasmPlugin) ResponseMiddleware() middleware.Middleware {
 				respHeaders:  respHeaders,
 				respBody:     respBody,
 				pluginConfig: p.cfg.Config,
-				routeID:      "",
+				routeID:      p.routeID,
 				scheme:       schemeFromRequest(r),
 				logger:       logger,
+				plugin:       p.cfg.MetricsName(),
+				metrics:      p.metrics,
 			}
 			ctx = contextWithHostState(ctx, hs)
 
@@ -425,6 +432,7 @@ type WasmByRoute struct {
 	runtime wazero.Runtime
 	envMod  wazero.CompiledModule
 	wasmCfg config.WasmConfig
+	metrics *metrics.PluginMetrics
 }
 
 // NewWasmByRoute creates a new per-route WASM plugin manager.
@@ -432,6 +440,12 @@ func NewWasmByRoute(cfg config.WasmConfig) *WasmByRoute {
 	return &WasmByRoute{wasmCfg: cfg}
 }
 
+// SetPluginMetrics exposes pm to the plugins of routes added afterwards
+// through host_metric_counter_inc and host_metric_gauge_set.
+func (m *WasmByRoute) SetPluginMetrics(pm *metrics.PluginMetrics) {
+	m.metrics = pm
+}
+
 // ensureRuntime lazily initializes the shared wazero runtime.
 func (m *WasmByRoute) ensureRuntime(ctx context.Context) error {
 	if m.runtime != nil {
@@ -489,6 +503,8 @@ func (m *WasmByRoute) AddRoute(routeID string, cfgs []config.WasmPluginConfig) e
 			}
 			return err
 		}
+		p.routeID = routeID
+		p.metrics = m.metrics
 		plugins = append(plugins, p)
 	}
 
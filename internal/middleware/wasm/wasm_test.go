@@ -8,12 +8,14 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/tetratelabs/wazero"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/metrics"
 )
 
 // --- Minimal WASM binary builders ---
@@ -795,3 +797,56 @@ func TestRequestBody_Passthrough(t *testing.T) {
 		t.Errorf("expected body 'hello world', got %q", gotBody)
 	}
 }
+
+func TestHostMetrics(t *testing.T) {
+	ctx := context.Background()
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer rt.Close(ctx)
+
+	// A module with a single exported memory page for the host to read.
+	var b bytes.Buffer
+	b.Write([]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00})
+	b.Write(encodeSection(5, []byte{1, 0x00, 1}))
+	b.Write(encodeSection(7, encodeVector([][]byte{encodeExport("memory", 0x02, 0)})))
+	mod, err := rt.Instantiate(ctx, b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(off uint32, s string) (uint32, uint32) {
+		mod.Memory().Write(off, []byte(s))
+		return off, uint32(len(s))
+	}
+	namePtr, nameLen := put(0, "jobs_total")
+	gaugePtr, gaugeLen := put(32, "queue_depth")
+	labelsPtr, labelsLen := put(64, `{"queue":"emails"}`)
+	otherPtr, otherLen := put(128, `{"queue":"sms"}`)
+	badPtr, badLen := put(192, `{"queue":`)
+
+	c := metrics.NewCollector()
+	c.Plugins().SetMaxSeries(2)
+	ctx = contextWithHostState(ctx, &hostState{routeID: "jobs", plugin: "worker", metrics: c.Plugins()})
+
+	if rc := hostMetricCounterInc(ctx, mod, namePtr, nameLen, labelsPtr, labelsLen, 2); rc != MetricOK {
+		t.Errorf("counter: expected MetricOK, got %d", rc)
+	}
+	if rc := hostMetricGaugeSet(ctx, mod, gaugePtr, gaugeLen, 0, 0, 9); rc != MetricOK {
+		t.Errorf("gauge: expected MetricOK, got %d", rc)
+	}
+	if rc := hostMetricCounterInc(ctx, mod, namePtr, nameLen, badPtr, badLen, 1); rc != MetricInvalid {
+		t.Errorf("malformed labels: expected MetricInvalid, got %d", rc)
+	}
+	if rc := hostMetricCounterInc(ctx, mod, namePtr, nameLen, otherPtr, otherLen, 1); rc != MetricLimit {
+		t.Errorf("third series: expected MetricLimit, got %d", rc)
+	}
+
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, want := range []string{
+		`runway_plugin_worker_jobs_total{plugin="worker",queue="emails",route="jobs"} 2`,
+		`runway_plugin_worker_queue_depth{plugin="worker",route="jobs"} 9`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("expected %q in metrics output", want)
+		}
+	}
+}
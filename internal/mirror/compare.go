@@ -52,39 +52,60 @@ func CompareMirrorResponse(primary *PrimaryResponse, mirrorResp *http.Response)
 // CompareMirrorResponseDetailed compares a mirror response against the primary with detailed diffs.
 // The mirror response body is read and closed by this function.
 func CompareMirrorResponseDetailed(primary *PrimaryDiffResponse, mirrorResp *http.Response, dc *DiffConfig) (CompareResult, *DiffDetail) {
+	return compareMirrorResponseDetailed(primary, mirrorResp, dc, nil)
+}
+
+func compareMirrorResponseDetailed(primary *PrimaryDiffResponse, mirrorResp *http.Response, dc *DiffConfig, secrets []string) (CompareResult, *DiffDetail) {
+	body, hash, truncated := readMirrorBody(mirrorResp.Body, dc.maxBodyCapture)
+	return compareCaptured(primary, &PrimaryDiffResponse{
+		StatusCode: mirrorResp.StatusCode,
+		Headers:    mirrorResp.Header,
+		Body:       body,
+		BodyHash:   hash,
+		Truncated:  truncated,
+	}, dc, secrets)
+}
+
+// compareCaptured compares two captured responses with detailed diffs.
+// Occurrences of secrets, the values the copy policy set on the copy, are
+// redacted from both responses first, so a target echoing them back never
+// exposes them in a diff.
+func compareCaptured(primary, mirror *PrimaryDiffResponse, dc *DiffConfig, secrets []string) (CompareResult, *DiffDetail) {
+	if len(secrets) > 0 {
+		primary = redactSecrets(primary, secrets)
+		mirror = redactSecrets(mirror, secrets)
+	}
 	detail := &DiffDetail{}
 	result := CompareResult{StatusMatch: true, BodyMatch: true}
 
 	// 1. Status comparison
-	if primary.StatusCode != mirrorResp.StatusCode {
+	if primary.StatusCode != mirror.StatusCode {
 		result.StatusMatch = false
 		detail.StatusDiff = &StatusDiff{
 			PrimaryStatus: primary.StatusCode,
-			MirrorStatus:  mirrorResp.StatusCode,
+			MirrorStatus:  mirror.StatusCode,
 		}
 	}
 
 	// 2. Header comparison
-	detail.HeaderDiffs = compareHeaders(primary.Headers, mirrorResp.Header, dc.ignoreHeaders)
-
-	// 3. Body comparison — read mirror body (up to limit + hash)
-	mirrorBody, mirrorHash, mirrorTruncated := readMirrorBody(mirrorResp.Body, dc.maxBodyCapture)
+	detail.HeaderDiffs = compareHeaders(primary.Headers, mirror.Headers, dc.ignoreHeaders)
 
-	if primary.Truncated || mirrorTruncated {
+	// 3. Body comparison
+	if primary.Truncated || mirror.Truncated {
 		// Either side truncated — fall back to hash comparison
-		if primary.BodyHash != mirrorHash {
+		if primary.BodyHash != mirror.BodyHash {
 			result.BodyMatch = false
 			detail.BodyDiffs = append(detail.BodyDiffs, BodyDiff{
 				Type: "hash_mismatch",
 				Details: map[string]interface{}{
 					"primary_hash_prefix": fmt.Sprintf("%x", primary.BodyHash[:8]),
-					"mirror_hash_prefix":  fmt.Sprintf("%x", mirrorHash[:8]),
+					"mirror_hash_prefix":  fmt.Sprintf("%x", mirror.BodyHash[:8]),
 					"truncated":           true,
 				},
 			})
 		}
 	} else {
-		detail.BodyDiffs = compareBody(primary.Body, mirrorBody, primary.BodyHash, mirrorHash, dc.ignoreJSONFields)
+		detail.BodyDiffs = compareBody(primary.Body, mirror.Body, primary.BodyHash, mirror.BodyHash, dc.ignoreJSONFields)
 		if len(detail.BodyDiffs) > 0 {
 			result.BodyMatch = false
 		}
@@ -84,6 +84,7 @@ func (m *MirrorMetrics) addLatency(d time.Duration) {
 
 // MirrorSnapshot is a point-in-time summary of mirror metrics.
 type MirrorSnapshot struct {
+	ShadowRoute      string        `json:"shadow_route,omitempty"`
 	TotalMirrored    int64         `json:"total_mirrored"`
 	TotalErrors      int64         `json:"total_errors"`
 	TotalCompared    int64         `json:"total_compared"`
@@ -95,6 +96,7 @@ type MirrorSnapshot struct {
 	LatencyP50       time.Duration `json:"latency_p50_ms"`
 	LatencyP95       time.Duration `json:"latency_p95_ms"`
 	LatencyP99       time.Duration `json:"latency_p99_ms"`
+	Sink             *SinkStats    `json:"sink,omitempty"`
 }
 
 // Snapshot returns a point-in-time summary of mirror metrics.
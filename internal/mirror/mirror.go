@@ -4,9 +4,10 @@ import (
 	"bytes"
 	"context"
 	"io"
-	"math/rand"
+	"maps"
 	"net/http"
 	"net/url"
+	"slices"
 	"time"
 
 	"github.com/wudi/runway/internal/byroute"
@@ -14,12 +15,17 @@ import (
 	"github.com/wudi/runway/internal/logging"
 	"go.uber.org/zap"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/proxy/copypolicy"
+	"github.com/wudi/runway/internal/randutil"
+	"github.com/wudi/runway/variables"
 )
 
 // Mirror handles traffic mirroring/shadowing for a route
 type Mirror struct {
 	enabled       bool
 	backends      []string
+	shadowRoute   string
+	routeHandler  func(routeID string) http.Handler
 	percentage    int
 	client        *http.Client
 	conditions    *Conditions
@@ -29,12 +35,15 @@ type Mirror struct {
 	diffConfig    *DiffConfig
 	mismatchStore *MismatchStore
 	metrics       *MirrorMetrics
+	sink          *Sink              // records copies to files instead of sending them
+	policy        *copypolicy.Policy // header and query policy of the copies
 }
 
 // New creates a new Mirror from config
 func New(cfg config.MirrorConfig) (*Mirror, error) {
 	m := &Mirror{
 		enabled:     cfg.Enabled,
+		shadowRoute: cfg.ShadowRoute,
 		percentage:  cfg.Percentage,
 		compare:     cfg.Compare.Enabled,
 		logMismatch: cfg.Compare.LogMismatches,
@@ -79,7 +88,7 @@ func New(cfg config.MirrorConfig) (*Mirror, error) {
 
 // IsEnabled returns whether mirroring is enabled
 func (m *Mirror) IsEnabled() bool {
-	return m.enabled && len(m.backends) > 0
+	return m.enabled && (len(m.backends) > 0 || m.shadowRoute != "" || m.sink != nil)
 }
 
 // ShouldMirror returns whether this request should be mirrored
@@ -87,6 +96,10 @@ func (m *Mirror) ShouldMirror(r *http.Request) bool {
 	if !m.IsEnabled() {
 		return false
 	}
+	// A shadowed copy is never mirrored again
+	if variables.IsShadow(r) {
+		return false
+	}
 	// Check conditions first
 	if m.conditions != nil && !m.conditions.Match(r) {
 		return false
@@ -94,7 +107,7 @@ func (m *Mirror) ShouldMirror(r *http.Request) bool {
 	if m.percentage >= 100 {
 		return true
 	}
-	return rand.Intn(100) < m.percentage
+	return randutil.Intn(100) < m.percentage
 }
 
 // CompareEnabled returns whether response comparison is enabled.
@@ -122,37 +135,79 @@ func BufferRequestBody(r *http.Request) ([]byte, error) {
 	return body, nil
 }
 
+// mirrorCopy holds what the copies of one request are built from. It is
+// captured while the request is served, with the copy policy applied, so
+// the copies never read the primary request's header map.
+type mirrorCopy struct {
+	header   http.Header
+	rawQuery string
+	secrets  []string // values set by the copy policy, redacted from diffs
+}
+
+// newCopy captures the copy of r, applying the copy policy.
+func (m *Mirror) newCopy(r *http.Request) *mirrorCopy {
+	c := &mirrorCopy{header: r.Header.Clone(), rawQuery: m.policy.FilterQuery(r.URL.RawQuery)}
+	if c.header == nil {
+		c.header = make(http.Header)
+	}
+	c.secrets = m.policy.ApplyHeaders(r, c.header)
+	return c
+}
+
+// sinkCopy returns the request the sink records for r: r itself, or a
+// shallow copy with the copy policy applied.
+func (m *Mirror) sinkCopy(r *http.Request) *http.Request {
+	if m.policy == nil {
+		return r
+	}
+	cp := m.newCopy(r)
+	rec := new(http.Request)
+	*rec = *r
+	u := *r.URL
+	u.RawQuery = cp.rawQuery
+	rec.URL = &u
+	rec.Header = cp.header
+	return rec
+}
+
 // SendAsync sends mirrored requests asynchronously (fire-and-forget).
 // If primary is non-nil and compare is enabled, responses are compared.
 func (m *Mirror) SendAsync(r *http.Request, body []byte, primary *PrimaryResponse) {
+	cp := m.newCopy(r)
+	if m.shadowRoute != "" {
+		req, cancel := m.shadowRequest(r, body, cp)
+		go m.sendShadow(req, cancel, primary, nil, cp.secrets)
+		return
+	}
 	for _, backend := range m.backends {
-		go m.sendMirrorWithMetrics(r, backend, body, primary)
+		go m.sendMirrorWithMetrics(r, backend, body, cp, primary)
 	}
 }
 
 // SendAsyncDetailed sends mirrored requests with detailed diff comparison.
 func (m *Mirror) SendAsyncDetailed(r *http.Request, body []byte, primary *PrimaryDiffResponse) {
+	cp := m.newCopy(r)
+	if m.shadowRoute != "" {
+		req, cancel := m.shadowRequest(r, body, cp)
+		go m.sendShadow(req, cancel, nil, primary, cp.secrets)
+		return
+	}
 	for _, backend := range m.backends {
-		go m.sendMirrorDetailed(r, backend, body, primary)
+		go m.sendMirrorDetailed(r, backend, body, cp, primary)
 	}
 }
 
-func (m *Mirror) sendMirrorWithMetrics(original *http.Request, backendURL string, body []byte, primary *PrimaryResponse) {
-	start := time.Now()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
+// newBackendRequest builds the request sent to a mirror backend from cp.
+func newBackendRequest(ctx context.Context, original *http.Request, backendURL string, body []byte, cp *mirrorCopy) (*http.Request, error) {
 	targetURL, err := url.Parse(backendURL)
 	if err != nil {
-		m.metrics.RecordError()
-		return
+		return nil, err
 	}
 
 	// Build the mirrored URL
 	mirrorURL := *targetURL
 	mirrorURL.Path = original.URL.Path
-	mirrorURL.RawQuery = original.URL.RawQuery
+	mirrorURL.RawQuery = cp.rawQuery
 
 	var bodyReader io.Reader
 	if body != nil {
@@ -161,19 +216,24 @@ func (m *Mirror) sendMirrorWithMetrics(original *http.Request, backendURL string
 
 	req, err := http.NewRequestWithContext(ctx, original.Method, mirrorURL.String(), bodyReader)
 	if err != nil {
-		m.metrics.RecordError()
-		return
+		return nil, err
 	}
+	req.Header = cp.header.Clone()
+	req.Header.Set("X-Mirrored-From", original.Host)
+	return req, nil
+}
 
-	// Copy relevant headers
-	for k, vv := range original.Header {
-		for _, v := range vv {
-			req.Header.Add(k, v)
-		}
-	}
+func (m *Mirror) sendMirrorWithMetrics(original *http.Request, backendURL string, body []byte, cp *mirrorCopy, primary *PrimaryResponse) {
+	start := time.Now()
 
-	// Mark as mirrored
-	req.Header.Set("X-Mirrored-From", original.Host)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := newBackendRequest(ctx, original, backendURL, body, cp)
+	if err != nil {
+		m.metrics.RecordError()
+		return
+	}
 
 	resp, err := m.client.Do(req)
 	if err != nil {
@@ -205,40 +265,18 @@ func (m *Mirror) sendMirrorWithMetrics(original *http.Request, backendURL string
 	m.metrics.RecordSuccess(latency)
 }
 
-func (m *Mirror) sendMirrorDetailed(original *http.Request, backendURL string, body []byte, primary *PrimaryDiffResponse) {
+func (m *Mirror) sendMirrorDetailed(original *http.Request, backendURL string, body []byte, cp *mirrorCopy, primary *PrimaryDiffResponse) {
 	start := time.Now()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	targetURL, err := url.Parse(backendURL)
+	req, err := newBackendRequest(ctx, original, backendURL, body, cp)
 	if err != nil {
 		m.metrics.RecordError()
 		return
 	}
 
-	mirrorURL := *targetURL
-	mirrorURL.Path = original.URL.Path
-	mirrorURL.RawQuery = original.URL.RawQuery
-
-	var bodyReader io.Reader
-	if body != nil {
-		bodyReader = bytes.NewReader(body)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, original.Method, mirrorURL.String(), bodyReader)
-	if err != nil {
-		m.metrics.RecordError()
-		return
-	}
-
-	for k, vv := range original.Header {
-		for _, v := range vv {
-			req.Header.Add(k, v)
-		}
-	}
-	req.Header.Set("X-Mirrored-From", original.Host)
-
 	resp, err := m.client.Do(req)
 	if err != nil {
 		m.metrics.RecordError()
@@ -248,7 +286,15 @@ func (m *Mirror) sendMirrorDetailed(original *http.Request, backendURL string, b
 
 	latency := time.Since(start)
 
-	result, detail := CompareMirrorResponseDetailed(primary, resp, m.diffConfig)
+	result, detail := compareMirrorResponseDetailed(primary, resp, m.diffConfig, cp.secrets)
+	m.recordDetailed(original, backendURL, result, detail, primary.StatusCode, resp.StatusCode)
+
+	m.metrics.RecordSuccess(latency)
+}
+
+// recordDetailed records a detailed comparison and stores and logs any
+// mismatch against backend.
+func (m *Mirror) recordDetailed(original *http.Request, backend string, result CompareResult, detail *DiffDetail, primaryStatus, mirrorStatus int) {
 	m.metrics.RecordDetailedComparison(result, detail)
 
 	if detail.HasDiffs() {
@@ -256,7 +302,7 @@ func (m *Mirror) sendMirrorDetailed(original *http.Request, backendURL string, b
 			Timestamp: time.Now(),
 			Method:    original.Method,
 			Path:      original.URL.Path,
-			Backend:   backendURL,
+			Backend:   backend,
 			Detail:    *detail,
 			DiffTypes: detail.DiffTypes(),
 		}
@@ -265,17 +311,15 @@ func (m *Mirror) sendMirrorDetailed(original *http.Request, backendURL string, b
 		if m.logMismatch {
 			logging.Warn("mirror detailed mismatch",
 				zap.String("path", original.URL.Path),
-				zap.String("backend", backendURL),
+				zap.String("backend", backend),
 				zap.Bool("status_match", result.StatusMatch),
 				zap.Bool("body_match", result.BodyMatch),
 				zap.Strings("diff_types", detail.DiffTypes()),
-				zap.Int("primary_status", primary.StatusCode),
-				zap.Int("mirror_status", resp.StatusCode),
+				zap.Int("primary_status", primaryStatus),
+				zap.Int("mirror_status", mirrorStatus),
 			)
 		}
 	}
-
-	m.metrics.RecordSuccess(latency)
 }
 
 // GetMismatchStore returns the mismatch store (nil if detailed diff is not enabled).
@@ -286,6 +330,8 @@ func (m *Mirror) GetMismatchStore() *MismatchStore {
 // MirrorByRoute manages mirrors per route
 type MirrorByRoute struct {
 	byroute.Manager[*Mirror]
+	routeHandler func(routeID string) http.Handler
+	secrets      *config.SecretRegistry
 }
 
 // NewMirrorByRoute creates a new per-route mirror manager
@@ -299,15 +345,52 @@ func (m *MirrorByRoute) AddRoute(routeID string, cfg config.MirrorConfig) error
 	if err != nil {
 		return err
 	}
+	mirror.routeHandler = m.routeHandler
+	if mirror.policy, err = copypolicy.New(cfg.CopyRequestPolicy, m.secrets); err != nil {
+		return err
+	}
+	if cfg.Enabled && cfg.Sink.Enabled {
+		// Headers the copy policy sets may carry service credentials.
+		sinkCfg := cfg.Sink
+		sinkCfg.RedactHeaders = append(slices.Clone(sinkCfg.RedactHeaders), slices.Sorted(maps.Keys(cfg.SetHeaders))...)
+		if mirror.sink, err = NewSink(routeID, sinkCfg); err != nil {
+			return err
+		}
+	}
 	m.Add(routeID, mirror)
 	return nil
 }
 
+// CloseAll completes the files of all sinks.
+func (m *MirrorByRoute) CloseAll() {
+	byroute.ForEach(&m.Manager, func(mir *Mirror) {
+		if mir.sink != nil {
+			mir.sink.Close()
+		}
+	})
+}
+
+// SetRouteHandlers sets the lookup of compiled route handlers that mirrors
+// with shadow_route dispatch to. It must be called before AddRoute.
+func (m *MirrorByRoute) SetRouteHandlers(fn func(routeID string) http.Handler) {
+	m.routeHandler = fn
+}
+
+// SetSecrets sets the registry the secret function of set_headers
+// templates resolves through. It must be called before AddRoute.
+func (m *MirrorByRoute) SetSecrets(registry *config.SecretRegistry) {
+	m.secrets = registry
+}
 
 // Stats returns a snapshot of metrics for all routes.
 func (m *MirrorByRoute) Stats() map[string]MirrorSnapshot {
 	return byroute.CollectStats(&m.Manager, func(mir *Mirror) MirrorSnapshot {
 		snap := mir.metrics.Snapshot()
+		snap.ShadowRoute = mir.shadowRoute
+		if mir.sink != nil {
+			st := mir.sink.Stats()
+			snap.Sink = &st
+		}
 		if mir.mismatchStore != nil {
 			snap.MismatchStoreSize = mir.mismatchStore.Size()
 		}
@@ -350,6 +433,12 @@ func (m *Mirror) Middleware() middleware.Middleware {
 				return
 			}
 
+			if m.sink != nil {
+				m.sink.Record(m.sinkCopy(r), mirrorBody)
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			if m.detailedDiff {
 				dw := NewDiffCapturingWriter(w, m.diffConfig.maxBodyCapture)
 				next.ServeHTTP(dw, r)
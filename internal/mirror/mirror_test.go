@@ -2,8 +2,11 @@ package mirror
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -215,3 +218,98 @@ func TestMirrorStats(t *testing.T) {
 		t.Error("expected stats for r1")
 	}
 }
+
+func TestMirrorCopyPolicy(t *testing.T) {
+	const secret = "svc-secret-123"
+	t.Setenv("MIRROR_SERVICE_TOKEN", secret)
+
+	type seen struct {
+		header http.Header
+		query  string
+	}
+	seenCh := make(chan seen, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenCh <- seen{r.Header.Clone(), r.URL.RawQuery}
+		// Echo the injected credential back, as a debugging target might.
+		token := r.Header.Get("X-Service-Token")
+		w.Header().Set("X-Echo-Token", token)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":1,"note":"token %s"}`, token)
+	}))
+	defer server.Close()
+
+	mbr := NewMirrorByRoute()
+	mbr.SetSecrets(config.NewDefaultSecretRegistry(config.SecretsConfig{}))
+	err := mbr.AddRoute("orders-v1", config.MirrorConfig{
+		Enabled:  true,
+		Backends: []config.BackendConfig{{URL: server.URL}},
+		Compare:  config.MirrorCompareConfig{Enabled: true, DetailedDiff: true},
+		CopyRequestPolicy: config.CopyRequestPolicy{
+			StripHeaders: []string{"Authorization", "Cookie"},
+			SetHeaders: map[string]string{
+				"X-Service-Token": `{{ secret "env:MIRROR_SERVICE_TOKEN" }}`,
+				"X-Copy-Of":       `{{ .Var "request_id" }} {{ .Method }} {{ .Path }}`,
+			},
+			ForwardQueryParams: config.QueryParamFilter{Deny: []string{"api_key"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := mbr.Lookup("orders-v1")
+
+	var primary seen
+	h := m.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primary = seen{r.Header.Clone(), r.URL.RawQuery}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1,"note":"none"}`))
+	}))
+	r := primaryRequest("GET", "/orders?id=1&api_key=k", "")
+	r.Header.Set("Authorization", "Bearer client-token")
+	r.Header.Set("Cookie", "session=abc")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	// The primary request is untouched, during and after mirroring.
+	for _, got := range []seen{primary, {r.Header, r.URL.RawQuery}} {
+		if got.header.Get("Authorization") != "Bearer client-token" || got.header.Get("Cookie") != "session=abc" {
+			t.Errorf("primary lost its credentials: %v", got.header)
+		}
+		if got.header.Get("X-Service-Token") != "" || got.header.Get("X-Copy-Of") != "" || got.header.Get("X-Mirrored-From") != "" {
+			t.Errorf("copy headers leaked into the primary: %v", got.header)
+		}
+		if got.query != "id=1&api_key=k" {
+			t.Errorf("primary query changed to %q", got.query)
+		}
+	}
+
+	select {
+	case got := <-seenCh:
+		if got.header.Get("Authorization") != "" || got.header.Get("Cookie") != "" {
+			t.Errorf("expected client credentials stripped from the copy, got %v", got.header)
+		}
+		if got.header.Get("X-Service-Token") != secret || got.header.Get("X-Copy-Of") != "req-1 GET /orders" {
+			t.Errorf("expected set_headers on the copy, got %v", got.header)
+		}
+		if got.query != "id=1" {
+			t.Errorf("expected api_key dropped from the copy, got %q", got.query)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("mirror backend not called")
+	}
+
+	var snap *MismatchSnapshot
+	deadline := time.Now().Add(2 * time.Second)
+	for snap = mbr.GetMismatchSnapshot("orders-v1"); len(snap.Entries) == 0; snap = mbr.GetMismatchSnapshot("orders-v1") {
+		if time.Now().After(deadline) {
+			t.Fatal("no mismatch recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	data, _ := json.Marshal(snap)
+	if strings.Contains(string(data), secret) {
+		t.Errorf("injected secret appears in the diff: %s", data)
+	}
+	if !strings.Contains(string(data), `"mirror_value":"[REDACTED]"`) || !strings.Contains(string(data), "token [REDACTED]") {
+		t.Errorf("expected the echoed secret redacted in header and body diffs: %s", data)
+	}
+}
@@ -0,0 +1,78 @@
+package mirror
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxRecordSize bounds the length prefix accepted when reading records, so a
+// corrupt file cannot make the reader allocate arbitrarily.
+const maxRecordSize = 64 << 20
+
+// Record is a mirrored request as written by a Sink. A sink file is a
+// sequence of records, each a 4-byte big-endian length followed by the
+// record as JSON, optionally wrapped in one gzip stream.
+type Record struct {
+	Timestamp     time.Time   `json:"ts"`
+	Route         string      `json:"route"`
+	Method        string      `json:"method"`
+	Host          string      `json:"host"`
+	Path          string      `json:"path"`
+	Query         string      `json:"query,omitempty"`
+	Headers       http.Header `json:"headers,omitempty"`
+	Body          []byte      `json:"body,omitempty"`           // base64 in JSON
+	BodyTruncated bool        `json:"body_truncated,omitempty"` // body cut at max_body_size
+	BodyDropped   bool        `json:"body_dropped,omitempty"`   // body omitted because it could not be redacted
+}
+
+// appendFrame appends a length-prefixed record to buf.
+func appendFrame(buf, data []byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(data)))
+	return append(buf, data...)
+}
+
+// ReadRecords reads the records of a sink file, compressed or not, and calls
+// fn for each. It stops at the first error fn returns.
+func ReadRecords(r io.Reader, fn func(*Record) error) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	var prefix [4]byte
+	for {
+		if _, err := io.ReadFull(br, prefix[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("reading record length: %w", err)
+		}
+		n := binary.BigEndian.Uint32(prefix[:])
+		if n > maxRecordSize {
+			return fmt.Errorf("record length %d exceeds %d bytes", n, maxRecordSize)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return fmt.Errorf("reading record: %w", err)
+		}
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("decoding record: %w", err)
+		}
+		if err := fn(&rec); err != nil {
+			return err
+		}
+	}
+}
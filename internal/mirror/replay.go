@@ -0,0 +1,139 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayOptions controls Replay.
+type ReplayOptions struct {
+	Target      string       // base URL the recorded requests are sent to
+	Rate        float64      // requests per second; 0 sends as fast as Concurrency allows
+	Concurrency int          // requests in flight at most (default 16)
+	Client      *http.Client // default: 10s timeout
+}
+
+// ReplayResult summarizes a replay.
+type ReplayResult struct {
+	Sent   int64            `json:"sent"`
+	Errors int64            `json:"errors"` // requests that got no response
+	Status map[string]int64 `json:"status"` // responses by status class ("2xx", ...)
+}
+
+// Replay sends the records of the given sink files to opts.Target, in file
+// order, paced at opts.Rate. Redacted header values are not sent.
+func Replay(ctx context.Context, files []string, opts ReplayOptions) (ReplayResult, error) {
+	result := ReplayResult{Status: make(map[string]int64)}
+	target, err := url.Parse(opts.Target)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return result, fmt.Errorf("target must be an http(s) URL, got %q", opts.Target)
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 16
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	var tick <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, opts.Concurrency)
+	)
+	send := func(rec *Record) error {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			status, err := replayOne(ctx, opts.Client, target, rec)
+			mu.Lock()
+			defer mu.Unlock()
+			result.Sent++
+			if err != nil {
+				result.Errors++
+				return
+			}
+			result.Status[fmt.Sprintf("%dxx", status/100)]++
+		}()
+		return nil
+	}
+
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			wg.Wait()
+			return result, err
+		}
+		err = ReadRecords(f, send)
+		f.Close()
+		if err != nil {
+			wg.Wait()
+			return result, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	wg.Wait()
+	return result, nil
+}
+
+// replayOne sends one record and returns the response status.
+func replayOne(ctx context.Context, client *http.Client, target *url.URL, rec *Record) (int, error) {
+	u := *target
+	u.Path = strings.TrimSuffix(target.Path, "/") + rec.Path
+	u.RawQuery = rec.Query
+
+	var body io.Reader
+	if len(rec.Body) > 0 {
+		body = bytes.NewReader(rec.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, rec.Method, u.String(), body)
+	if err != nil {
+		return 0, err
+	}
+	for k, vv := range rec.Headers {
+		for _, v := range vv {
+			if v != redacted {
+				req.Header.Add(k, v)
+			}
+		}
+	}
+	req.Header.Del("Content-Length")
+	if rec.Host != "" {
+		req.Host = rec.Host
+	}
+	req.Header.Set("X-Replayed-From", rec.Route)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,197 @@
+package mirror
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// redacted replaces the values of redacted headers and fields.
+const redacted = "[REDACTED]"
+
+// defaultRedactHeaders are always redacted by a sink.
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-API-Key"}
+
+// sanitizer turns a request into a Record with credentials and configured
+// fields redacted. It runs on the request path, so nothing unredacted is
+// ever queued or written.
+type sanitizer struct {
+	headers map[string]bool // canonical header names
+	fields  map[string]bool
+	maxBody int
+}
+
+func newSanitizer(redactHeaders, redactFields []string, maxBody int) *sanitizer {
+	s := &sanitizer{
+		headers: make(map[string]bool, len(defaultRedactHeaders)+len(redactHeaders)),
+		fields:  make(map[string]bool, len(redactFields)),
+		maxBody: maxBody,
+	}
+	for _, h := range defaultRedactHeaders {
+		s.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, h := range redactHeaders {
+		s.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, f := range redactFields {
+		s.fields[f] = true
+	}
+	return s
+}
+
+// record builds the sanitized record of r with the given buffered body.
+func (s *sanitizer) record(routeID string, r *http.Request, body []byte, now time.Time) *Record {
+	rec := &Record{
+		Timestamp: now.UTC(),
+		Route:     routeID,
+		Method:    r.Method,
+		Host:      r.Host,
+		Path:      r.URL.Path,
+		Query:     s.redactQuery(r.URL.RawQuery),
+		Headers:   make(http.Header, len(r.Header)),
+	}
+	for k, vv := range r.Header {
+		if s.headers[k] {
+			rec.Headers[k] = []string{redacted}
+			continue
+		}
+		rec.Headers[k] = append([]string(nil), vv...)
+	}
+
+	if len(body) > s.maxBody {
+		body = body[:s.maxBody]
+		rec.BodyTruncated = true
+	}
+	if len(body) > 0 {
+		rec.Body, rec.BodyDropped = s.redactBody(r.Header.Get("Content-Type"), body, rec.BodyTruncated)
+	}
+	return rec
+}
+
+// redactQuery redacts configured fields among the query parameters.
+func (s *sanitizer) redactQuery(raw string) string {
+	if raw == "" || len(s.fields) == 0 {
+		return raw
+	}
+	q, err := url.ParseQuery(raw)
+	if err != nil {
+		// Parameters that do not parse cannot be checked.
+		return ""
+	}
+	if !s.redactValues(q) {
+		return raw
+	}
+	return q.Encode()
+}
+
+// redactBody redacts configured fields in JSON and form bodies and reports
+// whether the body had to be dropped because it could not be parsed (for
+// example, because it was truncated). Other content types are kept as is.
+func (s *sanitizer) redactBody(contentType string, body []byte, truncated bool) ([]byte, bool) {
+	if len(s.fields) == 0 {
+		return body, false
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if truncated {
+			return nil, true
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, true
+		}
+		if !s.redactJSON(v) {
+			return body, false
+		}
+		out, err := json.Marshal(v)
+		if err != nil {
+			return nil, true
+		}
+		return out, false
+	case mediaType == "application/x-www-form-urlencoded":
+		if truncated {
+			return nil, true
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, true
+		}
+		if !s.redactValues(form) {
+			return body, false
+		}
+		return []byte(form.Encode()), false
+	}
+	return body, false
+}
+
+// redactJSON replaces configured fields at any depth and reports whether any was found.
+func (s *sanitizer) redactJSON(v any) bool {
+	changed := false
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if s.fields[k] {
+				t[k] = redacted
+				changed = true
+				continue
+			}
+			if s.redactJSON(child) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, child := range t {
+			if s.redactJSON(child) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// redactValues replaces configured fields in v and reports whether any was found.
+func (s *sanitizer) redactValues(v url.Values) bool {
+	changed := false
+	for k, vv := range v {
+		if !s.fields[k] {
+			continue
+		}
+		for i := range vv {
+			vv[i] = redacted
+		}
+		changed = true
+	}
+	return changed
+}
+
+// redactSecrets returns a copy of resp with every occurrence of secrets in
+// its headers and captured body replaced. The body hash is kept, so the
+// comparison still sees the body as received.
+func redactSecrets(resp *PrimaryDiffResponse, secrets []string) *PrimaryDiffResponse {
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, v := range secrets {
+		pairs = append(pairs, v, redacted)
+	}
+	rep := strings.NewReplacer(pairs...)
+
+	out := *resp
+	out.Headers = make(http.Header, len(resp.Headers))
+	for k, vv := range resp.Headers {
+		values := make([]string, len(vv))
+		for i, v := range vv {
+			values[i] = rep.Replace(v)
+		}
+		out.Headers[k] = values
+	}
+	if len(resp.Body) > 0 {
+		out.Body = []byte(rep.Replace(string(resp.Body)))
+	}
+	return &out
+}
@@ -0,0 +1,124 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/variables"
+	"go.uber.org/zap"
+)
+
+// shadowTimeout bounds the shadow route's handling of a copy, like the
+// client timeout of backend mirrors.
+const shadowTimeout = 5 * time.Second
+
+// shadowRequest copies r for the shadow route, with cp's header and query.
+// The copy has its own variable context so it can outlive r, marked so the
+// shadow route's side-effectful features can skip it and its mirror never
+// fires.
+func (m *Mirror) shadowRequest(r *http.Request, body []byte, cp *mirrorCopy) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+	req := r.Clone(ctx)
+	req.Header = cp.header
+	if req.URL.RawQuery != cp.rawQuery {
+		req.URL.RawQuery = cp.rawQuery
+		req.RequestURI = req.URL.RequestURI()
+	}
+	req.Body = http.NoBody
+	req.ContentLength = 0
+	if len(body) > 0 {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+	req.Header.Set("X-Mirrored-From", r.Host)
+
+	orig := variables.GetFromRequest(r)
+	varCtx := variables.AcquireContext(req)
+	varCtx.RequestID = orig.RequestID
+	varCtx.ServerPort = orig.ServerPort
+	varCtx.PathParams = maps.Clone(orig.PathParams)
+	varCtx.ShadowOf = orig.RouteID
+	req = req.WithContext(context.WithValue(ctx, variables.RequestContextKey{}, varCtx))
+	varCtx.Request = req
+	return req, cancel
+}
+
+// sendShadow serves req with the shadow route's handler, discarding the
+// response after comparing it with the primary when compare is enabled.
+func (m *Mirror) sendShadow(req *http.Request, cancel context.CancelFunc, primary *PrimaryResponse, diffPrimary *PrimaryDiffResponse, secrets []string) {
+	defer cancel()
+	varCtx := variables.GetFromRequest(req)
+	defer variables.ReleaseContext(varCtx)
+	defer func() {
+		if p := recover(); p != nil {
+			m.metrics.RecordError()
+			logging.Error("shadow route panicked",
+				zap.String("shadow_route", m.shadowRoute),
+				zap.Any("panic", p),
+			)
+		}
+	}()
+
+	var h http.Handler
+	if m.routeHandler != nil {
+		h = m.routeHandler(m.shadowRoute)
+	}
+	if h == nil {
+		m.metrics.RecordError()
+		return
+	}
+
+	var maxCapture int64
+	if diffPrimary != nil {
+		maxCapture = m.diffConfig.maxBodyCapture
+	}
+	sw := NewDiffCapturingWriter(&discardWriter{header: make(http.Header)}, maxCapture)
+	start := time.Now()
+	h.ServeHTTP(sw, req)
+	latency := time.Since(start)
+
+	switch {
+	case diffPrimary != nil:
+		shadow := &PrimaryDiffResponse{
+			StatusCode: sw.StatusCode(),
+			Headers:    sw.CapturedHeaders(),
+			Body:       sw.CapturedBody(),
+			BodyHash:   sw.BodyHash(),
+			Truncated:  sw.BodyTruncated(),
+		}
+		result, detail := compareCaptured(diffPrimary, shadow, m.diffConfig, secrets)
+		m.recordDetailed(req, "route:"+m.shadowRoute, result, detail, diffPrimary.StatusCode, shadow.StatusCode)
+	case primary != nil && m.compare:
+		result := CompareResult{
+			StatusMatch: primary.StatusCode == sw.StatusCode(),
+			BodyMatch:   primary.BodyHash == sw.BodyHash(),
+		}
+		m.metrics.RecordComparison(result)
+		if m.logMismatch && (!result.StatusMatch || !result.BodyMatch) {
+			logging.Warn("mirror mismatch",
+				zap.String("shadow_route", m.shadowRoute),
+				zap.String("path", req.URL.Path),
+				zap.Bool("status_match", result.StatusMatch),
+				zap.Bool("body_match", result.BodyMatch),
+				zap.Int("primary_status", primary.StatusCode),
+				zap.Int("mirror_status", sw.StatusCode()),
+			)
+		}
+	}
+
+	m.metrics.RecordSuccess(latency)
+}
+
+// discardWriter is the synthetic response writer of shadowed requests.
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardWriter) WriteHeader(int)             {}
@@ -0,0 +1,155 @@
+package mirror
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
+)
+
+// waitMirrored waits for the mirror's copies to be recorded.
+func waitMirrored(t *testing.T, m *Mirror, n int64) MirrorSnapshot {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if snap := m.metrics.Snapshot(); snap.TotalMirrored >= n {
+			return snap
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected %d mirrored requests, got %d", n, m.metrics.Snapshot().TotalMirrored)
+	return MirrorSnapshot{}
+}
+
+// primaryRequest returns a request as the primary route's pipeline sees it.
+func primaryRequest(method, path, body string) *http.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	varCtx := variables.NewContext(r)
+	varCtx.RouteID = "orders-v1"
+	varCtx.RequestID = "req-1"
+	return r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx))
+}
+
+func TestShadowRouteDispatch(t *testing.T) {
+	type seen struct {
+		shadowOf, requestID, body string
+		nested                    bool
+	}
+	seenCh := make(chan seen, 1)
+
+	mbr := NewMirrorByRoute()
+	var shadowMirror *Mirror
+	mbr.SetRouteHandlers(func(routeID string) http.Handler {
+		if routeID != "orders-v2" {
+			return nil
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			varCtx := variables.GetFromRequest(r)
+			body, _ := io.ReadAll(r.Body)
+			seenCh <- seen{varCtx.ShadowOf, varCtx.RequestID, string(body), shadowMirror.ShouldMirror(r)}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":2}`))
+		})
+	})
+	if err := mbr.AddRoute("orders-v1", config.MirrorConfig{
+		Enabled:     true,
+		ShadowRoute: "orders-v2",
+		Compare:     config.MirrorCompareConfig{Enabled: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// The shadow route mirrors too; its copies must not fan out again.
+	mbr.AddRoute("orders-v2", config.MirrorConfig{
+		Enabled:     true,
+		ShadowRoute: "orders-v1",
+	})
+	shadowMirror = mbr.Lookup("orders-v2")
+
+	primary := mbr.Lookup("orders-v1")
+	handler := primary.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, primaryRequest("POST", "/orders", `{"item":"book"}`))
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"id":1}` {
+		t.Fatalf("expected the primary response, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case s := <-seenCh:
+		if s.shadowOf != "orders-v1" || s.requestID != "req-1" {
+			t.Errorf("expected the copy marked as shadowed from orders-v1 with the request ID, got %+v", s)
+		}
+		if s.body != `{"item":"book"}` {
+			t.Errorf("expected the request body on the copy, got %q", s.body)
+		}
+		if s.nested {
+			t.Error("expected a shadowed request to never be mirrored again")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("shadow route was not called")
+	}
+
+	snap := waitMirrored(t, primary, 1)
+	if snap.TotalCompared != 1 || snap.TotalMismatches != 1 {
+		t.Errorf("expected one compared mismatch, got %+v", snap)
+	}
+	if got := mbr.Stats()["orders-v1"].ShadowRoute; got != "orders-v2" {
+		t.Errorf("expected stats keyed by shadow route, got %q", got)
+	}
+}
+
+func TestShadowRouteDetailedDiff(t *testing.T) {
+	mbr := NewMirrorByRoute()
+	mbr.SetRouteHandlers(func(string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id":1,"total":12}`))
+		})
+	})
+	mbr.AddRoute("orders-v1", config.MirrorConfig{
+		Enabled:     true,
+		ShadowRoute: "orders-v2",
+		Compare:     config.MirrorCompareConfig{Enabled: true, DetailedDiff: true},
+	})
+	m := mbr.Lookup("orders-v1")
+
+	handler := m.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":1,"total":10}`))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), primaryRequest("GET", "/orders/1", ""))
+
+	snap := waitMirrored(t, m, 1)
+	if snap.BodyMismatches != 1 || snap.StatusMismatches != 0 || snap.HeaderMismatches != 0 {
+		t.Errorf("expected only a body mismatch, got %+v", snap)
+	}
+	mismatches := mbr.GetMismatchSnapshot("orders-v1")
+	if mismatches == nil || len(mismatches.Entries) != 1 {
+		t.Fatalf("expected one stored mismatch, got %+v", mismatches)
+	}
+	if got := mismatches.Entries[0].Backend; got != "route:orders-v2" {
+		t.Errorf("expected the mismatch attributed to the shadow route, got %q", got)
+	}
+}
+
+func TestShadowRouteMissingHandler(t *testing.T) {
+	mbr := NewMirrorByRoute()
+	mbr.SetRouteHandlers(func(string) http.Handler { return nil })
+	mbr.AddRoute("orders-v1", config.MirrorConfig{Enabled: true, ShadowRoute: "orders-v2"})
+	m := mbr.Lookup("orders-v1")
+
+	m.SendAsync(primaryRequest("GET", "/orders", ""), nil, nil)
+	if snap := waitMirrored(t, m, 1); snap.TotalErrors != 1 {
+		t.Errorf("expected an error for a missing shadow route, got %+v", snap)
+	}
+}
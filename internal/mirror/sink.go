@@ -0,0 +1,340 @@
+package mirror
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+// partSuffix marks a file the sink is still writing. It is renamed to its
+// final name when rotated, so readers only pick up complete files.
+const partSuffix = ".part"
+
+// fileTimeLayout sorts lexically in time order.
+const fileTimeLayout = "20060102T150405.000Z"
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// Sink records mirrored requests to rotating local files. Records are
+// sanitized on the request path, queued, and written in batches by a
+// background goroutine; when the queue is full they are dropped and counted.
+type Sink struct {
+	cfg      config.MirrorSinkConfig
+	routeID  string
+	prefix   string // file name prefix: the route ID made safe for file names
+	ext      string
+	compress bool
+	san      *sanitizer
+	queue    chan *Record
+	now      func() time.Time
+	fileRe   *regexp.Regexp // completed files of this route
+
+	// Writer state, owned by the write loop.
+	file   *os.File
+	gz     *gzip.Writer
+	w      *bufio.Writer
+	path   string // final path of the open file
+	size   int64  // record bytes written to the open file
+	opened time.Time
+
+	recorded atomic.Int64
+	dropped  atomic.Int64
+	written  atomic.Int64
+	files    atomic.Int64
+	errors   atomic.Int64
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// SinkStats is a point-in-time summary of a sink.
+type SinkStats struct {
+	Directory string `json:"directory"`
+	Recorded  int64  `json:"recorded"`
+	Dropped   int64  `json:"dropped"`
+	Written   int64  `json:"written"`
+	Files     int64  `json:"files"`
+	Errors    int64  `json:"errors"`
+	QueueLen  int    `json:"queue_len"`
+}
+
+// NewSink creates the sink directory and starts the background writer.
+func NewSink(routeID string, cfg config.MirrorSinkConfig) (*Sink, error) {
+	s, err := newSink(routeID, cfg)
+	if err != nil {
+		return nil, err
+	}
+	go s.writeLoop()
+	return s, nil
+}
+
+// newSink creates a sink without starting its writer.
+func newSink(routeID string, cfg config.MirrorSinkConfig) (*Sink, error) {
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = 64 << 20
+	}
+	if cfg.MaxFileAge <= 0 {
+		cfg.MaxFileAge = time.Hour
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 65536
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 1000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if err := os.MkdirAll(cfg.Directory, 0o755); err != nil {
+		return nil, fmt.Errorf("mirror sink: %w", err)
+	}
+
+	s := &Sink{
+		cfg:      cfg,
+		routeID:  routeID,
+		prefix:   unsafeFileChars.ReplaceAllString(routeID, "_") + "-",
+		ext:      ".rec",
+		compress: cfg.Compress == nil || *cfg.Compress,
+		san:      newSanitizer(cfg.RedactHeaders, cfg.RedactFields, cfg.MaxBodySize),
+		queue:    make(chan *Record, cfg.BufferSize),
+		now:      time.Now,
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	if s.compress {
+		s.ext += ".gz"
+	}
+	s.fileRe = regexp.MustCompile(`^` + regexp.QuoteMeta(s.prefix) + `\d{8}T\d{6}\.\d{3}Z-\d{6}` + regexp.QuoteMeta(s.ext) + `$`)
+	return s, nil
+}
+
+// Record sanitizes the request and queues it for writing. It never blocks:
+// when the writer cannot keep up the record is dropped and counted.
+func (s *Sink) Record(r *http.Request, body []byte) {
+	rec := s.san.record(s.routeID, r, body, s.now())
+	select {
+	case s.queue <- rec:
+		s.recorded.Add(1)
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Close writes the queued records, completes the open file and stops the writer.
+func (s *Sink) Close() {
+	close(s.stopCh)
+	<-s.doneCh
+}
+
+// Stats returns a snapshot of the sink counters.
+func (s *Sink) Stats() SinkStats {
+	return SinkStats{
+		Directory: s.cfg.Directory,
+		Recorded:  s.recorded.Load(),
+		Dropped:   s.dropped.Load(),
+		Written:   s.written.Load(),
+		Files:     s.files.Load(),
+		Errors:    s.errors.Load(),
+		QueueLen:  len(s.queue),
+	}
+}
+
+// writeLoop batches queued records and writes them, rotating files by size
+// and age.
+func (s *Sink) writeLoop() {
+	defer close(s.doneCh)
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Record, 0, s.cfg.BatchSize)
+	flush := func() {
+		s.writeBatch(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case rec := <-s.queue:
+			batch = append(batch, rec)
+			if len(batch) >= s.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.stopCh:
+			for {
+				select {
+				case rec := <-s.queue:
+					batch = append(batch, rec)
+					if len(batch) >= s.cfg.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					s.closeFile()
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeBatch writes records to the open file, rotating before a record that
+// would take the file past max_file_size, so records never span files. An
+// open file older than max_file_age is completed even without new records.
+func (s *Sink) writeBatch(batch []*Record) {
+	if s.file != nil && s.now().Sub(s.opened) >= s.cfg.MaxFileAge {
+		s.closeFile()
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	var frame []byte
+	for _, rec := range batch {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			s.errors.Add(1)
+			continue
+		}
+		frame = appendFrame(frame[:0], data)
+		if s.file != nil && s.size+int64(len(frame)) > s.cfg.MaxFileSize {
+			s.closeFile()
+		}
+		if s.file == nil {
+			if err := s.openFile(); err != nil {
+				s.errors.Add(1)
+				logging.Warn("mirror sink: cannot open file",
+					zap.String("route", s.routeID), zap.Error(err))
+				return
+			}
+		}
+		if _, err := s.w.Write(frame); err != nil {
+			s.fail(err)
+			return
+		}
+		s.size += int64(len(frame))
+		s.written.Add(1)
+	}
+
+	if s.file == nil {
+		return
+	}
+	err := s.w.Flush()
+	if err == nil && s.gz != nil {
+		err = s.gz.Flush()
+	}
+	if err != nil {
+		s.fail(err)
+	}
+}
+
+// openFile starts a new file named after the route, the time and a sequence
+// number that avoids collisions with files of earlier sinks.
+func (s *Sink) openFile() error {
+	now := s.now()
+	stamp := now.UTC().Format(fileTimeLayout)
+	for seq := 0; ; seq++ {
+		path := filepath.Join(s.cfg.Directory, fmt.Sprintf("%s%s-%06d%s", s.prefix, stamp, seq, s.ext))
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		f, err := os.OpenFile(path+partSuffix, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		s.file, s.path, s.size, s.opened = f, path, 0, now
+		var w io.Writer = f
+		if s.compress {
+			s.gz = gzip.NewWriter(f)
+			w = s.gz
+		}
+		s.w = bufio.NewWriter(w)
+		return nil
+	}
+}
+
+// closeFile completes the open file, renames it to its final name and
+// deletes the oldest files beyond max_files.
+func (s *Sink) closeFile() {
+	if s.file == nil {
+		return
+	}
+	err := s.w.Flush()
+	if s.gz != nil {
+		if cerr := s.gz.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(s.path+partSuffix, s.path)
+	}
+	s.file, s.gz, s.w = nil, nil, nil
+	if err != nil {
+		s.errors.Add(1)
+		logging.Warn("mirror sink: cannot complete file",
+			zap.String("route", s.routeID), zap.String("file", s.path), zap.Error(err))
+		return
+	}
+	s.files.Add(1)
+	s.prune()
+}
+
+// fail abandons the open file after a write error. Its records up to the
+// last successful flush remain readable.
+func (s *Sink) fail(err error) {
+	s.errors.Add(1)
+	logging.Warn("mirror sink: write failed",
+		zap.String("route", s.routeID), zap.String("file", s.path), zap.Error(err))
+	if s.gz != nil {
+		s.gz.Close()
+	}
+	s.file.Close()
+	s.file, s.gz, s.w = nil, nil, nil
+}
+
+// prune deletes this route's oldest completed files beyond max_files.
+func (s *Sink) prune() {
+	if s.cfg.MaxFiles <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(s.cfg.Directory)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		if s.fileRe.MatchString(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for len(names) > s.cfg.MaxFiles {
+		os.Remove(filepath.Join(s.cfg.Directory, names[0]))
+		names = names[1:]
+	}
+}
@@ -0,0 +1,286 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+// readSinkFiles returns the completed files in dir and the records in each.
+func readSinkFiles(t *testing.T, dir string) ([]string, [][]*Record) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), partSuffix) {
+			t.Fatalf("unexpected incomplete file %s", e.Name())
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	var records [][]*Record
+	for _, name := range names {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var recs []*Record
+		if err := ReadRecords(f, func(r *Record) error { recs = append(recs, r); return nil }); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		f.Close()
+		records = append(records, recs)
+	}
+	return names, records
+}
+
+func TestSanitizerRedaction(t *testing.T) {
+	san := newSanitizer([]string{"x-session"}, []string{"password", "token"}, 1024)
+
+	r := httptest.NewRequest("POST", "/login?user=bob&token=abc", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Cookie", "sid=1")
+	r.Header.Set("X-Session", "s3cr3t")
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "text/html")
+	body := `{"user":"bob","password":"hunter2","profile":{"token":"t","amount":12345678901234567890},"items":[{"password":"p"}]}`
+
+	rec := san.record("login", r, []byte(body), time.Unix(1700000000, 0))
+
+	for _, h := range []string{"Authorization", "Cookie", "X-Session"} {
+		if got := rec.Headers.Get(h); got != redacted {
+			t.Errorf("expected %s redacted, got %q", h, got)
+		}
+	}
+	if got := rec.Headers.Get("Accept"); got != "text/html" {
+		t.Errorf("expected Accept kept, got %q", got)
+	}
+	if strings.Contains(rec.Query, "abc") || !strings.Contains(rec.Query, "user=bob") {
+		t.Errorf("expected token redacted from the query, got %q", rec.Query)
+	}
+	for _, secret := range []string{"hunter2", `"t"`, `"p"`} {
+		if strings.Contains(string(rec.Body), secret) {
+			t.Errorf("expected %s redacted from the body, got %s", secret, rec.Body)
+		}
+	}
+	if !strings.Contains(string(rec.Body), "12345678901234567890") {
+		t.Errorf("expected numbers kept exactly, got %s", rec.Body)
+	}
+	// The live request is left untouched.
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		t.Error("expected the request headers unchanged")
+	}
+
+	// Form bodies are redacted too.
+	r = httptest.NewRequest("POST", "/login", nil)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = san.record("login", r, []byte("user=bob&password=hunter2"), time.Now())
+	if strings.Contains(string(rec.Body), "hunter2") || !strings.Contains(string(rec.Body), "user=bob") {
+		t.Errorf("expected password redacted from the form, got %s", rec.Body)
+	}
+
+	// A JSON body cut at max_body_size cannot be checked, so it is dropped.
+	small := newSanitizer(nil, []string{"password"}, 10)
+	r = httptest.NewRequest("POST", "/login", nil)
+	r.Header.Set("Content-Type", "application/json")
+	rec = small.record("login", r, []byte(body), time.Now())
+	if rec.Body != nil || !rec.BodyDropped || !rec.BodyTruncated {
+		t.Errorf("expected truncated JSON body dropped, got %+v", rec)
+	}
+
+	// Without redact_fields other bodies are only truncated.
+	r = httptest.NewRequest("POST", "/upload", nil)
+	rec = small.record("upload", r, []byte("0123456789abcdef"), time.Now())
+	if string(rec.Body) != "0123456789" || !rec.BodyTruncated || rec.BodyDropped {
+		t.Errorf("expected body cut at 10 bytes, got %+v", rec)
+	}
+}
+
+func TestSinkRotationBoundaries(t *testing.T) {
+	dir := t.TempDir()
+	compress := false
+	s, err := newSink("orders/v1", config.MirrorSinkConfig{
+		Directory:   dir,
+		MaxFileSize: 600,
+		Compress:    &compress,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var batch []*Record
+	for i := 0; i < 10; i++ {
+		r := httptest.NewRequest("GET", "/orders/"+strings.Repeat("x", 100), nil)
+		batch = append(batch, s.san.record("orders/v1", r, nil, time.Unix(1700000000, 0)))
+	}
+	frame, _ := json.Marshal(batch[0])
+	perFile := 600 / (len(frame) + 4)
+
+	s.writeBatch(batch)
+	s.closeFile()
+
+	names, records := readSinkFiles(t, dir)
+	if len(names) < 2 {
+		t.Fatalf("expected the records to span several files, got %v", names)
+	}
+	total := 0
+	for i, recs := range records {
+		if !strings.HasPrefix(names[i], "orders_v1-") || !strings.HasSuffix(names[i], ".rec") {
+			t.Errorf("unexpected file name %s", names[i])
+		}
+		info, _ := os.Stat(filepath.Join(dir, names[i]))
+		if info.Size() > 600 {
+			t.Errorf("%s: %d bytes exceeds max_file_size", names[i], info.Size())
+		}
+		if i < len(records)-1 && len(recs) != perFile {
+			t.Errorf("%s: expected %d whole records, got %d", names[i], perFile, len(recs))
+		}
+		total += len(recs)
+	}
+	if total != 10 {
+		t.Fatalf("expected all 10 records across files, got %d", total)
+	}
+	if got := s.Stats().Files; got != int64(len(names)) {
+		t.Errorf("expected %d files counted, got %d", len(names), got)
+	}
+}
+
+func TestSinkMaxFilesAndAge(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newSink("api", config.MirrorSinkConfig{
+		Directory:  dir,
+		MaxFileAge: time.Minute,
+		MaxFiles:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+	// Another route's files in the same directory are left alone.
+	other := filepath.Join(dir, "api-2-20231114T221320.000Z-000000.rec.gz")
+	os.WriteFile(other, nil, 0o644)
+
+	for i := 0; i < 4; i++ {
+		r := httptest.NewRequest("GET", "/", nil)
+		s.writeBatch([]*Record{s.san.record("api", r, nil, now)})
+		now = now.Add(time.Minute)
+		// An idle file is completed once it reaches max_file_age.
+		s.writeBatch(nil)
+		if s.file != nil {
+			t.Fatal("expected the aged file to be completed")
+		}
+	}
+
+	names, records := readSinkFiles(t, dir)
+	if len(names) != 3 || names[0] != filepath.Base(other) {
+		t.Fatalf("expected two newest files plus the other route's, got %v", names)
+	}
+	for _, recs := range records[1:] {
+		if len(recs) != 1 {
+			t.Errorf("expected one record per file, got %d", len(recs))
+		}
+	}
+	if !strings.HasSuffix(names[2], ".rec.gz") {
+		t.Errorf("expected compressed files by default, got %s", names[2])
+	}
+}
+
+func TestSinkDropsWhenFull(t *testing.T) {
+	// The writer is not started, so the queue fills.
+	s, err := newSink("api", config.MirrorSinkConfig{Directory: t.TempDir(), BufferSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		s.Record(httptest.NewRequest("GET", "/", nil), nil)
+	}
+	if st := s.Stats(); st.Recorded != 2 || st.Dropped != 3 || st.QueueLen != 2 {
+		t.Fatalf("expected 2 queued and 3 dropped, got %+v", st)
+	}
+}
+
+func TestSinkMiddlewareAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	mbr := NewMirrorByRoute()
+	if err := mbr.AddRoute("orders", config.MirrorConfig{
+		Enabled: true,
+		Sink: config.MirrorSinkConfig{
+			Enabled:      true,
+			Directory:    dir,
+			RedactFields: []string{"card"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	m := mbr.Lookup("orders")
+
+	var served int
+	h := m.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), "4111") {
+			t.Errorf("expected the primary to get the unredacted body, got %s", body)
+		}
+		served++
+	}))
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest("POST", "/orders?page=1", strings.NewReader(`{"card":"4111","qty":1}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer secret")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	mbr.CloseAll()
+	if served != 3 {
+		t.Fatalf("expected 3 primary requests, got %d", served)
+	}
+	if st := mbr.Stats()["orders"].Sink; st == nil || st.Written != 3 || st.Files != 1 {
+		t.Fatalf("expected 3 records written to one file, got %+v", st)
+	}
+
+	type received struct {
+		method, path, auth, body, from string
+	}
+	var mu sync.Mutex
+	var got []received
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, received{r.Method, r.URL.RequestURI(), r.Header.Get("Authorization"), string(body), r.Header.Get("X-Replayed-From")})
+		mu.Unlock()
+	}))
+	defer target.Close()
+
+	names, _ := readSinkFiles(t, dir)
+	res, err := Replay(context.Background(), []string{filepath.Join(dir, names[0])}, ReplayOptions{Target: target.URL, Rate: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 3 || res.Status["2xx"] != 3 || res.Errors != 0 {
+		t.Fatalf("unexpected replay result %+v", res)
+	}
+	for _, r := range got {
+		if r.method != "POST" || r.path != "/orders?page=1" || r.from != "orders" {
+			t.Errorf("unexpected replayed request %+v", r)
+		}
+		if r.auth != "" {
+			t.Errorf("expected redacted headers not sent, got %q", r.auth)
+		}
+		if strings.Contains(r.body, "4111") || !strings.Contains(r.body, `"qty":1`) {
+			t.Errorf("expected the redacted body, got %s", r.body)
+		}
+	}
+}
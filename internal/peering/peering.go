@@ -0,0 +1,415 @@
+// Package peering forwards requests to peer gateways, typically in other
+// regions, when a route has no healthy backends of its own. Forwarded
+// requests carry a hop header signed with a secret shared by the peers; a
+// gateway never forwards a request it received from a peer, so a request
+// crosses at most one gateway boundary.
+package peering
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/circuitbreaker"
+	"github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/variables"
+	"go.uber.org/zap"
+)
+
+// Headers set on requests forwarded to a peer.
+const (
+	HeaderForwardedBy = "X-Gateway-Forwarded-By"
+	HeaderHop         = "X-Gateway-Peer-Hop"
+	HeaderSignature   = "X-Gateway-Peer-Signature"
+)
+
+// Outcomes of peer failover, used as metric labels.
+const (
+	OutcomeServed       = "served"
+	OutcomeError        = "error"
+	OutcomeCircuitOpen  = "circuit_open"
+	OutcomeLoopRejected = "loop_rejected"
+)
+
+const (
+	// maxHops is the number of gateway boundaries a request may cross.
+	maxHops = 1
+
+	defaultTimeout = 5 * time.Second
+)
+
+// hopHeaders are the hop-by-hop headers not forwarded in either direction.
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// Peer is a peer gateway with its own client and circuit breaker.
+type Peer struct {
+	name    string
+	url     string // base URL without a trailing slash
+	timeout time.Duration
+	client  *http.Client
+	breaker *circuitbreaker.Breaker
+
+	served        atomic.Int64
+	errors        atomic.Int64
+	loopsRejected atomic.Int64
+}
+
+// Failover forwards requests of routes with peer_failover to peer gateways.
+type Failover struct {
+	gatewayID string
+	secret    []byte
+	peers     []*Peer
+
+	mu     sync.Mutex
+	routes map[string][]string // route ID -> peer names tried in order
+
+	loopsRejected atomic.Int64
+}
+
+// New creates a Failover from the global peer_failover config.
+func New(cfg config.PeerFailoverConfig) (*Failover, error) {
+	id := cfg.GatewayID
+	if id == "" {
+		id, _ = os.Hostname()
+	}
+	f := &Failover{
+		gatewayID: id,
+		secret:    []byte(cfg.Secret),
+		routes:    make(map[string][]string),
+	}
+	for _, pc := range cfg.Peers {
+		p, err := newPeer(pc, cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("peer %s: %w", pc.Name, err)
+		}
+		f.peers = append(f.peers, p)
+	}
+	return f, nil
+}
+
+func newPeer(cfg config.PeerConfig, defaultTO time.Duration) (*Peer, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTO
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := buildTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &Peer{
+		name:    cfg.Name,
+		url:     strings.TrimSuffix(cfg.URL, "/"),
+		timeout: timeout,
+		client: &http.Client{
+			Transport: transport,
+			// Redirects are relayed to the client, as the proxy does.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		breaker: circuitbreaker.NewBreaker(cfg.CircuitBreaker, func(from, to string) {
+			logging.Info("Peer gateway circuit breaker state changed",
+				zap.String("peer", cfg.Name), zap.String("from", from), zap.String("to", to))
+		}),
+	}, nil
+}
+
+func buildTLSConfig(cfg config.PeerTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: cfg.ServerName}
+
+	if cfg.CAFile != "" {
+		caCert, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to parse CA certificate")
+		}
+		tlsConfig.RootCAs = certPool
+	}
+
+	if cfg.CertFile != "" && cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// GatewayID returns the name this gateway sends in X-Gateway-Forwarded-By.
+func (f *Failover) GatewayID() string {
+	return f.gatewayID
+}
+
+// Middleware returns a middleware that wraps a route's proxy handler. While
+// healthy reports that the route has a healthy backend, requests go to next.
+// Otherwise they are forwarded to the route's peers in order, skipping peers
+// whose circuit breaker is open, and fall through to next (which answers 503)
+// when no peer serves them. A request that already came from a peer is
+// rejected with 508 Loop Detected instead. record is called with each
+// outcome (may be nil).
+func (f *Failover) Middleware(routeID string, cfg config.RoutePeerFailoverConfig, healthy func() bool, record func(peer, outcome string)) middleware.Middleware {
+	peers := f.peers
+	if len(cfg.Peers) > 0 {
+		peers = nil
+		for _, name := range cfg.Peers {
+			for _, p := range f.peers {
+				if p.name == name {
+					peers = append(peers, p)
+				}
+			}
+		}
+	}
+	names := make([]string, len(peers))
+	for i, p := range peers {
+		names[i] = p.name
+	}
+	f.mu.Lock()
+	f.routes[routeID] = names
+	f.mu.Unlock()
+
+	if record == nil {
+		record = func(string, string) {}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			from, forwarded := f.fromPeer(r)
+			if healthy() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if forwarded {
+				f.loopsRejected.Add(1)
+				record(from, OutcomeLoopRejected)
+				logging.Warn("Rejected peer failover loop",
+					zap.String("route", routeID), zap.String("forwarded_by", from))
+				errors.ErrLoopDetected.WithDetails(
+					fmt.Sprintf("peer failover loop: request was forwarded by gateway %q and this gateway has no healthy backends", from),
+				).WriteJSON(w)
+				return
+			}
+			if !f.forward(w, r, routeID, peers, record) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// fromPeer reports the gateway that forwarded r when r carries a validly
+// signed hop header at the hop limit. Peer headers that fail verification
+// are removed so they reach neither the backend nor another peer.
+func (f *Failover) fromPeer(r *http.Request) (string, bool) {
+	by := r.Header.Get(HeaderForwardedBy)
+	hop := r.Header.Get(HeaderHop)
+	sig := r.Header.Get(HeaderSignature)
+	if by == "" && hop == "" && sig == "" {
+		return "", false
+	}
+	if n, err := strconv.Atoi(hop); err == nil && n >= maxHops && hmac.Equal([]byte(sig), []byte(f.sign(by, hop))) {
+		return by, true
+	}
+	r.Header.Del(HeaderForwardedBy)
+	r.Header.Del(HeaderHop)
+	r.Header.Del(HeaderSignature)
+	return "", false
+}
+
+// sign returns the hex HMAC-SHA256 of the forwarded-by and hop values.
+func (f *Failover) sign(by, hop string) string {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write([]byte(by + "\n" + hop))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// forward tries peers in order and relays the first response a peer serves.
+// It reports whether a response was written. Transport errors, timeouts and
+// 502, 503 and 504 responses count as peer failures; a 508 from a peer that
+// has no healthy backends either moves on without counting against it.
+func (f *Failover) forward(w http.ResponseWriter, r *http.Request, routeID string, peers []*Peer, record func(peer, outcome string)) bool {
+	// The body can only be replayed to a second peer if it was buffered.
+	var body []byte
+	if len(peers) > 1 && r.Body != nil && r.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			errors.ErrBadGateway.WithDetails("Failed to read request body").WriteJSON(w)
+			return true
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	for _, p := range peers {
+		done, err := p.breaker.Allow()
+		if err != nil {
+			record(p.name, OutcomeCircuitOpen)
+			continue
+		}
+		if body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		served, err := f.forwardTo(w, r, p)
+		switch {
+		case r.Context().Err() == context.Canceled:
+			// The client went away; the peer is not at fault.
+			done(nil)
+			variables.GetFromRequest(r).ClientAbort = variables.AbortDuringBackend
+			w.WriteHeader(variables.StatusClientClosedRequest)
+			return true
+		case err != nil:
+			done(err)
+			p.errors.Add(1)
+			record(p.name, OutcomeError)
+			logging.Warn("Peer failover request failed",
+				zap.String("route", routeID), zap.String("peer", p.name), zap.Error(err))
+		case !served:
+			done(nil)
+			p.loopsRejected.Add(1)
+			record(p.name, OutcomeLoopRejected)
+		default:
+			done(nil)
+			p.served.Add(1)
+			record(p.name, OutcomeServed)
+			return true
+		}
+	}
+	if body != nil {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return false
+}
+
+// forwardTo sends r to peer p and relays the response. It reports false
+// without writing anything when the peer rejected the request as a loop.
+func (f *Failover) forwardTo(w http.ResponseWriter, r *http.Request, p *Peer) (bool, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, p.url+r.URL.RequestURI(), r.Body)
+	if err != nil {
+		return false, err
+	}
+	req.ContentLength = r.ContentLength
+	req.Host = r.Host
+	req.Header = r.Header.Clone()
+	for _, h := range hopHeaders {
+		req.Header.Del(h)
+	}
+	if clientIP := variables.ExtractClientIP(r); clientIP != "" {
+		if prior := req.Header.Get("X-Forwarded-For"); prior != "" {
+			clientIP = prior + ", " + clientIP
+		}
+		req.Header.Set("X-Forwarded-For", clientIP)
+	}
+	hop := strconv.Itoa(maxHops)
+	req.Header.Set(HeaderForwardedBy, f.gatewayID)
+	req.Header.Set(HeaderHop, hop)
+	req.Header.Set(HeaderSignature, f.sign(f.gatewayID, hop))
+
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusLoopDetected:
+		return false, nil
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return false, fmt.Errorf("peer responded with status %d", resp.StatusCode)
+	}
+
+	varCtx := variables.GetFromRequest(r)
+	varCtx.UpstreamAddr = p.url
+	varCtx.UpstreamStatus = resp.StatusCode
+	varCtx.UpstreamResponseTime = time.Since(start)
+	varCtx.ServedByPeer = p.name
+
+	for k, vv := range resp.Header {
+		w.Header()[k] = vv
+	}
+	for _, h := range hopHeaders {
+		w.Header().Del(h)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil && r.Context().Err() == context.Canceled {
+		varCtx.ClientAbort = variables.AbortDuringResponse
+	}
+	return true, nil
+}
+
+// Stats is a point-in-time view of peer failover.
+type Stats struct {
+	GatewayID     string              `json:"gateway_id"`
+	Peers         []PeerStats         `json:"peers"`
+	Routes        map[string][]string `json:"routes"`
+	LoopsRejected int64               `json:"loops_rejected"` // forwarded requests refused because this gateway had no healthy backends
+}
+
+// PeerStats is a point-in-time view of one peer.
+type PeerStats struct {
+	Name           string                         `json:"name"`
+	URL            string                         `json:"url"`
+	Timeout        string                         `json:"timeout"`
+	Served         int64                          `json:"served"`
+	Errors         int64                          `json:"errors"`
+	LoopsRejected  int64                          `json:"loops_rejected"` // requests the peer refused because it had no healthy backends either
+	CircuitBreaker circuitbreaker.BreakerSnapshot `json:"circuit_breaker"`
+}
+
+// Stats returns a snapshot of the peers, their counters and the routes
+// failing over to them.
+func (f *Failover) Stats() Stats {
+	s := Stats{
+		GatewayID:     f.gatewayID,
+		Peers:         make([]PeerStats, 0, len(f.peers)),
+		Routes:        make(map[string][]string),
+		LoopsRejected: f.loopsRejected.Load(),
+	}
+	for _, p := range f.peers {
+		s.Peers = append(s.Peers, PeerStats{
+			Name:           p.name,
+			URL:            p.url,
+			Timeout:        p.timeout.String(),
+			Served:         p.served.Load(),
+			Errors:         p.errors.Load(),
+			LoopsRejected:  p.loopsRejected.Load(),
+			CircuitBreaker: p.breaker.Snapshot(),
+		})
+	}
+	f.mu.Lock()
+	for id, names := range f.routes {
+		s.Routes[id] = names
+	}
+	f.mu.Unlock()
+	return s
+}
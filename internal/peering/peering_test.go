@@ -0,0 +1,110 @@
+package peering
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
+)
+
+func serve(t *testing.T, h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	vc := variables.NewContext(r)
+	r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, vc))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestFailover_TriesPeersInOrder(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer slow.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Peer", "healthy")
+		io.WriteString(w, r.Method+" "+string(body))
+	}))
+	defer healthy.Close()
+
+	f, err := New(config.PeerFailoverConfig{
+		GatewayID: "gw-a",
+		Secret:    "s3cret",
+		Peers: []config.PeerConfig{
+			{Name: "slow", URL: slow.URL, Timeout: 50 * time.Millisecond,
+				CircuitBreaker: config.CircuitBreakerConfig{FailureThreshold: 1, Timeout: time.Minute}},
+			{Name: "healthy", URL: healthy.URL + "/"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outcomes []string
+	local := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	h := f.Middleware("api", config.RoutePeerFailoverConfig{Enabled: true}, func() bool { return false },
+		func(peer, outcome string) { outcomes = append(outcomes, peer+":"+outcome) })(local)
+
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		w := serve(t, h, httptest.NewRequest("POST", "/orders", strings.NewReader("payload")))
+		if w.Code != http.StatusOK || w.Body.String() != "POST payload" || w.Header().Get("X-Peer") != "healthy" {
+			t.Fatalf("expected the body replayed to the healthy peer, got %d %q", w.Code, w.Body.String())
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the slow peer to time out quickly, took %v", elapsed)
+		}
+	}
+
+	want := "slow:error,healthy:served,slow:circuit_open,healthy:served"
+	if got := strings.Join(outcomes, ","); got != want {
+		t.Errorf("expected outcomes %s, got %s", want, got)
+	}
+	s := f.Stats()
+	if s.Peers[0].CircuitBreaker.State != "open" || s.Peers[0].Errors != 1 || s.Peers[1].Served != 2 {
+		t.Errorf("unexpected peer stats %+v", s.Peers)
+	}
+}
+
+func TestFailover_HealthyRouteIsNotForwarded(t *testing.T) {
+	f, err := New(config.PeerFailoverConfig{
+		GatewayID: "gw-a",
+		Secret:    "s3cret",
+		Peers:     []config.PeerConfig{{Name: "b", URL: "http://127.0.0.1:1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen http.Header
+	local := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = r.Header })
+	h := f.Middleware("api", config.RoutePeerFailoverConfig{Enabled: true}, func() bool { return true }, nil)(local)
+
+	signed := httptest.NewRequest("GET", "/", nil)
+	signed.Header.Set(HeaderForwardedBy, "gw-b")
+	signed.Header.Set(HeaderHop, "1")
+	signed.Header.Set(HeaderSignature, f.sign("gw-b", "1"))
+	if w := serve(t, h, signed); w.Code != http.StatusOK || seen.Get(HeaderForwardedBy) != "gw-b" {
+		t.Errorf("expected a signed forwarded request to reach the backend with its headers, got %d %v", w.Code, seen)
+	}
+
+	forged := httptest.NewRequest("GET", "/", nil)
+	forged.Header.Set(HeaderForwardedBy, "gw-b")
+	forged.Header.Set(HeaderHop, "1")
+	forged.Header.Set(HeaderSignature, f.sign("gw-c", "1"))
+	if serve(t, h, forged); seen.Get(HeaderForwardedBy) != "" || seen.Get(HeaderSignature) != "" {
+		t.Errorf("expected forged peer headers to be removed, got %v", seen)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -14,9 +16,14 @@ import (
 	"time"
 
 	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/egress"
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/middleware/backendauth"
 	"github.com/wudi/runway/internal/middleware/backendenc"
 	"github.com/wudi/runway/internal/middleware/transform"
+	"github.com/wudi/runway/internal/proxy/copypolicy"
+	"github.com/wudi/runway/internal/proxy"
+	"github.com/wudi/runway/internal/proxy/headerprop"
 	"github.com/wudi/runway/internal/tmplutil"
 	"github.com/wudi/runway/variables"
 )
@@ -33,8 +40,29 @@ type compiledBackend struct {
 	variables  map[string]string
 	encoding   string                          // per-backend encoding (xml, yaml, etc.)
 	transform  *transform.CompiledBodyTransform // per-backend response transform
+	propagate  *headerprop.Rule
+	policy     *copypolicy.Policy         // strip_headers, set_headers, forward_query_params
+	auth       *backendauth.TokenProvider // shared backend_auth provider, or nil
 }
 
+// Options carries route-level settings that apply to an aggregate handler.
+type Options struct {
+	CompletionHeader bool
+	RequestTimeout   time.Duration // route timeout_policy.request; all backend calls share this budget
+	DetailedErrors   bool          // include per-backend timing in errors (error_handling.mode: detailed)
+
+	Secrets     *config.SecretRegistry          // resolves the secret function of set_headers templates
+	BackendAuth *backendauth.BackendAuthByRoute // shared providers referenced by backend_auth.ref
+}
+
+// errBudgetExhausted marks a backend call cut short by the route's request budget.
+var errBudgetExhausted = errors.New("deadline exhausted")
+
+// statusError is a backend call answered with an error status.
+type statusError struct{ code int }
+
+func (e statusError) Error() string { return fmt.Sprintf("HTTP %d", e.code) }
+
 // AggregateHandler fans out requests to multiple backends and merges JSON responses.
 type AggregateHandler struct {
 	backends          []compiledBackend
@@ -43,11 +71,21 @@ type AggregateHandler struct {
 	failStrategy      string // "abort" or "partial"
 	responseTransform *transform.CompiledBodyTransform // post-merge transform
 	completionHeader  bool
-
-	totalRequests atomic.Int64
-	totalErrors   atomic.Int64
-	backendErrors []atomic.Int64
-	backendLatNs  []atomic.Int64
+	requestTimeout    time.Duration
+	detailedErrors    bool
+	maxPropHeaders    int
+	maxPropBytes      int
+
+	totalRequests   atomic.Int64
+	totalErrors     atomic.Int64
+	budgetExhausted atomic.Int64
+	backendErrors   []atomic.Int64
+	backendTimeouts []atomic.Int64
+	backendLatNs    []atomic.Int64
+
+	propagatedHeaders atomic.Int64
+	headerConflicts   atomic.Int64
+	headersDropped    atomic.Int64
 }
 
 // New creates an AggregateHandler from config.
@@ -103,6 +141,7 @@ func New(cfg config.AggregateConfig, transport http.RoundTripper) (*AggregateHan
 			timeout:    bt,
 			variables:  b.Variables,
 			encoding:   b.Encoding,
+			propagate:  headerprop.NewRule(b.PropagateHeaders),
 		}
 
 		// Compile per-backend transform if configured
@@ -118,12 +157,15 @@ func New(cfg config.AggregateConfig, transport http.RoundTripper) (*AggregateHan
 	}
 
 	ah := &AggregateHandler{
-		backends:      backends,
-		transport:     transport,
-		timeout:       timeout,
-		failStrategy:  failStrategy,
-		backendErrors: make([]atomic.Int64, len(backends)),
-		backendLatNs:  make([]atomic.Int64, len(backends)),
+		backends:        backends,
+		transport:       transport,
+		timeout:         timeout,
+		failStrategy:    failStrategy,
+		maxPropHeaders:  cfg.MaxPropagatedHeaders,
+		maxPropBytes:    cfg.MaxPropagatedHeaderBytes,
+		backendErrors:   make([]atomic.Int64, len(backends)),
+		backendTimeouts: make([]atomic.Int64, len(backends)),
+		backendLatNs:    make([]atomic.Int64, len(backends)),
 	}
 
 	// Compile post-merge response transform if configured
@@ -152,11 +194,14 @@ type templateContext struct {
 }
 
 type backendResult struct {
-	index int
-	name  string
-	group string
-	body  []byte
-	err   error
+	index   int
+	name    string
+	group   string
+	body    []byte
+	header  http.Header
+	err     error
+	elapsed time.Duration
+	budget  time.Duration
 }
 
 func (ah *AggregateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -177,6 +222,19 @@ func (ah *AggregateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ctx.RouteID = varCtx.RouteID
 	}
 
+	// Fail fast when the route budget is already spent.
+	deadline, hasDeadline := proxy.BudgetDeadline(r.Context(), time.Now(), ah.requestTimeout)
+	if hasDeadline && !time.Now().Before(deadline) {
+		ah.budgetExhausted.Add(1)
+		ah.totalErrors.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusGatewayTimeout)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "deadline exhausted before aggregate fan-out",
+		})
+		return
+	}
+
 	results := make(chan backendResult, len(ah.backends))
 	var wg sync.WaitGroup
 
@@ -185,22 +243,44 @@ func (ah *AggregateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		go func(idx int, backend compiledBackend) {
 			defer wg.Done()
 
+			// Each backend gets its own timeout, capped by what is left of the route budget.
+			budget := backend.timeout
+			budgetBound := false
+			if hasDeadline {
+				if remaining := time.Until(deadline); budget <= 0 || remaining < budget {
+					budget = remaining
+					budgetBound = true
+				}
+			}
+
 			start := time.Now()
 			bctx := ctx
 			bctx.Variables = backend.variables
-			body, err := ah.callBackend(r, backend, bctx)
-			ah.backendLatNs[idx].Add(time.Since(start).Nanoseconds())
+			body, header, err := ah.callBackend(r, backend, bctx, budget)
+			elapsed := time.Since(start)
+			ah.backendLatNs[idx].Add(elapsed.Nanoseconds())
 
 			if err != nil {
 				ah.backendErrors[idx].Add(1)
+				if errors.Is(err, context.DeadlineExceeded) {
+					if budgetBound {
+						err = fmt.Errorf("%w at backend %s", errBudgetExhausted, backend.name)
+					} else {
+						ah.backendTimeouts[idx].Add(1)
+						err = fmt.Errorf("timed out after %s", budget)
+					}
+				}
 			}
 
 			results <- backendResult{
-				index: idx,
-				name:  backend.name,
-				group: backend.group,
-				body:  body,
-				err:   err,
+				index:   idx,
+				name:    backend.name,
+				group:   backend.group,
+				body:    body,
+				header:  header,
+				err:     err,
+				elapsed: elapsed,
+				budget:  budget,
 			}
 		}(i, b)
 	}
@@ -218,49 +298,78 @@ func (ah *AggregateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check for errors
-	var errors []map[string]interface{}
+	var errs []map[string]interface{}
 	hasRequiredFailure := false
 	hasAnyFailure := false
+	exhausted := false
+	// upstreamStatus is the first backend error status, kept while every
+	// failure is a backend answering with one.
+	upstreamStatus := 0
+	relayed := true
 
 	for _, res := range collected {
 		if res.err != nil {
 			hasAnyFailure = true
-			errors = append(errors, map[string]interface{}{
+			var se statusError
+			if !errors.As(res.err, &se) {
+				relayed = false
+			} else if upstreamStatus == 0 {
+				upstreamStatus = se.code
+			}
+			entry := map[string]interface{}{
 				"backend": res.name,
 				"error":   res.err.Error(),
-			})
+			}
+			if ah.detailedErrors {
+				entry["elapsed_ms"] = res.elapsed.Milliseconds()
+				entry["budget_ms"] = res.budget.Milliseconds()
+			}
+			errs = append(errs, entry)
 			if ah.backends[res.index].required {
 				hasRequiredFailure = true
 			}
+			if errors.Is(res.err, errBudgetExhausted) {
+				exhausted = true
+			}
 		}
 	}
+	if exhausted {
+		ah.budgetExhausted.Add(1)
+	}
+	// A failure caused only by backend responses is not the gateway's own
+	// error, so error_pages and error_handling treat it as a backend error.
+	if hasAnyFailure && relayed && varCtx != nil {
+		varCtx.UpstreamStatus = upstreamStatus
+	}
 
 	// Abort if strategy requires it
 	if ah.failStrategy == "abort" && hasAnyFailure {
-		ah.totalErrors.Add(1)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadGateway)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":  "aggregate backend failure",
-			"errors": errors,
-		})
+		ah.abort(w, "aggregate backend failure", exhausted, errs)
 		return
 	}
 
 	if hasRequiredFailure {
-		ah.totalErrors.Add(1)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadGateway)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":  "required aggregate backend failure",
-			"errors": errors,
-		})
+		ah.abort(w, "required aggregate backend failure", exhausted, errs)
 		return
 	}
 
 	// Merge responses
 	merged := make(map[string]interface{})
 
+	// Propagate headers in backend order, not completion order, so conflict
+	// resolution is deterministic.
+	ordered := make([]*backendResult, len(ah.backends))
+	for i := range collected {
+		ordered[collected[i].index] = &collected[i]
+	}
+	var propagated headerprop.Set
+	for _, res := range ordered {
+		if res != nil && res.err == nil {
+			propagated.Collect(ah.backends[res.index].propagate, res.header)
+		}
+	}
+	ah.recordPropagation(propagated.Apply(w.Header(), ah.maxPropHeaders, ah.maxPropBytes))
+
 	for _, res := range collected {
 		if res.err != nil {
 			continue
@@ -287,7 +396,7 @@ func (ah *AggregateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Add errors array for partial mode
 	if ah.failStrategy == "partial" && hasAnyFailure {
-		merged["_errors"] = errors
+		merged["_errors"] = errs
 		w.Header().Set("X-Aggregate-Partial", "true")
 	}
 
@@ -314,24 +423,61 @@ func (ah *AggregateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write(mergedJSON)
 }
 
-func (ah *AggregateHandler) callBackend(origReq *http.Request, backend compiledBackend, ctx templateContext) ([]byte, error) {
+func (ah *AggregateHandler) recordPropagation(res headerprop.Result) {
+	ah.propagatedHeaders.Add(int64(res.Propagated))
+	ah.headerConflicts.Add(int64(res.Conflicts))
+	ah.headersDropped.Add(int64(res.Dropped))
+}
+
+// abort writes an aggregate failure. Failures caused by the route budget
+// running out are reported as 504 rather than 502.
+func (ah *AggregateHandler) abort(w http.ResponseWriter, msg string, exhausted bool, errs []map[string]interface{}) {
+	ah.totalErrors.Add(1)
+	status := http.StatusBadGateway
+	if exhausted {
+		status = http.StatusGatewayTimeout
+		msg = "aggregate deadline exhausted"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  msg,
+		"errors": errs,
+	})
+}
+
+func (ah *AggregateHandler) callBackend(origReq *http.Request, backend compiledBackend, ctx templateContext, timeout time.Duration) ([]byte, http.Header, error) {
 	// Render URL
 	var urlBuf bytes.Buffer
 	if err := backend.urlTmpl.Execute(&urlBuf, ctx); err != nil {
-		return nil, fmt.Errorf("URL template: %w", err)
+		return nil, nil, fmt.Errorf("URL template: %w", err)
 	}
 
-	// Create request with timeout context
-	reqCtx := origReq.Context()
-	if backend.timeout > 0 {
+	// Create request with timeout context, tagged for the egress policy
+	reqCtx := egress.WithSource(origReq.Context(), "aggregate")
+	if timeout > 0 {
 		var cancel context.CancelFunc
-		reqCtx, cancel = context.WithTimeout(reqCtx, backend.timeout)
+		reqCtx, cancel = context.WithTimeout(reqCtx, timeout)
 		defer cancel()
 	}
 
 	req, err := http.NewRequestWithContext(reqCtx, backend.method, urlBuf.String(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, nil, fmt.Errorf("create request: %w", err)
+	}
+
+	// Forward the client query parameters the copy policy selects; the
+	// parameters of the URL template take precedence.
+	if backend.policy.FiltersQuery() {
+		if fwd, _ := url.ParseQuery(backend.policy.FilterQuery(origReq.URL.RawQuery)); len(fwd) > 0 {
+			q := req.URL.Query()
+			for name, values := range fwd {
+				if _, ok := q[name]; !ok {
+					q[name] = values
+				}
+			}
+			req.URL.RawQuery = q.Encode()
+		}
 	}
 
 	// Render headers
@@ -342,27 +488,31 @@ func (ah *AggregateHandler) callBackend(origReq *http.Request, backend compiledB
 		}
 		req.Header.Set(hk, hBuf.String())
 	}
+	backend.policy.ApplyHeaders(origReq, req.Header)
+	if backend.auth != nil {
+		backend.auth.Apply(req)
+	}
 
 	resp, err := ah.transport.RoundTrip(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+		return nil, nil, statusError{resp.StatusCode}
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read body: %w", err)
+		return nil, nil, fmt.Errorf("read body: %w", err)
 	}
 
 	// Apply encoding conversion (e.g., XML/YAML to JSON)
 	if backend.encoding != "" {
 		decoded, decErr := backendenc.DecodeBytes(body, backend.encoding)
 		if decErr != nil {
-			return nil, fmt.Errorf("decode %s: %w", backend.encoding, decErr)
+			return nil, nil, fmt.Errorf("decode %s: %w", backend.encoding, decErr)
 		}
 		body = decoded
 	}
@@ -372,7 +522,7 @@ func (ah *AggregateHandler) callBackend(origReq *http.Request, backend compiledB
 		body = backend.transform.Transform(body, nil)
 	}
 
-	return body, nil
+	return body, resp.Header, nil
 }
 
 // Stats returns aggregate handler stats.
@@ -380,16 +530,21 @@ func (ah *AggregateHandler) Stats() map[string]interface{} {
 	backends := make([]map[string]interface{}, len(ah.backends))
 	for i := range ah.backends {
 		backends[i] = map[string]interface{}{
-			"name":            ah.backends[i].name,
-			"errors":          ah.backendErrors[i].Load(),
+			"name":             ah.backends[i].name,
+			"errors":           ah.backendErrors[i].Load(),
+			"timeouts":         ah.backendTimeouts[i].Load(),
 			"total_latency_us": ah.backendLatNs[i].Load() / 1000,
 		}
 	}
 	return map[string]interface{}{
-		"total_requests": ah.totalRequests.Load(),
-		"total_errors":   ah.totalErrors.Load(),
-		"fail_strategy":  ah.failStrategy,
-		"backends":       backends,
+		"total_requests":     ah.totalRequests.Load(),
+		"total_errors":       ah.totalErrors.Load(),
+		"budget_exhausted":   ah.budgetExhausted.Load(),
+		"fail_strategy":      ah.failStrategy,
+		"backends":           backends,
+		"propagated_headers": ah.propagatedHeaders.Load(),
+		"header_conflicts":   ah.headerConflicts.Load(),
+		"headers_dropped":    ah.headersDropped.Load(),
 	}
 }
 
@@ -404,13 +559,27 @@ func NewAggregateByRoute() *AggregateByRoute {
 }
 
 // AddRoute adds an aggregate handler for a route.
-func (m *AggregateByRoute) AddRoute(routeID string, cfg config.AggregateConfig, transport http.RoundTripper, completionHeader ...bool) error {
+func (m *AggregateByRoute) AddRoute(routeID string, cfg config.AggregateConfig, transport http.RoundTripper, opts Options) error {
 	ah, err := New(cfg, transport)
 	if err != nil {
 		return err
 	}
-	if len(completionHeader) > 0 {
-		ah.completionHeader = completionHeader[0]
+	ah.completionHeader = opts.CompletionHeader
+	ah.requestTimeout = opts.RequestTimeout
+	ah.detailedErrors = opts.DetailedErrors
+	for i, b := range cfg.Backends {
+		backend := &ah.backends[i]
+		if backend.policy, err = copypolicy.New(b.CopyRequestPolicy, opts.Secrets); err != nil {
+			return fmt.Errorf("backend %s: %w", b.Name, err)
+		}
+		if ref := b.BackendAuth.Ref; ref != "" {
+			if opts.BackendAuth == nil {
+				return fmt.Errorf("backend %s: backend_auth ref %q: no shared providers", b.Name, ref)
+			}
+			if backend.auth, err = opts.BackendAuth.AcquireShared(ref, routeID+"/"+b.Name); err != nil {
+				return fmt.Errorf("backend %s: %w", b.Name, err)
+			}
+		}
 	}
 	m.Add(routeID, ah)
 	return nil
@@ -1,13 +1,17 @@
 package aggregate
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/middleware/backendauth"
 )
 
 func TestAggregateHandler_BasicMerge(t *testing.T) {
@@ -289,7 +293,7 @@ func TestAggregateByRoute(t *testing.T) {
 		},
 	}
 
-	if err := m.AddRoute("r1", cfg, http.DefaultTransport); err != nil {
+	if err := m.AddRoute("r1", cfg, http.DefaultTransport, Options{}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -341,3 +345,326 @@ func TestAggregateHandler_Stats(t *testing.T) {
 		t.Errorf("expected 0 errors, got %v", stats["total_errors"])
 	}
 }
+
+func newBudgetAggregate(t *testing.T, backends []config.AggregateBackend, strategy string, opts Options) *AggregateHandler {
+	t.Helper()
+	m := NewAggregateByRoute()
+	cfg := config.AggregateConfig{Enabled: true, FailStrategy: strategy, Backends: backends}
+	if err := m.AddRoute("r1", cfg, http.DefaultTransport, opts); err != nil {
+		t.Fatal(err)
+	}
+	return m.Lookup("r1")
+}
+
+func delayedServer(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			json.NewEncoder(w).Encode(map[string]string{"ok": "true"})
+		case <-r.Context().Done():
+		}
+	}))
+}
+
+func TestAggregateHandler_RouteBudgetExhausted(t *testing.T) {
+	fast := delayedServer(0)
+	defer fast.Close()
+	slow := delayedServer(time.Second)
+	defer slow.Close()
+
+	ah := newBudgetAggregate(t, []config.AggregateBackend{
+		{Name: "fast", URL: fast.URL},
+		{Name: "slow", URL: slow.URL, Timeout: 5 * time.Second},
+	}, "abort", Options{RequestTimeout: 50 * time.Millisecond, DetailedErrors: true})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	ah.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected the route budget to bound fan-out, took %s", elapsed)
+	}
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", w.Code)
+	}
+
+	var body struct {
+		Error  string                   `json:"error"`
+		Errors []map[string]interface{} `json:"errors"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body.Error != "aggregate deadline exhausted" || len(body.Errors) != 1 {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+	if body.Errors[0]["error"] != "deadline exhausted at backend slow" {
+		t.Errorf("unexpected backend error: %v", body.Errors[0]["error"])
+	}
+	if _, ok := body.Errors[0]["budget_ms"]; !ok {
+		t.Errorf("expected budget_ms in detailed error entry: %v", body.Errors[0])
+	}
+
+	stats := ah.Stats()
+	if stats["budget_exhausted"] != int64(1) {
+		t.Errorf("expected budget_exhausted 1, got %v", stats["budget_exhausted"])
+	}
+	if b := stats["backends"].([]map[string]interface{}); b[1]["timeouts"] != int64(0) {
+		t.Errorf("budget exhaustion must not count as a backend timeout, got %v", b[0]["timeouts"])
+	}
+}
+
+func TestAggregateHandler_BackendTimeoutCountedSeparately(t *testing.T) {
+	fast := delayedServer(0)
+	defer fast.Close()
+	slow := delayedServer(time.Second)
+	defer slow.Close()
+
+	ah := newBudgetAggregate(t, []config.AggregateBackend{
+		{Name: "fast", URL: fast.URL},
+		{Name: "slow", URL: slow.URL, Timeout: 50 * time.Millisecond},
+	}, "abort", Options{RequestTimeout: 5 * time.Second})
+
+	w := httptest.NewRecorder()
+	ah.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", w.Code)
+	}
+	stats := ah.Stats()
+	if stats["budget_exhausted"] != int64(0) {
+		t.Errorf("expected budget_exhausted 0, got %v", stats["budget_exhausted"])
+	}
+	if b := stats["backends"].([]map[string]interface{}); b[1]["timeouts"] != int64(1) {
+		t.Errorf("expected backend timeouts 1, got %v", b[0]["timeouts"])
+	}
+}
+
+func TestAggregateHandler_FailsFastWhenBudgetSpent(t *testing.T) {
+	var called atomic.Bool
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called.Store(true)
+		json.NewEncoder(w).Encode(map[string]string{"ok": "true"})
+	}))
+	defer s.Close()
+
+	ah := newBudgetAggregate(t, []config.AggregateBackend{
+		{Name: "a", URL: s.URL},
+		{Name: "b", URL: s.URL},
+	}, "abort", Options{})
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Millisecond))
+	defer cancel()
+	w := httptest.NewRecorder()
+	ah.ServeHTTP(w, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", w.Code)
+	}
+	if called.Load() {
+		t.Error("expected no backend calls once the budget is spent")
+	}
+}
+
+func TestAggregateHandler_PartialWithBudget(t *testing.T) {
+	fast := delayedServer(0)
+	defer fast.Close()
+	slow := delayedServer(time.Second)
+	defer slow.Close()
+
+	ah := newBudgetAggregate(t, []config.AggregateBackend{
+		{Name: "fast", URL: fast.URL, Group: "fast"},
+		{Name: "slow", URL: slow.URL, Group: "slow"},
+	}, "partial", Options{RequestTimeout: 100 * time.Millisecond})
+
+	w := httptest.NewRecorder()
+	ah.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusOK || w.Header().Get("X-Aggregate-Partial") != "true" {
+		t.Fatalf("expected partial 200, got %d %s", w.Code, w.Body.String())
+	}
+	var merged map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &merged)
+	if merged["fast"] == nil || merged["slow"] != nil {
+		t.Errorf("expected only the fast backend to be merged: %v", merged)
+	}
+}
+
+func TestAggregateHandler_PropagateHeaders(t *testing.T) {
+	// The slow backend answers last; conflict resolution must still follow
+	// backend order.
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.Header().Set("X-Total-Count", "10")
+		w.Header().Set("X-RateLimit-Remaining", "5")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Set-Cookie", "a=1")
+		w.Header().Set("Upgrade", "h2c")
+		json.NewEncoder(w).Encode(map[string]string{"orders": "x"})
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Total-Count", "20")
+		w.Header().Set("X-RateLimit-Remaining", "7")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]string{"users": "y"})
+	}))
+	defer fast.Close()
+
+	run := func(conflict string) (http.Header, map[string]interface{}) {
+		ah, err := New(config.AggregateConfig{
+			Enabled: true,
+			Backends: []config.AggregateBackend{
+				{Name: "orders", URL: slow.URL, PropagateHeaders: config.PropagateHeadersConfig{
+					Headers: []string{"X-Total-Count", "X-RateLimit-*", "Upgrade", "Set-Cookie"},
+					Rename:  map[string]string{"X-Total-Count": "X-Orders-Total"},
+				}},
+				{Name: "users", URL: fast.URL, PropagateHeaders: config.PropagateHeadersConfig{
+					Headers:  []string{"X-RateLimit-*", "Cache-Control"},
+					Conflict: conflict,
+				}},
+			},
+		}, http.DefaultTransport)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		ah.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return w.Header(), ah.Stats()
+	}
+
+	for conflict, want := range map[string]string{"": "5", "first": "5", "last": "7", "join": "5, 7"} {
+		h, stats := run(conflict)
+		if got := h.Get("X-RateLimit-Remaining"); got != want {
+			t.Errorf("conflict %q: expected X-RateLimit-Remaining %q, got %q", conflict, want, got)
+		}
+		if h.Get("X-Orders-Total") != "10" || h.Get("X-Total-Count") != "" {
+			t.Errorf("expected X-Total-Count renamed to X-Orders-Total, got %v", h)
+		}
+		if h.Get("Cache-Control") != "no-store" {
+			t.Errorf("expected Cache-Control from users, got %q", h.Get("Cache-Control"))
+		}
+		if h.Get("Upgrade") != "" || h.Get("Set-Cookie") != "" {
+			t.Errorf("expected hop-by-hop and unapproved Set-Cookie to be dropped, got %v", h)
+		}
+		if h.Get("Content-Type") != "application/json" {
+			t.Errorf("expected the merged Content-Type, got %q", h.Get("Content-Type"))
+		}
+		if stats["propagated_headers"] != int64(3) || stats["header_conflicts"] != int64(1) {
+			t.Errorf("conflict %q: unexpected stats %v", conflict, stats)
+		}
+	}
+}
+
+func TestAggregateHandler_PropagateHeadersCapped(t *testing.T) {
+	backend := func(n string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-A-"+n, "1")
+			w.Header().Set("X-B-"+n, "2")
+			w.Write([]byte(`{}`))
+		}))
+	}
+	s1, s2 := backend("1"), backend("2")
+	defer s1.Close()
+	defer s2.Close()
+
+	prop := config.PropagateHeadersConfig{Headers: []string{"X-*"}}
+	ah, err := New(config.AggregateConfig{
+		Enabled:              true,
+		MaxPropagatedHeaders: 3,
+		Backends: []config.AggregateBackend{
+			{Name: "one", URL: s1.URL, PropagateHeaders: prop},
+			{Name: "two", URL: s2.URL, PropagateHeaders: prop},
+		},
+	}, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	ah.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	for _, name := range []string{"X-A-1", "X-A-2", "X-B-1"} {
+		if w.Header().Get(name) == "" {
+			t.Errorf("expected %s to be propagated", name)
+		}
+	}
+	if w.Header().Get("X-B-2") != "" {
+		t.Error("expected X-B-2 to be dropped by the cap")
+	}
+	if stats := ah.Stats(); stats["headers_dropped"] != int64(1) {
+		t.Errorf("expected 1 dropped header, got %v", stats["headers_dropped"])
+	}
+}
+
+func TestAggregateHandler_CopyPolicyAndBackendAuth(t *testing.T) {
+	t.Setenv("AGGREGATE_PARTNER_KEY", "partner-key")
+	var tokenRequests atomic.Int64
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "svc-token", "expires_in": 3600})
+	}))
+	defer tokens.Close()
+
+	var mu sync.Mutex
+	seen := make(map[string]*http.Request)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Path] = r
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{r.URL.Path[1:]: "ok"})
+	}))
+	defer backend.Close()
+
+	auths := backendauth.NewBackendAuthByRoute(map[string]config.BackendAuthConfig{
+		"partner": {Type: "oauth2_client_credentials", TokenURL: tokens.URL, ClientID: "gw", ClientSecret: "s"},
+	})
+	ah := newBudgetAggregate(t, []config.AggregateBackend{
+		{
+			Name:    "users",
+			URL:     backend.URL + "/users?view=full",
+			Headers: map[string]string{"X-Client-Auth": `{{ .Headers.Get "Authorization" }}`},
+			CopyRequestPolicy: config.CopyRequestPolicy{
+				StripHeaders:       []string{"X-Client-Auth"},
+				SetHeaders:         map[string]string{"X-Partner-Key": `{{ secret "env:AGGREGATE_PARTNER_KEY" }}`},
+				ForwardQueryParams: config.QueryParamFilter{Allow: []string{"lang", "view"}},
+			},
+			BackendAuth: config.BackendAuthConfig{Ref: "partner"},
+		},
+		{Name: "stats", URL: backend.URL + "/stats"},
+	}, "abort", Options{Secrets: config.NewDefaultSecretRegistry(config.SecretsConfig{}), BackendAuth: auths})
+
+	r := httptest.NewRequest(http.MethodGet, "/dash?lang=de&debug=1&view=compact", nil)
+	r.Header.Set("Authorization", "Bearer client-token")
+	w := httptest.NewRecorder()
+	ah.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	users := seen["/users"]
+	if got := users.Header.Get("Authorization"); got != "Bearer svc-token" {
+		t.Errorf("expected the shared provider's token, got %q", got)
+	}
+	if users.Header.Get("X-Client-Auth") != "" || users.Header.Get("X-Partner-Key") != "partner-key" {
+		t.Errorf("expected the copy policy applied to the headers, got %v", users.Header)
+	}
+	// The URL template's view wins; only allowed client parameters are added.
+	if q := users.URL.Query(); q.Get("view") != "full" || q.Get("lang") != "de" || q.Has("debug") {
+		t.Errorf("unexpected forwarded query %q", users.URL.RawQuery)
+	}
+
+	stats := seen["/stats"]
+	if stats.Header.Get("Authorization") != "" || stats.URL.RawQuery != "" {
+		t.Errorf("expected a backend without policy untouched, got %v %q", stats.Header, stats.URL.RawQuery)
+	}
+	if r.Header.Get("Authorization") != "Bearer client-token" || r.URL.RawQuery != "lang=de&debug=1&view=compact" {
+		t.Errorf("client request changed: %v %q", r.Header, r.URL.RawQuery)
+	}
+	if shared := auths.SharedStats()["partner"]; len(shared.Routes) != 1 || shared.Routes[0] != "r1/users" {
+		t.Errorf("expected the backend listed as a referrer, got %+v", shared)
+	}
+	if tokenRequests.Load() != 1 {
+		t.Errorf("expected one token request, got %d", tokenRequests.Load())
+	}
+}
@@ -0,0 +1,20 @@
+package proxy
+
+import (
+	"context"
+	"time"
+)
+
+// BudgetDeadline returns the deadline every backend call of a request that
+// fans out to several backends must finish by: the earlier of the request
+// context's deadline and start+requestTimeout. It reports false when
+// neither is set.
+func BudgetDeadline(ctx context.Context, start time.Time, requestTimeout time.Duration) (time.Time, bool) {
+	deadline, ok := ctx.Deadline()
+	if requestTimeout > 0 {
+		if d := start.Add(requestTimeout); !ok || d.Before(deadline) {
+			deadline, ok = d, true
+		}
+	}
+	return deadline, ok
+}
@@ -0,0 +1,26 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBudgetDeadline(t *testing.T) {
+	start := time.Now()
+	ctx, cancel := context.WithDeadline(context.Background(), start.Add(time.Second))
+	defer cancel()
+
+	if _, ok := BudgetDeadline(context.Background(), start, 0); ok {
+		t.Error("expected no deadline without a context deadline or request timeout")
+	}
+	if d, ok := BudgetDeadline(context.Background(), start, 2*time.Second); !ok || !d.Equal(start.Add(2*time.Second)) {
+		t.Errorf("request timeout only: got %v, %v", d, ok)
+	}
+	if d, _ := BudgetDeadline(ctx, start, 2*time.Second); !d.Equal(start.Add(time.Second)) {
+		t.Errorf("expected the earlier context deadline, got %v", d)
+	}
+	if d, _ := BudgetDeadline(ctx, start, 500*time.Millisecond); !d.Equal(start.Add(500 * time.Millisecond)) {
+		t.Errorf("expected the earlier request timeout, got %v", d)
+	}
+}
@@ -0,0 +1,233 @@
+// Package copypolicy applies the header and query policy of requests a
+// route copies to secondary targets, such as mirror and aggregate backends.
+//
+// A policy strips headers from the copy, sets headers rendered from Go
+// templates, and filters the client query parameters the copy carries. It
+// only ever changes the copy: callers hand it the copy's header map and
+// query, never the primary request's.
+package copypolicy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/tmplutil"
+	"github.com/wudi/runway/variables"
+)
+
+const (
+	// secretTTL is how long a resolved secret is reused before the
+	// reference is resolved again.
+	secretTTL      = time.Minute
+	resolveTimeout = 10 * time.Second
+)
+
+// resolver resolves the variables templates read with .Var.
+var resolver = variables.NewResolver()
+
+// Policy is a compiled config.CopyRequestPolicy. A nil *Policy leaves
+// copies unchanged.
+type Policy struct {
+	strip   []string // canonical header names
+	set     []setHeader
+	allow   map[string]bool
+	deny    map[string]bool
+	secrets *secretCache
+}
+
+type setHeader struct {
+	name string
+	tmpl *template.Template
+}
+
+// New compiles cfg, or returns nil when cfg changes nothing. The secret
+// template function resolves references through registry.
+func New(cfg config.CopyRequestPolicy, registry *config.SecretRegistry) (*Policy, error) {
+	if !cfg.IsActive() {
+		return nil, nil
+	}
+	p := &Policy{secrets: &secretCache{registry: registry, values: make(map[string]cachedSecret)}}
+	for _, name := range cfg.StripHeaders {
+		p.strip = append(p.strip, http.CanonicalHeaderKey(name))
+	}
+	funcs := tmplutil.FuncMap()
+	funcs["secret"] = p.secrets.get
+	for _, name := range slices.Sorted(maps.Keys(cfg.SetHeaders)) {
+		tmpl, err := template.New(name).Funcs(funcs).Parse(cfg.SetHeaders[name])
+		if err != nil {
+			return nil, fmt.Errorf("set_headers %s: %w", name, err)
+		}
+		p.set = append(p.set, setHeader{name: http.CanonicalHeaderKey(name), tmpl: tmpl})
+	}
+	if len(cfg.ForwardQueryParams.Allow) > 0 {
+		p.allow = nameSet(cfg.ForwardQueryParams.Allow)
+	}
+	if len(cfg.ForwardQueryParams.Deny) > 0 {
+		p.deny = nameSet(cfg.ForwardQueryParams.Deny)
+	}
+	return p, nil
+}
+
+func nameSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, n := range names {
+		set[n] = true
+	}
+	return set
+}
+
+// TemplateData is the data set_headers templates execute with.
+type TemplateData struct {
+	Method     string
+	Path       string
+	Host       string
+	Query      url.Values
+	Headers    http.Header
+	ClientIP   string
+	RouteID    string
+	PathParams map[string]string
+
+	varCtx *variables.Context
+}
+
+// Var returns a variable of the request's variable context, such as
+// request_id or a custom variable set earlier in the pipeline. A leading $
+// is optional.
+func (d *TemplateData) Var(name string) string {
+	v, _ := resolver.Get(strings.TrimPrefix(name, "$"), d.varCtx)
+	return v
+}
+
+func newTemplateData(r *http.Request) *TemplateData {
+	d := &TemplateData{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Host:     r.Host,
+		Query:    r.URL.Query(),
+		Headers:  r.Header,
+		ClientIP: variables.ExtractClientIP(r),
+		varCtx:   variables.GetFromRequest(r),
+	}
+	if d.varCtx != nil {
+		d.RouteID = d.varCtx.RouteID
+		d.PathParams = d.varCtx.PathParams
+	}
+	return d
+}
+
+// ApplyHeaders strips and sets the policy's headers on h, the header map of
+// a copy of r. Templates render against r, so ApplyHeaders must run while
+// r is being served. It returns the values it set, which callers redact
+// from anything recorded about the copy.
+func (p *Policy) ApplyHeaders(r *http.Request, h http.Header) []string {
+	if p == nil {
+		return nil
+	}
+	for _, name := range p.strip {
+		h.Del(name)
+	}
+	if len(p.set) == 0 {
+		return nil
+	}
+	data := newTemplateData(r)
+	values := make([]string, 0, len(p.set))
+	var buf bytes.Buffer
+	for _, sh := range p.set {
+		buf.Reset()
+		if err := sh.tmpl.Execute(&buf, data); err != nil {
+			logging.DebugContext(r.Context(), "copy policy header template failed",
+				zap.String("header", sh.name),
+				zap.Error(err),
+			)
+			continue
+		}
+		h.Set(sh.name, buf.String())
+		if buf.Len() > 0 {
+			values = append(values, buf.String())
+		}
+	}
+	return values
+}
+
+// FiltersQuery reports whether the policy selects query parameters.
+func (p *Policy) FiltersQuery() bool {
+	return p != nil && (p.allow != nil || p.deny != nil)
+}
+
+// FilterQuery returns the parameters of the raw query the copy carries.
+func (p *Policy) FilterQuery(raw string) string {
+	if !p.FiltersQuery() || raw == "" {
+		return raw
+	}
+	q, err := url.ParseQuery(raw)
+	if err != nil {
+		return ""
+	}
+	for name := range q {
+		if (p.allow != nil && !p.allow[name]) || p.deny[name] {
+			q.Del(name)
+		}
+	}
+	return q.Encode()
+}
+
+// secretCache resolves the secret template function's references, reusing
+// a value for secretTTL.
+type secretCache struct {
+	registry *config.SecretRegistry
+
+	mu     sync.Mutex
+	values map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	value    string
+	resolved time.Time
+}
+
+// get resolves ref, a scheme:reference pair such as env:MIRROR_TOKEN. When
+// a refresh fails, the previous value is kept.
+func (c *secretCache) get(ref string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.values[ref]
+	if ok && time.Since(cached.resolved) < secretTTL {
+		return cached.value, nil
+	}
+	value, err := c.resolve(ref)
+	if err != nil {
+		if ok {
+			logging.Warn("copy policy secret refresh failed, keeping the previous value", zap.Error(err))
+			return cached.value, nil
+		}
+		return "", err
+	}
+	c.values[ref] = cachedSecret{value: value, resolved: time.Now()}
+	return value, nil
+}
+
+func (c *secretCache) resolve(ref string) (string, error) {
+	if c.registry == nil {
+		return "", fmt.Errorf("secret %q: no secret providers", ref)
+	}
+	scheme, reference, ok := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(ref, "${"), "}"), ":")
+	if !ok || scheme == "" || reference == "" {
+		return "", fmt.Errorf("secret %q: want scheme:reference", ref)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	return c.registry.Resolve(ctx, scheme, reference)
+}
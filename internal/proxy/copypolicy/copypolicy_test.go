@@ -0,0 +1,113 @@
+package copypolicy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
+)
+
+func TestInactivePolicy(t *testing.T) {
+	p, err := New(config.CopyRequestPolicy{}, nil)
+	if err != nil || p != nil {
+		t.Fatalf("expected a nil policy, got %v, %v", p, err)
+	}
+	h := http.Header{"Authorization": {"Bearer x"}}
+	if set := p.ApplyHeaders(httptest.NewRequest("GET", "/", nil), h); set != nil || h.Get("Authorization") == "" {
+		t.Errorf("nil policy changed the copy: %v %v", set, h)
+	}
+	if p.FiltersQuery() || p.FilterQuery("a=1") != "a=1" {
+		t.Error("nil policy filtered the query")
+	}
+}
+
+func TestApplyHeaders(t *testing.T) {
+	t.Setenv("COPY_TOKEN", "env-token")
+	file := filepath.Join(t.TempDir(), "key")
+	os.WriteFile(file, []byte("file-key"), 0o600)
+
+	p, err := New(config.CopyRequestPolicy{
+		StripHeaders: []string{"authorization", "Cookie"},
+		SetHeaders: map[string]string{
+			"X-Token":   `Bearer {{ secret "env:COPY_TOKEN" }}`,
+			"X-Key":     `{{ secret "${file:` + file + `}" }}`,
+			"X-Context": `{{ .Var "$request_id" }} {{ .RouteID }} {{ .PathParams.id }} {{ .Query.Get "q" }}`,
+			"X-Missing": `{{ secret "env:COPY_MISSING" }}`,
+			"X-Empty":   `{{ .Headers.Get "X-None" }}`,
+		},
+	}, config.NewDefaultSecretRegistry(config.SecretsConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/items/7?q=shoes", nil)
+	r.Header.Set("Authorization", "Bearer client")
+	r.Header.Set("Cookie", "s=1")
+	varCtx := variables.NewContext(r)
+	varCtx.RequestID = "req-9"
+	varCtx.RouteID = "items"
+	varCtx.PathParams = map[string]string{"id": "7"}
+	r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx))
+
+	h := r.Header.Clone()
+	set := p.ApplyHeaders(r, h)
+	want := map[string]string{
+		"Authorization": "",
+		"Cookie":        "",
+		"X-Token":       "Bearer env-token",
+		"X-Key":         "file-key",
+		"X-Context":     "req-9 items 7 shoes",
+		"X-Missing":     "",
+	}
+	for name, v := range want {
+		if got := h.Get(name); got != v {
+			t.Errorf("%s = %q, want %q", name, got, v)
+		}
+	}
+	if len(set) != 3 {
+		t.Errorf("expected the 3 non-empty values returned, got %q", set)
+	}
+	if r.Header.Get("Authorization") != "Bearer client" || r.Header.Get("X-Token") != "" {
+		t.Errorf("source request changed: %v", r.Header)
+	}
+}
+
+func TestFilterQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter config.QueryParamFilter
+		want   url.Values
+	}{
+		{"allow", config.QueryParamFilter{Allow: []string{"id", "lang"}}, url.Values{"id": {"1", "2"}, "lang": {"de"}}},
+		{"deny", config.QueryParamFilter{Deny: []string{"api_key"}}, url.Values{"id": {"1", "2"}, "lang": {"de"}, "debug": {""}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(config.CopyRequestPolicy{ForwardQueryParams: tt.filter}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := url.ParseQuery(p.FilterQuery("id=1&api_key=k&lang=de&id=2&debug"))
+			if err != nil || got.Encode() != tt.want.Encode() {
+				t.Errorf("FilterQuery = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretWithoutRegistry(t *testing.T) {
+	p, err := New(config.CopyRequestPolicy{SetHeaders: map[string]string{"X-Token": `{{ secret "env:HOME" }}`}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := make(http.Header)
+	if set := p.ApplyHeaders(httptest.NewRequest("GET", "/", nil), h); len(set) != 0 || h.Get("X-Token") != "" {
+		t.Errorf("expected no value without a registry, got %q %v", set, h)
+	}
+}
@@ -0,0 +1,272 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/internal/egress"
+	"github.com/wudi/runway/internal/middleware/ssrf"
+)
+
+// IP family preferences accepted by transport.ip_family.
+const (
+	IPFamilyAuto       = "auto"
+	IPFamilyIPv4       = "ipv4"
+	IPFamilyIPv6       = "ipv6"
+	IPFamilyPreferIPv4 = "prefer_ipv4"
+	IPFamilyPreferIPv6 = "prefer_ipv6"
+)
+
+// DefaultConnectionAttemptDelay is the stagger between connection attempts
+// (RFC 8305 section 5 recommends 250ms).
+const DefaultConnectionAttemptDelay = 250 * time.Millisecond
+
+// familyStats tracks dial outcomes for a single address family.
+type familyStats struct {
+	attempts  atomic.Int64
+	successes atomic.Int64
+	fallbacks atomic.Int64
+}
+
+func (fs *familyStats) snapshot() map[string]interface{} {
+	return map[string]interface{}{
+		"attempts":  fs.attempts.Load(),
+		"successes": fs.successes.Load(),
+		"fallbacks": fs.fallbacks.Load(),
+	}
+}
+
+// FamilyDialer resolves both address families and races connection attempts
+// Happy Eyeballs style (RFC 8305). The configured family preference decides
+// which family is tried first, or restricts dialing to a single family.
+// When an SSRF guard or egress policy is set, every candidate address is
+// validated before any connection attempt is made.
+type FamilyDialer struct {
+	dialer *net.Dialer
+	family string
+	guard  *ssrf.SafeDialer
+	egress func() *egress.Policy // nil, or returning nil, when off
+	delay  time.Duration
+
+	// lookup and dial are indirections for tests.
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	v4 familyStats
+	v6 familyStats
+}
+
+// NewFamilyDialer creates a dialer for the given family preference. An empty
+// family is treated as "auto". guard may be nil when SSRF protection is off.
+func NewFamilyDialer(dialer *net.Dialer, family string, guard *ssrf.SafeDialer) *FamilyDialer {
+	if family == "" {
+		family = IPFamilyAuto
+	}
+	resolver := dialer.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &FamilyDialer{
+		dialer: dialer,
+		family: family,
+		guard:  guard,
+		delay:  DefaultConnectionAttemptDelay,
+		lookup: resolver.LookupIPAddr,
+		dial:   dialer.DialContext,
+	}
+}
+
+// SetEgressPolicy makes fd check every connection against the policy
+// returned by policy, which is read on each dial so the policy can change
+// on reload. A nil policy disables the check.
+func (fd *FamilyDialer) SetEgressPolicy(policy func() *egress.Policy) {
+	fd.egress = policy
+}
+
+// DialContext resolves addr and connects to the first address that answers.
+func (fd *FamilyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial: invalid address %q: %w", addr, err)
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := fd.lookup(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("dial: DNS lookup failed for %q: %w", host, err)
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+
+	primary, fallback := fd.partition(ips)
+	if len(primary) == 0 && len(fallback) == 0 {
+		return nil, fmt.Errorf("dial: no %s addresses found for %q", fd.family, host)
+	}
+
+	if fd.guard != nil {
+		for _, ip := range append(append([]net.IP{}, primary...), fallback...) {
+			if err := fd.guard.Check(ip); err != nil {
+				return nil, fmt.Errorf("%w (resolved from %s)", err, host)
+			}
+		}
+	}
+	if fd.egress != nil {
+		if p := fd.egress(); p != nil {
+			if err := p.CheckDial(ctx, host, append(append([]net.IP{}, primary...), fallback...)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return fd.race(ctx, network, port, primary, fallback)
+}
+
+// partition splits resolved addresses into the preferred family and the
+// fallback family according to the configured preference. Single-family
+// modes drop the other family entirely.
+func (fd *FamilyDialer) partition(ips []net.IP) (primary, fallback []net.IP) {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+
+	switch fd.family {
+	case IPFamilyIPv4:
+		return v4, nil
+	case IPFamilyIPv6:
+		return v6, nil
+	case IPFamilyPreferIPv4:
+		if len(v4) == 0 {
+			return v6, nil
+		}
+		return v4, v6
+	case IPFamilyPreferIPv6:
+		if len(v6) == 0 {
+			return v4, nil
+		}
+		return v6, v4
+	default:
+		// auto: follow the resolver's ordering for the first family
+		if len(ips) > 0 && ips[0].To4() == nil {
+			return v6, v4
+		}
+		if len(v4) == 0 {
+			return v6, nil
+		}
+		return v4, v6
+	}
+}
+
+type dialResult struct {
+	conn     net.Conn
+	err      error
+	ip       net.IP
+	fallback bool
+}
+
+// race starts connection attempts in interleaved family order, launching the
+// next attempt after the stagger delay or as soon as the previous one fails.
+// The first successful connection wins; all others are cancelled and closed.
+func (fd *FamilyDialer) race(ctx context.Context, network, port string, primary, fallback []net.IP) (net.Conn, error) {
+	type candidate struct {
+		ip       net.IP
+		fallback bool
+	}
+	candidates := make([]candidate, 0, len(primary)+len(fallback))
+	for i := 0; i < len(primary) || i < len(fallback); i++ {
+		if i < len(primary) {
+			candidates = append(candidates, candidate{ip: primary[i]})
+		}
+		if i < len(fallback) {
+			candidates = append(candidates, candidate{ip: fallback[i], fallback: true})
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, len(candidates))
+	next, inflight := 0, 0
+	start := func() {
+		c := candidates[next]
+		next++
+		inflight++
+		fd.statsFor(c.ip).attempts.Add(1)
+		go func() {
+			conn, err := fd.dial(ctx, network, net.JoinHostPort(c.ip.String(), port))
+			results <- dialResult{conn: conn, err: err, ip: c.ip, fallback: c.fallback}
+		}()
+	}
+
+	start()
+	timer := time.NewTimer(fd.delay)
+	defer timer.Stop()
+
+	var errs []error
+	for inflight > 0 {
+		select {
+		case <-timer.C:
+			if next < len(candidates) && ctx.Err() == nil {
+				start()
+				timer.Reset(fd.delay)
+			}
+		case r := <-results:
+			inflight--
+			if r.err == nil {
+				cancel()
+				go closeLosers(results, inflight)
+				st := fd.statsFor(r.ip)
+				st.successes.Add(1)
+				if r.fallback {
+					st.fallbacks.Add(1)
+				}
+				return r.conn, nil
+			}
+			errs = append(errs, r.err)
+			if next < len(candidates) && ctx.Err() == nil {
+				start()
+				timer.Reset(fd.delay)
+			}
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// closeLosers drains the remaining in-flight attempts after a winner has been
+// chosen, closing any connection that completed anyway.
+func closeLosers(results <-chan dialResult, n int) {
+	for i := 0; i < n; i++ {
+		if r := <-results; r.conn != nil {
+			r.conn.Close()
+		}
+	}
+}
+
+func (fd *FamilyDialer) statsFor(ip net.IP) *familyStats {
+	if ip.To4() != nil {
+		return &fd.v4
+	}
+	return &fd.v6
+}
+
+// Stats returns per-family dial statistics.
+func (fd *FamilyDialer) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"ip_family": fd.family,
+		"ipv4":      fd.v4.snapshot(),
+		"ipv6":      fd.v6.snapshot(),
+	}
+}
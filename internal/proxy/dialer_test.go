@@ -0,0 +1,265 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/egress"
+	"github.com/wudi/runway/internal/middleware/ssrf"
+)
+
+// listenLoopback binds a listener on the given loopback address, skipping the
+// test when the family is unavailable in the environment.
+func listenLoopback(t *testing.T, network, addr string) net.Listener {
+	t.Helper()
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		t.Skipf("%s loopback unavailable: %v", network, err)
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+// unusedPort returns a port that nothing listens on for the given family.
+func unusedPort(t *testing.T, network, addr string) string {
+	t.Helper()
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		t.Skipf("%s loopback unavailable: %v", network, err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+	return port
+}
+
+func staticLookup(ips ...string) func(context.Context, string) ([]net.IPAddr, error) {
+	return func(context.Context, string) ([]net.IPAddr, error) {
+		addrs := make([]net.IPAddr, 0, len(ips))
+		for _, ip := range ips {
+			addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+		}
+		return addrs, nil
+	}
+}
+
+func familyStat(t *testing.T, fd *FamilyDialer, family, key string) int64 {
+	t.Helper()
+	return fd.Stats()[family].(map[string]interface{})[key].(int64)
+}
+
+func TestFamilyDialerFallsBackToIPv4(t *testing.T) {
+	ln := listenLoopback(t, "tcp4", "127.0.0.1:0")
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// Ensure nothing listens on [::1]:port so the IPv6 attempt is refused.
+	if probe, err := net.Listen("tcp6", net.JoinHostPort("::1", port)); err != nil {
+		t.Skipf("ipv6 loopback unavailable or port taken: %v", err)
+	} else {
+		probe.Close()
+	}
+
+	fd := NewFamilyDialer(&net.Dialer{Timeout: time.Second}, IPFamilyAuto, nil)
+	fd.lookup = staticLookup("::1", "127.0.0.1")
+
+	conn, err := fd.DialContext(context.Background(), "tcp", net.JoinHostPort("backend.test", port))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	if !strings.HasPrefix(conn.RemoteAddr().String(), "127.0.0.1:") {
+		t.Errorf("expected IPv4 connection, got %s", conn.RemoteAddr())
+	}
+	if got := familyStat(t, fd, "ipv6", "attempts"); got != 1 {
+		t.Errorf("expected 1 ipv6 attempt, got %d", got)
+	}
+	if got := familyStat(t, fd, "ipv6", "successes"); got != 0 {
+		t.Errorf("expected 0 ipv6 successes, got %d", got)
+	}
+	if got := familyStat(t, fd, "ipv4", "successes"); got != 1 {
+		t.Errorf("expected 1 ipv4 success, got %d", got)
+	}
+	if got := familyStat(t, fd, "ipv4", "fallbacks"); got != 1 {
+		t.Errorf("expected 1 ipv4 fallback, got %d", got)
+	}
+}
+
+func TestFamilyDialerPreferIPv6(t *testing.T) {
+	ln := listenLoopback(t, "tcp6", "[::1]:0")
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	fd := NewFamilyDialer(&net.Dialer{Timeout: time.Second}, IPFamilyPreferIPv6, nil)
+	fd.lookup = staticLookup("127.0.0.1", "::1")
+
+	conn, err := fd.DialContext(context.Background(), "tcp", net.JoinHostPort("backend.test", port))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	if !strings.HasPrefix(conn.RemoteAddr().String(), "[::1]:") {
+		t.Errorf("expected IPv6 connection, got %s", conn.RemoteAddr())
+	}
+	if got := familyStat(t, fd, "ipv4", "attempts"); got != 0 {
+		t.Errorf("expected no ipv4 attempts, got %d", got)
+	}
+	if got := familyStat(t, fd, "ipv6", "fallbacks"); got != 0 {
+		t.Errorf("expected no fallbacks, got %d", got)
+	}
+}
+
+func TestFamilyDialerIPv4Only(t *testing.T) {
+	port := unusedPort(t, "tcp4", "127.0.0.1:0")
+
+	fd := NewFamilyDialer(&net.Dialer{Timeout: time.Second}, IPFamilyIPv4, nil)
+	fd.lookup = staticLookup("::1", "127.0.0.1")
+
+	_, err := fd.DialContext(context.Background(), "tcp", net.JoinHostPort("backend.test", port))
+	if err == nil {
+		t.Fatal("expected dial error with nothing listening")
+	}
+	if got := familyStat(t, fd, "ipv6", "attempts"); got != 0 {
+		t.Errorf("ipv4-only mode must not attempt ipv6, got %d attempts", got)
+	}
+	if got := familyStat(t, fd, "ipv4", "attempts"); got != 1 {
+		t.Errorf("expected 1 ipv4 attempt, got %d", got)
+	}
+}
+
+func TestFamilyDialerNoAddressesForFamily(t *testing.T) {
+	fd := NewFamilyDialer(&net.Dialer{}, IPFamilyIPv6, nil)
+	fd.lookup = staticLookup("127.0.0.1")
+
+	_, err := fd.DialContext(context.Background(), "tcp", "backend.test:80")
+	if err == nil || !strings.Contains(err.Error(), "no ipv6 addresses") {
+		t.Fatalf("expected no-addresses error, got %v", err)
+	}
+}
+
+func TestFamilyDialerStaggersHungPrimary(t *testing.T) {
+	ln := listenLoopback(t, "tcp4", "127.0.0.1:0")
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	fd := NewFamilyDialer(&net.Dialer{Timeout: 5 * time.Second}, IPFamilyPreferIPv6, nil)
+	fd.delay = 20 * time.Millisecond
+	fd.lookup = staticLookup("2001:db8::1", "127.0.0.1")
+	inner := fd.dial
+	fd.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if strings.HasPrefix(addr, "[2001:db8::1]") {
+			// Simulate a black-holed IPv6 path that never answers.
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return inner(ctx, network, addr)
+	}
+
+	start := time.Now()
+	conn, err := fd.DialContext(context.Background(), "tcp", net.JoinHostPort("backend.test", port))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	conn.Close()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("fallback took %v, expected roughly the stagger delay", elapsed)
+	}
+	if got := familyStat(t, fd, "ipv4", "fallbacks"); got != 1 {
+		t.Errorf("expected 1 ipv4 fallback, got %d", got)
+	}
+}
+
+func TestFamilyDialerSSRFChecksEveryAddress(t *testing.T) {
+	guard, err := ssrf.New(&net.Dialer{}, config.SSRFProtectionConfig{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd := NewFamilyDialer(&net.Dialer{Timeout: time.Second}, IPFamilyAuto, guard)
+	// The public first address passes; the private fallback must still be refused.
+	fd.lookup = staticLookup("2606:4700::1111", "10.0.0.1")
+	fd.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		t.Fatalf("unexpected dial to %s", addr)
+		return nil, nil
+	}
+
+	_, err = fd.DialContext(context.Background(), "tcp", "backend.test:80")
+	if err == nil || !strings.Contains(err.Error(), "ssrf") {
+		t.Fatalf("expected ssrf error, got %v", err)
+	}
+	if guard.BlockedRequests() != 1 {
+		t.Errorf("expected 1 blocked request, got %d", guard.BlockedRequests())
+	}
+}
+
+func TestFamilyDialerEgressPolicy(t *testing.T) {
+	policy, err := egress.New(config.EgressPolicyConfig{
+		Enabled:      true,
+		AllowedCIDRs: []string{"10.0.0.0/8"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd := NewFamilyDialer(&net.Dialer{Timeout: time.Second}, IPFamilyAuto, nil)
+	fd.SetEgressPolicy(func() *egress.Policy { return policy })
+	fd.lookup = staticLookup("10.0.0.1", "192.0.2.1")
+	fd.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		t.Fatalf("unexpected dial to %s", addr)
+		return nil, nil
+	}
+
+	ctx := egress.WithSource(context.Background(), "aggregate")
+	_, err = fd.DialContext(ctx, "tcp", "users.test:80")
+	if err == nil || !strings.Contains(err.Error(), "egress policy") {
+		t.Fatalf("expected egress policy error, got %v", err)
+	}
+	if got := policy.Violations()["aggregate"]; got != 1 {
+		t.Errorf("expected 1 violation counted for aggregate, got %d", got)
+	}
+}
+
+func TestTransportPoolDialStats(t *testing.T) {
+	pool := NewTransportPool()
+	cfg := DefaultTransportConfig
+	cfg.IPFamily = IPFamilyPreferIPv4
+	pool.Set("dual", cfg)
+
+	stats := pool.DialStats()
+	def := stats["default"].(map[string]interface{})
+	if def["ip_family"] != IPFamilyAuto {
+		t.Errorf("expected default ip_family auto, got %v", def["ip_family"])
+	}
+	ups := stats["upstreams"].(map[string]interface{})
+	dual, ok := ups["dual"].(map[string]interface{})
+	if !ok {
+		t.Fatal("expected dial stats for upstream dual")
+	}
+	if dual["ip_family"] != IPFamilyPreferIPv4 {
+		t.Errorf("expected prefer_ipv4, got %v", dual["ip_family"])
+	}
+}
+
+func TestMergeTransportConfigsIPFamily(t *testing.T) {
+	base := DefaultTransportConfig
+	merged := MergeTransportConfigs(base, config.TransportConfig{IPFamily: "ipv4"}, config.TransportConfig{})
+	if merged.IPFamily != "ipv4" {
+		t.Errorf("expected ipv4, got %q", merged.IPFamily)
+	}
+	merged = MergeTransportConfigs(merged, config.TransportConfig{IPFamily: "prefer_ipv6"})
+	if merged.IPFamily != "prefer_ipv6" {
+		t.Errorf("expected prefer_ipv6, got %q", merged.IPFamily)
+	}
+}
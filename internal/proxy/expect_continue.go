@@ -0,0 +1,28 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// expectContinueValue is the Expect header sent for generated expectations.
+var expectContinueValue = []string{"100-continue"}
+
+// expectsContinue reports whether the backend request for r should carry
+// Expect: 100-continue: the client sent it, or the route generates it for
+// bodies of at least threshold bytes.
+//
+// The body is left unread until the transport writes it, which happens once
+// the backend answers 100 Continue or expect_continue_timeout passes. The
+// server sends the client its own 100 Continue on that first read, so a
+// backend that rejects at the headers stage never causes the client body to
+// be sent.
+func expectsContinue(r *http.Request, threshold int64) bool {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return false
+	}
+	if strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+		return true
+	}
+	return threshold > 0 && r.ContentLength >= threshold
+}
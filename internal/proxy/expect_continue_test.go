@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/router"
+	"github.com/wudi/runway/variables"
+)
+
+// expectContinueBackend rejects requests without Authorization at the
+// headers stage and otherwise echoes the number of body bytes it read.
+func expectContinueBackend(t *testing.T, sawExpect *atomic.Value) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sawExpect.Store(r.Header.Get("Expect"))
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n, _ := io.Copy(io.Discard, r.Body)
+		fmt.Fprint(w, n)
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func newExpectContinueRunway(t *testing.T, route *router.Route, backendURL string) string {
+	t.Helper()
+	balancer := loadbalancer.NewRoundRobin([]*loadbalancer.Backend{
+		{URL: backendURL, Weight: 1, Healthy: true},
+	})
+	handler := New(Config{}).Handler(route, balancer)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		varCtx := variables.NewContext(r)
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx)))
+	}))
+	t.Cleanup(ts.Close)
+	return ts.Listener.Addr().String()
+}
+
+// sendExpectContinue writes the request headers only and returns the
+// connection with the first status line the runway answered.
+func sendExpectContinue(t *testing.T, addr, extraHeaders string) (net.Conn, *bufio.Reader, string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(conn, "POST /upload HTTP/1.1\r\nHost: runway\r\n%sContent-Length: 1048576\r\nExpect: 100-continue\r\n\r\n", extraHeaders)
+	br := bufio.NewReader(conn)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading status line: %v", err)
+	}
+	return conn, br, line
+}
+
+func TestProxyExpectContinue(t *testing.T) {
+	var sawExpect atomic.Value
+	backend := expectContinueBackend(t, &sawExpect)
+
+	routes := map[string]*router.Route{
+		"standard": {ID: "upload", Path: "/"},
+		"hedging": {ID: "upload", Path: "/", RetryPolicy: config.RetryConfig{
+			MaxRetries: 1,
+			Hedging:    config.HedgingConfig{Enabled: true, MaxRequests: 2, Delay: time.Second},
+		}},
+	}
+	for name, route := range routes {
+		t.Run(name+"/rejected at headers", func(t *testing.T) {
+			addr := newExpectContinueRunway(t, route, backend.URL)
+			_, br, line := sendExpectContinue(t, addr, "")
+			if !strings.HasPrefix(line, "HTTP/1.1 401") {
+				t.Fatalf("expected the backend's 401 before any body was sent, got %q", line)
+			}
+			if got := sawExpect.Load(); got != "100-continue" {
+				t.Errorf("expected Expect forwarded to the backend, got %v", got)
+			}
+			resp, err := http.ReadResponse(bufio.NewReader(io.MultiReader(strings.NewReader(line), br)), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !resp.Close {
+				t.Error("expected the connection closed since the body was never read")
+			}
+		})
+
+		t.Run(name+"/accepted", func(t *testing.T) {
+			addr := newExpectContinueRunway(t, route, backend.URL)
+			conn, br, line := sendExpectContinue(t, addr, "Authorization: Bearer t\r\n")
+			if !strings.HasPrefix(line, "HTTP/1.1 100 Continue") {
+				t.Fatalf("expected 100 Continue relayed from the backend, got %q", line)
+			}
+			br.ReadString('\n')
+			if _, err := io.CopyN(conn, strings.NewReader(strings.Repeat("x", 1<<20)), 1<<20); err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.ReadResponse(br, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK || string(body) != "1048576" {
+				t.Errorf("expected the body streamed through, got %d %q", resp.StatusCode, body)
+			}
+		})
+	}
+}
+
+func TestProxyExpectContinueThreshold(t *testing.T) {
+	var sawExpect atomic.Value
+	backend := expectContinueBackend(t, &sawExpect)
+	addr := newExpectContinueRunway(t, &router.Route{ID: "upload", Path: "/", ExpectContinueThreshold: 1024}, backend.URL)
+
+	for _, tt := range []struct {
+		size int
+		want string
+	}{
+		{2048, "100-continue"},
+		{512, ""},
+	} {
+		req, _ := http.NewRequest("POST", "http://"+addr+"/upload", strings.NewReader(strings.Repeat("x", tt.size)))
+		req.Header.Set("Authorization", "Bearer t")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != fmt.Sprint(tt.size) {
+			t.Errorf("size %d: expected the body forwarded, got %q", tt.size, body)
+		}
+		if got := sawExpect.Load(); got != tt.want {
+			t.Errorf("size %d: expected Expect %q at the backend, got %q", tt.size, tt.want, got)
+		}
+	}
+}
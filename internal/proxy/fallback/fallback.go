@@ -0,0 +1,277 @@
+// Package fallback proxies a request to plain HTTP backends when the route's
+// translator, lambda or amqp handler fails in a configured way, for example
+// while an endpoint migrates from a REST backend to gRPC. The handler's
+// response is held back only until its status is known, so a fallback never
+// follows response bytes already sent to the client.
+package fallback
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/variables"
+)
+
+// DefaultMaxBodySize is the largest request body kept for replay when
+// max_body_size is unset.
+const DefaultMaxBodySize = 1 << 20 // 1MiB
+
+// Outcomes of a triggered fallback, used as metric labels.
+const (
+	OutcomeServed        = "served"
+	OutcomeRateLimited   = "rate_limited"
+	OutcomeNotReplayable = "not_replayable"
+)
+
+// TriggerConnectionError labels fallbacks triggered by an unreachable
+// backend. Other triggers are labeled grpc_<code> and status_<code>.
+const TriggerConnectionError = "connection_error"
+
+// Fallback wraps a route's handler and replays failed requests to the
+// fallback target.
+type Fallback struct {
+	grpcCodes  map[string]string // Grpc-Status value -> trigger label
+	statuses   map[int]bool
+	connErrors bool
+	maxBody    int64
+	limiter    *rate.Limiter // nil when max_rate is unset
+	target     http.Handler
+	record     func(trigger, outcome string)
+
+	primary       atomic.Int64
+	served        atomic.Int64
+	rateLimited   atomic.Int64
+	notReplayable atomic.Int64
+
+	mu        sync.Mutex
+	byTrigger map[string]int64
+}
+
+// New creates a Fallback that replays requests to target. record, if not
+// nil, is called with the trigger and outcome of every triggered fallback.
+func New(cfg config.HandlerFallbackConfig, target http.Handler, record func(trigger, outcome string)) *Fallback {
+	f := &Fallback{
+		grpcCodes:  make(map[string]string, len(cfg.Triggers.GRPCCodes)),
+		statuses:   make(map[int]bool, len(cfg.Triggers.Statuses)),
+		connErrors: cfg.Triggers.ConnectionErrors,
+		maxBody:    cfg.MaxBodySize,
+		target:     target,
+		record:     record,
+		byTrigger:  make(map[string]int64),
+	}
+	for _, name := range cfg.Triggers.GRPCCodes {
+		var c codes.Code
+		if err := c.UnmarshalJSON([]byte(strconv.Quote(name))); err == nil {
+			f.grpcCodes[strconv.Itoa(int(c))] = "grpc_" + strings.ToLower(name)
+		}
+	}
+	for _, st := range cfg.Triggers.Statuses {
+		f.statuses[st] = true
+	}
+	if f.maxBody == 0 {
+		f.maxBody = DefaultMaxBodySize
+	}
+	if cfg.MaxRate > 0 {
+		f.limiter = rate.NewLimiter(rate.Limit(cfg.MaxRate), max(1, int(cfg.MaxRate)))
+	}
+	return f
+}
+
+// match returns the trigger label for a handler response, or "" when the
+// response is not a fallback trigger.
+func (f *Fallback) match(status int, h http.Header) string {
+	grpcStatus := h.Get("Grpc-Status")
+	if grpcStatus != "" {
+		if t, ok := f.grpcCodes[grpcStatus]; ok {
+			return t
+		}
+		if f.connErrors && grpcStatus == strconv.Itoa(int(codes.Unavailable)) {
+			return TriggerConnectionError
+		}
+	} else if f.connErrors && (status == http.StatusBadGateway || status == http.StatusServiceUnavailable) {
+		return TriggerConnectionError
+	}
+	if f.statuses[status] {
+		return "status_" + strconv.Itoa(status)
+	}
+	return ""
+}
+
+// allow reports whether a triggered fallback may run, recording why not.
+func (f *Fallback) allow(trigger string, replayable bool) bool {
+	switch {
+	case !replayable:
+		f.notReplayable.Add(1)
+		f.recordOutcome(trigger, OutcomeNotReplayable)
+		return false
+	case f.limiter != nil && !f.limiter.Allow():
+		f.rateLimited.Add(1)
+		f.recordOutcome(trigger, OutcomeRateLimited)
+		return false
+	}
+	return true
+}
+
+func (f *Fallback) recordOutcome(trigger, outcome string) {
+	if f.record != nil {
+		f.record(trigger, outcome)
+	}
+}
+
+// Middleware returns middleware that wraps the route's handler. Requests
+// whose handler response matches a trigger are replayed to the target
+// instead; the handler's headers and body are discarded.
+func (f *Fallback) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, replayable := f.bufferBody(r)
+			before := w.Header().Clone()
+			pw := &primaryWriter{w: w, f: f, replayable: replayable}
+			next.ServeHTTP(pw, r)
+			if pw.trigger == "" {
+				f.primary.Add(1)
+				return
+			}
+
+			// Drop the headers the handler set on its held-back response.
+			h := w.Header()
+			clear(h)
+			for k, vv := range before {
+				h[k] = vv
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			variables.GetFromRequest(r).FallbackTrigger = pw.trigger
+
+			f.served.Add(1)
+			f.mu.Lock()
+			f.byTrigger[pw.trigger]++
+			f.mu.Unlock()
+			f.recordOutcome(pw.trigger, OutcomeServed)
+			f.target.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bufferBody reads up to max_body_size of the request body so it can be
+// replayed, and reports whether the whole body fit. The handler always sees
+// the complete body.
+func (f *Fallback) bufferBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, f.maxBody+1))
+	if err != nil || int64(len(buf)) > f.maxBody {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+		return nil, false
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(buf))
+	return buf, true
+}
+
+// Stats returns primary and fallback counts.
+func (f *Fallback) Stats() map[string]any {
+	f.mu.Lock()
+	byTrigger := make(map[string]int64, len(f.byTrigger))
+	for t, n := range f.byTrigger {
+		byTrigger[t] = n
+	}
+	f.mu.Unlock()
+	return map[string]any{
+		"primary":                f.primary.Load(),
+		"fallback":               f.served.Load(),
+		"fallback_by_trigger":    byTrigger,
+		"skipped_rate_limited":   f.rateLimited.Load(),
+		"skipped_not_replayable": f.notReplayable.Load(),
+	}
+}
+
+// primaryWriter holds back the handler's response until its status shows
+// whether it is a fallback trigger. A triggering response is discarded.
+type primaryWriter struct {
+	w          http.ResponseWriter
+	f          *Fallback
+	replayable bool
+	written    bool
+	trigger    string // set when the response is discarded for a fallback
+}
+
+func (pw *primaryWriter) Header() http.Header {
+	return pw.w.Header()
+}
+
+func (pw *primaryWriter) WriteHeader(code int) {
+	if pw.written {
+		return
+	}
+	pw.written = true
+	if t := pw.f.match(code, pw.w.Header()); t != "" && pw.f.allow(t, pw.replayable) {
+		pw.trigger = t
+		return
+	}
+	pw.w.WriteHeader(code)
+}
+
+func (pw *primaryWriter) Write(b []byte) (int, error) {
+	if !pw.written {
+		pw.WriteHeader(http.StatusOK)
+	}
+	if pw.trigger != "" {
+		return len(b), nil
+	}
+	return pw.w.Write(b)
+}
+
+func (pw *primaryWriter) Flush() {
+	if pw.written && pw.trigger == "" {
+		http.NewResponseController(pw.w).Flush()
+	}
+}
+
+// FallbackByRoute manages per-route fallbacks.
+type FallbackByRoute struct {
+	byroute.Manager[*Fallback]
+}
+
+// NewFallbackByRoute creates a new per-route fallback manager.
+func NewFallbackByRoute() *FallbackByRoute {
+	return &FallbackByRoute{}
+}
+
+// AddRoute adds a fallback for a route.
+func (m *FallbackByRoute) AddRoute(routeID string, cfg config.HandlerFallbackConfig, target http.Handler, record func(trigger, outcome string)) {
+	m.Add(routeID, New(cfg, target, record))
+}
+
+// Stats returns per-route fallback stats.
+func (m *FallbackByRoute) Stats() map[string]any {
+	return byroute.CollectStats(&m.Manager, func(f *Fallback) any { return f.Stats() })
+}
+
+// ConfigFor returns the fallback config of the route's translator, lambda
+// or amqp handler, checked in the order the gateway picks the handler.
+func ConfigFor(route config.RouteConfig) config.HandlerFallbackConfig {
+	switch {
+	case route.Protocol.Type != "":
+		return route.Protocol.Fallback
+	case route.Lambda.Enabled:
+		return route.Lambda.Fallback
+	case route.AMQP.Enabled:
+		return route.AMQP.Fallback
+	}
+	return config.HandlerFallbackConfig{}
+}
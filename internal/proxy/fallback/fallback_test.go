@@ -0,0 +1,201 @@
+package fallback
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/loadbalancer"
+	grpctranslator "github.com/wudi/runway/internal/proxy/protocol/grpc"
+	"github.com/wudi/runway/variables"
+)
+
+// unimplementedHealth registers the health service without implementing
+// any of its methods, so every call returns UNIMPLEMENTED.
+type unimplementedHealth struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func startGRPCBackend(t *testing.T) string {
+	t.Helper()
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, unimplementedHealth{})
+	reflection.Register(s)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+	return addr
+}
+
+func translatorHandler(t *testing.T, addr string) http.Handler {
+	t.Helper()
+	tr := grpctranslator.New()
+	t.Cleanup(tr.CloseAll)
+	bal := loadbalancer.NewRoundRobin([]*loadbalancer.Backend{{URL: addr, Healthy: true}})
+	h, err := tr.Handler("migrate", bal, config.ProtocolConfig{Type: "http_to_grpc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// legacyREST stands in for the REST backends, echoing the replayed body.
+func legacyREST() (http.Handler, *int) {
+	var calls int
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Backend", "legacy")
+		w.Write([]byte(`{"legacy":true,"request":` + string(body) + `}`))
+	}), &calls
+}
+
+func serve(h http.Handler, body string) (*httptest.ResponseRecorder, *variables.Context) {
+	r := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", strings.NewReader(body))
+	varCtx := variables.NewContext(r)
+	r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w, varCtx
+}
+
+func TestFallback_Unimplemented(t *testing.T) {
+	target, calls := legacyREST()
+	var recorded []string
+	f := New(config.HandlerFallbackConfig{
+		Triggers: config.FallbackTriggerConfig{GRPCCodes: []string{"UNIMPLEMENTED"}},
+	}, target, func(trigger, outcome string) { recorded = append(recorded, trigger+"/"+outcome) })
+	h := f.Middleware()(translatorHandler(t, startGRPCBackend(t)))
+
+	w, varCtx := serve(h, `{"service":"orders"}`)
+	if w.Code != http.StatusOK || *calls != 1 {
+		t.Fatalf("expected the legacy backend to answer, got %d after %d calls: %s", w.Code, *calls, w.Body)
+	}
+	if got := w.Body.String(); got != `{"legacy":true,"request":{"service":"orders"}}` {
+		t.Errorf("expected the original body replayed, got %s", got)
+	}
+	if w.Header().Get("Grpc-Status") != "" || w.Header().Get("X-Backend") != "legacy" {
+		t.Errorf("expected only the fallback's headers, got %v", w.Header())
+	}
+	if varCtx.FallbackTrigger != "grpc_unimplemented" {
+		t.Errorf("expected the access log trigger grpc_unimplemented, got %q", varCtx.FallbackTrigger)
+	}
+	if len(recorded) != 1 || recorded[0] != "grpc_unimplemented/served" {
+		t.Errorf("unexpected recorded outcomes %v", recorded)
+	}
+	stats := f.Stats()
+	if stats["fallback"] != int64(1) || stats["primary"] != int64(0) {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
+
+func TestFallback_DialFailure(t *testing.T) {
+	target, calls := legacyREST()
+	cfg := config.HandlerFallbackConfig{Triggers: config.FallbackTriggerConfig{ConnectionErrors: true}}
+	f := New(cfg, target, nil)
+	h := f.Middleware()(translatorHandler(t, closedAddr(t)))
+
+	w, varCtx := serve(h, `{}`)
+	if w.Code != http.StatusOK || *calls != 1 {
+		t.Fatalf("expected the legacy backend to answer an unreachable gRPC backend, got %d: %s", w.Code, w.Body)
+	}
+	if varCtx.FallbackTrigger != TriggerConnectionError {
+		t.Errorf("expected trigger %s, got %q", TriggerConnectionError, varCtx.FallbackTrigger)
+	}
+
+	// Without the trigger the translator's error reaches the client.
+	plain := New(config.HandlerFallbackConfig{Triggers: config.FallbackTriggerConfig{GRPCCodes: []string{"UNIMPLEMENTED"}}}, target, nil)
+	w, _ = serve(plain.Middleware()(translatorHandler(t, closedAddr(t))), `{}`)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Grpc-Status") != "14" {
+		t.Errorf("expected the translator's 503 UNAVAILABLE, got %d %v", w.Code, w.Header())
+	}
+	if plain.Stats()["primary"] != int64(1) {
+		t.Errorf("expected a primary response, got %v", plain.Stats())
+	}
+}
+
+func TestFallback_RateLimited(t *testing.T) {
+	target, calls := legacyREST()
+	var recorded []string
+	f := New(config.HandlerFallbackConfig{
+		Triggers: config.FallbackTriggerConfig{Statuses: []int{http.StatusNotImplemented}},
+		MaxRate:  1,
+	}, target, func(trigger, outcome string) { recorded = append(recorded, outcome) })
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+		w.Write([]byte("primary"))
+	})
+	h := f.Middleware()(failing)
+
+	serve(h, `{}`)
+	w, _ := serve(h, `{}`)
+	if *calls != 1 || w.Code != http.StatusNotImplemented || w.Body.String() != "primary" {
+		t.Fatalf("expected the second failure to pass through once over max_rate, got %d %q after %d calls", w.Code, w.Body, *calls)
+	}
+	if strings.Join(recorded, ",") != "served,rate_limited" {
+		t.Errorf("unexpected outcomes %v", recorded)
+	}
+}
+
+func TestFallback_BodyTooLarge(t *testing.T) {
+	target, calls := legacyREST()
+	f := New(config.HandlerFallbackConfig{
+		Triggers:    config.FallbackTriggerConfig{Statuses: []int{http.StatusNotImplemented}},
+		MaxBodySize: 4,
+	}, target, nil)
+	var seen string
+	h := f.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		seen = string(b)
+		w.WriteHeader(http.StatusNotImplemented)
+	}))
+
+	w, _ := serve(h, `{"large":true}`)
+	if seen != `{"large":true}` {
+		t.Errorf("expected the handler to read the whole body, got %q", seen)
+	}
+	if *calls != 0 || w.Code != http.StatusNotImplemented {
+		t.Errorf("expected no fallback for a body that cannot be replayed, got %d after %d calls", w.Code, *calls)
+	}
+	if f.Stats()["skipped_not_replayable"] != int64(1) {
+		t.Errorf("unexpected stats %v", f.Stats())
+	}
+}
+
+func TestFallback_AfterBodyStarted(t *testing.T) {
+	target, calls := legacyREST()
+	f := New(config.HandlerFallbackConfig{Triggers: config.FallbackTriggerConfig{ConnectionErrors: true}}, target, nil)
+	h := f.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.WriteHeader(http.StatusBadGateway) // too late, the status was sent
+	}))
+
+	w, _ := serve(h, `{}`)
+	if *calls != 0 || w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("expected no fallback once bytes were sent, got %d %q", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,185 @@
+// Package headerprop copies selected headers from the sub-responses of
+// sequential and aggregate routes to the final client response.
+package headerprop
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/wudi/runway/config"
+)
+
+const (
+	// DefaultMaxHeaders caps the number of propagated headers.
+	DefaultMaxHeaders = 32
+	// DefaultMaxBytes caps the total name+value size of propagated headers.
+	DefaultMaxBytes = 8192
+)
+
+// excluded headers are never propagated: hop-by-hop headers, and the
+// framing headers the gateway sets for the merged body itself.
+var excluded = map[string]bool{
+	"Connection":          true,
+	"Proxy-Connection":    true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Content-Length":      true,
+}
+
+// IsExcluded reports whether name can never be propagated.
+func IsExcluded(name string) bool {
+	return excluded[http.CanonicalHeaderKey(name)]
+}
+
+// Rule selects the headers of one sub-response.
+type Rule struct {
+	exact     map[string]bool
+	prefixes  []string
+	rename    map[string]string
+	conflict  string
+	setCookie bool
+}
+
+// NewRule compiles cfg, or returns nil when it lists no headers.
+func NewRule(cfg config.PropagateHeadersConfig) *Rule {
+	if len(cfg.Headers) == 0 {
+		return nil
+	}
+	r := &Rule{
+		exact:     make(map[string]bool, len(cfg.Headers)),
+		conflict:  cfg.Conflict,
+		setCookie: cfg.AllowSetCookie,
+	}
+	if r.conflict == "" {
+		r.conflict = "first"
+	}
+	for _, h := range cfg.Headers {
+		if p, ok := strings.CutSuffix(h, "*"); ok {
+			r.prefixes = append(r.prefixes, http.CanonicalHeaderKey(p))
+		} else {
+			r.exact[http.CanonicalHeaderKey(h)] = true
+		}
+	}
+	if len(cfg.Rename) > 0 {
+		r.rename = make(map[string]string, len(cfg.Rename))
+		for from, to := range cfg.Rename {
+			r.rename[http.CanonicalHeaderKey(from)] = http.CanonicalHeaderKey(to)
+		}
+	}
+	return r
+}
+
+func (r *Rule) matches(name string) bool {
+	if excluded[name] || (name == "Set-Cookie" && !r.setCookie) {
+		return false
+	}
+	if r.exact[name] {
+		return true
+	}
+	for _, p := range r.prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Set accumulates propagated headers across sub-responses. Sources must be
+// collected in a fixed order (step or backend order) so that conflict
+// resolution does not depend on response timing.
+type Set struct {
+	header    http.Header
+	conflicts int
+}
+
+// Collect adds the headers of src selected by rule. When a header was
+// already collected from an earlier source, the rule's conflict policy
+// decides the outcome: keep the first value, replace it with the last, or
+// join both. Each such collision counts as one resolved conflict.
+func (s *Set) Collect(rule *Rule, src http.Header) {
+	if rule == nil {
+		return
+	}
+	for name, values := range src {
+		if !rule.matches(name) || len(values) == 0 {
+			continue
+		}
+		dst := name
+		if to, ok := rule.rename[name]; ok {
+			dst = to
+		}
+		if excluded[dst] {
+			continue
+		}
+		if s.header == nil {
+			s.header = make(http.Header)
+		}
+		prev, exists := s.header[dst]
+		if !exists {
+			s.header[dst] = append([]string(nil), values...)
+			continue
+		}
+		s.conflicts++
+		switch rule.conflict {
+		case "last":
+			s.header[dst] = append([]string(nil), values...)
+		case "join":
+			if dst == "Set-Cookie" {
+				s.header[dst] = append(prev, values...)
+			} else {
+				s.header[dst] = []string{strings.Join(append(append([]string(nil), prev...), values...), ", ")}
+			}
+		}
+	}
+}
+
+// Result reports what Apply did.
+type Result struct {
+	Propagated int // headers written to the response
+	Conflicts  int // collisions resolved while collecting
+	Dropped    int // headers left out because of the caps
+}
+
+// Apply sets the collected headers on dst, replacing values already there.
+// Headers are applied in name order until maxHeaders or maxBytes (name plus
+// values) would be exceeded; the rest are dropped. Zero limits use the
+// defaults.
+func (s *Set) Apply(dst http.Header, maxHeaders, maxBytes int) Result {
+	res := Result{Conflicts: s.conflicts}
+	if len(s.header) == 0 {
+		return res
+	}
+	if maxHeaders <= 0 {
+		maxHeaders = DefaultMaxHeaders
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	names := make([]string, 0, len(s.header))
+	for name := range s.header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	size := 0
+	for _, name := range names {
+		n := len(name)
+		for _, v := range s.header[name] {
+			n += len(v)
+		}
+		if res.Propagated >= maxHeaders || size+n > maxBytes {
+			res.Dropped++
+			continue
+		}
+		size += n
+		dst[name] = s.header[name]
+		res.Propagated++
+	}
+	return res
+}
@@ -0,0 +1,83 @@
+package headerprop
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+func TestNewRuleEmpty(t *testing.T) {
+	if NewRule(config.PropagateHeadersConfig{}) != nil {
+		t.Error("expected nil rule without headers")
+	}
+}
+
+func TestCollectConflicts(t *testing.T) {
+	a := http.Header{"X-Trace": {"a"}, "Set-Cookie": {"a=1"}}
+	b := http.Header{"X-Trace": {"b"}, "Set-Cookie": {"b=2"}}
+
+	tests := []struct {
+		conflict string
+		trace    []string
+		cookies  []string
+	}{
+		{"first", []string{"a"}, []string{"a=1"}},
+		{"last", []string{"b"}, []string{"b=2"}},
+		{"join", []string{"a, b"}, []string{"a=1", "b=2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.conflict, func(t *testing.T) {
+			rule := NewRule(config.PropagateHeadersConfig{
+				Headers:        []string{"x-trace", "Set-Cookie"},
+				Conflict:       tt.conflict,
+				AllowSetCookie: true,
+			})
+			var s Set
+			s.Collect(rule, a)
+			s.Collect(rule, b)
+			dst := http.Header{}
+			res := s.Apply(dst, 0, 0)
+			if res.Conflicts != 2 || res.Propagated != 2 {
+				t.Errorf("unexpected result %+v", res)
+			}
+			if got := dst.Values("X-Trace"); len(got) != len(tt.trace) || got[0] != tt.trace[0] {
+				t.Errorf("expected X-Trace %v, got %v", tt.trace, got)
+			}
+			if got := dst.Values("Set-Cookie"); len(got) != len(tt.cookies) {
+				t.Errorf("expected Set-Cookie %v, got %v", tt.cookies, got)
+			}
+		})
+	}
+}
+
+func TestCollectExclusions(t *testing.T) {
+	rule := NewRule(config.PropagateHeadersConfig{
+		Headers: []string{"*"},
+		Rename:  map[string]string{"X-Len": "Content-Length"},
+	})
+	var s Set
+	s.Collect(rule, http.Header{
+		"Connection":        {"close"},
+		"Transfer-Encoding": {"chunked"},
+		"Content-Length":    {"10"},
+		"Set-Cookie":        {"a=1"},
+		"X-Len":             {"3"},
+		"X-Ok":              {"yes"},
+	})
+	dst := http.Header{}
+	if res := s.Apply(dst, 0, 0); res.Propagated != 1 || dst.Get("X-Ok") != "yes" {
+		t.Errorf("expected only X-Ok to be propagated, got %v (%+v)", dst, res)
+	}
+}
+
+func TestApplyByteCap(t *testing.T) {
+	rule := NewRule(config.PropagateHeadersConfig{Headers: []string{"X-*"}})
+	var s Set
+	s.Collect(rule, http.Header{"X-A": {"1234567890"}, "X-B": {"1"}})
+	dst := http.Header{}
+	res := s.Apply(dst, 0, 10)
+	if res.Propagated != 1 || res.Dropped != 1 || dst.Get("X-B") != "1" {
+		t.Errorf("expected X-A to exceed the byte cap, got %v (%+v)", dst, res)
+	}
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// informationalForwarder forwards backend 1xx responses (e.g. 103 Early
+// Hints) to the client while the proxy waits for the final response. It
+// writes to the outermost client writer so response middleware never sees
+// an interim status as the final one.
+type informationalForwarder struct {
+	mu   sync.Mutex
+	w    http.ResponseWriter
+	done bool
+}
+
+// trace returns a ClientTrace that forwards 1xx responses to the client.
+func (f *informationalForwarder) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{Got1xxResponse: f.got1xx}
+}
+
+func (f *informationalForwarder) got1xx(code int, header textproto.MIMEHeader) error {
+	// 100 Continue is negotiated by the server itself and 101 is final.
+	if code == http.StatusContinue || code == http.StatusSwitchingProtocols {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.done {
+		return nil
+	}
+
+	// The client header map may already hold headers set for the final
+	// response; send only the interim headers and restore the rest.
+	h := f.w.Header()
+	saved := h.Clone()
+	clear(h)
+	for k, vv := range header {
+		h[k] = vv
+	}
+	f.w.WriteHeader(code)
+	clear(h)
+	for k, vv := range saved {
+		h[k] = vv
+	}
+	return nil
+}
+
+// finish stops forwarding; late 1xx responses after the round trip returns
+// must not touch the client writer.
+func (f *informationalForwarder) finish() {
+	f.mu.Lock()
+	f.done = true
+	f.mu.Unlock()
+}
+
+// announceTrailers declares the backend's trailers on the client response so
+// they are sent after the body.
+func announceTrailers(dst http.Header, trailer http.Header) {
+	keys := make([]string, 0, len(trailer))
+	for k := range trailer {
+		keys = append(keys, k)
+	}
+	dst.Add("Trailer", strings.Join(keys, ", "))
+}
+
+// copyTrailers sets the backend's trailer values on w after the body has
+// been copied. Trailers the backend did not announce up front use
+// http.TrailerPrefix.
+func copyTrailers(w http.ResponseWriter, trailer http.Header, announced int) {
+	h := w.Header()
+	if len(trailer) == announced {
+		for k, vv := range trailer {
+			h[k] = vv
+		}
+		return
+	}
+	for k, vv := range trailer {
+		h[http.TrailerPrefix+k] = vv
+	}
+}
@@ -0,0 +1,206 @@
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	maxInFlight      = 256      // pipelined requests per broker connection
+	maxResponseBytes = 64 << 20 // larger frames mean a desynchronized stream
+)
+
+var errConnClosed = errors.New("kafka: connection closed")
+
+// dialer opens authenticated broker connections.
+type dialer struct {
+	clientID string
+	timeout  time.Duration // connect timeout
+	tls      *tls.Config   // nil for plaintext
+	sasl     *saslConfig   // nil without SASL
+}
+
+// conn is a connection to one broker. Requests are pipelined: writes are
+// serialized and a reader goroutine hands responses, which Kafka returns in
+// request order, to the callers waiting for them.
+type conn struct {
+	nc       net.Conn
+	clientID string
+
+	wmu     sync.Mutex // serializes writes and the order of pending
+	corr    int32
+	pending chan *call
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	err       error // why the connection closed; set before closed is closed
+}
+
+type call struct {
+	corr int32
+	done chan result
+}
+
+type result struct {
+	body []byte
+	err  error
+}
+
+func (d *dialer) dial(ctx context.Context, addr string) (*conn, error) {
+	nd := &net.Dialer{Timeout: d.timeout, KeepAlive: 30 * time.Second}
+	var nc net.Conn
+	var err error
+	if d.tls != nil {
+		td := &tls.Dialer{NetDialer: nd, Config: d.tls}
+		nc, err = td.DialContext(ctx, "tcp", addr)
+	} else {
+		nc, err = nd.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("kafka: dial %s: %w", addr, err)
+	}
+	c := &conn{
+		nc:       nc,
+		clientID: d.clientID,
+		pending:  make(chan *call, maxInFlight),
+		closed:   make(chan struct{}),
+	}
+	go c.readLoop()
+	if d.sasl != nil {
+		if err := d.sasl.authenticate(ctx, c); err != nil {
+			c.close(err)
+			return nil, fmt.Errorf("kafka: authenticate to %s: %w", addr, err)
+		}
+	}
+	return c, nil
+}
+
+// roundTrip sends a request and, when expectResponse is set, waits for its
+// response body. Produce requests with acks=0 get no response.
+func (c *conn) roundTrip(ctx context.Context, apiKey, version int16, body []byte, expectResponse bool) ([]byte, error) {
+	cl := &call{done: make(chan result, 1)}
+
+	c.wmu.Lock()
+	c.corr++
+	cl.corr = c.corr
+	var frame encoder
+	frame.int32(0) // size, patched below
+	frame.int16(apiKey)
+	frame.int16(version)
+	frame.int32(cl.corr)
+	frame.string(c.clientID)
+	frame.b = append(frame.b, body...)
+	binary.BigEndian.PutUint32(frame.b, uint32(len(frame.b)-4))
+
+	if expectResponse {
+		select {
+		case c.pending <- cl:
+		case <-c.closed:
+			c.wmu.Unlock()
+			return nil, c.err
+		case <-ctx.Done():
+			c.wmu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.nc.SetWriteDeadline(deadline)
+	}
+	_, err := c.nc.Write(frame.b)
+	c.wmu.Unlock()
+	if err != nil {
+		c.close(err)
+		return nil, err
+	}
+	if !expectResponse {
+		return nil, nil
+	}
+
+	select {
+	case res := <-cl.done:
+		return res.body, res.err
+	case <-c.closed:
+		select {
+		case res := <-cl.done:
+			return res.body, res.err
+		default:
+			return nil, c.err
+		}
+	case <-ctx.Done():
+		// The response is still owed on this connection. Drop it rather
+		// than let calls pile up behind a stuck broker.
+		c.close(ctx.Err())
+		return nil, ctx.Err()
+	}
+}
+
+func (c *conn) readLoop() {
+	var hdr [8]byte
+	for {
+		if _, err := io.ReadFull(c.nc, hdr[:]); err != nil {
+			c.close(err)
+			break
+		}
+		size := int32(binary.BigEndian.Uint32(hdr[:4]))
+		corr := int32(binary.BigEndian.Uint32(hdr[4:]))
+		if size < 4 || size > maxResponseBytes {
+			c.close(errShortResponse)
+			break
+		}
+		body := make([]byte, size-4)
+		if _, err := io.ReadFull(c.nc, body); err != nil {
+			c.close(err)
+			break
+		}
+		var cl *call
+		select {
+		case cl = <-c.pending:
+		default:
+		}
+		if cl == nil || cl.corr != corr {
+			c.close(fmt.Errorf("kafka: unexpected response %d", corr))
+			if cl != nil {
+				cl.done <- result{err: c.err}
+			}
+			break
+		}
+		cl.done <- result{body: body}
+	}
+
+	// Fail the calls still waiting.
+	for {
+		select {
+		case cl := <-c.pending:
+			cl.done <- result{err: c.err}
+		default:
+			return
+		}
+	}
+}
+
+func (c *conn) close(err error) {
+	c.closeOnce.Do(func() {
+		if err == nil {
+			err = errConnClosed
+		}
+		c.err = err
+		close(c.closed)
+		c.nc.Close()
+	})
+}
+
+func (c *conn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,254 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/tmplutil"
+	"github.com/wudi/runway/variables"
+)
+
+const (
+	defaultTimeout  = 10 * time.Second
+	defaultClientID = "runway"
+)
+
+// Ack modes.
+var acksModes = map[string]int16{
+	"none":   0,
+	"leader": 1,
+	"all":    -1,
+}
+
+// KeyContext is the template context of the partition key template.
+type KeyContext struct {
+	Request struct {
+		Method     string
+		Host       string
+		Path       string
+		PathParams map[string]string
+		Query      url.Values
+		Headers    http.Header
+	}
+	ClientID string                 // authenticated client, if any
+	Claims   map[string]interface{} // JWT claims of the authenticated client
+}
+
+// Handler produces POST bodies to a Kafka topic as an HTTP backend.
+// Brokers are dialed on first use, so the route starts while Kafka is down.
+type Handler struct {
+	brokers []string
+	topic   string
+	acks    string
+	keyTmpl *template.Template
+	headers []string
+	timeout time.Duration
+	p       *producer
+
+	totalRequests atomic.Int64
+	produced      atomic.Int64
+	totalErrors   atomic.Int64
+	bytesProduced atomic.Int64
+}
+
+// New creates a Kafka handler from config.
+func New(cfg config.KafkaConfig) (*Handler, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka: brokers is required")
+	}
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf("kafka: topic is required")
+	}
+	acks := cfg.Acks
+	if acks == "" {
+		acks = "all"
+	}
+	ackCode, ok := acksModes[acks]
+	if !ok {
+		return nil, fmt.Errorf("kafka: unknown acks %q", cfg.Acks)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = defaultClientID
+	}
+
+	h := &Handler{
+		brokers: cfg.Brokers,
+		topic:   cfg.Topic,
+		acks:    acks,
+		headers: cfg.Headers,
+		timeout: timeout,
+	}
+	if cfg.Key != "" {
+		t, err := template.New("kafka_key").Funcs(tmplutil.FuncMap()).Parse(cfg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("kafka: invalid key template: %w", err)
+		}
+		h.keyTmpl = t
+	}
+
+	d := &dialer{clientID: clientID, timeout: timeout}
+	if cfg.TLS.Enabled {
+		tc, err := buildTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		d.tls = tc
+	}
+	if cfg.SASL.Mechanism != "" {
+		d.sasl = &saslConfig{mechanism: cfg.SASL.Mechanism, username: cfg.SASL.Username, password: cfg.SASL.Password}
+	}
+	h.p = &producer{
+		bootstrap: cfg.Brokers,
+		topic:     cfg.Topic,
+		acks:      ackCode,
+		timeout:   timeout,
+		dialer:    d,
+	}
+	return h, nil
+}
+
+func buildTLSConfig(cfg config.KafkaTLSConfig) (*tls.Config, error) {
+	tc := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.SkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafka: read tls.ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("kafka: tls.ca_file %s contains no certificates", cfg.CAFile)
+		}
+		tc.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafka: load client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.totalRequests.Add(1)
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "kafka: method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.totalErrors.Add(1)
+		http.Error(w, "kafka: read body failed", http.StatusBadGateway)
+		return
+	}
+	msg := Message{Value: body}
+	if h.keyTmpl != nil {
+		key, err := h.renderKey(r)
+		if err != nil {
+			h.totalErrors.Add(1)
+			http.Error(w, "kafka: key template error", http.StatusBadGateway)
+			return
+		}
+		if key != "" {
+			msg.Key = []byte(key)
+		}
+	}
+	for _, name := range h.headers {
+		for _, v := range r.Header.Values(name) {
+			msg.Headers = append(msg.Headers, Header{Key: name, Value: []byte(v)})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+	partition, offset, err := h.p.produce(ctx, msg)
+	if err != nil {
+		h.totalErrors.Add(1)
+		http.Error(w, "kafka: produce failed", http.StatusBadGateway)
+		return
+	}
+
+	h.produced.Add(1)
+	h.bytesProduced.Add(int64(len(body)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "produced",
+		"topic":     h.topic,
+		"partition": partition,
+		"offset":    offset,
+	})
+}
+
+// renderKey executes the key template. An empty key leaves the message
+// unkeyed.
+func (h *Handler) renderKey(r *http.Request) (string, error) {
+	kc := &KeyContext{}
+	kc.Request.Method = r.Method
+	kc.Request.Host = r.Host
+	kc.Request.Path = r.URL.Path
+	kc.Request.Query = r.URL.Query()
+	kc.Request.Headers = r.Header
+	varCtx := variables.GetFromRequest(r)
+	kc.Request.PathParams = varCtx.PathParams
+	if id := varCtx.Identity; id != nil {
+		kc.ClientID = id.ClientID
+		kc.Claims = id.Claims
+	}
+	var buf bytes.Buffer
+	if err := h.keyTmpl.Execute(&buf, kc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Close closes the broker connections.
+func (h *Handler) Close() {
+	h.p.close()
+}
+
+// Stats returns handler stats.
+func (h *Handler) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"brokers":            h.brokers,
+		"topic":              h.topic,
+		"acks":               h.acks,
+		"total_requests":     h.totalRequests.Load(),
+		"produced":           h.produced.Load(),
+		"total_errors":       h.totalErrors.Load(),
+		"bytes_produced":     h.bytesProduced.Load(),
+		"metadata_refreshes": h.p.metadataRefreshes.Load(),
+	}
+}
+
+// KafkaByRoute manages per-route Kafka handlers.
+type KafkaByRoute = byroute.Factory[*Handler, config.KafkaConfig]
+
+func NewKafkaByRoute() *KafkaByRoute {
+	return byroute.NewFactory(New, func(h *Handler) any { return h.Stats() }).WithClose((*Handler).Close)
+}
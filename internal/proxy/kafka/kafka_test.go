@@ -0,0 +1,551 @@
+package kafka
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
+)
+
+type fakeRecord struct {
+	partition int32
+	key       []byte
+	value     []byte
+	headers   []Header
+}
+
+// fakeBroker is a single-node Kafka cluster speaking the requests the
+// producer sends.
+type fakeBroker struct {
+	t          *testing.T
+	ln         net.Listener
+	topic      string
+	partitions int
+
+	// SASL; empty mechanism disables authentication.
+	mechanism string
+	username  string
+	password  string
+
+	mu          sync.Mutex
+	records     []fakeRecord
+	offsets     map[int32]int64
+	failProduce []Error // returned by successive produce requests
+	metadataReq int
+	acks        []int16
+}
+
+func newFakeBroker(t *testing.T, topic string, partitions int) *fakeBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{t: t, ln: ln, topic: topic, partitions: partitions, offsets: make(map[int32]int64)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(c)
+		}
+	}()
+	return b
+}
+
+func (b *fakeBroker) addr() string { return b.ln.Addr().String() }
+
+func (b *fakeBroker) serve(c net.Conn) {
+	defer c.Close()
+	var scram *scramServer
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(c, size[:]); err != nil {
+			return
+		}
+		frame := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(c, frame); err != nil {
+			return
+		}
+		d := &decoder{b: frame}
+		apiKey, _, corr := d.int16(), d.int16(), d.int32()
+		d.string() // client id
+
+		var resp *encoder
+		switch apiKey {
+		case apiMetadata:
+			resp = b.metadata(d)
+		case apiProduce:
+			resp = b.produce(d)
+		case apiSaslHandshake:
+			resp = &encoder{}
+			if d.string() != b.mechanism {
+				resp.int16(33)
+			} else {
+				resp.int16(0)
+			}
+			resp.int32(1)
+			resp.string(b.mechanism)
+		case apiSaslAuthenticate:
+			msg := d.bytes()
+			var reply []byte
+			ok := true
+			if b.mechanism == MechanismPlain {
+				ok = string(msg) == "\x00"+b.username+"\x00"+b.password
+			} else {
+				if scram == nil {
+					scram = &scramServer{username: b.username, password: b.password}
+				}
+				reply, ok = scram.step(string(msg))
+			}
+			resp = &encoder{}
+			if ok {
+				resp.int16(0)
+				resp.int16(-1)
+			} else {
+				resp.int16(58)
+				resp.string("authentication failed")
+			}
+			resp.bytes(reply)
+		default:
+			b.t.Errorf("unexpected api key %d", apiKey)
+			return
+		}
+		if resp == nil {
+			continue // acks=0
+		}
+		var out encoder
+		out.int32(int32(4 + len(resp.b)))
+		out.int32(corr)
+		out.b = append(out.b, resp.b...)
+		if _, err := c.Write(out.b); err != nil {
+			return
+		}
+	}
+}
+
+func (b *fakeBroker) metadata(d *decoder) *encoder {
+	var topics []string
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		topics = append(topics, d.string())
+	}
+	b.mu.Lock()
+	b.metadataReq++
+	b.mu.Unlock()
+
+	host, portStr, _ := net.SplitHostPort(b.addr())
+	port, _ := strconv.Atoi(portStr)
+	var e encoder
+	e.int32(1)
+	e.int32(1) // node id
+	e.string(host)
+	e.int32(int32(port))
+	e.int16(-1) // rack
+	e.int32(1)  // controller
+	e.int32(int32(len(topics)))
+	for _, topic := range topics {
+		if topic != b.topic {
+			e.int16(3)
+			e.string(topic)
+			e.int8(0)
+			e.int32(0)
+			continue
+		}
+		e.int16(0)
+		e.string(topic)
+		e.int8(0)
+		e.int32(int32(b.partitions))
+		for p := 0; p < b.partitions; p++ {
+			e.int16(0)
+			e.int32(int32(p))
+			e.int32(1) // leader
+			e.int32(1)
+			e.int32(1) // replicas
+			e.int32(1)
+			e.int32(1) // isr
+		}
+	}
+	return &e
+}
+
+func (b *fakeBroker) produce(d *decoder) *encoder {
+	d.int16() // transactional id
+	acks := d.int16()
+	d.int32() // timeout
+	d.arrayLen()
+	topic := d.string()
+	d.arrayLen()
+	partition := d.int32()
+	rec := b.decodeBatch(d.bytes())
+	if d.err != nil {
+		b.t.Errorf("malformed produce request: %v", d.err)
+	}
+	rec.partition = partition
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.acks = append(b.acks, acks)
+	code := Error(0)
+	if len(b.failProduce) > 0 {
+		code, b.failProduce = b.failProduce[0], b.failProduce[1:]
+	}
+	offset := int64(-1)
+	if code == 0 {
+		b.records = append(b.records, rec)
+		offset = b.offsets[partition]
+		b.offsets[partition]++
+	}
+	if acks == 0 {
+		return nil
+	}
+	var e encoder
+	e.int32(1)
+	e.string(topic)
+	e.int32(1)
+	e.int32(partition)
+	e.int16(int16(code))
+	e.int64(offset)
+	e.int64(-1)
+	e.int32(0) // throttle
+	return &e
+}
+
+// decodeBatch checks a single-record v2 batch and returns its record.
+func (b *fakeBroker) decodeBatch(batch []byte) fakeRecord {
+	d := &decoder{b: batch}
+	d.int64() // base offset
+	if n := d.int32(); int(n) != len(d.b) {
+		b.t.Errorf("batch length %d, have %d bytes", n, len(d.b))
+	}
+	d.int32() // leader epoch
+	if magic := d.int8(); magic != 2 {
+		b.t.Errorf("magic %d", magic)
+	}
+	crc := uint32(d.int32())
+	if got := crc32.Checksum(d.b, crc32.MakeTable(crc32.Castagnoli)); got != crc {
+		b.t.Errorf("batch crc %x, computed %x", crc, got)
+	}
+	d.int16()
+	d.int32()
+	d.int64()
+	d.int64()
+	d.int64()
+	d.int16()
+	d.int32()
+	if n := d.int32(); n != 1 {
+		b.t.Errorf("record count %d", n)
+	}
+	d.varint() // record length
+	d.int8()
+	d.varint()
+	d.varint()
+	rec := fakeRecord{key: d.varBytes(), value: d.varBytes()}
+	for i, n := 0, int(d.varint()); i < n; i++ {
+		rec.headers = append(rec.headers, Header{Key: string(d.varBytes()), Value: d.varBytes()})
+	}
+	if d.err != nil || len(d.b) != 0 {
+		b.t.Errorf("malformed record: %v, %d trailing bytes", d.err, len(d.b))
+	}
+	return rec
+}
+
+func (b *fakeBroker) snapshot() ([]fakeRecord, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]fakeRecord(nil), b.records...), b.metadataReq
+}
+
+// scramServer is the broker side of a SCRAM-SHA-256 exchange.
+type scramServer struct {
+	username, password string
+	clientFirstBare    string
+	serverFirst        string
+	salted             []byte
+}
+
+func (s *scramServer) step(msg string) ([]byte, bool) {
+	if s.serverFirst == "" {
+		s.clientFirstBare = strings.TrimPrefix(msg, "n,,")
+		attrs := scramAttrs(s.clientFirstBare)
+		if strings.NewReplacer("=2C", ",", "=3D", "=").Replace(attrs["n"]) != s.username {
+			return nil, false
+		}
+		salt := []byte("fake-broker-salt")
+		s.salted, _ = pbkdf2.Key(sha256.New, s.password, salt, 4096, sha256.Size)
+		s.serverFirst = "r=" + attrs["r"] + "server,s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+		return []byte(s.serverFirst), true
+	}
+	withoutProof, proof64, _ := strings.Cut(msg, ",p=")
+	proof, _ := base64.StdEncoding.DecodeString(proof64)
+	authMessage := []byte(s.clientFirstBare + "," + s.serverFirst + "," + withoutProof)
+	clientKey := hmacSum(sha256.New, s.salted, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	signature := hmacSum(sha256.New, storedKey[:], authMessage)
+	if len(proof) != len(signature) {
+		return nil, false
+	}
+	recovered := make([]byte, len(proof))
+	subtle.XORBytes(recovered, proof, signature)
+	if sha256.Sum256(recovered) != storedKey {
+		return nil, false
+	}
+	serverKey := hmacSum(sha256.New, s.salted, []byte("Server Key"))
+	return []byte("v=" + base64.StdEncoding.EncodeToString(hmacSum(sha256.New, serverKey, authMessage))), true
+}
+
+type produceResponse struct {
+	Status    string `json:"status"`
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+func post(t *testing.T, h http.Handler, body string, configure func(*http.Request, *variables.Context)) (*httptest.ResponseRecorder, produceResponse) {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+	varCtx := variables.NewContext(r)
+	if configure != nil {
+		configure(r, varCtx)
+	}
+	r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var resp produceResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp
+}
+
+func newHandler(t *testing.T, cfg config.KafkaConfig) *Handler {
+	t.Helper()
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Close)
+	return h
+}
+
+func TestHandlerProduces(t *testing.T) {
+	b := newFakeBroker(t, "orders", 8)
+	h := newHandler(t, config.KafkaConfig{
+		Brokers: []string{b.addr()},
+		Topic:   "orders",
+		Key:     `{{.Request.PathParams.tenant}}-{{.Claims.sub}}`,
+		Headers: []string{"X-Trace-Id", "X-Missing"},
+	})
+
+	w, resp := post(t, h, `{"id":1}`, func(r *http.Request, vc *variables.Context) {
+		r.Header.Set("X-Trace-Id", "abc")
+		vc.PathParams = map[string]string{"tenant": "acme"}
+		vc.Identity = &variables.Identity{ClientID: "u1", Claims: map[string]interface{}{"sub": "u1"}}
+	})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body)
+	}
+	wantPartition := (murmur2([]byte("acme-u1")) & 0x7fffffff) % 8
+	if resp.Status != "produced" || resp.Topic != "orders" || resp.Partition != wantPartition || resp.Offset != 0 {
+		t.Errorf("unexpected response %+v, want partition %d offset 0", resp, wantPartition)
+	}
+
+	records, _ := b.snapshot()
+	if len(records) != 1 {
+		t.Fatalf("expected one record, got %d", len(records))
+	}
+	rec := records[0]
+	if string(rec.key) != "acme-u1" || string(rec.value) != `{"id":1}` || rec.partition != wantPartition {
+		t.Errorf("unexpected record %+v", rec)
+	}
+	if len(rec.headers) != 1 || rec.headers[0].Key != "X-Trace-Id" || string(rec.headers[0].Value) != "abc" {
+		t.Errorf("expected the X-Trace-Id header only, got %+v", rec.headers)
+	}
+
+	// The same key lands on the same partition with the next offset.
+	_, resp = post(t, h, `{"id":2}`, func(r *http.Request, vc *variables.Context) {
+		vc.PathParams = map[string]string{"tenant": "acme"}
+		vc.Identity = &variables.Identity{Claims: map[string]interface{}{"sub": "u1"}}
+	})
+	if resp.Partition != wantPartition || resp.Offset != 1 {
+		t.Errorf("expected offset 1 on partition %d, got %+v", wantPartition, resp)
+	}
+
+	st := h.Stats()
+	if st["produced"].(int64) != 2 || st["total_errors"].(int64) != 0 || st["bytes_produced"].(int64) != 16 {
+		t.Errorf("unexpected stats %v", st)
+	}
+}
+
+func TestHandlerUnkeyedRoundRobin(t *testing.T) {
+	b := newFakeBroker(t, "events", 3)
+	h := newHandler(t, config.KafkaConfig{Brokers: []string{b.addr()}, Topic: "events"})
+
+	seen := map[int32]bool{}
+	for i := 0; i < 3; i++ {
+		_, resp := post(t, h, "x", nil)
+		seen[resp.Partition] = true
+	}
+	records, _ := b.snapshot()
+	if len(seen) != 3 || records[0].key != nil {
+		t.Errorf("expected unkeyed messages on every partition, got %v", seen)
+	}
+}
+
+func TestHandlerAcksNone(t *testing.T) {
+	b := newFakeBroker(t, "events", 1)
+	h := newHandler(t, config.KafkaConfig{Brokers: []string{b.addr()}, Topic: "events", Acks: "none"})
+
+	w, resp := post(t, h, "fire and forget", nil)
+	if w.Code != http.StatusAccepted || resp.Offset != -1 {
+		t.Fatalf("expected 202 with offset -1, got %d %+v", w.Code, resp)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if records, _ := b.snapshot(); len(records) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("record not delivered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.acks[0] != 0 {
+		t.Errorf("expected acks 0 on the wire, got %d", b.acks[0])
+	}
+}
+
+func TestHandlerRetriesAfterLeaderChange(t *testing.T) {
+	b := newFakeBroker(t, "orders", 1)
+	b.failProduce = []Error{6}
+	h := newHandler(t, config.KafkaConfig{Brokers: []string{b.addr()}, Topic: "orders", Acks: "leader"})
+
+	if w, _ := post(t, h, "x", nil); w.Code != http.StatusAccepted {
+		t.Fatalf("expected the retry to succeed, got %d: %s", w.Code, w.Body)
+	}
+	if records, metadataReq := b.snapshot(); len(records) != 1 || metadataReq != 2 {
+		t.Errorf("expected one record after a metadata refresh, got %d records, %d metadata requests", len(records), metadataReq)
+	}
+
+	// Errors that a refresh cannot fix are not retried.
+	b.mu.Lock()
+	b.failProduce = []Error{10}
+	b.mu.Unlock()
+	if w, _ := post(t, h, "x", nil); w.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 for MESSAGE_TOO_LARGE, got %d", w.Code)
+	}
+	if _, metadataReq := b.snapshot(); metadataReq != 2 {
+		t.Errorf("expected no further metadata requests, got %d", metadataReq)
+	}
+	if h.Stats()["total_errors"].(int64) != 1 {
+		t.Errorf("expected one error, got %v", h.Stats())
+	}
+}
+
+func TestHandlerSASL(t *testing.T) {
+	for _, mechanism := range []string{MechanismPlain, MechanismSCRAMSHA256} {
+		t.Run(mechanism, func(t *testing.T) {
+			b := newFakeBroker(t, "orders", 1)
+			b.mechanism, b.username, b.password = mechanism, "svc=gw,1", "s3cret"
+
+			good := newHandler(t, config.KafkaConfig{
+				Brokers: []string{b.addr()}, Topic: "orders",
+				SASL: config.KafkaSASLConfig{Mechanism: mechanism, Username: "svc=gw,1", Password: "s3cret"},
+			})
+			if w, _ := post(t, good, "x", nil); w.Code != http.StatusAccepted {
+				t.Errorf("expected 202 with valid credentials, got %d", w.Code)
+			}
+
+			bad := newHandler(t, config.KafkaConfig{
+				Brokers: []string{b.addr()}, Topic: "orders",
+				SASL: config.KafkaSASLConfig{Mechanism: mechanism, Username: "svc=gw,1", Password: "wrong"},
+			})
+			if w, _ := post(t, bad, "x", nil); w.Code != http.StatusBadGateway {
+				t.Errorf("expected 502 with invalid credentials, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	b := newFakeBroker(t, "orders", 1)
+	h := newHandler(t, config.KafkaConfig{Brokers: []string{b.addr()}, Topic: "missing", Timeout: time.Second})
+	if w, _ := post(t, h, "x", nil); w.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 for an unknown topic, got %d", w.Code)
+	}
+
+	r := httptest.NewRequest("GET", "/orders", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	down := ln.Addr().String()
+	ln.Close()
+	h = newHandler(t, config.KafkaConfig{Brokers: []string{down}, Topic: "orders", Timeout: time.Second})
+	if w, _ := post(t, h, "x", nil); w.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 when no broker is reachable, got %d", w.Code)
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	for _, cfg := range []config.KafkaConfig{
+		{Topic: "t"},
+		{Brokers: []string{"k:9092"}},
+		{Brokers: []string{"k:9092"}, Topic: "t", Acks: "some"},
+		{Brokers: []string{"k:9092"}, Topic: "t", Key: "{{.Request"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestMurmur2(t *testing.T) {
+	// Vectors from the Kafka Java client's Utils.murmur2 tests.
+	for in, want := range map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	} {
+		if got := murmur2([]byte(in)); got != want {
+			t.Errorf("murmur2(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestKafkaByRoute(t *testing.T) {
+	m := NewKafkaByRoute()
+	if err := m.AddRoute("r1", config.KafkaConfig{}); err == nil {
+		t.Error("expected an error for an empty config")
+	}
+	if err := m.AddRoute("r1", config.KafkaConfig{Brokers: []string{"k:9092"}, Topic: "t"}); err != nil {
+		t.Fatal(err)
+	}
+	if m.Lookup("r1") == nil || m.Stats()["r1"] == nil {
+		t.Error("expected the route's handler and stats")
+	}
+	m.CloseAll()
+}
@@ -0,0 +1,188 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metadataMaxAge bounds how long partition leaders are cached without an
+// error forcing a refresh, so added partitions are picked up.
+const metadataMaxAge = 5 * time.Minute
+
+// producer sends single-record batches to the leader of the chosen
+// partition, with one pipelined connection per broker.
+type producer struct {
+	bootstrap []string
+	topic     string
+	acks      int16
+	timeout   time.Duration // broker-side wait for acks
+	dialer    *dialer
+
+	mu    sync.Mutex
+	conns map[string]*conn // by broker address
+	md    *metadata
+	mdAt  time.Time
+
+	rr                atomic.Uint32 // round-robin cursor for unkeyed messages
+	metadataRefreshes atomic.Int64
+}
+
+// produce writes msg and returns its partition and offset. The offset is -1
+// with acks=0. A leader change or broken connection refreshes metadata and
+// retries once.
+func (p *producer) produce(ctx context.Context, msg Message) (int32, int64, error) {
+	batch := encodeRecordBatch(msg, time.Now())
+	partition, offset, err := p.produceOnce(ctx, msg.Key, batch, false)
+	var kerr Error
+	if err == nil || ctx.Err() != nil || (errors.As(err, &kerr) && !kerr.retriable()) {
+		return partition, offset, err
+	}
+	return p.produceOnce(ctx, msg.Key, batch, true)
+}
+
+func (p *producer) produceOnce(ctx context.Context, key, batch []byte, refresh bool) (int32, int64, error) {
+	md, err := p.metadata(ctx, refresh)
+	if err != nil {
+		return -1, -1, err
+	}
+	partition := p.partition(key, len(md.leaders))
+	addr, ok := md.brokers[md.leaders[partition]]
+	if !ok {
+		return partition, -1, Error(5)
+	}
+	c, err := p.conn(ctx, addr)
+	if err != nil {
+		return partition, -1, err
+	}
+	req := encodeProduceRequest(p.topic, partition, p.acks, p.timeout, batch)
+	resp, err := c.roundTrip(ctx, apiProduce, produceVersion, req, p.acks != 0)
+	if err != nil || p.acks == 0 {
+		return partition, -1, err
+	}
+	offset, err := decodeProduceResponse(resp)
+	return partition, offset, err
+}
+
+// partition picks the partition the Java client's default partitioner
+// would for keyed messages, so keys land on the same partitions whichever
+// client produces them. Unkeyed messages are spread round-robin.
+func (p *producer) partition(key []byte, n int) int32 {
+	if key == nil {
+		return int32((p.rr.Add(1) - 1) % uint32(n))
+	}
+	return int32((murmur2(key) & 0x7fffffff) % int32(n))
+}
+
+// metadata returns the topic's partition leaders, fetching them when the
+// cache is empty, stale or refresh is set.
+func (p *producer) metadata(ctx context.Context, refresh bool) (*metadata, error) {
+	p.mu.Lock()
+	md, fresh := p.md, time.Since(p.mdAt) < metadataMaxAge
+	var addrs []string
+	if md != nil {
+		for _, addr := range md.brokers {
+			addrs = append(addrs, addr)
+		}
+	}
+	p.mu.Unlock()
+	if md != nil && fresh && !refresh {
+		return md, nil
+	}
+
+	p.metadataRefreshes.Add(1)
+	var lastErr error
+	for _, addr := range append(addrs, p.bootstrap...) {
+		c, err := p.conn(ctx, addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp, err := c.roundTrip(ctx, apiMetadata, metadataVersion, encodeMetadataRequest(p.topic), true)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		md, err := decodeMetadataResponse(resp, p.topic)
+		if err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		p.md, p.mdAt = md, time.Now()
+		p.mu.Unlock()
+		return md, nil
+	}
+	return nil, lastErr
+}
+
+// conn returns the open connection to addr, dialing one if needed.
+func (p *producer) conn(ctx context.Context, addr string) (*conn, error) {
+	p.mu.Lock()
+	c := p.conns[addr]
+	p.mu.Unlock()
+	if c != nil && !c.isClosed() {
+		return c, nil
+	}
+
+	c, err := p.dialer.dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cur := p.conns[addr]; cur != nil && !cur.isClosed() {
+		c.close(nil) // lost a dial race
+		return cur, nil
+	}
+	if p.conns == nil {
+		p.conns = make(map[string]*conn)
+	}
+	p.conns[addr] = c
+	return c, nil
+}
+
+func (p *producer) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, c := range p.conns {
+		c.close(nil)
+		delete(p.conns, addr)
+	}
+}
+
+// murmur2 is the hash of Kafka's default partitioner.
+func murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+	n := len(data)
+	h := seed ^ uint32(n)
+	for i := 0; i+4 <= n; i += 4 {
+		k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[n&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
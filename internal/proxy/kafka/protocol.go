@@ -0,0 +1,362 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Kafka API keys and the versions the producer speaks. These versions are
+// supported by every broker since Kafka 1.0.
+const (
+	apiProduce          int16 = 0
+	apiMetadata         int16 = 3
+	apiSaslHandshake    int16 = 17
+	apiSaslAuthenticate int16 = 36
+
+	produceVersion          int16 = 3
+	metadataVersion         int16 = 1
+	saslHandshakeVersion    int16 = 1
+	saslAuthenticateVersion int16 = 0
+)
+
+// Error is a non-zero Kafka protocol error code.
+type Error int16
+
+var errorNames = map[Error]string{
+	1:  "OFFSET_OUT_OF_RANGE",
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	17: "INVALID_TOPIC_EXCEPTION",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	33: "UNSUPPORTED_SASL_MECHANISM",
+	34: "ILLEGAL_SASL_STATE",
+	35: "UNSUPPORTED_VERSION",
+	58: "SASL_AUTHENTICATION_FAILED",
+}
+
+func (e Error) Error() string {
+	if name, ok := errorNames[e]; ok {
+		return fmt.Sprintf("kafka: %s (%d)", name, int16(e))
+	}
+	return fmt.Sprintf("kafka: error code %d", int16(e))
+}
+
+// retriable reports whether the error clears once metadata is refreshed,
+// such as after a leader election.
+func (e Error) retriable() bool {
+	return e == 3 || e == 5 || e == 6
+}
+
+var errShortResponse = errors.New("kafka: malformed response")
+
+// encoder appends big-endian Kafka primitives to a buffer.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) int8(v int8)   { e.b = append(e.b, byte(v)) }
+func (e *encoder) int16(v int16) { e.b = binary.BigEndian.AppendUint16(e.b, uint16(v)) }
+func (e *encoder) int32(v int32) { e.b = binary.BigEndian.AppendUint32(e.b, uint32(v)) }
+func (e *encoder) int64(v int64) { e.b = binary.BigEndian.AppendUint64(e.b, uint64(v)) }
+func (e *encoder) varint(v int64) {
+	e.b = binary.AppendVarint(e.b, v)
+}
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *encoder) nullableString(s *string) {
+	if s == nil {
+		e.int16(-1)
+		return
+	}
+	e.string(*s)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.b = append(e.b, b...)
+}
+
+// varBytes writes a record field: a zigzag varint length, -1 for nil.
+func (e *encoder) varBytes(b []byte) {
+	if b == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(b)))
+	e.b = append(e.b, b...)
+}
+
+// decoder reads big-endian Kafka primitives. The first short read sets err;
+// later reads return zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.b) < n {
+		d.err = errShortResponse
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) int8() int8 {
+	if v := d.take(1); v != nil {
+		return int8(v[0])
+	}
+	return 0
+}
+
+func (d *decoder) int16() int16 {
+	if v := d.take(2); v != nil {
+		return int16(binary.BigEndian.Uint16(v))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if v := d.take(4); v != nil {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if v := d.take(8); v != nil {
+		return int64(binary.BigEndian.Uint64(v))
+	}
+	return 0
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = errShortResponse
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.take(int(n))
+}
+
+func (d *decoder) varBytes() []byte {
+	n := d.varint()
+	if n < 0 {
+		return nil
+	}
+	return d.take(int(n))
+}
+
+// arrayLen reads an array length; null arrays are empty.
+func (d *decoder) arrayLen() int {
+	n := d.int32()
+	if n < 0 || d.err != nil {
+		return 0
+	}
+	if int(n) > len(d.b) {
+		d.err = errShortResponse
+		return 0
+	}
+	return int(n)
+}
+
+// Header is a Kafka record header.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is a record to produce. A nil Key spreads messages across
+// partitions.
+type Message struct {
+	Key     []byte
+	Value   []byte
+	Headers []Header
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// encodeRecordBatch encodes msg as a single-record, uncompressed v2 record
+// batch.
+func encodeRecordBatch(msg Message, ts time.Time) []byte {
+	var rec encoder
+	rec.int8(0)   // attributes
+	rec.varint(0) // timestamp delta
+	rec.varint(0) // offset delta
+	rec.varBytes(msg.Key)
+	rec.varBytes(msg.Value)
+	rec.varint(int64(len(msg.Headers)))
+	for _, h := range msg.Headers {
+		rec.varBytes([]byte(h.Key))
+		rec.varBytes(h.Value)
+	}
+
+	// Everything from attributes on is covered by the CRC.
+	var body encoder
+	millis := ts.UnixMilli()
+	body.int16(0) // attributes: no compression, create time
+	body.int32(0) // last offset delta
+	body.int64(millis)
+	body.int64(millis)
+	body.int64(-1) // producer id
+	body.int16(-1) // producer epoch
+	body.int32(-1) // base sequence
+	body.int32(1)  // record count
+	body.varint(int64(len(rec.b)))
+	body.b = append(body.b, rec.b...)
+
+	var batch encoder
+	batch.int64(0)                              // base offset
+	batch.int32(int32(4 + 1 + 4 + len(body.b))) // batch length: leader epoch, magic, crc, body
+	batch.int32(-1)                             // partition leader epoch
+	batch.int8(2)                               // magic
+	batch.b = binary.BigEndian.AppendUint32(batch.b, crc32.Checksum(body.b, crc32c))
+	batch.b = append(batch.b, body.b...)
+	return batch.b
+}
+
+// metadata is a topic's partition leaders and the brokers that host them.
+type metadata struct {
+	brokers map[int32]string // node id -> host:port
+	leaders []int32          // partition -> leader node id; -1 while leaderless
+}
+
+func encodeMetadataRequest(topic string) []byte {
+	var e encoder
+	e.int32(1)
+	e.string(topic)
+	return e.b
+}
+
+func decodeMetadataResponse(b []byte, topic string) (*metadata, error) {
+	d := &decoder{b: b}
+	md := &metadata{brokers: make(map[int32]string)}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		if rack := d.int16(); rack > 0 {
+			d.take(int(rack))
+		}
+		md.brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller id
+
+	var topicErr Error
+	found := false
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		code := Error(d.int16())
+		name := d.string()
+		d.int8() // is internal
+		var leaders []int32
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			d.int16() // partition error
+			index := d.int32()
+			leader := d.int32()
+			for k, r := 0, d.arrayLen(); k < r; k++ {
+				d.int32() // replicas
+			}
+			for k, r := 0, d.arrayLen(); k < r; k++ {
+				d.int32() // in-sync replicas
+			}
+			if index < 0 || int(index) >= m {
+				d.err = errShortResponse
+				break
+			}
+			for len(leaders) <= int(index) {
+				leaders = append(leaders, -1)
+			}
+			leaders[index] = leader
+		}
+		if name == topic {
+			found, topicErr, md.leaders = true, code, leaders
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if !found {
+		return nil, Error(3)
+	}
+	if topicErr != 0 {
+		return nil, topicErr
+	}
+	if len(md.leaders) == 0 {
+		return nil, Error(5)
+	}
+	return md, nil
+}
+
+func encodeProduceRequest(topic string, partition int32, acks int16, timeout time.Duration, batch []byte) []byte {
+	var e encoder
+	e.nullableString(nil) // transactional id
+	e.int16(acks)
+	e.int32(int32(timeout.Milliseconds()))
+	e.int32(1)
+	e.string(topic)
+	e.int32(1)
+	e.int32(partition)
+	e.bytes(batch)
+	return e.b
+}
+
+// decodeProduceResponse returns the base offset the broker assigned.
+func decodeProduceResponse(b []byte) (int64, error) {
+	d := &decoder{b: b}
+	offset, code := int64(-1), Error(0)
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		d.string()
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			d.int32() // partition
+			code = Error(d.int16())
+			offset = d.int64()
+			d.int64() // log append time
+		}
+	}
+	if d.err != nil {
+		return -1, d.err
+	}
+	if code != 0 {
+		return -1, code
+	}
+	return offset, nil
+}
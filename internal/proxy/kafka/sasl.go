@@ -0,0 +1,167 @@
+package kafka
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// SASL mechanisms.
+const (
+	MechanismPlain       = "PLAIN"
+	MechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	MechanismSCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+type saslConfig struct {
+	mechanism string
+	username  string
+	password  string
+}
+
+// authenticate runs the SASL handshake and exchange on a new connection,
+// before any other request is sent.
+func (s *saslConfig) authenticate(ctx context.Context, c *conn) error {
+	var e encoder
+	e.string(s.mechanism)
+	resp, err := c.roundTrip(ctx, apiSaslHandshake, saslHandshakeVersion, e.b, true)
+	if err != nil {
+		return err
+	}
+	d := &decoder{b: resp}
+	code := Error(d.int16())
+	var offered []string
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		offered = append(offered, d.string())
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if code != 0 {
+		return fmt.Errorf("%w: broker offers %s", code, strings.Join(offered, ", "))
+	}
+
+	switch s.mechanism {
+	case MechanismPlain:
+		_, err = saslExchange(ctx, c, []byte("\x00"+s.username+"\x00"+s.password))
+		return err
+	case MechanismSCRAMSHA256:
+		return s.scram(ctx, c, sha256.New)
+	case MechanismSCRAMSHA512:
+		return s.scram(ctx, c, sha512.New)
+	}
+	return fmt.Errorf("unsupported SASL mechanism %q", s.mechanism)
+}
+
+// saslExchange sends one SaslAuthenticate round and returns the broker's
+// reply.
+func saslExchange(ctx context.Context, c *conn, msg []byte) ([]byte, error) {
+	var e encoder
+	e.bytes(msg)
+	resp, err := c.roundTrip(ctx, apiSaslAuthenticate, saslAuthenticateVersion, e.b, true)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{b: resp}
+	code := Error(d.int16())
+	var errMsg string
+	if n := d.int16(); n > 0 {
+		errMsg = string(d.take(int(n)))
+	}
+	reply := d.bytes()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if code != 0 {
+		if errMsg != "" {
+			return nil, fmt.Errorf("%w: %s", code, errMsg)
+		}
+		return nil, code
+	}
+	return reply, nil
+}
+
+// scram runs a SCRAM exchange (RFC 5802) without channel binding.
+func (s *saslConfig) scram(ctx context.Context, c *conn, h func() hash.Hash) error {
+	var raw [24]byte
+	rand.Read(raw[:])
+	nonce := base64.RawStdEncoding.EncodeToString(raw[:])
+	user := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(s.username)
+	clientFirstBare := "n=" + user + ",r=" + nonce
+
+	serverFirst, err := saslExchange(ctx, c, []byte("n,,"+clientFirstBare))
+	if err != nil {
+		return err
+	}
+	attrs := scramAttrs(string(serverFirst))
+	serverNonce, salt64, iterStr := attrs["r"], attrs["s"], attrs["i"]
+	if !strings.HasPrefix(serverNonce, nonce) || len(serverNonce) == len(nonce) {
+		return errors.New("scram: server nonce does not extend the client nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(salt64)
+	if err != nil {
+		return fmt.Errorf("scram: invalid salt: %w", err)
+	}
+	iter, err := strconv.Atoi(iterStr)
+	if err != nil || iter <= 0 {
+		return fmt.Errorf("scram: invalid iteration count %q", iterStr)
+	}
+
+	salted, err := pbkdf2.Key(h, s.password, salt, iter, h().Size())
+	if err != nil {
+		return fmt.Errorf("scram: %w", err)
+	}
+	clientKey := hmacSum(h, salted, []byte("Client Key"))
+	storedKey := h()
+	storedKey.Write(clientKey)
+	clientFinalBare := "c=biws,r=" + serverNonce
+	authMessage := []byte(clientFirstBare + "," + string(serverFirst) + "," + clientFinalBare)
+	signature := hmacSum(h, storedKey.Sum(nil), authMessage)
+	proof := make([]byte, len(clientKey))
+	subtle.XORBytes(proof, clientKey, signature)
+
+	serverFinal, err := saslExchange(ctx, c, []byte(clientFinalBare+",p="+base64.StdEncoding.EncodeToString(proof)))
+	if err != nil {
+		return err
+	}
+	final := scramAttrs(string(serverFinal))
+	if e, ok := final["e"]; ok {
+		return fmt.Errorf("scram: %s", e)
+	}
+	verifier, err := base64.StdEncoding.DecodeString(final["v"])
+	if err != nil {
+		return errors.New("scram: invalid server signature")
+	}
+	serverKey := hmacSum(h, salted, []byte("Server Key"))
+	if !hmac.Equal(verifier, hmacSum(h, serverKey, authMessage)) {
+		return errors.New("scram: server signature mismatch")
+	}
+	return nil
+}
+
+func hmacSum(h func() hash.Hash, key, msg []byte) []byte {
+	mac := hmac.New(h, key)
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
+
+// scramAttrs parses "k=v,k=v" SCRAM messages.
+func scramAttrs(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(msg, ",") {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,265 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/loadbalancer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// uploadServer is a gRPC backend with a client-streaming Upload method. It
+// reports every message it receives and how each stream ended.
+type uploadServer struct {
+	addr     string
+	received chan string // data field of each message
+	ended    chan error  // nil when the client closed the stream cleanly
+}
+
+func startUploadServer(t *testing.T) *uploadServer {
+	t.Helper()
+
+	fdProto := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("upload_test.proto"),
+		Package: proto.String("uploadtest"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Chunk"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("data"), JsonName: proto.String("data"), Number: proto.Int32(1),
+					Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+				{Name: proto.String("count"), JsonName: proto.String("count"), Number: proto.Int32(2),
+					Type: descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+			},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Uploader"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:            proto.String("Upload"),
+				InputType:       proto.String(".uploadtest.Chunk"),
+				OutputType:      proto.String(".uploadtest.Chunk"),
+				ClientStreaming: proto.Bool(true),
+			}},
+		}},
+	}
+	files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{fdProto}})
+	if err != nil {
+		t.Fatalf("failed to build descriptors: %v", err)
+	}
+	fd, _ := files.FindFileByPath("upload_test.proto")
+	msgDesc := fd.Messages().Get(0)
+	dataField := msgDesc.Fields().ByName("data")
+	countField := msgDesc.Fields().ByName("count")
+
+	us := &uploadServer{received: make(chan string, 16), ended: make(chan error, 16)}
+	s := grpc.NewServer()
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "uploadtest.Uploader",
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "Upload",
+			ClientStreams: true,
+			Handler: func(_ interface{}, stream grpc.ServerStream) error {
+				var count int32
+				for {
+					msg := dynamicpb.NewMessage(msgDesc)
+					if err := stream.RecvMsg(msg); err != nil {
+						if err == io.EOF {
+							us.ended <- nil
+							break
+						}
+						us.ended <- err
+						return err
+					}
+					count++
+					us.received <- msg.Get(dataField).String()
+				}
+				resp := dynamicpb.NewMessage(msgDesc)
+				resp.Set(countField, protoreflect.ValueOfInt32(count))
+				return stream.SendMsg(resp)
+			},
+		}},
+	}, nil)
+	rpb.RegisterServerReflectionServer(s, reflection.NewServer(reflection.ServerOptions{Services: s, DescriptorResolver: files}))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	us.addr = lis.Addr().String()
+	return us
+}
+
+func newUploadHandler(t *testing.T, addr string, cs config.GRPCClientStreamConfig) http.Handler {
+	t.Helper()
+	tr := New()
+	t.Cleanup(tr.CloseAll)
+	bal := loadbalancer.NewRoundRobin([]*loadbalancer.Backend{{URL: addr, Healthy: true}})
+	h, err := tr.Handler("upload", bal, config.ProtocolConfig{
+		Type: "http_to_grpc",
+		GRPC: config.GRPCTranslateConfig{Service: "uploadtest.Uploader", Method: "Upload", ClientStream: cs},
+	})
+	if err != nil {
+		t.Fatalf("Handler: %v", err)
+	}
+	return h
+}
+
+func TestClientStreamUpload_MessageBoundaries(t *testing.T) {
+	us := startUploadServer(t)
+	srv := httptest.NewServer(newUploadHandler(t, us.addr, config.GRPCClientStreamConfig{}))
+	defer srv.Close()
+
+	pr, pw := io.Pipe()
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := http.Post(srv.URL, "application/x-ndjson", pr)
+		done <- result{resp, err}
+	}()
+
+	// Each line reaches the backend as its own message before the next one
+	// is written, so the body is not buffered.
+	for _, line := range []string{`{"data":"a b"}`, `{"data":"{\"nested\":1}"}`, ``, `{"data":"c"}`} {
+		io.WriteString(pw, line+"\n")
+		if line == "" {
+			continue
+		}
+		var want map[string]string
+		json.Unmarshal([]byte(line), &want)
+		select {
+		case got := <-us.received:
+			if got != want["data"] {
+				t.Fatalf("backend received %q, want %q", got, want["data"])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("line %q was not sent before the body ended", line)
+		}
+	}
+	pw.Close()
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("POST: %v", res.err)
+	}
+	defer res.resp.Body.Close()
+	body, _ := io.ReadAll(res.resp.Body)
+	if res.resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"count":3`) {
+		t.Fatalf("unexpected response %d %s", res.resp.StatusCode, body)
+	}
+	if err := <-us.ended; err != nil {
+		t.Errorf("expected a cleanly closed stream, got %v", err)
+	}
+}
+
+func TestClientStreamUpload_ClientDisconnectCancelsStream(t *testing.T) {
+	us := startUploadServer(t)
+	srv := httptest.NewServer(newUploadHandler(t, us.addr, config.GRPCClientStreamConfig{}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL, pr)
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	io.WriteString(pw, `{"data":"first"}`+"\n")
+	select {
+	case <-us.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first message was not sent")
+	}
+	cancel()
+	pw.CloseWithError(context.Canceled)
+
+	select {
+	case err := <-us.ended:
+		if status.Code(err) != codes.Canceled {
+			t.Fatalf("expected the backend stream to be cancelled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("backend stream did not end after the client disconnected")
+	}
+}
+
+func TestClientStreamUpload_InvalidLines(t *testing.T) {
+	us := startUploadServer(t)
+	body := `{"data":"a"}` + "\n" + `not json` + "\n" + `{"data":"b"}` + "\n"
+
+	h := newUploadHandler(t, us.addr, config.GRPCClientStreamConfig{})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "line 2") {
+		t.Fatalf("expected 400 naming line 2, got %d %s", w.Code, w.Body.String())
+	}
+	// The stream may be cancelled before the backend sees it at all, but
+	// it must never end cleanly.
+	select {
+	case err := <-us.ended:
+		if status.Code(err) != codes.Canceled {
+			t.Errorf("a rejected upload must cancel the stream, got %v", err)
+		}
+	case <-time.After(200 * time.Millisecond):
+	}
+	for len(us.received) > 0 {
+		<-us.received
+	}
+
+	h = newUploadHandler(t, us.addr, config.GRPCClientStreamConfig{OnInvalidLine: "skip"})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"count":2`) {
+		t.Fatalf("expected the invalid line to be skipped, got %d %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("X-Runway-Skipped-Lines"); got != "1" {
+		t.Errorf("X-Runway-Skipped-Lines = %q, want 1", got)
+	}
+}
+
+func TestClientStreamUpload_Limits(t *testing.T) {
+	us := startUploadServer(t)
+
+	tests := []struct {
+		name string
+		cfg  config.GRPCClientStreamConfig
+		body string
+	}{
+		{"max messages", config.GRPCClientStreamConfig{MaxMessages: 1}, `{"data":"a"}` + "\n" + `{"data":"b"}` + "\n"},
+		{"max line size", config.GRPCClientStreamConfig{MaxLineSize: 16}, `{"data":"` + strings.Repeat("x", 64) + `"}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newUploadHandler(t, us.addr, tt.cfg).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("expected 413, got %d %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
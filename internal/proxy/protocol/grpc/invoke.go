@@ -156,12 +156,19 @@ func (inv *invoker) invokeServerStream(
 }
 
 // invokeClientStream handles client-streaming RPC: N requests, 1 response.
+// Each message is read only after the previous one was accepted by the
+// stream, so flow control on the stream paces reading. If reading fails the
+// stream is cancelled rather than closed, and the backend never sees a
+// truncated upload as complete.
 func (inv *invoker) invokeClientStream(
 	ctx context.Context,
 	conn *grpc.ClientConn,
 	md protoreflect.MethodDescriptor,
 	reader *ndjsonReader,
 ) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	// Build the full method name
 	fullMethod := fmt.Sprintf("/%s/%s", md.Parent().FullName(), md.Name())
 
@@ -190,6 +197,10 @@ func (inv *invoker) invokeClientStream(
 		}
 
 		if err := stream.SendMsg(inputMsg); err != nil {
+			if err == io.EOF {
+				// The backend ended the stream; its status comes from RecvMsg.
+				break
+			}
 			return nil, fmt.Errorf("failed to send message: %w", err)
 		}
 	}
@@ -2,11 +2,14 @@ package grpc
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"sync"
 
+	"github.com/wudi/runway/config"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
@@ -80,50 +83,131 @@ func (nw *ndjsonWriter) WriteError(code codes.Code, message string) error {
 	return nil
 }
 
-// ndjsonReader reads newline-delimited JSON from an io.Reader.
+// defaultMaxLineSize is the max size of a request NDJSON line.
+const defaultMaxLineSize = 1024 * 1024
+
+var (
+	// errTooManyMessages is returned when a request has more lines than the
+	// configured max_messages.
+	errTooManyMessages = errors.New("too many messages")
+
+	// errBodyRead wraps failures reading the request body, typically a
+	// client that disconnected mid-upload.
+	errBodyRead = errors.New("failed to read request body")
+)
+
+// lineError reports a request line that cannot be sent.
+type lineError struct {
+	line     int
+	tooLarge bool
+	msg      string
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+// ndjsonReader reads newline-delimited JSON from an io.Reader. Lines are read
+// one at a time as messages are requested, so at most one line is buffered.
 type ndjsonReader struct {
-	scanner       *bufio.Scanner
+	r             *bufio.Reader
 	unmarshalOpts protojson.UnmarshalOptions
+	err           error
+
+	maxLineSize int
+	maxMessages int  // 0 = unlimited
+	skipInvalid bool // skip lines that fail to parse instead of failing
+
+	line     int // number of the last line read
+	messages int
+	skipped  int
 }
 
-// newNDJSONReader creates a new NDJSON reader.
+// newNDJSONReader creates a new NDJSON reader with the default line size limit.
 func newNDJSONReader(r io.Reader) *ndjsonReader {
-	scanner := bufio.NewScanner(r)
-	// Set a reasonable max line size (1MB)
-	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return newClientStreamReader(r, config.GRPCClientStreamConfig{})
+}
 
+// newClientStreamReader creates an NDJSON reader bounded by cfg.
+func newClientStreamReader(r io.Reader, cfg config.GRPCClientStreamConfig) *ndjsonReader {
+	maxLineSize := cfg.MaxLineSize
+	if maxLineSize <= 0 {
+		maxLineSize = defaultMaxLineSize
+	}
 	return &ndjsonReader{
-		scanner: scanner,
+		r: bufio.NewReaderSize(r, 64*1024),
 		unmarshalOpts: protojson.UnmarshalOptions{
 			DiscardUnknown: true,
 		},
+		maxLineSize: maxLineSize,
+		maxMessages: cfg.MaxMessages,
+		skipInvalid: cfg.OnInvalidLine == "skip",
 	}
 }
 
 // ReadMessage reads the next JSON line and unmarshals it into the proto message.
-// Returns io.EOF when there are no more lines.
+// Empty lines are ignored, as are lines that fail to parse when the reader
+// skips invalid lines. Returns io.EOF when there are no more lines.
 func (nr *ndjsonReader) ReadMessage(msg proto.Message) error {
-	if !nr.scanner.Scan() {
-		if err := nr.scanner.Err(); err != nil {
+	for {
+		line, err := nr.readLine()
+		if err != nil {
 			return err
 		}
-		return io.EOF
-	}
-
-	line := nr.scanner.Bytes()
-	if len(line) == 0 {
-		// Skip empty lines and try next
-		return nr.ReadMessage(msg)
+		if len(line) == 0 {
+			continue
+		}
+		if nr.maxMessages > 0 && nr.messages >= nr.maxMessages {
+			return fmt.Errorf("%w: limit is %d", errTooManyMessages, nr.maxMessages)
+		}
+		if err := nr.unmarshalOpts.Unmarshal(line, msg); err != nil {
+			if nr.skipInvalid {
+				nr.skipped++
+				proto.Reset(msg)
+				continue
+			}
+			return &lineError{line: nr.line, msg: fmt.Sprintf("failed to parse JSON: %v", err)}
+		}
+		nr.messages++
+		return nil
 	}
+}
 
-	if err := nr.unmarshalOpts.Unmarshal(line, msg); err != nil {
-		return fmt.Errorf("failed to parse JSON: %w", err)
+// readLine returns the next line without surrounding whitespace.
+func (nr *ndjsonReader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		frag, err := nr.r.ReadSlice('\n')
+		if len(line)+len(frag) > nr.maxLineSize+2 { // room for "\r\n"
+			nr.line++
+			return nil, &lineError{line: nr.line, tooLarge: true, msg: fmt.Sprintf("exceeds max line size of %d bytes", nr.maxLineSize)}
+		}
+		line = append(line, frag...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err != io.EOF {
+				nr.err = err
+				return nil, fmt.Errorf("%w: %v", errBodyRead, err)
+			}
+			return nil, io.EOF
+		}
+		nr.line++
+		line = bytes.TrimSpace(line)
+		if len(line) > nr.maxLineSize {
+			return nil, &lineError{line: nr.line, tooLarge: true, msg: fmt.Sprintf("exceeds max line size of %d bytes", nr.maxLineSize)}
+		}
+		return line, nil
 	}
+}
 
-	return nil
+// Skipped returns the number of invalid lines skipped so far.
+func (nr *ndjsonReader) Skipped() int {
+	return nr.skipped
 }
 
-// Err returns any error that occurred during scanning.
+// Err returns any error that occurred reading the underlying reader.
 func (nr *ndjsonReader) Err() error {
-	return nr.scanner.Err()
+	return nr.err
 }
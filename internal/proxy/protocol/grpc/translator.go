@@ -5,10 +5,12 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -95,16 +97,9 @@ func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, routeID s
 
 	var serviceName, methodName string
 	var requestBody []byte
+	var bodyRead bool
 	var err error
 
-	// Read request body first (needed for both modes)
-	rawBody, err := io.ReadAll(r.Body)
-	if err != nil {
-		t.writeError(w, codes.InvalidArgument, fmt.Sprintf("failed to read request body: %v", err))
-		metrics.Failures.Add(1)
-		return
-	}
-
 	// Priority order:
 	// 1. Fixed method (service + method in config)
 	// 2. REST mappings (if configured)
@@ -113,7 +108,6 @@ func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, routeID s
 		// Fixed method mode: service and method both from config
 		serviceName = cfg.GRPC.Service
 		methodName = cfg.GRPC.Method
-		requestBody = rawBody
 	} else if mapper != nil {
 		// REST-to-gRPC mode: match against configured mappings
 		match := mapper.match(r.Method, r.URL.Path)
@@ -127,12 +121,19 @@ func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, routeID s
 		methodName = match.grpcMethod
 
 		// Build request body from path params, query params, and body
+		rawBody, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.writeError(w, codes.InvalidArgument, fmt.Sprintf("failed to read request body: %v", err))
+			metrics.Failures.Add(1)
+			return
+		}
 		requestBody, err = mapper.buildRequestBody(r, match, rawBody)
 		if err != nil {
 			t.writeError(w, codes.InvalidArgument, err.Error())
 			metrics.Failures.Add(1)
 			return
 		}
+		bodyRead = true
 	} else {
 		// Original mode: POST only, path-based method resolution
 		if r.Method != http.MethodPost {
@@ -147,7 +148,6 @@ func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, routeID s
 			metrics.Failures.Add(1)
 			return
 		}
-		requestBody = rawBody
 	}
 
 	// Select backend
@@ -173,7 +173,13 @@ func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, routeID s
 	// Get service descriptor via reflection
 	sd, err := t.descCache.getServiceDescriptor(ctx, conn, backend.URL, serviceName)
 	if err != nil {
-		t.writeError(w, codes.NotFound, fmt.Sprintf("service discovery failed: %v", err))
+		// An unreachable backend is reported as such, so callers can tell it
+		// apart from a service the backend does not expose.
+		code := codes.NotFound
+		if status.Code(err) == codes.Unavailable {
+			code = codes.Unavailable
+		}
+		t.writeError(w, code, fmt.Sprintf("service discovery failed: %v", err))
 		metrics.Failures.Add(1)
 		return
 	}
@@ -190,7 +196,27 @@ func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, routeID s
 	isClientStream := md.IsStreamingClient()
 	isServerStream := md.IsStreamingServer()
 
-	if isClientStream || isServerStream {
+	if isClientStream && !isServerStream {
+		// Client streaming: N requests, 1 response. The body is read line by
+		// line as messages are sent unless a REST mapping already consumed it.
+		var body io.Reader = r.Body
+		if bodyRead {
+			body = bytes.NewReader(requestBody)
+		}
+		t.handleClientStream(w, ctx, conn, md, body, cfg.GRPC.ClientStream, metrics, start)
+		return
+	}
+
+	if !bodyRead {
+		requestBody, err = io.ReadAll(r.Body)
+		if err != nil {
+			t.writeError(w, codes.InvalidArgument, fmt.Sprintf("failed to read request body: %v", err))
+			metrics.Failures.Add(1)
+			return
+		}
+	}
+
+	if isServerStream {
 		t.serveStreaming(w, r, ctx, conn, md, requestBody, metrics, isClientStream, isServerStream)
 		return
 	}
@@ -324,7 +350,8 @@ func (t *Translator) buildTLSCredentials(cfg config.ProtocolTLSConfig) (credenti
 	return credentials.NewTLS(tlsConfig), nil
 }
 
-// serveStreaming handles streaming gRPC calls using NDJSON format.
+// serveStreaming handles server and bidirectional streaming gRPC calls using
+// NDJSON format.
 func (t *Translator) serveStreaming(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -348,10 +375,6 @@ func (t *Translator) serveStreaming(
 		// Server streaming: 1 request, N responses
 		t.handleServerStream(w, ctx, conn, md, requestBody, metrics, start)
 
-	case isClientStream && !isServerStream:
-		// Client streaming: N requests, 1 response
-		t.handleClientStream(w, ctx, conn, md, requestBody, metrics, start)
-
 	case isClientStream && isServerStream:
 		// Bidirectional streaming: N requests, N responses
 		t.handleBidiStream(w, ctx, conn, md, requestBody, metrics, start)
@@ -400,21 +423,37 @@ func (t *Translator) handleClientStream(
 	ctx context.Context,
 	conn *grpc.ClientConn,
 	md protoreflect.MethodDescriptor,
-	requestBody []byte,
+	body io.Reader,
+	cfg config.GRPCClientStreamConfig,
 	metrics *protocol.RouteMetrics,
 	start time.Time,
 ) {
-	// Create NDJSON reader from request body (already read into bytes)
-	reader := newNDJSONReader(bytes.NewReader(requestBody))
+	// Each NDJSON line of the body is one stream message
+	reader := newClientStreamReader(body, cfg)
 
 	// Invoke the streaming RPC
 	respJSON, err := t.invoker.invokeClientStream(ctx, conn, md, reader)
+	if skipped := reader.Skipped(); skipped > 0 {
+		w.Header().Set("X-Runway-Skipped-Lines", strconv.Itoa(skipped))
+	}
 	if err != nil {
-		st, ok := status.FromError(err)
-		if !ok {
-			t.writeError(w, codes.Internal, err.Error())
-		} else {
-			t.writeError(w, st.Code(), st.Message())
+		var lineErr *lineError
+		switch {
+		case errors.As(err, &lineErr) && lineErr.tooLarge:
+			t.writeErrorStatus(w, http.StatusRequestEntityTooLarge, codes.InvalidArgument, lineErr.Error())
+		case errors.As(err, &lineErr):
+			t.writeError(w, codes.InvalidArgument, lineErr.Error())
+		case errors.Is(err, errTooManyMessages):
+			t.writeErrorStatus(w, http.StatusRequestEntityTooLarge, codes.InvalidArgument, err.Error())
+		case errors.Is(err, errBodyRead):
+			t.writeError(w, codes.Canceled, err.Error())
+		default:
+			st, ok := status.FromError(err)
+			if !ok {
+				t.writeError(w, codes.Internal, err.Error())
+			} else {
+				t.writeError(w, st.Code(), st.Message())
+			}
 		}
 		metrics.Failures.Add(1)
 		return
@@ -470,7 +509,11 @@ func (t *Translator) handleBidiStream(
 
 // writeError writes a JSON error response with the appropriate HTTP status code.
 func (t *Translator) writeError(w http.ResponseWriter, code codes.Code, message string) {
-	httpStatus := protocol.GRPCStatusToHTTP(code)
+	t.writeErrorStatus(w, protocol.GRPCStatusToHTTP(code), code, message)
+}
+
+// writeErrorStatus writes a JSON error response with an explicit HTTP status code.
+func (t *Translator) writeErrorStatus(w http.ResponseWriter, httpStatus int, code codes.Code, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Grpc-Status", fmt.Sprintf("%d", code))
 	w.Header().Set("Grpc-Message", message)
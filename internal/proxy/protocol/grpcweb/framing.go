@@ -1,6 +1,7 @@
 package grpcweb
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/binary"
 	"fmt"
@@ -8,6 +9,8 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+
+	"google.golang.org/grpc/metadata"
 )
 
 const (
@@ -146,12 +149,85 @@ func base64Encode(data []byte) []byte {
 	return encoded
 }
 
-// base64Decode decodes standard base64 data.
+// base64Decode decodes standard base64 data. The data may be several
+// independently padded chunks concatenated, as written by a streaming
+// gRPC-Web peer that encodes each flush separately.
 func base64Decode(data []byte) ([]byte, error) {
-	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
-	n, err := base64.StdEncoding.Decode(decoded, data)
-	if err != nil {
-		return nil, fmt.Errorf("base64 decode failed: %w", err)
+	decoded := make([]byte, 0, base64.StdEncoding.DecodedLen(len(data)))
+	for len(data) > 0 {
+		// A chunk ends after its padding.
+		end := len(data)
+		if i := bytes.IndexByte(data, '='); i != -1 {
+			end = i + 1
+			for end < len(data) && data[end] == '=' {
+				end++
+			}
+		}
+		chunk := make([]byte, base64.StdEncoding.DecodedLen(end))
+		n, err := base64.StdEncoding.Decode(chunk, data[:end])
+		if err != nil {
+			return nil, fmt.Errorf("base64 decode failed: %w", err)
+		}
+		decoded = append(decoded, chunk[:n]...)
+		data = data[end:]
+	}
+	return decoded, nil
+}
+
+// frameWriter writes gRPC-Web response frames, flushing each frame to the
+// client as it is written.
+//
+// Base64 can only be cut at 3-byte boundaries, so in text mode each frame is
+// encoded on its own, with padding, and written in one piece: every chunk
+// the client receives is independently decodable, wherever the transport
+// splits the stream between flushes.
+type frameWriter struct {
+	w        http.ResponseWriter
+	flusher  http.Flusher
+	textMode bool
+}
+
+func newFrameWriter(w http.ResponseWriter, textMode bool) *frameWriter {
+	fw := &frameWriter{w: w, textMode: textMode}
+	fw.flusher, _ = w.(http.Flusher)
+	return fw
+}
+
+// writeHeader writes the response headers, adding the backend's initial
+// metadata.
+func (fw *frameWriter) writeHeader(md metadata.MD) {
+	if fw.textMode {
+		fw.w.Header().Set("Content-Type", "application/grpc-web-text+proto")
+	} else {
+		fw.w.Header().Set("Content-Type", "application/grpc-web+proto")
+	}
+	for k, vals := range md {
+		for _, v := range vals {
+			fw.w.Header().Add(k, v)
+		}
+	}
+	fw.w.WriteHeader(http.StatusOK)
+}
+
+// writeMessage writes msg as a data frame and flushes it.
+func (fw *frameWriter) writeMessage(msg []byte) error {
+	return fw.writeFrame(encodeDataFrame(msg))
+}
+
+// writeTrailer writes the trailer frame ending the response and flushes it.
+func (fw *frameWriter) writeTrailer(trailers map[string]string) error {
+	return fw.writeFrame(encodeTrailerFrame(trailers))
+}
+
+func (fw *frameWriter) writeFrame(frame []byte) error {
+	if fw.textMode {
+		frame = base64Encode(frame)
+	}
+	if _, err := fw.w.Write(frame); err != nil {
+		return err
+	}
+	if fw.flusher != nil {
+		fw.flusher.Flush()
 	}
-	return decoded[:n], nil
+	return nil
 }
@@ -175,6 +175,21 @@ func TestBase64RoundTrip(t *testing.T) {
 	}
 }
 
+func TestBase64DecodeConcatenatedChunks(t *testing.T) {
+	// Independently padded chunks, as a streaming peer flushes them.
+	var body []byte
+	for _, chunk := range []string{"a", "bc", "def", "g"} {
+		body = append(body, base64Encode([]byte(chunk))...)
+	}
+	decoded, err := base64Decode(body)
+	if err != nil {
+		t.Fatalf("base64 decode failed: %v", err)
+	}
+	if string(decoded) != "abcdefg" {
+		t.Errorf("decoded = %q, want %q", decoded, "abcdefg")
+	}
+}
+
 func TestBase64DecodeInvalid(t *testing.T) {
 	_, err := base64Decode([]byte("!!!not-base64!!!"))
 	if err == nil {
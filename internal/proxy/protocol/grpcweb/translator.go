@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,6 +18,7 @@ import (
 	"github.com/wudi/runway/internal/loadbalancer"
 	"github.com/wudi/runway/internal/proxy/protocol"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
@@ -73,6 +75,7 @@ func (t *Translator) Handler(routeID string, balancer loadbalancer.Balancer, cfg
 	t.routeMetrics[routeID] = &protocol.RouteMetrics{}
 	t.metricsMu.Unlock()
 
+	// The timeout bounds how long a call waits for each response message.
 	timeout := cfg.GRPCWeb.Timeout
 	if timeout == 0 {
 		timeout = 30 * time.Second
@@ -184,251 +187,130 @@ func (t *Translator) serveHTTP(
 		return
 	}
 
-	// Create context with timeout and forward metadata.
-	ctx, cancel := context.WithTimeout(r.Context(), timeout)
-	defer cancel()
-
 	// Forward relevant headers as gRPC metadata.
+	ctx := r.Context()
 	md := extractMetadata(r)
 	if len(md) > 0 {
 		ctx = metadata.NewOutgoingContext(ctx, md)
 	}
 
 	fullMethod := "/" + serviceName + "/" + methodName
-	reqPayload := frame.Payload
-
-	// Branch: server streaming or unary.
-	if isServerStreaming(r) {
-		t.serveServerStream(w, conn, ctx, fullMethod, methodName, reqPayload, textMode, metrics, start)
-		return
-	}
-
-	// Invoke the unary RPC using raw codec.
-	var respPayload []byte
-	var headerMD, trailerMD metadata.MD
-	err = conn.Invoke(ctx, fullMethod, &reqPayload, &respPayload,
-		grpc.Header(&headerMD),
-		grpc.Trailer(&trailerMD),
-	)
-
-	if err != nil {
-		st, ok := status.FromError(err)
-		if !ok {
-			t.writeGRPCWebError(w, textMode, "13", err.Error())
-		} else {
-			t.writeGRPCWebError(w, textMode, fmt.Sprintf("%d", st.Code()), st.Message())
-		}
+	if t.serveStream(w, ctx, conn, fullMethod, methodName, frame.Payload, textMode, timeout, maxMsgSize) {
+		metrics.Successes.Add(1)
+		metrics.TotalLatencyNs.Add(time.Since(start).Nanoseconds())
+	} else {
 		metrics.Failures.Add(1)
-		return
 	}
-
-	// Write successful gRPC-Web response.
-	t.writeGRPCWebResponse(w, textMode, respPayload, headerMD, trailerMD)
-
-	metrics.Successes.Add(1)
-	metrics.TotalLatencyNs.Add(time.Since(start).Nanoseconds())
 }
 
-// isServerStreaming returns true if the request signals server-streaming mode
-// via query parameter (?streaming=server) or header (X-Grpc-Web-Streaming: server).
-func isServerStreaming(r *http.Request) bool {
-	if r.URL.Query().Get("streaming") == "server" {
-		return true
-	}
-	return r.Header.Get("X-Grpc-Web-Streaming") == "server"
-}
-
-// serveServerStream handles a server-streaming RPC over gRPC-Web.
-// It creates a gRPC stream, sends the single request, then loops over responses,
-// writing each as a gRPC-Web data frame flushed to the client in real time.
-func (t *Translator) serveServerStream(
+// errIdleTimeout cancels a call whose backend sent nothing for the idle
+// timeout.
+var errIdleTimeout = errors.New("grpc-web: idle timeout")
+
+// serveStream proxies a call as a server-streaming RPC: it sends the single
+// request message, then writes each response message as a gRPC-Web data
+// frame flushed to the client as it arrives, and ends with the trailer
+// frame. Unary methods are served the same way, as a stream of one message,
+// so the method type need not be known.
+//
+// timeout is an idle timeout: the call is cancelled when the backend sends
+// no message for that long. maxMsgSize limits each response message; a
+// larger one ends the stream with RESOURCE_EXHAUSTED. serveStream reports
+// whether the call succeeded.
+func (t *Translator) serveStream(
 	w http.ResponseWriter,
-	conn *grpc.ClientConn,
 	ctx context.Context,
+	conn *grpc.ClientConn,
 	fullMethod string,
 	methodName string,
 	reqPayload []byte,
 	textMode bool,
-	metrics *protocol.RouteMetrics,
-	start time.Time,
-) {
+	timeout time.Duration,
+	maxMsgSize int,
+) bool {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	idle := time.AfterFunc(timeout, func() { cancel(errIdleTimeout) })
+	defer idle.Stop()
+
 	streamDesc := &grpc.StreamDesc{
 		StreamName:    methodName,
 		ServerStreams: true,
 	}
-
-	stream, err := conn.NewStream(ctx, streamDesc, fullMethod)
+	stream, err := conn.NewStream(ctx, streamDesc, fullMethod, grpc.MaxCallRecvMsgSize(maxMsgSize))
 	if err != nil {
-		st, ok := status.FromError(err)
-		if !ok {
-			t.writeGRPCWebError(w, textMode, "13", err.Error())
-		} else {
-			t.writeGRPCWebError(w, textMode, fmt.Sprintf("%d", st.Code()), st.Message())
-		}
-		metrics.Failures.Add(1)
-		return
+		code, msg := statusCode(ctx, err, timeout)
+		t.writeGRPCWebError(w, textMode, code, msg)
+		return false
 	}
 
-	// Send the single request message and close the send direction.
-	if err := stream.SendMsg(&reqPayload); err != nil {
-		st, ok := status.FromError(err)
-		if !ok {
-			t.writeGRPCWebError(w, textMode, "13", err.Error())
-		} else {
-			t.writeGRPCWebError(w, textMode, fmt.Sprintf("%d", st.Code()), st.Message())
-		}
-		metrics.Failures.Add(1)
-		return
+	// Send the single request message and close the send direction. io.EOF
+	// means the backend ended the stream; RecvMsg reports its status.
+	if err := stream.SendMsg(&reqPayload); err != nil && err != io.EOF {
+		code, msg := statusCode(ctx, err, timeout)
+		t.writeGRPCWebError(w, textMode, code, msg)
+		return false
 	}
 	if err := stream.CloseSend(); err != nil {
 		t.writeGRPCWebError(w, textMode, "13", fmt.Sprintf("failed to close send: %v", err))
-		metrics.Failures.Add(1)
-		return
+		return false
 	}
 
-	// Read response headers (initial metadata).
+	// Initial metadata; on error the status is reported by RecvMsg below.
 	headerMD, _ := stream.Header()
+	idle.Reset(timeout)
 
-	// Write HTTP headers before the streaming loop.
-	if textMode {
-		w.Header().Set("Content-Type", "application/grpc-web-text+proto")
-	} else {
-		w.Header().Set("Content-Type", "application/grpc-web+proto")
-	}
-	for k, vals := range headerMD {
-		for _, v := range vals {
-			w.Header().Add(k, v)
-		}
-	}
-	w.WriteHeader(http.StatusOK)
-
-	flusher, canFlush := w.(http.Flusher)
+	fw := newFrameWriter(w, textMode)
+	fw.writeHeader(headerMD)
 
-	// Receive loop: each message becomes one gRPC-Web data frame.
 	for {
-		var respBytes []byte
-		if err := stream.RecvMsg(&respBytes); err != nil {
-			if err == io.EOF {
-				// Normal end of stream — write success trailer.
-				trailers := map[string]string{
-					"grpc-status": "0",
+		var msg []byte
+		if err := stream.RecvMsg(&msg); err != nil {
+			trailers := make(map[string]string)
+			for k, vals := range stream.Trailer() {
+				if len(vals) > 0 {
+					trailers[k] = vals[0]
 				}
-				for k, vals := range stream.Trailer() {
-					if len(vals) > 0 {
-						trailers[k] = vals[0]
-					}
-				}
-				trailerFrame := encodeTrailerFrame(trailers)
-				if textMode {
-					w.Write(base64Encode(trailerFrame))
-				} else {
-					w.Write(trailerFrame)
-				}
-				if canFlush {
-					flusher.Flush()
-				}
-				metrics.Successes.Add(1)
-				metrics.TotalLatencyNs.Add(time.Since(start).Nanoseconds())
-				return
 			}
-
-			// Error — write error trailer.
-			trailers := map[string]string{}
-			st, ok := status.FromError(err)
+			ok := err == io.EOF
 			if ok {
-				trailers["grpc-status"] = fmt.Sprintf("%d", st.Code())
-				trailers["grpc-message"] = st.Message()
+				trailers["grpc-status"] = "0"
 			} else {
-				trailers["grpc-status"] = "13"
-				trailers["grpc-message"] = err.Error()
+				trailers["grpc-status"], trailers["grpc-message"] = statusCode(ctx, err, timeout)
 			}
-			trailerFrame := encodeTrailerFrame(trailers)
-			if textMode {
-				w.Write(base64Encode(trailerFrame))
-			} else {
-				w.Write(trailerFrame)
-			}
-			if canFlush {
-				flusher.Flush()
-			}
-			metrics.Failures.Add(1)
-			return
+			fw.writeTrailer(trailers)
+			return ok
 		}
-
-		// Write data frame and flush.
-		dataFrame := encodeDataFrame(respBytes)
-		if textMode {
-			w.Write(base64Encode(dataFrame))
-		} else {
-			w.Write(dataFrame)
-		}
-		if canFlush {
-			flusher.Flush()
+		// A slow client does not count against the backend's idle time.
+		idle.Stop()
+		if err := fw.writeMessage(msg); err != nil {
+			// The client is gone; cancelling ctx ends the backend call.
+			return false
 		}
+		idle.Reset(timeout)
 	}
 }
 
-// writeGRPCWebResponse writes a successful gRPC-Web response with data frame + trailer frame.
-func (t *Translator) writeGRPCWebResponse(w http.ResponseWriter, textMode bool, data []byte, headerMD, trailerMD metadata.MD) {
-	if textMode {
-		w.Header().Set("Content-Type", "application/grpc-web-text+proto")
-	} else {
-		w.Header().Set("Content-Type", "application/grpc-web+proto")
-	}
-
-	// Forward response headers from gRPC metadata.
-	for k, vals := range headerMD {
-		for _, v := range vals {
-			w.Header().Add(k, v)
-		}
-	}
-
-	w.WriteHeader(http.StatusOK)
-
-	// Build data frame.
-	dataFrame := encodeDataFrame(data)
-
-	// Build trailer frame with grpc-status and any trailing metadata.
-	trailers := map[string]string{
-		"grpc-status": "0",
+// statusCode returns the gRPC status code and message of a failed call.
+func statusCode(ctx context.Context, err error, timeout time.Duration) (string, string) {
+	if context.Cause(ctx) == errIdleTimeout {
+		return fmt.Sprintf("%d", codes.DeadlineExceeded), fmt.Sprintf("no message from the backend for %s", timeout)
 	}
-	for k, vals := range trailerMD {
-		if len(vals) > 0 {
-			trailers[k] = vals[0]
-		}
-	}
-	trailerFrame := encodeTrailerFrame(trailers)
-
-	if textMode {
-		// In text mode, each frame is base64-encoded independently.
-		w.Write(base64Encode(dataFrame))
-		w.Write(base64Encode(trailerFrame))
-	} else {
-		w.Write(dataFrame)
-		w.Write(trailerFrame)
+	st, ok := status.FromError(err)
+	if !ok {
+		return "13", err.Error()
 	}
+	return fmt.Sprintf("%d", st.Code()), st.Message()
 }
 
 // writeGRPCWebError writes a gRPC-Web error response as a trailer-only response.
 func (t *Translator) writeGRPCWebError(w http.ResponseWriter, textMode bool, grpcStatus string, grpcMessage string) {
-	if textMode {
-		w.Header().Set("Content-Type", "application/grpc-web-text+proto")
-	} else {
-		w.Header().Set("Content-Type", "application/grpc-web+proto")
-	}
-	w.WriteHeader(http.StatusOK)
-
-	trailers := map[string]string{
+	fw := newFrameWriter(w, textMode)
+	fw.writeHeader(nil)
+	fw.writeTrailer(map[string]string{
 		"grpc-status":  grpcStatus,
 		"grpc-message": grpcMessage,
-	}
-	trailerFrame := encodeTrailerFrame(trailers)
-
-	if textMode {
-		w.Write(base64Encode(trailerFrame))
-	} else {
-		w.Write(trailerFrame)
-	}
+	})
 }
 
 // writeError writes a plain HTTP error (for non-gRPC-Web error cases like bad content type).
@@ -468,6 +350,8 @@ func extractMetadata(r *http.Request) metadata.MD {
 	for k, vals := range r.Header {
 		key := strings.ToLower(k)
 		// Skip standard HTTP headers and gRPC-Web specific headers.
+		// X-Grpc-Web-Streaming is the server-streaming hint older clients
+		// send; every call is now served as a stream.
 		switch key {
 		case "content-type", "content-length", "accept",
 			"user-agent", "host", "connection",
@@ -2,10 +2,13 @@ package grpcweb
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +16,7 @@ import (
 	"github.com/wudi/runway/internal/loadbalancer"
 	"github.com/wudi/runway/internal/proxy/protocol"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 func TestTranslatorName(t *testing.T) {
@@ -342,17 +346,25 @@ func TestRawCodec(t *testing.T) {
 	}
 }
 
-func TestWriteGRPCWebResponse(t *testing.T) {
-	tr := New()
+func TestFrameWriter(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	data := []byte("response-data")
-	tr.writeGRPCWebResponse(w, false, data, nil, nil)
+	fw := newFrameWriter(w, false)
+	fw.writeHeader(metadata.Pairs("x-backend", "b1"))
+	fw.writeMessage(data)
+	fw.writeTrailer(map[string]string{"grpc-status": "0"})
 
 	resp := w.Result()
 	if resp.Header.Get("Content-Type") != "application/grpc-web+proto" {
 		t.Errorf("Content-Type = %q, want %q", resp.Header.Get("Content-Type"), "application/grpc-web+proto")
 	}
+	if resp.Header.Get("X-Backend") != "b1" {
+		t.Errorf("X-Backend = %q, want %q", resp.Header.Get("X-Backend"), "b1")
+	}
+	if !w.Flushed {
+		t.Error("frames should be flushed")
+	}
 
 	body, _ := io.ReadAll(resp.Body)
 
@@ -382,12 +394,14 @@ func TestWriteGRPCWebResponse(t *testing.T) {
 	}
 }
 
-func TestWriteGRPCWebResponseTextMode(t *testing.T) {
-	tr := New()
+func TestFrameWriterTextMode(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	data := []byte("response-data")
-	tr.writeGRPCWebResponse(w, true, data, nil, nil)
+	fw := newFrameWriter(w, true)
+	fw.writeHeader(nil)
+	fw.writeMessage(data)
+	fw.writeTrailer(map[string]string{"grpc-status": "0"})
 
 	resp := w.Result()
 	if resp.Header.Get("Content-Type") != "application/grpc-web-text+proto" {
@@ -396,14 +410,22 @@ func TestWriteGRPCWebResponseTextMode(t *testing.T) {
 
 	body, _ := io.ReadAll(resp.Body)
 
-	// Body should be two base64-encoded frames concatenated.
-	// Decode the data frame (first base64 chunk).
-	dataFrame := encodeDataFrame(data)
-	expectedDataB64 := base64Encode(dataFrame)
-
+	// Each frame is a complete, padded base64 chunk.
+	expectedDataB64 := base64Encode(encodeDataFrame(data))
 	if !bytes.HasPrefix(body, expectedDataB64) {
 		t.Error("text mode response should start with base64-encoded data frame")
 	}
+	decoded, err := base64Decode(body)
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	reader := bytes.NewReader(decoded)
+	if f, err := decodeGRPCWebFrame(reader, 0); err != nil || !bytes.Equal(f.Payload, data) {
+		t.Fatalf("data frame: %v %v", f, err)
+	}
+	if f, err := decodeGRPCWebFrame(reader, 0); err != nil || !f.isTrailer() {
+		t.Fatalf("trailer frame: %v %v", f, err)
+	}
 }
 
 func TestWriteGRPCWebError(t *testing.T) {
@@ -443,38 +465,6 @@ func TestProtocolRegistration(t *testing.T) {
 	}
 }
 
-func TestIsServerStreaming(t *testing.T) {
-	tests := []struct {
-		name   string
-		query  string
-		header string
-		want   bool
-	}{
-		{"no signal", "", "", false},
-		{"query param", "streaming=server", "", true},
-		{"header", "", "server", true},
-		{"both", "streaming=server", "server", true},
-		{"wrong query value", "streaming=client", "", false},
-		{"wrong header value", "", "client", false},
-		{"unrelated query", "foo=bar", "", false},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			url := "/pkg.Service/Method"
-			if tt.query != "" {
-				url += "?" + tt.query
-			}
-			req := httptest.NewRequest("POST", url, nil)
-			if tt.header != "" {
-				req.Header.Set("X-Grpc-Web-Streaming", tt.header)
-			}
-			if got := isServerStreaming(req); got != tt.want {
-				t.Errorf("isServerStreaming() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func TestServeServerStreamNoBackend(t *testing.T) {
 	tr := New()
 	cfg := config.ProtocolConfig{
@@ -534,7 +524,7 @@ func TestServerStreamWritesMultipleFrames(t *testing.T) {
 	}
 
 	body := encodeDataFrame([]byte("request"))
-	req := httptest.NewRequest("POST", "/mock.StreamService/StreamMethod?streaming=server", bytes.NewReader(body))
+	req := httptest.NewRequest("POST", "/mock.StreamService/StreamMethod", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/grpc-web+proto")
 	w := httptest.NewRecorder()
 
@@ -611,7 +601,7 @@ func TestServerStreamTextMode(t *testing.T) {
 
 	rawBody := encodeDataFrame([]byte("request"))
 	encodedBody := base64Encode(rawBody)
-	req := httptest.NewRequest("POST", "/mock.StreamService/StreamMethod?streaming=server", bytes.NewReader(encodedBody))
+	req := httptest.NewRequest("POST", "/mock.StreamService/StreamMethod", bytes.NewReader(encodedBody))
 	req.Header.Set("Content-Type", "application/grpc-web-text+proto")
 	w := httptest.NewRecorder()
 
@@ -669,6 +659,255 @@ func TestServerStreamTextMode(t *testing.T) {
 	}
 }
 
+// startEchoGRPCServer starts a gRPC server with a unary Echo method and a
+// server-streaming EchoStream method served by stream. Returns the address
+// to dial.
+func startEchoGRPCServer(t *testing.T, stream grpc.StreamHandler) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer(grpc.ForceServerCodec(rawCodec{}))
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "mock.EchoService",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Echo",
+			Handler: func(_ interface{}, _ context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				var req []byte
+				if err := dec(&req); err != nil {
+					return nil, err
+				}
+				return &req, nil
+			},
+		}},
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "EchoStream",
+			ServerStreams: true,
+			Handler:       stream,
+		}},
+	}, &struct{}{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func newEchoHandler(t *testing.T, addr string, gcfg config.GRPCWebTranslateConfig) http.Handler {
+	t.Helper()
+	handler, err := New().Handler("echo-route", &mockBalancer{backend: &loadbalancer.Backend{URL: addr}},
+		config.ProtocolConfig{Type: "grpc_web", GRPCWeb: gcfg})
+	if err != nil {
+		t.Fatalf("Handler() error: %v", err)
+	}
+	return handler
+}
+
+// readFrames decodes a gRPC-Web response body into its data payloads and
+// trailers.
+func readFrames(t *testing.T, body []byte) ([]string, map[string]string) {
+	t.Helper()
+	var msgs []string
+	reader := bytes.NewReader(body)
+	for {
+		f, err := decodeGRPCWebFrame(reader, 0)
+		if err != nil {
+			t.Fatalf("response ended without a trailer frame: %v", err)
+		}
+		if f.isTrailer() {
+			return msgs, parseTrailerFrame(f.Payload)
+		}
+		msgs = append(msgs, string(f.Payload))
+	}
+}
+
+func TestStreamingEcho(t *testing.T) {
+	// Each message after the first waits for the client to have read the
+	// previous one, so the test only passes if frames are flushed as the
+	// backend sends them.
+	next := make(chan struct{})
+	addr := startEchoGRPCServer(t, func(_ interface{}, stream grpc.ServerStream) error {
+		var req []byte
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		for i := 1; i <= 3; i++ {
+			if i > 1 {
+				select {
+				case <-next:
+				case <-stream.Context().Done():
+					return stream.Context().Err()
+				}
+			}
+			msg := []byte(fmt.Sprintf("%s-%d", req, i))
+			if err := stream.SendMsg(&msg); err != nil {
+				return err
+			}
+		}
+		stream.SetTrailer(metadata.Pairs("x-echo-count", "3"))
+		return nil
+	})
+	srv := httptest.NewServer(newEchoHandler(t, addr, config.GRPCWebTranslateConfig{Timeout: 5 * time.Second}))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/mock.EchoService/EchoStream", "application/grpc-web+proto",
+		bytes.NewReader(encodeDataFrame([]byte("ping"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	for i := 1; i <= 3; i++ {
+		f, err := decodeGRPCWebFrame(resp.Body, 0)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if want := fmt.Sprintf("ping-%d", i); string(f.Payload) != want {
+			t.Errorf("frame %d = %q, want %q", i, f.Payload, want)
+		}
+		if i < 3 {
+			next <- struct{}{}
+		}
+	}
+	f, err := decodeGRPCWebFrame(resp.Body, 0)
+	if err != nil || !f.isTrailer() {
+		t.Fatalf("expected the trailer frame, got %v %v", f, err)
+	}
+	trailers := parseTrailerFrame(f.Payload)
+	if trailers["grpc-status"] != "0" || trailers["x-echo-count"] != "3" {
+		t.Errorf("unexpected trailers %v", trailers)
+	}
+}
+
+func TestStreamingEchoTextMode(t *testing.T) {
+	addr := startEchoGRPCServer(t, func(_ interface{}, stream grpc.ServerStream) error {
+		var req []byte
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		// Message lengths that leave 1, 2 and 0 bytes of the last base64
+		// group, so the chunks carry each kind of padding.
+		for _, n := range []int{1, 2, 3} {
+			msg := bytes.Repeat(req, n)
+			if err := stream.SendMsg(&msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	handler := newEchoHandler(t, addr, config.GRPCWebTranslateConfig{Timeout: 5 * time.Second, TextMode: true})
+
+	req := httptest.NewRequest("POST", "/mock.EchoService/EchoStream", bytes.NewReader(base64Encode(encodeDataFrame([]byte("ab")))))
+	req.Header.Set("Content-Type", "application/grpc-web-text+proto")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/grpc-web-text+proto" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body, err := base64Decode(w.Body.Bytes())
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	msgs, trailers := readFrames(t, body)
+	if want := []string{"ab", "abab", "ababab"}; fmt.Sprint(msgs) != fmt.Sprint(want) {
+		t.Errorf("messages = %q, want %q", msgs, want)
+	}
+	if trailers["grpc-status"] != "0" {
+		t.Errorf("grpc-status = %q, want 0", trailers["grpc-status"])
+	}
+}
+
+func TestUnaryMethodServedAsStream(t *testing.T) {
+	addr := startEchoGRPCServer(t, nil)
+	handler := newEchoHandler(t, addr, config.GRPCWebTranslateConfig{Timeout: 5 * time.Second})
+
+	req := httptest.NewRequest("POST", "/mock.EchoService/Echo", bytes.NewReader(encodeDataFrame([]byte("hello"))))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	msgs, trailers := readFrames(t, w.Body.Bytes())
+	if len(msgs) != 1 || msgs[0] != "hello" || trailers["grpc-status"] != "0" {
+		t.Errorf("unexpected response %q %v", msgs, trailers)
+	}
+
+	// An unknown method ends with the backend's status.
+	req = httptest.NewRequest("POST", "/mock.EchoService/Missing", bytes.NewReader(encodeDataFrame([]byte("hello"))))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if _, trailers := readFrames(t, w.Body.Bytes()); trailers["grpc-status"] != "12" {
+		t.Errorf("grpc-status = %q, want 12 (UNIMPLEMENTED)", trailers["grpc-status"])
+	}
+}
+
+func TestStreamIdleTimeout(t *testing.T) {
+	addr := startEchoGRPCServer(t, func(_ interface{}, stream grpc.ServerStream) error {
+		var req []byte
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		// Messages 100ms apart keep a 300ms idle timeout from firing,
+		// although the stream lasts longer; then the backend goes quiet.
+		for i := 0; i < 4; i++ {
+			time.Sleep(100 * time.Millisecond)
+			if err := stream.SendMsg(&req); err != nil {
+				return err
+			}
+		}
+		<-stream.Context().Done()
+		return nil
+	})
+	handler := newEchoHandler(t, addr, config.GRPCWebTranslateConfig{Timeout: 300 * time.Millisecond})
+
+	req := httptest.NewRequest("POST", "/mock.EchoService/EchoStream", bytes.NewReader(encodeDataFrame([]byte("tick"))))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	msgs, trailers := readFrames(t, w.Body.Bytes())
+	if len(msgs) != 4 {
+		t.Errorf("got %d messages, want 4", len(msgs))
+	}
+	if trailers["grpc-status"] != "4" || !strings.Contains(trailers["grpc-message"], "300ms") {
+		t.Errorf("expected DEADLINE_EXCEEDED for the idle stream, got %v", trailers)
+	}
+}
+
+func TestStreamMaxMessageSizePerMessage(t *testing.T) {
+	addr := startEchoGRPCServer(t, func(_ interface{}, stream grpc.ServerStream) error {
+		var req []byte
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		// Three 60-byte messages exceed the limit together but not alone.
+		for i := 0; i < 3; i++ {
+			msg := bytes.Repeat([]byte("x"), 60)
+			if err := stream.SendMsg(&msg); err != nil {
+				return err
+			}
+		}
+		big := bytes.Repeat([]byte("x"), 200)
+		return stream.SendMsg(&big)
+	})
+	handler := newEchoHandler(t, addr, config.GRPCWebTranslateConfig{Timeout: 5 * time.Second, MaxMessageSize: 100})
+
+	req := httptest.NewRequest("POST", "/mock.EchoService/EchoStream", bytes.NewReader(encodeDataFrame([]byte("go"))))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	msgs, trailers := readFrames(t, w.Body.Bytes())
+	if len(msgs) != 3 {
+		t.Errorf("got %d messages, want 3", len(msgs))
+	}
+	if trailers["grpc-status"] != "8" {
+		t.Errorf("expected RESOURCE_EXHAUSTED for the oversized message, got %v", trailers)
+	}
+}
+
 func TestExtractMetadataSkipsStreamingHeader(t *testing.T) {
 	req, _ := http.NewRequest("POST", "/test", nil)
 	req.Header.Set("X-Grpc-Web-Streaming", "server")
@@ -108,6 +108,9 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "soap: read response failed", http.StatusBadGateway)
 		return
 	}
+	if varCtx != nil {
+		varCtx.UpstreamStatus = resp.StatusCode
+	}
 
 	// Convert XML response to JSON
 	jsonBody, err := backendenc.DecodeBytes(respBody, "xml")
@@ -12,8 +12,7 @@ import (
 
 func newTestCodec(t *testing.T) (*codec, *serviceSchema) {
 	t.Helper()
-	cache := newSchemaCache()
-	schema, err := cache.getServiceSchemaFromContent("test.thrift", testIDL, "UserService")
+	schema, err := parseServiceSchemaContent("test.thrift", testIDL, "UserService")
 	if err != nil {
 		t.Fatalf("failed to parse schema: %v", err)
 	}
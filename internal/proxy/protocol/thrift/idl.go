@@ -2,25 +2,12 @@ package thrift
 
 import (
 	"fmt"
-	"sync"
 
 	"github.com/cloudwego/thriftgo/parser"
 	"github.com/cloudwego/thriftgo/semantic"
 	"github.com/wudi/runway/config"
 )
 
-// schemaCache caches parsed IDL schemas keyed by "idlFile:serviceName".
-type schemaCache struct {
-	mu      sync.RWMutex
-	schemas map[string]*serviceSchema
-}
-
-func newSchemaCache() *schemaCache {
-	return &schemaCache{
-		schemas: make(map[string]*serviceSchema),
-	}
-}
-
 // serviceSchema holds the parsed schema for a single Thrift service.
 type serviceSchema struct {
 	ast      *parser.Thrift
@@ -41,35 +28,8 @@ type methodSchema struct {
 	void       bool
 }
 
-// getServiceSchema returns a cached or freshly parsed service schema.
-func (c *schemaCache) getServiceSchema(idlFile, serviceName string) (*serviceSchema, error) {
-	key := idlFile + ":" + serviceName
-
-	c.mu.RLock()
-	if s, ok := c.schemas[key]; ok {
-		c.mu.RUnlock()
-		return s, nil
-	}
-	c.mu.RUnlock()
-
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// Double-check after acquiring write lock.
-	if s, ok := c.schemas[key]; ok {
-		return s, nil
-	}
-
-	s, err := parseServiceSchema(idlFile, serviceName)
-	if err != nil {
-		return nil, err
-	}
-	c.schemas[key] = s
-	return s, nil
-}
-
-// getServiceSchemaFromContent parses IDL from a string (used in tests).
-func (c *schemaCache) getServiceSchemaFromContent(filename, content, serviceName string) (*serviceSchema, error) {
+// parseServiceSchemaContent parses IDL from a string (used in tests).
+func parseServiceSchemaContent(filename, content, serviceName string) (*serviceSchema, error) {
 	ast, err := parser.ParseString(filename, content)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse thrift IDL: %w", err)
@@ -0,0 +1,173 @@
+package thrift
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/thriftgo/parser"
+	"go.uber.org/zap"
+
+	"github.com/wudi/runway/internal/logging"
+)
+
+// defaultIDLReloadInterval is how often IDL files are checked for changes.
+const defaultIDLReloadInterval = 10 * time.Second
+
+// schemaSet is an immutable, versioned snapshot of the parsed schemas for a
+// route's services. Reloads swap in a new set atomically.
+type schemaSet struct {
+	services map[string]*serviceSchema // service name → schema
+	version  int64
+	hash     string
+	files    []string // IDL file and its includes
+	sig      string   // file modtime/size signature, guarded by routeState.reloadMu
+	loadedAt time.Time
+}
+
+// loadSchemaSet parses idlFile for each named service. sig is the signature
+// of the files taken before parsing, so changes made while parsing are
+// picked up by the next check; pass "" to compute it from the parsed files.
+func loadSchemaSet(idlFile string, services []string, version int64, sig string) (*schemaSet, error) {
+	set := &schemaSet{
+		services: make(map[string]*serviceSchema, len(services)),
+		version:  version,
+		loadedAt: time.Now(),
+	}
+	for _, name := range services {
+		s, err := parseServiceSchema(idlFile, name)
+		if err != nil {
+			return nil, err
+		}
+		set.services[name] = s
+		if set.files == nil {
+			set.files = idlFiles(s.ast)
+		}
+	}
+
+	if sig == "" {
+		sig = signature(set.files)
+	}
+	hash, err := hashFiles(set.files)
+	if err != nil {
+		return nil, err
+	}
+	set.sig = sig
+	set.hash = hash
+	return set, nil
+}
+
+// idlFiles returns the file names of an AST and all of its includes.
+func idlFiles(ast *parser.Thrift) []string {
+	var files []string
+	seen := make(map[string]bool)
+	var walk func(*parser.Thrift)
+	walk = func(t *parser.Thrift) {
+		if t == nil || seen[t.Filename] {
+			return
+		}
+		seen[t.Filename] = true
+		files = append(files, t.Filename)
+		for _, inc := range t.Includes {
+			walk(inc.Reference)
+		}
+	}
+	walk(ast)
+	return files
+}
+
+// signature summarizes file modtimes and sizes to cheaply detect changes.
+func signature(files []string) string {
+	var b strings.Builder
+	for _, f := range files {
+		b.WriteString(f)
+		if fi, err := os.Stat(f); err == nil {
+			fmt.Fprintf(&b, "|%d|%d;", fi.ModTime().UnixNano(), fi.Size())
+		} else {
+			b.WriteString("|missing;")
+		}
+	}
+	return b.String()
+}
+
+// hashFiles returns a short content hash identifying an IDL version.
+func hashFiles(files []string) (string, error) {
+	h := sha256.New()
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return "", fmt.Errorf("reading thrift IDL: %w", err)
+		}
+		fmt.Fprintf(h, "file:%s:%d\n", f, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// reloadLoop polls the route's IDL files until done is closed.
+func (rs *routeState) reloadLoop(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			rs.checkReload()
+		}
+	}
+}
+
+// checkReload re-parses the IDL when its files changed on disk. A schema
+// that fails to parse is not applied: the previous one stays in effect and
+// the failure is reported once per change.
+func (rs *routeState) checkReload() {
+	rs.reloadMu.Lock()
+	defer rs.reloadMu.Unlock()
+
+	cur := rs.schemas.Load()
+	sig := signature(cur.files)
+	if sig == cur.sig || sig == rs.failedSig {
+		return
+	}
+
+	hash, err := hashFiles(cur.files)
+	if err == nil && hash == cur.hash {
+		cur.sig = sig
+		return
+	}
+	var next *schemaSet
+	if err == nil {
+		next, err = loadSchemaSet(rs.cfg.IDLFile, rs.serviceNames, cur.version+1, sig)
+	}
+	if err != nil {
+		rs.failedSig = sig
+		rs.reloadErrors.Add(1)
+		rs.lastError.Store(err.Error())
+		logging.Error("Thrift IDL reload failed, keeping previous schema",
+			zap.String("route", rs.routeID),
+			zap.String("idl_file", rs.cfg.IDLFile),
+			zap.Error(err),
+		)
+		return
+	}
+	if strings.Join(next.files, "\n") != strings.Join(cur.files, "\n") {
+		// Includes changed; track the new file set from now on.
+		next.sig = signature(next.files)
+	}
+
+	rs.schemas.Store(next)
+	rs.failedSig = ""
+	rs.lastError.Store("")
+	rs.reloads.Add(1)
+	logging.Info("Thrift IDL reloaded",
+		zap.String("route", rs.routeID),
+		zap.String("idl_file", rs.cfg.IDLFile),
+		zap.Int64("version", next.version),
+		zap.String("hash", next.hash),
+	)
+}
@@ -0,0 +1,151 @@
+package thrift
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+const reloadIDLv1 = `
+service UserService {
+  string getUser(1: string id)
+}
+
+service OrderService {
+  string getOrder(1: string id)
+}
+`
+
+const reloadIDLv2 = `
+service UserService {
+  string getUser(1: string id)
+  void deleteUser(1: string id)
+}
+
+service OrderService {
+  string getOrder(1: string id)
+}
+`
+
+func newReloadRoute(t *testing.T, content string) (*routeState, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "platform.thrift")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rs := &routeState{
+		routeID:      "thrift-route",
+		cfg:          config.ThriftTranslateConfig{IDLFile: path},
+		serviceNames: []string{"UserService", "OrderService"},
+	}
+	set, err := loadSchemaSet(path, rs.serviceNames, 1, "")
+	if err != nil {
+		t.Fatalf("loadSchemaSet: %v", err)
+	}
+	rs.schemas.Store(set)
+	rs.lastError.Store("")
+	return rs, path
+}
+
+func TestLoadSchemaSet(t *testing.T) {
+	rs, _ := newReloadRoute(t, reloadIDLv1)
+	set := rs.schemas.Load()
+
+	if set.version != 1 {
+		t.Errorf("version = %d, want 1", set.version)
+	}
+	if len(set.hash) != 16 {
+		t.Errorf("hash = %q, want 16 hex chars", set.hash)
+	}
+	if _, ok := set.services["UserService"].methods["getUser"]; !ok {
+		t.Error("UserService.getUser not loaded")
+	}
+	if _, ok := set.services["OrderService"].methods["getOrder"]; !ok {
+		t.Error("OrderService.getOrder not loaded")
+	}
+}
+
+func TestLoadSchemaSetUnknownService(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "platform.thrift")
+	if err := os.WriteFile(path, []byte(reloadIDLv1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSchemaSet(path, []string{"Missing"}, 1, ""); err == nil {
+		t.Error("expected error for unknown service")
+	}
+}
+
+func TestCheckReloadUnchanged(t *testing.T) {
+	rs, _ := newReloadRoute(t, reloadIDLv1)
+	before := rs.schemas.Load()
+
+	rs.checkReload()
+
+	if rs.schemas.Load() != before {
+		t.Error("schema swapped although IDL did not change")
+	}
+	if rs.reloads.Load() != 0 {
+		t.Errorf("reloads = %d, want 0", rs.reloads.Load())
+	}
+}
+
+func TestCheckReloadSwapsSchema(t *testing.T) {
+	rs, path := newReloadRoute(t, reloadIDLv1)
+	oldHash := rs.schemas.Load().hash
+
+	if err := os.WriteFile(path, []byte(reloadIDLv2), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rs.checkReload()
+
+	set := rs.schemas.Load()
+	if set.version != 2 {
+		t.Errorf("version = %d, want 2", set.version)
+	}
+	if set.hash == oldHash {
+		t.Error("hash did not change after reload")
+	}
+	if _, ok := set.services["UserService"].methods["deleteUser"]; !ok {
+		t.Error("new method deleteUser not loaded")
+	}
+	if rs.reloads.Load() != 1 {
+		t.Errorf("reloads = %d, want 1", rs.reloads.Load())
+	}
+}
+
+func TestCheckReloadKeepsSchemaOnParseError(t *testing.T) {
+	rs, path := newReloadRoute(t, reloadIDLv1)
+	before := rs.schemas.Load()
+
+	if err := os.WriteFile(path, []byte("service UserService {"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rs.checkReload()
+	// A second check of the same broken file is not reported again.
+	rs.checkReload()
+
+	if rs.schemas.Load() != before {
+		t.Error("schema swapped despite parse error")
+	}
+	if rs.reloadErrors.Load() != 1 {
+		t.Errorf("reloadErrors = %d, want 1", rs.reloadErrors.Load())
+	}
+	if rs.lastError.Load().(string) == "" {
+		t.Error("expected lastError to be set")
+	}
+
+	// Fixing the file recovers.
+	if err := os.WriteFile(path, []byte(reloadIDLv2), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rs.checkReload()
+
+	if rs.schemas.Load().version != 2 {
+		t.Errorf("version = %d, want 2", rs.schemas.Load().version)
+	}
+	if rs.lastError.Load().(string) != "" {
+		t.Errorf("lastError = %q, want empty", rs.lastError.Load())
+	}
+}
@@ -55,8 +55,7 @@ service UserService {
 `
 
 func TestParseServiceSchema(t *testing.T) {
-	cache := newSchemaCache()
-	schema, err := cache.getServiceSchemaFromContent("test.thrift", testIDL, "UserService")
+	schema, err := parseServiceSchemaContent("test.thrift", testIDL, "UserService")
 	if err != nil {
 		t.Fatalf("failed to parse schema: %v", err)
 	}
@@ -120,16 +119,14 @@ func TestParseServiceSchema(t *testing.T) {
 }
 
 func TestParseServiceSchema_MissingService(t *testing.T) {
-	cache := newSchemaCache()
-	_, err := cache.getServiceSchemaFromContent("test.thrift", testIDL, "NonExistentService")
+	_, err := parseServiceSchemaContent("test.thrift", testIDL, "NonExistentService")
 	if err == nil {
 		t.Fatal("expected error for missing service")
 	}
 }
 
 func TestParseServiceSchema_InvalidIDL(t *testing.T) {
-	cache := newSchemaCache()
-	_, err := cache.getServiceSchemaFromContent("test.thrift", "invalid thrift content {{{", "MyService")
+	_, err := parseServiceSchemaContent("test.thrift", "invalid thrift content {{{", "MyService")
 	if err == nil {
 		t.Fatal("expected error for invalid IDL")
 	}
@@ -137,8 +134,7 @@ func TestParseServiceSchema_InvalidIDL(t *testing.T) {
 
 func TestFieldCategories(t *testing.T) {
 	// Verify that semantic resolution fills in correct categories.
-	cache := newSchemaCache()
-	schema, err := cache.getServiceSchemaFromContent("test.thrift", testIDL, "UserService")
+	schema, err := parseServiceSchemaContent("test.thrift", testIDL, "UserService")
 	if err != nil {
 		t.Fatalf("failed to parse: %v", err)
 	}
@@ -170,8 +166,7 @@ func TestFieldCategories(t *testing.T) {
 }
 
 func TestMethodArgs(t *testing.T) {
-	cache := newSchemaCache()
-	schema, err := cache.getServiceSchemaFromContent("test.thrift", testIDL, "UserService")
+	schema, err := parseServiceSchemaContent("test.thrift", testIDL, "UserService")
 	if err != nil {
 		t.Fatalf("failed to parse schema: %v", err)
 	}
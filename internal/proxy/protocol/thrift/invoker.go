@@ -19,8 +19,9 @@ func newInvoker() *invoker {
 	return &invoker{}
 }
 
-// invokeMethod sends a Thrift RPC and reads the response.
-func (inv *invoker) invokeMethod(ctx context.Context, iprot, oprot thrift.TProtocol, c *codec, schema *serviceSchema, methodName string, jsonBody []byte, multiplexed bool) ([]byte, error) {
+// invokeMethod sends a Thrift RPC and reads the response. A non-empty
+// multiplexedName prefixes the method name as TMultiplexedProtocol does.
+func (inv *invoker) invokeMethod(ctx context.Context, iprot, oprot thrift.TProtocol, c *codec, schema *serviceSchema, methodName string, jsonBody []byte, multiplexedName string) ([]byte, error) {
 	ms, ok := schema.methods[methodName]
 	if !ok {
 		return nil, thrift.NewTApplicationException(thrift.UNKNOWN_METHOD, fmt.Sprintf("unknown method: %s", methodName))
@@ -43,8 +44,8 @@ func (inv *invoker) invokeMethod(ctx context.Context, iprot, oprot thrift.TProto
 
 	// Determine wire method name.
 	wireName := methodName
-	if multiplexed {
-		wireName = schema.service.Name + ":" + methodName
+	if multiplexedName != "" {
+		wireName = multiplexedName + ":" + methodName
 	}
 
 	// Determine message type.
@@ -21,12 +21,14 @@ type compiledMapping struct {
 	httpMethod   string
 	pattern      *regexp.Regexp
 	paramNames   []string
+	service      string
 	thriftMethod string
 	body         string // "*" = whole body, "field" = nested, "" = no body
 }
 
 // matchResult contains the matched mapping and extracted parameters.
 type matchResult struct {
+	service      string
 	thriftMethod string
 	pathParams   map[string]string
 	body         string
@@ -48,6 +50,7 @@ func newRESTMapper(service string, mappings []config.ThriftMethodMapping) (*rest
 			httpMethod:   m.HTTPMethod,
 			pattern:      pattern,
 			paramNames:   paramNames,
+			service:      service,
 			thriftMethod: m.ThriftMethod,
 			body:         m.Body,
 		})
@@ -59,6 +62,23 @@ func newRESTMapper(service string, mappings []config.ThriftMethodMapping) (*rest
 	}, nil
 }
 
+// newServicesMapper creates a REST mapper over the mappings of several
+// multiplexed services. Mappings are tried in config order and the first
+// match selects the service.
+func newServicesMapper(services []config.ThriftServiceConfig) (*restMapper, error) {
+	m := &restMapper{}
+	for _, svc := range services {
+		sm, err := newRESTMapper(svc.Name, svc.Mappings)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+		if sm != nil {
+			m.mappings = append(m.mappings, sm.mappings...)
+		}
+	}
+	return m, nil
+}
+
 // compilePathPattern converts a path pattern like /users/:user_id or /users/{user_id}
 // into a regex and extracts parameter names.
 func compilePathPattern(pattern string) (*regexp.Regexp, []string, error) {
@@ -121,6 +141,7 @@ func (m *restMapper) match(method, path string) *matchResult {
 		}
 
 		return &matchResult{
+			service:      mapping.service,
 			thriftMethod: mapping.thriftMethod,
 			pathParams:   params,
 			body:         mapping.body,
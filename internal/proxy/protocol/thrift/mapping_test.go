@@ -200,3 +200,46 @@ func assertJSONContains(t *testing.T, data []byte, key, expected string) {
 		t.Errorf("key %q = %q, want %q", key, str, expected)
 	}
 }
+
+func TestNewServicesMapper(t *testing.T) {
+	mapper, err := newServicesMapper([]config.ThriftServiceConfig{
+		{
+			Name: "UserService",
+			Mappings: []config.ThriftMethodMapping{
+				{HTTPMethod: "GET", HTTPPath: "/users/:id", ThriftMethod: "getUser"},
+			},
+		},
+		{
+			Name:            "OrderService",
+			MultiplexedName: "orders",
+			Mappings: []config.ThriftMethodMapping{
+				{HTTPMethod: "GET", HTTPPath: "/orders/:id", ThriftMethod: "getOrder"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newServicesMapper failed: %v", err)
+	}
+
+	tests := []struct {
+		path        string
+		wantService string
+		wantMethod  string
+	}{
+		{"/users/1", "UserService", "getUser"},
+		{"/orders/2", "OrderService", "getOrder"},
+	}
+	for _, tt := range tests {
+		result := mapper.match("GET", tt.path)
+		if result == nil {
+			t.Fatalf("no match for %s", tt.path)
+		}
+		if result.service != tt.wantService || result.thriftMethod != tt.wantMethod {
+			t.Errorf("match(%s) = %s.%s, want %s.%s", tt.path, result.service, result.thriftMethod, tt.wantService, tt.wantMethod)
+		}
+	}
+
+	if mapper.match("GET", "/products/3") != nil {
+		t.Error("expected no match for unmapped path")
+	}
+}
@@ -0,0 +1,76 @@
+package thrift
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/wudi/runway/config"
+)
+
+// sharedPools holds the Thrift connection pools of every translator. A new
+// translator is created for each route on every config reload, so pools are
+// keyed by connection settings and reference counted: a reload that keeps a
+// route's settings acquires the existing pool before the old route releases
+// it, and the open connections survive the reload.
+var sharedPools = &poolRegistry{pools: make(map[string]*connPool)}
+
+// poolRegistry tracks reference-counted connection pools.
+type poolRegistry struct {
+	mu    sync.Mutex
+	pools map[string]*connPool
+}
+
+// connPool holds pooled connections for one set of connection settings.
+type connPool struct {
+	key   string
+	refs  int
+	conns sync.Map // backend URL → *connEntry
+}
+
+// poolKey identifies the connection settings a pooled connection was built with.
+func poolKey(cfg config.ThriftTranslateConfig) string {
+	return fmt.Sprintf("%s|%s|%s|%t|%s|%s|%s", cfg.Protocol, cfg.Transport, cfg.Timeout,
+		cfg.TLS.Enabled, cfg.TLS.CAFile, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+}
+
+// acquire returns the pool for cfg's connection settings, creating it if needed.
+func (r *poolRegistry) acquire(cfg config.ThriftTranslateConfig) *connPool {
+	key := poolKey(cfg)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.pools[key]
+	if !ok {
+		p = &connPool{key: key}
+		r.pools[key] = p
+	}
+	p.refs++
+	return p
+}
+
+// release drops a reference and closes the pool's connections when it was the last.
+func (r *poolRegistry) release(p *connPool) {
+	r.mu.Lock()
+	p.refs--
+	last := p.refs <= 0
+	if last {
+		delete(r.pools, p.key)
+	}
+	r.mu.Unlock()
+
+	if last {
+		p.closeAll()
+	}
+}
+
+// closeAll closes and removes every pooled connection.
+func (p *connPool) closeAll() {
+	p.conns.Range(func(key, value interface{}) bool {
+		if entry, ok := value.(*connEntry); ok {
+			entry.transport.Close()
+		}
+		p.conns.Delete(key)
+		return true
+	})
+}
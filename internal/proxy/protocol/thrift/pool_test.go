@@ -0,0 +1,38 @@
+package thrift
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func TestPoolRegistryShared(t *testing.T) {
+	r := &poolRegistry{pools: make(map[string]*connPool)}
+	cfg := config.ThriftTranslateConfig{Protocol: "binary", Transport: "framed"}
+
+	a := r.acquire(cfg)
+	b := r.acquire(cfg)
+	if a != b {
+		t.Fatal("expected the same pool for identical connection settings")
+	}
+
+	r.release(a)
+	if _, ok := r.pools[a.key]; !ok {
+		t.Fatal("pool removed while still referenced")
+	}
+	r.release(b)
+	if _, ok := r.pools[a.key]; ok {
+		t.Fatal("pool not removed after last release")
+	}
+}
+
+func TestPoolRegistryDistinctSettings(t *testing.T) {
+	r := &poolRegistry{pools: make(map[string]*connPool)}
+
+	a := r.acquire(config.ThriftTranslateConfig{Transport: "framed"})
+	b := r.acquire(config.ThriftTranslateConfig{Transport: "framed", Timeout: 5 * time.Second})
+	if a == b {
+		t.Fatal("expected distinct pools for different connection settings")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	athrift "github.com/apache/thrift/lib/go/thrift"
@@ -28,24 +29,46 @@ type connEntry struct {
 
 // Translator implements HTTP-to-Thrift protocol translation.
 type Translator struct {
-	routeMetrics map[string]*protocol.RouteMetrics
-	routeMappers map[string]*restMapper
-	routeSchemas map[string]*serviceSchema
-	metricsMu    sync.RWMutex
-
-	connPool    sync.Map // backend URL → *connEntry
-	schemaCache *schemaCache
-	invoker     *invoker
+	routes    map[string]*routeState
+	metricsMu sync.RWMutex
+
+	invoker *invoker
+}
+
+// routeState holds the per-route translation state.
+type routeState struct {
+	routeID  string
+	cfg      config.ThriftTranslateConfig
+	metrics  *protocol.RouteMetrics
+	mapper   *restMapper
+	services map[string]*routeService
+	pool     *connPool
+	timeout  time.Duration
+
+	// serviceNames lists the services parsed from the IDL, in config order.
+	serviceNames []string
+	schemas      atomic.Pointer[schemaSet]
+
+	reloadMu     sync.Mutex // serializes IDL reloads
+	failedSig    string     // signature of the last IDL that failed to parse
+	reloads      atomic.Int64
+	reloadErrors atomic.Int64
+	lastError    atomic.Value // string
+	done         chan struct{}
+}
+
+// routeService is a Thrift service reachable through a route.
+type routeService struct {
+	name     string
+	wireName string // multiplexed service name; empty when not multiplexed
+	calls    atomic.Int64
 }
 
 // New creates a new Thrift translator.
 func New() *Translator {
 	return &Translator{
-		routeMetrics: make(map[string]*protocol.RouteMetrics),
-		routeMappers: make(map[string]*restMapper),
-		routeSchemas: make(map[string]*serviceSchema),
-		schemaCache:  newSchemaCache(),
-		invoker:      newInvoker(),
+		routes:  make(map[string]*routeState),
+		invoker: newInvoker(),
 	}
 }
 
@@ -56,52 +79,93 @@ func (t *Translator) Name() string {
 
 // Handler returns an http.Handler that translates HTTP/JSON to Thrift.
 func (t *Translator) Handler(routeID string, balancer loadbalancer.Balancer, cfg config.ProtocolConfig) (http.Handler, error) {
-	// Load schema from IDL file or inline config.
-	var schema *serviceSchema
+	rs := &routeState{
+		routeID:  routeID,
+		cfg:      cfg.Thrift,
+		metrics:  &protocol.RouteMetrics{},
+		services: make(map[string]*routeService),
+		timeout:  cfg.Thrift.Timeout,
+		done:     make(chan struct{}),
+	}
+	if rs.timeout == 0 {
+		rs.timeout = 30 * time.Second
+	}
+
+	// Services are either a list of multiplexed services selected by their
+	// mappings, or the single configured service.
 	var err error
+	if len(cfg.Thrift.Services) > 0 {
+		for _, svc := range cfg.Thrift.Services {
+			wireName := svc.MultiplexedName
+			if wireName == "" {
+				wireName = svc.Name
+			}
+			rs.services[svc.Name] = &routeService{name: svc.Name, wireName: wireName}
+			rs.serviceNames = append(rs.serviceNames, svc.Name)
+		}
+		rs.mapper, err = newServicesMapper(cfg.Thrift.Services)
+	} else {
+		svc := &routeService{name: cfg.Thrift.Service}
+		if cfg.Thrift.Multiplexed {
+			svc.wireName = cfg.Thrift.Service
+		}
+		rs.services[svc.name] = svc
+		rs.serviceNames = []string{svc.name}
+		rs.mapper, err = newRESTMapper(cfg.Thrift.Service, cfg.Thrift.Mappings)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create REST mapper: %w", err)
+	}
+
+	// Load schema from IDL file or inline config.
+	var set *schemaSet
 	if cfg.Thrift.IDLFile != "" {
-		schema, err = t.schemaCache.getServiceSchema(cfg.Thrift.IDLFile, cfg.Thrift.Service)
+		set, err = loadSchemaSet(cfg.Thrift.IDLFile, rs.serviceNames, 1, "")
 	} else {
+		var schema *serviceSchema
 		schema, err = buildServiceSchemaFromConfig(cfg.Thrift)
+		if err == nil {
+			set = &schemaSet{
+				services: map[string]*serviceSchema{cfg.Thrift.Service: schema},
+				version:  1,
+				loadedAt: time.Now(),
+			}
+		}
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to load thrift schema: %w", err)
 	}
+	rs.schemas.Store(set)
+	rs.lastError.Store("")
+
+	// Acquire the pool before releasing a replaced route so that unchanged
+	// connection settings keep their open connections.
+	rs.pool = sharedPools.acquire(cfg.Thrift)
 
-	// Initialize route metrics.
 	t.metricsMu.Lock()
-	t.routeMetrics[routeID] = &protocol.RouteMetrics{}
-	t.routeSchemas[routeID] = schema
+	if old, ok := t.routes[routeID]; ok {
+		t.closeRoute(old)
+	}
+	t.routes[routeID] = rs
 	t.metricsMu.Unlock()
 
-	// Create REST mapper if mappings are configured.
-	if len(cfg.Thrift.Mappings) > 0 {
-		mapper, err := newRESTMapper(cfg.Thrift.Service, cfg.Thrift.Mappings)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create REST mapper: %w", err)
+	if cfg.Thrift.IDLFile != "" {
+		interval := cfg.Thrift.IDLReloadInterval
+		if interval <= 0 {
+			interval = defaultIDLReloadInterval
 		}
-		t.metricsMu.Lock()
-		t.routeMappers[routeID] = mapper
-		t.metricsMu.Unlock()
-	}
-
-	timeout := cfg.Thrift.Timeout
-	if timeout == 0 {
-		timeout = 30 * time.Second
+		go rs.reloadLoop(interval, rs.done)
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		t.serveHTTP(w, r, routeID, balancer, cfg, schema, timeout)
+		t.serveHTTP(w, r, rs, balancer)
 	}), nil
 }
 
-func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, routeID string, balancer loadbalancer.Balancer, cfg config.ProtocolConfig, schema *serviceSchema, timeout time.Duration) {
+func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, rs *routeState, balancer loadbalancer.Balancer) {
 	start := time.Now()
-
-	t.metricsMu.RLock()
-	metrics := t.routeMetrics[routeID]
-	mapper := t.routeMappers[routeID]
-	t.metricsMu.RUnlock()
+	metrics := rs.metrics
+	cfg := rs.cfg
 
 	metrics.Requests.Add(1)
 
@@ -115,23 +179,25 @@ func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, routeID s
 
 	var methodName string
 	var requestBody []byte
+	serviceName := cfg.Service
 
 	// Priority order:
 	// 1. Fixed method (method in config)
-	// 2. REST mappings (if configured)
+	// 2. REST mappings (if configured); the matched mapping selects the service
 	// 3. Path-based resolution (last segment)
-	if cfg.Thrift.Method != "" {
-		methodName = cfg.Thrift.Method
+	if cfg.Method != "" {
+		methodName = cfg.Method
 		requestBody = rawBody
-	} else if mapper != nil {
-		match := mapper.match(r.Method, r.URL.Path)
+	} else if rs.mapper != nil {
+		match := rs.mapper.match(r.Method, r.URL.Path)
 		if match == nil {
 			t.writeError(w, http.StatusNotFound, fmt.Sprintf("no mapping found for %s %s", r.Method, r.URL.Path))
 			metrics.Failures.Add(1)
 			return
 		}
 		methodName = match.thriftMethod
-		requestBody, err = mapper.buildRequestBody(r, match, rawBody)
+		serviceName = match.service
+		requestBody, err = rs.mapper.buildRequestBody(r, match, rawBody)
 		if err != nil {
 			t.writeError(w, http.StatusBadRequest, err.Error())
 			metrics.Failures.Add(1)
@@ -147,6 +213,10 @@ func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, routeID s
 		requestBody = rawBody
 	}
 
+	svc := rs.services[serviceName]
+	schema := rs.schemas.Load().services[serviceName]
+	svc.calls.Add(1)
+
 	// Select backend.
 	backend := balancer.Next()
 	if backend == nil {
@@ -156,7 +226,7 @@ func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, routeID s
 	}
 
 	// Get or create connection.
-	conn, err := t.getConnection(backend.URL, cfg.Thrift)
+	conn, err := getConnection(rs.pool, backend.URL, cfg)
 	if err != nil {
 		t.writeError(w, http.StatusBadGateway, fmt.Sprintf("failed to connect to backend: %v", err))
 		metrics.Failures.Add(1)
@@ -168,7 +238,7 @@ func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, routeID s
 	defer conn.mu.Unlock()
 
 	// Create context with timeout.
-	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	ctx, cancel := context.WithTimeout(r.Context(), rs.timeout)
 	defer cancel()
 
 	// Build codec from schema.
@@ -179,7 +249,7 @@ func (t *Translator) serveHTTP(w http.ResponseWriter, r *http.Request, routeID s
 	}
 
 	// Invoke the method.
-	respJSON, err := t.invoker.invokeMethod(ctx, conn.iprot, conn.oprot, c, schema, methodName, requestBody, cfg.Thrift.Multiplexed)
+	respJSON, err := t.invoker.invokeMethod(ctx, conn.iprot, conn.oprot, c, schema, methodName, requestBody, svc.wireName)
 	if err != nil {
 		httpStatus := ThriftExceptionToHTTP(err)
 		t.writeError(w, httpStatus, err.Error())
@@ -207,14 +277,14 @@ func resolveMethodFromPath(path string) string {
 }
 
 // getConnection returns a pooled Thrift connection, creating one if needed.
-func (t *Translator) getConnection(backendURL string, cfg config.ThriftTranslateConfig) (*connEntry, error) {
-	if existing, ok := t.connPool.Load(backendURL); ok {
+func getConnection(pool *connPool, backendURL string, cfg config.ThriftTranslateConfig) (*connEntry, error) {
+	if existing, ok := pool.conns.Load(backendURL); ok {
 		entry := existing.(*connEntry)
 		if entry.transport.IsOpen() {
 			return entry, nil
 		}
 		// Connection closed — remove and recreate.
-		t.connPool.Delete(backendURL)
+		pool.conns.CompareAndDelete(backendURL, entry)
 	}
 
 	// Parse backend URL to get host:port.
@@ -277,7 +347,7 @@ func (t *Translator) getConnection(backendURL string, cfg config.ThriftTranslate
 	}
 
 	// Store in pool (race-safe).
-	actual, loaded := t.connPool.LoadOrStore(backendURL, entry)
+	actual, loaded := pool.conns.LoadOrStore(backendURL, entry)
 	if loaded {
 		// Another goroutine stored first — close ours and use theirs.
 		transport.Close()
@@ -324,22 +394,34 @@ func (t *Translator) writeError(w http.ResponseWriter, status int, message strin
 // Close releases resources for the specified route.
 func (t *Translator) Close(routeID string) error {
 	t.metricsMu.Lock()
-	delete(t.routeMetrics, routeID)
-	delete(t.routeMappers, routeID)
-	delete(t.routeSchemas, routeID)
+	rs, ok := t.routes[routeID]
+	delete(t.routes, routeID)
 	t.metricsMu.Unlock()
+	if ok {
+		t.closeRoute(rs)
+	}
 	return nil
 }
 
-// CloseAll closes all Thrift connections.
+// closeRoute stops the route's IDL watcher and releases its connection pool.
+// Pooled connections are only closed when no other route still uses them.
+func (t *Translator) closeRoute(rs *routeState) {
+	close(rs.done)
+	if rs.pool != nil {
+		sharedPools.release(rs.pool)
+	}
+}
+
+// CloseAll releases all routes and their Thrift connections.
 func (t *Translator) CloseAll() {
-	t.connPool.Range(func(key, value interface{}) bool {
-		if entry, ok := value.(*connEntry); ok {
-			entry.transport.Close()
-		}
-		t.connPool.Delete(key)
-		return true
-	})
+	t.metricsMu.Lock()
+	routes := t.routes
+	t.routes = make(map[string]*routeState)
+	t.metricsMu.Unlock()
+
+	for _, rs := range routes {
+		t.closeRoute(rs)
+	}
 }
 
 // Metrics returns metrics for the specified route.
@@ -347,8 +429,26 @@ func (t *Translator) Metrics(routeID string) *protocol.TranslatorMetrics {
 	t.metricsMu.RLock()
 	defer t.metricsMu.RUnlock()
 
-	if m, ok := t.routeMetrics[routeID]; ok {
-		return m.Snapshot(t.Name())
+	rs, ok := t.routes[routeID]
+	if !ok {
+		return nil
 	}
-	return nil
+	m := rs.metrics.Snapshot(t.Name())
+	m.ServiceCalls = make(map[string]int64, len(rs.services))
+	for name, svc := range rs.services {
+		m.ServiceCalls[name] = svc.calls.Load()
+	}
+	if rs.cfg.IDLFile != "" {
+		set := rs.schemas.Load()
+		m.Schema = &protocol.SchemaInfo{
+			Source:       rs.cfg.IDLFile,
+			Version:      set.version,
+			Hash:         set.hash,
+			LoadedAt:     set.loadedAt,
+			Reloads:      rs.reloads.Load(),
+			ReloadErrors: rs.reloadErrors.Load(),
+			LastError:    rs.lastError.Load().(string),
+		}
+	}
+	return m
 }
@@ -1,7 +1,11 @@
 package thrift
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/wudi/runway/config"
 )
 
 func TestResolveMethodFromPath(t *testing.T) {
@@ -49,3 +53,41 @@ func TestTranslatorClose(t *testing.T) {
 		t.Errorf("Close returned error: %v", err)
 	}
 }
+
+func TestTranslatorMetricsServices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "platform.thrift")
+	if err := os.WriteFile(path, []byte(reloadIDLv1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := New()
+	defer tr.CloseAll()
+	_, err := tr.Handler("thrift-route", nil, config.ProtocolConfig{
+		Type: "http_to_thrift",
+		Thrift: config.ThriftTranslateConfig{
+			IDLFile: path,
+			Services: []config.ThriftServiceConfig{
+				{Name: "UserService", Mappings: []config.ThriftMethodMapping{
+					{HTTPMethod: "GET", HTTPPath: "/users/:id", ThriftMethod: "getUser"},
+				}},
+				{Name: "OrderService", Mappings: []config.ThriftMethodMapping{
+					{HTTPMethod: "GET", HTTPPath: "/orders/:id", ThriftMethod: "getOrder"},
+				}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Handler failed: %v", err)
+	}
+
+	m := tr.Metrics("thrift-route")
+	if m == nil {
+		t.Fatal("expected metrics")
+	}
+	if m.Schema == nil || m.Schema.Version != 1 || m.Schema.Hash == "" {
+		t.Errorf("unexpected schema info: %+v", m.Schema)
+	}
+	if len(m.ServiceCalls) != 2 {
+		t.Errorf("ServiceCalls = %v, want 2 services", m.ServiceCalls)
+	}
+}
@@ -4,6 +4,7 @@ package protocol
 import (
 	"net/http"
 	"sync/atomic"
+	"time"
 
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/loadbalancer"
@@ -33,6 +34,21 @@ type TranslatorMetrics struct {
 	Failures     int64   `json:"failures"`
 	AvgLatencyMs float64 `json:"avg_latency_ms"`
 	ProtocolType string  `json:"protocol_type"`
+
+	// Optional translator-specific details.
+	Schema       *SchemaInfo      `json:"schema,omitempty"`
+	ServiceCalls map[string]int64 `json:"service_calls,omitempty"`
+}
+
+// SchemaInfo describes a schema loaded from a file (e.g. a Thrift IDL).
+type SchemaInfo struct {
+	Source       string    `json:"source"`
+	Version      int64     `json:"version"`
+	Hash         string    `json:"hash"`
+	LoadedAt     time.Time `json:"loaded_at"`
+	Reloads      int64     `json:"reloads"`
+	ReloadErrors int64     `json:"reload_errors"`
+	LastError    string    `json:"last_error,omitempty"`
 }
 
 // RouteMetrics provides atomic counters for per-route metrics.
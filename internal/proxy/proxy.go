@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strings"
 	"sync"
@@ -123,6 +124,13 @@ func (p *Proxy) HandlerWithPolicy(route *router.Route, balancer loadbalancer.Bal
 		varCtx := variables.GetFromRequest(r)
 		varCtx.RouteID = route.ID
 
+		// The client went away while earlier middleware ran; don't call the backend
+		if r.Context().Err() == context.Canceled {
+			varCtx.ClientAbort = variables.AbortBeforeBackend
+			w.WriteHeader(variables.StatusClientClosedRequest)
+			return
+		}
+
 		// Set timeout: only create a new context deadline if the incoming context
 		// has none (i.e., no timeout middleware already set one).
 		ctx := r.Context()
@@ -138,12 +146,23 @@ func (p *Proxy) HandlerWithPolicy(route *router.Route, balancer loadbalancer.Bal
 			defer cancel()
 		}
 
+		// Forward backend 1xx responses straight to the client connection
+		if route.ForwardInformational && varCtx.ClientWriter != nil {
+			fwd := &informationalForwarder{w: varCtx.ClientWriter}
+			ctx = httptrace.WithClientTrace(ctx, fwd.trace())
+			defer fwd.finish()
+		}
+
 		start := time.Now()
 		var resp *http.Response
 		var err error
 		var backendURL string
 
-		if retryPolicy != nil && retryPolicy.Hedging != nil {
+		// Hedging buffers the body up front, which would answer the client's
+		// 100-continue before any backend has seen the headers.
+		expectContinue := expectsContinue(r, route.ExpectContinueThreshold)
+
+		if retryPolicy != nil && retryPolicy.Hedging != nil && !expectContinue {
 			// Hedging path: let hedging executor pick backends and send concurrent requests
 			// Buffer the body so it can be reused across hedged requests
 			var bodyBytes []byte
@@ -179,7 +198,7 @@ func (p *Proxy) HandlerWithPolicy(route *router.Route, balancer loadbalancer.Bal
 
 			// Check for switch_backend override from rule actions
 			if varCtx.Overrides != nil && varCtx.Overrides.SwitchBackend != "" {
-				if b := balancer.GetBackendByURL(varCtx.Overrides.SwitchBackend); b != nil && b.Healthy {
+				if b := balancer.GetBackendByURL(varCtx.Overrides.SwitchBackend); b != nil && b.InRotation() {
 					backend = b
 				}
 			}
@@ -202,6 +221,7 @@ func (p *Proxy) HandlerWithPolicy(route *router.Route, balancer loadbalancer.Bal
 				backend = balancer.Next()
 			}
 			if backend == nil {
+				variables.SetErrorReason(r, errors.ReasonNoHealthyBackends)
 				errors.ErrServiceUnavailable.WithDetails("No healthy backends available").WriteJSON(w)
 				return
 			}
@@ -223,6 +243,9 @@ func (p *Proxy) HandlerWithPolicy(route *router.Route, balancer loadbalancer.Bal
 			pooledHeader := acquireProxyHeader()
 			defer releaseProxyHeader(pooledHeader)
 			proxyReq := p.createProxyRequest(ctx, r, targetURL, route, varCtx, pooledHeader)
+			if expectContinue {
+				proxyReq.Header["Expect"] = expectContinueValue
+			}
 
 			if retryPolicy != nil {
 				resp, err = retryPolicy.Execute(ctx, transport, proxyReq)
@@ -262,11 +285,25 @@ func (p *Proxy) HandlerWithPolicy(route *router.Route, balancer loadbalancer.Bal
 		// Copy response headers
 		p.copyHeaders(w.Header(), resp.Header)
 
+		// Re-announce trailers (Trailer is hop-by-hop and removed above)
+		announced := len(resp.Trailer)
+		if announced > 0 {
+			announceTrailers(w.Header(), resp.Trailer)
+		}
+
 		// Write status code
 		w.WriteHeader(resp.StatusCode)
 
-		// Copy response body
-		p.copyBody(w, resp.Body)
+		// Copy response body; stop reading from the backend once the client is gone
+		if p.copyBody(w, r, resp.Body) {
+			varCtx.ClientAbort = variables.AbortDuringResponse
+			return
+		}
+
+		// Trailer values are only known once the body has been read
+		if len(resp.Trailer) > 0 {
+			copyTrailers(w, resp.Trailer, announced)
+		}
 	})
 }
 
@@ -392,11 +429,26 @@ func (p *Proxy) handleError(w http.ResponseWriter, r *http.Request, err error, b
 	// detector (when configured). Individual proxy errors should not
 	// permanently eject backends — that causes cascading failures under load.
 
+	// The backend call was canceled because the client went away
+	if r.Context().Err() == context.Canceled {
+		variables.GetFromRequest(r).ClientAbort = variables.AbortDuringBackend
+		w.WriteHeader(variables.StatusClientClosedRequest)
+		return
+	}
+
 	if err == context.DeadlineExceeded {
+		// An expired request deadline is answered by the timeout middleware;
+		// only the backend's own deadline is reported here.
+		if r.Context().Err() == nil {
+			variables.GetFromRequest(r).TimeoutReason = variables.TimeoutBackend
+			variables.SetErrorReason(r, errors.ReasonUpstreamTimeout)
+			w.Header().Set("X-Timeout-Reason", variables.TimeoutBackend)
+		}
 		errors.ErrGatewayTimeout.WriteJSON(w)
 		return
 	}
 
+	variables.SetErrorReason(r, errors.ReasonUpstreamError)
 	errors.ErrBadGateway.WithDetails(err.Error()).WriteJSON(w)
 }
 
@@ -420,31 +472,33 @@ var copyBufPool = sync.Pool{
 	},
 }
 
-// copyBody copies the response body
-func (p *Proxy) copyBody(w http.ResponseWriter, body io.Reader) {
+// copyBody copies the response body, flushing after each read when a
+// flush interval is set. It reports whether the copy stopped because the
+// client went away: a write to the client failed, or reading the body was
+// canceled along with the client's request.
+func (p *Proxy) copyBody(w http.ResponseWriter, r *http.Request, body io.Reader) bool {
+	var flusher http.Flusher
 	if p.flushInterval > 0 {
-		// Streaming copy with flush
-		if flusher, ok := w.(http.Flusher); ok {
-			bp := copyBufPool.Get().(*[]byte)
-			buf := *bp
-			for {
-				n, err := body.Read(buf)
-				if n > 0 {
-					w.Write(buf[:n])
-					flusher.Flush()
-				}
-				if err != nil {
-					break
-				}
-			}
-			copyBufPool.Put(bp)
-			return
-		}
+		flusher, _ = w.(http.Flusher)
 	}
 
 	bp := copyBufPool.Get().(*[]byte)
-	io.CopyBuffer(w, body, *bp)
-	copyBufPool.Put(bp)
+	defer copyBufPool.Put(bp)
+	buf := *bp
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return true
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return err != io.EOF && r.Context().Err() == context.Canceled
+		}
+	}
 }
 
 // hopHeaders lists hop-by-hop headers that should be removed.
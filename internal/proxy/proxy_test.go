@@ -1,14 +1,19 @@
 package proxy
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/loadbalancer"
 	"github.com/wudi/runway/internal/router"
+	"github.com/wudi/runway/variables"
 )
 
 func TestProxy(t *testing.T) {
@@ -457,3 +462,85 @@ func TestProxyStripPrefixStillWorks(t *testing.T) {
 		t.Errorf("Expected path /users/456, got %s", receivedPath)
 	}
 }
+
+// failingWriter accepts headers but fails every body write, as a connection
+// closed by the client does.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestProxyClientAbort(t *testing.T) {
+	arrived := make(chan struct{}, 1)
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/slow" {
+			arrived <- struct{}{}
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(strings.Repeat("x", 64*1024)))
+	}))
+	defer backend.Close()
+
+	route := &router.Route{ID: "test", Path: "/"}
+	balancer := loadbalancer.NewRoundRobin([]*loadbalancer.Backend{
+		{URL: backend.URL, Weight: 1, Healthy: true},
+	})
+	handler := New(Config{}).Handler(route, balancer)
+
+	serve := func(ctx context.Context, w http.ResponseWriter, path string) *variables.Context {
+		req := httptest.NewRequest("GET", path, nil)
+		varCtx := variables.NewContext(req)
+		handler.ServeHTTP(w, req.WithContext(context.WithValue(ctx, variables.RequestContextKey{}, varCtx)))
+		return varCtx
+	}
+
+	t.Run("before backend", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		rr := httptest.NewRecorder()
+		varCtx := serve(ctx, rr, "/fast")
+		if varCtx.ClientAbort != variables.AbortBeforeBackend || rr.Code != variables.StatusClientClosedRequest {
+			t.Errorf("expected abort before backend with 499, got %v %d", varCtx.ClientAbort, rr.Code)
+		}
+		if calls.Load() != 0 {
+			t.Error("expected the backend not to be called")
+		}
+	})
+
+	t.Run("during backend", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-arrived
+			cancel()
+		}()
+		rr := httptest.NewRecorder()
+		varCtx := serve(ctx, rr, "/slow")
+		if varCtx.ClientAbort != variables.AbortDuringBackend || rr.Code != variables.StatusClientClosedRequest {
+			t.Errorf("expected abort during backend with 499, got %v %d", varCtx.ClientAbort, rr.Code)
+		}
+	})
+
+	t.Run("during response", func(t *testing.T) {
+		w := failingWriter{httptest.NewRecorder()}
+		varCtx := serve(context.Background(), w, "/fast")
+		if varCtx.ClientAbort != variables.AbortDuringResponse {
+			t.Errorf("expected abort during response, got %v", varCtx.ClientAbort)
+		}
+		if w.Code != http.StatusOK {
+			t.Errorf("expected the backend status to be kept, got %d", w.Code)
+		}
+	})
+
+	t.Run("completed", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		if varCtx := serve(context.Background(), rr, "/fast"); varCtx.ClientAbort != variables.AbortNone {
+			t.Errorf("expected no abort, got %v", varCtx.ClientAbort)
+		}
+	})
+}
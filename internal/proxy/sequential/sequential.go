@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,8 +14,11 @@ import (
 	"time"
 
 	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/egress"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/middleware/backendenc"
+	"github.com/wudi/runway/internal/proxy"
+	"github.com/wudi/runway/internal/proxy/headerprop"
 	"github.com/wudi/runway/internal/tmplutil"
 	"github.com/wudi/runway/variables"
 )
@@ -44,6 +48,14 @@ type compiledStep struct {
 	timeout     time.Duration
 	variables   map[string]string
 	encoding    string // "no-op", "string", or "" (default JSON)
+	propagate   *headerprop.Rule
+}
+
+// Options carries route-level settings that apply to a sequential handler.
+type Options struct {
+	CompletionHeader bool
+	RequestTimeout   time.Duration // route timeout_policy.request; all steps share this budget
+	DetailedErrors   bool          // include per-step timing in error bodies (error_handling.mode: detailed)
 }
 
 // SequentialHandler chains multiple backend calls where each step's response
@@ -52,11 +64,28 @@ type SequentialHandler struct {
 	steps            []compiledStep
 	transport        http.RoundTripper
 	completionHeader bool
+	requestTimeout   time.Duration
+	detailedErrors   bool
+	maxPropHeaders   int
+	maxPropBytes     int
+
+	totalRequests   atomic.Int64
+	totalErrors     atomic.Int64
+	budgetExhausted atomic.Int64
+	stepErrors      []atomic.Int64
+	stepTimeouts    []atomic.Int64
+	stepLatencies   []atomic.Int64 // accumulated microseconds
+
+	propagatedHeaders atomic.Int64
+	headerConflicts   atomic.Int64
+	headersDropped    atomic.Int64
+}
 
-	totalRequests atomic.Int64
-	totalErrors   atomic.Int64
-	stepErrors    []atomic.Int64
-	stepLatencies []atomic.Int64 // accumulated microseconds
+// stepTrace records how long a step ran and how much budget it was given.
+type stepTrace struct {
+	Step      int   `json:"step"`
+	ElapsedMs int64 `json:"elapsed_ms"`
+	BudgetMs  int64 `json:"budget_ms"`
 }
 
 // New creates a SequentialHandler from config.
@@ -87,6 +116,7 @@ func New(cfg config.SequentialConfig, transport http.RoundTripper) (*SequentialH
 			timeout:   timeout,
 			variables: s.Variables,
 			encoding:  s.Encoding,
+			propagate: headerprop.NewRule(s.PropagateHeaders),
 		}
 
 		if len(s.Headers) > 0 {
@@ -112,10 +142,13 @@ func New(cfg config.SequentialConfig, transport http.RoundTripper) (*SequentialH
 	}
 
 	return &SequentialHandler{
-		steps:         steps,
-		transport:     transport,
-		stepErrors:    stepErrors,
-		stepLatencies: stepLatencies,
+		steps:          steps,
+		transport:      transport,
+		maxPropHeaders: cfg.MaxPropagatedHeaders,
+		maxPropBytes:   cfg.MaxPropagatedHeaderBytes,
+		stepErrors:     stepErrors,
+		stepTimeouts:   make([]atomic.Int64, len(cfg.Steps)),
+		stepLatencies:  stepLatencies,
 	}, nil
 }
 
@@ -136,11 +169,32 @@ func (sh *SequentialHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	sctx.Request.Headers = r.Header
 
 	var lastResp *http.Response
+	var propagated headerprop.Set
+
+	deadline, hasDeadline := proxy.BudgetDeadline(r.Context(), time.Now(), sh.requestTimeout)
+	trace := make([]stepTrace, 0, len(sh.steps))
 
 	for i, step := range sh.steps {
 		start := time.Now()
 		sctx.Variables = step.variables
 
+		// Each step gets its own timeout, capped by what is left of the route budget.
+		stepTimeout := step.timeout
+		budgetBound := false
+		if hasDeadline {
+			remaining := deadline.Sub(start)
+			if remaining <= 0 {
+				sh.budgetExhausted.Add(1)
+				sh.totalErrors.Add(1)
+				sh.fail(w, http.StatusGatewayTimeout, fmt.Sprintf("deadline exhausted at step %d", i), i, trace)
+				return
+			}
+			if remaining < stepTimeout {
+				stepTimeout = remaining
+				budgetBound = true
+			}
+		}
+
 		// Render URL
 		var urlBuf bytes.Buffer
 		if err := step.urlTmpl.Execute(&urlBuf, sctx); err != nil {
@@ -165,8 +219,8 @@ func (sh *SequentialHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			body = &bodyBuf
 		}
 
-		// Create request with per-step timeout
-		ctx, cancel := context.WithTimeout(r.Context(), step.timeout)
+		// Create request with per-step timeout, tagged for the egress policy
+		ctx, cancel := context.WithTimeout(egress.WithSource(r.Context(), "sequential"), stepTimeout)
 		stepReq, err := http.NewRequestWithContext(ctx, step.method, targetURL, body)
 		if err != nil {
 			cancel()
@@ -195,11 +249,22 @@ func (sh *SequentialHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		elapsed := time.Since(start)
 		sh.stepLatencies[i].Add(elapsed.Microseconds())
+		trace = append(trace, stepTrace{Step: i, ElapsedMs: elapsed.Milliseconds(), BudgetMs: stepTimeout.Milliseconds()})
 
 		if err != nil {
 			sh.stepErrors[i].Add(1)
 			sh.totalErrors.Add(1)
-			http.Error(w, fmt.Sprintf("step %d: request failed", i), http.StatusBadGateway)
+			if errors.Is(err, context.DeadlineExceeded) {
+				if budgetBound {
+					sh.budgetExhausted.Add(1)
+					sh.fail(w, http.StatusGatewayTimeout, fmt.Sprintf("deadline exhausted at step %d", i), i, trace)
+					return
+				}
+				sh.stepTimeouts[i].Add(1)
+				sh.fail(w, http.StatusBadGateway, fmt.Sprintf("step %d: timed out after %s", i, stepTimeout), i, trace)
+				return
+			}
+			sh.fail(w, http.StatusBadGateway, fmt.Sprintf("step %d: request failed", i), i, trace)
 			return
 		}
 
@@ -250,6 +315,7 @@ func (sh *SequentialHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			stepResult = parsed
 		}
 		sctx.Responses[fmt.Sprintf("Resp%d", i)] = stepResult
+		propagated.Collect(step.propagate, resp.Header)
 
 		// Keep last response for final output
 		if i == len(sh.steps)-1 {
@@ -260,9 +326,11 @@ func (sh *SequentialHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 					w.Header().Add(k, v)
 				}
 			}
+			sh.recordPropagation(propagated.Apply(w.Header(), sh.maxPropHeaders, sh.maxPropBytes))
 			if sh.completionHeader {
 				w.Header().Set("X-Runway-Completed", "true")
 			}
+			varCtx.UpstreamStatus = resp.StatusCode
 			w.WriteHeader(resp.StatusCode)
 			w.Write(respBody)
 		}
@@ -271,19 +339,46 @@ func (sh *SequentialHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	_ = lastResp
 }
 
+func (sh *SequentialHandler) recordPropagation(res headerprop.Result) {
+	sh.propagatedHeaders.Add(int64(res.Propagated))
+	sh.headerConflicts.Add(int64(res.Conflicts))
+	sh.headersDropped.Add(int64(res.Dropped))
+}
+
+// fail writes a step error. With detailed errors enabled the body is JSON and
+// includes the elapsed time and budget of every step that ran.
+func (sh *SequentialHandler) fail(w http.ResponseWriter, status int, msg string, step int, trace []stepTrace) {
+	if !sh.detailedErrors {
+		http.Error(w, msg, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": msg,
+		"step":  step,
+		"steps": trace,
+	})
+}
+
 // Stats returns sequential handler stats.
 func (sh *SequentialHandler) Stats() map[string]interface{} {
 	steps := make([]map[string]interface{}, len(sh.steps))
 	for i := range sh.steps {
 		steps[i] = map[string]interface{}{
-			"errors":            sh.stepErrors[i].Load(),
-			"total_latency_us":  sh.stepLatencies[i].Load(),
+			"errors":           sh.stepErrors[i].Load(),
+			"timeouts":         sh.stepTimeouts[i].Load(),
+			"total_latency_us": sh.stepLatencies[i].Load(),
 		}
 	}
 	return map[string]interface{}{
-		"total_requests": sh.totalRequests.Load(),
-		"total_errors":   sh.totalErrors.Load(),
-		"steps":          steps,
+		"total_requests":     sh.totalRequests.Load(),
+		"total_errors":       sh.totalErrors.Load(),
+		"budget_exhausted":   sh.budgetExhausted.Load(),
+		"steps":              steps,
+		"propagated_headers": sh.propagatedHeaders.Load(),
+		"header_conflicts":   sh.headerConflicts.Load(),
+		"headers_dropped":    sh.headersDropped.Load(),
 	}
 }
 
@@ -298,14 +393,14 @@ func NewSequentialByRoute() *SequentialByRoute {
 }
 
 // AddRoute adds a sequential handler for a route.
-func (m *SequentialByRoute) AddRoute(routeID string, cfg config.SequentialConfig, transport http.RoundTripper, completionHeader ...bool) error {
+func (m *SequentialByRoute) AddRoute(routeID string, cfg config.SequentialConfig, transport http.RoundTripper, opts Options) error {
 	sh, err := New(cfg, transport)
 	if err != nil {
 		return err
 	}
-	if len(completionHeader) > 0 {
-		sh.completionHeader = completionHeader[0]
-	}
+	sh.completionHeader = opts.CompletionHeader
+	sh.requestTimeout = opts.RequestTimeout
+	sh.detailedErrors = opts.DetailedErrors
 	m.Add(routeID, sh)
 	return nil
 }
@@ -1,10 +1,13 @@
 package sequential
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -238,7 +241,7 @@ func TestSequentialByRoute(t *testing.T) {
 		},
 	}
 
-	if err := m.AddRoute("route1", cfg, http.DefaultTransport); err != nil {
+	if err := m.AddRoute("route1", cfg, http.DefaultTransport, Options{}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -259,3 +262,172 @@ func TestSequentialByRoute(t *testing.T) {
 		t.Errorf("expected 1 route in stats, got %d", len(stats))
 	}
 }
+
+// slowServer responds after delay, or returns early if the client gives up.
+func slowServer(delay time.Duration, calls *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-time.After(delay):
+			w.Write([]byte(`{"ok":true}`))
+		case <-r.Context().Done():
+		}
+	}))
+}
+
+func newBudgetHandler(t *testing.T, url string, steps int, stepTimeout time.Duration, opts Options) *SequentialHandler {
+	t.Helper()
+	cfg := config.SequentialConfig{Enabled: true}
+	for i := 0; i < steps; i++ {
+		cfg.Steps = append(cfg.Steps, config.SequentialStep{URL: url, Timeout: stepTimeout})
+	}
+	m := NewSequentialByRoute()
+	if err := m.AddRoute("r1", cfg, http.DefaultTransport, opts); err != nil {
+		t.Fatal(err)
+	}
+	return m.Lookup("r1")
+}
+
+func TestSequentialHandler_RouteBudgetCapsSteps(t *testing.T) {
+	var calls atomic.Int32
+	server := slowServer(100*time.Millisecond, &calls)
+	defer server.Close()
+
+	sh := newBudgetHandler(t, server.URL, 3, 5*time.Second, Options{RequestTimeout: 150 * time.Millisecond})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	sh.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the route budget to bound the chain, took %s", elapsed)
+	}
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "deadline exhausted at step 1") {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+
+	stats := sh.Stats()
+	if stats["budget_exhausted"] != int64(1) {
+		t.Errorf("expected budget_exhausted 1, got %v", stats["budget_exhausted"])
+	}
+	if steps := stats["steps"].([]map[string]interface{}); steps[1]["timeouts"] != int64(0) {
+		t.Errorf("budget exhaustion must not count as a step timeout, got %v", steps[1]["timeouts"])
+	}
+}
+
+func TestSequentialHandler_FailsFastWhenBudgetSpent(t *testing.T) {
+	var calls atomic.Int32
+	server := slowServer(0, &calls)
+	defer server.Close()
+
+	sh := newBudgetHandler(t, server.URL, 2, 5*time.Second, Options{})
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Millisecond))
+	defer cancel()
+	w := httptest.NewRecorder()
+	sh.ServeHTTP(w, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+
+	if w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), "deadline exhausted at step 0") {
+		t.Errorf("expected 504 at step 0, got %d %s", w.Code, w.Body.String())
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected no backend calls, got %d", calls.Load())
+	}
+}
+
+func TestSequentialHandler_StepTimeoutCountedSeparately(t *testing.T) {
+	var calls atomic.Int32
+	server := slowServer(time.Second, &calls)
+	defer server.Close()
+
+	sh := newBudgetHandler(t, server.URL, 2, 50*time.Millisecond, Options{RequestTimeout: 5 * time.Second})
+
+	w := httptest.NewRecorder()
+	sh.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "step 0: timed out") {
+		t.Errorf("expected 502 step timeout, got %d %s", w.Code, w.Body.String())
+	}
+	stats := sh.Stats()
+	if stats["budget_exhausted"] != int64(0) {
+		t.Errorf("expected budget_exhausted 0, got %v", stats["budget_exhausted"])
+	}
+	if steps := stats["steps"].([]map[string]interface{}); steps[0]["timeouts"] != int64(1) {
+		t.Errorf("expected step 0 timeouts 1, got %v", steps[0]["timeouts"])
+	}
+}
+
+func TestSequentialHandler_DetailedBudgetError(t *testing.T) {
+	var calls atomic.Int32
+	server := slowServer(80*time.Millisecond, &calls)
+	defer server.Close()
+
+	sh := newBudgetHandler(t, server.URL, 3, 5*time.Second, Options{RequestTimeout: 120 * time.Millisecond, DetailedErrors: true})
+
+	w := httptest.NewRecorder()
+	sh.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	var body struct {
+		Error string      `json:"error"`
+		Step  int         `json:"step"`
+		Steps []stepTrace `json:"steps"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected JSON error body: %v (%s)", err, w.Body.String())
+	}
+	if body.Error != "deadline exhausted at step 1" || body.Step != 1 {
+		t.Errorf("unexpected error detail: %+v", body)
+	}
+	if len(body.Steps) != 2 || body.Steps[0].BudgetMs < 100 || body.Steps[1].BudgetMs > 60 {
+		t.Errorf("unexpected step trace: %+v", body.Steps)
+	}
+}
+
+func TestSequentialHandler_PropagateHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/search":
+			w.Header().Set("X-Total-Count", "42")
+			w.Header().Set("Set-Cookie", "session=abc")
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte(`{"ids":[1,2]}`))
+		case "/details":
+			w.Header().Set("ETag", `"v2"`)
+			w.Write([]byte(`{"items":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	sh, err := New(config.SequentialConfig{
+		Enabled: true,
+		Steps: []config.SequentialStep{
+			{URL: server.URL + "/search", PropagateHeaders: config.PropagateHeadersConfig{
+				Headers:        []string{"X-Total-Count", "Set-Cookie", "ETag"},
+				AllowSetCookie: true,
+			}},
+			{URL: server.URL + "/details"},
+		},
+	}, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	sh.ServeHTTP(w, httptest.NewRequest("GET", "/search", nil))
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if w.Header().Get("X-Total-Count") != "42" || w.Header().Get("Set-Cookie") != "session=abc" {
+		t.Errorf("expected step 0 headers on the final response, got %v", w.Header())
+	}
+	// Propagated headers replace the final step's own values.
+	if w.Header().Get("ETag") != `"v1"` {
+		t.Errorf("expected propagated ETag, got %q", w.Header().Get("ETag"))
+	}
+	if stats := sh.Stats(); stats["propagated_headers"] != int64(3) {
+		t.Errorf("expected 3 propagated headers, got %v", stats["propagated_headers"])
+	}
+}
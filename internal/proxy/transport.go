@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/quic-go/quic-go/http3"
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/egress"
 	"github.com/wudi/runway/internal/middleware/ssrf"
 )
 
@@ -45,9 +47,13 @@ type TransportConfig struct {
 
 	// DNS
 	Resolver *net.Resolver // nil = default OS resolver
+	IPFamily string        // auto (default), ipv4, ipv6, prefer_ipv4, prefer_ipv6
 
 	// SSRF protection
 	SSRFProtection *config.SSRFProtectionConfig
+
+	// Egress allowlist, shared by every transport built from this config
+	Egress *egress.Policy
 }
 
 // DefaultTransportConfig provides default transport settings.
@@ -98,6 +104,13 @@ func buildTLSConfig(cfg TransportConfig) *tls.Config {
 
 // NewTransport creates a new HTTP transport with the given configuration
 func NewTransport(cfg TransportConfig) *http.Transport {
+	t, _ := newTransport(cfg)
+	return t
+}
+
+// newTransport builds the HTTP transport along with its family-aware dialer,
+// so the pool can expose per-family dial stats.
+func newTransport(cfg TransportConfig) (*http.Transport, *FamilyDialer) {
 	dialer := &net.Dialer{
 		Timeout:   cfg.DialTimeout,
 		KeepAlive: 30 * time.Second,
@@ -106,16 +119,20 @@ func NewTransport(cfg TransportConfig) *http.Transport {
 
 	tlsConfig := buildTLSConfig(cfg)
 
-	dialCtx := dialer.DialContext
+	var guard *ssrf.SafeDialer
 	if cfg.SSRFProtection != nil && cfg.SSRFProtection.Enabled {
 		if sd, err := ssrf.New(dialer, *cfg.SSRFProtection); err == nil {
-			dialCtx = sd.DialContext
+			guard = sd
 		}
 	}
+	fd := NewFamilyDialer(dialer, cfg.IPFamily, guard)
+	if p := cfg.Egress; p != nil {
+		fd.SetEgressPolicy(func() *egress.Policy { return p })
+	}
 
 	return &http.Transport{
 		Proxy:                 http.ProxyFromEnvironment,
-		DialContext:           dialCtx,
+		DialContext:           fd.DialContext,
 		MaxIdleConns:          cfg.MaxIdleConns,
 		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
 		MaxConnsPerHost:       cfg.MaxConnsPerHost,
@@ -128,7 +145,7 @@ func NewTransport(cfg TransportConfig) *http.Transport {
 		ForceAttemptHTTP2:     cfg.ForceHTTP2,
 		WriteBufferSize:       32 * 1024, // 32KB — reduce syscalls for large responses
 		ReadBufferSize:        32 * 1024,
-	}
+	}, fd
 }
 
 // NewHTTP3Transport creates an HTTP/3 QUIC transport with the given configuration.
@@ -200,6 +217,9 @@ func MergeTransportConfigs(base TransportConfig, overlays ...config.TransportCon
 		if o.EnableHTTP3 != nil {
 			base.EnableHTTP3 = *o.EnableHTTP3
 		}
+		if o.IPFamily != "" {
+			base.IPFamily = o.IPFamily
+		}
 	}
 	return base
 }
@@ -213,22 +233,26 @@ func (tc TransportConfig) WithSSRFProtection(cfg *config.SSRFProtectionConfig) T
 // TransportPool manages a pool of transports keyed by upstream name.
 type TransportPool struct {
 	defaultTransport http.RoundTripper
+	defaultDialer    *FamilyDialer
 	transports       map[string]http.RoundTripper
+	dialers          map[string]*FamilyDialer
+	canaries         map[string]*CanaryTransport
 }
 
 // NewTransportPool creates a new transport pool with a default transport.
 func NewTransportPool() *TransportPool {
-	return &TransportPool{
-		defaultTransport: DefaultTransport(),
-		transports:       make(map[string]http.RoundTripper),
-	}
+	return NewTransportPoolWithDefault(DefaultTransportConfig)
 }
 
 // NewTransportPoolWithDefault creates a new transport pool with a custom default config.
 func NewTransportPoolWithDefault(cfg TransportConfig) *TransportPool {
+	t, fd := newTransport(cfg)
 	return &TransportPool{
-		defaultTransport: NewTransport(cfg),
+		defaultTransport: t,
+		defaultDialer:    fd,
 		transports:       make(map[string]http.RoundTripper),
+		dialers:          make(map[string]*FamilyDialer),
+		canaries:         make(map[string]*CanaryTransport),
 	}
 }
 
@@ -248,9 +272,41 @@ func (tp *TransportPool) Get(name string) http.RoundTripper {
 func (tp *TransportPool) Set(name string, cfg TransportConfig) {
 	if cfg.EnableHTTP3 {
 		tp.transports[name] = NewHTTP3Transport(cfg)
+		delete(tp.dialers, name)
+	} else {
+		t, fd := newTransport(cfg)
+		tp.transports[name] = t
+		tp.dialers[name] = fd
+	}
+}
+
+// SetCanary wraps the named upstream's current transport (the default one if
+// it has none) in a CanaryTransport whose candidate is built from candidate.
+// Dial stats and TLSDialer keep reporting the primary transport.
+func (tp *TransportPool) SetCanary(name string, candidate TransportConfig, cfg config.TransportCanaryConfig, onChange CanaryStateFunc) {
+	var rt http.RoundTripper
+	if candidate.EnableHTTP3 {
+		rt = NewHTTP3Transport(candidate)
 	} else {
-		tp.transports[name] = NewTransport(cfg)
+		rt, _ = newTransport(candidate)
+	}
+	ct := NewCanaryTransport(name, tp.Get(name), rt, cfg, onChange)
+	tp.transports[name] = ct
+	tp.canaries[name] = ct
+}
+
+// Canary returns the named upstream's transport canary, or nil.
+func (tp *TransportPool) Canary(name string) *CanaryTransport {
+	return tp.canaries[name]
+}
+
+// CanaryStats returns the state and per-transport stats of every transport canary.
+func (tp *TransportPool) CanaryStats() map[string]CanaryTransportSnapshot {
+	result := make(map[string]CanaryTransportSnapshot, len(tp.canaries))
+	for name, ct := range tp.canaries {
+		result[name] = ct.Snapshot()
 	}
+	return result
 }
 
 // SetForHost sets a custom transport for a host (legacy API, delegates to Set).
@@ -281,6 +337,7 @@ func (tp *TransportPool) DefaultConfig() map[string]interface{} {
 			"expect_continue_timeout": fmt.Sprintf("%v", dt.ExpectContinueTimeout),
 			"disable_keep_alives":     dt.DisableKeepAlives,
 			"force_attempt_http2":     dt.ForceAttemptHTTP2,
+			"ip_family":               tp.defaultDialer.family,
 		}
 	}
 	// HTTP/3 transport — fewer configurable fields
@@ -289,6 +346,45 @@ func (tp *TransportPool) DefaultConfig() map[string]interface{} {
 	}
 }
 
+// DialStats returns per-family dial statistics for the default transport and
+// every TCP-based upstream transport.
+func (tp *TransportPool) DialStats() map[string]interface{} {
+	upstreams := make(map[string]interface{}, len(tp.dialers))
+	for name, fd := range tp.dialers {
+		upstreams[name] = fd.Stats()
+	}
+	return map[string]interface{}{
+		"default":   tp.defaultDialer.Stats(),
+		"upstreams": upstreams,
+	}
+}
+
+// TLSDialer returns the dial function and a copy of the TLS settings used by
+// the named upstream's transport, so out-of-band probes connect the way
+// proxied requests do (resolver, IP family, SSRF protection, CA and client
+// certificates). HTTP/3 upstreams dial over TCP with the default dialer.
+func (tp *TransportPool) TLSDialer(name string) (func(ctx context.Context, network, addr string) (net.Conn, error), *tls.Config) {
+	dial := tp.defaultDialer.DialContext
+	if fd, ok := tp.dialers[name]; ok && name != "" {
+		dial = fd.DialContext
+	}
+	rt := tp.Get(name)
+	if ct, ok := rt.(*CanaryTransport); ok {
+		rt = ct.primary.rt
+	}
+	var tlsCfg *tls.Config
+	switch t := rt.(type) {
+	case *http.Transport:
+		tlsCfg = t.TLSClientConfig
+	case *http3.Transport:
+		tlsCfg = t.TLSClientConfig
+	}
+	if tlsCfg == nil {
+		return dial, &tls.Config{}
+	}
+	return dial, tlsCfg.Clone()
+}
+
 // CloseIdleConnections closes idle connections on all transports
 func (tp *TransportPool) CloseIdleConnections() {
 	closeIdle(tp.defaultTransport)
@@ -304,5 +400,8 @@ func closeIdle(rt http.RoundTripper) {
 		t.CloseIdleConnections()
 	case *http3.Transport:
 		t.Close()
+	case *CanaryTransport:
+		closeIdle(t.primary.rt)
+		closeIdle(t.canary.rt)
 	}
 }
@@ -0,0 +1,319 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
+)
+
+// Transport canary states.
+const (
+	CanaryStateActive     = "active"      // the candidate takes its share of requests
+	CanaryStateRolledBack = "rolled_back" // the candidate failed its thresholds; all requests use the primary
+	CanaryStatePromoted   = "promoted"    // the candidate replaced the primary
+)
+
+// Internal state values, indexing canaryStates.
+const (
+	stateActive int32 = iota
+	stateRolledBack
+	statePromoted
+)
+
+var canaryStates = [...]string{
+	stateActive:     CanaryStateActive,
+	stateRolledBack: CanaryStateRolledBack,
+	statePromoted:   CanaryStatePromoted,
+}
+
+const (
+	defaultCanaryWindow           = time.Minute
+	defaultCanaryMinRequests      = 20
+	defaultCanaryMaxErrorRate     = 0.05
+	defaultCanaryMaxHandshakeRate = 0.02
+)
+
+// CanaryStateFunc is called when a transport canary is rolled back or
+// promoted. reason is empty for promotions.
+type CanaryStateFunc func(upstream, state, reason string, stats CanaryTransportSnapshot)
+
+// meteredTransport counts requests, errors, handshake failures and the
+// protocol negotiated for one transport.
+type meteredTransport struct {
+	rt                http.RoundTripper
+	requests          atomic.Int64
+	errors            atomic.Int64
+	handshakeFailures atomic.Int64
+	protocols         [4]atomic.Int64 // HTTP/1.x, HTTP/2, HTTP/3, other
+}
+
+var protocolNames = [...]string{"HTTP/1.1", "HTTP/2.0", "HTTP/3.0", "other"}
+
+func (m *meteredTransport) roundTrip(r *http.Request) (*http.Response, bool, error) {
+	resp, err := m.rt.RoundTrip(r)
+	m.requests.Add(1)
+	if err != nil {
+		m.errors.Add(1)
+		handshake := isHandshakeError(err)
+		if handshake {
+			m.handshakeFailures.Add(1)
+		}
+		return resp, handshake, err
+	}
+	switch resp.ProtoMajor {
+	case 1:
+		m.protocols[0].Add(1)
+	case 2:
+		m.protocols[1].Add(1)
+	case 3:
+		m.protocols[2].Add(1)
+	default:
+		m.protocols[3].Add(1)
+	}
+	return resp, false, nil
+}
+
+// TransportStats is the JSON form of a transport's counters.
+type TransportStats struct {
+	Requests          int64            `json:"requests"`
+	Errors            int64            `json:"errors"`
+	HandshakeFailures int64            `json:"handshake_failures"`
+	Protocols         map[string]int64 `json:"protocols"`
+}
+
+func (m *meteredTransport) stats() TransportStats {
+	s := TransportStats{
+		Requests:          m.requests.Load(),
+		Errors:            m.errors.Load(),
+		HandshakeFailures: m.handshakeFailures.Load(),
+		Protocols:         make(map[string]int64, len(protocolNames)),
+	}
+	for i, name := range protocolNames {
+		if n := m.protocols[i].Load(); n > 0 {
+			s.Protocols[name] = n
+		}
+	}
+	return s
+}
+
+// isHandshakeError reports whether err happened while establishing the
+// connection: dial failures, TLS handshake and certificate errors, and QUIC
+// handshake timeouts or version negotiation failures.
+func isHandshakeError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var quicTimeout *quic.HandshakeTimeoutError
+	var quicVersion *quic.VersionNegotiationError
+	if errors.As(err, &certErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &quicTimeout) || errors.As(err, &quicVersion) {
+		return true
+	}
+	// net/http does not export its TLS handshake timeout error.
+	return strings.Contains(err.Error(), "TLS handshake timeout")
+}
+
+// CanaryTransport routes a share of an upstream's requests to a candidate
+// transport. Requests are assigned by a hash of their request ID, so retries
+// of a request stay on the same transport. The candidate's error and
+// handshake failure rates are evaluated per window; exceeding either
+// threshold rolls the canary back.
+type CanaryTransport struct {
+	upstream   string
+	primary    *meteredTransport
+	canary     *meteredTransport
+	percentage uint32
+	routes     map[string]bool
+	onChange   CanaryStateFunc
+
+	window           time.Duration
+	minRequests      int64
+	maxErrorRate     float64
+	maxHandshakeRate float64
+
+	state        atomic.Int32 // index into canaryStates
+	reason       atomic.Pointer[string]
+	windowStart  atomic.Int64 // unix nanos
+	winRequests  atomic.Int64
+	winErrors    atomic.Int64
+	winHandshake atomic.Int64
+}
+
+// NewCanaryTransport wraps primary and candidate for the named upstream.
+func NewCanaryTransport(upstream string, primary, candidate http.RoundTripper, cfg config.TransportCanaryConfig, onChange CanaryStateFunc) *CanaryTransport {
+	ct := &CanaryTransport{
+		upstream:         upstream,
+		primary:          &meteredTransport{rt: primary},
+		canary:           &meteredTransport{rt: candidate},
+		percentage:       uint32(cfg.Percentage),
+		routes:           make(map[string]bool, len(cfg.Routes)),
+		onChange:         onChange,
+		window:           cfg.Window,
+		minRequests:      int64(cfg.MinRequests),
+		maxErrorRate:     cfg.MaxErrorRate,
+		maxHandshakeRate: cfg.MaxHandshakeFailureRate,
+	}
+	for _, id := range cfg.Routes {
+		ct.routes[id] = true
+	}
+	if ct.window <= 0 {
+		ct.window = defaultCanaryWindow
+	}
+	if ct.minRequests <= 0 {
+		ct.minRequests = defaultCanaryMinRequests
+	}
+	if ct.maxErrorRate <= 0 {
+		ct.maxErrorRate = defaultCanaryMaxErrorRate
+	}
+	if ct.maxHandshakeRate <= 0 {
+		ct.maxHandshakeRate = defaultCanaryMaxHandshakeRate
+	}
+	ct.windowStart.Store(time.Now().UnixNano())
+	return ct
+}
+
+// RoundTrip implements http.RoundTripper.
+func (ct *CanaryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	switch ct.state.Load() {
+	case statePromoted:
+		resp, _, err := ct.canary.roundTrip(r)
+		return resp, err
+	case stateActive:
+		if ct.useCanary(r) {
+			resp, handshake, err := ct.canary.roundTrip(r)
+			ct.observe(err != nil, handshake)
+			return resp, err
+		}
+	}
+	resp, _, err := ct.primary.roundTrip(r)
+	return resp, err
+}
+
+// useCanary reports whether r is assigned to the candidate transport.
+func (ct *CanaryTransport) useCanary(r *http.Request) bool {
+	var key string
+	if vc, ok := r.Context().Value(variables.RequestContextKey{}).(*variables.Context); ok {
+		if ct.routes[vc.RouteID] {
+			return true
+		}
+		key = vc.RequestID
+	}
+	if ct.percentage == 0 {
+		return false
+	}
+	if key == "" {
+		key = r.URL.String()
+	}
+	// FNV-1a, inlined to keep the hot path allocation-free.
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return h%100 < ct.percentage
+}
+
+// observe records a candidate request and, once the window has elapsed and
+// holds at least min_requests, evaluates the window's rates. Windows with
+// fewer requests are extended rather than discarded.
+func (ct *CanaryTransport) observe(failed, handshake bool) {
+	ct.winRequests.Add(1)
+	if failed {
+		ct.winErrors.Add(1)
+	}
+	if handshake {
+		ct.winHandshake.Add(1)
+	}
+
+	start := ct.windowStart.Load()
+	now := time.Now().UnixNano()
+	if time.Duration(now-start) < ct.window || ct.winRequests.Load() < ct.minRequests ||
+		!ct.windowStart.CompareAndSwap(start, now) {
+		return
+	}
+	reqs := ct.winRequests.Swap(0)
+	errs := ct.winErrors.Swap(0)
+	hs := ct.winHandshake.Swap(0)
+	if reqs == 0 {
+		return
+	}
+	errRate := float64(errs) / float64(reqs)
+	hsRate := float64(hs) / float64(reqs)
+	switch {
+	case hsRate > ct.maxHandshakeRate:
+		ct.rollback(fmt.Sprintf("handshake failure rate %.3f exceeds %.3f over %d requests", hsRate, ct.maxHandshakeRate, reqs))
+	case errRate > ct.maxErrorRate:
+		ct.rollback(fmt.Sprintf("error rate %.3f exceeds %.3f over %d requests", errRate, ct.maxErrorRate, reqs))
+	}
+}
+
+func (ct *CanaryTransport) rollback(reason string) {
+	if !ct.state.CompareAndSwap(stateActive, stateRolledBack) {
+		return
+	}
+	ct.reason.Store(&reason)
+	closeIdle(ct.canary.rt)
+	if ct.onChange != nil {
+		ct.onChange(ct.upstream, CanaryStateRolledBack, reason, ct.Snapshot())
+	}
+}
+
+// Promote makes the candidate the transport for every request. It fails
+// unless the canary is active.
+func (ct *CanaryTransport) Promote() error {
+	if !ct.state.CompareAndSwap(stateActive, statePromoted) {
+		return fmt.Errorf("transport canary for upstream %s is %s", ct.upstream, ct.State())
+	}
+	closeIdle(ct.primary.rt)
+	if ct.onChange != nil {
+		ct.onChange(ct.upstream, CanaryStatePromoted, "", ct.Snapshot())
+	}
+	return nil
+}
+
+// State returns the canary state.
+func (ct *CanaryTransport) State() string {
+	return canaryStates[ct.state.Load()]
+}
+
+// CanaryTransportSnapshot is the JSON form of a transport canary.
+type CanaryTransportSnapshot struct {
+	State      string         `json:"state"`
+	Reason     string         `json:"reason,omitempty"`
+	Percentage int            `json:"percentage"`
+	Routes     []string       `json:"routes,omitempty"`
+	Primary    TransportStats `json:"primary"`
+	Canary     TransportStats `json:"canary"`
+}
+
+// Snapshot returns the canary state and per-transport stats.
+func (ct *CanaryTransport) Snapshot() CanaryTransportSnapshot {
+	snap := CanaryTransportSnapshot{
+		State:      ct.State(),
+		Percentage: int(ct.percentage),
+		Primary:    ct.primary.stats(),
+		Canary:     ct.canary.stats(),
+	}
+	if r := ct.reason.Load(); r != nil {
+		snap.Reason = *r
+	}
+	for id := range ct.routes {
+		snap.Routes = append(snap.Routes, id)
+	}
+	sort.Strings(snap.Routes)
+	return snap
+}
@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
+)
+
+// stubTransport returns a fixed response or error and counts calls.
+type stubTransport struct {
+	err   error
+	proto int
+	calls int
+}
+
+func (s *stubTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &http.Response{StatusCode: http.StatusOK, ProtoMajor: s.proto, Body: http.NoBody, Request: r}, nil
+}
+
+func canaryRequest(routeID, requestID string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "http://backend/x", nil)
+	vc := &variables.Context{RouteID: routeID, RequestID: requestID}
+	return r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, vc))
+}
+
+func TestCanaryTransportPercentage(t *testing.T) {
+	primary := &stubTransport{proto: 1}
+	candidate := &stubTransport{proto: 3}
+	ct := NewCanaryTransport("api", primary, candidate, config.TransportCanaryConfig{Percentage: 25}, nil)
+
+	for i := 0; i < 1000; i++ {
+		if _, err := ct.RoundTrip(canaryRequest("r", fmt.Sprintf("req-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if candidate.calls < 150 || candidate.calls > 350 {
+		t.Errorf("expected ~250 canary requests, got %d", candidate.calls)
+	}
+	if primary.calls+candidate.calls != 1000 {
+		t.Errorf("expected 1000 requests total, got %d", primary.calls+candidate.calls)
+	}
+
+	// The same request ID is always assigned to the same transport.
+	r := canaryRequest("r", "sticky")
+	want := ct.useCanary(r)
+	for i := 0; i < 10; i++ {
+		if ct.useCanary(r) != want {
+			t.Fatal("assignment is not stable for a request ID")
+		}
+	}
+
+	snap := ct.Snapshot()
+	if snap.Canary.Protocols["HTTP/3.0"] != int64(candidate.calls) {
+		t.Errorf("expected canary HTTP/3.0 count %d, got %v", candidate.calls, snap.Canary.Protocols)
+	}
+	if snap.Primary.Protocols["HTTP/1.1"] != int64(primary.calls) {
+		t.Errorf("expected primary HTTP/1.1 count %d, got %v", primary.calls, snap.Primary.Protocols)
+	}
+}
+
+func TestCanaryTransportRoutes(t *testing.T) {
+	primary := &stubTransport{proto: 1}
+	candidate := &stubTransport{proto: 2}
+	ct := NewCanaryTransport("api", primary, candidate, config.TransportCanaryConfig{Routes: []string{"beta"}}, nil)
+
+	ct.RoundTrip(canaryRequest("beta", "1"))
+	ct.RoundTrip(canaryRequest("stable", "2"))
+	if candidate.calls != 1 || primary.calls != 1 {
+		t.Errorf("expected 1 canary and 1 primary request, got %d and %d", candidate.calls, primary.calls)
+	}
+}
+
+func TestCanaryTransportRollback(t *testing.T) {
+	primary := &stubTransport{proto: 1}
+	candidate := &stubTransport{err: errors.New("stream reset")}
+
+	var gotState, gotReason string
+	ct := NewCanaryTransport("api", primary, candidate, config.TransportCanaryConfig{
+		Percentage:   100,
+		Window:       time.Nanosecond,
+		MinRequests:  5,
+		MaxErrorRate: 0.5,
+	}, func(upstream, state, reason string, _ CanaryTransportSnapshot) {
+		gotState, gotReason = state, reason
+	})
+
+	for i := 0; i < 5; i++ {
+		ct.RoundTrip(canaryRequest("r", fmt.Sprintf("req-%d", i)))
+	}
+	if ct.State() != CanaryStateRolledBack {
+		t.Fatalf("expected rolled_back, got %s", ct.State())
+	}
+	if gotState != CanaryStateRolledBack || gotReason == "" {
+		t.Errorf("expected rollback callback with reason, got %q %q", gotState, gotReason)
+	}
+
+	// After rollback every request goes to the primary.
+	if _, err := ct.RoundTrip(canaryRequest("r", "after")); err != nil {
+		t.Fatalf("expected primary response, got %v", err)
+	}
+	if primary.calls != 1 || candidate.calls != 5 {
+		t.Errorf("expected 1 primary and 5 canary requests, got %d and %d", primary.calls, candidate.calls)
+	}
+	if err := ct.Promote(); err == nil {
+		t.Error("expected promote to fail after rollback")
+	}
+}
+
+func TestCanaryTransportWaitsForMinRequests(t *testing.T) {
+	candidate := &stubTransport{err: errors.New("boom")}
+	ct := NewCanaryTransport("api", &stubTransport{}, candidate, config.TransportCanaryConfig{
+		Percentage:  100,
+		Window:      time.Nanosecond,
+		MinRequests: 10,
+	}, nil)
+
+	for i := 0; i < 9; i++ {
+		ct.RoundTrip(canaryRequest("r", fmt.Sprintf("req-%d", i)))
+	}
+	if ct.State() != CanaryStateActive {
+		t.Errorf("expected active below min_requests, got %s", ct.State())
+	}
+}
+
+func TestCanaryTransportHandshakeRollback(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "udp", Err: errors.New("connection refused")}
+	candidate := &stubTransport{err: dialErr}
+	ct := NewCanaryTransport("api", &stubTransport{}, candidate, config.TransportCanaryConfig{
+		Percentage:   100,
+		Window:       time.Nanosecond,
+		MinRequests:  1,
+		MaxErrorRate: 1,
+	}, nil)
+
+	ct.RoundTrip(canaryRequest("r", "1"))
+	if ct.State() != CanaryStateRolledBack {
+		t.Fatalf("expected rolled_back, got %s", ct.State())
+	}
+	snap := ct.Snapshot()
+	if snap.Canary.HandshakeFailures != 1 {
+		t.Errorf("expected 1 handshake failure, got %d", snap.Canary.HandshakeFailures)
+	}
+}
+
+func TestCanaryTransportPromote(t *testing.T) {
+	primary := &stubTransport{proto: 1}
+	candidate := &stubTransport{proto: 3}
+	promoted := false
+	ct := NewCanaryTransport("api", primary, candidate, config.TransportCanaryConfig{Percentage: 1}, func(_, state, _ string, _ CanaryTransportSnapshot) {
+		promoted = state == CanaryStatePromoted
+	})
+
+	if err := ct.Promote(); err != nil {
+		t.Fatal(err)
+	}
+	if !promoted {
+		t.Error("expected promote callback")
+	}
+	for i := 0; i < 10; i++ {
+		ct.RoundTrip(canaryRequest("r", fmt.Sprintf("req-%d", i)))
+	}
+	if candidate.calls != 10 || primary.calls != 0 {
+		t.Errorf("expected all requests on the candidate, got primary=%d canary=%d", primary.calls, candidate.calls)
+	}
+	if err := ct.Promote(); err == nil {
+		t.Error("expected second promote to fail")
+	}
+}
+
+func TestIsHandshakeError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "dial", Err: errors.New("refused")}, true},
+		{fmt.Errorf("wrapped: %w", tls.RecordHeaderError{Msg: "bad"}), true},
+		{&tls.CertificateVerificationError{Err: errors.New("expired")}, true},
+		{errors.New("net/http: TLS handshake timeout"), true},
+		{&net.OpError{Op: "read", Err: errors.New("reset")}, false},
+		{errors.New("unexpected EOF"), false},
+	}
+	for _, tt := range tests {
+		if got := isHandshakeError(tt.err); got != tt.want {
+			t.Errorf("isHandshakeError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestTransportPoolSetCanary(t *testing.T) {
+	pool := NewTransportPool()
+	pool.Set("api", DefaultTransportConfig)
+
+	candidate := DefaultTransportConfig
+	candidate.ForceHTTP2 = true
+	pool.SetCanary("api", candidate, config.TransportCanaryConfig{Enabled: true, Percentage: 10}, nil)
+
+	ct, ok := pool.Get("api").(*CanaryTransport)
+	if !ok {
+		t.Fatalf("expected *CanaryTransport, got %T", pool.Get("api"))
+	}
+	if pool.Canary("api") != ct {
+		t.Error("expected Canary to return the pool entry")
+	}
+	if pool.Canary("other") != nil {
+		t.Error("expected nil canary for unknown upstream")
+	}
+	if _, ok := pool.CanaryStats()["api"]; !ok {
+		t.Error("expected canary stats for api")
+	}
+	if dial, _ := pool.TLSDialer("api"); dial == nil {
+		t.Error("expected TLS dialer to resolve through the canary")
+	}
+	pool.CloseIdleConnections()
+}
@@ -0,0 +1,70 @@
+// Package randutil is the random source of sampling decisions: traffic
+// split rolls, fault injection, mirror sampling and mock data. It uses the
+// global math/rand source until Seed is called; test mode seeds it so that
+// a run with the same seed and request order makes the same choices.
+package randutil
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lockedRand is a seeded source safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+var seeded atomic.Pointer[lockedRand]
+
+// Seed makes every later draw deterministic.
+func Seed(seed int64) {
+	seeded.Store(&lockedRand{r: rand.New(rand.NewSource(seed))})
+}
+
+// Seeded reports whether Seed was called.
+func Seeded() bool {
+	return seeded.Load() != nil
+}
+
+// Intn returns a number in [0, n).
+func Intn(n int) int {
+	if s := seeded.Load(); s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.r.Intn(n)
+	}
+	return rand.Intn(n)
+}
+
+// Float64 returns a number in [0.0, 1.0).
+func Float64() float64 {
+	if s := seeded.Load(); s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.r.Float64()
+	}
+	return rand.Float64()
+}
+
+// Int63 returns a non-negative 63-bit number.
+func Int63() int64 {
+	if s := seeded.Load(); s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.r.Int63()
+	}
+	return rand.Int63()
+}
+
+// New returns a generator for a component that keeps its own. Once seeded,
+// its seed is drawn from the global source, so components created in the
+// same order get the same sequences.
+func New() *rand.Rand {
+	if Seeded() {
+		return rand.New(rand.NewSource(Int63()))
+	}
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
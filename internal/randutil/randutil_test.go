@@ -0,0 +1,45 @@
+package randutil
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestSeedIsDeterministic(t *testing.T) {
+	defer seeded.Store(nil)
+
+	draw := func() []int {
+		Seed(42)
+		return []int{Intn(100), Intn(100), int(Float64() * 1000), New().Intn(100)}
+	}
+	a, b := draw(), draw()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("expected the same draws for the same seed, got %v and %v", a, b)
+		}
+	}
+	if !Seeded() {
+		t.Fatal("expected Seeded after Seed")
+	}
+}
+
+func TestSeededConcurrentUse(t *testing.T) {
+	defer seeded.Store(nil)
+	Seed(7)
+	var n atomic.Int64
+	done := make(chan struct{})
+	for range 8 {
+		go func() {
+			for range 1000 {
+				n.Add(int64(Intn(10)))
+			}
+			done <- struct{}{}
+		}()
+	}
+	for range 8 {
+		<-done
+	}
+	if n.Load() == 0 {
+		t.Fatal("expected draws")
+	}
+}
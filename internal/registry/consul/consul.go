@@ -3,6 +3,11 @@ package consul
 import (
 	"context"
 	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -51,6 +56,18 @@ func New(cfg config.ConsulConfig) (*Registry, error) {
 	}, nil
 }
 
+// Ping checks that the Consul cluster is reachable and has a leader
+func (r *Registry) Ping(ctx context.Context) error {
+	leader, err := r.client.Status().LeaderWithQueryOptions((&consulapi.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if leader == "" {
+		return fmt.Errorf("consul cluster has no leader")
+	}
+	return nil
+}
+
 // Register registers a service instance with Consul
 func (r *Registry) Register(ctx context.Context, service *registry.Service) error {
 	registration := &consulapi.AgentServiceRegistration{
@@ -101,10 +118,49 @@ func (r *Registry) DiscoverWithTags(ctx context.Context, serviceName string, tag
 	return r.fetchServices(serviceName, tags)
 }
 
+// DiscoverWithFilter returns instances matching specific tags and service
+// metadata. The metadata is matched by a Consul filter expression, so
+// non-matching instances are not transferred.
+func (r *Registry) DiscoverWithFilter(ctx context.Context, serviceName string, tags []string, meta map[string]string) ([]*registry.Service, error) {
+	services, err := r.fetchServicesFiltered(serviceName, tags, metaFilter(meta))
+	if err != nil {
+		return nil, err
+	}
+	// Keys the filter syntax cannot express are matched here.
+	filtered := services[:0:0]
+	for _, svc := range services {
+		if svc.HasMetadata(meta) {
+			filtered = append(filtered, svc)
+		}
+	}
+	return filtered, nil
+}
+
+// filterIdent matches metadata keys usable as filter selectors.
+var filterIdent = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// metaFilter returns a Consul filter expression requiring each metadata
+// key/value pair, e.g. Service.Meta.version == "v2" and Service.Meta.zone == "a".
+func metaFilter(meta map[string]string) string {
+	var terms []string
+	for _, k := range slices.Sorted(maps.Keys(meta)) {
+		if filterIdent.MatchString(k) {
+			terms = append(terms, fmt.Sprintf("Service.Meta.%s == %s", k, strconv.Quote(meta[k])))
+		}
+	}
+	return strings.Join(terms, " and ")
+}
+
 // fetchServices fetches services from Consul
 func (r *Registry) fetchServices(serviceName string, tags []string) ([]*registry.Service, error) {
+	return r.fetchServicesFiltered(serviceName, tags, "")
+}
+
+// fetchServicesFiltered fetches services matching a Consul filter expression
+func (r *Registry) fetchServicesFiltered(serviceName string, tags []string, filter string) ([]*registry.Service, error) {
 	queryOpts := &consulapi.QueryOptions{
 		Datacenter: r.datacenter,
+		Filter:     filter,
 	}
 
 	// Use Health API to get only healthy services
@@ -142,10 +198,12 @@ func (r *Registry) fetchServices(serviceName string, tags []string) ([]*registry
 		services = append(services, svc)
 	}
 
-	// Update cache
-	r.cacheMu.Lock()
-	r.cache[serviceName] = services
-	r.cacheMu.Unlock()
+	// Update cache (only unfiltered results: Discover serves it)
+	if filter == "" {
+		r.cacheMu.Lock()
+		r.cache[serviceName] = services
+		r.cacheMu.Unlock()
+	}
 
 	return services, nil
 }
@@ -33,6 +33,21 @@ func (n *netResolver) LookupHost(ctx context.Context, host string) ([]string, er
 	return n.r.LookupHost(ctx, host)
 }
 
+// newNetResolver returns a resolver querying nameserver ("host:port"), or
+// the system resolver when nameserver is empty.
+func newNetResolver(nameserver string) resolver {
+	if nameserver == "" {
+		return &netResolver{r: net.DefaultResolver}
+	}
+	return &netResolver{r: &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{Timeout: 5 * time.Second}
+			return d.DialContext(ctx, "udp", nameserver)
+		},
+	}}
+}
+
 // Registry implements service discovery via DNS SRV records (RFC 2782).
 type Registry struct {
 	domain       string
@@ -61,24 +76,11 @@ func New(cfg config.DNSSRVConfig) (*Registry, error) {
 		pollInterval = 30 * time.Second
 	}
 
-	var r *net.Resolver
-	if cfg.Nameserver != "" {
-		r = &net.Resolver{
-			PreferGo: true,
-			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-				d := net.Dialer{Timeout: 5 * time.Second}
-				return d.DialContext(ctx, "udp", cfg.Nameserver)
-			},
-		}
-	} else {
-		r = net.DefaultResolver
-	}
-
 	return &Registry{
 		domain:       cfg.Domain,
 		protocol:     protocol,
 		pollInterval: pollInterval,
-		resolver:     &netResolver{r: r},
+		resolver:     newNetResolver(cfg.Nameserver),
 		cache:        make(map[string][]*registry.Service),
 		watchers:     make(map[string]context.CancelFunc),
 	}, nil
@@ -0,0 +1,335 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+// Defaults applied by NewFailover when a field is zero.
+const (
+	DefaultFailoverPollInterval  = 30 * time.Second
+	DefaultFailoverFailbackDelay = 30 * time.Second
+)
+
+// evaluateInterval is how often the active group is checked against the
+// health checker between DNS polls.
+const evaluateInterval = time.Second
+
+// Target is a backend resolved from an SRV record.
+type Target struct {
+	URL      string `json:"url"`
+	Priority uint16 `json:"priority"`
+	Weight   int    `json:"weight"`
+}
+
+// FailoverConfig holds failover configuration.
+type FailoverConfig struct {
+	config.UpstreamDNSDiscoveryConfig
+	// Unhealthy reports whether the health checker marked a backend down.
+	Unhealthy func(url string) bool
+	// OnTargets is called with every target when the SRV records change.
+	OnTargets func(targets []Target)
+	// OnChange is called with the members of the active priority group
+	// whenever the group or its members change.
+	OnChange func(active []Target)
+}
+
+// Failover resolves an upstream's SRV records and keeps one priority group
+// active: the lowest priority with a healthy member. Traffic fails over to
+// the next group as soon as every member of the active group is unhealthy,
+// and only returns to a preferred group once it has stayed healthy for the
+// failback delay.
+type Failover struct {
+	cfg      FailoverConfig
+	resolver resolver
+
+	mu           sync.Mutex
+	targets      []Target // sorted by priority, then weight descending
+	active       []Target
+	activePrio   int // -1 before the first successful lookup
+	pendingPrio  int // preferred group waiting out the failback delay, -1 if none
+	pendingSince time.Time
+	lastRefresh  time.Time
+	lastError    string
+	switches     int64
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// NewFailover creates a failover. Call Refresh for the initial lookup and
+// Start to keep it current.
+func NewFailover(cfg FailoverConfig) *Failover {
+	if cfg.Protocol == "" {
+		cfg.Protocol = "tcp"
+	}
+	if cfg.Scheme == "" {
+		cfg.Scheme = "http"
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultFailoverPollInterval
+	}
+	if cfg.FailbackDelay <= 0 {
+		cfg.FailbackDelay = DefaultFailoverFailbackDelay
+	}
+	if cfg.Unhealthy == nil {
+		cfg.Unhealthy = func(string) bool { return false }
+	}
+	return &Failover{
+		cfg:         cfg,
+		resolver:    newNetResolver(cfg.Nameserver),
+		activePrio:  -1,
+		pendingPrio: -1,
+		stopCh:      make(chan struct{}),
+		doneCh:      make(chan struct{}),
+	}
+}
+
+// Refresh looks up the SRV records and re-evaluates the active group. On
+// failure the previous targets are kept.
+func (f *Failover) Refresh(ctx context.Context) error {
+	targets, err := f.lookup(ctx)
+	f.mu.Lock()
+	f.lastRefresh = time.Now()
+	if err != nil {
+		f.lastError = err.Error()
+		f.mu.Unlock()
+		return err
+	}
+	f.lastError = ""
+	changed := !slices.Equal(f.targets, targets)
+	f.targets = targets
+	f.mu.Unlock()
+
+	if changed && f.cfg.OnTargets != nil {
+		f.cfg.OnTargets(targets)
+	}
+	f.evaluate(time.Now())
+	return nil
+}
+
+func (f *Failover) lookup(ctx context.Context) ([]Target, error) {
+	service, proto := f.cfg.Name, f.cfg.Protocol
+	name := f.cfg.Domain
+	if name == "" {
+		// name is the full record name
+		service, proto, name = "", "", f.cfg.Name
+	}
+	_, srvs, err := f.resolver.LookupSRV(ctx, service, proto, name)
+	if err != nil {
+		return nil, fmt.Errorf("dns srv lookup failed for %s: %w", f.cfg.Name, err)
+	}
+	targets := make([]Target, 0, len(srvs))
+	for _, srv := range srvs {
+		host := strings.TrimSuffix(srv.Target, ".")
+		if net.ParseIP(host) == nil {
+			// Resolve to an address so the health checker and the
+			// balancer see the same URL on every lookup.
+			if addrs, err := f.resolver.LookupHost(ctx, host); err == nil && len(addrs) > 0 {
+				host = pickAddr(addrs)
+			}
+		}
+		weight := int(srv.Weight)
+		if weight == 0 {
+			weight = 1
+		}
+		targets = append(targets, Target{
+			URL:      f.cfg.Scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(srv.Port))),
+			Priority: srv.Priority,
+			Weight:   weight,
+		})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Priority != targets[j].Priority {
+			return targets[i].Priority < targets[j].Priority
+		}
+		if targets[i].Weight != targets[j].Weight {
+			return targets[i].Weight > targets[j].Weight
+		}
+		return targets[i].URL < targets[j].URL
+	})
+	return targets, nil
+}
+
+// pickAddr prefers an IPv4 address.
+func pickAddr(addrs []string) string {
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+			return addr
+		}
+	}
+	return addrs[0]
+}
+
+// evaluate picks the active group and calls OnChange when it or its
+// members changed.
+func (f *Failover) evaluate(now time.Time) {
+	f.mu.Lock()
+	if len(f.targets) == 0 {
+		f.mu.Unlock()
+		return
+	}
+	// The first group with a member the health checker hasn't marked down.
+	preferred := -1
+	for _, t := range f.targets {
+		if !f.cfg.Unhealthy(t.URL) {
+			preferred = int(t.Priority)
+			break
+		}
+	}
+	activeExists := false
+	for _, t := range f.targets {
+		if int(t.Priority) == f.activePrio {
+			activeExists = true
+			break
+		}
+	}
+
+	next := f.activePrio
+	switch {
+	case !activeExists:
+		// First lookup, or DNS removed the active group.
+		next = preferred
+		if next < 0 {
+			next = int(f.targets[0].Priority)
+		}
+		f.pendingPrio = -1
+	case preferred < 0 || preferred == f.activePrio:
+		// Everything is down (stay put rather than flap) or the active
+		// group is still the best one.
+		f.pendingPrio = -1
+	case preferred > f.activePrio:
+		// Every member of the active group is down: fail over now.
+		next = preferred
+		f.pendingPrio = -1
+	default:
+		// A preferred group recovered: fail back once it stayed healthy.
+		if f.pendingPrio != preferred {
+			f.pendingPrio, f.pendingSince = preferred, now
+		} else if now.Sub(f.pendingSince) >= f.cfg.FailbackDelay {
+			next = preferred
+			f.pendingPrio = -1
+		}
+	}
+
+	var active []Target
+	for _, t := range f.targets {
+		if int(t.Priority) == next {
+			active = append(active, t)
+		}
+	}
+	if f.activePrio >= 0 && next != f.activePrio {
+		f.switches++
+	}
+	f.activePrio = next
+	changed := !slices.Equal(f.active, active)
+	f.active = active
+	f.mu.Unlock()
+
+	if changed && f.cfg.OnChange != nil {
+		f.cfg.OnChange(active)
+	}
+}
+
+// Active returns the members of the active priority group.
+func (f *Failover) Active() []Target {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.active)
+}
+
+// Targets returns every resolved target.
+func (f *Failover) Targets() []Target {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.targets)
+}
+
+// Start polls DNS every PollInterval and re-evaluates the active group
+// every second until Stop.
+func (f *Failover) Start() {
+	go f.loop()
+}
+
+func (f *Failover) loop() {
+	defer close(f.doneCh)
+	poll := time.NewTicker(f.cfg.PollInterval)
+	defer poll.Stop()
+	eval := time.NewTicker(evaluateInterval)
+	defer eval.Stop()
+	for {
+		select {
+		case <-poll.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			f.Refresh(ctx) // on failure the previous targets stay active
+			cancel()
+		case now := <-eval.C:
+			f.evaluate(now)
+		case <-f.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops polling. It must only be called after Start.
+func (f *Failover) Stop() {
+	f.stopOnce.Do(func() { close(f.stopCh) })
+	<-f.doneCh
+}
+
+// Stats returns the active group, the resolved targets and the last refresh.
+func (f *Failover) Stats() map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	type group struct {
+		Priority  uint16   `json:"priority"`
+		Targets   []string `json:"targets"`
+		Unhealthy []string `json:"unhealthy,omitempty"`
+	}
+	var groups []group
+	for _, t := range f.targets {
+		if len(groups) == 0 || groups[len(groups)-1].Priority != t.Priority {
+			groups = append(groups, group{Priority: t.Priority})
+		}
+		g := &groups[len(groups)-1]
+		g.Targets = append(g.Targets, t.URL)
+		if f.cfg.Unhealthy(t.URL) {
+			g.Unhealthy = append(g.Unhealthy, t.URL)
+		}
+	}
+	stats := map[string]any{
+		"record":        f.recordName(),
+		"groups":        groups,
+		"group_changes": f.switches,
+	}
+	if f.activePrio >= 0 {
+		stats["active_priority"] = f.activePrio
+	}
+	if f.pendingPrio >= 0 {
+		stats["failback_pending"] = map[string]any{"priority": f.pendingPrio, "since": f.pendingSince}
+	}
+	if !f.lastRefresh.IsZero() {
+		stats["last_refresh"] = f.lastRefresh
+	}
+	if f.lastError != "" {
+		stats["last_error"] = f.lastError
+	}
+	return stats
+}
+
+func (f *Failover) recordName() string {
+	if f.cfg.Domain == "" {
+		return f.cfg.Name
+	}
+	return "_" + f.cfg.Name + "._" + f.cfg.Protocol + "." + f.cfg.Domain
+}
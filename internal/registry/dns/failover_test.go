@@ -0,0 +1,167 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func newTestFailover(t *testing.T, srvs *[]*net.SRV, down map[string]bool, mu *sync.Mutex) (*Failover, *[][]Target) {
+	t.Helper()
+	var changes [][]Target
+	f := NewFailover(FailoverConfig{
+		UpstreamDNSDiscoveryConfig: config.UpstreamDNSDiscoveryConfig{
+			Name:          "api",
+			Domain:        "example.com",
+			FailbackDelay: time.Minute,
+		},
+		Unhealthy: func(url string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return down[url]
+		},
+		OnChange: func(active []Target) { changes = append(changes, active) },
+	})
+	f.resolver = &mockResolver{
+		srvFunc: func(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			if service != "api" || proto != "tcp" || name != "example.com" {
+				t.Errorf("unexpected lookup _%s._%s.%s", service, proto, name)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			return "", *srvs, nil
+		},
+	}
+	return f, &changes
+}
+
+func activeURLs(ts []Target) []string {
+	var urls []string
+	for _, t := range ts {
+		urls = append(urls, t.URL)
+	}
+	return urls
+}
+
+func TestFailoverPriorityGroups(t *testing.T) {
+	var mu sync.Mutex
+	srvs := []*net.SRV{
+		{Target: "10.0.0.1.", Port: 8080, Priority: 10, Weight: 50},
+		{Target: "10.0.0.2.", Port: 8080, Priority: 10, Weight: 50},
+		{Target: "10.1.0.1.", Port: 8080, Priority: 20, Weight: 0},
+	}
+	down := map[string]bool{}
+	f, changes := newTestFailover(t, &srvs, down, &mu)
+
+	if err := f.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := activeURLs(f.Active()); len(got) != 2 || got[0] != "http://10.0.0.1:8080" {
+		t.Fatalf("expected the priority 10 group active, got %v", got)
+	}
+
+	// One member down: the group stays active.
+	mu.Lock()
+	down["http://10.0.0.1:8080"] = true
+	mu.Unlock()
+	now := time.Now()
+	f.evaluate(now)
+	if f.Stats()["active_priority"] != 10 {
+		t.Fatalf("expected priority 10 to stay active, got %v", f.Stats()["active_priority"])
+	}
+
+	// Every member down: fail over immediately.
+	mu.Lock()
+	down["http://10.0.0.2:8080"] = true
+	mu.Unlock()
+	f.evaluate(now)
+	if got := activeURLs(f.Active()); len(got) != 1 || got[0] != "http://10.1.0.1:8080" {
+		t.Fatalf("expected failover to the priority 20 group, got %v", got)
+	}
+	if w := f.Active()[0].Weight; w != 1 {
+		t.Errorf("expected SRV weight 0 to become 1, got %d", w)
+	}
+
+	// The primary recovers: stay on the DR group until the failback delay.
+	mu.Lock()
+	down["http://10.0.0.2:8080"] = false
+	mu.Unlock()
+	f.evaluate(now.Add(time.Second))
+	f.evaluate(now.Add(30 * time.Second))
+	if f.Stats()["active_priority"] != 20 {
+		t.Fatalf("expected no failback before the delay, got %v", f.Stats()["active_priority"])
+	}
+	// A relapse restarts the delay.
+	mu.Lock()
+	down["http://10.0.0.2:8080"] = true
+	mu.Unlock()
+	f.evaluate(now.Add(40 * time.Second))
+	mu.Lock()
+	down["http://10.0.0.2:8080"] = false
+	mu.Unlock()
+	f.evaluate(now.Add(50 * time.Second))
+	f.evaluate(now.Add(90 * time.Second))
+	if f.Stats()["active_priority"] != 20 {
+		t.Fatalf("expected the relapse to restart the failback delay, got %v", f.Stats()["active_priority"])
+	}
+	f.evaluate(now.Add(111 * time.Second))
+	if f.Stats()["active_priority"] != 10 {
+		t.Fatalf("expected failback after the delay, got %v", f.Stats()["active_priority"])
+	}
+
+	if len(*changes) != 3 {
+		t.Errorf("expected 3 active group changes, got %d", len(*changes))
+	}
+	if got := f.Stats()["group_changes"]; got != int64(2) {
+		t.Errorf("expected 2 group switches, got %v", got)
+	}
+}
+
+func TestFailoverDNSFlip(t *testing.T) {
+	var mu sync.Mutex
+	srvs := []*net.SRV{
+		{Target: "10.0.0.1.", Port: 8080, Priority: 10, Weight: 1},
+		{Target: "10.1.0.1.", Port: 8080, Priority: 20, Weight: 1},
+	}
+	f, _ := newTestFailover(t, &srvs, map[string]bool{}, &mu)
+	if err := f.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Region failover published in DNS: the DR targets become priority 10.
+	mu.Lock()
+	srvs = []*net.SRV{
+		{Target: "10.1.0.1.", Port: 8080, Priority: 10, Weight: 1},
+		{Target: "10.0.0.1.", Port: 8080, Priority: 20, Weight: 1},
+	}
+	mu.Unlock()
+	if err := f.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := activeURLs(f.Active()); len(got) != 1 || got[0] != "http://10.1.0.1:8080" {
+		t.Fatalf("expected the new priority 10 target active, got %v", got)
+	}
+	if _, ok := f.Stats()["last_refresh"]; !ok {
+		t.Error("expected last_refresh in stats")
+	}
+}
+
+func TestFailoverKeepsTargetsOnLookupError(t *testing.T) {
+	var mu sync.Mutex
+	srvs := []*net.SRV{{Target: "10.0.0.1.", Port: 8080, Priority: 10, Weight: 1}}
+	f, _ := newTestFailover(t, &srvs, map[string]bool{}, &mu)
+	if err := f.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	f.resolver = &mockResolver{} // every lookup fails
+	if err := f.Refresh(context.Background()); err == nil {
+		t.Fatal("expected a lookup error")
+	}
+	if len(f.Active()) != 1 || f.Stats()["last_error"] == nil {
+		t.Errorf("expected the previous targets kept and the error reported, got %v", f.Stats())
+	}
+}
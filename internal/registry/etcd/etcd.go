@@ -21,6 +21,7 @@ const (
 // Registry implements service registry using etcd
 type Registry struct {
 	client   *clientv3.Client
+	endpoint string
 	leaseID  clientv3.LeaseID
 	watchers map[string]context.CancelFunc
 	cache    map[string][]*registry.Service
@@ -57,11 +58,18 @@ func New(cfg config.EtcdConfig) (*Registry, error) {
 
 	return &Registry{
 		client:   client,
+		endpoint: cfg.Endpoints[0],
 		watchers: make(map[string]context.CancelFunc),
 		cache:    make(map[string][]*registry.Service),
 	}, nil
 }
 
+// Ping checks that the etcd cluster is reachable
+func (r *Registry) Ping(ctx context.Context) error {
+	_, err := r.client.Status(ctx, r.endpoint)
+	return err
+}
+
 // Register registers a service instance with etcd
 func (r *Registry) Register(ctx context.Context, service *registry.Service) error {
 	// Create a lease
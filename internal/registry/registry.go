@@ -52,6 +52,31 @@ type Registry interface {
 	Close() error
 }
 
+// MetadataDiscoverer is implemented by registries that can filter instances
+// by service metadata when discovering them.
+type MetadataDiscoverer interface {
+	// DiscoverWithFilter returns instances matching all tags and all
+	// metadata key/value pairs
+	DiscoverWithFilter(ctx context.Context, serviceName string, tags []string, meta map[string]string) ([]*Service, error)
+}
+
+// HasMetadata reports whether the service has every key/value pair of meta.
+func (s *Service) HasMetadata(meta map[string]string) bool {
+	for k, v := range meta {
+		if got, ok := s.Metadata[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Pinger is implemented by registries backed by a remote store whose
+// connectivity can be probed.
+type Pinger interface {
+	// Ping checks that the registry backend is reachable
+	Ping(ctx context.Context) error
+}
+
 // RegistryType represents the type of registry
 type RegistryType string
 
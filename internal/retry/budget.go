@@ -32,6 +32,9 @@ type Budget struct {
 
 	// advMu protects bucket rotation (rare — once per bucketDur).
 	advMu sync.Mutex
+
+	// dist shares counts across replicas; nil for local budgets.
+	dist *distributedCounter
 }
 
 // NewBudget creates a retry budget.
@@ -57,17 +60,14 @@ func (b *Budget) RecordRequest() {
 	b.maybeAdvance()
 	idx := b.epoch.Load() % budgetBuckets
 	b.buckets[idx].requests.Add(1)
+	if b.dist != nil {
+		b.dist.pendingReqs.Add(1)
+	}
 }
 
 // AllowRetry returns true if the budget permits another retry.
 func (b *Budget) AllowRetry() bool {
-	b.maybeAdvance()
-
-	var totalReqs, totalRetries int64
-	for i := 0; i < budgetBuckets; i++ {
-		totalReqs += b.buckets[i].requests.Load()
-		totalRetries += b.buckets[i].retries.Load()
-	}
+	totalReqs, totalRetries := b.totals()
 
 	// Always allow if below minimum retries per second
 	windowSec := b.window.Seconds()
@@ -87,6 +87,31 @@ func (b *Budget) RecordRetry() {
 	b.maybeAdvance()
 	idx := b.epoch.Load() % budgetBuckets
 	b.buckets[idx].retries.Add(1)
+	if b.dist != nil {
+		b.dist.pendingRetries.Add(1)
+	}
+}
+
+// totals returns the request and retry counts over the window: cluster-wide
+// for a distributed budget with a fresh Redis sync, local otherwise.
+func (b *Budget) totals() (reqs, retries int64) {
+	if b.dist != nil {
+		b.dist.maybeSync()
+		if reqs, retries, ok := b.dist.totals(); ok {
+			return reqs, retries
+		}
+	}
+	return b.localTotals()
+}
+
+// localTotals returns this instance's counts over the window.
+func (b *Budget) localTotals() (reqs, retries int64) {
+	b.maybeAdvance()
+	for i := 0; i < budgetBuckets; i++ {
+		reqs += b.buckets[i].requests.Load()
+		retries += b.buckets[i].retries.Load()
+	}
+	return reqs, retries
 }
 
 // BudgetStats holds a point-in-time snapshot of budget state.
@@ -97,28 +122,54 @@ type BudgetStats struct {
 	TotalReqs    int64   `json:"total_requests"`
 	TotalRetries int64   `json:"total_retries"`
 	Utilization  float64 `json:"utilization"`
+
+	// Distributed budgets only. Mode is "distributed" while cluster-wide
+	// counts are in use and "fallback" while Redis is unavailable.
+	Mode               string     `json:"mode"`
+	ClusterRequests    int64      `json:"cluster_requests,omitempty"`
+	ClusterRetries     int64      `json:"cluster_retries,omitempty"`
+	ClusterUtilization float64    `json:"cluster_utilization,omitempty"`
+	LastSync           *time.Time `json:"last_sync,omitempty"`
+	SyncErrors         int64      `json:"sync_errors,omitempty"`
 }
 
 // Stats returns a point-in-time snapshot of the budget.
 func (b *Budget) Stats() BudgetStats {
-	b.maybeAdvance()
-	var totalReqs, totalRetries int64
-	for i := 0; i < budgetBuckets; i++ {
-		totalReqs += b.buckets[i].requests.Load()
-		totalRetries += b.buckets[i].retries.Load()
-	}
-	var utilization float64
-	if totalReqs > 0 {
-		utilization = float64(totalRetries) / float64(totalReqs)
-	}
-	return BudgetStats{
+	totalReqs, totalRetries := b.localTotals()
+	stats := BudgetStats{
 		Ratio:        b.ratio,
 		MinRetries:   b.minRetriesPerS,
 		Window:       b.window.String(),
 		TotalReqs:    totalReqs,
 		TotalRetries: totalRetries,
-		Utilization:  utilization,
+		Utilization:  utilizationOf(totalReqs, totalRetries),
+		Mode:         "local",
+	}
+	if b.dist == nil {
+		return stats
+	}
+
+	b.dist.maybeSync()
+	stats.Mode = "fallback"
+	if reqs, retries, ok := b.dist.totals(); ok {
+		stats.Mode = "distributed"
+		stats.ClusterRequests = reqs
+		stats.ClusterRetries = retries
+		stats.ClusterUtilization = utilizationOf(reqs, retries)
+	}
+	if last := b.dist.lastSyncNano.Load(); last > 0 {
+		t := time.Unix(0, last)
+		stats.LastSync = &t
+	}
+	stats.SyncErrors = b.dist.syncErrors.Load()
+	return stats
+}
+
+func utilizationOf(reqs, retries int64) float64 {
+	if reqs == 0 {
+		return 0
 	}
+	return float64(retries) / float64(reqs)
 }
 
 // maybeAdvance checks whether the window needs rotating. The fast path
@@ -0,0 +1,145 @@
+package retry
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+// budgetSyncScript adds this instance's pending counts to the current bucket
+// and returns the cluster-wide totals over the window.
+// KEYS: current bucket first, then the older buckets in the window.
+// ARGV: request delta, retry delta, bucket TTL in ms.
+// Returns: [requests, retries]
+var budgetSyncScript = redis.NewScript(`
+local dreq = tonumber(ARGV[1])
+local dret = tonumber(ARGV[2])
+if dreq > 0 or dret > 0 then
+    redis.call('HINCRBY', KEYS[1], 'req', dreq)
+    redis.call('HINCRBY', KEYS[1], 'ret', dret)
+    redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+local req, ret = 0, 0
+for i = 1, #KEYS do
+    local v = redis.call('HMGET', KEYS[i], 'req', 'ret')
+    req = req + (tonumber(v[1]) or 0)
+    ret = ret + (tonumber(v[2]) or 0)
+end
+return {req, ret}
+`)
+
+const (
+	defaultBudgetSyncInterval = time.Second
+	budgetRedisTimeout        = 100 * time.Millisecond
+)
+
+// distributedCounter shares a budget's request/retry counts across replicas
+// through Redis. Counts are flushed and the cluster totals refreshed at most
+// once per sync interval, off the request path; between syncs decisions use
+// the cached totals plus counts not yet flushed.
+type distributedCounter struct {
+	client       *redis.Client
+	prefix       string
+	bucketDur    time.Duration
+	syncInterval time.Duration
+
+	pendingReqs    atomic.Int64
+	pendingRetries atomic.Int64
+
+	clusterReqs    atomic.Int64
+	clusterRetries atomic.Int64
+	lastSyncNano   atomic.Int64 // last successful sync
+	lastTryNano    atomic.Int64 // last sync attempt
+	syncing        atomic.Bool
+	fallback       atomic.Bool
+	syncErrors     atomic.Int64
+}
+
+// NewDistributedBudget creates a retry budget whose counts are shared with
+// every instance using the same name and Redis. Until the first sync, and
+// whenever Redis is unavailable, it falls back to local counting.
+func NewDistributedBudget(name string, ratio float64, minRetries int, window, syncInterval time.Duration, client *redis.Client) *Budget {
+	b := NewBudget(ratio, minRetries, window)
+	if syncInterval <= 0 {
+		syncInterval = defaultBudgetSyncInterval
+	}
+	b.dist = &distributedCounter{
+		client:       client,
+		prefix:       "gw:rb:" + name + ":",
+		bucketDur:    time.Duration(b.bucketDurNano),
+		syncInterval: syncInterval,
+	}
+	b.dist.fallback.Store(true)
+	return b
+}
+
+// maybeSync starts a background sync when the cached cluster totals are older
+// than the sync interval.
+func (d *distributedCounter) maybeSync() {
+	now := time.Now().UnixNano()
+	if now-d.lastTryNano.Load() < int64(d.syncInterval) {
+		return
+	}
+	if !d.syncing.CompareAndSwap(false, true) {
+		return
+	}
+	d.lastTryNano.Store(now)
+	go func() {
+		defer d.syncing.Store(false)
+		d.sync()
+	}()
+}
+
+// sync flushes pending counts and refreshes the cluster totals.
+func (d *distributedCounter) sync() {
+	now := time.Now()
+	cur := now.UnixNano() / int64(d.bucketDur)
+	keys := make([]string, budgetBuckets)
+	for i := range keys {
+		keys[i] = d.prefix + strconv.FormatInt(cur-int64(i), 10)
+	}
+	ttl := (time.Duration(budgetBuckets+1) * d.bucketDur).Milliseconds()
+
+	dreq := d.pendingReqs.Swap(0)
+	dret := d.pendingRetries.Swap(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), budgetRedisTimeout)
+	defer cancel()
+	res, err := budgetSyncScript.Run(ctx, d.client, keys, dreq, dret, ttl).Int64Slice()
+	if err != nil || len(res) != 2 {
+		// Pending counts are dropped: the local buckets already hold them
+		// for fallback decisions, and replaying them later would land in
+		// the wrong window.
+		d.syncErrors.Add(1)
+		if !d.fallback.Swap(true) {
+			logging.Warn("Retry budget Redis unavailable, falling back to local counting",
+				zap.String("prefix", d.prefix), zap.Error(err))
+		}
+		return
+	}
+	d.clusterReqs.Store(res[0])
+	d.clusterRetries.Store(res[1])
+	d.lastSyncNano.Store(now.UnixNano())
+	if d.fallback.Swap(false) && d.syncErrors.Load() > 0 {
+		logging.Info("Retry budget Redis recovered, using cluster-wide counts",
+			zap.String("prefix", d.prefix))
+	}
+}
+
+// totals returns the cluster-wide counts including this instance's unflushed
+// counts. ok is false when the budget should fall back to local counting.
+func (d *distributedCounter) totals() (reqs, retries int64, ok bool) {
+	if d.fallback.Load() {
+		return 0, 0, false
+	}
+	// Cached totals older than a few sync intervals mean syncs have stalled.
+	if time.Now().UnixNano()-d.lastSyncNano.Load() > 3*int64(d.syncInterval) {
+		return 0, 0, false
+	}
+	return d.clusterReqs.Load() + d.pendingReqs.Load(), d.clusterRetries.Load() + d.pendingRetries.Load(), true
+}
@@ -0,0 +1,139 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func redisAvailable(t *testing.T) *redis.Client {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{
+		Addr:        "localhost:6379",
+		DialTimeout: 100 * time.Millisecond,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	return client
+}
+
+func cleanupBudgetKeys(t *testing.T, client *redis.Client, name string) {
+	t.Helper()
+	ctx := context.Background()
+	keys, _ := client.Keys(ctx, "gw:rb:"+name+":*").Result()
+	if len(keys) > 0 {
+		client.Del(ctx, keys...)
+	}
+}
+
+func TestDistributedBudget_SharedEnforcement(t *testing.T) {
+	client := redisAvailable(t)
+	name := "test-shared"
+	cleanupBudgetKeys(t, client, name)
+	defer cleanupBudgetKeys(t, client, name)
+
+	a := NewDistributedBudget(name, 0.2, 0, 10*time.Second, time.Second, client)
+	b := NewDistributedBudget(name, 0.2, 0, 10*time.Second, time.Second, client)
+
+	// Each replica sees 10 requests; replica a spends 4 retries, which is
+	// 40% locally but exactly 20% of the 20 cluster-wide requests.
+	for i := 0; i < 10; i++ {
+		a.RecordRequest()
+		b.RecordRequest()
+	}
+	for i := 0; i < 4; i++ {
+		a.RecordRetry()
+	}
+	a.dist.sync()
+	b.dist.sync()
+	a.dist.sync()
+
+	if b.AllowRetry() {
+		t.Error("replica b should be denied: cluster budget is exhausted by replica a")
+	}
+	if a.AllowRetry() {
+		t.Error("replica a should be denied: cluster budget is exhausted")
+	}
+
+	stats := b.Stats()
+	if stats.Mode != "distributed" {
+		t.Fatalf("expected distributed mode, got %q", stats.Mode)
+	}
+	if stats.ClusterRequests != 20 || stats.ClusterRetries != 4 {
+		t.Errorf("expected cluster 20/4, got %d/%d", stats.ClusterRequests, stats.ClusterRetries)
+	}
+	if stats.ClusterUtilization != 0.2 {
+		t.Errorf("expected cluster utilization 0.2, got %v", stats.ClusterUtilization)
+	}
+	if stats.TotalRetries != 0 {
+		t.Errorf("expected no local retries on replica b, got %d", stats.TotalRetries)
+	}
+}
+
+func TestDistributedBudget_AllowsWithinClusterRatio(t *testing.T) {
+	client := redisAvailable(t)
+	name := "test-within"
+	cleanupBudgetKeys(t, client, name)
+	defer cleanupBudgetKeys(t, client, name)
+
+	a := NewDistributedBudget(name, 0.5, 0, 10*time.Second, time.Second, client)
+	b := NewDistributedBudget(name, 0.5, 0, 10*time.Second, time.Second, client)
+
+	// Replica b has no traffic of its own but may retry against the
+	// requests replica a recorded.
+	for i := 0; i < 10; i++ {
+		a.RecordRequest()
+	}
+	a.RecordRetry()
+	a.dist.sync()
+	b.dist.sync()
+
+	if !b.AllowRetry() {
+		t.Error("replica b should be allowed: cluster ratio is 10%")
+	}
+}
+
+func TestDistributedBudget_FallbackWhenRedisUnavailable(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		DialTimeout: 50 * time.Millisecond,
+		MaxRetries:  -1,
+	})
+	defer client.Close()
+
+	b := NewDistributedBudget("test-fallback", 0.2, 0, 10*time.Second, time.Second, client)
+	for i := 0; i < 10; i++ {
+		b.RecordRequest()
+	}
+	b.RecordRetry()
+	b.RecordRetry()
+	b.dist.sync()
+
+	// Local counting still enforces the ratio.
+	if b.AllowRetry() {
+		t.Error("fallback budget should deny retries over the local ratio")
+	}
+
+	stats := b.Stats()
+	if stats.Mode != "fallback" {
+		t.Errorf("expected fallback mode, got %q", stats.Mode)
+	}
+	if stats.SyncErrors == 0 {
+		t.Error("expected sync errors to be counted")
+	}
+	if stats.TotalReqs != 10 || stats.TotalRetries != 2 {
+		t.Errorf("expected local 10/2, got %d/%d", stats.TotalReqs, stats.TotalRetries)
+	}
+}
+
+func TestBudget_LocalStatsMode(t *testing.T) {
+	b := NewBudget(0.2, 0, 10*time.Second)
+	if mode := b.Stats().Mode; mode != "local" {
+		t.Errorf("expected local mode, got %q", mode)
+	}
+}
@@ -2,11 +2,14 @@ package router
 
 import (
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 
 	"github.com/tidwall/gjson"
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/matchexpr"
+	"github.com/wudi/runway/variables"
 )
 
 // CompiledMatcher evaluates domain, header, query, and cookie match criteria for a route.
@@ -21,7 +24,23 @@ type CompiledMatcher struct {
 	cookies          []cookieMatcher
 	bodies           []bodyMatcher
 	methods          map[string]bool // nil = all methods allowed
+	listeners        map[string]bool // nil = all listeners
 	maxMatchBodySize int64
+	parseQuery       bool // some query matcher, plain or named, needs r.URL.Query()
+
+	// expr combines the named matchers; terms[i] is expr.Names()[i].
+	expr  *matchexpr.Expr
+	terms []exprTerm
+}
+
+// exprTerm is a named matcher referenced by a match expression.
+type exprTerm struct {
+	id     string
+	kind   string // "header", "query", "cookie" or "body"
+	header headerMatcher
+	query  queryMatcher
+	cookie cookieMatcher
+	body   bodyMatcher
 }
 
 type domainMatcher struct {
@@ -71,6 +90,13 @@ func NewCompiledMatcher(mc config.MatchConfig, methods []string) *CompiledMatche
 		}
 	}
 
+	// Matchers with an id are only evaluated through the expression.
+	var named map[string]exprTerm
+	if mc.Expression != "" {
+		cm.expr = matchexpr.MustParse(mc.Expression) // already validated in loader
+		named = make(map[string]exprTerm)
+	}
+
 	// Compile header matchers
 	for _, h := range mc.Headers {
 		hm := headerMatcher{name: h.Name}
@@ -81,6 +107,10 @@ func NewCompiledMatcher(mc config.MatchConfig, methods []string) *CompiledMatche
 		} else if h.Regex != "" {
 			hm.regex = regexp.MustCompile(h.Regex) // already validated in loader
 		}
+		if named != nil && h.ID != "" {
+			named[h.ID] = exprTerm{id: h.ID, kind: "header", header: hm}
+			continue
+		}
 		cm.headers = append(cm.headers, hm)
 	}
 
@@ -94,6 +124,11 @@ func NewCompiledMatcher(mc config.MatchConfig, methods []string) *CompiledMatche
 		} else if q.Regex != "" {
 			qm.regex = regexp.MustCompile(q.Regex) // already validated in loader
 		}
+		cm.parseQuery = true
+		if named != nil && q.ID != "" {
+			named[q.ID] = exprTerm{id: q.ID, kind: "query", query: qm}
+			continue
+		}
 		cm.queries = append(cm.queries, qm)
 	}
 
@@ -107,6 +142,10 @@ func NewCompiledMatcher(mc config.MatchConfig, methods []string) *CompiledMatche
 		} else if c.Regex != "" {
 			ck.regex = regexp.MustCompile(c.Regex) // already validated in loader
 		}
+		if named != nil && c.ID != "" {
+			named[c.ID] = exprTerm{id: c.ID, kind: "cookie", cookie: ck}
+			continue
+		}
 		cm.cookies = append(cm.cookies, ck)
 	}
 
@@ -120,9 +159,20 @@ func NewCompiledMatcher(mc config.MatchConfig, methods []string) *CompiledMatche
 		} else if b.Regex != "" {
 			bm.regex = regexp.MustCompile(b.Regex) // already validated in loader
 		}
+		if named != nil && b.ID != "" {
+			named[b.ID] = exprTerm{id: b.ID, kind: "body", body: bm}
+			continue
+		}
 		cm.bodies = append(cm.bodies, bm)
 	}
 
+	if cm.expr != nil {
+		cm.terms = make([]exprTerm, len(cm.expr.Names()))
+		for i, name := range cm.expr.Names() {
+			cm.terms[i] = named[name]
+		}
+	}
+
 	// Max match body size
 	cm.maxMatchBodySize = mc.MaxMatchBodySize
 
@@ -137,9 +187,52 @@ func NewCompiledMatcher(mc config.MatchConfig, methods []string) *CompiledMatche
 	return cm
 }
 
+// setListeners restricts cm to requests received by the given listeners.
+func (cm *CompiledMatcher) setListeners(ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	cm.listeners = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		cm.listeners[id] = true
+	}
+}
+
+// listenerID returns the ID of the listener that received r, set in its
+// variable context by the global chain.
+func listenerID(r *http.Request) string {
+	if varCtx, ok := r.Context().Value(variables.RequestContextKey{}).(*variables.Context); ok {
+		return varCtx.ListenerID
+	}
+	return ""
+}
+
 // HasBodyMatchers returns true if this matcher has body match criteria.
 func (cm *CompiledMatcher) HasBodyMatchers() bool {
-	return len(cm.bodies) > 0
+	if len(cm.bodies) > 0 {
+		return true
+	}
+	for i := range cm.terms {
+		if cm.terms[i].kind == "body" {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyFieldNames returns the body matcher paths, used as form field names
+// when matching multipart/form-data bodies.
+func (cm *CompiledMatcher) bodyFieldNames() []string {
+	names := make([]string, 0, len(cm.bodies))
+	for _, bm := range cm.bodies {
+		names = append(names, bm.path)
+	}
+	for i := range cm.terms {
+		if cm.terms[i].kind == "body" {
+			names = append(names, cm.terms[i].body.path)
+		}
+	}
+	return names
 }
 
 // MaxMatchBodySize returns the configured max body size for matching, or the default (1MB).
@@ -164,104 +257,39 @@ func (cm *CompiledMatcher) MatchesWithBody(r *http.Request, body []byte) bool {
 		return false
 	}
 
+	// Listener check
+	if cm.listeners != nil && !cm.listeners[listenerID(r)] {
+		return false
+	}
+
 	// Domain check — at least one domain must match (OR within domains)
-	if len(cm.domains) > 0 {
-		host := r.Host
-		// Strip port if present
-		if idx := strings.LastIndex(host, ":"); idx != -1 {
-			host = host[:idx]
-		}
-		matched := false
-		for _, dm := range cm.domains {
-			if dm.exact != "" && strings.EqualFold(host, dm.exact) {
-				matched = true
-				break
-			}
-			if dm.wildcard != "" && strings.HasSuffix(strings.ToLower(host), strings.ToLower(dm.wildcard)) {
-				matched = true
-				break
-			}
-		}
-		if !matched {
-			return false
-		}
+	if len(cm.domains) > 0 && !cm.matchDomain(r.Host) {
+		return false
 	}
 
 	// Header checks — all must match (AND)
-	for _, hm := range cm.headers {
-		val := r.Header.Get(hm.name)
-		if hm.present != nil {
-			has := val != "" || r.Header.Get(hm.name) != "" // Get returns "" for missing
-			// More precise: check if header key exists
-			_, has = r.Header[http.CanonicalHeaderKey(hm.name)]
-			if has != *hm.present {
-				return false
-			}
-			continue
-		}
-		if hm.exact != "" {
-			if val != hm.exact {
-				return false
-			}
-			continue
-		}
-		if hm.regex != nil {
-			if !hm.regex.MatchString(val) {
-				return false
-			}
-			continue
+	for i := range cm.headers {
+		if !cm.headers[i].matches(r) {
+			return false
 		}
 	}
 
-	// Query checks — all must match (AND)
-	query := r.URL.Query()
-	for _, qm := range cm.queries {
-		val := query.Get(qm.name)
-		if qm.present != nil {
-			has := query.Has(qm.name)
-			if has != *qm.present {
-				return false
-			}
-			continue
-		}
-		if qm.exact != "" {
-			if val != qm.exact {
-				return false
-			}
-			continue
-		}
-		if qm.regex != nil {
-			if !qm.regex.MatchString(val) {
-				return false
-			}
-			continue
+	// Query checks — all must match (AND). The query is only parsed for
+	// routes that match on it.
+	var query url.Values
+	if cm.parseQuery {
+		query = r.URL.Query()
+	}
+	for i := range cm.queries {
+		if !cm.queries[i].matches(query) {
+			return false
 		}
 	}
 
 	// Cookie checks — all must match (AND)
-	for _, ck := range cm.cookies {
-		cookie, err := r.Cookie(ck.name)
-		if ck.present != nil {
-			has := err == nil
-			if has != *ck.present {
-				return false
-			}
-			continue
-		}
-		if err != nil {
-			return false // cookie not found, and we need exact/regex match
-		}
-		if ck.exact != "" {
-			if cookie.Value != ck.exact {
-				return false
-			}
-			continue
-		}
-		if ck.regex != nil {
-			if !ck.regex.MatchString(cookie.Value) {
-				return false
-			}
-			continue
+	for i := range cm.cookies {
+		if !cm.cookies[i].matches(r) {
+			return false
 		}
 	}
 
@@ -270,36 +298,198 @@ func (cm *CompiledMatcher) MatchesWithBody(r *http.Request, body []byte) bool {
 		if body == nil {
 			return false
 		}
-		for _, bm := range cm.bodies {
-			result := gjson.GetBytes(body, bm.path)
-			if bm.present != nil {
-				if result.Exists() != *bm.present {
-					return false
-				}
-				continue
-			}
-			if !result.Exists() {
-				return false // field not found, and we need exact/regex match
-			}
-			val := result.String()
-			if bm.exact != "" {
-				if val != bm.exact {
-					return false
-				}
-				continue
-			}
-			if bm.regex != nil {
-				if !bm.regex.MatchString(val) {
-					return false
-				}
-				continue
+		for i := range cm.bodies {
+			if !cm.bodies[i].matches(body) {
+				return false
 			}
 		}
 	}
 
+	if cm.expr != nil {
+		return cm.expr.Eval(func(i int) bool {
+			return cm.terms[i].matches(r, query, body)
+		})
+	}
+	return true
+}
+
+func (cm *CompiledMatcher) matchDomain(host string) bool {
+	// Strip port if present
+	if idx := strings.LastIndex(host, ":"); idx != -1 {
+		host = host[:idx]
+	}
+	for _, dm := range cm.domains {
+		if dm.exact != "" && strings.EqualFold(host, dm.exact) {
+			return true
+		}
+		if dm.wildcard != "" && strings.HasSuffix(strings.ToLower(host), strings.ToLower(dm.wildcard)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (hm *headerMatcher) matches(r *http.Request) bool {
+	if hm.present != nil {
+		_, has := r.Header[http.CanonicalHeaderKey(hm.name)]
+		return has == *hm.present
+	}
+	val := r.Header.Get(hm.name)
+	if hm.exact != "" {
+		return val == hm.exact
+	}
+	if hm.regex != nil {
+		return hm.regex.MatchString(val)
+	}
+	return true
+}
+
+func (qm *queryMatcher) matches(query url.Values) bool {
+	if qm.present != nil {
+		return query.Has(qm.name) == *qm.present
+	}
+	val := query.Get(qm.name)
+	if qm.exact != "" {
+		return val == qm.exact
+	}
+	if qm.regex != nil {
+		return qm.regex.MatchString(val)
+	}
+	return true
+}
+
+func (ck *cookieMatcher) matches(r *http.Request) bool {
+	cookie, err := r.Cookie(ck.name)
+	if ck.present != nil {
+		return (err == nil) == *ck.present
+	}
+	if err != nil {
+		return false // cookie not found, and we need exact/regex match
+	}
+	if ck.exact != "" {
+		return cookie.Value == ck.exact
+	}
+	if ck.regex != nil {
+		return ck.regex.MatchString(cookie.Value)
+	}
+	return true
+}
+
+// matches reports whether body satisfies bm. A nil body never does.
+func (bm *bodyMatcher) matches(body []byte) bool {
+	if body == nil {
+		return false
+	}
+	result := gjson.GetBytes(body, bm.path)
+	if bm.present != nil {
+		return result.Exists() == *bm.present
+	}
+	if !result.Exists() {
+		return false // field not found, and we need exact/regex match
+	}
+	val := result.String()
+	if bm.exact != "" {
+		return val == bm.exact
+	}
+	if bm.regex != nil {
+		return bm.regex.MatchString(val)
+	}
 	return true
 }
 
+// matches evaluates the named matcher t refers to.
+func (t *exprTerm) matches(r *http.Request, query url.Values, body []byte) bool {
+	switch t.kind {
+	case "header":
+		return t.header.matches(r)
+	case "query":
+		return t.query.matches(query)
+	case "cookie":
+		return t.cookie.matches(r)
+	default:
+		return t.body.matches(body)
+	}
+}
+
+// MatchExplanation reports how each of a matcher's conditions evaluated
+// for a request. It is built for the admin simulate endpoint, not for the
+// request path.
+type MatchExplanation struct {
+	Matched    bool   `json:"matched"`
+	Expression string `json:"expression,omitempty"`
+	// ExpressionResult is the value of Expression over the named conditions.
+	ExpressionResult *bool             `json:"expression_result,omitempty"`
+	Conditions       []ConditionResult `json:"conditions"`
+}
+
+// ConditionResult is the outcome of a single match condition.
+type ConditionResult struct {
+	Kind    string `json:"kind"`             // "method", "listener", "domain", "header", "query", "cookie" or "body"
+	Target  string `json:"target,omitempty"` // header, query or cookie name, or body path
+	ID      string `json:"id,omitempty"`     // set for matchers combined by the expression
+	Matched bool   `json:"matched"`
+}
+
+// Explain evaluates every condition of cm against r, without
+// short-circuiting, and reports the results.
+func (cm *CompiledMatcher) Explain(r *http.Request, body []byte) MatchExplanation {
+	ex := MatchExplanation{Matched: cm.MatchesWithBody(r, body)}
+	add := func(kind, target, id string, matched bool) {
+		ex.Conditions = append(ex.Conditions, ConditionResult{Kind: kind, Target: target, ID: id, Matched: matched})
+	}
+	if cm.methods != nil {
+		add("method", r.Method, "", cm.methods[r.Method])
+	}
+	if cm.listeners != nil {
+		id := listenerID(r)
+		add("listener", id, "", cm.listeners[id])
+	}
+	if len(cm.domains) > 0 {
+		add("domain", r.Host, "", cm.matchDomain(r.Host))
+	}
+	var query url.Values
+	if cm.parseQuery {
+		query = r.URL.Query()
+	}
+	for i := range cm.headers {
+		add("header", cm.headers[i].name, "", cm.headers[i].matches(r))
+	}
+	for i := range cm.queries {
+		add("query", cm.queries[i].name, "", cm.queries[i].matches(query))
+	}
+	for i := range cm.cookies {
+		add("cookie", cm.cookies[i].name, "", cm.cookies[i].matches(r))
+	}
+	for i := range cm.bodies {
+		add("body", cm.bodies[i].path, "", cm.bodies[i].matches(body))
+	}
+	if cm.expr != nil {
+		results := make([]bool, len(cm.terms))
+		for i := range cm.terms {
+			t := &cm.terms[i]
+			results[i] = t.matches(r, query, body)
+			add(t.kind, t.target(), t.id, results[i])
+		}
+		v := cm.expr.Eval(func(i int) bool { return results[i] })
+		ex.Expression = cm.expr.String()
+		ex.ExpressionResult = &v
+	}
+	return ex
+}
+
+// target returns the header, query or cookie name, or body path, t checks.
+func (t *exprTerm) target() string {
+	switch t.kind {
+	case "header":
+		return t.header.name
+	case "query":
+		return t.query.name
+	case "cookie":
+		return t.cookie.name
+	}
+	return t.body.path
+}
+
 // Specificity returns a score for ordering routes. Higher = more specific.
 func (cm *CompiledMatcher) Specificity() int {
 	score := 0
@@ -314,8 +504,14 @@ func (cm *CompiledMatcher) Specificity() int {
 	score += len(cm.queries) * 10
 	score += len(cm.cookies) * 10
 	score += len(cm.bodies) * 10
+	if cm.expr != nil {
+		score += 10 // an expression counts as a single condition
+	}
 	if cm.methods != nil {
 		score += 5
 	}
+	if cm.listeners != nil {
+		score += 10
+	}
 	return score
 }
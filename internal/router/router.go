@@ -2,6 +2,7 @@ package router
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/url"
@@ -13,6 +14,7 @@ import (
 	"github.com/julienschmidt/httprouter"
 	"github.com/tidwall/gjson"
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
 )
 
 // Route represents a configured route
@@ -48,6 +50,10 @@ type Route struct {
 	Rewrite          config.RewriteConfig
 	FollowRedirects    config.FollowRedirectsConfig
 	Echo               bool
+	ForwardInformational bool // forward backend 1xx responses to the client
+	ExpectContinueThreshold int64 // generate Expect: 100-continue for bodies of at least this size
+	Metadata           map[string]string
+	Listeners          []string // IDs of the listeners serving the route (nil = all)
 	PrefixSegmentCount int // pre-computed segment count for zero-alloc strip-prefix
 
 	rewriteRegex *regexp.Regexp // compiled regex for rewrite (nil if no regex rewrite)
@@ -103,15 +109,17 @@ type RouteGroup struct {
 }
 
 // readBodyForMatching reads the request body for body-based route matching.
-// Returns nil if the body is nil, Content-Type isn't application/json, or the body
-// exceeds the max size. The request body is always restored for downstream handlers.
+// Returns nil if the body is nil, Content-Type isn't application/json or
+// multipart/form-data, or the body exceeds the max size. The request body is
+// always restored for downstream handlers.
 func readBodyForMatching(r *http.Request, routes []*Route) []byte {
 	if r.Body == nil || r.Body == http.NoBody {
 		return nil
 	}
 
 	ct := r.Header.Get("Content-Type")
-	if !strings.HasPrefix(ct, "application/json") {
+	_, isForm := variables.IsMultipartForm(r)
+	if !strings.HasPrefix(ct, "application/json") && !isForm {
 		return nil
 	}
 
@@ -129,6 +137,10 @@ func readBodyForMatching(r *http.Request, routes []*Route) []byte {
 		maxSize = defaultMaxMatchBodySize
 	}
 
+	if isForm {
+		return readFormForMatching(r, routes, maxSize)
+	}
+
 	// Read up to maxSize+1 to detect oversized bodies
 	data, err := io.ReadAll(io.LimitReader(r.Body, maxSize+1))
 	// Restore body regardless
@@ -150,16 +162,38 @@ func readBodyForMatching(r *http.Request, routes []*Route) []byte {
 	return data
 }
 
-// ServeHTTP is called by httprouter for a matched path. It type-asserts the writer
-// to *captureWriter, iterates candidates, and stores the first matching route.
-func (rg *RouteGroup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+// readFormForMatching streams a multipart/form-data body until it has seen
+// the form fields named by the candidate routes' body matchers, and returns
+// them as a flat JSON object for the matchers. Only the first maxSize bytes
+// are read; upload parts are never buffered in full. Returns nil if the
+// fields were not all found within maxSize.
+func readFormForMatching(r *http.Request, routes []*Route, maxSize int64) []byte {
+	var names []string
+	for _, route := range routes {
+		names = append(names, route.matcher.bodyFieldNames()...)
+	}
+	fields, err := variables.ScanMultipart(r, variables.MultipartScan{Fields: names, MaxScan: maxSize})
+	if err != nil {
+		return nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// handle is the httprouter handle of the group's path, called by Match with
+// the params of the looked-up path. It type-asserts the writer to
+// *captureWriter, iterates candidates, and stores the first matching route.
+// Params are passed directly rather than through the request context, which
+// would copy the request.
+func (rg *RouteGroup) handle(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	cw, ok := w.(*captureWriter)
 	if !ok {
 		return
 	}
 
-	// Extract httprouter params from request context
-	params := httprouter.ParamsFromContext(r.Context())
 	var pathParams map[string]string
 	if len(params) > 0 {
 		pathParams = pathParamsPool.Get().(map[string]string)
@@ -388,6 +422,10 @@ func (rt *Router) AddRoute(routeCfg config.RouteConfig) error {
 		Rewrite:          routeCfg.Rewrite,
 		FollowRedirects:  routeCfg.FollowRedirects,
 		Echo:             routeCfg.Echo,
+		ForwardInformational: routeCfg.ForwardInformational,
+		ExpectContinueThreshold: routeCfg.ExpectContinueThreshold,
+		Metadata:         routeCfg.Metadata,
+		Listeners:        routeCfg.Listeners,
 		configIdx:      rt.nextIdx,
 	}
 	rt.nextIdx++
@@ -425,6 +463,7 @@ func (rt *Router) AddRoute(routeCfg config.RouteConfig) error {
 
 	// Create compiled matcher for domain/header/query/method
 	route.matcher = NewCompiledMatcher(routeCfg.Match, routeCfg.Methods)
+	route.matcher.setListeners(routeCfg.Listeners)
 
 	if routeCfg.PathPrefix {
 		rt.addPrefixRoute(route, routeCfg.Path)
@@ -451,7 +490,7 @@ func (rt *Router) addExactRoute(route *Route, path string) {
 		for _, method := range standardMethods {
 			key := method + " " + normalized
 			if !rt.registeredPaths[key] {
-				rt.tree.Handler(method, normalized, group)
+				rt.tree.Handle(method, normalized, group.handle)
 				rt.registeredPaths[key] = true
 			}
 		}
@@ -477,7 +516,7 @@ func (rt *Router) addPrefixRoute(route *Route, path string) {
 		for _, method := range standardMethods {
 			key := method + " " + normalized
 			if !rt.registeredPaths[key] {
-				rt.tree.Handler(method, normalized, group)
+				rt.tree.Handle(method, normalized, group.handle)
 				rt.registeredPaths[key] = true
 			}
 		}
@@ -510,14 +549,17 @@ func (rt *Router) Match(r *http.Request) *Match {
 	rt.mu.RLock()
 	defer rt.mu.RUnlock()
 
-	// Tier 1: Try httprouter for exact/param paths
-	cw := captureWriterPool.Get().(*captureWriter)
-	rt.tree.ServeHTTP(cw, r)
-	match := cw.match
-	cw.match = nil
-	captureWriterPool.Put(cw)
-	if match != nil {
-		return match
+	// Tier 1: Try httprouter for exact/param paths. Lookup skips the
+	// not-found handler ServeHTTP would write to.
+	if handle, params, _ := rt.tree.Lookup(r.Method, r.URL.Path); handle != nil {
+		cw := captureWriterPool.Get().(*captureWriter)
+		handle(cw, r, params)
+		match := cw.match
+		cw.match = nil
+		captureWriterPool.Put(cw)
+		if match != nil {
+			return match
+		}
 	}
 
 	// Tier 2: Try prefix routes for subpaths
@@ -527,10 +569,9 @@ func (rt *Router) Match(r *http.Request) *Match {
 // matchPrefix checks prefix routes against the request path.
 func (rt *Router) matchPrefix(r *http.Request) *Match {
 	reqPath := r.URL.Path
-	reqSegments := splitPath(reqPath)
 
 	for _, pr := range rt.prefixGroups {
-		if !pathHasPrefix(reqSegments, pr.segments) {
+		if !pathHasPrefix(reqPath, pr.segments) {
 			continue
 		}
 
@@ -567,6 +608,21 @@ func (rt *Router) GetRoute(id string) *Route {
 	return nil
 }
 
+// ExplainMatch evaluates the match conditions of route id against r,
+// reading the body as the router would. The path is not checked. It
+// returns false if no such route exists.
+func (rt *Router) ExplainMatch(id string, r *http.Request) (MatchExplanation, bool) {
+	route := rt.GetRoute(id)
+	if route == nil {
+		return MatchExplanation{}, false
+	}
+	var body []byte
+	if route.matcher.HasBodyMatchers() {
+		body = readBodyForMatching(r, []*Route{route})
+	}
+	return route.matcher.Explain(r, body), true
+}
+
 // GetRoutes returns all configured routes
 func (rt *Router) GetRoutes() []*Route {
 	rt.mu.RLock()
@@ -658,17 +714,21 @@ func splitPath(path string) []string {
 	return strings.Split(path, "/")
 }
 
-// pathHasPrefix checks if reqSegments starts with prefixSegments.
-func pathHasPrefix(reqSegments, prefixSegments []string) bool {
-	if len(reqSegments) < len(prefixSegments) {
-		return false
-	}
-	for i, seg := range prefixSegments {
+// pathHasPrefix checks if the segments of reqPath start with prefixSegments.
+// It walks reqPath in place, so matching allocates nothing.
+func pathHasPrefix(reqPath string, prefixSegments []string) bool {
+	rest := strings.Trim(reqPath, "/")
+	for _, seg := range prefixSegments {
+		if rest == "" {
+			return false
+		}
+		reqSeg, tail, _ := strings.Cut(rest, "/")
+		rest = tail
 		// Skip param segments (start with ':')
 		if strings.HasPrefix(seg, ":") {
 			continue
 		}
-		if reqSegments[i] != seg {
+		if reqSeg != seg {
 			return false
 		}
 	}
@@ -1,8 +1,11 @@
 package router
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
@@ -10,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
 )
 
 func TestRouterMatch(t *testing.T) {
@@ -637,6 +641,59 @@ func TestMultiRouteSpecificity(t *testing.T) {
 	}
 }
 
+func TestListenerMatch(t *testing.T) {
+	r := New()
+	r.AddRoute(config.RouteConfig{
+		ID:       "any",
+		Path:     "/api",
+		Backends: []config.BackendConfig{{URL: "http://any:9001"}},
+	})
+	r.AddRoute(config.RouteConfig{
+		ID:        "public",
+		Path:      "/api",
+		Listeners: []string{"public"},
+		Backends:  []config.BackendConfig{{URL: "http://public:9001"}},
+	})
+	r.AddRoute(config.RouteConfig{
+		ID:        "admin",
+		Path:      "/admin",
+		Listeners: []string{"internal"},
+		Backends:  []config.BackendConfig{{URL: "http://admin:9001"}},
+	})
+
+	via := func(listener, path string) *http.Request {
+		req := httptest.NewRequest("GET", path, nil)
+		varCtx := variables.NewContext(req)
+		varCtx.ListenerID = listener
+		return req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+	}
+	tests := []struct {
+		listener, path, want string
+	}{
+		{"public", "/api", "public"},
+		{"internal", "/api", "any"},
+		{"", "/api", "any"},
+		{"internal", "/admin", "admin"},
+		{"public", "/admin", ""},
+		{"", "/admin", ""},
+	}
+	for _, tt := range tests {
+		match := r.Match(via(tt.listener, tt.path))
+		var got string
+		if match != nil {
+			got = match.Route.ID
+		}
+		if got != tt.want {
+			t.Errorf("%s via %q: matched %q, want %q", tt.path, tt.listener, got, tt.want)
+		}
+	}
+
+	ex, _ := r.ExplainMatch("admin", via("public", "/admin"))
+	if ex.Matched || len(ex.Conditions) != 1 || ex.Conditions[0] != (ConditionResult{Kind: "listener", Target: "public"}) {
+		t.Errorf("unexpected explanation %+v", ex)
+	}
+}
+
 func TestSpecificityExactDomainBeatsWildcard(t *testing.T) {
 	r := New()
 
@@ -1171,6 +1228,56 @@ func TestBodyMatchNonJSONContentType(t *testing.T) {
 	}
 }
 
+func TestBodyMatchMultipartForm(t *testing.T) {
+	r := New()
+
+	r.AddRoute(config.RouteConfig{
+		ID:   "acme-uploads",
+		Path: "/api/upload",
+		Match: config.MatchConfig{
+			Body:             []config.BodyMatchConfig{{Name: "tenant_id", Value: "acme"}},
+			MaxMatchBodySize: 64 << 10,
+		},
+		Backends: []config.BackendConfig{{URL: "http://localhost:9001"}},
+	})
+
+	newUpload := func(fieldFirst bool) (*http.Request, []byte) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		writeField := func() { mw.WriteField("tenant_id", "acme") }
+		if fieldFirst {
+			writeField()
+		}
+		fw, _ := mw.CreateFormFile("file", "big.bin")
+		fw.Write(bytes.Repeat([]byte("x"), 1<<20))
+		if !fieldFirst {
+			writeField()
+		}
+		mw.Close()
+		raw := bytes.Clone(buf.Bytes())
+		req := httptest.NewRequest("POST", "/api/upload", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return req, raw
+	}
+
+	req, raw := newUpload(true)
+	if match := r.Match(req); match == nil || match.Route.ID != "acme-uploads" {
+		t.Fatalf("expected the form field to match, got %v", match)
+	}
+	if got, _ := io.ReadAll(req.Body); !bytes.Equal(got, raw) {
+		t.Error("expected the full upload to be restored after matching")
+	}
+
+	// The field is past max_match_body_size: no match, body intact.
+	req, raw = newUpload(false)
+	if match := r.Match(req); match != nil {
+		t.Error("should not match a field beyond max_match_body_size")
+	}
+	if got, _ := io.ReadAll(req.Body); !bytes.Equal(got, raw) {
+		t.Error("expected the full upload to be restored after matching")
+	}
+}
+
 func TestBodyMatchInvalidJSON(t *testing.T) {
 	r := New()
 
@@ -1417,6 +1524,33 @@ func TestBodyMatchPrefixRoute(t *testing.T) {
 	}
 }
 
+func TestRouterMatchAllocs(t *testing.T) {
+	r := New()
+	backends := []config.BackendConfig{{URL: "http://localhost:9001"}}
+	r.AddRoute(config.RouteConfig{ID: "exact", Path: "/api", Backends: backends})
+	r.AddRoute(config.RouteConfig{ID: "param", Path: "/users/:id", Backends: backends})
+	r.AddRoute(config.RouteConfig{ID: "prefix", Path: "/svc", PathPrefix: true, Backends: backends})
+
+	tests := []struct {
+		path   string
+		allocs float64
+	}{
+		{"/api", 0},
+		{"/api?page=2", 0},
+		{"/svc/a/b", 0},
+		{"/users/42", 1}, // httprouter's Params slice
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		got := testing.AllocsPerRun(100, func() {
+			ReleaseMatch(r.Match(req))
+		})
+		if got > tt.allocs {
+			t.Errorf("Match(%s) = %v allocs, want <= %v", tt.path, got, tt.allocs)
+		}
+	}
+}
+
 func BenchmarkRouterMatch(b *testing.B) {
 	r := New()
 
@@ -1480,3 +1614,161 @@ func TestCountSegments(t *testing.T) {
 		}
 	}
 }
+
+func betaExpressionRoute() config.RouteConfig {
+	present := true
+	return config.RouteConfig{
+		ID:   "v2",
+		Path: "/app",
+		Match: config.MatchConfig{
+			Headers: []config.HeaderMatchConfig{
+				{ID: "header_beta", Name: "X-Beta", Present: &present},
+				{Name: "X-Tenant", Value: "acme"}, // no id: always required
+			},
+			Query:      []config.QueryMatchConfig{{ID: "query_beta", Name: "beta", Value: "1"}},
+			Cookies:    []config.CookieMatchConfig{{ID: "cookie_beta", Name: "beta", Value: "1"}},
+			Expression: "header_beta || cookie_beta || query_beta",
+		},
+		Backends: []config.BackendConfig{{URL: "http://localhost:9002"}},
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	r := New()
+	r.AddRoute(betaExpressionRoute())
+	r.AddRoute(config.RouteConfig{
+		ID:       "v1",
+		Path:     "/app",
+		Backends: []config.BackendConfig{{URL: "http://localhost:9001"}},
+	})
+
+	tests := []struct {
+		name  string
+		setup func(*http.Request)
+		want  string
+	}{
+		{"header", func(r *http.Request) { r.Header.Set("X-Beta", "") }, "v2"},
+		{"cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "beta", Value: "1"}) }, "v2"},
+		{"query", func(r *http.Request) { r.URL.RawQuery = "beta=1" }, "v2"},
+		{"none", func(r *http.Request) {}, "v1"},
+		{"wrong cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "beta", Value: "0"}) }, "v1"},
+		{"unnamed matcher fails", func(r *http.Request) {
+			r.Header.Del("X-Tenant")
+			r.Header.Set("X-Beta", "1")
+		}, "v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/app", nil)
+			req.Header.Set("X-Tenant", "acme")
+			tt.setup(req)
+			m := r.Match(req)
+			if m == nil || m.Route.ID != tt.want {
+				t.Fatalf("expected route %s, got %+v", tt.want, m)
+			}
+		})
+	}
+}
+
+func TestMatchExpressionNot(t *testing.T) {
+	r := New()
+	r.AddRoute(config.RouteConfig{
+		ID:   "modern",
+		Path: "/app",
+		Match: config.MatchConfig{
+			Headers:    []config.HeaderMatchConfig{{ID: "legacy_ua", Name: "User-Agent", Regex: "MSIE"}},
+			Body:       []config.BodyMatchConfig{{ID: "v2_body", Name: "version", Value: "2"}},
+			Expression: "!legacy_ua && v2_body",
+		},
+		Backends: []config.BackendConfig{{URL: "http://localhost:9001"}},
+	})
+
+	send := func(ua, body string) *Match {
+		req := httptest.NewRequest("POST", "/app", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", ua)
+		return r.Match(req)
+	}
+	if send("curl/8", `{"version":"2"}`) == nil {
+		t.Error("expected match for non-legacy client with v2 body")
+	}
+	if send("Mozilla/4.0 (compatible; MSIE 6.0)", `{"version":"2"}`) != nil {
+		t.Error("legacy client should not match")
+	}
+	if send("curl/8", `{"version":"1"}`) != nil {
+		t.Error("v1 body should not match")
+	}
+}
+
+func TestExplainMatch(t *testing.T) {
+	r := New()
+	r.AddRoute(betaExpressionRoute())
+
+	req := httptest.NewRequest("GET", "/app?beta=1", nil)
+	req.Header.Set("X-Tenant", "acme")
+	ex, ok := r.ExplainMatch("v2", req)
+	if !ok {
+		t.Fatal("expected route to be found")
+	}
+	if !ex.Matched || ex.ExpressionResult == nil || !*ex.ExpressionResult {
+		t.Fatalf("expected a match through the expression, got %+v", ex)
+	}
+	if ex.Expression != "header_beta || cookie_beta || query_beta" {
+		t.Errorf("unexpected expression %q", ex.Expression)
+	}
+	got := map[string]bool{}
+	for _, c := range ex.Conditions {
+		got[c.Kind+":"+c.Target+":"+c.ID] = c.Matched
+	}
+	want := map[string]bool{
+		"header:X-Tenant:":          true,
+		"header:X-Beta:header_beta": false,
+		"cookie:beta:cookie_beta":   false,
+		"query:beta:query_beta":     true,
+	}
+	for k, v := range want {
+		if m, ok := got[k]; !ok || m != v {
+			t.Errorf("condition %s: got %v (present %v), want %v", k, m, ok, v)
+		}
+	}
+
+	if _, ok := r.ExplainMatch("missing", req); ok {
+		t.Error("expected unknown route to be reported")
+	}
+}
+
+func BenchmarkRouterMatchWithExpression(b *testing.B) {
+	r := New()
+	r.AddRoute(betaExpressionRoute())
+
+	req, _ := http.NewRequest("GET", "/app?beta=1", nil)
+	req.Header.Set("X-Tenant", "acme")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ReleaseMatch(r.Match(req))
+	}
+}
+
+func BenchmarkRouterMatchWithoutExpression(b *testing.B) {
+	r := New()
+	r.AddRoute(config.RouteConfig{
+		ID:   "v2",
+		Path: "/app",
+		Match: config.MatchConfig{
+			Headers: []config.HeaderMatchConfig{{Name: "X-Tenant", Value: "acme"}},
+			Query:   []config.QueryMatchConfig{{Name: "beta", Value: "1"}},
+		},
+		Backends: []config.BackendConfig{{URL: "http://localhost:9002"}},
+	})
+
+	req, _ := http.NewRequest("GET", "/app?beta=1", nil)
+	req.Header.Set("X-Tenant", "acme")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ReleaseMatch(r.Match(req))
+	}
+}
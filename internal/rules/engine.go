@@ -25,6 +25,7 @@ type RuleEngine struct {
 	requestRules  []*CompiledRule
 	responseRules []*CompiledRule
 	metrics       *Metrics
+	usesBody      bool       // a request rule reads the body namespace
 	luaPool       *sync.Pool // Lua VM pool, initialized when any rule uses action=="lua"
 }
 
@@ -41,6 +42,9 @@ func NewEngine(reqCfgs, respCfgs []config.RuleConfig) (*RuleEngine, error) {
 			return nil, err
 		}
 		e.requestRules = append(e.requestRules, cr)
+		if cr.usesBody {
+			e.usesBody = true
+		}
 		if cfg.Action == "lua" {
 			hasLua = true
 		}
@@ -144,6 +148,12 @@ func (e *RuleEngine) HasResponseRules() bool {
 	return len(e.responseRules) > 0
 }
 
+// UsesBody returns true if any request rule reads JSON body fields
+// (the body namespace), so the body must be parsed before evaluation.
+func (e *RuleEngine) UsesBody() bool {
+	return e.usesBody
+}
+
 // GetMetrics returns the metrics snapshot.
 func (e *RuleEngine) GetMetrics() MetricsSnapshot {
 	return e.metrics.Snapshot()
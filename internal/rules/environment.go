@@ -30,10 +30,15 @@ var requestEnvPool = sync.Pool{
 			Auth: AuthEnv{
 				Claims: make(map[string]any, 4),
 			},
+			Baggage: make(map[string]string, 4),
 		}
 	},
 }
 
+// noRouteMetadata is route.metadata for routes without metadata. Route
+// metadata is never modified, so it is shared rather than copied.
+var noRouteMetadata = map[string]string{}
+
 // AcquireRequestEnv returns a pooled RequestEnv populated from the request.
 func AcquireRequestEnv(r *http.Request, varCtx *variables.Context) *RequestEnv {
 	env := requestEnvPool.Get().(*RequestEnv)
@@ -75,6 +80,9 @@ func ReleaseRequestEnv(env *RequestEnv) {
 	clear(env.HTTP.Response.Headers)
 	clear(env.Route.Params)
 	clear(env.Auth.Claims)
+	clear(env.Baggage)
+	env.Route.Metadata = nil
+	env.Body = nil
 	requestEnvPool.Put(env)
 }
 
@@ -138,9 +146,11 @@ func populateRequestEnv(env *RequestEnv, r *http.Request, varCtx *variables.Cont
 	}
 	env.Auth.ClientID = clientID
 	env.Auth.Type = authType
+	env.Auth.ConsumerGroup, env.Tenant = consumerEnv(varCtx)
 
 	// Route
 	var routeID string
+	routeMetadata := noRouteMetadata
 	if varCtx != nil {
 		routeID = varCtx.RouteID
 		params := env.Route.Params
@@ -148,14 +158,40 @@ func populateRequestEnv(env *RequestEnv, r *http.Request, varCtx *variables.Cont
 			params[k] = v
 		}
 		env.Route.Params = params
+		if varCtx.RouteMetadata != nil {
+			routeMetadata = varCtx.RouteMetadata
+		}
 	}
 	env.Route.ID = routeID
+	env.Route.Metadata = routeMetadata
+
+	var listenerID string
+	if varCtx != nil {
+		listenerID = varCtx.ListenerID
+	}
+	env.Listener.ID = listenerID
+
+	// Baggage members registered by the baggage middleware
+	if varCtx != nil {
+		for k, v := range varCtx.Baggage {
+			env.Baggage[k] = v
+		}
+	}
 
 	// Clear response fields (may be set from prior pool usage)
 	env.HTTP.Response.Code = 0
 	env.HTTP.Response.ResponseTime = 0
 }
 
+// consumerEnv returns the consumer group and tenant the consumer_group and
+// tenant middlewares resolved for the request.
+func consumerEnv(varCtx *variables.Context) (string, TenantEnv) {
+	if varCtx == nil {
+		return "", TenantEnv{}
+	}
+	return varCtx.ConsumerGroup, TenantEnv{ID: varCtx.TenantID, Tier: varCtx.TenantTier}
+}
+
 // GeoEnv provides geolocation fields populated by the geo middleware.
 type GeoEnv struct {
 	Country     string `expr:"country"`      // ISO 3166-1 alpha-2 code
@@ -166,11 +202,16 @@ type GeoEnv struct {
 // RequestEnv is the expression environment for request-phase rules.
 // Field names use Cloudflare-style dot notation via expr struct tags.
 type RequestEnv struct {
-	HTTP  HTTPEnv  `expr:"http"`
-	IP    IPEnv    `expr:"ip"`
-	Geo   GeoEnv   `expr:"geo"`
-	Route RouteEnv `expr:"route"`
-	Auth  AuthEnv  `expr:"auth"`
+	HTTP     HTTPEnv     `expr:"http"`
+	IP       IPEnv       `expr:"ip"`
+	Geo      GeoEnv      `expr:"geo"`
+	Route    RouteEnv    `expr:"route"`
+	Listener ListenerEnv `expr:"listener"`
+	Auth     AuthEnv     `expr:"auth"`
+	Tenant   TenantEnv   `expr:"tenant"`
+
+	Baggage map[string]string `expr:"baggage"` // W3C baggage members (see variables.Context.Baggage)
+	Body    map[string]any    `expr:"body"`    // JSON body fields, set only when a rule reads them (see RuleEngine.UsesBody)
 }
 
 // HTTPEnv groups HTTP-related fields.
@@ -205,15 +246,28 @@ type IPEnv struct {
 
 // RouteEnv provides route context.
 type RouteEnv struct {
-	ID     string            `expr:"id"`
-	Params map[string]string `expr:"params"`
+	ID       string            `expr:"id"`
+	Params   map[string]string `expr:"params"`
+	Metadata map[string]string `expr:"metadata"` // route metadata (see config.RouteConfig.Metadata)
+}
+
+// ListenerEnv provides the listener that received the request.
+type ListenerEnv struct {
+	ID string `expr:"id"` // empty for handlers not bound to a listener
 }
 
 // AuthEnv provides authentication context.
 type AuthEnv struct {
-	ClientID string         `expr:"client_id"`
-	Type     string         `expr:"type"`
-	Claims   map[string]any `expr:"claims"`
+	ClientID      string         `expr:"client_id"`
+	Type          string         `expr:"type"`
+	Claims        map[string]any `expr:"claims"`
+	ConsumerGroup string         `expr:"consumer_group"` // resolved consumer group name
+}
+
+// TenantEnv provides the resolved tenant (populated by the tenant middleware).
+type TenantEnv struct {
+	ID   string `expr:"id"`
+	Tier string `expr:"tier"`
 }
 
 // HTTPResponseEnv provides response fields (only populated in response phase).
@@ -279,15 +333,31 @@ func NewRequestEnv(r *http.Request, varCtx *variables.Context) RequestEnv {
 	}
 
 	// Route fields
-	var routeID string
-	var pathParams map[string]string
+	var routeID, listenerID string
+	var pathParams, routeMetadata map[string]string
 	if varCtx != nil {
 		routeID = varCtx.RouteID
+		listenerID = varCtx.ListenerID
 		pathParams = varCtx.PathParams
+		routeMetadata = varCtx.RouteMetadata
 	}
 	if pathParams == nil {
 		pathParams = make(map[string]string)
 	}
+	if routeMetadata == nil {
+		routeMetadata = noRouteMetadata
+	}
+
+	group, tenantEnv := consumerEnv(varCtx)
+
+	// Baggage members
+	var bag map[string]string
+	if varCtx != nil {
+		bag = varCtx.Baggage
+	}
+	if bag == nil {
+		bag = make(map[string]string)
+	}
 
 	return RequestEnv{
 		HTTP: HTTPEnv{
@@ -311,14 +381,19 @@ func NewRequestEnv(r *http.Request, varCtx *variables.Context) RequestEnv {
 		},
 		Geo: geoEnv,
 		Route: RouteEnv{
-			ID:     routeID,
-			Params: pathParams,
+			ID:       routeID,
+			Params:   pathParams,
+			Metadata: routeMetadata,
 		},
+		Listener: ListenerEnv{ID: listenerID},
 		Auth: AuthEnv{
-			ClientID: clientID,
-			Type:     authType,
-			Claims:   claims,
+			ClientID:      clientID,
+			Type:          authType,
+			Claims:        claims,
+			ConsumerGroup: group,
 		},
+		Tenant:  tenantEnv,
+		Baggage: bag,
 	}
 }
 
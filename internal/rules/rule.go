@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
 	"github.com/expr-lang/expr/vm"
 	lua "github.com/yuin/gopher-lua"
 
@@ -20,6 +21,7 @@ type CompiledRule struct {
 	program    *vm.Program
 	Action     Action
 	Enabled    bool
+	usesBody   bool // expression reads the body namespace
 }
 
 // Action defines what happens when a rule matches.
@@ -79,9 +81,30 @@ func CompileRequestRule(cfg config.RuleConfig) (*CompiledRule, error) {
 		program:    program,
 		Action:     action,
 		Enabled:    enabled,
+		usesBody:   referencesIdentifier(program, "body"),
 	}, nil
 }
 
+// identifierFinder is an AST visitor that looks for an identifier.
+type identifierFinder struct {
+	name  string
+	found bool
+}
+
+func (f *identifierFinder) Visit(node *ast.Node) {
+	if id, ok := (*node).(*ast.IdentifierNode); ok && id.Value == f.name {
+		f.found = true
+	}
+}
+
+// referencesIdentifier reports whether program's expression uses name.
+func referencesIdentifier(program *vm.Program, name string) bool {
+	f := &identifierFinder{name: name}
+	node := program.Node()
+	ast.Walk(&node, f)
+	return f.found
+}
+
 // CompileResponseRule compiles a rule config for the response phase.
 func CompileResponseRule(cfg config.RuleConfig) (*CompiledRule, error) {
 	enabled := true
@@ -121,6 +144,32 @@ func (cr *CompiledRule) Evaluate(env any) (bool, error) {
 	return result, nil
 }
 
+// Condition is a compiled boolean expression over the request environment,
+// for features that gate behavior on a rules-engine predicate.
+type Condition struct {
+	Expression string
+	program    *vm.Program
+}
+
+// CompileCondition compiles an expression against the request environment.
+func CompileCondition(expression string) (*Condition, error) {
+	program, err := expr.Compile(expression, expr.Env(RequestEnv{}), expr.AsBool())
+	if err != nil {
+		return nil, err
+	}
+	return &Condition{Expression: expression, program: program}, nil
+}
+
+// Match evaluates the condition. Evaluation errors count as no match.
+func (c *Condition) Match(env *RequestEnv) bool {
+	output, err := expr.Run(c.program, env)
+	if err != nil {
+		return false
+	}
+	result, _ := output.(bool)
+	return result
+}
+
 func actionFromConfig(cfg config.RuleConfig) (Action, error) {
 	var luaProto *lua.FunctionProto
 	if cfg.Action == "lua" && cfg.LuaScript != "" {
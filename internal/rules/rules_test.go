@@ -78,6 +78,145 @@ func TestNewRequestEnv(t *testing.T) {
 	}
 }
 
+func TestNewRequestEnv_Baggage(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://localhost/", nil)
+	varCtx := &variables.Context{Request: r}
+	varCtx.SetBaggage("tenant", "acme")
+
+	env := NewRequestEnv(r, varCtx)
+	if env.Baggage["tenant"] != "acme" {
+		t.Errorf("expected baggage.tenant acme, got %q", env.Baggage["tenant"])
+	}
+
+	rule, err := CompileRequestRule(config.RuleConfig{
+		ID:         "baggage-rule",
+		Expression: `baggage["tenant"] == "acme"`,
+		Action:     "block",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched, err := rule.Evaluate(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matched {
+		t.Error("expected baggage expression to match")
+	}
+}
+
+func TestRequestEnv_RouteMetadata(t *testing.T) {
+	rule, err := CompileRequestRule(config.RuleConfig{
+		ID:         "tier-rule",
+		Expression: `route.metadata.tier == "gold"`,
+		Action:     "block",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "http://localhost/", nil)
+	env := AcquireRequestEnv(r, &variables.Context{Request: r, RouteMetadata: map[string]string{"tier": "gold"}})
+	if matched, err := rule.Evaluate(*env); err != nil || !matched {
+		t.Errorf("expected route metadata expression to match, got %v, %v", matched, err)
+	}
+	ReleaseRequestEnv(env)
+
+	// Routes without metadata evaluate without error.
+	env2 := NewRequestEnv(r, &variables.Context{Request: r})
+	if matched, err := rule.Evaluate(env2); err != nil || matched {
+		t.Errorf("expected no match without metadata, got %v, %v", matched, err)
+	}
+}
+
+func TestRequestEnv_Listener(t *testing.T) {
+	rule, err := CompileRequestRule(config.RuleConfig{
+		ID:         "public-only",
+		Expression: `listener.id == "public"`,
+		Action:     "block",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "http://localhost/", nil)
+	env := AcquireRequestEnv(r, &variables.Context{Request: r, ListenerID: "public"})
+	if matched, err := rule.Evaluate(*env); err != nil || !matched {
+		t.Errorf("expected listener expression to match, got %v, %v", matched, err)
+	}
+	ReleaseRequestEnv(env)
+
+	env2 := NewRequestEnv(r, &variables.Context{Request: r, ListenerID: "internal"})
+	if matched, err := rule.Evaluate(env2); err != nil || matched {
+		t.Errorf("expected no match via another listener, got %v, %v", matched, err)
+	}
+}
+
+func TestRequestEnv_Body(t *testing.T) {
+	engine, err := NewEngine([]config.RuleConfig{
+		{ID: "gold", Expression: `body?.user?.tier == "gold"`, Action: "block"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !engine.UsesBody() {
+		t.Fatal("expected engine to report body usage")
+	}
+
+	r := httptest.NewRequest("POST", "http://localhost/", nil)
+	env := NewRequestEnv(r, &variables.Context{Request: r})
+	env.Body = map[string]any{"user": map[string]any{"tier": "gold"}}
+	if results := engine.EvaluateRequest(&env); len(results) != 1 {
+		t.Errorf("expected body rule to match, got %v", results)
+	}
+
+	// Without body fields the expression evaluates without error.
+	env.Body = nil
+	if results := engine.EvaluateRequest(&env); len(results) != 0 {
+		t.Errorf("expected no match without a body, got %v", results)
+	}
+	if m := engine.GetMetrics(); m.Errors != 0 {
+		t.Errorf("expected no evaluation errors, got %d", m.Errors)
+	}
+
+	other, _ := NewEngine([]config.RuleConfig{
+		{ID: "path", Expression: `http.request.uri.path == "/body"`, Action: "block"},
+	}, nil)
+	if other.UsesBody() {
+		t.Error("expected engine without body references to skip body parsing")
+	}
+}
+
+func TestNewRequestEnv_ConsumerGroupAndTenant(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://localhost/", nil)
+	varCtx := &variables.Context{Request: r, ConsumerGroup: "gold", TenantID: "acme", TenantTier: "free"}
+
+	env := NewRequestEnv(r, varCtx)
+	if env.Auth.ConsumerGroup != "gold" || env.Tenant.ID != "acme" || env.Tenant.Tier != "free" {
+		t.Errorf("unexpected env: group=%q tenant=%+v", env.Auth.ConsumerGroup, env.Tenant)
+	}
+
+	pooled := AcquireRequestEnv(r, varCtx)
+	defer ReleaseRequestEnv(pooled)
+
+	cond, err := CompileCondition(`auth.consumer_group == "gold" && tenant.tier == "free"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cond.Match(pooled) {
+		t.Error("expected condition to match")
+	}
+
+	// Evaluation errors count as no match
+	cond, err = CompileCondition(`auth.claims.plan.tier == "x"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cond.Match(pooled) {
+		t.Error("expected erroring condition not to match")
+	}
+}
+
 func TestNewRequestEnv_NilVarCtx(t *testing.T) {
 	r := httptest.NewRequest("GET", "http://localhost/", nil)
 	env := NewRequestEnv(r, nil)
@@ -0,0 +1,56 @@
+package runway
+
+import (
+	"slices"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/artifact"
+)
+
+// ArtifactStatus is a cached remote artifact and the config fields that
+// reference it.
+type ArtifactStatus struct {
+	artifact.Entry
+	References []config.ArtifactRef `json:"references"`
+	Routes     []string             `json:"routes"`
+}
+
+// Artifacts lists the artifact cache of the current config. Routes holds
+// the routes referencing each artifact, including the routes generated
+// from or validated against a top-level OpenAPI spec it holds. Cached
+// artifacts no field references any more have no references.
+func (g *Runway) Artifacts() ([]ArtifactStatus, error) {
+	cfg := g.currentConfig()
+	entries, err := cfg.Artifacts.ArtifactStore(nil).List()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ArtifactStatus, 0, len(entries))
+	for _, e := range entries {
+		st := ArtifactStatus{Entry: e, References: []config.ArtifactRef{}, Routes: []string{}}
+		for _, ref := range cfg.ArtifactRefs {
+			if ref.Digest != e.Digest {
+				continue
+			}
+			st.References = append(st.References, ref)
+			if ref.Route != "" {
+				st.Routes = append(st.Routes, ref.Route)
+				continue
+			}
+			for _, spec := range cfg.OpenAPI.Specs {
+				if spec.File != ref.Path {
+					continue
+				}
+				for _, rc := range cfg.Routes {
+					if rc.OpenAPI.SpecID == spec.ID {
+						st.Routes = append(st.Routes, rc.ID)
+					}
+				}
+			}
+		}
+		slices.Sort(st.Routes)
+		st.Routes = slices.Compact(st.Routes)
+		out = append(out, st)
+	}
+	return out, nil
+}
@@ -0,0 +1,77 @@
+package runway
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+func TestArtifactsAdmin(t *testing.T) {
+	spec := []byte("openapi: 3.0.0\ninfo: {title: items, version: '1'}\n" +
+		"paths:\n  /items:\n    get:\n      operationId: listItems\n      responses:\n        '200': {description: ok}\n")
+	sum := sha256.Sum256(spec)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(spec)
+	}))
+	defer srv.Close()
+
+	cacheDir := t.TempDir()
+	// A copy left behind by an earlier config.
+	stale := []byte("old plugin")
+	staleSum := sha256.Sum256(stale)
+	os.MkdirAll(filepath.Join(cacheDir, "sha256"), 0o755)
+	os.WriteFile(filepath.Join(cacheDir, "sha256", hex.EncodeToString(staleSum[:])), stale, 0o644)
+
+	ref := srv.URL + "/items.yaml@" + digest
+	yaml := "listeners:\n  - id: http\n    address: \":0\"\n    protocol: http\n" +
+		"registry:\n  type: memory\n" +
+		"admin:\n  enabled: true\n  port: 8082\n" +
+		"artifacts:\n  cache_dir: " + cacheDir + "\n" +
+		"openapi:\n  specs:\n    - id: items\n      file: " + ref + "\n      default_backends:\n        - url: http://localhost:9000\n" +
+		"routes:\n  - id: orders\n    path: /orders\n    backends:\n      - url: http://localhost:9000\n    openapi:\n      spec_file: " + ref + "\n      operation_id: listItems\n"
+	cfg, err := config.NewLoader(config.WithArtifactClient(srv.Client())).Parse([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/artifacts", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	var artifacts []ArtifactStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &artifacts); err != nil {
+		t.Fatal(err)
+	}
+	byDigest := make(map[string]ArtifactStatus)
+	for _, a := range artifacts {
+		byDigest[a.Digest] = a
+	}
+	if len(byDigest) != 2 {
+		t.Fatalf("expected the spec and the stale copy, got %+v", artifacts)
+	}
+	a := byDigest[digest]
+	if a.Size != int64(len(spec)) || len(a.References) != 2 || a.References[0].Reference != ref {
+		t.Errorf("unexpected spec artifact %+v", a)
+	}
+	// The spec's generated routes and the route naming it directly.
+	if len(a.Routes) < 2 || a.Routes[len(a.Routes)-1] != "orders" {
+		t.Errorf("expected the generated routes and orders, got %v", a.Routes)
+	}
+	if st := byDigest["sha256:"+hex.EncodeToString(staleSum[:])]; len(st.References) != 0 || len(st.Routes) != 0 {
+		t.Errorf("expected the stale copy unreferenced, got %+v", st)
+	}
+}
@@ -0,0 +1,166 @@
+package runway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/wudi/runway/config"
+)
+
+func TestAuthRequirements(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	const secret = "test-secret-key-for-testing"
+	quiet := false
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Authentication: config.AuthenticationConfig{
+			APIKey: config.APIKeyConfig{
+				Enabled: true,
+				Header:  "X-API-Key",
+				Keys: []config.APIKeyEntry{
+					{Key: "admin-key", ClientID: "ops", Roles: []string{"admin"}},
+					{Key: "reader-key", ClientID: "viewer", Roles: []string{"reader"}},
+				},
+			},
+			JWT: config.JWTConfig{Enabled: true, Secret: secret, Algorithm: "HS256"},
+		},
+		Routes: []config.RouteConfig{
+			{
+				ID:       "orders",
+				Path:     "/orders",
+				Backends: []config.BackendConfig{{URL: backend.URL}},
+				Auth: config.RouteAuthConfig{
+					Required: true,
+					Methods:  []string{"jwt", "api_key"},
+					Requirements: config.AuthRequirementsConfig{
+						AuthRequirement: config.AuthRequirement{ScopesAny: []string{"orders:read"}},
+						Methods: map[string]config.AuthRequirement{
+							"DELETE": {RolesAny: []string{"admin"}},
+						},
+					},
+				},
+			},
+			{
+				ID:       "reports",
+				Path:     "/reports",
+				Backends: []config.BackendConfig{{URL: backend.URL}},
+				Auth: config.RouteAuthConfig{
+					Required: true,
+					Methods:  []string{"api_key"},
+					Requirements: config.AuthRequirementsConfig{
+						AuthRequirement: config.AuthRequirement{RolesAny: []string{"admin"}},
+						VerboseErrors:   &quiet,
+					},
+				},
+			},
+		},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	token := func(scope string) string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub":   "alice",
+			"scope": scope,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	handler := server.Runway().Handler()
+	do := func(method, path, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// JWT scope path.
+	if rec := do("GET", "/orders", "Authorization", "Bearer "+token("openid orders:read")); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 with orders:read scope, got %d: %s", rec.Code, rec.Body)
+	}
+	rec := do("GET", "/orders", "Authorization", "Bearer "+token("openid profile"))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without orders:read scope, got %d", rec.Code)
+	}
+	var body struct {
+		Code        int      `json:"code"`
+		Message     string   `json:"message"`
+		Requirement string   `json:"requirement"`
+		Missing     []string `json:"missing"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != http.StatusForbidden || body.Requirement != "scopes_any" || len(body.Missing) != 1 || body.Missing[0] != "orders:read" {
+		t.Errorf("unexpected 403 body %+v", body)
+	}
+	if rec := do("GET", "/orders", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without credentials, got %d", rec.Code)
+	}
+
+	// Method override: DELETE needs the admin role instead of the scope.
+	if rec := do("DELETE", "/orders", "X-API-Key", "admin-key"); rec.Code != http.StatusOK {
+		t.Errorf("expected DELETE allowed for admin key, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do("DELETE", "/orders", "Authorization", "Bearer "+token("orders:read")); rec.Code != http.StatusForbidden {
+		t.Errorf("expected DELETE denied without admin role, got %d", rec.Code)
+	}
+
+	// API key role path with generic errors.
+	if rec := do("GET", "/reports", "X-API-Key", "admin-key"); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for admin key, got %d", rec.Code)
+	}
+	rec = do("GET", "/reports", "X-API-Key", "reader-key")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for reader key, got %d", rec.Code)
+	}
+	var generic map[string]interface{}
+	json.NewDecoder(rec.Body).Decode(&generic)
+	if _, ok := generic["requirement"]; ok {
+		t.Errorf("expected verbose_errors: false to hide the requirement, got %v", generic)
+	}
+
+	// Route stats count 403s separately from 401s.
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/routes", nil))
+	var routes []struct {
+		ID          string           `json:"id"`
+		AuthzDenied map[string]int64 `json:"authz_denied"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&routes); err != nil {
+		t.Fatal(err)
+	}
+	denied := map[string]map[string]int64{}
+	for _, r := range routes {
+		denied[r.ID] = r.AuthzDenied
+	}
+	if denied["orders"]["scopes_any"] != 1 || denied["orders"]["roles_any"] != 1 || denied["orders"]["total"] != 2 {
+		t.Errorf("unexpected orders denials %v", denied["orders"])
+	}
+	if denied["reports"]["roles_any"] != 1 {
+		t.Errorf("unexpected reports denials %v", denied["reports"])
+	}
+}
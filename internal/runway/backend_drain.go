@@ -0,0 +1,188 @@
+package runway
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/proxy"
+)
+
+// allRoutes is the backendDrains route key for a drain on every route.
+const allRoutes = ""
+
+// errDrainedOnAllRoutes is returned when undraining a single route of a
+// backend that is drained on every route.
+var errDrainedOnAllRoutes = errors.New("backend is drained on all routes; undrain it without a route")
+
+// backendDrains records the backends drained through the admin API, so that
+// drains survive config reloads and discovery updates. It is kept across
+// reloads; only the balancers' Drained flags are rebuilt.
+type backendDrains struct {
+	mu   sync.Mutex
+	urls map[string]map[string]bool // backend URL -> route IDs (allRoutes for every route)
+}
+
+// setDrained sets the drain flag for url on the route's balancer and reports
+// whether the balancer has that backend.
+func setDrained(rp *proxy.RouteProxy, url string, drained bool) bool {
+	d, ok := rp.GetBalancer().(loadbalancer.Drainer)
+	return ok && d.SetDrained(url, drained)
+}
+
+// BackendDrain describes a drained backend for GET /backends.
+type BackendDrain struct {
+	All    bool     // drained on every route
+	Routes []string // route IDs the drain applies to, when not All
+}
+
+// DrainBackend takes a backend out of rotation on one route, or on every
+// route when routeID is empty. Requests already in flight complete. It returns
+// the routes the backend was drained on.
+func (g *Runway) DrainBackend(url, routeID string) ([]string, error) {
+	g.drains.mu.Lock()
+	defer g.drains.mu.Unlock()
+
+	routes, err := g.setBackendDrained(url, routeID, true)
+	if err != nil {
+		return nil, err
+	}
+	if g.drains.urls == nil {
+		g.drains.urls = make(map[string]map[string]bool)
+	}
+	switch {
+	case routeID == allRoutes:
+		// A drain on every route subsumes the per-route ones.
+		g.drains.urls[url] = map[string]bool{allRoutes: true}
+	case g.drains.urls[url][allRoutes]:
+		// Already drained on every route.
+	default:
+		if g.drains.urls[url] == nil {
+			g.drains.urls[url] = make(map[string]bool)
+		}
+		g.drains.urls[url][routeID] = true
+	}
+	return routes, nil
+}
+
+// UndrainBackend returns a drained backend to rotation on one route, or on
+// every route when routeID is empty. A backend drained on every route must be
+// undrained without a route. It returns the routes the backend was undrained on.
+func (g *Runway) UndrainBackend(url, routeID string) ([]string, error) {
+	g.drains.mu.Lock()
+	defer g.drains.mu.Unlock()
+
+	if routeID != allRoutes && g.drains.urls[url][allRoutes] {
+		return nil, errDrainedOnAllRoutes
+	}
+	routes, err := g.setBackendDrained(url, routeID, false)
+	if err != nil {
+		return nil, err
+	}
+	if routeID == allRoutes {
+		delete(g.drains.urls, url)
+	} else if r := g.drains.urls[url]; r != nil {
+		delete(r, routeID)
+		if len(r) == 0 {
+			delete(g.drains.urls, url)
+		}
+	}
+	return routes, nil
+}
+
+// setBackendDrained sets the drain flag for url on routeID's balancer, or on
+// every route's when routeID is empty. Caller must hold g.drains.mu.
+func (g *Runway) setBackendDrained(url, routeID string, drained bool) ([]string, error) {
+	proxies := *g.routeProxies.Load()
+	if routeID != allRoutes {
+		rp, ok := proxies[routeID]
+		if !ok {
+			return nil, fmt.Errorf("route %q not found", routeID)
+		}
+		if !setDrained(rp, url, drained) {
+			return nil, fmt.Errorf("backend %q not found on route %q", url, routeID)
+		}
+		return []string{routeID}, nil
+	}
+
+	var routes []string
+	for id, rp := range proxies {
+		if setDrained(rp, url, drained) {
+			routes = append(routes, id)
+		}
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("backend %q not found on any route", url)
+	}
+	sort.Strings(routes)
+	return routes, nil
+}
+
+// applyBackendDrains sets the drain flags on a route proxy built for a new
+// route state, before it starts serving.
+func (g *Runway) applyBackendDrains(routeID string, rp *proxy.RouteProxy) {
+	g.drains.mu.Lock()
+	defer g.drains.mu.Unlock()
+
+	for url, routes := range g.drains.urls {
+		if routes[allRoutes] || routes[routeID] {
+			setDrained(rp, url, true)
+		}
+	}
+}
+
+// reapplyBackendDrains sets the drain flags on the current route balancers,
+// whose backends may have been rebuilt by a reload or discovery update. With
+// prune, drains whose backend or route no longer exists are forgotten.
+func (g *Runway) reapplyBackendDrains(prune bool) {
+	g.drains.mu.Lock()
+	defer g.drains.mu.Unlock()
+
+	proxies := *g.routeProxies.Load()
+	for url, routes := range g.drains.urls {
+		if routes[allRoutes] {
+			found := false
+			for _, rp := range proxies {
+				if setDrained(rp, url, true) {
+					found = true
+				}
+			}
+			if prune && !found {
+				delete(g.drains.urls, url)
+			}
+			continue
+		}
+		for id := range routes {
+			rp, ok := proxies[id]
+			if (!ok || !setDrained(rp, url, true)) && prune {
+				delete(routes, id)
+			}
+		}
+		if len(routes) == 0 {
+			delete(g.drains.urls, url)
+		}
+	}
+}
+
+// BackendDrains returns the drained backends keyed by URL.
+func (g *Runway) BackendDrains() map[string]BackendDrain {
+	g.drains.mu.Lock()
+	defer g.drains.mu.Unlock()
+
+	result := make(map[string]BackendDrain, len(g.drains.urls))
+	for url, routes := range g.drains.urls {
+		if routes[allRoutes] {
+			result[url] = BackendDrain{All: true}
+			continue
+		}
+		var ids []string
+		for id := range routes {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		result[url] = BackendDrain{Routes: ids}
+	}
+	return result
+}
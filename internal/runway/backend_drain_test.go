@@ -0,0 +1,247 @@
+package runway
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+// newDrainConfig returns a config with an "orders" route on both backends and
+// a "plain" route on the first.
+func newDrainConfig(b1, b2 string) *config.Config {
+	return &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{
+			{ID: "orders", Path: "/orders", Backends: []config.BackendConfig{{URL: b1}, {URL: b2}}},
+			{ID: "plain", Path: "/plain", Backends: []config.BackendConfig{{URL: b1}}},
+		},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+}
+
+func newDrainServer(t *testing.T) (s *Server, b1, b2 string) {
+	t.Helper()
+	var urls []string
+	for i := 0; i < 2; i++ {
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "ok")
+		}))
+		t.Cleanup(backend.Close)
+		urls = append(urls, backend.URL)
+	}
+
+	server, err := NewServer(newDrainConfig(urls[0], urls[1]), "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	t.Cleanup(func() { server.Runway().Close() })
+	return server, urls[0], urls[1]
+}
+
+// isDrained reports whether url is drained on the route's current balancer.
+func isDrained(t *testing.T, g *Runway, routeID, url string) bool {
+	t.Helper()
+	rp, ok := (*g.routeProxies.Load())[routeID]
+	if !ok {
+		t.Fatalf("route %q not found", routeID)
+	}
+	for _, b := range rp.GetBalancer().GetBackends() {
+		if b.URL == url {
+			return b.Drained
+		}
+	}
+	t.Fatalf("backend %q not found on route %q", url, routeID)
+	return false
+}
+
+func TestBackendDrainRoutes(t *testing.T) {
+	s, b1, b2 := newDrainServer(t)
+	g := s.Runway()
+
+	// A per-route drain leaves the backend in rotation on other routes.
+	routes, err := g.DrainBackend(b1, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(routes, []string{"orders"}) {
+		t.Errorf("expected [orders], got %v", routes)
+	}
+	if !isDrained(t, g, "orders", b1) || isDrained(t, g, "plain", b1) {
+		t.Error("expected b1 drained on orders only")
+	}
+	if d := g.BackendDrains()[b1]; d.All || !slices.Equal(d.Routes, []string{"orders"}) {
+		t.Errorf("unexpected drain %+v", d)
+	}
+
+	// A drain on every route subsumes the per-route one.
+	routes, err = g.DrainBackend(b1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(routes, []string{"orders", "plain"}) {
+		t.Errorf("expected [orders plain], got %v", routes)
+	}
+	if d := g.BackendDrains()[b1]; !d.All || d.Routes != nil {
+		t.Errorf("expected an all-routes drain, got %+v", d)
+	}
+	if _, err := g.UndrainBackend(b1, "plain"); !errors.Is(err, errDrainedOnAllRoutes) {
+		t.Errorf("expected errDrainedOnAllRoutes, got %v", err)
+	}
+	if !isDrained(t, g, "plain", b1) {
+		t.Error("expected b1 still drained on plain")
+	}
+	if _, err := g.UndrainBackend(b1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if isDrained(t, g, "orders", b1) || isDrained(t, g, "plain", b1) {
+		t.Error("expected b1 undrained on every route")
+	}
+	if len(g.BackendDrains()) != 0 {
+		t.Errorf("expected no drains, got %v", g.BackendDrains())
+	}
+
+	// Undraining the last per-route drain forgets the backend.
+	if _, err := g.DrainBackend(b2, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.UndrainBackend(b2, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if isDrained(t, g, "orders", b2) || len(g.BackendDrains()) != 0 {
+		t.Error("expected b2 undrained and forgotten")
+	}
+
+	if _, err := g.DrainBackend(b2, "plain"); err == nil {
+		t.Error("expected an error for a backend not on the route")
+	}
+	if _, err := g.DrainBackend(b1, "missing"); err == nil {
+		t.Error("expected an error for an unknown route")
+	}
+	if _, err := g.DrainBackend("http://unknown:1", ""); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+}
+
+func TestBackendDrainReload(t *testing.T) {
+	s, b1, b2 := newDrainServer(t)
+	g := s.Runway()
+
+	if _, err := g.DrainBackend(b1, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.DrainBackend(b2, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := g.Reload(newDrainConfig(b1, b2)); !result.Success {
+		t.Fatalf("Reload failed: %s", result.Error)
+	}
+	if !isDrained(t, g, "orders", b1) || isDrained(t, g, "plain", b1) {
+		t.Error("expected b1 still drained on orders only after reload")
+	}
+	if !isDrained(t, g, "orders", b2) {
+		t.Error("expected b2 still drained after reload")
+	}
+
+	// Drains whose backend or route is gone are forgotten.
+	cfg := newDrainConfig(b1, b2)
+	cfg.Routes = cfg.Routes[1:]
+	if result := g.Reload(cfg); !result.Success {
+		t.Fatalf("Reload failed: %s", result.Error)
+	}
+	if drains := g.BackendDrains(); len(drains) != 0 {
+		t.Errorf("expected stale drains to be pruned, got %v", drains)
+	}
+	if isDrained(t, g, "plain", b1) {
+		t.Error("expected b1 active on plain")
+	}
+}
+
+func TestBackendDrainAdmin(t *testing.T) {
+	s, b1, b2 := newDrainServer(t)
+
+	for body, want := range map[string]int{
+		`{}`:                                     http.StatusBadRequest,
+		`not json`:                               http.StatusBadRequest,
+		`{"url":"http://unknown:1"}`:             http.StatusNotFound,
+		`{"url":"` + b2 + `","route":"plain"}`:   http.StatusNotFound,
+		`{"url":"` + b1 + `","route":"missing"}`: http.StatusNotFound,
+	} {
+		if w := postUpstreamAction(s, "/backends/drain", body); w.Code != want {
+			t.Errorf("%s: expected %d, got %d: %s", body, want, w.Code, w.Body)
+		}
+	}
+	w := httptest.NewRecorder()
+	s.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/backends/drain", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+
+	w = postUpstreamAction(s, "/backends/drain", `{"url":"`+b1+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected drain, got %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		URL        string   `json:"url"`
+		AdminState string   `json:"admin_state"`
+		Routes     []string `json:"routes"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.URL != b1 || resp.AdminState != "drained" || !slices.Equal(resp.Routes, []string{"orders", "plain"}) {
+		t.Errorf("unexpected drain response %+v", resp)
+	}
+
+	if w := postUpstreamAction(s, "/backends/undrain", `{"url":"`+b1+`","route":"plain"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 undraining one route of an all-routes drain, got %d: %s", w.Code, w.Body)
+	}
+	if w := postUpstreamAction(s, "/backends/drain", `{"url":"`+b2+`","route":"orders"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected drain, got %d: %s", w.Code, w.Body)
+	}
+
+	type backendStatus struct {
+		URL           string   `json:"url"`
+		AdminState    string   `json:"admin_state"`
+		DrainedRoutes []string `json:"drained_routes"`
+	}
+	getBackends := func() map[string]backendStatus {
+		w := httptest.NewRecorder()
+		s.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/backends", nil))
+		var list []backendStatus
+		if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+			t.Fatalf("decode /backends: %v", err)
+		}
+		result := make(map[string]backendStatus, len(list))
+		for _, b := range list {
+			result[b.URL] = b
+		}
+		return result
+	}
+	backends := getBackends()
+	if b := backends[b1]; b.AdminState != "drained" || b.DrainedRoutes != nil {
+		t.Errorf("expected b1 drained on every route, got %+v", b)
+	}
+	if b := backends[b2]; b.AdminState != "drained" || !slices.Equal(b.DrainedRoutes, []string{"orders"}) {
+		t.Errorf("expected b2 drained on orders, got %+v", b)
+	}
+
+	w = postUpstreamAction(s, "/backends/undrain", `{"url":"`+b1+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected undrain, got %d: %s", w.Code, w.Body)
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.AdminState != "active" {
+		t.Errorf("expected active, got %+v", resp)
+	}
+	if b := getBackends()[b1]; b.AdminState != "active" {
+		t.Errorf("expected b1 active, got %+v", b)
+	}
+}
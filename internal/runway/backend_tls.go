@@ -0,0 +1,142 @@
+package runway
+
+import (
+	"net"
+	"net/url"
+	"slices"
+	"sort"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/health"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/tlsscan"
+	"github.com/wudi/runway/internal/webhook"
+	"go.uber.org/zap"
+)
+
+// initBackendTLSScan starts scanning backend certificates when enabled.
+func (g *Runway) initBackendTLSScan(cfg *config.Config) {
+	ts := cfg.Admin.BackendTLSScan
+	if !ts.Enabled {
+		return
+	}
+	s := tlsscan.New(tlsscan.Config{
+		Interval:        ts.Interval,
+		Timeout:         ts.Timeout,
+		ExpiryThreshold: ts.ExpiryThreshold,
+		Rate:            ts.Rate,
+		Targets:         g.backendTLSTargets,
+		OnExpiring:      g.onBackendCertExpiring,
+	})
+	g.tlsScanConfig = ts
+	g.tlsScanner.Store(s)
+	s.Start()
+}
+
+// reloadBackendTLSScan applies scan settings from a reloaded config. The
+// scanner keeps running unchanged when its settings are the same; targets
+// are always read from the live routes at scan time.
+func (g *Runway) reloadBackendTLSScan(cfg *config.Config) {
+	s := g.tlsScanner.Load()
+	if s != nil && cfg.Admin.BackendTLSScan == g.tlsScanConfig {
+		return
+	}
+	if s != nil {
+		g.tlsScanner.Store(nil)
+		s.Stop()
+	}
+	g.initBackendTLSScan(cfg)
+}
+
+// onBackendCertExpiring logs a backend certificate nearing expiry and emits
+// a webhook event.
+func (g *Runway) onBackendCertExpiring(r tlsscan.Result) {
+	logging.Warn("Backend TLS certificate expiring soon",
+		zap.String("address", r.Address),
+		zap.String("server_name", r.ServerName),
+		zap.Strings("routes", r.Routes),
+		zap.Time("not_after", r.NotAfter),
+		zap.Int("days_left", r.DaysLeft),
+	)
+	if g.webhookDispatcher != nil {
+		g.webhookDispatcher.Emit(webhook.NewEvent(webhook.BackendCertExpiring, "", map[string]interface{}{
+			"address":     r.Address,
+			"server_name": r.ServerName,
+			"upstream":    r.Upstream,
+			"routes":      r.Routes,
+			"not_after":   r.NotAfter,
+			"days_left":   r.DaysLeft,
+		}))
+	}
+}
+
+// backendTLSTargets collects the unique https backends of the live routes
+// (including discovered instances) and of the configured upstream pools.
+// Each backend is dialed with the transport of its upstream.
+func (g *Runway) backendTLSTargets() []tlsscan.Target {
+	g.mu.RLock()
+	cfg := g.config
+	g.mu.RUnlock()
+	proxies := *g.routeProxies.Load()
+	pool := g.GetTransportPool()
+
+	targets := make(map[string]*tlsscan.Target)
+	var order []string
+	add := func(rawURL, upstream, routeID string, healthy bool) {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+			return
+		}
+		addr := u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		}
+		key := upstream + "|" + addr
+		t, ok := targets[key]
+		if !ok {
+			dial, tlsCfg := pool.TLSDialer(upstream)
+			t = &tlsscan.Target{
+				Address:    addr,
+				ServerName: u.Hostname(),
+				Upstream:   upstream,
+				Routes:     []string{},
+				Dial:       dial,
+				TLSConfig:  tlsCfg,
+			}
+			targets[key] = t
+			order = append(order, key)
+		}
+		t.Healthy = t.Healthy || healthy
+		if routeID != "" && !slices.Contains(t.Routes, routeID) {
+			t.Routes = append(t.Routes, routeID)
+		}
+	}
+
+	for _, routeCfg := range cfg.Routes {
+		rp, ok := proxies[routeCfg.ID]
+		if !ok {
+			continue
+		}
+		for _, b := range rp.GetBalancer().GetBackends() {
+			add(b.URL, routeCfg.Upstream, routeCfg.ID, b.Healthy)
+		}
+	}
+	for name, us := range cfg.Upstreams {
+		for _, b := range us.Backends {
+			add(b.URL, name, "", g.healthChecker.GetStatus(b.URL) != health.StatusUnhealthy)
+		}
+	}
+
+	sort.Strings(order)
+	out := make([]tlsscan.Target, 0, len(order))
+	for _, key := range order {
+		out = append(out, *targets[key])
+	}
+	return out
+}
+
+// GetBackendTLSScanner returns the backend TLS scanner, or nil when the scan
+// is disabled.
+func (g *Runway) GetBackendTLSScanner() *tlsscan.Scanner {
+	return g.tlsScanner.Load()
+}
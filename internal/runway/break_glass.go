@@ -0,0 +1,303 @@
+package runway
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/decision"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/auditlog"
+	"github.com/wudi/runway/internal/webhook"
+	"go.uber.org/zap"
+)
+
+const defaultBreakGlassMaxTTL = 60 * time.Minute
+
+// Break-glass revert causes, reported in webhook events and the audit log.
+const (
+	BreakGlassRevertManual  = "manual"
+	BreakGlassRevertExpired = "expired"
+	BreakGlassRevertReload  = "reload"
+)
+
+var (
+	// ErrBreakGlassNotConfigured is returned for routes without a
+	// break_glass profile.
+	ErrBreakGlassNotConfigured = errors.New("break-glass is not configured for route")
+	// ErrBreakGlassActive is returned when activating a route that is
+	// already in break-glass mode.
+	ErrBreakGlassActive = errors.New("break-glass is already active for route")
+	// ErrBreakGlassNotActive is returned when reverting a route that is not
+	// in break-glass mode.
+	ErrBreakGlassNotActive = errors.New("break-glass is not active for route")
+)
+
+// BreakGlassRequest is an operator's request to bypass a route's
+// break_glass features. The admin API is unauthenticated, so Actor is the
+// identity the caller gives; RemoteAddr is recorded alongside it.
+type BreakGlassRequest struct {
+	Actor      string
+	Reason     string
+	TTL        time.Duration
+	RemoteAddr string
+}
+
+// BreakGlassState is an active break-glass bypass on a route.
+type BreakGlassState struct {
+	Route       string    `json:"route"`
+	Features    []string  `json:"features"`
+	Skipped     []string  `json:"skipped,omitempty"`
+	Actor       string    `json:"actor"`
+	RemoteAddr  string    `json:"remote_addr"`
+	Reason      string    `json:"reason"`
+	ActivatedAt time.Time `json:"activated_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+
+	prior map[string]*bool // feature override in place before activation
+	timer *time.Timer
+}
+
+// breakGlass holds the active break-glass states. They live only in memory:
+// a restart clears them by design.
+type breakGlass struct {
+	mu     sync.RWMutex
+	active map[string]*BreakGlassState
+}
+
+func (b *breakGlass) isActive(routeID string) bool {
+	b.mu.RLock()
+	_, ok := b.active[routeID]
+	b.mu.RUnlock()
+	return ok
+}
+
+// middleware marks responses of a route in break-glass mode.
+func (b *breakGlass) middleware(routeID string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if b.isActive(routeID) {
+				w.Header().Set("X-Break-Glass", "true")
+				if rec := decision.FromContext(r.Context()); rec != nil {
+					rec.Add("break_glass", decision.Resolve, "active", "true")
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ActivateBreakGlass switches off the route's break_glass features through
+// runtime feature overrides until the TTL expires or the bypass is reverted.
+// Features that are not active on the route are reported as skipped.
+func (g *Runway) ActivateBreakGlass(routeID string, req BreakGlassRequest) (*BreakGlassState, error) {
+	g.mu.RLock()
+	var profile config.BreakGlassConfig
+	if idx := slices.IndexFunc(g.config.Routes, func(rc config.RouteConfig) bool { return rc.ID == routeID }); idx >= 0 {
+		profile = g.config.Routes[idx].BreakGlass
+	}
+	g.mu.RUnlock()
+	if !profile.Enabled {
+		return nil, ErrBreakGlassNotConfigured
+	}
+	if req.Reason == "" || req.Actor == "" {
+		return nil, errors.New("reason and actor are required")
+	}
+	maxTTL := profile.MaxTTL
+	if maxTTL <= 0 {
+		maxTTL = defaultBreakGlassMaxTTL
+	}
+	if req.TTL <= 0 || req.TTL > maxTTL {
+		return nil, fmt.Errorf("ttl must be > 0 and <= %s", maxTTL)
+	}
+
+	g.breakGlass.mu.Lock()
+	if _, ok := g.breakGlass.active[routeID]; ok {
+		g.breakGlass.mu.Unlock()
+		return nil, ErrBreakGlassActive
+	}
+
+	now := time.Now()
+	state := &BreakGlassState{
+		Route:       routeID,
+		Actor:       req.Actor,
+		RemoteAddr:  req.RemoteAddr,
+		Reason:      req.Reason,
+		ActivatedAt: now,
+		ExpiresAt:   now.Add(req.TTL),
+		prior:       make(map[string]*bool),
+	}
+	current := g.featureOverrides.Snapshot()[routeID]
+	for _, feature := range profile.Bypass {
+		if prev, ok := current[feature]; ok {
+			state.prior[feature] = &prev
+		} else {
+			state.prior[feature] = nil
+		}
+		if err := g.SetFeatureEnabled(routeID, feature, false); err != nil {
+			delete(state.prior, feature)
+			state.Skipped = append(state.Skipped, feature)
+			continue
+		}
+		state.Features = append(state.Features, feature)
+	}
+	if len(state.Features) == 0 {
+		g.breakGlass.mu.Unlock()
+		return nil, fmt.Errorf("none of the break_glass features %v are active on route %q", profile.Bypass, routeID)
+	}
+
+	if g.breakGlass.active == nil {
+		g.breakGlass.active = make(map[string]*BreakGlassState)
+	}
+	g.breakGlass.active[routeID] = state
+	state.timer = time.AfterFunc(req.TTL, func() {
+		g.revertBreakGlass(routeID, state, BreakGlassRevertExpired, "")
+	})
+	g.breakGlass.mu.Unlock()
+
+	g.recordBreakGlass(webhook.BreakGlassActivated, state, "", state.Actor)
+	return state.snapshot(), nil
+}
+
+// RevertBreakGlass ends a route's break-glass bypass and restores the
+// feature overrides that were in place before it.
+func (g *Runway) RevertBreakGlass(routeID, actor string) (*BreakGlassState, error) {
+	g.breakGlass.mu.RLock()
+	state, ok := g.breakGlass.active[routeID]
+	g.breakGlass.mu.RUnlock()
+	if !ok || !g.revertBreakGlass(routeID, state, BreakGlassRevertManual, actor) {
+		return nil, ErrBreakGlassNotActive
+	}
+	return state.snapshot(), nil
+}
+
+// revertBreakGlass removes state if it is still the route's active
+// break-glass, reporting whether it did.
+func (g *Runway) revertBreakGlass(routeID string, state *BreakGlassState, cause, actor string) bool {
+	g.breakGlass.mu.Lock()
+	if g.breakGlass.active[routeID] != state {
+		g.breakGlass.mu.Unlock()
+		return false
+	}
+	delete(g.breakGlass.active, routeID)
+	state.timer.Stop()
+	for feature, prev := range state.prior {
+		if prev == nil {
+			g.featureOverrides.Clear(routeID, feature)
+		} else {
+			g.featureOverrides.Set(routeID, feature, *prev)
+		}
+	}
+	g.breakGlass.mu.Unlock()
+
+	g.recordBreakGlass(webhook.BreakGlassReverted, state, cause, actor)
+	return true
+}
+
+// reconcileBreakGlass reverts bypasses on routes that a reload removed or
+// whose break_glass profile was switched off.
+func (g *Runway) reconcileBreakGlass(cfg *config.Config) {
+	g.breakGlass.mu.RLock()
+	var stale []*BreakGlassState
+	for id, state := range g.breakGlass.active {
+		idx := slices.IndexFunc(cfg.Routes, func(rc config.RouteConfig) bool { return rc.ID == id })
+		if idx < 0 || !cfg.Routes[idx].BreakGlass.Enabled {
+			stale = append(stale, state)
+		}
+	}
+	g.breakGlass.mu.RUnlock()
+	for _, state := range stale {
+		g.revertBreakGlass(state.Route, state, BreakGlassRevertReload, "")
+	}
+}
+
+// stopBreakGlass stops expiry timers on shutdown.
+func (g *Runway) stopBreakGlass() {
+	g.breakGlass.mu.Lock()
+	defer g.breakGlass.mu.Unlock()
+	for _, state := range g.breakGlass.active {
+		state.timer.Stop()
+	}
+}
+
+// BreakGlassStates returns the active break-glass bypasses, ordered by route.
+func (g *Runway) BreakGlassStates() []*BreakGlassState {
+	g.breakGlass.mu.RLock()
+	out := make([]*BreakGlassState, 0, len(g.breakGlass.active))
+	for _, state := range g.breakGlass.active {
+		out = append(out, state.snapshot())
+	}
+	g.breakGlass.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out
+}
+
+func (s *BreakGlassState) snapshot() *BreakGlassState {
+	return &BreakGlassState{
+		Route:       s.Route,
+		Features:    s.Features,
+		Skipped:     s.Skipped,
+		Actor:       s.Actor,
+		RemoteAddr:  s.RemoteAddr,
+		Reason:      s.Reason,
+		ActivatedAt: s.ActivatedAt,
+		ExpiresAt:   s.ExpiresAt,
+	}
+}
+
+// recordBreakGlass logs a break-glass change, writes it to the route's audit
+// log (when audit_log is enabled for the route) and emits a webhook event.
+func (g *Runway) recordBreakGlass(typ webhook.EventType, state *BreakGlassState, cause, actor string) {
+	if actor == "" {
+		actor = state.Actor
+	}
+	logging.Warn("break-glass changed",
+		zap.String("event", string(typ)),
+		zap.String("route", state.Route),
+		zap.Strings("features", state.Features),
+		zap.String("actor", actor),
+		zap.String("remote_addr", state.RemoteAddr),
+		zap.String("reason", state.Reason),
+		zap.Time("expires_at", state.ExpiresAt),
+		zap.String("cause", cause))
+
+	details := map[string]interface{}{
+		"features":   state.Features,
+		"expires_at": state.ExpiresAt.Format(time.RFC3339),
+	}
+	if cause != "" {
+		details["cause"] = cause
+	}
+
+	g.mu.RLock()
+	al := g.auditLoggers.Lookup(state.Route)
+	g.mu.RUnlock()
+	if al != nil {
+		al.Enqueue(&auditlog.AuditEntry{
+			Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+			RouteID:   state.Route,
+			ClientIP:  state.RemoteAddr,
+			Event:     string(typ),
+			Actor:     actor,
+			Reason:    state.Reason,
+			Details:   details,
+		})
+	}
+
+	if g.webhookDispatcher != nil {
+		data := map[string]interface{}{
+			"actor":  actor,
+			"reason": state.Reason,
+		}
+		for k, v := range details {
+			data[k] = v
+		}
+		g.webhookDispatcher.Emit(webhook.NewEvent(typ, state.Route, data))
+	}
+}
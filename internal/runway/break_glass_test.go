@@ -0,0 +1,172 @@
+package runway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func newBreakGlassServer(t *testing.T, webhookURL string) *Server {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(backend.Close)
+
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{
+			{ID: "locked", Path: "/locked", Backends: []config.BackendConfig{{URL: backend.URL}},
+				IPFilter:   config.IPFilterConfig{Enabled: true, Deny: []string{"0.0.0.0/0"}},
+				BreakGlass: config.BreakGlassConfig{Enabled: true, Bypass: []string{"ip_filter", "auth"}, MaxTTL: time.Minute}},
+			{ID: "plain", Path: "/plain", Backends: []config.BackendConfig{{URL: backend.URL}}},
+		},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+	if webhookURL != "" {
+		cfg.Webhooks = config.WebhooksConfig{
+			Enabled:   true,
+			Endpoints: []config.WebhookEndpoint{{ID: "ops", URL: webhookURL, Events: []string{"break_glass.*"}}},
+		}
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	t.Cleanup(func() { server.Runway().Close() })
+	return server
+}
+
+func TestBreakGlass(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev struct {
+			Type string                 `json:"type"`
+			Data map[string]interface{} `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&ev)
+		mu.Lock()
+		events = append(events, ev.Type+":"+ev.Data["actor"].(string))
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	s := newBreakGlassServer(t, hook.URL)
+	h := s.Runway().Handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	if w := get("/locked"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the IP filter to reject the request, got %d", w.Code)
+	}
+
+	for body, want := range map[string]int{
+		`{"actor":"alice","ttl":"10m"}`:                 http.StatusBadRequest,
+		`{"actor":"alice","reason":"sev-1","ttl":"2h"}`: http.StatusBadRequest,
+		`{"actor":"alice","reason":"sev-1"}`:            http.StatusBadRequest,
+	} {
+		if w := postUpstreamAction(s, "/admin/routes/locked/break-glass", body); w.Code != want {
+			t.Errorf("%s: expected %d, got %d: %s", body, want, w.Code, w.Body)
+		}
+	}
+	if w := postUpstreamAction(s, "/admin/routes/plain/break-glass", `{"actor":"alice","reason":"sev-1","ttl":"1m"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a route without a break_glass profile, got %d", w.Code)
+	}
+
+	w := postUpstreamAction(s, "/admin/routes/locked/break-glass", `{"actor":"alice","reason":"sev-1 INC-42","ttl":"150ms"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected activation, got %d: %s", w.Code, w.Body)
+	}
+	var state BreakGlassState
+	json.NewDecoder(w.Body).Decode(&state)
+	if strings.Join(state.Features, ",") != "ip_filter" || strings.Join(state.Skipped, ",") != "auth" || state.Actor != "alice" {
+		t.Errorf("unexpected state %+v", state)
+	}
+
+	if w := get("/locked"); w.Code != http.StatusOK || w.Header().Get("X-Break-Glass") != "true" {
+		t.Errorf("expected the bypassed route to answer with X-Break-Glass, got %d %q", w.Code, w.Header().Get("X-Break-Glass"))
+	}
+	if w := get("/plain"); w.Header().Get("X-Break-Glass") != "" {
+		t.Error("expected other routes to be unaffected")
+	}
+	if w := postUpstreamAction(s, "/admin/routes/locked/break-glass", `{"actor":"bob","reason":"again","ttl":"1m"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 while active, got %d", w.Code)
+	}
+
+	ow := httptest.NewRecorder()
+	s.adminHandler().ServeHTTP(ow, httptest.NewRequest("GET", "/admin/overrides", nil))
+	var overrides struct {
+		BreakGlassActive bool                       `json:"break_glass_active"`
+		BreakGlass       []BreakGlassState          `json:"break_glass"`
+		FeatureOverrides map[string]map[string]bool `json:"feature_overrides"`
+	}
+	json.NewDecoder(ow.Body).Decode(&overrides)
+	if !overrides.BreakGlassActive || len(overrides.BreakGlass) != 1 || overrides.BreakGlass[0].Reason != "sev-1 INC-42" {
+		t.Errorf("expected the active bypass in /admin/overrides, got %+v", overrides)
+	}
+	if enabled, ok := overrides.FeatureOverrides["locked"]["ip_filter"]; !ok || enabled {
+		t.Errorf("expected an ip_filter override, got %v", overrides.FeatureOverrides)
+	}
+
+	// The bypass reverts itself at TTL expiry.
+	deadline := time.Now().Add(2 * time.Second)
+	for get("/locked").Code != http.StatusForbidden {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the bypass to expire")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if w := get("/locked"); w.Header().Get("X-Break-Glass") != "" {
+		t.Error("expected no X-Break-Glass header after expiry")
+	}
+	if len(s.Runway().GetFeatureOverrides().Snapshot()["locked"]) != 0 {
+		t.Error("expected the feature override to be cleared")
+	}
+
+	// Manual revert.
+	if w := postUpstreamAction(s, "/admin/routes/locked/break-glass", `{"actor":"bob","reason":"sev-1","ttl":"1m"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected activation, got %d: %s", w.Code, w.Body)
+	}
+	if w := postUpstreamAction(s, "/admin/routes/locked/break-glass/revert", `{"actor":"carol"}`); w.Code != http.StatusOK {
+		t.Errorf("expected revert, got %d: %s", w.Code, w.Body)
+	}
+	if w := get("/locked"); w.Code != http.StatusForbidden {
+		t.Errorf("expected the IP filter back after revert, got %d", w.Code)
+	}
+	if w := postUpstreamAction(s, "/admin/routes/locked/break-glass/revert", ``); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 when nothing is active, got %d", w.Code)
+	}
+
+	// Webhook deliveries are concurrent, so compare without ordering.
+	want := "break_glass.activated:alice,break_glass.activated:bob,break_glass.reverted:alice,break_glass.reverted:carol"
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		got := slices.Clone(events)
+		mu.Unlock()
+		sort.Strings(got)
+		if strings.Join(got, ",") == want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected webhook events %s, got %v", want, got)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,19 @@
+package runway
+
+import (
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+// logCacheKeyWarnings logs one warning per high-cardinality cache key
+// header in cfg.
+func logCacheKeyWarnings(cfg *config.Config) {
+	for _, w := range cfg.CacheKeyWarnings {
+		logging.Warn("High-cardinality cache key header",
+			zap.String("route", w.Route),
+			zap.String("header", w.Header),
+			zap.String("detail", w.Message),
+		)
+	}
+}
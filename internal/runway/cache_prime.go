@@ -0,0 +1,182 @@
+package runway
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/cacheprime"
+	"github.com/wudi/runway/internal/router"
+	"github.com/wudi/runway/variables"
+)
+
+// errPrimeNoMatch is returned for manifest paths the primed route does not
+// serve.
+var errPrimeNoMatch = errors.New("path does not match the route")
+
+// cachePrimes holds the cache priming job of each route with cache.prime.
+type cachePrimes struct {
+	mu   sync.Mutex
+	jobs map[string]*cacheprime.Job
+}
+
+// initCachePriming starts priming the caches of routes with cache.prime.
+func (g *Runway) initCachePriming(cfg *config.Config) {
+	jobs := make(map[string]*cacheprime.Job)
+	for _, rc := range cfg.Routes {
+		if rc.Cache.Enabled && rc.Cache.Prime.Enabled {
+			jobs[rc.ID] = cacheprime.Start(rc.ID, rc.Cache.Prime, g.primeFetch(rc.ID))
+		}
+	}
+	g.cachePrimes.mu.Lock()
+	g.cachePrimes.jobs = jobs
+	g.cachePrimes.mu.Unlock()
+}
+
+// reloadCachePriming re-primes routes whose cache a reload left empty, and
+// routes whose priming had not finished. Finished jobs of routes with a
+// cache kept across the reload, such as a distributed one, are kept.
+func (g *Runway) reloadCachePriming(cfg *config.Config) {
+	g.cachePrimes.mu.Lock()
+	old := g.cachePrimes.jobs
+	g.cachePrimes.mu.Unlock()
+
+	caches := g.GetCaches()
+	jobs := make(map[string]*cacheprime.Job)
+	var restart []config.RouteConfig
+	for _, rc := range cfg.Routes {
+		if !rc.Cache.Enabled || !rc.Cache.Prime.Enabled {
+			continue
+		}
+		if j, ok := old[rc.ID]; ok && j.Finished() {
+			if h := caches.Lookup(rc.ID); h != nil && h.Stats().Size > 0 {
+				jobs[rc.ID] = j
+				delete(old, rc.ID)
+				continue
+			}
+		}
+		restart = append(restart, rc)
+	}
+	for _, j := range old {
+		j.Stop()
+	}
+	for _, rc := range restart {
+		jobs[rc.ID] = cacheprime.Start(rc.ID, rc.Cache.Prime, g.primeFetch(rc.ID))
+	}
+
+	g.cachePrimes.mu.Lock()
+	g.cachePrimes.jobs = jobs
+	g.cachePrimes.mu.Unlock()
+}
+
+// stopCachePriming stops the running priming jobs on shutdown.
+func (g *Runway) stopCachePriming() {
+	g.cachePrimes.mu.Lock()
+	jobs := g.cachePrimes.jobs
+	g.cachePrimes.jobs = nil
+	g.cachePrimes.mu.Unlock()
+	for _, j := range jobs {
+		j.Stop()
+	}
+}
+
+// primeFetch returns the function issuing routeID's priming requests. Each
+// request runs through the route's compiled handler, so the response is
+// cached as a client request's would be. Priming requests are marked
+// internal and skip rate limits and quotas.
+func (g *Runway) primeFetch(routeID string) cacheprime.FetchFunc {
+	return func(ctx context.Context, req cacheprime.Request) (int, error) {
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, req.Path, nil)
+		if err != nil {
+			return 0, err
+		}
+		for name, value := range req.Headers {
+			if http.CanonicalHeaderKey(name) == "Host" {
+				r.Host = value
+				continue
+			}
+			r.Header.Set(name, value)
+		}
+		r.RemoteAddr = "127.0.0.1:0"
+
+		varCtx := variables.NewContext(r)
+		defer variables.ReleaseContext(varCtx)
+		varCtx.Internal = true
+		varCtx.SkipFlags |= variables.SkipRateLimit | variables.SkipSpikeArrest | variables.SkipQuota
+
+		g.mu.RLock()
+		rt := g.router
+		g.mu.RUnlock()
+		match := rt.Match(r)
+		if match == nil {
+			return 0, errPrimeNoMatch
+		}
+		matched := match.Route.ID == routeID
+		varCtx.PathParams = maps.Clone(match.PathParams)
+		router.ReleaseMatch(match)
+		if !matched {
+			return 0, errPrimeNoMatch
+		}
+		h := g.routeHandler(routeID)
+		if h == nil {
+			return 0, ErrRouteNotFound
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx))
+		varCtx.Request = r
+		w := &primeWriter{header: make(http.Header), status: http.StatusOK}
+		h.ServeHTTP(w, r)
+		return w.status, nil
+	}
+}
+
+// CachePriming returns the progress of each route's cache priming.
+func (g *Runway) CachePriming() map[string]cacheprime.Snapshot {
+	g.cachePrimes.mu.Lock()
+	defer g.cachePrimes.mu.Unlock()
+	out := make(map[string]cacheprime.Snapshot, len(g.cachePrimes.jobs))
+	for id, j := range g.cachePrimes.jobs {
+		out[id] = j.Snapshot()
+	}
+	return out
+}
+
+// cachePrimingReadiness returns the reasons readiness waits for cache
+// priming, ordered by route.
+func (g *Runway) cachePrimingReadiness() []string {
+	g.cachePrimes.mu.Lock()
+	var reasons []string
+	for _, j := range g.cachePrimes.jobs {
+		if reason := j.ReadinessReason(); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	g.cachePrimes.mu.Unlock()
+	sort.Strings(reasons)
+	return reasons
+}
+
+// primeWriter discards a priming response, keeping its status.
+type primeWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+}
+
+func (w *primeWriter) Header() http.Header { return w.header }
+
+func (w *primeWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code >= 200 {
+		w.status = code
+		w.wroteHeader = true
+	}
+}
+
+func (w *primeWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return len(b), nil
+}
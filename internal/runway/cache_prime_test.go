@@ -0,0 +1,144 @@
+package runway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/cacheprime"
+)
+
+func TestCachePriming(t *testing.T) {
+	var hits, slowHits atomic.Int64
+	release := make(chan struct{})
+	var releaseOnce sync.Once
+	defer releaseOnce.Do(func() { close(release) })
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/items/slow":
+			slowHits.Add(1)
+			<-release
+		case "/items/1":
+			hits.Add(1)
+		}
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.URL.Path + " in " + r.Header.Get("Accept-Language")))
+	}))
+	defer backend.Close()
+
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+	os.WriteFile(manifest, []byte(`{"entries": [
+		{"path": "/items/1", "variants": [{"Accept-Language": "de"}, {"Accept-Language": "fr"}]},
+		{"path": "/elsewhere"},
+		{"path": "/items/slow"}
+	]}`), 0o644)
+
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{{
+			ID: "items", Path: "/items", PathPrefix: true,
+			Backends: []config.BackendConfig{{URL: backend.URL}},
+			Cache: config.CacheConfig{
+				Enabled: true,
+				TTL:     time.Minute,
+				Prime:   config.CachePrimeConfig{Enabled: true, Manifest: manifest, ReadyPercent: 100},
+			},
+		}},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	priming := func() cacheprime.Snapshot {
+		w := httptest.NewRecorder()
+		server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/cache/priming", nil))
+		var result map[string]cacheprime.Snapshot
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("decoding priming status: %v: %s", err, w.Body.String())
+		}
+		return result["items"]
+	}
+	ready := func() (int, string) {
+		w := httptest.NewRecorder()
+		server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+		return w.Code, w.Body.String()
+	}
+	waitFor := func(what string, cond func(cacheprime.Snapshot) bool) cacheprime.Snapshot {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			s := priming()
+			if cond(s) {
+				return s
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s: %+v", what, s)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Everything but /items/slow is done; readiness waits for 100%.
+	s := waitFor("3 of 4 requests", func(s cacheprime.Snapshot) bool { return s.Primed+s.Failed == 3 })
+	if s.State != cacheprime.StateRunning || s.Total != 4 || s.Primed != 2 || s.Failed != 1 {
+		t.Errorf("unexpected progress %+v", s)
+	}
+	if !strings.Contains(s.LastError, "/elsewhere") {
+		t.Errorf("expected the unmatched path as last error, got %q", s.LastError)
+	}
+	if code, body := ready(); code != http.StatusServiceUnavailable || !strings.Contains(body, "cache priming for route items at 75%") {
+		t.Errorf("expected readiness to wait for priming, got %d %s", code, body)
+	}
+
+	releaseOnce.Do(func() { close(release) })
+	s = waitFor("priming to finish", func(s cacheprime.Snapshot) bool { return s.State != cacheprime.StateRunning })
+	if s.State != cacheprime.StateDone || s.Primed != 3 || s.Failed != 1 || s.Percent != 100 {
+		t.Errorf("unexpected final progress %+v", s)
+	}
+	if code, body := ready(); code != http.StatusOK {
+		t.Errorf("expected ready after priming, got %d %s", code, body)
+	}
+
+	// Both variants were primed; clients are served from the cache.
+	for _, lang := range []string{"de", "fr"} {
+		req := httptest.NewRequest("GET", "/items/1", nil)
+		req.Header.Set("Accept-Language", lang)
+		w := httptest.NewRecorder()
+		server.Runway().Handler().ServeHTTP(w, req)
+		if w.Header().Get("X-Cache") != "HIT" || w.Body.String() != "/items/1 in "+lang {
+			t.Errorf("expected a primed %s hit, got %s %q", lang, w.Header().Get("X-Cache"), w.Body.String())
+		}
+	}
+	if hits.Load() != 2 {
+		t.Errorf("expected 2 backend requests for /items/1, got %d", hits.Load())
+	}
+
+	// Priming requests stay out of client metrics.
+	if n := server.Runway().GetMetricsCollector().Snapshot().RequestsTotal["items|GET|200"]; n != 2 {
+		t.Errorf("expected only the 2 client requests in metrics, got %d", n)
+	}
+
+	// A reload rebuilds the local cache empty, so the route is primed again.
+	if result := server.Runway().Reload(cfg); !result.Success {
+		t.Fatalf("Reload failed: %s", result.Error)
+	}
+	waitFor("priming after reload", func(s cacheprime.Snapshot) bool { return s.State == cacheprime.StateDone })
+	if hits.Load() != 4 || slowHits.Load() != 2 {
+		t.Errorf("expected the manifest primed again, got %d and %d backend requests", hits.Load(), slowHits.Load())
+	}
+}
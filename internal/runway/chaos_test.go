@@ -0,0 +1,109 @@
+package runway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/trafficshape"
+)
+
+const chaosConfig = `
+listeners:
+  - id: http
+    address: ":0"
+    protocol: http
+registry:
+  type: memory
+admin:
+  enabled: true
+  port: 8082
+tenants:
+  enabled: true
+  key: "header:X-Tenant"
+  tenants:
+    synthetic: {}
+    acme: {}
+routes:
+  - id: orders
+    path: /orders
+    backends:
+      - url: BACKEND
+    traffic_shaping:
+      fault_injection:
+        enabled: true
+        abort:
+          percentage: 100
+          status_code: 503
+        target:
+          tenants: [synthetic]
+`
+
+func TestChaosTargeting(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	yaml := []byte(strings.ReplaceAll(chaosConfig, "BACKEND", backend.URL))
+	cfg, err := config.NewLoader().Parse(yaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	do := func(tenant string) int {
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req.Header.Set("X-Tenant", tenant)
+		w := httptest.NewRecorder()
+		server.Runway().Handler().ServeHTTP(w, req)
+		return w.Code
+	}
+	admin := func(method, path string, out any) {
+		w := httptest.NewRecorder()
+		server.adminHandler().ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: expected 200, got %d %s", method, path, w.Code, w.Body.String())
+		}
+		json.Unmarshal(w.Body.Bytes(), out)
+	}
+
+	// Production tenants never see the faults of the synthetic tenant.
+	for i := 0; i < 200; i++ {
+		if code := do("acme"); code != http.StatusOK {
+			t.Fatalf("acme: expected 200, got %d", code)
+		}
+		if code := do("synthetic"); code != http.StatusServiceUnavailable {
+			t.Fatalf("synthetic: expected 503, got %d", code)
+		}
+	}
+
+	var status map[string]trafficshape.FaultInjectionSnapshot
+	admin("GET", "/admin/chaos", &status)
+	if st := status["orders"]; st.AbortPercentage != 100 || st.TotalAborted != 200 || st.TotalUntargeted != 200 {
+		t.Errorf("unexpected chaos status %+v", st)
+	}
+
+	var stop struct{ Stopped []string }
+	admin("POST", "/admin/chaos/stop-all", &stop)
+	if len(stop.Stopped) != 1 || stop.Stopped[0] != "orders" {
+		t.Errorf("expected orders stopped, got %v", stop.Stopped)
+	}
+	if code := do("synthetic"); code != http.StatusOK {
+		t.Errorf("expected no faults after stop-all, got %d", code)
+	}
+
+	// Reloading an unchanged config keeps the faults stopped.
+	cfg, _ = config.NewLoader().Parse(yaml)
+	if res := server.Runway().Reload(cfg); !res.Success {
+		t.Fatalf("reload failed: %s", res.Error)
+	}
+	if code := do("synthetic"); code != http.StatusOK {
+		t.Errorf("expected the faults to stay stopped across the reload, got %d", code)
+	}
+}
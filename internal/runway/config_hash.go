@@ -0,0 +1,120 @@
+package runway
+
+import (
+	"os"
+	"sync"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/configdrift"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/webhook"
+	"go.uber.org/zap"
+)
+
+// configHashCache holds the hash of the running config. It is keyed by the
+// config pointer, so reloads and upstream swaps, which replace the config,
+// are picked up on the next read.
+type configHashCache struct {
+	mu   sync.Mutex
+	cfg  *config.Config
+	hash string
+}
+
+// ConfigHash returns the hash of the running configuration (see
+// config.Hash). It reflects ingress-derived routes and admin API upstream
+// swaps, which are part of the running config.
+func (g *Runway) ConfigHash() string {
+	g.mu.RLock()
+	cfg := g.config
+	g.mu.RUnlock()
+	return g.hashConfig(cfg)
+}
+
+// hashConfig returns cfg's hash, updating the cache and the
+// runway_config_info and runway_route_info gauges when cfg is not the last
+// hashed config.
+func (g *Runway) hashConfig(cfg *config.Config) string {
+	c := &g.configHash
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cfg == cfg {
+		return c.hash
+	}
+	h, err := config.Hash(cfg)
+	if err != nil {
+		logging.Error("Failed to hash config", zap.Error(err))
+		return c.hash
+	}
+	c.cfg, c.hash = cfg, h
+	if g.metricsCollector != nil {
+		g.metricsCollector.SetConfigHash(h)
+		publishRouteInfo(g.metricsCollector, cfg)
+	}
+	return h
+}
+
+// initConfigDrift starts publishing the config hash to Redis when enabled.
+func (g *Runway) initConfigDrift(cfg *config.Config) {
+	cd := cfg.Admin.ConfigDrift
+	g.configDriftConfig = cd
+	if !cd.Enabled {
+		return
+	}
+	if g.redisClient == nil {
+		logging.Warn("Config drift detection needs the Redis client created at startup; disabled until restart")
+		return
+	}
+	instance := cd.InstanceID
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	d := configdrift.New(configdrift.Config{
+		Instance:    instance,
+		Interval:    cd.Interval,
+		GracePeriod: cd.GracePeriod,
+		Store:       configdrift.NewRedisStore(g.redisClient, cd.Key),
+		Hash:        g.ConfigHash,
+		OnDrift:     g.onConfigDrift,
+	})
+	g.configDrift.Store(d)
+	d.Start()
+}
+
+// reloadConfigDrift applies drift settings from a reloaded config. The
+// detector keeps running when its settings are unchanged; it publishes the
+// new hash on its next check.
+func (g *Runway) reloadConfigDrift(cfg *config.Config) {
+	d := g.configDrift.Load()
+	if cfg.Admin.ConfigDrift == g.configDriftConfig {
+		return
+	}
+	if d != nil {
+		g.configDrift.Store(nil)
+		d.Stop()
+	}
+	g.initConfigDrift(cfg)
+}
+
+// onConfigDrift logs peers running a different config past the grace
+// period and emits a webhook event.
+func (g *Runway) onConfigDrift(d configdrift.Drift) {
+	logging.Warn("Config drift detected between replicas",
+		zap.String("instance", d.Instance),
+		zap.String("config_hash", d.Hash),
+		zap.Any("peers", d.Peers),
+		zap.Time("since", d.Since),
+	)
+	if g.webhookDispatcher != nil {
+		g.webhookDispatcher.Emit(webhook.NewEvent(webhook.ConfigDriftDetected, "", map[string]interface{}{
+			"instance":    d.Instance,
+			"config_hash": d.Hash,
+			"peers":       d.Peers,
+			"since":       d.Since,
+		}))
+	}
+}
+
+// GetConfigDrift returns the drift detector, or nil when disabled.
+func (g *Runway) GetConfigDrift() *configdrift.Detector {
+	return g.configDrift.Load()
+}
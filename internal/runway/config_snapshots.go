@@ -0,0 +1,163 @@
+package runway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/configsnapshot"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrConfigSnapshotsDisabled is returned when admin.config_snapshots is off.
+	ErrConfigSnapshotsDisabled = errors.New("config snapshots are not enabled")
+
+	// ErrSnapshotSecrets is returned when masked secrets of a snapshot
+	// cannot be re-resolved.
+	ErrSnapshotSecrets = errors.New("snapshot secrets cannot be restored")
+
+	// ErrInvalidSnapshot is returned when a snapshot no longer loads or
+	// validates.
+	ErrInvalidSnapshot = errors.New("snapshot config is invalid")
+)
+
+// initConfigSnapshots creates the snapshot manager when enabled.
+func (g *Runway) initConfigSnapshots(cfg *config.Config) {
+	cs := cfg.Admin.ConfigSnapshots
+	g.configSnapshotsConfig = cs
+	if !cs.Enabled {
+		return
+	}
+	var store configsnapshot.Store
+	if cs.Store == "redis" {
+		if g.redisClient == nil {
+			logging.Warn("Config snapshots in Redis need the Redis client created at startup; disabled until restart")
+			return
+		}
+		store = configsnapshot.NewRedisStore(g.redisClient, cs.RedisKey)
+	} else {
+		fs, err := configsnapshot.NewFileStore(cs.Dir)
+		if err != nil {
+			logging.Error("Config snapshots disabled", zap.Error(err))
+			return
+		}
+		store = fs
+	}
+	mode := cs.Secrets
+	m := configsnapshot.New(configsnapshot.Config{
+		Store:     store,
+		Retention: cs.Retention,
+		Interval:  cs.Interval,
+		Build: func(c *config.Config) (*configsnapshot.Snapshot, error) {
+			return buildConfigSnapshot(c, mode)
+		},
+		Current: g.currentConfig,
+		OnError: func(err error) {
+			logging.Warn("Scheduled config snapshot failed", zap.Error(err))
+		},
+	})
+	g.configSnapshots.Store(m)
+	m.Start()
+}
+
+// reloadConfigSnapshots applies snapshot settings from a reloaded config.
+// The store is shared, so a new manager sees the existing snapshots.
+func (g *Runway) reloadConfigSnapshots(cfg *config.Config) {
+	if cfg.Admin.ConfigSnapshots == g.configSnapshotsConfig {
+		return
+	}
+	if m := g.configSnapshots.Load(); m != nil {
+		g.configSnapshots.Store(nil)
+		m.Stop()
+	}
+	g.initConfigSnapshots(cfg)
+}
+
+// GetConfigSnapshots returns the snapshot manager, or nil when disabled.
+func (g *Runway) GetConfigSnapshots() *configsnapshot.Manager {
+	return g.configSnapshots.Load()
+}
+
+func (g *Runway) currentConfig() *config.Config {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.config
+}
+
+// buildConfigSnapshot renders cfg and removes its secrets as mode says.
+func buildConfigSnapshot(cfg *config.Config, mode string) (*configsnapshot.Snapshot, error) {
+	if mode == "" {
+		mode = config.SecretsReference
+	}
+	hash, err := config.Hash(cfg)
+	if err != nil {
+		return nil, err
+	}
+	out, err := renderUnredacted(cfg)
+	if err != nil {
+		return nil, err
+	}
+	masked := config.ProtectSecrets(out, mode)
+	data, err := yaml.Marshal(out)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: marshal failed: %w", err)
+	}
+	return &configsnapshot.Snapshot{
+		Timestamp:    time.Now(),
+		Hash:         hash,
+		Secrets:      mode,
+		MaskedFields: masked,
+		Config:       string(data),
+	}, nil
+}
+
+// SaveConfigSnapshot persists cfg, recording source as what replaced or
+// captured it.
+func (g *Runway) SaveConfigSnapshot(ctx context.Context, cfg *config.Config, source string) (*configsnapshot.Snapshot, error) {
+	m := g.configSnapshots.Load()
+	if m == nil {
+		return nil, ErrConfigSnapshotsDisabled
+	}
+	return m.Capture(ctx, cfg, source)
+}
+
+// RestoreConfigSnapshot returns snapshot id as a config ready to reload.
+// Masked secrets are filled from their reference, or from the running
+// config when they have none, and the result goes through the loader like
+// a config file, so references are resolved again and the config is
+// validated.
+func (g *Runway) RestoreConfigSnapshot(ctx context.Context, id string) (*config.Config, error) {
+	m := g.configSnapshots.Load()
+	if m == nil {
+		return nil, ErrConfigSnapshotsDisabled
+	}
+	snap, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.DefaultConfig()
+	if err := yaml.Unmarshal([]byte(snap.Config), cfg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	current, err := renderUnredacted(g.currentConfig())
+	if err != nil {
+		return nil, err
+	}
+	if err := config.RestoreSecrets(cfg, current, snap.MaskedFields); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotSecrets, err)
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	restored, err := config.NewLoader().Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	return restored, nil
+}
@@ -0,0 +1,126 @@
+package runway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/configsnapshot"
+)
+
+const snapshotBaseConfig = `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+admin:
+  config_snapshots:
+    enabled: true
+    dir: SNAPSHOT_DIR
+routes:
+  - id: keep
+    path: /keep
+    backends:
+      - url: BACKEND
+  - id: remove
+    path: /remove
+    backends:
+      - url: BACKEND
+    backend_auth:
+      token_url: BACKEND/token
+      client_id: gateway
+      client_secret: ${env:SNAPSHOT_TEST_SECRET}
+`
+
+func TestConfigRollbackAfterRouteDeletion(t *testing.T) {
+	t.Setenv("SNAPSHOT_TEST_SECRET", "s3cret")
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	base := strings.ReplaceAll(snapshotBaseConfig, "SNAPSHOT_DIR", t.TempDir())
+	base = strings.ReplaceAll(base, "BACKEND", backend.URL)
+	cfg, err := config.NewLoader().Parse([]byte(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	// Delete the "remove" route.
+	cut := base[:strings.Index(base, "  - id: remove")]
+	newCfg, err := config.NewLoader().Parse([]byte(cut))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := server.ReloadWithConfig(newCfg); !result.Success {
+		t.Fatalf("reload failed: %s", result.Error)
+	}
+	if got := serveStatus(server, "/remove"); got != http.StatusNotFound {
+		t.Fatalf("expected 404 for deleted route, got %d", got)
+	}
+
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/config/snapshots", nil))
+	var list struct {
+		Snapshots []configsnapshot.Snapshot `json:"snapshots"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Snapshots) != 1 || list.Snapshots[0].Source != "api" || list.Snapshots[0].Config != "" {
+		t.Fatalf("expected one snapshot from the api reload without its config, got %+v", list.Snapshots)
+	}
+	id := list.Snapshots[0].ID
+
+	w = httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/config/snapshots/"+id, nil))
+	var snap configsnapshot.Snapshot
+	if err := json.NewDecoder(w.Body).Decode(&snap); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(snap.Config, "s3cret") || !strings.Contains(snap.Config, "${env:SNAPSHOT_TEST_SECRET}") {
+		t.Fatalf("expected the secret stored as its reference, got:\n%s", snap.Config)
+	}
+
+	w = httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("POST", "/admin/config/rollback/"+id, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected rollback to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := serveStatus(server, "/remove"); got != http.StatusOK {
+		t.Errorf("expected restored route to serve 200, got %d", got)
+	}
+	restored := server.Runway().currentConfig()
+	if len(restored.Routes) != 2 || restored.Routes[1].BackendAuth.ClientSecret != "s3cret" {
+		t.Errorf("expected the secret re-resolved through its reference, got %+v", restored.Routes)
+	}
+
+	// The rollback snapshotted the config it replaced.
+	snaps, err := server.Runway().GetConfigSnapshots().List(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 2 || snaps[0].Source != "rollback" {
+		t.Errorf("expected a rollback snapshot first, got %+v", snaps)
+	}
+
+	w = httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("POST", "/admin/config/rollback/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown snapshot, got %d", w.Code)
+	}
+}
+
+func serveStatus(server *Server, path string) int {
+	rec := httptest.NewRecorder()
+	server.Runway().Handler().ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	return rec.Code
+}
@@ -0,0 +1,132 @@
+package runway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/debugtrace"
+	"github.com/wudi/runway/internal/logging"
+)
+
+func TestDebugTrace(t *testing.T) {
+	core, obs := observer.New(zapcore.InfoLevel)
+	original := logging.Global()
+	logging.SetGlobal(zap.New(core))
+	defer logging.SetGlobal(original)
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Authentication: config.AuthenticationConfig{
+			APIKey: config.APIKeyConfig{
+				Enabled: true,
+				Header:  "X-API-Key",
+				Keys: []config.APIKeyEntry{
+					{Key: "key-a", ClientID: "partner-a"},
+					{Key: "key-b", ClientID: "partner-b"},
+				},
+			},
+		},
+		Routes: []config.RouteConfig{{
+			ID:       "orders",
+			Path:     "/orders",
+			Backends: []config.BackendConfig{{URL: backend.URL}},
+			Auth:     config.RouteAuthConfig{Required: true, Methods: []string{"api_key"}},
+		}},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.adminHandler().ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	send := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		server.Runway().Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"match": {}}`,
+		`{"match": {"client_id": "partner-a"}, "ttl": "forever"}`,
+	} {
+		if w := admin("POST", "/admin/debug/trace", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d %s", body, w.Code, w.Body.String())
+		}
+	}
+
+	w := admin("POST", "/admin/debug/trace", `{"match": {"client_id": "partner-a"}, "ttl": "5m", "max_requests": 10, "response_header": true, "actor": "oncall"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", w.Code, w.Body.String())
+	}
+	var session debugtrace.Session
+	if err := json.Unmarshal(w.Body.Bytes(), &session); err != nil {
+		t.Fatal(err)
+	}
+	if w := admin("POST", "/admin/debug/trace", `{"match": {"client_id": "partner-a"}}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a duplicate match, got %d", w.Code)
+	}
+
+	if w := send("key-a"); w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get(debugtrace.ResponseHeader), session.ID+"-") {
+		t.Errorf("expected a traced response, got %d %q", w.Code, w.Header().Get(debugtrace.ResponseHeader))
+	}
+	if w := send("key-b"); w.Code != http.StatusOK || w.Header().Get(debugtrace.ResponseHeader) != "" {
+		t.Errorf("expected an untraced response, got %d %q", w.Code, w.Header().Get(debugtrace.ResponseHeader))
+	}
+
+	var traced []string
+	for _, e := range obs.All() {
+		if e.Level != zapcore.DebugLevel {
+			continue
+		}
+		if e.ContextMap()["trace_session"] != session.ID {
+			t.Errorf("debug entry outside the session: %s %v", e.Message, e.ContextMap())
+		}
+		traced = append(traced, e.Message)
+	}
+	if len(traced) == 0 || traced[len(traced)-1] != "Debug trace: response" {
+		t.Errorf("expected the traced entries to end with the response, got %q", traced)
+	}
+
+	w = admin("GET", "/admin/debug/trace", "")
+	var sessions []debugtrace.Session
+	if err := json.Unmarshal(w.Body.Bytes(), &sessions); err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Traced != 1 || sessions[0].Actor != "oncall" {
+		t.Errorf("unexpected sessions %+v", sessions)
+	}
+
+	if w := admin("DELETE", "/admin/debug/trace/"+session.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200 cancelling, got %d %s", w.Code, w.Body.String())
+	}
+	if w := admin("GET", "/admin/debug/trace/"+session.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 after cancel, got %d", w.Code)
+	}
+	if w := send("key-a"); w.Header().Get(debugtrace.ResponseHeader) != "" {
+		t.Error("traced after the session was cancelled")
+	}
+}
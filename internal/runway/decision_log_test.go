@@ -0,0 +1,143 @@
+package runway
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/decision"
+	"github.com/wudi/runway/internal/middleware/decisionlog"
+)
+
+func TestDecisionLog(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	file := filepath.Join(t.TempDir(), "decisions.log")
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Authentication: config.AuthenticationConfig{
+			APIKey: config.APIKeyConfig{
+				Enabled: true,
+				Header:  "X-API-Key",
+				Keys:    []config.APIKeyEntry{{Key: "secret-key", ClientID: "acme"}},
+			},
+		},
+		Routes: []config.RouteConfig{{
+			ID:        "payments",
+			Path:      "/payments",
+			Backends:  []config.BackendConfig{{URL: backend.URL}},
+			Auth:      config.RouteAuthConfig{Required: true, Methods: []string{"api_key"}},
+			RateLimit: config.RateLimitConfig{Enabled: true, Rate: 10, Period: 60e9, PerIP: true},
+			Rules: config.RulesConfig{Request: []config.RuleConfig{
+				{ID: "tag-eu", Expression: `http.request.headers["X-Region"] == "eu"`, Action: "set_headers",
+					Headers: config.HeaderTransform{Set: map[string]string{"X-Residency": "eu"}}},
+			}},
+			DecisionLog: config.DecisionLogConfig{Enabled: true, File: file, HashKey: "k"},
+		}},
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/payments", nil)
+	req.Header.Set("X-API-Key", "secret-key")
+	req.Header.Set("X-Region", "eu")
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	server.Runway().Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	// An unauthenticated request is denied by auth after the rate limit
+	// debit.
+	req = httptest.NewRequest("GET", "/payments", nil)
+	w = httptest.NewRecorder()
+	server.Runway().Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+
+	// Closing the gateway drains the record file.
+	server.Runway().Close()
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records []decisionlog.Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec decisionlog.Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	rec := records[0]
+	if rec.SchemaVersion != decision.SchemaVersion || rec.RequestID != "req-1" || rec.RouteID != "payments" || rec.StatusCode != http.StatusOK {
+		t.Errorf("unexpected record header %+v", rec)
+	}
+	want := []struct{ stage, outcome string }{
+		{"rate_limit", decision.Debit},
+		{"auth", decision.Allow},
+		{"rules", decision.Match},
+		{"backend", decision.Route},
+	}
+	if len(rec.Decisions) != len(want) {
+		t.Fatalf("expected %d decisions, got %+v", len(want), rec.Decisions)
+	}
+	for i, d := range rec.Decisions {
+		if d.Stage != want[i].stage || d.Outcome != want[i].outcome {
+			t.Errorf("decision %d = %s/%s, want %s/%s", i, d.Stage, d.Outcome, want[i].stage, want[i].outcome)
+		}
+	}
+	if got := rec.Decisions[0].Attrs["remaining"]; got != "9" {
+		t.Errorf("expected 9 tokens remaining after the debit, got %q", got)
+	}
+	authAttrs := rec.Decisions[1].Attrs
+	if authAttrs["method"] != "api_key" || !strings.HasPrefix(authAttrs["client"], "sha256:") {
+		t.Errorf("unexpected auth attributes %v", authAttrs)
+	}
+	if rec.Decisions[2].Attrs["rule"] != "tag-eu" {
+		t.Errorf("unexpected rules attributes %v", rec.Decisions[2].Attrs)
+	}
+	if rec.Decisions[3].Attrs["status"] != "200" {
+		t.Errorf("unexpected backend attributes %v", rec.Decisions[3].Attrs)
+	}
+	for _, line := range []string{"acme", "secret-key"} {
+		for _, d := range rec.Decisions {
+			for _, v := range d.Attrs {
+				if strings.Contains(v, line) {
+					t.Errorf("decision %s recorded %q verbatim", d.Stage, line)
+				}
+			}
+		}
+	}
+
+	denied := records[1]
+	if n := len(denied.Decisions); n != 2 || denied.Decisions[1].Stage != "auth" || denied.Decisions[1].Outcome != decision.Deny {
+		t.Errorf("expected rate_limit then auth deny, got %+v", denied.Decisions)
+	}
+	if denied.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status 401 in the record, got %d", denied.StatusCode)
+	}
+}
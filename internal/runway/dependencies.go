@@ -0,0 +1,203 @@
+package runway
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/health"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/registry"
+	"github.com/wudi/runway/internal/webhook"
+	"go.uber.org/zap"
+)
+
+// Dependency kinds reported by the dependency monitor.
+const (
+	depRedis    = "redis"
+	depRegistry = "registry"
+	depGeoDB    = "geo_db"
+	depOPA      = "opa"
+	depExtAuth  = "ext_auth"
+	depWebhook  = "webhook"
+)
+
+// dependencyProbeClient is used for HTTP dependency probes; timeouts come
+// from the probe context.
+var dependencyProbeClient = &http.Client{}
+
+// initDependencyHealth starts probing external dependencies when enabled.
+func (g *Runway) initDependencyHealth(cfg *config.Config) {
+	if !cfg.Admin.DependencyHealth.Enabled {
+		return
+	}
+	m := health.NewDependencyMonitor(health.DependencyMonitorConfig{
+		DefaultInterval: cfg.Admin.DependencyHealth.Interval,
+		DefaultTimeout:  cfg.Admin.DependencyHealth.Timeout,
+		OnChange:        g.onDependencyChange,
+	})
+	m.Update(g.buildDependencies(cfg))
+	g.depMonitor.Store(m)
+}
+
+// reloadDependencyHealth applies dependency health settings from a reloaded
+// config. Unchanged dependencies keep their cached state.
+func (g *Runway) reloadDependencyHealth(cfg *config.Config) {
+	m := g.depMonitor.Load()
+	if !cfg.Admin.DependencyHealth.Enabled {
+		if m != nil {
+			g.depMonitor.Store(nil)
+			m.Stop()
+		}
+		return
+	}
+	if m == nil {
+		g.initDependencyHealth(cfg)
+		return
+	}
+	m.Update(g.buildDependencies(cfg))
+}
+
+// onDependencyChange logs dependency state transitions and emits webhook events.
+func (g *Runway) onDependencyChange(r health.DependencyResult, previous health.Status) {
+	eventType := webhook.DependencyHealthy
+	if r.Status == health.StatusHealthy {
+		logging.Info("Dependency recovered",
+			zap.String("dependency", r.Name),
+			zap.String("kind", r.Kind),
+		)
+	} else {
+		eventType = webhook.DependencyUnhealthy
+		logging.Warn("Dependency unhealthy",
+			zap.String("dependency", r.Name),
+			zap.String("kind", r.Kind),
+			zap.Bool("critical", r.Critical),
+			zap.String("error", r.LastError),
+		)
+	}
+
+	if g.webhookDispatcher != nil {
+		data := map[string]interface{}{
+			"name":     r.Name,
+			"kind":     r.Kind,
+			"target":   r.Target,
+			"critical": r.Critical,
+			"from":     string(previous),
+			"to":       string(r.Status),
+		}
+		if r.LastError != "" {
+			data["error"] = r.LastError
+		}
+		g.webhookDispatcher.Emit(webhook.NewEvent(eventType, "", data))
+	}
+}
+
+// buildDependencies collects the external dependencies referenced by cfg.
+func (g *Runway) buildDependencies(cfg *config.Config) []health.Dependency {
+	var deps []health.Dependency
+	add := func(name, kind, target string, check func(ctx context.Context) error) {
+		d := health.Dependency{
+			Name:     name,
+			Kind:     kind,
+			Target:   target,
+			Check:    check,
+			Critical: kind != depWebhook,
+		}
+		cc, ok := cfg.Admin.DependencyHealth.Checks[name]
+		if !ok {
+			cc, ok = cfg.Admin.DependencyHealth.Checks[kind]
+		}
+		if ok {
+			if cc.Disabled {
+				return
+			}
+			d.Interval = cc.Interval
+			d.Timeout = cc.Timeout
+			if cc.Critical != nil {
+				d.Critical = *cc.Critical
+			}
+			d.AffectsReadiness = cc.AffectsReadiness
+		}
+		deps = append(deps, d)
+	}
+
+	if g.redisClient != nil {
+		add(depRedis, depRedis, cfg.Redis.Address, func(ctx context.Context) error {
+			return g.redisClient.Ping(ctx).Err()
+		})
+	}
+
+	if p, ok := g.registry.(registry.Pinger); ok {
+		add(depRegistry, depRegistry, cfg.Registry.Type, p.Ping)
+	}
+
+	if cfg.Geo.Enabled && cfg.Geo.Database != "" {
+		add(depGeoDB, depGeoDB, cfg.Geo.Database, health.FileCheck(cfg.Geo.Database))
+	}
+
+	seen := make(map[string]bool)
+	for _, rc := range cfg.Routes {
+		opaCfg, extAuthCfg := rc.OPA, rc.ExtAuth
+		if opaCfg.Ref != "" {
+			opaCfg = cfg.OPAPolicies[opaCfg.Ref]
+		}
+		if extAuthCfg.Ref != "" {
+			extAuthCfg = cfg.ExtAuthServices[extAuthCfg.Ref]
+		}
+		if rc.OPA.IsEnabled() && opaCfg.URL != "" {
+			if u, err := url.Parse(opaCfg.URL); err == nil && u.Host != "" {
+				name := depOPA + ":" + u.Host
+				if !seen[name] {
+					seen[name] = true
+					healthURL := strings.TrimRight(opaCfg.URL, "/") + "/health"
+					add(name, depOPA, healthURL, health.HTTPCheck(dependencyProbeClient, healthURL))
+				}
+			}
+		}
+		if rc.ExtAuth.IsEnabled() && extAuthCfg.URL != "" {
+			if addr := dependencyDialAddress(extAuthCfg.URL); addr != "" {
+				name := depExtAuth + ":" + addr
+				if !seen[name] {
+					seen[name] = true
+					add(name, depExtAuth, addr, health.DialCheck(addr))
+				}
+			}
+		}
+	}
+
+	if cfg.Webhooks.Enabled {
+		for _, ep := range cfg.Webhooks.Endpoints {
+			if addr := dependencyDialAddress(ep.URL); addr != "" {
+				add(depWebhook+":"+ep.ID, depWebhook, addr, health.DialCheck(addr))
+			}
+		}
+	}
+
+	return deps
+}
+
+// dependencyDialAddress returns the host:port to dial for rawURL, or "" if
+// the URL has no host.
+func dependencyDialAddress(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	if u.Scheme == "https" || u.Scheme == "grpcs" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// GetDependencyMonitor returns the dependency monitor, or nil when dependency
+// health checks are disabled.
+func (g *Runway) GetDependencyMonitor() *health.DependencyMonitor {
+	return g.depMonitor.Load()
+}
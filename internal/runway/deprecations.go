@@ -0,0 +1,32 @@
+package runway
+
+import (
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+// DeprecationWarnings returns the deprecated fields the loader translated
+// in the running configuration. Configs built programmatically (ingress,
+// data plane) carry none.
+func (g *Runway) DeprecationWarnings() []config.DeprecationWarning {
+	g.mu.RLock()
+	cfg := g.config
+	g.mu.RUnlock()
+	if cfg.DeprecationWarnings == nil {
+		return []config.DeprecationWarning{}
+	}
+	return cfg.DeprecationWarnings
+}
+
+// logDeprecationWarnings logs one warning per deprecated field in cfg.
+func logDeprecationWarnings(cfg *config.Config) {
+	for _, w := range cfg.DeprecationWarnings {
+		logging.Warn("Deprecated config field",
+			zap.String("field", w.Field),
+			zap.String("replacement", w.Replacement),
+			zap.String("detail", w.Message),
+			zap.String("doc", w.DocURL),
+		)
+	}
+}
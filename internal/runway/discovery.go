@@ -0,0 +1,79 @@
+package runway
+
+import (
+	"context"
+	"sort"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/registry"
+	"go.uber.org/zap"
+)
+
+// discoverService returns the instances of a route's service matching its
+// tags and metadata, letting the registry filter metadata when it can.
+func (g *Runway) discoverService(ctx context.Context, svc config.ServiceConfig) ([]*registry.Service, error) {
+	if md, ok := g.registry.(registry.MetadataDiscoverer); ok && len(svc.Meta) > 0 {
+		return md.DiscoverWithFilter(ctx, svc.Name, svc.Tags, svc.Meta)
+	}
+	return g.registry.DiscoverWithTags(ctx, svc.Name, svc.Tags)
+}
+
+// serviceBackends converts discovered service instances into a route's
+// backends. Instances missing a required tag or metadata value are dropped,
+// so watch updates apply the same filter as the initial discovery. With
+// locality_aware, only instances in localZone are used while any of them is
+// passing; otherwise every zone is used.
+func (g *Runway) serviceBackends(routeID string, svc config.ServiceConfig, localZone string, services []*registry.Service) []*loadbalancer.Backend {
+	var filtered []*registry.Service
+	for _, s := range services {
+		if hasAllTags(s.Tags, svc.Tags) && s.HasMetadata(svc.Meta) {
+			filtered = append(filtered, s)
+		}
+	}
+
+	if svc.LocalityAware && localZone != "" {
+		var local bool
+		filtered, local = preferZone(filtered, svc.ZoneKey(), localZone)
+		if !local && len(filtered) > 0 {
+			logging.Warn("No healthy local-zone instances, using all zones",
+				zap.String("route", routeID),
+				zap.String("service", svc.Name),
+				zap.String("zone", localZone),
+			)
+		}
+	}
+
+	var backends []*loadbalancer.Backend
+	for _, s := range filtered {
+		b := &loadbalancer.Backend{
+			URL:     s.URL(),
+			Weight:  1,
+			Healthy: s.Health == registry.HealthPassing,
+		}
+		b.InitParsedURL()
+		backends = append(backends, b)
+	}
+	return g.egressBackends(egressSourceRegistry, "route "+routeID, backends)
+}
+
+// preferZone returns only the passing instances whose zoneKey metadata is
+// zone, or all instances with those of zone first if none is passing. The
+// bool reports whether the local zone was used.
+func preferZone(services []*registry.Service, zoneKey, zone string) ([]*registry.Service, bool) {
+	var local []*registry.Service
+	for _, s := range services {
+		if s.Metadata[zoneKey] == zone && s.Health == registry.HealthPassing {
+			local = append(local, s)
+		}
+	}
+	if len(local) > 0 {
+		return local, true
+	}
+	out := append([]*registry.Service(nil), services...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Metadata[zoneKey] == zone && out[j].Metadata[zoneKey] != zone
+	})
+	return out, false
+}
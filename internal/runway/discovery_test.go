@@ -0,0 +1,86 @@
+package runway
+
+import (
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/registry"
+)
+
+func TestServiceBackends(t *testing.T) {
+	inst := func(port int, zone, version string, health registry.HealthStatus) *registry.Service {
+		return &registry.Service{
+			Address:  "10.0.0.1",
+			Port:     port,
+			Tags:     []string{"http"},
+			Metadata: map[string]string{"az": zone, "version": version},
+			Health:   health,
+		}
+	}
+	services := []*registry.Service{
+		inst(8001, "b", "v2", registry.HealthPassing),
+		inst(8002, "a", "v2", registry.HealthPassing),
+		inst(8003, "a", "v1", registry.HealthPassing),
+		inst(8004, "a", "v2", registry.HealthCritical),
+	}
+	urls := func(bs []*loadbalancer.Backend) []string {
+		var out []string
+		for _, b := range bs {
+			out = append(out, b.URL)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		svc      config.ServiceConfig
+		services []*registry.Service
+		want     []string
+	}{
+		{
+			name:     "meta filter",
+			svc:      config.ServiceConfig{Name: "users", Tags: []string{"http"}, Meta: map[string]string{"version": "v2"}},
+			services: services,
+			want:     []string{"http://10.0.0.1:8001", "http://10.0.0.1:8002", "http://10.0.0.1:8004"},
+		},
+		{
+			name:     "tag filter",
+			svc:      config.ServiceConfig{Name: "users", Tags: []string{"grpc"}},
+			services: services,
+		},
+		{
+			name:     "locality prefers passing local instances",
+			svc:      config.ServiceConfig{Name: "users", Meta: map[string]string{"version": "v2"}, LocalityAware: true, ZoneMetaKey: "az"},
+			services: services,
+			want:     []string{"http://10.0.0.1:8002"},
+		},
+		{
+			name:     "locality falls back when no local instance is passing",
+			svc:      config.ServiceConfig{Name: "users", LocalityAware: true, ZoneMetaKey: "az"},
+			services: []*registry.Service{services[0], services[3]},
+			want:     []string{"http://10.0.0.1:8004", "http://10.0.0.1:8001"},
+		},
+		{
+			name:     "default zone key",
+			svc:      config.ServiceConfig{Name: "users", LocalityAware: true},
+			services: services,
+			want:     []string{"http://10.0.0.1:8001", "http://10.0.0.1:8002", "http://10.0.0.1:8003", "http://10.0.0.1:8004"},
+		},
+	}
+
+	g := &Runway{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := urls(g.serviceBackends("r1", tt.svc, "a", tt.services))
+			if len(got) != len(tt.want) {
+				t.Fatalf("backends = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("backends = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package runway
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/egress"
+	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/proxy"
+	"go.uber.org/zap"
+)
+
+// Egress policy sources of discovered backends and of the gateway's own
+// clients.
+const (
+	egressSourceRegistry = "registry"
+	egressSourceDNS      = "dns_discovery"
+	egressSourceWebhook  = "webhook"
+	egressSourceExtAuth  = "ext_auth"
+	egressSourceOIDC     = "oidc"
+)
+
+// loadEgressPolicy makes the egress policy of cfg current and returns it, or
+// nil when disabled. An unchanged policy is kept so its counters survive
+// reloads.
+func (g *Runway) loadEgressPolicy(cfg config.EgressPolicyConfig) *egress.Policy {
+	if !cfg.Enabled {
+		g.egressPolicy.Store(nil)
+		return nil
+	}
+	if cur := g.egressPolicy.Load(); cur != nil && reflect.DeepEqual(cur.Config(), cfg) {
+		return cur
+	}
+	p, err := egress.New(cfg)
+	if err != nil {
+		logging.Error("Failed to build egress policy", zap.Error(err))
+		g.egressPolicy.Store(nil)
+		return nil
+	}
+	g.egressPolicy.Store(p)
+	return p
+}
+
+// egressDialer returns a dialer that checks every connection against the
+// current egress policy, counting violations for source. It is the proxy
+// transport's dialer for clients that do not use the transport pool.
+func (g *Runway) egressDialer(source string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	fd := proxy.NewFamilyDialer(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}, proxy.IPFamilyAuto, nil)
+	fd.SetEgressPolicy(g.egressPolicy.Load)
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return fd.DialContext(egress.WithSource(ctx, source), network, addr)
+	}
+}
+
+// wireEgressDialers makes the ext_auth and OIDC clients rm creates connect
+// through egress-checked dialers.
+func (g *Runway) wireEgressDialers(rm *routeManagers) {
+	rm.extAuths.SetDialer(g.egressDialer(egressSourceExtAuth))
+	rm.oidcAuths.SetDialer(g.egressDialer(egressSourceOIDC))
+}
+
+// logEgressWarnings logs one warning per static destination outside the
+// egress policy's allowlist in warn mode.
+func logEgressWarnings(cfg *config.Config) {
+	for _, w := range cfg.EgressWarnings {
+		logging.Warn("egress destination not in allowlist",
+			zap.String("field", w.Field),
+			zap.String("host", w.Host),
+			zap.String("detail", w.Message),
+		)
+	}
+}
+
+// egressBackends drops the discovered backends of owner that the egress
+// policy refuses.
+func (g *Runway) egressBackends(source, owner string, backends []*loadbalancer.Backend) []*loadbalancer.Backend {
+	p := g.egressPolicy.Load()
+	if p == nil {
+		return backends
+	}
+	return p.FilterBackends(source, owner, backends)
+}
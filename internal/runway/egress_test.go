@@ -0,0 +1,29 @@
+package runway
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/egress"
+)
+
+func TestEgressDialerChecksCurrentPolicy(t *testing.T) {
+	g := &Runway{}
+	dial := g.egressDialer(egressSourceWebhook)
+
+	policy, err := egress.New(config.EgressPolicyConfig{Enabled: true, AllowedCIDRs: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.egressPolicy.Store(policy)
+
+	_, err = dial(context.Background(), "tcp", "127.0.0.1:1")
+	if err == nil || !strings.Contains(err.Error(), "egress policy") {
+		t.Fatalf("expected egress policy error, got %v", err)
+	}
+	if got := policy.Violations()[egressSourceWebhook]; got != 1 {
+		t.Errorf("violations for webhook = %d, want 1", got)
+	}
+}
@@ -32,10 +32,11 @@ type CustomSlot struct {
 
 // CustomGlobalSlot is the internal representation of a custom global middleware.
 type CustomGlobalSlot struct {
-	Name   string
-	After  string
-	Before string
-	Build  func(cfg *config.Config) func(http.Handler) http.Handler
+	Name      string
+	After     string
+	Before    string
+	Listeners []string // empty = all listeners
+	Build     func(cfg *config.Config) func(http.Handler) http.Handler
 }
 
 // ExternalFeature wraps a public Feature interface for use in the internal gateway.
@@ -23,12 +23,20 @@ type featureFunc struct {
 	adminPath string
 	setup     func(routeID string, cfg config.RouteConfig) error
 	routeIDs  func() []string
+	render    func(rc *config.RouteConfig) (renderSource, bool)
 }
 
 func (f *featureFunc) Name() string                                       { return f.name }
 func (f *featureFunc) Setup(routeID string, cfg config.RouteConfig) error { return f.setup(routeID, cfg) }
 func (f *featureFunc) RouteIDs() []string                                 { return f.routeIDs() }
 
+func (f *featureFunc) renderRoute(rc *config.RouteConfig) (renderSource, bool) {
+	if f.render == nil {
+		return renderSource{}, false
+	}
+	return f.render(rc)
+}
+
 // featureFuncStats extends featureFunc with AdminStatsProvider.
 type featureFuncStats struct {
 	featureFunc
@@ -107,25 +115,92 @@ func featureForWithStats[C any, M routeFeatureMgr[C]](name, adminPath string, mg
 	}, mgr.RouteIDs, stats)
 }
 
-// mergeFeature creates a Feature that merges per-route config with global defaults.
+// routeRenderer is implemented by features whose effective route config is
+// derived from a global default. renderRoute rewrites rc to the config the
+// feature sets up and reports where it was inherited from.
+type routeRenderer interface {
+	renderRoute(rc *config.RouteConfig) (renderSource, bool)
+}
+
+// renderSource records where a rendered route field was inherited from.
+type renderSource struct {
+	field  any  // pointer to the rewritten field of the route config
+	global any  // pointer to the global config it inherited from
+	merged bool // the route's own config was merged with the global config
+}
+
+// mergeSpec describes a per-route config merged with a global default. The
+// same spec drives feature setup and config rendering, so a rendered route
+// shows exactly the config the feature applies.
+type mergeSpec[C any] struct {
+	route  func(*config.RouteConfig) *C
+	global *C
+	active func(C) bool
+	merge  func(C, C) C
+	// always merges even when the route config is inactive; the merged
+	// result then decides whether the feature is active.
+	always bool
+}
+
+// resolve returns the effective config for rc and whether the feature is active.
 // When the route config is active, it is merged with the global config via merge.
 // When only the global config is active, the global config is used as-is.
-func mergeFeature[C any, M routeFeatureMgr[C]](name, adminPath string, mgr M,
-	getRoute func(config.RouteConfig) (C, bool),
-	getGlobal func() (C, bool),
-	merge func(C, C) C,
-) Feature {
-	return newFeature(name, adminPath, func(id string, rc config.RouteConfig) error {
-		routeCfg, routeActive := getRoute(rc)
-		globalCfg, globalActive := getGlobal()
-		if routeActive {
-			return mgr.AddRoute(id, merge(routeCfg, globalCfg))
-		}
-		if globalActive {
-			return mgr.AddRoute(id, globalCfg)
-		}
-		return nil
-	}, mgr.RouteIDs, statsFor(adminPath, mgr))
+func (s mergeSpec[C]) resolve(rc *config.RouteConfig) (C, bool) {
+	routeCfg, globalCfg := *s.route(rc), *s.global
+	if s.always {
+		merged := s.merge(routeCfg, globalCfg)
+		return merged, s.active(merged)
+	}
+	if s.active(routeCfg) {
+		return s.merge(routeCfg, globalCfg), true
+	}
+	if s.active(globalCfg) {
+		return globalCfg, true
+	}
+	var zero C
+	return zero, false
+}
+
+// render writes the effective config into rc. It reports a source only when
+// an active global config contributed to the result.
+func (s mergeSpec[C]) render(rc *config.RouteConfig) (renderSource, bool) {
+	field := s.route(rc)
+	merged := s.active(*field)
+	effective, active := s.resolve(rc)
+	if !active {
+		return renderSource{}, false
+	}
+	*field = effective
+	if !s.active(*s.global) {
+		return renderSource{}, false
+	}
+	return renderSource{field: field, global: s.global, merged: merged}, true
+}
+
+// enabledSpec creates a mergeSpec for Enableable config types.
+func enabledSpec[C Enableable](route func(*config.RouteConfig) *C, global *C, merge func(C, C) C) mergeSpec[C] {
+	return mergeSpec[C]{route: route, global: global, active: func(c C) bool { return c.IsEnabled() }, merge: merge}
+}
+
+// mergeFeature creates a Feature that sets up the effective config of spec
+// with add. The feature also renders the effective config for the route.
+func mergeFeature[C any](name, adminPath string, spec mergeSpec[C], add func(string, C) error, routeIDs func() []string, stats func() any) Feature {
+	ff := featureFunc{
+		name:      name,
+		adminPath: adminPath,
+		setup: func(id string, rc config.RouteConfig) error {
+			if c, active := spec.resolve(&rc); active {
+				return add(id, c)
+			}
+			return nil
+		},
+		routeIDs: routeIDs,
+		render:   spec.render,
+	}
+	if stats != nil {
+		return &featureFuncStats{featureFunc: ff, stats: stats}
+	}
+	return &ff
 }
 
 // Enableable is implemented by config types with an Enabled field.
@@ -146,15 +221,13 @@ func enabledFeature[C Enableable, M routeFeatureMgr[C]](name, adminPath string,
 	})
 }
 
-// enabledMerge creates a merge Feature for Enableable config types.
-// It reduces the verbose pair of (config, bool) getters to simple value getters.
+// enabledMerge creates a merge Feature for Enableable config types backed by
+// a per-route manager. route points at the route's config and global at the
+// global default it is merged with.
 func enabledMerge[C Enableable, M routeFeatureMgr[C]](name, adminPath string, mgr M,
-	getRoute func(config.RouteConfig) C,
-	getGlobal func() C,
+	route func(*config.RouteConfig) *C,
+	global *C,
 	merge func(C, C) C,
 ) Feature {
-	return mergeFeature(name, adminPath, mgr,
-		func(rc config.RouteConfig) (C, bool) { c := getRoute(rc); return c, c.IsEnabled() },
-		func() (C, bool) { c := getGlobal(); return c, c.IsEnabled() },
-		merge)
+	return mergeFeature(name, adminPath, enabledSpec(route, global, merge), mgr.AddRoute, mgr.RouteIDs, statsFor(adminPath, mgr))
 }
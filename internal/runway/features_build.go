@@ -27,6 +27,8 @@ import (
 	"github.com/wudi/runway/internal/middleware/securityheaders"
 	"github.com/wudi/runway/internal/middleware/signing"
 	"github.com/wudi/runway/internal/middleware/spikearrest"
+	"github.com/wudi/runway/internal/middleware/spillbuf"
+	"github.com/wudi/runway/internal/middleware/synthetic"
 	"github.com/wudi/runway/internal/trafficshape"
 )
 
@@ -46,12 +48,23 @@ func buildFeatures(rm *routeManagers, cfg *config.Config, redisClient *redis.Cli
 		enabledFeature("validation", "", rm.validators, func(rc config.RouteConfig) config.ValidationConfig { return rc.Validation }),
 		enabledFeature("waf", "/waf", rm.wafHandlers, func(rc config.RouteConfig) config.WAFConfig { return rc.WAF }),
 		enabledFeature("graphql", "/graphql", rm.graphqlParsers, func(rc config.RouteConfig) config.GraphQLConfig { return rc.GraphQL }),
-		enabledFeature("coalesce", "/coalesce", rm.coalescers, func(rc config.RouteConfig) config.CoalesceConfig { return rc.Coalesce }),
+		enabledFeature("coalesce", "/coalesce", rm.coalescers, func(rc config.RouteConfig) config.CoalesceConfig {
+			c := rc.Coalesce
+			if rc.Cache.Enabled {
+				c.KeyNormalization = rc.Cache.KeyNormalization
+			}
+			return c
+		}),
 		enabledFeature("versioning", "/versioning", rm.versioners, func(rc config.RouteConfig) config.VersioningConfig { return rc.Versioning }),
 		enabledFeature("proxy_rate_limit", "/proxy-rate-limits", rm.proxyRateLimiters, func(rc config.RouteConfig) config.ProxyRateLimitConfig { return rc.ProxyRateLimit }),
 		enabledFeature("claims_propagation", "/claims-propagation", rm.claimsPropagators, func(rc config.RouteConfig) config.ClaimsPropagationConfig { return rc.ClaimsPropagation }),
 		enabledFeature("token_exchange", "/token-exchange", rm.tokenExchangers, func(rc config.RouteConfig) config.TokenExchangeConfig { return rc.TokenExchange }),
+		featureForWithStats("oidc", "/oidc", rm.oidcAuths,
+			func(rc config.RouteConfig) (config.RouteConfig, bool) { return rc, rc.OIDC.Enabled },
+			func() any { return rm.oidcAuths.Stats() }),
+		enabledFeature("websocket", "/websocket", rm.wsProxies, func(rc config.RouteConfig) config.WebSocketConfig { return rc.WebSocket }),
 		enabledFeature("backend_auth", "/backend-auth", rm.backendAuths, func(rc config.RouteConfig) config.BackendAuthConfig { return rc.BackendAuth }),
+		enabledFeature("backend_headers_secret", "/backend-headers-secret", rm.secretHeaders, func(rc config.RouteConfig) config.BackendHeadersSecretConfig { return rc.BackendHeadersSecret }),
 		enabledFeature("fastcgi", "/fastcgi", rm.fastcgiHandlers, func(rc config.RouteConfig) config.FastCGIConfig { return rc.FastCGI }),
 		enabledFeature("ai", "/ai", rm.aiHandlers, func(rc config.RouteConfig) config.AIConfig { return rc.AI }),
 		enabledFeature("body_generator", "/body-generator", rm.bodyGenerators, func(rc config.RouteConfig) config.BodyGeneratorConfig { return rc.BodyGenerator }),
@@ -61,6 +74,7 @@ func buildFeatures(rm *routeManagers, cfg *config.Config, redisClient *redis.Cli
 		enabledFeature("field_encryption", "/field-encryption", rm.fieldEncryptors, func(rc config.RouteConfig) config.FieldEncryptionConfig { return rc.FieldEncryption }),
 		enabledFeature("jmespath", "/jmespath", rm.jmespathHandlers, func(rc config.RouteConfig) config.JMESPathConfig { return rc.JMESPath }),
 		enabledFeature("field_replacer", "/field-replacer", rm.fieldReplacers, func(rc config.RouteConfig) config.FieldReplacerConfig { return rc.FieldReplacer }),
+		enabledFeature("response_field_policy", "/response-field-policy", rm.fieldPolicies, func(rc config.RouteConfig) config.ResponseFieldPolicyConfig { return rc.ResponseFieldPolicy }),
 		enabledFeature("lua", "/lua", rm.luaScripters, func(rc config.RouteConfig) config.LuaConfig { return rc.Lua }),
 		enabledFeature("traffic_replay", "/traffic-replay", rm.trafficReplay, func(rc config.RouteConfig) config.TrafficReplayConfig { return rc.TrafficReplay }),
 		enabledFeature("opa", "/opa", rm.opaEnforcers, func(rc config.RouteConfig) config.OPAConfig { return rc.OPA }),
@@ -74,7 +88,10 @@ func buildFeatures(rm *routeManagers, cfg *config.Config, redisClient *redis.Cli
 		enabledFeature("ext_auth", "/ext-auth", rm.extAuths, func(rc config.RouteConfig) config.ExtAuthConfig { return rc.ExtAuth }),
 		enabledFeature("sse", "/sse", rm.sseHandlers, func(rc config.RouteConfig) config.SSEConfig { return rc.SSE }),
 		enabledFeature("request_dedup", "/request-dedup", rm.dedupHandlers, func(rc config.RouteConfig) config.RequestDedupConfig { return rc.RequestDedup }),
+		enabledFeature("content_dedup", "/content-dedup", rm.contentDedups, func(rc config.RouteConfig) config.ContentDedupConfig { return rc.ContentDedup }),
 		enabledFeature("quota", "/quotas", rm.quotaEnforcers, func(rc config.RouteConfig) config.QuotaConfig { return rc.Quota }),
+		enabledFeature("bandwidth_quota", "/bandwidth-quotas", rm.bandwidthQuotas, func(rc config.RouteConfig) config.BandwidthQuotaConfig { return rc.BandwidthQuota }),
+		enabledFeature("concurrency_limit", "/concurrency-limits", rm.concurrencyLimiters, func(rc config.RouteConfig) config.ConcurrencyLimitConfig { return rc.ConcurrencyLimit }),
 
 		// Simple features with non-standard enabled checks (keep featureFor)
 		featureFor("content_replacer", "/content-replacer", rm.contentReplacers, func(rc config.RouteConfig) (config.ContentReplacerConfig, bool) {
@@ -105,92 +122,117 @@ func buildFeatures(rm *routeManagers, cfg *config.Config, redisClient *redis.Cli
 		// ---- Merge features: per-route config merged with global defaults (enabledMerge) ----
 
 		enabledMerge("throttle", "", rm.throttlers,
-			func(rc config.RouteConfig) config.ThrottleConfig { return rc.TrafficShaping.Throttle },
-			func() config.ThrottleConfig { return cfg.TrafficShaping.Throttle },
+			func(rc *config.RouteConfig) *config.ThrottleConfig { return &rc.TrafficShaping.Throttle },
+			&cfg.TrafficShaping.Throttle,
 			trafficshape.MergeThrottleConfig),
 		enabledMerge("bandwidth", "", rm.bandwidthLimiters,
-			func(rc config.RouteConfig) config.BandwidthConfig { return rc.TrafficShaping.Bandwidth },
-			func() config.BandwidthConfig { return cfg.TrafficShaping.Bandwidth },
+			func(rc *config.RouteConfig) *config.BandwidthConfig { return &rc.TrafficShaping.Bandwidth },
+			&cfg.TrafficShaping.Bandwidth,
 			trafficshape.MergeBandwidthConfig),
 		enabledMerge("fault_injection", "", rm.faultInjectors,
-			func(rc config.RouteConfig) config.FaultInjectionConfig { return rc.TrafficShaping.FaultInjection },
-			func() config.FaultInjectionConfig { return cfg.TrafficShaping.FaultInjection },
+			func(rc *config.RouteConfig) *config.FaultInjectionConfig { return &rc.TrafficShaping.FaultInjection },
+			&cfg.TrafficShaping.FaultInjection,
 			trafficshape.MergeFaultInjectionConfig),
 		enabledMerge("request_queue", "/request-queues", rm.requestQueues,
-			func(rc config.RouteConfig) config.RequestQueueConfig { return rc.TrafficShaping.RequestQueue },
-			func() config.RequestQueueConfig { return cfg.TrafficShaping.RequestQueue },
+			func(rc *config.RouteConfig) *config.RequestQueueConfig { return &rc.TrafficShaping.RequestQueue },
+			&cfg.TrafficShaping.RequestQueue,
 			requestqueue.MergeRequestQueueConfig),
 		enabledMerge("request_decompression", "/decompression", rm.decompressors,
-			func(rc config.RouteConfig) config.RequestDecompressionConfig { return rc.RequestDecompression },
-			func() config.RequestDecompressionConfig { return cfg.RequestDecompression },
+			func(rc *config.RouteConfig) *config.RequestDecompressionConfig { return &rc.RequestDecompression },
+			&cfg.RequestDecompression,
 			decompress.MergeDecompressionConfig),
 		enabledMerge("response_limit", "/response-limits", rm.responseLimiters,
-			func(rc config.RouteConfig) config.ResponseLimitConfig { return rc.ResponseLimit },
-			func() config.ResponseLimitConfig { return cfg.ResponseLimit },
+			func(rc *config.RouteConfig) *config.ResponseLimitConfig { return &rc.ResponseLimit },
+			&cfg.ResponseLimit,
 			responselimit.MergeResponseLimitConfig),
+		enabledMerge("response_buffering", "/response-buffering", rm.responseBuffers,
+			func(rc *config.RouteConfig) *config.ResponseBufferingConfig { return &rc.ResponseBuffering },
+			&cfg.ResponseBuffering,
+			spillbuf.MergeResponseBufferingConfig),
 		enabledMerge("security_headers", "/security-headers", rm.securityHeaders,
-			func(rc config.RouteConfig) config.SecurityHeadersConfig { return rc.SecurityHeaders },
-			func() config.SecurityHeadersConfig { return cfg.SecurityHeaders },
+			func(rc *config.RouteConfig) *config.SecurityHeadersConfig { return &rc.SecurityHeaders },
+			&cfg.SecurityHeaders,
 			securityheaders.MergeSecurityHeadersConfig),
 		enabledMerge("maintenance", "/maintenance", rm.maintenanceHandlers,
-			func(rc config.RouteConfig) config.MaintenanceConfig { return rc.Maintenance },
-			func() config.MaintenanceConfig { return cfg.Maintenance },
+			func(rc *config.RouteConfig) *config.MaintenanceConfig { return &rc.Maintenance },
+			&cfg.Maintenance,
 			maintenance.MergeMaintenanceConfig),
+		enabledMerge("synthetic_monitoring", "/synthetic-monitoring", rm.syntheticMonitors,
+			func(rc *config.RouteConfig) *config.SyntheticMonitoringConfig { return &rc.SyntheticMonitoring },
+			&cfg.SyntheticMonitoring,
+			synthetic.MergeSyntheticMonitoringConfig),
 		enabledMerge("bot_detection", "/bot-detection", rm.botDetectors,
-			func(rc config.RouteConfig) config.BotDetectionConfig { return rc.BotDetection },
-			func() config.BotDetectionConfig { return cfg.BotDetection },
+			func(rc *config.RouteConfig) *config.BotDetectionConfig { return &rc.BotDetection },
+			&cfg.BotDetection,
 			botdetect.MergeBotDetectionConfig),
 		enabledMerge("ai_crawl_control", "/ai-crawl-control", rm.aiCrawlControllers,
-			func(rc config.RouteConfig) config.AICrawlConfig { return rc.AICrawlControl },
-			func() config.AICrawlConfig { return cfg.AICrawlControl },
+			func(rc *config.RouteConfig) *config.AICrawlConfig { return &rc.AICrawlControl },
+			&cfg.AICrawlControl,
 			aicrawl.MergeAICrawlConfig),
 		enabledMerge("spike_arrest", "/spike-arrest", rm.spikeArresters,
-			func(rc config.RouteConfig) config.SpikeArrestConfig { return rc.SpikeArrest },
-			func() config.SpikeArrestConfig { return cfg.SpikeArrest },
+			func(rc *config.RouteConfig) *config.SpikeArrestConfig { return &rc.SpikeArrest },
+			&cfg.SpikeArrest,
 			spikearrest.MergeSpikeArrestConfig),
 		enabledMerge("client_mtls", "/client-mtls", rm.clientMTLSVerifiers,
-			func(rc config.RouteConfig) config.ClientMTLSConfig { return rc.ClientMTLS },
-			func() config.ClientMTLSConfig { return cfg.ClientMTLS },
+			func(rc *config.RouteConfig) *config.ClientMTLSConfig { return &rc.ClientMTLS },
+			&cfg.ClientMTLS,
 			clientmtls.MergeClientMTLSConfig),
 		enabledMerge("backend_signing", "/signing", rm.backendSigners,
-			func(rc config.RouteConfig) config.BackendSigningConfig { return rc.BackendSigning },
-			func() config.BackendSigningConfig { return cfg.BackendSigning },
+			func(rc *config.RouteConfig) *config.BackendSigningConfig { return &rc.BackendSigning },
+			&cfg.BackendSigning,
 			signing.MergeSigningConfig),
 		enabledMerge("inbound_signing", "/inbound-signing", rm.inboundVerifiers,
-			func(rc config.RouteConfig) config.InboundSigningConfig { return rc.InboundSigning },
-			func() config.InboundSigningConfig { return cfg.InboundSigning },
+			func(rc *config.RouteConfig) *config.InboundSigningConfig { return &rc.InboundSigning },
+			&cfg.InboundSigning,
 			inboundsigning.MergeInboundSigningConfig),
 		enabledMerge("deprecation", "/deprecation", rm.deprecationHandlers,
-			func(rc config.RouteConfig) config.DeprecationConfig { return rc.Deprecation },
-			func() config.DeprecationConfig { return cfg.Deprecation },
+			func(rc *config.RouteConfig) *config.DeprecationConfig { return &rc.Deprecation },
+			&cfg.Deprecation,
 			deprecation.MergeDeprecationConfig),
 		enabledMerge("baggage", "/baggage", rm.baggagePropagators,
-			func(rc config.RouteConfig) config.BaggageConfig { return rc.Baggage },
-			func() config.BaggageConfig { return cfg.Baggage },
+			func(rc *config.RouteConfig) *config.BaggageConfig { return &rc.Baggage },
+			&cfg.Baggage,
 			baggage.MergeBaggageConfig),
 		enabledMerge("audit_log", "/audit-log", rm.auditLoggers,
-			func(rc config.RouteConfig) config.AuditLogConfig { return rc.AuditLog },
-			func() config.AuditLogConfig { return cfg.AuditLog },
+			func(rc *config.RouteConfig) *config.AuditLogConfig { return &rc.AuditLog },
+			&cfg.AuditLog,
 			auditlog.MergeAuditLogConfig),
 		enabledMerge("nonce", "/nonces", rm.nonceCheckers,
-			func(rc config.RouteConfig) config.NonceConfig { return rc.Nonce },
-			func() config.NonceConfig { return cfg.Nonce },
+			func(rc *config.RouteConfig) *config.NonceConfig { return &rc.Nonce },
+			&cfg.Nonce,
 			nonce.MergeNonceConfig),
 		enabledMerge("csrf", "/csrf", rm.csrfProtectors,
-			func(rc config.RouteConfig) config.CSRFConfig { return rc.CSRF },
-			func() config.CSRFConfig { return cfg.CSRF },
+			func(rc *config.RouteConfig) *config.CSRFConfig { return &rc.CSRF },
+			&cfg.CSRF,
 			csrf.MergeCSRFConfig),
 		enabledMerge("idempotency", "/idempotency", rm.idempotencyHandlers,
-			func(rc config.RouteConfig) config.IdempotencyConfig { return rc.Idempotency },
-			func() config.IdempotencyConfig { return cfg.Idempotency },
+			func(rc *config.RouteConfig) *config.IdempotencyConfig { return &rc.Idempotency },
+			&cfg.Idempotency,
 			idempotency.MergeIdempotencyConfig),
-		enabledMerge("ip_blocklist", "/ip-blocklist", rm.ipBlocklists,
-			func(rc config.RouteConfig) config.IPBlocklistConfig { return rc.IPBlocklist },
-			func() config.IPBlocklistConfig { return cfg.IPBlocklist },
-			ipblocklist.MergeIPBlocklistConfig),
+		mergeFeature("ip_blocklist", "/ip-blocklist",
+			enabledSpec(func(rc *config.RouteConfig) *config.IPBlocklistConfig { return &rc.IPBlocklist },
+				&cfg.IPBlocklist,
+				ipblocklist.MergeIPBlocklistConfig),
+			rm.ipBlocklists.AddRoute, rm.ipBlocklists.RouteIDs,
+			func() any {
+				// The global blocklist, which also carries reputation blocks, is listed as "_global".
+				stats := rm.ipBlocklists.Stats()
+				if rm.globalBlocklist != nil {
+					stats["_global"] = rm.globalBlocklist.Status()
+				}
+				return stats
+			}),
 
 		// ---- Custom features: unique logic that can't be generalized ----
 
+		newFeature("decision_log", "/decision-log", func(id string, rc config.RouteConfig) error {
+			if rc.DecisionLog.Enabled {
+				// The audit_webhook sink posts to the route's effective audit log webhook.
+				return rm.decisionLoggers.AddRoute(id, rc.DecisionLog, auditlog.MergeAuditLogConfig(rc.AuditLog, cfg.AuditLog))
+			}
+			return nil
+		}, rm.decisionLoggers.RouteIDs, func() any { return rm.decisionLoggers.Stats() }),
+
 		newFeature("circuit_breaker", "/circuit-breakers", func(id string, rc config.RouteConfig) error {
 			if rc.CircuitBreaker.Enabled {
 				if rc.CircuitBreaker.Mode == "distributed" && redisClient != nil {
@@ -202,6 +244,8 @@ func buildFeatures(rm *routeManagers, cfg *config.Config, redisClient *redis.Cli
 			return nil
 		}, rm.circuitBreakers.RouteIDs, func() any { return rm.circuitBreakers.Snapshots() }),
 
+		enabledFeature("degraded_mode", "/degraded-mode", rm.degradedModes, func(rc config.RouteConfig) config.DegradedModeConfig { return rc.DegradedMode }),
+
 		newFeature("cache", "/cache", func(id string, rc config.RouteConfig) error {
 			if rc.Cache.Enabled {
 				rm.caches.AddRoute(id, rc.Cache)
@@ -215,11 +259,12 @@ func buildFeatures(rm *routeManagers, cfg *config.Config, redisClient *redis.Cli
 
 		featureForWithStats("access_log", "/access-log", rm.accessLogConfigs, func(rc config.RouteConfig) (config.AccessLogConfig, bool) {
 			al := rc.AccessLog
-			return al, al.Enabled != nil || al.Format != "" ||
+			return al, al.Enabled != nil || al.Format != "" || len(al.Fields) > 0 ||
 				len(al.HeadersInclude) > 0 || len(al.HeadersExclude) > 0 ||
 				al.Body.Enabled ||
 				al.Conditions.SampleRate > 0 || len(al.Conditions.StatusCodes) > 0 ||
-				len(al.Conditions.Methods) > 0
+				len(al.Conditions.Methods) > 0 ||
+				al.Level != "" || al.Sampling.Enabled
 		}, func() any { return rm.accessLogConfigs.Stats() }),
 
 		featureForWithStats("openapi", "/openapi", rm.openapiValidators, func(rc config.RouteConfig) (config.OpenAPIRouteConfig, bool) {
@@ -264,23 +309,19 @@ func buildFeatures(rm *routeManagers, cfg *config.Config, redisClient *redis.Cli
 			return nil
 		}, rm.contentNegotiators.RouteIDs, func() any { return rm.contentNegotiators.Stats() }),
 
-		newFeature("adaptive_concurrency", "/adaptive-concurrency", func(id string, rc config.RouteConfig) error {
-			ac := rc.TrafficShaping.AdaptiveConcurrency
-			if ac.Enabled {
-				return rm.adaptiveLimiters.AddRoute(id, trafficshape.MergeAdaptiveConcurrencyConfig(ac, cfg.TrafficShaping.AdaptiveConcurrency))
-			} else if cfg.TrafficShaping.AdaptiveConcurrency.Enabled {
-				return rm.adaptiveLimiters.AddRoute(id, cfg.TrafficShaping.AdaptiveConcurrency)
-			}
-			return nil
-		}, rm.adaptiveLimiters.RouteIDs, func() any { return rm.adaptiveLimiters.Stats() }),
-
-		newFeature("priority", "", func(id string, rc config.RouteConfig) error {
-			pc := rc.TrafficShaping.Priority
-			if pc.Enabled {
-				rm.priorityConfigs.AddRoute(id, trafficshape.MergePriorityConfig(pc, cfg.TrafficShaping.Priority))
-			} else if cfg.TrafficShaping.Priority.Enabled {
-				rm.priorityConfigs.AddRoute(id, cfg.TrafficShaping.Priority)
-			}
+		enabledMerge("adaptive_concurrency", "/adaptive-concurrency", rm.adaptiveLimiters,
+			func(rc *config.RouteConfig) *config.AdaptiveConcurrencyConfig {
+				return &rc.TrafficShaping.AdaptiveConcurrency
+			},
+			&cfg.TrafficShaping.AdaptiveConcurrency,
+			trafficshape.MergeAdaptiveConcurrencyConfig),
+
+		mergeFeature("priority", "", enabledSpec(
+			func(rc *config.RouteConfig) *config.PriorityConfig { return &rc.TrafficShaping.Priority },
+			&cfg.TrafficShaping.Priority,
+			trafficshape.MergePriorityConfig,
+		), func(id string, pc config.PriorityConfig) error {
+			rm.priorityConfigs.AddRoute(id, pc)
 			return nil
 		}, rm.priorityConfigs.RouteIDs, nil),
 
@@ -291,35 +332,50 @@ func buildFeatures(rm *routeManagers, cfg *config.Config, redisClient *redis.Cli
 			return nil
 		}, rm.errorPages.RouteIDs, func() any { return rm.errorPages.Stats() }),
 
-		newFeature("geo", "/geo", func(id string, rc config.RouteConfig) error {
-			if rm.geoProvider == nil {
-				return nil
+		// Runs after error_pages, whose compiled pages it renders for browsers.
+		// Routes opting out of a global problem_json still get a renderer to
+		// record their format.
+		newFeature("errors", "/errors", func(id string, rc config.RouteConfig) error {
+			if cfg.Errors.Format == config.ErrorFormatProblemJSON || rc.Errors.Format == config.ErrorFormatProblemJSON {
+				rm.errorRenderers.AddRoute(id, cfg.Errors, rc.Errors, rm.errorPages.Lookup(id))
 			}
-			if rc.Geo.Enabled {
-				return rm.geoFilters.AddRoute(id, geo.MergeGeoConfig(rc.Geo, cfg.Geo))
+			return nil
+		}, rm.errorRenderers.RouteIDs, func() any {
+			// Errors outside any route are listed as "_global".
+			stats := rm.errorRenderers.Stats()
+			if rm.globalErrors != nil {
+				stats["_global"] = rm.globalErrors.Stats()
 			}
-			if cfg.Geo.Enabled {
-				return rm.geoFilters.AddRoute(id, cfg.Geo)
+			return stats
+		}),
+
+		mergeFeature("geo", "/geo", enabledSpec(
+			func(rc *config.RouteConfig) *config.GeoConfig { return &rc.Geo },
+			&cfg.Geo,
+			geo.MergeGeoConfig,
+		), func(id string, gc config.GeoConfig) error {
+			if rm.geoProvider == nil {
+				return nil
 			}
-			return nil
+			return rm.geoFilters.AddRoute(id, gc)
 		}, rm.geoFilters.RouteIDs, func() any { return rm.geoFilters.Stats() }),
 
 		// Always-merge features: merge first, then check if result is active
-		newFeature("cdn_cache_headers", "/cdn-cache-headers", func(id string, rc config.RouteConfig) error {
-			merged := cdnheaders.MergeCDNCacheConfig(rc.CDNCacheHeaders, cfg.CDNCacheHeaders)
-			if merged.Enabled {
-				return rm.cdnHeaders.AddRoute(id, merged)
-			}
-			return nil
-		}, rm.cdnHeaders.RouteIDs, func() any { return rm.cdnHeaders.Stats() }),
+		mergeFeature("cdn_cache_headers", "/cdn-cache-headers", mergeSpec[config.CDNCacheConfig]{
+			route:  func(rc *config.RouteConfig) *config.CDNCacheConfig { return &rc.CDNCacheHeaders },
+			global: &cfg.CDNCacheHeaders,
+			active: config.CDNCacheConfig.IsEnabled,
+			merge:  cdnheaders.MergeCDNCacheConfig,
+			always: true,
+		}, rm.cdnHeaders.AddRoute, rm.cdnHeaders.RouteIDs, func() any { return rm.cdnHeaders.Stats() }),
 
-		newFeature("edge_cache_rules", "/edge-cache-rules", func(id string, rc config.RouteConfig) error {
-			merged := edgecacherules.MergeEdgeCacheRulesConfig(rc.EdgeCacheRules, cfg.EdgeCacheRules)
-			if merged.Enabled {
-				return rm.edgeCacheRules.AddRoute(id, merged)
-			}
-			return nil
-		}, rm.edgeCacheRules.RouteIDs, func() any { return rm.edgeCacheRules.Stats() }),
+		mergeFeature("edge_cache_rules", "/edge-cache-rules", mergeSpec[config.EdgeCacheRulesConfig]{
+			route:  func(rc *config.RouteConfig) *config.EdgeCacheRulesConfig { return &rc.EdgeCacheRules },
+			global: &cfg.EdgeCacheRules,
+			active: config.EdgeCacheRulesConfig.IsEnabled,
+			merge:  edgecacherules.MergeEdgeCacheRulesConfig,
+			always: true,
+		}, rm.edgeCacheRules.AddRoute, rm.edgeCacheRules.RouteIDs, func() any { return rm.edgeCacheRules.Stats() }),
 
 		// ---- Global-scope features (setup is a no-op) ----
 
@@ -343,7 +399,9 @@ func buildFeatures(rm *routeManagers, cfg *config.Config, redisClient *redis.Cli
 		noOpStatsFeature("graphql_federation", "/graphql-federation", rm.federationHandlers),
 		noOpStatsFeature("canary", "/canary", rm.canaryControllers),
 		noOpStatsFeature("outlier_detection", "/outlier-detection", rm.outlierDetectors),
+		noOpStatsFeature("upstream_dns", "/upstream-dns", rm.upstreamDNS),
 		noOpStatsFeature("backpressure", "/backpressure", rm.backpressureHandlers),
+		noOpStatsFeature("stream_limits", "/stream-limits", rm.streamGates),
 		noOpStatsFeature("blue_green", "/blue-green", rm.blueGreenControllers),
 		noOpStatsFeature("ab_test", "/ab-tests", rm.abTests),
 		noOpStatsFeature("sequential", "/sequential", rm.sequentialHandlers),
@@ -351,7 +409,9 @@ func buildFeatures(rm *routeManagers, cfg *config.Config, redisClient *redis.Cli
 		noOpStatsFeature("lambda", "/lambda", rm.lambdaHandlers),
 		noOpStatsFeature("amqp", "/amqp", rm.amqpHandlers),
 		noOpStatsFeature("pubsub", "/pubsub", rm.pubsubHandlers),
+		noOpStatsFeature("kafka", "/kafka", rm.kafkaHandlers),
 		noOpStatsFeature("protocol_translators", "/protocol-translators", rm.translators),
+		noOpStatsFeature("handler_fallbacks", "/handler-fallbacks", rm.handlerFallbacks),
 		noOpStatsFeature("grpc_proxy", "/grpc-proxy", rm.grpcHandlers),
 
 		noOpFeature("retry_budget_pools", "/retry-budget-pools", func() []string { return nil }, func() any {
@@ -365,6 +425,12 @@ func buildFeatures(rm *routeManagers, cfg *config.Config, redisClient *redis.Cli
 			return result
 		}),
 
+		// Shared auth definitions, grouped with the routes that reference them
+		noOpFeature("ext_auth_services", "/ext-auth-services", func() []string { return nil }, func() any { return rm.extAuths.SharedStats() }),
+		noOpFeature("opa_policies", "/opa-policies", func() []string { return nil }, func() any { return rm.opaEnforcers.SharedStats() }),
+		noOpFeature("backend_auth_providers", "/backend-auth-providers", func() []string { return nil }, func() any { return rm.backendAuths.SharedStats() }),
+		noOpFeature("token_exchangers", "/token-exchangers", func() []string { return nil }, func() any { return rm.tokenExchangers.SharedStats() }),
+
 		// Global singleton stats (no per-route setup, but admin stats)
 		noOpFeature("trusted_proxies", "/trusted-proxies", func() []string { return nil }, func() any {
 			if rm.realIPExtractor == nil {
@@ -372,5 +438,6 @@ func buildFeatures(rm *routeManagers, cfg *config.Config, redisClient *redis.Cli
 			}
 			return rm.realIPExtractor.Stats()
 		}),
+		noOpFeature("storage_encryption", "/storage-encryption", func() []string { return nil }, func() any { return rm.storeKeys.Stats() }),
 	}
 }
@@ -0,0 +1,343 @@
+package runway
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/wudi/runway/config"
+)
+
+// Impact severities, from least to most disruptive.
+const (
+	ImpactNone   = "none"
+	ImpactLow    = "low"    // applied by reload; no state or connections lost
+	ImpactMedium = "medium" // applied by reload; in-memory state or a route is lost
+	ImpactHigh   = "high"   // needs a restart, or closes client connections
+)
+
+var impactRank = map[string]int{ImpactNone: 0, ImpactLow: 1, ImpactMedium: 2, ImpactHigh: 3}
+
+// Effects a reload has on a listener, route or config section.
+const (
+	EffectPipelineRebuilt    = "pipeline_rebuilt"
+	EffectStateReset         = "state_reset"
+	EffectRouteRemoved       = "route_removed"
+	EffectListenerStarted    = "listener_started"
+	EffectListenerUpdated    = "listener_updated"
+	EffectListenerRestarted  = "listener_restarted"
+	EffectListenerStopped    = "listener_stopped"
+	EffectRestartRequired    = "restart_required"
+	EffectConnectionsKept    = "connections_kept"
+	EffectConnectionsDropped = "connections_dropped"
+	EffectApplied            = "applied"
+)
+
+// restartSections are top-level config sections that are only read at
+// startup, so a reload silently keeps the running values.
+var restartSections = map[string]bool{
+	"registry":      true,
+	"logging":       true,
+	"tracing":       true,
+	"redis":         true,
+	"tcp_routes":    true,
+	"udp_routes":    true,
+	"feature_flags": true,
+	"cluster":       true,
+}
+
+// ImpactItem is one effect of applying a candidate config.
+type ImpactItem struct {
+	Kind     string `json:"kind"`   // "listener", "route" or "section"
+	Target   string `json:"target"` // listener ID, route ID or section name
+	Change   string `json:"change"` // "added", "removed", "modified" or "unchanged"
+	Effect   string `json:"effect"`
+	Severity string `json:"severity"`
+	Detail   string `json:"detail"`
+}
+
+// ImpactReport describes what reloading the running gateway with a
+// candidate config would do.
+type ImpactReport struct {
+	Valid           bool           `json:"valid"`
+	Error           string         `json:"error,omitempty"`
+	Changes         []string       `json:"changes,omitempty"` // same summary a reload records
+	Items           []ImpactItem   `json:"items"`
+	Severity        string         `json:"severity"` // highest item severity
+	RestartRequired bool           `json:"restart_required"`
+	Counts          map[string]int `json:"counts"` // items per severity
+}
+
+// routeConnections counts a route's open long-lived connections.
+type routeConnections struct {
+	websocket int64
+	sse       int64
+	fanout    bool
+}
+
+// ConfigImpact reports what a reload with newCfg would do to the running
+// gateway without applying it. newCfg must already be validated.
+func (g *Runway) ConfigImpact(newCfg *config.Config) ImpactReport {
+	g.mu.RLock()
+	oldCfg := g.config
+	conns := make(map[string]routeConnections, len(oldCfg.Routes))
+	for _, rc := range oldCfg.Routes {
+		var c routeConnections
+		if ws := g.wsProxies.Lookup(rc.ID); ws != nil {
+			c.websocket = ws.Active()
+		}
+		if h := g.sseHandlers.Lookup(rc.ID); h != nil {
+			c.sse = h.ActiveConnections()
+			c.fanout = h.Fanout()
+		}
+		conns[rc.ID] = c
+	}
+	g.mu.RUnlock()
+	return analyzeImpact(oldCfg, newCfg, conns)
+}
+
+// analyzeImpact classifies the differences between oldCfg and newCfg by how
+// Reload and listener reconciliation apply them.
+func analyzeImpact(oldCfg, newCfg *config.Config, conns map[string]routeConnections) ImpactReport {
+	r := ImpactReport{Valid: true, Changes: diffConfig(oldCfg, newCfg)}
+	r.Items = append(r.Items, listenerImpact(oldCfg, newCfg)...)
+	r.Items = append(r.Items, routeImpact(oldCfg, newCfg, conns)...)
+	r.Items = append(r.Items, sectionImpact(oldCfg, newCfg)...)
+
+	r.Severity = ImpactNone
+	r.Counts = map[string]int{ImpactLow: 0, ImpactMedium: 0, ImpactHigh: 0}
+	for _, it := range r.Items {
+		r.Counts[it.Severity]++
+		if impactRank[it.Severity] > impactRank[r.Severity] {
+			r.Severity = it.Severity
+		}
+		if it.Effect == EffectRestartRequired {
+			r.RestartRequired = true
+		}
+	}
+	if r.Items == nil {
+		r.Items = []ImpactItem{}
+	}
+	return r
+}
+
+// listenerImpact mirrors reconcileListeners: added listeners are started,
+// removed ones drained, and changed ones updated in place or reopened. TCP
+// and UDP listeners need the proxy that tcp_routes or udp_routes start.
+func listenerImpact(oldCfg, newCfg *config.Config) []ImpactItem {
+	var items []ImpactItem
+	old := make(map[string]config.ListenerConfig, len(oldCfg.Listeners))
+	for _, l := range oldCfg.Listeners {
+		old[l.ID] = l
+	}
+	seen := make(map[string]bool, len(newCfg.Listeners))
+	for _, l := range newCfg.Listeners {
+		seen[l.ID] = true
+		prev, ok := old[l.ID]
+		change := "added"
+		if ok {
+			change = "modified"
+		}
+		switch {
+		case ok && reflect.DeepEqual(prev, l):
+		case !proxyRunning(oldCfg, l.Protocol):
+			items = append(items, ImpactItem{Kind: "listener", Target: l.ID, Change: change,
+				Effect: EffectRestartRequired, Severity: ImpactHigh,
+				Detail: fmt.Sprintf("%s listeners need %s_routes at startup", l.Protocol, l.Protocol)})
+		case !ok:
+			items = append(items, ImpactItem{Kind: "listener", Target: l.ID, Change: change,
+				Effect: EffectListenerStarted, Severity: ImpactLow,
+				Detail: fmt.Sprintf("listener starts on %s", l.Address)})
+		case prev.Address != l.Address:
+			items = append(items, ImpactItem{Kind: "listener", Target: l.ID, Change: change,
+				Effect: EffectListenerRestarted, Severity: ImpactMedium,
+				Detail: fmt.Sprintf("address %s -> %s; the listener on %s drains and stops", prev.Address, l.Address, prev.Address)})
+		case listenerReopens(prev, l):
+			items = append(items, ImpactItem{Kind: "listener", Target: l.ID, Change: change,
+				Effect: EffectListenerRestarted, Severity: ImpactMedium,
+				Detail: fmt.Sprintf("listener on %s is closed and reopened; open connections drain", l.Address)})
+		default:
+			items = append(items, ImpactItem{Kind: "listener", Target: l.ID, Change: change,
+				Effect: EffectListenerUpdated, Severity: ImpactLow,
+				Detail: "applied in place; new connections and handshakes use the new settings"})
+		}
+	}
+	for _, l := range oldCfg.Listeners {
+		if !seen[l.ID] {
+			items = append(items, ImpactItem{Kind: "listener", Target: l.ID, Change: "removed",
+				Effect: EffectListenerStopped, Severity: ImpactMedium,
+				Detail: fmt.Sprintf("listener on %s drains and stops", l.Address)})
+		}
+	}
+	return items
+}
+
+// proxyRunning reports whether the gateway started with cfg can serve
+// listeners of protocol: TCP and UDP listeners need the proxy their routes
+// start.
+func proxyRunning(cfg *config.Config, protocol config.Protocol) bool {
+	switch protocol {
+	case config.ProtocolTCP:
+		return len(cfg.TCPRoutes) > 0
+	case config.ProtocolUDP:
+		return len(cfg.UDPRoutes) > 0
+	}
+	return true
+}
+
+// routeImpact reports rebuilt and removed routes, the in-memory state every
+// reload discards, and open connections. Every route gets fresh managers on
+// reload, so local state is lost even for routes whose config is unchanged.
+func routeImpact(oldCfg, newCfg *config.Config, conns map[string]routeConnections) []ImpactItem {
+	var items []ImpactItem
+	old := make(map[string]config.RouteConfig, len(oldCfg.Routes))
+	for _, rc := range oldCfg.Routes {
+		old[rc.ID] = resolveUpstreamRefs(oldCfg, rc)
+	}
+	sharedChanged := sharedDefChanges(oldCfg, newCfg)
+
+	seen := make(map[string]bool, len(newCfg.Routes))
+	for i := range newCfg.Routes {
+		rc := &newCfg.Routes[i]
+		seen[rc.ID] = true
+		prev, ok := old[rc.ID]
+		if !ok {
+			items = append(items, ImpactItem{Kind: "route", Target: rc.ID, Change: "added",
+				Effect: EffectPipelineRebuilt, Severity: ImpactLow, Detail: "route handler is built"})
+			continue
+		}
+
+		change := "unchanged"
+		cur := resolveUpstreamRefs(newCfg, *rc)
+		if fields := changedFields(reflect.ValueOf(prev), reflect.ValueOf(cur)); len(fields) > 0 || referencesAny(rc, sharedChanged) {
+			change = "modified"
+			detail := "handler pipeline is rebuilt with a changed shared definition"
+			if len(fields) > 0 {
+				detail = "handler pipeline is rebuilt; changed: " + strings.Join(fields, ", ")
+			}
+			items = append(items, ImpactItem{Kind: "route", Target: rc.ID, Change: change,
+				Effect: EffectPipelineRebuilt, Severity: ImpactLow, Detail: detail})
+		}
+		if state := localState(&prev); len(state) > 0 {
+			items = append(items, ImpactItem{Kind: "route", Target: rc.ID, Change: change,
+				Effect: EffectStateReset, Severity: ImpactMedium,
+				Detail: "in-memory state is reset: " + strings.Join(state, ", ")})
+		}
+		if it, ok := connectionImpact(rc.ID, change, conns[rc.ID]); ok {
+			items = append(items, it)
+		}
+	}
+
+	for _, rc := range oldCfg.Routes {
+		if seen[rc.ID] {
+			continue
+		}
+		items = append(items, ImpactItem{Kind: "route", Target: rc.ID, Change: "removed",
+			Effect: EffectRouteRemoved, Severity: ImpactMedium,
+			Detail: fmt.Sprintf("requests to %s no longer match this route", rc.Path)})
+		if it, ok := connectionImpact(rc.ID, "removed", conns[rc.ID]); ok {
+			items = append(items, it)
+		}
+	}
+	return items
+}
+
+// connectionImpact reports open WebSocket and SSE connections on a route.
+// Fan-out hubs are stopped on every reload, disconnecting their clients;
+// other connections keep running on the previous handler until they close.
+func connectionImpact(routeID, change string, c routeConnections) (ImpactItem, bool) {
+	if c.fanout && c.sse > 0 {
+		return ImpactItem{Kind: "route", Target: routeID, Change: change,
+			Effect: EffectConnectionsDropped, Severity: ImpactHigh,
+			Detail: fmt.Sprintf("%d SSE fan-out clients are disconnected when the hub stops", c.sse)}, true
+	}
+	if change == "unchanged" || c.websocket+c.sse == 0 {
+		return ImpactItem{}, false
+	}
+	return ImpactItem{Kind: "route", Target: routeID, Change: change,
+		Effect: EffectConnectionsKept, Severity: ImpactLow,
+		Detail: fmt.Sprintf("%d WebSocket and %d SSE connections keep the previous config until they close", c.websocket, c.sse)}, true
+}
+
+// localState lists a route's features whose state lives in process memory.
+func localState(rc *config.RouteConfig) []string {
+	var state []string
+	if rc.RateLimit.Enabled && rc.RateLimit.Mode != "distributed" {
+		state = append(state, "rate_limit")
+	}
+	if rc.SpikeArrest.Enabled {
+		state = append(state, "spike_arrest")
+	}
+	if rc.ConcurrencyLimit.Enabled && rc.ConcurrencyLimit.Mode != "distributed" {
+		state = append(state, "concurrency_limit")
+	}
+	if rc.Quota.Enabled && !rc.Quota.Redis {
+		state = append(state, "quota")
+	}
+	if rc.CircuitBreaker.Enabled && rc.CircuitBreaker.Mode != "distributed" {
+		state = append(state, "circuit_breaker")
+	}
+	if rc.Cache.Enabled && rc.Cache.Mode != "distributed" {
+		state = append(state, "cache")
+	}
+	if rc.OutlierDetection.Enabled {
+		state = append(state, "outlier_detection")
+	}
+	if rc.TrafficShaping.AdaptiveConcurrency.Enabled {
+		state = append(state, "adaptive_concurrency")
+	}
+	if rc.DegradedMode.Enabled {
+		state = append(state, "degraded_mode")
+	}
+	return state
+}
+
+// sectionImpact reports changed top-level sections other than listeners and
+// routes, which are covered in detail above.
+func sectionImpact(oldCfg, newCfg *config.Config) []ImpactItem {
+	var items []ImpactItem
+	for _, name := range changedFields(reflect.ValueOf(*oldCfg), reflect.ValueOf(*newCfg)) {
+		if name == "listeners" || name == "routes" {
+			continue
+		}
+		it := ImpactItem{Kind: "section", Target: name, Change: "modified",
+			Effect: EffectApplied, Severity: ImpactLow, Detail: "applied on reload"}
+		if restartSections[name] || (name == "admin" && adminNeedsRestart(oldCfg.Admin, newCfg.Admin)) {
+			it.Effect, it.Severity = EffectRestartRequired, ImpactHigh
+			it.Detail = "only read at startup; the running value is kept until restart"
+		}
+		items = append(items, it)
+	}
+	if oldCfg.ServiceRateLimit.Enabled && newCfg.ServiceRateLimit.Enabled {
+		items = append(items, ImpactItem{Kind: "section", Target: "service_rate_limit", Change: "unchanged",
+			Effect: EffectStateReset, Severity: ImpactMedium, Detail: "service-wide limiter is rebuilt"})
+	}
+	return items
+}
+
+// adminNeedsRestart reports whether an admin change goes beyond the
+// dependency health and backend TLS scan settings that reload re-applies.
+func adminNeedsRestart(oldAdmin, newAdmin config.AdminConfig) bool {
+	oldAdmin.DependencyHealth, newAdmin.DependencyHealth = config.DependencyHealthConfig{}, config.DependencyHealthConfig{}
+	oldAdmin.BackendTLSScan, newAdmin.BackendTLSScan = config.BackendTLSScanConfig{}, config.BackendTLSScanConfig{}
+	return !reflect.DeepEqual(oldAdmin, newAdmin)
+}
+
+// changedFields returns the YAML names of the exported fields that differ
+// between two values of the same struct type.
+func changedFields(a, b reflect.Value) []string {
+	var names []string
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		names = append(names, name)
+	}
+	return names
+}
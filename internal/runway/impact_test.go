@@ -0,0 +1,158 @@
+package runway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+const impactBaseConfig = `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: orders
+    path: /orders
+    backends:
+      - url: http://localhost:9000
+`
+
+func postImpact(t *testing.T, server *Server, body string) (int, ImpactReport) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/admin/config/impact", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, req)
+	var report ImpactReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	return w.Code, report
+}
+
+func findImpact(report ImpactReport, kind, target, effect string) (ImpactItem, bool) {
+	for _, it := range report.Items {
+		if it.Kind == kind && it.Target == target && it.Effect == effect {
+			return it, true
+		}
+	}
+	return ImpactItem{}, false
+}
+
+func TestConfigImpactEndpoint(t *testing.T) {
+	cfg, err := config.NewLoader().Parse([]byte(impactBaseConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	t.Run("listener port change", func(t *testing.T) {
+		code, report := postImpact(t, server, strings.Replace(impactBaseConfig, `":8080"`, `":9090"`, 1))
+		if code != http.StatusOK || !report.Valid {
+			t.Fatalf("expected a valid report, got %d %+v", code, report)
+		}
+		it, ok := findImpact(report, "listener", "http", EffectListenerRestarted)
+		if !ok || it.Severity != ImpactMedium || !strings.Contains(it.Detail, ":8080 -> :9090") {
+			t.Errorf("expected the listener to be reopened, got %+v", report.Items)
+		}
+		if report.RestartRequired {
+			t.Error("expected no process restart for a listener address change")
+		}
+	})
+
+	t.Run("listener timeout change", func(t *testing.T) {
+		candidate := strings.Replace(impactBaseConfig, `    protocol: "http"`, "    protocol: \"http\"\n    http:\n      read_timeout: 5s", 1)
+		code, report := postImpact(t, server, candidate)
+		if code != http.StatusOK || !report.Valid {
+			t.Fatalf("expected a valid report, got %d %+v", code, report)
+		}
+		if it, ok := findImpact(report, "listener", "http", EffectListenerUpdated); !ok || it.Severity != ImpactLow {
+			t.Errorf("expected the listener to be updated in place, got %+v", report.Items)
+		}
+	})
+
+	t.Run("header transform change", func(t *testing.T) {
+		candidate := impactBaseConfig + "    transform:\n      request:\n        headers:\n          set:\n            X-Source: gateway\n"
+		code, report := postImpact(t, server, candidate)
+		if code != http.StatusOK || !report.Valid {
+			t.Fatalf("expected a valid report, got %d %+v", code, report)
+		}
+		it, ok := findImpact(report, "route", "orders", EffectPipelineRebuilt)
+		if !ok || it.Severity != ImpactLow || it.Change != "modified" || !strings.Contains(it.Detail, "transform") {
+			t.Errorf("expected a low-severity rebuild of the route, got %+v", report.Items)
+		}
+		if report.Severity != ImpactLow || report.RestartRequired || len(report.Items) != 1 {
+			t.Errorf("expected only low-severity impact, got %+v", report)
+		}
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		code, report := postImpact(t, server, impactBaseConfig+"    load_balancer: bogus\n")
+		if code != http.StatusUnprocessableEntity || report.Valid || report.Error == "" {
+			t.Errorf("expected a validation error, got %d %+v", code, report)
+		}
+	})
+
+	req := httptest.NewRequest("GET", "/admin/config/impact", nil)
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+}
+
+func TestAnalyzeImpactStateAndConnections(t *testing.T) {
+	oldCfg := &config.Config{
+		Listeners: []config.ListenerConfig{{ID: "http", Address: ":8080", Protocol: config.ProtocolHTTP}},
+		Routes: []config.RouteConfig{
+			{ID: "api", Path: "/api",
+				RateLimit:      config.RateLimitConfig{Enabled: true, Rate: 10},
+				CircuitBreaker: config.CircuitBreakerConfig{Enabled: true, Mode: "distributed"}},
+			{ID: "events", Path: "/events"},
+			{ID: "legacy", Path: "/legacy"},
+		},
+	}
+	newCfg := &config.Config{
+		Listeners: []config.ListenerConfig{
+			{ID: "http", Address: ":8080", Protocol: config.ProtocolHTTP},
+			{ID: "tcp", Address: ":9000", Protocol: config.ProtocolTCP},
+		},
+		Routes: oldCfg.Routes[:2],
+	}
+	conns := map[string]routeConnections{
+		"events": {sse: 3, fanout: true},
+		"legacy": {websocket: 2},
+	}
+
+	report := analyzeImpact(oldCfg, newCfg, conns)
+
+	if it, ok := findImpact(report, "route", "api", EffectStateReset); !ok || it.Severity != ImpactMedium || it.Detail != "in-memory state is reset: rate_limit" {
+		t.Errorf("expected the local rate limiter but not the distributed breaker to be reset, got %+v", it)
+	}
+	if _, ok := findImpact(report, "route", "api", EffectPipelineRebuilt); ok {
+		t.Error("expected no rebuild item for an unchanged route")
+	}
+	if it, ok := findImpact(report, "route", "events", EffectConnectionsDropped); !ok || it.Severity != ImpactHigh {
+		t.Errorf("expected fan-out clients to be dropped, got %+v", report.Items)
+	}
+	if it, ok := findImpact(report, "route", "legacy", EffectRouteRemoved); !ok || it.Severity != ImpactMedium {
+		t.Errorf("expected the removed route to be reported, got %+v", report.Items)
+	}
+	if it, ok := findImpact(report, "route", "legacy", EffectConnectionsKept); !ok || it.Severity != ImpactLow {
+		t.Errorf("expected open WebSocket connections to be kept, got %+v", report.Items)
+	}
+	if _, ok := findImpact(report, "listener", "tcp", EffectRestartRequired); !ok || !report.RestartRequired {
+		t.Errorf("expected a new TCP listener to require a restart, got %+v", report.Items)
+	}
+	if report.Counts[ImpactHigh] != 2 || report.Severity != ImpactHigh {
+		t.Errorf("expected 2 high-severity items, got %v", report.Counts)
+	}
+}
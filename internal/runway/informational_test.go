@@ -0,0 +1,187 @@
+package runway
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+func newInformationalTestServer(t *testing.T, cfg *config.Config) *httptest.Server {
+	t.Helper()
+	gw, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	t.Cleanup(func() { gw.Close() })
+
+	ts := httptest.NewServer(gw.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRunwayForwardsEarlyHints(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Del("Link")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer backend.Close()
+
+	for _, forward := range []bool{true, false} {
+		cfg := &config.Config{
+			Registry: config.RegistryConfig{Type: "memory"},
+			Routes: []config.RouteConfig{{
+				ID:                   "hints",
+				Path:                 "/hints",
+				Backends:             []config.BackendConfig{{URL: backend.URL}},
+				ForwardInformational: forward,
+			}},
+		}
+		ts := newInformationalTestServer(t, cfg)
+
+		var codes []int
+		var link string
+		trace := &httptrace.ClientTrace{
+			Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+				codes = append(codes, code)
+				link = header.Get("Link")
+				return nil
+			},
+		}
+		req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), "GET", ts.URL+"/hints", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || string(body) != `{"ok":true}` {
+			t.Fatalf("forward=%v: unexpected final response %d %q", forward, resp.StatusCode, body)
+		}
+		if resp.Header.Get("Link") != "" {
+			t.Errorf("forward=%v: interim Link header leaked into final response", forward)
+		}
+		if !forward {
+			if len(codes) != 0 {
+				t.Errorf("expected no interim responses, got %v", codes)
+			}
+			continue
+		}
+		if len(codes) != 1 || codes[0] != http.StatusEarlyHints {
+			t.Fatalf("expected one 103 response, got %v", codes)
+		}
+		if link != "</style.css>; rel=preload; as=style" {
+			t.Errorf("expected Link header on 103, got %q", link)
+		}
+	}
+}
+
+func TestRunwayPreservesTrailersWithCompression(t *testing.T) {
+	payload := `{"data":"` + strings.Repeat("x", 4096) + `"}`
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(payload))
+		w.Header().Set("X-Checksum", "abc123")
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{{
+			ID:          "trailers",
+			Path:        "/trailers",
+			Backends:    []config.BackendConfig{{URL: backend.URL}},
+			Compression: config.CompressionConfig{Enabled: true, Algorithms: []string{"gzip"}},
+		}},
+	}
+	ts := newInformationalTestServer(t, cfg)
+
+	req, _ := http.NewRequest("GET", ts.URL+"/trailers", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip response, got Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	if string(body) != payload {
+		t.Errorf("body mismatch after decompression")
+	}
+	if got := resp.Trailer.Get("X-Checksum"); got != "abc123" {
+		t.Errorf("expected trailer X-Checksum=abc123, got %q", got)
+	}
+}
+
+func TestRunwayGRPCBypassesBodyMiddleware(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.Write([]byte(strings.Repeat("\x00", 2048)))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{{
+			ID:       "grpc",
+			Path:     "/svc",
+			Backends: []config.BackendConfig{{URL: backend.URL}},
+			Compression: config.CompressionConfig{
+				Enabled:      true,
+				Algorithms:   []string{"gzip"},
+				ContentTypes: []string{"application/grpc", "application/json"},
+			},
+		}},
+	}
+	ts := newInformationalTestServer(t, cfg)
+
+	for _, ct := range []string{"application/grpc", "application/json"} {
+		req, _ := http.NewRequest("POST", ts.URL+"/svc", strings.NewReader(""))
+		req.Header.Set("Content-Type", ct)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		compressed := resp.Header.Get("Content-Encoding") == "gzip"
+		if ct == "application/grpc" {
+			if compressed {
+				t.Error("gRPC response must bypass compression")
+			}
+			if resp.Trailer.Get("Grpc-Status") != "0" {
+				t.Errorf("expected Grpc-Status trailer, got %q", resp.Trailer.Get("Grpc-Status"))
+			}
+		} else if !compressed {
+			t.Error("expected non-gRPC response to be compressed")
+		}
+	}
+}
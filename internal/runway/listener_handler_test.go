@@ -0,0 +1,114 @@
+package runway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/variables"
+)
+
+const listenerHandlerConfig = `
+listeners:
+  - id: public
+    address: ":8443"
+    protocol: http
+  - id: internal
+    address: ":8080"
+    protocol: http
+routes:
+  - id: api
+    path: /api
+    backends:
+      - url: BACKEND
+    transform:
+      request:
+        headers:
+          set:
+            X-Listener: "$listener_id"
+  - id: ops
+    path: /ops
+    listeners: [internal]
+    backends:
+      - url: BACKEND
+    rules:
+      request:
+        - id: internal-only
+          expression: 'listener.id != "internal"'
+          action: block
+`
+
+func TestListenerHandler(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen-Listener", r.Header.Get("X-Listener"))
+	}))
+	defer backend.Close()
+
+	cfg, err := config.NewLoader().Parse([]byte(strings.ReplaceAll(listenerHandlerConfig, "BACKEND", backend.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A WAF-style check that only runs on the public listener.
+	blockScanners := CustomGlobalSlot{
+		Name:      "block_scanners",
+		After:     "listener_id",
+		Listeners: []string{"public"},
+		Build: func(*config.Config) func(http.Handler) http.Handler {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Header.Get("User-Agent") == "scanner" {
+						w.Header().Set("X-Blocked-On", variables.GetFromRequest(r).ListenerID)
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					next.ServeHTTP(w, r)
+				})
+			}
+		},
+	}
+	server, err := NewServer(cfg, "", ExternalOptions{UseDefaults: true, CustomGlobal: []CustomGlobalSlot{blockScanners}})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	handlers := map[string]http.Handler{
+		"public":   server.Runway().ListenerHandler("public"),
+		"internal": server.Runway().ListenerHandler("internal"),
+		"":         server.Runway().Handler(),
+	}
+	do := func(listener, path, userAgent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("User-Agent", userAgent)
+		rec := httptest.NewRecorder()
+		handlers[listener].ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The listener-scoped middleware only runs on its listener.
+	if rec := do("public", "/api", "scanner"); rec.Code != http.StatusForbidden || rec.Header().Get("X-Blocked-On") != "public" {
+		t.Errorf("public: expected the scanner blocked, got %d %v", rec.Code, rec.Header())
+	}
+	for _, l := range []string{"internal", ""} {
+		if rec := do(l, "/api", "scanner"); rec.Code != http.StatusOK {
+			t.Errorf("%q: expected the scanner let through, got %d", l, rec.Code)
+		}
+	}
+
+	// $listener_id names the receiving listener.
+	if rec := do("internal", "/api", ""); rec.Header().Get("X-Seen-Listener") != "internal" {
+		t.Errorf("expected $listener_id internal, got %q", rec.Header().Get("X-Seen-Listener"))
+	}
+
+	// Routes restricted to listeners are not found via other listeners.
+	if rec := do("internal", "/ops", ""); rec.Code != http.StatusOK {
+		t.Errorf("internal /ops: expected 200, got %d", rec.Code)
+	}
+	for _, l := range []string{"public", ""} {
+		if rec := do(l, "/ops", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%q /ops: expected 404, got %d", l, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package runway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/listener"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+// Listener reload actions, reported per listener in ReloadResult.
+const (
+	ListenerAdded     = "added"     // started
+	ListenerRemoved   = "removed"   // stopped accepting and closed once drained
+	ListenerUpdated   = "updated"   // changes applied in place, socket kept
+	ListenerRestarted = "restarted" // closed and reopened for an address or protocol change
+	ListenerFailed    = "failed"    // change rolled back, the listener keeps its previous config
+)
+
+// ListenerAction is what a reload did to one listener. Draining is set
+// when the removed or replaced listener is still finishing its open
+// connections in the background.
+type ListenerAction struct {
+	ID       string `json:"id"`
+	Action   string `json:"action"`
+	Address  string `json:"address,omitempty"`
+	Draining bool   `json:"draining,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// addressReleaser is implemented by listeners that can give up their
+// address before their connections finish draining.
+type addressReleaser interface {
+	Release()
+}
+
+// shutdownTimeout returns how long listeners drain on shutdown.
+func shutdownTimeout(sc config.ShutdownConfig) time.Duration {
+	if sc.Timeout <= 0 {
+		return 30 * time.Second
+	}
+	return sc.Timeout
+}
+
+// reconcileListeners brings the running listeners in line with newCfg,
+// the config committed as generation gen, and reports what it did to each
+// listener that was added, removed or changed. A config a later reload has
+// already replaced is skipped: that reload reconciles its own. Removed
+// listeners drain in the background; changed ones are updated in place
+// where they can be, and reopened otherwise. A listener whose change fails
+// keeps its previous config, and the next reload retries the change.
+func (s *Server) reconcileListeners(newCfg *config.Config, gen uint64) []ListenerAction {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+
+	s.reloadMu.Lock()
+	latest := s.configGen
+	s.reloadMu.Unlock()
+	if gen != latest {
+		logging.Debug("Skipping listener reconcile of a replaced config", zap.Uint64("generation", gen), zap.Uint64("latest", latest))
+		return nil
+	}
+
+	wanted := make(map[string]bool, len(newCfg.Listeners))
+	addrs := make(map[string]bool, len(newCfg.Listeners))
+	for _, lc := range newCfg.Listeners {
+		wanted[lc.ID] = true
+		addrs[lc.Address] = true
+	}
+
+	var actions []ListenerAction
+
+	// Removed listeners leave before any listener starts, so a new
+	// listener can take over a removed one's address: one whose address is
+	// still wanted gives it up at once, the others keep accepting for the
+	// drain delay.
+	for id, lc := range s.listenerCfgs {
+		if wanted[id] {
+			continue
+		}
+		delete(s.listenerCfgs, id)
+		l, ok := s.manager.Get(id)
+		if !ok {
+			continue
+		}
+		s.manager.Remove(id)
+		a := ListenerAction{ID: id, Action: ListenerRemoved, Address: lc.Address, Draining: true}
+		r, releases := l.(addressReleaser)
+		switch {
+		case !addrs[lc.Address]:
+			s.drainInBackground(id, func() error { return s.drainListener(l, newCfg.Shutdown) })
+		case releases:
+			r.Release()
+			s.drainInBackground(id, func() error { return s.stopListener(l, newCfg.Shutdown) })
+		default:
+			if err := s.stopListener(l, newCfg.Shutdown); err != nil {
+				a.Error = err.Error()
+			}
+			a.Draining = false
+		}
+		actions = append(actions, a)
+		logging.Info("Removed listener", zap.String("id", id), zap.String("address", lc.Address))
+	}
+
+	for _, lc := range newCfg.Listeners {
+		prev, exists := s.listenerCfgs[lc.ID]
+		if exists && reflect.DeepEqual(prev, lc) {
+			continue
+		}
+		var a ListenerAction
+		if cur, ok := s.manager.Get(lc.ID); exists && ok {
+			a = s.changeListener(cur, prev, lc, newCfg.Shutdown)
+		} else {
+			a = s.addListener(lc)
+		}
+		actions = append(actions, a)
+		if a.Action == ListenerFailed {
+			logging.Error("Listener reload failed", zap.String("id", lc.ID), zap.String("error", a.Error))
+		} else {
+			logging.Info("Reloaded listener", zap.String("id", lc.ID), zap.String("action", a.Action), zap.String("address", a.Address))
+		}
+	}
+
+	return actions
+}
+
+// drainInBackground runs stop, which takes a removed or replaced listener
+// out of service, without holding up the reload. Shutdown waits for it.
+func (s *Server) drainInBackground(id string, stop func() error) {
+	s.drains.Add(1)
+	go func() {
+		defer s.drains.Done()
+		if err := stop(); err != nil {
+			logging.Warn("Listener did not drain cleanly", zap.String("id", id), zap.Error(err))
+		}
+	}()
+}
+
+// addListener starts a new listener.
+func (s *Server) addListener(lc config.ListenerConfig) ListenerAction {
+	l, err := s.makeListener(lc)
+	if err == nil {
+		err = s.startListener(l)
+	}
+	if err != nil {
+		return ListenerAction{ID: lc.ID, Action: ListenerFailed, Address: lc.Address, Error: err.Error()}
+	}
+	s.manager.Replace(l)
+	s.listenerCfgs[lc.ID] = lc
+	return ListenerAction{ID: lc.ID, Action: ListenerAdded, Address: lc.Address}
+}
+
+// changeListener applies lc to the running listener cur, configured with
+// prev: in place when cur supports the change, otherwise by reopening it.
+// The old listener of a reopened one drains in the background.
+func (s *Server) changeListener(cur listener.Listener, prev, lc config.ListenerConfig, sc config.ShutdownConfig) ListenerAction {
+	failed := func(err error) ListenerAction {
+		return ListenerAction{ID: lc.ID, Action: ListenerFailed, Address: prev.Address, Error: err.Error()}
+	}
+
+	if prev.Protocol == lc.Protocol && prev.Address == lc.Address {
+		err := s.updateListener(cur, lc)
+		if err == nil {
+			s.listenerCfgs[lc.ID] = lc
+			return ListenerAction{ID: lc.ID, Action: ListenerUpdated, Address: lc.Address}
+		}
+		if !errors.Is(err, listener.ErrRestartRequired) {
+			return failed(err)
+		}
+	}
+
+	next, err := s.makeListener(lc)
+	if err != nil {
+		return failed(err)
+	}
+
+	// A new address is bound before the old listener lets go of its own,
+	// which keeps serving through its drain.
+	if prev.Address != lc.Address {
+		if err := s.startListener(next); err != nil {
+			return failed(err)
+		}
+		s.manager.Replace(next)
+		s.listenerCfgs[lc.ID] = lc
+		s.drainInBackground(lc.ID, func() error { return s.drainListener(cur, sc) })
+		return ListenerAction{ID: lc.ID, Action: ListenerRestarted, Address: lc.Address, Draining: true}
+	}
+
+	// The same address must be released first. Connections the old
+	// listener accepted drain while the new one starts.
+	stopped := make(chan struct{})
+	r, releases := cur.(addressReleaser)
+	if releases {
+		r.Release()
+		go func() {
+			defer close(stopped)
+			s.stopListener(cur, sc)
+		}()
+	} else {
+		s.stopListener(cur, sc)
+		close(stopped)
+	}
+	if err := s.startListener(next); err != nil {
+		// Roll back: reopen the listener with its previous config.
+		<-stopped
+		restored, rerr := s.makeListener(prev)
+		if rerr == nil {
+			rerr = s.startListener(restored)
+		}
+		if rerr != nil {
+			s.manager.Remove(lc.ID)
+			delete(s.listenerCfgs, lc.ID)
+			return failed(fmt.Errorf("%w; restoring the previous config failed: %v", err, rerr))
+		}
+		s.manager.Replace(restored)
+		return failed(err)
+	}
+	s.manager.Replace(next)
+	s.listenerCfgs[lc.ID] = lc
+	s.drainInBackground(lc.ID, func() error {
+		<-stopped
+		return nil
+	})
+	return ListenerAction{ID: lc.ID, Action: ListenerRestarted, Address: lc.Address, Draining: releases}
+}
+
+// updateListener applies lc to a running listener in place. It returns
+// listener.ErrRestartRequired when l cannot apply the change.
+func (s *Server) updateListener(l listener.Listener, lc config.ListenerConfig) error {
+	switch l := l.(type) {
+	case *listener.HTTPListener:
+		return l.Update(s.httpListenerConfig(lc))
+	case *listener.TCPListener:
+		return l.Update(s.tcpListenerConfig(lc))
+	default:
+		return listener.ErrRestartRequired
+	}
+}
+
+// listenerReopens reports whether changing a listener from prev to lc
+// closes and reopens it rather than updating it in place, as the
+// listener's Update decides.
+func listenerReopens(prev, lc config.ListenerConfig) bool {
+	if prev.Protocol != lc.Protocol || prev.Address != lc.Address {
+		return true
+	}
+	switch lc.Protocol {
+	case config.ProtocolHTTP:
+		return prev.TLS.Enabled != lc.TLS.Enabled || lc.TLS.Enabled && !reflect.DeepEqual(prev.TLS.ACME, lc.TLS.ACME)
+	case config.ProtocolTCP:
+		terminates := func(c config.ListenerConfig) bool { return c.TLS.Enabled && !c.TCP.SNIRouting }
+		return prev.TCP.SNIRouting != lc.TCP.SNIRouting || terminates(prev) != terminates(lc)
+	default:
+		return true
+	}
+}
+
+// startListener starts l, stopping it again if it fails to start.
+func (s *Server) startListener(l listener.Listener) error {
+	if err := l.Start(context.Background()); err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		l.Stop(ctx)
+		return err
+	}
+	return nil
+}
+
+// drainListener takes a listener out of service: it keeps accepting for
+// the shutdown drain delay, so load balancers stop sending to it, then
+// stops it.
+func (s *Server) drainListener(l listener.Listener, sc config.ShutdownConfig) error {
+	if sc.DrainDelay > 0 {
+		time.Sleep(sc.DrainDelay)
+	}
+	return s.stopListener(l, sc)
+}
+
+// stopListener stops a listener, letting its connections finish within the
+// shutdown timeout.
+func (s *Server) stopListener(l listener.Listener, sc config.ShutdownConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout(sc))
+	defer cancel()
+	return l.Stop(ctx)
+}
@@ -0,0 +1,329 @@
+package runway
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+// freeAddr returns a loopback address nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// writeListenerCert writes a self-signed certificate for 127.0.0.1.
+func writeListenerCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// listenerReloadConfig parses a config with the given listeners block and
+// a route to backend.
+func listenerReloadConfig(t *testing.T, listeners, backend string) *config.Config {
+	t.Helper()
+	cfg, err := config.NewLoader().Parse([]byte("listeners:\n" + listeners + `
+admin:
+  enabled: false
+routes:
+  - id: test
+    path: /test
+    backends:
+      - url: ` + backend + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func httpListenerYAML(id, addr string) string {
+	return fmt.Sprintf("  - id: %s\n    address: %q\n    protocol: http\n", id, addr)
+}
+
+// startReloadServer starts a server for cfg and stops it with the test.
+func startReloadServer(t *testing.T, cfg *config.Config) *Server {
+	t.Helper()
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Shutdown(5 * time.Second) })
+	return server
+}
+
+func getStatus(t *testing.T, client *http.Client, url string) int {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestReloadAddsListener(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	a, b := freeAddr(t), freeAddr(t)
+	server := startReloadServer(t, listenerReloadConfig(t, httpListenerYAML("a", a), backend.URL))
+
+	result := server.ReloadWithConfig(listenerReloadConfig(t, httpListenerYAML("a", a)+httpListenerYAML("b", b), backend.URL))
+	if !result.Success {
+		t.Fatalf("reload failed: %s", result.Error)
+	}
+	want := []ListenerAction{{ID: "b", Action: ListenerAdded, Address: b}}
+	if fmt.Sprint(result.Listeners) != fmt.Sprint(want) {
+		t.Errorf("listener actions = %+v, want %+v", result.Listeners, want)
+	}
+
+	for _, addr := range []string{a, b} {
+		if got := getStatus(t, http.DefaultClient, "http://"+addr+"/test"); got != http.StatusOK {
+			t.Errorf("%s: status %d, want 200", addr, got)
+		}
+	}
+	if server.ListenerManager().Count() != 2 {
+		t.Errorf("listeners = %d, want 2", server.ListenerManager().Count())
+	}
+}
+
+func TestReloadChangesClientAuthInPlace(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	certFile, keyFile := writeListenerCert(t)
+	addr := freeAddr(t)
+	listeners := func(clientAuth string) string {
+		return httpListenerYAML("https", addr) + fmt.Sprintf(`    tls:
+      enabled: true
+      cert_file: %s
+      key_file: %s
+      client_auth: %s
+`, certFile, keyFile, clientAuth)
+	}
+	server := startReloadServer(t, listenerReloadConfig(t, listeners("none"), backend.URL))
+
+	// A connection opened before the reload keeps being served after it.
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"http/1.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	roundTrip := func() int {
+		t.Helper()
+		fmt.Fprintf(conn, "GET /test HTTP/1.1\r\nHost: %s\r\n\r\n", addr)
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if got := roundTrip(); got != http.StatusOK {
+		t.Fatalf("before reload: status %d, want 200", got)
+	}
+
+	result := server.ReloadWithConfig(listenerReloadConfig(t, listeners("require"), backend.URL))
+	want := []ListenerAction{{ID: "https", Action: ListenerUpdated, Address: addr}}
+	if fmt.Sprint(result.Listeners) != fmt.Sprint(want) {
+		t.Errorf("listener actions = %+v, want %+v", result.Listeners, want)
+	}
+
+	if got := roundTrip(); got != http.StatusOK {
+		t.Errorf("existing connection after reload: status %d, want 200", got)
+	}
+
+	// New handshakes require a client certificate.
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	if resp, err := client.Get("https://" + addr + "/test"); err == nil {
+		resp.Body.Close()
+		t.Error("request without a client certificate succeeded after client_auth: require")
+	}
+}
+
+func TestReloadRemovesListenerWithActiveConnection(t *testing.T) {
+	arrived, release := make(chan struct{}), make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/test" {
+			return // health checks
+		}
+		close(arrived)
+		<-release
+		w.Write([]byte("done"))
+	}))
+	defer backend.Close()
+
+	a, b := freeAddr(t), freeAddr(t)
+	server := startReloadServer(t, listenerReloadConfig(t, httpListenerYAML("a", a)+httpListenerYAML("b", b), backend.URL))
+
+	type response struct {
+		status int
+		body   string
+		err    error
+	}
+	inflight := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + b + "/test")
+		if err != nil {
+			inflight <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		inflight <- response{status: resp.StatusCode, body: string(body), err: err}
+	}()
+	<-arrived
+
+	// The reload returns while the removed listener drains.
+	result := server.ReloadWithConfig(listenerReloadConfig(t, httpListenerYAML("a", a), backend.URL))
+	want := []ListenerAction{{ID: "b", Action: ListenerRemoved, Address: b, Draining: true}}
+	if fmt.Sprint(result.Listeners) != fmt.Sprint(want) {
+		t.Errorf("listener actions = %+v, want %+v", result.Listeners, want)
+	}
+	if _, ok := server.ListenerManager().Get("b"); ok {
+		t.Error("removed listener still registered")
+	}
+
+	// The removed listener stops accepting but waits for the request.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c, err := net.DialTimeout("tcp", b, 100*time.Millisecond)
+		if err != nil {
+			break
+		}
+		c.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("removed listener still accepting connections")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(release)
+	if r := <-inflight; r.err != nil || r.status != http.StatusOK || r.body != "done" {
+		t.Errorf("in-flight request = %d %q, %v; want 200 \"done\"", r.status, r.body, r.err)
+	}
+	server.drains.Wait()
+}
+
+func TestReconcileSkipsReplacedConfig(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	a, b := freeAddr(t), freeAddr(t)
+	server := startReloadServer(t, listenerReloadConfig(t, httpListenerYAML("a", a), backend.URL))
+	cfg := listenerReloadConfig(t, httpListenerYAML("a", a)+httpListenerYAML("b", b), backend.URL)
+
+	// A reload that committed after generation 1 owns the listeners.
+	server.reloadMu.Lock()
+	server.configGen = 2
+	server.reloadMu.Unlock()
+	if actions := server.reconcileListeners(cfg, 1); actions != nil {
+		t.Errorf("stale reconcile actions = %+v, want none", actions)
+	}
+	if _, ok := server.ListenerManager().Get("b"); ok {
+		t.Error("stale reconcile started a listener")
+	}
+
+	want := []ListenerAction{{ID: "b", Action: ListenerAdded, Address: b}}
+	if actions := server.reconcileListeners(cfg, 2); fmt.Sprint(actions) != fmt.Sprint(want) {
+		t.Errorf("listener actions = %+v, want %+v", actions, want)
+	}
+}
+
+func TestReloadListenerBindFailureRollsBack(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	a, c := freeAddr(t), freeAddr(t)
+	server := startReloadServer(t, listenerReloadConfig(t, httpListenerYAML("a", a), backend.URL))
+
+	// Moving a to a taken address fails and leaves it serving on its old
+	// one; adding c still succeeds, as does the route update.
+	newCfg := listenerReloadConfig(t, httpListenerYAML("a", taken.Addr().String())+httpListenerYAML("c", c), backend.URL)
+	newCfg.Routes[0].Path = "/moved"
+	result := server.ReloadWithConfig(newCfg)
+	if !result.Success {
+		t.Fatalf("reload failed: %s", result.Error)
+	}
+	if len(result.Listeners) != 2 {
+		t.Fatalf("listener actions = %+v, want 2", result.Listeners)
+	}
+	if got := result.Listeners[0]; got.ID != "a" || got.Action != ListenerFailed || got.Address != a || got.Error == "" {
+		t.Errorf("a: %+v, want failed on %s with an error", got, a)
+	}
+	if got := result.Listeners[1]; got.ID != "c" || got.Action != ListenerAdded {
+		t.Errorf("c: %+v, want added", got)
+	}
+
+	for _, addr := range []string{a, c} {
+		if got := getStatus(t, http.DefaultClient, "http://"+addr+"/moved"); got != http.StatusOK {
+			t.Errorf("%s: status %d, want 200", addr, got)
+		}
+	}
+
+	// The failed change is retried by the next reload.
+	taken.Close()
+	result = server.ReloadWithConfig(newCfg)
+	want := []ListenerAction{{ID: "a", Action: ListenerRestarted, Address: taken.Addr().String(), Draining: true}}
+	if fmt.Sprint(result.Listeners) != fmt.Sprint(want) {
+		t.Errorf("retry: listener actions = %+v, want %+v", result.Listeners, want)
+	}
+}
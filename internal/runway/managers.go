@@ -2,6 +2,7 @@ package runway
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -15,10 +16,12 @@ import (
 	"github.com/wudi/runway/internal/graphql"
 	"github.com/wudi/runway/internal/graphql/federation"
 	"github.com/wudi/runway/internal/loadbalancer/outlier"
+	"github.com/wudi/runway/internal/metrics"
 	"github.com/wudi/runway/internal/middleware/accesslog"
 	"github.com/wudi/runway/internal/middleware/ai"
 	"github.com/wudi/runway/internal/middleware/aicrawl"
 	"github.com/wudi/runway/internal/middleware/auditlog"
+	"github.com/wudi/runway/internal/middleware/decisionlog"
 	"github.com/wudi/runway/internal/middleware/auth"
 	"github.com/wudi/runway/internal/middleware/backendauth"
 	"github.com/wudi/runway/internal/middleware/backendenc"
@@ -31,6 +34,7 @@ import (
 	"github.com/wudi/runway/internal/middleware/clientmtls"
 	"github.com/wudi/runway/internal/middleware/compression"
 	"github.com/wudi/runway/internal/middleware/connect"
+	"github.com/wudi/runway/internal/middleware/concurrencylimit"
 	"github.com/wudi/runway/internal/middleware/consumergroup"
 	"github.com/wudi/runway/internal/middleware/contentneg"
 	"github.com/wudi/runway/internal/middleware/contentreplacer"
@@ -46,6 +50,7 @@ import (
 	"github.com/wudi/runway/internal/middleware/etag"
 	"github.com/wudi/runway/internal/middleware/extauth"
 	"github.com/wudi/runway/internal/middleware/fieldencrypt"
+	"github.com/wudi/runway/internal/middleware/fieldpolicy"
 	"github.com/wudi/runway/internal/middleware/fieldreplacer"
 	"github.com/wudi/runway/internal/middleware/geo"
 	"github.com/wudi/runway/internal/middleware/graphqlsub"
@@ -56,6 +61,8 @@ import (
 	"github.com/wudi/runway/internal/middleware/jmespath"
 	"github.com/wudi/runway/internal/middleware/luascript"
 	"github.com/wudi/runway/internal/middleware/maintenance"
+	"github.com/wudi/runway/internal/middleware/synthetic"
+	"github.com/wudi/runway/internal/middleware/degraded"
 	"github.com/wudi/runway/internal/middleware/mock"
 	"github.com/wudi/runway/internal/middleware/modifiers"
 	"github.com/wudi/runway/internal/middleware/nonce"
@@ -63,6 +70,7 @@ import (
 	"github.com/wudi/runway/internal/middleware/opa"
 	"github.com/wudi/runway/internal/middleware/paramforward"
 	"github.com/wudi/runway/internal/middleware/piiredact"
+	"github.com/wudi/runway/internal/middleware/problem"
 	"github.com/wudi/runway/internal/middleware/proxyratelimit"
 	"github.com/wudi/runway/internal/middleware/quota"
 	"github.com/wudi/runway/internal/middleware/ratelimit"
@@ -71,6 +79,7 @@ import (
 	"github.com/wudi/runway/internal/middleware/respbodygen"
 	"github.com/wudi/runway/internal/middleware/responselimit"
 	"github.com/wudi/runway/internal/middleware/responsesigning"
+	"github.com/wudi/runway/internal/middleware/secretheaders"
 	"github.com/wudi/runway/internal/middleware/securityheaders"
 	"github.com/wudi/runway/internal/middleware/signing"
 	"github.com/wudi/runway/internal/middleware/slo"
@@ -79,6 +88,8 @@ import (
 	"github.com/wudi/runway/internal/middleware/staticfiles"
 	"github.com/wudi/runway/internal/middleware/statusmap"
 	"github.com/wudi/runway/internal/middleware/streaming"
+	"github.com/wudi/runway/internal/middleware/spillbuf"
+	"github.com/wudi/runway/internal/middleware/streamlimit"
 	"github.com/wudi/runway/internal/middleware/tenant"
 	"github.com/wudi/runway/internal/middleware/timeout"
 	"github.com/wudi/runway/internal/middleware/tokenexchange"
@@ -88,19 +99,25 @@ import (
 	"github.com/wudi/runway/internal/middleware/waf"
 	wasmPlugin "github.com/wudi/runway/internal/middleware/wasm"
 	"github.com/wudi/runway/internal/mirror"
+	"github.com/wudi/runway/internal/peering"
 	amqpproxy "github.com/wudi/runway/internal/proxy/amqp"
+	"github.com/wudi/runway/internal/proxy/fallback"
 	fastcgiproxy "github.com/wudi/runway/internal/proxy/fastcgi"
 	grpcproxy "github.com/wudi/runway/internal/proxy/grpc"
 	lambdaproxy "github.com/wudi/runway/internal/proxy/lambda"
 	"github.com/wudi/runway/internal/proxy/aggregate"
 	"github.com/wudi/runway/internal/proxy/protocol"
 	pubsubproxy "github.com/wudi/runway/internal/proxy/pubsub"
+	kafkaproxy "github.com/wudi/runway/internal/proxy/kafka"
 	"github.com/wudi/runway/internal/proxy/sequential"
 	"github.com/wudi/runway/internal/retry"
 	"github.com/wudi/runway/internal/rules"
+	"github.com/wudi/runway/internal/storecrypt"
 	"github.com/wudi/runway/internal/trafficreplay"
 	"github.com/wudi/runway/internal/trafficshape"
 	"github.com/wudi/runway/internal/webhook"
+	"github.com/wudi/runway/internal/websocket"
+	"github.com/wudi/runway/variables"
 )
 
 // routeManagers holds all per-route and per-config-reload manager objects.
@@ -116,6 +133,9 @@ type routeManagers struct {
 	ldapAuth   *auth.LDAPAuth
 	samlAuth   *auth.SAMLAuth
 
+	// Client-facing API key lifecycle endpoints
+	keySelfService *auth.KeySelfService
+
 	// Per-route managers (ByRoute types)
 	rateLimiters      *ratelimit.RateLimitByRoute
 	circuitBreakers   *circuitbreaker.BreakerByRoute
@@ -128,6 +148,7 @@ type routeManagers struct {
 	grpcHandlers      *grpcproxy.GRPCByRoute
 	grpcReflection    *grpcproxy.ReflectionByRoute
 	translators       *protocol.TranslatorByRoute
+	handlerFallbacks  *fallback.FallbackByRoute
 	federationHandlers *federation.FederationByRoute
 	routeRules        *rules.RulesByRoute
 	throttlers        *trafficshape.ThrottleByRoute
@@ -145,6 +166,7 @@ type routeManagers struct {
 	openapiValidators *openapivalidation.OpenAPIByRoute
 	timeoutConfigs    *timeout.TimeoutByRoute
 	errorPages        *errorpages.ErrorPagesByRoute
+	errorRenderers    *problem.RendererByRoute
 	nonceCheckers     *nonce.NonceByRoute
 	csrfProtectors    *csrf.CSRFByRoute
 	outlierDetectors  *outlier.DetectorByRoute
@@ -153,15 +175,21 @@ type routeManagers struct {
 	backendSigners      *signing.SigningByRoute
 	decompressors       *decompress.DecompressorByRoute
 	responseLimiters    *responselimit.ResponseLimitByRoute
+	responseBuffers     *spillbuf.BufferingByRoute
 	securityHeaders     *securityheaders.SecurityHeadersByRoute
 	maintenanceHandlers *maintenance.MaintenanceByRoute
+	syntheticMonitors   *synthetic.SyntheticByRoute
+	degradedModes       *degraded.DegradedByRoute
 	botDetectors        *botdetect.BotDetectByRoute
 	aiCrawlControllers  *aicrawl.AICrawlByRoute
 	proxyRateLimiters   *proxyratelimit.ProxyRateLimitByRoute
 	mockHandlers        *mock.MockByRoute
 	claimsPropagators   *claimsprop.ClaimsPropByRoute
 	tokenExchangers     *tokenexchange.TokenExchangeByRoute
+	oidcAuths           *auth.OIDCByRoute
 	backendAuths        *backendauth.BackendAuthByRoute
+	wsProxies           *websocket.WebSocketByRoute
+	secretHeaders       *secretheaders.SecretHeadersByRoute
 	statusMappers       *statusmap.StatusMapByRoute
 	staticFiles         *staticfiles.StaticByRoute
 	fastcgiHandlers     *fastcgiproxy.FastCGIByRoute
@@ -169,6 +197,8 @@ type routeManagers struct {
 	contentReplacers    *contentreplacer.ContentReplacerByRoute
 	bodyGenerators      *bodygen.BodyGenByRoute
 	quotaEnforcers      *quota.QuotaByRoute
+	concurrencyLimiters *concurrencylimit.ConcurrencyLimitByRoute
+	bandwidthQuotas     *quota.BandwidthByRoute
 	sequentialHandlers  *sequential.SequentialByRoute
 	aggregateHandlers   *aggregate.AggregateByRoute
 	respBodyGenerators  *respbodygen.RespBodyGenByRoute
@@ -185,20 +215,25 @@ type routeManagers struct {
 	abTests              *abtest.ABTestByRoute
 	requestQueues        *requestqueue.RequestQueueByRoute
 	dedupHandlers        *dedup.DedupByRoute
+	contentDedups        *dedup.ContentDedupByRoute
 	ipBlocklists         *ipblocklist.BlocklistByRoute
 	clientMTLSVerifiers  *clientmtls.ClientMTLSByRoute
 	baggagePropagators   *baggage.BaggageByRoute
 	backpressureHandlers *backpressure.BackpressureByRoute
+	streamGates          *streamlimit.GateByRoute
 	auditLoggers         *auditlog.AuditLogByRoute
+	decisionLoggers      *decisionlog.DecisionLogByRoute
 	modifierChains       *modifiers.ModifiersByRoute
 	jmespathHandlers     *jmespath.JMESPathByRoute
 	fieldReplacers       *fieldreplacer.FieldReplacerByRoute
+	fieldPolicies        *fieldpolicy.FieldPolicyByRoute
 	errorHandlers        *errorhandling.ErrorHandlerByRoute
 	luaScripters         *luascript.LuaScriptByRoute
 	wasmPlugins          *wasmPlugin.WasmByRoute
 	lambdaHandlers       *lambdaproxy.LambdaByRoute
 	amqpHandlers         *amqpproxy.AMQPByRoute
 	pubsubHandlers       *pubsubproxy.PubSubByRoute
+	kafkaHandlers        *kafkaproxy.KafkaByRoute
 	trafficReplay        *trafficreplay.ReplayByRoute
 	deprecationHandlers  *deprecation.DeprecationByRoute
 	sloTrackers          *slo.SLOByRoute
@@ -215,6 +250,7 @@ type routeManagers struct {
 	// Global-scope objects that change per config reload
 	globalIPFilter   *ipfilter.Filter
 	globalBlocklist  *ipblocklist.Blocklist
+	globalErrors     *problem.Renderer // nil unless errors.format is problem_json
 	globalGeo        *geo.CompiledGeo
 	geoProvider      geo.Provider
 	globalRules      *rules.RuleEngine
@@ -223,12 +259,32 @@ type routeManagers struct {
 	realIPExtractor  *realip.CompiledRealIP
 	tenantManager    *tenant.Manager
 	budgetPools      map[string]*retry.Budget
+	peerFailover     *peering.Failover // nil when no peers are configured
+	upstreamDNS      *upstreamDNS      // SRV failover for upstreams with dns_discovery
+	storeKeys        *storecrypt.Keyring // seals distributed cache, idempotency and dedup entries; nil when unset
+	secrets          *config.SecretRegistry // runtime secret resolution for secret headers and copy policies
 	consumerGroupMgr bool // tracks if consumer group manager was set
+
+	// Count client aborts as failures in breakers and traffic analysis
+	countClientAborts bool
+
+	// Route metadata keys added to access and audit logs
+	metadataLogKeys []string
+
+	// Request metric instruments for routes that don't choose their own
+	requestMetrics config.RequestMetricsConfig
 }
 
-// newRouteManagers creates a fresh set of all per-route managers.
-func newRouteManagers(cfg *config.Config, redisClient *redis.Client) routeManagers {
-	return routeManagers{
+// newRouteManagers creates a fresh set of all per-route managers. keys seals
+// the distributed entries of routes with encrypt: true.
+func newRouteManagers(cfg *config.Config, redisClient *redis.Client, keys *storecrypt.Keyring) routeManagers {
+	// Reuse the providers the loader resolved the config with; configs
+	// built in code fall back to the built-in ones.
+	secrets := config.NewDefaultSecretRegistry(cfg.Secrets)
+	if cfg.SecretRegistry != nil {
+		secrets = cfg.SecretRegistry.Clone()
+	}
+	rm := routeManagers{
 		rateLimiters:      ratelimit.NewRateLimitByRoute(),
 		circuitBreakers:   circuitbreaker.NewBreakerByRoute(),
 		caches:            cache.NewCacheByRoute(redisClient),
@@ -240,6 +296,7 @@ func newRouteManagers(cfg *config.Config, redisClient *redis.Client) routeManage
 		grpcHandlers:      grpcproxy.NewGRPCByRoute(),
 		grpcReflection:    grpcproxy.NewReflectionByRoute(),
 		translators:       protocol.NewTranslatorByRoute(),
+		handlerFallbacks:  fallback.NewFallbackByRoute(),
 		federationHandlers: federation.NewFederationByRoute(),
 		routeRules:        rules.NewRulesByRoute(),
 		throttlers:        trafficshape.NewThrottleByRoute(),
@@ -251,29 +308,36 @@ func newRouteManagers(cfg *config.Config, redisClient *redis.Client) routeManage
 		coalescers:        coalesce.NewCoalesceByRoute(),
 		canaryControllers: canary.NewCanaryByRoute(),
 		adaptiveLimiters:  trafficshape.NewAdaptiveConcurrencyByRoute(),
-		extAuths:          extauth.NewExtAuthByRoute(),
+		extAuths:          extauth.NewExtAuthByRoute(cfg.ExtAuthServices),
 		versioners:        versioning.NewVersioningByRoute(),
 		accessLogConfigs:  accesslog.NewAccessLogByRoute(),
 		openapiValidators: openapivalidation.NewOpenAPIByRoute(),
 		timeoutConfigs:    timeout.NewTimeoutByRoute(),
 		errorPages:        errorpages.NewErrorPagesByRoute(),
+		errorRenderers:    problem.NewRendererByRoute(),
 		nonceCheckers:     nonce.NewNonceByRoute(redisClient),
 		csrfProtectors:    csrf.NewCSRFByRoute(),
 		outlierDetectors:  outlier.NewDetectorByRoute(),
 		geoFilters:        geo.NewGeoByRoute(),
-		idempotencyHandlers: idempotency.NewIdempotencyByRoute(redisClient),
+		idempotencyHandlers: idempotency.NewIdempotencyByRoute(redisClient, keys),
 		backendSigners:      signing.NewSigningByRoute(),
 		decompressors:       decompress.NewDecompressorByRoute(),
 		responseLimiters:    responselimit.NewResponseLimitByRoute(),
+		responseBuffers:     spillbuf.NewBufferingByRoute(),
 		securityHeaders:     securityheaders.NewSecurityHeadersByRoute(),
 		maintenanceHandlers: maintenance.NewMaintenanceByRoute(),
+		syntheticMonitors:   synthetic.NewSyntheticByRoute(),
+		degradedModes:       degraded.NewDegradedByRoute(),
 		botDetectors:        botdetect.NewBotDetectByRoute(),
 		aiCrawlControllers:  aicrawl.NewAICrawlByRoute(),
 		proxyRateLimiters:   proxyratelimit.NewProxyRateLimitByRoute(),
 		mockHandlers:        mock.NewMockByRoute(),
 		claimsPropagators:   claimsprop.NewClaimsPropByRoute(),
-		tokenExchangers:     tokenexchange.NewTokenExchangeByRoute(),
-		backendAuths:        backendauth.NewBackendAuthByRoute(),
+		tokenExchangers:     tokenexchange.NewTokenExchangeByRoute(cfg.TokenExchangers),
+		oidcAuths:           auth.NewOIDCByRoute(redisClient),
+		backendAuths:        backendauth.NewBackendAuthByRoute(cfg.BackendAuthProviders),
+		wsProxies:           websocket.NewWebSocketByRoute(),
+		secretHeaders:       secretheaders.NewSecretHeadersByRoute(secrets),
 		statusMappers:       statusmap.NewStatusMapByRoute(),
 		staticFiles:         staticfiles.NewStaticByRoute(),
 		fastcgiHandlers:     fastcgiproxy.NewFastCGIByRoute(),
@@ -281,6 +345,8 @@ func newRouteManagers(cfg *config.Config, redisClient *redis.Client) routeManage
 		contentReplacers:    contentreplacer.NewContentReplacerByRoute(),
 		bodyGenerators:      bodygen.NewBodyGenByRoute(),
 		quotaEnforcers:      quota.NewQuotaByRoute(redisClient),
+		concurrencyLimiters: concurrencylimit.NewConcurrencyLimitByRoute(redisClient),
+		bandwidthQuotas:     quota.NewBandwidthByRoute(redisClient),
 		sequentialHandlers:  sequential.NewSequentialByRoute(),
 		aggregateHandlers:   aggregate.NewAggregateByRoute(),
 		respBodyGenerators:  respbodygen.NewRespBodyGenByRoute(),
@@ -294,29 +360,34 @@ func newRouteManagers(cfg *config.Config, redisClient *redis.Client) routeManage
 		piiRedactors:        piiredact.NewPIIRedactByRoute(),
 		fieldEncryptors:     fieldencrypt.NewFieldEncryptByRoute(),
 		blueGreenControllers: bluegreen.NewBlueGreenByRoute(),
-		abTests:              abtest.NewABTestByRoute(),
+		abTests:              abtest.NewABTestByRoute(redisClient),
 		requestQueues:        requestqueue.NewRequestQueueByRoute(),
-		dedupHandlers:        dedup.NewDedupByRoute(redisClient),
+		dedupHandlers:        dedup.NewDedupByRoute(redisClient, keys),
+		contentDedups:        dedup.NewContentDedupByRoute(redisClient, keys),
 		ipBlocklists:         ipblocklist.NewBlocklistByRoute(),
 		clientMTLSVerifiers:  clientmtls.NewClientMTLSByRoute(),
 		baggagePropagators:   baggage.NewBaggageByRoute(),
 		backpressureHandlers: backpressure.NewBackpressureByRoute(),
+		streamGates:          streamlimit.NewGateByRoute(),
 		auditLoggers:         auditlog.NewAuditLogByRoute(),
+		decisionLoggers:      decisionlog.NewDecisionLogByRoute(),
 		modifierChains:       modifiers.NewModifiersByRoute(),
 		jmespathHandlers:     jmespath.NewJMESPathByRoute(),
 		fieldReplacers:       fieldreplacer.NewFieldReplacerByRoute(),
+		fieldPolicies:        fieldpolicy.NewFieldPolicyByRoute(),
 		errorHandlers:        errorhandling.NewErrorHandlerByRoute(),
 		luaScripters:         luascript.NewLuaScriptByRoute(),
 		wasmPlugins:          wasmPlugin.NewWasmByRoute(cfg.Wasm),
 		lambdaHandlers:       lambdaproxy.NewLambdaByRoute(),
 		amqpHandlers:         amqpproxy.NewAMQPByRoute(),
 		pubsubHandlers:       pubsubproxy.NewPubSubByRoute(),
+		kafkaHandlers:        kafkaproxy.NewKafkaByRoute(),
 		trafficReplay:        trafficreplay.NewReplayByRoute(),
 		deprecationHandlers:  deprecation.NewDeprecationByRoute(),
-		sloTrackers:          slo.NewSLOByRoute(),
+		sloTrackers:          slo.NewSLOByRoute(cfg.ClientAborts.CountAsErrors),
 		etagHandlers:         etag.NewETagByRoute(),
 		streamHandlers:       streaming.NewStreamByRoute(),
-		opaEnforcers:         opa.NewOPAByRoute(),
+		opaEnforcers:         opa.NewOPAByRoute(cfg.OPAPolicies),
 		responseSigners:      responsesigning.NewSignerByRoute(),
 		costTrackers:         costtrack.NewCostByRoute(),
 		consumerGroups:       consumergroup.NewGroupByRoute(),
@@ -324,15 +395,29 @@ func newRouteManagers(cfg *config.Config, redisClient *redis.Client) routeManage
 		connectHandlers:      connect.NewConnectByRoute(),
 		aiHandlers:           ai.NewAIByRoute(),
 		budgetPools:          make(map[string]*retry.Budget),
+		countClientAborts:    cfg.ClientAborts.CountAsErrors,
+		metadataLogKeys:      cfg.RouteMetadata.LogKeys,
+		requestMetrics:       cfg.Admin.Metrics.Requests,
+		storeKeys:            keys,
+		secrets:              secrets,
+		upstreamDNS:          newUpstreamDNS(),
 	}
+	rm.caches.SetKeyring(keys)
+	rm.mirrors.SetSecrets(secrets)
+	return rm
 }
 
 // initGlobals initializes the global singletons on routeManagers from config.
 // This is shared by both New() and buildState() to prevent divergence.
-func (rm *routeManagers) initGlobals(cfg *config.Config, redisClient *redis.Client) error {
+// tempBlocks holds the reputation blocks the global IP blocklist enforces.
+func (rm *routeManagers) initGlobals(cfg *config.Config, redisClient *redis.Client, tempBlocks *ipblocklist.TempBlocks) error {
 	// Retry budget pools
 	for name, bc := range cfg.RetryBudgets {
-		rm.budgetPools[name] = retry.NewBudget(bc.Ratio, bc.MinRetries, bc.Window)
+		if bc.Mode == "distributed" && redisClient != nil {
+			rm.budgetPools[name] = retry.NewDistributedBudget(name, bc.Ratio, bc.MinRetries, bc.Window, bc.SyncInterval, redisClient)
+		} else {
+			rm.budgetPools[name] = retry.NewBudget(bc.Ratio, bc.MinRetries, bc.Window)
+		}
 	}
 
 	// Priority admitter
@@ -359,13 +444,25 @@ func (rm *routeManagers) initGlobals(cfg *config.Config, redisClient *redis.Clie
 		}
 	}
 
-	// Global IP blocklist
-	if cfg.IPBlocklist.Enabled {
+	// Global IP blocklist; also enforces reputation blocks
+	if cfg.IPBlocklist.Enabled || cfg.Reputation.Enabled {
 		var err error
 		rm.globalBlocklist, err = ipblocklist.New(cfg.IPBlocklist)
 		if err != nil {
 			return fmt.Errorf("failed to initialize global IP blocklist: %w", err)
 		}
+		if cfg.Reputation.Enabled {
+			rm.globalBlocklist.SetTemporary(tempBlocks)
+		}
+	}
+
+	// Problem details for gateway errors outside any route
+	if cfg.Errors.Format == config.ErrorFormatProblemJSON {
+		pages, err := errorpages.New(cfg.ErrorPages, config.ErrorPagesConfig{})
+		if err != nil {
+			return fmt.Errorf("failed to initialize global error pages: %w", err)
+		}
+		rm.globalErrors = problem.New("", cfg.Errors, pages)
 	}
 
 	// Geo provider + global geo filter
@@ -389,6 +486,7 @@ func (rm *routeManagers) initGlobals(cfg *config.Config, redisClient *redis.Clie
 		if err != nil {
 			return fmt.Errorf("failed to initialize trusted proxies: %w", err)
 		}
+		rm.oidcAuths.SetTrustedProxies(rm.realIPExtractor.TrustedPeer)
 	}
 
 	// Global rules engine
@@ -405,18 +503,32 @@ func (rm *routeManagers) initGlobals(cfg *config.Config, redisClient *redis.Clie
 		rm.tokenChecker = tokenrevoke.New(cfg.TokenRevocation, redisClient)
 	}
 
+	// Peer gateways for routes with peer_failover
+	if len(cfg.PeerFailover.Peers) > 0 {
+		var err error
+		rm.peerFailover, err = peering.New(cfg.PeerFailover)
+		if err != nil {
+			return fmt.Errorf("failed to initialize peer failover: %w", err)
+		}
+	}
+
 	return nil
 }
 
 // initAuth initializes all authentication providers from config.
 // This is shared by both New() and buildState() to prevent divergence.
-func (rm *routeManagers) initAuth(cfg *config.Config) error {
+func (rm *routeManagers) initAuth(cfg *config.Config, redisClient *redis.Client, dispatcher *webhook.Dispatcher) error {
 	if cfg.Authentication.APIKey.Enabled {
 		rm.apiKeyAuth = auth.NewAPIKeyAuth(cfg.Authentication.APIKey)
 
 		if cfg.Authentication.APIKey.Management.Enabled {
 			mgmt := cfg.Authentication.APIKey.Management
-			store := auth.NewMemoryKeyStore(60 * time.Second)
+			var store auth.KeyStore
+			if mgmt.Store == "redis" && redisClient != nil {
+				store = auth.NewRedisKeyStore(redisClient)
+			} else {
+				store = auth.NewMemoryKeyStore(60 * time.Second)
+			}
 
 			var defaultRL *auth.KeyRateLimit
 			if mgmt.DefaultRateLimit != nil {
@@ -473,6 +585,20 @@ func (rm *routeManagers) initAuth(cfg *config.Config) error {
 		}
 	}
 
+	// Self-service key endpoints need both the key manager and JWT auth
+	if ss := cfg.Authentication.APIKey.Management.SelfService; ss.Enabled && rm.apiKeyAuth != nil &&
+		rm.apiKeyAuth.GetManager() != nil && rm.jwtAuth != nil {
+		rm.keySelfService = auth.NewKeySelfService(ss, rm.apiKeyAuth.GetManager(), rm.jwtAuth.Authenticate)
+		if cfg.AuditLog.Enabled {
+			rm.keySelfService.SetAuditLogger(auditlog.New("", cfg.AuditLog))
+		}
+		if dispatcher != nil {
+			rm.keySelfService.SetOnEvent(func(eventType string, data map[string]interface{}) {
+				dispatcher.Emit(webhook.NewEvent(webhook.EventType(eventType), "", data))
+			})
+		}
+	}
+
 	return nil
 }
 
@@ -480,6 +606,7 @@ func (rm *routeManagers) initAuth(cfg *config.Config) error {
 func (rm *routeManagers) cleanup() {
 	rm.translators.Close()
 	rm.extAuths.CloseAll()
+	rm.kafkaHandlers.CloseAll()
 	rm.canaryControllers.StopAll()
 	rm.blueGreenControllers.StopAll()
 	rm.adaptiveLimiters.CloseAll()
@@ -487,11 +614,20 @@ func (rm *routeManagers) cleanup() {
 	rm.outlierDetectors.StopAll()
 	rm.idempotencyHandlers.CloseAll()
 	rm.quotaEnforcers.CloseAll()
+	rm.bandwidthQuotas.CloseAll()
 	rm.backpressureHandlers.CloseAll()
 	rm.auditLoggers.CloseAll()
+	rm.decisionLoggers.CloseAll()
+	rm.mirrors.CloseAll()
 	rm.dedupHandlers.CloseAll()
+	rm.contentDedups.CloseAll()
 	rm.sseHandlers.CloseAll()
 	rm.ipBlocklists.CloseAll()
+	rm.secretHeaders.CloseAll()
+	rm.wafHandlers.CloseAll()
+	rm.tokenExchangers.CloseAll()
+	rm.openapiValidators.Close()
+	rm.upstreamDNS.stop()
 	if rm.tenantManager != nil {
 		rm.tenantManager.Close()
 	}
@@ -507,10 +643,29 @@ func (rm *routeManagers) cleanup() {
 	if rm.samlAuth != nil {
 		rm.samlAuth.Close()
 	}
+	if rm.apiKeyAuth != nil && rm.apiKeyAuth.GetManager() != nil {
+		rm.apiKeyAuth.GetManager().Close()
+	}
+	if rm.keySelfService != nil {
+		rm.keySelfService.Close()
+	}
+}
+
+// setPluginMetrics exposes the plugin metrics registry to Lua scripts and
+// WASM plugins. This is shared by New() and buildState().
+func (rm *routeManagers) setPluginMetrics(pm *metrics.PluginMetrics) {
+	rm.luaScripters.SetPluginMetrics(pm)
+	rm.wasmPlugins.SetPluginMetrics(pm)
+}
+
+// setObserveMetrics counts the requests observe-mode spike arresters would
+// have rejected. This is shared by New() and buildState().
+func (rm *routeManagers) setObserveMetrics(mc *metrics.Collector) {
+	rm.spikeArresters.SetOnObservedReject(mc.RecordSpikeArrestObservedReject)
 }
 
 // wireWebhookCallbacks sets up event callbacks on circuit breakers, canary controllers,
-// and outlier detectors to emit webhook events. This is shared by New() and buildState().
+// A/B tests, degraded mode controllers, outlier detectors and schema drift digests to emit webhook events. This is shared by New() and buildState().
 func (rm *routeManagers) wireWebhookCallbacks(dispatcher *webhook.Dispatcher) {
 	if dispatcher == nil {
 		return
@@ -523,6 +678,26 @@ func (rm *routeManagers) wireWebhookCallbacks(dispatcher *webhook.Dispatcher) {
 	rm.canaryControllers.SetOnEvent(func(routeID, eventType string, data map[string]interface{}) {
 		dispatcher.Emit(webhook.NewEvent(webhook.EventType(eventType), routeID, data))
 	})
+	rm.abTests.SetOnExposure(func(r *http.Request, routeID string, data map[string]interface{}) {
+		if variables.IsInternal(r) {
+			return
+		}
+		ev := webhook.NewEvent(webhook.ABTestExposure, routeID, data)
+		ev.Shadow = variables.IsShadow(r)
+		dispatcher.Emit(ev)
+	})
+	rm.degradedModes.SetOnTransition(func(routeID, from, to, reason string) {
+		eventType := webhook.DegradedModeExited
+		if to == degraded.StateDegraded {
+			eventType = webhook.DegradedModeEntered
+		}
+		dispatcher.Emit(webhook.NewEvent(eventType, routeID, map[string]interface{}{
+			"from": from, "to": to, "reason": reason,
+		}))
+	})
+	rm.openapiValidators.SetOnDriftDigest(func(routeID string, data map[string]interface{}) {
+		dispatcher.Emit(webhook.NewEvent(webhook.SchemaDriftDigest, routeID, data))
+	})
 	rm.outlierDetectors.SetCallbacks(
 		func(routeID, backend, reason string) {
 			dispatcher.Emit(webhook.NewEvent(webhook.OutlierEjected, routeID, map[string]interface{}{
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -14,22 +16,27 @@ import (
 	"github.com/wudi/runway/internal/cache"
 	"github.com/wudi/runway/internal/circuitbreaker"
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/decision"
 	"github.com/wudi/runway/internal/errors"
 	"github.com/wudi/runway/internal/logging"
 	"github.com/wudi/runway/internal/loadbalancer"
 	"github.com/wudi/runway/internal/metrics"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/auth"
 	"github.com/wudi/runway/internal/middleware/bufutil"
+	"github.com/wudi/runway/internal/middleware/consumergroup"
 	"github.com/wudi/runway/internal/middleware/geo"
 	"github.com/wudi/runway/internal/middleware/ipblocklist"
 	"github.com/wudi/runway/internal/middleware/ipfilter"
 	openapivalidation "github.com/wudi/runway/internal/middleware/openapi"
+	"github.com/wudi/runway/internal/middleware/streamlimit"
 	"github.com/wudi/runway/internal/middleware/tenant"
 	"github.com/wudi/runway/internal/middleware/transform"
 	"github.com/wudi/runway/internal/middleware/validation"
 	grpcproxy "github.com/wudi/runway/internal/proxy/grpc"
 	"github.com/wudi/runway/internal/router"
 	"github.com/wudi/runway/internal/rules"
+	"github.com/wudi/runway/internal/simulate"
 	"github.com/wudi/runway/internal/trafficshape"
 	"github.com/wudi/runway/variables"
 	"github.com/wudi/runway/internal/websocket"
@@ -102,21 +109,107 @@ func geoMW(global *geo.CompiledGeo, route *geo.CompiledGeo) middleware.Middlewar
 }
 
 // 3. authMW authenticates requests using the gateway's auth providers.
-func authMW(g *Runway, cfg router.RouteAuth) middleware.Middleware {
+// When reqs is non-nil, the authenticated identity must also satisfy the
+// route's auth.requirements; otherwise the request gets a 403. oidc is the
+// route's OIDC relying party, if any.
+func authMW(g *Runway, routeID string, cfg router.RouteAuth, reqs *auth.Requirements, oidc *auth.OIDCAuth) middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if variables.GetFromRequest(r).SkipFlags&variables.SkipAuth != 0 {
+			varCtx := variables.GetFromRequest(r)
+			rec := decision.FromContext(r.Context())
+			if varCtx.SkipFlags&variables.SkipAuth != 0 {
+				if rec != nil {
+					rec.Add("auth", decision.Allow, "skipped", "rule")
+				}
 				next.ServeHTTP(w, r)
 				return
 			}
-			if !g.authenticate(w, r, cfg.Methods) {
+			// A simulation may assume an identity instead of authenticating.
+			simulated := simulate.Active(r.Context())
+			if simulated && varCtx.Identity != nil {
+				simulate.Note(r.Context(), "authentication skipped: assumed identity %q", varCtx.Identity.ClientID)
+			} else if !g.authenticate(w, r, cfg.Methods, oidc) {
+				if rec != nil {
+					rec.Add("auth", decision.Deny, "methods", strings.Join(cfg.Methods, ","))
+				}
+				logging.DebugContext(r.Context(), "Authentication failed",
+					zap.String("route", routeID),
+					zap.Strings("methods", cfg.Methods))
 				return
+			} else if simulated {
+				simulate.Note(r.Context(), "authenticated %q via %s", varCtx.Identity.ClientID, varCtx.Identity.AuthType)
+			}
+			if varCtx.Identity != nil && g.debugTraces.Active() {
+				// Sessions tracing a client start once it is authenticated.
+				r = g.debugTraces.TraceClient(w, r, varCtx.Identity.ClientID)
+				rec = decision.FromContext(r.Context())
+			}
+			if reqs != nil {
+				if unmet := reqs.Check(r.Method, varCtx.Identity); unmet != nil {
+					if !simulated {
+						g.metricsCollector.RecordAuthzDenied(routeID, unmet.Requirement)
+					}
+					simulate.Note(r.Context(), "requirement %q not met", unmet.Requirement)
+					logging.DebugContext(r.Context(), "Authorization requirement not met",
+						zap.String("route", routeID),
+						zap.String("requirement", unmet.Requirement))
+					if rec != nil {
+						rec.Add("auth", decision.Deny, "requirement", unmet.Requirement)
+					}
+					variables.SetErrorReason(r, errors.ReasonInsufficientPermissions)
+					writeAuthzDenied(w, unmet, reqs.Verbose())
+					return
+				}
+			}
+			if rec != nil {
+				recordAuthDecision(rec, varCtx.Identity)
 			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// recordAuthDecision records a successful authentication: the method, the
+// client and the names of the identity's claims, with the client ID and
+// subject hashed.
+func recordAuthDecision(rec *decision.Recorder, id *variables.Identity) {
+	if id == nil {
+		rec.Add("auth", decision.Allow)
+		return
+	}
+	claims := make([]string, 0, len(id.Claims))
+	for name := range id.Claims {
+		claims = append(claims, name)
+	}
+	sort.Strings(claims)
+	kv := []string{"method", id.AuthType, "client", rec.Hash(id.ClientID)}
+	if len(claims) > 0 {
+		kv = append(kv, "claims", strings.Join(claims, ","))
+	}
+	if sub, ok := id.Claims["sub"].(string); ok {
+		kv = append(kv, "subject", rec.Hash(sub))
+	}
+	rec.Add("auth", decision.Allow, kv...)
+}
+
+// writeAuthzDenied writes the 403 for an unmet auth requirement. Verbose
+// responses name the requirement and what would have satisfied it.
+func writeAuthzDenied(w http.ResponseWriter, unmet *auth.Unmet, verbose bool) {
+	if !verbose {
+		errors.ErrForbidden.WithDetails("Insufficient permissions").WriteJSON(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(struct {
+		Code        int      `json:"code"`
+		Message     string   `json:"message"`
+		Details     string   `json:"details"`
+		Requirement string   `json:"requirement"`
+		Missing     []string `json:"missing"`
+	}{http.StatusForbidden, "Forbidden", unmet.Error(), unmet.Requirement, unmet.Missing})
+}
+
 // 4. requestRulesMW evaluates global then per-route request rules.
 func requestRulesMW(global, route *rules.RuleEngine) middleware.Middleware {
 	return func(next http.Handler) http.Handler {
@@ -144,7 +237,20 @@ func evaluateRequestRules(engine *rules.RuleEngine, w http.ResponseWriter, r *ht
 	}
 	reqEnv := rules.AcquireRequestEnv(r, varCtx)
 	defer rules.ReleaseRequestEnv(reqEnv)
+	if engine.UsesBody() && varCtx != nil {
+		reqEnv.Body = varCtx.BodyValue()
+	}
+	simulated := simulate.Active(r.Context())
+	rec := decision.FromContext(r.Context())
 	for _, result := range engine.EvaluateRequest(reqEnv) {
+		simulate.Match(r.Context(), result.RuleID)
+		if rec != nil {
+			rec.Add("rules", decision.Match, "rule", result.RuleID, "action", result.Action.Type)
+		}
+		if simulated && simulatedNoopActions[result.Action.Type] {
+			simulate.Note(r.Context(), "rule %q: %s action not run", result.RuleID, result.Action.Type)
+			continue
+		}
 		if result.Terminated {
 			rules.ExecuteTerminatingAction(w, r, result.Action)
 			return r, true
@@ -192,6 +298,14 @@ func evaluateRequestRules(engine *rules.RuleEngine, w http.ResponseWriter, r *ht
 	return r, false
 }
 
+// simulatedNoopActions are request rule actions with effects outside the
+// request, not run for simulated requests.
+var simulatedNoopActions = map[string]bool{
+	"log":   true,
+	"delay": true,
+	"lua":   true,
+}
+
 // skipFlagMap maps action type strings to SkipFlags constants.
 var skipFlagMap = map[string]variables.SkipFlags{
 	"skip_auth":                 variables.SkipAuth,
@@ -241,16 +355,28 @@ func bodyLimitMW(max int64) middleware.Middleware {
 }
 
 // 8. websocketMW upgrades WebSocket requests; non-WS requests pass through.
-func websocketMW(wsProxy *websocket.Proxy, getBalancer func() loadbalancer.Balancer) middleware.Middleware {
+func websocketMW(wsProxy *websocket.Proxy, getBalancer func() loadbalancer.Balancer, gate *streamlimit.Gate) middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if websocket.IsUpgradeRequest(r) {
-				backend := getBalancer().Next()
-				if backend == nil {
+				var backendURL string
+				if gate != nil {
+					picked, release, ok := gate.Pick(getBalancer(), "", 0)
+					if !ok {
+						gate.Reject(w)
+						return
+					}
+					defer release()
+					backendURL = picked
+				} else if backend := getBalancer().Next(); backend != nil {
+					backendURL = backend.URL
+				}
+				if backendURL == "" {
+					variables.SetErrorReason(r, errors.ReasonNoHealthyBackends)
 					errors.ErrServiceUnavailable.WithDetails("No healthy backends available").WriteJSON(w)
 					return
 				}
-				wsProxy.ServeHTTP(w, r, backend.URL)
+				wsProxy.ServeHTTP(w, r, backendURL)
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -258,14 +384,38 @@ func websocketMW(wsProxy *websocket.Proxy, getBalancer func() loadbalancer.Balan
 	}
 }
 
+// wsUpgradeGuardMW runs inner and calls record when it rejects a WebSocket
+// upgrade request instead of passing it on.
+func wsUpgradeGuardMW(inner middleware.Middleware, record func()) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		h := inner(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !websocket.IsUpgradeRequest(r) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			passed := false
+			inner(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				passed = true
+				next.ServeHTTP(w, r)
+			})).ServeHTTP(w, r)
+			if !passed {
+				record()
+			}
+		})
+	}
+}
+
 // 9. cacheMW handles both cache HIT (early return) and MISS (wrap writer, store after proxy).
 // Supports stale-while-revalidate (serve stale + background refresh) and
-// stale-if-error (serve stale when backend returns 5xx).
+// stale-if-error (serve stale when backend returns 5xx or exceeds the soft
+// timeout).
 func cacheMW(h *cache.Handler, mc *metrics.Collector, routeID string) middleware.Middleware {
 	conditional := h.IsConditional()
 	hasStale := h.HasStaleSupport()
 	swr := h.StaleWhileRevalidate()
 	sie := h.StaleIfError()
+	soft := h.SoftTimeout()
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -274,6 +424,9 @@ func cacheMW(h *cache.Handler, mc *metrics.Collector, routeID string) middleware
 				if hasStale {
 					key := h.KeyForRequest(r)
 					entry, fresh, stale := h.GetWithStaleness(key)
+					if entry != nil {
+						h.RecordNormalizedHit(r)
+					}
 
 					if entry != nil && fresh {
 						// Fresh cache hit — serve as normal
@@ -287,12 +440,12 @@ func cacheMW(h *cache.Handler, mc *metrics.Collector, routeID string) middleware
 					}
 
 					if entry != nil && stale {
-						age := time.Since(entry.StoredAt)
+						age := h.Age(entry)
 
 						// stale-while-revalidate: serve stale immediately, revalidate in background
-						if swr > 0 && age <= h.TTL()+swr {
+						if swr > 0 && age <= h.EntryTTL(entry)+swr {
 							mc.RecordCacheHit(routeID)
-							writeStaleResponse(w, r, entry, conditional)
+							writeStaleResponse(w, r, entry, conditional, "STALE")
 
 							// Trigger background revalidation (deduped by key)
 							if !h.IsRevalidating(key) {
@@ -306,7 +459,11 @@ func cacheMW(h *cache.Handler, mc *metrics.Collector, routeID string) middleware
 
 						// Entry is stale but within stale-if-error window — proceed
 						// to backend but fall back to stale if backend fails.
-						if sie > 0 && age <= h.TTL()+sie {
+						if sie > 0 && age <= h.EntryTTL(entry)+sie {
+							if soft > 0 {
+								serveStaleOnTimeout(w, r, next, h, mc, routeID, key, entry, soft, conditional)
+								return
+							}
 							capWriter := cache.AcquireCapturingResponseWriter()
 							defer cache.ReleaseCapturingResponseWriter(capWriter)
 							next.ServeHTTP(capWriter, r)
@@ -314,7 +471,7 @@ func cacheMW(h *cache.Handler, mc *metrics.Collector, routeID string) middleware
 							if capWriter.StatusCode() >= 500 {
 								// Backend error — serve stale entry instead
 								mc.RecordCacheHit(routeID)
-								writeStaleResponse(w, r, entry, conditional)
+								writeStaleResponse(w, r, entry, conditional, "STALE")
 								return
 							}
 
@@ -343,6 +500,13 @@ func cacheMW(h *cache.Handler, mc *metrics.Collector, routeID string) middleware
 			// Invalidate cache on mutating requests
 			if cache.IsMutatingMethod(r.Method) {
 				h.InvalidateByPath(r.URL.Path)
+				if !shouldCache && h.InvalidatesOnWrite() {
+					rec := getStatusRecorder(w)
+					defer putStatusRecorder(rec)
+					next.ServeHTTP(rec, r)
+					h.InvalidateAfterWrite(r, rec.StatusCode())
+					return
+				}
 			}
 
 			// Wrap writer for cache capture on cacheable requests
@@ -359,12 +523,13 @@ func cacheMW(h *cache.Handler, mc *metrics.Collector, routeID string) middleware
 						entry, _, stale := h.GetWithStaleness(key)
 						if stale && entry != nil {
 							mc.RecordCacheHit(routeID)
-							writeStaleResponse(w, r, entry, conditional)
+							writeStaleResponse(w, r, entry, conditional, "STALE")
 							return
 						}
 					}
 
 					// Write captured response and store
+					h.RecordMiss(capWriter.StatusCode())
 					writeCapturedAndStore(w, r, capWriter, h, key, conditional)
 					return
 				}
@@ -379,12 +544,13 @@ func cacheMW(h *cache.Handler, mc *metrics.Collector, routeID string) middleware
 				defer cache.ReleaseCachingResponseWriter(cachingWriter)
 				cachingWriter.Header().Set("X-Cache", "MISS")
 				next.ServeHTTP(cachingWriter, r)
+				h.RecordMiss(cachingWriter.StatusCode())
 
 				// Store if response is cacheable (check skip flag again — may be set by response rules)
 				if varCtx.SkipFlags&variables.SkipCacheStore == 0 &&
 					h.ShouldStore(cachingWriter.StatusCode(), cachingWriter.Header(), int64(cachingWriter.Body.Len())) {
 					entry := buildCacheEntry(cachingWriter.StatusCode(), cachingWriter.Header(), cachingWriter.Body.Bytes(), conditional)
-					storeCacheEntry(h, h.KeyForRequest(r), r.URL.Path, entry, varCtx)
+					storeCacheEntry(h, r, h.KeyForRequest(r), entry, varCtx)
 				}
 				return
 			}
@@ -394,10 +560,12 @@ func cacheMW(h *cache.Handler, mc *metrics.Collector, routeID string) middleware
 	}
 }
 
-// writeStaleResponse writes a stale cached entry to the response writer with X-Cache: STALE.
-func writeStaleResponse(w http.ResponseWriter, r *http.Request, entry *cache.Entry, conditional bool) {
+// writeStaleResponse writes a stale cached entry to the response writer with
+// the given X-Cache value.
+func writeStaleResponse(w http.ResponseWriter, r *http.Request, entry *cache.Entry, conditional bool, xCache string) {
 	bufutil.CopyHeaders(w.Header(), entry.Headers)
-	w.Header().Set("X-Cache", "STALE")
+	w.Header().Set("X-Cache", xCache)
+	variables.GetFromRequest(r).Relayed = true
 
 	if conditional {
 		if entry.ETag != "" {
@@ -417,6 +585,97 @@ func writeStaleResponse(w http.ResponseWriter, r *http.Request, entry *cache.Ent
 	w.Write(entry.Body)
 }
 
+// serveStaleOnTimeout waits up to soft for the backend. If it is slower, the
+// stale entry is served with X-Cache: STALE-TIMEOUT and the backend request
+// finishes in the background to refresh the cache. One refresh per key runs
+// in the background at a time; requests arriving meanwhile get the stale
+// entry without waiting. Concurrent backend calls made before the soft
+// timeout are shared by the coalesce middleware when it is enabled.
+func serveStaleOnTimeout(w http.ResponseWriter, r *http.Request, next http.Handler, h *cache.Handler, mc *metrics.Collector, routeID, key string, entry *cache.Entry, soft time.Duration, conditional bool) {
+	if h.RevalidationPending(key) {
+		mc.RecordCacheStaleOnTimeout(routeID)
+		writeStaleTimeoutResponse(w, r, entry, conditional)
+		return
+	}
+
+	// The backend call may outlive the client request, so it gets its own
+	// request, variables and capture buffer. It keeps the route deadline but
+	// not the client's cancellation.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	if deadline, ok := r.Context().Deadline(); ok {
+		cancel()
+		ctx, cancel = context.WithDeadline(context.WithoutCancel(r.Context()), deadline)
+	}
+	varCtx := variables.GetFromRequest(r)
+	bgVarCtx := varCtx.Clone()
+	bgReq := r.Clone(context.WithValue(ctx, variables.RequestContextKey{}, bgVarCtx))
+	bgVarCtx.Request = bgReq
+	capWriter := cache.AcquireCapturingResponseWriter()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		next.ServeHTTP(capWriter, bgReq)
+	}()
+
+	timer := time.NewTimer(soft)
+	select {
+	case <-done:
+		timer.Stop()
+		cancel()
+		varCtx.UpstreamAddr = bgVarCtx.UpstreamAddr
+		varCtx.UpstreamStatus = bgVarCtx.UpstreamStatus
+		varCtx.UpstreamResponseTime = bgVarCtx.UpstreamResponseTime
+		varCtx.ServedByPeer = bgVarCtx.ServedByPeer
+		varCtx.SkipFlags = bgVarCtx.SkipFlags
+		varCtx.ErrorReason = bgVarCtx.ErrorReason
+		variables.ReleaseContext(bgVarCtx)
+		defer cache.ReleaseCapturingResponseWriter(capWriter)
+
+		if capWriter.StatusCode() >= 500 {
+			mc.RecordCacheHit(routeID)
+			writeStaleResponse(w, r, entry, conditional, "STALE")
+			return
+		}
+		writeCapturedAndStore(w, r, capWriter, h, key, conditional)
+		return
+	case <-timer.C:
+	}
+
+	mc.RecordCacheStaleOnTimeout(routeID)
+	writeStaleTimeoutResponse(w, r, entry, conditional)
+
+	owner := !h.IsRevalidating(key)
+	go func() {
+		<-done
+		defer cancel()
+		defer cache.ReleaseCapturingResponseWriter(capWriter)
+		defer variables.ReleaseContext(bgVarCtx)
+		if !owner {
+			return
+		}
+		defer h.DoneRevalidating(key)
+
+		outcome := "not_cacheable"
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			outcome = "timeout"
+		case capWriter.StatusCode() >= 500:
+			outcome = "error"
+		case bgVarCtx.SkipFlags&variables.SkipCacheStore == 0 &&
+			h.ShouldStore(capWriter.StatusCode(), capWriter.Header(), int64(capWriter.Body.Len())):
+			storeCacheEntry(h, bgReq, key, buildCacheEntry(capWriter.StatusCode(), capWriter.Header(), capWriter.Body.Bytes(), conditional), bgVarCtx)
+			outcome = "refreshed"
+		}
+		mc.RecordCacheStaleRefresh(routeID, outcome)
+	}()
+}
+
+// writeStaleTimeoutResponse serves a stale entry in place of a slow backend.
+func writeStaleTimeoutResponse(w http.ResponseWriter, r *http.Request, entry *cache.Entry, conditional bool) {
+	w.Header().Set("Warning", `110 - "Response is Stale"`)
+	writeStaleResponse(w, r, entry, conditional, "STALE-TIMEOUT")
+}
+
 // writeCapturedAndStore writes a captured response to the client with X-Cache: MISS,
 // and stores it in the cache if the response is cacheable.
 func writeCapturedAndStore(w http.ResponseWriter, r *http.Request, capWriter *cache.CapturingResponseWriter, h *cache.Handler, key string, conditional bool) {
@@ -429,7 +688,7 @@ func writeCapturedAndStore(w http.ResponseWriter, r *http.Request, capWriter *ca
 	if varCtx.SkipFlags&variables.SkipCacheStore == 0 &&
 		h.ShouldStore(capWriter.StatusCode(), capWriter.Header(), int64(capWriter.Body.Len())) {
 		entry := buildCacheEntry(capWriter.StatusCode(), capWriter.Header(), capWriter.Body.Bytes(), conditional)
-		storeCacheEntry(h, key, r.URL.Path, entry, varCtx)
+		storeCacheEntry(h, r, key, entry, varCtx)
 	}
 }
 
@@ -445,16 +704,17 @@ func revalidateInBackground(h *cache.Handler, next http.Handler, origReq *http.R
 	// Only store successful responses
 	if h.ShouldStore(capWriter.StatusCode(), capWriter.Header(), int64(capWriter.Body.Len())) {
 		entry := buildCacheEntry(capWriter.StatusCode(), capWriter.Header(), capWriter.Body.Bytes(), conditional)
-		h.StoreWithMeta(key, origReq.URL.Path, entry)
+		h.StoreForRequest(bgReq, key, entry)
 	}
 }
 
-// buildCacheEntry creates a cache.Entry from captured response data.
+// buildCacheEntry creates a cache.Entry from captured response data. The
+// capture buffers go back to their pools, so the entry gets its own body.
 func buildCacheEntry(statusCode int, headers http.Header, body []byte, conditional bool) *cache.Entry {
 	entry := &cache.Entry{
 		StatusCode: statusCode,
 		Headers:    headers.Clone(),
-		Body:       body,
+		Body:       bytes.Clone(body),
 	}
 	if conditional {
 		cache.PopulateConditionalFields(entry)
@@ -462,19 +722,21 @@ func buildCacheEntry(statusCode int, headers http.Header, body []byte, condition
 	return entry
 }
 
-// storeCacheEntry applies optional TTL override and stores the entry.
-func storeCacheEntry(h *cache.Handler, key, path string, entry *cache.Entry, varCtx *variables.Context) {
+// storeCacheEntry applies optional TTL override and stores the entry as the
+// response to r.
+func storeCacheEntry(h *cache.Handler, r *http.Request, key string, entry *cache.Entry, varCtx *variables.Context) {
 	if varCtx != nil && varCtx.Overrides != nil && varCtx.Overrides.CacheTTLOverride > 0 {
 		entry.TTL = varCtx.Overrides.CacheTTLOverride
 	}
-	h.StoreWithMeta(key, path, entry)
+	h.StoreForRequest(r, key, entry)
 }
 
 var errServerError = fmt.Errorf("server error")
 
 // 10. circuitBreakerMW checks the circuit breaker and records outcomes.
 // If the breaker supports tenant isolation, requests are routed to per-tenant breakers.
-func circuitBreakerMW(cb circuitbreaker.BreakerInterface, isGRPC bool) middleware.Middleware {
+// Requests abandoned by the client count as successes unless countAborts is set.
+func circuitBreakerMW(cb circuitbreaker.BreakerInterface, isGRPC, countAborts bool) middleware.Middleware {
 	tenantCB, isTenantAware := cb.(circuitbreaker.TenantAwareBreakerInterface)
 
 	return func(next http.Handler) http.Handler {
@@ -495,6 +757,7 @@ func circuitBreakerMW(cb circuitbreaker.BreakerInterface, isGRPC bool) middlewar
 				done, err = cb.Allow()
 			}
 			if err != nil {
+				variables.SetErrorReason(r, errors.ReasonCircuitOpen)
 				errors.ErrServiceUnavailable.WithDetails("Circuit breaker is open").WriteJSON(w)
 				return
 			}
@@ -510,6 +773,12 @@ func circuitBreakerMW(cb circuitbreaker.BreakerInterface, isGRPC bool) middlewar
 					cbStatus = 500
 				}
 			}
+			if variables.GetFromRequest(r).ClientAbort != variables.AbortNone {
+				cbStatus = http.StatusOK
+				if countAborts {
+					cbStatus = http.StatusBadGateway
+				}
+			}
 			if cbStatus >= 500 {
 				done(errServerError)
 			} else {
@@ -530,6 +799,7 @@ func adaptiveConcurrencyMW(al *trafficshape.AdaptiveLimiter) middleware.Middlewa
 			}
 			release, ok := al.Allow()
 			if !ok {
+				variables.SetErrorReason(r, errors.ReasonConcurrencyLimited)
 				errors.ErrServiceUnavailable.WithDetails("Adaptive concurrency limit reached").WriteJSON(w)
 				return
 			}
@@ -693,41 +963,128 @@ func requestTransformMW(route *router.Route, grpcH *grpcproxy.Handler, reqBodyTr
 	}
 }
 
-// 16. metricsMW records request metrics (timing + status).
-func metricsMW(mc *metrics.Collector, routeID string) middleware.Middleware {
+// 16. metricsMW records request metrics (timing + status). The route's
+// instruments are chosen here, once per build.
+func metricsMW(mc *metrics.Collector, routeID string, opts metrics.RouteOptions) middleware.Middleware {
+	requests := mc.Route(routeID, opts)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			rec := getStatusRecorder(w)
 			next.ServeHTTP(rec, r)
-			mc.RecordRequest(routeID, r.Method, rec.statusCode, time.Since(start))
+			varCtx := variables.GetFromRequest(r)
+			if varCtx.Synthetic {
+				mc.RecordSyntheticRequest(routeID, rec.statusCode, time.Since(start))
+			} else if !varCtx.Internal {
+				requests.RecordRequest(r.Method, varCtx.RecordedStatus(rec.statusCode), time.Since(start))
+				if varCtx.ClientAbort != variables.AbortNone {
+					mc.RecordClientAbort(routeID, varCtx.ClientAbort.String())
+				}
+			}
 			putStatusRecorder(rec)
 		})
 	}
 }
 
-// varContextMW sets RouteID on the variable context.
+// routeMetricsOptions converts a route's effective metrics config.
+func routeMetricsOptions(cfg config.RequestMetricsConfig) metrics.RouteOptions {
+	return metrics.RouteOptions{
+		Mode:        cfg.Mode,
+		StatusClass: cfg.Status == config.MetricsStatusClass,
+	}
+}
+
+// varContextMW sets RouteID and the route metadata on the variable context,
+// and adds the route's exposed metadata response headers.
 // PathParams are already set by serveHTTP before the handler chain runs.
-func varContextMW(routeID string) middleware.Middleware {
+// A positive bodyFieldsLimit enables lazily parsed JSON body fields for
+// routes whose features reference them (see routeBodyFieldsLimit).
+func varContextMW(routeID string, bodyFieldsLimit int64, md routeMetadata) middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			varCtx := variables.GetFromRequest(r)
 			varCtx.RouteID = routeID
+			varCtx.RouteMetadata = md.all
+			varCtx.LogMetadata = md.log
+			for _, h := range md.headers {
+				w.Header().Set(h.name, h.value)
+			}
+			if bodyFieldsLimit > 0 {
+				variables.EnableBodyFields(r, bodyFieldsLimit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// multipartFieldsMW captures the route's multipart_fields from the start of
+// multipart/form-data uploads into the variable context, for tenant keys,
+// validation and $form_<name> variables. The scanned prefix is replayed
+// ahead of the unread stream, so uploads are forwarded without buffering.
+// With on_limit: reject, requests whose fields were not all found within
+// max_scan_bytes get a 400.
+func multipartFieldsMW(cfg config.MultipartFieldsConfig) middleware.Middleware {
+	scan := variables.MultipartScan{
+		Fields:       cfg.Fields,
+		MaxScan:      cfg.MaxScanBytes,
+		MaxFieldSize: cfg.MaxFieldSize,
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := variables.ScanMultipart(r, scan); err != nil && cfg.OnLimit == "reject" {
+				errors.ErrBadRequest.WithDetails("Multipart form fields not found within the scan limit").WriteJSON(w)
+				return
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// routeBodyFieldsLimit returns the size cap for JSON body fields on a route,
+// or 0 when no feature on the route references them, so such routes never
+// buffer or parse the body for them. Body fields are referenced by rules
+// reading body.*, a body:<path> rate limit key, $body.<path> request header
+// templates and body modifier conditions. The cap is the route's
+// max_body_size when smaller than variables.DefaultMaxBodyFieldsSize.
+func routeBodyFieldsLimit(cfg config.RouteConfig, globalRules, routeRules *rules.RuleEngine) int64 {
+	if cfg.Passthrough {
+		return 0
+	}
+	uses := strings.HasPrefix(cfg.RateLimit.Key, "body:") ||
+		(globalRules != nil && globalRules.UsesBody()) ||
+		(routeRules != nil && routeRules.UsesBody())
+	for _, v := range cfg.Transform.Request.Headers.Add {
+		uses = uses || variables.ReferencesBody(v)
+	}
+	for _, v := range cfg.Transform.Request.Headers.Set {
+		uses = uses || variables.ReferencesBody(v)
+	}
+	for _, m := range cfg.Modifiers {
+		uses = uses || (m.Condition != nil && m.Condition.Type == "body")
+	}
+	if !uses {
+		return 0
+	}
+	if cfg.MaxBodySize > 0 && cfg.MaxBodySize < variables.DefaultMaxBodyFieldsSize {
+		return cfg.MaxBodySize
+	}
+	return variables.DefaultMaxBodyFieldsSize
+}
+
 // 18. priorityMW enforces priority-based admission control.
 func priorityMW(admitter *trafficshape.PriorityAdmitter, cfg config.PriorityConfig) middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			varCtx := variables.GetFromRequest(r)
-			var tenantPriority int
+			// A tenant's priority takes precedence over its consumer group's.
+			var fixedPriority int
 			if ti := tenant.FromContext(r.Context()); ti != nil {
-				tenantPriority = ti.Config.Priority
+				fixedPriority = ti.Config.Priority
 			}
-			level := trafficshape.DetermineLevel(r, varCtx.Identity, cfg, tenantPriority)
+			if gi := consumergroup.FromContext(r.Context()); fixedPriority == 0 && gi != nil && gi.Group != nil {
+				fixedPriority = gi.Group.Priority
+			}
+			level := trafficshape.DetermineLevel(r, varCtx.Identity, cfg, fixedPriority)
 			if varCtx.Overrides != nil && varCtx.Overrides.PriorityOverride > 0 {
 				level = varCtx.Overrides.PriorityOverride
 			}
@@ -741,6 +1098,7 @@ func priorityMW(admitter *trafficshape.PriorityAdmitter, cfg config.PriorityConf
 
 			release, err := admitter.Admit(ctx, level)
 			if err != nil {
+				consumergroup.CountRejection(r, consumergroup.LimitPriority)
 				errors.ErrServiceUnavailable.WithDetails("Priority admission timeout").WriteJSON(w)
 				return
 			}
@@ -757,14 +1115,21 @@ type trafficRecorder interface {
 }
 
 // trafficObserverMW records per-traffic-group outcomes for traffic analysis.
-func trafficObserverMW(rec trafficRecorder) middleware.Middleware {
+// Requests abandoned by the client are skipped unless countAborts is set, in
+// which case they are recorded as failed requests.
+func trafficObserverMW(rec trafficRecorder, countAborts bool) middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			sr := getStatusRecorder(w)
 			next.ServeHTTP(sr, r)
 			if varCtx := variables.GetFromRequest(r); varCtx.TrafficGroup != "" {
-				rec.RecordRequest(varCtx.TrafficGroup, sr.statusCode, time.Since(start))
+				switch {
+				case varCtx.ClientAbort == variables.AbortNone:
+					rec.RecordRequest(varCtx.TrafficGroup, sr.statusCode, time.Since(start))
+				case countAborts:
+					rec.RecordRequest(varCtx.TrafficGroup, http.StatusBadGateway, time.Since(start))
+				}
 			}
 			putStatusRecorder(sr)
 		})
@@ -777,6 +1142,22 @@ func skipFlagMW(flag variables.SkipFlags, inner middleware.Middleware) middlewar
 		h := inner(next)
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if variables.GetFromRequest(r).SkipFlags&flag != 0 {
+				simulate.Note(r.Context(), "skipped by a rule action")
+				next.ServeHTTP(w, r)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// grpcBypassMW skips inner for gRPC and gRPC-Web requests, whose responses
+// must reach the client byte-for-byte with their trailers intact.
+func grpcBypassMW(inner middleware.Middleware) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		h := inner(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -796,6 +1177,7 @@ func openapiRequestMW(ov *openapivalidation.CompiledOpenAPI) middleware.Middlewa
 					next.ServeHTTP(w, r)
 					return
 				}
+				variables.SetErrorReason(r, errors.ReasonValidationFailed)
 				validation.RejectValidation(w, err)
 				return
 			}
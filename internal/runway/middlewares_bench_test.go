@@ -15,7 +15,7 @@ import (
 )
 
 func BenchmarkVarContextMW(b *testing.B) {
-	mw := varContextMW("bench-route")
+	mw := varContextMW("bench-route", 0, routeMetadata{})
 	handler := mw(ok200())
 
 	baseReq := httptest.NewRequest("GET", "/test", nil)
@@ -102,7 +102,7 @@ func BenchmarkCircuitBreakerMW(b *testing.B) {
 		Timeout:          5 * time.Second,
 	}, nil)
 
-	mw := circuitBreakerMW(cb, false)
+	mw := circuitBreakerMW(cb, false, false)
 	handler := mw(ok200())
 
 	req := httptest.NewRequest("GET", "/test", nil)
@@ -5,14 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/wudi/runway/internal/cache"
 	"github.com/wudi/runway/internal/circuitbreaker"
+	"github.com/wudi/runway/internal/coalesce"
 	"github.com/wudi/runway/config"
 	"github.com/wudi/runway/internal/metrics"
 	"github.com/wudi/runway/internal/middleware/compression"
@@ -21,6 +25,7 @@ import (
 	"github.com/wudi/runway/internal/middleware/ratelimit"
 	"github.com/wudi/runway/internal/middleware/transform"
 	"github.com/wudi/runway/internal/middleware/validation"
+	"github.com/wudi/runway/internal/router"
 	"github.com/wudi/runway/internal/rules"
 	"github.com/wudi/runway/variables"
 )
@@ -350,6 +355,224 @@ func TestCacheMW_SkipNonCacheable(t *testing.T) {
 	}
 }
 
+func TestCacheMW_NegativeCachingPurgedByWrite(t *testing.T) {
+	h := cache.NewHandler(config.CacheConfig{
+		Enabled:    true,
+		TTL:        5 * time.Second,
+		StatusTTLs: map[string]time.Duration{"404": time.Second},
+	}, cache.NewMemoryStore(100, 5*time.Second))
+	mc := metrics.NewCollector()
+
+	exists := false
+	backendCalls := 0
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendCalls++
+		switch {
+		case r.Method == http.MethodPut:
+			exists = true
+			w.WriteHeader(http.StatusCreated)
+		case exists:
+			w.Write([]byte("found"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	handler := cacheMW(h, mc, "test-route")(backend)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/items/1", nil))
+		return w
+	}
+
+	get()
+	if w := get(); w.Code != 404 || w.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("expected cached 404, got %d X-Cache=%q", w.Code, w.Header().Get("X-Cache"))
+	}
+	if backendCalls != 1 {
+		t.Fatalf("expected 1 backend call, got %d", backendCalls)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("PUT", "/items/1", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 from PUT, got %d", w.Code)
+	}
+
+	if w := get(); w.Code != 200 || w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("expected negative entry purged by PUT, got %d X-Cache=%q", w.Code, w.Header().Get("X-Cache"))
+	}
+	if s := h.Stats().ByStatus["4xx"]; s.Hits != 1 || s.Misses != 1 || s.Stores != 1 {
+		t.Errorf("unexpected 4xx stats: %+v", s)
+	}
+}
+
+// newStaleTimeoutHandler returns a cache handler with serve_stale_on_timeout
+// holding a stale entry "v1" for GET /data.
+func newStaleTimeoutHandler(t *testing.T) (*cache.Handler, string) {
+	t.Helper()
+	h := cache.NewHandler(config.CacheConfig{
+		Enabled:             true,
+		TTL:                 50 * time.Millisecond,
+		StaleIfError:        time.Minute,
+		ServeStaleOnTimeout: true,
+		SoftTimeout:         30 * time.Millisecond,
+	}, cache.NewMemoryStore(100, time.Minute))
+	key := h.KeyForRequest(httptest.NewRequest("GET", "/data", nil))
+	h.StoreByKey(key, &cache.Entry{StatusCode: 200, Headers: http.Header{"Content-Type": {"text/plain"}}, Body: []byte("v1")})
+	time.Sleep(60 * time.Millisecond)
+	return h, key
+}
+
+// waitStaleRefresh waits until the route has recorded n background refreshes.
+func waitStaleRefresh(t *testing.T, mc *metrics.Collector, route string, n int64) map[string]int64 {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		outcomes := mc.Snapshot().CacheStaleRefresh[route]
+		var total int64
+		for _, v := range outcomes {
+			total += v
+		}
+		if total >= n {
+			return outcomes
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d background refreshes, got %v", n, outcomes)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCacheMW_StaleOnTimeout(t *testing.T) {
+	h, key := newStaleTimeoutHandler(t)
+	mc := metrics.NewCollector()
+
+	release := make(chan struct{})
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		w.Write([]byte("v2"))
+	})
+	handler := cacheMW(h, mc, "test-route")(backend)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/data", nil).WithContext(ctx))
+	cancel() // the client is gone; the refresh must not be attributed to it
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the stale entry after the soft timeout, took %v", elapsed)
+	}
+	if w.Body.String() != "v1" || w.Header().Get("X-Cache") != "STALE-TIMEOUT" {
+		t.Errorf("expected stale v1 with X-Cache: STALE-TIMEOUT, got %q %q", w.Body.String(), w.Header().Get("X-Cache"))
+	}
+	if !strings.HasPrefix(w.Header().Get("Warning"), "110") {
+		t.Errorf("expected a 110 Warning header, got %q", w.Header().Get("Warning"))
+	}
+
+	close(release)
+	if outcomes := waitStaleRefresh(t, mc, "test-route", 1); outcomes["refreshed"] != 1 {
+		t.Errorf("expected the background request to refresh the cache, got %v", outcomes)
+	}
+	if entry, fresh, _ := h.GetWithStaleness(key); !fresh || string(entry.Body) != "v2" {
+		t.Errorf("expected a fresh v2 entry after the refresh, got %+v", entry)
+	}
+	if n := mc.Snapshot().CacheStaleOnTimeout["test-route"]; n != 1 {
+		t.Errorf("expected 1 stale-on-timeout serve, got %d", n)
+	}
+}
+
+func TestCacheMW_StaleOnTimeoutWaitsWhenNoStaleEntry(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(80 * time.Millisecond)
+		w.Write([]byte("v2"))
+	})
+
+	t.Run("no entry", func(t *testing.T) {
+		h, _ := newStaleTimeoutHandler(t)
+		mc := metrics.NewCollector()
+		w := httptest.NewRecorder()
+		cacheMW(h, mc, "test-route")(slow).ServeHTTP(w, httptest.NewRequest("GET", "/other", nil))
+		if w.Body.String() != "v2" || w.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("expected to wait for the backend, got %q %q", w.Body.String(), w.Header().Get("X-Cache"))
+		}
+	})
+
+	t.Run("backend within soft timeout", func(t *testing.T) {
+		h, key := newStaleTimeoutHandler(t)
+		mc := metrics.NewCollector()
+		w := httptest.NewRecorder()
+		cacheMW(h, mc, "test-route")(ok200()).ServeHTTP(w, httptest.NewRequest("GET", "/data", nil))
+		if w.Header().Get("X-Cache") != "MISS" || w.Code != 200 {
+			t.Errorf("expected the backend response, got %d %q", w.Code, w.Header().Get("X-Cache"))
+		}
+		if _, fresh, _ := h.GetWithStaleness(key); !fresh {
+			t.Error("expected the backend response to be stored")
+		}
+		if n := mc.Snapshot().CacheStaleOnTimeout["test-route"]; n != 0 {
+			t.Errorf("expected no stale-on-timeout serves, got %d", n)
+		}
+	})
+}
+
+func TestCacheMW_StaleOnTimeoutCoalescesRefresh(t *testing.T) {
+	h, _ := newStaleTimeoutHandler(t)
+	mc := metrics.NewCollector()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte("v2"))
+	})
+	co := coalesce.New(config.CoalesceConfig{Enabled: true, Timeout: time.Minute})
+	handler := cacheMW(h, mc, "test-route")(co.Middleware()(backend))
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/data", nil))
+		return w
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := serve(); w.Header().Get("X-Cache") != "STALE-TIMEOUT" || w.Body.String() != "v1" {
+				t.Errorf("expected a stale-on-timeout response, got %q %q", w.Header().Get("X-Cache"), w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+
+	// A request arriving while the refresh runs gets the stale entry
+	// without another backend call.
+	if w := serve(); w.Header().Get("X-Cache") != "STALE-TIMEOUT" {
+		t.Errorf("expected a stale response while the refresh is pending, got %q", w.Header().Get("X-Cache"))
+	}
+
+	close(release)
+	outcomes := waitStaleRefresh(t, mc, "test-route", 1)
+	time.Sleep(20 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected one coalesced backend call, got %d", n)
+	}
+	if outcomes = mc.Snapshot().CacheStaleRefresh["test-route"]; len(outcomes) != 1 || outcomes["refreshed"] != 1 {
+		t.Errorf("expected a single refresh outcome, got %v", outcomes)
+	}
+	if n := mc.Snapshot().CacheStaleOnTimeout["test-route"]; n != 6 {
+		t.Errorf("expected 6 stale-on-timeout serves, got %d", n)
+	}
+}
+
 // --- circuitBreakerMW ---
 
 func TestCircuitBreakerMW_Closed(t *testing.T) {
@@ -360,7 +583,7 @@ func TestCircuitBreakerMW_Closed(t *testing.T) {
 		Timeout:          5 * time.Second,
 	}, nil)
 
-	mw := circuitBreakerMW(cb, false)
+	mw := circuitBreakerMW(cb, false, false)
 	handler := mw(ok200())
 
 	req := httptest.NewRequest("GET", "/test", nil)
@@ -384,7 +607,7 @@ func TestCircuitBreakerMW_Open(t *testing.T) {
 	failHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
 	})
-	mw := circuitBreakerMW(cb, false)
+	mw := circuitBreakerMW(cb, false, false)
 	handler := mw(failHandler)
 
 	for i := 0; i < 2; i++ {
@@ -411,7 +634,7 @@ func TestCircuitBreakerMW_ReportsSuccess(t *testing.T) {
 		Timeout:          5 * time.Second,
 	}, nil)
 
-	mw := circuitBreakerMW(cb, false)
+	mw := circuitBreakerMW(cb, false, false)
 	handler := mw(ok200())
 
 	req := httptest.NewRequest("GET", "/test", nil)
@@ -424,6 +647,77 @@ func TestCircuitBreakerMW_ReportsSuccess(t *testing.T) {
 	}
 }
 
+func TestCircuitBreakerMW_ClientAborts(t *testing.T) {
+	aborted := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		variables.GetFromRequest(r).ClientAbort = variables.AbortDuringBackend
+		w.WriteHeader(variables.StatusClientClosedRequest)
+	})
+
+	for _, countAborts := range []bool{false, true} {
+		cb := circuitbreaker.NewBreaker(config.CircuitBreakerConfig{
+			Enabled:          true,
+			FailureThreshold: 2,
+			MaxRequests:      1,
+			Timeout:          5 * time.Second,
+		}, nil)
+		handler := circuitBreakerMW(cb, false, countAborts)(aborted)
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, variables.NewContext(req)))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		want := "closed"
+		if countAborts {
+			want = "open"
+		}
+		if state := cb.Snapshot().State; state != want {
+			t.Errorf("countAborts=%v: expected %s breaker, got %s", countAborts, want, state)
+		}
+	}
+}
+
+// --- trafficObserverMW ---
+
+type recordedTraffic struct {
+	statuses []int
+}
+
+func (rt *recordedTraffic) RecordRequest(group string, statusCode int, latency time.Duration) {
+	rt.statuses = append(rt.statuses, statusCode)
+}
+
+func TestTrafficObserverMW_ClientAborts(t *testing.T) {
+	handler := func(abort bool) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			varCtx := variables.GetFromRequest(r)
+			varCtx.TrafficGroup = "canary"
+			if abort {
+				varCtx.ClientAbort = variables.AbortDuringResponse
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	serve := func(h http.Handler) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, variables.NewContext(req)))
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	excluded := &recordedTraffic{}
+	serve(trafficObserverMW(excluded, false)(handler(false)))
+	serve(trafficObserverMW(excluded, false)(handler(true)))
+	if len(excluded.statuses) != 1 || excluded.statuses[0] != http.StatusOK {
+		t.Errorf("expected only the completed request recorded, got %v", excluded.statuses)
+	}
+
+	counted := &recordedTraffic{}
+	serve(trafficObserverMW(counted, true)(handler(true)))
+	if len(counted.statuses) != 1 || counted.statuses[0] < 500 {
+		t.Errorf("expected the abort recorded as a failure, got %v", counted.statuses)
+	}
+}
+
 // --- compressionMW ---
 
 func TestCompressionMW_Compresses(t *testing.T) {
@@ -599,7 +893,7 @@ func TestResponseBodyTransformMW_RenameFields(t *testing.T) {
 func TestMetricsMW_Records(t *testing.T) {
 	mc := metrics.NewCollector()
 
-	mw := metricsMW(mc, "metrics-route")
+	mw := metricsMW(mc, "metrics-route", metrics.RouteOptions{})
 	handler := mw(ok200())
 
 	req := httptest.NewRequest("GET", "/test", nil)
@@ -623,7 +917,7 @@ func TestMetricsMW_CapturesStatus(t *testing.T) {
 		w.WriteHeader(500)
 	})
 
-	mw := metricsMW(mc, "fail-route")
+	mw := metricsMW(mc, "fail-route", metrics.RouteOptions{})
 	handler := mw(fail)
 
 	req := httptest.NewRequest("GET", "/test", nil)
@@ -636,6 +930,50 @@ func TestMetricsMW_CapturesStatus(t *testing.T) {
 	}
 }
 
+func TestMetricsMW_SyntheticSeparated(t *testing.T) {
+	mc := metrics.NewCollector()
+	handler := metricsMW(mc, "probe-route", metrics.RouteOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		variables.GetFromRequest(r).Synthetic = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	varCtx := variables.NewContext(req)
+	req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec := httptest.NewRecorder()
+	mc.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `runway_synthetic_requests_total{route="probe-route",status="200"} 1`) {
+		t.Error("expected synthetic request counter")
+	}
+	if strings.Contains(body, `runway_requests_total{method="GET",route="probe-route"`) {
+		t.Error("synthetic probe must not be counted in runway_requests_total")
+	}
+}
+
+func TestMetricsMW_ClientAbort(t *testing.T) {
+	mc := metrics.NewCollector()
+	handler := metricsMW(mc, "abort-route", metrics.RouteOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		variables.GetFromRequest(r).ClientAbort = variables.AbortDuringResponse
+	}))
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	varCtx := variables.NewContext(req)
+	req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	snap := mc.Snapshot()
+	if snap.RequestsTotal["abort-route|GET|499"] != 1 || snap.RequestsTotal["abort-route|GET|200"] != 0 {
+		t.Errorf("expected the abort recorded as 499, got %v", snap.RequestsTotal)
+	}
+	if snap.ClientAborts["abort-route"]["during_response"] != 1 {
+		t.Errorf("expected an abort during response, got %v", snap.ClientAborts)
+	}
+}
+
 // --- varContextMW ---
 
 func TestVarContextMW(t *testing.T) {
@@ -649,7 +987,7 @@ func TestVarContextMW(t *testing.T) {
 		w.WriteHeader(200)
 	})
 
-	mw := varContextMW("my-route")
+	mw := varContextMW("my-route", 0, routeMetadata{})
 	handler := mw(next)
 
 	req := httptest.NewRequest("GET", "/test", nil)
@@ -670,6 +1008,168 @@ func TestVarContextMW(t *testing.T) {
 	}
 }
 
+func TestVarContextMW_BodyFields(t *testing.T) {
+	engine, err := rules.NewEngine([]config.RuleConfig{
+		{ID: "no-free", Expression: `body?.account?.plan == "free"`, Action: "block", StatusCode: 402},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFn := ratelimit.BuildKeyFunc(false, "body:account.id")
+	route := &router.Route{}
+	route.Transform.Request.Headers.Set = map[string]string{"X-Account": "$body.account.id"}
+
+	var key, header, body string
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = keyFn(r)
+		header = r.Header.Get("X-Account")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	})
+	handler := varContextMW("body-route", variables.DefaultMaxBodyFieldsSize, routeMetadata{})(
+		requestRulesMW(nil, engine)(requestTransformMW(route, nil, nil)(backend)))
+
+	serve := func(payload string) int {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		varCtx := variables.NewContext(req)
+		req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	payload := `{"account":{"id":"a-7","plan":"gold"}}`
+	if code := serve(payload); code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if key != "body:account.id:a-7" || header != "a-7" {
+		t.Errorf("expected body fields in key and header, got key=%q header=%q", key, header)
+	}
+	if body != payload {
+		t.Errorf("expected backend to receive the original body, got %q", body)
+	}
+	if code := serve(`{"account":{"id":"a-8","plan":"free"}}`); code != 402 {
+		t.Errorf("expected body rule to block with 402, got %d", code)
+	}
+}
+
+func TestMultipartFieldsMW(t *testing.T) {
+	newUpload := func(fieldFirst bool, tenant string) (*http.Request, []byte) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		if fieldFirst {
+			mw.WriteField("tenant_id", tenant)
+		}
+		fw, _ := mw.CreateFormFile("file", "upload.bin")
+		fw.Write(bytes.Repeat([]byte("u"), 512<<10))
+		if !fieldFirst {
+			mw.WriteField("tenant_id", tenant)
+		}
+		mw.Close()
+		raw := bytes.Clone(buf.Bytes())
+		req := httptest.NewRequest("POST", "/upload", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		varCtx := variables.NewContext(req)
+		req = req.WithContext(context.WithValue(req.Context(), variables.RequestContextKey{}, varCtx))
+		return req, raw
+	}
+
+	validator, err := validation.New(config.ValidationConfig{
+		Enabled: true,
+		Schema:  `{"type":"object","required":["tenant_id"],"properties":{"tenant_id":{"pattern":"^[a-z]+$"}}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tenant string
+	var forwarded []byte
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, _ = variables.FormField(r, "tenant_id")
+		forwarded, _ = io.ReadAll(r.Body)
+	})
+	cfg := config.MultipartFieldsConfig{Enabled: true, Fields: []string{"tenant_id"}, MaxScanBytes: 64 << 10}
+
+	// Happy path: the field precedes the upload and is validated without
+	// reading the upload.
+	handler := multipartFieldsMW(cfg)(validator.Middleware()(backend))
+	req, raw := newUpload(true, "acme")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != 200 || tenant != "acme" || !bytes.Equal(forwarded, raw) {
+		t.Fatalf("expected 200 with tenant acme and the full upload, got %d tenant=%q forwarded=%d/%d bytes", w.Code, tenant, len(forwarded), len(raw))
+	}
+	req, _ = newUpload(true, "ACME!")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != 400 {
+		t.Errorf("expected the schema to reject the form field, got %d", w.Code)
+	}
+
+	// The field follows the upload: on_limit continue forwards without it.
+	tenant = "unset"
+	handler = multipartFieldsMW(cfg)(backend)
+	req, raw = newUpload(false, "acme")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != 200 || tenant != "" || !bytes.Equal(forwarded, raw) {
+		t.Errorf("expected 200 without the field and the full upload, got %d tenant=%q", w.Code, tenant)
+	}
+
+	// on_limit reject refuses it.
+	cfg.OnLimit = "reject"
+	handler = multipartFieldsMW(cfg)(backend)
+	req, _ = newUpload(false, "acme")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != 400 {
+		t.Errorf("expected 400 when the scan limit is hit, got %d", w.Code)
+	}
+}
+
+func TestRouteBodyFieldsLimit(t *testing.T) {
+	bodyRules, _ := rules.NewEngine([]config.RuleConfig{
+		{ID: "r", Expression: `body?.a == 1`, Action: "block"},
+	}, nil)
+	otherRules, _ := rules.NewEngine([]config.RuleConfig{
+		{ID: "r", Expression: `http.request.method == "POST"`, Action: "block"},
+	}, nil)
+
+	var none config.RouteConfig
+	if got := routeBodyFieldsLimit(none, otherRules, otherRules); got != 0 {
+		t.Errorf("expected body fields disabled without references, got %d", got)
+	}
+
+	var keyed config.RouteConfig
+	keyed.RateLimit.Key = "body:user.id"
+	if got := routeBodyFieldsLimit(keyed, nil, nil); got != variables.DefaultMaxBodyFieldsSize {
+		t.Errorf("expected default cap for body rate limit key, got %d", got)
+	}
+
+	var templated config.RouteConfig
+	templated.Transform.Request.Headers.Add = map[string]string{"X-User": "$body.user.id"}
+	templated.MaxBodySize = 4096
+	if got := routeBodyFieldsLimit(templated, nil, nil); got != 4096 {
+		t.Errorf("expected cap lowered to max_body_size, got %d", got)
+	}
+
+	conditioned := config.RouteConfig{Modifiers: []config.ModifierConfig{
+		{Type: "header_set", Name: "X", Value: "y", Condition: &config.ConditionConfig{Type: "body", Name: "a"}},
+	}}
+	if got := routeBodyFieldsLimit(conditioned, nil, nil); got == 0 {
+		t.Error("expected body modifier condition to enable body fields")
+	}
+
+	if got := routeBodyFieldsLimit(none, bodyRules, nil); got == 0 {
+		t.Error("expected global body rule to enable body fields")
+	}
+	passthrough := config.RouteConfig{Passthrough: true}
+	if got := routeBodyFieldsLimit(passthrough, bodyRules, bodyRules); got != 0 {
+		t.Errorf("expected passthrough route to skip body fields, got %d", got)
+	}
+}
+
 // --- responseRulesMW ---
 
 func TestResponseRulesMW_SetHeaders(t *testing.T) {
@@ -858,3 +1358,40 @@ func TestStatusRecorder(t *testing.T) {
 
 // Ensure unused imports are satisfied.
 var _ = io.Discard
+
+// --- wsUpgradeGuardMW ---
+
+func TestWSUpgradeGuardMW_CountsRejectedUpgrades(t *testing.T) {
+	reject := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("bad") != "" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	blocked := 0
+	handler := wsUpgradeGuardMW(reject, func() { blocked++ })(ok200())
+
+	tests := []struct {
+		path    string
+		upgrade bool
+		want    int
+	}{
+		{"/ws", true, 0},
+		{"/ws?bad=1", true, 1},
+		{"/api?bad=1", false, 1}, // plain requests are not counted
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.upgrade {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if blocked != tt.want {
+			t.Errorf("%s: expected %d blocked upgrades, got %d", tt.path, tt.want, blocked)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package runway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/middleware/openapi"
+)
+
+const openAPIDriftConfig = `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+openapi:
+  specs:
+    - id: petstore
+      file: ../middleware/openapi/testdata/petstore.yaml
+      default_backends:
+        - url: BACKEND
+      validation:
+        request: false
+      schema_drift:
+        enabled: true
+        sample_rate: 100
+`
+
+func TestOpenAPISchemaDrift(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"7","name":"rex","owner":"sam"}`))
+	}))
+	defer backend.Close()
+
+	cfg, err := config.NewLoader().Parse([]byte(strings.ReplaceAll(openAPIDriftConfig, "BACKEND", backend.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	rec := httptest.NewRecorder()
+	server.Runway().Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/pets/7", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "owner") {
+		t.Fatalf("drift detection must not change the response, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	var report struct {
+		Spec   string                `json:"spec"`
+		Routes []openapi.DriftReport `json:"routes"`
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w := httptest.NewRecorder()
+		server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/openapi/petstore/drift", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, r := range report.Routes {
+			found = found || r.Inspected > 0
+		}
+		if found || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	kinds := map[string]bool{}
+	for _, r := range report.Routes {
+		for _, f := range r.Findings {
+			kinds[f.Kind+" "+f.Pointer] = true
+		}
+	}
+	if !kinds["unknown_field /owner"] || !kinds["type_mismatch /id"] {
+		t.Errorf("expected the owner and id findings, got %+v", report.Routes)
+	}
+
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/openapi/other/drift", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a spec without drift detection, got %d", w.Code)
+	}
+}
@@ -0,0 +1,98 @@
+package runway
+
+import (
+	"fmt"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/featureflags"
+	"github.com/wudi/runway/internal/logging"
+)
+
+// Runtime overrides shared by the admin override endpoints and the feature
+// flag watcher. *Runway implements featureflags.Applier.
+
+// SetFeatureEnabled switches a route middleware on or off at runtime.
+// The feature name is the middleware slot name (e.g. "waf", "rate_limit")
+// and must be active on the route.
+func (g *Runway) SetFeatureEnabled(routeID, feature string, enabled bool) error {
+	v, ok := g.routeSlotNames.Load(routeID)
+	if !ok {
+		return fmt.Errorf("route %q not found", routeID)
+	}
+	if !v.(map[string]bool)[feature] {
+		return fmt.Errorf("feature %q is not active on route %q", feature, routeID)
+	}
+	g.featureOverrides.Set(routeID, feature, enabled)
+	return nil
+}
+
+// ClearFeature removes a runtime feature override, restoring config behaviour.
+func (g *Runway) ClearFeature(routeID, feature string) {
+	g.featureOverrides.Clear(routeID, feature)
+}
+
+// SetMaintenance enables or disables maintenance mode on a route.
+func (g *Runway) SetMaintenance(routeID string, enabled bool) error {
+	cm := g.maintenanceHandlers.Lookup(routeID)
+	if cm == nil {
+		return fmt.Errorf("route %q not found or maintenance not configured", routeID)
+	}
+	if enabled {
+		cm.Enable()
+	} else {
+		cm.Disable()
+	}
+	return nil
+}
+
+// ResetMaintenance restores a route's configured maintenance state.
+func (g *Runway) ResetMaintenance(routeID string) error {
+	cm := g.maintenanceHandlers.Lookup(routeID)
+	if cm == nil {
+		return fmt.Errorf("route %q not found or maintenance not configured", routeID)
+	}
+	cm.Reset()
+	return nil
+}
+
+// SetLogLevel changes the runtime log level.
+func (g *Runway) SetLogLevel(level string) error {
+	return logging.SetLevel(level)
+}
+
+// ResetLogLevel restores the configured log level.
+func (g *Runway) ResetLogLevel() error {
+	g.mu.RLock()
+	level := g.config.Logging.Level
+	g.mu.RUnlock()
+	if err := logging.SetLevel(level); err != nil {
+		// Unknown configured levels fall back to info, as at start-up.
+		return logging.SetLevel("info")
+	}
+	return nil
+}
+
+// GetFeatureOverrides returns the runtime per-route feature overrides.
+func (g *Runway) GetFeatureOverrides() *featureflags.Overrides {
+	return g.featureOverrides
+}
+
+// GetFeatureFlags returns the feature flag watcher, or nil when disabled.
+func (g *Runway) GetFeatureFlags() *featureflags.Watcher {
+	return g.featureFlags
+}
+
+// initFeatureFlags starts watching the configured flag prefix. Connection
+// failures are retried in the background and never block start-up.
+func (g *Runway) initFeatureFlags(cfg *config.Config) error {
+	if !cfg.FeatureFlags.Enabled {
+		return nil
+	}
+	source, err := featureflags.NewSource(cfg.FeatureFlags, cfg.Registry)
+	if err != nil {
+		return fmt.Errorf("failed to initialize feature flags: %w", err)
+	}
+	g.featureFlags = featureflags.New(cfg.FeatureFlags, source, g)
+	g.featureFlags.Start()
+	return nil
+}
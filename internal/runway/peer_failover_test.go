@@ -0,0 +1,149 @@
+package runway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/peering"
+)
+
+// peerGateway is an in-process gateway served over HTTP so that another
+// gateway can use it as a peer.
+type peerGateway struct {
+	server *Server
+	http   *httptest.Server
+}
+
+// newPeerGateways starts gateways "gw-a" and "gw-b", each with an /api route
+// on backendA or backendB that fails over to the other gateway.
+func newPeerGateways(t *testing.T, backendA, backendB string) (a, b *peerGateway) {
+	t.Helper()
+	a, b = &peerGateway{}, &peerGateway{}
+	for _, g := range []*peerGateway{a, b} {
+		g := g
+		g.http = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			g.server.Runway().Handler().ServeHTTP(w, r)
+		}))
+		t.Cleanup(g.http.Close)
+	}
+
+	build := func(g *peerGateway, id, backend string, peer *peerGateway, peerName string) {
+		cfg := &config.Config{
+			Listeners: []config.ListenerConfig{{
+				ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+			}},
+			Registry: config.RegistryConfig{Type: "memory"},
+			PeerFailover: config.PeerFailoverConfig{
+				GatewayID: id,
+				Secret:    "shared-secret",
+				Peers: []config.PeerConfig{{
+					Name:           peerName,
+					URL:            peer.http.URL,
+					CircuitBreaker: config.CircuitBreakerConfig{FailureThreshold: 3},
+				}},
+			},
+			Routes: []config.RouteConfig{{
+				ID: "api", Path: "/api", PathPrefix: true,
+				Backends:     []config.BackendConfig{{URL: backend}},
+				PeerFailover: config.RoutePeerFailoverConfig{Enabled: true},
+			}},
+		}
+		server, err := NewServer(cfg, "")
+		if err != nil {
+			t.Fatalf("Failed to create server: %v", err)
+		}
+		t.Cleanup(func() { server.Runway().Close() })
+		g.server = server
+	}
+	build(a, "gw-a", backendA, b, "region-b")
+	build(b, "gw-b", backendB, a, "region-a")
+	return a, b
+}
+
+func (g *peerGateway) markUnhealthy(backend string) {
+	(*g.server.Runway().routeProxies.Load())["api"].GetBalancer().MarkUnhealthy(backend)
+}
+
+func (g *peerGateway) get(t *testing.T, path string, header http.Header) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", g.http.URL+path, nil)
+	for k, vv := range header {
+		req.Header[k] = vv
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestPeerFailover_TwoGateways(t *testing.T) {
+	var forwardedBy, hop string
+	backendB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedBy, hop = r.Header.Get(peering.HeaderForwardedBy), r.Header.Get(peering.HeaderHop)
+		io.WriteString(w, "region-b:"+r.URL.RequestURI())
+	}))
+	defer backendB.Close()
+	deadA := "http://127.0.0.1:1"
+
+	a, b := newPeerGateways(t, deadA, backendB.URL)
+	a.markUnhealthy(deadA)
+
+	// gw-a has no healthy backend and forwards to gw-b.
+	status, body := a.get(t, "/api/orders?id=7", nil)
+	if status != http.StatusOK || body != "region-b:/api/orders?id=7" {
+		t.Fatalf("expected the request to be served by gw-b, got %d %q", status, body)
+	}
+	if forwardedBy != "gw-a" || hop != "1" {
+		t.Errorf("expected forwarded-by gw-a at hop 1, got %q at hop %q", forwardedBy, hop)
+	}
+	if s := a.server.Runway().GetPeerFailover().Stats(); s.Peers[0].Served != 1 {
+		t.Errorf("expected one request served by region-b, got %+v", s.Peers[0])
+	}
+
+	// A client cannot forge the hop header: unsigned peer headers are dropped.
+	status, _ = b.get(t, "/api/direct", http.Header{
+		peering.HeaderForwardedBy: {"gw-x"}, peering.HeaderHop: {"1"}, peering.HeaderSignature: {"bogus"},
+	})
+	if status != http.StatusOK || forwardedBy != "" || hop != "" {
+		t.Errorf("expected forged peer headers to be removed, got %d forwarded-by %q hop %q", status, forwardedBy, hop)
+	}
+
+	// With gw-b down as well, gw-b refuses to send gw-a's request back.
+	backendB.Close()
+	b.markUnhealthy(backendB.URL)
+	status, _ = a.get(t, "/api/orders", nil)
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("expected gw-a to answer 503 when no peer can serve, got %d", status)
+	}
+	if s := b.server.Runway().GetPeerFailover().Stats(); s.LoopsRejected != 1 {
+		t.Errorf("expected gw-b to reject one loop, got %d", s.LoopsRejected)
+	}
+	if s := a.server.Runway().GetPeerFailover().Stats(); s.Peers[0].LoopsRejected != 1 || s.Peers[0].Errors != 0 {
+		t.Errorf("expected a loop rejection not counted as a peer error, got %+v", s.Peers[0])
+	}
+
+	// A request forwarded by a peer is answered with 508 Loop Detected.
+	status, body = a.get(t, "/api/orders", http.Header{
+		peering.HeaderForwardedBy: {"gw-b"}, peering.HeaderHop: {"1"},
+		peering.HeaderSignature: {signHop("gw-b")},
+	})
+	if status != http.StatusLoopDetected {
+		t.Errorf("expected 508 for a forwarded request, got %d %s", status, body)
+	}
+}
+
+// signHop signs a hop header the way a peer sharing the test secret does.
+func signHop(by string) string {
+	mac := hmac.New(sha256.New, []byte("shared-secret"))
+	mac.Write([]byte(by + "\n1"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,35 @@
+package runway
+
+import "github.com/wudi/runway/config"
+
+// reloadPluginMetrics applies the plugin series cap from a reloaded config
+// and drops the metrics of Lua and WASM plugins that are no longer
+// configured on a route. Plugins that are kept keep their values.
+func (g *Runway) reloadPluginMetrics(cfg *config.Config) {
+	pm := g.metricsCollector.Plugins()
+	pm.SetMaxSeries(cfg.Admin.Metrics.PluginMaxSeries)
+	pm.Retain(activePlugins(cfg))
+}
+
+// activePlugins maps each enabled Lua and WASM plugin name to the routes
+// it is configured on.
+func activePlugins(cfg *config.Config) map[string]map[string]bool {
+	active := make(map[string]map[string]bool)
+	add := func(plugin, routeID string) {
+		if active[plugin] == nil {
+			active[plugin] = make(map[string]bool)
+		}
+		active[plugin][routeID] = true
+	}
+	for _, rc := range cfg.Routes {
+		if rc.Lua.Enabled {
+			add(rc.Lua.MetricsName(), rc.ID)
+		}
+		for _, wp := range rc.WasmPlugins {
+			if wp.Enabled {
+				add(wp.MetricsName(), rc.ID)
+			}
+		}
+	}
+	return active
+}
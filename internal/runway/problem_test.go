@@ -0,0 +1,241 @@
+package runway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func TestProblemJSONErrors(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+		case "/failing":
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte("version conflict"))
+			return
+		case "/missing":
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("no such order"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	auth := config.RouteAuthConfig{Required: true, Methods: []string{"api_key"}}
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Authentication: config.AuthenticationConfig{
+			APIKey: config.APIKeyConfig{
+				Enabled: true,
+				Header:  "X-API-Key",
+				Keys:    []config.APIKeyEntry{{Key: "k1", ClientID: "c1"}},
+			},
+		},
+		Errors: config.ErrorsConfig{
+			Format: config.ErrorFormatProblemJSON,
+			Catalog: map[string]config.ErrorCatalogEntry{
+				"rate_limited": {Type: "https://errors.example.com/rate-limited", Title: "Slow down"},
+			},
+		},
+		ErrorPages: config.ErrorPagesConfig{
+			Enabled: true,
+			Pages:   map[string]config.ErrorPageEntry{"5xx": {HTML: "<h1>{{.StatusCode}} {{.StatusText}}</h1>"}},
+		},
+		Routes: []config.RouteConfig{
+			{
+				ID:        "limited",
+				Path:      "/limited",
+				Backends:  []config.BackendConfig{{URL: backend.URL}},
+				RateLimit: config.RateLimitConfig{Enabled: true, Rate: 1, Period: time.Minute, PerIP: true},
+			},
+			{
+				ID:       "private",
+				Path:     "/private",
+				Backends: []config.BackendConfig{{URL: backend.URL}},
+				Auth:     auth,
+				Errors: config.ErrorsConfig{Catalog: map[string]config.ErrorCatalogEntry{
+					"authentication_failed": {Type: "https://errors.example.com/auth"},
+				}},
+			},
+			{
+				ID:            "slow",
+				Path:          "/slow",
+				Backends:      []config.BackendConfig{{URL: backend.URL}},
+				TimeoutPolicy: config.TimeoutConfig{Backend: 50 * time.Millisecond},
+			},
+			{
+				ID:       "failing",
+				Path:     "/failing",
+				Backends: []config.BackendConfig{{URL: backend.URL}},
+			},
+			{
+				ID:   "chain",
+				Path: "/chain",
+				Sequential: config.SequentialConfig{Enabled: true, Steps: []config.SequentialStep{
+					{URL: backend.URL + "/ok"},
+					{URL: backend.URL + "/missing"},
+				}},
+			},
+			{
+				ID:   "fanout",
+				Path: "/fanout",
+				Aggregate: config.AggregateConfig{Enabled: true, Backends: []config.AggregateBackend{
+					{Name: "orders", URL: backend.URL + "/missing"},
+					{Name: "stock", URL: backend.URL + "/ok"},
+				}},
+			},
+			{
+				ID:       "legacy",
+				Path:     "/legacy",
+				Backends: []config.BackendConfig{{URL: backend.URL}},
+				Auth:     auth,
+				Errors:   config.ErrorsConfig{Format: config.ErrorFormatLegacy},
+			},
+		},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+	handler := server.Runway().Handler()
+
+	do := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	problem := func(t *testing.T, rec *httptest.ResponseRecorder, status int) map[string]any {
+		t.Helper()
+		if rec.Code != status {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, status, rec.Body)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Fatalf("Content-Type = %q, want application/problem+json", ct)
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("invalid problem document %q: %v", rec.Body, err)
+		}
+		if doc["status"] != float64(status) || doc["instance"] != "req-1" {
+			t.Errorf("status/instance = %v/%v, want %d/req-1", doc["status"], doc["instance"], status)
+		}
+		return doc
+	}
+	expect := func(t *testing.T, doc map[string]any, want map[string]any) {
+		t.Helper()
+		for k, v := range want {
+			if doc[k] != v {
+				t.Errorf("%s = %v, want %v (document %v)", k, doc[k], v, doc)
+			}
+		}
+	}
+
+	t.Run("rate limit", func(t *testing.T) {
+		do("/limited", "")
+		rec := do("/limited", "")
+		doc := problem(t, rec, http.StatusTooManyRequests)
+		expect(t, doc, map[string]any{
+			"type":   "https://errors.example.com/rate-limited",
+			"title":  "Slow down",
+			"route":  "limited",
+			"reason": "rate_limited",
+		})
+		if rec.Header().Get("Retry-After") == "" {
+			t.Error("Retry-After header was dropped")
+		}
+	})
+
+	t.Run("auth", func(t *testing.T) {
+		rec := do("/private", "")
+		doc := problem(t, rec, http.StatusUnauthorized)
+		expect(t, doc, map[string]any{
+			"type":   "https://errors.example.com/auth",
+			"title":  "Unauthorized",
+			"route":  "private",
+			"reason": "authentication_failed",
+		})
+		if rec.Header().Get("WWW-Authenticate") == "" {
+			t.Error("WWW-Authenticate header was dropped")
+		}
+	})
+
+	t.Run("upstream timeout", func(t *testing.T) {
+		doc := problem(t, do("/slow", "application/json"), http.StatusGatewayTimeout)
+		expect(t, doc, map[string]any{
+			"type":   "about:blank",
+			"title":  "Gateway Timeout",
+			"route":  "slow",
+			"reason": "upstream_timeout",
+		})
+	})
+
+	t.Run("html for browsers", func(t *testing.T) {
+		rec := do("/slow", "text/html,application/xhtml+xml")
+		if rec.Code != http.StatusGatewayTimeout || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+			t.Fatalf("got %d %q, want the 504 HTML error page", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if got := rec.Body.String(); got != "<h1>504 Gateway Timeout</h1>" {
+			t.Errorf("body = %q", got)
+		}
+	})
+
+	t.Run("route not found", func(t *testing.T) {
+		doc := problem(t, do("/nowhere", ""), http.StatusNotFound)
+		expect(t, doc, map[string]any{"reason": "route_not_found", "title": "Not Found"})
+		if _, ok := doc["route"]; ok {
+			t.Errorf("unmatched request names a route: %v", doc)
+		}
+	})
+
+	t.Run("backend errors pass through", func(t *testing.T) {
+		rec := do("/failing", "application/json")
+		if rec.Code != http.StatusConflict || rec.Body.String() != "version conflict" {
+			t.Errorf("got %d %q, want the backend's 409", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("sequential backend errors pass through", func(t *testing.T) {
+		rec := do("/chain", "application/json")
+		if rec.Code != http.StatusNotFound || rec.Body.String() != "no such order" {
+			t.Errorf("got %d %q, want the final step's 404", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("aggregate backend errors are not gateway errors", func(t *testing.T) {
+		rec := do("/fanout", "application/json")
+		if rec.Code != http.StatusBadGateway || rec.Header().Get("Content-Type") == "application/problem+json" {
+			t.Errorf("got %d %q, want the aggregate failure without problem details", rec.Code, rec.Header().Get("Content-Type"))
+		}
+	})
+
+	t.Run("legacy route", func(t *testing.T) {
+		rec := do("/legacy", "")
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("got %d %q, want a legacy 401", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["code"] != float64(http.StatusUnauthorized) {
+			t.Errorf("body = %s, want RunwayError JSON", rec.Body)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package runway
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware/ratelimit"
+	"go.uber.org/zap"
+)
+
+// rateLimitStateKeyPrefix is the default Redis key prefix; the hostname is
+// appended because buckets are local to an instance.
+const rateLimitStateKeyPrefix = "runway:ratelimit_state:"
+
+// initRateLimitState starts saving local rate limit buckets when enabled.
+// With restore set, buckets saved by the previous process are loaded into
+// the running limiters first.
+func (g *Runway) initRateLimitState(cfg *config.Config, restore bool) {
+	rs := cfg.RateLimitState
+	g.rateLimitStateConfig = rs
+	if !rs.Enabled {
+		return
+	}
+	var store ratelimit.StateStore
+	if rs.Store == "redis" {
+		if g.redisClient == nil {
+			logging.Warn("Rate limit state in Redis needs the Redis client created at startup; disabled until restart")
+			return
+		}
+		key := rs.RedisKey
+		if key == "" {
+			host, _ := os.Hostname()
+			key = rateLimitStateKeyPrefix + host
+		}
+		store = ratelimit.NewRedisStateStore(g.redisClient, key)
+	} else {
+		fs, err := ratelimit.NewFileStateStore(rs.File)
+		if err != nil {
+			logging.Error("Rate limit state disabled", zap.Error(err))
+			return
+		}
+		store = fs
+	}
+	p := ratelimit.NewPersister(ratelimit.PersisterConfig{
+		Store:        store,
+		Interval:     rs.SaveInterval,
+		ActiveWithin: rs.ActiveWithin,
+		MaxKeys:      rs.MaxKeysPerRoute,
+		Limiters:     g.currentRateLimiters,
+		OnError: func(err error) {
+			logging.Warn("Rate limit state save failed", zap.Error(err))
+		},
+	})
+	if restore {
+		// Restoring is best-effort: a missing or unreadable state only
+		// means clients start with full buckets.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		n, err := p.Restore(ctx, g.currentRateLimiters())
+		cancel()
+		if err != nil {
+			logging.Warn("Rate limit state not restored", zap.Error(err))
+		} else if n > 0 {
+			logging.Info("Restored rate limit buckets", zap.Int("buckets", n))
+		}
+	}
+	g.rateLimitState.Store(p)
+	p.Start()
+}
+
+// carryRateLimitState copies the running buckets into the limiters of a
+// new state, for routes whose limiter config is unchanged. Without saved
+// state only observe-mode buckets are carried, so a route switched to
+// enforcement starts from the levels real traffic left.
+func (g *Runway) carryRateLimitState(newState *gatewayState) {
+	if p := g.rateLimitState.Load(); p != nil {
+		p.Carry(g.currentRateLimiters(), newState.rateLimiters)
+	} else if cur := g.currentRateLimiters(); cur != nil {
+		newState.rateLimiters.CarryObserved(cur)
+	}
+}
+
+// reloadRateLimitState applies state settings from a reloaded config. The
+// buckets were already carried over by the reload, so nothing is restored.
+func (g *Runway) reloadRateLimitState(cfg *config.Config) {
+	if cfg.RateLimitState == g.rateLimitStateConfig {
+		return
+	}
+	if p := g.rateLimitState.Load(); p != nil {
+		g.rateLimitState.Store(nil)
+		p.Stop()
+	}
+	g.initRateLimitState(cfg, false)
+}
+
+func (g *Runway) currentRateLimiters() *ratelimit.RateLimitByRoute {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.rateLimiters
+}
@@ -4,11 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"reflect"
 	"sort"
 	"time"
 
 	"github.com/wudi/runway/config"
-	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/health"
 	"github.com/wudi/runway/internal/logging"
 	"github.com/wudi/runway/internal/middleware/allowedhosts"
 	"github.com/wudi/runway/internal/middleware/debug"
@@ -16,11 +17,13 @@ import (
 	"github.com/wudi/runway/internal/middleware/loadshed"
 	openapivalidation "github.com/wudi/runway/internal/middleware/openapi"
 	"github.com/wudi/runway/internal/middleware/serviceratelimit"
+	"github.com/wudi/runway/internal/middleware/spillbuf"
+	"github.com/wudi/runway/internal/middleware/warmup"
 	"github.com/wudi/runway/internal/proxy"
-	"github.com/wudi/runway/internal/registry"
 	"github.com/wudi/runway/internal/router"
+	"github.com/wudi/runway/internal/storecrypt"
+	"github.com/wudi/runway/internal/trafficshape"
 	"github.com/wudi/runway/internal/webhook"
-	"github.com/wudi/runway/internal/websocket"
 	"github.com/wudi/runway/variables"
 	"go.uber.org/zap"
 )
@@ -31,6 +34,11 @@ type ReloadResult struct {
 	Timestamp time.Time `json:"timestamp"`
 	Error     string    `json:"error,omitempty"`
 	Changes   []string  `json:"changes,omitempty"`
+	// ConfigHash is the hash of the running config after a successful reload.
+	ConfigHash string `json:"config_hash,omitempty"`
+	// Listeners lists what the reload did to each added, removed or
+	// changed listener.
+	Listeners []ListenerAction `json:"listeners,omitempty"`
 }
 
 // gatewayState holds all route-scoped state that gets replaced during a reload.
@@ -42,6 +50,11 @@ type gatewayState struct {
 	watchCancels  map[string]context.CancelFunc
 	features      []Feature
 
+	// dryRun marks state built only to validate a config: its routes are
+	// set up without registering backends, watching services or building
+	// handlers, and it is never swapped in.
+	dryRun bool
+
 	// All per-route and per-reload managers (same struct as Runway)
 	routeManagers
 }
@@ -50,17 +63,60 @@ type gatewayState struct {
 // Shared infrastructure (proxy, healthChecker, registry, metricsCollector, redisClient, tracer) is
 // passed via the Runway and reused without replacement.
 func (g *Runway) buildState(cfg *config.Config) (*gatewayState, error) {
+	s, err := g.newState(cfg, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rebuild transport pool from new config and swap onto shared proxy
+	newPool := g.buildTransportPool(cfg)
+	oldPool := g.proxy.GetTransportPool()
+	g.proxy.SetTransportPool(newPool)
+	if oldPool != nil {
+		oldPool.CloseIdleConnections()
+	}
+
+	// Initialize each route using a temporary Runway view so addRouteForState works
+	for _, routeCfg := range cfg.Routes {
+		if err := g.addRouteForState(s, routeCfg); err != nil {
+			// Clean up translators on failure
+			s.translators.Close()
+			return nil, fmt.Errorf("failed to add route %s: %w", routeCfg.ID, err)
+		}
+	}
+
+	return s, nil
+}
+
+// newState creates the managers and features of a config, without any
+// routes. A dry-run state leaves webhook callbacks unwired.
+func (g *Runway) newState(cfg *config.Config, dryRun bool) (*gatewayState, error) {
+	// The running keyring is passed on so the previous key's grace window
+	// keeps counting across reloads.
+	storeKeys, err := storecrypt.New(cfg.StorageEncryption, g.storeKeys)
+	if err != nil {
+		return nil, fmt.Errorf("storage encryption: %w", err)
+	}
+
 	s := &gatewayState{
 		config:        cfg,
 		router:        router.New(),
 		routeProxies:  make(map[string]*proxy.RouteProxy),
 		routeHandlers: make(map[string]http.Handler),
 		watchCancels:  make(map[string]context.CancelFunc),
-		routeManagers: newRouteManagers(cfg, g.redisClient),
+		dryRun:        dryRun,
+		routeManagers: newRouteManagers(cfg, g.redisClient, storeKeys),
 	}
+	s.routeManagers.setPluginMetrics(g.metricsCollector.Plugins())
+	s.routeManagers.setObserveMetrics(g.metricsCollector)
+	s.routeManagers.mirrors.SetRouteHandlers(g.routeHandler)
+	g.wireEgressDialers(&s.routeManagers)
+	// The running disk is passed on so spill files of in-flight requests
+	// stay counted against the budget.
+	s.responseBuffers.SetDisk(spillbuf.NewDisk(cfg.ResponseBuffering, g.responseBuffers.Disk()))
 
 	// Initialize global singletons (shared between New and Reload)
-	if err := s.routeManagers.initGlobals(cfg, g.redisClient); err != nil {
+	if err := s.routeManagers.initGlobals(cfg, g.redisClient, g.tempBlocks); err != nil {
 		return nil, err
 	}
 
@@ -68,61 +124,80 @@ func (g *Runway) buildState(cfg *config.Config) (*gatewayState, error) {
 	s.features = buildFeatures(&s.routeManagers, cfg, g.redisClient)
 
 	// Wire webhook callbacks on new state's managers
-	s.routeManagers.wireWebhookCallbacks(g.webhookDispatcher)
+	if !dryRun {
+		s.routeManagers.wireWebhookCallbacks(g.webhookDispatcher)
+	}
 
 	// Initialize authentication (shared between New and Reload)
-	if err := s.routeManagers.initAuth(cfg); err != nil {
+	if err := s.routeManagers.initAuth(cfg, g.redisClient, g.webhookDispatcher); err != nil {
 		return nil, err
 	}
 
-	// Rebuild transport pool from new config and swap onto shared proxy
-	newPool := g.buildTransportPool(cfg)
-	oldPool := g.proxy.GetTransportPool()
-	g.proxy.SetTransportPool(newPool)
-	if oldPool != nil {
-		oldPool.CloseIdleConnections()
-	}
+	return s, nil
+}
 
-	// Initialize each route using a temporary Runway view so addRouteForState works
-	for _, routeCfg := range cfg.Routes {
-		if err := g.addRouteForState(s, routeCfg); err != nil {
-			// Clean up translators on failure
-			s.translators.Close()
-			return nil, fmt.Errorf("failed to add route %s: %w", routeCfg.ID, err)
+// evolutionSpec is an OpenAPI spec tracked by schema evolution.
+type evolutionSpec struct {
+	id, file string
+}
+
+// evolutionSpecs returns the file-backed OpenAPI specs of cfg, global
+// specs first, then per-route ones in route order.
+func evolutionSpecs(cfg *config.Config) []evolutionSpec {
+	var specs []evolutionSpec
+	for _, specCfg := range cfg.OpenAPI.Specs {
+		if specCfg.File != "" {
+			specs = append(specs, evolutionSpec{id: specCfg.ID, file: specCfg.File})
 		}
 	}
-
-	return s, nil
+	for _, rc := range cfg.Routes {
+		if rc.OpenAPI.SpecFile != "" {
+			specID := rc.OpenAPI.SpecFile
+			if rc.OpenAPI.SpecID != "" {
+				specID = rc.OpenAPI.SpecID
+			}
+			specs = append(specs, evolutionSpec{id: specID, file: rc.OpenAPI.SpecFile})
+		}
+	}
+	return specs
 }
 
 // addRouteForState adds a single route into the given gatewayState, using the Runway's
 // shared infrastructure (proxy, healthChecker, registry, redisClient).
 func (g *Runway) addRouteForState(s *gatewayState, routeCfg config.RouteConfig) error {
-	return g.setupRoute(&routeSetup{
+	rs := &routeSetup{
 		cfg:             s.config,
 		rtr:             s.router,
 		rm:              &s.routeManagers,
 		features:        s.features,
 		registerBackend: g.healthChecker.UpdateBackend,
-		watchService: func(routeID, serviceName string, tags []string) {
+		watchService: func(routeID string, svc config.ServiceConfig, localZone string) {
 			watchCtx, cancel := context.WithCancel(context.Background())
 			s.watchCancels[routeID] = cancel
-			go g.watchServiceForState(s, watchCtx, routeID, serviceName, tags)
+			go g.watchServiceForState(s, watchCtx, routeID, svc, localZone)
 		},
 		storeProxy: func(id string, rp *proxy.RouteProxy) { s.routeProxies[id] = rp },
 		buildHandler: func(routeID string, cfg config.RouteConfig, route *router.Route, rp *proxy.RouteProxy) http.Handler {
 			return g.buildRouteHandler(&s.routeManagers, routeID, cfg, route, rp)
 		},
 		storeHandler: func(id string, h http.Handler) { s.routeHandlers[id] = h },
-	}, routeCfg)
+	}
+	if s.dryRun {
+		// Building handlers records route stages on the Runway itself.
+		rs.dryRun = true
+		rs.registerBackend = func(health.Backend) {}
+		rs.watchService = func(string, config.ServiceConfig, string) {}
+		rs.buildHandler = func(string, config.RouteConfig, *router.Route, *proxy.RouteProxy) http.Handler { return nil }
+	}
+	return g.setupRoute(rs, routeCfg)
 }
 
 // watchServiceForState is like watchService but writes to a gatewayState's routeProxies.
-func (g *Runway) watchServiceForState(s *gatewayState, ctx context.Context, routeID, serviceName string, tags []string) {
-	ch, err := g.registry.Watch(ctx, serviceName)
+func (g *Runway) watchServiceForState(s *gatewayState, ctx context.Context, routeID string, svc config.ServiceConfig, localZone string) {
+	ch, err := g.registry.Watch(ctx, svc.Name)
 	if err != nil {
 		logging.Error("Failed to watch service during reload",
-			zap.String("service", serviceName),
+			zap.String("service", svc.Name),
 			zap.Error(err),
 		)
 		return
@@ -137,23 +212,7 @@ func (g *Runway) watchServiceForState(s *gatewayState, ctx context.Context, rout
 				return
 			}
 
-			var filtered []*registry.Service
-			for _, svc := range services {
-				if hasAllTags(svc.Tags, tags) {
-					filtered = append(filtered, svc)
-				}
-			}
-
-			var backends []*loadbalancer.Backend
-			for _, svc := range filtered {
-				be := &loadbalancer.Backend{
-					URL:     svc.URL(),
-					Weight:  1,
-					Healthy: svc.Health == registry.HealthPassing,
-				}
-				be.InitParsedURL()
-				backends = append(backends, be)
-			}
+			backends := g.serviceBackends(routeID, svc, localZone, services)
 
 			// The state's routeProxies are accessed by the Runway under g.mu,
 			// but since this watcher was started for the new state it's safe to
@@ -185,29 +244,12 @@ func (g *Runway) Reload(newCfg *config.Config) ReloadResult {
 
 	// Schema evolution check (before state swap)
 	if g.schemaChecker != nil && newCfg.OpenAPI.SchemaEvolution.Enabled {
-		for _, specCfg := range newCfg.OpenAPI.Specs {
-			if specCfg.File != "" {
-				doc, loadErr := openapivalidation.LoadSpec(specCfg.File)
-				if loadErr == nil {
-					if _, checkErr := g.schemaChecker.CheckAndStore(specCfg.ID, doc); checkErr != nil {
-						result.Error = checkErr.Error()
-						return result
-					}
-				}
-			}
-		}
-		for _, rc := range newCfg.Routes {
-			if rc.OpenAPI.SpecFile != "" {
-				doc, loadErr := openapivalidation.LoadSpec(rc.OpenAPI.SpecFile)
-				if loadErr == nil {
-					specID := rc.OpenAPI.SpecFile
-					if rc.OpenAPI.SpecID != "" {
-						specID = rc.OpenAPI.SpecID
-					}
-					if _, checkErr := g.schemaChecker.CheckAndStore(specID, doc); checkErr != nil {
-						result.Error = checkErr.Error()
-						return result
-					}
+		for _, spec := range evolutionSpecs(newCfg) {
+			doc, loadErr := openapivalidation.LoadSpec(spec.file)
+			if loadErr == nil {
+				if _, checkErr := g.schemaChecker.CheckAndStore(spec.id, doc); checkErr != nil {
+					result.Error = checkErr.Error()
+					return result
 				}
 			}
 		}
@@ -215,6 +257,12 @@ func (g *Runway) Reload(newCfg *config.Config) ReloadResult {
 
 	// Compute changes
 	result.Changes = diffConfig(g.config, newCfg)
+	changedFraction := routeChangeFraction(g.config, newCfg)
+
+	// Keep the buckets of unchanged rate limiters
+	g.carryRateLimitState(newState)
+	// Keep the ramp progress and stop state of unchanged fault injectors
+	trafficshape.CarryFaultInjectionState(g.GetFaultInjectors(), newState.faultInjectors)
 
 	// Save old state for cleanup
 	oldWatchCancels := g.watchCancels
@@ -230,6 +278,7 @@ func (g *Runway) Reload(newCfg *config.Config) ReloadResult {
 	g.watchCancels = newState.watchCancels
 	g.features = newState.features
 	g.routeManagers = newState.routeManagers
+	g.globalErrors.Store(newState.routeManagers.globalErrors)
 	// Rebuild global singletons from new config
 	if newCfg.ServiceRateLimit.Enabled {
 		g.serviceLimiter = serviceratelimit.New(newCfg.ServiceRateLimit)
@@ -264,9 +313,22 @@ func (g *Runway) Reload(newCfg *config.Config) ReloadResult {
 		cancel()
 	}
 	oldManagers.cleanup()
+	newState.upstreamDNS.start()
 	if oldLoadShedder != nil {
 		oldLoadShedder.Close()
 	}
+	g.reloadWarmup(newCfg.Warmup, changedFraction)
+	g.reapplyOverrides(newCfg)
+	g.reapplyBackendDrains(true)
+	g.reloadDependencyHealth(newCfg)
+	g.reloadBackendTLSScan(newCfg)
+	g.reloadConfigDrift(newCfg)
+	g.reloadConfigSnapshots(newCfg)
+	g.reloadRateLimitState(newCfg)
+	g.upstreamSwaps.reset()
+	g.reloadReputation(newCfg)
+	g.reloadPluginMetrics(newCfg)
+	g.reloadCachePriming(newCfg)
 	// Reconcile health checker: remove backends no longer present
 	newBackendURLs := make(map[string]bool)
 	// Collect backend URLs from upstreams
@@ -299,6 +361,9 @@ func (g *Runway) Reload(newCfg *config.Config) ReloadResult {
 			}
 		}
 	}
+	for _, url := range newState.upstreamDNS.targetURLs() {
+		newBackendURLs[url] = true
+	}
 	for url := range g.healthChecker.GetAllStatus() {
 		if !newBackendURLs[url] {
 			g.healthChecker.RemoveBackend(url)
@@ -316,14 +381,23 @@ func (g *Runway) Reload(newCfg *config.Config) ReloadResult {
 		}
 	}
 
+	result.ConfigHash = g.hashConfig(newState.config)
+
 	// Update webhook endpoints and emit success event
 	if g.webhookDispatcher != nil {
 		g.webhookDispatcher.UpdateEndpoints(newCfg.Webhooks.Endpoints)
+		g.webhookDispatcher.SetSkipShadow(newCfg.Webhooks.SkipShadow == nil || *newCfg.Webhooks.SkipShadow)
 		g.webhookDispatcher.Emit(webhook.NewEvent(webhook.ConfigReloadSuccess, "", map[string]interface{}{
-			"changes": result.Changes,
+			"changes":     result.Changes,
+			"config_hash": result.ConfigHash,
 		}))
 	}
 
+	applyXMLLimits(newCfg.XMLLimits)
+	logDeprecationWarnings(newCfg)
+	logResponsePipelineWarnings(newCfg)
+	logCacheKeyWarnings(newCfg)
+	logEgressWarnings(newCfg)
 	result.Success = true
 	return result
 }
@@ -360,6 +434,11 @@ func diffConfig(oldCfg, newCfg *config.Config) []string {
 		}
 	}
 
+	// Shared definitions: every referencing route picks up the new definition
+	for key := range sharedDefChanges(oldCfg, newCfg) {
+		changes = append(changes, fmt.Sprintf("shared definition changed: %s", key))
+	}
+
 	// Listener changes
 	if len(oldCfg.Listeners) != len(newCfg.Listeners) {
 		changes = append(changes, fmt.Sprintf("listeners changed: %d -> %d", len(oldCfg.Listeners), len(newCfg.Listeners)))
@@ -369,9 +448,111 @@ func diffConfig(oldCfg, newCfg *config.Config) []string {
 	return changes
 }
 
-// wsProxy accessor for buildState — uses shared wsProxy from Runway
-func (g *Runway) getWSProxy() *websocket.Proxy {
-	return g.wsProxy
+// routeChangeFraction returns the fraction of the new config's routes that
+// were added or changed relative to the old config.
+func routeChangeFraction(oldCfg, newCfg *config.Config) float64 {
+	if len(newCfg.Routes) == 0 {
+		return 0
+	}
+	oldRoutes := make(map[string]*config.RouteConfig, len(oldCfg.Routes))
+	for i := range oldCfg.Routes {
+		oldRoutes[oldCfg.Routes[i].ID] = &oldCfg.Routes[i]
+	}
+	sharedChanged := sharedDefChanges(oldCfg, newCfg)
+	changed := 0
+	for i := range newCfg.Routes {
+		old, ok := oldRoutes[newCfg.Routes[i].ID]
+		if !ok || !reflect.DeepEqual(*old, newCfg.Routes[i]) || referencesAny(&newCfg.Routes[i], sharedChanged) {
+			changed++
+		}
+	}
+	return float64(changed) / float64(len(newCfg.Routes))
+}
+
+// sharedDefChanges returns the shared auth definitions that were added,
+// removed or modified, keyed as "<section>/<name>".
+func sharedDefChanges(oldCfg, newCfg *config.Config) map[string]bool {
+	changed := make(map[string]bool)
+	diffNamedDefs(changed, "ext_auth_services", oldCfg.ExtAuthServices, newCfg.ExtAuthServices)
+	diffNamedDefs(changed, "opa_policies", oldCfg.OPAPolicies, newCfg.OPAPolicies)
+	diffNamedDefs(changed, "backend_auth_providers", oldCfg.BackendAuthProviders, newCfg.BackendAuthProviders)
+	diffNamedDefs(changed, "token_exchangers", oldCfg.TokenExchangers, newCfg.TokenExchangers)
+	return changed
+}
+
+func diffNamedDefs[C any](changed map[string]bool, section string, oldDefs, newDefs map[string]C) {
+	for name, def := range newDefs {
+		if old, ok := oldDefs[name]; !ok || !reflect.DeepEqual(old, def) {
+			changed[section+"/"+name] = true
+		}
+	}
+	for name := range oldDefs {
+		if _, ok := newDefs[name]; !ok {
+			changed[section+"/"+name] = true
+		}
+	}
+}
+
+// referencesAny reports whether rc references one of the given shared
+// definition keys.
+func referencesAny(rc *config.RouteConfig, keys map[string]bool) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	return (rc.ExtAuth.Ref != "" && keys["ext_auth_services/"+rc.ExtAuth.Ref]) ||
+		(rc.OPA.Ref != "" && keys["opa_policies/"+rc.OPA.Ref]) ||
+		(rc.BackendAuth.Ref != "" && keys["backend_auth_providers/"+rc.BackendAuth.Ref]) ||
+		(rc.TokenExchange.Ref != "" && keys["token_exchangers/"+rc.TokenExchange.Ref])
+}
+
+// reapplyOverrides forgets slot names and stages of removed routes,
+// re-applies feature flags, since rebuilt maintenance handlers start from
+// their config state, reverts break-glass bypasses of routes that lost
+// their profile and resumes pauses of removed routes.
+func (g *Runway) reapplyOverrides(cfg *config.Config) {
+	live := make(map[string]bool, len(cfg.Routes))
+	for _, rc := range cfg.Routes {
+		live[rc.ID] = true
+	}
+	g.routeSlotNames.Range(func(k, _ any) bool {
+		if !live[k.(string)] {
+			g.routeSlotNames.Delete(k)
+			g.routeStages.Delete(k)
+		}
+		return true
+	})
+	if g.featureFlags != nil {
+		g.featureFlags.Reapply()
+	}
+	g.reconcileBreakGlass(cfg)
+	g.reconcileRoutePauses(live)
+}
+
+// reloadWarmup applies warm-up settings from a reloaded config. The ramp
+// survives reloads and only restarts when enough routes were rebuilt.
+func (g *Runway) reloadWarmup(cfg config.WarmupConfig, changedFraction float64) {
+	if !cfg.Enabled {
+		g.warmer.Store(nil)
+		return
+	}
+	wu := g.warmer.Load()
+	if wu == nil {
+		// Newly enabled on a running instance: only ramp if the reload is large.
+		wu = warmup.New(cfg)
+		if wu.ShouldRestart(changedFraction) {
+			wu.Restart("reload")
+		} else {
+			wu.Finish()
+		}
+		g.warmer.Store(wu)
+		return
+	}
+	wu.Reconfigure(cfg)
+	if wu.ShouldRestart(changedFraction) {
+		wu.Restart("reload")
+		logging.Info("Warm-up restarted after reload",
+			zap.Float64("changed_routes_fraction", changedFraction))
+	}
 }
 
 // resolver accessor for buildState
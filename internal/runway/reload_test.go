@@ -3,6 +3,7 @@ package runway
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -85,6 +86,134 @@ func TestDiffConfigNoChanges(t *testing.T) {
 	}
 }
 
+func TestRouteChangeFraction(t *testing.T) {
+	old := &config.Config{Routes: []config.RouteConfig{
+		{ID: "a", Path: "/a"},
+		{ID: "b", Path: "/b"},
+		{ID: "c", Path: "/c"},
+		{ID: "d", Path: "/d"},
+	}}
+	new := &config.Config{Routes: []config.RouteConfig{
+		{ID: "a", Path: "/a"},
+		{ID: "b", Path: "/b2"}, // modified
+		{ID: "c", Path: "/c"},
+		{ID: "e", Path: "/e"}, // added
+	}}
+
+	if got := routeChangeFraction(old, new); got != 0.5 {
+		t.Errorf("expected 0.5, got %v", got)
+	}
+	if got := routeChangeFraction(old, old); got != 0 {
+		t.Errorf("expected 0 for identical configs, got %v", got)
+	}
+}
+
+func TestDiffConfigSharedDefinitionChange(t *testing.T) {
+	old := &config.Config{
+		Routes: []config.RouteConfig{
+			{ID: "a", ExtAuth: config.ExtAuthConfig{Ref: "main-authz"}},
+			{ID: "b", ExtAuth: config.ExtAuthConfig{Ref: "main-authz"}},
+			{ID: "c"},
+			{ID: "d", OPA: config.OPAConfig{Ref: "authz"}},
+		},
+		ExtAuthServices: map[string]config.ExtAuthConfig{"main-authz": {URL: "http://authz-v1"}},
+		OPAPolicies:     map[string]config.OPAConfig{"authz": {URL: "http://opa", PolicyPath: "authz/allow"}},
+	}
+	new := *old
+	new.ExtAuthServices = map[string]config.ExtAuthConfig{"main-authz": {URL: "http://authz-v2"}}
+
+	found := false
+	for _, c := range diffConfig(old, &new) {
+		if c == "shared definition changed: ext_auth_services/main-authz" {
+			found = true
+		}
+		if c == "shared definition changed: opa_policies/authz" {
+			t.Errorf("unchanged definition reported: %s", c)
+		}
+	}
+	if !found {
+		t.Error("expected shared definition change for ext_auth_services/main-authz")
+	}
+
+	// Both routes referencing the changed service count as changed.
+	if got := routeChangeFraction(old, &new); got != 0.5 {
+		t.Errorf("expected 0.5, got %v", got)
+	}
+}
+
+func TestReloadWarmupRestartThreshold(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	makeCfg := func(paths ...string) *config.Config {
+		cfg := &config.Config{
+			Listeners: []config.ListenerConfig{{
+				ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+			}},
+			Registry: config.RegistryConfig{Type: "memory"},
+			Warmup: config.WarmupConfig{
+				Enabled:         true,
+				Duration:        time.Hour,
+				ReloadThreshold: 0.5,
+			},
+		}
+		for _, p := range paths {
+			cfg.Routes = append(cfg.Routes, config.RouteConfig{
+				ID:       p,
+				Path:     "/" + p,
+				Backends: []config.BackendConfig{{URL: backend.URL}},
+			})
+		}
+		return cfg
+	}
+
+	gw, err := New(makeCfg("a", "b", "c", "d"))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	defer gw.Close()
+
+	wu := gw.GetWarmer()
+	if wu == nil || !wu.WarmingUp() {
+		t.Fatal("expected warm-up to be active after start")
+	}
+	wu.Finish()
+
+	// One of four routes changed: below threshold, no restart
+	if result := gw.Reload(makeCfg("a", "b", "c", "e")); !result.Success {
+		t.Fatalf("Reload failed: %s", result.Error)
+	}
+	if gw.GetWarmer() != wu {
+		t.Fatal("expected the warmer to survive reload")
+	}
+	if wu.WarmingUp() {
+		t.Error("expected no warm-up restart for a small reload")
+	}
+
+	// Three of four routes changed: above threshold, restart
+	if result := gw.Reload(makeCfg("a", "x", "y", "z")); !result.Success {
+		t.Fatalf("Reload failed: %s", result.Error)
+	}
+	if !wu.WarmingUp() {
+		t.Error("expected warm-up restart for a large reload")
+	}
+	if wu.Stats()["restarts"] != int64(1) {
+		t.Errorf("expected 1 restart, got %v", wu.Stats()["restarts"])
+	}
+
+	// Disabling warm-up removes the warmer
+	cfg := makeCfg("a", "x", "y", "z")
+	cfg.Warmup.Enabled = false
+	if result := gw.Reload(cfg); !result.Success {
+		t.Fatalf("Reload failed: %s", result.Error)
+	}
+	if gw.GetWarmer() != nil {
+		t.Error("expected warmer to be removed when disabled")
+	}
+}
+
 func TestReloadSuccess(t *testing.T) {
 	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -275,6 +404,64 @@ func TestReloadPreservesSharedState(t *testing.T) {
 	}
 }
 
+func TestReloadPluginMetrics(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	route := func(id string) config.RouteConfig {
+		return config.RouteConfig{
+			ID: id, Path: "/" + id, Backends: []config.BackendConfig{{URL: backend.URL}},
+			Lua: config.LuaConfig{
+				Enabled:       true,
+				Name:          id + "_counter",
+				RequestScript: `runway.metric_inc("calls_total")`,
+			},
+		}
+	}
+	cfgFor := func(routes ...config.RouteConfig) *config.Config {
+		return &config.Config{
+			Listeners: []config.ListenerConfig{{
+				ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+			}},
+			Registry: config.RegistryConfig{Type: "memory"},
+			Routes:   routes,
+			Admin:    config.AdminConfig{Enabled: false},
+		}
+	}
+
+	gw, err := New(cfgFor(route("keep"), route("drop")))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	defer gw.Close()
+
+	for _, path := range []string{"/keep", "/drop"} {
+		rec := httptest.NewRecorder()
+		gw.Handler().ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d", path, rec.Code)
+		}
+	}
+
+	if result := gw.Reload(cfgFor(route("keep"))); !result.Success {
+		t.Fatalf("Reload failed: %s", result.Error)
+	}
+	rec := httptest.NewRecorder()
+	gw.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/keep", nil))
+
+	rec = httptest.NewRecorder()
+	gw.GetMetricsCollector().Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `runway_plugin_keep_counter_calls_total{plugin="keep_counter",route="keep"} 2`) {
+		t.Errorf("Expected the kept plugin's counter to survive the reload:\n%s", body)
+	}
+	if strings.Contains(body, "runway_plugin_drop_counter") {
+		t.Errorf("Expected the removed plugin's metrics to be dropped:\n%s", body)
+	}
+}
+
 func TestReloadWithLoadBalancerChange(t *testing.T) {
 	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -0,0 +1,251 @@
+package runway
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/middleware/tenant"
+)
+
+// RenderConfig returns cfg as YAML with every route's effective configuration
+// resolved: global defaults are merged into routes by the same merge specs
+// the features set up with, load balancing is inherited from referenced
+// upstreams, tenant tiers are applied, generated OpenAPI routes are inlined
+// and secrets are masked. With provenance, inherited values carry a
+// "# from: ..." comment. Routes merged in from included files carry a
+// "# source: ..." comment naming the file. The output loads and validates
+// like a hand-written config, and loading it yields the same effective
+// configuration.
+func RenderConfig(cfg *config.Config, provenance bool) ([]byte, error) {
+	out, err := config.RedactConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("render: %w", err)
+	}
+	comments := renderEffective(out, provenance)
+	for i, rc := range out.Routes {
+		if src, ok := cfg.RouteSources[rc.ID]; ok {
+			path := fmt.Sprintf("$.routes[%d]", i)
+			comments[path] = append(comments[path], yaml.HeadComment(" source: "+src))
+		}
+	}
+	if len(comments) == 0 {
+		return yaml.Marshal(out)
+	}
+	return yaml.MarshalWithOptions(out, yaml.WithComment(comments))
+}
+
+// renderUnredacted returns a copy of cfg rendered like RenderConfig, with
+// secrets left in place.
+func renderUnredacted(cfg *config.Config) (*config.Config, error) {
+	out, err := config.CloneConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("render: %w", err)
+	}
+	renderEffective(out, false)
+	return out, nil
+}
+
+// renderEffective resolves every route's effective configuration in out, a
+// copy of the config, and returns the provenance comments.
+func renderEffective(out *config.Config, provenance bool) yaml.CommentMap {
+	comments := yaml.CommentMap{}
+	note := func(path, from string) {
+		if provenance && path != "" {
+			comments[path] = append(comments[path], yaml.HeadComment(" from: "+from))
+		}
+	}
+
+	rm := newRouteManagers(out, nil, nil)
+	features := buildFeatures(&rm, out, nil)
+	for i := range out.Routes {
+		rc := &out.Routes[i]
+		prefix := fmt.Sprintf("$.routes[%d]", i)
+		for _, f := range features {
+			r, ok := f.(routeRenderer)
+			if !ok {
+				continue
+			}
+			src, ok := r.renderRoute(rc)
+			if !ok {
+				continue
+			}
+			field := yamlFieldPath(rc, src.field)
+			if field == "" {
+				continue
+			}
+			from := "global." + yamlFieldPath(out, src.global)
+			if src.merged {
+				from = "route, merged with " + from
+			}
+			note(prefix+"."+field, from)
+		}
+		renderUpstreamRefs(out, rc, prefix, note)
+		renderSharedRefs(rc, prefix, note)
+		renderOpenAPISpec(out, rc, prefix, note)
+	}
+	// Generated routes are inlined above; loading the specs again would
+	// generate conflicting routes.
+	out.OpenAPI.Specs = nil
+	renderTenantTiers(out, note)
+	return comments
+}
+
+// renderUpstreamRefs inherits the load balancer settings of the route's
+// upstream. Upstream references are kept so the route still uses the
+// upstream's transport and health checks; the backends they resolve to are
+// noted instead.
+func renderUpstreamRefs(cfg *config.Config, rc *config.RouteConfig, prefix string, note func(string, string)) {
+	probe := *rc
+	probe.TrafficSplit = slices.Clone(rc.TrafficSplit)
+	probe.Versioning.Versions = maps.Clone(rc.Versioning.Versions)
+	resolved := resolveUpstreamRefs(cfg, probe)
+
+	if rc.Upstream != "" {
+		note(prefix+".upstream", upstreamSource(rc.Upstream, resolved.Backends, resolved.Service))
+		if rc.LoadBalancer != resolved.LoadBalancer {
+			rc.LoadBalancer = resolved.LoadBalancer
+			note(prefix+".load_balancer", "upstreams."+rc.Upstream+".load_balancer")
+		}
+		if rc.ConsistentHash != resolved.ConsistentHash {
+			rc.ConsistentHash = resolved.ConsistentHash
+			note(prefix+".consistent_hash", "upstreams."+rc.Upstream+".consistent_hash")
+		}
+		if rc.EWMA != resolved.EWMA {
+			rc.EWMA = resolved.EWMA
+			note(prefix+".ewma", "upstreams."+rc.Upstream+".ewma")
+		}
+	}
+	for i, split := range rc.TrafficSplit {
+		if split.Upstream != "" {
+			note(fmt.Sprintf("%s.traffic_split[%d].upstream", prefix, i), upstreamSource(split.Upstream, resolved.TrafficSplit[i].Backends, config.ServiceConfig{}))
+		}
+	}
+	if rc.Versioning.Enabled {
+		for ver, vcfg := range rc.Versioning.Versions {
+			if vcfg.Upstream != "" {
+				note(yamlKeyPath(prefix+".versioning.versions", ver, ".upstream"), upstreamSource(vcfg.Upstream, resolved.Versioning.Versions[ver].Backends, config.ServiceConfig{}))
+			}
+		}
+	}
+	if rc.Mirror.Enabled && rc.Mirror.Upstream != "" {
+		note(prefix+".mirror.upstream", upstreamSource(rc.Mirror.Upstream, resolved.Mirror.Backends, config.ServiceConfig{}))
+	}
+}
+
+func upstreamSource(name string, backends []config.BackendConfig, svc config.ServiceConfig) string {
+	if svc.Name != "" {
+		return fmt.Sprintf("upstreams.%s (service %s)", name, svc.Name)
+	}
+	urls := make([]string, len(backends))
+	for i, b := range backends {
+		urls[i] = b.URL
+	}
+	return fmt.Sprintf("upstreams.%s (backends: %s)", name, strings.Join(urls, ", "))
+}
+
+// renderSharedRefs notes the shared definitions a route's auth references.
+func renderSharedRefs(rc *config.RouteConfig, prefix string, note func(string, string)) {
+	if rc.ExtAuth.Ref != "" {
+		note(prefix+".ext_auth.ref", "ext_auth_services."+rc.ExtAuth.Ref)
+	}
+	if rc.OPA.Ref != "" {
+		note(prefix+".opa.ref", "opa_policies."+rc.OPA.Ref)
+	}
+	if rc.BackendAuth.Ref != "" {
+		note(prefix+".backend_auth.ref", "backend_auth_providers."+rc.BackendAuth.Ref)
+	}
+}
+
+// renderOpenAPISpec replaces a route's spec_id with the spec's file, since
+// the rendered config carries no openapi.specs.
+func renderOpenAPISpec(cfg *config.Config, rc *config.RouteConfig, prefix string, note func(string, string)) {
+	id := rc.OpenAPI.SpecID
+	if id == "" {
+		return
+	}
+	for _, spec := range cfg.OpenAPI.Specs {
+		if spec.ID == id && rc.OpenAPI.SpecFile == "" {
+			rc.OpenAPI.SpecFile = spec.File
+		}
+	}
+	rc.OpenAPI.SpecID = ""
+	note(prefix+".openapi.spec_file", "openapi.specs."+id)
+}
+
+// renderTenantTiers applies tier defaults to the tenants that reference them.
+func renderTenantTiers(cfg *config.Config, note func(string, string)) {
+	names := slices.Collect(maps.Keys(cfg.Tenants.Tenants))
+	sort.Strings(names)
+	for _, name := range names {
+		tc := cfg.Tenants.Tenants[name]
+		tier, ok := cfg.Tenants.Tiers[tc.Tier]
+		if tc.Tier == "" || !ok {
+			continue
+		}
+		cfg.Tenants.Tenants[name] = tenant.MergeTenantWithTier(tc, tier)
+		note(yamlKeyPath("$.tenants.tenants", name, ""), "tenants.tiers."+tc.Tier)
+	}
+}
+
+// plainKey matches map keys that can be addressed in a comment path unquoted.
+var plainKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// yamlKeyPath returns the comment path of a map entry, or "" when the key
+// cannot be addressed.
+func yamlKeyPath(prefix, key, suffix string) string {
+	if !plainKey.MatchString(key) {
+		return ""
+	}
+	return prefix + "." + key + suffix
+}
+
+// yamlFieldPath returns the dotted YAML path of the struct field target
+// points to within the struct root points to.
+func yamlFieldPath(root, target any) string {
+	t := reflect.ValueOf(target)
+	path, _ := findFieldPath(reflect.ValueOf(root).Elem(), t.Pointer(), t.Type().Elem())
+	return path
+}
+
+func findFieldPath(v reflect.Value, addr uintptr, typ reflect.Type) (string, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		start := fv.Addr().Pointer()
+		if addr < start || addr >= start+sf.Type.Size() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		if start == addr && sf.Type == typ {
+			return name, true
+		}
+		if sf.Type.Kind() == reflect.Struct {
+			if sub, ok := findFieldPath(fv, addr, typ); ok {
+				return name + "." + sub, true
+			}
+		}
+	}
+	return "", false
+}
+
+// RenderedConfig renders the running configuration. See RenderConfig.
+func (g *Runway) RenderedConfig(provenance bool) ([]byte, error) {
+	g.mu.RLock()
+	cfg := g.config
+	g.mu.RUnlock()
+	return RenderConfig(cfg, provenance)
+}
@@ -0,0 +1,194 @@
+package runway
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/wudi/runway/config"
+)
+
+const renderTestConfig = `
+listeners:
+  - id: http
+    address: ":8080"
+    protocol: http
+
+authentication:
+  jwt:
+    enabled: true
+    secret: "a-very-long-hmac-secret-for-tests-only"
+
+security_headers:
+  enabled: true
+  x_frame_options: DENY
+  content_security_policy: "default-src 'self'"
+
+traffic_shaping:
+  throttle:
+    enabled: true
+    rate: 100
+    burst: 10
+
+upstreams:
+  users:
+    load_balancer: least_conn
+    backends:
+      - url: http://users-1:8080
+      - url: http://users-2:8080
+
+tenants:
+  enabled: true
+  key: "header:X-Tenant"
+  tiers:
+    gold:
+      priority: 2
+      metadata:
+        plan: gold
+  tenants:
+    acme:
+      tier: gold
+      metadata:
+        region: eu
+
+routes:
+  - id: users
+    path: /users
+    path_prefix: true
+    upstream: users
+    security_headers:
+      enabled: true
+      x_frame_options: SAMEORIGIN
+  - id: health
+    path: /health
+    backends:
+      - url: http://health:8080
+    traffic_shaping:
+      throttle:
+        enabled: true
+        rate: 5
+`
+
+// renderRoundTrip loads a config, renders it and loads the rendered output.
+func renderRoundTrip(t *testing.T, provenance bool) (*config.Config, *config.Config, string) {
+	t.Helper()
+	cfg, err := config.NewLoader().Parse([]byte(renderTestConfig))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	rendered, err := RenderConfig(cfg, provenance)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	reloaded, err := config.NewLoader().Parse(rendered)
+	if err != nil {
+		t.Fatalf("rendered config does not load: %v\n%s", err, rendered)
+	}
+	return cfg, reloaded, string(rendered)
+}
+
+// effectiveRoutes resolves every route through the merge features, as
+// feature setup does. Merge helpers fill constructor defaults (such as the
+// throttle max_wait) that a global-only config leaves to the constructor, so
+// routes are resolved twice to compare them with those defaults applied.
+func effectiveRoutes(cfg *config.Config) []config.RouteConfig {
+	rm := newRouteManagers(cfg, nil, nil)
+	features := buildFeatures(&rm, cfg, nil)
+	routes := make([]config.RouteConfig, len(cfg.Routes))
+	for i, rc := range cfg.Routes {
+		rc := resolveUpstreamRefs(cfg, rc)
+		for range 2 {
+			for _, f := range features {
+				if r, ok := f.(routeRenderer); ok {
+					r.renderRoute(&rc)
+				}
+			}
+		}
+		routes[i] = rc
+	}
+	return routes
+}
+
+func TestRenderConfig_RoundTrip(t *testing.T) {
+	cfg, reloaded, _ := renderRoundTrip(t, false)
+
+	// The rendered routes carry the merged config...
+	users := reloaded.Routes[0]
+	if !users.SecurityHeaders.Enabled || users.SecurityHeaders.XFrameOptions != "SAMEORIGIN" ||
+		users.SecurityHeaders.ContentSecurityPolicy != "default-src 'self'" {
+		t.Errorf("expected route security headers merged with global, got %+v", users.SecurityHeaders)
+	}
+	if !users.TrafficShaping.Throttle.Enabled || users.TrafficShaping.Throttle.Rate != 100 {
+		t.Errorf("expected global throttle on route, got %+v", users.TrafficShaping.Throttle)
+	}
+	if users.Upstream != "users" || len(users.Backends) != 0 || users.LoadBalancer != "least_conn" {
+		t.Errorf("expected upstream ref kept with inherited load balancer, got upstream=%q backends=%d lb=%q",
+			users.Upstream, len(users.Backends), users.LoadBalancer)
+	}
+	acme := reloaded.Tenants.Tenants["acme"]
+	if acme.Priority != 2 || acme.Metadata["plan"] != "gold" || acme.Metadata["region"] != "eu" {
+		t.Errorf("expected tier defaults applied to tenant, got %+v", acme)
+	}
+	if reloaded.Authentication.JWT.Secret != config.RedactedValue {
+		t.Errorf("expected masked secret, got %q", reloaded.Authentication.JWT.Secret)
+	}
+
+	// ...and loading them yields the same effective config as the original.
+	want, got := effectiveRoutes(cfg), effectiveRoutes(reloaded)
+	for i := range want {
+		for _, f := range []string{"SecurityHeaders", "TrafficShaping", "LoadBalancer", "Backends"} {
+			w, _ := yaml.Marshal(reflect.ValueOf(want[i]).FieldByName(f).Interface())
+			g, _ := yaml.Marshal(reflect.ValueOf(got[i]).FieldByName(f).Interface())
+			if string(w) != string(g) {
+				t.Errorf("route %s: effective %s drifted:\nwant %s\ngot  %s", want[i].ID, f, w, g)
+			}
+		}
+	}
+
+	// Rendering is stable: the rendered config renders to itself.
+	first, _ := RenderConfig(reloaded, false)
+	second, err := RenderConfig(mustParse(t, first), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != string(second) {
+		t.Error("rendering the rendered config changed it")
+	}
+}
+
+func TestRenderConfig_Provenance(t *testing.T) {
+	_, _, out := renderRoundTrip(t, true)
+	for _, want := range []string{
+		"# from: route, merged with global.security_headers",
+		"# from: global.traffic_shaping.throttle",
+		"# from: upstreams.users (backends: http://users-1:8080, http://users-2:8080)",
+		"# from: upstreams.users.load_balancer",
+		"# from: tenants.tiers.gold",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered config missing %q", want)
+		}
+	}
+}
+
+func TestRenderConfig_RouteSources(t *testing.T) {
+	cfg := mustParse(t, []byte(renderTestConfig))
+	cfg.RouteSources = map[string]string{"users": "routes/users.yaml"}
+	out, err := RenderConfig(cfg, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "# source: routes/users.yaml") {
+		t.Errorf("rendered config missing the route's source file:\n%s", out)
+	}
+}
+
+func mustParse(t *testing.T, data []byte) *config.Config {
+	t.Helper()
+	cfg, err := config.NewLoader().Parse(data)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return cfg
+}
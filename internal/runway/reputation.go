@@ -0,0 +1,68 @@
+package runway
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware/reputation"
+	"github.com/wudi/runway/internal/webhook"
+	"go.uber.org/zap"
+)
+
+// initReputation starts client IP reputation scoring when enabled. Blocks
+// are enforced by the global IP blocklist through g.tempBlocks.
+func (g *Runway) initReputation(cfg *config.Config) error {
+	if !cfg.Reputation.Enabled {
+		return nil
+	}
+	tr, err := reputation.New(cfg.Reputation, g.redisClient, g.tempBlocks)
+	if err != nil {
+		return fmt.Errorf("failed to initialize reputation scoring: %w", err)
+	}
+	tr.OnBlock(g.onReputationBlock)
+	g.reputationConfig = cfg.Reputation
+	g.reputation.Store(tr)
+	return nil
+}
+
+// reloadReputation applies reputation settings from a reloaded config. The
+// tracker keeps its scores when the settings are unchanged; changed settings
+// start from fresh scores. Active blocks are kept either way.
+func (g *Runway) reloadReputation(cfg *config.Config) {
+	if g.reputation.Load() != nil && reflect.DeepEqual(cfg.Reputation, g.reputationConfig) {
+		return
+	}
+	g.reputation.Store(nil)
+	if err := g.initReputation(cfg); err != nil {
+		logging.Error("Failed to reload reputation scoring", zap.Error(err))
+	}
+}
+
+// onReputationBlock logs an automatic block and emits a webhook event.
+func (g *Runway) onReputationBlock(b reputation.Block) {
+	logging.Warn("Client IP blocked by reputation scoring",
+		zap.String("ip", b.IP),
+		zap.Float64("score", b.Score),
+		zap.Int("strikes", b.Strikes),
+		zap.Duration("duration", b.Duration),
+		zap.Strings("signals", b.Signals),
+	)
+	if g.webhookDispatcher != nil {
+		g.webhookDispatcher.Emit(webhook.NewEvent(webhook.ReputationBlocked, "", map[string]interface{}{
+			"ip":       b.IP,
+			"score":    b.Score,
+			"strikes":  b.Strikes,
+			"duration": b.Duration.String(),
+			"until":    b.Until,
+			"signals":  b.Signals,
+		}))
+	}
+}
+
+// GetReputation returns the reputation tracker, or nil when reputation
+// scoring is disabled.
+func (g *Runway) GetReputation() *reputation.Tracker {
+	return g.reputation.Load()
+}
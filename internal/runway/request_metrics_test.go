@@ -0,0 +1,89 @@
+package runway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/metrics"
+)
+
+const requestMetricsConfig = `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+admin:
+  metrics:
+    requests:
+      mode: counters
+      status: class
+routes:
+  - id: quiet
+    path: /quiet
+    backends:
+      - url: BACKEND
+  - id: latency
+    path: /latency
+    backends:
+      - url: BACKEND
+    metrics:
+      mode: histogram
+`
+
+func TestRequestMetricModes(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg, err := config.NewLoader().Parse([]byte(strings.ReplaceAll(requestMetricsConfig, "BACKEND", backend.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	for _, path := range []string{"/quiet", "/latency"} {
+		rec := httptest.NewRecorder()
+		server.Runway().Handler().ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rec.Code)
+		}
+	}
+
+	snap := server.Runway().metricsCollector.Snapshot()
+	if snap.RequestsTotal["quiet|GET|2xx"] != 1 {
+		t.Errorf("expected quiet to inherit status class labels, got %v", snap.RequestsTotal)
+	}
+	if snap.RequestsTotal["latency|GET|2xx"] != 1 {
+		t.Errorf("expected latency to inherit status class labels, got %v", snap.RequestsTotal)
+	}
+	if _, ok := snap.RequestDurations["quiet"]; ok {
+		t.Error("counters route must not record a duration histogram")
+	}
+	if _, ok := snap.RequestDurations["latency"]; !ok {
+		t.Error("expected histogram for route overriding the mode")
+	}
+
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/metrics/cardinality", nil))
+	var report metrics.CardinalityReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	for _, ic := range report.Instruments {
+		if ic.Name == "runway_request_duration_seconds" && ic.LabelSets != 1 {
+			t.Errorf("expected one histogram label set, got %+v", ic)
+		}
+	}
+	if report.TotalSeries == 0 {
+		t.Error("expected a non-empty cardinality report")
+	}
+}
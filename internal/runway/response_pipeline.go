@@ -0,0 +1,179 @@
+package runway
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/goccy/go-yaml"
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+// responseStageSlots maps the stages response_pipeline_order can reorder to
+// their middleware slots.
+var responseStageSlots = map[string]string{
+	"content_negotiation":     "content_neg",
+	"response_body_generator": "resp_body_gen",
+	"field_replacer":          "field_replacer",
+	"pii_redaction":           "pii_redact",
+	"content_replacer":        "content_replacer",
+	"jmespath":                "jmespath",
+}
+
+// responseSlotStages is responseStageSlots inverted.
+var responseSlotStages = func() map[string]string {
+	m := make(map[string]string, len(responseStageSlots))
+	for stage, slot := range responseStageSlots {
+		m[slot] = stage
+	}
+	return m
+}()
+
+// reorderResponseSlots applies the route's response_pipeline_order. The
+// reorderable slots swap places among the positions they occupy; response
+// middleware runs innermost first, so the last position runs first.
+func reorderResponseSlots(slots []namedSlot, cfg config.RouteConfig) []namedSlot {
+	if len(cfg.ResponsePipelineOrder) == 0 {
+		return slots
+	}
+	var positions []int
+	bySlot := make(map[string]namedSlot, len(responseStageSlots))
+	for i, s := range slots {
+		if _, ok := responseSlotStages[s.name]; ok {
+			positions = append(positions, i)
+			bySlot[s.name] = s
+		}
+	}
+	order := config.ResponsePipelineOrder(cfg)
+	if len(positions) != len(order) {
+		return slots
+	}
+	out := slices.Clone(slots)
+	for i, stage := range order {
+		out[positions[len(positions)-1-i]] = bySlot[responseStageSlots[stage]]
+	}
+	return out
+}
+
+// responseBodyStage is a middleware slot that rewrites response bodies.
+type responseBodyStage struct {
+	config   string                       // route config key that enables it
+	settings func(config.RouteConfig) any // the config section
+}
+
+// responseBodyStages lists the slots shown by the response pipeline view.
+var responseBodyStages = map[string]responseBodyStage{
+	"response_transform":    {"transform.response.body", func(rc config.RouteConfig) any { return rc.Transform.Response.Body }},
+	"wasm_response":         {"wasm_plugins", func(rc config.RouteConfig) any { return rc.WasmPlugins }},
+	"lua_response":          {"lua", func(rc config.RouteConfig) any { return rc.Lua }},
+	"jmespath":              {"jmespath", func(rc config.RouteConfig) any { return rc.JMESPath }},
+	"content_replacer":      {"content_replacer", func(rc config.RouteConfig) any { return rc.ContentReplacer }},
+	"pii_redact":            {"pii_redaction", func(rc config.RouteConfig) any { return rc.PIIRedaction }},
+	"field_replacer":        {"field_replacer", func(rc config.RouteConfig) any { return rc.FieldReplacer }},
+	"response_field_policy": {"response_field_policy", func(rc config.RouteConfig) any { return rc.ResponseFieldPolicy }},
+	"resp_body_gen":         {"response_body_generator", func(rc config.RouteConfig) any { return rc.ResponseBodyGenerator }},
+	"error_handling":        {"error_handling", func(rc config.RouteConfig) any { return rc.ErrorHandling }},
+	"content_neg": {"content_negotiation", func(rc config.RouteConfig) any {
+		return map[string]any{"content_negotiation": rc.ContentNegotiation, "output_encoding": rc.OutputEncoding}
+	}},
+}
+
+// ResponsePipeline describes the body-modifying response stages of a route.
+type ResponsePipeline struct {
+	Route    string                           `json:"route"`
+	Order    []string                         `json:"response_pipeline_order,omitempty"` // the route's override
+	Stages   []ResponsePipelineStage          `json:"stages"`                            // in execution order
+	Warnings []config.ResponsePipelineWarning `json:"warnings"`
+}
+
+// ResponsePipelineStage is one active body-modifying stage.
+type ResponsePipelineStage struct {
+	Stage       string `json:"stage"`              // middleware name, as used by feature overrides
+	Config      string `json:"config"`             // route config key that enabled it
+	Reorderable bool   `json:"reorderable"`        // can be moved with response_pipeline_order
+	Disabled    bool   `json:"disabled,omitempty"` // switched off by a feature override
+	Settings    any    `json:"settings"`
+}
+
+// ResponsePipeline returns the active body-modifying response stages of a
+// route in the order they run, with the config that enabled each.
+func (g *Runway) ResponsePipeline(routeID string) (*ResponsePipeline, error) {
+	v, ok := g.routeStages.Load(routeID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRouteNotFound, routeID)
+	}
+	stages := v.([]routeStage)
+
+	g.mu.RLock()
+	cfg := g.config
+	g.mu.RUnlock()
+	var rc config.RouteConfig
+	for _, r := range cfg.Routes {
+		if r.ID == routeID {
+			rc = r
+			break
+		}
+	}
+
+	p := &ResponsePipeline{
+		Route:    routeID,
+		Order:    rc.ResponsePipelineOrder,
+		Stages:   []ResponsePipelineStage{},
+		Warnings: config.ResponsePipelineConflicts(rc),
+	}
+	if p.Warnings == nil {
+		p.Warnings = []config.ResponsePipelineWarning{}
+	}
+
+	// The backend_encoding and is_collection wrappers sit inside the chain
+	// and run before every slot.
+	if rc.BackendEncoding.Encoding != "" {
+		p.Stages = append(p.Stages, ResponsePipelineStage{Stage: "backend_encoding", Config: "backend_encoding", Settings: yamlSettings(rc.BackendEncoding)})
+	}
+	if rc.BackendResponse.IsCollection {
+		p.Stages = append(p.Stages, ResponsePipelineStage{Stage: "is_collection", Config: "backend_response", Settings: yamlSettings(rc.BackendResponse)})
+	}
+	for i := len(stages) - 1; i >= 0; i-- {
+		name := stages[i].name
+		st, ok := responseBodyStages[name]
+		if !ok {
+			continue
+		}
+		_, reorderable := responseSlotStages[name]
+		p.Stages = append(p.Stages, ResponsePipelineStage{
+			Stage:       name,
+			Config:      st.config,
+			Reorderable: reorderable,
+			Disabled:    g.featureOverrides.Disabled(routeID, name),
+			Settings:    yamlSettings(st.settings(rc)),
+		})
+	}
+	return p, nil
+}
+
+// yamlSettings returns v keyed by its YAML field names, as written in the
+// config file.
+func yamlSettings(v any) any {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out any
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// logResponsePipelineWarnings logs one warning per conflicting combination
+// of response body stages in cfg.
+func logResponsePipelineWarnings(cfg *config.Config) {
+	for _, w := range cfg.ResponsePipelineWarnings {
+		logging.Warn("Conflicting response body stages",
+			zap.String("route", w.Route),
+			zap.Strings("stages", w.Stages),
+			zap.String("detail", w.Message),
+		)
+	}
+}
@@ -0,0 +1,117 @@
+package runway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+func TestResponsePipeline(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"items":[{"name":"a"}]}`)
+	}))
+	defer backend.Close()
+
+	route := func(id string, order ...string) config.RouteConfig {
+		return config.RouteConfig{
+			ID:                 id,
+			Path:               "/" + id,
+			Backends:           []config.BackendConfig{{URL: backend.URL}},
+			ContentNegotiation: config.ContentNegotiationConfig{Enabled: true, Supported: []string{"json", "xml"}},
+			ResponseBodyGenerator: config.ResponseBodyGeneratorConfig{
+				Enabled: true, Template: `{{.Body}}`,
+			},
+			FieldReplacer: config.FieldReplacerConfig{Enabled: true, Operations: []config.FieldReplacerOperation{
+				{Field: "items.0.name", Type: "upper"},
+			}},
+			PIIRedaction: config.PIIRedactionConfig{Enabled: true, BuiltIns: []string{"email"}},
+			ContentReplacer: config.ContentReplacerConfig{Enabled: true, Replacements: []config.ReplacementRule{
+				{Pattern: `"items"`, Replacement: `"rows"`},
+			}},
+			JMESPath:              config.JMESPathConfig{Enabled: true, Expression: "items"},
+			ResponsePipelineOrder: order,
+		}
+	}
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{
+			route("default"),
+			route("reordered", "jmespath", "content_replacer"),
+		},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	pipeline := func(id string) ResponsePipeline {
+		w := httptest.NewRecorder()
+		server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/routes/"+id+"/response-pipeline", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", id, w.Code, w.Body)
+		}
+		var p ResponsePipeline
+		json.NewDecoder(w.Body).Decode(&p)
+		return p
+	}
+	configs := func(p ResponsePipeline) []string {
+		var out []string
+		for _, st := range p.Stages {
+			if st.Reorderable {
+				out = append(out, st.Config)
+			}
+		}
+		return out
+	}
+
+	// buildRouteHandler must keep running the stages in the documented
+	// default order.
+	p := pipeline("default")
+	if got := configs(p); !slices.Equal(got, config.ResponsePipelineStages) {
+		t.Fatalf("default execution order = %v, want %v", got, config.ResponsePipelineStages)
+	}
+	if st := p.Stages[0]; st.Stage != "content_neg" || st.Settings == nil {
+		t.Errorf("unexpected first stage %+v", st)
+	}
+
+	p = pipeline("reordered")
+	want := []string{"content_negotiation", "response_body_generator", "field_replacer", "pii_redaction", "jmespath", "content_replacer"}
+	if got := configs(p); !slices.Equal(got, want) {
+		t.Fatalf("reordered execution order = %v, want %v", got, want)
+	}
+	if !slices.Equal(p.Order, []string{"jmespath", "content_replacer"}) {
+		t.Errorf("expected the override in the view, got %v", p.Order)
+	}
+
+	// By default the replacer renames items before jmespath selects it;
+	// reordered, jmespath sees the backend body.
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		server.Runway().Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return strings.TrimSpace(w.Body.String())
+	}
+	if got := get("/default"); got != "null" {
+		t.Errorf("default: expected jmespath to find no items, got %s", got)
+	}
+	if got := get("/reordered"); got != `[{"name":"A"}]` {
+		t.Errorf("reordered: expected the selected items, got %s", got)
+	}
+
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/routes/missing/response-pipeline", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown route, got %d", w.Code)
+	}
+}
@@ -0,0 +1,56 @@
+package runway
+
+import (
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/metrics"
+	"github.com/wudi/runway/variables"
+)
+
+// routeMetadata is what var_context attaches to a route's requests from
+// its metadata. The maps are shared by all requests and never modified.
+type routeMetadata struct {
+	all     map[string]string // the route's metadata, for variables, rules and plugins
+	log     map[string]string // keys in route_metadata.log_keys, nil when none
+	headers []metadataHeader  // expose_metadata_headers
+}
+
+type metadataHeader struct {
+	name, value string
+}
+
+// newRouteMetadata precomputes the log subset and response headers of a
+// route's metadata.
+func newRouteMetadata(cfg config.RouteConfig, logKeys []string) routeMetadata {
+	md := routeMetadata{all: cfg.Metadata}
+	for _, k := range logKeys {
+		if v, ok := cfg.Metadata[k]; ok {
+			if md.log == nil {
+				md.log = make(map[string]string, len(logKeys))
+			}
+			md.log[k] = v
+		}
+	}
+	for _, k := range cfg.ExposeMetadataHeaders {
+		md.headers = append(md.headers, metadataHeader{
+			name:  metadataHeaderName(k),
+			value: cfg.Metadata[k],
+		})
+	}
+	return md
+}
+
+// metadataHeaderName returns the response header for a metadata key:
+// cost_center is sent as X-Route-Cost-Center.
+func metadataHeaderName(key string) string {
+	return "X-Route-" + variables.NormalizeHeaderName(key)
+}
+
+// publishRouteInfo replaces the runway_route_info series with the routes
+// of cfg.
+func publishRouteInfo(mc *metrics.Collector, cfg *config.Config) {
+	md := make(map[string]map[string]string, len(cfg.Routes))
+	for _, rc := range cfg.Routes {
+		md[rc.ID] = rc.Metadata
+	}
+	mc.SetRouteInfo(cfg.RouteMetadata.MetricLabels, md)
+}
@@ -0,0 +1,150 @@
+package runway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+const routeMetadataConfig = `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+route_metadata:
+  log_keys: [team]
+  metric_labels: [team, tier]
+routes:
+  - id: gold
+    path: /gold
+    backends:
+      - url: BACKEND
+    metadata:
+      team: payments
+      tier: gold
+    expose_metadata_headers: [team]
+    rules:
+      request:
+        - id: gold-only
+          expression: 'route.metadata.tier == "gold" && http.request.headers["X-Plan"] != "gold"'
+          action: block
+          status_code: 402
+  - id: api
+    path: /api
+    backends:
+      - url: BACKEND
+    metadata:
+      team: search
+      cost_center: cc_42
+    expose_metadata_headers: [cost_center]
+    transform:
+      request:
+        headers:
+          set:
+            X-Owner: "$route.metadata.team"
+`
+
+func TestRouteMetadata(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen-Owner", r.Header.Get("X-Owner"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg, err := config.NewLoader().Parse([]byte(strings.ReplaceAll(routeMetadataConfig, "BACKEND", backend.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	do := func(path, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		server.Runway().Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Rules read route.metadata; exposed keys become response headers,
+	// including on responses the gateway writes itself.
+	rec := do("/gold", "", "")
+	if rec.Code != http.StatusPaymentRequired {
+		t.Errorf("expected the tier rule to block with 402, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("X-Route-Team"); got != "payments" {
+		t.Errorf("expected X-Route-Team on the blocked response, got %q", got)
+	}
+	if rec := do("/gold", "X-Plan", "gold"); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for gold plan, got %d", rec.Code)
+	}
+
+	// Templates resolve $route.metadata.<key>; unexposed keys stay internal.
+	rec = do("/api", "", "")
+	if got := rec.Header().Get("X-Seen-Owner"); got != "search" {
+		t.Errorf("expected the backend to see X-Owner: search, got %q", got)
+	}
+	if got := rec.Header().Get("X-Route-Cost-Center"); got != "cc_42" {
+		t.Errorf("expected X-Route-Cost-Center: cc_42, got %q", got)
+	}
+	if rec.Header().Get("X-Route-Team") != "" {
+		t.Error("team is not in expose_metadata_headers for api")
+	}
+
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/routes", nil))
+	var routes []struct {
+		ID       string            `json:"id"`
+		Metadata map[string]string `json:"metadata"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&routes); err != nil {
+		t.Fatal(err)
+	}
+	listed := map[string]map[string]string{}
+	for _, r := range routes {
+		listed[r.ID] = r.Metadata
+	}
+	if listed["gold"]["tier"] != "gold" || listed["api"]["cost_center"] != "cc_42" {
+		t.Errorf("expected metadata in the route listing, got %v", listed)
+	}
+
+	w = httptest.NewRecorder()
+	server.Runway().metricsCollector.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`runway_route_info{route="gold",team="payments",tier="gold"} 1`,
+		`runway_route_info{route="api",team="search",tier=""} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	if strings.Contains(body, "cost_center") {
+		t.Error("keys outside metric_labels must not be exported")
+	}
+}
+
+func TestNewRouteMetadata(t *testing.T) {
+	md := newRouteMetadata(config.RouteConfig{
+		Metadata:              map[string]string{"team": "payments", "tier": "gold"},
+		ExposeMetadataHeaders: []string{"tier"},
+	}, []string{"team", "runbook"})
+	if len(md.log) != 1 || md.log["team"] != "payments" {
+		t.Errorf("expected only team in the log subset, got %v", md.log)
+	}
+	if len(md.headers) != 1 || md.headers[0] != (metadataHeader{"X-Route-Tier", "gold"}) {
+		t.Errorf("unexpected headers %v", md.headers)
+	}
+	if md := newRouteMetadata(config.RouteConfig{}, []string{"team"}); md.log != nil {
+		t.Errorf("expected no log subset without metadata, got %v", md.log)
+	}
+}
@@ -0,0 +1,346 @@
+package runway
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/requestqueue"
+	"github.com/wudi/runway/internal/webhook"
+	"go.uber.org/zap"
+)
+
+// Route pause modes.
+const (
+	PauseModeQueue  = "queue"  // hold requests until resume, bounded by depth and wait
+	PauseModeReject = "reject" // answer 503 with Retry-After
+)
+
+// Route resume causes, reported in webhook events.
+const (
+	PauseResumeManual  = "manual"
+	PauseResumeExpired = "expired"
+	PauseResumeReload  = "reload"
+)
+
+const (
+	defaultPauseMaxDepth = 100
+	defaultPauseMaxWait  = 30 * time.Second
+)
+
+var (
+	// ErrRoutePaused is returned when pausing a route that is already paused.
+	ErrRoutePaused = errors.New("route is already paused")
+	// ErrRouteNotPaused is returned when resuming a route that is not paused.
+	ErrRouteNotPaused = errors.New("route is not paused")
+)
+
+// RoutePauseRequest is a request to pause a route, typically sent by a
+// deployment system around a backend switchover. MaxDepth and MaxWait
+// bound the queue in queue mode; a zero TTL pauses until resumed.
+type RoutePauseRequest struct {
+	Mode       string
+	MaxDepth   int
+	MaxWait    time.Duration
+	TTL        time.Duration
+	Actor      string
+	Reason     string
+	RemoteAddr string
+}
+
+// RoutePauseState is an active pause on a route.
+type RoutePauseState struct {
+	Route      string                      `json:"route"`
+	Mode       string                      `json:"mode"`
+	MaxDepth   int                         `json:"max_depth,omitempty"`
+	MaxWait    string                      `json:"max_wait,omitempty"`
+	Actor      string                      `json:"actor,omitempty"`
+	Reason     string                      `json:"reason,omitempty"`
+	RemoteAddr string                      `json:"remote_addr"`
+	PausedAt   time.Time                   `json:"paused_at"`
+	ExpiresAt  *time.Time                  `json:"expires_at,omitempty"`
+	Queue      *requestqueue.QueueSnapshot `json:"queue,omitempty"`
+}
+
+// routePause is the runtime side of a pause. release is closed on resume
+// and wakes every held request.
+type routePause struct {
+	state   RoutePauseState
+	queue   *requestqueue.RequestQueue // nil in reject mode
+	release chan struct{}
+	timer   *time.Timer
+}
+
+// routeGate sits in every route pipeline. Unpaused, it costs one atomic load.
+type routeGate struct {
+	pause atomic.Pointer[routePause]
+}
+
+// routeGates holds the gate of each route. Gates outlive pipeline rebuilds,
+// so a pause survives config reloads; like break-glass, pauses live only
+// in memory.
+type routeGates struct {
+	mu    sync.Mutex // serializes pause transitions
+	gates map[string]*routeGate
+}
+
+// gate returns routeID's gate, creating it on first use.
+func (rg *routeGates) gate(routeID string) *routeGate {
+	rg.mu.Lock()
+	defer rg.mu.Unlock()
+	gt, ok := rg.gates[routeID]
+	if !ok {
+		if rg.gates == nil {
+			rg.gates = make(map[string]*routeGate)
+		}
+		gt = &routeGate{}
+		rg.gates[routeID] = gt
+	}
+	return gt
+}
+
+func (gt *routeGate) middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p := gt.pause.Load(); p != nil && !p.hold(w, r) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hold applies the pause to a request and reports whether it may proceed.
+func (p *routePause) hold(w http.ResponseWriter, r *http.Request) bool {
+	if p.queue != nil {
+		err := p.queue.Hold(r.Context(), p.release)
+		if err == nil {
+			return true
+		}
+		if r.Context().Err() != nil {
+			return false // client went away
+		}
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(p.retryAfter(time.Now()), 10))
+	http.Error(w, "Service Unavailable: route paused", http.StatusServiceUnavailable)
+	return false
+}
+
+// retryAfter returns the whole seconds until the pause expires, or 1 for
+// pauses without a TTL.
+func (p *routePause) retryAfter(now time.Time) int64 {
+	if p.state.ExpiresAt == nil {
+		return 1
+	}
+	return max(1, int64(math.Ceil(p.state.ExpiresAt.Sub(now).Seconds())))
+}
+
+func (p *routePause) snapshot() *RoutePauseState {
+	st := p.state
+	if p.queue != nil {
+		q := p.queue.Snapshot()
+		st.Queue = &q
+	}
+	return &st
+}
+
+// PauseRoute holds (queue mode) or rejects (reject mode) the route's
+// requests until ResumeRoute is called or the TTL expires.
+func (g *Runway) PauseRoute(routeID string, req RoutePauseRequest) (*RoutePauseState, error) {
+	if _, ok := g.routeStages.Load(routeID); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRouteNotFound, routeID)
+	}
+	if req.Mode == "" {
+		req.Mode = PauseModeQueue
+	}
+	switch {
+	case req.Mode != PauseModeQueue && req.Mode != PauseModeReject:
+		return nil, fmt.Errorf("mode must be %q or %q", PauseModeQueue, PauseModeReject)
+	case req.MaxDepth < 0 || req.MaxWait < 0 || req.TTL < 0:
+		return nil, errors.New("max_depth, max_wait and ttl must be >= 0")
+	case req.Mode == PauseModeReject && (req.MaxDepth != 0 || req.MaxWait != 0):
+		return nil, errors.New("max_depth and max_wait apply to queue mode only")
+	}
+
+	now := time.Now()
+	p := &routePause{
+		state: RoutePauseState{
+			Route:      routeID,
+			Mode:       req.Mode,
+			Actor:      req.Actor,
+			Reason:     req.Reason,
+			RemoteAddr: req.RemoteAddr,
+			PausedAt:   now,
+		},
+		release: make(chan struct{}),
+	}
+	if req.Mode == PauseModeQueue {
+		if req.MaxDepth == 0 {
+			req.MaxDepth = defaultPauseMaxDepth
+		}
+		if req.MaxWait == 0 {
+			req.MaxWait = defaultPauseMaxWait
+		}
+		p.state.MaxDepth = req.MaxDepth
+		p.state.MaxWait = req.MaxWait.String()
+		p.queue = requestqueue.New(req.MaxDepth, req.MaxWait)
+	}
+	if req.TTL > 0 {
+		expires := now.Add(req.TTL)
+		p.state.ExpiresAt = &expires
+	}
+
+	gt := g.routeGates.gate(routeID)
+	g.routeGates.mu.Lock()
+	if gt.pause.Load() != nil {
+		g.routeGates.mu.Unlock()
+		return nil, ErrRoutePaused
+	}
+	if req.TTL > 0 {
+		p.timer = time.AfterFunc(req.TTL, func() {
+			g.endRoutePause(gt, p, PauseResumeExpired, "")
+		})
+	}
+	gt.pause.Store(p)
+	g.routeGates.mu.Unlock()
+
+	g.recordRoutePause(webhook.RoutePaused, p, "", p.state.Actor)
+	return p.snapshot(), nil
+}
+
+// ResumeRoute ends a route's pause, releasing every held request.
+func (g *Runway) ResumeRoute(routeID, actor string) (*RoutePauseState, error) {
+	gt := g.routeGates.gate(routeID)
+	p := gt.pause.Load()
+	if p == nil || !g.endRoutePause(gt, p, PauseResumeManual, actor) {
+		return nil, ErrRouteNotPaused
+	}
+	return p.snapshot(), nil
+}
+
+// endRoutePause removes p if it is still the gate's pause, reporting
+// whether it did.
+func (g *Runway) endRoutePause(gt *routeGate, p *routePause, cause, actor string) bool {
+	g.routeGates.mu.Lock()
+	if !gt.pause.CompareAndSwap(p, nil) {
+		g.routeGates.mu.Unlock()
+		return false
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	close(p.release)
+	g.routeGates.mu.Unlock()
+
+	typ := webhook.RouteResumed
+	if cause == PauseResumeExpired {
+		typ = webhook.RoutePauseExpired
+	}
+	g.recordRoutePause(typ, p, cause, actor)
+	return true
+}
+
+// reconcileRoutePauses resumes pauses on routes that a reload removed and
+// forgets their gates.
+func (g *Runway) reconcileRoutePauses(live map[string]bool) {
+	g.routeGates.mu.Lock()
+	var stale []*routeGate
+	for id, gt := range g.routeGates.gates {
+		if !live[id] {
+			stale = append(stale, gt)
+			delete(g.routeGates.gates, id)
+		}
+	}
+	g.routeGates.mu.Unlock()
+	for _, gt := range stale {
+		if p := gt.pause.Load(); p != nil {
+			g.endRoutePause(gt, p, PauseResumeReload, "")
+		}
+	}
+}
+
+// stopRoutePauses stops expiry timers on shutdown and releases held
+// requests.
+func (g *Runway) stopRoutePauses() {
+	g.routeGates.mu.Lock()
+	defer g.routeGates.mu.Unlock()
+	for _, gt := range g.routeGates.gates {
+		if p := gt.pause.Swap(nil); p != nil {
+			if p.timer != nil {
+				p.timer.Stop()
+			}
+			close(p.release)
+		}
+	}
+}
+
+// RoutePauses returns the active route pauses, ordered by route.
+func (g *Runway) RoutePauses() []*RoutePauseState {
+	g.routeGates.mu.Lock()
+	out := make([]*RoutePauseState, 0)
+	for _, gt := range g.routeGates.gates {
+		if p := gt.pause.Load(); p != nil {
+			out = append(out, p.snapshot())
+		}
+	}
+	g.routeGates.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out
+}
+
+// RoutePause returns routeID's active pause, or nil.
+func (g *Runway) RoutePause(routeID string) *RoutePauseState {
+	if p := g.routeGates.gate(routeID).pause.Load(); p != nil {
+		return p.snapshot()
+	}
+	return nil
+}
+
+// recordRoutePause logs a pause change and emits a webhook event.
+func (g *Runway) recordRoutePause(typ webhook.EventType, p *routePause, cause, actor string) {
+	if actor == "" {
+		actor = p.state.Actor
+	}
+	fields := []zap.Field{
+		zap.String("event", string(typ)),
+		zap.String("route", p.state.Route),
+		zap.String("mode", p.state.Mode),
+		zap.String("actor", actor),
+		zap.String("reason", p.state.Reason),
+	}
+	data := map[string]interface{}{
+		"mode":   p.state.Mode,
+		"actor":  actor,
+		"reason": p.state.Reason,
+	}
+	if p.state.ExpiresAt != nil {
+		data["expires_at"] = p.state.ExpiresAt.Format(time.RFC3339)
+	}
+	if p.queue != nil {
+		data["max_depth"] = p.state.MaxDepth
+		data["max_wait"] = p.state.MaxWait
+	}
+	if cause != "" {
+		fields = append(fields, zap.String("cause", cause))
+		data["cause"] = cause
+		data["paused_for"] = time.Since(p.state.PausedAt).String()
+		if p.queue != nil {
+			q := p.queue.Snapshot()
+			data["held"] = q.Enqueued
+			data["timed_out"] = q.TimedOut
+		}
+	}
+	logging.Info("route pause changed", fields...)
+
+	if g.webhookDispatcher != nil {
+		g.webhookDispatcher.Emit(webhook.NewEvent(typ, p.state.Route, data))
+	}
+}
@@ -0,0 +1,279 @@
+package runway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func newPauseServer(t *testing.T, webhookURL string) *Server {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(backend.Close)
+
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{
+			{ID: "orders", Path: "/orders", Backends: []config.BackendConfig{{URL: backend.URL}}},
+			{ID: "plain", Path: "/plain", Backends: []config.BackendConfig{{URL: backend.URL}}},
+		},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+	if webhookURL != "" {
+		cfg.Webhooks = config.WebhooksConfig{
+			Enabled:   true,
+			Endpoints: []config.WebhookEndpoint{{ID: "cd", URL: webhookURL, Events: []string{"route.*"}}},
+		}
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	t.Cleanup(func() { server.Runway().Close() })
+	return server
+}
+
+func TestRoutePauseQueueUnderLoad(t *testing.T) {
+	s := newPauseServer(t, "")
+	h := s.Runway().Handler()
+
+	const workers = 20
+	var served, failed atomic.Int64
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest("GET", "/orders", nil))
+				if w.Code == http.StatusOK {
+					served.Add(1)
+				} else {
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	w := postUpstreamAction(s, "/admin/routes/orders/pause", `{"mode":"queue","max_depth":100,"max_wait":"10s","actor":"cd"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected pause, got %d: %s", w.Code, w.Body)
+	}
+
+	// Every worker ends up held in the queue.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st := s.Runway().RoutePause("orders")
+		if st.Queue.CurrentDepth == workers {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d held requests, got %+v", workers, st.Queue)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	heldAt := served.Load()
+	time.Sleep(50 * time.Millisecond)
+	if got := served.Load(); got != heldAt {
+		t.Fatalf("expected no requests served while paused, got %d more", got-heldAt)
+	}
+	pw := httptest.NewRecorder()
+	h.ServeHTTP(pw, httptest.NewRequest("GET", "/plain", nil))
+	if pw.Code != http.StatusOK {
+		t.Errorf("expected other routes to be unaffected, got %d", pw.Code)
+	}
+
+	if w := postUpstreamAction(s, "/admin/routes/orders/resume", `{"actor":"cd"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected resume, got %d: %s", w.Code, w.Body)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	if failed.Load() != 0 {
+		t.Errorf("expected zero failed requests, got %d", failed.Load())
+	}
+	if served.Load() <= heldAt+workers {
+		t.Errorf("expected the held requests and later ones to be served, got %d (%d before the pause)", served.Load(), heldAt)
+	}
+}
+
+func TestRoutePauseReject(t *testing.T) {
+	s := newPauseServer(t, "")
+	h := s.Runway().Handler()
+
+	if w := postUpstreamAction(s, "/admin/routes/orders/pause", `{"mode":"reject","ttl":"30s"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected pause, got %d: %s", w.Code, w.Body)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/orders", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "30" {
+		t.Errorf("expected 503 with Retry-After 30, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestRoutePauseQueueFull(t *testing.T) {
+	s := newPauseServer(t, "")
+	h := s.Runway().Handler()
+
+	if w := postUpstreamAction(s, "/admin/routes/orders/pause", `{"max_depth":1,"max_wait":"50ms"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected pause, got %d: %s", w.Code, w.Body)
+	}
+	held := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/orders", nil))
+		held <- w.Code
+	}()
+	for s.Runway().RoutePause("orders").Queue.CurrentDepth != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/orders", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected 503 when the queue is full, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if code := <-held; code != http.StatusServiceUnavailable {
+		t.Errorf("expected the held request to time out with 503, got %d", code)
+	}
+}
+
+func TestRoutePauseAdmin(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev struct {
+			Type string                 `json:"type"`
+			Data map[string]interface{} `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&ev)
+		mu.Lock()
+		events = append(events, ev.Type+":"+ev.Data["mode"].(string))
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	s := newPauseServer(t, hook.URL)
+	h := s.Runway().Handler()
+
+	for body, want := range map[string]int{
+		`{"mode":"drain"}`:                  http.StatusBadRequest,
+		`{"mode":"reject","max_depth":10}`:  http.StatusBadRequest,
+		`{"ttl":"soon"}`:                    http.StatusBadRequest,
+		`{"max_depth":-1}`:                  http.StatusBadRequest,
+		`{"mode":"queue","max_wait":"-1s"}`: http.StatusBadRequest,
+	} {
+		if w := postUpstreamAction(s, "/admin/routes/orders/pause", body); w.Code != want {
+			t.Errorf("%s: expected %d, got %d: %s", body, want, w.Code, w.Body)
+		}
+	}
+	if w := postUpstreamAction(s, "/admin/routes/missing/pause", ``); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown route, got %d", w.Code)
+	}
+	if w := postUpstreamAction(s, "/admin/routes/orders/resume", ``); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 when the route is not paused, got %d", w.Code)
+	}
+
+	w := postUpstreamAction(s, "/admin/routes/orders/pause", `{"mode":"reject","ttl":"150ms","actor":"cd","reason":"deploy v42"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected pause, got %d: %s", w.Code, w.Body)
+	}
+	var state RoutePauseState
+	json.NewDecoder(w.Body).Decode(&state)
+	if state.Mode != PauseModeReject || state.ExpiresAt == nil || state.Reason != "deploy v42" {
+		t.Errorf("unexpected state %+v", state)
+	}
+	if w := postUpstreamAction(s, "/admin/routes/orders/pause", `{}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 while paused, got %d", w.Code)
+	}
+
+	gw := httptest.NewRecorder()
+	s.adminHandler().ServeHTTP(gw, httptest.NewRequest("GET", "/admin/routes/orders/pause", nil))
+	if gw.Code != http.StatusOK {
+		t.Errorf("expected the active pause, got %d", gw.Code)
+	}
+	ow := httptest.NewRecorder()
+	s.adminHandler().ServeHTTP(ow, httptest.NewRequest("GET", "/admin/overrides", nil))
+	var overrides struct {
+		PausedRoutes []RoutePauseState `json:"paused_routes"`
+	}
+	json.NewDecoder(ow.Body).Decode(&overrides)
+	if len(overrides.PausedRoutes) != 1 || overrides.PausedRoutes[0].Route != "orders" {
+		t.Errorf("expected the pause in /admin/overrides, got %+v", overrides)
+	}
+
+	// The pause expires at its TTL.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/orders", nil))
+		if w.Code == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the pause to expire")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(s.Runway().RoutePauses()) != 0 {
+		t.Error("expected no pauses after expiry")
+	}
+
+	// Manual resume.
+	if w := postUpstreamAction(s, "/admin/routes/orders/pause", `{"actor":"cd"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected pause, got %d: %s", w.Code, w.Body)
+	}
+	if w := postUpstreamAction(s, "/admin/routes/orders/resume", `{"actor":"cd"}`); w.Code != http.StatusOK {
+		t.Errorf("expected resume, got %d: %s", w.Code, w.Body)
+	}
+
+	want := "route.pause_expired:reject,route.paused:queue,route.paused:reject,route.resumed:queue"
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		got := slices.Clone(events)
+		mu.Unlock()
+		sort.Strings(got)
+		if strings.Join(got, ",") == want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected webhook events %s, got %v", want, got)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRoutePauseReload(t *testing.T) {
+	s := newPauseServer(t, "")
+	if _, err := s.Runway().PauseRoute("plain", RoutePauseRequest{Mode: PauseModeReject}); err != nil {
+		t.Fatal(err)
+	}
+	s.Runway().reconcileRoutePauses(map[string]bool{"orders": true})
+	if len(s.Runway().RoutePauses()) != 0 {
+		t.Error("expected the pause of a removed route to end")
+	}
+}
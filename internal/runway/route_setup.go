@@ -4,15 +4,20 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/graphql"
 	"github.com/wudi/runway/internal/health"
 	"github.com/wudi/runway/internal/loadbalancer"
 	"github.com/wudi/runway/internal/logging"
 	"github.com/wudi/runway/internal/middleware/ratelimit"
 	"github.com/wudi/runway/internal/middleware/sse"
+	"github.com/wudi/runway/internal/middleware/streamlimit"
 	"github.com/wudi/runway/internal/proxy"
-	"github.com/wudi/runway/internal/registry"
+	"github.com/wudi/runway/internal/proxy/aggregate"
+	"github.com/wudi/runway/internal/proxy/fallback"
+	"github.com/wudi/runway/internal/proxy/sequential"
 	"github.com/wudi/runway/internal/router"
 	"go.uber.org/zap"
 )
@@ -26,12 +31,27 @@ type routeSetup struct {
 	rm              *routeManagers
 	features        []Feature
 	registerBackend func(health.Backend)
-	watchService    func(routeID, serviceName string, tags []string)
+	watchService    func(routeID string, svc config.ServiceConfig, localZone string)
 	storeProxy      func(routeID string, rp *proxy.RouteProxy)
 	buildHandler    func(routeID string, cfg config.RouteConfig, route *router.Route, rp *proxy.RouteProxy) http.Handler
 	storeHandler    func(routeID string, h http.Handler)
+
+	// dryRun builds the route for validation only: DNS and service
+	// discovery are skipped, stream limiters are not shared, and nothing
+	// is started (canary auto-start, SSE hubs, external features).
+	dryRun bool
+}
+
+// featureError attributes a route setup error to the feature that caused
+// it. Its message is err's.
+type featureError struct {
+	feature string
+	err     error
 }
 
+func (e *featureError) Error() string { return e.err.Error() }
+func (e *featureError) Unwrap() error { return e.err }
+
 // setupRoute adds a single route using the shared logic. It is called by both
 // addRoute (initial startup) and addRouteForState (config reload).
 func (g *Runway) setupRoute(rs *routeSetup, routeCfg config.RouteConfig) error {
@@ -52,25 +72,23 @@ func (g *Runway) setupRoute(rs *routeSetup, routeCfg config.RouteConfig) error {
 	if !routeCfg.Echo && !routeCfg.Sequential.Enabled && !routeCfg.Aggregate.Enabled && !routeCfg.AI.Enabled {
 		var backends []*loadbalancer.Backend
 
-		if routeCfg.Service.Name != "" {
+		var dnsFailover *upstreamFailover
+		if !rs.dryRun {
+			dnsFailover = g.upstreamFailover(rs.rm.upstreamDNS, rs.cfg, routeCfg.Upstream)
+		}
+		if dnsFailover != nil {
+			backends = dnsFailover.backends(g, routeCfg.ID)
+		} else if routeCfg.Service.Name != "" && !rs.dryRun {
 			ctx := context.Background()
-			services, err := g.registry.DiscoverWithTags(ctx, routeCfg.Service.Name, routeCfg.Service.Tags)
+			services, err := g.discoverService(ctx, routeCfg.Service)
 			if err != nil {
 				logging.Warn("Failed to discover service",
 					zap.String("service", routeCfg.Service.Name),
 					zap.Error(err),
 				)
 			}
-			for _, svc := range services {
-				b := &loadbalancer.Backend{
-					URL:     svc.URL(),
-					Weight:  1,
-					Healthy: svc.Health == registry.HealthPassing,
-				}
-				b.InitParsedURL()
-				backends = append(backends, b)
-			}
-			rs.watchService(routeCfg.ID, routeCfg.Service.Name, routeCfg.Service.Tags)
+			backends = g.serviceBackends(routeCfg.ID, routeCfg.Service, rs.cfg.Registry.LocalZone, services)
+			rs.watchService(routeCfg.ID, routeCfg.Service, rs.cfg.Registry.LocalZone)
 		} else {
 			usHC := upstreamHCConfig(rs.cfg, routeCfg.Upstream)
 			backends = buildBackends(routeCfg.Backends, rs.registerBackend, rs.cfg.HealthCheck, usHC)
@@ -108,7 +126,11 @@ func (g *Runway) setupRoute(rs *routeSetup, routeCfg config.RouteConfig) error {
 			}
 			routeProxy = proxy.NewRouteProxyWithBalancer(g.proxy, route, bal)
 		}
+		g.applyBackendDrains(routeCfg.ID, routeProxy)
 		rs.storeProxy(routeCfg.ID, routeProxy)
+		if dnsFailover != nil {
+			dnsFailover.addProxy(routeProxy)
+		}
 
 		// Wire shared retry budget pool
 		if routeCfg.RetryPolicy.BudgetPool != "" {
@@ -119,6 +141,16 @@ func (g *Runway) setupRoute(rs *routeSetup, routeCfg config.RouteConfig) error {
 	}
 
 	// Rate limiting (unique setup signature, not in feature loop)
+	costFn := rateLimitCostFunc(rs.rm, routeCfg)
+	observe := routeCfg.RateLimit.Mode == "observe"
+	headers := ratelimit.HeaderConfig{
+		Disabled: !routeCfg.RateLimit.HeadersEnabled(),
+		Legacy:   routeCfg.RateLimit.LegacyHeaders,
+	}
+	var onObserved func(tier string)
+	if observe {
+		onObserved = func(tier string) { g.metricsCollector.RecordRateLimitObservedReject(routeCfg.ID, tier) }
+	}
 	if len(routeCfg.RateLimit.Tiers) > 0 {
 		tiers := make(map[string]ratelimit.Config, len(routeCfg.RateLimit.Tiers))
 		for name, tc := range routeCfg.RateLimit.Tiers {
@@ -130,44 +162,104 @@ func (g *Runway) setupRoute(rs *routeSetup, routeCfg config.RouteConfig) error {
 		}
 		var keyFn func(*http.Request) string
 		if routeCfg.RateLimit.Key != "" {
-			keyFn = ratelimit.BuildKeyFunc(false, routeCfg.RateLimit.Key)
+			keyFn = ratelimit.BuildIPKeyFunc(false, routeCfg.RateLimit.Key, routeCfg.RateLimit.IPv6AggregationPrefix)
 		}
 		rs.rm.rateLimiters.AddRouteTiered(routeCfg.ID, ratelimit.TieredConfig{
 			Tiers:       tiers,
 			TierKey:     routeCfg.RateLimit.TierKey,
 			DefaultTier: routeCfg.RateLimit.DefaultTier,
 			KeyFn:       keyFn,
+			IPv6Prefix:  routeCfg.RateLimit.IPv6AggregationPrefix,
+			Cost:        costFn,
+			MaxCost:     routeCfg.RateLimit.MaxCost,
+			Headers:     headers,
+
+			Observe:          observe,
+			OnObservedReject: onObserved,
 		})
 	} else if routeCfg.RateLimit.Enabled || routeCfg.RateLimit.Rate > 0 {
 		if routeCfg.RateLimit.Mode == "distributed" && g.redisClient != nil {
 			rs.rm.rateLimiters.AddRouteDistributed(routeCfg.ID, ratelimit.RedisLimiterConfig{
-				Client: g.redisClient,
-				Prefix: "gw:rl:" + routeCfg.ID + ":",
-				Rate:   routeCfg.RateLimit.Rate,
-				Period: routeCfg.RateLimit.Period,
-				Burst:  routeCfg.RateLimit.Burst,
-				PerIP:  routeCfg.RateLimit.PerIP,
-				Key:    routeCfg.RateLimit.Key,
+				Client:     g.redisClient,
+				Prefix:     "gw:rl:" + routeCfg.ID + ":",
+				Rate:       routeCfg.RateLimit.Rate,
+				Period:     routeCfg.RateLimit.Period,
+				Burst:      routeCfg.RateLimit.Burst,
+				PerIP:      routeCfg.RateLimit.PerIP,
+				Key:        routeCfg.RateLimit.Key,
+				IPv6Prefix: routeCfg.RateLimit.IPv6AggregationPrefix,
+				Cost:       costFn,
+				MaxCost:    routeCfg.RateLimit.MaxCost,
+				Headers:    headers,
 			})
 		} else if routeCfg.RateLimit.Algorithm == "sliding_window" {
 			rs.rm.rateLimiters.AddRouteSlidingWindow(routeCfg.ID, ratelimit.Config{
-				Rate:   routeCfg.RateLimit.Rate,
-				Period: routeCfg.RateLimit.Period,
-				Burst:  routeCfg.RateLimit.Burst,
-				PerIP:  routeCfg.RateLimit.PerIP,
-				Key:    routeCfg.RateLimit.Key,
+				Rate:       routeCfg.RateLimit.Rate,
+				Period:     routeCfg.RateLimit.Period,
+				Burst:      routeCfg.RateLimit.Burst,
+				PerIP:      routeCfg.RateLimit.PerIP,
+				Key:        routeCfg.RateLimit.Key,
+				IPv6Prefix: routeCfg.RateLimit.IPv6AggregationPrefix,
+				Cost:       costFn,
+				MaxCost:    routeCfg.RateLimit.MaxCost,
+				Headers:    headers,
+
+				Observe:          observe,
+				OnObservedReject: onObserved,
 			})
 		} else {
 			rs.rm.rateLimiters.AddRoute(routeCfg.ID, ratelimit.Config{
-				Rate:   routeCfg.RateLimit.Rate,
-				Period: routeCfg.RateLimit.Period,
-				Burst:  routeCfg.RateLimit.Burst,
-				PerIP:  routeCfg.RateLimit.PerIP,
-				Key:    routeCfg.RateLimit.Key,
+				Rate:       routeCfg.RateLimit.Rate,
+				Period:     routeCfg.RateLimit.Period,
+				Burst:      routeCfg.RateLimit.Burst,
+				PerIP:      routeCfg.RateLimit.PerIP,
+				Key:        routeCfg.RateLimit.Key,
+				IPv6Prefix: routeCfg.RateLimit.IPv6AggregationPrefix,
+				Cost:       costFn,
+				MaxCost:    routeCfg.RateLimit.MaxCost,
+				Headers:    headers,
+
+				Observe:          observe,
+				OnObservedReject: onObserved,
 			})
 		}
 	}
 
+	// Consumer groups with a rate_limit replace the route's rate, keeping
+	// its key, cost and mode
+	if rs.rm.consumerGroups.GetManager() != nil {
+		distributed := routeCfg.RateLimit.Mode == "distributed" && g.redisClient != nil
+		rs.rm.rateLimiters.SetGroupLimits(routeCfg.ID, func(group string, rate int) ratelimit.RateLimitMiddleware {
+			if distributed {
+				return ratelimit.NewRedisLimiter(ratelimit.RedisLimiterConfig{
+					Client:     g.redisClient,
+					Prefix:     "gw:rl:" + routeCfg.ID + ":group:" + group + ":",
+					Rate:       rate,
+					Period:     time.Second,
+					PerIP:      routeCfg.RateLimit.PerIP,
+					Key:        routeCfg.RateLimit.Key,
+					IPv6Prefix: routeCfg.RateLimit.IPv6AggregationPrefix,
+					Cost:       costFn,
+					MaxCost:    routeCfg.RateLimit.MaxCost,
+					Headers:    headers,
+				})
+			}
+			return ratelimit.NewLimiter(ratelimit.Config{
+				Rate:       rate,
+				Period:     time.Second,
+				PerIP:      routeCfg.RateLimit.PerIP,
+				Key:        routeCfg.RateLimit.Key,
+				IPv6Prefix: routeCfg.RateLimit.IPv6AggregationPrefix,
+				Cost:       costFn,
+				MaxCost:    routeCfg.RateLimit.MaxCost,
+				Headers:    headers,
+
+				Observe:          observe,
+				OnObservedReject: onObserved,
+			})
+		})
+	}
+
 	// gRPC handler
 	if routeCfg.GRPC.Enabled {
 		rs.rm.grpcHandlers.AddRoute(routeCfg.ID, routeCfg.GRPC)
@@ -187,7 +279,7 @@ func (g *Runway) setupRoute(rs *routeSetup, routeCfg config.RouteConfig) error {
 	// GraphQL federation
 	if routeCfg.GraphQLFederation.Enabled {
 		if err := rs.rm.federationHandlers.AddRoute(routeCfg.ID, routeCfg.GraphQLFederation, nil); err != nil {
-			return fmt.Errorf("graphql federation: route %s: %w", routeCfg.ID, err)
+			return &featureError{"graphql_federation", fmt.Errorf("graphql federation: route %s: %w", routeCfg.ID, err)}
 		}
 	}
 
@@ -195,21 +287,24 @@ func (g *Runway) setupRoute(rs *routeSetup, routeCfg config.RouteConfig) error {
 	if routeCfg.Protocol.Type != "" && routeProxy != nil {
 		bal := routeProxy.GetBalancer()
 		if err := rs.rm.translators.AddRoute(routeCfg.ID, routeCfg.Protocol, bal); err != nil {
-			return fmt.Errorf("protocol translator: route %s: %w", routeCfg.ID, err)
+			return &featureError{"protocol", fmt.Errorf("protocol translator: route %s: %w", routeCfg.ID, err)}
 		}
 	}
 
 	// Generic features
 	for _, f := range rs.features {
 		if err := f.Setup(routeCfg.ID, routeCfg); err != nil {
-			return fmt.Errorf("feature %s: route %s: %w", f.Name(), routeCfg.ID, err)
+			return &featureError{f.Name(), fmt.Errorf("feature %s: route %s: %w", f.Name(), routeCfg.ID, err)}
 		}
 	}
 
 	// External features (from public builder)
 	for _, ef := range g.externalFeatures {
+		if rs.dryRun {
+			break
+		}
 		if err := ef.Feature.Setup(routeCfg.ID, routeCfg); err != nil {
-			return fmt.Errorf("external feature %s: route %s: %w", ef.Feature.Name(), routeCfg.ID, err)
+			return &featureError{ef.Feature.Name(), fmt.Errorf("external feature %s: route %s: %w", ef.Feature.Name(), routeCfg.ID, err)}
 		}
 	}
 
@@ -217,8 +312,13 @@ func (g *Runway) setupRoute(rs *routeSetup, routeCfg config.RouteConfig) error {
 	if routeCfg.Sequential.Enabled {
 		transport := g.proxy.GetTransportPool().Get(routeCfg.Upstream)
 		ch := routeCfg.CompletionHeader || rs.cfg.CompletionHeader
-		if err := rs.rm.sequentialHandlers.AddRoute(routeCfg.ID, routeCfg.Sequential, transport, ch); err != nil {
-			return fmt.Errorf("sequential: route %s: %w", routeCfg.ID, err)
+		opts := sequential.Options{
+			CompletionHeader: ch,
+			RequestTimeout:   routeCfg.TimeoutPolicy.Request,
+			DetailedErrors:   routeCfg.ErrorHandling.Mode == "detailed",
+		}
+		if err := rs.rm.sequentialHandlers.AddRoute(routeCfg.ID, routeCfg.Sequential, transport, opts); err != nil {
+			return &featureError{"sequential", fmt.Errorf("sequential: route %s: %w", routeCfg.ID, err)}
 		}
 	}
 
@@ -226,32 +326,57 @@ func (g *Runway) setupRoute(rs *routeSetup, routeCfg config.RouteConfig) error {
 	if routeCfg.Aggregate.Enabled {
 		transport := g.proxy.GetTransportPool().Get(routeCfg.Upstream)
 		ch := routeCfg.CompletionHeader || rs.cfg.CompletionHeader
-		if err := rs.rm.aggregateHandlers.AddRoute(routeCfg.ID, routeCfg.Aggregate, transport, ch); err != nil {
-			return fmt.Errorf("aggregate: route %s: %w", routeCfg.ID, err)
+		opts := aggregate.Options{
+			CompletionHeader: ch,
+			RequestTimeout:   routeCfg.TimeoutPolicy.Request,
+			DetailedErrors:   routeCfg.ErrorHandling.Mode == "detailed",
+			Secrets:          rs.rm.secrets,
+			BackendAuth:      rs.rm.backendAuths,
+		}
+		if err := rs.rm.aggregateHandlers.AddRoute(routeCfg.ID, routeCfg.Aggregate, transport, opts); err != nil {
+			return &featureError{"aggregate", fmt.Errorf("aggregate: route %s: %w", routeCfg.ID, err)}
 		}
 	}
 
 	// Lambda backend handler
 	if routeCfg.Lambda.Enabled {
 		if err := rs.rm.lambdaHandlers.AddRoute(routeCfg.ID, routeCfg.Lambda); err != nil {
-			return fmt.Errorf("lambda: route %s: %w", routeCfg.ID, err)
+			return &featureError{"lambda", fmt.Errorf("lambda: route %s: %w", routeCfg.ID, err)}
 		}
 	}
 
 	// AMQP backend handler
 	if routeCfg.AMQP.Enabled {
 		if err := rs.rm.amqpHandlers.AddRoute(routeCfg.ID, routeCfg.AMQP); err != nil {
-			return fmt.Errorf("amqp: route %s: %w", routeCfg.ID, err)
+			return &featureError{"amqp", fmt.Errorf("amqp: route %s: %w", routeCfg.ID, err)}
 		}
 	}
 
 	// PubSub backend handler
 	if routeCfg.PubSub.Enabled {
 		if err := rs.rm.pubsubHandlers.AddRoute(routeCfg.ID, routeCfg.PubSub); err != nil {
-			return fmt.Errorf("pubsub: route %s: %w", routeCfg.ID, err)
+			return &featureError{"pubsub", fmt.Errorf("pubsub: route %s: %w", routeCfg.ID, err)}
+		}
+	}
+
+	// Kafka backend handler
+	if routeCfg.Kafka.Enabled {
+		if err := rs.rm.kafkaHandlers.AddRoute(routeCfg.ID, routeCfg.Kafka); err != nil {
+			return &featureError{"kafka", fmt.Errorf("kafka: route %s: %w", routeCfg.ID, err)}
 		}
 	}
 
+	// Fallback target for a failing translator, lambda or amqp handler
+	if fb := fallback.ConfigFor(routeCfg); fb.Enabled {
+		usHC := upstreamHCConfig(rs.cfg, fb.Upstream)
+		backends := buildBackends(fb.Backends, rs.registerBackend, rs.cfg.HealthCheck, usHC)
+		target := proxy.NewRouteProxyWithBalancer(g.proxy, route, createBalancerForBackends(routeCfg, backends))
+		routeID := routeCfg.ID
+		rs.rm.handlerFallbacks.AddRoute(routeID, fb, target, func(trigger, outcome string) {
+			g.metricsCollector.RecordHandlerFallback(routeID, trigger, outcome)
+		})
+	}
+
 	// Override per-try timeout with backend timeout
 	if routeCfg.TimeoutPolicy.Backend > 0 && routeProxy != nil {
 		routeProxy.SetPerTryTimeout(routeCfg.TimeoutPolicy.Backend)
@@ -261,12 +386,12 @@ func (g *Runway) setupRoute(rs *routeSetup, routeCfg config.RouteConfig) error {
 	if routeCfg.Canary.Enabled && routeProxy != nil {
 		if wb, ok := routeProxy.GetBalancer().(*loadbalancer.WeightedBalancer); ok {
 			if err := rs.rm.canaryControllers.AddRoute(routeCfg.ID, routeCfg.Canary, wb); err != nil {
-				return fmt.Errorf("canary: route %s: %w", routeCfg.ID, err)
+				return &featureError{"canary", fmt.Errorf("canary: route %s: %w", routeCfg.ID, err)}
 			}
-			if routeCfg.Canary.AutoStart {
+			if routeCfg.Canary.AutoStart && !rs.dryRun {
 				if ctrl := rs.rm.canaryControllers.Lookup(routeCfg.ID); ctrl != nil {
 					if err := ctrl.Start(); err != nil {
-						return fmt.Errorf("canary auto-start: route %s: %w", routeCfg.ID, err)
+						return &featureError{"canary", fmt.Errorf("canary auto-start: route %s: %w", routeCfg.ID, err)}
 					}
 				}
 			}
@@ -297,12 +422,37 @@ func (g *Runway) setupRoute(rs *routeSetup, routeCfg config.RouteConfig) error {
 		rs.rm.backpressureHandlers.AddRoute(routeCfg.ID, routeCfg.Backpressure, routeProxy.GetBalancer())
 	}
 
+	// Stream limits (needs balancer from routeProxy)
+	var gate *streamlimit.Gate
+	if routeProxy != nil {
+		limiters := g.streamLimiters
+		if rs.dryRun {
+			limiters = streamlimit.NewRegistry()
+		}
+		if gate = streamGate(limiters, rs.cfg, routeCfg); gate != nil {
+			rs.rm.streamGates.Add(routeCfg.ID, gate)
+		}
+	}
+
 	// SSE fan-out hub (needs balancer from routeProxy)
-	if routeCfg.SSE.Enabled && routeCfg.SSE.Fanout.Enabled && routeProxy != nil {
-		hub := sse.NewHub(routeCfg.SSE.Fanout, routeProxy.GetBalancer())
+	if routeCfg.SSE.Enabled && routeProxy != nil && !rs.dryRun {
 		if sh := rs.rm.sseHandlers.Lookup(routeCfg.ID); sh != nil {
-			sh.SetHub(hub)
-			hub.Start()
+			switch {
+			case routeCfg.SSE.Fanout.Enabled:
+				hub := sse.NewHub(routeCfg.SSE.Fanout, routeProxy.GetBalancer())
+				if gate != nil {
+					hub.SetStreamGate(gate)
+				}
+				sh.SetHub(hub)
+				hub.Start()
+			case gate != nil:
+				var overflow *sse.Hub
+				if gate.Fanout() {
+					overflow = sse.NewHub(routeCfg.SSE.Fanout, routeProxy.GetBalancer())
+					overflow.SetStreamGate(gate)
+				}
+				sh.SetStreamGate(gate, routeProxy.GetBalancer, overflow)
+			}
 		}
 	}
 
@@ -313,6 +463,64 @@ func (g *Runway) setupRoute(rs *routeSetup, routeCfg config.RouteConfig) error {
 	return nil
 }
 
+// rateLimitCostFunc returns the number of tokens a request debits from the
+// route's rate limit, or nil when every request costs one token.
+func rateLimitCostFunc(rm *routeManagers, routeCfg config.RouteConfig) func(*http.Request) int {
+	rl := routeCfg.RateLimit
+	switch rl.CostSource {
+	case "fixed":
+		cost := rl.Cost
+		if cost <= 0 {
+			cost = 1
+		}
+		return func(*http.Request) int { return cost }
+	case "request_cost":
+		rc := routeCfg.RequestCost
+		cost := rc.Cost
+		if cost == 0 {
+			cost = 1
+		}
+		return func(r *http.Request) int {
+			if mc, ok := rc.CostByMethod[r.Method]; ok {
+				return mc
+			}
+			return cost
+		}
+	case "graphql_complexity":
+		// Evaluated in the rate_limit_cost slot, after the GraphQL parser.
+		return func(r *http.Request) int {
+			if batch := graphql.GetBatchInfo(r.Context()); batch != nil {
+				total := 0
+				for _, info := range batch.Queries {
+					total += info.Complexity
+				}
+				return total
+			}
+			if info := graphql.GetInfo(r.Context()); info != nil {
+				return info.Complexity
+			}
+			return 1
+		}
+	case "openapi_weight":
+		routeID := routeCfg.ID
+		weights := rl.OperationWeights
+		return func(*http.Request) int {
+			ov := rm.openapiValidators.Lookup(routeID)
+			if ov == nil {
+				return 1
+			}
+			if w, ok := weights[ov.OperationID()]; ok {
+				return w
+			}
+			if w, ok := ov.RateLimitWeight(); ok {
+				return w
+			}
+			return 1
+		}
+	}
+	return nil
+}
+
 // buildBackends constructs loadbalancer Backends from config and registers
 // each with the health checker. This eliminates the repeated weight-defaulting
 // and InitParsedURL loop that appeared in three places (standard, versioned,
@@ -342,3 +550,22 @@ func upstreamHCConfig(cfg *config.Config, upstreamName string) *config.HealthChe
 	}
 	return nil
 }
+
+// streamGate returns the stream gate of an SSE or WebSocket route, or nil
+// when neither the route nor its upstream sets max_upstream_streams. The
+// limiters come from limiters, which is g.streamLimiters outside dry runs
+// so streams opened before a reload still count.
+func streamGate(limiters *streamlimit.Registry, cfg *config.Config, rc config.RouteConfig) *streamlimit.Gate {
+	if !rc.SSE.Enabled && !rc.WebSocket.Enabled {
+		return nil
+	}
+	var upstream *streamlimit.Limiter
+	if us, ok := cfg.Upstreams[rc.Upstream]; ok && us.MaxUpstreamStreams > 0 {
+		upstream = limiters.Get("upstream:"+rc.Upstream, us.MaxUpstreamStreams)
+	}
+	if rc.StreamLimits.MaxUpstreamStreams == 0 && upstream == nil {
+		return nil
+	}
+	route := limiters.Get("route:"+rc.ID, rc.StreamLimits.MaxUpstreamStreams)
+	return streamlimit.New(rc.StreamLimits, route, upstream, rc.Upstream)
+}
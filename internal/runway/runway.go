@@ -6,11 +6,13 @@ import (
 	"net"
 	"net/http"
 	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/wudi/runway/internal/byroute"
+	"github.com/wudi/runway/internal/featureflags"
 	"github.com/wudi/runway/internal/logging"
 	"go.uber.org/zap"
 
@@ -22,6 +24,11 @@ import (
 	"github.com/wudi/runway/internal/canary"
 	"github.com/wudi/runway/internal/catalog"
 	"github.com/wudi/runway/internal/circuitbreaker"
+	"github.com/wudi/runway/internal/clock"
+	"github.com/wudi/runway/internal/configdrift"
+	"github.com/wudi/runway/internal/configsnapshot"
+	"github.com/wudi/runway/internal/debugtrace"
+	"github.com/wudi/runway/internal/egress"
 	"github.com/wudi/runway/internal/errors"
 	"github.com/wudi/runway/internal/graphql"
 	"github.com/wudi/runway/internal/health"
@@ -36,24 +43,33 @@ import (
 	"github.com/wudi/runway/internal/middleware/backpressure"
 	"github.com/wudi/runway/internal/middleware/debug"
 	"github.com/wudi/runway/internal/middleware/errorpages"
-	"github.com/wudi/runway/internal/middleware/extauth"
 	"github.com/wudi/runway/internal/middleware/httpsredirect"
 	"github.com/wudi/runway/internal/middleware/idempotency"
+	"github.com/wudi/runway/internal/middleware/ipblocklist"
 	"github.com/wudi/runway/internal/middleware/loadshed"
+	"github.com/wudi/runway/internal/middleware/warmup"
 	"github.com/wudi/runway/internal/middleware/luascript"
 	"github.com/wudi/runway/internal/middleware/maintenance"
+	"github.com/wudi/runway/internal/middleware/synthetic"
+	"github.com/wudi/runway/internal/middleware/degraded"
 	"github.com/wudi/runway/internal/middleware/mtls"
 	"github.com/wudi/runway/internal/middleware/nonce"
 	openapivalidation "github.com/wudi/runway/internal/middleware/openapi"
+	"github.com/wudi/runway/internal/middleware/problem"
 	"github.com/wudi/runway/internal/middleware/ratelimit"
+	"github.com/wudi/runway/internal/middleware/reputation"
 	"github.com/wudi/runway/internal/middleware/requestqueue"
 	"github.com/wudi/runway/internal/middleware/serviceratelimit"
+	"github.com/wudi/runway/internal/middleware/spillbuf"
 	"github.com/wudi/runway/internal/middleware/sse"
 	"github.com/wudi/runway/internal/middleware/ssrf"
+	"github.com/wudi/runway/internal/middleware/streamlimit"
 	"github.com/wudi/runway/internal/middleware/tokenrevoke"
 	"github.com/wudi/runway/internal/middleware/transform"
+	"github.com/wudi/runway/internal/middleware/waf"
 	wasmPlugin "github.com/wudi/runway/internal/middleware/wasm"
 	"github.com/wudi/runway/internal/mirror"
+	"github.com/wudi/runway/internal/peering"
 	"github.com/wudi/runway/internal/proxy"
 	"github.com/wudi/runway/internal/proxy/protocol"
 	_ "github.com/wudi/runway/internal/proxy/protocol/graphql"
@@ -67,11 +83,14 @@ import (
 	"github.com/wudi/runway/internal/router"
 	"github.com/wudi/runway/internal/rules"
 	"github.com/wudi/runway/internal/schemaevolution"
+	"github.com/wudi/runway/internal/storecrypt"
 	"github.com/wudi/runway/internal/tracing"
 	"github.com/wudi/runway/internal/trafficreplay"
 	"github.com/wudi/runway/internal/trafficshape"
+	"github.com/wudi/runway/internal/tlsscan"
 	"github.com/wudi/runway/internal/webhook"
 	"github.com/wudi/runway/internal/websocket"
+	"github.com/wudi/runway/internal/xmlsafe"
 	"github.com/wudi/runway/variables"
 )
 
@@ -93,7 +112,6 @@ type Runway struct {
 	routeManagers
 
 	// Shared infrastructure (persists across reloads)
-	wsProxy           *websocket.Proxy
 	metricsCollector  *metrics.Collector
 	tracer            *tracing.Tracer
 	redisClient       *redis.Client // shared Redis client for distributed features
@@ -109,6 +127,44 @@ type Runway struct {
 	ssrfDialer      *ssrf.SafeDialer
 	http3AltSvcPort string // port for Alt-Svc header; empty = no HTTP/3
 	loadShedder     *loadshed.LoadShedder
+	warmer          atomic.Pointer[warmup.Warmer]    // kept across reloads; nil when warm-up is disabled
+	globalErrors    atomic.Pointer[problem.Renderer] // routeManagers.globalErrors, read per request by the listener chain
+	egressPolicy    atomic.Pointer[egress.Policy]    // kept across unchanged reloads; nil when the egress policy is disabled
+
+	// Runtime overrides, kept across reloads
+	featureOverrides *featureflags.Overrides
+	featureFlags     *featureflags.Watcher // nil when feature_flags is disabled
+	routeSlotNames   sync.Map              // routeID -> map[string]bool of active middleware slots
+	routeStages      sync.Map              // routeID -> []routeStage, for request simulation
+	drains           backendDrains         // backends drained through the admin API
+
+	depMonitor atomic.Pointer[health.DependencyMonitor] // nil when dependency health is disabled
+	tlsScanner    atomic.Pointer[tlsscan.Scanner] // nil when the backend TLS scan is disabled
+	tlsScanConfig config.BackendTLSScanConfig       // settings of the running scanner
+
+	tempBlocks       *ipblocklist.TempBlocks             // reputation blocks, kept across reloads
+	streamLimiters   *streamlimit.Registry               // open SSE/WebSocket streams per backend, kept across reloads
+	reputation       atomic.Pointer[reputation.Tracker] // nil when reputation scoring is disabled
+	reputationConfig config.ReputationConfig           // settings of the running tracker
+
+	configHash        configHashCache                      // hash of the running config
+	configDrift       atomic.Pointer[configdrift.Detector] // nil when config drift detection is disabled
+	configDriftConfig config.ConfigDriftConfig             // settings of the running detector
+
+	configSnapshots       atomic.Pointer[configsnapshot.Manager] // nil when config snapshots are disabled
+	configSnapshotsConfig config.ConfigSnapshotsConfig            // settings of the running manager
+
+	rateLimitState       atomic.Pointer[ratelimit.Persister] // nil when rate limit state is disabled
+	rateLimitStateConfig config.RateLimitStateConfig         // settings of the running persister
+
+	testClock *clock.Fake // frozen clock advanced by the admin API; nil unless test_mode is enabled
+
+	upstreamSwaps upstreamSwaps      // admin API upstream swaps and their rollback slots
+	breakGlass    breakGlass         // active emergency bypasses, in memory only
+	debugTraces   *debugtrace.Tracer // targeted request tracing sessions, in memory only
+	routeGates    routeGates         // per-route pause gates, in memory only
+	cachePrimes   cachePrimes        // cache priming jobs, restarted by reloads that empty a cache
+	validating    sync.Mutex         // held while a candidate config is validated
 
 	features      []Feature
 	adminFeatures []Feature // Runway-level stats features, set once, never swapped on reload
@@ -122,6 +178,15 @@ type Runway struct {
 	mu           sync.RWMutex // cold: only held during route add/reload
 }
 
+// routeHandler returns the compiled handler of a running route, for
+// mirrors with shadow_route, or nil.
+func (g *Runway) routeHandler(id string) http.Handler {
+	if m := g.routeHandlers.Load(); m != nil {
+		return (*m)[id]
+	}
+	return nil
+}
+
 // storeAtomicMap atomically stores an entry in a copy-on-write map behind an atomic.Pointer.
 func storeAtomicMap[T any](p *atomic.Pointer[map[string]T], id string, item T) {
 	old := *p.Load()
@@ -174,17 +239,51 @@ func (sr *statusRecorder) Flush() {
 	}
 }
 
+// applyXMLLimits installs the global xml_limits used by every XML parser in
+// the gateway.
+func applyXMLLimits(c config.XMLLimitsConfig) {
+	xmlsafe.SetLimits(xmlsafe.Limits{
+		MaxBytes:           c.MaxBytes,
+		MaxDepth:           c.MaxDepth,
+		MaxAttributes:      c.MaxAttributes,
+		MaxEntities:        c.MaxEntities,
+		MaxEntityExpansion: c.MaxEntityExpansion,
+	})
+}
+
 // New creates a new gateway
 func New(cfg *config.Config) (*Runway, error) {
+	testClock := initTestMode(cfg.TestMode)
+	storeKeys, err := storecrypt.New(cfg.StorageEncryption, nil)
+	if err != nil {
+		return nil, fmt.Errorf("storage encryption: %w", err)
+	}
+
 	g := &Runway{
 		config:           cfg,
 		router:           router.New(),
 		resolver:         variables.NewResolver(),
-		wsProxy:          websocket.NewProxy(config.WebSocketConfig{}),
-		metricsCollector: metrics.NewCollector(),
-		routeManagers:    newRouteManagers(cfg, nil),
+		metricsCollector: metrics.NewCollectorWithOptions(metrics.Options{
+			Buckets:       cfg.Admin.Metrics.Buckets,
+			SummaryWindow: cfg.Admin.Metrics.SummaryWindow,
+		}),
+		routeManagers:    newRouteManagers(cfg, nil, storeKeys),
 		watchCancels:     make(map[string]context.CancelFunc),
-	}
+		testClock:        testClock,
+		debugTraces:      debugtrace.New(clock.Default()),
+	}
+	g.metricsCollector.Plugins().SetMaxSeries(cfg.Admin.Metrics.PluginMaxSeries)
+	g.responseBuffers.SetDisk(spillbuf.NewDisk(cfg.ResponseBuffering, nil))
+	g.metricsCollector.SetSpillDiskUsage(g.responseBuffers.Disk().Usage)
+	g.routeManagers.setPluginMetrics(g.metricsCollector.Plugins())
+	g.routeManagers.setObserveMetrics(g.metricsCollector)
+	g.routeManagers.mirrors.SetRouteHandlers(g.routeHandler)
+	g.wireEgressDialers(&g.routeManagers)
+	applyXMLLimits(cfg.XMLLimits)
+	logDeprecationWarnings(cfg)
+	logResponsePipelineWarnings(cfg)
+	logCacheKeyWarnings(cfg)
+	logEgressWarnings(cfg)
 
 	// Initialize atomic pointers for hot-path map access
 	rp := make(map[string]*proxy.RouteProxy)
@@ -197,6 +296,12 @@ func New(cfg *config.Config) (*Runway, error) {
 		g.loadShedder = loadshed.New(cfg.LoadShedding)
 	}
 
+	// Initialize warm-up ramp if enabled
+	if cfg.Warmup.Enabled {
+		g.warmer.Store(warmup.New(cfg.Warmup))
+	}
+	g.featureOverrides = featureflags.NewOverrides()
+
 	// Initialize tracer
 	if cfg.Tracing.Enabled {
 		var err error
@@ -218,10 +323,18 @@ func New(cfg *config.Config) (*Runway, error) {
 		g.caches.SetRedisClient(g.redisClient)
 	}
 
+	// Initialize reputation scoring before the global blocklist that enforces it
+	g.tempBlocks = ipblocklist.NewTempBlocks()
+	g.streamLimiters = streamlimit.NewRegistry()
+	if err := g.initReputation(cfg); err != nil {
+		return nil, err
+	}
+
 	// Initialize global singletons (shared between New and Reload)
-	if err := g.routeManagers.initGlobals(cfg, g.redisClient); err != nil {
+	if err := g.routeManagers.initGlobals(cfg, g.redisClient, g.tempBlocks); err != nil {
 		return nil, err
 	}
+	g.globalErrors.Store(g.routeManagers.globalErrors)
 
 	// Register per-route features (shared between New and Reload)
 	g.features = buildFeatures(&g.routeManagers, cfg, g.redisClient)
@@ -331,6 +444,7 @@ func New(cfg *config.Config) (*Runway, error) {
 	// Initialize webhook dispatcher if enabled
 	if cfg.Webhooks.Enabled {
 		g.webhookDispatcher = webhook.NewDispatcher(cfg.Webhooks)
+		g.webhookDispatcher.SetDialer(g.egressDialer(egressSourceWebhook))
 		g.routeManagers.wireWebhookCallbacks(g.webhookDispatcher)
 	}
 
@@ -372,7 +486,7 @@ func New(cfg *config.Config) (*Runway, error) {
 	}
 
 	// Initialize authentication (shared between New and Reload)
-	if err := g.routeManagers.initAuth(cfg); err != nil {
+	if err := g.routeManagers.initAuth(cfg, g.redisClient, g.webhookDispatcher); err != nil {
 		return nil, fmt.Errorf("failed to initialize auth: %w", err)
 	}
 
@@ -423,6 +537,30 @@ func New(cfg *config.Config) (*Runway, error) {
 		}
 	}
 
+	// Start watching feature flags last so routes exist when flags are applied
+	if err := g.initFeatureFlags(cfg); err != nil {
+		return nil, err
+	}
+
+	// Probe external dependencies in the background
+	g.initDependencyHealth(cfg)
+
+	// Scan backend certificates in the background
+	g.initBackendTLSScan(cfg)
+
+	// Publish the config hash and compare it with peer replicas
+	g.hashConfig(cfg)
+	g.initConfigDrift(cfg)
+
+	// Keep replaced configs for rollback
+	g.initConfigSnapshots(cfg)
+
+	// Restore rate limit buckets saved by the previous process
+	g.initRateLimitState(cfg, true)
+
+	// Warm route caches from their priming manifests in the background
+	g.initCachePriming(cfg)
+
 	return g, nil
 }
 
@@ -532,6 +670,7 @@ func (g *Runway) initRoutes() error {
 			return fmt.Errorf("failed to add route %s: %w", routeCfg.ID, err)
 		}
 	}
+	g.routeManagers.upstreamDNS.start()
 	return nil
 }
 
@@ -555,6 +694,9 @@ func resolveUpstreamRefs(cfg *config.Config, routeCfg config.RouteConfig) config
 			if routeCfg.ConsistentHash == (config.ConsistentHashConfig{}) {
 				routeCfg.ConsistentHash = us.ConsistentHash
 			}
+			if routeCfg.EWMA == (config.EWMAConfig{}) {
+				routeCfg.EWMA = us.EWMA
+			}
 		}
 	}
 
@@ -579,6 +721,15 @@ func resolveUpstreamRefs(cfg *config.Config, routeCfg config.RouteConfig) config
 		}
 	}
 
+	// Resolve handler fallback upstream refs
+	for _, fb := range []*config.HandlerFallbackConfig{&routeCfg.Protocol.Fallback, &routeCfg.Lambda.Fallback, &routeCfg.AMQP.Fallback} {
+		if fb.Enabled && fb.Upstream != "" {
+			if us, ok := cfg.Upstreams[fb.Upstream]; ok {
+				fb.Backends = us.Backends
+			}
+		}
+	}
+
 	// Resolve mirror upstream ref
 	if routeCfg.Mirror.Enabled && routeCfg.Mirror.Upstream != "" {
 		if us, ok := cfg.Upstreams[routeCfg.Mirror.Upstream]; ok {
@@ -616,6 +767,24 @@ func upstreamHealthCheck(backendURL string, global config.HealthCheckConfig, ups
 		if len(upstream.ExpectedStatus) > 0 {
 			merged.ExpectedStatus = upstream.ExpectedStatus
 		}
+		if upstream.Type != "" {
+			merged.Type = upstream.Type
+		}
+		if upstream.Body != "" {
+			merged.Body = upstream.Body
+		}
+		if len(upstream.Headers) > 0 {
+			merged.Headers = upstream.Headers
+		}
+		if upstream.Send != "" {
+			merged.Send = upstream.Send
+		}
+		if upstream.Expect != "" {
+			merged.Expect = upstream.Expect
+		}
+		if upstream.Service != "" {
+			merged.Service = upstream.Service
+		}
 	}
 	return mergeHealthCheckConfig(backendURL, merged, perBackend)
 }
@@ -646,6 +815,8 @@ func createBalancerForBackends(cfg config.RouteConfig, backends []*loadbalancer.
 		return loadbalancer.NewConsistentHash(backends, cfg.ConsistentHash)
 	case "least_response_time":
 		return loadbalancer.NewLeastResponseTime(backends)
+	case "ewma":
+		return loadbalancer.NewPeakEWMA(backends, cfg.EWMA)
 	default:
 		return loadbalancer.NewRoundRobin(backends)
 	}
@@ -689,6 +860,66 @@ func enabledSlot[T interface {
 	}}
 }
 
+// backendHealthFunc returns the last known health of rp's backends, for
+// synthetic probes answered from health. Returns nil when rp is nil.
+func (g *Runway) backendHealthFunc(rp *proxy.RouteProxy) synthetic.HealthFunc {
+	if rp == nil {
+		return nil
+	}
+	return func() map[string]health.Status {
+		backends := rp.GetBalancer().GetBackends()
+		statuses := make(map[string]health.Status, len(backends))
+		for _, b := range backends {
+			statuses[b.URL] = g.healthChecker.GetStatus(b.URL)
+		}
+		return statuses
+	}
+}
+
+// bodySlot wraps a response-body slot so gRPC requests bypass it. gRPC
+// responses carry length-prefixed frames and report status in trailers,
+// which buffering or rewriting middleware would break.
+func bodySlot(s namedSlot) namedSlot {
+	build := s.build
+	return namedSlot{s.name, func() middleware.Middleware {
+		if mw := build(); mw != nil {
+			return grpcBypassMW(mw)
+		}
+		return nil
+	}}
+}
+
+// wsUpgradeSlot wraps a request-inspection slot so WebSocket upgrade
+// requests it rejects are counted in the route's WebSocket stats.
+func wsUpgradeSlot(s namedSlot, wsProxies *websocket.WebSocketByRoute, routeID string) namedSlot {
+	build := s.build
+	return namedSlot{s.name, func() middleware.Middleware {
+		mw := build()
+		if mw == nil {
+			return nil
+		}
+		if ws := wsProxies.Lookup(routeID); ws != nil {
+			return wsUpgradeGuardMW(mw, ws.RecordUpgradeBlocked)
+		}
+		return mw
+	}}
+}
+
+// groupDeferredSlot places s at one of two chain positions: its own, or
+// ("group_" + name) right after consumer group resolution when deferred is
+// set. late selects which position the returned slot is for; the other
+// position gets an inactive slot.
+func groupDeferredSlot(s namedSlot, deferred, late bool) namedSlot {
+	name := s.name
+	if late {
+		name = "group_" + name
+	}
+	if deferred != late {
+		return namedSlot{name, func() middleware.Middleware { return nil }}
+	}
+	return namedSlot{name, s.build}
+}
+
 // methodSlot creates a named slot using a custom function to get the middleware.
 func methodSlot[T any](name string, mgr *byroute.Manager[T], routeID string, fn func(T) middleware.Middleware) namedSlot {
 	return namedSlot{name, func() middleware.Middleware {
@@ -715,21 +946,63 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 		respBodyTransform, _ = transform.NewCompiledBodyTransform(route.Transform.Response.Body)
 	}
 
+	// With consumer groups, rate limiting, quota and priority admission run
+	// after the group is resolved, so they can apply its overrides.
+	afterGroups := rm.consumerGroups.GetManager() != nil
+	// Baggage rate limit keys read the members the baggage middleware
+	// registered, so the limiter runs after it.
+	rateLimitLate := afterGroups || strings.HasPrefix(cfg.RateLimit.Key, "baggage:")
+	rateLimitSlot := namedSlot{"rate_limit", func() middleware.Middleware {
+		if cfg.RateLimit.CostSource == "graphql_complexity" {
+			return nil // needs the parsed query; runs in rate_limit_cost
+		}
+		if inner := rm.rateLimiters.GetMiddleware(routeID); inner != nil {
+			return skipFlagMW(variables.SkipRateLimit, inner)
+		}
+		return nil
+	}}
+	quotaSlot := slot("quota", false, variables.SkipQuota, &rm.quotaEnforcers.Manager, routeID)
+	prioritySlot := namedSlot{"priority", func() middleware.Middleware {
+		if rm.priorityAdmitter == nil {
+			return nil
+		}
+		if pcfg, ok := rm.priorityConfigs.GetConfig(routeID); ok {
+			return priorityMW(rm.priorityAdmitter, pcfg)
+		}
+		return nil
+	}}
+
 	// Middleware chain in order. Each slot has a name (for anchor-based insertion)
 	// and a build function that returns a middleware or nil to skip.
 	// Order matches CLAUDE.md serveHTTP flow exactly — do not reorder.
 	slots := []namedSlot{
-		{"metrics", func() middleware.Middleware { return metricsMW(g.metricsCollector, routeID) }},
+		{"metrics", func() middleware.Middleware {
+			return metricsMW(g.metricsCollector, routeID, routeMetricsOptions(cfg.Metrics.Merge(rm.requestMetrics)))
+		}},
+		slot("errors", false, 0, &rm.errorRenderers.Manager, routeID),
+		slot("decision_log", false, 0, &rm.decisionLoggers.Manager, routeID),
+		{"break_glass", func() middleware.Middleware {
+			if cfg.BreakGlass.Enabled {
+				return g.breakGlass.middleware(routeID)
+			}
+			return nil
+		}},
+		{"pause", func() middleware.Middleware {
+			return g.routeGates.gate(routeID).middleware()
+		}},
+		methodSlot("synthetic", &rm.syntheticMonitors.Manager, routeID, func(m *synthetic.Monitor) middleware.Middleware {
+			return m.Middleware(g.backendHealthFunc(rp))
+		}),
 		slot("slo", false, 0, &rm.sloTrackers.Manager, routeID),
 		{"canary_observer", func() middleware.Middleware {
 			if ctrl := rm.canaryControllers.Lookup(routeID); ctrl != nil {
-				return trafficObserverMW(ctrl)
+				return trafficObserverMW(ctrl, rm.countClientAborts)
 			}
 			if bg := rm.blueGreenControllers.Lookup(routeID); bg != nil {
-				return trafficObserverMW(bg)
+				return trafficObserverMW(bg, rm.countClientAborts)
 			}
 			if ab := rm.abTests.Lookup(routeID); ab != nil {
-				return trafficObserverMW(ab)
+				return trafficObserverMW(ab, rm.countClientAborts)
 			}
 			return nil
 		}},
@@ -759,7 +1032,15 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 		}},
 		slot("client_mtls", false, 0, &rm.clientMTLSVerifiers.Manager, routeID),
 		enabledSlot("cors", false, 0, &rm.corsHandlers.Manager, routeID),
-		{"var_context", func() middleware.Middleware { return varContextMW(routeID) }},
+		{"var_context", func() middleware.Middleware {
+			return varContextMW(routeID, routeBodyFieldsLimit(cfg, rm.globalRules, routeEngine), newRouteMetadata(cfg, rm.metadataLogKeys))
+		}},
+		{"multipart_fields", func() middleware.Middleware {
+			if !skipBody && cfg.MultipartFields.Enabled {
+				return multipartFieldsMW(cfg.MultipartFields)
+			}
+			return nil
+		}},
 		slot("security_headers", false, 0, &rm.securityHeaders.Manager, routeID),
 		slot("cdn_headers", false, 0, &rm.cdnHeaders.Manager, routeID),
 		slot("edge_cache_rules", false, 0, &rm.edgeCacheRules.Manager, routeID),
@@ -774,19 +1055,16 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 		slot("versioning", false, 0, &rm.versioners.Manager, routeID),
 		slot("deprecation", false, 0, &rm.deprecationHandlers.Manager, routeID),
 		slot("timeout", false, 0, &rm.timeoutConfigs.Manager, routeID),
-		{"rate_limit", func() middleware.Middleware {
-			if inner := rm.rateLimiters.GetMiddleware(routeID); inner != nil {
-				return skipFlagMW(variables.SkipRateLimit, inner)
-			}
-			return nil
-		}},
-		slot("spike_arrest", false, 0, &rm.spikeArresters.Manager, routeID),
-		slot("quota", false, 0, &rm.quotaEnforcers.Manager, routeID),
+		groupDeferredSlot(rateLimitSlot, rateLimitLate, false),
+		slot("spike_arrest", false, variables.SkipSpikeArrest, &rm.spikeArresters.Manager, routeID),
+		slot("concurrency_limit", false, 0, &rm.concurrencyLimiters.Manager, routeID),
+		groupDeferredSlot(quotaSlot, afterGroups, false),
+		slot("bandwidth_quota", false, variables.SkipQuota, &rm.bandwidthQuotas.Manager, routeID),
 		slot("throttle", false, variables.SkipThrottle, &rm.throttlers.Manager, routeID),
 		slot("request_queue", false, 0, &rm.requestQueues.Manager, routeID),
 		{"auth", func() middleware.Middleware {
 			if route.Auth.Required {
-				return authMW(g, route.Auth)
+				return authMW(g, routeID, route.Auth, auth.NewRequirements(cfg.Auth.Requirements), rm.oidcAuths.Lookup(routeID))
 			}
 			return nil
 		}},
@@ -805,15 +1083,8 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 		slot("inbound_signing", false, 0, &rm.inboundVerifiers.Manager, routeID),
 		slot("idempotency", false, 0, &rm.idempotencyHandlers.Manager, routeID),
 		slot("dedup", false, 0, &rm.dedupHandlers.Manager, routeID),
-		{"priority", func() middleware.Middleware {
-			if rm.priorityAdmitter == nil {
-				return nil
-			}
-			if pcfg, ok := rm.priorityConfigs.GetConfig(routeID); ok {
-				return priorityMW(rm.priorityAdmitter, pcfg)
-			}
-			return nil
-		}},
+		slot("content_dedup", skipBody, 0, &rm.contentDedups.Manager, routeID),
+		groupDeferredSlot(prioritySlot, afterGroups, false),
 		slot("baggage", false, 0, &rm.baggagePropagators.Manager, routeID),
 		{"tenant", func() middleware.Middleware {
 			if rm.tenantManager == nil {
@@ -827,6 +1098,9 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 			}
 			return nil
 		}},
+		groupDeferredSlot(rateLimitSlot, rateLimitLate, true),
+		groupDeferredSlot(quotaSlot, afterGroups, true),
+		groupDeferredSlot(prioritySlot, afterGroups, true),
 		slot("cost_track", false, 0, &rm.costTrackers.Manager, routeID),
 		{"request_rules", func() middleware.Middleware {
 			hasReq := (rm.globalRules != nil && rm.globalRules.HasRequestRules()) ||
@@ -836,7 +1110,7 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 			}
 			return nil
 		}},
-		slot("waf", false, variables.SkipWAF, &rm.wafHandlers.Manager, routeID),
+		wsUpgradeSlot(slot("waf", false, variables.SkipWAF, &rm.wafHandlers.Manager, routeID), rm.wsProxies, routeID),
 		slot("fault_injection", false, 0, &rm.faultInjectors.Manager, routeID),
 		methodSlot("traffic_replay", &rm.trafficReplay.Manager, routeID, (*trafficreplay.Recorder).RecordingMiddleware),
 		slot("mock", false, 0, &rm.mockHandlers.Manager, routeID),
@@ -890,18 +1164,46 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 			return nil
 		}},
 		slot("graphql", skipBody, 0, &rm.graphqlParsers.Manager, routeID),
+		{"rate_limit_cost", func() middleware.Middleware {
+			if cfg.RateLimit.CostSource != "graphql_complexity" {
+				return nil
+			}
+			if inner := rm.rateLimiters.GetMiddleware(routeID); inner != nil {
+				return skipFlagMW(variables.SkipRateLimit, inner)
+			}
+			return nil
+		}},
 		slot("graphql_subscription", false, 0, &rm.graphqlSubs.Manager, routeID),
 		methodSlot("ai_prompt_guard", &rm.aiHandlers.Manager, routeID, (*ai.AIHandler).PromptGuardMiddleware),
 		methodSlot("ai_prompt_decorate", &rm.aiHandlers.Manager, routeID, (*ai.AIHandler).PromptDecorateMiddleware),
 		methodSlot("ai_rate_limit", &rm.aiHandlers.Manager, routeID, (*ai.AIHandler).AIRateLimitMiddleware),
 		{"websocket", func() middleware.Middleware {
-			if route.WebSocket.Enabled {
-				return websocketMW(g.wsProxy, func() loadbalancer.Balancer { return rp.GetBalancer() })
+			if ws := rm.wsProxies.Lookup(routeID); ws != nil {
+				return websocketMW(ws, func() loadbalancer.Balancer { return rp.GetBalancer() }, rm.streamGates.Lookup(routeID))
 			}
 			return nil
 		}},
 		slot("sse", false, 0, &rm.sseHandlers.Manager, routeID),
-		{"cache", func() middleware.Middleware {
+		{"degraded_mode", func() middleware.Middleware {
+			dc := rm.degradedModes.Lookup(routeID)
+			if dc == nil {
+				return nil
+			}
+			var stale degraded.StaleLookup
+			if ch := rm.caches.Lookup(routeID); ch != nil && !skipBody {
+				stale = ch.GetStale
+			}
+			return dc.Middleware(stale, func(source string) {
+				g.metricsCollector.RecordDegradedResponse(routeID, source)
+			})
+		}},
+		{"ab_assignment", func() middleware.Middleware {
+			if ab := rm.abTests.Lookup(routeID); ab != nil {
+				return ab.Middleware()
+			}
+			return nil
+		}},
+		bodySlot(namedSlot{"cache", func() middleware.Middleware {
 			if skipBody {
 				return nil
 			}
@@ -909,11 +1211,11 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 				return cacheMW(ch, g.metricsCollector, routeID)
 			}
 			return nil
-		}},
-		slot("coalesce", skipBody, 0, &rm.coalescers.Manager, routeID),
+		}}),
+		bodySlot(slot("coalesce", skipBody, 0, &rm.coalescers.Manager, routeID)),
 		{"circuit_breaker", func() middleware.Middleware {
 			if cb := rm.circuitBreakers.Lookup(routeID); cb != nil {
-				return circuitBreakerMW(cb, isGRPC)
+				return circuitBreakerMW(cb, isGRPC, rm.countClientAborts)
 			}
 			return nil
 		}},
@@ -927,9 +1229,10 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 		slot("backpressure", false, 0, &rm.backpressureHandlers.Manager, routeID),
 		slot("proxy_rate_limit", false, 0, &rm.proxyRateLimiters.Manager, routeID),
 		slot("streaming", false, 0, &rm.streamHandlers.Manager, routeID),
-		enabledSlot("compression", skipBody, variables.SkipCompression, &rm.compressors.Manager, routeID),
-		enabledSlot("response_limit", skipBody, 0, &rm.responseLimiters.Manager, routeID),
-		slot("etag", skipBody, 0, &rm.etagHandlers.Manager, routeID),
+		bodySlot(slot("response_buffering", skipBody, 0, &rm.responseBuffers.Manager, routeID)),
+		bodySlot(enabledSlot("compression", skipBody, variables.SkipCompression, &rm.compressors.Manager, routeID)),
+		bodySlot(enabledSlot("response_limit", skipBody, 0, &rm.responseLimiters.Manager, routeID)),
+		bodySlot(slot("etag", skipBody, 0, &rm.etagHandlers.Manager, routeID)),
 		{"response_rules", func() middleware.Middleware {
 			hasResp := (rm.globalRules != nil && rm.globalRules.HasResponseRules()) ||
 				(routeEngine != nil && routeEngine.HasResponseRules())
@@ -958,32 +1261,41 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 			return nil
 		}},
 		{"request_transform", func() middleware.Middleware {
-			return requestTransformMW(route, rm.grpcHandlers.Lookup(routeID), reqBodyTransform)
+			grpcH := rm.grpcHandlers.Lookup(routeID)
+			h := route.Transform.Request.Headers
+			if len(h.Add) == 0 && len(h.Set) == 0 && len(h.Remove) == 0 && reqBodyTransform == nil && grpcH == nil {
+				return nil
+			}
+			return requestTransformMW(route, grpcH, reqBodyTransform)
 		}},
 		slot("body_gen", false, 0, &rm.bodyGenerators.Manager, routeID),
 		slot("modifiers", false, 0, &rm.modifierChains.Manager, routeID),
 		slot("param_forward", false, 0, &rm.paramForwarders.Manager, routeID),
 		slot("backend_auth", false, 0, &rm.backendAuths.Manager, routeID),
+		slot("backend_headers_secret", false, 0, &rm.secretHeaders.Manager, routeID),
 		slot("backend_signing", false, 0, &rm.backendSigners.Manager, routeID),
-		{"response_transform", func() middleware.Middleware {
+		bodySlot(namedSlot{"response_transform", func() middleware.Middleware {
 			if !skipBody && respBodyTransform != nil {
 				return transform.ResponseBodyTransformMiddleware(respBodyTransform)
 			}
 			return nil
-		}},
+		}}),
 		methodSlot("wasm_response", &rm.wasmPlugins.Manager, routeID, (*wasmPlugin.WasmPluginChain).ResponseMiddleware),
 		methodSlot("lua_response", &rm.luaScripters.Manager, routeID, (*luascript.LuaScript).ResponseMiddleware),
-		slot("jmespath", skipBody, 0, &rm.jmespathHandlers.Manager, routeID),
+		bodySlot(slot("jmespath", skipBody, 0, &rm.jmespathHandlers.Manager, routeID)),
 		slot("status_map", false, 0, &rm.statusMappers.Manager, routeID),
-		slot("content_replacer", skipBody, 0, &rm.contentReplacers.Manager, routeID),
-		slot("pii_redact", skipBody, 0, &rm.piiRedactors.Manager, routeID),
-		slot("field_replacer", skipBody, 0, &rm.fieldReplacers.Manager, routeID),
-		slot("resp_body_gen", skipBody, 0, &rm.respBodyGenerators.Manager, routeID),
+		bodySlot(slot("content_replacer", skipBody, 0, &rm.contentReplacers.Manager, routeID)),
+		bodySlot(slot("pii_redact", skipBody, 0, &rm.piiRedactors.Manager, routeID)),
+		bodySlot(slot("field_replacer", skipBody, 0, &rm.fieldReplacers.Manager, routeID)),
+		bodySlot(slot("response_field_policy", skipBody, 0, &rm.fieldPolicies.Manager, routeID)),
+		bodySlot(slot("resp_body_gen", skipBody, 0, &rm.respBodyGenerators.Manager, routeID)),
 		slot("error_handling", false, 0, &rm.errorHandlers.Manager, routeID),
-		slot("content_neg", skipBody, 0, &rm.contentNegotiators.Manager, routeID),
-		slot("response_signing", skipBody, 0, &rm.responseSigners.Manager, routeID),
+		bodySlot(slot("content_neg", skipBody, 0, &rm.contentNegotiators.Manager, routeID)),
+		bodySlot(slot("response_signing", skipBody, 0, &rm.responseSigners.Manager, routeID)),
 	}
 
+	slots = reorderResponseSlots(slots, cfg)
+
 	// Insert custom middleware slots at anchor positions
 	for _, cs := range g.customSlots {
 		idx, err := resolveCustomSlotAnchor(slots, cs.After, cs.Before, cs.Name)
@@ -1005,12 +1317,20 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 		slots = slices.Insert(slots, idx, newSlot)
 	}
 
+	// Every active slot can be switched off at runtime via feature overrides.
 	chain := middleware.NewBuilderWithCap(len(slots))
+	active := make(map[string]bool, len(slots))
+	stages := make([]routeStage, 0, len(slots))
 	for _, s := range slots {
 		if mw := s.build(); mw != nil {
+			mw = g.featureOverrides.Wrap(routeID, s.name, mw)
 			chain = chain.Use(mw)
+			active[s.name] = true
+			stages = append(stages, routeStage{s.name, mw})
 		}
 	}
+	g.routeSlotNames.Store(routeID, active)
+	g.routeStages.Store(routeID, stages)
 
 	// Innermost handler: aggregate, sequential, echo, static, translator, or proxy
 	var innermost http.Handler
@@ -1036,8 +1356,23 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 		innermost = amqpH
 	} else if pubsubH := rm.pubsubHandlers.Lookup(routeID); pubsubH != nil {
 		innermost = pubsubH
+	} else if kafkaH := rm.kafkaHandlers.Lookup(routeID); kafkaH != nil {
+		innermost = kafkaH
 	} else {
 		innermost = rp
+		// Forward to peer gateways while the route has no healthy backend
+		if cfg.PeerFailover.Enabled && rm.peerFailover != nil {
+			innermost = rm.peerFailover.Middleware(routeID, cfg.PeerFailover,
+				func() bool { return rp.GetBalancer().HealthyCount() > 0 },
+				func(peer, outcome string) { g.metricsCollector.RecordPeerFailover(routeID, peer, outcome) },
+			)(innermost)
+		}
+	}
+
+	// Replay to the fallback backends when the translator, lambda or amqp
+	// handler fails before responding
+	if fb := rm.handlerFallbacks.Lookup(routeID); fb != nil {
+		innermost = fb.Middleware()(innermost)
 	}
 
 	// gRPC reflection proxy — intercepts reflection requests before reaching the proxy
@@ -1055,6 +1390,14 @@ func (g *Runway) buildRouteHandler(rm *routeManagers, routeID string, cfg config
 		innermost = isCollectionMW(cfg.BackendResponse.CollectionKey)(innermost)
 	}
 
+	// 17.57. schema drift — samples backend responses for off-path checks
+	// against the OpenAPI response schema; never changes the response
+	if !skipBody {
+		if ov := rm.openapiValidators.Lookup(routeID); ov != nil && ov.Drift() != nil {
+			innermost = ov.Drift().Middleware()(innermost)
+		}
+	}
+
 	// 17.5 responseValidationMW — wraps innermost (closest to proxy)
 	if !skipBody {
 		respValidator := rm.validators.Lookup(routeID)
@@ -1127,6 +1470,12 @@ func mergeHealthCheckConfig(backendURL string, global config.HealthCheckConfig,
 	b.Timeout = global.Timeout
 	b.HealthyAfter = global.HealthyAfter
 	b.UnhealthyAfter = global.UnhealthyAfter
+	b.Type = global.Type
+	b.Body = global.Body
+	b.Headers = global.Headers
+	b.Send = global.Send
+	b.Expect = global.Expect
+	b.Service = global.Service
 
 	// Parse global expected status
 	for _, s := range global.ExpectedStatus {
@@ -1155,6 +1504,24 @@ func mergeHealthCheckConfig(backendURL string, global config.HealthCheckConfig,
 		if perBackend.UnhealthyAfter > 0 {
 			b.UnhealthyAfter = perBackend.UnhealthyAfter
 		}
+		if perBackend.Type != "" {
+			b.Type = perBackend.Type
+		}
+		if perBackend.Body != "" {
+			b.Body = perBackend.Body
+		}
+		if len(perBackend.Headers) > 0 {
+			b.Headers = perBackend.Headers
+		}
+		if perBackend.Send != "" {
+			b.Send = perBackend.Send
+		}
+		if perBackend.Expect != "" {
+			b.Expect = perBackend.Expect
+		}
+		if perBackend.Service != "" {
+			b.Service = perBackend.Service
+		}
 		if len(perBackend.ExpectedStatus) > 0 {
 			b.ExpectedStatus = nil
 			for _, s := range perBackend.ExpectedStatus {
@@ -1169,7 +1536,7 @@ func mergeHealthCheckConfig(backendURL string, global config.HealthCheckConfig,
 }
 
 // watchService watches for service changes from registry
-func (g *Runway) watchService(routeID, serviceName string, tags []string) {
+func (g *Runway) watchService(routeID string, svc config.ServiceConfig, localZone string) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	g.mu.Lock()
@@ -1180,10 +1547,10 @@ func (g *Runway) watchService(routeID, serviceName string, tags []string) {
 	g.mu.Unlock()
 
 	go func() {
-		ch, err := g.registry.Watch(ctx, serviceName)
+		ch, err := g.registry.Watch(ctx, svc.Name)
 		if err != nil {
 			logging.Error("Failed to watch service",
-				zap.String("service", serviceName),
+				zap.String("service", svc.Name),
 				zap.Error(err),
 			)
 			return
@@ -1198,30 +1565,14 @@ func (g *Runway) watchService(routeID, serviceName string, tags []string) {
 					return
 				}
 
-				// Filter by tags if specified
-				var filtered []*registry.Service
-				for _, svc := range services {
-					if hasAllTags(svc.Tags, tags) {
-						filtered = append(filtered, svc)
-					}
-				}
-
-				// Convert to backends
-				var backends []*loadbalancer.Backend
-				for _, svc := range filtered {
-					be := &loadbalancer.Backend{
-						URL:     svc.URL(),
-						Weight:  1,
-						Healthy: svc.Health == registry.HealthPassing,
-					}
-					be.InitParsedURL()
-					backends = append(backends, be)
-				}
+				// Filter by tags and metadata, then convert to backends
+				backends := g.serviceBackends(routeID, svc, localZone, services)
 
 				// Update route proxy
 				rp, ok := (*g.routeProxies.Load())[routeID]
 				if ok {
 					rp.UpdateBackends(backends)
+					g.reapplyBackendDrains(false)
 					logging.Info("Updated backends for route",
 						zap.String("route", routeID),
 						zap.Int("services", len(backends)),
@@ -1264,8 +1615,18 @@ func (g *Runway) updateBackendHealth(url string, status health.Status) {
 	}
 }
 
-// Handler returns the main HTTP handler
+// Handler returns the main HTTP handler, for requests not received through
+// a configured listener. Custom global middleware restricted to listeners
+// is left out.
 func (g *Runway) Handler() http.Handler {
+	return g.ListenerHandler("")
+}
+
+// ListenerHandler returns the HTTP handler of the listener with the given
+// ID. Its global chain records the listener ID in each request's variable
+// context and includes the custom global middleware that applies to the
+// listener.
+func (g *Runway) ListenerHandler(listenerID string) http.Handler {
 	slots := []namedSlot{
 		{"recovery", func() middleware.Middleware { return middleware.Recovery() }},
 		{"real_ip", func() middleware.Middleware {
@@ -1287,6 +1648,65 @@ func (g *Runway) Handler() http.Handler {
 			return nil
 		}},
 		{"request_id", func() middleware.Middleware { return middleware.RequestID() }},
+		{"listener_id", func() middleware.Middleware {
+			if listenerID == "" {
+				return nil
+			}
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					variables.GetFromRequest(r).ListenerID = listenerID
+					next.ServeHTTP(w, r)
+				})
+			}
+		}},
+		{"debug_trace", func() middleware.Middleware {
+			// Always installed: trace sessions start and end at runtime.
+			return g.debugTraces.Middleware()
+		}},
+		{"reputation", func() middleware.Middleware {
+			// Always installed: reputation scoring can be enabled or disabled
+			// by a reload. Runs outside logging, which records the status.
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					next.ServeHTTP(w, r)
+					if tr := g.reputation.Load(); tr != nil {
+						tr.Observe(r)
+					}
+				})
+			}
+		}},
+		{"client_writer", func() middleware.Middleware {
+			// Record the unwrapped writer for forward_informational routes.
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					variables.GetFromRequest(r).ClientWriter = w
+					next.ServeHTTP(w, r)
+				})
+			}
+		}},
+		{"errors", func() middleware.Middleware {
+			// Always installed: errors.format can be changed by a reload.
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if rr := g.globalErrors.Load(); rr != nil {
+						rr.ServeGlobal(next, w, r)
+						return
+					}
+					next.ServeHTTP(w, r)
+				})
+			}
+		}},
+		{"warmup", func() middleware.Middleware {
+			// Always installed: the warmer can be enabled or disabled by a reload.
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if wu := g.warmer.Load(); wu != nil && !wu.Admit(w) {
+						return
+					}
+					next.ServeHTTP(w, r)
+				})
+			}
+		}},
 		{"load_shed", func() middleware.Middleware {
 			if g.loadShedder != nil {
 				return g.loadShedder.Middleware()
@@ -1315,13 +1735,18 @@ func (g *Runway) Handler() http.Handler {
 		{"logging", func() middleware.Middleware {
 			return middleware.LoggingWithConfig(middleware.LoggingConfig{
 				Format: g.config.Logging.Format,
+				Output: logging.Output(),
 				JSON:   true,
+				Fields: g.config.Logging.Fields,
 			})
 		}},
 	}
 
 	// Insert custom global middleware slots at anchor positions
 	for _, cs := range g.customGlobalSlots {
+		if len(cs.Listeners) > 0 && !slices.Contains(cs.Listeners, listenerID) {
+			continue
+		}
 		idx, err := resolveCustomSlotAnchor(slots, cs.After, cs.Before, cs.Name)
 		if err != nil {
 			logging.Error("failed to resolve custom global middleware anchor",
@@ -1363,12 +1788,28 @@ func (g *Runway) serveHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// OIDC callback and logout endpoint intercept (before route matching)
+	if g.oidcAuths.Len() > 0 {
+		if oidc := g.oidcAuths.Match(r); oidc != nil {
+			oidc.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	// Self-service API key endpoint intercept (before route matching)
+	if g.keySelfService != nil && g.keySelfService.Matches(r) {
+		g.keySelfService.ServeHTTP(w, r)
+		return
+	}
+
 	match := g.router.Match(r)
 	if match == nil {
+		variables.SetErrorReason(r, errors.ReasonRouteNotFound)
 		errors.ErrNotFound.WriteJSON(w)
 		return
 	}
 	defer router.ReleaseMatch(match)
+	logging.DebugContext(r.Context(), "Route matched", zap.String("route", match.Route.ID))
 
 	// Set path params directly on the existing varCtx (already in context from RequestID middleware).
 	varCtx := variables.GetFromRequest(r)
@@ -1383,8 +1824,9 @@ func (g *Runway) serveHTTP(w http.ResponseWriter, r *http.Request) {
 	handler.ServeHTTP(w, r)
 }
 
-// authenticate handles authentication for a request
-func (g *Runway) authenticate(w http.ResponseWriter, r *http.Request, methods []string) bool {
+// authenticate handles authentication for a request. oidc is the route's
+// OIDC relying party, used by the "oidc" method.
+func (g *Runway) authenticate(w http.ResponseWriter, r *http.Request, methods []string, oidc *auth.OIDCAuth) bool {
 	// If no specific methods, try all available (basic/ldap excluded from default — they trigger browser dialogs)
 	if len(methods) == 0 {
 		methods = []string{"jwt", "api_key", "oauth"}
@@ -1394,6 +1836,7 @@ func (g *Runway) authenticate(w http.ResponseWriter, r *http.Request, methods []
 	var err error
 	hasBasicMethod := false
 	hasSAMLMethod := false
+	hasOIDCMethod := false
 
 	for _, method := range methods {
 		switch method {
@@ -1442,6 +1885,14 @@ func (g *Runway) authenticate(w http.ResponseWriter, r *http.Request, methods []
 					break
 				}
 			}
+		case "oidc":
+			hasOIDCMethod = true
+			if oidc != nil {
+				identity, err = oidc.Authenticate(w, r)
+				if err == nil {
+					break
+				}
+			}
 		}
 
 		if identity != nil {
@@ -1450,6 +1901,12 @@ func (g *Runway) authenticate(w http.ResponseWriter, r *http.Request, methods []
 	}
 
 	if identity == nil {
+		// OIDC routes send browsers to the IdP to log in
+		if hasOIDCMethod && oidc != nil && auth.BrowserNavigation(r) {
+			oidc.StartLogin(w, r)
+			return false
+		}
+		variables.SetErrorReason(r, errors.ReasonAuthenticationFailed)
 		// SAML-only routes: return JSON with login_url instead of WWW-Authenticate
 		if hasSAMLMethod && !hasBasicMethod {
 			w.Header().Set("Content-Type", "application/json")
@@ -1486,6 +1943,14 @@ func (g *Runway) authenticate(w http.ResponseWriter, r *http.Request, methods []
 
 // Close closes the gateway and releases resources
 func (g *Runway) Close() error {
+	// Stop watching feature flags
+	if g.featureFlags != nil {
+		g.featureFlags.Close()
+	}
+	g.stopBreakGlass()
+	g.stopRoutePauses()
+	g.stopCachePriming()
+
 	// Cancel all watchers
 	g.mu.Lock()
 	for _, cancel := range g.watchCancels {
@@ -1494,9 +1959,39 @@ func (g *Runway) Close() error {
 	g.watchCancels = make(map[string]context.CancelFunc)
 	g.mu.Unlock()
 
+	// Stop upstream DNS failover
+	g.mu.RLock()
+	g.routeManagers.upstreamDNS.stop()
+	g.mu.RUnlock()
+
 	// Stop health checker
 	g.healthChecker.Stop()
 
+	// Stop dependency probes
+	if m := g.depMonitor.Load(); m != nil {
+		m.Stop()
+	}
+
+	// Stop backend TLS scans
+	if s := g.tlsScanner.Load(); s != nil {
+		s.Stop()
+	}
+
+	// Save rate limit buckets (before the Redis client is closed)
+	if p := g.rateLimitState.Load(); p != nil {
+		p.Stop()
+	}
+
+	// Stop config drift detection (before the Redis client is closed)
+	if d := g.configDrift.Load(); d != nil {
+		d.Stop()
+	}
+
+	// Stop scheduled config snapshots
+	if m := g.configSnapshots.Load(); m != nil {
+		m.Stop()
+	}
+
 	// Close JWKS providers
 	if g.jwtAuth != nil {
 		g.jwtAuth.Close()
@@ -1541,6 +2036,9 @@ func (g *Runway) Close() error {
 	// Stop outlier detectors
 	g.outlierDetectors.StopAll()
 
+	// Stop schema drift digests
+	g.openapiValidators.Close()
+
 	// Close nonce checkers
 	byroute.ForEach(&g.nonceCheckers.Manager, func(nc *nonce.NonceChecker) { nc.CloseStore() })
 
@@ -1562,9 +2060,19 @@ func (g *Runway) Close() error {
 
 	// Close audit loggers
 	byroute.ForEach(&g.auditLoggers.Manager, (*auditlog.AuditLogger).Close)
+	g.decisionLoggers.CloseAll()
+	if g.keySelfService != nil {
+		g.keySelfService.Close()
+	}
+
+	// Complete mirror sink files
+	g.mirrors.CloseAll()
 
 	// Close ext auth clients
-	byroute.ForEach(&g.extAuths.Manager, (*extauth.ExtAuth).Close)
+	g.extAuths.CloseAll()
+
+	// Close Kafka broker connections
+	g.kafkaHandlers.CloseAll()
 
 	// Close geo provider
 	if g.geoProvider != nil {
@@ -1613,6 +2121,11 @@ func (g *Runway) GetCircuitBreakers() *circuitbreaker.BreakerByRoute {
 	return g.circuitBreakers
 }
 
+// GetOpenAPIValidators returns the OpenAPI validation manager
+func (g *Runway) GetOpenAPIValidators() *openapivalidation.OpenAPIByRoute {
+	return g.openapiValidators
+}
+
 // GetCaches returns the cache manager
 func (g *Runway) GetCaches() *cache.CacheByRoute {
 	return g.caches
@@ -1724,6 +2237,32 @@ func (g *Runway) GetMaintenanceHandlers() *maintenance.MaintenanceByRoute {
 	return g.maintenanceHandlers
 }
 
+// GetPeerFailover returns the peer failover forwarder, or nil when no peers
+// are configured.
+func (g *Runway) GetPeerFailover() *peering.Failover {
+	return g.peerFailover
+}
+
+// GetDegradedModes returns the degraded mode ByRoute manager.
+func (g *Runway) GetDegradedModes() *degraded.DegradedByRoute {
+	return g.degradedModes
+}
+
+// DebugTraces returns the targeted request tracing sessions.
+func (g *Runway) DebugTraces() *debugtrace.Tracer {
+	return g.debugTraces
+}
+
+// GetWarmer returns the warm-up ramp, or nil when warm-up is disabled.
+func (g *Runway) GetWarmer() *warmup.Warmer {
+	return g.warmer.Load()
+}
+
+// GetWAFHandlers returns the WAF ByRoute manager.
+func (g *Runway) GetWAFHandlers() *waf.WAFByRoute {
+	return g.wafHandlers
+}
+
 // GetHTTPSRedirect returns the HTTPS redirect handler (may be nil).
 func (g *Runway) GetHTTPSRedirect() *httpsredirect.CompiledHTTPSRedirect {
 	return g.httpsRedirect
@@ -1778,20 +2317,59 @@ func (g *Runway) buildTransportPool(cfg *config.Config) *proxy.TransportPool {
 		baseCfg.SSRFProtection = &cfg.SSRFProtection
 	}
 
+	// Apply the egress policy if configured
+	baseCfg.Egress = g.loadEgressPolicy(cfg.EgressPolicy)
+
 	pool := proxy.NewTransportPoolWithDefault(baseCfg)
 
 	// Create per-upstream transports
 	for name, us := range cfg.Upstreams {
-		if us.Transport == (config.TransportConfig{}) {
-			continue // no per-upstream overrides
-		}
 		usCfg := proxy.MergeTransportConfigs(baseCfg, us.Transport)
-		pool.Set(name, usCfg)
+		if us.Transport != (config.TransportConfig{}) {
+			pool.Set(name, usCfg)
+		}
+		if us.TransportCanary.Enabled {
+			candidate := proxy.MergeTransportConfigs(usCfg, us.TransportCanary.Transport)
+			pool.SetCanary(name, candidate, us.TransportCanary, g.onTransportCanaryChange)
+		}
 	}
 
 	return pool
 }
 
+// onTransportCanaryChange logs a transport canary rollback or promotion and
+// emits the matching webhook event.
+func (g *Runway) onTransportCanaryChange(upstream, state, reason string, stats proxy.CanaryTransportSnapshot) {
+	eventType := webhook.TransportCanaryPromoted
+	if state == proxy.CanaryStateRolledBack {
+		eventType = webhook.TransportCanaryRolledBack
+		logging.Warn("Transport canary rolled back",
+			zap.String("upstream", upstream),
+			zap.String("reason", reason),
+		)
+	} else {
+		logging.Info("Transport canary promoted", zap.String("upstream", upstream))
+	}
+	if g.webhookDispatcher != nil {
+		g.webhookDispatcher.Emit(webhook.NewEvent(eventType, "", map[string]interface{}{
+			"upstream": upstream,
+			"reason":   reason,
+			"primary":  stats.Primary,
+			"canary":   stats.Canary,
+		}))
+	}
+}
+
+// PromoteTransportCanary makes the named upstream's candidate transport the
+// one used for all its requests until the next reload.
+func (g *Runway) PromoteTransportCanary(upstream string) error {
+	ct := g.GetTransportPool().Canary(upstream)
+	if ct == nil {
+		return fmt.Errorf("upstream %s has no transport canary", upstream)
+	}
+	return ct.Promote()
+}
+
 // GetLoadBalancerInfo returns per-route load balancer algorithm and stats.
 func (g *Runway) GetLoadBalancerInfo() map[string]interface{} {
 	proxies := *g.routeProxies.Load()
@@ -1821,6 +2399,13 @@ func (g *Runway) GetLoadBalancerInfo() map[string]interface{} {
 				}
 			}
 		}
+		// Checked by type so routes inheriting ewma from their upstream report it too
+		if rp, ok := proxies[routeCfg.ID]; ok {
+			if pe, ok := rp.GetBalancer().(*loadbalancer.PeakEWMA); ok {
+				info["algorithm"] = "ewma"
+				info["ewma"] = pe.GetScores()
+			}
+		}
 		result[routeCfg.ID] = info
 	}
 	return result
@@ -1865,6 +2450,11 @@ func (g *Runway) GetAPIKeyAuth() *auth.APIKeyAuth {
 	return g.apiKeyAuth
 }
 
+// GetJWTAuth returns the JWT auth provider.
+func (g *Runway) GetJWTAuth() *auth.JWTAuth {
+	return g.jwtAuth
+}
+
 // GetBasicAuth returns the basic auth provider.
 func (g *Runway) GetBasicAuth() *auth.BasicAuth {
 	return g.basicAuth
@@ -1880,6 +2470,11 @@ func (g *Runway) GetSAMLAuth() *auth.SAMLAuth {
 	return g.samlAuth
 }
 
+// GetKeySelfService returns the self-service API key endpoints, or nil when disabled.
+func (g *Runway) GetKeySelfService() *auth.KeySelfService {
+	return g.keySelfService
+}
+
 // Stats returns gateway statistics
 type Stats struct {
 	Routes        int            `json:"routes"`
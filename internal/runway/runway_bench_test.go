@@ -6,6 +6,9 @@ import (
 	"testing"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/proxy"
+	"github.com/wudi/runway/internal/router"
 )
 
 func BenchmarkServeHTTP(b *testing.B) {
@@ -82,3 +85,78 @@ func BenchmarkServeHTTP_Miss(b *testing.B) {
 		handler.ServeHTTP(w, req)
 	}
 }
+
+// plainRouteAllocBudget is the number of allocations the runway may add per
+// request to a route with no features, on top of the proxy round trip.
+const plainRouteAllocBudget = 10
+
+// plainRouteHandlers returns the runway handler of a route with no
+// features and the bare proxy handler to the same backend, with a request
+// for both.
+func plainRouteHandlers(tb testing.TB) (runway, bare http.Handler, req *http.Request) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	tb.Cleanup(backend.Close)
+
+	cfg := &config.Config{
+		Registry: config.RegistryConfig{
+			Type: "memory",
+		},
+		Routes: []config.RouteConfig{
+			{
+				ID:       "bench-route",
+				Path:     "/api",
+				Backends: []config.BackendConfig{{URL: backend.URL}},
+			},
+		},
+	}
+
+	gw, err := New(cfg)
+	if err != nil {
+		tb.Fatalf("Failed to create gateway: %v", err)
+	}
+	tb.Cleanup(func() { gw.Close() })
+
+	req = httptest.NewRequest("GET", "/api", nil)
+	req.Header.Set("Accept", "application/json")
+
+	bare = proxy.New(proxy.Config{}).Handler(&router.Route{ID: "bench-route", Path: "/api"},
+		loadbalancer.NewRoundRobin([]*loadbalancer.Backend{{URL: backend.URL, Weight: 1, Healthy: true}}))
+	return gw.Handler(), bare, req
+}
+
+// TestPlainProxyRouteAllocBudget fails if the runway's allocations per
+// request to a route with no features, beyond those of the bare proxy
+// handler to the same backend, exceed plainRouteAllocBudget.
+func TestPlainProxyRouteAllocBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocation budget measured without -short")
+	}
+	handler, bare, req := plainRouteHandlers(t)
+	allocs := func(h http.Handler) float64 {
+		h.ServeHTTP(httptest.NewRecorder(), req) // warm up pools and connections
+		return testing.AllocsPerRun(200, func() {
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		})
+	}
+	gw, proxied := allocs(handler), allocs(bare)
+	t.Logf("allocs/op: runway %v, proxy %v", gw, proxied)
+	if overhead := gw - proxied; overhead > plainRouteAllocBudget {
+		t.Fatalf("plain proxy route allocates %v more per request than the proxy, budget %d", overhead, plainRouteAllocBudget)
+	}
+}
+
+// BenchmarkPlainProxyRoute measures a route with no features.
+func BenchmarkPlainProxyRoute(b *testing.B) {
+	handler, _, req := plainRouteHandlers(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+	}
+}
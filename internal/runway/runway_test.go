@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -747,3 +748,112 @@ func TestRunwayNewFeatureGetters(t *testing.T) {
 		t.Errorf("Expected 0 retry metrics, got %d", len(metrics))
 	}
 }
+
+func TestRunwayRateLimitGraphQLComplexityCost(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{
+			{
+				ID:       "gql",
+				Path:     "/graphql",
+				Backends: []config.BackendConfig{{URL: backend.URL}},
+				GraphQL:  config.GraphQLConfig{Enabled: true},
+				RateLimit: config.RateLimitConfig{
+					Enabled:    true,
+					Rate:       10,
+					Period:     time.Hour,
+					PerIP:      true,
+					CostSource: "graphql_complexity",
+				},
+			},
+		},
+	}
+
+	gw, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	defer gw.Close()
+
+	ts := httptest.NewServer(gw.Handler())
+	defer ts.Close()
+
+	post := func() *http.Response {
+		resp, err := http.Post(ts.URL+"/graphql", "application/json",
+			strings.NewReader(`{"query":"{ user { id name email } }"}`))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// { user { id name email } } has complexity 4.
+	resp := post()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-RateLimit-Cost") != "4" || resp.Header.Get("RateLimit-Remaining") != "6" {
+		t.Fatalf("expected 200 with cost 4 and 6 remaining, got %d cost=%q remaining=%q",
+			resp.StatusCode, resp.Header.Get("X-RateLimit-Cost"), resp.Header.Get("RateLimit-Remaining"))
+	}
+	post()
+	if resp := post(); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected 429 once the complexity budget is spent, got %d", resp.StatusCode)
+	}
+}
+
+func TestRunwayRateLimitBaggageKey(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{
+			{
+				ID:       "tenants",
+				Path:     "/tenants",
+				Backends: []config.BackendConfig{{URL: backend.URL}},
+				Baggage: config.BaggageConfig{
+					Enabled: true,
+					Tags:    []config.BaggageTagDef{{Name: "tenant", Source: "header:X-Tenant", Header: "X-Backend-Tenant"}},
+				},
+				RateLimit: config.RateLimitConfig{Enabled: true, Rate: 1, Period: time.Hour, Key: "baggage:tenant"},
+			},
+		},
+	}
+
+	gw, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	defer gw.Close()
+	handler := gw.Handler()
+
+	do := func(tenant, bag string) int {
+		req := httptest.NewRequest("GET", "/tenants", nil)
+		req.Header.Set("X-Tenant", tenant)
+		if bag != "" {
+			req.Header.Set("Baggage", bag)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// The limiter sees the tag the baggage middleware registered, so each
+	// tenant has its own bucket and inbound members cannot pick another.
+	if code := do("acme", ""); code != http.StatusOK {
+		t.Fatalf("first acme request: got %d", code)
+	}
+	if code := do("globex", ""); code != http.StatusOK {
+		t.Fatalf("first globex request: got %d", code)
+	}
+	if code := do("acme", "tenant=initech"); code != http.StatusTooManyRequests {
+		t.Errorf("second acme request with spoofed baggage: got %d, want 429", code)
+	}
+}
@@ -2,17 +2,16 @@ package runway
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
-	"math/big"
 	"net/http"
 	"net/http/pprof"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -26,15 +25,22 @@ import (
 	"github.com/wudi/runway/internal/cluster"
 	"github.com/wudi/runway/internal/cluster/cp"
 	"github.com/wudi/runway/internal/cluster/dp"
+	"github.com/wudi/runway/internal/configsnapshot"
+	"github.com/wudi/runway/internal/debugtrace"
 	"github.com/wudi/runway/internal/grpchealth"
 	gatewayerrors "github.com/wudi/runway/internal/errors"
+	"github.com/wudi/runway/internal/health"
 	"github.com/wudi/runway/internal/listener"
 	"github.com/wudi/runway/internal/logging"
 	"github.com/wudi/runway/internal/middleware/auth"
 	"github.com/wudi/runway/internal/middleware/ipblocklist"
+	"github.com/wudi/runway/internal/middleware/secretheaders"
 	"github.com/wudi/runway/internal/proxy/tcp"
 	"github.com/wudi/runway/internal/proxy/udp"
+	"github.com/wudi/runway/internal/simulate"
 	"github.com/wudi/runway/internal/trafficreplay"
+	"github.com/wudi/runway/internal/trafficshape"
+	"github.com/wudi/runway/internal/webhook"
 	"github.com/wudi/runway/ui"
 	"go.uber.org/zap"
 )
@@ -46,11 +52,16 @@ type Server struct {
 	adminServer   *http.Server
 	config        *config.Config
 	configPath    string
+	configDir     string // included with -config-dir, re-read on reload
 	tcpProxy      *tcp.Proxy
 	udpProxy      *udp.Proxy
 	startTime     time.Time
 	reloadHistory []ReloadResult
-	reloadMu      sync.Mutex // serializes concurrent ReloadWithConfig calls
+	reloadMu      sync.Mutex                       // serializes concurrent ReloadWithConfig calls
+	configGen     uint64                           // generation of config, guarded by reloadMu
+	listenerMu    sync.Mutex                       // serializes listener reconciliation
+	listenerCfgs  map[string]config.ListenerConfig // config each running listener applies
+	drains        sync.WaitGroup                   // listeners a reload removed or replaced, still draining
 	grpcHealthServer *grpchealth.Server
 	draining         atomic.Bool
 	drainStart       atomic.Int64 // unix nano timestamp when drain started
@@ -77,7 +88,10 @@ func NewServer(cfg *config.Config, configPath string, opts ...ExternalOptions) (
 		manager:    listener.NewManager(),
 		config:     cfg,
 		configPath: configPath,
+		configDir:  cfg.ConfigDir,
 		startTime:  time.Now(),
+
+		listenerCfgs: make(map[string]config.ListenerConfig),
 	}
 
 	// Initialize TCP/UDP proxies if needed
@@ -164,7 +178,7 @@ func (s *Server) initCluster() error {
 			HeartbeatInterval: dpCfg.HeartbeatInterval,
 			DPCluster:         s.config.Cluster,
 			ReloadFn: func(cfg *config.Config) dp.ReloadResult {
-				r := s.ReloadWithConfig(cfg)
+				r := s.reloadWithConfig(cfg, "cluster")
 				return dp.ReloadResult{
 					Success:   r.Success,
 					Timestamp: r.Timestamp,
@@ -227,55 +241,68 @@ func (s *Server) initL4Proxies() error {
 
 // initListeners initializes all listeners from configuration
 func (s *Server) initListeners() error {
-	cfg := s.config
-
-	// Create listeners from config
-	for _, listenerCfg := range cfg.Listeners {
-		var l listener.Listener
-		var err error
+	for _, listenerCfg := range s.config.Listeners {
+		l, err := s.makeListener(listenerCfg)
+		if err != nil {
+			return err
+		}
+		if err := s.manager.Add(l); err != nil {
+			return fmt.Errorf("failed to add listener %s: %w", listenerCfg.ID, err)
+		}
+		s.listenerCfgs[listenerCfg.ID] = listenerCfg
+	}
 
-		switch listenerCfg.Protocol {
-		case config.ProtocolHTTP:
-			l, err = s.makeHTTPListener(listenerCfg)
+	return nil
+}
 
-		case config.ProtocolTCP:
-			if s.tcpProxy == nil {
-				return fmt.Errorf("TCP proxy not initialized for listener %s", listenerCfg.ID)
-			}
-			l, err = listener.NewTCPListener(listener.TCPListenerConfig{
-				ID:          listenerCfg.ID,
-				Address:     listenerCfg.Address,
-				Proxy:       s.tcpProxy,
-				TLS:         listenerCfg.TLS,
-				SNIRouting:  listenerCfg.TCP.SNIRouting,
-				IdleTimeout: listenerCfg.TCP.IdleTimeout,
-			})
+// makeListener creates a listener from a listener config.
+func (s *Server) makeListener(lc config.ListenerConfig) (listener.Listener, error) {
+	var l listener.Listener
+	var err error
 
-		case config.ProtocolUDP:
-			if s.udpProxy == nil {
-				return fmt.Errorf("UDP proxy not initialized for listener %s", listenerCfg.ID)
-			}
-			l, err = listener.NewUDPListener(listener.UDPListenerConfig{
-				ID:      listenerCfg.ID,
-				Address: listenerCfg.Address,
-				Proxy:   s.udpProxy,
-				UDP:     listenerCfg.UDP,
-			})
+	switch lc.Protocol {
+	case config.ProtocolHTTP:
+		l, err = s.makeHTTPListener(lc)
 
-		default:
-			return fmt.Errorf("unknown protocol for listener %s: %s", listenerCfg.ID, listenerCfg.Protocol)
+	case config.ProtocolTCP:
+		if s.tcpProxy == nil {
+			return nil, fmt.Errorf("TCP proxy not initialized for listener %s", lc.ID)
 		}
+		l, err = listener.NewTCPListener(s.tcpListenerConfig(lc))
 
-		if err != nil {
-			return fmt.Errorf("failed to create listener %s: %w", listenerCfg.ID, err)
+	case config.ProtocolUDP:
+		if s.udpProxy == nil {
+			return nil, fmt.Errorf("UDP proxy not initialized for listener %s", lc.ID)
 		}
+		l, err = listener.NewUDPListener(listener.UDPListenerConfig{
+			ID:      lc.ID,
+			Address: lc.Address,
+			Proxy:   s.udpProxy,
+			UDP:     lc.UDP,
+		})
 
-		if err := s.manager.Add(l); err != nil {
-			return fmt.Errorf("failed to add listener %s: %w", listenerCfg.ID, err)
-		}
+	default:
+		return nil, fmt.Errorf("unknown protocol for listener %s: %s", lc.ID, lc.Protocol)
 	}
 
-	return nil
+	if err != nil {
+		return nil, fmt.Errorf("failed to create listener %s: %w", lc.ID, err)
+	}
+	return l, nil
+}
+
+// tcpListenerConfig returns the TCP listener settings of a listener config.
+func (s *Server) tcpListenerConfig(lc config.ListenerConfig) listener.TCPListenerConfig {
+	return listener.TCPListenerConfig{
+		ID:          lc.ID,
+		Address:     lc.Address,
+		Proxy:       s.tcpProxy,
+		TLS:         lc.TLS,
+		SNIRouting:  lc.TCP.SNIRouting,
+		IdleTimeout: lc.TCP.IdleTimeout,
+
+		OnCertReloadError: s.onCertReloadError,
+	}
 }
 
 // Start starts the gateway servers
@@ -332,6 +359,11 @@ func (s *Server) Start() error {
 		// Give servers a moment to start
 	}
 
+	logging.Info("Runway started",
+		zap.Int("listeners", s.manager.Count()),
+		zap.String("config_hash", s.gateway.ConfigHash()),
+	)
+
 	return nil
 }
 
@@ -357,6 +389,7 @@ func (s *Server) Run() error {
 			if result.Success {
 				logging.Info("Config reloaded successfully",
 					zap.Int("changes", len(result.Changes)),
+					zap.String("config_hash", result.ConfigHash),
 				)
 			} else {
 				logging.Error("Config reload failed",
@@ -365,11 +398,7 @@ func (s *Server) Run() error {
 			}
 		default:
 			logging.Info("Shutting down gracefully...")
-			timeout := s.config.Shutdown.Timeout
-			if timeout <= 0 {
-				timeout = 30 * time.Second
-			}
-			return s.Shutdown(timeout)
+			return s.Shutdown(shutdownTimeout(s.config.Shutdown))
 		}
 	}
 
@@ -422,6 +451,16 @@ func (s *Server) Shutdown(timeout time.Duration) error {
 	if err := s.manager.StopAll(ctx); err != nil {
 		logging.Error("Listener manager shutdown error", zap.Error(err))
 	}
+	drained := make(chan struct{})
+	go func() {
+		s.drains.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		logging.Warn("Reloaded listeners still draining at shutdown timeout")
+	}
 
 	// Close L4 proxies
 	if s.tcpProxy != nil {
@@ -470,103 +509,69 @@ func (s *Server) ReloadConfig() ReloadResult {
 		}
 	}
 
-	loader := config.NewLoader()
+	// Load re-reads every included file, and the config dir's files.
+	loader := config.NewLoader(config.WithConfigDir(s.configDir))
 	newCfg, err := loader.Load(s.configPath)
 	if err != nil {
 		result := ReloadResult{
 			Timestamp: time.Now(),
 			Error:     fmt.Sprintf("config load failed: %v", err),
 		}
+		s.reloadMu.Lock()
 		s.reloadHistory = appendReloadHistory(s.reloadHistory, result)
+		s.reloadMu.Unlock()
 		return result
 	}
 
-	result := s.gateway.Reload(newCfg)
-
-	// Reconcile listeners (new/removed/TLS changes)
-	if result.Success {
-		s.reconcileListeners(newCfg)
-		s.config = newCfg
-		s.pushCurrentConfig("file")
-	}
-
-	s.reloadHistory = appendReloadHistory(s.reloadHistory, result)
-	return result
+	return s.reloadWithConfig(newCfg, "file")
 }
 
 // ReloadWithConfig performs a hot config reload using the provided Config object
 // instead of loading from a file. This is used by the ingress controller and DP
 // client to push configs. Concurrent calls are serialized by reloadMu.
 func (s *Server) ReloadWithConfig(newCfg *config.Config) ReloadResult {
+	return s.reloadWithConfig(newCfg, "api")
+}
+
+// reloadWithConfig is ReloadWithConfig; source names the origin of the
+// config in the snapshot of the config it replaces.
+func (s *Server) reloadWithConfig(newCfg *config.Config, source string) ReloadResult {
 	s.reloadMu.Lock()
+	prior := s.gateway.currentConfig()
 	result := s.gateway.Reload(newCfg)
+	var gen uint64
 	if result.Success {
+		s.snapshotReplacedConfig(prior, source)
 		s.config = newCfg
+		s.configGen++
+		gen = s.configGen
 	}
-	s.reloadHistory = appendReloadHistory(s.reloadHistory, result)
 	s.reloadMu.Unlock()
 
 	// Listener reconciliation outside lock — route handlers already swapped,
-	// listener start/stop can block (up to 10s graceful stop timeout).
+	// and a listener start can block. A reload that commits meanwhile
+	// supersedes this one's listeners.
 	if result.Success {
-		s.reconcileListeners(newCfg)
+		result.Listeners = s.reconcileListeners(newCfg, gen)
 		// Push to cluster DPs (no-op if not CP mode or if called by DP itself)
-		if s.config.Cluster.Role == "control_plane" {
-			s.pushCurrentConfig("api")
+		if newCfg.Cluster.Role == "control_plane" {
+			s.pushCurrentConfig(source)
 		}
 	}
+
+	s.reloadMu.Lock()
+	s.reloadHistory = appendReloadHistory(s.reloadHistory, result)
+	s.reloadMu.Unlock()
 	return result
 }
 
-// reconcileListeners adjusts listeners after a config reload.
-// It stops removed listeners, starts new ones, and reloads TLS certs on existing ones.
-func (s *Server) reconcileListeners(newCfg *config.Config) {
-	oldIDs := make(map[string]bool)
-	for _, l := range s.config.Listeners {
-		oldIDs[l.ID] = true
-	}
-	newIDs := make(map[string]bool)
-	for _, l := range newCfg.Listeners {
-		newIDs[l.ID] = true
-	}
-
-	// Stop removed listeners
-	for id := range oldIDs {
-		if !newIDs[id] {
-			if l, ok := s.manager.Get(id); ok {
-				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-				if err := l.Stop(ctx); err != nil {
-					logging.Error("Failed to stop removed listener", zap.String("id", id), zap.Error(err))
-				}
-				cancel()
-				s.manager.Remove(id)
-				logging.Info("Removed listener", zap.String("id", id))
-			}
-		}
-	}
-
-	// Start new listeners
-	for _, listenerCfg := range newCfg.Listeners {
-		if oldIDs[listenerCfg.ID] {
-			continue // existing listener, skip
-		}
-		if listenerCfg.Protocol != config.ProtocolHTTP {
-			continue // only handle HTTP listeners during reload
-		}
-		l, err := s.makeHTTPListener(listenerCfg)
-		if err != nil {
-			logging.Error("Failed to create new listener", zap.String("id", listenerCfg.ID), zap.Error(err))
-			continue
-		}
-		if err := s.manager.Add(l); err != nil {
-			logging.Error("Failed to add new listener", zap.String("id", listenerCfg.ID), zap.Error(err))
-			continue
-		}
-		if err := l.Start(context.Background()); err != nil {
-			logging.Error("Failed to start new listener", zap.String("id", listenerCfg.ID), zap.Error(err))
-			continue
-		}
-		logging.Info("Started new listener", zap.String("id", listenerCfg.ID), zap.String("address", listenerCfg.Address))
+// snapshotReplacedConfig keeps prior, the config a successful reload from
+// source replaced, when config snapshots are enabled. A failed snapshot
+// does not fail the reload.
+func (s *Server) snapshotReplacedConfig(prior *config.Config, source string) {
+	_, err := s.gateway.SaveConfigSnapshot(context.Background(), prior, source)
+	if err != nil && !errors.Is(err, ErrConfigSnapshotsDisabled) {
+		logging.Warn("Failed to save config snapshot", zap.String("source", source), zap.Error(err))
 	}
 }
 
@@ -581,10 +586,16 @@ func appendReloadHistory(history []ReloadResult, result ReloadResult) []ReloadRe
 
 // makeHTTPListener creates an HTTP listener from a listener config.
 func (s *Server) makeHTTPListener(lc config.ListenerConfig) (*listener.HTTPListener, error) {
-	return listener.NewHTTPListener(listener.HTTPListenerConfig{
+	return listener.NewHTTPListener(s.httpListenerConfig(lc))
+}
+
+// httpListenerConfig returns the HTTP listener settings of a listener
+// config.
+func (s *Server) httpListenerConfig(lc config.ListenerConfig) listener.HTTPListenerConfig {
+	return listener.HTTPListenerConfig{
 		ID:                lc.ID,
 		Address:           lc.Address,
-		Handler:           s.gateway.Handler(),
+		Handler:           s.gateway.ListenerHandler(lc.ID),
 		TLS:               lc.TLS,
 		ACME:              lc.TLS.ACME,
 		ReadTimeout:       lc.HTTP.ReadTimeout,
@@ -593,7 +604,28 @@ func (s *Server) makeHTTPListener(lc config.ListenerConfig) (*listener.HTTPListe
 		MaxHeaderBytes:    lc.HTTP.MaxHeaderBytes,
 		ReadHeaderTimeout: lc.HTTP.ReadHeaderTimeout,
 		EnableHTTP3:       lc.HTTP.EnableHTTP3,
-	})
+		HTTP2:             lc.HTTP.HTTP2,
+		HTTP3:             lc.HTTP.HTTP3,
+		DrainTimeout:      shutdownTimeout(s.config.Shutdown),
+		OnStreamEnforce: func(protocol, action string) {
+			s.gateway.metricsCollector.RecordStreamEnforcement(lc.ID, protocol, action)
+		},
+		OnCertReloadError: s.onCertReloadError,
+	}
+}
+
+// onCertReloadError emits a webhook event for a listener certificate that
+// changed on disk but failed to load; the listener logs it and keeps
+// serving the previous certificate.
+func (s *Server) onCertReloadError(e *listener.CertReloadError) {
+	if d := s.gateway.webhookDispatcher; d != nil {
+		d.Emit(webhook.NewEvent(webhook.ListenerCertReloadFailed, "", map[string]interface{}{
+			"listener_id": e.ListenerID,
+			"cert_file":   e.CertFile,
+			"key_file":    e.KeyFile,
+			"error":       e.Err.Error(),
+		}))
+	}
 }
 
 // adminHandler creates the admin API handler
@@ -607,6 +639,7 @@ func (s *Server) adminHandler() http.Handler {
 	// Ready check endpoint
 	mux.HandleFunc("/ready", s.handleReady)
 	mux.HandleFunc("/readyz", s.handleReady)
+	mux.HandleFunc("/ready/weight", s.handleReadyWeight)
 
 	// Stats endpoint
 	mux.HandleFunc("/stats", s.handleStats)
@@ -619,6 +652,8 @@ func (s *Server) adminHandler() http.Handler {
 
 	// Backends health
 	mux.HandleFunc("/backends", s.handleBackends)
+	mux.HandleFunc("/backends/drain", s.handleBackendDrain)
+	mux.HandleFunc("/backends/undrain", s.handleBackendDrain)
 
 	// Listeners endpoint
 	mux.HandleFunc("/listeners", s.handleListeners)
@@ -668,6 +703,7 @@ func (s *Server) adminHandler() http.Handler {
 	if s.config.Admin.Metrics.Enabled {
 		mux.HandleFunc(metricsPath, s.handleMetrics)
 	}
+	mux.HandleFunc("/admin/metrics/cardinality", jsonStatsHandler(func() any { return s.gateway.metricsCollector.Cardinality() }))
 
 	// Custom admin endpoints (non-boilerplate; simple stats auto-registered via features above)
 	mux.HandleFunc("/rules", s.handleRules)
@@ -677,12 +713,40 @@ func (s *Server) adminHandler() http.Handler {
 	mux.HandleFunc("/reload/status", jsonStatsHandler(func() any { return s.reloadHistory }))
 	mux.HandleFunc("/load-balancers", jsonStatsHandler(func() any { return s.gateway.GetLoadBalancerInfo() }))
 	mux.HandleFunc("/maintenance/", s.handleMaintenanceAction)
+	mux.HandleFunc("/features/", s.handleFeatureAction)
+	mux.HandleFunc("/admin/feature-flags", s.handleFeatureFlags)
+	mux.HandleFunc("/admin/health/dependencies", s.handleDependencyHealth)
+	mux.HandleFunc("/admin/cache/priming", s.handleCachePriming)
+	mux.HandleFunc("/admin/artifacts", s.handleArtifacts)
+	mux.HandleFunc("/admin/backends/tls", s.handleBackendTLS)
+	mux.HandleFunc("/admin/config/rendered", s.handleRenderedConfig)
+	mux.HandleFunc("/admin/config/impact", s.handleConfigImpact)
+	mux.HandleFunc("/admin/config/validate", s.handleConfigValidate)
+	mux.HandleFunc("/admin/config/hash", s.handleConfigHash)
+	mux.HandleFunc("/admin/config/snapshots", s.handleConfigSnapshots)
+	mux.HandleFunc("/admin/config/snapshots/", s.handleConfigSnapshot)
+	mux.HandleFunc("/admin/config/rollback/", s.handleConfigRollback)
+	mux.HandleFunc("/admin/config/warnings", jsonStatsHandler(func() any { return s.gateway.DeprecationWarnings() }))
+	mux.HandleFunc("/admin/reputation", s.handleReputation)
+	mux.HandleFunc("/admin/peer-failover", s.handlePeerFailover)
+	mux.HandleFunc("/admin/debug/trace", s.handleDebugTraces)
+	mux.HandleFunc("/admin/debug/trace/", s.handleDebugTraces)
 	mux.HandleFunc("/drain", s.handleDrain)
+	if s.gateway.TestClock() != nil {
+		mux.HandleFunc("/__test/advance-clock", s.handleAdvanceClock)
+	}
 	mux.HandleFunc("/transport", s.handleTransport)
+	mux.HandleFunc("/transport/canary/", s.handleTransportCanaryAction)
 	mux.HandleFunc("/upstreams", s.handleUpstreams)
+	mux.HandleFunc("/admin/upstreams/", s.handleUpstreamAction)
+	mux.HandleFunc("/admin/routes/", s.handleRouteAction)
+	mux.HandleFunc("/admin/overrides", s.handleOverrides)
+	mux.HandleFunc("/admin/openapi/", s.handleOpenAPIDrift)
 	mux.HandleFunc("/mirrors/", s.handleMirrorsAction)
 	mux.HandleFunc("/canary/", s.handleCanaryAction)
 	mux.HandleFunc("/circuit-breakers/", s.handleCircuitBreakerAction)
+	mux.HandleFunc("/degraded-mode/", s.handleDegradedModeAction)
+	mux.HandleFunc("/waf/", s.handleWAFAction)
 	mux.HandleFunc("/blue-green/", s.handleBlueGreenAction)
 	mux.HandleFunc("/ab-tests/", s.handleABTestAction)
 	mux.HandleFunc("/traffic-replay/", s.handleTrafficReplayAction)
@@ -696,14 +760,32 @@ func (s *Server) adminHandler() http.Handler {
 		}
 		return s.gateway.ssrfDialer.Stats()
 	}))
+	mux.HandleFunc("/egress-policy", jsonStatsHandler(func() any {
+		p := s.gateway.egressPolicy.Load()
+		if p == nil {
+			return map[string]interface{}{"enabled": false}
+		}
+		return p.Stats()
+	}))
 	mux.HandleFunc("/cache/purge", s.handleCachePurge)
+	mux.HandleFunc("/cache/tags/", s.handleCacheTagPurge)
+	mux.HandleFunc("/cache/routes/", s.handleCacheRoutePurge)
 	mux.HandleFunc("/load-shedding", jsonStatsHandler(func() any {
 		if s.gateway.loadShedder == nil {
 			return map[string]interface{}{"enabled": false}
 		}
 		return s.gateway.loadShedder.Stats()
 	}))
+	mux.HandleFunc("/warmup", jsonStatsHandler(func() any {
+		if wu := s.gateway.GetWarmer(); wu != nil {
+			return wu.Stats()
+		}
+		return map[string]interface{}{"enabled": false}
+	}))
+	mux.HandleFunc("/admin/chaos", jsonStatsHandler(func() any { return s.gateway.GetFaultInjectors().Stats() }))
+	mux.HandleFunc("/admin/chaos/stop-all", s.handleChaosStopAll)
 	mux.HandleFunc("/ip-blocklist/refresh", s.handleIPBlocklistRefresh)
+	mux.HandleFunc("/backend-headers-secret/refresh", s.handleSecretHeadersRefresh)
 	mux.HandleFunc("/dashboard", s.handleDashboard)
 	mux.HandleFunc("/tenants/", s.handleTenantCRUD)
 	if s.gateway.GetAPIKeyAuth() != nil {
@@ -714,6 +796,11 @@ func (s *Server) adminHandler() http.Handler {
 			mux.HandleFunc("/api-keys/", s.handleAPIKeyAction)
 		}
 	}
+	if s.gateway.GetJWTAuth() != nil && s.gateway.GetJWTAuth().JWKSStats() != nil {
+		mux.HandleFunc("/jwks/stats", jsonStatsHandler(func() any {
+			return s.gateway.GetJWTAuth().JWKSStats()
+		}))
+	}
 	if s.gateway.GetLDAPAuth() != nil {
 		mux.HandleFunc("/ldap/stats", jsonStatsHandler(func() any {
 			return s.gateway.GetLDAPAuth().Stats()
@@ -843,6 +930,12 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Egress policy violations are reported without degrading health:
+	// refused destinations never receive traffic.
+	if p := s.gateway.egressPolicy.Load(); p != nil {
+		checks["egress_policy"] = p.HealthReport()
+	}
+
 	status := http.StatusOK
 	statusStr := "ok"
 	if !allHealthy {
@@ -862,22 +955,80 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 // handleReady handles readiness check requests with configurable checks
 func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 	stats := s.gateway.GetStats()
-	readyCfg := s.config.Admin.Readiness
+	reasons := s.readinessReasons(r, stats)
+	ready := len(reasons) == 0
+
+	w.Header().Set("Content-Type", "application/json")
+
+	response := map[string]interface{}{
+		"routes":         stats.Routes,
+		"healthy_routes": stats.HealthyRoutes,
+		"listeners":      s.manager.Count(),
+	}
+
+	// Expose the warm-up weight for load balancers that support weighted backends
+	weight := s.readinessWeight(ready)
+	w.Header().Set("X-Runway-Weight", strconv.Itoa(weight))
+	if wu := s.gateway.GetWarmer(); wu != nil {
+		response["weight"] = weight
+		response["warming_up"] = wu.WarmingUp()
+	}
+
+	if ready {
+		w.WriteHeader(http.StatusOK)
+		response["status"] = "ready"
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		response["status"] = "not_ready"
+		response["reasons"] = reasons
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleReadyWeight returns the instance's traffic weight (0-100). Not-ready
+// instances report 0; warming instances report the current ramp value.
+func (s *Server) handleReadyWeight(w http.ResponseWriter, r *http.Request) {
+	ready := len(s.readinessReasons(r, s.gateway.GetStats())) == 0
+	weight := s.readinessWeight(ready)
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Runway-Weight", strconv.Itoa(weight))
+	response := map[string]interface{}{
+		"weight": weight,
+		"ready":  ready,
+	}
+	if wu := s.gateway.GetWarmer(); wu != nil {
+		response["warming_up"] = wu.WarmingUp()
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// readinessWeight returns the traffic weight to advertise for the given readiness.
+func (s *Server) readinessWeight(ready bool) int {
+	if !ready {
+		return 0
+	}
+	if wu := s.gateway.GetWarmer(); wu != nil {
+		return wu.Weight()
+	}
+	return 100
+}
+
+// readinessReasons runs the configured readiness checks and returns the
+// reasons the instance is not ready (empty when ready).
+func (s *Server) readinessReasons(r *http.Request, stats *Stats) []string {
+	readyCfg := s.config.Admin.Readiness
 
-	ready := true
 	reasons := []string{}
 
 	// Check draining state — draining instances are not ready
 	if s.draining.Load() {
-		ready = false
 		reasons = append(reasons, "server is draining")
 	}
 
 	// DP readiness: must have config from CP or cache
 	if s.dpClient != nil && !s.dpClient.HasConfig() {
-		ready = false
 		reasons = append(reasons, "data plane has no config from control plane")
 	}
 
@@ -887,7 +1038,6 @@ func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 		minHealthy = 1
 	}
 	if stats.Routes > 0 && stats.HealthyRoutes < minHealthy {
-		ready = false
 		reasons = append(reasons, fmt.Sprintf("need %d healthy routes, have %d", minHealthy, stats.HealthyRoutes))
 	}
 
@@ -896,168 +1046,670 @@ func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 		defer cancel()
 		if err := s.gateway.redisClient.Ping(ctx).Err(); err != nil {
-			ready = false
 			reasons = append(reasons, "redis unavailable: "+err.Error())
 		}
 	}
 
-	response := map[string]interface{}{
-		"routes":         stats.Routes,
-		"healthy_routes": stats.HealthyRoutes,
-		"listeners":      s.manager.Count(),
+	// Critical dependencies configured to affect readiness
+	if m := s.gateway.GetDependencyMonitor(); m != nil {
+		reasons = append(reasons, m.ReadinessReasons()...)
 	}
 
-	if ready {
-		w.WriteHeader(http.StatusOK)
-		response["status"] = "ready"
-	} else {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		response["status"] = "not_ready"
-		response["reasons"] = reasons
-	}
+	// Cache priming with ready_percent, until enough is primed
+	reasons = append(reasons, s.gateway.cachePrimingReadiness()...)
 
-	json.NewEncoder(w).Encode(response)
+	return reasons
 }
 
-// handleStats handles stats requests
-func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+// handleDependencyHealth handles GET /admin/health/dependencies. Results are
+// cached from background probes, so the endpoint never probes dependencies.
+func (s *Server) handleDependencyHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	stats := s.gateway.GetStats()
-
-	// Add listener count
-	response := map[string]interface{}{
-		"routes":         stats.Routes,
-		"healthy_routes": stats.HealthyRoutes,
-		"backends":       stats.Backends,
-		"listeners":      s.manager.Count(),
+	m := s.gateway.GetDependencyMonitor()
+	if m == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"enabled": false})
+		return
 	}
 
-	// Add TCP/UDP stats if available
-	if s.tcpProxy != nil {
-		response["tcp_routes"] = len(s.config.TCPRoutes)
+	status := m.Status()
+	if status == health.StatusUnhealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
 	}
-	if s.udpProxy != nil {
-		response["udp_routes"] = len(s.config.UDPRoutes)
-		response["udp_sessions"] = s.udpProxy.SessionCount()
+	resp := map[string]interface{}{
+		"enabled":      true,
+		"status":       status,
+		"timestamp":    time.Now().Format(time.RFC3339),
+		"dependencies": m.Results(),
 	}
-
-	json.NewEncoder(w).Encode(response)
+	if p := s.gateway.egressPolicy.Load(); p != nil {
+		resp["egress_policy"] = p.HealthReport()
+	}
+	json.NewEncoder(w).Encode(resp)
 }
 
-// handleRoutes handles routes listing
-func (s *Server) handleRoutes(w http.ResponseWriter, r *http.Request) {
+// handleBackendTLS handles GET /admin/backends/tls, returning the latest
+// backend certificate scan. ?expiring_within=<duration> keeps only backends
+// whose chain expires within that window.
+func (s *Server) handleBackendTLS(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	routes := s.gateway.GetRouter().GetRoutes()
-
-	type routeInfo struct {
-		ID         string   `json:"id"`
-		Path       string   `json:"path"`
-		PathPrefix bool     `json:"path_prefix"`
-		Backends   int      `json:"backends"`
-		Methods    []string `json:"methods,omitempty"`
-		Domains    []string `json:"domains,omitempty"`
-		Headers    int      `json:"header_matchers,omitempty"`
-		Query      int      `json:"query_matchers,omitempty"`
-		Echo       bool     `json:"echo,omitempty"`
+	sc := s.gateway.GetBackendTLSScanner()
+	if sc == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"enabled": false})
+		return
 	}
 
-	result := make([]routeInfo, 0, len(routes))
-	for _, route := range routes {
-		info := routeInfo{
-			ID:         route.ID,
-			Path:       route.Path,
-			PathPrefix: route.PathPrefix,
-			Backends:   len(route.Backends),
-			Echo:       route.Echo,
-			Domains:    route.MatchCfg.Domains,
-			Headers:    len(route.MatchCfg.Headers),
-			Query:      len(route.MatchCfg.Query),
-		}
-
-		if route.Methods != nil {
-			for method := range route.Methods {
-				info.Methods = append(info.Methods, method)
-			}
+	var within time.Duration
+	if v := r.URL.Query().Get("expiring_within"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "expiring_within must be a positive duration"})
+			return
 		}
-
-		result = append(result, info)
+		within = d
 	}
 
-	json.NewEncoder(w).Encode(result)
-}
-
-// handleRegistry handles registry status
-func (s *Server) handleRegistry(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"type": s.config.Registry.Type,
+		"enabled":  true,
+		"scan":     sc.Stats(),
+		"backends": sc.Results(within),
 	})
 }
 
-// handleBackends handles backend health status
-func (s *Server) handleBackends(w http.ResponseWriter, r *http.Request) {
+// handleReputation handles client IP reputation inspection and clearing.
+// GET /admin/reputation — scoring stats
+// GET /admin/reputation?ip=... — the IP's score, strikes, block and history
+// DELETE /admin/reputation?ip=... — forget the IP's score and lift its block
+func (s *Server) handleReputation(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	checker := s.gateway.GetHealthChecker()
-	results := checker.GetAllStatus()
-
-	type backendCheckConfig struct {
-		Method         string   `json:"method"`
-		Path           string   `json:"path"`
-		Interval       string   `json:"interval"`
-		Timeout        string   `json:"timeout"`
-		HealthyAfter   int      `json:"healthy_after"`
-		UnhealthyAfter int      `json:"unhealthy_after"`
-		ExpectedStatus []string `json:"expected_status"`
-	}
-
-	type backendStatus struct {
-		URL       string             `json:"url"`
-		Status    string             `json:"status"`
-		Latency   string             `json:"latency,omitempty"`
-		LastCheck string             `json:"last_check,omitempty"`
-		Error     string             `json:"error,omitempty"`
-		Config    backendCheckConfig `json:"config"`
+	tr := s.gateway.GetReputation()
+	if tr == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"enabled": false})
+		return
 	}
+	ip := r.URL.Query().Get("ip")
 
-	backends := make([]backendStatus, 0, len(results))
-	for _, result := range results {
-		bs := backendStatus{
-			URL:       result.URL,
-			Status:    string(result.Status),
-			Latency:   result.Latency.String(),
-			LastCheck: result.Timestamp.Format(time.RFC3339),
+	switch r.Method {
+	case http.MethodGet:
+		if ip == "" {
+			json.NewEncoder(w).Encode(tr.Stats())
+			return
 		}
-		if result.Error != nil {
-			bs.Error = result.Error.Error()
+		rec, err := tr.Lookup(ip)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
 		}
-		if bcfg, ok := checker.GetBackendConfig(result.URL); ok {
-			bs.Config.Method = bcfg.Method
-			bs.Config.Path = bcfg.HealthPath
-			bs.Config.Interval = bcfg.Interval.String()
-			bs.Config.Timeout = bcfg.Timeout.String()
-			bs.Config.HealthyAfter = bcfg.HealthyAfter
-			bs.Config.UnhealthyAfter = bcfg.UnhealthyAfter
-			for _, sr := range bcfg.ExpectedStatus {
-				if sr.Lo == sr.Hi {
-					bs.Config.ExpectedStatus = append(bs.Config.ExpectedStatus, fmt.Sprintf("%d", sr.Lo))
-				} else {
-					bs.Config.ExpectedStatus = append(bs.Config.ExpectedStatus, fmt.Sprintf("%d-%d", sr.Lo, sr.Hi))
-				}
-			}
+		json.NewEncoder(w).Encode(rec)
+	case http.MethodDelete:
+		if ip == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "ip query parameter is required"})
+			return
 		}
-		backends = append(backends, bs)
+		found, err := tr.Clear(ip)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "no reputation state for ip"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "cleared", "ip": ip})
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 	}
-
-	json.NewEncoder(w).Encode(backends)
 }
 
-// handleListeners handles listeners listing
-func (s *Server) handleListeners(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// handlePeerFailover handles GET /admin/peer-failover, returning the peer
+// gateways with their circuit breakers and counters, and the routes failing
+// over to them.
+func (s *Server) handlePeerFailover(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	f := s.gateway.GetPeerFailover()
+	if f == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"enabled": false})
+		return
+	}
+	json.NewEncoder(w).Encode(f.Stats())
+}
+
+// debugTraceRequest is the body of POST /admin/debug/trace.
+type debugTraceRequest struct {
+	Match          debugtrace.Match `json:"match"`
+	TTL            string           `json:"ttl"`
+	MaxRequests    int              `json:"max_requests"`
+	ResponseHeader bool             `json:"response_header"`
+	Actor          string           `json:"actor"`
+}
+
+// handleDebugTraces handles targeted request tracing sessions.
+// POST   /admin/debug/trace — start a session
+// GET    /admin/debug/trace — the active sessions
+// GET    /admin/debug/trace/{id} — one session
+// DELETE /admin/debug/trace/{id} — end a session
+func (s *Server) handleDebugTraces(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	writeErr := func(status int, err error) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+	tracer := s.gateway.DebugTraces()
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/debug/trace"), "/")
+
+	if id != "" {
+		var session debugtrace.Session
+		var err error
+		switch r.Method {
+		case http.MethodGet:
+			session, err = tracer.Get(id)
+		case http.MethodDelete:
+			session, err = tracer.Cancel(id)
+		default:
+			writeErr(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		if err != nil {
+			writeErr(http.StatusNotFound, err)
+			return
+		}
+		json.NewEncoder(w).Encode(session)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(tracer.Sessions())
+	case http.MethodPost:
+		var req debugTraceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeErr(http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(req.TTL); err != nil {
+				writeErr(http.StatusBadRequest, fmt.Errorf("invalid ttl %q", req.TTL))
+				return
+			}
+		}
+		session, err := tracer.Start(debugtrace.Spec{
+			Match:          req.Match,
+			TTL:            ttl,
+			MaxRequests:    req.MaxRequests,
+			ResponseHeader: req.ResponseHeader,
+			Actor:          req.Actor,
+			RemoteAddr:     r.RemoteAddr,
+		})
+		switch {
+		case errors.Is(err, debugtrace.ErrDuplicateMatch), errors.Is(err, debugtrace.ErrTooManySessions):
+			writeErr(http.StatusConflict, err)
+		case err != nil:
+			writeErr(http.StatusBadRequest, err)
+		default:
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(session)
+		}
+	default:
+		writeErr(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// handleRenderedConfig handles GET /admin/config/rendered, returning the
+// running configuration with every route's effective config resolved and
+// secrets masked. ?provenance=true annotates inherited values.
+func (s *Server) handleRenderedConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	out, err := s.gateway.RenderedConfig(r.URL.Query().Get("provenance") == "true")
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Write(out)
+}
+
+// handleConfigHash handles GET /admin/config/hash, returning the hash of the
+// running configuration and, when drift detection is enabled, the last
+// comparison with peer replicas.
+func (s *Server) handleConfigHash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	resp := map[string]interface{}{"hash": s.gateway.ConfigHash()}
+	if d := s.gateway.GetConfigDrift(); d != nil {
+		resp["drift"] = d.Status()
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleConfigImpact handles POST /admin/config/impact. The body is a
+// candidate YAML config; it is validated and compared with the running
+// config without being applied.
+func (s *Server) handleConfigImpact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20) // 10MB limit
+	configData, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to read body: " + err.Error()})
+		return
+	}
+	if len(configData) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "empty body"})
+		return
+	}
+
+	newCfg, err := config.NewLoader().Parse(configData)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(ImpactReport{Error: err.Error(), Items: []ImpactItem{}})
+		return
+	}
+	json.NewEncoder(w).Encode(s.gateway.ConfigImpact(newCfg))
+}
+
+// handleConfigValidate handles POST /admin/config/validate: it validates a
+// candidate YAML config the way a reload would and reports its errors and
+// how it differs from the running config, without applying it.
+func (s *Server) handleConfigValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20) // 10MB limit
+	configData, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to read body: " + err.Error()})
+		return
+	}
+	if len(configData) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "empty body"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), ConfigValidateTimeout)
+	defer cancel()
+	report, err := s.gateway.ValidateConfig(ctx, configData)
+	if err != nil {
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if !report.Valid {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleConfigSnapshots handles GET /admin/config/snapshots, listing the
+// stored snapshots newest first, and POST, which snapshots the running
+// config.
+func (s *Server) handleConfigSnapshots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeErr := func(status int, err error) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+	m := s.gateway.GetConfigSnapshots()
+	if m == nil {
+		writeErr(http.StatusNotFound, ErrConfigSnapshotsDisabled)
+		return
+	}
+	if r.Method == http.MethodGet {
+		list, err := m.List(r.Context())
+		if err != nil {
+			writeErr(http.StatusInternalServerError, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"snapshots": list})
+		return
+	}
+	snap, err := s.gateway.SaveConfigSnapshot(r.Context(), s.gateway.currentConfig(), "admin")
+	if err != nil {
+		writeErr(http.StatusInternalServerError, err)
+		return
+	}
+	meta := *snap
+	meta.Config = ""
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(meta)
+}
+
+// handleConfigSnapshot handles GET /admin/config/snapshots/{id}.
+func (s *Server) handleConfigSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	id := strings.TrimPrefix(r.URL.Path, "/admin/config/snapshots/")
+	var snap *configsnapshot.Snapshot
+	err := ErrConfigSnapshotsDisabled
+	if m := s.gateway.GetConfigSnapshots(); m != nil {
+		snap, err = m.Get(r.Context(), id)
+	}
+	switch {
+	case errors.Is(err, ErrConfigSnapshotsDisabled), errors.Is(err, configsnapshot.ErrNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	default:
+		json.NewEncoder(w).Encode(snap)
+	}
+}
+
+// handleConfigRollback handles POST /admin/config/rollback/{id}. The
+// snapshot is loaded, validated and applied like any other reload, so the
+// config it replaces is snapshotted in turn. The config file is not changed.
+func (s *Server) handleConfigRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.config.Cluster.Role == "data_plane" {
+		http.Error(w, "config changes must go through control plane", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	id := strings.TrimPrefix(r.URL.Path, "/admin/config/rollback/")
+	newCfg, err := s.gateway.RestoreConfigSnapshot(r.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrConfigSnapshotsDisabled), errors.Is(err, configsnapshot.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrSnapshotSecrets):
+			status = http.StatusConflict
+		case errors.Is(err, ErrInvalidSnapshot):
+			status = http.StatusUnprocessableEntity
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	result := s.reloadWithConfig(newCfg, "rollback")
+	if !result.Success {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleStats handles stats requests
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	stats := s.gateway.GetStats()
+
+	// Add listener count
+	response := map[string]interface{}{
+		"routes":         stats.Routes,
+		"healthy_routes": stats.HealthyRoutes,
+		"backends":       stats.Backends,
+		"listeners":      s.manager.Count(),
+	}
+
+	// Add TCP/UDP stats if available
+	if s.tcpProxy != nil {
+		response["tcp_routes"] = len(s.config.TCPRoutes)
+	}
+	if s.udpProxy != nil {
+		response["udp_routes"] = len(s.config.UDPRoutes)
+		response["udp_sessions"] = s.udpProxy.SessionCount()
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleRoutes handles routes listing
+func (s *Server) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	routes := s.gateway.GetRouter().GetRoutes()
+
+	type routeInfo struct {
+		ID         string   `json:"id"`
+		Path       string   `json:"path"`
+		PathPrefix bool     `json:"path_prefix"`
+		Backends   int      `json:"backends"`
+		Methods    []string `json:"methods,omitempty"`
+		Domains    []string `json:"domains,omitempty"`
+		Headers    int      `json:"header_matchers,omitempty"`
+		Query      int      `json:"query_matchers,omitempty"`
+		Expression string   `json:"match_expression,omitempty"`
+		Echo       bool     `json:"echo,omitempty"`
+		Listeners  []string `json:"listeners,omitempty"`
+
+		Metadata     map[string]string `json:"metadata,omitempty"`
+		ClientAborts map[string]int64 `json:"client_aborts,omitempty"` // by phase, plus "total"
+		AuthzDenied  map[string]int64 `json:"authz_denied,omitempty"`  // 403s from auth.requirements, by requirement, plus "total"
+	}
+
+	snap := s.gateway.metricsCollector.Snapshot()
+	aborts := snap.ClientAborts
+
+	result := make([]routeInfo, 0, len(routes))
+	for _, route := range routes {
+		info := routeInfo{
+			ID:         route.ID,
+			Path:       route.Path,
+			PathPrefix: route.PathPrefix,
+			Backends:   len(route.Backends),
+			Echo:       route.Echo,
+			Domains:    route.MatchCfg.Domains,
+			Headers:    len(route.MatchCfg.Headers),
+			Query:      len(route.MatchCfg.Query),
+			Expression: route.MatchCfg.Expression,
+			Metadata:   route.Metadata,
+			Listeners:  route.Listeners,
+		}
+		if phases := aborts[route.ID]; len(phases) > 0 {
+			info.ClientAborts = make(map[string]int64, len(phases)+1)
+			for phase, n := range phases {
+				info.ClientAborts[phase] = n
+				info.ClientAborts["total"] += n
+			}
+		}
+		if reqs := snap.AuthzDenied[route.ID]; len(reqs) > 0 {
+			info.AuthzDenied = make(map[string]int64, len(reqs)+1)
+			for req, n := range reqs {
+				info.AuthzDenied[req] = n
+				info.AuthzDenied["total"] += n
+			}
+		}
+
+		if route.Methods != nil {
+			for method := range route.Methods {
+				info.Methods = append(info.Methods, method)
+			}
+		}
+
+		result = append(result, info)
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleRegistry handles registry status
+func (s *Server) handleRegistry(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type": s.config.Registry.Type,
+	})
+}
+
+// handleBackends handles backend health status
+func (s *Server) handleBackends(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	checker := s.gateway.GetHealthChecker()
+	results := checker.GetAllStatus()
+
+	type backendCheckConfig struct {
+		Type           string   `json:"type"`
+		Method         string   `json:"method,omitempty"`
+		Path           string   `json:"path,omitempty"`
+		Interval       string   `json:"interval"`
+		Timeout        string   `json:"timeout"`
+		HealthyAfter   int      `json:"healthy_after"`
+		UnhealthyAfter int      `json:"unhealthy_after"`
+		ExpectedStatus []string `json:"expected_status,omitempty"`
+		Send           string   `json:"send,omitempty"`
+		Expect         string   `json:"expect,omitempty"`
+		Service        string   `json:"service,omitempty"`
+	}
+
+	type backendStatus struct {
+		URL           string             `json:"url"`
+		Status        string             `json:"status"`
+		AdminState    string             `json:"admin_state"`
+		DrainedRoutes []string           `json:"drained_routes,omitempty"`
+		Latency       string             `json:"latency,omitempty"`
+		LastCheck     string             `json:"last_check,omitempty"`
+		Error         string             `json:"error,omitempty"`
+		Config        backendCheckConfig `json:"config"`
+	}
+
+	drains := s.gateway.BackendDrains()
+	backends := make([]backendStatus, 0, len(results))
+	for _, result := range results {
+		bs := backendStatus{
+			URL:        result.URL,
+			Status:     string(result.Status),
+			AdminState: "active",
+			Latency:    result.Latency.String(),
+			LastCheck:  result.Timestamp.Format(time.RFC3339),
+		}
+		if d, ok := drains[result.URL]; ok {
+			bs.AdminState = "drained"
+			bs.DrainedRoutes = d.Routes
+		}
+		if result.Error != nil {
+			bs.Error = result.Error.Error()
+		}
+		if bcfg, ok := checker.GetBackendConfig(result.URL); ok {
+			bs.Config.Type = bcfg.Type
+			bs.Config.Interval = bcfg.Interval.String()
+			bs.Config.Timeout = bcfg.Timeout.String()
+			bs.Config.HealthyAfter = bcfg.HealthyAfter
+			bs.Config.UnhealthyAfter = bcfg.UnhealthyAfter
+			switch bcfg.Type {
+			case health.ProbeTCP:
+				bs.Config.Send = bcfg.Send
+				bs.Config.Expect = bcfg.Expect
+			case health.ProbeGRPC:
+				bs.Config.Service = bcfg.Service
+			default:
+				bs.Config.Method = bcfg.Method
+				bs.Config.Path = bcfg.HealthPath
+				for _, sr := range bcfg.ExpectedStatus {
+					if sr.Lo == sr.Hi {
+						bs.Config.ExpectedStatus = append(bs.Config.ExpectedStatus, fmt.Sprintf("%d", sr.Lo))
+					} else {
+						bs.Config.ExpectedStatus = append(bs.Config.ExpectedStatus, fmt.Sprintf("%d-%d", sr.Lo, sr.Hi))
+					}
+				}
+			}
+		}
+		backends = append(backends, bs)
+	}
+
+	json.NewEncoder(w).Encode(backends)
+}
+
+// handleBackendDrain handles POST /backends/drain and POST /backends/undrain.
+// The body names the backend URL and, optionally, the one route to act on;
+// without a route the backend is drained or undrained on every route.
+func (s *Server) handleBackendDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		URL   string `json:"url"`
+		Route string `json:"route"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "body must be a JSON object with a \"url\""})
+		return
+	}
+
+	drain := r.URL.Path == "/backends/drain"
+	var routes []string
+	var err error
+	if drain {
+		routes, err = s.gateway.DrainBackend(req.URL, req.Route)
+	} else {
+		routes, err = s.gateway.UndrainBackend(req.URL, req.Route)
+	}
+	if err != nil {
+		code := http.StatusNotFound
+		if errors.Is(err, errDrainedOnAllRoutes) {
+			code = http.StatusConflict
+		}
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	status := "drained"
+	if !drain {
+		status = "active"
+	}
+	logging.Info("backend admin state changed",
+		zap.String("source", "admin"),
+		zap.String("backend", req.URL),
+		zap.String("route", req.Route),
+		zap.String("admin_state", status))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"url":         req.URL,
+		"admin_state": status,
+		"routes":      routes,
+	})
+}
+
+// handleListeners handles listeners listing
+func (s *Server) handleListeners(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
 	listenerIDs := s.manager.List()
 
@@ -1067,6 +1719,8 @@ func (s *Server) handleListeners(w http.ResponseWriter, r *http.Request) {
 		Address  string `json:"address"`
 		HTTP3    bool   `json:"http3,omitempty"`
 		ACME     bool   `json:"acme,omitempty"`
+
+		StreamEnforcement map[string]int64 `json:"stream_enforcement,omitempty"`
 	}
 
 	result := make([]listenerInfo, 0, len(listenerIDs))
@@ -1080,6 +1734,7 @@ func (s *Server) handleListeners(w http.ResponseWriter, r *http.Request) {
 			if hl, ok := l.(*listener.HTTPListener); ok {
 				info.HTTP3 = hl.HTTP3Enabled()
 				info.ACME = hl.ACMEManager() != nil
+				info.StreamEnforcement = hl.StreamStats()
 			}
 			result = append(result, info)
 		}
@@ -1105,13 +1760,9 @@ func (s *Server) handleCertificates(w http.ResponseWriter, r *http.Request) {
 		if !ok {
 			continue
 		}
-		hl, ok := l.(*listener.HTTPListener)
-		if !ok {
-			continue
-		}
 
-		if acmeMgr := hl.ACMEManager(); acmeMgr != nil {
-			info := acmeMgr.CertStatus()
+		if hl, ok := l.(*listener.HTTPListener); ok && hl.ACMEManager() != nil {
+			info := hl.ACMEManager().CertStatus()
 			result = append(result, certEntry{
 				ListenerID: id,
 				Mode:       "acme",
@@ -1124,38 +1775,60 @@ func (s *Server) handleCertificates(w http.ResponseWriter, r *http.Request) {
 					"serial":     info.Serial,
 				},
 			})
-		} else if cert := hl.CertPtr(); cert != nil {
-			// Manual TLS: parse leaf cert for expiry info
-			entry := certEntry{
-				ListenerID: id,
-				Mode:       "manual",
-			}
-			// Find the config for this listener to get file paths
-			var certFile, keyFile string
-			for _, lc := range s.config.Listeners {
-				if lc.ID == id {
-					certFile = lc.TLS.CertFile
-					keyFile = lc.TLS.KeyFile
-					break
-				}
-			}
-			manualInfo := map[string]interface{}{
-				"cert_file": certFile,
-				"key_file":  keyFile,
-			}
-			if leaf := parseCertLeaf(cert); leaf != nil {
-				manualInfo["not_after"] = leaf.NotAfter
-				manualInfo["days_left"] = int(time.Until(leaf.NotAfter).Hours() / 24)
-				manualInfo["serial"] = formatCertSerial(leaf.SerialNumber)
-			}
-			entry.Manual = manualInfo
-			result = append(result, entry)
+			continue
+		}
+
+		// Manual TLS, on HTTP and TLS-terminating TCP listeners
+		cp, ok := l.(certStatusProvider)
+		if !ok {
+			continue
 		}
+		st := cp.CertStatus()
+		if st == nil {
+			continue
+		}
+		manualInfo := map[string]interface{}{
+			"reload":       st.Reload,
+			"last_reload":  st.LastReload,
+			"not_after":    st.NotAfter,
+			"days_left":    int(time.Until(st.NotAfter).Hours() / 24),
+			"certificates": st.Certificates,
+		}
+		if st.ReloadInterval != "" {
+			manualInfo["reload_interval"] = st.ReloadInterval
+		}
+		if st.LastError != "" {
+			manualInfo["last_error"] = st.LastError
+			manualInfo["last_error_at"] = st.LastErrorAt
+		}
+		if len(st.Certificates) > 0 {
+			first := st.Certificates[0]
+			manualInfo["cert_file"] = first.CertFile
+			manualInfo["key_file"] = first.KeyFile
+			manualInfo["serial"] = first.Serial
+		}
+		result = append(result, certEntry{
+			ListenerID: id,
+			Mode:       "manual",
+			Manual:     manualInfo,
+		})
 	}
 
 	json.NewEncoder(w).Encode(result)
 }
 
+// certStatusProvider is implemented by listeners that serve manual TLS
+// certificates.
+type certStatusProvider interface {
+	CertStatus() *listener.CertStatus
+}
+
+// certReloadSetter is implemented by listeners that reload certificate
+// files.
+type certReloadSetter interface {
+	SetCertReload(config.TLSReloadConfig)
+}
+
 // checkTLSCertificates checks all listener TLS certs and returns health status.
 // Returns nil if no TLS listeners exist.
 func (s *Server) checkTLSCertificates() map[string]interface{} {
@@ -1179,13 +1852,10 @@ func (s *Server) checkTLSCertificates() map[string]interface{} {
 			if info.DaysLeft >= 0 && info.DaysLeft < minDaysLeft {
 				allOK = false
 			}
-		} else if cert := hl.CertPtr(); cert != nil {
+		} else if st := hl.CertStatus(); st != nil {
 			hasTLS = true
-			if leaf := parseCertLeaf(cert); leaf != nil {
-				daysLeft := int(time.Until(leaf.NotAfter).Hours() / 24)
-				if daysLeft < minDaysLeft {
-					allOK = false
-				}
+			if !st.NotAfter.IsZero() && int(time.Until(st.NotAfter).Hours()/24) < minDaysLeft {
+				allOK = false
 			}
 		}
 	}
@@ -1204,29 +1874,6 @@ func (s *Server) checkTLSCertificates() map[string]interface{} {
 	}
 }
 
-// parseCertLeaf parses the leaf certificate from a tls.Certificate.
-func parseCertLeaf(cert *tls.Certificate) *x509.Certificate {
-	if cert == nil || len(cert.Certificate) == 0 {
-		return nil
-	}
-	if cert.Leaf != nil {
-		return cert.Leaf
-	}
-	leaf, err := x509.ParseCertificate(cert.Certificate[0])
-	if err != nil {
-		return nil
-	}
-	return leaf
-}
-
-// formatCertSerial formats a certificate serial number as hex.
-func formatCertSerial(serial *big.Int) string {
-	if serial == nil {
-		return ""
-	}
-	return fmt.Sprintf("%X", serial)
-}
-
 // handleRules handles rules engine status requests
 func (s *Server) handleRules(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -1325,7 +1972,11 @@ func (s *Server) handleAPIKeyStats(w http.ResponseWriter, r *http.Request) {
 	}
 	mgr := s.gateway.GetAPIKeyAuth().GetManager()
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(mgr.Stats())
+	stats := mgr.Stats()
+	if ss := s.gateway.GetKeySelfService(); ss != nil {
+		stats["self_service"] = ss.Stats()
+	}
+	json.NewEncoder(w).Encode(stats)
 }
 
 func (s *Server) handleAPIKeyAction(w http.ResponseWriter, r *http.Request) {
@@ -1477,10 +2128,15 @@ func (s *Server) handleRateLimits(w http.ResponseWriter, r *http.Request) {
 	result := make(map[string]interface{})
 	for _, id := range routeIDs {
 		mode, algorithm := rl.LimiterInfo(id)
-		result[id] = map[string]interface{}{
-			"mode":      mode,
-			"algorithm": algorithm,
+		info := map[string]interface{}{
+			"mode":             mode,
+			"algorithm":        algorithm,
+			"restored_buckets": rl.RestoredBuckets(id),
+		}
+		if observed, ok := rl.ObservedRejections(id); ok {
+			info["observed_rejections"] = observed
 		}
+		result[id] = info
 	}
 	json.NewEncoder(w).Encode(result)
 }
@@ -1608,29 +2264,72 @@ func (s *Server) handleMaintenanceAction(w http.ResponseWriter, r *http.Request)
 	routeID := parts[0]
 	action := parts[1]
 
-	cm := s.gateway.GetMaintenanceHandlers().Lookup(routeID)
-	if cm == nil {
+	if action != "enable" && action != "disable" {
+		http.Error(w, `{"error":"action must be 'enable' or 'disable'"}`, http.StatusBadRequest)
+		return
+	}
+	if err := s.gateway.SetMaintenance(routeID, action == "enable"); err != nil {
 		http.Error(w, `{"error":"route not found or maintenance not configured"}`, http.StatusNotFound)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": action + "d",
+		"route":  routeID,
+	})
+}
+
+// handleFeatureAction handles POST /features/{route}/{feature}/{action}.
+// Actions: enable, disable, reset (remove the runtime override).
+func (s *Server) handleFeatureAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/features/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, `{"error":"expected /features/{route}/{feature}/{action}"}`, http.StatusBadRequest)
+		return
+	}
+	routeID, feature, action := parts[0], parts[1], parts[2]
+
 	w.Header().Set("Content-Type", "application/json")
 	switch action {
-	case "enable":
-		cm.Enable()
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status":  "enabled",
-			"route":   routeID,
-		})
-	case "disable":
-		cm.Disable()
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status":  "disabled",
-			"route":   routeID,
-		})
+	case "enable", "disable":
+		if err := s.gateway.SetFeatureEnabled(routeID, feature, action == "enable"); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+	case "reset":
+		s.gateway.ClearFeature(routeID, feature)
 	default:
-		http.Error(w, `{"error":"action must be 'enable' or 'disable'"}`, http.StatusBadRequest)
+		http.Error(w, `{"error":"action must be 'enable', 'disable' or 'reset'"}`, http.StatusBadRequest)
+		return
 	}
+	logging.Info("feature override changed",
+		zap.String("source", "admin"),
+		zap.String("route", routeID),
+		zap.String("feature", feature),
+		zap.String("action", action))
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "route": routeID, "feature": feature, "action": action})
+}
+
+// handleFeatureFlags handles GET /admin/feature-flags: watcher state plus the
+// runtime overrides currently in effect.
+func (s *Server) handleFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	result := map[string]interface{}{"enabled": false}
+	if ff := s.gateway.GetFeatureFlags(); ff != nil {
+		result = ff.Stats()
+	}
+	result["feature_overrides"] = s.gateway.GetFeatureOverrides().Snapshot()
+	result["log_level"] = logging.Level()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
 }
 
 
@@ -1800,6 +2499,47 @@ func (s *Server) handleIPBlocklistRefresh(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// handleChaosStopAll stops fault injection on every route. Stopped routes
+// stay stopped across reloads that leave their fault_injection unchanged.
+func (s *Server) handleChaosStopAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	stopped := trafficshape.StopFaultInjection(s.gateway.GetFaultInjectors())
+	if stopped == nil {
+		stopped = []string{}
+	}
+	logging.Warn("Fault injection stopped via admin API", zap.Strings("routes", stopped))
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"stopped": stopped,
+	})
+}
+
+func (s *Server) handleSecretHeadersRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	refreshed := 0
+	s.gateway.secretHeaders.Range(func(_ string, inj *secretheaders.Injector) bool {
+		inj.Refresh()
+		refreshed++
+		return true
+	})
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "ok",
+		"refreshed": refreshed,
+	})
+}
+
 func (s *Server) handleCachePurge(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	if r.Method != http.MethodPost {
@@ -1867,7 +2607,79 @@ func (s *Server) handleCachePurge(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": "route not found"})
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]interface{}{"purged": true, "entries_removed": count})
+	json.NewEncoder(w).Encode(map[string]interface{}{"purged": true, "entries_removed": count})
+}
+
+// handleCacheTagPurge handles DELETE /cache/tags/{tag}: the entries tagged
+// tag are removed from every route's cache.
+func (s *Server) handleCacheTagPurge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	tag := strings.TrimPrefix(r.URL.Path, "/cache/tags/")
+	if tag == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "usage: DELETE /cache/tags/{tag}"})
+		return
+	}
+	count := s.gateway.caches.PurgeTag(tag)
+	json.NewEncoder(w).Encode(map[string]interface{}{"purged": true, "tag": tag, "entries_removed": count})
+}
+
+// handleCacheRoutePurge handles DELETE /cache/routes/{routeID}.
+func (s *Server) handleCacheRoutePurge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	routeID := strings.TrimPrefix(r.URL.Path, "/cache/routes/")
+	if routeID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "usage: DELETE /cache/routes/{routeID}"})
+		return
+	}
+	count, ok := s.gateway.caches.PurgeRoute(routeID)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "route not found"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"purged": true, "route": routeID, "entries_removed": count})
+}
+
+// handleCachePriming handles GET /admin/cache/priming, the progress of
+// each route's cache priming.
+func (s *Server) handleCachePriming(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	json.NewEncoder(w).Encode(s.gateway.CachePriming())
+}
+
+// handleArtifacts lists the remote artifact cache.
+// GET /admin/artifacts — cached artifacts, their digests and the routes referencing them
+func (s *Server) handleArtifacts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	artifacts, err := s.gateway.Artifacts()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(artifacts)
 }
 
 func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
@@ -1904,6 +2716,65 @@ func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleAdvanceClock handles /__test/advance-clock in test mode. GET returns
+// the frozen time; POST moves it forward by "duration" or to "to".
+func (s *Server) handleAdvanceClock(w http.ResponseWriter, r *http.Request) {
+	clk := s.gateway.TestClock()
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{"now": clk.Now().Format(time.RFC3339Nano)})
+
+	case http.MethodPost:
+		var req struct {
+			Duration string `json:"duration"`
+			To       string `json:"to"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		before := clk.Now()
+		var now time.Time
+		switch {
+		case req.Duration != "" && req.To != "":
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "set either duration or to"})
+			return
+		case req.Duration != "":
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil || d < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "duration must be a non-negative Go duration"})
+				return
+			}
+			now = clk.Advance(d)
+		case req.To != "":
+			t, err := time.Parse(time.RFC3339Nano, req.To)
+			if err != nil || t.Before(before) {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "to must be an RFC 3339 time no earlier than the clock"})
+				return
+			}
+			clk.Set(t)
+			now = t
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "duration or to is required"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"now":      now.Format(time.RFC3339Nano),
+			"advanced": now.Sub(before).String(),
+		})
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // handleUpstreams returns configured upstream pools.
 func (s *Server) handleUpstreams(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -1920,8 +2791,10 @@ func (s *Server) handleTransport(w http.ResponseWriter, r *http.Request) {
 
 	pool := s.gateway.GetTransportPool()
 	result := map[string]interface{}{
-		"default":   pool.DefaultConfig(),
-		"upstreams": make(map[string]interface{}),
+		"default":    pool.DefaultConfig(),
+		"upstreams":  make(map[string]interface{}),
+		"dial_stats": pool.DialStats(),
+		"canaries":   pool.CanaryStats(),
 	}
 
 	// Show per-upstream transport overrides from config
@@ -1935,6 +2808,38 @@ func (s *Server) handleTransport(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+// handleTransportCanaryAction handles POST /transport/canary/{upstream}/promote.
+func (s *Server) handleTransportCanaryAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.TrimPrefix(r.URL.Path, "/transport/canary/")
+	upstream, action, ok := strings.Cut(path, "/")
+	if !ok || upstream == "" || action != "promote" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "expected /transport/canary/{upstream}/promote"})
+		return
+	}
+	ct := s.gateway.GetTransportPool().Canary(upstream)
+	if ct == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "upstream has no transport canary"})
+		return
+	}
+	if err := s.gateway.PromoteTransportCanary(upstream); err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"upstream": upstream,
+		"canary":   ct.Snapshot(),
+	})
+}
+
 // handleCanaryAction handles POST /canary/{route}/{action}.
 func (s *Server) handleCanaryAction(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -2006,6 +2911,28 @@ func (s *Server) handleSchemaEvolution(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(report)
 }
 
+// handleOpenAPIDrift handles GET /admin/openapi/{spec}/drift. spec is a
+// spec_id or a spec_file path, which may contain slashes.
+func (s *Server) handleOpenAPIDrift(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	spec, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/admin/openapi/"), "/drift")
+	if !ok || spec == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	reports, found := s.gateway.GetOpenAPIValidators().DriftReports(spec)
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "no route has schema_drift enabled for spec " + spec})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"spec": spec, "routes": reports})
+}
+
 // handleCircuitBreakerAction handles POST /circuit-breakers/{route}/{action}.
 // Supported actions: open (force open), close (force close), reset (return to auto).
 func (s *Server) handleCircuitBreakerAction(w http.ResponseWriter, r *http.Request) {
@@ -2047,6 +2974,408 @@ func (s *Server) handleCircuitBreakerAction(w http.ResponseWriter, r *http.Reque
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "action": actionName, "route": routeID})
 }
 
+// handleDegradedModeAction handles POST /degraded-mode/{route}/{action}.
+// Supported actions: enter (force degraded), exit (force normal), reset (return to auto).
+func (s *Server) handleDegradedModeAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse /degraded-mode/{route}/{action}
+	path := strings.TrimPrefix(r.URL.Path, "/degraded-mode/")
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "usage: POST /degraded-mode/{route}/{action}", http.StatusBadRequest)
+		return
+	}
+	routeID := parts[0]
+	actionName := parts[1]
+
+	var err error
+	switch actionName {
+	case "enter":
+		err = s.gateway.GetDegradedModes().ForceDegraded(routeID)
+	case "exit":
+		err = s.gateway.GetDegradedModes().ForceNormal(routeID)
+	case "reset":
+		err = s.gateway.GetDegradedModes().ResetOverride(routeID)
+	default:
+		http.Error(w, fmt.Sprintf("unknown action %q (valid: enter, exit, reset)", actionName), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "action": actionName, "route": routeID})
+}
+
+// handleWAFAction handles POST /waf/{route}/promote-shadow.
+func (s *Server) handleWAFAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse /waf/{route}/{action}
+	path := strings.TrimPrefix(r.URL.Path, "/waf/")
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "usage: POST /waf/{route}/promote-shadow", http.StatusBadRequest)
+		return
+	}
+	routeID := parts[0]
+	actionName := parts[1]
+
+	if actionName != "promote-shadow" {
+		http.Error(w, fmt.Sprintf("unknown action %q (valid: promote-shadow)", actionName), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := s.gateway.GetWAFHandlers().PromoteShadow(routeID); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "action": actionName, "route": routeID})
+}
+
+// upstreamSwapRequest is the body of POST /admin/upstreams/{name}/swap.
+type upstreamSwapRequest struct {
+	Backends []struct {
+		URL    string `json:"url"`
+		Weight int    `json:"weight"`
+	} `json:"backends"`
+	Verify struct {
+		Method         string `json:"method"`
+		Path           string `json:"path"`
+		ExpectedStatus int    `json:"expected_status"`
+		Count          int    `json:"count"`
+		Timeout        string `json:"timeout"`
+	} `json:"verify"`
+}
+
+// handleUpstreamAction handles POST /admin/upstreams/{name}/{swap|rollback}.
+func (s *Server) handleUpstreamAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/admin/upstreams/")
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "usage: POST /admin/upstreams/{name}/{swap|rollback}", http.StatusBadRequest)
+		return
+	}
+	name, action := parts[0], parts[1]
+
+	w.Header().Set("Content-Type", "application/json")
+	writeErr := func(status int, err error) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+
+	var result *UpstreamSwapResult
+	var err error
+	switch action {
+	case "swap":
+		var req upstreamSwapRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeErr(http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		backends := make([]config.BackendConfig, len(req.Backends))
+		for i, b := range req.Backends {
+			backends[i] = config.BackendConfig{URL: b.URL, Weight: b.Weight}
+		}
+		verify := config.UpstreamVerifyConfig{
+			Method:         req.Verify.Method,
+			Path:           req.Verify.Path,
+			ExpectedStatus: req.Verify.ExpectedStatus,
+			Count:          req.Verify.Count,
+		}
+		if req.Verify.Timeout != "" {
+			if verify.Timeout, err = time.ParseDuration(req.Verify.Timeout); err != nil || verify.Timeout < 0 {
+				writeErr(http.StatusBadRequest, fmt.Errorf("invalid verify.timeout %q", req.Verify.Timeout))
+				return
+			}
+		}
+		if verify.Count < 0 || (verify.ExpectedStatus != 0 && (verify.ExpectedStatus < 100 || verify.ExpectedStatus > 599)) {
+			writeErr(http.StatusBadRequest, fmt.Errorf("verify.count must be >= 0 and verify.expected_status between 100 and 599"))
+			return
+		}
+		result, err = s.gateway.SwapUpstream(r.Context(), name, backends, verify)
+	case "rollback":
+		result, err = s.gateway.RollbackUpstream(name)
+	default:
+		writeErr(http.StatusBadRequest, fmt.Errorf("unknown action %q (valid: swap, rollback)", action))
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrUpstreamVerification):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "result": result})
+	case errors.Is(err, ErrUpstreamNotFound):
+		writeErr(http.StatusNotFound, err)
+	case errors.Is(err, ErrNoUpstreamRollback):
+		writeErr(http.StatusConflict, err)
+	case err != nil:
+		writeErr(http.StatusBadRequest, err)
+	default:
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// breakGlassRequest is the body of POST /admin/routes/{id}/break-glass and
+// its revert.
+type breakGlassRequest struct {
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+	TTL    string `json:"ttl"`
+}
+
+// handleRouteAction handles per-route admin actions:
+// POST /admin/routes/{id}/break-glass — bypass the route's break_glass features
+// POST /admin/routes/{id}/break-glass/revert — end the bypass early
+// GET  /admin/routes/{id}/break-glass — the route's active bypass
+// POST /admin/routes/{id}/simulate — dry-run a request through the route
+// GET  /admin/routes/{id}/response-pipeline — the route's response body stages
+// POST /admin/routes/{id}/pause — hold or reject the route's requests
+// GET  /admin/routes/{id}/pause — the route's active pause
+// POST /admin/routes/{id}/resume — end the pause
+func (s *Server) handleRouteAction(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/admin/routes/")
+	routeID, action, _ := strings.Cut(path, "/")
+	switch action {
+	case "break-glass", "break-glass/revert", "simulate", "response-pipeline", "pause", "resume":
+	default:
+		routeID = ""
+	}
+	if routeID == "" {
+		http.Error(w, "usage: /admin/routes/{id}/break-glass[/revert], /admin/routes/{id}/simulate, /admin/routes/{id}/response-pipeline or /admin/routes/{id}/{pause,resume}", http.StatusBadRequest)
+		return
+	}
+	if action == "pause" || action == "resume" {
+		s.handleRoutePause(w, r, routeID, action)
+		return
+	}
+	if action == "simulate" {
+		s.handleRouteSimulate(w, r, routeID)
+		return
+	}
+	if action == "response-pipeline" {
+		s.handleResponsePipeline(w, r, routeID)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeErr := func(status int, err error) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+
+	if r.Method == http.MethodGet && action == "break-glass" {
+		for _, st := range s.gateway.BreakGlassStates() {
+			if st.Route == routeID {
+				json.NewEncoder(w).Encode(st)
+				return
+			}
+		}
+		writeErr(http.StatusNotFound, ErrBreakGlassNotActive)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeErr(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	var req breakGlassRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && (action == "break-glass" || err != io.EOF) {
+		writeErr(http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	var state *BreakGlassState
+	var err error
+	if action == "break-glass" {
+		var ttl time.Duration
+		if ttl, err = time.ParseDuration(req.TTL); err != nil {
+			writeErr(http.StatusBadRequest, fmt.Errorf("invalid ttl %q", req.TTL))
+			return
+		}
+		state, err = s.gateway.ActivateBreakGlass(routeID, BreakGlassRequest{
+			Actor:      req.Actor,
+			Reason:     req.Reason,
+			TTL:        ttl,
+			RemoteAddr: r.RemoteAddr,
+		})
+	} else {
+		state, err = s.gateway.RevertBreakGlass(routeID, req.Actor)
+	}
+
+	switch {
+	case errors.Is(err, ErrBreakGlassNotConfigured), errors.Is(err, ErrBreakGlassNotActive):
+		writeErr(http.StatusNotFound, err)
+	case errors.Is(err, ErrBreakGlassActive):
+		writeErr(http.StatusConflict, err)
+	case err != nil:
+		writeErr(http.StatusBadRequest, err)
+	default:
+		json.NewEncoder(w).Encode(state)
+	}
+}
+
+// routePauseRequest is the body of POST /admin/routes/{id}/pause and
+// its resume.
+type routePauseRequest struct {
+	Mode     string `json:"mode"`
+	MaxDepth int    `json:"max_depth"`
+	MaxWait  string `json:"max_wait"`
+	TTL      string `json:"ttl"`
+	Actor    string `json:"actor"`
+	Reason   string `json:"reason"`
+}
+
+// handleRoutePause handles /admin/routes/{id}/pause and /resume.
+func (s *Server) handleRoutePause(w http.ResponseWriter, r *http.Request, routeID, action string) {
+	w.Header().Set("Content-Type", "application/json")
+	writeErr := func(status int, err error) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+
+	if r.Method == http.MethodGet && action == "pause" {
+		if st := s.gateway.RoutePause(routeID); st != nil {
+			json.NewEncoder(w).Encode(st)
+			return
+		}
+		writeErr(http.StatusNotFound, ErrRouteNotPaused)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeErr(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	var req routePauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeErr(http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	var state *RoutePauseState
+	var err error
+	if action == "pause" {
+		durations := map[string]time.Duration{}
+		for name, v := range map[string]string{"max_wait": req.MaxWait, "ttl": req.TTL} {
+			if v == "" {
+				continue
+			}
+			if durations[name], err = time.ParseDuration(v); err != nil {
+				writeErr(http.StatusBadRequest, fmt.Errorf("invalid %s %q", name, v))
+				return
+			}
+		}
+		state, err = s.gateway.PauseRoute(routeID, RoutePauseRequest{
+			Mode:       req.Mode,
+			MaxDepth:   req.MaxDepth,
+			MaxWait:    durations["max_wait"],
+			TTL:        durations["ttl"],
+			Actor:      req.Actor,
+			Reason:     req.Reason,
+			RemoteAddr: r.RemoteAddr,
+		})
+	} else {
+		state, err = s.gateway.ResumeRoute(routeID, req.Actor)
+	}
+
+	switch {
+	case errors.Is(err, ErrRouteNotFound), errors.Is(err, ErrRouteNotPaused):
+		writeErr(http.StatusNotFound, err)
+	case errors.Is(err, ErrRoutePaused):
+		writeErr(http.StatusConflict, err)
+	case err != nil:
+		writeErr(http.StatusBadRequest, err)
+	default:
+		json.NewEncoder(w).Encode(state)
+	}
+}
+
+// handleRouteSimulate handles POST /admin/routes/{id}/simulate.
+func (s *Server) handleRouteSimulate(w http.ResponseWriter, r *http.Request, routeID string) {
+	w.Header().Set("Content-Type", "application/json")
+	writeErr := func(status int, err error) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+	if r.Method != http.MethodPost {
+		writeErr(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	var req simulate.Request
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeErr(http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	result, err := s.gateway.SimulateRequest(routeID, req)
+	switch {
+	case errors.Is(err, ErrRouteNotFound):
+		writeErr(http.StatusNotFound, err)
+	case err != nil:
+		writeErr(http.StatusBadRequest, err)
+	default:
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// handleResponsePipeline handles GET /admin/routes/{id}/response-pipeline.
+func (s *Server) handleResponsePipeline(w http.ResponseWriter, r *http.Request, routeID string) {
+	w.Header().Set("Content-Type", "application/json")
+	writeErr := func(status int, err error) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+	if r.Method != http.MethodGet {
+		writeErr(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	p, err := s.gateway.ResponsePipeline(routeID)
+	if err != nil {
+		writeErr(http.StatusNotFound, err)
+		return
+	}
+	json.NewEncoder(w).Encode(p)
+}
+
+// handleOverrides handles GET /admin/overrides: every runtime change to
+// configured behaviour, with active break-glass bypasses first.
+func (s *Server) handleOverrides(w http.ResponseWriter, r *http.Request) {
+	bg := s.gateway.BreakGlassStates()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		BreakGlassActive bool                       `json:"break_glass_active"`
+		BreakGlass       []*BreakGlassState         `json:"break_glass"`
+		PausedRoutes     []*RoutePauseState         `json:"paused_routes"`
+		FeatureOverrides map[string]map[string]bool `json:"feature_overrides"`
+		LogLevel         string                     `json:"log_level"`
+	}{
+		BreakGlassActive: len(bg) > 0,
+		BreakGlass:       bg,
+		PausedRoutes:     s.gateway.RoutePauses(),
+		FeatureOverrides: s.gateway.GetFeatureOverrides().Snapshot(),
+		LogLevel:         logging.Level(),
+	})
+}
+
 // handleBlueGreenAction handles POST /blue-green/{route}/{action}.
 func (s *Server) handleBlueGreenAction(w http.ResponseWriter, r *http.Request) {
 	// Parse /blue-green/{route}/{action}
@@ -1,14 +1,23 @@
 package runway
 
 import (
+	"context"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/featureflags"
+	"github.com/wudi/runway/internal/health"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware/ipblocklist"
+	"github.com/wudi/runway/internal/tlsscan"
 	"github.com/wudi/runway/ui"
 )
 
@@ -607,6 +616,90 @@ func TestAdminCachePurgeNotFound(t *testing.T) {
 	}
 }
 
+func TestAdminCacheVaryAndDeletePurge(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept-Language")
+		w.Header().Set("Cache-Tag", "greeting")
+		w.Write([]byte("greeting in " + r.Header.Get("Accept-Language")))
+	}))
+	defer backend.Close()
+
+	cacheCfg := config.CacheConfig{
+		Enabled:    true,
+		TTL:        60 * time.Second,
+		Bucket:     "pages",
+		TagHeaders: []string{"Cache-Tag"},
+	}
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{
+			{ID: "home", Path: "/home", Backends: []config.BackendConfig{{URL: backend.URL}}, Cache: cacheCfg},
+			{ID: "about", Path: "/about", Backends: []config.BackendConfig{{URL: backend.URL}}, Cache: cacheCfg},
+		},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	get := func(path, lang string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Language", lang)
+		w := httptest.NewRecorder()
+		server.Runway().Handler().ServeHTTP(w, req)
+		return w
+	}
+	purge := func(path string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		server.adminHandler().ServeHTTP(w, httptest.NewRequest("DELETE", path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("DELETE %s: expected 200, got %d: %s", path, w.Code, w.Body.String())
+		}
+		var result map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return result
+	}
+
+	get("/home", "de")
+	if w := get("/home", "fr"); w.Header().Get("X-Cache") != "MISS" || w.Body.String() != "greeting in fr" {
+		t.Fatalf("expected a fr miss, got %s %q", w.Header().Get("X-Cache"), w.Body.String())
+	}
+	if w := get("/home", "de"); w.Header().Get("X-Cache") != "HIT" || w.Body.String() != "greeting in de" {
+		t.Fatalf("expected a de hit, got %s %q", w.Header().Get("X-Cache"), w.Body.String())
+	}
+	get("/about", "de")
+
+	if result := purge("/cache/routes/home"); result["entries_removed"] != float64(2) {
+		t.Errorf("expected 2 home entries removed, got %v", result)
+	}
+	if w := get("/about", "de"); w.Header().Get("X-Cache") != "HIT" {
+		t.Error("purging home must keep about's entries in the shared bucket")
+	}
+	if result := purge("/cache/tags/greeting"); result["entries_removed"] != float64(1) {
+		t.Errorf("expected 1 entry removed for the tag, got %v", result)
+	}
+	if w := get("/about", "de"); w.Header().Get("X-Cache") != "MISS" {
+		t.Error("expected the tag purge to remove about's entry")
+	}
+
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("DELETE", "/cache/routes/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown route, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/cache/tags/greeting", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+}
+
 func TestAdminRetriesEndpoint(t *testing.T) {
 	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -788,6 +881,463 @@ func TestReadinessWhenDraining(t *testing.T) {
 	}
 }
 
+func TestReadinessWeightDuringWarmup(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{{
+			ID:       "test",
+			Path:     "/test",
+			Backends: []config.BackendConfig{{URL: backend.URL}},
+		}},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+		Warmup: config.WarmupConfig{
+			Enabled:       true,
+			InitialWeight: 25,
+			Duration:      time.Hour,
+			SelfThrottle:  true,
+		},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	handler := server.adminHandler()
+
+	// Readiness stays 200 while warming, with the ramp weight exposed
+	req := httptest.NewRequest("GET", "/ready", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 while warming, got %d", w.Code)
+	}
+	if w.Header().Get("X-Runway-Weight") != "25" {
+		t.Errorf("Expected X-Runway-Weight 25, got %q", w.Header().Get("X-Runway-Weight"))
+	}
+
+	req = httptest.NewRequest("GET", "/ready/weight", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var weightResp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &weightResp)
+	if weightResp["weight"] != float64(25) || weightResp["warming_up"] != true {
+		t.Errorf("Unexpected weight response: %v", weightResp)
+	}
+
+	req = httptest.NewRequest("GET", "/warmup", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var stats map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &stats)
+	if stats["warming_up"] != true || stats["self_throttle"] != true {
+		t.Errorf("Unexpected warmup stats: %v", stats)
+	}
+
+	// Self-throttle rejects part of the traffic on the data path
+	rejected := 0
+	gwHandler := server.Runway().Handler()
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		gwHandler.ServeHTTP(rec, httptest.NewRequest("GET", "/test", nil))
+		if rec.Code == http.StatusServiceUnavailable {
+			rejected++
+		}
+	}
+	if rejected < 70 || rejected > 80 {
+		t.Errorf("Expected roughly 75 of 100 requests rejected, got %d", rejected)
+	}
+
+	// Draining instances report weight 0
+	server.Drain()
+	req = httptest.NewRequest("GET", "/ready/weight", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &weightResp)
+	if weightResp["weight"] != float64(0) || weightResp["ready"] != false {
+		t.Errorf("Expected weight 0 when draining, got %v", weightResp)
+	}
+}
+
+func TestDependencyHealthEndpoint(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	opa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer opa.Close()
+
+	// A webhook endpoint that refuses connections.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := ln.Addr().String()
+	ln.Close()
+
+	critical := true
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{{
+			ID:       "test",
+			Path:     "/test",
+			Backends: []config.BackendConfig{{URL: backend.URL}},
+			OPA:      config.OPAConfig{Enabled: true, URL: opa.URL, PolicyPath: "authz/allow"},
+		}},
+		Webhooks: config.WebhooksConfig{
+			Enabled:   true,
+			Endpoints: []config.WebhookEndpoint{{ID: "ops", URL: "http://" + deadAddr + "/hook", Events: []string{"*"}}},
+		},
+		Admin: config.AdminConfig{
+			Enabled: true,
+			Port:    8082,
+			DependencyHealth: config.DependencyHealthConfig{
+				Enabled:  true,
+				Interval: time.Hour,
+				Timeout:  time.Second,
+				Checks: map[string]config.DependencyCheckConfig{
+					"webhook": {Critical: &critical, AffectsReadiness: true},
+				},
+			},
+		},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	handler := server.adminHandler()
+
+	var resp struct {
+		Status       string                    `json:"status"`
+		Dependencies []health.DependencyResult `json:"dependencies"`
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/admin/health/dependencies", nil))
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		probed := len(resp.Dependencies) == 2
+		for _, d := range resp.Dependencies {
+			probed = probed && d.Status != health.StatusUnknown
+		}
+		if probed {
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("Expected 503 with a failing critical dependency, got %d", w.Code)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Dependencies not probed: %+v", resp.Dependencies)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if resp.Status != "unhealthy" {
+		t.Errorf("Expected status unhealthy, got %q", resp.Status)
+	}
+	for _, d := range resp.Dependencies {
+		switch d.Kind {
+		case "opa":
+			if d.Status != health.StatusHealthy {
+				t.Errorf("Expected OPA healthy, got %+v", d)
+			}
+		case "webhook":
+			if d.Name != "webhook:ops" || d.Status != health.StatusUnhealthy || d.LastError == "" {
+				t.Errorf("Expected webhook:ops unhealthy with error, got %+v", d)
+			}
+		default:
+			t.Errorf("Unexpected dependency %+v", d)
+		}
+	}
+
+	// The failing critical dependency flips readiness
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 from /ready, got %d", w.Code)
+	}
+
+	// Disabling dependency health on reload stops the monitor
+	newCfg := *cfg
+	newCfg.Admin.DependencyHealth.Enabled = false
+	if result := server.Runway().Reload(&newCfg); !result.Success {
+		t.Fatalf("Reload failed: %v", result.Error)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 from /ready after disabling, got %d", w.Code)
+	}
+}
+
+func TestBackendTLSEndpoint(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{{
+			ID:       "secure",
+			Path:     "/secure",
+			Backends: []config.BackendConfig{{URL: backend.URL}},
+		}, {
+			ID:       "plain",
+			Path:     "/plain",
+			Backends: []config.BackendConfig{{URL: "http://127.0.0.1:1"}},
+		}},
+		Admin: config.AdminConfig{
+			Enabled: true,
+			Port:    8082,
+			BackendTLSScan: config.BackendTLSScanConfig{
+				Enabled:  true,
+				Interval: time.Hour,
+				Timeout:  time.Second,
+				Rate:     100,
+			},
+		},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	handler := server.adminHandler()
+
+	var resp struct {
+		Enabled  bool             `json:"enabled"`
+		Backends []tlsscan.Result `json:"backends"`
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/admin/backends/tls", nil))
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if len(resp.Backends) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Backends not scanned: %s", w.Body.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(resp.Backends) != 1 {
+		t.Fatalf("Expected only the https backend, got %+v", resp.Backends)
+	}
+	r := resp.Backends[0]
+	if r.Status != tlsscan.StatusOK || r.Address != backend.Listener.Addr().String() || len(r.Routes) != 1 || r.Routes[0] != "secure" {
+		t.Errorf("Unexpected scan result %+v", r)
+	}
+	if r.HostnameVerified {
+		t.Error("The test CA is not trusted by the transport; verification should fail")
+	}
+	if len(r.Chain) == 0 || r.NotAfter.IsZero() {
+		t.Errorf("Expected certificate details, got %+v", r)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/admin/backends/tls?expiring_within=1h", nil))
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Backends) != 0 {
+		t.Errorf("Expected no backends expiring within 1h, got %+v", resp.Backends)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/admin/backends/tls?expiring_within=soon", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid duration, got %d", w.Code)
+	}
+}
+
+func TestRenderedConfigEndpoint(t *testing.T) {
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry:        config.RegistryConfig{Type: "memory"},
+		SecurityHeaders: config.SecurityHeadersConfig{Enabled: true, XFrameOptions: "DENY"},
+		Routes: []config.RouteConfig{{
+			ID:       "api",
+			Path:     "/api",
+			Backends: []config.BackendConfig{{URL: "http://127.0.0.1:1"}},
+		}},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/config/rendered?provenance=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-yaml" {
+		t.Errorf("Expected YAML content type, got %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), "# from: global.security_headers") {
+		t.Errorf("Expected the route to inherit global security headers:\n%s", w.Body.String())
+	}
+}
+
+func TestReputationBlocksAndClears(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry:     config.RegistryConfig{Type: "memory"},
+		BotDetection: config.BotDetectionConfig{Enabled: true, Deny: []string{"evilbot"}},
+		Reputation: config.ReputationConfig{
+			Enabled: true,
+			Weights: map[string]float64{"bot": 100},
+		},
+		Routes: []config.RouteConfig{{
+			ID:       "api",
+			Path:     "/api",
+			Backends: []config.BackendConfig{{URL: backend.URL}},
+		}},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+	handler := server.Runway().Handler()
+	admin := server.adminHandler()
+
+	request := func(ua string) int {
+		r := httptest.NewRequest("GET", "/api", nil)
+		r.RemoteAddr = "203.0.113.9:4321"
+		r.Header.Set("User-Agent", ua)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := request("evilbot/1.0"); code != http.StatusForbidden {
+		t.Fatalf("Expected bot to be rejected, got %d", code)
+	}
+	if code := request("curl/8.0"); code != http.StatusForbidden {
+		t.Fatalf("Expected the IP to be blocked after the bot match, got %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest("GET", "/ip-blocklist", nil))
+	var stats map[string]ipblocklist.BlocklistStatus
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	global := stats["_global"]
+	if global.TemporaryEntries != 1 || global.Temporary[0].IP != "203.0.113.9" || global.Temporary[0].Signals[0] != "bot" {
+		t.Errorf("Expected the reputation block in blocklist stats, got %+v", global)
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest("GET", "/admin/reputation?ip=203.0.113.9", nil))
+	var rec struct {
+		Strikes      int        `json:"strikes"`
+		BlockedUntil *time.Time `json:"blocked_until"`
+	}
+	json.NewDecoder(w.Body).Decode(&rec)
+	if rec.Strikes != 1 || rec.BlockedUntil == nil {
+		t.Errorf("Expected one strike and an active block, got %+v", rec)
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest("DELETE", "/admin/reputation?ip=203.0.113.9", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 clearing the IP, got %d: %s", w.Code, w.Body.String())
+	}
+	if code := request("curl/8.0"); code != http.StatusOK {
+		t.Errorf("Expected the cleared IP to be allowed, got %d", code)
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest("GET", "/admin/reputation?ip=bogus", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid IP, got %d", w.Code)
+	}
+}
+
+func TestClientAbortsInRouteStats(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{{
+			ID:       "api",
+			Path:     "/api",
+			Backends: []config.BackendConfig{{URL: backend.URL}},
+		}},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	// The client is gone before the request reaches the backend
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	server.Runway().Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api", nil).WithContext(ctx))
+
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/routes", nil))
+	var routes []struct {
+		ID           string           `json:"id"`
+		ClientAborts map[string]int64 `json:"client_aborts"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&routes); err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || routes[0].ClientAborts["before_backend"] != 1 || routes[0].ClientAborts["total"] != 1 {
+		t.Errorf("Expected one abort before backend in route stats, got %+v", routes)
+	}
+}
+
 func TestShutdownWithConfiguredTimeout(t *testing.T) {
 	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -1041,3 +1591,250 @@ func TestUIDoesNotConflictWithAPIRoutes(t *testing.T) {
 		}
 	}
 }
+
+func TestFeatureOverridesAndFlags(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{{
+			ID:          "test",
+			Path:        "/test",
+			Backends:    []config.BackendConfig{{URL: backend.URL}},
+			Maintenance: config.MaintenanceConfig{Enabled: true},
+		}},
+		Admin:   config.AdminConfig{Enabled: true, Port: 8082},
+		Logging: config.LoggingConfig{Level: "info"},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	admin := server.adminHandler()
+	gwHandler := server.Runway().Handler()
+	get := func() int {
+		rec := httptest.NewRecorder()
+		gwHandler.ServeHTTP(rec, httptest.NewRequest("GET", "/test", nil))
+		return rec.Code
+	}
+	post := func(path string) int {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest("POST", path, nil))
+		return rec.Code
+	}
+
+	if get() != http.StatusServiceUnavailable {
+		t.Fatal("expected maintenance to block requests")
+	}
+
+	// Switching the maintenance middleware off lets traffic through
+	if code := post("/features/test/maintenance/disable"); code != http.StatusOK {
+		t.Fatalf("expected 200 disabling feature, got %d", code)
+	}
+	if get() != http.StatusOK {
+		t.Error("expected request to skip the disabled middleware")
+	}
+	if code := post("/features/test/waf/disable"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a feature not active on the route, got %d", code)
+	}
+	post("/features/test/maintenance/reset")
+	if get() != http.StatusServiceUnavailable {
+		t.Error("expected maintenance restored after reset")
+	}
+
+	// Flags go through the same override path and revert on removal
+	w := featureflags.New(config.FeatureFlagsConfig{}, nopSource{}, server.Runway())
+	w.Sync(map[string]string{"routes/test/maintenance": "false", "log_level": "debug"})
+	if get() != http.StatusOK || logging.Level() != "debug" {
+		t.Errorf("expected flags applied, log level %s", logging.Level())
+	}
+	w.Sync(map[string]string{})
+	if get() != http.StatusServiceUnavailable || logging.Level() != "info" {
+		t.Errorf("expected flags reverted, log level %s", logging.Level())
+	}
+
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest("GET", "/admin/feature-flags", nil))
+	var resp map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp["enabled"] != false || resp["log_level"] != "info" {
+		t.Errorf("unexpected feature flags response: %v", resp)
+	}
+}
+
+type nopSource struct{}
+
+func (nopSource) Watch(ctx context.Context, fn func(map[string]string)) error {
+	<-ctx.Done()
+	return nil
+}
+func (nopSource) Name() string { return "test" }
+func (nopSource) Close() error { return nil }
+
+func TestContentDedupWithRouteRetries(t *testing.T) {
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hooks" {
+			return // health checks
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"evt-1"}`))
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{{
+			ID:       "hooks",
+			Path:     "/hooks",
+			Backends: []config.BackendConfig{{URL: backend.URL}},
+			RetryPolicy: config.RetryConfig{
+				MaxRetries:        2,
+				InitialBackoff:    time.Millisecond,
+				RetryableStatuses: []int{http.StatusServiceUnavailable},
+				RetryableMethods:  []string{"POST"},
+			},
+			ContentDedup: config.ContentDedupConfig{Enabled: true},
+		}},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/hooks?event=paid", nil)
+		rec := httptest.NewRecorder()
+		server.Runway().Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The gateway retry after a 503 reaches the backend instead of being
+	// treated as a duplicate of the original attempt.
+	rec := post()
+	if rec.Code != http.StatusCreated || calls.Load() != 2 {
+		t.Fatalf("expected retried request to succeed, got %d after %d backend calls", rec.Code, calls.Load())
+	}
+
+	// A client double-submit is replayed without reaching the backend
+	rec = post()
+	if rec.Code != http.StatusCreated || rec.Header().Get("X-Dedup-Replayed") != "true" || calls.Load() != 2 {
+		t.Errorf("expected replayed duplicate, got %d (replayed=%q) after %d backend calls",
+			rec.Code, rec.Header().Get("X-Dedup-Replayed"), calls.Load())
+	}
+
+	req := httptest.NewRequest("GET", "/content-dedup", nil)
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, req)
+	var stats map[string]map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &stats)
+	if s := stats["hooks"]; s["hits"] != float64(1) || s["misses"] != float64(1) || s["replays"] != float64(1) {
+		t.Errorf("unexpected content dedup stats: %v", stats)
+	}
+}
+
+func TestKeySelfServiceEndpoints(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	const secret = "test-secret-key-for-testing"
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Authentication: config.AuthenticationConfig{
+			APIKey: config.APIKeyConfig{
+				Enabled: true,
+				Header:  "X-API-Key",
+				Management: config.APIKeyManagementConfig{
+					Enabled:     true,
+					SelfService: config.APIKeySelfServiceConfig{Enabled: true, PathPrefix: "/me/keys"},
+				},
+			},
+			JWT: config.JWTConfig{Enabled: true, Secret: secret, Algorithm: "HS256"},
+		},
+		Routes: []config.RouteConfig{{
+			ID:       "api",
+			Path:     "/api",
+			Backends: []config.BackendConfig{{URL: backend.URL}},
+			Auth:     config.RouteAuthConfig{Required: true, Methods: []string{"api_key"}},
+		}},
+		Admin: config.AdminConfig{Enabled: true, Port: 0},
+	}
+
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := server.Runway().Handler()
+	req := httptest.NewRequest(http.MethodPost, "/me/keys", strings.NewReader(`{"name":"cli"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	// The new key authenticates on routes.
+	req = httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("X-API-Key", created.Key)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("route with self-service key: status %d %s", rec.Code, rec.Body.String())
+	}
+
+	// API keys cannot be used to manage keys.
+	req = httptest.NewRequest(http.MethodGet, "/me/keys", nil)
+	req.Header.Set("X-API-Key", created.Key)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("list with API key: status %d, want 401", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api-keys/stats", nil))
+	var stats map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &stats)
+	ss, _ := stats["self_service"].(map[string]interface{})
+	if ss == nil || ss["created"] != float64(1) {
+		t.Errorf("self_service stats = %v", stats["self_service"])
+	}
+}
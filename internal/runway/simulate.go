@@ -0,0 +1,118 @@
+package runway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/router"
+	"github.com/wudi/runway/internal/simulate"
+	"github.com/wudi/runway/variables"
+)
+
+// ErrRouteNotFound is returned when simulating a request on an unknown route.
+var ErrRouteNotFound = errors.New("route not found")
+
+// maxSimulatedResponse caps the blocked response body in a simulation result.
+const maxSimulatedResponse = 64 << 10
+
+// routeStage is an active middleware slot of a route, kept so requests can
+// be simulated through the route's compiled chain.
+type routeStage struct {
+	name string
+	mw   middleware.Middleware
+}
+
+// simulatedStages are the slots whose middlewares support simulation (see
+// package simulate). Other active slots are reported as skipped.
+var simulatedStages = map[string]bool{
+	"var_context":       true,
+	"rate_limit":        true,
+	"auth":              true,
+	"group_rate_limit":  true,
+	"request_rules":     true,
+	"waf":               true,
+	"rate_limit_cost":   true,
+	"request_transform": true,
+}
+
+// SimulateRequest runs req through routeID's middleware chain with the
+// backend replaced by a recorder, and reports each stage's decision.
+// Stages without simulation support are skipped, so nothing outside the
+// request is changed.
+func (g *Runway) SimulateRequest(routeID string, req simulate.Request) (*simulate.Result, error) {
+	v, ok := g.routeStages.Load(routeID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRouteNotFound, routeID)
+	}
+	stages := v.([]routeStage)
+
+	r, err := req.HTTPRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	varCtx := variables.NewContext(r)
+	defer variables.ReleaseContext(varCtx)
+	if id := req.Identity; id != nil {
+		authType := id.AuthType
+		if authType == "" {
+			authType = "simulated"
+		}
+		varCtx.Identity = &variables.Identity{ClientID: id.ClientID, AuthType: authType, Claims: id.Claims}
+	}
+
+	result := &simulate.Result{Route: routeID}
+	if match := g.router.Match(r); match != nil {
+		result.MatchedRoute = match.Route.ID
+		if match.Route.ID == routeID {
+			varCtx.PathParams = maps.Clone(match.PathParams)
+		}
+		router.ReleaseMatch(match)
+	}
+	if ex, ok := g.router.ExplainMatch(routeID, r); ok {
+		result.Match = &ex
+	}
+	if result.MatchedRoute != routeID {
+		result.Notes = append(result.Notes, fmt.Sprintf("request does not match route %q; path parameters are empty", routeID))
+	}
+
+	trace := simulate.NewTrace()
+	r = r.WithContext(simulate.WithTrace(context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx), trace))
+	varCtx.Request = r
+
+	var h http.Handler = trace.Recorder()
+	for i := len(stages) - 1; i >= 0; i-- {
+		s := stages[i]
+		if simulatedStages[s.name] {
+			h = trace.Stage(s.name, s.mw)(h)
+		} else {
+			h = trace.Skip(s.name, "no simulation support")(h)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+
+	result.Stages = trace.Stages()
+	if up := trace.Upstream(); up != nil {
+		result.Outcome = "forwarded"
+		result.Upstream = up
+		return result, nil
+	}
+	result.Outcome = "blocked"
+	for _, st := range result.Stages {
+		if st.Decision == simulate.Blocked {
+			result.BlockedBy = st.Name
+		}
+	}
+	body := rec.Body.String()
+	if len(body) > maxSimulatedResponse {
+		body = body[:maxSimulatedResponse]
+	}
+	result.Response = &simulate.Response{Status: rec.Code, Headers: rec.Header(), Body: body}
+	return result, nil
+}
@@ -0,0 +1,176 @@
+package runway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/simulate"
+)
+
+func TestSimulateRequest(t *testing.T) {
+	var backendHits atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/orders/42" {
+			backendHits.Add(1)
+		}
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Authentication: config.AuthenticationConfig{
+			APIKey: config.APIKeyConfig{
+				Enabled: true,
+				Header:  "X-API-Key",
+				Keys:    []config.APIKeyEntry{{Key: "reader-key", ClientID: "viewer", Roles: []string{"reader"}}},
+			},
+		},
+		Routes: []config.RouteConfig{{
+			ID:       "orders",
+			Path:     "/orders/:id",
+			Backends: []config.BackendConfig{{URL: backend.URL}},
+			Auth: config.RouteAuthConfig{
+				Required: true,
+				Methods:  []string{"api_key"},
+				Requirements: config.AuthRequirementsConfig{
+					AuthRequirement: config.AuthRequirement{RolesAny: []string{"admin"}},
+				},
+			},
+			RateLimit: config.RateLimitConfig{Enabled: true, Rate: 1, Period: 60e9, PerIP: true},
+			Rules: config.RulesConfig{Request: []config.RuleConfig{
+				{ID: "tag-beta", Expression: `http.request.headers["X-Beta"] == "1"`, Action: "set_headers",
+					Headers: config.HeaderTransform{Set: map[string]string{"X-Cohort": "beta"}}},
+				{ID: "audit", Expression: `true`, Action: "log"},
+			}},
+			Transform: config.TransformConfig{Request: config.RequestTransform{
+				Headers: config.HeaderTransform{Set: map[string]string{"X-Order": "$route_param_id"}},
+			}},
+			CircuitBreaker: config.CircuitBreakerConfig{Enabled: true},
+		}},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	simulateReq := func(route, body string) (*httptest.ResponseRecorder, simulate.Result) {
+		w := postUpstreamAction(server, "/admin/routes/"+route+"/simulate", body)
+		var res simulate.Result
+		json.NewDecoder(w.Body).Decode(&res)
+		return w, res
+	}
+	stage := func(res simulate.Result, name string) simulate.Stage {
+		for _, st := range res.Stages {
+			if st.Name == name {
+				return st
+			}
+		}
+		t.Fatalf("stage %q not in %+v", name, res.Stages)
+		return simulate.Stage{}
+	}
+
+	// The reader key authenticates but lacks the admin role.
+	_, res := simulateReq("orders", `{"method":"GET","path":"/orders/42","headers":{"X-API-Key":"reader-key"}}`)
+	if res.Outcome != "blocked" || res.BlockedBy != "auth" || res.Response == nil || res.Response.Status != http.StatusForbidden {
+		t.Fatalf("expected auth to block with 403, got %+v", res)
+	}
+
+	// An assumed identity replaces authentication; every request passes the
+	// rate limit of 1 because simulations do not consume it.
+	body := `{"method":"GET","path":"/orders/42","headers":{"X-Beta":"1"},"identity":{"client_id":"ops","claims":{"roles":["admin"]}}}`
+	for i := 0; i < 3; i++ {
+		var w *httptest.ResponseRecorder
+		w, res = simulateReq("orders", body)
+		if w.Code != http.StatusOK || res.Outcome != "forwarded" {
+			t.Fatalf("simulation %d: expected forwarded, got %d %+v", i, w.Code, res)
+		}
+		if st := stage(res, "rate_limit"); st.Decision != simulate.Passed || len(st.Notes) == 0 {
+			t.Errorf("simulation %d: unexpected rate_limit stage %+v", i, st)
+		}
+	}
+	if res.MatchedRoute != "orders" {
+		t.Errorf("expected the request to match orders, got %q", res.MatchedRoute)
+	}
+	if st := stage(res, "request_rules"); st.Decision != simulate.Modified || len(st.Matched) != 2 ||
+		st.Request.HeadersAfter.Get("X-Cohort") != "beta" {
+		t.Errorf("unexpected request_rules stage %+v", st)
+	}
+	if st := stage(res, "circuit_breaker"); st.Decision != simulate.Skipped {
+		t.Errorf("expected circuit_breaker to be skipped, got %+v", st)
+	}
+	if res.Upstream == nil || res.Upstream.Headers.Get("X-Order") != "42" || res.Upstream.Headers.Get("X-Cohort") != "beta" {
+		t.Errorf("unexpected upstream request %+v", res.Upstream)
+	}
+	if backendHits.Load() != 0 {
+		t.Errorf("expected no backend calls, got %d", backendHits.Load())
+	}
+
+	// The rate limit still has its token for real traffic.
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/orders/42", nil)
+	req.Header.Set("X-API-Key", "reader-key")
+	server.Runway().Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected the live request to reach auth, got %d", w.Code)
+	}
+
+	if w, _ := simulateReq("missing", `{"path":"/"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown route, got %d", w.Code)
+	}
+	if w, _ := simulateReq("orders", `not json`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid body, got %d", w.Code)
+	}
+}
+
+func TestSimulateRequestExplainsMatch(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	present := true
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Routes: []config.RouteConfig{{
+			ID:   "beta",
+			Path: "/app",
+			Match: config.MatchConfig{
+				Headers:    []config.HeaderMatchConfig{{ID: "header_beta", Name: "X-Beta", Present: &present}},
+				Cookies:    []config.CookieMatchConfig{{ID: "cookie_beta", Name: "beta", Value: "1"}},
+				Expression: "header_beta || cookie_beta",
+			},
+			Backends: []config.BackendConfig{{URL: backend.URL}},
+		}},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082},
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+
+	w := postUpstreamAction(server, "/admin/routes/beta/simulate", `{"method":"GET","path":"/app","headers":{"Cookie":"beta=1"}}`)
+	var res simulate.Result
+	json.NewDecoder(w.Body).Decode(&res)
+	if res.MatchedRoute != "beta" || res.Match == nil || !res.Match.Matched {
+		t.Fatalf("expected the cookie to select the route, got %+v", res)
+	}
+	if res.Match.Expression != "header_beta || cookie_beta" || len(res.Match.Conditions) != 2 {
+		t.Fatalf("unexpected match explanation %+v", res.Match)
+	}
+	for _, c := range res.Match.Conditions {
+		if want := c.ID == "cookie_beta"; c.Matched != want {
+			t.Errorf("condition %s: matched %v, want %v", c.ID, c.Matched, want)
+		}
+	}
+}
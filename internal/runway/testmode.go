@@ -0,0 +1,47 @@
+package runway
+
+import (
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/clock"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/randutil"
+	"go.uber.org/zap"
+)
+
+// defaultTestModeSeed seeds sampling when test_mode sets no seed.
+const defaultTestModeSeed = 1
+
+// initTestMode freezes the process clock and seeds sampling when test_mode
+// is enabled, returning the clock the admin API advances. It must run
+// before any component is created: components take the process clock when
+// they are built.
+func initTestMode(tm config.TestModeConfig) *clock.Fake {
+	if !tm.Enabled {
+		return nil
+	}
+	start := time.Now()
+	if tm.StartTime != "" {
+		if t, err := time.Parse(time.RFC3339, tm.StartTime); err == nil {
+			start = t
+		}
+	}
+	seed := tm.Seed
+	if seed == 0 {
+		seed = defaultTestModeSeed
+	}
+	fake := clock.NewFake(start)
+	clock.SetDefault(fake)
+	randutil.Seed(seed)
+	logging.Warn("Test mode enabled: the clock is frozen and sampling is seeded; do not use in production",
+		zap.Time("clock", start),
+		zap.Int64("seed", seed),
+	)
+	return fake
+}
+
+// TestClock returns the frozen clock, or nil unless test_mode is enabled.
+func (g *Runway) TestClock() *clock.Fake {
+	return g.testClock
+}
@@ -0,0 +1,194 @@
+package runway
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/health"
+	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/proxy"
+	dnsregistry "github.com/wudi/runway/internal/registry/dns"
+	"go.uber.org/zap"
+)
+
+// upstreamDNS feeds the routes of upstreams with dns_discovery from their
+// SRV records. Each route state has its own set; its failovers start once
+// the state's routes exist and stop when the state is replaced.
+type upstreamDNS struct {
+	mu        sync.Mutex
+	failovers map[string]*upstreamFailover
+	started   bool
+}
+
+// upstreamFailover is an upstream's failover and the route proxies it
+// updates.
+type upstreamFailover struct {
+	*dnsregistry.Failover
+	name     string
+	mu       sync.Mutex
+	routeIDs []string
+	proxies  []*proxy.RouteProxy
+}
+
+func newUpstreamDNS() *upstreamDNS {
+	return &upstreamDNS{failovers: make(map[string]*upstreamFailover)}
+}
+
+// upstreamFailover returns the failover of an upstream with dns_discovery, creating
+// it with an initial lookup on first use, or nil for other upstreams.
+func (g *Runway) upstreamFailover(ud *upstreamDNS, cfg *config.Config, name string) *upstreamFailover {
+	us, ok := cfg.Upstreams[name]
+	if !ok || !us.DNSDiscovery.Enabled {
+		return nil
+	}
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
+	if uf, ok := ud.failovers[name]; ok {
+		return uf
+	}
+
+	uf := &upstreamFailover{name: name}
+	uf.Failover = dnsregistry.NewFailover(dnsregistry.FailoverConfig{
+		UpstreamDNSDiscoveryConfig: us.DNSDiscovery,
+		Unhealthy: func(url string) bool {
+			return g.healthChecker.GetStatus(url) == health.StatusUnhealthy
+		},
+		// Every target is health checked, standby groups included, so
+		// the failover knows when a preferred group recovers.
+		OnTargets: func(targets []dnsregistry.Target) {
+			for _, t := range targets {
+				g.healthChecker.UpdateBackend(upstreamHealthCheck(t.URL, cfg.HealthCheck, us.HealthCheck, nil))
+			}
+		},
+		OnChange: func(active []dnsregistry.Target) {
+			backends := g.dnsBackends(name, active)
+			uf.mu.Lock()
+			defer uf.mu.Unlock()
+			for _, rp := range uf.proxies {
+				rp.UpdateBackends(backends)
+			}
+			g.reapplyBackendDrains(false)
+			if len(uf.proxies) > 0 {
+				logging.Info("Updated backends for upstream from DNS",
+					zap.String("upstream", name),
+					zap.Int("priority", int(active[0].Priority)),
+					zap.Int("backends", len(backends)),
+				)
+			}
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := uf.Refresh(ctx); err != nil {
+		logging.Warn("Failed to resolve upstream SRV records",
+			zap.String("upstream", name),
+			zap.Error(err),
+		)
+	}
+	cancel()
+	ud.failovers[name] = uf
+	if ud.started {
+		uf.Start()
+	}
+	return uf
+}
+
+// dnsBackends converts the active group of an upstream to balancer backends,
+// dropping targets the egress policy refuses.
+func (g *Runway) dnsBackends(upstream string, active []dnsregistry.Target) []*loadbalancer.Backend {
+	backends := make([]*loadbalancer.Backend, 0, len(active))
+	for _, t := range active {
+		be := &loadbalancer.Backend{
+			URL:     t.URL,
+			Weight:  t.Weight,
+			Healthy: g.healthChecker.GetStatus(t.URL) != health.StatusUnhealthy,
+		}
+		be.InitParsedURL()
+		backends = append(backends, be)
+	}
+	return g.egressBackends(egressSourceDNS, "upstream "+upstream, backends)
+}
+
+// backends returns the active group's backends for a route. Later changes
+// reach the route's proxy once addProxy registers it; polling only starts
+// after every route of the state is set up.
+func (uf *upstreamFailover) backends(g *Runway, routeID string) []*loadbalancer.Backend {
+	uf.mu.Lock()
+	uf.routeIDs = append(uf.routeIDs, routeID)
+	uf.mu.Unlock()
+	return g.dnsBackends(uf.name, uf.Active())
+}
+
+func (uf *upstreamFailover) addProxy(rp *proxy.RouteProxy) {
+	uf.mu.Lock()
+	uf.proxies = append(uf.proxies, rp)
+	uf.mu.Unlock()
+}
+
+// start starts polling for every failover.
+func (ud *upstreamDNS) start() {
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
+	ud.started = true
+	for _, uf := range ud.failovers {
+		uf.Start()
+	}
+}
+
+// stop stops polling.
+func (ud *upstreamDNS) stop() {
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
+	if !ud.started {
+		return
+	}
+	for _, uf := range ud.failovers {
+		uf.Stop()
+	}
+}
+
+// targetURLs returns every resolved target, for health checker
+// reconciliation.
+func (ud *upstreamDNS) targetURLs() []string {
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
+	var urls []string
+	for _, uf := range ud.failovers {
+		for _, t := range uf.Targets() {
+			urls = append(urls, t.URL)
+		}
+	}
+	return urls
+}
+
+// RouteIDs returns the routes fed by DNS discovery.
+func (ud *upstreamDNS) RouteIDs() []string {
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
+	var ids []string
+	for _, uf := range ud.failovers {
+		uf.mu.Lock()
+		ids = append(ids, uf.routeIDs...)
+		uf.mu.Unlock()
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Stats returns per-upstream failover stats.
+func (ud *upstreamDNS) Stats() map[string]any {
+	ud.mu.Lock()
+	defer ud.mu.Unlock()
+	result := make(map[string]any, len(ud.failovers))
+	for name, uf := range ud.failovers {
+		s := uf.Stats()
+		uf.mu.Lock()
+		s["routes"] = append([]string(nil), uf.routeIDs...)
+		uf.mu.Unlock()
+		result[name] = s
+	}
+	return result
+}
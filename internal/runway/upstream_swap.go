@@ -0,0 +1,388 @@
+package runway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/health"
+	"github.com/wudi/runway/internal/loadbalancer"
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/webhook"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSwapRollbackWindow = 15 * time.Minute
+	defaultSwapVerifyPath     = "/health"
+	defaultSwapVerifyCount    = 3
+	defaultSwapVerifyTimeout  = 5 * time.Second
+)
+
+var (
+	// ErrUpstreamNotFound is returned when swapping an unknown upstream.
+	ErrUpstreamNotFound = errors.New("upstream not found")
+	// ErrUpstreamVerification is returned when a candidate backend fails its
+	// verification requests; the upstream is left unchanged.
+	ErrUpstreamVerification = errors.New("candidate backend verification failed")
+	// ErrNoUpstreamRollback is returned when an upstream has no previous
+	// backend set, or its rollback window has passed.
+	ErrNoUpstreamRollback = errors.New("no rollback available for upstream")
+)
+
+// upstreamSwaps serializes upstream backend swaps and holds the rollback
+// slot of each swapped upstream. Slots are discarded on config reload,
+// which restores the upstreams from the configuration.
+type upstreamSwaps struct {
+	mu    sync.Mutex
+	slots map[string]*upstreamRollback
+}
+
+// upstreamRollback is the backend set an upstream served before its last swap.
+type upstreamRollback struct {
+	backends []config.BackendConfig
+	expires  time.Time
+}
+
+func (s *upstreamSwaps) reset() {
+	s.mu.Lock()
+	s.slots = nil
+	s.mu.Unlock()
+}
+
+// UpstreamSwapResult describes a completed (or rejected) upstream swap or
+// rollback.
+type UpstreamSwapResult struct {
+	Upstream      string                `json:"upstream"`
+	Routes        []string              `json:"routes"`
+	Backends      []string              `json:"backends"`
+	Previous      []string              `json:"previous,omitempty"`
+	RollbackUntil *time.Time            `json:"rollback_until,omitempty"`
+	Verification  []BackendVerification `json:"verification,omitempty"`
+}
+
+// BackendVerification is the outcome of one candidate backend's
+// verification requests.
+type BackendVerification struct {
+	URL    string `json:"url"`
+	Sent   int    `json:"sent"`
+	Passed int    `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// SwapUpstream replaces the backend set of upstream name on every route that
+// references it. Each candidate backend is first sent verify.Count
+// verification requests through the upstream's transport; unset verify
+// fields take the admin.upstream_swap defaults. Only when every request
+// passes are the balancers updated, all under the gateway lock, and the
+// previous set kept for RollbackUpstream until the rollback window ends.
+func (g *Runway) SwapUpstream(ctx context.Context, name string, backends []config.BackendConfig, verify config.UpstreamVerifyConfig) (*UpstreamSwapResult, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("at least one backend is required")
+	}
+	for _, b := range backends {
+		if u, err := url.Parse(b.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid backend url %q", b.URL)
+		}
+		if b.Weight < 0 {
+			return nil, fmt.Errorf("backend %s: weight must be >= 0", b.URL)
+		}
+	}
+
+	g.mu.RLock()
+	cfg := g.config
+	g.mu.RUnlock()
+	if err := checkSwappableUpstream(cfg, name); err != nil {
+		return nil, err
+	}
+	settings := cfg.Admin.UpstreamSwap
+	verify = swapVerifyDefaults(verify, settings.Verify)
+
+	g.upstreamSwaps.mu.Lock()
+	defer g.upstreamSwaps.mu.Unlock()
+
+	result := &UpstreamSwapResult{Upstream: name}
+	client := &http.Client{
+		Transport: g.GetTransportPool().Get(name),
+		Timeout:   verify.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	failed := false
+	for _, b := range backends {
+		v := verifyBackend(ctx, client, b.URL, verify)
+		result.Verification = append(result.Verification, v)
+		failed = failed || v.Passed < v.Sent
+	}
+	if failed {
+		logging.Warn("Upstream swap rejected by verification", zap.String("upstream", name))
+		g.emitUpstreamEvent(webhook.UpstreamSwapFailed, name, map[string]interface{}{
+			"candidates":   backendURLs(backends),
+			"verification": result.Verification,
+		})
+		return result, ErrUpstreamVerification
+	}
+
+	routes, previous, err := g.applyUpstreamBackends(name, backends)
+	if err != nil {
+		return nil, err
+	}
+	result.Routes = routes
+	result.Backends = backendURLs(backends)
+	result.Previous = backendURLs(previous)
+
+	window := settings.RollbackWindow
+	if window == 0 {
+		window = defaultSwapRollbackWindow
+	}
+	until := time.Now().Add(window)
+	if g.upstreamSwaps.slots == nil {
+		g.upstreamSwaps.slots = make(map[string]*upstreamRollback)
+	}
+	g.upstreamSwaps.slots[name] = &upstreamRollback{backends: previous, expires: until}
+	result.RollbackUntil = &until
+
+	logging.Info("Upstream backends swapped",
+		zap.String("upstream", name),
+		zap.Strings("routes", routes),
+		zap.Strings("backends", result.Backends),
+	)
+	g.emitUpstreamEvent(webhook.UpstreamSwapped, name, map[string]interface{}{
+		"routes":         routes,
+		"backends":       result.Backends,
+		"previous":       result.Previous,
+		"rollback_until": until,
+	})
+	return result, nil
+}
+
+// RollbackUpstream restores the backend set upstream name served before its
+// last swap, without verification. It fails with ErrNoUpstreamRollback once
+// the rollback window has passed.
+func (g *Runway) RollbackUpstream(name string) (*UpstreamSwapResult, error) {
+	g.upstreamSwaps.mu.Lock()
+	defer g.upstreamSwaps.mu.Unlock()
+
+	slot, ok := g.upstreamSwaps.slots[name]
+	if !ok || time.Now().After(slot.expires) {
+		delete(g.upstreamSwaps.slots, name)
+		return nil, ErrNoUpstreamRollback
+	}
+	routes, swapped, err := g.applyUpstreamBackends(name, slot.backends)
+	if err != nil {
+		return nil, err
+	}
+	delete(g.upstreamSwaps.slots, name)
+
+	result := &UpstreamSwapResult{
+		Upstream: name,
+		Routes:   routes,
+		Backends: backendURLs(slot.backends),
+		Previous: backendURLs(swapped),
+	}
+	logging.Info("Upstream swap rolled back",
+		zap.String("upstream", name),
+		zap.Strings("routes", routes),
+		zap.Strings("backends", result.Backends),
+	)
+	g.emitUpstreamEvent(webhook.UpstreamRolledBack, name, map[string]interface{}{
+		"routes":   routes,
+		"backends": result.Backends,
+		"previous": result.Previous,
+	})
+	return result, nil
+}
+
+// applyUpstreamBackends installs backends as upstream name's backend set. The
+// new backends are registered with the health checker and built for every
+// referencing balancer first; the balancers are then switched in one pass
+// under the gateway lock, so no balancer ever holds a mix of old and new
+// backends and no reload interleaves with the swap. Health checks of
+// backends no longer used by any route are removed. It returns the updated
+// route IDs and the previous backend set.
+func (g *Runway) applyUpstreamBackends(name string, backends []config.BackendConfig) ([]string, []config.BackendConfig, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cfg := g.config
+	if err := checkSwappableUpstream(cfg, name); err != nil {
+		return nil, nil, err
+	}
+	us := cfg.Upstreams[name]
+	previous := us.Backends
+
+	build := func() []*loadbalancer.Backend {
+		bs := buildBackends(backends, g.healthChecker.UpdateBackend, cfg.HealthCheck, us.HealthCheck)
+		for _, be := range bs {
+			be.Healthy = g.healthChecker.GetStatus(be.URL) != health.StatusUnhealthy
+		}
+		return bs
+	}
+
+	var updates []func()
+	var routes []string
+	proxies := *g.routeProxies.Load()
+	for _, rc := range cfg.Routes {
+		rp, ok := proxies[rc.ID]
+		if !ok {
+			continue
+		}
+		n := len(updates)
+		bal := rp.GetBalancer()
+		switch {
+		case rc.Versioning.Enabled:
+			if vb, ok := bal.(*loadbalancer.VersionedBalancer); ok {
+				for ver, vcfg := range rc.Versioning.Versions {
+					if vcfg.Upstream == name {
+						bs := build()
+						updates = append(updates, func() { vb.UpdateVersionBackends(ver, bs) })
+					}
+				}
+			}
+		case len(rc.TrafficSplit) > 0:
+			if wb, ok := bal.(*loadbalancer.WeightedBalancer); ok {
+				for _, split := range rc.TrafficSplit {
+					if group := wb.GetGroupByName(split.Name); group != nil && split.Upstream == name {
+						bs := build()
+						updates = append(updates, func() { group.Balancer.UpdateBackends(bs) })
+					}
+				}
+			}
+		case rc.Upstream == name:
+			bs := build()
+			updates = append(updates, func() { rp.UpdateBackends(bs) })
+		}
+		if len(updates) > n {
+			routes = append(routes, rc.ID)
+		}
+	}
+
+	for _, update := range updates {
+		update()
+	}
+	g.reapplyBackendDrains(false)
+
+	// Keep the running config in step so /upstreams and reload diffs show
+	// the live set. Readers holding the old config are unaffected.
+	next := *cfg
+	next.Upstreams = maps.Clone(cfg.Upstreams)
+	us.Backends = backends
+	next.Upstreams[name] = us
+	g.config = &next
+
+	inUse := make(map[string]bool)
+	for _, rp := range proxies {
+		for _, be := range rp.GetBalancer().GetBackends() {
+			inUse[be.URL] = true
+		}
+	}
+	for _, b := range previous {
+		if !inUse[b.URL] {
+			g.healthChecker.RemoveBackend(b.URL)
+		}
+	}
+
+	return routes, previous, nil
+}
+
+// checkSwappableUpstream reports whether upstream name exists with a static
+// backend list.
+func checkSwappableUpstream(cfg *config.Config, name string) error {
+	us, ok := cfg.Upstreams[name]
+	if !ok {
+		return ErrUpstreamNotFound
+	}
+	if us.Service.Name != "" {
+		return fmt.Errorf("upstream %s uses service discovery; its backends come from the registry", name)
+	}
+	return nil
+}
+
+// swapVerifyDefaults fills unset verification settings from the configured
+// defaults, then from the built-in defaults.
+func swapVerifyDefaults(v, defaults config.UpstreamVerifyConfig) config.UpstreamVerifyConfig {
+	if v.Method == "" {
+		v.Method = defaults.Method
+	}
+	if v.Method == "" {
+		v.Method = http.MethodGet
+	}
+	if v.Path == "" {
+		v.Path = defaults.Path
+	}
+	if v.Path == "" {
+		v.Path = defaultSwapVerifyPath
+	}
+	if v.ExpectedStatus == 0 {
+		v.ExpectedStatus = defaults.ExpectedStatus
+	}
+	if v.ExpectedStatus == 0 {
+		v.ExpectedStatus = http.StatusOK
+	}
+	if v.Count == 0 {
+		v.Count = defaults.Count
+	}
+	if v.Count == 0 {
+		v.Count = defaultSwapVerifyCount
+	}
+	if v.Timeout == 0 {
+		v.Timeout = defaults.Timeout
+	}
+	if v.Timeout == 0 {
+		v.Timeout = defaultSwapVerifyTimeout
+	}
+	return v
+}
+
+// verifyBackend sends v.Count verification requests to backendURL and
+// counts those answered with v.ExpectedStatus. It stops at the first failure.
+func verifyBackend(ctx context.Context, client *http.Client, backendURL string, v config.UpstreamVerifyConfig) BackendVerification {
+	res := BackendVerification{URL: backendURL}
+	target := strings.TrimSuffix(backendURL, "/") + "/" + strings.TrimPrefix(v.Path, "/")
+	for range v.Count {
+		res.Sent++
+		req, err := http.NewRequestWithContext(ctx, v.Method, target, nil)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		if resp.StatusCode != v.ExpectedStatus {
+			res.Error = fmt.Sprintf("status %d, expected %d", resp.StatusCode, v.ExpectedStatus)
+			return res
+		}
+		res.Passed++
+	}
+	return res
+}
+
+func (g *Runway) emitUpstreamEvent(typ webhook.EventType, name string, data map[string]interface{}) {
+	if g.webhookDispatcher == nil {
+		return
+	}
+	data["upstream"] = name
+	g.webhookDispatcher.Emit(webhook.NewEvent(typ, "", data))
+}
+
+func backendURLs(backends []config.BackendConfig) []string {
+	urls := make([]string, len(backends))
+	for i, b := range backends {
+		urls[i] = b.URL
+	}
+	return urls
+}
@@ -0,0 +1,240 @@
+package runway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+// swapBackend is a test backend that answers requests with its name and
+// counts verification requests to /ready.
+type swapBackend struct {
+	*httptest.Server
+	probes atomic.Int64
+}
+
+func newSwapBackend(t *testing.T, name string, readyStatus int) *swapBackend {
+	t.Helper()
+	b := &swapBackend{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ready" {
+			b.probes.Add(1)
+			w.WriteHeader(readyStatus)
+			return
+		}
+		io.WriteString(w, name)
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+func newUpstreamSwapServer(t *testing.T, oldURL string, swap config.UpstreamSwapConfig, webhookURL string) *Server {
+	t.Helper()
+	cfg := &config.Config{
+		Listeners: []config.ListenerConfig{{
+			ID: "default-http", Address: ":0", Protocol: config.ProtocolHTTP,
+		}},
+		Registry: config.RegistryConfig{Type: "memory"},
+		Upstreams: map[string]config.UpstreamConfig{
+			"pool": {Backends: []config.BackendConfig{{URL: oldURL}}},
+		},
+		Routes: []config.RouteConfig{
+			{ID: "a", Path: "/a", Upstream: "pool"},
+			{ID: "b", Path: "/b", Upstream: "pool"},
+			{ID: "c", Path: "/c", TrafficSplit: []config.TrafficSplitConfig{
+				{Name: "main", Weight: 100, Upstream: "pool"},
+			}},
+			{ID: "other", Path: "/other", Backends: []config.BackendConfig{{URL: oldURL}}},
+		},
+		Admin: config.AdminConfig{Enabled: true, Port: 8082, UpstreamSwap: swap},
+	}
+	if webhookURL != "" {
+		cfg.Webhooks = config.WebhooksConfig{
+			Enabled:   true,
+			Endpoints: []config.WebhookEndpoint{{ID: "ops", URL: webhookURL, Events: []string{"upstream.*"}}},
+		}
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	t.Cleanup(func() { server.Runway().Close() })
+	return server
+}
+
+func routeBody(t *testing.T, h http.Handler, path string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w.Body.String()
+}
+
+func postUpstreamAction(s *Server, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.adminHandler().ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(body)))
+	return w
+}
+
+func TestUpstreamSwap(t *testing.T) {
+	oldB := newSwapBackend(t, "old", http.StatusOK)
+	newB := newSwapBackend(t, "new", http.StatusOK)
+	badB := newSwapBackend(t, "bad", http.StatusServiceUnavailable)
+
+	var mu sync.Mutex
+	var events []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev struct {
+			Type string `json:"type"`
+		}
+		json.NewDecoder(r.Body).Decode(&ev)
+		mu.Lock()
+		events = append(events, ev.Type)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	s := newUpstreamSwapServer(t, oldB.URL, config.UpstreamSwapConfig{
+		Verify: config.UpstreamVerifyConfig{Path: "/ready", Count: 2},
+	}, hook.URL)
+	h := s.Runway().Handler()
+
+	for _, p := range []string{"/a", "/b", "/c", "/other"} {
+		if got := routeBody(t, h, p); got != "old" {
+			t.Fatalf("expected %s to start on the old backend, got %q", p, got)
+		}
+	}
+
+	// A failing candidate leaves every route on the old set.
+	w := postUpstreamAction(s, "/admin/upstreams/pool/swap",
+		`{"backends":[{"url":"`+newB.URL+`"},{"url":"`+badB.URL+`"}]}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a failing candidate, got %d: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "status 503, expected 200") {
+		t.Errorf("expected verification details, got %s", w.Body)
+	}
+	for _, p := range []string{"/a", "/b", "/c"} {
+		if got := routeBody(t, h, p); got != "old" {
+			t.Errorf("expected %s unchanged after failed verification, got %q", p, got)
+		}
+	}
+
+	// Concurrent traffic only ever sees one complete set.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, p := range []string{"/a", "/b", "/c"} {
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest("GET", p, nil))
+				if body := w.Body.String(); body != "old" && body != "new" {
+					t.Errorf("unexpected response on %s: %q", p, body)
+					return
+				}
+			}
+		}(p)
+	}
+
+	probes := newB.probes.Load()
+	w = postUpstreamAction(s, "/admin/upstreams/pool/swap",
+		`{"backends":[{"url":"`+newB.URL+`","weight":2}],"verify":{"count":3,"timeout":"2s"}}`)
+	close(stop)
+	wg.Wait()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected swap to succeed, got %d: %s", w.Code, w.Body)
+	}
+	var result UpstreamSwapResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(result.Routes, ",") != "a,b,c" || result.RollbackUntil == nil {
+		t.Errorf("expected routes a, b and c swapped with a rollback slot, got %+v", result)
+	}
+	if len(result.Previous) != 1 || result.Previous[0] != oldB.URL {
+		t.Errorf("expected previous set to be the old backend, got %v", result.Previous)
+	}
+	if n := newB.probes.Load() - probes; n < 3 {
+		t.Errorf("expected 3 verification requests, got %d", n)
+	}
+	for _, p := range []string{"/a", "/b", "/c"} {
+		if got := routeBody(t, h, p); got != "new" {
+			t.Errorf("expected %s on the new backend, got %q", p, got)
+		}
+	}
+	if got := routeBody(t, h, "/other"); got != "old" {
+		t.Errorf("expected unrelated route unchanged, got %q", got)
+	}
+	if us := s.Runway().GetUpstreams()["pool"]; len(us.Backends) != 1 || us.Backends[0].URL != newB.URL || us.Backends[0].Weight != 2 {
+		t.Errorf("expected running config to show the new set, got %+v", us)
+	}
+
+	w = postUpstreamAction(s, "/admin/upstreams/pool/rollback", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected rollback to succeed, got %d: %s", w.Code, w.Body)
+	}
+	for _, p := range []string{"/a", "/b", "/c"} {
+		if got := routeBody(t, h, p); got != "old" {
+			t.Errorf("expected %s back on the old backend, got %q", p, got)
+		}
+	}
+	if w = postUpstreamAction(s, "/admin/upstreams/pool/rollback", ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a second rollback, got %d", w.Code)
+	}
+	if w = postUpstreamAction(s, "/admin/upstreams/missing/swap", `{"backends":[{"url":"`+newB.URL+`"}]}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown upstream, got %d", w.Code)
+	}
+	if w = postUpstreamAction(s, "/admin/upstreams/pool/swap", `{"backends":[]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without backends, got %d", w.Code)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	want := "upstream.swap_failed,upstream.swapped,upstream.rolled_back"
+	for {
+		mu.Lock()
+		got := strings.Join(events, ",")
+		mu.Unlock()
+		if got == want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected webhook events %s, got %s", want, got)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestUpstreamSwap_RollbackWindow(t *testing.T) {
+	oldB := newSwapBackend(t, "old", http.StatusOK)
+	newB := newSwapBackend(t, "new", http.StatusOK)
+	s := newUpstreamSwapServer(t, oldB.URL, config.UpstreamSwapConfig{
+		RollbackWindow: time.Nanosecond,
+		Verify:         config.UpstreamVerifyConfig{Path: "/ready", Count: 1},
+	}, "")
+	h := s.Runway().Handler()
+
+	if w := postUpstreamAction(s, "/admin/upstreams/pool/swap", `{"backends":[{"url":"`+newB.URL+`"}]}`); w.Code != http.StatusOK {
+		t.Fatalf("expected swap to succeed, got %d: %s", w.Code, w.Body)
+	}
+	time.Sleep(time.Millisecond)
+	if w := postUpstreamAction(s, "/admin/upstreams/pool/rollback", ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 after the rollback window, got %d", w.Code)
+	}
+	if got := routeBody(t, h, "/a"); got != "new" {
+		t.Errorf("expected the swapped set to stay, got %q", got)
+	}
+}
@@ -0,0 +1,264 @@
+package runway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/wudi/runway/config"
+	openapivalidation "github.com/wudi/runway/internal/middleware/openapi"
+)
+
+// ConfigValidateTimeout bounds how long validating a candidate config may
+// take through the admin API.
+const ConfigValidateTimeout = 30 * time.Second
+
+// ErrValidationInProgress is returned by ValidateConfig while another
+// validation is still running.
+var ErrValidationInProgress = errors.New("another config validation is in progress")
+
+// Validation stages, reported with each error.
+const (
+	StageConfig          = "config"           // parsing and LoadConfig validation
+	StageGlobal          = "global"           // global managers and authentication
+	StageRoute           = "route"            // per-route and per-feature setup
+	StageSchemaEvolution = "schema_evolution" // breaking changes in block mode
+	StageTimeout         = "timeout"          // validation did not finish in time
+)
+
+// ValidationError is one problem found in a candidate config.
+type ValidationError struct {
+	Stage   string `json:"stage"`
+	Route   string `json:"route,omitempty"`
+	Feature string `json:"feature,omitempty"`
+	Error   string `json:"error"`
+}
+
+// DiffSet lists the IDs added, removed and changed between two configs.
+type DiffSet struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// FeatureToggle is a feature switched on or off on a route present in both
+// the running and the candidate config.
+type FeatureToggle struct {
+	Route   string `json:"route"`
+	Feature string `json:"feature"`
+	Enabled bool   `json:"enabled"`
+}
+
+// ConfigDiff summarizes how a candidate config differs from the running one.
+type ConfigDiff struct {
+	Routes    DiffSet         `json:"routes"`
+	Listeners DiffSet         `json:"listeners"`
+	Sections  []string        `json:"sections"` // changed top-level sections other than routes and listeners
+	Features  []FeatureToggle `json:"features"`
+}
+
+// ValidationReport is the result of validating a candidate config against
+// the running gateway.
+type ValidationReport struct {
+	Valid   bool              `json:"valid"`
+	Errors  []ValidationError `json:"errors"`
+	Changes []string          `json:"changes,omitempty"` // same summary a reload records
+	Diff    *ConfigDiff       `json:"diff,omitempty"`    // nil when the config does not parse
+}
+
+// validationRouteRe finds the route a config validation error is about.
+var validationRouteRe = regexp.MustCompile(`\broute ([^\s:]+):`)
+
+// ValidateConfig parses the YAML config in data and builds every route of
+// it the way Reload would, without starting, registering or swapping in
+// anything, and reports the errors found and how the config differs from
+// the running one. Only one validation runs at a time. When ctx ends first
+// the report carries a timeout error; the abandoned build still finishes
+// and cleans up in the background before the next validation may start.
+func (g *Runway) ValidateConfig(ctx context.Context, data []byte) (ValidationReport, error) {
+	if !g.validating.TryLock() {
+		return ValidationReport{}, ErrValidationInProgress
+	}
+
+	g.mu.RLock()
+	oldCfg := g.config
+	live := featureRoutes(g.features)
+	g.mu.RUnlock()
+
+	done := make(chan ValidationReport, 1)
+	go func() {
+		defer g.validating.Unlock()
+		done <- g.validateConfig(data, oldCfg, live)
+	}()
+
+	select {
+	case r := <-done:
+		return r, nil
+	case <-ctx.Done():
+		return ValidationReport{Errors: []ValidationError{{
+			Stage: StageTimeout,
+			Error: fmt.Sprintf("validation did not finish: %v", ctx.Err()),
+		}}}, nil
+	}
+}
+
+// validateConfig does the work of ValidateConfig against the running config
+// oldCfg, whose features are enabled on the routes in live.
+func (g *Runway) validateConfig(data []byte, oldCfg *config.Config, live map[string]map[string]bool) ValidationReport {
+	r := ValidationReport{Errors: []ValidationError{}}
+	newCfg, err := config.NewLoader().Parse(data)
+	if err != nil {
+		ve := ValidationError{Stage: StageConfig, Error: err.Error()}
+		if m := validationRouteRe.FindStringSubmatch(err.Error()); m != nil {
+			ve.Route = m[1]
+		}
+		r.Errors = append(r.Errors, ve)
+		return r
+	}
+	r.Changes = diffConfig(oldCfg, newCfg)
+	r.Diff = diffConfigs(oldCfg, newCfg)
+
+	s, err := g.newState(newCfg, true)
+	if err != nil {
+		r.Errors = append(r.Errors, ValidationError{Stage: StageGlobal, Error: err.Error()})
+		return r
+	}
+	defer s.routeManagers.cleanup()
+
+	failed := make(map[string]bool)
+	for _, rc := range newCfg.Routes {
+		if err := g.addRouteForState(s, rc); err != nil {
+			ve := ValidationError{Stage: StageRoute, Route: rc.ID, Error: err.Error()}
+			var fe *featureError
+			if errors.As(err, &fe) {
+				ve.Feature = fe.feature
+			}
+			r.Errors = append(r.Errors, ve)
+			failed[rc.ID] = true
+		}
+	}
+
+	if g.schemaChecker != nil && newCfg.OpenAPI.SchemaEvolution.Enabled {
+		for _, spec := range evolutionSpecs(newCfg) {
+			doc, loadErr := openapivalidation.LoadSpec(spec.file)
+			if loadErr != nil {
+				continue
+			}
+			if _, err := g.schemaChecker.Check(spec.id, doc); err != nil {
+				r.Errors = append(r.Errors, ValidationError{Stage: StageSchemaEvolution, Error: err.Error()})
+			}
+		}
+	}
+
+	r.Diff.Features = featureToggles(oldCfg, newCfg, live, featureRoutes(s.features), failed)
+	r.Valid = len(r.Errors) == 0
+	return r
+}
+
+// diffConfigs compares the routes, listeners and other sections of two
+// configs. Feature toggles are filled in once the new routes are built.
+func diffConfigs(oldCfg, newCfg *config.Config) *ConfigDiff {
+	d := &ConfigDiff{Features: []FeatureToggle{}}
+
+	oldRoutes := make(map[string]config.RouteConfig, len(oldCfg.Routes))
+	for _, rc := range oldCfg.Routes {
+		oldRoutes[rc.ID] = resolveUpstreamRefs(oldCfg, rc)
+	}
+	newRoutes := make(map[string]config.RouteConfig, len(newCfg.Routes))
+	for _, rc := range newCfg.Routes {
+		newRoutes[rc.ID] = resolveUpstreamRefs(newCfg, rc)
+	}
+	sharedChanged := sharedDefChanges(oldCfg, newCfg)
+	d.Routes = diffSet(oldRoutes, newRoutes, func(prev, cur config.RouteConfig) bool {
+		return !reflect.DeepEqual(prev, cur) || referencesAny(&cur, sharedChanged)
+	})
+
+	oldListeners := make(map[string]config.ListenerConfig, len(oldCfg.Listeners))
+	for _, lc := range oldCfg.Listeners {
+		oldListeners[lc.ID] = lc
+	}
+	newListeners := make(map[string]config.ListenerConfig, len(newCfg.Listeners))
+	for _, lc := range newCfg.Listeners {
+		newListeners[lc.ID] = lc
+	}
+	d.Listeners = diffSet(oldListeners, newListeners, func(prev, cur config.ListenerConfig) bool {
+		return !reflect.DeepEqual(prev, cur)
+	})
+
+	d.Sections = []string{}
+	for _, name := range changedFields(reflect.ValueOf(*oldCfg), reflect.ValueOf(*newCfg)) {
+		if name != "listeners" && name != "routes" {
+			d.Sections = append(d.Sections, name)
+		}
+	}
+	return d
+}
+
+// diffSet returns the sorted keys added to, removed from and changed
+// between two maps.
+func diffSet[V any](oldItems, newItems map[string]V, changed func(prev, cur V) bool) DiffSet {
+	d := DiffSet{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	for id, cur := range newItems {
+		prev, ok := oldItems[id]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, id)
+		case changed(prev, cur):
+			d.Changed = append(d.Changed, id)
+		}
+	}
+	for id := range oldItems {
+		if _, ok := newItems[id]; !ok {
+			d.Removed = append(d.Removed, id)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
+
+// featureRoutes maps each feature's name to the routes it is enabled on.
+func featureRoutes(features []Feature) map[string]map[string]bool {
+	m := make(map[string]map[string]bool, len(features))
+	for _, f := range features {
+		routes := make(map[string]bool)
+		for _, id := range f.RouteIDs() {
+			routes[id] = true
+		}
+		m[f.Name()] = routes
+	}
+	return m
+}
+
+// featureToggles lists the features switched on or off on routes present
+// in both configs, skipping routes that failed to build.
+func featureToggles(oldCfg, newCfg *config.Config, oldFeatures, newFeatures map[string]map[string]bool, failed map[string]bool) []FeatureToggle {
+	kept := make(map[string]bool, len(oldCfg.Routes))
+	for _, rc := range oldCfg.Routes {
+		kept[rc.ID] = true
+	}
+	names := make([]string, 0, len(newFeatures))
+	for name := range newFeatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	toggles := []FeatureToggle{}
+	for _, rc := range newCfg.Routes {
+		if !kept[rc.ID] || failed[rc.ID] {
+			continue
+		}
+		for _, name := range names {
+			was, now := oldFeatures[name][rc.ID], newFeatures[name][rc.ID]
+			if was != now {
+				toggles = append(toggles, FeatureToggle{Route: rc.ID, Feature: name, Enabled: now})
+			}
+		}
+	}
+	return toggles
+}
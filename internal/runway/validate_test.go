@@ -0,0 +1,167 @@
+package runway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/config"
+)
+
+const validateBaseConfig = `
+listeners:
+  - id: "http"
+    address: ":8080"
+    protocol: "http"
+routes:
+  - id: orders
+    path: /orders
+    backends:
+      - url: http://localhost:9000
+  - id: users
+    path: /users
+    backends:
+      - url: http://localhost:9001
+`
+
+func postValidate(t *testing.T, server *Server, body string) (int, ValidationReport) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/admin/config/validate", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, req)
+	var report ValidationReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	return w.Code, report
+}
+
+func TestConfigValidateEndpoint(t *testing.T) {
+	cfg, err := config.NewLoader().Parse([]byte(validateBaseConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(cfg, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.Runway().Close()
+	g := server.Runway()
+
+	t.Run("valid with diff", func(t *testing.T) {
+		candidate := strings.Replace(validateBaseConfig, `":8080"`, `":9090"`, 1)
+		candidate = strings.Replace(candidate, "  - id: users\n    path: /users\n    backends:\n      - url: http://localhost:9001\n", "", 1)
+		candidate += `  - id: billing
+    path: /billing
+    backends:
+      - url: http://localhost:9002
+`
+		candidate = strings.Replace(candidate, "      - url: http://localhost:9000\n", "      - url: http://localhost:9000\n    cache:\n      enabled: true\n", 1)
+		code, report := postValidate(t, server, candidate)
+		if code != http.StatusOK || !report.Valid || len(report.Errors) != 0 {
+			t.Fatalf("expected a valid report, got %d %+v", code, report)
+		}
+		d := report.Diff
+		if d == nil {
+			t.Fatal("expected a diff")
+		}
+		want := DiffSet{Added: []string{"billing"}, Removed: []string{"users"}, Changed: []string{"orders"}}
+		if !reflect.DeepEqual(d.Routes, want) {
+			t.Errorf("routes = %+v, want %+v", d.Routes, want)
+		}
+		if !reflect.DeepEqual(d.Listeners.Changed, []string{"http"}) {
+			t.Errorf("listeners = %+v, want http changed", d.Listeners)
+		}
+		if !reflect.DeepEqual(d.Features, []FeatureToggle{{Route: "orders", Feature: "cache", Enabled: true}}) {
+			t.Errorf("features = %+v, want cache enabled on orders", d.Features)
+		}
+		if len(report.Changes) == 0 {
+			t.Error("expected the reload change summary")
+		}
+	})
+
+	t.Run("route feature error", func(t *testing.T) {
+		candidate := validateBaseConfig + "    waf:\n      enabled: true\n      inline_rules:\n        - \"SecRule bogus\"\n"
+		code, report := postValidate(t, server, candidate)
+		if code != http.StatusUnprocessableEntity || report.Valid {
+			t.Fatalf("expected an invalid report, got %d %+v", code, report)
+		}
+		if len(report.Errors) != 1 {
+			t.Fatalf("errors = %+v, want one", report.Errors)
+		}
+		e := report.Errors[0]
+		if e.Stage != StageRoute || e.Route != "users" || e.Feature != "waf" || e.Error == "" {
+			t.Errorf("error = %+v, want a waf error on route users", e)
+		}
+		if report.Diff == nil || !reflect.DeepEqual(report.Diff.Routes.Changed, []string{"users"}) {
+			t.Errorf("expected the diff alongside the errors, got %+v", report.Diff)
+		}
+	})
+
+	t.Run("config error", func(t *testing.T) {
+		code, report := postValidate(t, server, validateBaseConfig+"    load_balancer: bogus\n")
+		if code != http.StatusUnprocessableEntity || report.Valid || report.Diff != nil {
+			t.Fatalf("expected an invalid report without a diff, got %d %+v", code, report)
+		}
+		if len(report.Errors) != 1 || report.Errors[0].Stage != StageConfig || report.Errors[0].Route != "users" {
+			t.Errorf("errors = %+v, want a config error on route users", report.Errors)
+		}
+	})
+
+	// Nothing was applied.
+	if g.config != cfg {
+		t.Error("running config was replaced")
+	}
+	if _, ok := g.routeStages.Load("billing"); ok {
+		t.Error("validated route was registered")
+	}
+	if g.GetRouter().GetRoute("billing") != nil || g.GetRouter().GetRoute("users") == nil {
+		t.Error("running routes changed")
+	}
+
+	req := httptest.NewRequest("GET", "/admin/config/validate", nil)
+	w := httptest.NewRecorder()
+	server.adminHandler().ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+}
+
+func TestValidateConfigBounded(t *testing.T) {
+	cfg, err := config.NewLoader().Parse([]byte(validateBaseConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	// A validation that is still running turns others away.
+	g.validating.Lock()
+	if _, err := g.ValidateConfig(context.Background(), []byte(validateBaseConfig)); err != ErrValidationInProgress {
+		t.Errorf("err = %v, want ErrValidationInProgress", err)
+	}
+
+	// One that does not finish in time reports a timeout.
+	g.validating.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err := g.ValidateConfig(ctx, []byte(validateBaseConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The build may still win the race against the cancelled context.
+	if !report.Valid && (len(report.Errors) != 1 || report.Errors[0].Stage != StageTimeout) {
+		t.Errorf("report = %+v, want a timeout error", report)
+	}
+
+	// The abandoned build releases the lock once it is done.
+	g.validating.Lock()
+	g.validating.Unlock()
+}
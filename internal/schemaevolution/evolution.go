@@ -61,24 +61,11 @@ func (c *Checker) CheckAndStore(specID string, newDoc *openapi3.T) (*Compatibili
 		c.logger.Error("schema evolution: failed to store spec", zap.String("spec_id", specID), zap.Error(storeErr))
 	}
 
-	if oldDoc == nil {
+	report := compare(specID, oldDoc, oldVersion, newDoc)
+	if report == nil {
 		return nil, nil // First version, no comparison
 	}
-
-	newVersion := ""
-	if newDoc.Info != nil {
-		newVersion = newDoc.Info.Version
-	}
-
-	changes := detectBreakingChanges(oldDoc, newDoc)
-	report := &CompatibilityReport{
-		SpecID:          specID,
-		OldVersion:      oldVersion,
-		NewVersion:      newVersion,
-		Compatible:      len(changes) == 0,
-		BreakingChanges: changes,
-		Timestamp:       time.Now(),
-	}
+	changes := report.BreakingChanges
 
 	c.mu.Lock()
 	c.reports[specID] = report
@@ -103,6 +90,43 @@ func (c *Checker) CheckAndStore(specID string, newDoc *openapi3.T) (*Compatibili
 	return report, nil
 }
 
+// Check compares a new spec against the previously stored version like
+// CheckAndStore, without storing it or recording the report.
+func (c *Checker) Check(specID string, newDoc *openapi3.T) (*CompatibilityReport, error) {
+	oldDoc, oldVersion, err := c.store.GetPrevious(specID)
+	if err != nil {
+		c.logger.Warn("schema evolution: failed to load previous spec", zap.String("spec_id", specID), zap.Error(err))
+	}
+	report := compare(specID, oldDoc, oldVersion, newDoc)
+	if report != nil && !report.Compatible && c.mode == "block" {
+		return report, fmt.Errorf("schema evolution: %d breaking change(s) detected in spec %q", len(report.BreakingChanges), specID)
+	}
+	return report, nil
+}
+
+// compare builds the compatibility report of newDoc against oldDoc, or
+// returns nil when there is no previous version.
+func compare(specID string, oldDoc *openapi3.T, oldVersion string, newDoc *openapi3.T) *CompatibilityReport {
+	if oldDoc == nil {
+		return nil
+	}
+
+	newVersion := ""
+	if newDoc.Info != nil {
+		newVersion = newDoc.Info.Version
+	}
+
+	changes := detectBreakingChanges(oldDoc, newDoc)
+	return &CompatibilityReport{
+		SpecID:          specID,
+		OldVersion:      oldVersion,
+		NewVersion:      newVersion,
+		Compatible:      len(changes) == 0,
+		BreakingChanges: changes,
+		Timestamp:       time.Now(),
+	}
+}
+
 // GetReport returns the most recent compatibility report for a spec.
 func (c *Checker) GetReport(specID string) *CompatibilityReport {
 	c.mu.RLock()
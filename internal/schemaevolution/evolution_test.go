@@ -490,6 +490,47 @@ paths:
 	}
 }
 
+func TestCheckerCheckDoesNotStore(t *testing.T) {
+	dir := t.TempDir()
+	checker, err := NewChecker(config.SchemaEvolutionConfig{
+		Enabled:  true,
+		Mode:     "block",
+		StoreDir: dir,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := loadSpec(t, baseSpec)
+	if _, err := checker.CheckAndStore("test-api", old); err != nil {
+		t.Fatal(err)
+	}
+
+	removed := loadSpec(t, `
+openapi: "3.0.0"
+info:
+  title: Test API
+  version: "2.0.0"
+paths: {}
+`)
+	report, err := checker.Check("test-api", removed)
+	if err == nil {
+		t.Error("block mode should return error on breaking changes")
+	}
+	if report == nil || report.Compatible {
+		t.Fatalf("expected an incompatible report, got %+v", report)
+	}
+	if checker.GetReport("test-api") != nil {
+		t.Error("Check should not record a report")
+	}
+
+	// The stored version is still the original, so checking it again
+	// finds nothing.
+	if report, err := checker.Check("test-api", old); err != nil || !report.Compatible {
+		t.Errorf("Check(original) = %+v, %v; want compatible", report, err)
+	}
+}
+
 func TestSpecStorePruning(t *testing.T) {
 	dir := t.TempDir()
 	store, err := NewSpecStore(dir, 3)
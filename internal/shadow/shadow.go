@@ -0,0 +1,351 @@
+// Package shadow evaluates request-phase policies (WAF rules, OPA policies,
+// Lua scripts) off the request path. A request is captured into a pooled,
+// size-capped snapshot and queued to a bounded worker pool shared by every
+// route; the request itself proceeds immediately. Only verdicts and counters
+// are recorded, so shadow evaluation never affects a response. Other
+// observe-only work can share the workers through Go.
+package shadow
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware/geo"
+	"github.com/wudi/runway/internal/middleware/realip"
+	"github.com/wudi/runway/variables"
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxBodySize is the largest request body prefix copied into a snapshot.
+	MaxBodySize = 64 << 10
+
+	// queueSize bounds the snapshots waiting for a worker across all routes.
+	queueSize = 1024
+)
+
+// Verdict is the outcome of a request-phase evaluation.
+type Verdict uint8
+
+const (
+	// NoVerdict marks the absence of an enforcing evaluation to compare with.
+	NoVerdict Verdict = iota
+	// Allow means the request would proceed.
+	Allow
+	// Block means the request would be blocked, denied or answered early.
+	Block
+	// Error means the evaluation failed.
+	Error
+)
+
+// job is a queued snapshot and the evaluator that will judge it, or a task
+// queued with Go.
+type job struct {
+	e         *Evaluator
+	req       *Request
+	enforcing Verdict
+	task      func()
+}
+
+var (
+	jobs      = make(chan job, queueSize)
+	startOnce sync.Once
+)
+
+// start launches the shared workers on first use.
+func start() {
+	startOnce.Do(func() {
+		for range runtime.GOMAXPROCS(0) {
+			go worker()
+		}
+	})
+}
+
+func worker() {
+	for j := range jobs {
+		if j.task != nil {
+			runTask(j.task)
+			continue
+		}
+		j.e.run(j.req, j.enforcing)
+	}
+}
+
+// Go queues fn on the shared workers, for other off-path observation work
+// such as response schema drift checks. It reports false without running
+// fn when the queue is full.
+func Go(fn func()) bool {
+	start()
+	select {
+	case jobs <- job{task: fn}:
+		return true
+	default:
+		return false
+	}
+}
+
+func runTask(fn func()) {
+	defer func() {
+		if p := recover(); p != nil {
+			logging.Error("shadow task panicked", zap.Any("panic", p))
+		}
+	}()
+	fn()
+}
+
+// Evaluator queues request snapshots for one feature's shadow evaluation
+// and records the verdicts.
+type Evaluator struct {
+	eval     func(*Request) Verdict
+	withBody bool
+
+	queued    atomic.Int64
+	dropped   atomic.Int64
+	evaluated atomic.Int64
+	allowed   atomic.Int64
+	blocked   atomic.Int64
+	errors    atomic.Int64
+
+	compared         atomic.Int64
+	shadowBlocked    atomic.Int64
+	enforcingBlocked atomic.Int64
+}
+
+// Stats is a snapshot of an Evaluator's counters.
+type Stats struct {
+	Queued     int64       `json:"queued"`
+	Dropped    int64       `json:"dropped"`
+	Pending    int         `json:"pending"`
+	Evaluated  int64       `json:"evaluated"`
+	Allowed    int64       `json:"allowed"`
+	Blocked    int64       `json:"blocked"`
+	Errors     int64       `json:"errors"`
+	Divergence *Divergence `json:"divergence,omitempty"`
+}
+
+// Divergence counts requests on which the shadow and enforcing verdicts
+// disagreed. Evaluations where either side failed are not compared.
+type Divergence struct {
+	Compared         int64 `json:"compared"`
+	ShadowBlocked    int64 `json:"shadow_blocked"`    // shadow blocks, enforcing allowed
+	EnforcingBlocked int64 `json:"enforcing_blocked"` // enforcing blocked, shadow allows
+}
+
+// NewEvaluator returns an Evaluator that judges snapshots with eval on the
+// shared workers. withBody controls whether snapshots carry the request body.
+func NewEvaluator(eval func(*Request) Verdict, withBody bool) *Evaluator {
+	return &Evaluator{eval: eval, withBody: withBody}
+}
+
+// Capture snapshots r for a later Submit. The body, if captured, is replayed
+// to r's next reader. It returns nil without copying anything when the queue
+// is full, and nil when the body cannot be read; Submit counts both as
+// dropped evaluations.
+func (e *Evaluator) Capture(r *http.Request) *Request {
+	if len(jobs) == cap(jobs) {
+		return nil
+	}
+	return capture(r, e.withBody)
+}
+
+// Submit queues req for evaluation. enforcing is the verdict the enforcing
+// configuration reached on the same request, or NoVerdict. The request is
+// dropped and counted when the queue is full.
+func (e *Evaluator) Submit(req *Request, enforcing Verdict) {
+	if req == nil {
+		e.dropped.Add(1)
+		return
+	}
+	start()
+	select {
+	case jobs <- job{e: e, req: req, enforcing: enforcing}:
+		e.queued.Add(1)
+	default:
+		e.dropped.Add(1)
+		req.Release()
+	}
+}
+
+// run evaluates req on a worker and records the verdict.
+func (e *Evaluator) run(req *Request, enforcing Verdict) {
+	defer req.Release()
+
+	v := Error
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				logging.Error("shadow evaluation panicked", zap.Any("panic", p))
+			}
+		}()
+		v = e.eval(req)
+	}()
+
+	e.evaluated.Add(1)
+	switch v {
+	case Allow:
+		e.allowed.Add(1)
+	case Block:
+		e.blocked.Add(1)
+	default:
+		e.errors.Add(1)
+		return
+	}
+	if enforcing != Allow && enforcing != Block {
+		return
+	}
+	e.compared.Add(1)
+	switch {
+	case v == Block && enforcing == Allow:
+		e.shadowBlocked.Add(1)
+	case v == Allow && enforcing == Block:
+		e.enforcingBlocked.Add(1)
+	}
+}
+
+// Stats returns a snapshot of the evaluator's counters.
+func (e *Evaluator) Stats() Stats {
+	s := Stats{
+		Queued:    e.queued.Load(),
+		Dropped:   e.dropped.Load(),
+		Pending:   len(jobs),
+		Evaluated: e.evaluated.Load(),
+		Allowed:   e.allowed.Load(),
+		Blocked:   e.blocked.Load(),
+		Errors:    e.errors.Load(),
+	}
+	if n := e.compared.Load(); n > 0 {
+		s.Divergence = &Divergence{
+			Compared:         n,
+			ShadowBlocked:    e.shadowBlocked.Load(),
+			EnforcingBlocked: e.enforcingBlocked.Load(),
+		}
+	}
+	return s
+}
+
+// Request is a pooled snapshot of the request data shadow evaluations read:
+// method, URL, headers, connection details, variable context and at most
+// MaxBodySize bytes of body.
+type Request struct {
+	Method     string
+	URL        url.URL
+	Proto      string
+	Host       string
+	RemoteAddr string
+	Header     http.Header
+	TLS        *tls.ConnectionState
+	Body       []byte
+	// Truncated reports that the body was longer than MaxBodySize.
+	Truncated bool
+
+	clientIP string
+	geo      *geo.GeoResult
+	vars     *variables.Context
+}
+
+var requestPool = sync.Pool{
+	New: func() any { return &Request{Header: make(http.Header)} },
+}
+
+func capture(r *http.Request, withBody bool) *Request {
+	s := requestPool.Get().(*Request)
+	s.Method = r.Method
+	s.URL = *r.URL
+	s.Proto = r.Proto
+	s.Host = r.Host
+	s.RemoteAddr = r.RemoteAddr
+	s.TLS = r.TLS
+	for k, vv := range r.Header {
+		s.Header[k] = append(s.Header[k], vv...)
+	}
+	s.clientIP = variables.ExtractClientIP(r)
+	s.geo = geo.GeoResultFromContext(r.Context())
+	if vc, ok := r.Context().Value(variables.RequestContextKey{}).(*variables.Context); ok {
+		s.vars = vc.Clone()
+	}
+
+	if withBody && r.Body != nil && r.Body != http.NoBody {
+		if cap(s.Body) < MaxBodySize+1 {
+			s.Body = make([]byte, MaxBodySize+1)
+		}
+		n, err := io.ReadFull(r.Body, s.Body[:MaxBodySize+1])
+		prefix := bytes.NewReader(bytes.Clone(s.Body[:n]))
+		switch err {
+		case nil:
+			// More body follows the captured prefix.
+			r.Body = replayBody{io.MultiReader(prefix, r.Body), r.Body}
+		case io.EOF, io.ErrUnexpectedEOF:
+			r.Body.Close()
+			r.Body = io.NopCloser(prefix)
+		default:
+			// Keep the read error visible to the next reader. The body is
+			// incomplete, so the request is not evaluated.
+			r.Body = replayBody{io.MultiReader(prefix, errReader{err}), r.Body}
+			s.Release()
+			return nil
+		}
+		s.Truncated = n > MaxBodySize
+		s.Body = s.Body[:min(n, MaxBodySize)]
+	}
+	return s
+}
+
+type errReader struct{ err error }
+
+func (e errReader) Read([]byte) (int, error) { return 0, e.err }
+
+// replayBody replays a captured prefix before the rest of the original body.
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// HTTPRequest returns a request built from the snapshot for evaluators that
+// take an *http.Request. Its context is not canceled with the original
+// request and carries the client IP, geo result and a copy of the variable
+// context. The request is only valid until the snapshot is released.
+func (s *Request) HTTPRequest() *http.Request {
+	ctx := realip.WithClientIP(context.Background(), s.clientIP)
+	if s.geo != nil {
+		ctx = geo.WithGeoResult(ctx, s.geo)
+	}
+	if s.vars != nil {
+		ctx = context.WithValue(ctx, variables.RequestContextKey{}, s.vars)
+	}
+	u := s.URL
+	var body io.ReadCloser = http.NoBody
+	if len(s.Body) > 0 {
+		body = io.NopCloser(bytes.NewReader(s.Body))
+	}
+	r := (&http.Request{
+		Method:        s.Method,
+		URL:           &u,
+		Proto:         s.Proto,
+		Header:        s.Header,
+		Host:          s.Host,
+		RemoteAddr:    s.RemoteAddr,
+		TLS:           s.TLS,
+		Body:          body,
+		ContentLength: int64(len(s.Body)),
+	}).WithContext(ctx)
+	if s.vars != nil {
+		s.vars.Request = r
+	}
+	return r
+}
+
+// Release returns the snapshot to the pool.
+func (s *Request) Release() {
+	clear(s.Header)
+	variables.ReleaseContext(s.vars)
+	*s = Request{Header: s.Header, Body: s.Body[:0]}
+	requestPool.Put(s)
+}
@@ -0,0 +1,159 @@
+package shadow
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/variables"
+)
+
+// waitEvaluated waits until e has evaluated n snapshots.
+func waitEvaluated(t *testing.T, e *Evaluator, n int64) Stats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s := e.Stats()
+		if s.Evaluated >= n {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d evaluations, got %+v", n, s)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCapture_ReplaysBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		truncated bool
+	}{
+		{name: "small", size: 100},
+		{name: "exact", size: MaxBodySize},
+		{name: "oversized", size: MaxBodySize + 10, truncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := strings.Repeat("x", tt.size)
+			r := httptest.NewRequest("POST", "/upload?a=1", strings.NewReader(body))
+			r.Header.Set("X-Test", "1")
+
+			snap := NewEvaluator(nil, true).Capture(r)
+			defer snap.Release()
+
+			if got, _ := io.ReadAll(r.Body); string(got) != body {
+				t.Errorf("expected downstream to read the whole body, got %d bytes", len(got))
+			}
+			if len(snap.Body) != min(tt.size, MaxBodySize) || snap.Truncated != tt.truncated {
+				t.Errorf("expected %d captured bytes (truncated %v), got %d (%v)",
+					min(tt.size, MaxBodySize), tt.truncated, len(snap.Body), snap.Truncated)
+			}
+			if snap.Header.Get("X-Test") != "1" || snap.URL.RawQuery != "a=1" {
+				t.Errorf("unexpected snapshot %+v", snap)
+			}
+		})
+	}
+}
+
+func TestCapture_HTTPRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "/orders", strings.NewReader(`{"id":1}`))
+	r.RemoteAddr = "10.0.0.9:4321"
+	vc := variables.NewContext(r)
+	vc.RouteID = "orders"
+	r = r.WithContext(context.WithValue(r.Context(), variables.RequestContextKey{}, vc))
+
+	snap := NewEvaluator(nil, false).Capture(r)
+	defer snap.Release()
+	vc.RouteID = "changed"
+
+	req := snap.HTTPRequest()
+	if got := variables.GetFromRequest(req).RouteID; got != "orders" {
+		t.Errorf("expected a copy of the variable context, got route %q", got)
+	}
+	if got := variables.ExtractClientIP(req); got != "10.0.0.9" {
+		t.Errorf("expected client IP 10.0.0.9, got %q", got)
+	}
+	if req.Body != http.NoBody || len(snap.Body) != 0 {
+		t.Error("expected no body without withBody")
+	}
+	if got, _ := io.ReadAll(r.Body); string(got) != `{"id":1}` {
+		t.Errorf("expected original body untouched, got %q", got)
+	}
+}
+
+func TestEvaluator_VerdictsAndDivergence(t *testing.T) {
+	e := NewEvaluator(func(req *Request) Verdict {
+		switch req.URL.Path {
+		case "/block":
+			return Block
+		case "/error":
+			return Error
+		}
+		return Allow
+	}, false)
+
+	submit := func(path string, enforcing Verdict) {
+		e.Submit(e.Capture(httptest.NewRequest("GET", path, nil)), enforcing)
+	}
+	submit("/block", Allow)  // shadow blocks, enforcing allowed
+	submit("/ok", Block)     // enforcing blocked, shadow allows
+	submit("/block", Block)  // agree
+	submit("/ok", NoVerdict) // nothing to compare
+	submit("/error", Allow)  // errors are not compared
+	submit("/ok", Error)     // neither are enforcing errors
+	s := waitEvaluated(t, e, 6)
+
+	if s.Queued != 6 || s.Dropped != 0 || s.Allowed != 3 || s.Blocked != 2 || s.Errors != 1 {
+		t.Errorf("unexpected verdict counts %+v", s)
+	}
+	want := Divergence{Compared: 3, ShadowBlocked: 1, EnforcingBlocked: 1}
+	if s.Divergence == nil || *s.Divergence != want {
+		t.Errorf("expected divergence %+v, got %+v", want, s.Divergence)
+	}
+}
+
+func TestEvaluator_DropsWhenQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	e := NewEvaluator(func(*Request) Verdict {
+		<-release
+		return Allow
+	}, false)
+
+	// Occupy every worker and fill the queue.
+	limit := runtime.GOMAXPROCS(0) + queueSize + 100
+	for i := 0; i < limit && e.Stats().Dropped == 0; i++ {
+		e.Submit(e.Capture(httptest.NewRequest("GET", "/", nil)), NoVerdict)
+	}
+	close(release)
+
+	s := e.Stats()
+	if s.Dropped == 0 {
+		t.Fatalf("expected evaluations to be dropped, got %+v", s)
+	}
+	if s.Queued > int64(runtime.GOMAXPROCS(0)+queueSize) {
+		t.Errorf("expected at most %d queued, got %d", runtime.GOMAXPROCS(0)+queueSize, s.Queued)
+	}
+	waitEvaluated(t, e, s.Queued)
+}
+
+func TestGo_RecoversPanics(t *testing.T) {
+	done := make(chan struct{})
+	if !Go(func() { panic("boom") }) {
+		t.Fatal("expected the task to be queued")
+	}
+	if !Go(func() { close(done) }) {
+		t.Fatal("expected the task to be queued")
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("worker did not survive a panicking task")
+	}
+}
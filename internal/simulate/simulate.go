@@ -0,0 +1,344 @@
+// Package simulate traces a request through a route's middleware chain
+// without reaching the backend.
+//
+// A simulated request carries a Trace in its context. Middlewares that
+// support simulation check Active and, while it is true, make their
+// decision without changing shared state: rate limiters check without
+// consuming, counters and audit records are not updated and no external
+// calls beyond the decision itself are made. They may explain their
+// decision with Note and Match. Middlewares without that support must not
+// run in a simulation; the caller records them with Skip instead.
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/router"
+)
+
+// Stage decisions.
+const (
+	Passed   = "passed"   // the request continued unchanged
+	Modified = "modified" // the request continued with changed headers or URL
+	Blocked  = "blocked"  // the stage answered the request itself
+	Skipped  = "skipped"  // the stage was not run
+)
+
+// maxBody caps the request body echoed back in a Result.
+const maxBody = 64 << 10
+
+// Identity is a caller identity assumed by the simulation in place of
+// authenticating the request.
+type Identity struct {
+	ClientID string                 `json:"client_id"`
+	AuthType string                 `json:"auth_type,omitempty"`
+	Claims   map[string]interface{} `json:"claims,omitempty"`
+}
+
+// Request describes the request to simulate.
+type Request struct {
+	Method   string            `json:"method"`
+	Path     string            `json:"path"` // may include a query string
+	Host     string            `json:"host,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	ClientIP string            `json:"client_ip,omitempty"`
+	Identity *Identity         `json:"identity,omitempty"`
+}
+
+// HTTPRequest builds the *http.Request to run through the chain.
+func (req Request) HTTPRequest() (*http.Request, error) {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	path := req.Path
+	if path == "" {
+		path = "/"
+	}
+	r, err := http.NewRequest(method, path, bytes.NewBufferString(req.Body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	for k, v := range req.Headers {
+		r.Header.Set(k, v)
+	}
+	r.Host = req.Host
+	if r.Host == "" {
+		r.Host = r.Header.Get("Host")
+	}
+	r.RemoteAddr = "127.0.0.1:0"
+	if req.ClientIP != "" {
+		r.RemoteAddr = req.ClientIP + ":0"
+	}
+	return r, nil
+}
+
+// RequestChange is how a stage changed the request.
+type RequestChange struct {
+	HeadersBefore http.Header `json:"headers_before"`
+	HeadersAfter  http.Header `json:"headers_after"`
+	URLBefore     string      `json:"url_before,omitempty"`
+	URLAfter      string      `json:"url_after,omitempty"`
+}
+
+// Stage is the decision of one middleware.
+type Stage struct {
+	Name     string         `json:"name"`
+	Decision string         `json:"decision"`
+	Status   int            `json:"status,omitempty"` // response status of a blocking stage
+	Reason   string         `json:"reason,omitempty"` // why the stage was skipped
+	Request  *RequestChange `json:"request,omitempty"`
+
+	// ResponseHeaders are the headers the stage set on the response before
+	// passing the request on, e.g. RateLimit-Remaining.
+	ResponseHeaders http.Header `json:"response_headers,omitempty"`
+	Matched         []string    `json:"matched,omitempty"` // rules that matched
+	Notes           []string    `json:"notes,omitempty"`
+}
+
+// Upstream is the request as it would be sent to the backend.
+type Upstream struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Host    string      `json:"host,omitempty"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Response is the response a blocking stage sent.
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Result is the outcome of a simulation.
+type Result struct {
+	Route        string                   `json:"route"`
+	MatchedRoute string                   `json:"matched_route"`   // route the request's method, host and path select; empty when none
+	Match        *router.MatchExplanation `json:"match,omitempty"` // the route's match conditions, one by one
+	Outcome      string                   `json:"outcome"`         // "forwarded" or "blocked"
+	BlockedBy    string                   `json:"blocked_by,omitempty"`
+	Stages       []Stage                  `json:"stages"`
+	Upstream     *Upstream                `json:"upstream_request,omitempty"`
+	Response     *Response                `json:"response,omitempty"`
+	Notes        []string                 `json:"notes,omitempty"`
+}
+
+// Trace records the stages a simulated request passes through.
+type Trace struct {
+	mu       sync.Mutex
+	stages   []*Stage
+	current  *Stage
+	upstream *Upstream
+}
+
+type traceKey struct{}
+
+// NewTrace creates an empty trace.
+func NewTrace() *Trace {
+	return &Trace{}
+}
+
+// WithTrace returns a context marking requests as simulated.
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// FromContext returns the trace of a simulated request, or nil.
+func FromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// Active reports whether ctx belongs to a simulated request.
+func Active(ctx context.Context) bool {
+	return FromContext(ctx) != nil
+}
+
+// Note explains the running stage's decision. It does nothing outside a
+// simulation.
+func Note(ctx context.Context, format string, args ...interface{}) {
+	if t := FromContext(ctx); t != nil {
+		t.annotate(func(st *Stage) { st.Notes = append(st.Notes, fmt.Sprintf(format, args...)) })
+	}
+}
+
+// Match records a rule that matched in the running stage. It does nothing
+// outside a simulation.
+func Match(ctx context.Context, rule string) {
+	if t := FromContext(ctx); t != nil {
+		t.annotate(func(st *Stage) { st.Matched = append(st.Matched, rule) })
+	}
+}
+
+func (t *Trace) annotate(fn func(*Stage)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.current != nil {
+		fn(t.current)
+	}
+}
+
+// Stage wraps mw so its decision is recorded under name. mw must support
+// simulation.
+func (t *Trace) Stage(name string, mw middleware.Middleware) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			st := &Stage{Name: name}
+			t.mu.Lock()
+			t.stages = append(t.stages, st)
+			t.current = st
+			t.mu.Unlock()
+
+			headers, url := r.Header.Clone(), r.URL.String()
+			respHeaders := w.Header().Clone()
+			sw := &statusWriter{ResponseWriter: w}
+			reached := false
+			h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reached = true
+				t.mu.Lock()
+				st.Decision = Passed
+				if after := r.URL.String(); after != url || !equalHeaders(headers, r.Header) {
+					st.Decision = Modified
+					st.Request = &RequestChange{HeadersBefore: headers, HeadersAfter: r.Header.Clone()}
+					if after != url {
+						st.Request.URLBefore, st.Request.URLAfter = url, after
+					}
+				}
+				st.ResponseHeaders = addedHeaders(respHeaders, w.Header())
+				t.current = nil
+				t.mu.Unlock()
+				next.ServeHTTP(w, r)
+			}))
+			h.ServeHTTP(sw, r)
+
+			if !reached {
+				t.mu.Lock()
+				st.Decision = Blocked
+				st.Status = sw.status
+				if st.Status == 0 {
+					st.Status = http.StatusOK
+				}
+				t.current = nil
+				t.mu.Unlock()
+			}
+		})
+	}
+}
+
+// Skip records a stage that was not run and passes the request on.
+func (t *Trace) Skip(name, reason string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.mu.Lock()
+			t.stages = append(t.stages, &Stage{Name: name, Decision: Skipped, Reason: reason})
+			t.mu.Unlock()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Recorder returns the handler that stands in for the backend: it records
+// the request it receives and answers 200 with no body.
+func (t *Trace) Recorder() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		up := &Upstream{
+			Method:  r.Method,
+			URL:     r.URL.String(),
+			Host:    r.Host,
+			Headers: r.Header.Clone(),
+		}
+		if r.Body != nil {
+			body, _ := io.ReadAll(io.LimitReader(r.Body, maxBody))
+			up.Body = string(body)
+		}
+		t.mu.Lock()
+		t.upstream = up
+		t.mu.Unlock()
+	})
+}
+
+// Stages returns the recorded stages in chain order.
+func (t *Trace) Stages() []Stage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Stage, len(t.stages))
+	for i, st := range t.stages {
+		out[i] = *st
+	}
+	return out
+}
+
+// Upstream returns the request the backend would have received, or nil
+// when a stage blocked it.
+func (t *Trace) Upstream() *Upstream {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.upstream
+}
+
+// statusWriter captures the status written by a blocking stage.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func equalHeaders(a, b http.Header) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, av := range a {
+		bv, ok := b[k]
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if av[i] != bv[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// addedHeaders returns the headers in after that are new or changed
+// relative to before, or nil when there are none.
+func addedHeaders(before, after http.Header) http.Header {
+	var out http.Header
+	for k, v := range after {
+		if bv, ok := before[k]; ok && equalHeaders(http.Header{k: bv}, http.Header{k: v}) {
+			continue
+		}
+		if out == nil {
+			out = make(http.Header)
+		}
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package simulate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrace_RecordsDecisions(t *testing.T) {
+	trace := NewTrace()
+	setHeader := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Note(r.Context(), "tagging")
+			r.Header.Set("X-Tag", "1")
+			w.Header().Set("X-Seen", "yes")
+			next.ServeHTTP(w, r)
+		})
+	}
+	passThrough := func(next http.Handler) http.Handler { return next }
+	reject := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Match(r.Context(), "deny-all")
+			w.WriteHeader(http.StatusForbidden)
+		})
+	}
+
+	build := func(last func(http.Handler) http.Handler) http.Handler {
+		h := trace.Recorder()
+		h = trace.Stage("last", last)(h)
+		h = trace.Skip("cache", "no simulation support")(h)
+		h = trace.Stage("pass", passThrough)(h)
+		return trace.Stage("tag", setHeader)(h)
+	}
+
+	req := httptest.NewRequest("GET", "/a?b=1", nil)
+	req = req.WithContext(WithTrace(req.Context(), trace))
+	build(reject).ServeHTTP(httptest.NewRecorder(), req)
+
+	stages := trace.Stages()
+	if len(stages) != 4 {
+		t.Fatalf("expected 4 stages, got %+v", stages)
+	}
+	if st := stages[0]; st.Decision != Modified || st.Request.HeadersAfter.Get("X-Tag") != "1" ||
+		st.ResponseHeaders.Get("X-Seen") != "yes" || len(st.Notes) != 1 {
+		t.Errorf("unexpected tag stage %+v", st)
+	}
+	if stages[1].Decision != Passed || stages[1].ResponseHeaders != nil {
+		t.Errorf("unexpected pass stage %+v", stages[1])
+	}
+	if stages[2].Decision != Skipped || stages[2].Reason == "" {
+		t.Errorf("unexpected cache stage %+v", stages[2])
+	}
+	if st := stages[3]; st.Decision != Blocked || st.Status != http.StatusForbidden || len(st.Matched) != 1 {
+		t.Errorf("unexpected last stage %+v", st)
+	}
+	if trace.Upstream() != nil {
+		t.Error("a blocked request must not reach the recorder")
+	}
+
+	trace = NewTrace()
+	req = httptest.NewRequest("POST", "/a?b=1", nil)
+	req = req.WithContext(WithTrace(req.Context(), trace))
+	build(passThrough).ServeHTTP(httptest.NewRecorder(), req)
+	if up := trace.Upstream(); up == nil || up.URL != "/a?b=1" || up.Headers.Get("X-Tag") != "1" {
+		t.Errorf("unexpected upstream request %+v", up)
+	}
+}
+
+func TestNote_NoopOutsideSimulation(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if Active(req.Context()) {
+		t.Fatal("plain requests must not be simulated")
+	}
+	Note(req.Context(), "ignored")
+	Match(req.Context(), "ignored")
+}
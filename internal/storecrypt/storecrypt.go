@@ -0,0 +1,260 @@
+// Package storecrypt seals the values that the distributed cache,
+// idempotency and dedup stores write to Redis, so response bodies are not
+// kept there in the clear.
+//
+// A sealed value is laid out as
+//
+//	version(1) | len(key ID)(1) | key ID | nonce(12) | AES-256-GCM ciphertext
+//
+// with the Redis key as additional data, so an entry copied to another key
+// does not open. The key ID lets a keyring with a rotated key keep reading
+// entries sealed with the previous key for a grace window; stores rewrite
+// those entries under the current key when they read them.
+package storecrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+// DefaultGrace is how long the previous key stays readable when
+// previous_key_grace is not set.
+const DefaultGrace = 24 * time.Hour
+
+const (
+	version   = 1
+	nonceSize = 12
+
+	// maxPooled keeps buffers that grew for unusually large entries out of
+	// the pools.
+	maxPooled = 1 << 20
+)
+
+var (
+	// ErrMalformed is returned for values that are not sealed entries.
+	ErrMalformed = errors.New("storecrypt: malformed entry")
+	// ErrUnknownKey is returned for entries sealed with a key the keyring
+	// does not hold, or a previous key past its grace window.
+	ErrUnknownKey = errors.New("storecrypt: entry sealed with an unknown or expired key")
+)
+
+var (
+	bufPool  = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	sealPool = sync.Pool{New: func() any { return new([]byte) }}
+)
+
+// GetBuffer returns an empty buffer from the pool shared by the stores for
+// gob encoding.
+func GetBuffer() *bytes.Buffer {
+	return bufPool.Get().(*bytes.Buffer)
+}
+
+// PutBuffer returns buf to the pool.
+func PutBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooled {
+		return
+	}
+	buf.Reset()
+	bufPool.Put(buf)
+}
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func newKey(id, keyBase64 string) (key, error) {
+	raw, err := base64.StdEncoding.DecodeString(keyBase64)
+	if err != nil {
+		return key{}, fmt.Errorf("storecrypt: key %s: %w", id, err)
+	}
+	if len(raw) != 32 {
+		return key{}, fmt.Errorf("storecrypt: key %s must be 32 bytes, got %d", id, len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return key{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return key{}, err
+	}
+	return key{id: id, aead: aead}, nil
+}
+
+// counters are shared by the keyrings built across reloads.
+type counters struct {
+	sealed   atomic.Int64
+	opened   atomic.Int64
+	resealed atomic.Int64
+	failures atomic.Int64
+}
+
+// Keyring holds the current key and, during rotation, the previous one. A
+// nil *Keyring leaves values unsealed.
+type Keyring struct {
+	current  key
+	previous *key
+	since    time.Time // when the current key was first loaded
+	graceEnd time.Time // previous key is readable until then
+	now      func() time.Time
+	counts   *counters
+}
+
+// New builds a keyring from cfg, or returns nil when no key is configured.
+// prev is the keyring being replaced on reload: when it holds the same
+// current key, the grace window keeps counting from when that key was first
+// loaded. Counters carry over either way.
+func New(cfg config.StorageEncryptionConfig, prev *Keyring) (*Keyring, error) {
+	if cfg.KeyBase64 == "" {
+		return nil, nil
+	}
+	cur, err := newKey(cfg.KeyID, cfg.KeyBase64)
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{current: cur, since: time.Now(), now: time.Now, counts: &counters{}}
+	if prev != nil {
+		k.counts = prev.counts
+		if prev.current.id == cfg.KeyID {
+			k.since = prev.since
+		}
+	}
+	if cfg.PreviousKeyBase64 != "" {
+		pk, err := newKey(cfg.PreviousKeyID, cfg.PreviousKeyBase64)
+		if err != nil {
+			return nil, err
+		}
+		grace := cfg.PreviousKeyGrace
+		if grace == 0 {
+			grace = DefaultGrace
+		}
+		k.previous = &pk
+		k.graceEnd = k.since.Add(grace)
+	}
+	return k, nil
+}
+
+// Seal encrypts plain under the current key with aad (the Redis key) as
+// additional data and passes the sealed value to write. The sealed bytes come
+// from a pool and must not be retained after write returns. With a nil
+// keyring write gets plain unchanged.
+func (k *Keyring) Seal(plain []byte, aad string, write func([]byte) error) error {
+	if k == nil {
+		return write(plain)
+	}
+	id := k.current.id
+	bp := sealPool.Get().(*[]byte)
+	out := slices.Grow((*bp)[:0], 2+len(id)+nonceSize+len(plain)+k.current.aead.Overhead())
+	out = append(out, version, byte(len(id)))
+	out = append(out, id...)
+	nonce := out[len(out) : len(out)+nonceSize]
+	if _, err := rand.Read(nonce); err != nil {
+		sealPool.Put(bp)
+		return err
+	}
+	out = k.current.aead.Seal(out[:len(out)+nonceSize], nonce, plain, []byte(aad))
+	k.counts.sealed.Add(1)
+
+	err := write(out)
+	if cap(out) <= maxPooled {
+		*bp = out[:0]
+		sealPool.Put(bp)
+	}
+	return err
+}
+
+// Reseal is Seal for an entry that Open reported as stale; it is counted
+// separately so the admin stats show rotation progress.
+func (k *Keyring) Reseal(plain []byte, aad string, write func([]byte) error) error {
+	if err := k.Seal(plain, aad, write); err != nil {
+		return err
+	}
+	k.counts.resealed.Add(1)
+	return nil
+}
+
+// Open decrypts data in place and returns the plaintext, which shares
+// data's memory. stale reports that the entry was sealed with the previous
+// key and should be rewritten with Reseal. Failures are counted. With a nil
+// keyring data is returned unchanged.
+func (k *Keyring) Open(data []byte, aad string) (plain []byte, stale bool, err error) {
+	if k == nil {
+		return data, false, nil
+	}
+	plain, stale, err = k.open(data, aad)
+	if err != nil {
+		k.counts.failures.Add(1)
+		return nil, false, err
+	}
+	k.counts.opened.Add(1)
+	return plain, stale, nil
+}
+
+func (k *Keyring) open(data []byte, aad string) ([]byte, bool, error) {
+	if len(data) < 2 || data[0] != version {
+		return nil, false, ErrMalformed
+	}
+	n := int(data[1])
+	if len(data) < 2+n+nonceSize {
+		return nil, false, ErrMalformed
+	}
+	id := data[2 : 2+n]
+
+	var aead cipher.AEAD
+	stale := false
+	switch {
+	case string(id) == k.current.id:
+		aead = k.current.aead
+	case k.previous != nil && string(id) == k.previous.id && k.now().Before(k.graceEnd):
+		aead = k.previous.aead
+		stale = true
+	default:
+		return nil, false, ErrUnknownKey
+	}
+
+	nonce := data[2+n : 2+n+nonceSize]
+	ciphertext := data[2+n+nonceSize:]
+	plain, err := aead.Open(ciphertext[:0], nonce, ciphertext, []byte(aad))
+	if err != nil {
+		return nil, false, err
+	}
+	return plain, stale, nil
+}
+
+// KeyID returns the ID of the current key.
+func (k *Keyring) KeyID() string {
+	return k.current.id
+}
+
+// Stats returns the key IDs and counters for the admin API.
+func (k *Keyring) Stats() map[string]any {
+	if k == nil {
+		return map[string]any{"enabled": false}
+	}
+	stats := map[string]any{
+		"enabled":          true,
+		"key_id":           k.current.id,
+		"key_loaded_at":    k.since,
+		"sealed":           k.counts.sealed.Load(),
+		"opened":           k.counts.opened.Load(),
+		"resealed":         k.counts.resealed.Load(),
+		"decrypt_failures": k.counts.failures.Load(),
+	}
+	if k.previous != nil {
+		stats["previous_key_id"] = k.previous.id
+		stats["previous_key_readable_until"] = k.graceEnd
+	}
+	return stats
+}
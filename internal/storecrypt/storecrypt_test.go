@@ -0,0 +1,146 @@
+package storecrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+// seal returns a copy of plain sealed by k under aad.
+func seal(t *testing.T, k *Keyring, plain, aad string) []byte {
+	t.Helper()
+	var out []byte
+	if err := k.Seal([]byte(plain), aad, func(b []byte) error {
+		out = append([]byte(nil), b...)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestKeyring_SealOpen(t *testing.T) {
+	k, err := New(config.StorageEncryptionConfig{KeyID: "k1", KeyBase64: testKey(1)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := seal(t, k, "card=4111", "gw:idem:r:abc")
+	if bytes.Contains(sealed, []byte("4111")) {
+		t.Fatal("expected the plaintext not to appear in the sealed value")
+	}
+	if sealed2 := seal(t, k, "card=4111", "gw:idem:r:abc"); bytes.Equal(sealed, sealed2) {
+		t.Error("expected a fresh nonce per seal")
+	}
+
+	plain, stale, err := k.Open(sealed, "gw:idem:r:abc")
+	if err != nil || stale || string(plain) != "card=4111" {
+		t.Fatalf("Open = %q, %v, %v", plain, stale, err)
+	}
+}
+
+func TestKeyring_Tampered(t *testing.T) {
+	k, _ := New(config.StorageEncryptionConfig{KeyID: "k1", KeyBase64: testKey(1)}, nil)
+	sealed := seal(t, k, "body", "gw:cache:r:a")
+
+	flipped := append([]byte(nil), sealed...)
+	flipped[len(flipped)-1] ^= 0x01
+	if _, _, err := k.Open(flipped, "gw:cache:r:a"); err == nil {
+		t.Error("expected a flipped ciphertext bit to fail")
+	}
+	if _, _, err := k.Open(append([]byte(nil), sealed...), "gw:cache:r:b"); err == nil {
+		t.Error("expected an entry moved to another key to fail")
+	}
+	if _, _, err := k.Open([]byte("plain gob"), "gw:cache:r:a"); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed for an unsealed value, got %v", err)
+	}
+	if _, _, err := k.Open(sealed[:5], "gw:cache:r:a"); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed for a truncated value, got %v", err)
+	}
+	if got := k.Stats()["decrypt_failures"]; got != int64(4) {
+		t.Errorf("expected 4 decrypt failures, got %v", got)
+	}
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	old, _ := New(config.StorageEncryptionConfig{KeyID: "k1", KeyBase64: testKey(1)}, nil)
+	sealed := seal(t, old, "body", "key")
+
+	rotated, err := New(config.StorageEncryptionConfig{
+		KeyID: "k2", KeyBase64: testKey(2),
+		PreviousKeyID: "k1", PreviousKeyBase64: testKey(1),
+		PreviousKeyGrace: time.Hour,
+	}, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, stale, err := rotated.Open(append([]byte(nil), sealed...), "key")
+	if err != nil || !stale || string(plain) != "body" {
+		t.Fatalf("expected the previous key to open a stale entry, got %q, %v, %v", plain, stale, err)
+	}
+
+	var resealed []byte
+	rotated.Reseal(plain, "key", func(b []byte) error {
+		resealed = append([]byte(nil), b...)
+		return nil
+	})
+	if _, stale, err := rotated.Open(resealed, "key"); err != nil || stale {
+		t.Errorf("expected the resealed entry under the current key, got stale=%v err=%v", stale, err)
+	}
+	if _, _, err := old.Open(seal(t, rotated, "body", "key"), "key"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected the old keyring not to know k2, got %v", err)
+	}
+
+	// Past the grace window the previous key no longer opens entries.
+	rotated.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, _, err := rotated.Open(append([]byte(nil), sealed...), "key"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey after the grace window, got %v", err)
+	}
+
+	stats := rotated.Stats()
+	if stats["resealed"] != int64(1) || stats["previous_key_id"] != "k1" {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
+
+func TestKeyring_GraceSurvivesReload(t *testing.T) {
+	cfg := config.StorageEncryptionConfig{
+		KeyID: "k2", KeyBase64: testKey(2),
+		PreviousKeyID: "k1", PreviousKeyBase64: testKey(1),
+	}
+	first, _ := New(cfg, nil)
+	first.since = first.since.Add(-23 * time.Hour)
+	first.graceEnd = first.since.Add(DefaultGrace)
+
+	reloaded, _ := New(cfg, first)
+	if !reloaded.graceEnd.Equal(first.graceEnd) {
+		t.Errorf("expected the grace window to keep counting, got %v want %v", reloaded.graceEnd, first.graceEnd)
+	}
+
+	cfg.KeyID, cfg.KeyBase64 = "k3", testKey(3)
+	cfg.PreviousKeyID, cfg.PreviousKeyBase64 = "k2", testKey(2)
+	next, _ := New(cfg, reloaded)
+	if !next.graceEnd.After(first.graceEnd) {
+		t.Error("expected a new current key to start a new grace window")
+	}
+}
+
+func TestKeyring_Nil(t *testing.T) {
+	k, err := New(config.StorageEncryptionConfig{}, nil)
+	if err != nil || k != nil {
+		t.Fatalf("expected no keyring without a key, got %v, %v", k, err)
+	}
+	var got []byte
+	k.Seal([]byte("plain"), "key", func(b []byte) error { got = b; return nil })
+	plain, stale, err := k.Open(got, "key")
+	if err != nil || stale || string(plain) != "plain" {
+		t.Errorf("expected values to pass through, got %q, %v, %v", plain, stale, err)
+	}
+}
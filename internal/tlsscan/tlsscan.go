@@ -0,0 +1,351 @@
+// Package tlsscan periodically connects to https backends and records their
+// certificate chains so upcoming expiries are reported before they break
+// traffic.
+package tlsscan
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Defaults applied by New when a Config field is zero.
+const (
+	DefaultInterval        = 24 * time.Hour
+	DefaultTimeout         = 10 * time.Second
+	DefaultExpiryThreshold = 30 * 24 * time.Hour
+	DefaultRate            = 1.0
+)
+
+// Target is a unique https backend to scan.
+type Target struct {
+	Address    string   // host:port
+	ServerName string   // SNI and hostname to verify
+	Upstream   string   // named upstream whose transport is used, "" for the default
+	Routes     []string // routes that proxy to the backend
+	Healthy    bool     // unhealthy backends are skipped
+	Dial       func(ctx context.Context, network, addr string) (net.Conn, error)
+	TLSConfig  *tls.Config
+}
+
+// CertInfo describes one certificate of a backend's chain.
+type CertInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	DNSNames     []string  `json:"dns_names,omitempty"`
+	IPAddresses  []string  `json:"ip_addresses,omitempty"`
+	IsCA         bool      `json:"is_ca"`
+	Fingerprint  string    `json:"fingerprint_sha256"`
+}
+
+// Result statuses.
+const (
+	StatusOK      = "ok"
+	StatusError   = "error"
+	StatusSkipped = "skipped_unhealthy"
+)
+
+// Result is the outcome of scanning one backend.
+type Result struct {
+	Address          string     `json:"address"`
+	ServerName       string     `json:"server_name"`
+	Upstream         string     `json:"upstream,omitempty"`
+	Routes           []string   `json:"routes"`
+	Status           string     `json:"status"`
+	Error            string     `json:"error,omitempty"`
+	HostnameVerified bool       `json:"hostname_verified"`
+	VerifyError      string     `json:"verify_error,omitempty"`
+	NotAfter         time.Time  `json:"not_after,omitempty"`
+	DaysLeft         int        `json:"days_left"`
+	Expiring         bool       `json:"expiring"`
+	Chain            []CertInfo `json:"chain,omitempty"`
+	ScannedAt        time.Time  `json:"scanned_at"`
+}
+
+// Config holds scanner configuration.
+type Config struct {
+	Interval        time.Duration
+	Timeout         time.Duration
+	ExpiryThreshold time.Duration
+	Rate            float64 // backend connections per second
+
+	// Targets returns the backends to scan; it is called at the start of
+	// every scan so discovered instances are picked up.
+	Targets func() []Target
+	// OnExpiring is called once per scan for each backend whose earliest
+	// expiring certificate is within ExpiryThreshold.
+	OnExpiring func(Result)
+}
+
+// Scanner scans backend certificates in the background and caches the
+// results, so readers never trigger a connection.
+type Scanner struct {
+	cfg     Config
+	limiter *rate.Limiter
+
+	mu       sync.RWMutex
+	results  []Result
+	lastScan time.Time
+	scans    int64
+	started  bool
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// New creates a scanner. Call Start to begin scanning.
+func New(cfg Config) *Scanner {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.ExpiryThreshold <= 0 {
+		cfg.ExpiryThreshold = DefaultExpiryThreshold
+	}
+	if cfg.Rate <= 0 {
+		cfg.Rate = DefaultRate
+	}
+	return &Scanner{
+		cfg:     cfg,
+		limiter: rate.NewLimiter(rate.Limit(cfg.Rate), 1),
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+}
+
+// Start runs a scan immediately and then every Interval until Stop.
+func (s *Scanner) Start() {
+	s.mu.Lock()
+	s.started = true
+	s.mu.Unlock()
+	go s.loop()
+}
+
+func (s *Scanner) loop() {
+	defer close(s.doneCh)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	s.Scan(ctx)
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Scan(ctx)
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// Stop cancels any scan in progress and stops the background loop.
+func (s *Scanner) Stop() {
+	select {
+	case <-s.stopCh:
+		return
+	default:
+		close(s.stopCh)
+	}
+	s.mu.RLock()
+	started := s.started
+	s.mu.RUnlock()
+	if started {
+		<-s.doneCh
+	}
+}
+
+// Scan connects to every target once, waiting on the rate limiter between
+// connections, and replaces the cached results.
+func (s *Scanner) Scan(ctx context.Context) {
+	var targets []Target
+	if s.cfg.Targets != nil {
+		targets = s.cfg.Targets()
+	}
+
+	results := make([]Result, 0, len(targets))
+	for _, t := range targets {
+		r := Result{
+			Address:    t.Address,
+			ServerName: t.ServerName,
+			Upstream:   t.Upstream,
+			Routes:     t.Routes,
+		}
+		if !t.Healthy {
+			r.Status = StatusSkipped
+			r.ScannedAt = time.Now()
+			results = append(results, r)
+			continue
+		}
+		if err := s.limiter.Wait(ctx); err != nil {
+			return // stopped; keep the previous results
+		}
+		s.scanTarget(ctx, t, &r)
+		if r.Expiring && s.cfg.OnExpiring != nil {
+			s.cfg.OnExpiring(r)
+		}
+		results = append(results, r)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Address != results[j].Address {
+			return results[i].Address < results[j].Address
+		}
+		return results[i].Upstream < results[j].Upstream
+	})
+
+	s.mu.Lock()
+	s.results = results
+	s.lastScan = time.Now()
+	s.scans++
+	s.mu.Unlock()
+}
+
+// scanTarget performs the TLS handshake and fills in r.
+func (s *Scanner) scanTarget(ctx context.Context, t Target, r *Result) {
+	r.ScannedAt = time.Now()
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	dial := t.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	conn, err := dial(ctx, "tcp", t.Address)
+	if err != nil {
+		r.Status = StatusError
+		r.Error = err.Error()
+		return
+	}
+	defer conn.Close()
+
+	tlsCfg := &tls.Config{}
+	if t.TLSConfig != nil {
+		tlsCfg = t.TLSConfig.Clone()
+	}
+	// Verification is done below so invalid chains are still recorded.
+	tlsCfg.InsecureSkipVerify = true
+	tlsCfg.ServerName = t.ServerName
+	tc := tls.Client(conn, tlsCfg)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		r.Status = StatusError
+		r.Error = err.Error()
+		return
+	}
+
+	certs := tc.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		r.Status = StatusError
+		r.Error = "backend presented no certificate"
+		return
+	}
+	r.Status = StatusOK
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	_, verr := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       t.ServerName,
+		Roots:         tlsCfg.RootCAs,
+		Intermediates: intermediates,
+	})
+	r.HostnameVerified = verr == nil
+	if verr != nil {
+		r.VerifyError = verr.Error()
+	}
+
+	for i, c := range certs {
+		r.Chain = append(r.Chain, certInfo(c))
+		if i == 0 || c.NotAfter.Before(r.NotAfter) {
+			r.NotAfter = c.NotAfter
+		}
+	}
+	remaining := time.Until(r.NotAfter)
+	r.DaysLeft = int(remaining.Hours() / 24)
+	r.Expiring = remaining <= s.cfg.ExpiryThreshold
+}
+
+func certInfo(c *x509.Certificate) CertInfo {
+	info := CertInfo{
+		Subject:      c.Subject.String(),
+		Issuer:       c.Issuer.String(),
+		SerialNumber: c.SerialNumber.String(),
+		NotBefore:    c.NotBefore,
+		NotAfter:     c.NotAfter,
+		DNSNames:     c.DNSNames,
+		IsCA:         c.IsCA,
+	}
+	for _, ip := range c.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	sum := sha256.Sum256(c.Raw)
+	info.Fingerprint = hex.EncodeToString(sum[:])
+	return info
+}
+
+// Results returns the latest scan results. A positive expiringWithin keeps
+// only backends whose chain expires within that window.
+func (s *Scanner) Results(expiringWithin time.Duration) []Result {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Result, 0, len(s.results))
+	for _, r := range s.results {
+		if expiringWithin > 0 && (r.Status != StatusOK || time.Until(r.NotAfter) > expiringWithin) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// Stats returns scanner status for the admin API.
+func (s *Scanner) Stats() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var expiring, failed, skipped int
+	for _, r := range s.results {
+		switch {
+		case r.Status == StatusError:
+			failed++
+		case r.Status == StatusSkipped:
+			skipped++
+		case r.Expiring:
+			expiring++
+		}
+	}
+	stats := map[string]interface{}{
+		"interval":         s.cfg.Interval.String(),
+		"expiry_threshold": s.cfg.ExpiryThreshold.String(),
+		"scans":            s.scans,
+		"backends":         len(s.results),
+		"expiring":         expiring,
+		"failed":           failed,
+		"skipped":          skipped,
+	}
+	if !s.lastScan.IsZero() {
+		stats["last_scan"] = s.lastScan
+		stats["next_scan"] = s.lastScan.Add(s.cfg.Interval)
+	}
+	return stats
+}
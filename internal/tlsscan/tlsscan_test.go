@@ -0,0 +1,152 @@
+package tlsscan
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTLSBackend(t *testing.T) (*httptest.Server, *x509.CertPool) {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(srv.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return srv, pool
+}
+
+func TestScan_RecordsChainAndVerifiesHostname(t *testing.T) {
+	srv, pool := newTLSBackend(t)
+	addr := srv.Listener.Addr().String()
+
+	var expiring []Result
+	s := New(Config{
+		ExpiryThreshold: 100 * 365 * 24 * time.Hour, // everything is "expiring"
+		Rate:            100,
+		Targets: func() []Target {
+			return []Target{
+				{Address: addr, ServerName: "127.0.0.1", Routes: []string{"api"}, Healthy: true, TLSConfig: &tls.Config{RootCAs: pool}},
+				{Address: addr, ServerName: "wrong.example", Upstream: "pool", Healthy: true, TLSConfig: &tls.Config{RootCAs: pool}},
+				{Address: "10.255.255.1:443", ServerName: "down.example", Healthy: false},
+			}
+		},
+		OnExpiring: func(r Result) { expiring = append(expiring, r) },
+	})
+	s.Scan(context.Background())
+
+	results := s.Results(0)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	byName := make(map[string]Result)
+	for _, r := range results {
+		byName[r.ServerName] = r
+	}
+
+	ok := byName["127.0.0.1"]
+	if ok.Status != StatusOK || !ok.HostnameVerified {
+		t.Fatalf("expected verified chain, got %+v", ok)
+	}
+	if len(ok.Chain) == 0 || !ok.NotAfter.Equal(srv.Certificate().NotAfter) {
+		t.Errorf("expected leaf notAfter %v, got %v (chain %d)", srv.Certificate().NotAfter, ok.NotAfter, len(ok.Chain))
+	}
+	if len(ok.Chain[0].IPAddresses) == 0 || ok.Chain[0].Fingerprint == "" {
+		t.Errorf("expected SANs and fingerprint, got %+v", ok.Chain[0])
+	}
+
+	mismatch := byName["wrong.example"]
+	if mismatch.Status != StatusOK || mismatch.HostnameVerified || !strings.Contains(mismatch.VerifyError, "wrong.example") {
+		t.Errorf("expected hostname verification failure, got %+v", mismatch)
+	}
+
+	if byName["down.example"].Status != StatusSkipped {
+		t.Errorf("unhealthy backend should be skipped, got %+v", byName["down.example"])
+	}
+
+	if len(expiring) != 2 {
+		t.Errorf("expected 2 expiring callbacks, got %d", len(expiring))
+	}
+}
+
+func TestResults_ExpiringWithinFilter(t *testing.T) {
+	srv, _ := newTLSBackend(t)
+	s := New(Config{
+		Rate: 100,
+		Targets: func() []Target {
+			return []Target{{Address: srv.Listener.Addr().String(), ServerName: "127.0.0.1", Healthy: true}}
+		},
+	})
+	s.Scan(context.Background())
+
+	if got := s.Results(time.Hour); len(got) != 0 {
+		t.Errorf("test certificate should not expire within an hour, got %+v", got)
+	}
+	if got := s.Results(100 * 365 * 24 * time.Hour); len(got) != 1 {
+		t.Errorf("expected the backend within a century, got %d results", len(got))
+	}
+	if r := s.Results(0)[0]; r.Expiring {
+		t.Errorf("default threshold should not flag the test certificate: %+v", r)
+	}
+}
+
+func TestScan_RateLimited(t *testing.T) {
+	srv, _ := newTLSBackend(t)
+	addr := srv.Listener.Addr().String()
+	s := New(Config{
+		Rate: 10,
+		Targets: func() []Target {
+			return []Target{
+				{Address: addr, ServerName: "a", Healthy: true},
+				{Address: addr, ServerName: "b", Healthy: true},
+				{Address: addr, ServerName: "c", Healthy: true},
+			}
+		},
+	})
+	start := time.Now()
+	s.Scan(context.Background())
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("3 connections at 10/s should take at least 200ms, took %v", elapsed)
+	}
+}
+
+func TestScan_ErrorRecorded(t *testing.T) {
+	// A plain HTTP server fails the TLS handshake.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	s := New(Config{
+		Timeout: time.Second,
+		Rate:    100,
+		Targets: func() []Target {
+			return []Target{{Address: srv.Listener.Addr().String(), ServerName: "127.0.0.1", Healthy: true}}
+		},
+	})
+	s.Scan(context.Background())
+	r := s.Results(0)[0]
+	if r.Status != StatusError || r.Error == "" {
+		t.Errorf("expected handshake error, got %+v", r)
+	}
+	if s.Stats()["failed"] != 1 {
+		t.Errorf("expected 1 failed backend in stats, got %v", s.Stats())
+	}
+}
+
+func TestStopWithoutStart(t *testing.T) {
+	s := New(Config{})
+	done := make(chan struct{})
+	go func() {
+		s.Stop()
+		s.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked on a scanner that was never started")
+	}
+}
@@ -2,56 +2,83 @@ package trafficshape
 
 import (
 	"context"
+	"fmt"
 	"math/rand"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/clock"
 	"github.com/wudi/runway/internal/middleware"
+	"github.com/wudi/runway/internal/middleware/consumergroup"
+	"github.com/wudi/runway/internal/randutil"
+	"github.com/wudi/runway/variables"
 )
 
 // FaultInjector injects delays and/or aborts into request processing for chaos testing.
 type FaultInjector struct {
+	cfg           config.FaultInjectionConfig
 	delayPct      int
 	delayDuration time.Duration
 	abortPct      int
 	abortStatus   int
+	target        *faultTarget // nil: all requests
+	ramp          config.FaultRampConfig
+	clock         clock.Clock
+	startedAt     atomic.Int64 // unix nanos; start of the ramp
+	stopped       atomic.Bool
 
 	rng *rand.Rand
 	mu  sync.Mutex
 
-	totalRequests atomic.Int64
-	totalDelayed  atomic.Int64
-	totalAborted  atomic.Int64
-	totalDelayNs  atomic.Int64
+	totalRequests   atomic.Int64
+	totalUntargeted atomic.Int64
+	totalDelayed    atomic.Int64
+	totalAborted    atomic.Int64
+	totalDelayNs    atomic.Int64
 }
 
 // NewFaultInjector creates a new FaultInjector from config.
 func NewFaultInjector(cfg config.FaultInjectionConfig) *FaultInjector {
-	return &FaultInjector{
+	fi := &FaultInjector{
+		cfg:           cfg,
 		delayPct:      cfg.Delay.Percentage,
 		delayDuration: cfg.Delay.Duration,
 		abortPct:      cfg.Abort.Percentage,
 		abortStatus:   cfg.Abort.StatusCode,
-		rng:           rand.New(rand.NewSource(time.Now().UnixNano())),
+		ramp:          cfg.Ramp,
+		clock:         clock.Default(),
+		rng:           randutil.New(),
 	}
+	if !cfg.Target.IsZero() {
+		fi.target = newFaultTarget(cfg.Target)
+	}
+	fi.startedAt.Store(fi.clock.Now().UnixNano())
+	return fi
 }
 
 // Apply evaluates fault injection for a request.
 // Returns (aborted, statusCode). If aborted is true, the caller should write statusCode and stop.
 func (fi *FaultInjector) Apply(ctx context.Context) (aborted bool, statusCode int) {
 	fi.totalRequests.Add(1)
+	if fi.stopped.Load() {
+		return false, 0
+	}
+	scale := fi.rampScale(fi.clock.Now())
 
 	// Roll abort first — aborted requests skip delay entirely
-	if fi.abortPct > 0 && fi.roll(fi.abortPct) {
+	if fi.abortPct > 0 && fi.roll(float64(fi.abortPct)*scale) {
 		fi.totalAborted.Add(1)
 		return true, fi.abortStatus
 	}
 
 	// Roll delay
-	if fi.delayPct > 0 && fi.roll(fi.delayPct) {
+	if fi.delayPct > 0 && fi.roll(float64(fi.delayPct)*scale) {
 		start := time.Now()
 		select {
 		case <-time.After(fi.delayDuration):
@@ -64,8 +91,32 @@ func (fi *FaultInjector) Apply(ctx context.Context) (aborted bool, statusCode in
 	return false, 0
 }
 
+// Targets reports whether r matches the injector's target selectors.
+// Requests not targeted are never faulted.
+func (fi *FaultInjector) Targets(r *http.Request) bool {
+	return fi.target == nil || fi.target.matches(r)
+}
+
+// rampScale returns the fraction of the configured percentages in effect
+// at now: 1 without a ramp, else linear from the ramp's start to its end
+// percentage over its duration, then held.
+func (fi *FaultInjector) rampScale(now time.Time) float64 {
+	if fi.ramp.Duration <= 0 {
+		return 1
+	}
+	elapsed := now.Sub(time.Unix(0, fi.startedAt.Load()))
+	if elapsed >= fi.ramp.Duration {
+		return fi.ramp.EndPercentage / 100
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	progress := float64(elapsed) / float64(fi.ramp.Duration)
+	return (fi.ramp.StartPercentage + (fi.ramp.EndPercentage-fi.ramp.StartPercentage)*progress) / 100
+}
+
 // roll returns true if a random percentage falls within the given threshold.
-func (fi *FaultInjector) roll(percentage int) bool {
+func (fi *FaultInjector) roll(percentage float64) bool {
 	if percentage >= 100 {
 		return true
 	}
@@ -73,25 +124,66 @@ func (fi *FaultInjector) roll(percentage int) bool {
 		return false
 	}
 	fi.mu.Lock()
-	n := fi.rng.Intn(100)
+	n := fi.rng.Float64() * 100
 	fi.mu.Unlock()
 	return n < percentage
 }
 
+// Stop disables injection until the route's fault injection config changes.
+// It reports whether the injector was running.
+func (fi *FaultInjector) Stop() bool {
+	return !fi.stopped.Swap(true)
+}
+
+// carry takes over the ramp start and stop state of the injector it
+// replaces on a reload, if their configs are equal.
+func (fi *FaultInjector) carry(old *FaultInjector) {
+	if !reflect.DeepEqual(fi.cfg, old.cfg) {
+		return
+	}
+	fi.startedAt.Store(old.startedAt.Load())
+	fi.stopped.Store(old.stopped.Load())
+}
+
 // Snapshot returns a point-in-time metrics snapshot.
 func (fi *FaultInjector) Snapshot() FaultInjectionSnapshot {
-	return FaultInjectionSnapshot{
-		TotalRequests: fi.totalRequests.Load(),
-		TotalDelayed:  fi.totalDelayed.Load(),
-		TotalAborted:  fi.totalAborted.Load(),
-		TotalDelayNs:  fi.totalDelayNs.Load(),
+	now := fi.clock.Now()
+	scale := fi.rampScale(now)
+	snap := FaultInjectionSnapshot{
+		TotalRequests:   fi.totalRequests.Load(),
+		TotalUntargeted: fi.totalUntargeted.Load(),
+		TotalDelayed:    fi.totalDelayed.Load(),
+		TotalAborted:    fi.totalAborted.Load(),
+		TotalDelayNs:    fi.totalDelayNs.Load(),
+		DelayPercentage: float64(fi.delayPct) * scale,
+		AbortPercentage: float64(fi.abortPct) * scale,
+		Targeted:        fi.target != nil,
+		Stopped:         fi.stopped.Load(),
+	}
+	if snap.Stopped {
+		snap.DelayPercentage, snap.AbortPercentage = 0, 0
 	}
+	if fi.ramp.Duration > 0 {
+		started := time.Unix(0, fi.startedAt.Load())
+		progress := min(float64(now.Sub(started))/float64(fi.ramp.Duration), 1)
+		snap.Ramp = &FaultRampSnapshot{
+			StartedAt:  started,
+			Progress:   max(progress, 0),
+			Percentage: scale * 100,
+		}
+	}
+	return snap
 }
 
 // Middleware returns a middleware that injects delays and/or aborts for chaos testing.
 func (fi *FaultInjector) Middleware() middleware.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !fi.Targets(r) {
+				fi.totalUntargeted.Add(1)
+				next.ServeHTTP(w, r)
+				return
+			}
 			aborted, statusCode := fi.Apply(r.Context())
 			if aborted {
 				w.WriteHeader(statusCode)
@@ -101,3 +193,142 @@ func (fi *FaultInjector) Middleware() middleware.Middleware {
 		})
 	}
 }
+
+// faultTarget is a compiled config.FaultTargetConfig.
+type faultTarget struct {
+	tenants map[string]bool
+	groups  map[string]bool
+	match   []faultMatch
+}
+
+type faultMatch struct {
+	values  map[string]bool
+	extract func(*http.Request) []string
+}
+
+func newFaultTarget(cfg config.FaultTargetConfig) *faultTarget {
+	t := &faultTarget{}
+	if len(cfg.Tenants) > 0 {
+		t.tenants = setOf(cfg.Tenants)
+	}
+	if len(cfg.ConsumerGroups) > 0 {
+		t.groups = setOf(cfg.ConsumerGroups)
+	}
+	for _, m := range cfg.Match {
+		t.match = append(t.match, faultMatch{values: setOf(m.Values), extract: targetValues(m.Key)})
+	}
+	return t
+}
+
+// matches reports whether every selector of t matches r. Tenant and
+// consumer group selectors need the tenant and consumer_group middleware
+// to have run, which precede fault injection in the route chain.
+func (t *faultTarget) matches(r *http.Request) bool {
+	if t.tenants != nil && !t.tenants[variables.GetFromRequest(r).TenantID] {
+		return false
+	}
+	if t.groups != nil {
+		info := consumergroup.FromContext(r.Context())
+		if info == nil || !t.groups[info.Name] {
+			return false
+		}
+	}
+	for _, m := range t.match {
+		if !m.matches(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m faultMatch) matches(r *http.Request) bool {
+	for _, v := range m.extract(r) {
+		if m.values[v] {
+			return true
+		}
+	}
+	return false
+}
+
+func setOf(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// targetValues returns an extractor for a rate_limit.key style key. Unlike
+// rate limit keys, absent values do not fall back to the client IP. JWT
+// claims holding arrays yield each element.
+func targetValues(key string) func(*http.Request) []string {
+	one := func(v string) []string {
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	}
+	switch {
+	case key == "ip":
+		return func(r *http.Request) []string { return one(variables.ExtractClientIP(r)) }
+	case key == "client_id":
+		return func(r *http.Request) []string {
+			if id := variables.GetFromRequest(r).Identity; id != nil {
+				return one(id.ClientID)
+			}
+			return nil
+		}
+	case strings.HasPrefix(key, "header:"):
+		name := key[len("header:"):]
+		return func(r *http.Request) []string { return r.Header.Values(name) }
+	case strings.HasPrefix(key, "cookie:"):
+		name := key[len("cookie:"):]
+		return func(r *http.Request) []string {
+			if c, err := r.Cookie(name); err == nil {
+				return one(c.Value)
+			}
+			return nil
+		}
+	case strings.HasPrefix(key, "jwt_claim:"):
+		claim := key[len("jwt_claim:"):]
+		return func(r *http.Request) []string {
+			id := variables.GetFromRequest(r).Identity
+			if id == nil || id.Claims == nil {
+				return nil
+			}
+			val, ok := id.Claims[claim]
+			if !ok {
+				return nil
+			}
+			if list, ok := val.([]interface{}); ok {
+				out := make([]string, 0, len(list))
+				for _, v := range list {
+					out = append(out, claimString(v))
+				}
+				return out
+			}
+			return one(claimString(val))
+		}
+	case strings.HasPrefix(key, "baggage:"):
+		name := key[len("baggage:"):]
+		return func(r *http.Request) []string { return one(variables.BaggageValue(r, name)) }
+	case strings.HasPrefix(key, "body:"):
+		path := key[len("body:"):]
+		return func(r *http.Request) []string { return one(variables.BodyField(r, path).String()) }
+	default:
+		return func(*http.Request) []string { return nil }
+	}
+}
+
+func claimString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
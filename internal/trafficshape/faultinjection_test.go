@@ -2,10 +2,15 @@ package trafficshape
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/clock"
+	"github.com/wudi/runway/internal/middleware/consumergroup"
+	"github.com/wudi/runway/variables"
 )
 
 func TestFaultInjector_FullAbort(t *testing.T) {
@@ -192,3 +197,138 @@ func TestMergeFaultInjectionConfig(t *testing.T) {
 		t.Errorf("expected global delay pct 20, got %d", merged.Delay.Percentage)
 	}
 }
+
+func TestFaultInjector_Target(t *testing.T) {
+	fi := NewFaultInjector(config.FaultInjectionConfig{
+		Enabled: true,
+		Abort:   config.FaultAbortConfig{Percentage: 100, StatusCode: 503},
+		Target: config.FaultTargetConfig{
+			Tenants:        []string{"synthetic"},
+			ConsumerGroups: []string{"chaos"},
+			Match:          []config.FaultTargetMatch{{Key: "jwt_claim:env", Values: []string{"gameday"}}},
+		},
+	})
+	handler := fi.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(tenant, group string, claims map[string]interface{}) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		varCtx := variables.NewContext(r)
+		varCtx.TenantID = tenant
+		varCtx.Identity = &variables.Identity{ClientID: "c", Claims: claims}
+		ctx := context.WithValue(r.Context(), variables.RequestContextKey{}, varCtx)
+		if group != "" {
+			ctx = consumergroup.WithGroup(ctx, &consumergroup.GroupInfo{Name: group})
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r.WithContext(ctx))
+		return w.Code
+	}
+	gameday := map[string]interface{}{"env": []interface{}{"staging", "gameday"}}
+
+	// Requests missing any selector are never faulted.
+	for i := 0; i < 1000; i++ {
+		for _, c := range []struct {
+			tenant, group string
+			claims        map[string]interface{}
+		}{
+			{"acme", "chaos", gameday},
+			{"", "chaos", gameday},
+			{"synthetic", "premium", gameday},
+			{"synthetic", "", gameday},
+			{"synthetic", "chaos", map[string]interface{}{"env": "prod"}},
+			{"synthetic", "chaos", nil},
+		} {
+			if code := do(c.tenant, c.group, c.claims); code != http.StatusOK {
+				t.Fatalf("%+v: expected untargeted request to pass, got %d", c, code)
+			}
+		}
+	}
+	if code := do("synthetic", "chaos", gameday); code != 503 {
+		t.Errorf("expected the targeted request aborted, got %d", code)
+	}
+
+	snap := fi.Snapshot()
+	if snap.TotalUntargeted != 6000 || snap.TotalRequests != 1 || snap.TotalAborted != 1 || !snap.Targeted {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+}
+
+func TestFaultInjector_Ramp(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1_000_000, 0))
+	clock.SetDefault(clk)
+	defer clock.SetDefault(clock.Real{})
+
+	fi := NewFaultInjector(config.FaultInjectionConfig{
+		Enabled: true,
+		Abort:   config.FaultAbortConfig{Percentage: 50, StatusCode: 503},
+		Delay:   config.FaultDelayConfig{Percentage: 20, Duration: time.Millisecond},
+		Ramp:    config.FaultRampConfig{StartPercentage: 0, EndPercentage: 100, Duration: 10 * time.Minute},
+	})
+
+	for _, c := range []struct {
+		at           time.Duration
+		abort, delay float64
+	}{
+		{0, 0, 0},
+		{5 * time.Minute, 25, 10},
+		{10 * time.Minute, 50, 20},
+		{time.Hour, 50, 20}, // holds after the window
+	} {
+		clk.Set(time.Unix(1_000_000, 0).Add(c.at))
+		snap := fi.Snapshot()
+		if snap.AbortPercentage != c.abort || snap.DelayPercentage != c.delay {
+			t.Errorf("at %v: expected abort %v%% delay %v%%, got %v%% %v%%", c.at, c.abort, c.delay, snap.AbortPercentage, snap.DelayPercentage)
+		}
+	}
+
+	// At the start of the ramp nothing is injected.
+	clk.Set(time.Unix(1_000_000, 0))
+	for i := 0; i < 100; i++ {
+		if aborted, _ := fi.Apply(context.Background()); aborted {
+			t.Fatal("expected no abort at 0% of the ramp")
+		}
+	}
+	if snap := fi.Snapshot(); snap.TotalDelayed != 0 || snap.Ramp == nil || snap.Ramp.Progress != 0 {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+}
+
+func TestFaultInjector_StopAndCarry(t *testing.T) {
+	cfg := config.FaultInjectionConfig{
+		Enabled: true,
+		Abort:   config.FaultAbortConfig{Percentage: 100, StatusCode: 503},
+		Ramp:    config.FaultRampConfig{StartPercentage: 100, EndPercentage: 100, Duration: time.Minute},
+	}
+	m := NewFaultInjectionByRoute()
+	m.AddRoute("a", cfg)
+	m.AddRoute("b", cfg)
+
+	if stopped := StopFaultInjection(m); len(stopped) != 2 || stopped[0] != "a" {
+		t.Fatalf("expected both routes stopped, got %v", stopped)
+	}
+	if stopped := StopFaultInjection(m); len(stopped) != 0 {
+		t.Errorf("expected nothing left to stop, got %v", stopped)
+	}
+	if aborted, _ := m.Lookup("a").Apply(context.Background()); aborted {
+		t.Error("expected a stopped injector not to abort")
+	}
+	if snap := m.Lookup("a").Snapshot(); !snap.Stopped || snap.AbortPercentage != 0 {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+
+	// A reload keeps unchanged routes stopped and re-arms changed ones.
+	changed := cfg
+	changed.Abort.StatusCode = 500
+	next := NewFaultInjectionByRoute()
+	next.AddRoute("a", cfg)
+	next.AddRoute("b", changed)
+	CarryFaultInjectionState(m, next)
+	if !next.Lookup("a").Snapshot().Stopped {
+		t.Error("expected the unchanged route to stay stopped")
+	}
+	if next.Lookup("b").Snapshot().Stopped {
+		t.Error("expected the changed route re-armed")
+	}
+	if next.Lookup("a").startedAt.Load() != m.Lookup("a").startedAt.Load() {
+		t.Error("expected the unchanged route to keep its ramp start")
+	}
+}
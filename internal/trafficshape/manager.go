@@ -1,6 +1,7 @@
 package trafficshape
 
 import (
+	"sort"
 	"time"
 
 	"github.com/wudi/runway/internal/byroute"
@@ -74,6 +75,31 @@ func NewFaultInjectionByRoute() *FaultInjectionByRoute {
 	return byroute.SimpleFactory(NewFaultInjector, func(fi *FaultInjector) any { return fi.Snapshot() })
 }
 
+// StopFaultInjection stops every fault injector of m and returns the IDs of
+// the routes that were injecting faults.
+func StopFaultInjection(m *FaultInjectionByRoute) []string {
+	var stopped []string
+	m.Range(func(id string, fi *FaultInjector) bool {
+		if fi.Stop() {
+			stopped = append(stopped, id)
+		}
+		return true
+	})
+	sort.Strings(stopped)
+	return stopped
+}
+
+// CarryFaultInjectionState hands the ramp progress and stop state of old's
+// injectors to the injectors of next whose route config is unchanged.
+func CarryFaultInjectionState(old, next *FaultInjectionByRoute) {
+	next.Range(func(id string, fi *FaultInjector) bool {
+		if prev, ok := old.Get(id); ok {
+			fi.carry(prev)
+		}
+		return true
+	})
+}
+
 // MergeFaultInjectionConfig merges a route-level fault injection config with the global config as fallback.
 func MergeFaultInjectionConfig(route, global config.FaultInjectionConfig) config.FaultInjectionConfig {
 	if route.Delay.Percentage == 0 && global.Delay.Percentage > 0 {
@@ -82,5 +108,11 @@ func MergeFaultInjectionConfig(route, global config.FaultInjectionConfig) config
 	if route.Abort.Percentage == 0 && global.Abort.Percentage > 0 {
 		route.Abort = global.Abort
 	}
+	if route.Target.IsZero() {
+		route.Target = global.Target
+	}
+	if route.Ramp.Duration == 0 {
+		route.Ramp = global.Ramp
+	}
 	return route
 }
@@ -1,5 +1,7 @@
 package trafficshape
 
+import "time"
+
 // ThrottleSnapshot contains point-in-time throttle metrics.
 type ThrottleSnapshot struct {
 	TotalRequests  int64   `json:"total_requests"`
@@ -27,10 +29,23 @@ type PrioritySnapshot struct {
 
 // FaultInjectionSnapshot contains point-in-time fault injection metrics.
 type FaultInjectionSnapshot struct {
-	TotalRequests int64 `json:"total_requests"`
-	TotalDelayed  int64 `json:"total_delayed"`
-	TotalAborted  int64 `json:"total_aborted"`
-	TotalDelayNs  int64 `json:"total_delay_ns"`
+	TotalRequests   int64              `json:"total_requests"`   // targeted requests
+	TotalUntargeted int64              `json:"total_untargeted"` // requests not matching the target
+	TotalDelayed    int64              `json:"total_delayed"`
+	TotalAborted    int64              `json:"total_aborted"`
+	TotalDelayNs    int64              `json:"total_delay_ns"`
+	DelayPercentage float64            `json:"delay_percentage"` // currently effective
+	AbortPercentage float64            `json:"abort_percentage"` // currently effective
+	Targeted        bool               `json:"targeted"`
+	Stopped         bool               `json:"stopped"`
+	Ramp            *FaultRampSnapshot `json:"ramp,omitempty"`
+}
+
+// FaultRampSnapshot contains the state of a fault injection ramp.
+type FaultRampSnapshot struct {
+	StartedAt  time.Time `json:"started_at"`
+	Progress   float64   `json:"progress"`   // 0-1
+	Percentage float64   `json:"percentage"` // of the configured percentages
 }
 
 // AdaptiveConcurrencySnapshot contains point-in-time adaptive concurrency metrics.
@@ -104,10 +104,11 @@ func (pa *PriorityAdmitter) Snapshot() PrioritySnapshot {
 
 // DetermineLevel checks configured priority levels and returns the first matching
 // level, or the default level if nothing matches.
-// If tenantPriority > 0, it overrides all configured levels immediately.
-func DetermineLevel(r *http.Request, identity *variables.Identity, cfg config.PriorityConfig, tenantPriority int) int {
-	if tenantPriority > 0 {
-		return tenantPriority
+// If fixedPriority > 0 (a tenant's or consumer group's priority), it
+// overrides all configured levels immediately.
+func DetermineLevel(r *http.Request, identity *variables.Identity, cfg config.PriorityConfig, fixedPriority int) int {
+	if fixedPriority > 0 {
+		return fixedPriority
 	}
 
 	defaultLevel := cfg.DefaultLevel
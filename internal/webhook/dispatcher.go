@@ -2,8 +2,10 @@ package webhook
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/wudi/runway/config"
@@ -22,6 +24,8 @@ type Dispatcher struct {
 	mu        sync.RWMutex
 	history   []Event
 	queueSize int
+
+	skipShadow atomic.Bool
 }
 
 // NewDispatcher creates a new webhook dispatcher and starts worker goroutines.
@@ -65,6 +69,8 @@ func NewDispatcher(cfg config.WebhooksConfig) *Dispatcher {
 		queueSize: queueSize,
 	}
 
+	d.SetSkipShadow(cfg.SkipShadow == nil || *cfg.SkipShadow)
+
 	for i := 0; i < workers; i++ {
 		d.wg.Add(1)
 		go d.worker()
@@ -76,6 +82,9 @@ func NewDispatcher(cfg config.WebhooksConfig) *Dispatcher {
 // Emit sends an event to the dispatch queue. Non-blocking: if the queue is full,
 // the event is dropped and the dropped counter incremented.
 func (d *Dispatcher) Emit(event *Event) {
+	if event.Shadow && d.skipShadow.Load() {
+		return
+	}
 	d.metrics.TotalEmitted.Add(1)
 	select {
 	case d.queue <- event:
@@ -91,6 +100,19 @@ func (d *Dispatcher) UpdateEndpoints(eps []config.WebhookEndpoint) {
 	d.endpoints = eps
 }
 
+// SetSkipShadow sets whether events caused by shadowed requests are dropped.
+func (d *Dispatcher) SetSkipShadow(skip bool) {
+	d.skipShadow.Store(skip)
+}
+
+// SetDialer makes deliveries connect through dial. It must be called
+// before the first event is emitted.
+func (d *Dispatcher) SetDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = dial
+	d.client.Transport = t
+}
+
 // Close cancels the dispatcher context and waits for all workers to drain.
 func (d *Dispatcher) Close() {
 	d.cancel()
@@ -252,6 +252,26 @@ func TestQueueFullDropsEvent(t *testing.T) {
 	}
 }
 
+func TestShadowEventsSkipped(t *testing.T) {
+	cfg := testConfig("http://localhost:1", []string{"*"})
+	d := NewDispatcher(cfg)
+	d.cancel()
+	d.wg.Wait()
+
+	ev := NewEvent(ABTestExposure, "r1", nil)
+	ev.Shadow = true
+	d.Emit(ev)
+	if got := d.metrics.TotalEmitted.Load(); got != 0 {
+		t.Errorf("expected shadow events dropped by default, got %d emitted", got)
+	}
+
+	d.SetSkipShadow(false)
+	d.Emit(ev)
+	if got := d.metrics.TotalEmitted.Load(); got != 1 {
+		t.Errorf("expected shadow events emitted with skip_shadow false, got %d", got)
+	}
+}
+
 func TestUpdateEndpoints(t *testing.T) {
 	var callCount atomic.Int32
 
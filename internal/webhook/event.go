@@ -11,6 +11,7 @@ type EventType string
 const (
 	BackendHealthy            EventType = "backend.healthy"
 	BackendUnhealthy          EventType = "backend.unhealthy"
+	BackendCertExpiring       EventType = "backend.cert_expiring"
 	CircuitBreakerStateChange EventType = "circuit_breaker.state_change"
 	CanaryStarted             EventType = "canary.started"
 	CanaryPaused              EventType = "canary.paused"
@@ -19,10 +20,32 @@ const (
 	CanaryRolledBack          EventType = "canary.rolled_back"
 	CanaryStepAdvanced        EventType = "canary.step_advanced"
 	CanaryCompleted           EventType = "canary.completed"
+	ABTestExposure            EventType = "ab_test.exposure"
 	ConfigReloadSuccess       EventType = "config.reload_success"
 	ConfigReloadFailure       EventType = "config.reload_failure"
+	ConfigDriftDetected       EventType = "config.drift.detected"
 	OutlierEjected            EventType = "outlier.ejected"
 	OutlierRecovered          EventType = "outlier.recovered"
+	DegradedModeEntered       EventType = "degraded_mode.entered"
+	DegradedModeExited        EventType = "degraded_mode.exited"
+	DependencyHealthy         EventType = "dependency.healthy"
+	DependencyUnhealthy       EventType = "dependency.unhealthy"
+	APIKeyCreated             EventType = "api_key.created"
+	APIKeyRotated             EventType = "api_key.rotated"
+	APIKeyRevoked             EventType = "api_key.revoked"
+	ReputationBlocked         EventType = "reputation.blocked"
+	UpstreamSwapped           EventType = "upstream.swapped"
+	UpstreamSwapFailed        EventType = "upstream.swap_failed"
+	UpstreamRolledBack        EventType = "upstream.rolled_back"
+	TransportCanaryRolledBack EventType = "transport_canary.rolled_back"
+	TransportCanaryPromoted   EventType = "transport_canary.promoted"
+	BreakGlassActivated       EventType = "break_glass.activated"
+	BreakGlassReverted        EventType = "break_glass.reverted"
+	RoutePaused               EventType = "route.paused"
+	RouteResumed              EventType = "route.resumed"
+	RoutePauseExpired         EventType = "route.pause_expired"
+	ListenerCertReloadFailed  EventType = "listener.cert_reload_failed"
+	SchemaDriftDigest         EventType = "schema_drift.digest"
 )
 
 // Event represents a webhook event payload.
@@ -31,6 +54,10 @@ type Event struct {
 	Timestamp time.Time              `json:"timestamp"`
 	RouteID   string                 `json:"route_id,omitempty"`
 	Data      map[string]interface{} `json:"data,omitempty"`
+
+	// Shadow marks events caused by a request shadowed from another
+	// route (see mirror shadow_route); not delivered.
+	Shadow bool `json:"-"`
 }
 
 // NewEvent creates a new Event with the current timestamp.
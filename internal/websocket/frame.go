@@ -0,0 +1,126 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// WebSocket opcodes (RFC 6455 section 5.2).
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+)
+
+// Close status codes sent when a client message violates route policy.
+const (
+	closePolicyViolation = 1008
+	closeMessageTooBig   = 1009
+)
+
+var errMalformedFrame = errors.New("malformed websocket frame")
+
+// frame is a WebSocket frame header as read off the wire. header holds the
+// exact bytes received so the frame can be forwarded unchanged.
+type frame struct {
+	fin     bool
+	opcode  byte
+	masked  bool
+	maskKey [4]byte
+	length  int64
+	header  []byte
+}
+
+// isControl reports whether f is a control frame (close, ping, pong).
+func (f *frame) isControl() bool {
+	return f.opcode&0x8 != 0
+}
+
+// readFrameHeader reads a frame header from r. The payload is left unread
+// so callers can check its length first.
+func readFrameHeader(r io.Reader) (*frame, error) {
+	var b [14]byte
+	if _, err := io.ReadFull(r, b[:2]); err != nil {
+		return nil, err
+	}
+	f := &frame{
+		fin:    b[0]&0x80 != 0,
+		opcode: b[0] & 0x0f,
+		masked: b[1]&0x80 != 0,
+	}
+	n := 2
+	switch l := b[1] & 0x7f; l {
+	case 126:
+		if _, err := io.ReadFull(r, b[n:n+2]); err != nil {
+			return nil, err
+		}
+		f.length = int64(binary.BigEndian.Uint16(b[n : n+2]))
+		n += 2
+	case 127:
+		if _, err := io.ReadFull(r, b[n:n+8]); err != nil {
+			return nil, err
+		}
+		u := binary.BigEndian.Uint64(b[n : n+8])
+		if u > 1<<62 {
+			return nil, errMalformedFrame
+		}
+		f.length = int64(u)
+		n += 8
+	default:
+		f.length = int64(l)
+	}
+	if f.isControl() && (f.length > 125 || !f.fin) {
+		return nil, errMalformedFrame
+	}
+	if f.masked {
+		if _, err := io.ReadFull(r, b[n:n+4]); err != nil {
+			return nil, err
+		}
+		copy(f.maskKey[:], b[n:n+4])
+		n += 4
+	}
+	f.header = append([]byte(nil), b[:n]...)
+	return f, nil
+}
+
+// unmask returns the unmasked form of payload.
+func (f *frame) unmask(payload []byte) []byte {
+	if !f.masked {
+		return payload
+	}
+	out := make([]byte, len(payload))
+	for i, c := range payload {
+		out[i] = c ^ f.maskKey[i%4]
+	}
+	return out
+}
+
+// closeFrame builds an unmasked server-to-client close frame.
+func closeFrame(code int, reason string) []byte {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	b := make([]byte, 4, 4+len(reason))
+	b[0] = 0x80 | opClose
+	b[1] = byte(2 + len(reason))
+	binary.BigEndian.PutUint16(b[2:], uint16(code))
+	return append(b, reason...)
+}
+
+// maskedCloseFrame builds a client-to-server close frame, which the
+// proxy sends to the backend.
+func maskedCloseFrame(code int, reason string) []byte {
+	b := closeFrame(code, reason)
+	var key [4]byte
+	rand.Read(key[:])
+	out := make([]byte, 0, len(b)+4)
+	out = append(out, b[0], 0x80|b[1])
+	out = append(out, key[:]...)
+	for i, c := range b[2:] {
+		out = append(out, c^key[i%4])
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/wudi/runway/internal/logging"
+	"go.uber.org/zap"
+)
+
+// maxValidatedMessage caps how much of a message is buffered for schema
+// validation when no max_message_size is configured.
+const maxValidatedMessage = 1 << 20
+
+// policyError ends a connection because a message violated route policy.
+// The proxy sends the close code to the client, and to the backend when
+// its message was the violation.
+type policyError struct {
+	code   int
+	reason string
+}
+
+func (e *policyError) Error() string {
+	return fmt.Sprintf("websocket policy violation (%d): %s", e.code, e.reason)
+}
+
+// inspector copies frames in one direction, counting messages and
+// enforcing the message size limit. From the client, it also validates the
+// first messages against a schema. Frames are forwarded byte-for-byte;
+// messages under validation are held back until they are complete and
+// valid.
+type inspector struct {
+	p        *Proxy
+	dst      io.Writer
+	src      io.Reader
+	messages *atomic.Int64 // messages forwarded in this direction
+
+	remaining int   // messages still to validate
+	inMessage bool  // a fragmented data message is in progress
+	msgSize   int64 // payload bytes of the current message
+
+	validating bool         // the current message is being validated
+	pending    bytes.Buffer // raw frames of the current message, held back
+	payload    bytes.Buffer // unmasked payload of the current message
+}
+
+func (p *Proxy) newInspector(dst io.Writer, src io.Reader, fromBackend bool) *inspector {
+	if fromBackend {
+		return &inspector{p: p, dst: dst, src: src, messages: &p.backendMessages}
+	}
+	return &inspector{p: p, dst: dst, src: src, messages: &p.clientMessages, remaining: p.validateMessages}
+}
+
+// run copies frames until src is exhausted or a policy violation occurs.
+func (in *inspector) run() error {
+	for {
+		f, err := readFrameHeader(in.src)
+		if err != nil {
+			return err
+		}
+		if err := in.handle(f); err != nil {
+			return err
+		}
+	}
+}
+
+func (in *inspector) handle(f *frame) error {
+	if f.isControl() {
+		return in.forward(f)
+	}
+
+	switch f.opcode {
+	case opText, opBinary:
+		if in.inMessage {
+			return errMalformedFrame
+		}
+		in.inMessage = true
+		in.msgSize = 0
+		in.validating = in.remaining > 0
+	case opContinuation:
+		if !in.inMessage {
+			return errMalformedFrame
+		}
+	default:
+		return errMalformedFrame
+	}
+
+	in.msgSize += f.length
+	if limit := in.p.maxMessageSize; limit > 0 && in.msgSize > limit {
+		in.p.oversizeMessages.Add(1)
+		return &policyError{code: closeMessageTooBig, reason: "message too big"}
+	}
+
+	if in.validating && in.msgSize > maxValidatedMessage && in.p.maxMessageSize == 0 {
+		// Too large to buffer: treat as a validation failure.
+		if err := in.fail(fmt.Errorf("message exceeds %d bytes", maxValidatedMessage)); err != nil {
+			return err
+		}
+	}
+
+	if !in.validating {
+		if err := in.forward(f); err != nil {
+			return err
+		}
+		if f.fin {
+			in.inMessage = false
+			in.messages.Add(1)
+		}
+		return nil
+	}
+
+	payload := make([]byte, f.length)
+	if _, err := io.ReadFull(in.src, payload); err != nil {
+		return err
+	}
+	in.pending.Write(f.header)
+	in.pending.Write(payload)
+	in.payload.Write(f.unmask(payload))
+	if !f.fin {
+		return nil
+	}
+
+	in.inMessage = false
+	in.remaining--
+	in.p.messagesValidated.Add(1)
+	if err := in.validate(in.payload.Bytes()); err != nil {
+		if err := in.fail(err); err != nil {
+			return err
+		}
+	} else if err := in.flush(); err != nil {
+		return err
+	}
+	in.messages.Add(1)
+	return nil
+}
+
+// validate checks a complete message against the schema.
+func (in *inspector) validate(msg []byte) error {
+	var data interface{}
+	if err := json.Unmarshal(msg, &data); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return in.p.schema.Validate(data)
+}
+
+// fail records a validation failure. In close mode it returns a policy
+// error; in log mode it forwards what was held back and stops validating
+// the current message.
+func (in *inspector) fail(err error) error {
+	in.p.validationFailures.Add(1)
+	if !in.p.logOnly {
+		in.pending.Reset()
+		in.payload.Reset()
+		return &policyError{code: closePolicyViolation, reason: "message failed validation"}
+	}
+	logging.Warn("WebSocket message failed validation (log only)",
+		zap.String("route_id", in.p.routeID),
+		zap.Error(err),
+	)
+	if in.inMessage {
+		// The rest of the message is streamed without validation.
+		in.remaining--
+	}
+	return in.flush()
+}
+
+// flush forwards held-back frames and resets message buffers.
+func (in *inspector) flush() error {
+	in.validating = false
+	in.payload.Reset()
+	_, err := in.pending.WriteTo(in.dst)
+	return err
+}
+
+// forward copies f's header and payload to the backend unchanged.
+func (in *inspector) forward(f *frame) error {
+	if _, err := in.dst.Write(f.header); err != nil {
+		return err
+	}
+	_, err := io.CopyN(in.dst, in.src, f.length)
+	return err
+}
@@ -0,0 +1,301 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wudi/runway/config"
+)
+
+// clientFrame builds a masked client-to-server frame.
+func clientFrame(opcode byte, fin bool, payload []byte) []byte {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	var hdr []byte
+	switch n := len(payload); {
+	case n < 126:
+		hdr = []byte{b0, 0x80 | byte(n)}
+	default:
+		hdr = []byte{b0, 0x80 | 126, 0, 0}
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	}
+	key := [4]byte{1, 2, 3, 4}
+	hdr = append(hdr, key[:]...)
+	for i, c := range payload {
+		hdr = append(hdr, c^key[i%4])
+	}
+	return hdr
+}
+
+// startProxy connects a client pipe through p to a backend that records the
+// bytes it receives after the handshake.
+func startProxy(t *testing.T, p *Proxy) (client net.Conn, received <-chan []byte) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	recv := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		if _, err := http.ReadRequest(reader); err != nil {
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+		data, _ := io.ReadAll(reader)
+		recv <- data
+	}()
+
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() { clientConn.Close(); serverConn.Close() })
+
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	go p.ServeHTTP(&mockHijackResponseWriter{ResponseWriter: httptest.NewRecorder(), conn: serverConn}, r, "http://"+ln.Addr().String())
+
+	buf := make([]byte, 4096)
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := clientConn.Read(buf); err != nil {
+		t.Fatalf("failed to read 101 response: %v", err)
+	}
+	return clientConn, recv
+}
+
+// readClose reads a close frame from the client side and returns its code.
+func readClose(t *testing.T, conn net.Conn) int {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 128)
+	n, err := io.ReadAtLeast(conn, buf, 4)
+	if err != nil {
+		t.Fatalf("failed to read close frame: %v", err)
+	}
+	if buf[0] != 0x80|opClose {
+		t.Fatalf("expected close frame, got % x", buf[:n])
+	}
+	return int(binary.BigEndian.Uint16(buf[2:4]))
+}
+
+func newValidatingProxy(t *testing.T, action string, messages int) *Proxy {
+	t.Helper()
+	p, err := New("ws", config.WebSocketConfig{
+		FirstMessageValidation: config.WebSocketMessageValidationConfig{
+			Enabled:  true,
+			Schema:   `{"type":"object","required":["token"]}`,
+			Messages: messages,
+			Action:   action,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestFirstMessageValidation_ForwardsValidMessages(t *testing.T) {
+	p := newValidatingProxy(t, "close", 1)
+	client, recv := startProxy(t, p)
+
+	// Fragmented valid first message, then an unvalidated second message.
+	first := append(clientFrame(opText, false, []byte(`{"tok`)), clientFrame(opContinuation, true, []byte(`en":"abc"}`))...)
+	second := clientFrame(opText, true, []byte(`not json`))
+	client.Write(first)
+	client.Write(second)
+	client.Close()
+
+	select {
+	case got := <-recv:
+		if want := append(first, second...); !bytes.Equal(got, want) {
+			t.Errorf("backend received % x, want % x", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("backend did not receive messages")
+	}
+
+	stats := p.Stats()
+	if stats["messages_validated"] != int64(1) || stats["validation_failures"] != int64(0) {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func TestFirstMessageValidation_ClosesOnViolation(t *testing.T) {
+	p := newValidatingProxy(t, "", 2)
+	client, recv := startProxy(t, p)
+
+	client.Write(clientFrame(opText, true, []byte(`{"token":"abc"}`)))
+	client.Write(clientFrame(opText, true, []byte(`{"subscribe":"all"}`)))
+
+	if code := readClose(t, client); code != closePolicyViolation {
+		t.Errorf("expected close code 1008, got %d", code)
+	}
+	select {
+	case got := <-recv:
+		if want := clientFrame(opText, true, []byte(`{"token":"abc"}`)); !bytes.Equal(got, want) {
+			t.Errorf("invalid message must not reach the backend; got % x", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("backend connection was not closed")
+	}
+
+	stats := p.Stats()
+	if stats["validation_failures"] != int64(1) || stats["policy_closes"] != int64(1) {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func TestFirstMessageValidation_LogOnly(t *testing.T) {
+	p := newValidatingProxy(t, "log", 1)
+	client, recv := startProxy(t, p)
+
+	msg := clientFrame(opText, true, []byte(`{"subscribe":"all"}`))
+	client.Write(msg)
+	client.Close()
+
+	select {
+	case got := <-recv:
+		if !bytes.Equal(got, msg) {
+			t.Errorf("log-only mode should forward the message; got % x", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("backend did not receive message")
+	}
+	if p.Stats()["validation_failures"] != int64(1) {
+		t.Errorf("expected 1 validation failure, got %v", p.Stats())
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	p, err := New("ws", config.WebSocketConfig{MaxMessageSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, _ := startProxy(t, p)
+
+	// Two fragments that are each under the limit but exceed it together.
+	client.Write(clientFrame(opBinary, false, bytes.Repeat([]byte("a"), 10)))
+	client.Write(clientFrame(opContinuation, true, bytes.Repeat([]byte("a"), 10)))
+
+	if code := readClose(t, client); code != closeMessageTooBig {
+		t.Errorf("expected close code 1009, got %d", code)
+	}
+	if p.Stats()["oversize_messages"] != int64(1) {
+		t.Errorf("expected 1 oversize message, got %v", p.Stats())
+	}
+}
+
+func TestNewInvalidSchema(t *testing.T) {
+	_, err := New("ws", config.WebSocketConfig{
+		FirstMessageValidation: config.WebSocketMessageValidationConfig{Enabled: true, Schema: `{not json`},
+	})
+	if err == nil {
+		t.Fatal("expected error for invalid schema")
+	}
+}
+
+// serverFrame builds an unmasked server-to-client frame.
+func serverFrame(opcode byte, payload []byte) []byte {
+	if len(payload) > 125 {
+		panic("serverFrame: payload too long")
+	}
+	return append([]byte{0x80 | opcode, byte(len(payload))}, payload...)
+}
+
+func TestMaxMessageSize_FromBackend(t *testing.T) {
+	recv := make(chan []byte, 1)
+	backendURL := startBackend(t, func(conn net.Conn, br *bufio.Reader, req *http.Request) {
+		conn.Write([]byte(switchingProtocols + "\r\n"))
+		conn.Write(serverFrame(opBinary, bytes.Repeat([]byte("a"), 20)))
+		data, _ := io.ReadAll(br)
+		recv <- data
+	})
+	p, err := New("ws", config.WebSocketConfig{MaxMessageSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, _ := upgrade(t, p, upgradeRequest(), backendURL)
+
+	if code := readClose(t, client); code != closeMessageTooBig {
+		t.Errorf("client: expected close code 1009, got %d", code)
+	}
+
+	// The backend gets a masked close frame with the same code.
+	select {
+	case got := <-recv:
+		if len(got) < 8 || got[0] != 0x80|opClose || got[1]&0x80 == 0 {
+			t.Fatalf("backend: expected a masked close frame, got % x", got)
+		}
+		key := got[2:6]
+		code := int(got[6]^key[0])<<8 | int(got[7]^key[1])
+		if code != closeMessageTooBig {
+			t.Errorf("backend: expected close code 1009, got %d", code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("backend connection was not closed")
+	}
+
+	stats := p.Stats()
+	if stats["oversize_messages"] != int64(1) || stats["policy_closes"] != int64(1) || stats["messages_from_backend"] != int64(0) {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func TestMessageCounters(t *testing.T) {
+	backendURL := startBackend(t, func(conn net.Conn, br *bufio.Reader, req *http.Request) {
+		conn.Write([]byte(switchingProtocols + "\r\n"))
+		conn.Write(serverFrame(opText, []byte("welcome")))
+		io.Copy(conn, br) // echo
+	})
+	p, err := New("ws", config.WebSocketConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, _ := upgrade(t, p, upgradeRequest(), backendURL)
+
+	msgs := [][]byte{
+		clientFrame(opText, true, []byte("one")),
+		append(clientFrame(opText, false, []byte("tw")), clientFrame(opContinuation, true, []byte("o"))...),
+	}
+	want := serverFrame(opText, []byte("welcome"))
+	for _, m := range msgs {
+		client.Write(m)
+		want = append(want, m...)
+	}
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(client, got); err != nil {
+		t.Fatalf("failed to read echoed frames: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("client received % x, want % x", got, want)
+	}
+
+	// A message is counted once its last frame has been forwarded.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats := p.Stats()
+		if stats["messages_from_client"] == int64(2) && stats["messages_from_backend"] == int64(3) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected stats: %v", stats)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
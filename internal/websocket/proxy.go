@@ -1,26 +1,58 @@
 package websocket
 
 import (
-	"io"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/santhosh-tekuri/jsonschema/v6"
 	"github.com/wudi/runway/config"
+	"github.com/wudi/runway/internal/byroute"
+	gatewayerrors "github.com/wudi/runway/internal/errors"
 	"github.com/wudi/runway/internal/logging"
+	"github.com/wudi/runway/internal/middleware/validation"
 	"go.uber.org/zap"
 )
 
 // Proxy handles WebSocket proxying via HTTP hijack
 type Proxy struct {
+	routeID         string
 	readBufferSize  int
 	writeBufferSize int
 	readTimeout     time.Duration
 	writeTimeout    time.Duration
 	pingInterval    time.Duration
 	pongTimeout     time.Duration
+
+	// Message policy; see inspector. The size limit applies in both
+	// directions, validation to client messages.
+	maxMessageSize   int64
+	schema           *jsonschema.Schema
+	validateMessages int
+	logOnly          bool
+
+	subprotocols   []string // allowed subprotocols, empty to pass the client's through
+	maxConnections int64
+
+	connections         atomic.Int64
+	active              atomic.Int64
+	connectionsRejected atomic.Int64
+	upgradeBlocked      atomic.Int64
+	subprotocolRejected atomic.Int64
+	clientMessages      atomic.Int64
+	backendMessages     atomic.Int64
+	messagesValidated   atomic.Int64
+	validationFailures  atomic.Int64
+	oversizeMessages    atomic.Int64
+	policyCloses        atomic.Int64
 }
 
 // NewProxy creates a new WebSocket proxy
@@ -65,6 +97,78 @@ func NewProxy(cfg config.WebSocketConfig) *Proxy {
 	}
 }
 
+// New creates a WebSocket proxy for a route, compiling the first-message
+// validation schema when configured.
+func New(routeID string, cfg config.WebSocketConfig) (*Proxy, error) {
+	p := NewProxy(cfg)
+	p.routeID = routeID
+	p.maxMessageSize = cfg.MaxMessageSize
+	p.subprotocols = cfg.Subprotocols
+	p.maxConnections = int64(cfg.MaxConnections)
+	if fmv := cfg.FirstMessageValidation; fmv.Enabled {
+		schema, err := validation.CompileSchema(fmv.Schema, fmv.SchemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("websocket first_message_validation: %w", err)
+		}
+		p.schema = schema
+		p.validateMessages = fmv.Messages
+		if p.validateMessages == 0 {
+			p.validateMessages = 1
+		}
+		p.logOnly = fmv.Action == "log"
+	}
+	return p, nil
+}
+
+// enforces reports whether messages are subject to a size limit or
+// validation.
+func (p *Proxy) enforces() bool {
+	return p.schema != nil || p.maxMessageSize > 0
+}
+
+// RecordUpgradeBlocked counts an upgrade request rejected by route
+// middleware (e.g. the WAF) before the upgrade.
+func (p *Proxy) RecordUpgradeBlocked() {
+	p.upgradeBlocked.Add(1)
+}
+
+// Active returns the number of open WebSocket connections, including
+// upgrades still in their handshake.
+func (p *Proxy) Active() int64 {
+	return p.active.Load()
+}
+
+// Stats returns connection and message policy counters.
+func (p *Proxy) Stats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"connections_total":     p.connections.Load(),
+		"active":                p.active.Load(),
+		"upgrade_blocked":       p.upgradeBlocked.Load(),
+		"messages_from_client":  p.clientMessages.Load(),
+		"messages_from_backend": p.backendMessages.Load(),
+	}
+	if p.maxConnections > 0 {
+		stats["max_connections"] = p.maxConnections
+		stats["connections_rejected"] = p.connectionsRejected.Load()
+	}
+	if len(p.subprotocols) > 0 {
+		stats["subprotocols"] = p.subprotocols
+		stats["subprotocol_rejected"] = p.subprotocolRejected.Load()
+	}
+	if p.maxMessageSize > 0 {
+		stats["max_message_size"] = p.maxMessageSize
+		stats["oversize_messages"] = p.oversizeMessages.Load()
+	}
+	if p.schema != nil {
+		stats["messages_validated"] = p.messagesValidated.Load()
+		stats["validation_failures"] = p.validationFailures.Load()
+	}
+	if p.enforces() {
+		stats["policy_closes"] = p.policyCloses.Load()
+	}
+	return stats
+}
+
 // IsUpgradeRequest checks if the request is a WebSocket upgrade request
 func IsUpgradeRequest(r *http.Request) bool {
 	connection := strings.ToLower(r.Header.Get("Connection"))
@@ -73,6 +177,19 @@ func IsUpgradeRequest(r *http.Request) bool {
 	return strings.Contains(connection, "upgrade") && upgrade == "websocket"
 }
 
+// negotiate returns the first subprotocol offered by the client that the
+// route allows.
+func (p *Proxy) negotiate(r *http.Request) (string, bool) {
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, proto := range strings.Split(v, ",") {
+			if proto = strings.TrimSpace(proto); slices.Contains(p.subprotocols, proto) {
+				return proto, true
+			}
+		}
+	}
+	return "", false
+}
+
 // ServeHTTP proxies a WebSocket connection to the backend
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request, backendURL string) {
 	// Parse backend URL
@@ -82,6 +199,27 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request, backendURL str
 		return
 	}
 
+	// Only an allowed subprotocol is offered to the backend.
+	if len(p.subprotocols) > 0 {
+		proto, ok := p.negotiate(r)
+		if !ok {
+			p.subprotocolRejected.Add(1)
+			gatewayerrors.ErrBadRequest.WithDetails("No supported WebSocket subprotocol offered").WriteJSON(w)
+			return
+		}
+		r.Header.Set("Sec-WebSocket-Protocol", proto)
+	}
+
+	// Reserve a connection before the upgrade so concurrent upgrades
+	// cannot exceed the cap.
+	if n := p.active.Add(1); p.maxConnections > 0 && n > p.maxConnections {
+		p.active.Add(-1)
+		p.connectionsRejected.Add(1)
+		gatewayerrors.ErrServiceUnavailable.WithDetails("WebSocket connection limit reached").WriteJSON(w)
+		return
+	}
+	defer p.active.Add(-1)
+
 	// Hijack the client connection
 	hijacker, ok := w.(http.Hijacker)
 	if !ok {
@@ -134,36 +272,96 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request, backendURL str
 	}
 	backendConn.Write([]byte("\r\n"))
 
-	// Read the backend response (101 Switching Protocols)
-	buf := make([]byte, p.readBufferSize)
-	n, err := backendConn.Read(buf)
+	// Read the backend response (101 Switching Protocols). Frames sent
+	// right after it stay buffered in backendBuf.
+	backendBuf := bufio.NewReaderSize(backendConn, p.readBufferSize)
+	resp, err := http.ReadResponse(backendBuf, r)
 	if err != nil {
 		logging.Error("WebSocket proxy: failed to read backend response", zap.Error(err))
 		clientBuf.WriteString("HTTP/1.1 502 Bad Gateway\r\n\r\n")
 		clientBuf.Flush()
 		return
 	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		// The backend refused the upgrade; relay its answer.
+		resp.Write(clientConn)
+		resp.Body.Close()
+		return
+	}
+	if proto := resp.Header.Get("Sec-WebSocket-Protocol"); len(p.subprotocols) > 0 && proto != "" && proto != r.Header.Get("Sec-WebSocket-Protocol") {
+		logging.Error("WebSocket proxy: backend selected a subprotocol that was not offered",
+			zap.String("route_id", p.routeID),
+			zap.String("subprotocol", proto),
+		)
+		clientBuf.WriteString("HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		clientBuf.Flush()
+		return
+	}
 
 	// Forward the backend's response to the client
-	clientConn.Write(buf[:n])
+	var head bytes.Buffer
+	fmt.Fprintf(&head, "HTTP/1.1 %s\r\n", resp.Status)
+	resp.Header.Write(&head)
+	head.WriteString("\r\n")
+	clientConn.Write(head.Bytes())
+
+	p.connections.Add(1)
 
-	// Bidirectional copy
-	errCh := make(chan error, 2)
+	// Frames are copied in both directions by inspectors. Client bytes are
+	// read through clientBuf, which may already hold data sent right after
+	// the upgrade request.
+	type copyResult struct {
+		fromBackend bool
+		err         error
+	}
+	results := make(chan copyResult, 2)
 
 	go func() {
-		_, err := io.Copy(backendConn, clientConn)
-		errCh <- err
+		results <- copyResult{err: p.newInspector(backendConn, clientBuf.Reader, false).run()}
 	}()
 
 	go func() {
-		_, err := io.Copy(clientConn, backendConn)
-		errCh <- err
+		results <- copyResult{fromBackend: true, err: p.newInspector(clientConn, backendBuf, true).run()}
 	}()
 
 	// Wait for either direction to finish
-	<-errCh
+	res := <-results
+
+	var pe *policyError
+	if errors.As(res.err, &pe) {
+		// Stop the other direction before writing so the close frames do
+		// not interleave with forwarded frames.
+		if res.fromBackend {
+			clientConn.SetReadDeadline(time.Now())
+		} else {
+			backendConn.SetReadDeadline(time.Now())
+		}
+		<-results
+		clientConn.SetWriteDeadline(time.Now().Add(p.writeTimeout))
+		clientConn.Write(closeFrame(pe.code, pe.reason))
+		if res.fromBackend {
+			backendConn.SetWriteDeadline(time.Now().Add(p.writeTimeout))
+			backendConn.Write(maskedCloseFrame(pe.code, pe.reason))
+		}
+		p.policyCloses.Add(1)
+		logging.Info("WebSocket connection closed by policy",
+			zap.String("route_id", p.routeID),
+			zap.Bool("backend", res.fromBackend),
+			zap.Int("code", pe.code),
+			zap.String("reason", pe.reason),
+		)
+		return
+	}
 
 	// Set a deadline to let the other direction finish
 	clientConn.SetDeadline(time.Now().Add(1 * time.Second))
 	backendConn.SetDeadline(time.Now().Add(1 * time.Second))
 }
+
+// WebSocketByRoute manages per-route WebSocket proxies.
+type WebSocketByRoute = byroute.NamedFactory[*Proxy, config.WebSocketConfig]
+
+// NewWebSocketByRoute creates a new per-route WebSocket proxy manager.
+func NewWebSocketByRoute() *WebSocketByRoute {
+	return byroute.NewNamedFactory(New, func(p *Proxy) any { return p.Stats() })
+}
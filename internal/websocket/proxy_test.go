@@ -2,6 +2,8 @@ package websocket
 
 import (
 	"bufio"
+	"bytes"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -214,18 +216,19 @@ func TestProxyEndToEnd(t *testing.T) {
 		t.Errorf("expected 101 response, got: %s", respStr)
 	}
 
-	// Send data through the proxy
-	clientConn.Write([]byte("hello"))
+	// Send a frame through the proxy
+	msg := clientFrame(opText, true, []byte("hello"))
+	clientConn.Write(msg)
 
 	// Read echo back
 	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
-	n, err = clientConn.Read(buf)
-	if err != nil {
+	got := make([]byte, len(msg))
+	if _, err := io.ReadFull(clientConn, got); err != nil {
 		t.Fatalf("failed to read echo: %v", err)
 	}
 
-	if string(buf[:n]) != "hello" {
-		t.Errorf("expected 'hello', got '%s'", string(buf[:n]))
+	if !bytes.Equal(got, msg) {
+		t.Errorf("expected echoed frame % x, got % x", msg, got)
 	}
 
 	// Close connections to clean up
@@ -238,3 +241,137 @@ func TestProxyEndToEnd(t *testing.T) {
 		// Proxy may still be cleaning up
 	}
 }
+
+// startBackend accepts WebSocket upgrades on a local listener and calls
+// serve with each connection after reading the upgrade request.
+func startBackend(t *testing.T, serve func(conn net.Conn, br *bufio.Reader, req *http.Request)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				req, err := http.ReadRequest(br)
+				if err != nil {
+					return
+				}
+				serve(conn, br, req)
+			}()
+		}
+	}()
+	return "http://" + ln.Addr().String()
+}
+
+// upgrade runs r through p over a pipe and returns the client end and the
+// handshake response.
+func upgrade(t *testing.T, p *Proxy, r *http.Request, backendURL string) (net.Conn, string) {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() { clientConn.Close(); serverConn.Close() })
+	go p.ServeHTTP(&mockHijackResponseWriter{ResponseWriter: httptest.NewRecorder(), conn: serverConn}, r, backendURL)
+
+	buf := make([]byte, 4096)
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := clientConn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read handshake response: %v", err)
+	}
+	clientConn.SetReadDeadline(time.Time{})
+	return clientConn, string(buf[:n])
+}
+
+func upgradeRequest(protocols ...string) *http.Request {
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	for _, proto := range protocols {
+		r.Header.Add("Sec-WebSocket-Protocol", proto)
+	}
+	return r
+}
+
+const switchingProtocols = "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"
+
+func TestSubprotocolNegotiation(t *testing.T) {
+	offered := make(chan []string, 1)
+	backendURL := startBackend(t, func(conn net.Conn, br *bufio.Reader, req *http.Request) {
+		offered <- req.Header.Values("Sec-WebSocket-Protocol")
+		conn.Write([]byte(switchingProtocols + "Sec-WebSocket-Protocol: chat\r\n\r\n"))
+		io.Copy(io.Discard, br)
+	})
+	p, err := New("ws", config.WebSocketConfig{Subprotocols: []string{"v2.chat", "chat"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The client's preference order decides between allowed subprotocols.
+	_, resp := upgrade(t, p, upgradeRequest("superchat, chat", "v2.chat"), backendURL)
+	if got := <-offered; len(got) != 1 || got[0] != "chat" {
+		t.Errorf("backend was offered %q, want only chat", got)
+	}
+	if !strings.Contains(resp, "101") || !strings.Contains(strings.ToLower(resp), "sec-websocket-protocol: chat\r\n") {
+		t.Errorf("handshake response should select chat, got %q", resp)
+	}
+}
+
+func TestSubprotocolRejected(t *testing.T) {
+	p, err := New("ws", config.WebSocketConfig{Subprotocols: []string{"chat"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, offer := range [][]string{{"superchat"}, nil} {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, upgradeRequest(offer...), "http://127.0.0.1:1")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("offer %q: status %d, want 400", offer, w.Code)
+		}
+	}
+	if got := p.Stats()["subprotocol_rejected"]; got != int64(2) {
+		t.Errorf("subprotocol_rejected = %v, want 2", got)
+	}
+}
+
+func TestMaxConnections(t *testing.T) {
+	backendURL := startBackend(t, func(conn net.Conn, br *bufio.Reader, req *http.Request) {
+		conn.Write([]byte(switchingProtocols + "\r\n"))
+		io.Copy(io.Discard, br)
+	})
+	p, err := New("ws", config.WebSocketConfig{MaxConnections: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := upgrade(t, p, upgradeRequest(), backendURL)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, upgradeRequest(), backendURL)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("upgrade over the cap: status %d, want 503", w.Code)
+	}
+	stats := p.Stats()
+	if stats["active"] != int64(1) || stats["connections_rejected"] != int64(1) {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+
+	// Closing the first connection frees its slot.
+	first.Close()
+	deadline := time.Now().Add(3 * time.Second)
+	for p.Active() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("closed connection still counted as active")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, resp := upgrade(t, p, upgradeRequest(), backendURL); !strings.Contains(resp, "101") {
+		t.Errorf("upgrade after a slot was freed: %q", resp)
+	}
+}
@@ -0,0 +1,231 @@
+package xmlsafe
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// dtdEntities reads the document type declaration of data, if any, and
+// returns its internal general entities fully expanded. External DTDs,
+// external and parameter entities are rejected, as are declarations beyond
+// MaxEntities and entities or documents that expand beyond
+// MaxEntityExpansion.
+func dtdEntities(data []byte, lim Limits) (map[string]string, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = true
+	var doctype string
+	var doctypeEnd int64
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line, col := d.InputPos()
+			return nil, &Error{Violation: ViolationSyntax, Line: line, Column: col, Msg: syntaxMsg(err)}
+		}
+		if dir, ok := tok.(xml.Directive); ok && bytes.HasPrefix(dir, []byte("DOCTYPE")) {
+			doctype = string(dir[len("DOCTYPE"):])
+			doctypeEnd = d.InputOffset()
+			continue
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			break
+		}
+	}
+	if doctype == "" {
+		return nil, nil
+	}
+
+	decls, err := parseDoctype(doctype, lim)
+	if err != nil {
+		return nil, err
+	}
+	if len(decls) == 0 {
+		return nil, nil
+	}
+
+	x := &expander{decls: decls, lim: lim, values: map[string]string{}}
+	entities := make(map[string]string, len(decls))
+	for name := range decls {
+		v, err := x.expand(name, nil)
+		if err != nil {
+			return nil, err
+		}
+		entities[name] = v
+	}
+
+	// Every reference in the document body expands in full; bound the total.
+	var total int64
+	body := data[doctypeEnd:]
+	for name, v := range entities {
+		n := int64(bytes.Count(body, []byte("&"+name+";")))
+		total += n * int64(len(v))
+		if total > lim.MaxEntityExpansion {
+			return nil, &Error{Violation: ViolationEntityExpansion, Msg: fmt.Sprintf("entity references expand to more than %d bytes", lim.MaxEntityExpansion)}
+		}
+	}
+	return entities, nil
+}
+
+// parseDoctype returns the raw values of the internal general entities
+// declared in a DOCTYPE directive body.
+func parseDoctype(s string, lim Limits) (map[string]string, error) {
+	subset := ""
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		subset = s[i+1:]
+		if j := strings.LastIndexByte(subset, ']'); j >= 0 {
+			subset = subset[:j]
+		}
+		s = s[:i]
+	}
+	// The external ID, if any, sits between the root name and the subset.
+	for _, f := range strings.Fields(s) {
+		if f == "SYSTEM" || f == "PUBLIC" {
+			return nil, &Error{Violation: ViolationExternalEntity, Msg: "external DTD is not allowed"}
+		}
+	}
+
+	decls := map[string]string{}
+	for rest := subset; ; {
+		i := indexOutsideQuotes(rest, "<!ENTITY")
+		if i < 0 {
+			if indexOutsideQuotes(rest, "%") >= 0 {
+				return nil, &Error{Violation: ViolationParameterEntity, Msg: "parameter entity references are not allowed"}
+			}
+			break
+		}
+		if indexOutsideQuotes(rest[:i], "%") >= 0 {
+			return nil, &Error{Violation: ViolationParameterEntity, Msg: "parameter entity references are not allowed"}
+		}
+		rest = strings.TrimLeft(rest[i+len("<!ENTITY"):], " \t\r\n")
+		if strings.HasPrefix(rest, "%") {
+			return nil, &Error{Violation: ViolationParameterEntity, Msg: "parameter entities are not allowed"}
+		}
+		end := strings.IndexAny(rest, " \t\r\n")
+		if end <= 0 {
+			return nil, &Error{Violation: ViolationSyntax, Msg: "malformed ENTITY declaration"}
+		}
+		name := rest[:end]
+		rest = strings.TrimLeft(rest[end:], " \t\r\n")
+		if strings.HasPrefix(rest, "SYSTEM") || strings.HasPrefix(rest, "PUBLIC") {
+			return nil, &Error{Violation: ViolationExternalEntity, Msg: fmt.Sprintf("external entity %q is not allowed", name)}
+		}
+		if rest == "" || (rest[0] != '"' && rest[0] != '\'') {
+			return nil, &Error{Violation: ViolationSyntax, Msg: fmt.Sprintf("malformed declaration of entity %q", name)}
+		}
+		q := strings.IndexByte(rest[1:], rest[0])
+		if q < 0 {
+			return nil, &Error{Violation: ViolationSyntax, Msg: fmt.Sprintf("unterminated value of entity %q", name)}
+		}
+		if _, dup := decls[name]; !dup {
+			// The first declaration binds, as in XML 1.0.
+			decls[name] = rest[1 : q+1]
+		}
+		if len(decls) > lim.MaxEntities {
+			return nil, &Error{Violation: ViolationEntityCount, Msg: fmt.Sprintf("more than %d entities declared", lim.MaxEntities)}
+		}
+		rest = rest[q+2:]
+	}
+	return decls, nil
+}
+
+// indexOutsideQuotes returns the index of the first occurrence of sub in s
+// that is not inside a quoted literal, or -1.
+func indexOutsideQuotes(s, sub string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.HasPrefix(s[i:], sub):
+			return i
+		}
+	}
+	return -1
+}
+
+var predefined = map[string]string{"lt": "<", "gt": ">", "amp": "&", "apos": "'", "quot": `"`}
+
+// expander resolves entity values recursively, detecting cycles and
+// stopping as soon as a value would exceed MaxEntityExpansion.
+type expander struct {
+	decls  map[string]string
+	lim    Limits
+	values map[string]string
+}
+
+func (x *expander) expand(name string, visiting []string) (string, error) {
+	if v, ok := x.values[name]; ok {
+		return v, nil
+	}
+	for _, n := range visiting {
+		if n == name {
+			return "", &Error{Violation: ViolationEntityExpansion, Msg: fmt.Sprintf("entity %q references itself", name)}
+		}
+	}
+	visiting = append(visiting, name)
+
+	raw := x.decls[name]
+	var b strings.Builder
+	for len(raw) > 0 {
+		amp := strings.IndexByte(raw, '&')
+		if amp < 0 {
+			b.WriteString(raw)
+			break
+		}
+		b.WriteString(raw[:amp])
+		semi := strings.IndexByte(raw[amp:], ';')
+		if semi < 0 {
+			return "", &Error{Violation: ViolationSyntax, Msg: fmt.Sprintf("unterminated reference in entity %q", name)}
+		}
+		ref := raw[amp+1 : amp+semi]
+		raw = raw[amp+semi+1:]
+		switch {
+		case strings.HasPrefix(ref, "#"):
+			r, err := parseCharRef(ref[1:])
+			if err != nil {
+				return "", &Error{Violation: ViolationSyntax, Msg: fmt.Sprintf("invalid character reference in entity %q", name)}
+			}
+			b.WriteRune(r)
+		case predefined[ref] != "":
+			b.WriteString(predefined[ref])
+		default:
+			if _, ok := x.decls[ref]; !ok {
+				return "", &Error{Violation: ViolationSyntax, Msg: fmt.Sprintf("entity %q references undeclared entity %q", name, ref)}
+			}
+			v, err := x.expand(ref, visiting)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+		}
+		if int64(b.Len()) > x.lim.MaxEntityExpansion {
+			return "", &Error{Violation: ViolationEntityExpansion, Msg: fmt.Sprintf("entity %q expands to more than %d bytes", name, x.lim.MaxEntityExpansion)}
+		}
+	}
+	v := b.String()
+	x.values[name] = v
+	return v, nil
+}
+
+func parseCharRef(s string) (rune, error) {
+	base := 10
+	if strings.HasPrefix(s, "x") {
+		s, base = s[1:], 16
+	}
+	n, err := strconv.ParseUint(s, base, 32)
+	if err != nil {
+		return 0, err
+	}
+	return rune(n), nil
+}
@@ -0,0 +1,301 @@
+// Package xmlsafe parses untrusted XML with bounded resource use. Documents
+// are checked for size, nesting depth and attribute count, external and
+// parameter entities are rejected, and internal entity expansion is capped
+// before any other parser sees the document.
+package xmlsafe
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"sync/atomic"
+)
+
+// Default limits, used for zero fields of Limits.
+const (
+	DefaultMaxBytes           = 10 << 20
+	DefaultMaxDepth           = 100
+	DefaultMaxAttributes      = 100
+	DefaultMaxEntities        = 20
+	DefaultMaxEntityExpansion = 64 << 10
+)
+
+// Limits bounds the resources a document may use while parsed.
+type Limits struct {
+	MaxBytes           int64 // document size
+	MaxDepth           int   // element nesting depth
+	MaxAttributes      int   // attributes per element
+	MaxEntities        int   // internal entity declarations in the DTD
+	MaxEntityExpansion int64 // bytes produced by expanding entity references
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = DefaultMaxBytes
+	}
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = DefaultMaxDepth
+	}
+	if l.MaxAttributes <= 0 {
+		l.MaxAttributes = DefaultMaxAttributes
+	}
+	if l.MaxEntities <= 0 {
+		l.MaxEntities = DefaultMaxEntities
+	}
+	if l.MaxEntityExpansion <= 0 {
+		l.MaxEntityExpansion = DefaultMaxEntityExpansion
+	}
+	return l
+}
+
+var current atomic.Pointer[Limits]
+
+func init() {
+	SetLimits(Limits{})
+}
+
+// SetLimits replaces the process-wide limits used by Parse, Check,
+// NewDecoder and Unmarshal. Zero fields take their defaults.
+func SetLimits(l Limits) {
+	l = l.withDefaults()
+	current.Store(&l)
+}
+
+// CurrentLimits returns the process-wide limits.
+func CurrentLimits() Limits {
+	return *current.Load()
+}
+
+// Violation names the limit or rule a document broke.
+type Violation string
+
+const (
+	ViolationSize            Violation = "size"
+	ViolationDepth           Violation = "depth"
+	ViolationAttributes      Violation = "attributes"
+	ViolationExternalEntity  Violation = "external_entity"
+	ViolationParameterEntity Violation = "parameter_entity"
+	ViolationEntityCount     Violation = "entity_count"
+	ViolationEntityExpansion Violation = "entity_expansion"
+	ViolationSyntax          Violation = "syntax"
+)
+
+// Error describes why a document was rejected.
+type Error struct {
+	Violation Violation
+	Line      int // 0 when not known
+	Column    int
+	Msg       string
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("xml: line %d, column %d: %s", e.Line, e.Column, e.Msg)
+	}
+	return "xml: " + e.Msg
+}
+
+// Node is an element of a parsed document.
+type Node struct {
+	Name     xml.Name
+	Attr     []xml.Attr
+	Children []*Node
+	Text     string // character data directly inside the element
+	Line     int
+	Column   int
+
+	ns map[string]string // prefix -> namespace URI in scope
+}
+
+// LookupPrefix resolves a namespace prefix in the scope of n. The empty
+// prefix resolves the default namespace.
+func (n *Node) LookupPrefix(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return "http://www.w3.org/XML/1998/namespace", true
+	}
+	uri, ok := n.ns[prefix]
+	return uri, ok
+}
+
+// Attribute returns the value of the attribute with the given namespace and
+// local name.
+func (n *Node) Attribute(space, local string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// Parse parses data into a tree under the process-wide limits.
+func Parse(data []byte) (*Node, error) {
+	return ParseWithLimits(data, CurrentLimits())
+}
+
+// ParseWithLimits parses data into a tree under lim.
+func ParseWithLimits(data []byte, lim Limits) (*Node, error) {
+	root, _, err := scan(data, lim.withDefaults(), true)
+	return root, err
+}
+
+// Check reports whether data is well-formed and within the process-wide
+// limits, without building a tree.
+func Check(data []byte) error {
+	_, _, err := scan(data, CurrentLimits(), false)
+	return err
+}
+
+// NewDecoder checks data and returns a strict decoder for it with the
+// document's internal entities pre-expanded.
+func NewDecoder(data []byte) (*xml.Decoder, error) {
+	_, entities, err := scan(data, CurrentLimits(), false)
+	if err != nil {
+		return nil, err
+	}
+	return newDecoder(data, entities), nil
+}
+
+// Unmarshal checks data and decodes it into v like xml.Unmarshal.
+func Unmarshal(data []byte, v any) error {
+	d, err := NewDecoder(data)
+	if err != nil {
+		return err
+	}
+	return d.Decode(v)
+}
+
+// IsXMLContentType reports whether a Content-Type names an XML media type
+// (application/xml, text/xml, application/soap+xml, ...).
+func IsXMLContentType(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == "application/xml" || mt == "text/xml" || strings.HasSuffix(mt, "+xml")
+}
+
+func newDecoder(data []byte, entities map[string]string) *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = true
+	d.Entity = entities
+	return d
+}
+
+func sizeError(n int, lim Limits) *Error {
+	return &Error{Violation: ViolationSize, Msg: fmt.Sprintf("document is %d bytes, limit is %d", n, lim.MaxBytes)}
+}
+
+// scan tokenizes data, enforcing lim, and builds the tree when build is set.
+// It returns the document's expanded internal entities.
+func scan(data []byte, lim Limits, build bool) (*Node, map[string]string, error) {
+	if int64(len(data)) > lim.MaxBytes {
+		return nil, nil, sizeError(len(data), lim)
+	}
+	entities, err := dtdEntities(data, lim)
+	if err != nil {
+		return nil, nil, err
+	}
+	d := newDecoder(data, entities)
+
+	var root *Node
+	var stack []*Node
+	depth := 0
+	rootNS := map[string]string{}
+	for {
+		// Tokens are contiguous, so the position before reading one is
+		// where it starts.
+		line, col := d.InputPos()
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line, col := d.InputPos()
+			return nil, nil, &Error{Violation: ViolationSyntax, Line: line, Column: col, Msg: syntaxMsg(err)}
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth > lim.MaxDepth {
+				return nil, nil, &Error{Violation: ViolationDepth, Line: line, Column: col, Msg: fmt.Sprintf("element nesting exceeds depth %d", lim.MaxDepth)}
+			}
+			if len(t.Attr) > lim.MaxAttributes {
+				return nil, nil, &Error{Violation: ViolationAttributes, Line: line, Column: col, Msg: fmt.Sprintf("element <%s> has %d attributes, limit is %d", t.Name.Local, len(t.Attr), lim.MaxAttributes)}
+			}
+			if !build {
+				continue
+			}
+			parentNS := rootNS
+			if len(stack) > 0 {
+				parentNS = stack[len(stack)-1].ns
+			}
+			n := &Node{Name: t.Name, Line: line, Column: col, ns: scopeFor(parentNS, t.Attr)}
+			for _, a := range t.Attr {
+				if a.Name.Space != "xmlns" && !(a.Name.Space == "" && a.Name.Local == "xmlns") {
+					n.Attr = append(n.Attr, a)
+				}
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			depth--
+			if build && len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if build && len(stack) > 0 {
+				stack[len(stack)-1].Text += string(t)
+			}
+		}
+	}
+	if build && root == nil {
+		return nil, nil, &Error{Violation: ViolationSyntax, Msg: "document has no root element"}
+	}
+	return root, entities, nil
+}
+
+// syntaxMsg strips the position prefix from encoding/xml syntax errors,
+// which Error reports itself.
+func syntaxMsg(err error) string {
+	if se, ok := err.(*xml.SyntaxError); ok {
+		return se.Msg
+	}
+	return err.Error()
+}
+
+// scopeFor returns the namespace scope of an element declaring attrs inside
+// parent, sharing parent's map when nothing is declared.
+func scopeFor(parent map[string]string, attrs []xml.Attr) map[string]string {
+	var scope map[string]string
+	for _, a := range attrs {
+		var prefix string
+		switch {
+		case a.Name.Space == "xmlns":
+			prefix = a.Name.Local
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			prefix = ""
+		default:
+			continue
+		}
+		if scope == nil {
+			scope = make(map[string]string, len(parent)+1)
+			for k, v := range parent {
+				scope[k] = v
+			}
+		}
+		scope[prefix] = a.Value
+	}
+	if scope == nil {
+		return parent
+	}
+	return scope
+}
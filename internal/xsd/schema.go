@@ -0,0 +1,638 @@
+// Package xsd validates XML documents against a W3C XML Schema.
+//
+// It implements the subset of XSD 1.0 used by typical SOAP and REST payload
+// schemas: global and local elements, element references and substitution
+// groups, named and anonymous complex types with sequence, choice, all,
+// group references and wildcards, simple and complex content derivation,
+// attributes and attribute groups, and simple types derived by
+// restriction, list or union with the usual facets. Schemas must be
+// self-contained: xs:import, xs:include and xs:redefine are rejected, and
+// identity constraints are ignored.
+package xsd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wudi/runway/internal/xmlsafe"
+)
+
+const (
+	nsXSD    = "http://www.w3.org/2001/XMLSchema"
+	nsXSI    = "http://www.w3.org/2001/XMLSchema-instance"
+	nsXML    = "http://www.w3.org/XML/1998/namespace"
+	nsSOAP11 = "http://schemas.xmlsoap.org/soap/envelope/"
+	nsSOAP12 = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+// Schema is a compiled XML schema. It is safe for concurrent use.
+type Schema struct {
+	targetNS       string
+	qualifiedElems bool
+	qualifiedAttrs bool
+
+	elements    map[xml.Name]*elemDecl
+	substitutes map[xml.Name][]*elemDecl // head -> members
+
+	raw          map[string]map[xml.Name]*xmlsafe.Node // kind -> name -> definition
+	complexTypes map[xml.Name]*typeDef
+	simpleTypes  map[xml.Name]*simpleType
+	groups       map[xml.Name]*particle
+	attrGroups   map[xml.Name]*typeDef // only attrs and anyAttr are used
+	attributes   map[xml.Name]*attrDecl
+}
+
+type elemDecl struct {
+	name     xml.Name
+	typ      *typeDef
+	nillable bool
+}
+
+// typeDef is the content model of an element: simple content, element
+// content, or anything for xs:anyType.
+type typeDef struct {
+	simple  *simpleType // text-only content
+	content *particle   // element content; nil with simple == nil means empty
+	attrs   []*attrDecl
+	anyAttr bool
+	mixed   bool
+	anyType bool
+}
+
+func (td *typeDef) attr(name xml.Name) *attrDecl {
+	for _, a := range td.attrs {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+type attrDecl struct {
+	name       xml.Name
+	typ        *simpleType
+	required   bool
+	prohibited bool
+	fixed      string
+}
+
+type particleKind int
+
+const (
+	pElement particleKind = iota
+	pSequence
+	pChoice
+	pAll
+	pAny
+)
+
+type particle struct {
+	kind     particleKind
+	min, max int // max < 0 is unbounded
+	elem     *elemDecl
+	children []*particle
+
+	namespace string // wildcard namespace constraint
+	process   string // wildcard processContents
+}
+
+var anyTypeDef = &typeDef{anyType: true}
+
+// Compile parses and compiles a schema document.
+func Compile(data []byte) (*Schema, error) {
+	root, err := xmlsafe.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("xsd: %w", err)
+	}
+	if root.Name != (xml.Name{Space: nsXSD, Local: "schema"}) {
+		return nil, fmt.Errorf("xsd: root element is %s, not xs:schema", root.Name.Local)
+	}
+	s := &Schema{
+		targetNS:     attr(root, "targetNamespace"),
+		elements:     map[xml.Name]*elemDecl{},
+		substitutes:  map[xml.Name][]*elemDecl{},
+		raw:          map[string]map[xml.Name]*xmlsafe.Node{},
+		complexTypes: map[xml.Name]*typeDef{},
+		simpleTypes:  map[xml.Name]*simpleType{},
+		groups:       map[xml.Name]*particle{},
+		attrGroups:   map[xml.Name]*typeDef{},
+		attributes:   map[xml.Name]*attrDecl{},
+	}
+	s.qualifiedElems = attr(root, "elementFormDefault") == "qualified"
+	s.qualifiedAttrs = attr(root, "attributeFormDefault") == "qualified"
+
+	for _, c := range xsChildren(root) {
+		switch kind := c.Name.Local; kind {
+		case "import", "include", "redefine", "override":
+			return nil, schemaErr(c, "xs:%s is not supported; schemas must be self-contained", kind)
+		case "element", "complexType", "simpleType", "group", "attributeGroup", "attribute":
+			name := xml.Name{Space: s.targetNS, Local: attr(c, "name")}
+			if name.Local == "" {
+				return nil, schemaErr(c, "top-level xs:%s has no name", kind)
+			}
+			if s.raw[kind] == nil {
+				s.raw[kind] = map[xml.Name]*xmlsafe.Node{}
+			}
+			if _, dup := s.raw[kind][name]; dup {
+				return nil, schemaErr(c, "duplicate xs:%s %q", kind, name.Local)
+			}
+			s.raw[kind][name] = c
+		case "notation":
+		default:
+			return nil, schemaErr(c, "unsupported top-level xs:%s", kind)
+		}
+	}
+
+	// Compile every definition up front so schema errors surface at load.
+	for name := range s.raw["element"] {
+		if _, err := s.globalElement(c0, name); err != nil {
+			return nil, err
+		}
+	}
+	for name := range s.raw["complexType"] {
+		if _, err := s.namedComplex(name); err != nil {
+			return nil, err
+		}
+	}
+	for name := range s.raw["simpleType"] {
+		if _, err := s.namedSimple(name); err != nil {
+			return nil, err
+		}
+	}
+	for name := range s.raw["group"] {
+		if _, err := s.namedGroup(c0, name); err != nil {
+			return nil, err
+		}
+	}
+	for name := range s.raw["attributeGroup"] {
+		if _, err := s.namedAttrGroup(c0, name); err != nil {
+			return nil, err
+		}
+	}
+	if len(s.elements) == 0 {
+		return nil, fmt.Errorf("xsd: schema declares no global elements")
+	}
+	return s, nil
+}
+
+// c0 stands in for the referencing node when compiling top-level
+// definitions, which are never missing.
+var c0 = &xmlsafe.Node{}
+
+func schemaErr(n *xmlsafe.Node, format string, args ...any) error {
+	return fmt.Errorf("xsd: line %d: %s", n.Line, fmt.Sprintf(format, args...))
+}
+
+func attr(n *xmlsafe.Node, name string) string {
+	v, _ := n.Attribute("", name)
+	return strings.TrimSpace(v)
+}
+
+// xsChildren returns the XSD children of n, skipping annotations.
+func xsChildren(n *xmlsafe.Node) []*xmlsafe.Node {
+	var out []*xmlsafe.Node
+	for _, c := range n.Children {
+		if c.Name.Space == nsXSD && c.Name.Local != "annotation" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// qname resolves a QName-valued attribute in the scope of n.
+func qname(n *xmlsafe.Node, v string) xml.Name {
+	prefix, local := "", v
+	if i := strings.IndexByte(v, ':'); i >= 0 {
+		prefix, local = v[:i], v[i+1:]
+	}
+	uri, _ := n.LookupPrefix(prefix)
+	return xml.Name{Space: uri, Local: local}
+}
+
+func occurs(n *xmlsafe.Node) (min, max int, err error) {
+	min, max = 1, 1
+	if v := attr(n, "minOccurs"); v != "" {
+		if min, err = strconv.Atoi(v); err != nil || min < 0 {
+			return 0, 0, schemaErr(n, "invalid minOccurs %q", v)
+		}
+	}
+	if v := attr(n, "maxOccurs"); v == "unbounded" {
+		max = -1
+	} else if v != "" {
+		if max, err = strconv.Atoi(v); err != nil || max < 0 {
+			return 0, 0, schemaErr(n, "invalid maxOccurs %q", v)
+		}
+	}
+	if max >= 0 && min > max {
+		return 0, 0, schemaErr(n, "minOccurs %d exceeds maxOccurs %d", min, max)
+	}
+	return min, max, nil
+}
+
+func (s *Schema) globalElement(from *xmlsafe.Node, name xml.Name) (*elemDecl, error) {
+	if d, ok := s.elements[name]; ok {
+		return d, nil
+	}
+	n, ok := s.raw["element"][name]
+	if !ok {
+		return nil, schemaErr(from, "unknown element %s", fmtName(name))
+	}
+	d := &elemDecl{name: name, nillable: attr(n, "nillable") == "true"}
+	s.elements[name] = d
+	typ, err := s.elementType(n)
+	if err != nil {
+		return nil, err
+	}
+	d.typ = typ
+	if head := attr(n, "substitutionGroup"); head != "" {
+		hn := qname(n, head)
+		if _, err := s.globalElement(n, hn); err != nil {
+			return nil, err
+		}
+		s.substitutes[hn] = append(s.substitutes[hn], d)
+	}
+	return d, nil
+}
+
+func (s *Schema) localElement(n *xmlsafe.Node) (*elemDecl, error) {
+	if ref := attr(n, "ref"); ref != "" {
+		return s.globalElement(n, qname(n, ref))
+	}
+	name := xml.Name{Local: attr(n, "name")}
+	if name.Local == "" {
+		return nil, schemaErr(n, "xs:element needs a name or ref")
+	}
+	if form := attr(n, "form"); form == "qualified" || (form == "" && s.qualifiedElems) {
+		name.Space = s.targetNS
+	}
+	typ, err := s.elementType(n)
+	if err != nil {
+		return nil, err
+	}
+	return &elemDecl{name: name, typ: typ, nillable: attr(n, "nillable") == "true"}, nil
+}
+
+// elementType returns the type of an element declaration: its type
+// attribute, an anonymous type, or xs:anyType.
+func (s *Schema) elementType(n *xmlsafe.Node) (*typeDef, error) {
+	if t := attr(n, "type"); t != "" {
+		return s.lookupType(n, qname(n, t))
+	}
+	for _, c := range xsChildren(n) {
+		switch c.Name.Local {
+		case "complexType":
+			td := &typeDef{}
+			return td, s.compileComplex(c, td)
+		case "simpleType":
+			st, err := s.compileSimple(c)
+			if err != nil {
+				return nil, err
+			}
+			return &typeDef{simple: st}, nil
+		}
+	}
+	return anyTypeDef, nil
+}
+
+// lookupType resolves a type name to a complex type or a simple type
+// wrapped as text-only content.
+func (s *Schema) lookupType(from *xmlsafe.Node, name xml.Name) (*typeDef, error) {
+	if name == (xml.Name{Space: nsXSD, Local: "anyType"}) {
+		return anyTypeDef, nil
+	}
+	if _, ok := s.raw["complexType"][name]; ok {
+		return s.namedComplex(name)
+	}
+	st, err := s.lookupSimple(from, name)
+	if err != nil {
+		return nil, err
+	}
+	return &typeDef{simple: st}, nil
+}
+
+func (s *Schema) namedComplex(name xml.Name) (*typeDef, error) {
+	if td, ok := s.complexTypes[name]; ok {
+		return td, nil
+	}
+	td := &typeDef{}
+	s.complexTypes[name] = td // registered first so recursive types resolve
+	return td, s.compileComplex(s.raw["complexType"][name], td)
+}
+
+func (s *Schema) compileComplex(n *xmlsafe.Node, td *typeDef) error {
+	td.mixed = attr(n, "mixed") == "true"
+	for _, c := range xsChildren(n) {
+		switch c.Name.Local {
+		case "simpleContent":
+			if err := s.compileSimpleContent(c, td); err != nil {
+				return err
+			}
+		case "complexContent":
+			if attr(c, "mixed") == "true" {
+				td.mixed = true
+			}
+			if err := s.compileComplexContent(c, td); err != nil {
+				return err
+			}
+		default:
+			if err := s.compileContentChild(c, td); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compileContentChild adds a model group or attribute use to td.
+func (s *Schema) compileContentChild(c *xmlsafe.Node, td *typeDef) error {
+	switch c.Name.Local {
+	case "sequence", "choice", "all", "group":
+		p, err := s.compileParticle(c)
+		if err != nil {
+			return err
+		}
+		if td.content != nil {
+			return schemaErr(c, "more than one model group in a type")
+		}
+		td.content = p
+	case "attribute":
+		a, err := s.compileAttr(c)
+		if err != nil {
+			return err
+		}
+		td.attrs = mergeAttrs(td.attrs, a)
+	case "attributeGroup":
+		g, err := s.namedAttrGroup(c, qname(c, attr(c, "ref")))
+		if err != nil {
+			return err
+		}
+		for _, a := range g.attrs {
+			td.attrs = mergeAttrs(td.attrs, a)
+		}
+		td.anyAttr = td.anyAttr || g.anyAttr
+	case "anyAttribute":
+		td.anyAttr = true
+	case "assert", "openContent":
+		return schemaErr(c, "xs:%s is not supported", c.Name.Local)
+	default:
+		return schemaErr(c, "unexpected xs:%s in complex type", c.Name.Local)
+	}
+	return nil
+}
+
+// mergeAttrs adds a to attrs, replacing an inherited use of the same name.
+func mergeAttrs(attrs []*attrDecl, a *attrDecl) []*attrDecl {
+	for i, old := range attrs {
+		if old.name == a.name {
+			out := append([]*attrDecl(nil), attrs...)
+			out[i] = a
+			return out
+		}
+	}
+	return append(attrs, a)
+}
+
+func derivation(n *xmlsafe.Node) (*xmlsafe.Node, error) {
+	for _, c := range xsChildren(n) {
+		if c.Name.Local == "extension" || c.Name.Local == "restriction" {
+			return c, nil
+		}
+	}
+	return nil, schemaErr(n, "xs:%s needs an extension or restriction", n.Name.Local)
+}
+
+func (s *Schema) compileComplexContent(n *xmlsafe.Node, td *typeDef) error {
+	d, err := derivation(n)
+	if err != nil {
+		return err
+	}
+	base, err := s.lookupType(d, qname(d, attr(d, "base")))
+	if err != nil {
+		return err
+	}
+	if !base.anyType {
+		td.attrs = append(td.attrs, base.attrs...)
+		td.anyAttr = base.anyAttr
+		td.mixed = td.mixed || base.mixed
+	}
+	for _, c := range xsChildren(d) {
+		if err := s.compileContentChild(c, td); err != nil {
+			return err
+		}
+	}
+	if d.Name.Local == "extension" && base.content != nil {
+		if td.content == nil {
+			td.content = base.content
+		} else {
+			td.content = &particle{kind: pSequence, min: 1, max: 1, children: []*particle{base.content, td.content}}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) compileSimpleContent(n *xmlsafe.Node, td *typeDef) error {
+	d, err := derivation(n)
+	if err != nil {
+		return err
+	}
+	base, err := s.lookupType(d, qname(d, attr(d, "base")))
+	if err != nil {
+		return err
+	}
+	td.simple = base.simple
+	if td.simple == nil {
+		td.simple = builtin("string")
+	}
+	td.attrs = append(td.attrs, base.attrs...)
+	td.anyAttr = base.anyAttr
+
+	var facets []*xmlsafe.Node
+	for _, c := range xsChildren(d) {
+		switch c.Name.Local {
+		case "attribute", "attributeGroup", "anyAttribute":
+			if err := s.compileContentChild(c, td); err != nil {
+				return err
+			}
+		case "simpleType":
+		default:
+			facets = append(facets, c)
+		}
+	}
+	if d.Name.Local == "restriction" && len(facets) > 0 {
+		st := &simpleType{name: td.simple.name, base: td.simple}
+		if err := st.addFacets(facets); err != nil {
+			return err
+		}
+		td.simple = st
+	}
+	return nil
+}
+
+func (s *Schema) compileParticle(n *xmlsafe.Node) (*particle, error) {
+	min, max, err := occurs(n)
+	if err != nil {
+		return nil, err
+	}
+	p := &particle{min: min, max: max}
+	switch n.Name.Local {
+	case "element":
+		p.kind = pElement
+		if p.elem, err = s.localElement(n); err != nil {
+			return nil, err
+		}
+	case "sequence", "choice", "all":
+		p.kind = map[string]particleKind{"sequence": pSequence, "choice": pChoice, "all": pAll}[n.Name.Local]
+		for _, c := range xsChildren(n) {
+			cp, err := s.compileParticle(c)
+			if err != nil {
+				return nil, err
+			}
+			if p.kind == pAll && (cp.kind != pElement || cp.max > 1 || cp.max < 0) {
+				return nil, schemaErr(c, "xs:all may only contain elements with maxOccurs 1")
+			}
+			p.children = append(p.children, cp)
+		}
+	case "group":
+		g, err := s.namedGroup(n, qname(n, attr(n, "ref")))
+		if err != nil {
+			return nil, err
+		}
+		p.kind = pSequence
+		p.children = []*particle{g}
+	case "any":
+		p.kind = pAny
+		p.namespace = attr(n, "namespace")
+		if p.namespace == "" {
+			p.namespace = "##any"
+		}
+		p.process = attr(n, "processContents")
+		if p.process == "" {
+			p.process = "strict"
+		}
+	default:
+		return nil, schemaErr(n, "unexpected xs:%s in model group", n.Name.Local)
+	}
+	return p, nil
+}
+
+func (s *Schema) namedGroup(from *xmlsafe.Node, name xml.Name) (*particle, error) {
+	if g, ok := s.groups[name]; ok {
+		return g, nil
+	}
+	n, ok := s.raw["group"][name]
+	if !ok {
+		return nil, schemaErr(from, "unknown group %s", fmtName(name))
+	}
+	// Registered first so a self-referencing group cannot recurse forever.
+	g := &particle{kind: pSequence, min: 1, max: 1}
+	s.groups[name] = g
+	for _, c := range xsChildren(n) {
+		p, err := s.compileParticle(c)
+		if err != nil {
+			return nil, err
+		}
+		*g = *p
+	}
+	return g, nil
+}
+
+func (s *Schema) namedAttrGroup(from *xmlsafe.Node, name xml.Name) (*typeDef, error) {
+	if g, ok := s.attrGroups[name]; ok {
+		return g, nil
+	}
+	n, ok := s.raw["attributeGroup"][name]
+	if !ok {
+		return nil, schemaErr(from, "unknown attribute group %s", fmtName(name))
+	}
+	g := &typeDef{}
+	s.attrGroups[name] = g
+	for _, c := range xsChildren(n) {
+		if err := s.compileContentChild(c, g); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+func (s *Schema) compileAttr(n *xmlsafe.Node) (*attrDecl, error) {
+	var a *attrDecl
+	if ref := attr(n, "ref"); ref != "" {
+		name := qname(n, ref)
+		g, err := s.globalAttr(n, name)
+		if err != nil {
+			return nil, err
+		}
+		cp := *g
+		a = &cp
+	} else {
+		var err error
+		if a, err = s.attrDef(n, false); err != nil {
+			return nil, err
+		}
+	}
+	switch attr(n, "use") {
+	case "required":
+		a.required = true
+	case "prohibited":
+		a.prohibited = true
+	}
+	if v, ok := n.Attribute("", "fixed"); ok {
+		a.fixed = v
+	}
+	return a, nil
+}
+
+func (s *Schema) globalAttr(from *xmlsafe.Node, name xml.Name) (*attrDecl, error) {
+	if name.Space == nsXML {
+		return &attrDecl{name: name, typ: builtin("string")}, nil
+	}
+	if a, ok := s.attributes[name]; ok {
+		return a, nil
+	}
+	n, ok := s.raw["attribute"][name]
+	if !ok {
+		return nil, schemaErr(from, "unknown attribute %s", fmtName(name))
+	}
+	a, err := s.attrDef(n, true)
+	if err != nil {
+		return nil, err
+	}
+	s.attributes[name] = a
+	return a, nil
+}
+
+func (s *Schema) attrDef(n *xmlsafe.Node, global bool) (*attrDecl, error) {
+	a := &attrDecl{name: xml.Name{Local: attr(n, "name")}}
+	if a.name.Local == "" {
+		return nil, schemaErr(n, "xs:attribute needs a name or ref")
+	}
+	if form := attr(n, "form"); global || form == "qualified" || (form == "" && s.qualifiedAttrs) {
+		a.name.Space = s.targetNS
+	}
+	if t := attr(n, "type"); t != "" {
+		st, err := s.lookupSimple(n, qname(n, t))
+		if err != nil {
+			return nil, err
+		}
+		a.typ = st
+	}
+	for _, c := range xsChildren(n) {
+		if c.Name.Local == "simpleType" {
+			st, err := s.compileSimple(c)
+			if err != nil {
+				return nil, err
+			}
+			a.typ = st
+		}
+	}
+	return a, nil
+}
+
+func fmtName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return "{" + n.Space + "}" + n.Local
+}
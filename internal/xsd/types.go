@@ -0,0 +1,499 @@
+package xsd
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wudi/runway/internal/xmlsafe"
+)
+
+// simpleType is a built-in type or a type derived from one by restriction,
+// list or union. Facets of each derivation step are checked after the
+// step's base.
+type simpleType struct {
+	name    string
+	builtin string // set for built-in types
+	base    *simpleType
+	item    *simpleType   // list item type
+	members []*simpleType // union member types
+
+	enum           []string
+	patterns       []*regexp.Regexp // any may match
+	length         int              // -1 when unset, as are the other counts
+	minLength      int
+	maxLength      int
+	totalDigits    int
+	fractionDigits int
+	minInclusive   string
+	maxInclusive   string
+	minExclusive   string
+	maxExclusive   string
+}
+
+func newSimple(name string) *simpleType {
+	return &simpleType{name: name, length: -1, minLength: -1, maxLength: -1, totalDigits: -1, fractionDigits: -1}
+}
+
+var (
+	reInteger  = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	reDecimal  = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+	reTZ       = `(Z|[+-][0-9]{2}:[0-9]{2})?`
+	reDate     = regexp.MustCompile(`^-?[0-9]{4,}-[0-9]{2}-[0-9]{2}` + reTZ + `$`)
+	reDateTime = regexp.MustCompile(`^-?[0-9]{4,}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?` + reTZ + `$`)
+	reTime     = regexp.MustCompile(`^[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?` + reTZ + `$`)
+	reDuration = regexp.MustCompile(`^-?P([0-9]+Y)?([0-9]+M)?([0-9]+D)?(T([0-9]+H)?([0-9]+M)?([0-9]+(\.[0-9]+)?S)?)?$`)
+	reGYear    = regexp.MustCompile(`^-?[0-9]{4,}` + reTZ + `$`)
+	reGYM      = regexp.MustCompile(`^-?[0-9]{4,}-[0-9]{2}` + reTZ + `$`)
+	reGMonth   = regexp.MustCompile(`^--[0-9]{2}` + reTZ + `$`)
+	reGDay     = regexp.MustCompile(`^---[0-9]{2}` + reTZ + `$`)
+	reGMD      = regexp.MustCompile(`^--[0-9]{2}-[0-9]{2}` + reTZ + `$`)
+	reNCName   = regexp.MustCompile(`^[\pL_][\pL\pN._\-]*$`)
+	reName     = regexp.MustCompile(`^[\pL_:][\pL\pN._:\-]*$`)
+	reNMToken  = regexp.MustCompile(`^[\pL\pN._:\-]+$`)
+	reQName    = regexp.MustCompile(`^([\pL_][\pL\pN._\-]*:)?[\pL_][\pL\pN._\-]*$`)
+	reLanguage = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+)
+
+func intRange(lo, hi string) func(string) bool {
+	min, _ := new(big.Int).SetString(lo, 10)
+	max, _ := new(big.Int).SetString(hi, 10)
+	return func(v string) bool {
+		if !reInteger.MatchString(v) {
+			return false
+		}
+		n, _ := new(big.Int).SetString(strings.TrimPrefix(v, "+"), 10)
+		return (min == nil || n.Cmp(min) >= 0) && (max == nil || n.Cmp(max) <= 0)
+	}
+}
+
+func validDate(v string) bool {
+	// Calendar check for the common four-digit, non-negative year form.
+	if len(v) >= 10 && v[0] != '-' && v[4] == '-' {
+		if _, err := time.Parse("2006-01-02", v[:10]); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func validFloat(v string) bool {
+	switch v {
+	case "INF", "-INF", "+INF", "NaN":
+		return true
+	}
+	if strings.ContainsAny(v, "xXpP_") || strings.EqualFold(v, "inf") || strings.EqualFold(v, "infinity") {
+		return false
+	}
+	_, err := strconv.ParseFloat(v, 64)
+	return err == nil
+}
+
+// builtins maps XSD built-in type names to lexical checks on the
+// whitespace-normalized value.
+var builtins = map[string]func(string) bool{
+	"anySimpleType":      func(string) bool { return true },
+	"string":             func(string) bool { return true },
+	"normalizedString":   func(v string) bool { return !strings.ContainsAny(v, "\r\n\t") },
+	"token":              func(string) bool { return true },
+	"anyURI":             func(v string) bool { return !strings.ContainsAny(v, " <>\"{}|\\^`") },
+	"boolean":            func(v string) bool { return v == "true" || v == "false" || v == "1" || v == "0" },
+	"decimal":            reDecimal.MatchString,
+	"float":              validFloat,
+	"double":             validFloat,
+	"integer":            reInteger.MatchString,
+	"long":               intRange("-9223372036854775808", "9223372036854775807"),
+	"int":                intRange("-2147483648", "2147483647"),
+	"short":              intRange("-32768", "32767"),
+	"byte":               intRange("-128", "127"),
+	"nonNegativeInteger": intRange("0", ""),
+	"positiveInteger":    intRange("1", ""),
+	"nonPositiveInteger": intRange("", "0"),
+	"negativeInteger":    intRange("", "-1"),
+	"unsignedLong":       intRange("0", "18446744073709551615"),
+	"unsignedInt":        intRange("0", "4294967295"),
+	"unsignedShort":      intRange("0", "65535"),
+	"unsignedByte":       intRange("0", "255"),
+	"date":               func(v string) bool { return reDate.MatchString(v) && validDate(v) },
+	"dateTime":           func(v string) bool { return reDateTime.MatchString(v) && validDate(v) },
+	"time":               reTime.MatchString,
+	"duration": func(v string) bool {
+		return reDuration.MatchString(v) && v != "P" && v != "-P" && !strings.HasSuffix(v, "T")
+	},
+	"gYear":      reGYear.MatchString,
+	"gYearMonth": reGYM.MatchString,
+	"gMonth":     reGMonth.MatchString,
+	"gDay":       reGDay.MatchString,
+	"gMonthDay":  reGMD.MatchString,
+	"base64Binary": func(v string) bool {
+		_, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(v), ""))
+		return err == nil
+	},
+	"hexBinary": func(v string) bool { _, err := hex.DecodeString(v); return err == nil },
+	"Name":      reName.MatchString,
+	"NCName":    reNCName.MatchString,
+	"ID":        reNCName.MatchString,
+	"IDREF":     reNCName.MatchString,
+	"ENTITY":    reNCName.MatchString,
+	"NMTOKEN":   reNMToken.MatchString,
+	"QName":     reQName.MatchString,
+	"NOTATION":  reQName.MatchString,
+	"language":  reLanguage.MatchString,
+}
+
+// builtinLists are the built-in list types and their item types.
+var builtinLists = map[string]string{"IDREFS": "IDREF", "ENTITIES": "ENTITY", "NMTOKENS": "NMTOKEN"}
+
+func builtin(name string) *simpleType {
+	st := newSimple("xs:" + name)
+	if item, ok := builtinLists[name]; ok {
+		st.item = builtin(item)
+		return st
+	}
+	st.builtin = name
+	return st
+}
+
+func (s *Schema) lookupSimple(from *xmlsafe.Node, name xml.Name) (*simpleType, error) {
+	if name.Space == nsXSD {
+		if _, ok := builtins[name.Local]; ok {
+			return builtin(name.Local), nil
+		}
+		if _, ok := builtinLists[name.Local]; ok {
+			return builtin(name.Local), nil
+		}
+		return nil, schemaErr(from, "unsupported built-in type xs:%s", name.Local)
+	}
+	if _, ok := s.raw["simpleType"][name]; ok {
+		return s.namedSimple(name)
+	}
+	if _, ok := s.raw["complexType"][name]; ok {
+		return nil, schemaErr(from, "%s is a complex type; a simple type is required", fmtName(name))
+	}
+	return nil, schemaErr(from, "unknown type %s", fmtName(name))
+}
+
+func (s *Schema) namedSimple(name xml.Name) (*simpleType, error) {
+	if st, ok := s.simpleTypes[name]; ok {
+		if st == nil {
+			return nil, schemaErr(s.raw["simpleType"][name], "simple type %s is derived from itself", name.Local)
+		}
+		return st, nil
+	}
+	s.simpleTypes[name] = nil // cycle marker
+	st, err := s.compileSimple(s.raw["simpleType"][name])
+	if err != nil {
+		return nil, err
+	}
+	st.name = name.Local
+	s.simpleTypes[name] = st
+	return st, nil
+}
+
+// inlineOrRef returns the type named by n's attribute, or n's anonymous
+// xs:simpleType child.
+func (s *Schema) inlineOrRef(n *xmlsafe.Node, attrName string) (*simpleType, error) {
+	if t := attr(n, attrName); t != "" {
+		return s.lookupSimple(n, qname(n, t))
+	}
+	for _, c := range xsChildren(n) {
+		if c.Name.Local == "simpleType" {
+			return s.compileSimple(c)
+		}
+	}
+	return nil, schemaErr(n, "xs:%s needs a %s or an anonymous simple type", n.Name.Local, attrName)
+}
+
+func (s *Schema) compileSimple(n *xmlsafe.Node) (*simpleType, error) {
+	st := newSimple(attr(n, "name"))
+	for _, c := range xsChildren(n) {
+		switch c.Name.Local {
+		case "restriction":
+			base, err := s.inlineOrRef(c, "base")
+			if err != nil {
+				return nil, err
+			}
+			st.base = base
+			var facets []*xmlsafe.Node
+			for _, f := range xsChildren(c) {
+				if f.Name.Local != "simpleType" {
+					facets = append(facets, f)
+				}
+			}
+			if err := st.addFacets(facets); err != nil {
+				return nil, err
+			}
+		case "list":
+			item, err := s.inlineOrRef(c, "itemType")
+			if err != nil {
+				return nil, err
+			}
+			st.item = item
+		case "union":
+			for _, m := range strings.Fields(attr(c, "memberTypes")) {
+				mt, err := s.lookupSimple(c, qname(c, m))
+				if err != nil {
+					return nil, err
+				}
+				st.members = append(st.members, mt)
+			}
+			for _, m := range xsChildren(c) {
+				if m.Name.Local == "simpleType" {
+					mt, err := s.compileSimple(m)
+					if err != nil {
+						return nil, err
+					}
+					st.members = append(st.members, mt)
+				}
+			}
+			if len(st.members) == 0 {
+				return nil, schemaErr(c, "xs:union has no member types")
+			}
+		default:
+			return nil, schemaErr(c, "unexpected xs:%s in simple type", c.Name.Local)
+		}
+	}
+	if st.base == nil && st.item == nil && st.members == nil {
+		return nil, schemaErr(n, "simple type needs a restriction, list or union")
+	}
+	return st, nil
+}
+
+func (st *simpleType) addFacets(facets []*xmlsafe.Node) error {
+	for _, f := range facets {
+		v, _ := f.Attribute("", "value")
+		count := func(dst *int) error {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil || n < 0 {
+				return schemaErr(f, "invalid %s %q", f.Name.Local, v)
+			}
+			*dst = n
+			return nil
+		}
+		var err error
+		switch f.Name.Local {
+		case "enumeration":
+			st.enum = append(st.enum, v)
+		case "pattern":
+			re, perr := compilePattern(v)
+			if perr != nil {
+				return schemaErr(f, "unsupported pattern %q: %v", v, perr)
+			}
+			st.patterns = append(st.patterns, re)
+		case "length":
+			err = count(&st.length)
+		case "minLength":
+			err = count(&st.minLength)
+		case "maxLength":
+			err = count(&st.maxLength)
+		case "totalDigits":
+			err = count(&st.totalDigits)
+		case "fractionDigits":
+			err = count(&st.fractionDigits)
+		case "minInclusive":
+			st.minInclusive = strings.TrimSpace(v)
+		case "maxInclusive":
+			st.maxInclusive = strings.TrimSpace(v)
+		case "minExclusive":
+			st.minExclusive = strings.TrimSpace(v)
+		case "maxExclusive":
+			st.maxExclusive = strings.TrimSpace(v)
+		case "whiteSpace":
+		default:
+			return schemaErr(f, "unsupported facet xs:%s", f.Name.Local)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compilePattern translates an XSD regular expression, which is implicitly
+// anchored, to RE2. Multi-character escapes \i and \c are expanded to their
+// ASCII subsets; character class subtraction is not supported.
+func compilePattern(p string) (*regexp.Regexp, error) {
+	inClass := false
+	for i := 0; i < len(p); i++ {
+		switch {
+		case p[i] == '\\':
+			i++
+		case p[i] == '[' && !inClass:
+			inClass = true
+		case p[i] == ']':
+			inClass = false
+		case inClass && strings.HasPrefix(p[i:], "-["):
+			return nil, fmt.Errorf("character class subtraction is not supported")
+		}
+	}
+	r := strings.NewReplacer(`\i`, `[_:A-Za-z]`, `\I`, `[^_:A-Za-z]`, `\c`, `[-._:A-Za-z0-9]`, `\C`, `[^-._:A-Za-z0-9]`)
+	return regexp.Compile(`^(?:` + r.Replace(p) + `)$`)
+}
+
+// primitive returns the built-in type a type is ultimately derived from,
+// or "" for lists and unions.
+func (st *simpleType) primitive() string {
+	for t := st; t != nil; t = t.base {
+		if t.builtin != "" {
+			return t.builtin
+		}
+		if t.item != nil || t.members != nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+func (st *simpleType) isList() bool {
+	for t := st; t != nil; t = t.base {
+		if t.item != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks a raw text value against the type.
+func (st *simpleType) validate(raw string) error {
+	v := raw
+	switch st.primitive() {
+	case "string":
+	case "normalizedString":
+		v = strings.NewReplacer("\r", " ", "\n", " ", "\t", " ").Replace(raw)
+	default:
+		v = strings.Join(strings.Fields(raw), " ")
+	}
+	return st.check(v)
+}
+
+func (st *simpleType) check(v string) error {
+	switch {
+	case st.base != nil:
+		if err := st.base.validate(v); err != nil {
+			return err
+		}
+	case st.item != nil:
+		for _, item := range strings.Fields(v) {
+			if err := st.item.validate(item); err != nil {
+				return err
+			}
+		}
+	case st.members != nil:
+		matched := false
+		for _, m := range st.members {
+			if m.validate(v) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("matches none of the union member types")
+		}
+	case st.builtin != "":
+		if !builtins[st.builtin](v) {
+			return fmt.Errorf("not a valid xs:%s", st.builtin)
+		}
+	}
+	return st.checkFacets(v)
+}
+
+func (st *simpleType) checkFacets(v string) error {
+	if len(st.enum) > 0 {
+		found := false
+		for _, e := range st.enum {
+			if e == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("not one of the allowed values %s", quoteList(st.enum))
+		}
+	}
+	if len(st.patterns) > 0 {
+		matched := false
+		for _, re := range st.patterns {
+			if re.MatchString(v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("does not match pattern %s", strings.TrimSuffix(strings.TrimPrefix(st.patterns[0].String(), "^(?:"), ")$"))
+		}
+	}
+
+	n := utf8.RuneCountInString(v)
+	if st.isList() {
+		n = len(strings.Fields(v))
+	}
+	if st.length >= 0 && n != st.length {
+		return fmt.Errorf("length %d is not %d", n, st.length)
+	}
+	if st.minLength >= 0 && n < st.minLength {
+		return fmt.Errorf("length %d is less than minLength %d", n, st.minLength)
+	}
+	if st.maxLength >= 0 && n > st.maxLength {
+		return fmt.Errorf("length %d exceeds maxLength %d", n, st.maxLength)
+	}
+
+	if st.totalDigits >= 0 || st.fractionDigits >= 0 {
+		intPart, frac, _ := strings.Cut(strings.TrimLeft(v, "+-"), ".")
+		intPart = strings.TrimLeft(intPart, "0")
+		frac = strings.TrimRight(frac, "0")
+		if st.totalDigits >= 0 && len(intPart)+len(frac) > st.totalDigits {
+			return fmt.Errorf("has more than %d digits", st.totalDigits)
+		}
+		if st.fractionDigits >= 0 && len(frac) > st.fractionDigits {
+			return fmt.Errorf("has more than %d fraction digits", st.fractionDigits)
+		}
+	}
+
+	bounds := []struct {
+		limit string
+		ok    func(c int) bool
+		desc  string
+	}{
+		{st.minInclusive, func(c int) bool { return c >= 0 }, "less than"},
+		{st.maxInclusive, func(c int) bool { return c <= 0 }, "greater than"},
+		{st.minExclusive, func(c int) bool { return c > 0 }, "not greater than"},
+		{st.maxExclusive, func(c int) bool { return c < 0 }, "not less than"},
+	}
+	for _, b := range bounds {
+		if b.limit != "" && !b.ok(compareValues(v, b.limit)) {
+			return fmt.Errorf("%s is %s %s", v, b.desc, b.limit)
+		}
+	}
+	return nil
+}
+
+// compareValues orders two values numerically when both are numbers and
+// lexically otherwise, which is correct for same-zone date and time values.
+func compareValues(a, b string) int {
+	ra, okA := new(big.Rat).SetString(strings.TrimPrefix(a, "+"))
+	rb, okB := new(big.Rat).SetString(strings.TrimPrefix(b, "+"))
+	if okA && okB {
+		return ra.Cmp(rb)
+	}
+	return strings.Compare(a, b)
+}
+
+func quoteList(vs []string) string {
+	const max = 10
+	q := make([]string, 0, max)
+	for i, v := range vs {
+		if i == max {
+			q = append(q, "...")
+			break
+		}
+		q = append(q, strconv.Quote(v))
+	}
+	return "[" + strings.Join(q, ", ") + "]"
+}
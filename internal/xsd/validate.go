@@ -0,0 +1,420 @@
+package xsd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/wudi/runway/internal/xmlsafe"
+)
+
+// ValidationError locates the first schema violation in a document.
+type ValidationError struct {
+	Line   int
+	Column int
+	Path   string // slash-separated element path, e.g. /order/items/item[2]
+	Msg    string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s: %s", e.Line, e.Column, e.Path, e.Msg)
+}
+
+func invalid(n *xmlsafe.Node, path, format string, args ...any) *ValidationError {
+	return &ValidationError{Line: n.Line, Column: n.Column, Path: path, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Validate parses data under the process-wide xmlsafe limits and validates
+// it. Parse failures are returned as *xmlsafe.Error, schema violations as
+// *ValidationError.
+func (s *Schema) Validate(data []byte) error {
+	root, err := xmlsafe.Parse(data)
+	if err != nil {
+		return err
+	}
+	return s.ValidateNode(root)
+}
+
+// ValidateNode validates a parsed document. A SOAP envelope is validated
+// by checking each element of its Body against the schema's global
+// elements, unless the schema itself declares the envelope.
+func (s *Schema) ValidateNode(root *xmlsafe.Node) error {
+	path := "/" + root.Name.Local
+	if isSOAPEnvelope(root.Name) && s.elements[root.Name] == nil {
+		return s.validateEnvelope(root, path)
+	}
+	decl := s.elements[root.Name]
+	if decl == nil {
+		return invalid(root, path, "no global element declaration for %s", fmtName(root.Name))
+	}
+	return s.validateElement(root, decl, path)
+}
+
+func isSOAPEnvelope(n xml.Name) bool {
+	return n.Local == "Envelope" && (n.Space == nsSOAP11 || n.Space == nsSOAP12)
+}
+
+func (s *Schema) validateEnvelope(env *xmlsafe.Node, path string) error {
+	for i, c := range env.Children {
+		if c.Name.Space != env.Name.Space || c.Name.Local != "Body" {
+			continue
+		}
+		bodyPath := childPath(path, env.Children, i)
+		for j, part := range c.Children {
+			if part.Name.Space == nsSOAP11 || part.Name.Space == nsSOAP12 {
+				continue // soap:Fault
+			}
+			p := childPath(bodyPath, c.Children, j)
+			decl := s.elements[part.Name]
+			if decl == nil {
+				return invalid(part, p, "no global element declaration for %s", fmtName(part.Name))
+			}
+			if err := s.validateElement(part, decl, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return invalid(env, path, "SOAP envelope has no Body")
+}
+
+// childPath is the path of siblings[i], indexed when its name repeats.
+func childPath(parent string, siblings []*xmlsafe.Node, i int) string {
+	name := siblings[i].Name
+	idx, total := 0, 0
+	for j, s := range siblings {
+		if s.Name == name {
+			total++
+			if j <= i {
+				idx++
+			}
+		}
+	}
+	if total > 1 {
+		return fmt.Sprintf("%s/%s[%d]", parent, name.Local, idx)
+	}
+	return parent + "/" + name.Local
+}
+
+func (s *Schema) validateElement(n *xmlsafe.Node, decl *elemDecl, path string) error {
+	td := decl.typ
+	if td.anyType {
+		return nil
+	}
+	if v, ok := n.Attribute(nsXSI, "nil"); ok && (strings.TrimSpace(v) == "true" || strings.TrimSpace(v) == "1") {
+		if !decl.nillable {
+			return invalid(n, path, "element is not nillable")
+		}
+		if len(n.Children) > 0 || strings.TrimSpace(n.Text) != "" {
+			return invalid(n, path, "nil element must be empty")
+		}
+		return s.validateAttrs(n, td, path)
+	}
+	if err := s.validateAttrs(n, td, path); err != nil {
+		return err
+	}
+
+	if td.simple != nil {
+		if len(n.Children) > 0 {
+			c := n.Children[0]
+			return invalid(c, childPath(path, n.Children, 0), "element %s is not allowed in simple content", c.Name.Local)
+		}
+		if err := td.simple.validate(n.Text); err != nil {
+			return invalid(n, path, "invalid value %q: %v", truncate(n.Text), err)
+		}
+		return nil
+	}
+	if !td.mixed && strings.TrimSpace(n.Text) != "" {
+		return invalid(n, path, "text content is not allowed")
+	}
+	if td.content == nil {
+		if len(n.Children) > 0 {
+			c := n.Children[0]
+			return invalid(c, childPath(path, n.Children, 0), "unexpected element %s; no child elements are allowed", c.Name.Local)
+		}
+		return nil
+	}
+
+	m := &matcher{s: s, kids: n.Children, decls: make([]*elemDecl, len(n.Children)), wild: make([]*particle, len(n.Children)), memo: map[memoKey][]int{}}
+	if !contains(m.match(td.content, 0), len(n.Children)) {
+		return m.failure(n, path)
+	}
+	for i, c := range n.Children {
+		cp := childPath(path, n.Children, i)
+		d := m.decls[i]
+		if d == nil && m.wild[i] != nil && m.wild[i].process != "skip" {
+			d = s.elements[c.Name]
+			if d == nil && m.wild[i].process == "strict" {
+				return invalid(c, cp, "no global element declaration for %s", fmtName(c.Name))
+			}
+		}
+		if d == nil {
+			continue
+		}
+		if err := s.validateElement(c, d, cp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateAttrs(n *xmlsafe.Node, td *typeDef, path string) error {
+	for _, a := range n.Attr {
+		if a.Name.Space == nsXSI || a.Name.Space == nsXML {
+			continue
+		}
+		d := td.attr(a.Name)
+		if d == nil || d.prohibited {
+			if d == nil && td.anyAttr {
+				continue
+			}
+			return invalid(n, path+"/@"+a.Name.Local, "attribute is not allowed")
+		}
+		if d.fixed != "" && strings.TrimSpace(a.Value) != d.fixed {
+			return invalid(n, path+"/@"+a.Name.Local, "value %q must be %q", truncate(a.Value), d.fixed)
+		}
+		if d.typ != nil {
+			if err := d.typ.validate(a.Value); err != nil {
+				return invalid(n, path+"/@"+a.Name.Local, "invalid value %q: %v", truncate(a.Value), err)
+			}
+		}
+	}
+	for _, d := range td.attrs {
+		if !d.required {
+			continue
+		}
+		if _, ok := n.Attribute(d.name.Space, d.name.Local); !ok {
+			return invalid(n, path, "missing required attribute %s", d.name.Local)
+		}
+	}
+	return nil
+}
+
+func truncate(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > 64 {
+		return s[:64] + "..."
+	}
+	return s
+}
+
+type memoKey struct {
+	p   *particle
+	pos int
+}
+
+// matcher matches a sequence of child elements against a content model.
+// It works on sets of end positions, so ambiguous models are explored
+// without backtracking, and records how far matching got for error reports.
+type matcher struct {
+	s     *Schema
+	kids  []*xmlsafe.Node
+	decls []*elemDecl // declaration matched by each child
+	wild  []*particle // wildcard matched by each child
+	memo  map[memoKey][]int
+
+	far      int      // furthest position an element was expected at
+	expected []string // what was expected at far
+	reached  int      // furthest position consumed
+}
+
+// match returns the positions at which p, with its occurrence bounds, can
+// end when started at pos.
+func (m *matcher) match(p *particle, pos int) []int {
+	key := memoKey{p, pos}
+	if ends, ok := m.memo[key]; ok {
+		return ends
+	}
+	m.memo[key] = nil // guards against self-referencing groups
+	var ends []int
+	frontier := []int{pos}
+	for count := 0; len(frontier) > 0; count++ {
+		if count >= p.min {
+			ends = union(ends, frontier)
+		}
+		if p.max >= 0 && count == p.max {
+			break
+		}
+		var next []int
+		for _, s := range frontier {
+			for _, e := range m.matchOnce(p, s) {
+				if e > s {
+					next = union(next, []int{e})
+				} else if count < p.min {
+					// An empty match satisfies the remaining minimum.
+					ends = union(ends, []int{s})
+				}
+			}
+		}
+		frontier = next
+	}
+	m.memo[key] = ends
+	return ends
+}
+
+func (m *matcher) matchOnce(p *particle, pos int) []int {
+	switch p.kind {
+	case pElement:
+		m.expect(pos, p.elem.name.Local)
+		if pos < len(m.kids) {
+			if d := m.s.declFor(p.elem, m.kids[pos].Name); d != nil {
+				if m.decls[pos] == nil {
+					m.decls[pos] = d
+				}
+				m.consume(pos)
+				return []int{pos + 1}
+			}
+		}
+	case pAny:
+		m.expect(pos, "any element")
+		if pos < len(m.kids) && m.s.wildcardAllows(p, m.kids[pos].Name.Space) {
+			if m.wild[pos] == nil {
+				m.wild[pos] = p
+			}
+			m.consume(pos)
+			return []int{pos + 1}
+		}
+	case pSequence:
+		cur := []int{pos}
+		for _, c := range p.children {
+			var next []int
+			for _, s := range cur {
+				next = union(next, m.match(c, s))
+			}
+			if cur = next; len(cur) == 0 {
+				break
+			}
+		}
+		return cur
+	case pChoice:
+		var ends []int
+		for _, c := range p.children {
+			ends = union(ends, m.match(c, pos))
+		}
+		return ends
+	case pAll:
+		used := make([]bool, len(p.children))
+		i := pos
+		for progressed := true; progressed && i < len(m.kids); {
+			progressed = false
+			for j, c := range p.children {
+				if !used[j] && contains(m.match(c, i), i+1) {
+					used[j], progressed = true, true
+					i++
+					break
+				}
+			}
+		}
+		for j, c := range p.children {
+			if !used[j] && c.min > 0 {
+				m.expect(i, c.elem.name.Local)
+				return nil
+			}
+		}
+		return []int{i}
+	}
+	return nil
+}
+
+func (m *matcher) expect(pos int, what string) {
+	if pos > m.far {
+		m.far, m.expected = pos, nil
+	}
+	if pos == m.far && !containsString(m.expected, what) {
+		m.expected = append(m.expected, what)
+	}
+}
+
+func (m *matcher) consume(pos int) {
+	if pos+1 > m.reached {
+		m.reached = pos + 1
+	}
+}
+
+// failure describes why the children of n did not match its content model.
+func (m *matcher) failure(n *xmlsafe.Node, path string) error {
+	pos, expected := m.far, m.expected
+	if m.reached > pos {
+		pos, expected = m.reached, nil
+	}
+	want := ""
+	switch len(expected) {
+	case 0:
+	case 1:
+		want = "; expected " + expected[0]
+	default:
+		want = "; expected one of " + strings.Join(expected, ", ")
+	}
+	if pos < len(m.kids) {
+		c := m.kids[pos]
+		return invalid(c, childPath(path, m.kids, pos), "unexpected element %s%s", c.Name.Local, want)
+	}
+	return invalid(n, path, "missing child element%s", want)
+}
+
+// declFor returns the declaration matching name: decl itself or a member
+// of its substitution group.
+func (s *Schema) declFor(decl *elemDecl, name xml.Name) *elemDecl {
+	if decl.name == name {
+		return decl
+	}
+	for _, sub := range s.substitutes[decl.name] {
+		if d := s.declFor(sub, name); d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *Schema) wildcardAllows(p *particle, ns string) bool {
+	switch p.namespace {
+	case "##any":
+		return true
+	case "##other":
+		return ns != s.targetNS && ns != ""
+	}
+	for _, tok := range strings.Fields(p.namespace) {
+		switch tok {
+		case "##targetNamespace":
+			if ns == s.targetNS {
+				return true
+			}
+		case "##local":
+			if ns == "" {
+				return true
+			}
+		default:
+			if ns == tok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func union(a, b []int) []int {
+	for _, v := range b {
+		if !contains(a, v) {
+			a = append(a, v)
+		}
+	}
+	return a
+}
+
+func contains(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
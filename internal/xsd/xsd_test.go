@@ -0,0 +1,236 @@
+package xsd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/wudi/runway/internal/xmlsafe"
+)
+
+const orderXSD = `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:tns="urn:orders" targetNamespace="urn:orders"
+           elementFormDefault="qualified">
+  <xs:simpleType name="SKU">
+    <xs:restriction base="xs:string">
+      <xs:pattern value="[A-Z]{3}-[0-9]{4}"/>
+    </xs:restriction>
+  </xs:simpleType>
+  <xs:simpleType name="Status">
+    <xs:restriction base="xs:token">
+      <xs:enumeration value="new"/>
+      <xs:enumeration value="paid"/>
+    </xs:restriction>
+  </xs:simpleType>
+  <xs:complexType name="Item">
+    <xs:sequence>
+      <xs:element name="sku" type="tns:SKU"/>
+      <xs:element name="qty">
+        <xs:simpleType>
+          <xs:restriction base="xs:positiveInteger">
+            <xs:maxInclusive value="100"/>
+          </xs:restriction>
+        </xs:simpleType>
+      </xs:element>
+      <xs:element name="note" type="xs:string" minOccurs="0" nillable="true"/>
+    </xs:sequence>
+    <xs:attribute name="gift" type="xs:boolean"/>
+  </xs:complexType>
+  <xs:complexType name="Address">
+    <xs:all>
+      <xs:element name="street" type="xs:string"/>
+      <xs:element name="city" type="xs:string"/>
+    </xs:all>
+  </xs:complexType>
+  <xs:group name="Payment">
+    <xs:choice>
+      <xs:element name="card" type="xs:string"/>
+      <xs:element name="invoice" type="xs:string"/>
+    </xs:choice>
+  </xs:group>
+  <xs:element name="order">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="status" type="tns:Status"/>
+        <xs:element name="item" type="tns:Item" maxOccurs="unbounded"/>
+        <xs:element name="shipTo" type="tns:Address" minOccurs="0"/>
+        <xs:group ref="tns:Payment"/>
+        <xs:any namespace="##other" processContents="skip" minOccurs="0" maxOccurs="unbounded"/>
+      </xs:sequence>
+      <xs:attribute name="id" type="xs:int" use="required"/>
+    </xs:complexType>
+  </xs:element>
+  <xs:element name="ping" type="xs:string"/>
+</xs:schema>`
+
+const validOrder = `<order xmlns="urn:orders" id="42">
+  <status>paid</status>
+  <item gift="true"><sku>ABC-1234</sku><qty>2</qty></item>
+  <item><sku>XYZ-0001</sku><qty>1</qty><note xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:nil="true"/></item>
+  <shipTo><city>Oslo</city><street>Main 1</street></shipTo>
+  <invoice>INV-1</invoice>
+  <ext:trace xmlns:ext="urn:ext">abc</ext:trace>
+</order>`
+
+func mustCompile(t *testing.T, src string) *Schema {
+	t.Helper()
+	s, err := Compile([]byte(src))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	return s
+}
+
+func TestValidateValid(t *testing.T) {
+	s := mustCompile(t, orderXSD)
+	if err := s.Validate([]byte(validOrder)); err != nil {
+		t.Fatalf("expected valid document, got %v", err)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	s := mustCompile(t, orderXSD)
+	tests := []struct {
+		name     string
+		old, new string
+		wantPath string
+		wantMsg  string
+	}{
+		{"bad pattern", "ABC-1234", "abc-1234", "/order/item[1]/sku", "does not match pattern"},
+		{"bad enum", "<status>paid</status>", "<status>lost</status>", "/order/status", "not one of the allowed values"},
+		{"facet bound", "<qty>2</qty>", "<qty>200</qty>", "/order/item[1]/qty", "greater than 100"},
+		{"bad builtin", `gift="true"`, `gift="yes"`, "/order/item[1]/@gift", "not a valid xs:boolean"},
+		{"missing attribute", `id="42"`, ``, "/order", "missing required attribute id"},
+		{"unknown attribute", `id="42"`, `id="42" extra="1"`, "/order/@extra", "attribute is not allowed"},
+		{"unexpected element", "<status>paid</status>", "<state>paid</state>", "/order/state", "unexpected element state; expected status"},
+		{"missing choice", "<invoice>INV-1</invoice>", "", "/order/trace", "expected one of"},
+		{"all missing", "<city>Oslo</city>", "", "/order/shipTo", "missing child element; expected city"},
+		{"text in element content", "<status>paid</status>", "<status>paid</status>stray", "/order", "text content is not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := strings.Replace(validOrder, tt.old, tt.new, 1)
+			err := s.Validate([]byte(doc))
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("expected *ValidationError, got %v", err)
+			}
+			if ve.Path != tt.wantPath || !strings.Contains(ve.Msg, tt.wantMsg) {
+				t.Errorf("expected %s: ...%s..., got %v", tt.wantPath, tt.wantMsg, ve)
+			}
+			if ve.Line == 0 {
+				t.Error("expected a line number")
+			}
+		})
+	}
+}
+
+func TestValidateErrorLocation(t *testing.T) {
+	s := mustCompile(t, orderXSD)
+	doc := strings.Replace(validOrder, "<qty>1</qty>", "<qty>x</qty>", 1)
+	err := s.Validate([]byte(doc))
+	want := "line 4, column 28: /order/item[2]/qty: invalid value \"x\": not a valid xs:positiveInteger"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestValidateSOAPEnvelope(t *testing.T) {
+	s := mustCompile(t, orderXSD)
+	env := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Header><auth>x</auth></soap:Header>
+  <soap:Body><ping xmlns="urn:orders">hi</ping></soap:Body>
+</soap:Envelope>`
+	if err := s.Validate([]byte(env)); err != nil {
+		t.Fatalf("expected valid envelope, got %v", err)
+	}
+	bad := strings.Replace(env, "<ping", "<pong", 1)
+	bad = strings.Replace(bad, "</ping>", "</pong>", 1)
+	err := s.Validate([]byte(bad))
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Path != "/Envelope/Body/pong" {
+		t.Errorf("expected error at /Envelope/Body/pong, got %v", err)
+	}
+}
+
+func TestValidateRejectsMaliciousXML(t *testing.T) {
+	s := mustCompile(t, orderXSD)
+	doc := `<!DOCTYPE order [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><order xmlns="urn:orders">&xxe;</order>`
+	var xe *xmlsafe.Error
+	if err := s.Validate([]byte(doc)); !errors.As(err, &xe) || xe.Violation != xmlsafe.ViolationExternalEntity {
+		t.Errorf("expected external entity violation, got %v", err)
+	}
+}
+
+func TestExtensionAndSubstitution(t *testing.T) {
+	s := mustCompile(t, `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:complexType name="Base">
+    <xs:sequence><xs:element name="a" type="xs:string"/></xs:sequence>
+  </xs:complexType>
+  <xs:complexType name="Derived">
+    <xs:complexContent>
+      <xs:extension base="Base">
+        <xs:sequence><xs:element name="b" type="xs:int"/></xs:sequence>
+        <xs:attribute name="v" type="xs:string" fixed="2"/>
+      </xs:extension>
+    </xs:complexContent>
+  </xs:complexType>
+  <xs:complexType name="Price">
+    <xs:simpleContent>
+      <xs:extension base="xs:decimal">
+        <xs:attribute name="currency" type="xs:string" use="required"/>
+      </xs:extension>
+    </xs:simpleContent>
+  </xs:complexType>
+  <xs:element name="shape" type="xs:string"/>
+  <xs:element name="circle" type="xs:string" substitutionGroup="shape"/>
+  <xs:element name="root">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="d" type="Derived"/>
+        <xs:element name="price" type="Price"/>
+        <xs:element ref="shape" maxOccurs="2"/>
+        <xs:element name="tags">
+          <xs:simpleType><xs:list itemType="xs:int"/></xs:simpleType>
+        </xs:element>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`)
+	valid := `<root><d v="2"><a>x</a><b>1</b></d><price currency="EUR">9.99</price><shape>s</shape><circle>c</circle><tags>1 2 3</tags></root>`
+	if err := s.Validate([]byte(valid)); err != nil {
+		t.Fatalf("expected valid, got %v", err)
+	}
+	for _, bad := range []string{
+		strings.Replace(valid, "<b>1</b>", "", 1),
+		strings.Replace(valid, `v="2"`, `v="3"`, 1),
+		strings.Replace(valid, `currency="EUR"`, ``, 1),
+		strings.Replace(valid, "1 2 3", "1 two 3", 1),
+		strings.Replace(valid, "<circle>c</circle>", "<square>c</square>", 1),
+	} {
+		if err := s.Validate([]byte(bad)); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"not a schema", `<schema/>`, "not xs:schema"},
+		{"import", `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:import namespace="urn:x"/></xs:schema>`, "xs:import is not supported"},
+		{"unknown type", `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:element name="a" type="Nope"/></xs:schema>`, "unknown type"},
+		{"bad pattern", `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:element name="a"><xs:simpleType><xs:restriction base="xs:string"><xs:pattern value="[a-z-[aeiou]]"/></xs:restriction></xs:simpleType></xs:element></xs:schema>`, "unsupported pattern"},
+		{"no elements", `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"/>`, "no global elements"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
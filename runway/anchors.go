@@ -19,6 +19,7 @@ const (
 	// --- CORS & Headers ---
 	MWCORS            = "cors"
 	MWVarContext       = "var_context"
+	MWMultipartFields  = "multipart_fields"
 	MWSecurityHeaders  = "security_headers"
 	MWCDNHeaders       = "cdn_headers"
 	MWErrorPages       = "error_pages"
@@ -47,6 +48,7 @@ const (
 	MWInboundSigning = "inbound_signing"
 	MWIdempotency   = "idempotency"
 	MWDedup         = "dedup"
+	MWContentDedup  = "content_dedup"
 	MWPriority      = "priority"
 	MWBaggage       = "baggage"
 	MWTenant        = "tenant"
@@ -113,6 +115,7 @@ const (
 	MWContentReplacer      = "content_replacer"
 	MWPIIRedact            = "pii_redact"
 	MWFieldReplacer        = "field_replacer"
+	MWResponseFieldPolicy  = "response_field_policy"
 	MWRespBodyGen          = "resp_body_gen"
 	MWErrorHandling        = "error_handling"
 	MWContentNeg           = "content_neg"
@@ -124,6 +127,8 @@ const (
 	MWGlobalHTTPSRedirect   = "https_redirect"
 	MWGlobalAllowedHosts    = "allowed_hosts"
 	MWGlobalRequestID       = "request_id"
+	MWGlobalListenerID      = "listener_id"
+	MWGlobalWarmup          = "warmup"
 	MWGlobalLoadShed        = "load_shed"
 	MWGlobalServiceRateLimit = "service_rate_limit"
 	MWGlobalAltSvc          = "alt_svc"
@@ -2,6 +2,7 @@ package runway
 
 import (
 	"github.com/wudi/runway/config"
+	igw "github.com/wudi/runway/internal/runway"
 )
 
 // Config is the top-level runway configuration.
@@ -19,12 +20,27 @@ type AdminConfig = config.AdminConfig
 // Protocol type alias.
 const ProtocolHTTP = config.ProtocolHTTP
 
-// LoadConfig loads and validates a runway configuration from a YAML file.
+// LoadConfig loads and validates a runway configuration from a YAML file,
+// merging in the files it includes.
 func LoadConfig(path string) (*Config, error) {
 	return config.NewLoader().Load(path)
 }
 
+// LoadConfigDir is LoadConfig with the routes, upstreams and tenants of
+// every .yaml and .yml file in dir also merged in. Reloads re-read dir.
+func LoadConfigDir(path, dir string) (*Config, error) {
+	return config.NewLoader(config.WithConfigDir(dir)).Load(path)
+}
+
 // ParseConfig parses and validates a runway configuration from YAML bytes.
 func ParseConfig(data []byte) (*Config, error) {
 	return config.NewLoader().Parse(data)
 }
+
+// RenderConfig returns cfg as YAML with every route's effective configuration
+// resolved: global defaults merged in, upstream load balancing inherited,
+// tenant tiers applied and secrets masked. With provenance, inherited values
+// carry a "# from: ..." comment.
+func RenderConfig(cfg *Config, provenance bool) ([]byte, error) {
+	return igw.RenderConfig(cfg, provenance)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"slices"
+
+	"github.com/wudi/runway/variables"
 )
 
 // Middleware is a function that wraps an http.Handler.
@@ -26,13 +28,40 @@ type MiddlewareSlot struct {
 	Build  func(routeID string, cfg RouteConfig) Middleware  // return nil to skip for this route
 }
 
+// RouteMetadata returns the metadata of the route serving r, or nil before
+// the route's var_context middleware has run. The map is shared by all
+// requests to the route and must not be modified. At build time the same
+// metadata is available as RouteConfig.Metadata.
+func RouteMetadata(r *http.Request) map[string]string {
+	if varCtx, ok := r.Context().Value(variables.RequestContextKey{}).(*variables.Context); ok {
+		return varCtx.RouteMetadata
+	}
+	return nil
+}
+
 // GlobalMiddlewareSlot defines a custom global middleware and its position
 // in the global handler chain.
+//
+// Each HTTP listener has its own global chain. A slot with Listeners set
+// is only part of the chains of those listeners (by listener ID); it is
+// left out of Server.Handler, which serves no listener. Unknown listener
+// IDs fail at Build() time.
 type GlobalMiddlewareSlot struct {
-	Name   string                     // unique name for this middleware
-	After  string                     // insert after this named middleware
-	Before string                     // insert before this named middleware
-	Build  func(cfg *Config) Middleware // return nil to skip
+	Name      string                     // unique name for this middleware
+	After     string                     // insert after this named middleware
+	Before    string                     // insert before this named middleware
+	Listeners []string                   // listener IDs to run on (empty = all listeners)
+	Build     func(cfg *Config) Middleware // return nil to skip
+}
+
+// ListenerID returns the ID of the listener that received r, or "" for
+// requests served through Server.Handler or before the global chain's
+// listener_id middleware has run.
+func ListenerID(r *http.Request) string {
+	if varCtx, ok := r.Context().Value(variables.RequestContextKey{}).(*variables.Context); ok {
+		return varCtx.ListenerID
+	}
+	return ""
 }
 
 // NamedSlot is a named middleware builder used internally to compose
@@ -3,6 +3,7 @@ package runway
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	igw "github.com/wudi/runway/internal/runway"
@@ -83,6 +84,15 @@ func (b *RunwayBuilder) Build() (*Server, error) {
 		}
 	}
 
+	// Validate listener-scoped global middleware
+	for _, gs := range allGlobalSlots {
+		for _, id := range gs.Listeners {
+			if !slices.ContainsFunc(b.cfg.Listeners, func(lc ListenerConfig) bool { return lc.ID == id }) {
+				return nil, fmt.Errorf("global middleware %q: unknown listener %q", gs.Name, id)
+			}
+		}
+	}
+
 	// Validate custom feature configs
 	for _, f := range b.features {
 		if cv, ok := f.(ConfigValidator); ok {
@@ -174,10 +184,11 @@ func toInternalGlobalSlots(slots []GlobalMiddlewareSlot) []igw.CustomGlobalSlot
 	out := make([]igw.CustomGlobalSlot, len(slots))
 	for i, s := range slots {
 		out[i] = igw.CustomGlobalSlot{
-			Name:   s.Name,
-			After:  s.After,
-			Before: s.Before,
-			Build:  s.Build,
+			Name:      s.Name,
+			After:     s.After,
+			Before:    s.Before,
+			Listeners: s.Listeners,
+			Build:     s.Build,
 		}
 	}
 	return out
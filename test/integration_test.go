@@ -381,8 +381,8 @@ func TestRateLimitIntegration(t *testing.T) {
 	}
 
 	// Check rate limit headers
-	if resp.Header.Get("X-RateLimit-Limit") == "" {
-		t.Error("Missing X-RateLimit-Limit header")
+	if resp.Header.Get("RateLimit-Limit") == "" {
+		t.Error("Missing RateLimit-Limit header")
 	}
 	if resp.Header.Get("Retry-After") == "" {
 		t.Error("Missing Retry-After header")
@@ -0,0 +1,41 @@
+package variables
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BaggageValue returns the value of baggage member key for r. Members
+// registered by the baggage middleware take precedence; on routes without
+// that middleware the inbound W3C baggage header is consulted instead.
+func BaggageValue(r *http.Request, key string) string {
+	if vc, ok := r.Context().Value(RequestContextKey{}).(*Context); ok {
+		if v, ok := vc.GetBaggage(key); ok {
+			return v
+		}
+	}
+	return InboundBaggage(r.Header, key)
+}
+
+// InboundBaggage returns the percent-decoded value of member key from the
+// W3C baggage header(s) in h, or "" if absent.
+func InboundBaggage(h http.Header, key string) string {
+	for _, line := range h.Values("Baggage") {
+		for _, member := range strings.Split(line, ",") {
+			if i := strings.IndexByte(member, ';'); i >= 0 {
+				member = member[:i]
+			}
+			k, v, ok := strings.Cut(member, "=")
+			if !ok || strings.TrimSpace(k) != key {
+				continue
+			}
+			v = strings.TrimSpace(v)
+			if dec, err := url.PathUnescape(v); err == nil {
+				return dec
+			}
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+package variables
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// DefaultMaxBodyFieldsSize is the largest request body read for body fields.
+const DefaultMaxBodyFieldsSize = 1 << 20
+
+// bodyFields wraps a JSON request body so that body fields can be shared by
+// every feature on a route. The body is read and validated once, the first
+// time a field is requested; the buffered bytes are then replayed to the
+// next reader, followed by whatever was left unread. If a downstream reader
+// consumes the body before any field is requested, the bytes it reads are
+// kept (up to maxSize) so later lookups need not read the body again.
+type bodyFields struct {
+	rc      io.ReadCloser
+	maxSize int64
+
+	buf      []byte
+	off      int  // bytes of buf already returned by Read
+	eof      bool // buf holds the whole body
+	streamed bool // Read was called before load
+	loaded   bool // load has run
+	valid    bool // buf is a complete JSON document within maxSize
+
+	fields map[string]gjson.Result
+	value  map[string]any
+	parsed bool // value has been computed
+}
+
+func (b *bodyFields) Read(p []byte) (int, error) {
+	if b.off < len(b.buf) {
+		n := copy(p, b.buf[b.off:])
+		b.off += n
+		return n, nil
+	}
+	n, err := b.rc.Read(p)
+	if !b.loaded {
+		b.streamed = true
+		if int64(len(b.buf)) <= b.maxSize {
+			b.buf = append(b.buf, p[:n]...)
+		}
+		b.off = len(b.buf)
+		b.eof = err == io.EOF
+	}
+	return n, err
+}
+
+func (b *bodyFields) Close() error {
+	return b.rc.Close()
+}
+
+// load buffers up to maxSize+1 bytes of the body, unless a downstream reader
+// already consumed it, and validates the bytes as JSON. It reports whether
+// body fields are available.
+func (b *bodyFields) load() bool {
+	if b.loaded {
+		return b.valid
+	}
+	b.loaded = true
+	if !b.streamed {
+		var err error
+		b.buf, err = io.ReadAll(io.LimitReader(b.rc, b.maxSize+1))
+		b.eof = err == nil
+	}
+	b.valid = b.eof && int64(len(b.buf)) <= b.maxSize && gjson.ValidBytes(b.buf)
+	return b.valid
+}
+
+func (b *bodyFields) get(path string) gjson.Result {
+	if !b.load() {
+		return gjson.Result{}
+	}
+	if res, ok := b.fields[path]; ok {
+		return res
+	}
+	res := gjson.GetBytes(b.buf, path)
+	if b.fields == nil {
+		b.fields = make(map[string]gjson.Result)
+	}
+	b.fields[path] = res
+	return res
+}
+
+func (b *bodyFields) object() map[string]any {
+	if !b.parsed {
+		b.parsed = true
+		if b.load() {
+			b.value, _ = gjson.ParseBytes(b.buf).Value().(map[string]any)
+		}
+	}
+	return b.value
+}
+
+// isJSONContentType reports whether ct is application/json or a +json type.
+func isJSONContentType(ct string) bool {
+	mt, _, _ := strings.Cut(ct, ";")
+	mt = strings.ToLower(strings.TrimSpace(mt))
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// EnableBodyFields makes the fields of r's JSON body available through
+// BodyField and Context.BodyField. The body is not read here: it is read
+// once, up to maxSize bytes, the first time a field is requested, and
+// downstream readers of r.Body still see the complete body. Requests
+// without a JSON Content-Type, or with a compressed body, are left untouched.
+func EnableBodyFields(r *http.Request, maxSize int64) {
+	c, ok := r.Context().Value(RequestContextKey{}).(*Context)
+	if !ok || c.body != nil || r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		return
+	}
+	if ce := r.Header.Get("Content-Encoding"); ce != "" && !strings.EqualFold(ce, "identity") {
+		return
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBodyFieldsSize
+	}
+	c.body = &bodyFields{rc: r.Body, maxSize: maxSize}
+	r.Body = c.body
+}
+
+// BodyField returns the field of r's JSON body at path (gjson syntax). The
+// result is empty when body fields are not enabled for the request (see
+// EnableBodyFields).
+func BodyField(r *http.Request, path string) gjson.Result {
+	if c, ok := r.Context().Value(RequestContextKey{}).(*Context); ok {
+		return c.BodyField(path)
+	}
+	return gjson.Result{}
+}
+
+// BodyField returns the field of the request's JSON body at path (gjson
+// syntax). The result is empty when body fields are not enabled, or the
+// body is not valid JSON, exceeds the size cap, or was only partly read
+// before the first lookup.
+func (c *Context) BodyField(path string) gjson.Result {
+	if c.body == nil {
+		return gjson.Result{}
+	}
+	return c.body.get(path)
+}
+
+// BodyValue returns the request's JSON body as a map for rule expressions,
+// or nil under the same conditions in which BodyField returns no fields.
+// The map is built once per request and must not be modified.
+func (c *Context) BodyValue() map[string]any {
+	if c.body == nil {
+		return nil
+	}
+	return c.body.object()
+}
+
+// ReferencesBody reports whether template uses a $body.<path> variable.
+func ReferencesBody(template string) bool {
+	for _, m := range varPattern.FindAllStringSubmatch(template, -1) {
+		if strings.HasPrefix(m[1], "body.") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package variables
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// countingBody counts reads from the underlying request body.
+type countingBody struct {
+	io.Reader
+	reads int
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	c.reads++
+	return c.Reader.Read(p)
+}
+
+func (c *countingBody) Close() error { return nil }
+
+func newBodyRequest(body, contentType string) (*http.Request, *Context, *countingBody) {
+	cb := &countingBody{Reader: strings.NewReader(body)}
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Body = cb
+	r.Header.Set("Content-Type", contentType)
+	vc := NewContext(r)
+	r = r.WithContext(context.WithValue(r.Context(), RequestContextKey{}, vc))
+	vc.Request = r
+	return r, vc, cb
+}
+
+func TestBodyFields_ReadOnceAndReplayed(t *testing.T) {
+	body := `{"user":{"id":"u-42","tier":"gold"},"items":[1,2,3]}`
+	r, vc, cb := newBodyRequest(body, "application/json; charset=utf-8")
+	EnableBodyFields(r, 0)
+	if cb.reads != 0 {
+		t.Fatal("body read before any field was requested")
+	}
+
+	if got := BodyField(r, "user.id").String(); got != "u-42" {
+		t.Errorf("expected user.id u-42, got %q", got)
+	}
+	reads := cb.reads
+
+	resolver := NewResolver()
+	if got := resolver.Resolve("$body.user.tier/$body.items.#", vc); got != "gold/3" {
+		t.Errorf("expected template gold/3, got %q", got)
+	}
+	if user, _ := vc.BodyValue()["user"].(map[string]any); user["id"] != "u-42" {
+		t.Errorf("expected body value user.id u-42, got %v", vc.BodyValue())
+	}
+	if cb.reads != reads {
+		t.Errorf("expected no further body reads after the first lookup, got %d more", cb.reads-reads)
+	}
+
+	got, err := io.ReadAll(r.Body)
+	if err != nil || string(got) != body {
+		t.Errorf("expected downstream to read the original body, got %q (%v)", got, err)
+	}
+}
+
+func TestBodyFields_ReusesStreamedBody(t *testing.T) {
+	body := `{"order":{"id":7}}`
+	r, vc, _ := newBodyRequest(body, "application/json")
+	EnableBodyFields(r, 0)
+
+	// An earlier middleware reads the whole body before any lookup.
+	if got, _ := io.ReadAll(r.Body); string(got) != body {
+		t.Fatalf("unexpected body %q", got)
+	}
+	if got := vc.BodyField("order.id").Int(); got != 7 {
+		t.Errorf("expected order.id 7 from the streamed body, got %d", got)
+	}
+}
+
+func TestBodyFields_Unavailable(t *testing.T) {
+	t.Run("not json", func(t *testing.T) {
+		r, vc, cb := newBodyRequest(`{"a":1}`, "text/plain")
+		orig := r.Body
+		EnableBodyFields(r, 0)
+		if r.Body != orig {
+			t.Error("expected non-JSON body to be left untouched")
+		}
+		if vc.BodyField("a").Exists() || cb.reads != 0 {
+			t.Error("expected no body fields for a non-JSON body")
+		}
+	})
+
+	t.Run("oversized", func(t *testing.T) {
+		body := `{"a":"` + strings.Repeat("x", 64) + `"}`
+		r, vc, _ := newBodyRequest(body, "application/json")
+		EnableBodyFields(r, 32)
+		if vc.BodyField("a").Exists() || vc.BodyValue() != nil {
+			t.Error("expected no body fields above the size cap")
+		}
+		if got, _ := io.ReadAll(r.Body); string(got) != body {
+			t.Errorf("expected oversized body to be replayed intact, got %d bytes", len(got))
+		}
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		r, vc, _ := newBodyRequest(`{"a":`, "application/json")
+		EnableBodyFields(r, 0)
+		if vc.BodyField("a").Exists() {
+			t.Error("expected no body fields for invalid JSON")
+		}
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		r, vc, cb := newBodyRequest(`{"a":1}`, "application/json")
+		if BodyField(r, "a").Exists() || vc.BodyValue() != nil || cb.reads != 0 {
+			t.Error("expected no body fields when not enabled")
+		}
+		if NewResolver().Resolve("$body.a", vc) != "" {
+			t.Error("expected empty template value when not enabled")
+		}
+	})
+}
+
+func TestReferencesBody(t *testing.T) {
+	tests := map[string]bool{
+		"$body.user.id":         true,
+		"tenant-$body.tenant":   true,
+		"$body_bytes_sent":      false,
+		"$http_body":            false,
+		"body.user.id":          false,
+		"$request_id $body.x.y": true,
+	}
+	for tmpl, want := range tests {
+		if got := ReferencesBody(tmpl); got != want {
+			t.Errorf("ReferencesBody(%q) = %v, want %v", tmpl, got, want)
+		}
+	}
+}
+
+// BenchmarkBodyFields_ThreeFeatures compares three body-referencing features
+// (a rate limit key, a header template and a rules expression) sharing the
+// per-request view against each reading and parsing the body on its own.
+func BenchmarkBodyFields_ThreeFeatures(b *testing.B) {
+	body := `{"user":{"id":"u-42","tier":"gold"},"tenant":"acme","items":[` +
+		strings.Repeat(`{"sku":"abc-123","qty":2},`, 200) + `{"sku":"end","qty":1}]}`
+	resolver := NewResolver()
+	tmpl := resolver.PrecompileTemplate("$body.tenant")
+
+	b.Run("shared", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			r, vc, _ := newBodyRequest(body, "application/json")
+			EnableBodyFields(r, 0)
+			_ = BodyField(r, "user.id").String()
+			_ = tmpl.Resolve(vc)
+			_ = vc.BodyValue()["user"]
+			_, _ = io.Copy(io.Discard, r.Body)
+			ReleaseContext(vc)
+		}
+	})
+
+	b.Run("per_feature", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			r, vc, _ := newBodyRequest(body, "application/json")
+			// Each feature reads, restores and validates the body itself.
+			readBody := func() []byte {
+				data, _ := io.ReadAll(r.Body)
+				r.Body = io.NopCloser(bytes.NewReader(data))
+				if !gjson.ValidBytes(data) {
+					return nil
+				}
+				return data
+			}
+			_ = gjson.GetBytes(readBody(), "user.id").String()
+			_ = gjson.GetBytes(readBody(), "tenant").String()
+			_ = gjson.ParseBytes(readBody()).Value().(map[string]any)["user"]
+			_, _ = io.Copy(io.Discard, r.Body)
+			ReleaseContext(vc)
+		}
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/wudi/runway/internal/ipaddr"
 	"github.com/wudi/runway/internal/middleware/realip"
 )
 
@@ -68,6 +69,8 @@ func (b *BuiltinVariables) Get(name string, ctx *Context) (string, bool) {
 		}
 	case "server_port":
 		return strconv.Itoa(ctx.ServerPort), true
+	case "listener_id":
+		return ctx.ListenerID, true
 	case "scheme":
 		if ctx.Request != nil {
 			if ctx.Request.TLS != nil {
@@ -95,6 +98,10 @@ func (b *BuiltinVariables) Get(name string, ctx *Context) (string, bool) {
 		return strconv.Itoa(ctx.UpstreamStatus), true
 	case "upstream_response_time":
 		return fmt.Sprintf("%.3f", ctx.UpstreamResponseTime.Seconds()*1000), true
+	case "served_by_peer":
+		return ctx.ServedByPeer, true
+	case "fallback_trigger":
+		return ctx.FallbackTrigger, true
 
 	// Response variables
 	case "status":
@@ -117,6 +124,12 @@ func (b *BuiltinVariables) Get(name string, ctx *Context) (string, bool) {
 		return ctx.RouteID, true
 	case "api_version":
 		return ctx.APIVersion, true
+	case "rate_limit_cost":
+		return strconv.Itoa(ctx.RateLimitCost), true
+	case "client_abort":
+		return ctx.ClientAbort.String(), true
+	case "timeout_reason":
+		return ctx.TimeoutReason, true
 
 	// Auth variables
 	case "auth_client_id":
@@ -129,6 +142,8 @@ func (b *BuiltinVariables) Get(name string, ctx *Context) (string, bool) {
 			return ctx.Identity.AuthType, true
 		}
 		return "", true
+	case "consumer_group":
+		return ctx.ConsumerGroup, true
 
 	// Client certificate variables (mTLS)
 	case "client_cert_subject":
@@ -189,6 +204,25 @@ func (b *BuiltinVariables) getDynamic(prefix, suffix string, ctx *Context) (stri
 		if ctx.PathParams != nil {
 			return ctx.PathParams[suffix], true
 		}
+	case "baggage":
+		// $baggage_tenant -> baggage member "tenant"
+		if v, ok := ctx.GetBaggage(suffix); ok {
+			return v, true
+		}
+		if ctx.Request != nil {
+			return InboundBaggage(ctx.Request.Header, suffix), true
+		}
+		return "", true
+	case "body":
+		// $body.user.id -> JSON request body field "user.id"
+		return ctx.BodyField(suffix).String(), true
+	case "form":
+		// $form_tenant_id -> multipart form field "tenant_id"
+		v, _ := ctx.FormField(suffix)
+		return v, true
+	case "route.metadata":
+		// $route.metadata.team -> metadata key "team" of the matched route
+		return ctx.RouteMetadata[suffix], true
 	case "jwt_claim":
 		// $jwt_claim_sub -> JWT claim "sub"
 		if ctx.Identity != nil && ctx.Identity.Claims != nil {
@@ -215,6 +249,7 @@ func (b *BuiltinVariables) AllVariables() []string {
 		"remote_port",
 		"server_addr",
 		"server_port",
+		"listener_id",
 		"scheme",
 		"host",
 		"content_type",
@@ -226,11 +261,15 @@ func (b *BuiltinVariables) AllVariables() []string {
 		"cookie_<name>",
 		"route_param_<name>",
 		"jwt_claim_<name>",
+		"baggage_<name>",
+		"route.metadata.<key>",
 
 		// Upstream
 		"upstream_addr",
 		"upstream_status",
 		"upstream_response_time",
+		"served_by_peer",
+		"fallback_trigger",
 
 		// Response
 		"status",
@@ -245,10 +284,14 @@ func (b *BuiltinVariables) AllVariables() []string {
 		// Route
 		"route_id",
 		"api_version",
+		"rate_limit_cost",
+		"client_abort",
+		"timeout_reason",
 
 		// Auth
 		"auth_client_id",
 		"auth_type",
+		"consumer_group",
 
 		// Client certificate (mTLS)
 		"client_cert_subject",
@@ -293,6 +336,85 @@ const (
 	SkipMirror
 	SkipAccessLog
 	SkipCacheStore
+	SkipSpikeArrest
+	SkipQuota
+)
+
+// ThreatSignals is a bitfield of abuse signals observed on a request. Set by
+// the middleware that observed them and read by reputation scoring once the
+// response is written.
+type ThreatSignals uint32
+
+const (
+	SignalWAF ThreatSignals = 1 << iota
+	SignalAuthFailure
+	SignalRateLimit
+	SignalBot
+)
+
+// AddSignal records s on the request's variable context. It is a no-op when
+// no context is attached, so reporting never allocates.
+func AddSignal(r *http.Request, s ThreatSignals) {
+	if ctx, ok := r.Context().Value(RequestContextKey{}).(*Context); ok {
+		ctx.Signals |= s
+	}
+}
+
+// SetErrorReason records the reason code of an error response the gateway
+// is about to write. It is a no-op when no context is attached.
+func SetErrorReason(r *http.Request, reason string) {
+	if ctx, ok := r.Context().Value(RequestContextKey{}).(*Context); ok {
+		ctx.ErrorReason = reason
+	}
+}
+
+// IsShadow reports whether r is a copy dispatched by mirror shadow_route.
+// Side-effectful features check it to skip shadowed requests.
+func IsShadow(r *http.Request) bool {
+	ctx, ok := r.Context().Value(RequestContextKey{}).(*Context)
+	return ok && ctx.ShadowOf != ""
+}
+
+// IsInternal reports whether r was issued by the gateway itself, such as a
+// cache priming request.
+func IsInternal(r *http.Request) bool {
+	ctx, ok := r.Context().Value(RequestContextKey{}).(*Context)
+	return ok && ctx.Internal
+}
+
+// StatusClientClosedRequest is the status recorded in access logs and
+// metrics for requests the client abandoned before the response was
+// complete. The client never sees it.
+const StatusClientClosedRequest = 499
+
+// AbortPhase records when the client abandoned a request.
+type AbortPhase uint8
+
+const (
+	AbortNone           AbortPhase = iota
+	AbortBeforeBackend             // before the backend was called
+	AbortDuringBackend             // while waiting for the backend response
+	AbortDuringResponse            // while writing the response to the client
+)
+
+// String returns the phase name used in metrics labels and access logs.
+func (p AbortPhase) String() string {
+	switch p {
+	case AbortBeforeBackend:
+		return "before_backend"
+	case AbortDuringBackend:
+		return "during_backend"
+	case AbortDuringResponse:
+		return "during_response"
+	}
+	return ""
+}
+
+// Timeout reasons recorded in Context.TimeoutReason and sent to clients in
+// the X-Timeout-Reason response header.
+const (
+	TimeoutRequest = "request" // the route's timeout_policy.request expired
+	TimeoutBackend = "backend" // a backend call exceeded its deadline
 )
 
 // ValueOverrides holds per-request override values set by rule actions.
@@ -325,6 +447,10 @@ type Context struct {
 	BodyBytesSent        int64
 	ServerPort           int
 
+	// ID of the listener that received the request (set by the global
+	// chain; empty for handlers not bound to a listener)
+	ListenerID string
+
 	// Traffic management
 	TrafficGroup string
 
@@ -334,16 +460,99 @@ type Context struct {
 	// Tenant identification
 	TenantID string
 
+	// Tier of the resolved tenant (set by the tenant middleware)
+	TenantTier string
+
+	// Consumer group of the authenticated identity (set by the
+	// consumer_group middleware)
+	ConsumerGroup string
+
+	// Tokens debited by a cost-based rate limit (0 when not cost-based)
+	RateLimitCost int
+
 	// Access log config (interface{} to avoid import cycle)
 	AccessLogConfig interface{}
 
 	// Trace propagation flag (set by baggage middleware, read by proxy)
 	PropagateTrace bool
 
+	// Outermost client response writer (set by the global chain), used to
+	// forward 1xx informational responses past buffering middleware.
+	// Not copied by Clone.
+	ClientWriter http.ResponseWriter
+
+	// Synthetic monitor probe flag (set by synthetic monitoring middleware,
+	// read by metrics and logging to keep probes out of the main counters)
+	Synthetic bool
+
+	// Internal request flag (set for requests the gateway issues itself,
+	// such as cache priming, so they are kept out of client metrics,
+	// access logs, quotas and webhooks)
+	Internal bool
+
+	// Route that shadowed this copy of a request to its route (set by
+	// mirror shadow_route). Shadowed requests are never mirrored again.
+	ShadowOf string
+
+	// Abuse signals observed on the request (see AddSignal)
+	Signals ThreatSignals
+
+	// Phase in which the client abandoned the request (set by the proxy).
+	// Aborted requests are recorded with StatusClientClosedRequest.
+	ClientAbort AbortPhase
+
+	// Timeout that expired while serving the request (TimeoutRequest or
+	// TimeoutBackend; set by the timeout middleware and the proxy). Timed
+	// out requests are recorded with status 504.
+	TimeoutReason string
+
+	// The response must be aborted rather than completed once the handler
+	// chain returns (set when a request timeout fires after the response
+	// started). Not copied by Clone.
+	AbortResponse bool
+
+	// Name of the peer gateway that served the request (set by peer failover)
+	ServedByPeer string
+
+	// Trigger of a handler fallback that served the request instead of the
+	// route's translator, lambda or amqp handler (set by the fallback)
+	FallbackTrigger string
+
+	// Reason code of an error response the gateway generated itself (see
+	// SetErrorReason), reported in problem+json error responses
+	ErrorReason string
+
+	// The response was served from a cache or a mock rather than generated
+	// by the gateway or received from a backend (set by those middlewares)
+	Relayed bool
+
+	// Error format of the matched route, "legacy" or "problem_json" (set by
+	// the route's errors middleware; empty when the route has none)
+	ErrorFormat string
+
+	// Metadata of the matched route (set by var_context). Shared with the
+	// route config; never modified.
+	RouteMetadata map[string]string
+
+	// Subset of RouteMetadata selected by route_metadata.log_keys, added to
+	// access and audit log entries
+	LogMetadata map[string]string
+
 	// Rule-driven middleware control
 	SkipFlags SkipFlags
 	Overrides *ValueOverrides // nil when no overrides active
 
+	// W3C baggage members that survived the baggage middleware's limits,
+	// keyed by baggage key (see SetBaggage)
+	Baggage map[string]string
+
+	// Lazily parsed JSON request body (see EnableBodyFields). Not copied
+	// by Clone.
+	body *bodyFields
+
+	// Multipart form fields captured by ScanMultipart. Not copied by Clone.
+	form map[string]string
+
 	// Custom values
 	Custom map[string]string
 }
@@ -381,14 +590,36 @@ func ReleaseContext(c *Context) {
 	c.Status = 0
 	c.BodyBytesSent = 0
 	c.ServerPort = 0
+	c.ListenerID = ""
 	c.TrafficGroup = ""
 	c.APIVersion = ""
 	c.TenantID = ""
+	c.TenantTier = ""
+	c.ConsumerGroup = ""
+	c.RateLimitCost = 0
 	c.AccessLogConfig = nil
 	c.PropagateTrace = false
+	c.Synthetic = false
+	c.Internal = false
+	c.ShadowOf = ""
+	c.ClientWriter = nil
+	c.Signals = 0
+	c.ClientAbort = AbortNone
+	c.TimeoutReason = ""
+	c.AbortResponse = false
+	c.ServedByPeer = ""
+	c.FallbackTrigger = ""
+	c.ErrorReason = ""
+	c.Relayed = false
+	c.ErrorFormat = ""
+	c.RouteMetadata = nil
+	c.LogMetadata = nil
 	c.SkipFlags = 0
 	c.Overrides = nil
+	c.body = nil
+	c.form = nil
 	clear(c.Custom)
+	clear(c.Baggage)
 	contextPool.Put(c)
 }
 
@@ -415,11 +646,28 @@ func (c *Context) Clone() *Context {
 	newCtx.Status = c.Status
 	newCtx.BodyBytesSent = c.BodyBytesSent
 	newCtx.ServerPort = c.ServerPort
+	newCtx.ListenerID = c.ListenerID
 	newCtx.TrafficGroup = c.TrafficGroup
 	newCtx.APIVersion = c.APIVersion
 	newCtx.TenantID = c.TenantID
+	newCtx.TenantTier = c.TenantTier
+	newCtx.ConsumerGroup = c.ConsumerGroup
+	newCtx.RateLimitCost = c.RateLimitCost
 	newCtx.AccessLogConfig = c.AccessLogConfig
 	newCtx.PropagateTrace = c.PropagateTrace
+	newCtx.Synthetic = c.Synthetic
+	newCtx.Internal = c.Internal
+	newCtx.ShadowOf = c.ShadowOf
+	newCtx.Signals = c.Signals
+	newCtx.ClientAbort = c.ClientAbort
+	newCtx.TimeoutReason = c.TimeoutReason
+	newCtx.ServedByPeer = c.ServedByPeer
+	newCtx.FallbackTrigger = c.FallbackTrigger
+	newCtx.ErrorReason = c.ErrorReason
+	newCtx.Relayed = c.Relayed
+	newCtx.ErrorFormat = c.ErrorFormat
+	newCtx.RouteMetadata = c.RouteMetadata
+	newCtx.LogMetadata = c.LogMetadata
 	newCtx.SkipFlags = c.SkipFlags
 
 	if c.Overrides != nil {
@@ -445,9 +693,46 @@ func (c *Context) Clone() *Context {
 		}
 	}
 
+	if len(c.Baggage) > 0 {
+		if newCtx.Baggage == nil {
+			newCtx.Baggage = make(map[string]string, len(c.Baggage))
+		}
+		for k, v := range c.Baggage {
+			newCtx.Baggage[k] = v
+		}
+	}
+
 	return newCtx
 }
 
+// RecordedStatus returns the status to record for a response written with
+// status: StatusClientClosedRequest when the client abandoned the request,
+// and 504 when a timeout expired, even if the response had already started.
+func (c *Context) RecordedStatus(status int) int {
+	if c.ClientAbort != AbortNone {
+		return StatusClientClosedRequest
+	}
+	if c.TimeoutReason != "" {
+		return http.StatusGatewayTimeout
+	}
+	return status
+}
+
+// GatewayError reports whether an error response is one the gateway
+// generated itself: no backend answered and it was not relayed from a
+// cache or mock. Handlers relaying a backend status (the proxy, sequential,
+// aggregate and SOAP handlers) record it in UpstreamStatus.
+func (c *Context) GatewayError() bool {
+	return c.UpstreamStatus == 0 && !c.Relayed
+}
+
+// RendersProblem reports whether a gateway error is rendered as problem
+// details by the route's errors middleware, so other error formatters
+// should leave it alone.
+func (c *Context) RendersProblem() bool {
+	return c.ErrorFormat == "problem_json" && c.GatewayError()
+}
+
 // SetCustom sets a custom variable value
 func (c *Context) SetCustom(name, value string) {
 	if c.Custom == nil {
@@ -465,6 +750,23 @@ func (c *Context) GetCustom(name string) (string, bool) {
 	return v, ok
 }
 
+// SetBaggage records a baggage member value under the baggage namespace.
+func (c *Context) SetBaggage(key, value string) {
+	if c.Baggage == nil {
+		c.Baggage = make(map[string]string)
+	}
+	c.Baggage[key] = value
+}
+
+// GetBaggage returns a baggage member value recorded by SetBaggage.
+func (c *Context) GetBaggage(key string) (string, bool) {
+	if c.Baggage == nil {
+		return "", false
+	}
+	v, ok := c.Baggage[key]
+	return v, ok
+}
+
 // RequestContextKey is the context key for storing variable context
 type RequestContextKey struct{}
 
@@ -493,7 +795,8 @@ func FormatBytes(bytes int64) string {
 // ExtractClientIP extracts the real client IP from the request.
 // If the realip middleware has stored a trusted-proxy-aware IP in the
 // request context, that value is returned. Otherwise falls back to
-// X-Forwarded-For, X-Real-IP, and finally RemoteAddr.
+// X-Forwarded-For, X-Real-IP, and finally RemoteAddr. Addresses are
+// normalized by ipaddr.Normalize.
 func ExtractClientIP(r *http.Request) string {
 	// Check for realip middleware result in context
 	if ip := realip.FromContext(r.Context()); ip != "" {
@@ -503,18 +806,24 @@ func ExtractClientIP(r *http.Request) string {
 	// Fallback: legacy behavior (no trusted proxy config)
 	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
 		if i := strings.IndexByte(xff, ','); i > 0 {
-			return strings.TrimSpace(xff[:i])
+			return ipaddr.Normalize(xff[:i])
 		}
-		return strings.TrimSpace(xff)
+		return ipaddr.Normalize(xff)
 	}
 
 	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+		return ipaddr.Normalize(xri)
 	}
 
 	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		return r.RemoteAddr
+		return ipaddr.Normalize(r.RemoteAddr)
 	}
-	return host
+	return ipaddr.Normalize(host)
+}
+
+// ClientIPKey returns the client IP of r as a per-client key, with IPv6
+// addresses aggregated to their ipv6Prefix network (see ipaddr.Key).
+func ClientIPKey(r *http.Request, ipv6Prefix int) string {
+	return ipaddr.Key(ExtractClientIP(r), ipv6Prefix)
 }
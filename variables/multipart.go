@@ -0,0 +1,167 @@
+package variables
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxMultipartScan is the number of body bytes read looking for form
+// fields before giving up.
+const DefaultMaxMultipartScan = 1 << 20
+
+// DefaultMaxMultipartFieldSize is the largest form field value captured.
+const DefaultMaxMultipartFieldSize = 4096
+
+// ErrMultipartScanLimit is returned by ScanMultipart when the scan limit was
+// reached before every requested field was found.
+var ErrMultipartScanLimit = errors.New("multipart scan limit reached before all fields were found")
+
+// MultipartScan configures ScanMultipart.
+type MultipartScan struct {
+	Fields       []string // form field names to capture
+	MaxScan      int64    // body bytes read before giving up (default DefaultMaxMultipartScan)
+	MaxFieldSize int64    // largest captured value (default DefaultMaxMultipartFieldSize)
+}
+
+// scanReader records the bytes read from the body so they can be replayed,
+// and stops with errScanLimit once more than limit bytes have been read.
+type scanReader struct {
+	r     io.Reader
+	buf   bytes.Buffer
+	limit int64
+	hit   bool
+}
+
+var errScanLimit = errors.New("scan limit")
+
+func (s *scanReader) Read(p []byte) (int, error) {
+	remaining := s.limit - int64(s.buf.Len())
+	if remaining <= 0 {
+		s.hit = true
+		return 0, errScanLimit
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := s.r.Read(p)
+	s.buf.Write(p[:n])
+	return n, err
+}
+
+// replayBody yields the bytes read during a scan followed by the unread
+// remainder of the original body.
+type replayBody struct {
+	io.Reader
+	rc io.Closer
+}
+
+func (b *replayBody) Close() error { return b.rc.Close() }
+
+// IsMultipartForm reports whether r has a multipart/form-data body that can
+// be scanned, returning its boundary. Compressed bodies are not scanned.
+func IsMultipartForm(r *http.Request) (string, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return "", false
+	}
+	if ce := r.Header.Get("Content-Encoding"); ce != "" && !strings.EqualFold(ce, "identity") {
+		return "", false
+	}
+	mt, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/form-data" || params["boundary"] == "" {
+		return "", false
+	}
+	return params["boundary"], true
+}
+
+// ScanMultipart reads the parts of r's multipart/form-data body in order and
+// captures the values of the requested text fields, without buffering file
+// parts beyond the scan limit. It stops as soon as every field has been
+// found. r.Body is replaced by the bytes already read followed by the
+// untouched rest of the stream, so the body can still be forwarded in full.
+//
+// Fields larger than MaxFieldSize are not captured. The captured fields are
+// stored on the request's variable context (see FormField). The returned
+// error is ErrMultipartScanLimit when the limit was hit first; a malformed
+// body ends the scan with whatever was captured and no error. Requests that
+// are not multipart forms are left untouched and yield no fields.
+func ScanMultipart(r *http.Request, scan MultipartScan) (map[string]string, error) {
+	boundary, ok := IsMultipartForm(r)
+	if !ok || len(scan.Fields) == 0 {
+		return nil, nil
+	}
+	if scan.MaxScan <= 0 {
+		scan.MaxScan = DefaultMaxMultipartScan
+	}
+	if scan.MaxFieldSize <= 0 {
+		scan.MaxFieldSize = DefaultMaxMultipartFieldSize
+	}
+	wanted := make(map[string]bool, len(scan.Fields))
+	for _, name := range scan.Fields {
+		wanted[name] = true
+	}
+
+	sr := &scanReader{r: r.Body, limit: scan.MaxScan}
+	mr := multipart.NewReader(sr, boundary)
+	fields := make(map[string]string, len(wanted))
+	var err error
+	for len(fields) < len(wanted) {
+		part, perr := mr.NextPart()
+		if perr != nil {
+			if sr.hit {
+				err = ErrMultipartScanLimit
+			}
+			break
+		}
+		name := part.FormName()
+		if !wanted[name] || part.FileName() != "" {
+			continue
+		}
+		if _, dup := fields[name]; dup {
+			continue
+		}
+		val, rerr := io.ReadAll(io.LimitReader(part, scan.MaxFieldSize+1))
+		if rerr != nil {
+			if sr.hit {
+				err = ErrMultipartScanLimit
+			}
+			break
+		}
+		if int64(len(val)) <= scan.MaxFieldSize {
+			fields[name] = string(val)
+		}
+	}
+
+	r.Body = &replayBody{
+		Reader: io.MultiReader(bytes.NewReader(sr.buf.Bytes()), r.Body),
+		rc:     r.Body,
+	}
+	if c, ok := r.Context().Value(RequestContextKey{}).(*Context); ok {
+		c.form = fields
+	}
+	return fields, err
+}
+
+// FormField returns a multipart form field captured by ScanMultipart for r.
+func FormField(r *http.Request, name string) (string, bool) {
+	if c, ok := r.Context().Value(RequestContextKey{}).(*Context); ok {
+		return c.FormField(name)
+	}
+	return "", false
+}
+
+// FormField returns a multipart form field captured by ScanMultipart.
+func (c *Context) FormField(name string) (string, bool) {
+	v, ok := c.form[name]
+	return v, ok
+}
+
+// FormFields returns the multipart form fields captured by ScanMultipart, or
+// nil when the request was not scanned. The map must not be modified.
+func (c *Context) FormFields() map[string]string {
+	return c.form
+}
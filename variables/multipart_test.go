@@ -0,0 +1,154 @@
+package variables
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// byteCountingBody counts the bytes read from the underlying request body.
+type byteCountingBody struct {
+	io.Reader
+	n int64
+}
+
+func (b *byteCountingBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *byteCountingBody) Close() error { return nil }
+
+type formPart struct {
+	name, file string
+	value      []byte
+}
+
+func newMultipartRequest(t *testing.T, parts ...formPart) (*http.Request, *Context, *byteCountingBody, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		var w io.Writer
+		var err error
+		if p.file != "" {
+			w, err = mw.CreateFormFile(p.name, p.file)
+		} else {
+			w, err = mw.CreateFormField(p.name)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(p.value)
+	}
+	mw.Close()
+	raw := buf.Bytes()
+
+	cb := &byteCountingBody{Reader: bytes.NewReader(raw)}
+	r := httptest.NewRequest(http.MethodPost, "/upload", nil)
+	r.Body = cb
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	vc := NewContext(r)
+	r = r.WithContext(context.WithValue(r.Context(), RequestContextKey{}, vc))
+	vc.Request = r
+	return r, vc, cb, raw
+}
+
+func assertBodyReplayed(t *testing.T, r *http.Request, raw []byte) {
+	t.Helper()
+	got, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, raw) {
+		t.Fatalf("forwarded body differs from the original (%d vs %d bytes)", len(got), len(raw))
+	}
+}
+
+func TestScanMultipart_FieldsBeforeUpload(t *testing.T) {
+	upload := bytes.Repeat([]byte("x"), 8<<20)
+	r, vc, cb, raw := newMultipartRequest(t,
+		formPart{name: "tenant_id", value: []byte("acme")},
+		formPart{name: "content_sha256", value: []byte("deadbeef")},
+		formPart{name: "file", file: "big.bin", value: upload},
+	)
+
+	fields, err := ScanMultipart(r, MultipartScan{Fields: []string{"tenant_id", "content_sha256"}, MaxScan: 64 << 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fields["tenant_id"] != "acme" || fields["content_sha256"] != "deadbeef" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	if cb.n > 64<<10 {
+		t.Errorf("expected the scan to stop early, read %d bytes", cb.n)
+	}
+	if v, ok := FormField(r, "tenant_id"); !ok || v != "acme" {
+		t.Errorf("expected tenant_id on the variable context, got %q", v)
+	}
+	if got, _ := NewBuiltinVariables().Get("form_content_sha256", vc); got != "deadbeef" {
+		t.Errorf("expected $form_content_sha256 deadbeef, got %q", got)
+	}
+	assertBodyReplayed(t, r, raw)
+}
+
+func TestScanMultipart_FieldAfterUploadHitsLimit(t *testing.T) {
+	upload := bytes.Repeat([]byte("y"), 2<<20)
+	r, _, cb, raw := newMultipartRequest(t,
+		formPart{name: "file", file: "big.bin", value: upload},
+		formPart{name: "tenant_id", value: []byte("acme")},
+	)
+
+	fields, err := ScanMultipart(r, MultipartScan{Fields: []string{"tenant_id"}, MaxScan: 256 << 10})
+	if !errors.Is(err, ErrMultipartScanLimit) {
+		t.Fatalf("expected ErrMultipartScanLimit, got %v", err)
+	}
+	if _, ok := fields["tenant_id"]; ok {
+		t.Error("expected tenant_id not to be captured")
+	}
+	if cb.n > 256<<10 {
+		t.Errorf("expected at most the scan limit to be read, read %d bytes", cb.n)
+	}
+	assertBodyReplayed(t, r, raw)
+}
+
+func TestScanMultipart_FieldAfterUploadWithinLimit(t *testing.T) {
+	r, _, _, raw := newMultipartRequest(t,
+		formPart{name: "file", file: "small.bin", value: bytes.Repeat([]byte("z"), 10000)},
+		formPart{name: "tenant_id", value: []byte("acme")},
+	)
+	fields, err := ScanMultipart(r, MultipartScan{Fields: []string{"tenant_id"}})
+	if err != nil || fields["tenant_id"] != "acme" {
+		t.Fatalf("expected tenant_id acme, got %v, %v", fields, err)
+	}
+	assertBodyReplayed(t, r, raw)
+}
+
+func TestScanMultipart_OversizedAndFileFieldsSkipped(t *testing.T) {
+	r, _, _, raw := newMultipartRequest(t,
+		formPart{name: "note", value: bytes.Repeat([]byte("n"), 100)},
+		formPart{name: "tenant_id", file: "tenant.txt", value: []byte("evil")},
+	)
+	fields, err := ScanMultipart(r, MultipartScan{Fields: []string{"note", "tenant_id"}, MaxFieldSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fields) != 0 {
+		t.Errorf("expected no fields, got %v", fields)
+	}
+	assertBodyReplayed(t, r, raw)
+}
+
+func TestScanMultipart_NotMultipart(t *testing.T) {
+	r, vc, cb := newBodyRequest(`{"tenant_id":"acme"}`, "application/json")
+	fields, err := ScanMultipart(r, MultipartScan{Fields: []string{"tenant_id"}})
+	if fields != nil || err != nil || cb.reads != 0 || vc.FormFields() != nil {
+		t.Errorf("expected a JSON body to be left untouched, got %v, %v", fields, err)
+	}
+}
@@ -5,8 +5,9 @@ import (
 	"strings"
 )
 
-// varPattern matches $variable_name
-var varPattern = regexp.MustCompile(`\$([a-zA-Z_][a-zA-Z0-9_]*)`)
+// varPattern matches $variable_name, dotted body fields ($body.user.id) and
+// route metadata ($route.metadata.team)
+var varPattern = regexp.MustCompile(`\$(body(?:\.[a-zA-Z0-9_#]+)+|route\.metadata\.[a-zA-Z0-9_]+|[a-zA-Z_][a-zA-Z0-9_]*)`)
 
 // Parser handles variable extraction from strings
 type Parser struct{}
@@ -56,11 +57,17 @@ var dynamicPrefixes = [...]string{
 	"cookie_",
 	"route_param_",
 	"jwt_claim_",
+	"baggage_",
+	"form_",
+	"body.",
+	"route.metadata.",
 }
 
 // ParseDynamic extracts dynamic variable parts
 // e.g., "http_x_custom_header" returns ("http", "x_custom_header")
 // e.g., "arg_page" returns ("arg", "page")
+// e.g., "body.user.id" returns ("body", "user.id")
+// e.g., "route.metadata.team" returns ("route.metadata", "team")
 func ParseDynamic(name string) (prefix, suffix string, ok bool) {
 	for _, p := range dynamicPrefixes {
 		if strings.HasPrefix(name, p) {
@@ -129,6 +129,16 @@ func TestResolverCustomVariables(t *testing.T) {
 	}
 }
 
+func TestResolverRouteMetadata(t *testing.T) {
+	r := NewResolver()
+	ctx := &Context{RouteID: "api", RouteMetadata: map[string]string{"team": "payments"}}
+
+	got := r.Resolve("$route_id owned by $route.metadata.team ($route.metadata.tier).", ctx)
+	if got != "api owned by payments ()." {
+		t.Errorf("unexpected resolution %q", got)
+	}
+}
+
 func TestParser(t *testing.T) {
 	p := NewParser()
 
@@ -213,6 +223,8 @@ func TestParseDynamic(t *testing.T) {
 		{"cookie_session_id", "cookie", "session_id", true},
 		{"route_param_user_id", "route_param", "user_id", true},
 		{"jwt_claim_sub", "jwt_claim", "sub", true},
+		{"body.user.id", "body", "user.id", true},
+		{"route.metadata.team", "route.metadata", "team", true},
 		{"request_id", "", "", false},
 		{"unknown_var", "", "", false},
 	}
@@ -87,6 +87,7 @@ func TestBuiltinVariables_StaticVars(t *testing.T) {
 		UpstreamResponseTime: 50 * time.Millisecond,
 		ResponseTime:         55 * time.Millisecond,
 		ServerPort:           8080,
+		ListenerID:           "public",
 		APIVersion:           "v2",
 		Identity: &Identity{
 			ClientID: "client-abc",
@@ -123,6 +124,7 @@ func TestBuiltinVariables_StaticVars(t *testing.T) {
 		{"upstream_addr", "10.0.0.1:8080", true},
 		{"upstream_status", "200", true},
 		{"server_port", "8080", true},
+		{"listener_id", "public", true},
 		{"auth_client_id", "client-abc", true},
 		{"auth_type", "jwt", true},
 		{"client_cert_subject", "CN=client", true},
@@ -284,6 +286,35 @@ func TestGetDynamic_JWTClaim(t *testing.T) {
 	}
 }
 
+func TestGetDynamic_Baggage(t *testing.T) {
+	b := NewBuiltinVariables()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Baggage", "tenant=acme,region=eu%2Dwest")
+	ctx := &Context{Request: req}
+
+	// Before the baggage middleware runs, the inbound header is consulted
+	val, ok := b.Get("baggage_region", ctx)
+	if !ok || val != "eu-west" {
+		t.Errorf("baggage_region = (%q, %v), want (%q, true)", val, ok, "eu-west")
+	}
+
+	// Registered members take precedence
+	ctx.SetBaggage("tenant", "globex")
+	val, ok = b.Get("baggage_tenant", ctx)
+	if !ok || val != "globex" {
+		t.Errorf("baggage_tenant = (%q, %v), want (%q, true)", val, ok, "globex")
+	}
+
+	// Missing member
+	val, ok = b.Get("baggage_missing", ctx)
+	if !ok {
+		t.Error("baggage_missing should return ok=true")
+	}
+	if val != "" {
+		t.Errorf("baggage_missing = %q, want empty", val)
+	}
+}
+
 func TestContextPoolLifecycle(t *testing.T) {
 	req := httptest.NewRequest("GET", "/", nil)
 	ctx := AcquireContext(req)
@@ -312,6 +343,20 @@ func TestContextPoolLifecycle(t *testing.T) {
 	}
 }
 
+func TestContextPoolReusesMaps(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	cycle := func() {
+		c := AcquireContext(req)
+		c.SetCustom("tier", "gold")
+		c.SetBaggage("env", "prod")
+		ReleaseContext(c)
+	}
+	cycle() // allocates the maps once
+	if allocs := testing.AllocsPerRun(100, cycle); allocs != 0 {
+		t.Errorf("pooled context allocates %v per request, want 0", allocs)
+	}
+}
+
 func TestReleaseContextNil(t *testing.T) {
 	// Should not panic
 	ReleaseContext(nil)